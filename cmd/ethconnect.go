@@ -20,7 +20,9 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"reflect"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 
@@ -41,6 +43,7 @@ import (
 // to run with a set of individual commands as goroutines
 // (rather than the simple commandline mode that runs a single command)
 type ServerConfig struct {
+	Version      int                               `json:"version,omitempty"`
 	KafkaBridges map[string]*kafka.KafkaBridgeConf `json:"kafka"`
 	Webhooks     map[string]*rest.RESTGatewayConf  `json:"webhooks"`
 	RESTGateways map[string]*rest.RESTGatewayConf  `json:"rest"`
@@ -70,14 +73,23 @@ func initLogging(debugLevel int) {
 }
 
 var rootConfig struct {
-	DebugLevel int
-	DebugPort  int
-	PrintYAML  bool
+	DebugLevel    int
+	DebugPort     int
+	PrintYAML     bool
+	SlowStoreOpMS int
 }
 
+// currentConfigVersion is the latest version of the ServerConfig schema.
+// A config file without a version is treated as version 1.
+const currentConfigVersion = 1
+
+// configEnvPrefix is the prefix of environment variables that overlay the config file
+const configEnvPrefix = "ETHCONNECT_CONFIG"
+
 var serverCmdConfig struct {
-	Filename string
-	Type     string
+	Filename       string
+	Type           string
+	ValidateConfig bool
 }
 
 var rootCmd = &cobra.Command{
@@ -85,6 +97,7 @@ var rootCmd = &cobra.Command{
 	Short: "Connectivity Bridge for Ethereum permissioned chains",
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		initLogging(rootConfig.DebugLevel)
+		utils.SetSlowStoreOpThreshold(time.Duration(rootConfig.SlowStoreOpMS) * time.Millisecond)
 
 		if rootConfig.DebugPort > 0 {
 			go func() {
@@ -116,6 +129,7 @@ func initServer() (serverCmd *cobra.Command) {
 	}
 	serverCmd.Flags().StringVarP(&serverCmdConfig.Filename, "filename", "f", os.Getenv("ETHCONNECT_CONFIGFILE"), "Configuration file")
 	serverCmd.Flags().StringVarP(&serverCmdConfig.Type, "type", "t", defType, "File type (json/yaml)")
+	serverCmd.Flags().BoolVarP(&serverCmdConfig.ValidateConfig, "validate-config", "", false, "Validate the configuration and exit, without starting any bridges")
 	return
 }
 
@@ -125,6 +139,7 @@ func readServerConfig() (serverConfig *ServerConfig, err error) {
 		err = errors.Errorf(errors.ConfigFileReadFailed, serverCmdConfig.Filename, err)
 		return
 	}
+	var genericPayload map[string]interface{}
 	if strings.ToLower(serverCmdConfig.Type) == "yaml" {
 		// Convert to JSON first
 		yamlGenericPayload := make(map[interface{}]interface{})
@@ -132,16 +147,37 @@ func readServerConfig() (serverConfig *ServerConfig, err error) {
 			err = errors.Errorf(errors.ConfigYAMLParseFile, serverCmdConfig.Filename, err)
 			return
 		}
-		genericPayload := dyno.ConvertMapI2MapS(yamlGenericPayload).(map[string]interface{})
-		// Reseialize back to JSON
-		confBytes, _ = json.Marshal(&genericPayload)
+		genericPayload = dyno.ConvertMapI2MapS(yamlGenericPayload).(map[string]interface{})
+	} else if err = json.Unmarshal(confBytes, &genericPayload); err != nil {
+		err = errors.Errorf(errors.ConfigYAMLPostParseFile, serverCmdConfig.Filename, err)
+		return
+	}
+	if genericPayload == nil {
+		genericPayload = make(map[string]interface{})
+	}
+
+	// Environment variables take precedence over the file
+	configType := reflect.TypeOf(ServerConfig{})
+	if err = utils.ApplyConfigEnvOverlay(genericPayload, configType, configEnvPrefix, os.Environ()); err != nil {
+		return
 	}
+	if unknown := utils.ConfigUnknownFields(genericPayload, configType); len(unknown) > 0 {
+		err = errors.Errorf(errors.ConfigUnknownKeys, serverCmdConfig.Filename, strings.Join(unknown, ", "))
+		return
+	}
+
+	// Reseialize back to JSON
+	confBytes, _ = json.Marshal(&genericPayload)
 	serverConfig = &ServerConfig{}
 	err = json.Unmarshal(confBytes, serverConfig)
 	if err != nil {
 		err = errors.Errorf(errors.ConfigYAMLPostParseFile, serverCmdConfig.Filename, err)
 		return
 	}
+	if serverConfig.Version < 0 || serverConfig.Version > currentConfigVersion {
+		err = errors.Errorf(errors.ConfigUnsupportedVersion, serverConfig.Version, currentConfigVersion)
+		return
+	}
 
 	// Load any plugins
 	err = loadPlugins(&serverConfig.Plugins)
@@ -162,6 +198,10 @@ func startServer() (err error) {
 		return err
 	}
 
+	if serverCmdConfig.ValidateConfig {
+		return validateServerConfig(serverConfig)
+	}
+
 	anyRoutineFinished := make(chan bool)
 	var dontPrintYaml = false
 
@@ -173,6 +213,7 @@ func startServer() (err error) {
 		serverConfig.RESTGateways[name] = conf
 	}
 	var idempotencyCheckReceiptStore receipts.ReceiptStorePersistence
+	var localReplyHandler kafka.LocalReplyHandler
 	restGateways := make(map[string]*rest.RESTGateway)
 	for name, conf := range serverConfig.RESTGateways {
 		restGateway := rest.NewRESTGateway(&dontPrintYaml)
@@ -187,11 +228,13 @@ func startServer() (err error) {
 		// - Single Kafka bridge co-located in the same process
 		// In this scenario, we can pass the receipt store to the Kafka bridge for it to do
 		// additional idempotency checks that prevent res-submission of transactions.
+		// Replies to requests that opt out of the reply topic are also recorded directly in it.
 		if idempotencyCheckReceiptStore == nil {
 			idempotencyCheckReceiptStore, err = restGateway.Init()
 			if err != nil {
 				return err
 			}
+			localReplyHandler = restGateway
 		}
 
 	}
@@ -203,6 +246,9 @@ func startServer() (err error) {
 		if err := kafkaBridge.ValidateConf(); err != nil {
 			return err
 		}
+		if localReplyHandler != nil {
+			kafkaBridge.SetLocalReplyHandler(localReplyHandler)
+		}
 		go func(name string, anyRoutineFinished chan bool) {
 			log.Infof("Starting Kafka->Ethereum bridge '%s'", name)
 			if err := kafkaBridge.Start(idempotencyCheckReceiptStore); err != nil {
@@ -230,14 +276,41 @@ func startServer() (err error) {
 	return
 }
 
+// validateServerConfig performs the validation each bridge performs at startup, without starting them
+func validateServerConfig(serverConfig *ServerConfig) error {
+	var dontPrintYaml = false
+	for _, confs := range []map[string]*rest.RESTGatewayConf{serverConfig.RESTGateways, serverConfig.Webhooks} {
+		for name, conf := range confs {
+			restGateway := rest.NewRESTGateway(&dontPrintYaml)
+			restGateway.SetConf(conf)
+			if err := restGateway.ValidateConf(); err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+		}
+	}
+	for name, conf := range serverConfig.KafkaBridges {
+		kafkaBridge := kafka.NewKafkaBridge(&dontPrintYaml)
+		kafkaBridge.SetConf(conf)
+		if err := kafkaBridge.ValidateConf(); err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+	}
+	fmt.Printf("Configuration in %s is valid\n", serverCmdConfig.Filename)
+	return nil
+}
+
 func init() {
 	rootCmd.PersistentFlags().IntVarP(&rootConfig.DebugLevel, "debug", "d", 1, "0=error, 1=info, 2=debug")
 	rootCmd.PersistentFlags().IntVarP(&rootConfig.DebugPort, "debugPort", "Z", 6060, "Port for pprof HTTP endpoints (localhost only)")
 	rootCmd.PersistentFlags().BoolVarP(&rootConfig.PrintYAML, "print-yaml-confg", "Y", false, "Print YAML config snippet and exit")
+	rootCmd.PersistentFlags().IntVarP(&rootConfig.SlowStoreOpMS, "slowStoreOpMS", "", 1000, "Log operations on receipt and LevelDB stores that take longer than this")
 
 	serverCmd := initServer()
 	rootCmd.AddCommand(serverCmd)
 
+	rootCmd.AddCommand(initGenAPI())
+	rootCmd.AddCommand(initLoadTest())
+
 	kafkaBridge := kafka.NewKafkaBridge(&rootConfig.PrintYAML)
 	rootCmd.AddCommand(kafkaBridge.CobraInit())
 
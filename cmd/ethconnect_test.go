@@ -17,6 +17,7 @@ package cmd
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 	"syscall"
 	"testing"
 
@@ -121,13 +122,15 @@ func TestExecuteServerWithYAML(t *testing.T) {
 	ioutil.WriteFile(exampleConfYAML.Name(), []byte(
 		"kafka:\n"+
 			"  kbridge1:\n"+
-			"    topicIn: in1\n"+
-			"    topicOut: out1\n"+
+			"    kafka:\n"+
+			"      topicIn: in1\n"+
+			"      topicOut: out1\n"+
 			"    rpc:\n"+
 			"      url: http://ethereum1\n"+
 			"  kbridge2:\n"+
-			"    topicIn: in2\n"+
-			"    topicOut: out2\n"+
+			"    kafka:\n"+
+			"      topicIn: in2\n"+
+			"      topicOut: out2\n"+
 			"    rpc:\n"+
 			"      url: http://ethereum1\n"+
 			"webhooks: # legacy naming\n"+
@@ -156,8 +159,10 @@ func TestExecuteServerWithJSON(t *testing.T) {
 	defer syscall.Unlink(exampleConfYAML.Name())
 	testJSON := "{ \"kafka\": {\n" +
 		"  \"kbridge1\": {\n" +
-		"    \"topicIn\": \"in1\",\n" +
-		"    \"topicOut\": \"out1\",\n" +
+		"    \"kafka\": {\n" +
+		"      \"topicIn\": \"in1\",\n" +
+		"      \"topicOut\": \"out1\"\n" +
+		"    },\n" +
 		"    \"rpc\": {\n" +
 		"      \"url\": \"http://ethereum1\"\n" +
 		"      }\n" +
@@ -180,8 +185,10 @@ func TestExecuteServerWithIncompleteKafka(t *testing.T) {
 	defer syscall.Unlink(exampleConfYAML.Name())
 	testJSON := "{ \"kafka\": {\n" +
 		"  \"kbridge1\": {\n" +
-		"    \"topicIn\": \"in1\",\n" +
-		"    \"topicOut\": \"out1\"\n" +
+		"    \"kafka\": {\n" +
+		"      \"topicIn\": \"in1\",\n" +
+		"      \"topicOut\": \"out1\"\n" +
+		"    }\n" +
 		"   }\n" +
 		"  }\n" +
 		"}\n"
@@ -206,3 +213,103 @@ func TestExecuteServerWithBadYAML(t *testing.T) {
 
 	assert.Equal(1, osExit)
 }
+
+func TestExecuteServerUnknownKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	exampleConfYAML, _ := ioutil.TempFile("", "testYAML")
+	defer syscall.Unlink(exampleConfYAML.Name())
+	ioutil.WriteFile(exampleConfYAML.Name(), []byte(
+		"rest:\n"+
+			"  wbridge1:\n"+
+			"    http:\n"+
+			"      prot: 1234\n"), 0644)
+
+	serverCmdConfig.Filename = exampleConfYAML.Name()
+	serverCmdConfig.Type = "yaml"
+	_, err := readServerConfig()
+	assert.Regexp("FFEC100313.*rest.wbridge1.http.prot", err)
+}
+
+func TestExecuteServerUnsupportedVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	exampleConfYAML, _ := ioutil.TempFile("", "testJSON")
+	defer syscall.Unlink(exampleConfYAML.Name())
+	ioutil.WriteFile(exampleConfYAML.Name(), []byte(`{"version": 2}`), 0644)
+
+	serverCmdConfig.Filename = exampleConfYAML.Name()
+	serverCmdConfig.Type = "json"
+	_, err := readServerConfig()
+	assert.Regexp("FFEC100314", err)
+
+	ioutil.WriteFile(exampleConfYAML.Name(), []byte(`{"version": "1"}`), 0644)
+	_, err = readServerConfig()
+	assert.Regexp("FFEC100026", err)
+
+	ioutil.WriteFile(exampleConfYAML.Name(), []byte(`not json`), 0644)
+	_, err = readServerConfig()
+	assert.Regexp("FFEC100026", err)
+}
+
+func TestExecuteServerEnvOverlay(t *testing.T) {
+	assert := assert.New(t)
+
+	exampleConfYAML, _ := ioutil.TempFile("", "testYAML")
+	defer syscall.Unlink(exampleConfYAML.Name())
+	ioutil.WriteFile(exampleConfYAML.Name(), []byte(
+		"version: 1\n"+
+			"rest:\n"+
+			"  wbridge1:\n"+
+			"    http:\n"+
+			"      port: 1234\n"), 0644)
+
+	os.Setenv("ETHCONNECT_CONFIG__REST__WBRIDGE1__HTTP__PORT", "5678")
+	defer os.Unsetenv("ETHCONNECT_CONFIG__REST__WBRIDGE1__HTTP__PORT")
+	serverCmdConfig.Filename = exampleConfYAML.Name()
+	serverCmdConfig.Type = "yaml"
+	serverConfig, err := readServerConfig()
+	assert.NoError(err)
+	assert.Equal(5678, serverConfig.RESTGateways["wbridge1"].HTTP.Port)
+
+	os.Setenv("ETHCONNECT_CONFIG__REST__WBRIDGE1__HTTP__PROT", "5678")
+	defer os.Unsetenv("ETHCONNECT_CONFIG__REST__WBRIDGE1__HTTP__PROT")
+	_, err = readServerConfig()
+	assert.Regexp("FFEC100315", err)
+}
+
+func TestExecuteServerValidateConfig(t *testing.T) {
+	assert := assert.New(t)
+	rootConfig.PrintYAML = false
+	defer func() { serverCmdConfig.ValidateConfig = false }()
+
+	exampleConfYAML, _ := ioutil.TempFile("", "testYAML")
+	defer syscall.Unlink(exampleConfYAML.Name())
+	ioutil.WriteFile(exampleConfYAML.Name(), []byte(
+		"kafka:\n"+
+			"  kbridge1:\n"+
+			"    kafka:\n"+
+			"      brokers: [broker1]\n"+
+			"      consumerGroup: cg1\n"+
+			"      topicIn: in1\n"+
+			"      topicOut: out1\n"+
+			"    rpc:\n"+
+			"      url: http://ethereum1\n"+
+			"rest:\n"+
+			"  wbridge1:\n"+
+			"    http:\n"+
+			"      port: 1234\n"), 0644)
+
+	rootCmd.SetArgs([]string{"server", "-t", "yaml", "-f", exampleConfYAML.Name(), "--validate-config"})
+	osExit := Execute()
+	assert.Equal(0, osExit)
+
+	ioutil.WriteFile(exampleConfYAML.Name(), []byte(
+		"kafka:\n"+
+			"  kbridge1:\n"+
+			"    kafka:\n"+
+			"      topicOut: out1\n"), 0644)
+	rootCmd.SetArgs([]string{"server", "-t", "yaml", "-f", exampleConfYAML.Name(), "--validate-config"})
+	osExit = Execute()
+	assert.Equal(1, osExit)
+}
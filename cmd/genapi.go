@@ -0,0 +1,193 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/eth"
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	"github.com/hyperledger/firefly-ethconnect/internal/openapi"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/spf13/cobra"
+)
+
+var genAPIConfig struct {
+	ContractName    string
+	CompilerVersion string
+	EVMVersion      string
+	APIName         string
+	Address         string
+	Path            string
+	BaseURL         string
+	FactoryOnly     bool
+	Output          string
+	DevDocsOutput   string
+}
+
+// genAPIInput is the ABI and NatSpec docs of a contract, either compiled from Solidity or loaded from JSON
+type genAPIInput struct {
+	ContractName string
+	ABI          ethbinding.ABIMarshaling
+	DevDoc       string
+	UserDoc      string
+}
+
+func initGenAPI() (genAPICmd *cobra.Command) {
+	genAPICmd = &cobra.Command{
+		Use:   "genapi [solidity or ABI JSON file]",
+		Short: "Generates the OpenAPI definition for a contract, without running a server",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			return genAPI(args[0], cmd.OutOrStdout())
+		},
+	}
+	genAPICmd.Flags().StringVarP(&genAPIConfig.ContractName, "contract", "c", "", "Contract name to select, when the Solidity file contains more than one contract")
+	genAPICmd.Flags().StringVarP(&genAPIConfig.CompilerVersion, "compiler", "v", "", "Solidity compiler version to use (see SOLC_x_y env vars)")
+	genAPICmd.Flags().StringVarP(&genAPIConfig.EVMVersion, "evm-version", "e", "", "EVM version to compile for")
+	genAPICmd.Flags().StringVarP(&genAPIConfig.APIName, "name", "n", "", "API name for the title of the definition (defaults to the contract name)")
+	genAPICmd.Flags().StringVarP(&genAPIConfig.Address, "address", "a", "", "Generate an API for a deployed instance at this address, rather than a factory")
+	genAPICmd.Flags().StringVarP(&genAPIConfig.Path, "path", "p", "", "Base path of the API (default /contracts/{address} or /abis/{name})")
+	genAPICmd.Flags().StringVarP(&genAPIConfig.BaseURL, "openapi-baseurl", "U", "http://localhost:8080", "Base URL of the gateway for the generated definition")
+	genAPICmd.Flags().BoolVarP(&genAPIConfig.FactoryOnly, "factory-only", "F", false, "Only include the constructor in a factory definition")
+	genAPICmd.Flags().StringVarP(&genAPIConfig.Output, "output", "o", "", "File to write the OpenAPI definition to (default stdout)")
+	genAPICmd.Flags().StringVarP(&genAPIConfig.DevDocsOutput, "devdocs", "D", "", "File to write the devdocs of the contract to")
+	return
+}
+
+// loadGenAPIInput accepts a bare ABI array, or an object containing "abi" and optional
+// "devdoc" and "userdoc" fields (as produced by most build tools)
+func loadGenAPIInput(filename string) (*genAPIInput, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Errorf(errors.ConfigFileReadFailed, filename, err)
+	}
+	ext := filepath.Ext(filename)
+	input := &genAPIInput{
+		ContractName: strings.TrimSuffix(filepath.Base(filename), ext),
+	}
+	if strings.ToLower(ext) == ".sol" {
+		compiled, err := eth.CompileContract(string(b), genAPIConfig.ContractName, genAPIConfig.CompilerVersion, genAPIConfig.EVMVersion)
+		if err != nil {
+			return nil, err
+		}
+		input.ContractName = compiled.ContractName
+		input.ABI = compiled.ABI
+		input.DevDoc = compiled.DevDoc
+		input.UserDoc = compiled.UserDoc
+		return input, nil
+	}
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(b, &input.ABI)
+	} else {
+		var artifact struct {
+			ContractName string                   `json:"contractName"`
+			ABI          ethbinding.ABIMarshaling `json:"abi"`
+			DevDoc       json.RawMessage          `json:"devdoc"`
+			UserDoc      json.RawMessage          `json:"userdoc"`
+		}
+		if err = json.Unmarshal(b, &artifact); err == nil {
+			if artifact.ContractName != "" {
+				input.ContractName = artifact.ContractName
+			}
+			input.ABI = artifact.ABI
+			if len(artifact.DevDoc) > 0 {
+				input.DevDoc = string(artifact.DevDoc)
+			}
+			if len(artifact.UserDoc) > 0 {
+				input.UserDoc = string(artifact.UserDoc)
+			}
+		}
+	}
+	if err != nil || len(input.ABI) == 0 {
+		return nil, errors.Errorf(errors.GenAPIInvalidABI, filename, err)
+	}
+	return input, nil
+}
+
+func genAPI(filename string, stdout io.Writer) error {
+	input, err := loadGenAPIInput(filename)
+	if err != nil {
+		return err
+	}
+	runtimeABI, err := ethbind.API.ABIMarshalingToABIRuntime(input.ABI)
+	if err != nil {
+		return errors.Errorf(errors.GenAPIInvalidABI, filename, err)
+	}
+	baseURL, err := url.Parse(genAPIConfig.BaseURL)
+	if err != nil {
+		return errors.Errorf(errors.GenAPIInvalidBaseURL, genAPIConfig.BaseURL, err)
+	}
+	swaggerGen := openapi.NewABI2Swagger(&openapi.ABI2SwaggerConf{
+		ExternalHost:     baseURL.Host,
+		ExternalRootPath: baseURL.Path,
+		ExternalSchemes:  []string{baseURL.Scheme},
+	})
+
+	apiName := genAPIConfig.APIName
+	if apiName == "" {
+		apiName = input.ContractName
+	}
+	path := genAPIConfig.Path
+	docs := openapi.MergeNatSpec(input.DevDoc, input.UserDoc)
+	var swagger interface{}
+	if genAPIConfig.Address != "" {
+		if path == "" {
+			path = "/contracts/" + strings.TrimPrefix(strings.ToLower(genAPIConfig.Address), "0x")
+		}
+		swagger = swaggerGen.Gen4Instance(path, apiName, &runtimeABI.ABI, docs)
+	} else {
+		if path == "" {
+			path = "/abis/" + url.PathEscape(apiName)
+		}
+		swagger = swaggerGen.Gen4Factory(path, apiName, genAPIConfig.FactoryOnly, false, &runtimeABI.ABI, docs)
+	}
+
+	swaggerBytes, _ := json.MarshalIndent(swagger, "", "  ")
+	if err := writeGenAPIOutput(genAPIConfig.Output, stdout, swaggerBytes); err != nil {
+		return err
+	}
+	if genAPIConfig.DevDocsOutput != "" {
+		var devdocBytes bytes.Buffer
+		if input.DevDoc == "" || json.Indent(&devdocBytes, []byte(input.DevDoc), "", "  ") != nil {
+			devdocBytes.Reset()
+			devdocBytes.WriteString("{}")
+		}
+		if err := writeGenAPIOutput(genAPIConfig.DevDocsOutput, stdout, devdocBytes.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeGenAPIOutput(filename string, stdout io.Writer, b []byte) (err error) {
+	b = append(b, '\n')
+	if filename == "" {
+		_, err = stdout.Write(b)
+	} else {
+		err = ioutil.WriteFile(filename, b, 0644)
+	}
+	if err != nil {
+		return errors.Errorf(errors.GenAPIWriteFailed, filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const genAPITestABI = `[
+	{
+		"type": "function",
+		"name": "set",
+		"stateMutability": "nonpayable",
+		"inputs": [{"name": "x", "type": "uint256"}],
+		"outputs": []
+	},
+	{
+		"type": "constructor",
+		"stateMutability": "nonpayable",
+		"inputs": []
+	}
+]`
+
+func newTestGenAPIDir(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "genapi")
+	assert.NoError(t, err)
+	for name, content := range files {
+		err = ioutil.WriteFile(path.Join(dir, name), []byte(content), 0644)
+		assert.NoError(t, err)
+	}
+	genAPIConfig.APIName = ""
+	genAPIConfig.Address = ""
+	genAPIConfig.Path = ""
+	genAPIConfig.BaseURL = "http://localhost:8080"
+	genAPIConfig.FactoryOnly = false
+	genAPIConfig.Output = ""
+	genAPIConfig.DevDocsOutput = ""
+	return dir
+}
+
+func TestGenAPIFactoryFromABIArray(t *testing.T) {
+	assert := assert.New(t)
+	dir := newTestGenAPIDir(t, map[string]string{"simple.json": genAPITestABI})
+	defer os.RemoveAll(dir)
+
+	var out bytes.Buffer
+	err := genAPI(path.Join(dir, "simple.json"), &out)
+	assert.NoError(err)
+
+	var swagger map[string]interface{}
+	assert.NoError(json.Unmarshal(out.Bytes(), &swagger))
+	assert.Equal("simple", swagger["info"].(map[string]interface{})["title"])
+	assert.Equal("/abis/simple", swagger["basePath"])
+	paths := swagger["paths"].(map[string]interface{})
+	assert.Contains(paths, "/")
+	assert.Contains(paths, "/{address}/set")
+}
+
+func TestGenAPIInstanceFromArtifactToFiles(t *testing.T) {
+	assert := assert.New(t)
+	dir := newTestGenAPIDir(t, map[string]string{
+		"artifact.json": `{"contractName":"Simple","abi":` + genAPITestABI + `,"devdoc":{"details":"A simple contract"},"userdoc":{"notice":"Stores a value"}}`,
+	})
+	defer os.RemoveAll(dir)
+	genAPIConfig.Address = "0x0123456789ABCDEF0123456789abcdef01234567"
+	genAPIConfig.BaseURL = "https://gateway.example.com/api/v1"
+	genAPIConfig.Output = path.Join(dir, "openapi.json")
+	genAPIConfig.DevDocsOutput = path.Join(dir, "devdocs.json")
+
+	var out bytes.Buffer
+	err := genAPI(path.Join(dir, "artifact.json"), &out)
+	assert.NoError(err)
+	assert.Empty(out.String())
+
+	b, err := ioutil.ReadFile(genAPIConfig.Output)
+	assert.NoError(err)
+	var swagger map[string]interface{}
+	assert.NoError(json.Unmarshal(b, &swagger))
+	assert.Equal("Simple", swagger["info"].(map[string]interface{})["title"])
+	assert.Equal("Stores a value\n\nA simple contract", swagger["info"].(map[string]interface{})["description"])
+	assert.Equal("gateway.example.com", swagger["host"])
+	assert.Equal("/api/v1/contracts/0123456789abcdef0123456789abcdef01234567", swagger["basePath"])
+
+	b, err = ioutil.ReadFile(genAPIConfig.DevDocsOutput)
+	assert.NoError(err)
+	assert.JSONEq(`{"details":"A simple contract"}`, string(b))
+}
+
+func TestGenAPICommand(t *testing.T) {
+	assert := assert.New(t)
+	dir := newTestGenAPIDir(t, map[string]string{"simple.json": genAPITestABI})
+	defer os.RemoveAll(dir)
+
+	outFile := path.Join(dir, "out.json")
+	rootCmd.SetArgs([]string{"genapi", path.Join(dir, "simple.json"), "-n", "MyAPI", "-p", "/custom", "-o", outFile, "-D", path.Join(dir, "devdocs.json")})
+	assert.Equal(0, Execute())
+
+	b, err := ioutil.ReadFile(outFile)
+	assert.NoError(err)
+	assert.Contains(string(b), `"title": "MyAPI"`)
+	assert.Contains(string(b), `"basePath": "/custom"`)
+	b, err = ioutil.ReadFile(path.Join(dir, "devdocs.json"))
+	assert.NoError(err)
+	assert.Equal("{}\n", string(b))
+}
+
+func TestGenAPIMissingFile(t *testing.T) {
+	newTestGenAPIDir(t, nil)
+	err := genAPI("/non/existent/file.json", &bytes.Buffer{})
+	assert.Regexp(t, "FFEC100003", err)
+}
+
+func TestGenAPIBadABI(t *testing.T) {
+	assert := assert.New(t)
+	dir := newTestGenAPIDir(t, map[string]string{
+		"notjson.json":  `!!`,
+		"noabi.json":    `{"contractName":"x"}`,
+		"badtypes.json": `[{"type":"function","name":"x","inputs":[{"name":"a","type":"badness"}]}]`,
+	})
+	defer os.RemoveAll(dir)
+
+	for _, f := range []string{"notjson.json", "noabi.json", "badtypes.json"} {
+		err := genAPI(path.Join(dir, f), &bytes.Buffer{})
+		assert.Regexp("FFEC100236", err)
+	}
+}
+
+func TestGenAPIBadBaseURL(t *testing.T) {
+	dir := newTestGenAPIDir(t, map[string]string{"simple.json": genAPITestABI})
+	defer os.RemoveAll(dir)
+	genAPIConfig.BaseURL = ":badurl"
+	err := genAPI(path.Join(dir, "simple.json"), &bytes.Buffer{})
+	assert.Regexp(t, "FFEC100237", err)
+}
+
+func TestGenAPIWriteFail(t *testing.T) {
+	assert := assert.New(t)
+	dir := newTestGenAPIDir(t, map[string]string{"simple.json": genAPITestABI})
+	defer os.RemoveAll(dir)
+
+	genAPIConfig.Output = path.Join(dir, "missing", "openapi.json")
+	err := genAPI(path.Join(dir, "simple.json"), &bytes.Buffer{})
+	assert.Regexp("FFEC100238", err)
+
+	genAPIConfig.Output = ""
+	genAPIConfig.DevDocsOutput = path.Join(dir, "missing", "devdocs.json")
+	err = genAPI(path.Join(dir, "simple.json"), &bytes.Buffer{})
+	assert.Regexp("FFEC100238", err)
+}
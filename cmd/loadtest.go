@@ -0,0 +1,541 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	loadTestDeploy = "deploy"
+	loadTestInvoke = "invoke"
+	loadTestCall   = "call"
+	loadTestEvents = "events"
+
+	// loadTestIndexPlaceholder in the body is replaced with the sequence number of each request
+	loadTestIndexPlaceholder = "{{index}}"
+)
+
+var loadTestConfig struct {
+	URL            string
+	ServerConfig   string
+	Workload       string
+	From           string
+	ABI            string
+	Contract       string
+	Method         string
+	Event          string
+	Body           string
+	Headers        []string
+	Concurrency    int
+	Count          int64
+	Duration       time.Duration
+	Sync           bool
+	WebhookListen  string
+	WebhookURL     string
+	EventsPerTx    int
+	DrainTimeout   time.Duration
+	StartupTimeout time.Duration
+	JSON           bool
+}
+
+// loadTestLatency is the distribution of request latencies, in milliseconds
+type loadTestLatency struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+type loadTestReport struct {
+	Workload        string           `json:"workload"`
+	Concurrency     int              `json:"concurrency"`
+	Requests        int64            `json:"requests"`
+	Errors          int64            `json:"errors"`
+	ErrorsByStatus  map[string]int64 `json:"errorsByStatus,omitempty"`
+	DurationSeconds float64          `json:"durationSeconds"`
+	Throughput      float64          `json:"throughput"`
+	Latency         loadTestLatency  `json:"latencyMS"`
+	EventsExpected  int64            `json:"eventsExpected,omitempty"`
+	EventsReceived  int64            `json:"eventsReceived,omitempty"`
+	EventThroughput float64          `json:"eventThroughput,omitempty"`
+}
+
+type loadTest struct {
+	client   *http.Client
+	baseURL  string
+	headers  http.Header
+	streamID string
+	runStart time.Time
+
+	eventsReceived int64
+	lastEventAt    int64
+}
+
+func initLoadTest() (loadTestCmd *cobra.Command) {
+	loadTestCmd = &cobra.Command{
+		Use:   "loadtest",
+		Short: "Drives a synthetic workload against a gateway, and reports throughput and latency",
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer cancel()
+			return runLoadTest(ctx, cmd.OutOrStdout())
+		},
+	}
+	defaultURL := os.Getenv("ETHCONNECT_URL")
+	if defaultURL == "" {
+		defaultURL = "http://localhost:8080"
+	}
+	loadTestCmd.Flags().StringVarP(&loadTestConfig.URL, "url", "u", defaultURL, "URL of the REST gateway")
+	loadTestCmd.Flags().StringVarP(&loadTestConfig.ServerConfig, "server-config", "f", "", "Start the bridges in this server config file in-process, and drive the REST gateway listening on --url")
+	loadTestCmd.Flags().StringVarP(&loadTestConfig.Workload, "workload", "w", loadTestInvoke, "Workload to run: deploy, invoke, call or events")
+	loadTestCmd.Flags().StringVarP(&loadTestConfig.From, "from", "F", "", "Signing address for transactions")
+	loadTestCmd.Flags().StringVarP(&loadTestConfig.ABI, "abi", "a", "", "ABI ID or name to deploy instances of (deploy)")
+	loadTestCmd.Flags().StringVarP(&loadTestConfig.Contract, "contract", "c", "", "Address or registered name of the contract (invoke, call, events)")
+	loadTestCmd.Flags().StringVarP(&loadTestConfig.Method, "method", "m", "", "Method to invoke or call (invoke, call, events)")
+	loadTestCmd.Flags().StringVarP(&loadTestConfig.Event, "event", "e", "", "Event emitted by the method, to subscribe to (events)")
+	loadTestCmd.Flags().StringVarP(&loadTestConfig.Body, "body", "b", "{}", "JSON body of each request, where "+loadTestIndexPlaceholder+" is replaced with the request number")
+	loadTestCmd.Flags().StringArrayVarP(&loadTestConfig.Headers, "header", "H", []string{}, "HTTP header to add to each request, as 'Name: value'")
+	loadTestCmd.Flags().IntVarP(&loadTestConfig.Concurrency, "concurrency", "C", 10, "Number of requests to keep in flight")
+	loadTestCmd.Flags().Int64VarP(&loadTestConfig.Count, "count", "n", 0, "Number of requests to send (0 to run for --duration)")
+	loadTestCmd.Flags().DurationVarP(&loadTestConfig.Duration, "duration", "D", 0, "How long to send requests for (0 to send --count)")
+	loadTestCmd.Flags().BoolVarP(&loadTestConfig.Sync, "sync", "s", false, "Wait for each transaction to be mined, rather than for it to be accepted")
+	loadTestCmd.Flags().StringVarP(&loadTestConfig.WebhookListen, "webhook-listen", "l", "127.0.0.1:0", "Local address to receive events on (events)")
+	loadTestCmd.Flags().StringVarP(&loadTestConfig.WebhookURL, "webhook-url", "W", "", "URL the gateway delivers events to, if it cannot reach --webhook-listen directly (events)")
+	loadTestCmd.Flags().IntVarP(&loadTestConfig.EventsPerTx, "events-per-tx", "E", 1, "Number of events each transaction emits (events)")
+	loadTestCmd.Flags().DurationVarP(&loadTestConfig.DrainTimeout, "drain-timeout", "T", 60*time.Second, "How long to wait for events after the last transaction (events)")
+	loadTestCmd.Flags().DurationVarP(&loadTestConfig.StartupTimeout, "startup-timeout", "S", 30*time.Second, "How long to wait for the gateway to be ready")
+	loadTestCmd.Flags().BoolVarP(&loadTestConfig.JSON, "json", "j", false, "Print the report as JSON")
+	return
+}
+
+func validateLoadTestConfig() error {
+	conf := &loadTestConfig
+	switch conf.Workload {
+	case loadTestDeploy:
+		if conf.ABI == "" {
+			return errors.Errorf(errors.LoadTestInvalidConfig, "--abi is required to deploy")
+		}
+	case loadTestInvoke, loadTestCall, loadTestEvents:
+		if conf.Contract == "" || conf.Method == "" {
+			return errors.Errorf(errors.LoadTestInvalidConfig, fmt.Sprintf("--contract and --method are required to %s", conf.Workload))
+		}
+		if conf.Workload == loadTestEvents && conf.Event == "" {
+			return errors.Errorf(errors.LoadTestInvalidConfig, "--event is required for an events workload")
+		}
+	default:
+		return errors.Errorf(errors.LoadTestInvalidConfig, fmt.Sprintf("unknown workload '%s'", conf.Workload))
+	}
+	if conf.Workload != loadTestCall && conf.From == "" {
+		return errors.Errorf(errors.LoadTestInvalidConfig, "--from is required to submit transactions")
+	}
+	if conf.Count <= 0 && conf.Duration <= 0 {
+		return errors.Errorf(errors.LoadTestInvalidConfig, "one of --count or --duration is required")
+	}
+	if conf.Concurrency <= 0 {
+		return errors.Errorf(errors.LoadTestInvalidConfig, "--concurrency must be at least 1")
+	}
+	if !json.Valid([]byte(strings.ReplaceAll(conf.Body, loadTestIndexPlaceholder, "0"))) {
+		return errors.Errorf(errors.LoadTestInvalidConfig, "--body is not valid JSON")
+	}
+	return nil
+}
+
+func newLoadTest() (*loadTest, error) {
+	lt := &loadTest{
+		client:  &http.Client{},
+		baseURL: strings.TrimSuffix(loadTestConfig.URL, "/"),
+		headers: http.Header{},
+	}
+	for _, h := range loadTestConfig.Headers {
+		kv := strings.SplitN(h, ":", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf(errors.LoadTestInvalidConfig, fmt.Sprintf("header '%s' is not 'Name: value'", h))
+		}
+		lt.headers.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+	return lt, nil
+}
+
+func runLoadTest(ctx context.Context, stdout io.Writer) error {
+	if err := validateLoadTestConfig(); err != nil {
+		return err
+	}
+	lt, err := newLoadTest()
+	if err != nil {
+		return err
+	}
+
+	if loadTestConfig.ServerConfig != "" {
+		serverCmdConfig.Filename = loadTestConfig.ServerConfig
+		go func() {
+			if err := startServer(); err != nil {
+				log.Errorf("In-process server failed: %s", err)
+			}
+		}()
+	}
+	if err := lt.waitReady(ctx); err != nil {
+		return err
+	}
+
+	if loadTestConfig.Workload == loadTestEvents {
+		stopListener, err := lt.setupEvents()
+		if stopListener != nil {
+			defer stopListener()
+		}
+		if err != nil {
+			return err
+		}
+		defer lt.teardownEvents()
+	}
+
+	report := lt.run(ctx)
+	if loadTestConfig.Workload == loadTestEvents {
+		lt.drainEvents(ctx, report)
+	}
+	return printLoadTestReport(stdout, report)
+}
+
+// waitReady polls the status endpoint of the gateway until it responds
+func (lt *loadTest) waitReady(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, loadTestConfig.StartupTimeout)
+	defer cancel()
+	for {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, lt.baseURL+"/status", nil)
+		lt.setHeaders(req)
+		res, err := lt.client.Do(req)
+		if err == nil {
+			res.Body.Close()
+			if res.StatusCode == http.StatusOK {
+				return nil
+			}
+			err = fmt.Errorf("status %d", res.StatusCode)
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Errorf(errors.LoadTestGatewayNotReady, lt.baseURL, loadTestConfig.StartupTimeout, err)
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+func (lt *loadTest) setHeaders(req *http.Request) {
+	for k, v := range lt.headers {
+		req.Header[k] = v
+	}
+}
+
+// requestFor builds the request for one iteration of the workload
+func (lt *loadTest) requestFor(ctx context.Context, i int64) *http.Request {
+	conf := &loadTestConfig
+	var path string
+	query := url.Values{}
+	switch conf.Workload {
+	case loadTestDeploy:
+		path = "/abis/" + url.PathEscape(conf.ABI)
+	default:
+		path = "/contracts/" + url.PathEscape(conf.Contract) + "/" + url.PathEscape(conf.Method)
+	}
+	if conf.From != "" {
+		query.Set("fly-from", conf.From)
+	}
+	if conf.Workload == loadTestCall {
+		query.Set("fly-call", "true")
+	} else if conf.Sync {
+		query.Set("fly-sync", "true")
+	}
+	body := strings.ReplaceAll(conf.Body, loadTestIndexPlaceholder, strconv.FormatInt(i, 10))
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, lt.baseURL+path+"?"+query.Encode(), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	lt.setHeaders(req)
+	return req
+}
+
+// send makes one request, returning an error key (the HTTP status, or "transport") if it failed
+func (lt *loadTest) send(ctx context.Context, i int64) string {
+	res, err := lt.client.Do(lt.requestFor(ctx, i))
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Debugf("Request %d failed: %s", i, err)
+		}
+		return "transport"
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		b, _ := ioutil.ReadAll(res.Body)
+		log.Debugf("Request %d failed [%d]: %s", i, res.StatusCode, b)
+		return strconv.Itoa(res.StatusCode)
+	}
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	return ""
+}
+
+// run keeps the configured number of requests in flight until the count is reached, or the
+// duration has elapsed
+func (lt *loadTest) run(ctx context.Context) *loadTestReport {
+	conf := &loadTestConfig
+	if conf.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, conf.Duration)
+		defer cancel()
+	}
+	report := &loadTestReport{
+		Workload:       conf.Workload,
+		Concurrency:    conf.Concurrency,
+		ErrorsByStatus: map[string]int64{},
+	}
+
+	var next int64
+	var mux sync.Mutex
+	var latencies []time.Duration
+	var wg sync.WaitGroup
+	start := time.Now()
+	lt.runStart = start
+	for w := 0; w < conf.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var workerLatencies []time.Duration
+			workerErrors := map[string]int64{}
+			for ctx.Err() == nil {
+				i := atomic.AddInt64(&next, 1)
+				if conf.Count > 0 && i > conf.Count {
+					break
+				}
+				reqStart := time.Now()
+				errKey := lt.send(ctx, i)
+				if errKey != "" && ctx.Err() != nil {
+					// Cut off by the end of the run, rather than a failure
+					break
+				}
+				workerLatencies = append(workerLatencies, time.Since(reqStart))
+				if errKey != "" {
+					workerErrors[errKey]++
+				}
+			}
+			mux.Lock()
+			defer mux.Unlock()
+			latencies = append(latencies, workerLatencies...)
+			for k, v := range workerErrors {
+				report.ErrorsByStatus[k] += v
+				report.Errors += v
+			}
+		}()
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	report.Requests = int64(len(latencies))
+	report.DurationSeconds = elapsed.Seconds()
+	if elapsed > 0 {
+		report.Throughput = float64(report.Requests) / elapsed.Seconds()
+	}
+	report.Latency = latencyDistribution(latencies)
+	return report
+}
+
+func latencyDistribution(latencies []time.Duration) loadTestLatency {
+	if len(latencies) == 0 {
+		return loadTestLatency{}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	ms := func(d time.Duration) float64 {
+		return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+	}
+	percentile := func(p float64) float64 {
+		idx := int(math.Ceil(p/100*float64(len(latencies)))) - 1
+		if idx < 0 {
+			idx = 0
+		}
+		return ms(latencies[idx])
+	}
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+	return loadTestLatency{
+		Min:  ms(latencies[0]),
+		Mean: ms(total / time.Duration(len(latencies))),
+		P50:  percentile(50),
+		P90:  percentile(90),
+		P95:  percentile(95),
+		P99:  percentile(99),
+		Max:  ms(latencies[len(latencies)-1]),
+	}
+}
+
+// adminRequest makes a request to create or delete the event stream and subscription
+func (lt *loadTest) adminRequest(method, path string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		reader = bytes.NewReader(b)
+	}
+	req, _ := http.NewRequest(method, lt.baseURL+path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	lt.setHeaders(req)
+	res, err := lt.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	b, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("[%d] %s", res.StatusCode, b)
+	}
+	if result != nil {
+		return json.Unmarshal(b, result)
+	}
+	return nil
+}
+
+// setupEvents starts a webhook listener that counts the events delivered to it, and creates
+// an event stream and subscription that deliver the events of the contract to it
+func (lt *loadTest) setupEvents() (stop func(), err error) {
+	conf := &loadTestConfig
+	listener, err := net.Listen("tcp", conf.WebhookListen)
+	if err != nil {
+		return nil, errors.Errorf(errors.LoadTestSetupFailed, "listen for events", err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		var events []json.RawMessage
+		if err := json.NewDecoder(req.Body).Decode(&events); err != nil {
+			res.WriteHeader(400)
+			return
+		}
+		atomic.AddInt64(&lt.eventsReceived, int64(len(events)))
+		atomic.StoreInt64(&lt.lastEventAt, time.Now().UnixNano())
+		res.WriteHeader(200)
+	})}
+	go func() { _ = server.Serve(listener) }()
+	stop = func() { _ = server.Close() }
+
+	webhookURL := conf.WebhookURL
+	if webhookURL == "" {
+		webhookURL = "http://" + listener.Addr().String()
+	}
+	var stream struct {
+		ID string `json:"id"`
+	}
+	err = lt.adminRequest(http.MethodPost, "/eventstreams", map[string]interface{}{
+		"name":           "loadtest-" + utils.UUIDv4(),
+		"type":           "webhook",
+		"batchSize":      100,
+		"batchTimeoutMS": 100,
+		"webhook":        map[string]interface{}{"url": webhookURL},
+	}, &stream)
+	if err != nil {
+		return stop, errors.Errorf(errors.LoadTestSetupFailed, "create event stream", err)
+	}
+	lt.streamID = stream.ID
+	subPath := "/contracts/" + url.PathEscape(conf.Contract) + "/" + url.PathEscape(conf.Event) + "/subscribe"
+	err = lt.adminRequest(http.MethodPost, subPath, map[string]interface{}{
+		"stream":    stream.ID,
+		"fromBlock": "latest",
+	}, nil)
+	if err != nil {
+		lt.teardownEvents()
+		return stop, errors.Errorf(errors.LoadTestSetupFailed, "subscribe to "+conf.Event, err)
+	}
+	return stop, nil
+}
+
+// teardownEvents deletes the event stream, along with its subscription
+func (lt *loadTest) teardownEvents() {
+	if lt.streamID != "" {
+		if err := lt.adminRequest(http.MethodDelete, "/eventstreams/"+lt.streamID, nil, nil); err != nil {
+			log.Warnf("Failed to delete load test event stream %s: %s", lt.streamID, err)
+		}
+		lt.streamID = ""
+	}
+}
+
+// drainEvents waits for the events of all the successful transactions to be delivered
+func (lt *loadTest) drainEvents(ctx context.Context, report *loadTestReport) {
+	report.EventsExpected = (report.Requests - report.Errors) * int64(loadTestConfig.EventsPerTx)
+	deadline := time.After(loadTestConfig.DrainTimeout)
+waitLoop:
+	for atomic.LoadInt64(&lt.eventsReceived) < report.EventsExpected {
+		select {
+		case <-ctx.Done():
+			break waitLoop
+		case <-deadline:
+			log.Warnf("Timed out waiting for events after %s", loadTestConfig.DrainTimeout)
+			break waitLoop
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	report.EventsReceived = atomic.LoadInt64(&lt.eventsReceived)
+	if lastEventAt := atomic.LoadInt64(&lt.lastEventAt); lastEventAt > 0 {
+		if elapsed := time.Unix(0, lastEventAt).Sub(lt.runStart); elapsed > 0 {
+			report.EventThroughput = float64(report.EventsReceived) / elapsed.Seconds()
+		}
+	}
+}
+
+func printLoadTestReport(stdout io.Writer, report *loadTestReport) error {
+	if loadTestConfig.JSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	fmt.Fprintf(stdout, "Workload:    %s (concurrency %d)\n", report.Workload, report.Concurrency)
+	fmt.Fprintf(stdout, "Requests:    %d in %.2fs (%.2f/s)\n", report.Requests, report.DurationSeconds, report.Throughput)
+	fmt.Fprintf(stdout, "Errors:      %d", report.Errors)
+	if len(report.ErrorsByStatus) > 0 {
+		keys := make([]string, 0, len(report.ErrorsByStatus))
+		for k := range report.ErrorsByStatus {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		counts := make([]string, len(keys))
+		for i, k := range keys {
+			counts[i] = fmt.Sprintf("%s=%d", k, report.ErrorsByStatus[k])
+		}
+		fmt.Fprintf(stdout, " (%s)", strings.Join(counts, " "))
+	}
+	fmt.Fprintln(stdout)
+	l := report.Latency
+	fmt.Fprintf(stdout, "Latency ms:  min=%.3f mean=%.3f p50=%.3f p90=%.3f p95=%.3f p99=%.3f max=%.3f\n", l.Min, l.Mean, l.P50, l.P90, l.P95, l.P99, l.Max)
+	if report.Workload == loadTestEvents {
+		fmt.Fprintf(stdout, "Events:      %d of %d received (%.2f/s)\n", report.EventsReceived, report.EventsExpected, report.EventThroughput)
+	}
+	return nil
+}
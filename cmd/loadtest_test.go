@@ -0,0 +1,254 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func resetLoadTestConfig(url string) {
+	loadTestConfig.URL = url
+	loadTestConfig.ServerConfig = ""
+	loadTestConfig.Workload = loadTestInvoke
+	loadTestConfig.From = "0x12345"
+	loadTestConfig.ABI = ""
+	loadTestConfig.Contract = "simplestorage"
+	loadTestConfig.Method = "set"
+	loadTestConfig.Event = ""
+	loadTestConfig.Body = `{"x": "{{index}}"}`
+	loadTestConfig.Headers = []string{}
+	loadTestConfig.Concurrency = 4
+	loadTestConfig.Count = 20
+	loadTestConfig.Duration = 0
+	loadTestConfig.Sync = false
+	loadTestConfig.WebhookListen = "127.0.0.1:0"
+	loadTestConfig.WebhookURL = ""
+	loadTestConfig.EventsPerTx = 1
+	loadTestConfig.DrainTimeout = 5 * time.Second
+	loadTestConfig.StartupTimeout = 5 * time.Second
+	loadTestConfig.JSON = true
+}
+
+// testLoadTestGateway fails every fifth request, and delivers an event to the webhook
+// of the event stream for each successful one
+type testLoadTestGateway struct {
+	mux        sync.Mutex
+	queries    []string
+	bodies     []string
+	webhookURL string
+	deleted    bool
+}
+
+func (g *testLoadTestGateway) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	b, _ := ioutil.ReadAll(req.Body)
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	switch {
+	case req.URL.Path == "/status":
+		res.WriteHeader(200)
+	case req.Method == http.MethodPost && req.URL.Path == "/eventstreams":
+		var stream struct {
+			Webhook struct {
+				URL string `json:"url"`
+			} `json:"webhook"`
+		}
+		_ = json.Unmarshal(b, &stream)
+		g.webhookURL = stream.Webhook.URL
+		res.WriteHeader(200)
+		res.Write([]byte(`{"id": "es-12345"}`))
+	case req.URL.Path == "/contracts/simplestorage/Changed/subscribe":
+		res.WriteHeader(200)
+		res.Write([]byte(`{"id": "sb-12345"}`))
+	case req.Method == http.MethodDelete && req.URL.Path == "/eventstreams/es-12345":
+		g.deleted = true
+		res.WriteHeader(204)
+	case req.URL.Path == "/contracts/simplestorage/set" || req.URL.Path == "/abis/simplestorage":
+		g.queries = append(g.queries, req.URL.RawQuery)
+		g.bodies = append(g.bodies, string(b))
+		if len(g.bodies)%5 == 0 {
+			res.WriteHeader(500)
+			res.Write([]byte(`{"error": "pop"}`))
+			return
+		}
+		if g.webhookURL != "" {
+			_, _ = http.Post(g.webhookURL, "application/json", strings.NewReader(`[{"signature": "Changed(uint256)"}]`))
+		}
+		res.WriteHeader(202)
+		res.Write([]byte(`{"sent": true}`))
+	default:
+		res.WriteHeader(404)
+	}
+}
+
+func TestLoadTestInvokeCount(t *testing.T) {
+	assert := assert.New(t)
+	gw := &testLoadTestGateway{}
+	server := httptest.NewServer(gw)
+	defer server.Close()
+	resetLoadTestConfig(server.URL)
+	loadTestConfig.Sync = true
+	loadTestConfig.Headers = []string{"Authorization: Bearer token"}
+
+	stdout := &bytes.Buffer{}
+	err := runLoadTest(context.Background(), stdout)
+	assert.NoError(err)
+
+	var report loadTestReport
+	err = json.Unmarshal(stdout.Bytes(), &report)
+	assert.NoError(err)
+	assert.Equal(int64(20), report.Requests)
+	assert.Equal(int64(4), report.Errors)
+	assert.Equal(map[string]int64{"500": 4}, report.ErrorsByStatus)
+	assert.Greater(report.Throughput, float64(0))
+	assert.LessOrEqual(report.Latency.Min, report.Latency.P50)
+	assert.LessOrEqual(report.Latency.P99, report.Latency.Max)
+
+	assert.Len(gw.bodies, 20)
+	assert.Contains(gw.bodies, `{"x": "1"}`)
+	assert.Contains(gw.bodies, `{"x": "20"}`)
+	assert.Equal("fly-from=0x12345&fly-sync=true", gw.queries[0])
+}
+
+func TestLoadTestCallDuration(t *testing.T) {
+	assert := assert.New(t)
+	gw := &testLoadTestGateway{}
+	server := httptest.NewServer(gw)
+	defer server.Close()
+	resetLoadTestConfig(server.URL)
+	loadTestConfig.Workload = loadTestCall
+	loadTestConfig.From = ""
+	loadTestConfig.Count = 0
+	loadTestConfig.Duration = 100 * time.Millisecond
+	loadTestConfig.JSON = false
+
+	stdout := &bytes.Buffer{}
+	err := runLoadTest(context.Background(), stdout)
+	assert.NoError(err)
+	assert.Regexp("Workload:    call", stdout.String())
+	assert.Regexp(`Errors:      \d+ \(500=\d+\)`, stdout.String())
+	assert.Regexp("Latency ms:  min=", stdout.String())
+	assert.Equal("fly-call=true", gw.queries[0])
+}
+
+func TestLoadTestDeploy(t *testing.T) {
+	assert := assert.New(t)
+	gw := &testLoadTestGateway{}
+	server := httptest.NewServer(gw)
+	defer server.Close()
+	resetLoadTestConfig(server.URL)
+	loadTestConfig.Workload = loadTestDeploy
+	loadTestConfig.ABI = "simplestorage"
+	loadTestConfig.Count = 3
+
+	err := runLoadTest(context.Background(), &bytes.Buffer{})
+	assert.NoError(err)
+	assert.Len(gw.bodies, 3)
+}
+
+func TestLoadTestEvents(t *testing.T) {
+	assert := assert.New(t)
+	gw := &testLoadTestGateway{}
+	server := httptest.NewServer(gw)
+	defer server.Close()
+	resetLoadTestConfig(server.URL)
+	loadTestConfig.Workload = loadTestEvents
+	loadTestConfig.Event = "Changed"
+	loadTestConfig.JSON = false
+
+	stdout := &bytes.Buffer{}
+	err := runLoadTest(context.Background(), stdout)
+	assert.NoError(err)
+	assert.Regexp("Events:      16 of 16 received", stdout.String())
+	assert.True(gw.deleted)
+}
+
+func TestLoadTestEventsSetupFailure(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/status" {
+			res.WriteHeader(200)
+			return
+		}
+		res.WriteHeader(500)
+	}))
+	defer server.Close()
+	resetLoadTestConfig(server.URL)
+	loadTestConfig.Workload = loadTestEvents
+	loadTestConfig.Event = "Changed"
+
+	err := runLoadTest(context.Background(), &bytes.Buffer{})
+	assert.Regexp("FFEC100375.*create event stream", err)
+
+	loadTestConfig.WebhookListen = "not an address"
+	err = runLoadTest(context.Background(), &bytes.Buffer{})
+	assert.Regexp("FFEC100375.*listen for events", err)
+}
+
+func TestLoadTestGatewayNotReady(t *testing.T) {
+	assert := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(503)
+	}))
+	defer server.Close()
+	resetLoadTestConfig(server.URL)
+	loadTestConfig.StartupTimeout = 100 * time.Millisecond
+
+	err := runLoadTest(context.Background(), &bytes.Buffer{})
+	assert.Regexp("FFEC100374.*status 503", err)
+}
+
+func TestLoadTestInvalidConfig(t *testing.T) {
+	assert := assert.New(t)
+	for _, test := range []struct {
+		update   func()
+		expected string
+	}{
+		{func() { loadTestConfig.Workload = "unknown" }, "unknown workload"},
+		{func() { loadTestConfig.Workload = loadTestDeploy }, "--abi"},
+		{func() { loadTestConfig.Method = "" }, "--contract and --method"},
+		{func() { loadTestConfig.Workload = loadTestEvents }, "--event"},
+		{func() { loadTestConfig.From = "" }, "--from"},
+		{func() { loadTestConfig.Count = 0 }, "--count or --duration"},
+		{func() { loadTestConfig.Concurrency = 0 }, "--concurrency"},
+		{func() { loadTestConfig.Body = "{" }, "--body"},
+		{func() { loadTestConfig.Headers = []string{"bad"} }, "header 'bad'"},
+	} {
+		resetLoadTestConfig("http://localhost:0")
+		test.update()
+		err := runLoadTest(context.Background(), &bytes.Buffer{})
+		assert.Regexp("FFEC100373.*"+test.expected, err)
+	}
+}
+
+func TestLatencyDistribution(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(loadTestLatency{}, latencyDistribution(nil))
+	var latencies []time.Duration
+	for i := 100; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(loadTestLatency{Min: 1, Mean: 50.5, P50: 50, P90: 90, P95: 95, P99: 99, Max: 100}, latencyDistribution(latencies))
+}
@@ -27,6 +27,8 @@ const (
 	ContextKeySystemAuth ContextKey = iota
 	ContextKeyAuthContext
 	ContextKeyAccessToken
+	ContextKeyTLSPrincipal
+	ContextKeyNamespace
 )
 
 var securityModule plugins.SecurityModule
@@ -36,6 +38,11 @@ func RegisterSecurityModule(sm plugins.SecurityModule) {
 	securityModule = sm
 }
 
+// SecurityModuleEnabled returns true if requests are authenticated and authorized by a security module
+func SecurityModuleEnabled() bool {
+	return securityModule != nil
+}
+
 // NewSystemAuthContext creates a system background context
 func NewSystemAuthContext() context.Context {
 	return context.WithValue(context.Background(), ContextKeySystemAuth, true)
@@ -134,3 +141,95 @@ func AuthReadAsyncReplyByUUID(ctx context.Context) error {
 	}
 	return nil
 }
+
+// WithTLSPrincipal records the principal authenticated by a client certificate at the transport layer
+func WithTLSPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, ContextKeyTLSPrincipal, principal)
+}
+
+// GetTLSPrincipal extracts the principal authenticated by a client certificate, if any
+func GetTLSPrincipal(ctx context.Context) string {
+	v, _ := ctx.Value(ContextKeyTLSPrincipal).(string)
+	return v
+}
+
+// GetPrincipal returns the identity behind the auth context, if the security module can supply one.
+// Otherwise the identity from the client certificate is used, when the listener uses mutual TLS
+func GetPrincipal(ctx context.Context) string {
+	if asm, ok := securityModule.(plugins.ApprovalSecurityModule); ok && !IsSystemContext(ctx) {
+		if authCtx := GetAuthContext(ctx); authCtx != nil {
+			return asm.Principal(authCtx)
+		}
+	}
+	return GetTLSPrincipal(ctx)
+}
+
+// WithNamespace records the namespace requested by the caller, which only applies when the
+// security module does not assign namespaces itself
+func WithNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, ContextKeyNamespace, namespace)
+}
+
+// GetNamespace returns the namespace that partitions the replies of the caller. A security
+// module that assigns namespaces is authoritative, otherwise the requested namespace is used.
+// An empty string means replies in all namespaces are visible.
+func GetNamespace(ctx context.Context) string {
+	if IsSystemContext(ctx) {
+		return ""
+	}
+	if nsm, ok := securityModule.(plugins.NamespaceSecurityModule); ok {
+		if authCtx := GetAuthContext(ctx); authCtx != nil {
+			return nsm.Namespace(authCtx)
+		}
+	}
+	v, _ := ctx.Value(ContextKeyNamespace).(string)
+	return v
+}
+
+// AuthApprovals authorize listing, approving and rejecting submissions pending approval
+func AuthApprovals(ctx context.Context) error {
+	if securityModule != nil && !IsSystemContext(ctx) {
+		authCtx := GetAuthContext(ctx)
+		if authCtx == nil {
+			return errors.Errorf(errors.SecurityModuleNoAuthContext)
+		}
+		asm, ok := securityModule.(plugins.ApprovalSecurityModule)
+		if !ok {
+			return errors.Errorf(errors.SecurityModuleNoApprovalSupport)
+		}
+		return asm.AuthApprovals(authCtx)
+	}
+	return nil
+}
+
+// AuthDeleteAsyncReplies authorize deleting and purging replies
+func AuthDeleteAsyncReplies(ctx context.Context) error {
+	if securityModule != nil && !IsSystemContext(ctx) {
+		authCtx := GetAuthContext(ctx)
+		if authCtx == nil {
+			return errors.Errorf(errors.SecurityModuleNoAuthContext)
+		}
+		rsm, ok := securityModule.(plugins.ReceiptAdminSecurityModule)
+		if !ok {
+			return errors.Errorf(errors.SecurityModuleNoReceiptAdminSupport)
+		}
+		return rsm.AuthDeleteAsyncReplies(authCtx)
+	}
+	return nil
+}
+
+// AuthAdmin authorize the admin APIs, that reindex, export and import the contract store
+func AuthAdmin(ctx context.Context) error {
+	if securityModule != nil && !IsSystemContext(ctx) {
+		authCtx := GetAuthContext(ctx)
+		if authCtx == nil {
+			return errors.Errorf(errors.SecurityModuleNoAuthContext)
+		}
+		asm, ok := securityModule.(plugins.AdminSecurityModule)
+		if !ok {
+			return errors.Errorf(errors.SecurityModuleNoAdminSupport)
+		}
+		return asm.AuthAdmin(authCtx)
+	}
+	return nil
+}
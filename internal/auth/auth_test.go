@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"github.com/hyperledger/firefly-ethconnect/internal/auth/authtest"
+	"github.com/hyperledger/firefly-ethconnect/pkg/plugins"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -147,3 +148,101 @@ func TestAuthReadAsyncReplyByUUID(t *testing.T) {
 	RegisterSecurityModule(nil)
 
 }
+
+func TestAuthApprovals(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(AuthApprovals(context.Background()))
+	assert.Equal("", GetPrincipal(context.Background()))
+
+	RegisterSecurityModule(&authtest.TestSecurityModule{})
+
+	assert.Regexp("No auth context", AuthApprovals(context.Background()))
+	assert.Equal("", GetPrincipal(context.Background()))
+
+	assert.NoError(AuthApprovals(NewSystemAuthContext()))
+
+	ctx, _ := WithAuthContext(context.Background(), "testat")
+	assert.NoError(AuthApprovals(ctx))
+	assert.Equal("verified", GetPrincipal(ctx))
+
+	RegisterSecurityModule(struct{ plugins.SecurityModule }{&authtest.TestSecurityModule{}})
+	assert.Regexp("FFEC100255", AuthApprovals(ctx))
+	assert.Equal("", GetPrincipal(ctx))
+
+	RegisterSecurityModule(nil)
+
+}
+
+func TestAuthDeleteAsyncReplies(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(AuthDeleteAsyncReplies(context.Background()))
+
+	RegisterSecurityModule(&authtest.TestSecurityModule{})
+
+	assert.Regexp("No auth context", AuthDeleteAsyncReplies(context.Background()))
+	assert.NoError(AuthDeleteAsyncReplies(NewSystemAuthContext()))
+
+	ctx, _ := WithAuthContext(context.Background(), "testat")
+	assert.NoError(AuthDeleteAsyncReplies(ctx))
+
+	RegisterSecurityModule(struct{ plugins.SecurityModule }{&authtest.TestSecurityModule{}})
+	assert.Regexp("FFEC100305", AuthDeleteAsyncReplies(ctx))
+
+	RegisterSecurityModule(nil)
+}
+
+func TestAuthAdmin(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(AuthAdmin(context.Background()))
+
+	RegisterSecurityModule(&authtest.TestSecurityModule{})
+
+	assert.Regexp("No auth context", AuthAdmin(context.Background()))
+	assert.NoError(AuthAdmin(NewSystemAuthContext()))
+
+	ctx, _ := WithAuthContext(context.Background(), "testat")
+	assert.NoError(AuthAdmin(ctx))
+
+	RegisterSecurityModule(struct{ plugins.SecurityModule }{&authtest.TestSecurityModule{}})
+	assert.Regexp("FFEC100486", AuthAdmin(ctx))
+
+	RegisterSecurityModule(nil)
+}
+
+func TestTLSPrincipal(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := WithTLSPrincipal(context.Background(), "client1")
+	assert.Equal("client1", GetTLSPrincipal(ctx))
+	assert.Equal("client1", GetPrincipal(ctx))
+	assert.Equal("", GetTLSPrincipal(context.Background()))
+
+	// The security module takes precedence, when it can identify the caller
+	RegisterSecurityModule(&authtest.TestSecurityModule{})
+	ctx, _ = WithAuthContext(ctx, "testat")
+	assert.Equal("verified", GetPrincipal(ctx))
+	RegisterSecurityModule(nil)
+}
+
+func TestGetNamespace(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("", GetNamespace(context.Background()))
+	ctx := WithNamespace(context.Background(), "tenant1")
+	assert.Equal("tenant1", GetNamespace(ctx))
+
+	// The security module assigns the namespace, when it can identify the caller
+	RegisterSecurityModule(&authtest.TestSecurityModule{})
+	assert.Equal("tenant1", GetNamespace(ctx))
+	authCtx, _ := WithAuthContext(ctx, "testat")
+	assert.Equal("ns-verified", GetNamespace(authCtx))
+	assert.Equal("", GetNamespace(NewSystemAuthContext()))
+
+	RegisterSecurityModule(struct{ plugins.SecurityModule }{&authtest.TestSecurityModule{}})
+	assert.Equal("tenant1", GetNamespace(authCtx))
+
+	RegisterSecurityModule(nil)
+}
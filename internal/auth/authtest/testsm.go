@@ -77,3 +77,42 @@ func (sm *TestSecurityModule) AuthReadAsyncReplyByUUID(authCtx interface{}) erro
 	}
 	return fmt.Errorf("badness")
 }
+
+// Principal of TEST MODULE returns the auth context string
+func (sm *TestSecurityModule) Principal(authCtx interface{}) string {
+	s, _ := authCtx.(string)
+	return s
+}
+
+// AuthApprovals of TEST MODULE returns true if there is an auth context
+func (sm *TestSecurityModule) AuthApprovals(authCtx interface{}) error {
+	switch authCtx.(type) {
+	case string:
+		return nil
+	}
+	return fmt.Errorf("badness")
+}
+
+// AuthDeleteAsyncReplies of TEST MODULE returns true if there is an auth context
+func (sm *TestSecurityModule) AuthDeleteAsyncReplies(authCtx interface{}) error {
+	switch authCtx.(type) {
+	case string:
+		return nil
+	}
+	return fmt.Errorf("badness")
+}
+
+// AuthAdmin of TEST MODULE returns true if there is an auth context
+func (sm *TestSecurityModule) AuthAdmin(authCtx interface{}) error {
+	switch authCtx.(type) {
+	case string:
+		return nil
+	}
+	return fmt.Errorf("badness")
+}
+
+// Namespace of TEST MODULE returns "ns-" followed by the auth context string
+func (sm *TestSecurityModule) Namespace(authCtx interface{}) string {
+	s, _ := authCtx.(string)
+	return "ns-" + s
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+)
+
+// bulkABIUploader is implemented by the gateway, to handle POST /abis/bulk on the deploy route
+type bulkABIUploader interface {
+	addABIsBulk(res http.ResponseWriter, req *http.Request, params httprouter.Params)
+}
+
+// bulkABIEntry is the outcome for one artifact file uploaded to POST /abis/bulk
+type bulkABIEntry struct {
+	File         string `json:"file"`
+	ContractName string `json:"contractName,omitempty"`
+	ID           string `json:"id,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// bulkABIResponse is the manifest returned from POST /abis/bulk. JSON files that are not
+// contract artifacts, such as Hardhat debug files, are listed as skipped.
+type bulkABIResponse struct {
+	Created []*bulkABIEntry `json:"created"`
+	Failed  []*bulkABIEntry `json:"failed,omitempty"`
+	Skipped []string        `json:"skipped,omitempty"`
+}
+
+// addABIsBulk stores one ABI for each contract artifact in the uploaded files. Archives, such as
+// a zip of a Truffle build/contracts directory or a tarball of Hardhat or Foundry artifacts, are extracted
+// first. A failure to store one artifact is reported in the manifest, without failing the others.
+func (g *smartContractGW) addABIsBulk(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	if err := req.ParseMultipartForm(maxFormParsingMemory); err != nil {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewayCompileContractInvalidFormData, err), 400)
+		return
+	}
+
+	tempdir := tempdir()
+	defer cleanup(tempdir)
+	for name, files := range req.MultipartForm.File {
+		log.Debugf("multi-part form entry '%s'", name)
+		for _, fileHeader := range files {
+			if err := g.extractMultiPartFile(tempdir, fileHeader); err != nil {
+				g.gatewayErrReply(res, req, err, 400)
+				return
+			}
+		}
+	}
+
+	var jsonFiles []string
+	_ = filepath.Walk(
+		tempdir,
+		func(p string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && strings.HasSuffix(p, ".json") {
+				jsonFiles = append(jsonFiles, p)
+			}
+			return nil
+		})
+
+	reply := &bulkABIResponse{Created: []*bulkABIEntry{}}
+	for _, p := range jsonFiles {
+		fileName := filepath.ToSlash(strings.TrimPrefix(strings.TrimPrefix(p, tempdir), string(filepath.Separator)))
+		entry, err := g.storeArtifact(req.Context(), fileName, p)
+		switch {
+		case err != nil:
+			log.Errorf("Failed to store ABI from '%s': %s", fileName, err)
+			entry.Error = err.Error()
+			reply.Failed = append(reply.Failed, entry)
+		case entry.ID == "":
+			log.Debugf("Skipping '%s' as it is not a contract artifact", fileName)
+			reply.Skipped = append(reply.Skipped, fileName)
+		default:
+			reply.Created = append(reply.Created, entry)
+		}
+	}
+
+	if len(reply.Created) == 0 && len(reply.Failed) == 0 {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewayBulkABINoArtifacts), 400)
+		return
+	}
+
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, 200)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(200)
+	_ = json.NewEncoder(res).Encode(reply)
+}
+
+// storeArtifact stores the ABI from one artifact file, returning an entry with no ID if the
+// file is not a contract artifact
+func (g *smartContractGW) storeArtifact(ctx context.Context, fileName, p string) (*bulkABIEntry, error) {
+	entry := &bulkABIEntry{File: fileName}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return entry, err
+	}
+	msg, err := parseContractArtifact(fileName, b)
+	if err != nil || msg == nil {
+		return entry, err
+	}
+	entry.ContractName = msg.ContractName
+	msg.Headers.MsgType = messages.MsgTypeSendTransaction
+	msg.Headers.ID = utils.UUIDv4()
+	info, err := g.storeUploadedABI(ctx, msg, nil)
+	if err != nil {
+		return entry, err
+	}
+	entry.ID = info.ID
+	return entry, nil
+}
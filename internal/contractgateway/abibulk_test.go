@@ -0,0 +1,148 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/contractregistry"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func postBulkABIs(router *httprouter.Router, fileName string, content []byte) *httptest.ResponseRecorder {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("files", fileName)
+	part.Write(content)
+	writer.Close()
+	req := httptest.NewRequest("POST", "/abis/bulk", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+func zipArtifacts(files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, content := range files {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestAddABIsBulkFromArchive(t *testing.T) {
+	assert := assert.New(t)
+	_, mcs, router := newTestImportGW(ABIImportConf{})
+
+	archive := zipArtifacts(map[string]string{
+		// Truffle
+		"build/contracts/SimpleStorage.json": `{
+			"contractName": "SimpleStorage",
+			"abi": ` + importTestABI + `,
+			"bytecode": "0x6080",
+			"devdoc": {"title": "Simple storage"},
+			"userdoc": {"notice": "Stores a value"},
+			"compiler": {"name": "solc", "version": "0.8.4+commit.c7e474f2"}
+		}`,
+		// Hardhat, for an interface with no bytecode
+		"artifacts/IStorage.sol/IStorage.json": `{
+			"_format": "hh-sol-artifact-1",
+			"contractName": "IStorage",
+			"abi": ` + importTestABI + `,
+			"bytecode": "0x"
+		}`,
+		"artifacts/IStorage.sol/IStorage.dbg.json": `{"_format": "hh-sol-dbg-1", "buildInfo": "../build-info/abc.json"}`,
+		"build/contracts/Linked.json":              `{"contractName": "Linked", "abi": ` + importTestABI + `, "bytecode": "0x60__$abcdef$__"}`,
+		"build/contracts/Broken.json":              `!json`,
+		"README.md":                                "not json",
+	})
+
+	mcs.On("FindABIByContent", mock.Anything).Return(nil, nil)
+	mcs.On("AddABI", mock.Anything, mock.MatchedBy(func(msg *messages.DeployContract) bool {
+		return msg.ContractName == "SimpleStorage" &&
+			msg.CompilerVersion == "0.8.4+commit.c7e474f2" &&
+			msg.DevDoc == `{"title": "Simple storage"}` &&
+			msg.UserDoc == `{"notice": "Stores a value"}` &&
+			bytes.Equal(msg.Compiled, []byte{0x60, 0x80}) &&
+			len(msg.ABI) == 1
+	}), mock.Anything).Return(&contractregistry.ABIInfo{ID: "abi1", Name: "SimpleStorage"}, nil)
+	mcs.On("AddABI", mock.Anything, mock.MatchedBy(func(msg *messages.DeployContract) bool {
+		return msg.ContractName == "IStorage" && msg.Compiled == nil && msg.DevDoc == ""
+	}), mock.Anything).Return(&contractregistry.ABIInfo{ID: "abi2", Name: "IStorage"}, nil)
+
+	res := postBulkABIs(router, "artifacts.zip", archive)
+	assert.Equal(200, res.Code)
+	var reply bulkABIResponse
+	json.NewDecoder(res.Body).Decode(&reply)
+	assert.Equal([]*bulkABIEntry{
+		{File: "artifacts/IStorage.sol/IStorage.json", ContractName: "IStorage", ID: "abi2"},
+		{File: "build/contracts/SimpleStorage.json", ContractName: "SimpleStorage", ID: "abi1"},
+	}, reply.Created)
+	assert.Len(reply.Failed, 2)
+	assert.Equal("build/contracts/Broken.json", reply.Failed[0].File)
+	assert.Regexp("FFEC100372", reply.Failed[0].Error)
+	assert.Equal("build/contracts/Linked.json", reply.Failed[1].File)
+	assert.Regexp("FFEC100372", reply.Failed[1].Error)
+	assert.Equal([]string{"artifacts/IStorage.sol/IStorage.dbg.json"}, reply.Skipped)
+
+	mcs.AssertExpectations(t)
+}
+
+func TestAddABIsBulkStoreFailure(t *testing.T) {
+	assert := assert.New(t)
+	_, mcs, router := newTestImportGW(ABIImportConf{})
+
+	mcs.On("FindABIByContent", mock.Anything).Return(nil, nil)
+	mcs.On("AddABI", mock.Anything, mock.Anything, mock.Anything).Return(nil, fmt.Errorf("pop"))
+
+	res := postBulkABIs(router, "Token.json", []byte(`{"abi": `+importTestABI+`}`))
+	assert.Equal(200, res.Code)
+	var reply bulkABIResponse
+	json.NewDecoder(res.Body).Decode(&reply)
+	assert.Empty(reply.Created)
+	assert.Equal([]*bulkABIEntry{{File: "Token.json", ContractName: "Token", Error: "pop"}}, reply.Failed)
+}
+
+func TestAddABIsBulkNoArtifacts(t *testing.T) {
+	assert := assert.New(t)
+	_, _, router := newTestImportGW(ABIImportConf{})
+
+	res := postBulkABIs(router, "build-info.json", []byte(`{"input": {}, "output": {}}`))
+	assert.Equal(400, res.Code)
+	assert.Regexp("FFEC100371", res.Body.String())
+
+	res = postBulkABIs(router, "bad.zip", []byte("not a zip"))
+	assert.Equal(400, res.Code)
+	assert.Regexp("FFEC100123", res.Body.String())
+
+	req := httptest.NewRequest("POST", "/abis/bulk", strings.NewReader("{}"))
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(400, res.Code)
+	assert.Regexp("FFEC100111", res.Body.String())
+}
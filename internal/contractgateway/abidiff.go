@@ -0,0 +1,220 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/contractregistry"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+)
+
+const abiDiffPathSegment = "diff"
+
+// abiDiffer is implemented by the gateway, to handle GET /abis/:abi/diff/:other on the invoke route
+type abiDiffer interface {
+	diffABIs(res http.ResponseWriter, req *http.Request, params httprouter.Params)
+}
+
+// abiDiffEntry is a method or event that differs between two ABIs. Entries are matched on their
+// signature, so a change to the inputs is reported as a removal and an addition.
+type abiDiffEntry struct {
+	Type      string   `json:"type"`
+	Name      string   `json:"name"`
+	Signature string   `json:"signature"`
+	Changes   []string `json:"changes,omitempty"`
+}
+
+// abiDiffResponse is returned from GET /abis/:abi/diff/:other. The ABIs are compatible if every
+// method and event of the first is in the second unchanged, so existing callers and subscriptions
+// keep working once the second ABI is behind the instance.
+type abiDiffResponse struct {
+	From       string          `json:"from"`
+	To         string          `json:"to"`
+	Compatible bool            `json:"compatible"`
+	Added      []*abiDiffEntry `json:"added"`
+	Removed    []*abiDiffEntry `json:"removed"`
+	Changed    []*abiDiffEntry `json:"changed"`
+}
+
+// abiDiffElement is the comparable form of a method or event
+type abiDiffElement struct {
+	entry      abiDiffEntry
+	attributes map[string]string
+}
+
+func (g *smartContractGW) diffABIs(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	fromID, toID := params.ByName("abi"), params.ByName("method")
+	from, err := g.loadABIForDiff(fromID)
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 404)
+		return
+	}
+	to, err := g.loadABIForDiff(toID)
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 404)
+		return
+	}
+
+	reply, err := diffABIs(from, to)
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 400)
+		return
+	}
+	reply.From = fromID
+	reply.To = toID
+
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, 200)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(200)
+	enc := json.NewEncoder(res)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(reply)
+}
+
+func (g *smartContractGW) loadABIForDiff(id string) (ethbinding.ABIMarshaling, error) {
+	deployMsg, err := g.cs.GetABI(contractregistry.ABILocation{
+		ABIType: contractregistry.LocalABI,
+		Name:    id,
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+	if deployMsg == nil || deployMsg.Contract == nil {
+		return nil, errors.Errorf(errors.RESTGatewayLocalStoreABINotFound, id)
+	}
+	return deployMsg.Contract.ABI, nil
+}
+
+func diffABIs(from, to ethbinding.ABIMarshaling) (*abiDiffResponse, error) {
+	fromElements, err := abiDiffElements(from)
+	if err != nil {
+		return nil, err
+	}
+	toElements, err := abiDiffElements(to)
+	if err != nil {
+		return nil, err
+	}
+
+	reply := &abiDiffResponse{
+		Added:   []*abiDiffEntry{},
+		Removed: []*abiDiffEntry{},
+		Changed: []*abiDiffEntry{},
+	}
+	for key, f := range fromElements {
+		t, exists := toElements[key]
+		if !exists {
+			entry := f.entry
+			reply.Removed = append(reply.Removed, &entry)
+			continue
+		}
+		var changes []string
+		for attr, fv := range f.attributes {
+			if tv := t.attributes[attr]; tv != fv {
+				changes = append(changes, fmt.Sprintf("%s: %s -> %s", attr, fv, tv))
+			}
+		}
+		if len(changes) > 0 {
+			sort.Strings(changes)
+			entry := t.entry
+			entry.Changes = changes
+			reply.Changed = append(reply.Changed, &entry)
+		}
+	}
+	for key, t := range toElements {
+		if _, exists := fromElements[key]; !exists {
+			entry := t.entry
+			reply.Added = append(reply.Added, &entry)
+		}
+	}
+	for _, entries := range [][]*abiDiffEntry{reply.Added, reply.Removed, reply.Changed} {
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Type != entries[j].Type {
+				return entries[i].Type > entries[j].Type // functions before events
+			}
+			return entries[i].Signature < entries[j].Signature
+		})
+	}
+	reply.Compatible = len(reply.Removed) == 0 && len(reply.Changed) == 0
+	return reply, nil
+}
+
+// abiDiffElements indexes the methods and events of an ABI by their type and signature, with
+// the attributes that can change without changing the signature
+func abiDiffElements(a ethbinding.ABIMarshaling) (map[string]*abiDiffElement, error) {
+	elements := make(map[string]*abiDiffElement)
+	for i := range a {
+		element := &a[i]
+		var d *abiDiffElement
+		switch element.Type {
+		case "function":
+			method, err := ethbind.API.ABIElementMarshalingToABIMethod(element)
+			if err != nil {
+				return nil, errors.Errorf(errors.RESTGatewayMethodABIInvalid, element.Name, err)
+			}
+			d = &abiDiffElement{
+				entry: abiDiffEntry{Type: element.Type, Name: method.Name, Signature: method.Sig},
+				attributes: map[string]string{
+					"outputs":         abiDiffArgs(method.Outputs),
+					"stateMutability": method.StateMutability,
+				},
+			}
+		case "event":
+			event, err := ethbind.API.ABIElementMarshalingToABIEvent(element)
+			if err != nil {
+				return nil, errors.Errorf(errors.RESTGatewayEventABIInvalid, element.Name, err)
+			}
+			d = &abiDiffElement{
+				entry: abiDiffEntry{Type: element.Type, Name: event.Name, Signature: event.Sig},
+				attributes: map[string]string{
+					"inputs":    abiDiffArgs(event.Inputs),
+					"anonymous": fmt.Sprintf("%t", event.Anonymous),
+				},
+			}
+		default:
+			continue
+		}
+		elements[element.Type+":"+d.entry.Signature] = d
+	}
+	return elements, nil
+}
+
+// abiDiffArgs describes arguments including their names and whether they are indexed, as both
+// change the JSON that callers and event stream consumers receive
+func abiDiffArgs(args ethbinding.ABIArguments) string {
+	descs := make([]string, len(args))
+	for i, arg := range args {
+		desc := arg.Type.String()
+		if arg.Indexed {
+			desc += " indexed"
+		}
+		if arg.Name != "" {
+			desc += " " + arg.Name
+		}
+		descs[i] = desc
+	}
+	return "(" + strings.Join(descs, ",") + ")"
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/contractregistry"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/mocks/contractregistrymocks"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func expectDiffABI(mcs *contractregistrymocks.ContractStore, id string, abi ethbinding.ABIMarshaling) {
+	mcs.On("GetABI", contractregistry.ABILocation{
+		ABIType: contractregistry.LocalABI,
+		Name:    id,
+	}, false).Return(&contractregistry.DeployContractWithAddress{
+		Contract: &messages.DeployContract{ABI: abi},
+	}, nil)
+}
+
+func TestDiffABIs(t *testing.T) {
+	assert := assert.New(t)
+	_, mcs, router := newTestImportGW(ABIImportConf{})
+
+	expectDiffABI(mcs, "abi1", ethbinding.ABIMarshaling{
+		{Type: "constructor"},
+		{Type: "function", Name: "get", StateMutability: "view", Outputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "uint256"}}},
+		{Type: "function", Name: "set", StateMutability: "nonpayable", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "uint256"}}},
+		{Type: "function", Name: "burn", StateMutability: "nonpayable"},
+		{Type: "event", Name: "Changed", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "uint256"}}},
+	})
+	expectDiffABI(mcs, "abi2", ethbinding.ABIMarshaling{
+		{Type: "constructor", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "owner", Type: "address"}}},
+		{Type: "function", Name: "get", StateMutability: "view", Outputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "uint256"}}},
+		{Type: "function", Name: "set", StateMutability: "payable", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "uint256"}}},
+		{Type: "function", Name: "set", StateMutability: "nonpayable", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "uint256"}, {Name: "y", Type: "string"}}},
+		{Type: "event", Name: "Changed", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "uint256", Indexed: true}}},
+		{Type: "event", Name: "Burned"},
+	})
+
+	req := httptest.NewRequest("GET", "/abis/abi1/diff/abi2", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Result().StatusCode)
+	var reply abiDiffResponse
+	err := json.NewDecoder(res.Body).Decode(&reply)
+	assert.NoError(err)
+
+	assert.Equal("abi1", reply.From)
+	assert.Equal("abi2", reply.To)
+	assert.False(reply.Compatible)
+	assert.Equal([]*abiDiffEntry{
+		{Type: "function", Name: "set", Signature: "set(uint256,string)"},
+		{Type: "event", Name: "Burned", Signature: "Burned()"},
+	}, reply.Added)
+	assert.Equal([]*abiDiffEntry{
+		{Type: "function", Name: "burn", Signature: "burn()"},
+	}, reply.Removed)
+	assert.Equal([]*abiDiffEntry{
+		{Type: "function", Name: "set", Signature: "set(uint256)", Changes: []string{"stateMutability: nonpayable -> payable"}},
+		{Type: "event", Name: "Changed", Signature: "Changed(uint256)", Changes: []string{"inputs: (uint256 x) -> (uint256 indexed x)"}},
+	}, reply.Changed)
+}
+
+func TestDiffABIsCompatible(t *testing.T) {
+	assert := assert.New(t)
+
+	reply, err := diffABIs(ethbinding.ABIMarshaling{
+		{Type: "function", Name: "get", StateMutability: "view", Outputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "uint256"}}},
+	}, ethbinding.ABIMarshaling{
+		{Type: "function", Name: "get", StateMutability: "view", Outputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "uint256"}}},
+		{Type: "function", Name: "put", StateMutability: "nonpayable"},
+	})
+	assert.NoError(err)
+	assert.True(reply.Compatible)
+	assert.Len(reply.Added, 1)
+	assert.Empty(reply.Removed)
+	assert.Empty(reply.Changed)
+}
+
+func TestDiffABIsNotFound(t *testing.T) {
+	assert := assert.New(t)
+	_, mcs, router := newTestImportGW(ABIImportConf{})
+
+	expectDiffABI(mcs, "abi1", ethbinding.ABIMarshaling{})
+	mcs.On("GetABI", contractregistry.ABILocation{ABIType: contractregistry.LocalABI, Name: "abi2"}, false).
+		Return(nil, fmt.Errorf("pop"))
+	mcs.On("GetABI", contractregistry.ABILocation{ABIType: contractregistry.LocalABI, Name: "abi3"}, false).
+		Return(nil, nil)
+
+	for _, path := range []string{"/abis/abi1/diff/abi2", "/abis/abi2/diff/abi1", "/abis/abi1/diff/abi3"} {
+		req := httptest.NewRequest("GET", path, nil)
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		assert.Equal(404, res.Result().StatusCode)
+	}
+}
+
+func TestDiffABIsBadABI(t *testing.T) {
+	assert := assert.New(t)
+	_, mcs, router := newTestImportGW(ABIImportConf{})
+
+	expectDiffABI(mcs, "abi1", ethbinding.ABIMarshaling{})
+	expectDiffABI(mcs, "abi2", ethbinding.ABIMarshaling{
+		{Type: "function", Name: "bad", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "badness"}}},
+	})
+
+	req := httptest.NewRequest("GET", "/abis/abi1/diff/abi2", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(400, res.Result().StatusCode)
+
+	_, err := diffABIs(ethbinding.ABIMarshaling{
+		{Type: "function", Name: "bad", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "badness"}}},
+	}, nil)
+	assert.Regexp("FFEC100109", err)
+	_, err = diffABIs(nil, ethbinding.ABIMarshaling{
+		{Type: "event", Name: "Bad", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "badness"}}},
+	})
+	assert.Regexp("FFEC100110", err)
+}
@@ -0,0 +1,400 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/contractregistry"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/eth"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+)
+
+const (
+	abiImportSourcify  = "sourcify"
+	abiImportEtherscan = "etherscan"
+	abiImportIPFS      = "ipfs"
+
+	defaultSourcifyURL    = "https://sourcify.dev/server"
+	defaultIPFSGatewayURL = "http://localhost:8080"
+)
+
+var hexAddressRegexp = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// ABIImportConf configures the services that verified contract ABIs can be imported from
+type ABIImportConf struct {
+	Sourcify  *SourcifyConf             `json:"sourcify,omitempty"`
+	Etherscan map[string]*EtherscanConf `json:"etherscan,omitempty"` // keyed by chain ID
+	IPFS      *IPFSConf                 `json:"ipfs,omitempty"`
+}
+
+// SourcifyConf configures a Sourcify server, which serves all the chains it supports
+type SourcifyConf struct {
+	utils.HTTPRequesterConf
+	URL string `json:"url"`
+}
+
+// EtherscanConf configures an Etherscan-compatible API for one chain
+type EtherscanConf struct {
+	utils.HTTPRequesterConf
+	URL    string `json:"url"`
+	APIKey string `json:"apiKey"`
+}
+
+// IPFSConf configures an IPFS gateway, that the metadata JSON referred to by the hash at the end
+// of the bytecode of a contract is fetched from
+type IPFSConf struct {
+	utils.HTTPRequesterConf
+	URL string `json:"url"`
+	// AutoRegister imports and registers contracts the first time they are invoked by address
+	AutoRegister bool `json:"autoRegister,omitempty"`
+}
+
+// verifiedSource fetches the ABI of a verified contract. A nil result with no error means the
+// contract is not verified on the service.
+type verifiedSource interface {
+	name() string
+	fetch(ctx context.Context, chainID, addrHexNo0x string) (*messages.DeployContract, error)
+}
+
+// abiImporter is implemented by the gateway, to handle POST /abis/import on the deploy route
+type abiImporter interface {
+	importABI(res http.ResponseWriter, req *http.Request, params httprouter.Params)
+}
+
+// contractAutoRegistrar is implemented by the gateway, so contracts can be registered the first
+// time they are invoked by address
+type contractAutoRegistrar interface {
+	autoRegisterContract(ctx context.Context, addrHexNo0x string) *contractregistry.ContractInfo
+}
+
+// importABIRequest is the body of POST /abis/import
+type importABIRequest struct {
+	ChainID    json.Number       `json:"chainId"`
+	Address    string            `json:"address"`
+	Source     string            `json:"source,omitempty"`
+	Register   bool              `json:"register,omitempty"`
+	RegisterAs string            `json:"registerAs,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+// importABIResponse is the reply to POST /abis/import, including the contract registration if requested
+type importABIResponse struct {
+	Source   string                         `json:"source"`
+	ABI      *contractregistry.ABIInfo      `json:"abi"`
+	Contract *contractregistry.ContractInfo `json:"contract,omitempty"`
+}
+
+type sourcifySource struct {
+	url string
+	hr  *utils.HTTPRequester
+}
+
+func (s *sourcifySource) name() string {
+	return abiImportSourcify
+}
+
+// fetch reads the Solidity metadata of a full or partial match, which contains the ABI and devdoc
+// but not the bytecode, so the imported ABI is not deployable
+func (s *sourcifySource) fetch(ctx context.Context, chainID, addrHexNo0x string) (*messages.DeployContract, error) {
+	var files struct {
+		Files []struct {
+			Name    string `json:"name"`
+			Content string `json:"content"`
+		} `json:"files"`
+	}
+	found, err := s.hr.DoRequestInto(http.MethodGet, fmt.Sprintf("%s/files/any/%s/0x%s", s.url, url.PathEscape(chainID), addrHexNo0x), nil, &files)
+	if err != nil || !found {
+		return nil, err
+	}
+	for _, f := range files.Files {
+		if f.Name != "metadata.json" {
+			continue
+		}
+		var metadata solcMetadata
+		if err := json.Unmarshal([]byte(f.Content), &metadata); err != nil {
+			return nil, errors.Errorf(errors.ABIImportFailed, s.name(), err)
+		}
+		return metadata.deployContract(), nil
+	}
+	return nil, errors.Errorf(errors.ABIImportFailed, s.name(), "metadata.json missing")
+}
+
+type etherscanSource struct {
+	conf *EtherscanConf
+	hr   *utils.HTTPRequester
+}
+
+func (s *etherscanSource) name() string {
+	return abiImportEtherscan
+}
+
+// fetch uses the getsourcecode action, which returns the ABI as a JSON string, or a message
+// in its place when the contract is not verified
+func (s *etherscanSource) fetch(ctx context.Context, chainID, addrHexNo0x string) (*messages.DeployContract, error) {
+	query := url.Values{}
+	query.Set("module", "contract")
+	query.Set("action", "getsourcecode")
+	query.Set("address", "0x"+addrHexNo0x)
+	if s.conf.APIKey != "" {
+		query.Set("apikey", s.conf.APIKey)
+	}
+	var res struct {
+		Status string          `json:"status"`
+		Result json.RawMessage `json:"result"`
+	}
+	found, err := s.hr.DoRequestInto(http.MethodGet, s.conf.URL+"?"+query.Encode(), nil, &res)
+	if err != nil || !found {
+		return nil, err
+	}
+	var results []struct {
+		ABI             string `json:"ABI"`
+		ContractName    string `json:"ContractName"`
+		CompilerVersion string `json:"CompilerVersion"`
+	}
+	if res.Status != "1" || json.Unmarshal(res.Result, &results) != nil || len(results) == 0 {
+		// Errors are returned as a message in place of the result
+		message := string(res.Result)
+		_ = json.Unmarshal(res.Result, &message)
+		return nil, errors.Errorf(errors.ABIImportFailed, s.name(), message)
+	}
+	var abi ethbinding.ABIMarshaling
+	if err := json.Unmarshal([]byte(results[0].ABI), &abi); err != nil {
+		log.Infof("Contract %s on chain %s is not verified on %s: %s", addrHexNo0x, chainID, s.name(), results[0].ABI)
+		return nil, nil
+	}
+	return &messages.DeployContract{
+		ABI:             abi,
+		ContractName:    results[0].ContractName,
+		CompilerVersion: results[0].CompilerVersion,
+	}, nil
+}
+
+type ipfsSource struct {
+	url string
+	hr  *utils.HTTPRequester
+	rpc eth.RPCClient
+}
+
+func (s *ipfsSource) name() string {
+	return abiImportIPFS
+}
+
+// fetch reads the code of the contract from the node, and fetches the metadata JSON identified
+// by the IPFS hash solc appends to the code. The chain ID is not used, as the code comes from the
+// chain the gateway is connected to.
+func (s *ipfsSource) fetch(ctx context.Context, chainID, addrHexNo0x string) (*messages.DeployContract, error) {
+	code, err := eth.GetCode(ctx, s.rpc, addrHexNo0x)
+	if err != nil {
+		return nil, err
+	}
+	codeMetadata := eth.ParseCodeMetadata(code)
+	if codeMetadata == nil || codeMetadata.IPFS == "" {
+		log.Infof("Code of %s has no IPFS metadata hash", addrHexNo0x)
+		return nil, nil
+	}
+	var metadata solcMetadata
+	found, err := s.hr.DoRequestInto(http.MethodGet, fmt.Sprintf("%s/ipfs/%s", s.url, codeMetadata.IPFS), nil, &metadata)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		log.Infof("Metadata %s of %s is not available from IPFS", codeMetadata.IPFS, addrHexNo0x)
+		return nil, nil
+	}
+	if len(metadata.Output.ABI) == 0 {
+		return nil, errors.Errorf(errors.ABIImportFailed, s.name(), fmt.Sprintf("%s is not solc metadata", codeMetadata.IPFS))
+	}
+	return metadata.deployContract(), nil
+}
+
+// verifiedSourcesFor returns the services that can be queried for a chain, in the order they are tried
+func (g *smartContractGW) verifiedSourcesFor(chainID, source string) []verifiedSource {
+	sources := []verifiedSource{}
+	importConf := &g.conf.Import
+	if importConf.Sourcify != nil && (source == "" || source == abiImportSourcify) {
+		baseURL := importConf.Sourcify.URL
+		if baseURL == "" {
+			baseURL = defaultSourcifyURL
+		}
+		sources = append(sources, &sourcifySource{
+			url: strings.TrimSuffix(baseURL, "/"),
+			hr:  utils.NewHTTPRequester("Sourcify", &importConf.Sourcify.HTTPRequesterConf),
+		})
+	}
+	if esConf, ok := importConf.Etherscan[chainID]; ok && (source == "" || source == abiImportEtherscan) {
+		sources = append(sources, &etherscanSource{
+			conf: esConf,
+			hr:   utils.NewHTTPRequester("Etherscan", &esConf.HTTPRequesterConf),
+		})
+	}
+	if importConf.IPFS != nil && g.rpc != nil && (source == "" || source == abiImportIPFS) {
+		sources = append(sources, g.ipfsSource())
+	}
+	return sources
+}
+
+func (g *smartContractGW) ipfsSource() *ipfsSource {
+	ipfsConf := g.conf.Import.IPFS
+	baseURL := ipfsConf.URL
+	if baseURL == "" {
+		baseURL = defaultIPFSGatewayURL
+	}
+	return &ipfsSource{
+		url: strings.TrimSuffix(baseURL, "/"),
+		hr:  utils.NewHTTPRequester("IPFS", &ipfsConf.HTTPRequesterConf),
+		rpc: g.rpc,
+	}
+}
+
+// importABI fetches the ABI of a verified contract from Sourcify or an Etherscan-compatible API,
+// stores it as a local ABI, and optionally registers the contract instance against it
+func (g *smartContractGW) importABI(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	var body importABIRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewayImportABIInvalid, err), 400)
+		return
+	}
+	chainID := body.ChainID.String()
+	addrHexNo0x := strings.ToLower(strings.TrimPrefix(body.Address, "0x"))
+	if _, err := body.ChainID.Int64(); err != nil || !hexAddressRegexp.MatchString(addrHexNo0x) {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewayImportABIInvalid, "chainId and address are required"), 400)
+		return
+	}
+	if body.Source != "" && body.Source != abiImportSourcify && body.Source != abiImportEtherscan && body.Source != abiImportIPFS {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewayImportABIInvalid, fmt.Sprintf("unknown source '%s'", body.Source)), 400)
+		return
+	}
+	if err := contractregistry.ValidateLabels(body.Labels); err != nil {
+		g.gatewayErrReply(res, req, err, 400)
+		return
+	}
+	if body.RegisterAs != "" {
+		if err := g.cs.CheckNameAvailable(body.RegisterAs); err != nil {
+			g.gatewayErrReply(res, req, err, 409)
+			return
+		}
+	}
+
+	sources := g.verifiedSourcesFor(chainID, body.Source)
+	if len(sources) == 0 {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.ABIImportNoSource, chainID), 400)
+		return
+	}
+	var msg *messages.DeployContract
+	var source string
+	var sourceNames []string
+	for _, s := range sources {
+		var err error
+		if msg, err = s.fetch(req.Context(), chainID, addrHexNo0x); err != nil {
+			g.gatewayErrReply(res, req, err, 500)
+			return
+		}
+		if msg != nil {
+			source = s.name()
+			break
+		}
+		sourceNames = append(sourceNames, s.name())
+	}
+	if msg == nil {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.ABIImportNotVerified, addrHexNo0x, chainID, strings.Join(sourceNames, ", ")), 404)
+		return
+	}
+	if msg.ContractName == "" {
+		msg.ContractName = addrHexNo0x
+	}
+	msg.Headers.MsgType = messages.MsgTypeSendTransaction
+	msg.Headers.ID = utils.UUIDv4()
+	msg.Labels = body.Labels
+	info, err := g.storeUploadedABI(req.Context(), msg, nil)
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 500)
+		return
+	}
+	reply := &importABIResponse{Source: source, ABI: info}
+
+	if body.Register || body.RegisterAs != "" {
+		registeredName := body.RegisterAs
+		if registeredName == "" {
+			registeredName = addrHexNo0x
+		}
+		contractInfo, err := g.cs.AddContract(addrHexNo0x, info.ID, registeredName, body.RegisterAs, body.Labels)
+		if err != nil {
+			g.gatewayErrReply(res, req, err, 409)
+			return
+		}
+		reply.Contract = g.resolveProxy(req.Context(), contractInfo)
+		g.notifier.notify(req.Context(), RegistryEventContractRegistered, nil, reply.Contract)
+	}
+
+	status := 200
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	_ = json.NewEncoder(res).Encode(reply)
+}
+
+// autoRegisterContract imports the ABI of an unregistered contract from the IPFS metadata hash in
+// its code, and registers the contract under its address. Nil is returned when auto-registration is
+// not configured, or the contract cannot be imported.
+func (g *smartContractGW) autoRegisterContract(ctx context.Context, addrHexNo0x string) *contractregistry.ContractInfo {
+	if g.conf.Import.IPFS == nil || !g.conf.Import.IPFS.AutoRegister || g.rpc == nil {
+		return nil
+	}
+	msg, err := g.ipfsSource().fetch(ctx, "", addrHexNo0x)
+	if err != nil {
+		log.Warnf("Failed to import ABI of %s from IPFS: %s", addrHexNo0x, err)
+		return nil
+	}
+	if msg == nil {
+		return nil
+	}
+	if msg.ContractName == "" {
+		msg.ContractName = addrHexNo0x
+	}
+	msg.Headers.MsgType = messages.MsgTypeSendTransaction
+	msg.Headers.ID = utils.UUIDv4()
+	info, err := g.storeUploadedABI(ctx, msg, nil)
+	if err != nil {
+		log.Errorf("Failed to store ABI of %s imported from IPFS: %s", addrHexNo0x, err)
+		return nil
+	}
+	contractInfo, err := g.cs.AddContract(addrHexNo0x, info.ID, addrHexNo0x, "", nil)
+	if err != nil {
+		// Another request might have registered the contract in the meantime
+		if existing, lookupErr := g.cs.GetContractByAddress(addrHexNo0x); lookupErr == nil {
+			return existing
+		}
+		log.Errorf("Failed to register %s with ABI %s imported from IPFS: %s", addrHexNo0x, info.ID, err)
+		return nil
+	}
+	log.Infof("Registered %s with ABI %s imported from IPFS", addrHexNo0x, info.ID)
+	g.notifier.notify(ctx, RegistryEventContractRegistered, nil, contractInfo)
+	return contractInfo
+}
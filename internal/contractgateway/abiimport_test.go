@@ -0,0 +1,424 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/contractregistry"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/openapi"
+	"github.com/hyperledger/firefly-ethconnect/mocks/contractregistrymocks"
+	"github.com/hyperledger/firefly-ethconnect/mocks/ethmocks"
+	"github.com/julienschmidt/httprouter"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	importTestAddr = "0x0123456789abcdef0123456789abcdef01234567"
+	importTestABI  = `[{"type":"function","name":"set","inputs":[{"name":"x","type":"uint256"}],"outputs":[]}]`
+)
+
+func newTestImportGW(conf ABIImportConf) (*smartContractGW, *contractregistrymocks.ContractStore, *httprouter.Router) {
+	mcs := &contractregistrymocks.ContractStore{}
+	g := &smartContractGW{
+		conf:            &SmartContractGatewayConf{Import: conf},
+		cs:              mcs,
+		baseSwaggerConf: &openapi.ABI2SwaggerConf{},
+	}
+	g.r2e = newREST2eth(g, mcs, nil, nil, nil, nil, nil)
+	router := &httprouter.Router{}
+	g.AddRoutes(router)
+	return g, mcs, router
+}
+
+func postImport(router *httprouter.Router, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/abis/import", strings.NewReader(body))
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+func newTestSourcify(status int, metadata string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/files/any/1/"+importTestAddr || status != 200 {
+			res.WriteHeader(404)
+			return
+		}
+		b, _ := json.Marshal(map[string]interface{}{
+			"status": "full",
+			"files": []map[string]string{
+				{"name": "SimpleStorage.sol", "content": "contract SimpleStorage {}"},
+				{"name": "metadata.json", "content": metadata},
+			},
+		})
+		res.Write(b)
+	}))
+}
+
+func TestImportABISourcifyAndRegister(t *testing.T) {
+	assert := assert.New(t)
+
+	metadata := `{
+		"compiler": {"version": "0.8.4+commit.c7e474f2"},
+		"output": {"abi": ` + importTestABI + `, "devdoc": {"title": "Simple storage"}, "userdoc": {"notice": "Stores a value"}},
+		"settings": {"compilationTarget": {"contracts/SimpleStorage.sol": "SimpleStorage"}}
+	}`
+	sourcify := newTestSourcify(200, metadata)
+	defer sourcify.Close()
+	_, mcs, router := newTestImportGW(ABIImportConf{Sourcify: &SourcifyConf{URL: sourcify.URL + "/"}})
+
+	mcs.On("CheckNameAvailable", "storage").Return(nil)
+	mcs.On("FindABIByContent", mock.Anything).Return(nil, nil)
+	mcs.On("AddABI", mock.Anything, mock.MatchedBy(func(msg *messages.DeployContract) bool {
+		return msg.ContractName == "SimpleStorage" &&
+			msg.CompilerVersion == "0.8.4+commit.c7e474f2" &&
+			msg.DevDoc == `{"title": "Simple storage"}` &&
+			msg.UserDoc == `{"notice": "Stores a value"}` &&
+			len(msg.ABI) == 1 && msg.Compiled == nil
+	}), mock.Anything).Return(&contractregistry.ABIInfo{ID: "abi1", Name: "SimpleStorage"}, nil)
+	mcs.On("AddContract", "0123456789abcdef0123456789abcdef01234567", "abi1", "storage", "storage", (map[string]string)(nil)).
+		Return(&contractregistry.ContractInfo{Address: "0123456789abcdef0123456789abcdef01234567", ABI: "abi1", RegisteredAs: "storage"}, nil)
+
+	res := postImport(router, `{"chainId": 1, "address": "`+importTestAddr+`", "registerAs": "storage"}`)
+	assert.Equal(200, res.Code)
+	var reply importABIResponse
+	json.NewDecoder(res.Body).Decode(&reply)
+	assert.Equal("sourcify", reply.Source)
+	assert.Equal("abi1", reply.ABI.ID)
+	assert.Equal("storage", reply.Contract.RegisteredAs)
+
+	mcs.AssertExpectations(t)
+}
+
+func TestImportABINameClash(t *testing.T) {
+	assert := assert.New(t)
+
+	_, mcs, router := newTestImportGW(ABIImportConf{Sourcify: &SourcifyConf{URL: "http://localhost:0/"}})
+	mcs.On("CheckNameAvailable", "storage").Return(fmt.Errorf("Contract address 0x12345 (remote registry) is already registered for name 'storage'"))
+
+	res := postImport(router, `{"chainId": 1, "address": "`+importTestAddr+`", "registerAs": "storage"}`)
+	assert.Equal(409, res.Code)
+	assert.Regexp("0x12345 \\(remote registry\\)", res.Body.String())
+
+	mcs.AssertExpectations(t)
+}
+
+func TestImportABIEtherscanFallback(t *testing.T) {
+	assert := assert.New(t)
+
+	sourcify := newTestSourcify(404, "")
+	defer sourcify.Close()
+	etherscan := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		assert.Equal("getsourcecode", req.URL.Query().Get("action"))
+		assert.Equal(importTestAddr, req.URL.Query().Get("address"))
+		assert.Equal("key1", req.URL.Query().Get("apikey"))
+		b, _ := json.Marshal(map[string]interface{}{
+			"status":  "1",
+			"message": "OK",
+			"result": []map[string]string{
+				{"ABI": importTestABI, "ContractName": "SimpleStorage", "CompilerVersion": "v0.8.4+commit.c7e474f2"},
+			},
+		})
+		res.Write(b)
+	}))
+	defer etherscan.Close()
+	_, mcs, router := newTestImportGW(ABIImportConf{
+		Sourcify:  &SourcifyConf{URL: sourcify.URL},
+		Etherscan: map[string]*EtherscanConf{"1": {URL: etherscan.URL + "/api", APIKey: "key1"}},
+	})
+
+	mcs.On("FindABIByContent", mock.Anything).Return(nil, nil)
+	mcs.On("AddABI", mock.Anything, mock.MatchedBy(func(msg *messages.DeployContract) bool {
+		return msg.ContractName == "SimpleStorage" && msg.CompilerVersion == "v0.8.4+commit.c7e474f2"
+	}), mock.Anything).Return(&contractregistry.ABIInfo{ID: "abi1"}, nil)
+
+	res := postImport(router, `{"chainId": "1", "address": "`+importTestAddr+`"}`)
+	assert.Equal(200, res.Code)
+	var reply importABIResponse
+	json.NewDecoder(res.Body).Decode(&reply)
+	assert.Equal("etherscan", reply.Source)
+	assert.Nil(reply.Contract)
+
+	mcs.AssertExpectations(t)
+}
+
+func TestImportABINotVerified(t *testing.T) {
+	assert := assert.New(t)
+
+	sourcify := newTestSourcify(404, "")
+	defer sourcify.Close()
+	etherscan := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Write([]byte(`{"status":"1","message":"OK","result":[{"ABI":"Contract source code not verified"}]}`))
+	}))
+	defer etherscan.Close()
+	_, _, router := newTestImportGW(ABIImportConf{
+		Sourcify:  &SourcifyConf{URL: sourcify.URL},
+		Etherscan: map[string]*EtherscanConf{"1": {URL: etherscan.URL}},
+	})
+
+	res := postImport(router, `{"chainId": 1, "address": "`+importTestAddr+`"}`)
+	assert.Equal(404, res.Code)
+	assert.Regexp("sourcify, etherscan.*FFEC100352", res.Body.String())
+}
+
+func TestImportABIFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	sourcify := newTestSourcify(200, "!json")
+	defer sourcify.Close()
+	etherscan := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Write([]byte(`{"status":"0","message":"NOTOK","result":"Invalid API Key"}`))
+	}))
+	defer etherscan.Close()
+	_, mcs, router := newTestImportGW(ABIImportConf{
+		Sourcify:  &SourcifyConf{URL: sourcify.URL},
+		Etherscan: map[string]*EtherscanConf{"1": {URL: etherscan.URL}},
+	})
+
+	res := postImport(router, `{"chainId": 1, "address": "`+importTestAddr+`", "source": "sourcify"}`)
+	assert.Equal(500, res.Code)
+	assert.Regexp("FFEC100353", res.Body.String())
+
+	res = postImport(router, `{"chainId": 1, "address": "`+importTestAddr+`", "source": "etherscan"}`)
+	assert.Equal(500, res.Code)
+	assert.Regexp(`etherscan: Invalid API Key".*FFEC100353`, res.Body.String())
+
+	res = postImport(router, `{"chainId": 2, "address": "`+importTestAddr+`", "source": "etherscan"}`)
+	assert.Equal(400, res.Code)
+	assert.Regexp("FFEC100351", res.Body.String())
+
+	for _, body := range []string{"!json", `{"chainId": 1}`, `{"address": "` + importTestAddr + `"}`, `{"chainId": 1, "address": "` + importTestAddr + `", "source": "other"}`} {
+		res = postImport(router, body)
+		assert.Equal(400, res.Code)
+		assert.Regexp("FFEC100350", res.Body.String())
+	}
+
+	res = postImport(router, `{"chainId": 1, "address": "`+importTestAddr+`", "labels": {"team": "a b"}}`)
+	assert.Equal(400, res.Code)
+	assert.Regexp("FFEC100391", res.Body.String())
+
+	mcs.AssertExpectations(t)
+}
+
+func TestImportABIStoreFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	metadata := `{"output": {"abi": ` + importTestABI + `}}`
+	sourcify := newTestSourcify(200, metadata)
+	defer sourcify.Close()
+	_, mcs, router := newTestImportGW(ABIImportConf{Sourcify: &SourcifyConf{URL: sourcify.URL}})
+
+	mcs.On("FindABIByContent", mock.Anything).Return(nil, nil)
+	mcs.On("AddABI", mock.Anything, mock.MatchedBy(func(msg *messages.DeployContract) bool {
+		// Named after the address when the metadata has no compilation target
+		return msg.ContractName == importTestAddr[2:]
+	}), mock.Anything).Return(nil, fmt.Errorf("pop")).Once()
+	res := postImport(router, `{"chainId": 1, "address": "`+importTestAddr+`"}`)
+	assert.Equal(500, res.Code)
+
+	mcs.On("AddABI", mock.Anything, mock.Anything, mock.Anything).Return(&contractregistry.ABIInfo{ID: "abi1"}, nil)
+	mcs.On("AddContract", importTestAddr[2:], "abi1", importTestAddr[2:], "", (map[string]string)(nil)).Return(nil, fmt.Errorf("pop"))
+	res = postImport(router, `{"chainId": 1, "address": "`+importTestAddr+`", "register": true}`)
+	assert.Equal(409, res.Code)
+
+	mcs.AssertExpectations(t)
+}
+
+// The code of a contract compiled by solc 0.8.4, ending with the IPFS hash of its metadata
+const importTestCode = "0x6080604052a264697066735822122044136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a64736f6c63430008040033"
+
+func newTestIPFS(status int, metadata string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/ipfs/QmSvPd3sHK7iWgZuW47fyLy4CaZQe2DwxvRhrJ39VpBVMK" || status != 200 {
+			res.WriteHeader(404)
+			return
+		}
+		res.Write([]byte(metadata))
+	}))
+}
+
+func newTestIPFSImportGW(ipfsConf *IPFSConf, code string) (*smartContractGW, *contractregistrymocks.ContractStore, *ethmocks.RPCClient, *httprouter.Router) {
+	g, mcs, router := newTestImportGW(ABIImportConf{IPFS: ipfsConf})
+	mrpc := &ethmocks.RPCClient{}
+	g.rpc = mrpc
+	g.r2e.rpc = mrpc
+	mrpc.On("CallContext", mock.Anything, mock.Anything, "eth_getCode", importTestAddr, "latest").
+		Run(func(args mock.Arguments) {
+			*(args[1].(*string)) = code
+		}).
+		Return(nil)
+	// Registered contracts are checked for proxy implementation slots, which are empty
+	mrpc.On("CallContext", mock.Anything, mock.Anything, "eth_getStorageAt", importTestAddr, mock.Anything, "latest").Return(nil).Maybe()
+	return g, mcs, mrpc, router
+}
+
+func TestImportABIIPFS(t *testing.T) {
+	assert := assert.New(t)
+
+	metadata := `{
+		"compiler": {"version": "0.8.4+commit.c7e474f2"},
+		"output": {"abi": ` + importTestABI + `, "devdoc": {"title": "Simple storage"}},
+		"settings": {"compilationTarget": {"contracts/SimpleStorage.sol": "SimpleStorage"}},
+		"sources": {"contracts/SimpleStorage.sol": {"content": "contract SimpleStorage {}"}}
+	}`
+	ipfs := newTestIPFS(200, metadata)
+	defer ipfs.Close()
+	_, mcs, mrpc, router := newTestIPFSImportGW(&IPFSConf{URL: ipfs.URL + "/"}, importTestCode)
+
+	mcs.On("FindABIByContent", mock.Anything).Return(nil, nil)
+	mcs.On("AddABI", mock.Anything, mock.MatchedBy(func(msg *messages.DeployContract) bool {
+		return msg.ContractName == "SimpleStorage" &&
+			msg.CompilerVersion == "0.8.4+commit.c7e474f2" &&
+			msg.Solidity == "contract SimpleStorage {}" &&
+			msg.DevDoc == `{"title": "Simple storage"}` &&
+			len(msg.ABI) == 1 && msg.Compiled == nil
+	}), mock.Anything).Return(&contractregistry.ABIInfo{ID: "abi1", Name: "SimpleStorage"}, nil)
+	mcs.On("AddContract", importTestAddr[2:], "abi1", importTestAddr[2:], "", (map[string]string)(nil)).
+		Return(&contractregistry.ContractInfo{Address: importTestAddr[2:], ABI: "abi1"}, nil)
+
+	res := postImport(router, `{"chainId": 1, "address": "`+importTestAddr+`", "source": "ipfs", "register": true}`)
+	assert.Equal(200, res.Code)
+	var reply importABIResponse
+	json.NewDecoder(res.Body).Decode(&reply)
+	assert.Equal("ipfs", reply.Source)
+	assert.Equal("abi1", reply.ABI.ID)
+	assert.Equal(importTestAddr[2:], reply.Contract.Address)
+
+	mcs.AssertExpectations(t)
+	mrpc.AssertExpectations(t)
+}
+
+func TestImportABIIPFSNotFound(t *testing.T) {
+	assert := assert.New(t)
+
+	ipfs := newTestIPFS(404, "")
+	defer ipfs.Close()
+
+	// No metadata hash in the code, such as for a clone
+	_, _, _, router := newTestIPFSImportGW(&IPFSConf{URL: ipfs.URL}, "0x363d3d373d3d3d363d73ab8c0ecc76d0759a8f50b2e14a6881367d8058325af43d82803e903d91602b57fd5bf3")
+	res := postImport(router, `{"chainId": 1, "address": "`+importTestAddr+`"}`)
+	assert.Equal(404, res.Code)
+	assert.Regexp("ipfs.*FFEC100352", res.Body.String())
+
+	// Metadata not pinned on the IPFS gateway
+	_, _, _, router = newTestIPFSImportGW(&IPFSConf{URL: ipfs.URL}, importTestCode)
+	res = postImport(router, `{"chainId": 1, "address": "`+importTestAddr+`"}`)
+	assert.Equal(404, res.Code)
+	assert.Regexp("FFEC100352", res.Body.String())
+}
+
+func TestImportABIIPFSFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	ipfs := newTestIPFS(200, `{"some": "other file"}`)
+	defer ipfs.Close()
+	_, _, _, router := newTestIPFSImportGW(&IPFSConf{URL: ipfs.URL}, importTestCode)
+	res := postImport(router, `{"chainId": 1, "address": "`+importTestAddr+`", "source": "ipfs"}`)
+	assert.Equal(500, res.Code)
+	assert.Regexp("QmSvPd3sHK7iWgZuW47fyLy4CaZQe2DwxvRhrJ39VpBVMK is not solc metadata.*FFEC100353", res.Body.String())
+
+	g, _, router := newTestImportGW(ABIImportConf{IPFS: &IPFSConf{URL: ipfs.URL}})
+	mrpc := &ethmocks.RPCClient{}
+	g.rpc = mrpc
+	mrpc.On("CallContext", mock.Anything, mock.Anything, "eth_getCode", importTestAddr, "latest").Return(fmt.Errorf("pop"))
+	res = postImport(router, `{"chainId": 1, "address": "`+importTestAddr+`"}`)
+	assert.Equal(500, res.Code)
+	assert.Regexp("pop", res.Body.String())
+
+	// IPFS needs a connection to the node to read the code
+	_, _, router = newTestImportGW(ABIImportConf{IPFS: &IPFSConf{URL: ipfs.URL}})
+	res = postImport(router, `{"chainId": 1, "address": "`+importTestAddr+`", "source": "ipfs"}`)
+	assert.Equal(400, res.Code)
+	assert.Regexp("FFEC100351", res.Body.String())
+}
+
+func TestAutoRegisterContractOnInvoke(t *testing.T) {
+	assert := assert.New(t)
+
+	metadata := `{"output": {"abi": ` + importTestABI + `}, "settings": {"compilationTarget": {"SimpleStorage.sol": "SimpleStorage"}}}`
+	ipfs := newTestIPFS(200, metadata)
+	defer ipfs.Close()
+	g, mcs, mrpc, router := newTestIPFSImportGW(&IPFSConf{URL: ipfs.URL, AutoRegister: true}, importTestCode)
+	g.r2e.processor = &mockProcessor{}
+
+	mcs.On("GetContractByAddress", importTestAddr[2:]).Return(nil, fmt.Errorf("not found")).Once()
+	mcs.On("FindABIByContent", mock.Anything).Return(nil, nil)
+	mcs.On("AddABI", mock.Anything, mock.Anything, mock.Anything).Return(&contractregistry.ABIInfo{ID: "abi1", Name: "SimpleStorage"}, nil)
+	mcs.On("AddContract", importTestAddr[2:], "abi1", importTestAddr[2:], "", (map[string]string)(nil)).
+		Return(&contractregistry.ContractInfo{Address: importTestAddr[2:], ABI: "abi1"}, nil)
+	var abi ethbinding.ABIMarshaling
+	_ = json.Unmarshal([]byte(importTestABI), &abi)
+	mcs.On("GetABI", contractregistry.ABILocation{ABIType: contractregistry.LocalABI, Name: "abi1"}, false).
+		Return(&contractregistry.DeployContractWithAddress{Contract: &messages.DeployContract{ABI: abi}}, nil)
+	mrpc.On("CallContext", mock.Anything, mock.Anything, "eth_call", mock.Anything, "latest").Return(nil)
+
+	req := httptest.NewRequest("GET", "/contracts/"+importTestAddr[2:]+"/set?x=1", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Code)
+
+	mcs.AssertExpectations(t)
+	mrpc.AssertExpectations(t)
+}
+
+func TestAutoRegisterContractFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	ipfs := newTestIPFS(200, `{"output": {"abi": `+importTestABI+`}}`)
+	defer ipfs.Close()
+
+	// Not enabled
+	g, _, _, _ := newTestIPFSImportGW(&IPFSConf{URL: ipfs.URL}, importTestCode)
+	assert.Nil(g.autoRegisterContract(context.Background(), importTestAddr[2:]))
+
+	// Fails to read the code
+	g, _, _ = newTestImportGW(ABIImportConf{IPFS: &IPFSConf{URL: ipfs.URL, AutoRegister: true}})
+	mrpc := &ethmocks.RPCClient{}
+	g.rpc = mrpc
+	mrpc.On("CallContext", mock.Anything, mock.Anything, "eth_getCode", importTestAddr, "latest").Return(fmt.Errorf("pop"))
+	assert.Nil(g.autoRegisterContract(context.Background(), importTestAddr[2:]))
+
+	// No metadata hash
+	g, _, _, _ = newTestIPFSImportGW(&IPFSConf{URL: ipfs.URL, AutoRegister: true}, "0x6080")
+	assert.Nil(g.autoRegisterContract(context.Background(), importTestAddr[2:]))
+
+	// Fails to store the ABI
+	g, mcs, _, _ := newTestIPFSImportGW(&IPFSConf{URL: ipfs.URL, AutoRegister: true}, importTestCode)
+	mcs.On("FindABIByContent", mock.Anything).Return(nil, nil)
+	mcs.On("AddABI", mock.Anything, mock.MatchedBy(func(msg *messages.DeployContract) bool {
+		return msg.ContractName == importTestAddr[2:]
+	}), mock.Anything).Return(nil, fmt.Errorf("pop"))
+	assert.Nil(g.autoRegisterContract(context.Background(), importTestAddr[2:]))
+
+	// Registered by another request in the meantime
+	g, mcs, _, _ = newTestIPFSImportGW(&IPFSConf{URL: ipfs.URL, AutoRegister: true}, importTestCode)
+	mcs.On("FindABIByContent", mock.Anything).Return(nil, nil)
+	mcs.On("AddABI", mock.Anything, mock.Anything, mock.Anything).Return(&contractregistry.ABIInfo{ID: "abi1"}, nil)
+	mcs.On("AddContract", importTestAddr[2:], "abi1", importTestAddr[2:], "", (map[string]string)(nil)).Return(nil, fmt.Errorf("pop"))
+	mcs.On("GetContractByAddress", importTestAddr[2:]).Return(&contractregistry.ContractInfo{ABI: "abi2"}, nil).Once()
+	assert.Equal("abi2", g.autoRegisterContract(context.Background(), importTestAddr[2:]).ABI)
+
+	mcs.On("GetContractByAddress", importTestAddr[2:]).Return(nil, fmt.Errorf("pop"))
+	assert.Nil(g.autoRegisterContract(context.Background(), importTestAddr[2:]))
+}
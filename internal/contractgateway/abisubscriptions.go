@@ -0,0 +1,188 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/auth"
+	"github.com/hyperledger/firefly-ethconnect/internal/contractregistry"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	"github.com/hyperledger/firefly-ethconnect/internal/events"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+)
+
+// allABIEvents in the list of events subscribes to every (non-anonymous) event of the ABI
+const allABIEvents = "*"
+
+// abiSubscriptionsRequest is the body of POST /abis/:abi/subscriptions
+type abiSubscriptionsRequest struct {
+	Events    []string `json:"events"`
+	Stream    string   `json:"stream"`
+	Address   string   `json:"address,omitempty"`
+	FromBlock string   `json:"fromBlock,omitempty"`
+	FromTime  string   `json:"fromTime,omitempty"`
+}
+
+// abiSubscriptionEvents returns the events of the ABI to subscribe to, in the order of the ABI.
+// Each entry is an event name, which matches all of its overloads, or a full signature.
+func abiSubscriptionEvents(abi ethbinding.ABIMarshaling, abiID string, requested []string) ([]*ethbinding.ABIElementMarshaling, error) {
+	all := false
+	found := make(map[string]bool)
+	for _, nameOrSig := range requested {
+		nameOrSig = strings.Join(strings.Fields(nameOrSig), "")
+		if nameOrSig == allABIEvents {
+			all = true
+		} else if nameOrSig != "" {
+			found[nameOrSig] = false
+		}
+	}
+	if !all && len(found) == 0 {
+		return nil, errors.Errorf(errors.RESTGatewayBulkSubscribeInvalid, "supply the names of the 'events' to subscribe to, or '*' for every event")
+	}
+	var matched []*ethbinding.ABIElementMarshaling
+	for _, element := range abi {
+		element := element
+		if element.Type != "event" || element.Anonymous {
+			continue
+		}
+		event, err := ethbind.API.ABIElementMarshalingToABIEvent(&element)
+		if err != nil {
+			return nil, err
+		}
+		signature := ethbind.API.ABIEventSignature(event)
+		_, byName := found[element.Name]
+		_, bySig := found[signature]
+		if byName {
+			found[element.Name] = true
+		}
+		if bySig {
+			found[signature] = true
+		}
+		if all || byName || bySig {
+			matched = append(matched, &element)
+		}
+	}
+	for nameOrSig, ok := range found {
+		if !ok {
+			return nil, errors.Errorf(errors.RESTGatewayEventQueryUnknownEvent, nameOrSig, abiID)
+		}
+	}
+	return matched, nil
+}
+
+// addABISubscriptions subscribes a stream to a set of events of an ABI in one request. If any
+// subscription cannot be added, those already added are deleted so none are left behind.
+func (g *smartContractGW) addABISubscriptions(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	if err := auth.AuthEventStreams(req.Context()); err != nil {
+		log.Errorf("Unauthorized: %s", err)
+		g.gatewayErrReply(res, req, errors.Errorf(errors.Unauthorized), 401)
+		return
+	}
+	if g.sm == nil {
+		g.gatewayErrReply(res, req, errEventSupportMissing, 405)
+		return
+	}
+
+	var body abiSubscriptionsRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewayBulkSubscribeInvalid, err), 400)
+		return
+	}
+	if body.Stream == "" {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewaySubscribeMissingStreamParameter), 400)
+		return
+	}
+	var addr *ethbinding.Address
+	if body.Address != "" {
+		addrHexNo0x := strings.TrimPrefix(strings.ToLower(body.Address), "0x")
+		if !hexAddressRegexp.MatchString(addrHexNo0x) {
+			g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewayInvalidToAddress), 400)
+			return
+		}
+		address := ethbind.API.HexToAddress(addrHexNo0x)
+		addr = &address
+	}
+	fromBlock := body.FromBlock
+	if body.FromTime != "" {
+		if fromBlock != "" {
+			g.gatewayErrReply(res, req, errors.Errorf(errors.EventStreamsSubscribeBlockAndTime), 400)
+			return
+		}
+		var err error
+		if fromBlock, err = g.sm.ResolveFromTime(req.Context(), body.FromTime); err != nil {
+			g.gatewayErrReply(res, req, err, 400)
+			return
+		}
+	}
+
+	// The subscriptions are pinned to the ABI by ID, even if it is referred to by name@version
+	abiInfo, err := g.cs.GetLocalABIInfo(params.ByName("abi"))
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 404)
+		return
+	}
+	location := &contractregistry.ABILocation{ABIType: contractregistry.LocalABI, Name: abiInfo.ID}
+	deployMsg, err := g.cs.GetABI(*location, false)
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 404)
+		return
+	}
+	abiEvents, err := abiSubscriptionEvents(deployMsg.Contract.ABI, abiInfo.ID, body.Events)
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 400)
+		return
+	}
+
+	subs := make([]*events.SubscriptionInfo, 0, len(abiEvents))
+	for _, event := range abiEvents {
+		sub, err := g.sm.AddSubscription(req.Context(), addr, location, event, body.Stream, fromBlock, "")
+		if err != nil {
+			if len(subs) > 0 {
+				g.removeABISubscriptions(req.Context(), subs)
+				err = errors.Errorf(errors.RESTGatewayBulkSubscribeFailed, event.Name, len(subs), err)
+			}
+			g.gatewayErrReply(res, req, err, 400)
+			return
+		}
+		subs = append(subs, sub)
+	}
+
+	status := 200
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	_ = json.NewEncoder(res).Encode(subs)
+}
+
+// removeABISubscriptions rolls back the subscriptions of a request that failed part way through.
+// It carries on if the caller has gone away, so the rollback is not left half done.
+func (g *smartContractGW) removeABISubscriptions(ctx context.Context, subs []*events.SubscriptionInfo) {
+	ctx = context.WithoutCancel(ctx)
+	for _, sub := range subs {
+		if err := g.sm.DeleteSubscription(ctx, sub.ID); err != nil {
+			log.Errorf("Failed to remove subscription %s after a failed request: %s", sub.ID, err)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/auth"
+	"github.com/hyperledger/firefly-ethconnect/internal/auth/authtest"
+	"github.com/hyperledger/firefly-ethconnect/internal/contractregistry"
+	"github.com/hyperledger/firefly-ethconnect/internal/events"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/julienschmidt/httprouter"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSubscriptionsABI() ethbinding.ABIMarshaling {
+	return ethbinding.ABIMarshaling{
+		{Type: "function", Name: "set", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "uint256"}}},
+		{Type: "event", Name: "Changed", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "uint256"}}},
+		{Type: "event", Name: "Changed", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "s", Type: "string"}}},
+		{Type: "event", Name: "Reset"},
+		{Type: "event", Name: "Hidden", Anonymous: true},
+	}
+}
+
+func newTestABISubscriptionsGW(t *testing.T, dir string, sm *mockSubMgr) *httprouter.Router {
+	scgw, mcs, _, router := newTestDeleteGW(t, dir)
+	if sm != nil {
+		scgw.sm = sm
+	}
+	mcs.On("GetLocalABIInfo", "simple@1.0.0").Return(&contractregistry.ABIInfo{ID: "abi1"}, nil).Maybe()
+	mcs.On("GetLocalABIInfo", "abi2").Return(nil, fmt.Errorf("pop")).Maybe()
+	mcs.On("GetABI", contractregistry.ABILocation{ABIType: contractregistry.LocalABI, Name: "abi1"}, false).
+		Return(&contractregistry.DeployContractWithAddress{Contract: &messages.DeployContract{ABI: testSubscriptionsABI()}}, nil).Maybe()
+	return router
+}
+
+func postABISubscriptions(router *httprouter.Router, abi, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/abis/"+abi+"/subscriptions", bytes.NewReader([]byte(body)))
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+func TestAddABISubscriptionsAll(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	sm := &mockSubMgr{sub: &events.SubscriptionInfo{ID: "sub1"}}
+	router := newTestABISubscriptionsGW(t, dir, sm)
+
+	res := postABISubscriptions(router, "simple@1.0.0", `{"events":["*"],"stream":"es1","address":"0x0123456789ABCDEF0123456789abcdef01234567","fromBlock":"0"}`)
+	assert.Equal(200, res.Result().StatusCode)
+	var subs []*events.SubscriptionInfo
+	err := json.NewDecoder(res.Body).Decode(&subs)
+	assert.NoError(err)
+	assert.Len(subs, 3)
+	assert.Equal([]string{"Changed", "Changed", "Reset"}, sm.subscribed)
+	assert.Equal("0x0123456789abcdef0123456789abcdef01234567", strings.ToLower(sm.capturedAddr.Hex()))
+	assert.Equal("0", sm.capturedBlock)
+}
+
+func TestAddABISubscriptionsByNameOrSignature(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	sm := &mockSubMgr{sub: &events.SubscriptionInfo{ID: "sub1"}, resolvedBlock: "12345"}
+	router := newTestABISubscriptionsGW(t, dir, sm)
+
+	res := postABISubscriptions(router, "simple@1.0.0", `{"events":["Changed(string)","Reset","Reset"],"stream":"es1","fromTime":"2022-01-01T00:00:00Z"}`)
+	assert.Equal(200, res.Result().StatusCode)
+	assert.Equal([]string{"Changed", "Reset"}, sm.subscribed)
+	assert.Nil(sm.capturedAddr)
+	assert.Equal("12345", sm.capturedBlock)
+}
+
+func TestAddABISubscriptionsRollback(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	sm := &mockSubMgr{sub: &events.SubscriptionInfo{ID: "sub1"}, failEvent: "Reset"}
+	router := newTestABISubscriptionsGW(t, dir, sm)
+
+	res := postABISubscriptions(router, "simple@1.0.0", `{"events":["*"],"stream":"es1"}`)
+	assert.Equal(400, res.Result().StatusCode)
+	var errBody map[string]string
+	json.NewDecoder(res.Body).Decode(&errBody)
+	assert.Equal("FFEC100398", errBody["code"])
+	assert.Regexp("'Reset'.*2 subscriptions.*pop", errBody["error"])
+	assert.Equal([]string{"sub1", "sub1"}, sm.deleted)
+
+	// Nothing to roll back if the first subscription fails
+	dir2 := tempdir()
+	defer cleanup(dir2)
+	sm = &mockSubMgr{failEvent: "Changed"}
+	router = newTestABISubscriptionsGW(t, dir2, sm)
+	res = postABISubscriptions(router, "simple@1.0.0", `{"events":["Changed"],"stream":"es1"}`)
+	assert.Equal(400, res.Result().StatusCode)
+	json.NewDecoder(res.Body).Decode(&errBody)
+	assert.Equal("pop", errBody["error"])
+	assert.Empty(sm.deleted)
+}
+
+func TestAddABISubscriptionsBadRequests(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	router := newTestABISubscriptionsGW(t, dir, &mockSubMgr{resolveErr: fmt.Errorf("pop")})
+
+	for _, test := range []struct {
+		abi    string
+		body   string
+		status int
+		err    string
+	}{
+		{"simple@1.0.0", `!json`, 400, "FFEC100397"},
+		{"simple@1.0.0", `{"events":["*"]}`, 400, "FFEC100100"},
+		{"simple@1.0.0", `{"events":["*"],"stream":"es1","address":"bad"}`, 400, "FFEC100096"},
+		{"simple@1.0.0", `{"events":["*"],"stream":"es1","fromBlock":"0","fromTime":"1h"}`, 400, "FFEC100248"},
+		{"simple@1.0.0", `{"events":["*"],"stream":"es1","fromTime":"1h"}`, 400, "pop"},
+		{"abi2", `{"events":["*"],"stream":"es1"}`, 404, "pop"},
+		{"simple@1.0.0", `{"events":[],"stream":"es1"}`, 400, "FFEC100397"},
+		{"simple@1.0.0", `{"events":["Reset","Hidden"],"stream":"es1"}`, 400, "FFEC100358.*Hidden"},
+	} {
+		res := postABISubscriptions(router, test.abi, test.body)
+		assert.Equal(test.status, res.Result().StatusCode, test.body)
+		var errBody map[string]string
+		json.NewDecoder(res.Body).Decode(&errBody)
+		assert.Regexp(test.err, errBody["code"]+errBody["error"], test.body)
+	}
+}
+
+func TestAddABISubscriptionsNoEventSupport(t *testing.T) {
+	dir := tempdir()
+	defer cleanup(dir)
+	router := newTestABISubscriptionsGW(t, dir, nil)
+
+	res := postABISubscriptions(router, "simple@1.0.0", `{"events":["*"],"stream":"es1"}`)
+	assert.Equal(t, 405, res.Result().StatusCode)
+}
+
+func TestAddABISubscriptionsUnauthorized(t *testing.T) {
+	auth.RegisterSecurityModule(&authtest.TestSecurityModule{})
+	defer auth.RegisterSecurityModule(nil)
+	dir := tempdir()
+	defer cleanup(dir)
+	router := newTestABISubscriptionsGW(t, dir, &mockSubMgr{})
+
+	res := postABISubscriptions(router, "simple@1.0.0", `{"events":["*"],"stream":"es1"}`)
+	assert.Equal(t, 401, res.Result().StatusCode)
+}
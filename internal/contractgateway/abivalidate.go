@@ -0,0 +1,392 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+)
+
+const (
+	abiValidatePathSegment = "validate"
+
+	abiIssueError   = "error"
+	abiIssueWarning = "warning"
+
+	// maxIndexedEventInputs is the number of topics left for indexed inputs, after the event signature
+	maxIndexedEventInputs = 3
+)
+
+// abiTypeAliases are the Solidity shorthand types, which must be written in full in an ABI
+var abiTypeAliases = map[string]string{
+	"uint": "uint256",
+	"int":  "int256",
+	"byte": "bytes1",
+}
+
+// abiValidator is implemented by the gateway, to handle POST /abis/validate on the deploy route
+type abiValidator interface {
+	validateABI(res http.ResponseWriter, req *http.Request, params httprouter.Params)
+}
+
+// abiValidateIssue is a problem with one element of an ABI, by its index in the submitted ABI
+type abiValidateIssue struct {
+	Index    int    `json:"index"`
+	Element  string `json:"element,omitempty"`
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
+// abiValidateSelector is the selector of a function or error, or the topic of an event
+type abiValidateSelector struct {
+	Name      string `json:"name"`
+	Signature string `json:"signature"`
+	Selector  string `json:"selector,omitempty"`
+	Topic     string `json:"topic,omitempty"`
+}
+
+// abiValidateContract is the outcome of validating the ABI of one contract. The ABI is returned in
+// canonical form, without the elements that are in error.
+type abiValidateContract struct {
+	ContractName string                   `json:"contractName,omitempty"`
+	Valid        bool                     `json:"valid"`
+	Issues       []*abiValidateIssue      `json:"issues"`
+	ABI          ethbinding.ABIMarshaling `json:"abi"`
+	Methods      []*abiValidateSelector   `json:"methods"`
+	Events       []*abiValidateSelector   `json:"events"`
+	Errors       []*abiValidateSelector   `json:"errors"`
+}
+
+// abiValidateResponse is returned from POST /abis/validate
+type abiValidateResponse struct {
+	Valid     bool                   `json:"valid"`
+	Contracts []*abiValidateContract `json:"contracts"`
+}
+
+// abiValidateSource is an ABI found in the submitted body, with the name of its contract if known
+type abiValidateSource struct {
+	contractName string
+	abi          ethbinding.ABIMarshaling
+}
+
+// validateABI checks an ABI, or every ABI in a build artifact or solc output, and returns them in
+// canonical form with their selectors. Nothing is stored.
+func (g *smartContractGW) validateABI(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	b, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.ABIValidateInvalidBody, err), 400)
+		return
+	}
+	sources, err := parseABIValidateBody(b)
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 400)
+		return
+	}
+
+	reply := &abiValidateResponse{Valid: true, Contracts: make([]*abiValidateContract, 0, len(sources))}
+	for _, source := range sources {
+		result := validateABI(source.abi)
+		result.ContractName = source.contractName
+		reply.Valid = reply.Valid && result.Valid
+		reply.Contracts = append(reply.Contracts, result)
+	}
+
+	log.Infof("<-- %s %s [%d] valid=%t", req.Method, req.URL, 200, reply.Valid)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(200)
+	enc := json.NewEncoder(res)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(reply)
+}
+
+// parseABIValidateBody finds the ABIs in a JSON ABI, a Truffle, Hardhat or Foundry build artifact,
+// or the output of solc in standard JSON or --combined-json form
+func parseABIValidateBody(b []byte) ([]*abiValidateSource, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '[' {
+		abi, err := parseABIValidateABI(b)
+		if err != nil {
+			return nil, err
+		}
+		return []*abiValidateSource{{abi: abi}}, nil
+	}
+
+	var body struct {
+		ContractName string                     `json:"contractName"`
+		ABI          json.RawMessage            `json:"abi"`
+		Contracts    map[string]json.RawMessage `json:"contracts"`
+	}
+	if err := json.Unmarshal(b, &body); err != nil {
+		return nil, errors.Errorf(errors.ABIValidateInvalidBody, err)
+	}
+	if body.ABI != nil {
+		abi, err := parseABIValidateABI(body.ABI)
+		if err != nil {
+			return nil, err
+		}
+		return []*abiValidateSource{{contractName: body.ContractName, abi: abi}}, nil
+	}
+
+	var sources []*abiValidateSource
+	for key, contract := range body.Contracts {
+		var entries map[string]json.RawMessage
+		if err := json.Unmarshal(contract, &entries); err != nil {
+			return nil, errors.Errorf(errors.ABIValidateInvalidBody, err)
+		}
+		// Combined JSON is keyed by source:Contract, and standard JSON by source and then contract
+		if abiJSON, ok := entries["abi"]; ok {
+			abi, err := parseABIValidateABI(abiJSON)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, &abiValidateSource{contractName: key, abi: abi})
+			continue
+		}
+		for name, entry := range entries {
+			var output struct {
+				ABI json.RawMessage `json:"abi"`
+			}
+			if err := json.Unmarshal(entry, &output); err != nil {
+				return nil, errors.Errorf(errors.ABIValidateInvalidBody, err)
+			}
+			if output.ABI == nil {
+				continue
+			}
+			abi, err := parseABIValidateABI(output.ABI)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, &abiValidateSource{contractName: key + ":" + name, abi: abi})
+		}
+	}
+	if len(sources) == 0 {
+		return nil, errors.Errorf(errors.ABIValidateInvalidBody, "no ABI found")
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].contractName < sources[j].contractName })
+	return sources, nil
+}
+
+// parseABIValidateABI reads an ABI, which older versions of solc embed in their output as a string
+func parseABIValidateABI(b json.RawMessage) (ethbinding.ABIMarshaling, error) {
+	var abiString string
+	if err := json.Unmarshal(b, &abiString); err == nil {
+		b = []byte(abiString)
+	}
+	var abi ethbinding.ABIMarshaling
+	if err := json.Unmarshal(b, &abi); err != nil {
+		return nil, errors.Errorf(errors.ABIValidateInvalidBody, err)
+	}
+	return abi, nil
+}
+
+// abiValidation accumulates the outcome of validating one ABI
+type abiValidation struct {
+	result     *abiValidateContract
+	signatures map[string]bool
+	selectors  map[string]string
+}
+
+func (v *abiValidation) issue(index int, element, severity string, err errors.EthconnectError) {
+	v.result.Issues = append(v.result.Issues, &abiValidateIssue{
+		Index:    index,
+		Element:  element,
+		Severity: severity,
+		Code:     err.Code(),
+		Message:  err.ErrorNoCode(),
+	})
+	if severity == abiIssueError {
+		v.result.Valid = false
+	}
+}
+
+// unique records the signature of an element, returning false if it is already declared
+func (v *abiValidation) unique(index int, elementType, signature string) bool {
+	key := elementType + ":" + signature
+	if v.signatures[key] {
+		v.issue(index, signature, abiIssueError, errors.Errorf(errors.ABIValidateDuplicate, elementType, signature))
+		return false
+	}
+	v.signatures[key] = true
+	return true
+}
+
+// validateABI reports the structural problems of an ABI, and builds its canonical form: types are
+// written in full, and the legacy constant and payable flags are replaced by the state mutability
+func validateABI(abi ethbinding.ABIMarshaling) *abiValidateContract {
+	v := &abiValidation{
+		result: &abiValidateContract{
+			Valid:   true,
+			Issues:  []*abiValidateIssue{},
+			ABI:     ethbinding.ABIMarshaling{},
+			Methods: []*abiValidateSelector{},
+			Events:  []*abiValidateSelector{},
+			Errors:  []*abiValidateSelector{},
+		},
+		signatures: make(map[string]bool),
+		selectors:  make(map[string]string),
+	}
+	for i, element := range abi {
+		if element.Type == "" {
+			// The type of an element defaults to function in the ABI specification
+			element.Type = "function"
+		}
+		canonical := ethbinding.ABIElementMarshaling{
+			Type:      element.Type,
+			Name:      element.Name,
+			Anonymous: element.Anonymous,
+			Inputs:    v.canonicalArgs(i, element.Name, element.Inputs),
+		}
+		label := strings.TrimSpace(element.Type + " " + element.Name)
+		switch element.Type {
+		case "function", "constructor", "fallback", "receive":
+			canonical.Outputs = v.canonicalArgs(i, element.Name, element.Outputs)
+			if element.Type == "function" && element.Name == "" {
+				v.issue(i, "", abiIssueError, errors.Errorf(errors.ABIValidateMissingName, label))
+				continue
+			}
+			canonical.StateMutability = abiStateMutability(&element)
+			method, err := ethbind.API.ABIElementMarshalingToABIMethod(&canonical)
+			if err != nil {
+				v.issue(i, label, abiIssueError, errors.Errorf(errors.ABIValidateInvalidElement, element.Type, element.Name, err))
+				continue
+			}
+			if element.Type != "function" {
+				// There can only be one constructor, fallback and receive function
+				if !v.unique(i, element.Type, element.Type) {
+					continue
+				}
+				canonical.Name = ""
+				break
+			}
+			if !v.unique(i, element.Type, method.Sig) {
+				continue
+			}
+			selector := "0x" + hex.EncodeToString(method.ID)
+			if clash, ok := v.selectors[selector]; ok {
+				v.issue(i, method.Sig, abiIssueError, errors.Errorf(errors.ABIValidateSelectorClash, method.Sig, selector, clash))
+				continue
+			}
+			v.selectors[selector] = method.Sig
+			v.result.Methods = append(v.result.Methods, &abiValidateSelector{Name: method.Name, Signature: method.Sig, Selector: selector})
+		case "event":
+			if element.Name == "" {
+				v.issue(i, "", abiIssueError, errors.Errorf(errors.ABIValidateMissingName, label))
+				continue
+			}
+			event, err := ethbind.API.ABIElementMarshalingToABIEvent(&canonical)
+			if err != nil {
+				v.issue(i, label, abiIssueError, errors.Errorf(errors.ABIValidateInvalidElement, element.Type, element.Name, err))
+				continue
+			}
+			indexed, maxIndexed := 0, maxIndexedEventInputs
+			if event.Anonymous {
+				maxIndexed++
+			}
+			for _, input := range event.Inputs {
+				if input.Indexed {
+					indexed++
+				}
+			}
+			if indexed > maxIndexed {
+				v.issue(i, event.Sig, abiIssueError, errors.Errorf(errors.ABIValidateTooManyIndexed, event.Sig, indexed, maxIndexed))
+				continue
+			}
+			if !v.unique(i, element.Type, event.Sig) {
+				continue
+			}
+			entry := &abiValidateSelector{Name: event.Name, Signature: event.Sig}
+			if !event.Anonymous {
+				entry.Topic = event.ID.Hex()
+			}
+			v.result.Events = append(v.result.Events, entry)
+		case "error":
+			if element.Name == "" {
+				v.issue(i, "", abiIssueError, errors.Errorf(errors.ABIValidateMissingName, label))
+				continue
+			}
+			// Errors are encoded as calls to a function of the same signature
+			method, err := ethbind.API.ABIElementMarshalingToABIMethod(&canonical)
+			if err != nil {
+				v.issue(i, label, abiIssueError, errors.Errorf(errors.ABIValidateInvalidElement, element.Type, element.Name, err))
+				continue
+			}
+			if !v.unique(i, element.Type, method.Sig) {
+				continue
+			}
+			v.result.Errors = append(v.result.Errors, &abiValidateSelector{Name: method.Name, Signature: method.Sig, Selector: "0x" + hex.EncodeToString(method.ID)})
+		default:
+			v.issue(i, label, abiIssueError, errors.Errorf(errors.ABIValidateUnknownType, element.Type))
+			continue
+		}
+		v.result.ABI = append(v.result.ABI, canonical)
+	}
+	return v.result
+}
+
+// abiStateMutability returns the state mutability of a function, worked out from the constant and
+// payable flags of ABIs that were generated before it was added
+func abiStateMutability(element *ethbinding.ABIElementMarshaling) string {
+	switch {
+	case element.StateMutability != "":
+		return element.StateMutability
+	case element.Payable:
+		return "payable"
+	case element.Constant:
+		return "view"
+	default:
+		return "nonpayable"
+	}
+}
+
+// canonicalArgs returns a copy of the arguments of an element, with any type aliases expanded
+func (v *abiValidation) canonicalArgs(index int, elementName string, args []ethbinding.ABIArgumentMarshaling) []ethbinding.ABIArgumentMarshaling {
+	canonical := make([]ethbinding.ABIArgumentMarshaling, len(args))
+	for i, arg := range args {
+		canonical[i] = arg
+		canonical[i].Type = strings.Join(strings.Fields(arg.Type), "")
+		base, dims := canonical[i].Type, ""
+		if bracket := strings.Index(base, "["); bracket >= 0 {
+			base, dims = base[:bracket], base[bracket:]
+		}
+		if full, ok := abiTypeAliases[base]; ok {
+			canonical[i].Type = full + dims
+			argName := arg.Name
+			if argName == "" {
+				argName = fmt.Sprintf("%s[%d]", elementName, i)
+			}
+			v.issue(index, elementName, abiIssueWarning, errors.Errorf(errors.ABIValidateTypeAlias, base, argName, full))
+		}
+		if arg.Components != nil {
+			canonical[i].Components = v.canonicalArgs(index, elementName, arg.Components)
+		}
+	}
+	return canonical
+}
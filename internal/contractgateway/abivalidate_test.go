@@ -0,0 +1,217 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func postValidateABI(router *httprouter.Router, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/abis/validate", strings.NewReader(body))
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+func TestValidateABI(t *testing.T) {
+	assert := assert.New(t)
+	_, _, router := newTestImportGW(ABIImportConf{})
+
+	res := postValidateABI(router, `[
+		{"type": "constructor", "payable": true, "inputs": [{"name": "supply", "type": "uint"}]},
+		{"name": "transfer", "constant": false, "inputs": [{"name": "to", "type": "address"}, {"name": "value", "type": "uint256"}], "outputs": [{"name": "", "type": "bool"}]},
+		{"type": "function", "name": "balanceOf", "constant": true, "inputs": [{"name": "owner", "type": "address"}], "outputs": [{"name": "", "type": "uint256"}]},
+		{"type": "event", "name": "Transfer", "inputs": [{"name": "from", "type": "address", "indexed": true}, {"name": "to", "type": "address", "indexed": true}, {"name": "value", "type": "uint256"}]},
+		{"type": "event", "name": "Log", "anonymous": true, "inputs": [{"name": "data", "type": "bytes"}]},
+		{"type": "error", "name": "Error", "inputs": [{"name": "message", "type": "string"}]},
+		{"type": "receive", "stateMutability": "payable"}
+	]`)
+	assert.Equal(200, res.Result().StatusCode)
+	var reply abiValidateResponse
+	err := json.NewDecoder(res.Body).Decode(&reply)
+	assert.NoError(err)
+
+	assert.True(reply.Valid)
+	assert.Len(reply.Contracts, 1)
+	result := reply.Contracts[0]
+	assert.True(result.Valid)
+	assert.Equal([]*abiValidateIssue{
+		{Index: 0, Severity: "warning", Code: "FFEC100410", Message: "The type 'uint' of 'supply' is an alias for 'uint256'"},
+	}, result.Issues)
+	assert.Equal([]*abiValidateSelector{
+		{Name: "transfer", Signature: "transfer(address,uint256)", Selector: "0xa9059cbb"},
+		{Name: "balanceOf", Signature: "balanceOf(address)", Selector: "0x70a08231"},
+	}, result.Methods)
+	assert.Equal([]*abiValidateSelector{
+		{Name: "Transfer", Signature: "Transfer(address,address,uint256)", Topic: "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"},
+		{Name: "Log", Signature: "Log(bytes)"},
+	}, result.Events)
+	assert.Equal([]*abiValidateSelector{
+		{Name: "Error", Signature: "Error(string)", Selector: "0x08c379a0"},
+	}, result.Errors)
+
+	// The canonical ABI has full types, and the state mutability in place of the legacy flags
+	assert.Len(result.ABI, 7)
+	assert.Equal(ethbinding.ABIElementMarshaling{
+		Type:            "constructor",
+		StateMutability: "payable",
+		Inputs:          []ethbinding.ABIArgumentMarshaling{{Name: "supply", Type: "uint256"}},
+		Outputs:         []ethbinding.ABIArgumentMarshaling{},
+	}, result.ABI[0])
+	assert.Equal("function", result.ABI[1].Type)
+	assert.Equal("nonpayable", result.ABI[1].StateMutability)
+	assert.Equal("view", result.ABI[2].StateMutability)
+	assert.False(result.ABI[2].Constant)
+}
+
+func TestValidateABIIssues(t *testing.T) {
+	assert := assert.New(t)
+
+	result := validateABI(ethbinding.ABIMarshaling{
+		{Type: "function", Name: "burn", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "amount", Type: "uint256"}}},
+		{Type: "function", Name: "burn", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "value", Type: "uint256"}}},
+		{Type: "function", Name: "collate_propagate_storage", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "", Type: "bytes16"}}},
+		{Type: "function", Name: "bad", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "badness"}}},
+		{Type: "function"},
+		{Type: "event", Name: "Many", Inputs: []ethbinding.ABIArgumentMarshaling{
+			{Name: "a", Type: "uint256", Indexed: true}, {Name: "b", Type: "uint256", Indexed: true},
+			{Name: "c", Type: "uint256", Indexed: true}, {Name: "d", Type: "uint256", Indexed: true},
+		}},
+		{Type: "event"},
+		{Type: "error"},
+		{Type: "error", Name: "Bad", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "badness"}}},
+		{Type: "error", Name: "Failed"},
+		{Type: "error", Name: "Failed"},
+		{Type: "fallback"},
+		{Type: "fallback"},
+		{Type: "unknown"},
+	})
+	assert.False(result.Valid)
+	var messages []string
+	for _, issue := range result.Issues {
+		assert.Equal("error", issue.Severity)
+		messages = append(messages, issue.Message)
+	}
+	assert.Equal([]string{
+		"Duplicate function 'burn(uint256)'",
+		"Function 'collate_propagate_storage(bytes16)' has the same selector 0x42966c68 as 'burn(uint256)'",
+		"Invalid function 'bad': unsupported arg type: badness",
+		"The function has no name",
+		"Event 'Many(uint256,uint256,uint256,uint256)' has 4 indexed inputs, and at most 3 are allowed",
+		"The event has no name",
+		"The error has no name",
+		"Invalid error 'Bad': unsupported arg type: badness",
+		"Duplicate error 'Failed()'",
+		"Duplicate fallback 'fallback'",
+		"Unknown element type 'unknown'",
+	}, messages)
+	assert.Equal(3, result.Issues[2].Index)
+	assert.Equal("function bad", result.Issues[2].Element)
+	assert.Len(result.ABI, 3)
+	assert.Len(result.Methods, 1)
+
+	// Anonymous events have a topic free for a fourth indexed input
+	result = validateABI(ethbinding.ABIMarshaling{
+		{Type: "event", Name: "Many", Anonymous: true, Inputs: []ethbinding.ABIArgumentMarshaling{
+			{Name: "a", Type: "uint256", Indexed: true}, {Name: "b", Type: "uint256", Indexed: true},
+			{Name: "c", Type: "uint256", Indexed: true}, {Name: "d", Type: "uint256", Indexed: true},
+		}},
+	})
+	assert.True(result.Valid)
+}
+
+func TestValidateABITypeAliases(t *testing.T) {
+	assert := assert.New(t)
+
+	result := validateABI(ethbinding.ABIMarshaling{
+		{Type: "function", Name: "set", Inputs: []ethbinding.ABIArgumentMarshaling{
+			{Name: "a", Type: "int[2][]"},
+			{Type: "byte"},
+			{Name: "t", Type: "tuple", Components: []ethbinding.ABIArgumentMarshaling{{Name: "u", Type: "uint"}}},
+		}},
+	})
+	assert.True(result.Valid)
+	assert.Len(result.Issues, 3)
+	assert.Equal("The type 'byte' of 'set[1]' is an alias for 'bytes1'", result.Issues[1].Message)
+	assert.Equal("int256[2][]", result.ABI[0].Inputs[0].Type)
+	assert.Equal("bytes1", result.ABI[0].Inputs[1].Type)
+	assert.Equal("uint256", result.ABI[0].Inputs[2].Components[0].Type)
+	assert.Equal("set(int256[2][],bytes1,(uint256))", result.Methods[0].Signature)
+}
+
+func TestValidateABICompilerOutput(t *testing.T) {
+	assert := assert.New(t)
+	_, _, router := newTestImportGW(ABIImportConf{})
+
+	abi := `[{"type": "function", "name": "get", "inputs": [], "outputs": [{"name": "", "type": "uint256"}], "stateMutability": "view"}]`
+	abiString, _ := json.Marshal(abi)
+	for _, test := range []struct {
+		body  string
+		names []string
+	}{
+		{`{"contractName": "Storage", "abi": ` + abi + `, "bytecode": "0x"}`, []string{"Storage"}},
+		{`{"contracts": {"a.sol": {"B": {"abi": ` + abi + `}, "A": {"abi": ` + abi + `}, "I": {"evm": {}}}}}`, []string{"a.sol:A", "a.sol:B"}},
+		{`{"contracts": {"a.sol:A": {"abi": ` + string(abiString) + `, "bin": ""}}, "version": "0.7.6"}`, []string{"a.sol:A"}},
+	} {
+		res := postValidateABI(router, test.body)
+		assert.Equal(200, res.Result().StatusCode, test.body)
+		var reply abiValidateResponse
+		err := json.NewDecoder(res.Body).Decode(&reply)
+		assert.NoError(err)
+		assert.True(reply.Valid)
+		var names []string
+		for _, contract := range reply.Contracts {
+			names = append(names, contract.ContractName)
+			assert.Equal("0x6d4ce63c", contract.Methods[0].Selector)
+		}
+		assert.Equal(test.names, names)
+	}
+
+	res := postValidateABI(router, `[{"type": "function"}]`)
+	assert.Equal(200, res.Result().StatusCode)
+	var reply abiValidateResponse
+	err := json.NewDecoder(res.Body).Decode(&reply)
+	assert.NoError(err)
+	assert.False(reply.Valid)
+}
+
+func TestValidateABIBadBody(t *testing.T) {
+	assert := assert.New(t)
+	_, _, router := newTestImportGW(ABIImportConf{})
+
+	for _, body := range []string{
+		`!json`,
+		`[{"type": 1}]`,
+		`{}`,
+		`{"abi": {"not": "an array"}}`,
+		`{"contracts": {"a.sol": "string"}}`,
+		`{"contracts": {"a.sol": {"A": "string"}}}`,
+		`{"contracts": {"a.sol": {"A": {"abi": "!json"}}}}`,
+		`{"contracts": {"a.sol:A": {"abi": 1}}}`,
+	} {
+		res := postValidateABI(router, body)
+		assert.Equal(400, res.Result().StatusCode, body)
+		var errBody map[string]string
+		json.NewDecoder(res.Body).Decode(&errBody)
+		assert.Equal("FFEC100403", errBody["code"], body)
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/eth"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	log "github.com/sirupsen/logrus"
+)
+
+// solcMetadata is the subset of the Solidity compiler metadata that we use. It is served by
+// Sourcify, and embedded in Truffle (as a JSON string) and Foundry (as an object) artifacts.
+type solcMetadata struct {
+	Compiler struct {
+		Version string `json:"version"`
+	} `json:"compiler"`
+	Output struct {
+		ABI     ethbinding.ABIMarshaling `json:"abi"`
+		DevDoc  json.RawMessage          `json:"devdoc"`
+		UserDoc json.RawMessage          `json:"userdoc"`
+	} `json:"output"`
+	Settings struct {
+		CompilationTarget map[string]string `json:"compilationTarget"`
+	} `json:"settings"`
+	Sources map[string]struct {
+		Content string `json:"content"`
+	} `json:"sources"`
+}
+
+func (m *solcMetadata) contractName() string {
+	for _, contractName := range m.Settings.CompilationTarget {
+		return contractName
+	}
+	return ""
+}
+
+// deployContract returns the ABI and docs from the metadata, along with the source of the contract
+// if it was compiled with literal content. The metadata does not include the bytecode.
+func (m *solcMetadata) deployContract() *messages.DeployContract {
+	msg := &messages.DeployContract{
+		ContractName:    m.contractName(),
+		ABI:             m.Output.ABI,
+		CompilerVersion: m.Compiler.Version,
+		DevDoc:          docString(m.Output.DevDoc),
+		UserDoc:         docString(m.Output.UserDoc),
+	}
+	for sourceFile := range m.Settings.CompilationTarget {
+		msg.Solidity = m.Sources[sourceFile].Content
+	}
+	return msg
+}
+
+// contractArtifact is the subset of a Truffle, Hardhat or Foundry build artifact that we store
+type contractArtifact struct {
+	ContractName string                   `json:"contractName"`
+	ABI          ethbinding.ABIMarshaling `json:"abi"`
+	Bytecode     json.RawMessage          `json:"bytecode"`
+	DevDoc       json.RawMessage          `json:"devdoc"`
+	UserDoc      json.RawMessage          `json:"userdoc"`
+	Compiler     struct {
+		Version string `json:"version"`
+	} `json:"compiler"`
+	Metadata       json.RawMessage        `json:"metadata"`
+	LinkReferences artifactLinkReferences `json:"linkReferences"`
+}
+
+// artifactLinkReferences lists the libraries the bytecode is linked to, by source file and
+// then library name (Hardhat and Foundry)
+type artifactLinkReferences map[string]map[string]json.RawMessage
+
+func (lr artifactLinkReferences) libraryNames() []string {
+	var names []string
+	for source, libraries := range lr {
+		for library := range libraries {
+			names = append(names, source+":"+library)
+		}
+	}
+	return names
+}
+
+// bytecodeHex handles the bytecode as a hex string (Truffle and Hardhat) or as an object
+// with the hex string in "object", alongside the source map and link references (Foundry)
+func (a *contractArtifact) bytecodeHex() (string, error) {
+	if len(a.Bytecode) == 0 || string(a.Bytecode) == "null" {
+		return "", nil
+	}
+	var bytecode string
+	if err := json.Unmarshal(a.Bytecode, &bytecode); err == nil {
+		return bytecode, nil
+	}
+	var foundryBytecode struct {
+		Object         string                 `json:"object"`
+		LinkReferences artifactLinkReferences `json:"linkReferences"`
+	}
+	if err := json.Unmarshal(a.Bytecode, &foundryBytecode); err != nil {
+		return "", err
+	}
+	if a.LinkReferences == nil {
+		a.LinkReferences = foundryBytecode.LinkReferences
+	}
+	return foundryBytecode.Object, nil
+}
+
+// metadata returns the embedded compiler metadata, if there is any
+func (a *contractArtifact) metadata() *solcMetadata {
+	b := []byte(a.Metadata)
+	var metadataString string
+	if err := json.Unmarshal(b, &metadataString); err == nil {
+		b = []byte(metadataString)
+	}
+	var metadata solcMetadata
+	if len(b) == 0 || json.Unmarshal(b, &metadata) != nil {
+		return nil
+	}
+	return &metadata
+}
+
+func docString(doc json.RawMessage) string {
+	if len(doc) == 0 || string(doc) == "null" {
+		return ""
+	}
+	return string(doc)
+}
+
+// parseContractArtifact returns nil if the JSON is not a contract artifact
+func parseContractArtifact(fileName string, b []byte) (*messages.DeployContract, error) {
+	var artifact contractArtifact
+	if err := json.Unmarshal(b, &artifact); err != nil {
+		return nil, errors.Errorf(errors.RESTGatewayBulkABIInvalidArtifact, err)
+	}
+	if len(artifact.ABI) == 0 {
+		return nil, nil
+	}
+	msg := &messages.DeployContract{
+		ContractName:    artifact.ContractName,
+		ABI:             artifact.ABI,
+		CompilerVersion: artifact.Compiler.Version,
+		DevDoc:          docString(artifact.DevDoc),
+		UserDoc:         docString(artifact.UserDoc),
+	}
+	// Foundry only has the contract name, compiler version and NatSpec in the metadata
+	if metadata := artifact.metadata(); metadata != nil {
+		if msg.ContractName == "" {
+			msg.ContractName = metadata.contractName()
+		}
+		if msg.CompilerVersion == "" {
+			msg.CompilerVersion = metadata.Compiler.Version
+		}
+		if msg.DevDoc == "" {
+			msg.DevDoc = docString(metadata.Output.DevDoc)
+		}
+		if msg.UserDoc == "" {
+			msg.UserDoc = docString(metadata.Output.UserDoc)
+		}
+	}
+	if msg.ContractName == "" {
+		msg.ContractName = strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	}
+	bytecode, err := artifact.bytecodeHex()
+	if err != nil {
+		return nil, errors.Errorf(errors.RESTGatewayBulkABIInvalidArtifact, err)
+	}
+	// Abstract contracts and interfaces have no bytecode, but their ABI can be used to call instances
+	if bytecode = strings.TrimPrefix(bytecode, "0x"); bytecode != "" {
+		// Unlinked library placeholders are recorded, so the libraries can be linked on deploy
+		bytecode, msg.LinkReferences = eth.ExtractLinkReferences(bytecode, artifact.LinkReferences.libraryNames())
+		if msg.Compiled, err = hex.DecodeString(bytecode); err != nil {
+			return nil, errors.Errorf(errors.RESTGatewayBulkABIInvalidArtifact, err)
+		}
+	}
+	return msg, nil
+}
+
+// findUploadedArtifacts returns the contract artifacts in the files extracted from an upload,
+// keyed by contract name. Files that are not valid artifacts are ignored.
+func findUploadedArtifacts(dir string) map[string]*messages.DeployContract {
+	artifacts := make(map[string]*messages.DeployContract)
+	_ = filepath.Walk(
+		dir,
+		func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !strings.HasSuffix(p, ".json") {
+				return nil
+			}
+			b, err := ioutil.ReadFile(p)
+			if err == nil {
+				var msg *messages.DeployContract
+				if msg, err = parseContractArtifact(p, b); msg != nil {
+					artifacts[msg.ContractName] = msg
+				}
+			}
+			if err != nil {
+				log.Debugf("Ignoring '%s' as it is not a contract artifact: %s", p, err)
+			}
+			return nil
+		})
+	return artifacts
+}
+
+// selectUploadedArtifact picks the artifact for the named contract, or the only artifact
+func selectUploadedArtifact(artifacts map[string]*messages.DeployContract, contractName string) (*messages.DeployContract, error) {
+	if contractName != "" {
+		if msg, ok := artifacts[contractName]; ok {
+			return msg, nil
+		}
+		return nil, errors.Errorf(errors.RESTGatewayArtifactNotFound, contractName)
+	}
+	if len(artifacts) == 1 {
+		for _, msg := range artifacts {
+			return msg, nil
+		}
+	}
+	return nil, errors.Errorf(errors.RESTGatewayArtifactNotSelected, len(artifacts), strings.Join(artifactNames(artifacts), ","))
+}
+
+func artifactNames(artifacts map[string]*messages.DeployContract) []string {
+	names := make([]string, 0, len(artifacts))
+	for name := range artifacts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
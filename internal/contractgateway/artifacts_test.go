@@ -0,0 +1,213 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/contractregistry"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const foundryTestArtifact = `{
+	"abi": ` + importTestABI + `,
+	"bytecode": {"object": "0x6080", "sourceMap": "", "linkReferences": {}},
+	"deployedBytecode": {"object": "0x60", "sourceMap": "", "linkReferences": {}},
+	"metadata": {
+		"compiler": {"version": "0.8.19+commit.7dd6d404"},
+		"output": {
+			"abi": ` + importTestABI + `,
+			"devdoc": {"title": "Simple storage"},
+			"userdoc": {"notice": "Stores a value"}
+		},
+		"settings": {"compilationTarget": {"src/SimpleStorage.sol": "SimpleStorage"}}
+	}
+}`
+
+func postABIArtifacts(router *httprouter.Router, files map[string]string, fields map[string]string) *httptest.ResponseRecorder {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, content := range files {
+		part, _ := writer.CreateFormFile("files", name)
+		part.Write([]byte(content))
+	}
+	for k, v := range fields {
+		writer.WriteField(k, v)
+	}
+	writer.Close()
+	req := httptest.NewRequest("POST", "/abis", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+func TestParseContractArtifactFoundry(t *testing.T) {
+	assert := assert.New(t)
+
+	msg, err := parseContractArtifact("out/SimpleStorage.sol/SimpleStorage.json", []byte(foundryTestArtifact))
+	assert.NoError(err)
+	assert.Equal("SimpleStorage", msg.ContractName)
+	assert.Equal("0.8.19+commit.7dd6d404", msg.CompilerVersion)
+	assert.Equal(`{"title": "Simple storage"}`, msg.DevDoc)
+	assert.Equal(`{"notice": "Stores a value"}`, msg.UserDoc)
+	assert.Equal([]byte{0x60, 0x80}, msg.Compiled)
+	assert.Len(msg.ABI, 1)
+
+	// Foundry interfaces have empty bytecode, and no metadata with --no-metadata
+	msg, err = parseContractArtifact("out/IStorage.sol/IStorage.json", []byte(`{
+		"abi": `+importTestABI+`,
+		"bytecode": {"object": "0x", "linkReferences": {}}
+	}`))
+	assert.NoError(err)
+	assert.Equal("IStorage", msg.ContractName)
+	assert.Nil(msg.Compiled)
+
+	_, err = parseContractArtifact("Bad.json", []byte(`{"abi": `+importTestABI+`, "bytecode": ["0x6080"]}`))
+	assert.Regexp("FFEC100372", err)
+}
+
+func TestParseContractArtifactLinkReferences(t *testing.T) {
+	assert := assert.New(t)
+
+	// The placeholder solc writes for the library libraries/bigint.sol:BigInt
+	placeholder := "__$30bbc0abd4d6364515865950d3e0d10953$__"
+	zeros := strings.Repeat("0", 40)
+
+	msg, err := parseContractArtifact("artifacts/contracts/Token.sol/Token.json", []byte(`{
+		"contractName": "Token",
+		"abi": `+importTestABI+`,
+		"bytecode": "0x73`+placeholder+`6080",
+		"linkReferences": {"libraries/bigint.sol": {"BigInt": [{"start": 1, "length": 20}]}}
+	}`))
+	assert.NoError(err)
+	assert.Equal("73"+zeros+"6080", hex.EncodeToString(msg.Compiled))
+	assert.Equal([]*messages.LinkReference{{Library: "libraries/bigint.sol:BigInt", Offsets: []int{1}}}, msg.LinkReferences)
+
+	msg, err = parseContractArtifact("out/Token.sol/Token.json", []byte(`{
+		"abi": `+importTestABI+`,
+		"bytecode": {"object": "0x6080`+placeholder+`", "linkReferences": {"libraries/bigint.sol": {"BigInt": [{"start": 2, "length": 20}]}}}
+	}`))
+	assert.NoError(err)
+	assert.Equal("6080"+zeros, hex.EncodeToString(msg.Compiled))
+	assert.Equal([]*messages.LinkReference{{Library: "libraries/bigint.sol:BigInt", Offsets: []int{2}}}, msg.LinkReferences)
+}
+
+func TestParseContractArtifactMetadataString(t *testing.T) {
+	assert := assert.New(t)
+
+	// Truffle embeds the metadata as a string, which has the docs if they were not output separately
+	metadata, _ := json.Marshal(`{
+		"compiler": {"version": "0.8.4+commit.c7e474f2"},
+		"output": {"devdoc": {"title": "Simple storage"}},
+		"settings": {"compilationTarget": {"contracts/SimpleStorage.sol": "SimpleStorage"}}
+	}`)
+	msg, err := parseContractArtifact("build/contracts/Renamed.json", []byte(`{
+		"contractName": "SimpleStorage",
+		"abi": `+importTestABI+`,
+		"bytecode": "0x6080",
+		"metadata": `+string(metadata)+`
+	}`))
+	assert.NoError(err)
+	assert.Equal("SimpleStorage", msg.ContractName)
+	assert.Equal("0.8.4+commit.c7e474f2", msg.CompilerVersion)
+	assert.Equal(`{"title": "Simple storage"}`, msg.DevDoc)
+	assert.Empty(msg.UserDoc)
+
+	// Metadata that cannot be parsed is ignored
+	msg, err = parseContractArtifact("build/contracts/SimpleStorage.json", []byte(`{
+		"abi": `+importTestABI+`,
+		"metadata": "!json"
+	}`))
+	assert.NoError(err)
+	assert.Equal("SimpleStorage", msg.ContractName)
+	assert.Empty(msg.CompilerVersion)
+}
+
+func TestAddABIFromFoundryArtifact(t *testing.T) {
+	assert := assert.New(t)
+	_, mcs, router := newTestImportGW(ABIImportConf{})
+
+	mcs.On("FindABIByContent", mock.Anything).Return(nil, nil)
+	mcs.On("AddABI", mock.Anything, mock.MatchedBy(func(msg *messages.DeployContract) bool {
+		return msg.ContractName == "SimpleStorage" &&
+			msg.CompilerVersion == "0.8.19+commit.7dd6d404" &&
+			bytes.Equal(msg.Compiled, []byte{0x60, 0x80}) &&
+			msg.Headers.ID != ""
+	}), mock.Anything).Return(&contractregistry.ABIInfo{ID: "abi1", Name: "SimpleStorage"}, nil)
+
+	res := postABIArtifacts(router, map[string]string{"SimpleStorage.json": foundryTestArtifact}, nil)
+	assert.Equal(200, res.Code)
+	var info contractregistry.ABIInfo
+	json.NewDecoder(res.Body).Decode(&info)
+	assert.Equal("abi1", info.ID)
+
+	mcs.AssertExpectations(t)
+}
+
+func TestAddABIFromHardhatArtifactsSelectContract(t *testing.T) {
+	assert := assert.New(t)
+	_, mcs, router := newTestImportGW(ABIImportConf{})
+
+	archive := zipArtifacts(map[string]string{
+		"artifacts/contracts/SimpleStorage.sol/SimpleStorage.json": `{
+			"_format": "hh-sol-artifact-1",
+			"contractName": "SimpleStorage",
+			"abi": ` + importTestABI + `,
+			"bytecode": "0x6080"
+		}`,
+		"artifacts/contracts/SimpleStorage.sol/SimpleStorage.dbg.json": `{"_format": "hh-sol-dbg-1", "buildInfo": "../build-info/abc.json"}`,
+		"artifacts/contracts/IStorage.sol/IStorage.json": `{
+			"_format": "hh-sol-artifact-1",
+			"contractName": "IStorage",
+			"abi": ` + importTestABI + `,
+			"bytecode": "0x"
+		}`,
+	})
+	files := map[string]string{"artifacts.zip": string(archive)}
+
+	res := postABIArtifacts(router, files, map[string]string{"findcontracts": ""})
+	assert.Equal(200, res.Code)
+	var contractNames []string
+	json.NewDecoder(res.Body).Decode(&contractNames)
+	assert.Equal([]string{"IStorage", "SimpleStorage"}, contractNames)
+
+	res = postABIArtifacts(router, files, nil)
+	assert.Equal(400, res.Code)
+	assert.Regexp("IStorage,SimpleStorage.*FFEC100378", res.Body.String())
+
+	res = postABIArtifacts(router, files, map[string]string{"contract": "Unknown"})
+	assert.Equal(400, res.Code)
+	assert.Regexp("Unknown.*FFEC100379", res.Body.String())
+
+	mcs.On("FindABIByContent", mock.Anything).Return(nil, nil)
+	mcs.On("AddABI", mock.Anything, mock.MatchedBy(func(msg *messages.DeployContract) bool {
+		return msg.ContractName == "SimpleStorage"
+	}), mock.Anything).Return(&contractregistry.ABIInfo{ID: "abi1", Name: "SimpleStorage"}, nil)
+
+	res = postABIArtifacts(router, files, map[string]string{"contract": "SimpleStorage"})
+	assert.Equal(200, res.Code)
+
+	mcs.AssertExpectations(t)
+}
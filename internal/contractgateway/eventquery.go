@@ -0,0 +1,208 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/contractregistry"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	"github.com/hyperledger/firefly-ethconnect/internal/events"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+)
+
+const (
+	// DefaultEventQueryMaxRange is the largest block range of a GET /events query, unless configured
+	DefaultEventQueryMaxRange = 10000
+	// defaultEventQueryLimit is the page size of a GET /events query without a limit
+	defaultEventQueryLimit = 100
+)
+
+// parseQueryBlock parses a block number in decimal or 0x prefixed hex, with "latest" or an empty
+// string returning nil
+func parseQueryBlock(param, str string) (*big.Int, error) {
+	if str == "" || str == "latest" {
+		return nil, nil
+	}
+	i, ok := new(big.Int).SetString(str, 0)
+	if !ok || i.Sign() < 0 {
+		return nil, errors.Errorf(errors.RESTGatewayEventQueryInvalid, param+" must be a block number or 'latest'")
+	}
+	return i, nil
+}
+
+// eventQueryTarget resolves the contract of an event query, and the ABI to decode its logs with.
+// The ABI of a registered contract is used, unless an ABI is supplied for an unregistered address.
+func (g *smartContractGW) eventQueryTarget(addrOrName, abiID string) (string, string, error) {
+	addrHexNo0x := strings.TrimPrefix(strings.ToLower(addrOrName), "0x")
+	if abiID != "" {
+		if !hexAddressRegexp.MatchString(addrHexNo0x) {
+			return "", "", errors.Errorf(errors.RESTGatewayEventQueryInvalid, "address must be a contract address when abi is supplied")
+		}
+		return addrHexNo0x, abiID, nil
+	}
+	info, err := g.cs.GetContractByAddress(addrHexNo0x)
+	if err != nil {
+		if addrHexNo0x, err = g.cs.ResolveContractAddress(addrOrName); err != nil {
+			return "", "", err
+		}
+		if info, err = g.cs.GetContractByAddress(addrHexNo0x); err != nil {
+			return "", "", err
+		}
+	}
+	return info.Address, info.ABI, nil
+}
+
+// eventQueryEvents returns the events of the ABI that match a name or signature, or all of the
+// events that can be matched by topic if none is supplied
+func eventQueryEvents(runtimeABI *ethbinding.RuntimeABI, abiID, nameOrSig string) ([]*ethbinding.ABIEvent, error) {
+	nameOrSig = strings.Join(strings.Fields(nameOrSig), "")
+	var matched []*ethbinding.ABIEvent
+	for _, event := range runtimeABI.Events {
+		event := event
+		if event.Anonymous {
+			continue
+		}
+		if nameOrSig == "" || event.Name == nameOrSig || ethbind.API.ABIEventSignature(&event) == nameOrSig {
+			matched = append(matched, &event)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, errors.Errorf(errors.RESTGatewayEventQueryUnknownEvent, nameOrSig, abiID)
+	}
+	return matched, nil
+}
+
+// eventQueryPage parses the block range and paging parameters of an event query
+func eventQueryPage(req *http.Request) (fromBlock, toBlock *big.Int, limit, skip int, err error) {
+	query := req.URL.Query()
+	if fromBlock, err = parseQueryBlock("fromBlock", query.Get("fromBlock")); err != nil {
+		return nil, nil, 0, 0, err
+	}
+	if toBlock, err = parseQueryBlock("toBlock", query.Get("toBlock")); err != nil {
+		return nil, nil, 0, 0, err
+	}
+	limit = defaultEventQueryLimit
+	for _, param := range []string{"limit", "skip"} {
+		if str := query.Get(param); str != "" {
+			i, err := strconv.Atoi(str)
+			if err != nil || i < 0 {
+				return nil, nil, 0, 0, errors.Errorf(errors.RESTGatewayInvalidListingParam, param, str)
+			}
+			if param == "limit" {
+				limit = i
+			} else {
+				skip = i
+			}
+		}
+	}
+	return fromBlock, toBlock, limit, skip, nil
+}
+
+// queryEvents performs an on-demand eth_getLogs for the events of a contract, over a bounded block
+// range, returning a page of the decoded events oldest first
+func (g *smartContractGW) queryEvents(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	query := req.URL.Query()
+	if query.Get("address") == "" {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewayEventQueryInvalid, "address is required"), 400)
+		return
+	}
+	fromBlock, toBlock, limit, skip, err := eventQueryPage(req)
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 400)
+		return
+	}
+
+	addrHexNo0x, abiID, err := g.eventQueryTarget(query.Get("address"), query.Get("abi"))
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 404)
+		return
+	}
+	deployMsg, err := g.cs.GetABI(contractregistry.ABILocation{ABIType: contractregistry.LocalABI, Name: abiID}, false)
+	if err != nil || deployMsg == nil || deployMsg.Contract == nil {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewayLocalStoreABINotFound, abiID), 404)
+		return
+	}
+	runtimeABI, err := ethbind.API.ABIMarshalingToABIRuntime(deployMsg.Contract.ABI)
+	if err != nil {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewayInvalidABI, err), 500)
+		return
+	}
+	abiEvents, err := eventQueryEvents(runtimeABI, abiID, query.Get("event"))
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 404)
+		return
+	}
+
+	// The range is bounded, so an open-ended query is taken back from the latest block
+	maxRange := g.conf.EventQueryMaxRange
+	if maxRange <= 0 {
+		maxRange = DefaultEventQueryMaxRange
+	}
+	if toBlock == nil {
+		if toBlock, err = events.LatestBlock(req.Context(), g.rpc); err != nil {
+			g.gatewayErrReply(res, req, err, 500)
+			return
+		}
+	}
+	if fromBlock == nil {
+		fromBlock = new(big.Int).Sub(toBlock, big.NewInt(maxRange-1))
+		if fromBlock.Sign() < 0 {
+			fromBlock.SetInt64(0)
+		}
+	}
+	if fromBlock.Cmp(toBlock) > 0 {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewayEventQueryInvalid, "fromBlock is after toBlock"), 400)
+		return
+	}
+	if blocks := new(big.Int).Sub(toBlock, fromBlock); blocks.Cmp(big.NewInt(maxRange-1)) > 0 {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewayEventQueryRangeTooLarge, fromBlock, toBlock, maxRange), 400)
+		return
+	}
+
+	results, err := events.QueryLogs(req.Context(), g.rpc, &events.LogQuery{
+		Address:   ethbind.API.HexToAddress(addrHexNo0x),
+		Events:    abiEvents,
+		FromBlock: fromBlock,
+		ToBlock:   toBlock,
+	})
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 500)
+		return
+	}
+	if skip > len(results) {
+		skip = len(results)
+	}
+	results = results[skip:]
+	if limit > 0 && limit < len(results) {
+		results = results[:limit]
+	}
+
+	status := 200
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	_ = json.NewEncoder(res).Encode(results)
+}
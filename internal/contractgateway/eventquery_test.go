@@ -0,0 +1,201 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/contractregistry"
+	"github.com/hyperledger/firefly-ethconnect/internal/events"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/mocks/contractregistrymocks"
+	"github.com/hyperledger/firefly-ethconnect/mocks/ethmocks"
+	"github.com/julienschmidt/httprouter"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	testQueryAddr     = "0123456789abcdef0123456789abcdef01234567"
+	testTransferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	testApprovalTopic = "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"
+)
+
+func testERC20Events() ethbinding.ABIMarshaling {
+	inputs := []ethbinding.ABIArgumentMarshaling{
+		{Name: "from", Type: "address", Indexed: true},
+		{Name: "to", Type: "address", Indexed: true},
+		{Name: "value", Type: "uint256"},
+	}
+	return ethbinding.ABIMarshaling{
+		{Type: "event", Name: "Transfer", Inputs: inputs},
+		{Type: "event", Name: "Approval", Inputs: inputs},
+		{Type: "event", Name: "Anon", Inputs: inputs, Anonymous: true},
+	}
+}
+
+func newTestEventQueryGW(maxRange int64) (*contractregistrymocks.ContractStore, *ethmocks.RPCClient, *httprouter.Router) {
+	mcs := &contractregistrymocks.ContractStore{}
+	mrpc := &ethmocks.RPCClient{}
+	g := &smartContractGW{
+		conf: &SmartContractGatewayConf{EventQueryMaxRange: maxRange},
+		cs:   mcs,
+		rpc:  mrpc,
+	}
+	router := &httprouter.Router{}
+	g.AddRoutes(router)
+	mcs.On("GetContractByAddress", testQueryAddr).Return(&contractregistry.ContractInfo{Address: testQueryAddr, ABI: "abi1"}, nil)
+	mcs.On("GetContractByAddress", mock.Anything).Return(nil, fmt.Errorf("not found"))
+	mcs.On("ResolveContractAddress", "token").Return(testQueryAddr, nil)
+	mcs.On("ResolveContractAddress", mock.Anything).Return("", fmt.Errorf("not found"))
+	mcs.On("GetABI", contractregistry.ABILocation{ABIType: contractregistry.LocalABI, Name: "abi1"}, false).
+		Return(&contractregistry.DeployContractWithAddress{Contract: &messages.DeployContract{ABI: testERC20Events()}}, nil)
+	mcs.On("GetABI", contractregistry.ABILocation{ABIType: contractregistry.LocalABI, Name: "badabi"}, false).
+		Return(&contractregistry.DeployContractWithAddress{Contract: &messages.DeployContract{ABI: ethbinding.ABIMarshaling{
+			{Type: "event", Name: "Bad", Inputs: []ethbinding.ABIArgumentMarshaling{{Type: "badness"}}},
+		}}}, nil)
+	mcs.On("GetABI", mock.Anything, false).Return(nil, fmt.Errorf("not found"))
+	return mcs, mrpc, router
+}
+
+func getEvents(router *httprouter.Router, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/events?"+query, nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+func mockGetLogs(mrpc *ethmocks.RPCClient, fromBlock int64, toBlock string, topics int, logsJSON string) {
+	mrpc.On("CallContext", mock.Anything, mock.Anything, "eth_getLogs", mock.MatchedBy(func(f interface{}) bool {
+		b, _ := json.Marshal(f)
+		var filter struct {
+			FromBlock ethbinding.HexBigInt `json:"fromBlock"`
+			ToBlock   string               `json:"toBlock"`
+			Topics    [][]string           `json:"topics"`
+		}
+		_ = json.Unmarshal(b, &filter)
+		return filter.FromBlock.ToInt().Int64() == fromBlock && filter.ToBlock == toBlock && len(filter.Topics[0]) == topics
+	})).Run(func(args mock.Arguments) {
+		_ = json.Unmarshal([]byte(logsJSON), args[1])
+	}).Return(nil)
+}
+
+func testTransferLog(blockNumber string) string {
+	return `{
+		"address": "0x` + testQueryAddr + `",
+		"blockNumber": "` + blockNumber + `",
+		"logIndex": "0x1",
+		"topics": [
+			"` + testTransferTopic + `",
+			"0x000000000000000000000000aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+			"0x000000000000000000000000bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+		],
+		"data": "0x000000000000000000000000000000000000000000000000000000000000000a"
+	}`
+}
+
+func TestQueryEventsByName(t *testing.T) {
+	assert := assert.New(t)
+	_, mrpc, router := newTestEventQueryGW(0)
+
+	mockGetLogs(mrpc, 100, "0xc8", 1, `[`+testTransferLog("0x64")+`,`+testTransferLog("0x65")+`,`+testTransferLog("0x66")+`]`)
+
+	res := getEvents(router, "address=token&event=Transfer&fromBlock=100&toBlock=0xc8&skip=1&limit=1")
+	assert.Equal(200, res.Code)
+	var results []*events.QueriedEvent
+	json.NewDecoder(res.Body).Decode(&results)
+	assert.Len(results, 1)
+	assert.Equal("101", results[0].BlockNumber)
+	assert.Equal("1", results[0].LogIndex)
+	assert.Equal("Transfer(address,address,uint256)", results[0].Signature)
+	assert.Equal("10", results[0].Data["value"])
+
+	mrpc.AssertExpectations(t)
+}
+
+func TestQueryEventsAllEventsLatestRange(t *testing.T) {
+	assert := assert.New(t)
+	_, mrpc, router := newTestEventQueryGW(50)
+
+	mrpc.On("CallContext", mock.Anything, mock.Anything, "eth_blockNumber").
+		Run(func(args mock.Arguments) {
+			args[1].(*ethbinding.HexBigInt).ToInt().SetInt64(1000)
+		}).Return(nil)
+	mockGetLogs(mrpc, 951, "0x3e8", 2, `[`+testTransferLog("0x3e0")+`]`)
+
+	res := getEvents(router, "address=0x"+testQueryAddr)
+	assert.Equal(200, res.Code)
+	var results []*events.QueriedEvent
+	json.NewDecoder(res.Body).Decode(&results)
+	assert.Len(results, 1)
+
+	// A range starting before the genesis block starts at zero
+	mockGetLogs(mrpc, 0, "0xa", 1, `[]`)
+	res = getEvents(router, "address=0x"+testQueryAddr+"&event=Approval(address,address,uint256)&toBlock=10&skip=5")
+	assert.Equal(200, res.Code)
+	assert.Equal("[]\n", res.Body.String())
+
+	mrpc.AssertExpectations(t)
+}
+
+func TestQueryEventsSuppliedABI(t *testing.T) {
+	assert := assert.New(t)
+	_, mrpc, router := newTestEventQueryGW(0)
+
+	mockGetLogs(mrpc, 1, "0x2", 2, `[]`)
+	res := getEvents(router, "address=0xffffffffffffffffffffffffffffffffffffffff&abi=abi1&fromBlock=1&toBlock=2")
+	assert.Equal(200, res.Code)
+
+	res = getEvents(router, "address=token&abi=abi1")
+	assert.Equal(404, res.Code)
+	assert.Regexp("FFEC100356", res.Body.String())
+}
+
+func TestQueryEventsErrors(t *testing.T) {
+	assert := assert.New(t)
+	_, mrpc, router := newTestEventQueryGW(100)
+
+	mrpc.On("CallContext", mock.Anything, mock.Anything, "eth_blockNumber").Return(fmt.Errorf("pop"))
+	mrpc.On("CallContext", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).Return(fmt.Errorf("pop"))
+
+	for _, test := range []struct {
+		query  string
+		status int
+		regexp string
+	}{
+		{"", 400, "address.*FFEC100356"},
+		{"address=token&fromBlock=-1", 400, "fromBlock.*FFEC100356"},
+		{"address=token&toBlock=abc", 400, "toBlock.*FFEC100356"},
+		{"address=token&limit=x", 400, "FFEC100345"},
+		{"address=token&skip=-1", 400, "FFEC100345"},
+		{"address=other", 404, "not found"},
+		{"address=0xffffffffffffffffffffffffffffffffffffffff&abi=missing", 404, "FFEC100"},
+		{"address=0xffffffffffffffffffffffffffffffffffffffff&abi=badabi", 500, "FFEC100"},
+		{"address=token&event=Anon", 404, "FFEC100358"},
+		{"address=token&event=Missing(uint256)", 404, "FFEC100358"},
+		{"address=token&fromBlock=20&toBlock=10", 400, "after.*FFEC100356"},
+		{"address=token&fromBlock=0&toBlock=100", 400, "FFEC100357"},
+		{"address=token", 500, "eth_blockNumber.*pop"},
+		{"address=token&fromBlock=1&toBlock=2", 500, "eth_getLogs.*pop"},
+	} {
+		res := getEvents(router, test.query)
+		assert.Equal(test.status, res.Code, test.query)
+		assert.Regexp(test.regexp, res.Body.String(), test.query)
+	}
+}
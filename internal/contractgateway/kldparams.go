@@ -22,6 +22,24 @@ import (
 	"github.com/hyperledger/firefly-ethconnect/internal/utils"
 )
 
+// flyParams are the names of the 'fly' params read by the contract APIs
+var flyParams = []string{
+	"acktype", "blocknumber", "call", "ethvalue", "from", "gas", "gasprice", "id", "library", "noack",
+	"privacygroupid", "privatefor", "privatefrom", "register", "replymode", "replywebhook", "simulate",
+	"sync", "transaction",
+}
+
+// FlyParamNames returns the query parameter names of the 'fly' params accepted by the contract APIs.
+// Each can also be supplied as an x-firefly-* header.
+func FlyParamNames() []string {
+	prefix := utils.GetenvOrDefaultLowerCase("PREFIX_SHORT", "fly") + "-"
+	names := make([]string, len(flyParams))
+	for i, name := range flyParams {
+		names[i] = prefix + name
+	}
+	return names
+}
+
 func getQueryParamNoCase(name string, req *http.Request) []string {
 	name = strings.ToLower(name)
 	req.ParseForm()
@@ -0,0 +1,110 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/contractregistry"
+	"github.com/hyperledger/firefly-ethconnect/internal/eth"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	log "github.com/sirupsen/logrus"
+)
+
+// proxyResolver is implemented by the gateway, so REST invocations of a proxy use the ABI of
+// its current implementation
+type proxyResolver interface {
+	resolveProxy(ctx context.Context, info *contractregistry.ContractInfo) *contractregistry.ContractInfo
+}
+
+// resolveProxy reads the EIP-1967 or EIP-1822 implementation slot of a registered contract, or
+// the implementation embedded in the code of an ERC-1167 clone, and links the registration to the
+// implementation if it has changed. Failures are logged, and the existing registration is returned.
+func (g *smartContractGW) resolveProxy(ctx context.Context, info *contractregistry.ContractInfo) *contractregistry.ContractInfo {
+	if info.Proxy != nil && info.Proxy.Standard == eth.ProxyStandardERC1167 {
+		// The implementation of a clone is fixed in its code, so there is nothing to read from the
+		// chain, but the implementation might have been registered since the clone was
+		return g.linkCloneImplementationABI(info)
+	}
+	if g.rpc == nil {
+		return info
+	}
+	standard, impl, err := eth.GetProxyImplementation(ctx, g.rpc, info.Address)
+	if err != nil {
+		log.Warnf("Failed to check whether %s is a proxy: %s", info.Address, err)
+		return info
+	}
+	return g.linkProxyImplementation(info, standard, impl)
+}
+
+// linkProxyImplementation records the implementation of a proxy. If the implementation is
+// itself a registered contract, the proxy is registered with its ABI, so invocations and
+// decoded events use the implementation ABI at the address of the proxy.
+func (g *smartContractGW) linkProxyImplementation(info *contractregistry.ContractInfo, standard, implHexNo0x string) *contractregistry.ContractInfo {
+	if implHexNo0x == "" || (info.Proxy != nil && info.Proxy.Implementation == implHexNo0x) {
+		return info
+	}
+	updated := *info
+	updated.Proxy = &contractregistry.ProxyInfo{
+		Standard:       standard,
+		Implementation: implHexNo0x,
+	}
+	if implInfo, err := g.cs.GetContractByAddress(implHexNo0x); err == nil {
+		updated.ABI = implInfo.ABI
+	} else {
+		log.Warnf("Implementation %s of proxy %s is not registered. Keeping ABI %s", implHexNo0x, info.Address, info.ABI)
+	}
+	if err := g.cs.UpdateContract(&updated); err != nil {
+		log.Errorf("Failed to link proxy %s to implementation %s: %s", info.Address, implHexNo0x, err)
+		return info
+	}
+	log.Infof("Proxy %s linked to implementation %s with ABI %s", info.Address, implHexNo0x, updated.ABI)
+	return &updated
+}
+
+// linkCloneImplementationABI updates a clone to use the ABI of its implementation, if the
+// implementation is registered with a different ABI
+func (g *smartContractGW) linkCloneImplementationABI(info *contractregistry.ContractInfo) *contractregistry.ContractInfo {
+	implInfo, err := g.cs.GetContractByAddress(info.Proxy.Implementation)
+	if err != nil || implInfo.ABI == info.ABI {
+		return info
+	}
+	updated := *info
+	updated.ABI = implInfo.ABI
+	if err := g.cs.UpdateContract(&updated); err != nil {
+		log.Errorf("Failed to link clone %s to the ABI of implementation %s: %s", info.Address, info.Proxy.Implementation, err)
+		return info
+	}
+	log.Infof("Clone %s linked to implementation %s with ABI %s", info.Address, info.Proxy.Implementation, updated.ABI)
+	return &updated
+}
+
+// processProxyUpgrades links registered proxies to their new implementation, when the receipt
+// contains an EIP-1967 Upgraded event
+func (g *smartContractGW) processProxyUpgrades(msg *messages.TransactionReceipt) {
+	for _, l := range msg.Logs {
+		if l.Address == nil || len(l.Topics) < 2 || l.Topics[0] == nil || l.Topics[1] == nil ||
+			l.Topics[0].Hex() != eth.EIP1967UpgradedTopic {
+			continue
+		}
+		addrHexNo0x := strings.ToLower(l.Address.Hex()[2:])
+		info, err := g.cs.GetContractByAddress(addrHexNo0x)
+		if err != nil {
+			continue
+		}
+		g.linkProxyImplementation(info, eth.ProxyStandardEIP1967, eth.AddressFromWord(l.Topics[1].Hex()))
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/contractregistry"
+	"github.com/hyperledger/firefly-ethconnect/internal/eth"
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/mocks/contractregistrymocks"
+	"github.com/hyperledger/firefly-ethconnect/mocks/ethmocks"
+	"github.com/julienschmidt/httprouter"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	testProxyAddr = "0123456789abcdef0123456789abcdef01234567"
+	testImplAddr  = "1123456789abcdef0123456789abcdef01234567"
+	testImplAddr2 = "2123456789abcdef0123456789abcdef01234567"
+)
+
+func mockImplementationSlot(rpc *ethmocks.RPCClient, implHexNo0x string) {
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_getStorageAt", "0x"+testProxyAddr, mock.Anything, "latest").
+		Run(func(args mock.Arguments) {
+			*(args[1].(*string)) = "0x000000000000000000000000" + implHexNo0x
+		}).
+		Return(nil)
+}
+
+func mockCode(rpc *ethmocks.RPCClient, code string) {
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_getCode", "0x"+testProxyAddr, "latest").
+		Run(func(args mock.Arguments) {
+			*(args[1].(*string)) = code
+		}).
+		Return(nil)
+}
+
+func TestResolveProxyLinksImplementationABI(t *testing.T) {
+	assert := assert.New(t)
+
+	mcs := &contractregistrymocks.ContractStore{}
+	mrpc := &ethmocks.RPCClient{}
+	scgw := &smartContractGW{cs: mcs, rpc: mrpc}
+
+	mockImplementationSlot(mrpc, testImplAddr)
+	mcs.On("GetContractByAddress", testImplAddr).Return(&contractregistry.ContractInfo{ABI: "implabi"}, nil)
+	mcs.On("UpdateContract", mock.MatchedBy(func(info *contractregistry.ContractInfo) bool {
+		return info.ABI == "implabi" && info.Proxy.Implementation == testImplAddr
+	})).Return(nil)
+
+	info := scgw.resolveProxy(context.Background(), &contractregistry.ContractInfo{Address: testProxyAddr, ABI: "proxyabi"})
+	assert.Equal("implabi", info.ABI)
+	assert.Equal(&contractregistry.ProxyInfo{Standard: "EIP-1967", Implementation: testImplAddr}, info.Proxy)
+
+	// No change if the implementation is the same
+	same := scgw.resolveProxy(context.Background(), info)
+	assert.Equal(info, same)
+
+	mcs.AssertExpectations(t)
+	mcs.AssertNumberOfCalls(t, "UpdateContract", 1)
+}
+
+func TestResolveProxyNotProxy(t *testing.T) {
+	assert := assert.New(t)
+
+	mcs := &contractregistrymocks.ContractStore{}
+	mrpc := &ethmocks.RPCClient{}
+	scgw := &smartContractGW{cs: mcs, rpc: mrpc}
+
+	mockImplementationSlot(mrpc, "0000000000000000000000000000000000000000")
+	mockCode(mrpc, "0x608060405234801561001057600080fd5b50")
+
+	info := &contractregistry.ContractInfo{Address: testProxyAddr, ABI: "abi1"}
+	assert.Equal(info, scgw.resolveProxy(context.Background(), info))
+	mcs.AssertExpectations(t)
+}
+
+func TestResolveProxyEIP1822(t *testing.T) {
+	assert := assert.New(t)
+
+	mcs := &contractregistrymocks.ContractStore{}
+	mrpc := &ethmocks.RPCClient{}
+	scgw := &smartContractGW{cs: mcs, rpc: mrpc}
+
+	mrpc.On("CallContext", mock.Anything, mock.Anything, "eth_getStorageAt", "0x"+testProxyAddr, eth.EIP1967ImplementationSlot, "latest").
+		Run(func(args mock.Arguments) {
+			*(args[1].(*string)) = "0x0"
+		}).
+		Return(nil)
+	mrpc.On("CallContext", mock.Anything, mock.Anything, "eth_getStorageAt", "0x"+testProxyAddr, eth.EIP1822ProxiableSlot, "latest").
+		Run(func(args mock.Arguments) {
+			*(args[1].(*string)) = "0x000000000000000000000000" + testImplAddr
+		}).
+		Return(nil)
+	mcs.On("GetContractByAddress", testImplAddr).Return(&contractregistry.ContractInfo{ABI: "implabi"}, nil)
+	mcs.On("UpdateContract", mock.Anything).Return(nil)
+
+	info := scgw.resolveProxy(context.Background(), &contractregistry.ContractInfo{Address: testProxyAddr, ABI: "proxyabi"})
+	assert.Equal("implabi", info.ABI)
+	assert.Equal(&contractregistry.ProxyInfo{Standard: "EIP-1822", Implementation: testImplAddr}, info.Proxy)
+	mcs.AssertExpectations(t)
+}
+
+func TestResolveProxyERC1167(t *testing.T) {
+	assert := assert.New(t)
+
+	mcs := &contractregistrymocks.ContractStore{}
+	mrpc := &ethmocks.RPCClient{}
+	scgw := &smartContractGW{cs: mcs, rpc: mrpc}
+
+	mockImplementationSlot(mrpc, "0000000000000000000000000000000000000000")
+	mockCode(mrpc, "0x363d3d373d3d3d363d73"+testImplAddr+"5af43d82803e903d91602b57fd5bf3")
+	mcs.On("GetContractByAddress", testImplAddr).Return(nil, fmt.Errorf("not found")).Once()
+	mcs.On("UpdateContract", mock.MatchedBy(func(info *contractregistry.ContractInfo) bool {
+		return info.ABI == "cloneabi" && info.Proxy.Standard == eth.ProxyStandardERC1167
+	})).Return(nil).Once()
+
+	// Registering a clone before its implementation records the implementation only
+	info := scgw.resolveProxy(context.Background(), &contractregistry.ContractInfo{Address: testProxyAddr, ABI: "cloneabi"})
+	assert.Equal("cloneabi", info.ABI)
+	assert.Equal(&contractregistry.ProxyInfo{Standard: "ERC-1167", Implementation: testImplAddr}, info.Proxy)
+
+	// Once the implementation is registered, its ABI is linked without reading the chain again
+	mcs.On("GetContractByAddress", testImplAddr).Return(&contractregistry.ContractInfo{ABI: "implabi"}, nil)
+	mcs.On("UpdateContract", mock.MatchedBy(func(info *contractregistry.ContractInfo) bool {
+		return info.ABI == "implabi" && info.Proxy.Implementation == testImplAddr
+	})).Return(nil).Once()
+	info = scgw.resolveProxy(context.Background(), info)
+	assert.Equal("implabi", info.ABI)
+	assert.Equal(info, scgw.resolveProxy(context.Background(), info))
+
+	mcs.AssertExpectations(t)
+	mcs.AssertNumberOfCalls(t, "UpdateContract", 2)
+	mrpc.AssertNumberOfCalls(t, "CallContext", 3)
+}
+
+func TestResolveProxyERC1167UpdateFails(t *testing.T) {
+	assert := assert.New(t)
+
+	mcs := &contractregistrymocks.ContractStore{}
+	scgw := &smartContractGW{cs: mcs}
+
+	mcs.On("GetContractByAddress", testImplAddr).Return(&contractregistry.ContractInfo{ABI: "implabi"}, nil)
+	mcs.On("UpdateContract", mock.Anything).Return(fmt.Errorf("pop"))
+	info := &contractregistry.ContractInfo{
+		Address: testProxyAddr,
+		ABI:     "cloneabi",
+		Proxy:   &contractregistry.ProxyInfo{Standard: eth.ProxyStandardERC1167, Implementation: testImplAddr},
+	}
+	assert.Equal(info, scgw.resolveProxy(context.Background(), info))
+	mcs.AssertExpectations(t)
+}
+
+func TestResolveProxyFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	info := &contractregistry.ContractInfo{Address: testProxyAddr, ABI: "abi1"}
+
+	// No RPC connection
+	scgw := &smartContractGW{}
+	assert.Equal(info, scgw.resolveProxy(context.Background(), info))
+
+	// RPC failure
+	mrpc := &ethmocks.RPCClient{}
+	mrpc.On("CallContext", mock.Anything, mock.Anything, "eth_getStorageAt", mock.Anything, mock.Anything, mock.Anything).Return(fmt.Errorf("pop")).Once()
+	scgw = &smartContractGW{rpc: mrpc}
+	assert.Equal(info, scgw.resolveProxy(context.Background(), info))
+
+	// Unregistered implementation keeps the ABI, and a failed update returns the original
+	mcs := &contractregistrymocks.ContractStore{}
+	scgw = &smartContractGW{cs: mcs, rpc: mrpc}
+	mockImplementationSlot(mrpc, testImplAddr)
+	mcs.On("GetContractByAddress", testImplAddr).Return(nil, fmt.Errorf("not found"))
+	mcs.On("UpdateContract", mock.MatchedBy(func(updated *contractregistry.ContractInfo) bool {
+		return updated.ABI == "abi1" && updated.Proxy.Implementation == testImplAddr
+	})).Return(fmt.Errorf("pop"))
+	assert.Equal(info, scgw.resolveProxy(context.Background(), info))
+	mcs.AssertExpectations(t)
+}
+
+func TestDecodeReceiptEventsFollowsProxyUpgrade(t *testing.T) {
+	assert := assert.New(t)
+
+	mcs := &contractregistrymocks.ContractStore{}
+	scgw := &smartContractGW{cs: mcs}
+
+	abi := ethbinding.ABIMarshaling{
+		{
+			Type: "event",
+			Name: "Upgraded",
+			Inputs: []ethbinding.ABIArgumentMarshaling{
+				{Name: "implementation", Type: "address", Indexed: true},
+			},
+		},
+	}
+	proxyInfo := &contractregistry.ContractInfo{
+		Address: testProxyAddr,
+		ABI:     "abi1",
+		Proxy:   &contractregistry.ProxyInfo{Standard: "EIP-1967", Implementation: testImplAddr},
+	}
+	upgradedInfo := &contractregistry.ContractInfo{
+		Address: testProxyAddr,
+		ABI:     "abi2",
+		Proxy:   &contractregistry.ProxyInfo{Standard: "EIP-1967", Implementation: testImplAddr2},
+	}
+	mcs.On("GetContractByAddress", testProxyAddr).Return(proxyInfo, nil).Once()
+	mcs.On("GetContractByAddress", testImplAddr2).Return(&contractregistry.ContractInfo{ABI: "abi2"}, nil).Once()
+	mcs.On("UpdateContract", upgradedInfo).Return(nil).Once()
+	mcs.On("GetContractByAddress", testProxyAddr).Return(upgradedInfo, nil).Once()
+	mcs.On("GetABI", contractregistry.ABILocation{ABIType: contractregistry.LocalABI, Name: "abi2"}, false).Return(&contractregistry.DeployContractWithAddress{
+		Contract: &messages.DeployContract{ABI: abi},
+	}, nil).Once()
+
+	proxyAddr := ethbind.API.HexToAddress("0x" + testProxyAddr)
+	upgradedTopic := ethbind.API.HexToHash("0xbc7cd75a20ee27fd9adebab32041f755214dbc6bffa90cc0225b39da2e5c2d3b")
+	implTopic := ethbind.API.HexToHash("0x000000000000000000000000" + testImplAddr2)
+	decoded := scgw.DecodeReceiptEvents(&messages.TransactionReceipt{
+		Logs: []*messages.TransactionLog{
+			{Address: &proxyAddr, Topics: []*ethbinding.Hash{&upgradedTopic, &implTopic}, Data: "0x", LogIndexStr: "0"},
+		},
+	})
+	assert.Len(decoded, 1)
+	assert.Equal("Upgraded", decoded[0].Event)
+
+	mcs.AssertExpectations(t)
+}
+
+func TestResolveABIFollowsProxy(t *testing.T) {
+	assert := assert.New(t)
+
+	mcs := &contractregistrymocks.ContractStore{}
+	mrpc := &ethmocks.RPCClient{}
+	scgw := &smartContractGW{cs: mcs, rpc: mrpc}
+	r := newREST2eth(scgw, mcs, mrpc, nil, nil, nil, nil)
+
+	mockImplementationSlot(mrpc, testImplAddr2)
+	mcs.On("GetContractByAddress", testProxyAddr).Return(&contractregistry.ContractInfo{
+		Address: testProxyAddr,
+		ABI:     "abi1",
+		Proxy:   &contractregistry.ProxyInfo{Standard: "EIP-1967", Implementation: testImplAddr},
+	}, nil)
+	mcs.On("GetContractByAddress", testImplAddr2).Return(&contractregistry.ContractInfo{ABI: "abi2"}, nil)
+	mcs.On("UpdateContract", mock.Anything).Return(nil)
+	mcs.On("GetABI", contractregistry.ABILocation{ABIType: contractregistry.LocalABI, Name: "abi2"}, false).Return(&contractregistry.DeployContractWithAddress{
+		Contract: &messages.DeployContract{ABI: ethbinding.ABIMarshaling{}},
+	}, nil)
+
+	req := httptest.NewRequest("GET", "/contracts/"+testProxyAddr+"/get", nil)
+	res := httptest.NewRecorder()
+	var c restCmd
+	_, validAddress, err := r.resolveABI(res, req, httprouter.Params{}, &c, testProxyAddr)
+	assert.NoError(err)
+	assert.True(validAddress)
+	assert.Equal("abi2", c.abiLocation.Name)
+	assert.Equal(testProxyAddr, c.addr)
+
+	mcs.AssertExpectations(t)
+}
@@ -0,0 +1,397 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/hyperledger/firefly-ethconnect/internal/auth"
+	"github.com/hyperledger/firefly-ethconnect/internal/contractregistry"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/kafka"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	"github.com/hyperledger/firefly-ethconnect/internal/ws"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// RegistryNotifierTypeWebhook posts each registry event as JSON
+	RegistryNotifierTypeWebhook = "webhook"
+	// RegistryNotifierTypeKafka produces each registry event onto a topic, keyed by the ABI ID or contract address
+	RegistryNotifierTypeKafka = "kafka"
+	// RegistryNotifierTypeWebSocket broadcasts each registry event to the clients listening on a WebSocket topic
+	RegistryNotifierTypeWebSocket = "websocket"
+
+	// RegistryEventABIUploaded is emitted when an ABI is uploaded or imported
+	RegistryEventABIUploaded = "ABIUploaded"
+	// RegistryEventABIRemoved is emitted when an ABI is deleted
+	RegistryEventABIRemoved = "ABIRemoved"
+	// RegistryEventContractRegistered is emitted when a contract instance is registered, including after deployment
+	RegistryEventContractRegistered = "ContractRegistered"
+	// RegistryEventContractUnregistered is emitted when a contract instance registration is removed
+	RegistryEventContractUnregistered = "ContractUnregistered"
+	// RegistryEventContractReregistered is emitted when a contract instance is registered under a new name
+	RegistryEventContractReregistered = "ContractReregistered"
+
+	defaultRegistryNotifierQueueSize        = 100
+	defaultRegistryNotifierMaxAttempts      = 5
+	defaultRegistryNotifierRetryInitDelayMS = 500
+	defaultRegistryNotifierHTTPTimeoutMS    = 30000
+)
+
+// RegistryNotifierConf configures a webhook or Kafka topic that is notified of each change
+// to the local contract registry
+type RegistryNotifierConf struct {
+	Name                string                        `json:"name"`
+	Type                string                        `json:"type"`
+	QueueSize           int                           `json:"queueSize,omitempty"`
+	MaxAttempts         int                           `json:"maxAttempts,omitempty"`
+	RetryInitialDelayMS int                           `json:"retryInitialDelayMS,omitempty"`
+	Webhook             RegistryNotifierWebhookConf   `json:"webhook,omitempty"`
+	Kafka               RegistryNotifierKafkaConf     `json:"kafka,omitempty"`
+	WebSocket           RegistryNotifierWebSocketConf `json:"websocket,omitempty"`
+}
+
+// RegistryNotifierWebhookConf configures a webhook registry notifier
+type RegistryNotifierWebhookConf struct {
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	TimeoutMS int               `json:"timeoutMS,omitempty"`
+	TLS       utils.TLSConfig   `json:"tls,omitempty"`
+}
+
+// RegistryNotifierKafkaConf configures a Kafka registry notifier
+type RegistryNotifierKafkaConf struct {
+	kafka.KafkaCommonConf
+	Topic string `json:"topic"`
+}
+
+// RegistryNotifierWebSocketConf configures a WebSocket registry notifier
+type RegistryNotifierWebSocketConf struct {
+	Topic string `json:"topic"`
+}
+
+// RegistryEvent is the payload of a registry notification. Principal is the identity of the
+// caller that made the change, when one is known. PreviousRegisteredAs is the name a
+// re-registered contract was known by, so caches keyed by name can be invalidated.
+type RegistryEvent struct {
+	ID                   string                         `json:"id"`
+	Type                 string                         `json:"type"`
+	Timestamp            string                         `json:"timestamp"`
+	Principal            string                         `json:"principal,omitempty"`
+	ABI                  *contractregistry.ABIInfo      `json:"abi,omitempty"`
+	Contract             *contractregistry.ContractInfo `json:"contract,omitempty"`
+	PreviousRegisteredAs string                         `json:"previousRegisteredAs,omitempty"`
+}
+
+func (e *RegistryEvent) key() string {
+	if e.Contract != nil {
+		return e.Contract.Address
+	}
+	if e.ABI != nil {
+		return e.ABI.ID
+	}
+	return ""
+}
+
+type registryNotifierTarget interface {
+	notify(key string, payload []byte) error
+	close()
+}
+
+type queuedRegistryEvent struct {
+	key     string
+	payload []byte
+}
+
+// registryNotifierWorker delivers events to one target in order, from a queue that never
+// blocks the API call that made the change
+type registryNotifierWorker struct {
+	conf     *RegistryNotifierConf
+	target   registryNotifierTarget
+	queue    chan *queuedRegistryEvent
+	stopping chan struct{}
+	done     chan struct{}
+	dropped  int64
+}
+
+type registryNotifier struct {
+	mux     sync.RWMutex
+	workers []*registryNotifierWorker
+}
+
+var newRegistryKafkaProducer = sarama.NewSyncProducer
+
+func newRegistryNotifier(confs []RegistryNotifierConf, ws ws.WebSocketChannels) (*registryNotifier, error) {
+	rn := &registryNotifier{}
+	for i := range confs {
+		conf := &confs[i]
+		if conf.Name == "" {
+			conf.Name = fmt.Sprintf("%s%d", conf.Type, i)
+		}
+		target, err := newRegistryNotifierTarget(conf, ws)
+		if err != nil {
+			rn.close()
+			return nil, err
+		}
+		rn.workers = append(rn.workers, newRegistryNotifierWorker(conf, target))
+	}
+	return rn, nil
+}
+
+func newRegistryNotifierTarget(conf *RegistryNotifierConf, ws ws.WebSocketChannels) (registryNotifierTarget, error) {
+	switch conf.Type {
+	case RegistryNotifierTypeWebhook:
+		return newWebhookRegistryNotifier(conf)
+	case RegistryNotifierTypeKafka:
+		return newKafkaRegistryNotifier(conf)
+	case RegistryNotifierTypeWebSocket:
+		return newWebSocketRegistryNotifier(conf, ws)
+	default:
+		return nil, errors.Errorf(errors.RegistryNotifierUnknownType, conf.Type, conf.Name)
+	}
+}
+
+func newRegistryNotifierWorker(conf *RegistryNotifierConf, target registryNotifierTarget) *registryNotifierWorker {
+	if conf.QueueSize <= 0 {
+		conf.QueueSize = defaultRegistryNotifierQueueSize
+	}
+	if conf.MaxAttempts <= 0 {
+		conf.MaxAttempts = defaultRegistryNotifierMaxAttempts
+	}
+	if conf.RetryInitialDelayMS <= 0 {
+		conf.RetryInitialDelayMS = defaultRegistryNotifierRetryInitDelayMS
+	}
+	w := &registryNotifierWorker{
+		conf:     conf,
+		target:   target,
+		queue:    make(chan *queuedRegistryEvent, conf.QueueSize),
+		stopping: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// notify queues an event for every target, recording the caller behind the context as the principal
+func (rn *registryNotifier) notify(ctx context.Context, eventType string, abi *contractregistry.ABIInfo, contract *contractregistry.ContractInfo) {
+	rn.queue(ctx, &RegistryEvent{
+		Type:     eventType,
+		ABI:      abi,
+		Contract: contract,
+	})
+}
+
+// notifyReregistered queues an event for a contract that has changed its registered name
+func (rn *registryNotifier) notifyReregistered(ctx context.Context, contract *contractregistry.ContractInfo, previousRegisteredAs string) {
+	rn.queue(ctx, &RegistryEvent{
+		Type:                 RegistryEventContractReregistered,
+		Contract:             contract,
+		PreviousRegisteredAs: previousRegisteredAs,
+	})
+}
+
+func (rn *registryNotifier) queue(ctx context.Context, event *RegistryEvent) {
+	if rn == nil {
+		return
+	}
+	rn.mux.RLock()
+	defer rn.mux.RUnlock()
+	if len(rn.workers) == 0 {
+		return
+	}
+	event.ID = utils.UUIDv4()
+	event.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+	event.Principal = auth.GetPrincipal(ctx)
+	payload, _ := json.Marshal(event)
+	queued := &queuedRegistryEvent{key: event.key(), payload: payload}
+	for _, w := range rn.workers {
+		select {
+		case w.queue <- queued:
+		default:
+			dropped := atomic.AddInt64(&w.dropped, 1)
+			log.Warnf("Registry notifier '%s' queue full - dropped %s event (total dropped=%d)", w.conf.Name, event.Type, dropped)
+		}
+	}
+}
+
+// close delivers any queued events, then stops each target
+func (rn *registryNotifier) close() {
+	if rn == nil {
+		return
+	}
+	rn.mux.Lock()
+	defer rn.mux.Unlock()
+	for _, w := range rn.workers {
+		close(w.stopping)
+		close(w.queue)
+	}
+	for _, w := range rn.workers {
+		<-w.done
+		w.target.close()
+	}
+	rn.workers = nil
+}
+
+func (w *registryNotifierWorker) run() {
+	defer close(w.done)
+	for event := range w.queue {
+		w.deliver(event)
+	}
+}
+
+// deliver retries with backoff until the target accepts the event, or we run out of attempts.
+// Once shutdown starts we stop waiting between attempts.
+func (w *registryNotifierWorker) deliver(event *queuedRegistryEvent) {
+	delay := time.Duration(w.conf.RetryInitialDelayMS) * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := w.target.notify(event.key, event.payload)
+		if err == nil {
+			return
+		}
+		log.Errorf("Registry notifier '%s' attempt %d failed: %s", w.conf.Name, attempt, err)
+		if attempt >= w.conf.MaxAttempts {
+			dropped := atomic.AddInt64(&w.dropped, 1)
+			log.Errorf("Registry notifier '%s' dropped event for '%s' after %d attempts (total dropped=%d)", w.conf.Name, event.key, attempt, dropped)
+			return
+		}
+		select {
+		case <-time.After(delay):
+			delay = time.Duration(float64(delay) * 2)
+		case <-w.stopping:
+			attempt = w.conf.MaxAttempts - 1
+		}
+	}
+}
+
+type webhookRegistryNotifier struct {
+	conf   *RegistryNotifierConf
+	client *http.Client
+}
+
+func newWebhookRegistryNotifier(conf *RegistryNotifierConf) (*webhookRegistryNotifier, error) {
+	if conf.Webhook.URL == "" {
+		return nil, errors.Errorf(errors.RegistryNotifierMissingConfig, conf.Name, "webhook.url")
+	}
+	tlsConfig, err := utils.CreateTLSConfiguration(&conf.Webhook.TLS)
+	if err != nil {
+		return nil, err
+	}
+	timeoutMS := conf.Webhook.TimeoutMS
+	if timeoutMS <= 0 {
+		timeoutMS = defaultRegistryNotifierHTTPTimeoutMS
+	}
+	return &webhookRegistryNotifier{
+		conf: conf,
+		client: &http.Client{
+			Timeout:   time.Duration(timeoutMS) * time.Millisecond,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+func (n *webhookRegistryNotifier) notify(key string, payload []byte) error {
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, n.conf.Webhook.URL, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range n.conf.Webhook.Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = ioutil.ReadAll(res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.Errorf(errors.RegistryNotifierHTTPStatus, n.conf.Name, res.StatusCode, n.conf.Webhook.URL)
+	}
+	return nil
+}
+
+func (n *webhookRegistryNotifier) close() {
+	n.client.CloseIdleConnections()
+}
+
+type kafkaRegistryNotifier struct {
+	conf     *RegistryNotifierConf
+	producer sarama.SyncProducer
+}
+
+func newKafkaRegistryNotifier(conf *RegistryNotifierConf) (*kafkaRegistryNotifier, error) {
+	kconf := &conf.Kafka
+	if len(kconf.Brokers) == 0 || kconf.Brokers[0] == "" {
+		return nil, errors.Errorf(errors.RegistryNotifierMissingConfig, conf.Name, "kafka.brokers")
+	}
+	if kconf.Topic == "" {
+		return nil, errors.Errorf(errors.RegistryNotifierMissingConfig, conf.Name, "kafka.topic")
+	}
+	clientConf, err := kafka.NewSaramaConfig(kconf.ClientID, &kconf.TLS, kconf.SASL.Username, kconf.SASL.Password)
+	if err != nil {
+		return nil, err
+	}
+	clientConf.Producer.Return.Successes = true
+	clientConf.Producer.RequiredAcks = sarama.WaitForAll
+	producer, err := newRegistryKafkaProducer(kconf.Brokers, clientConf)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaRegistryNotifier{conf: conf, producer: producer}, nil
+}
+
+func (n *kafkaRegistryNotifier) notify(key string, payload []byte) error {
+	_, _, err := n.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: n.conf.Kafka.Topic,
+		Key:   sarama.StringEncoder(key),
+		Value: sarama.ByteEncoder(payload),
+	})
+	return err
+}
+
+func (n *kafkaRegistryNotifier) close() {
+	_ = n.producer.Close()
+}
+
+// webSocketRegistryNotifier broadcasts to the WebSocket clients listening on the topic when the
+// event is delivered. Clients that connect later do not receive earlier events.
+type webSocketRegistryNotifier struct {
+	broadcaster chan<- interface{}
+}
+
+func newWebSocketRegistryNotifier(conf *RegistryNotifierConf, ws ws.WebSocketChannels) (*webSocketRegistryNotifier, error) {
+	if conf.WebSocket.Topic == "" {
+		return nil, errors.Errorf(errors.RegistryNotifierMissingConfig, conf.Name, "websocket.topic")
+	}
+	if ws == nil {
+		return nil, errors.Errorf(errors.RegistryNotifierNoWebSocketServer, conf.Name)
+	}
+	_, broadcaster, _ := ws.GetChannels(conf.WebSocket.Topic)
+	return &webSocketRegistryNotifier{broadcaster: broadcaster}, nil
+}
+
+func (n *webSocketRegistryNotifier) notify(key string, payload []byte) error {
+	n.broadcaster <- json.RawMessage(payload)
+	return nil
+}
+
+func (n *webSocketRegistryNotifier) close() {}
@@ -0,0 +1,368 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/IBM/sarama"
+	saramamocks "github.com/IBM/sarama/mocks"
+	"github.com/hyperledger/firefly-ethconnect/internal/auth"
+	"github.com/hyperledger/firefly-ethconnect/internal/contractregistry"
+	"github.com/hyperledger/firefly-ethconnect/internal/kafka"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockRegistryNotifierTarget struct {
+	mux      sync.Mutex
+	failures int
+	keys     []string
+	events   []*RegistryEvent
+	closed   bool
+}
+
+func (m *mockRegistryNotifierTarget) notify(key string, payload []byte) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.failures > 0 {
+		m.failures--
+		return fmt.Errorf("pop")
+	}
+	var event RegistryEvent
+	_ = json.Unmarshal(payload, &event)
+	m.keys = append(m.keys, key)
+	m.events = append(m.events, &event)
+	return nil
+}
+
+func (m *mockRegistryNotifierTarget) close() {
+	m.closed = true
+}
+
+func newTestRegistryNotifier(confs []*RegistryNotifierConf, targets []registryNotifierTarget) *registryNotifier {
+	rn := &registryNotifier{}
+	for i, conf := range confs {
+		rn.workers = append(rn.workers, newRegistryNotifierWorker(conf, targets[i]))
+	}
+	return rn
+}
+
+func TestRegistryNotifierDeliversInOrderWithRetry(t *testing.T) {
+	assert := assert.New(t)
+
+	ok := &mockRegistryNotifierTarget{}
+	flaky := &mockRegistryNotifierTarget{failures: 1}
+	dead := &mockRegistryNotifierTarget{failures: 100}
+	rn := newTestRegistryNotifier([]*RegistryNotifierConf{
+		{Name: "ok"},
+		{Name: "flaky", RetryInitialDelayMS: 1},
+		{Name: "dead", MaxAttempts: 2, RetryInitialDelayMS: 1},
+	}, []registryNotifierTarget{ok, flaky, dead})
+
+	deadWorker := rn.workers[2]
+	ctx := auth.WithTLSPrincipal(context.Background(), "CN=admin")
+	rn.notify(ctx, RegistryEventABIUploaded, &contractregistry.ABIInfo{ID: "abi1"}, nil)
+	rn.notify(ctx, RegistryEventContractRegistered, nil, &contractregistry.ContractInfo{Address: "0123456789abcdef0123456789abcdef01234567", ABI: "abi1"})
+	rn.close()
+	rn.notify(ctx, RegistryEventContractUnregistered, nil, &contractregistry.ContractInfo{})
+
+	for _, m := range []*mockRegistryNotifierTarget{ok, flaky} {
+		assert.True(m.closed)
+		assert.Equal([]string{"abi1", "0123456789abcdef0123456789abcdef01234567"}, m.keys)
+		assert.Equal(RegistryEventABIUploaded, m.events[0].Type)
+		assert.Equal("abi1", m.events[0].ABI.ID)
+		assert.Equal("CN=admin", m.events[0].Principal)
+		assert.NotEmpty(m.events[0].ID)
+		assert.NotEmpty(m.events[0].Timestamp)
+		assert.Equal(RegistryEventContractRegistered, m.events[1].Type)
+		assert.Equal("abi1", m.events[1].Contract.ABI)
+	}
+	assert.Empty(dead.events)
+	assert.Equal(int64(2), deadWorker.dropped)
+}
+
+func TestRegistryNotifierQueueFull(t *testing.T) {
+	blocked := make(chan struct{})
+	target := &blockingRegistryNotifierTarget{blocked: blocked}
+	rn := newTestRegistryNotifier([]*RegistryNotifierConf{{Name: "slow", QueueSize: 1}}, []registryNotifierTarget{target})
+	w := rn.workers[0]
+
+	for i := 0; i < 5; i++ {
+		rn.notify(context.Background(), RegistryEventABIUploaded, &contractregistry.ABIInfo{ID: "abi1"}, nil)
+	}
+	assert.GreaterOrEqual(t, w.dropped, int64(3))
+	close(blocked)
+	rn.close()
+}
+
+type blockingRegistryNotifierTarget struct {
+	blocked chan struct{}
+}
+
+func (b *blockingRegistryNotifierTarget) notify(key string, payload []byte) error {
+	<-b.blocked
+	return nil
+}
+
+func (b *blockingRegistryNotifierTarget) close() {}
+
+func TestNewRegistryNotifierBadConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := newRegistryNotifier([]RegistryNotifierConf{{Type: "smoke"}}, nil)
+	assert.Regexp("FFEC100365.*smoke0", err)
+
+	_, err = newRegistryNotifier([]RegistryNotifierConf{{Type: RegistryNotifierTypeWebhook}}, nil)
+	assert.Regexp("FFEC100366.*webhook.url", err)
+
+	_, err = newRegistryNotifier([]RegistryNotifierConf{{Type: RegistryNotifierTypeWebhook, Webhook: RegistryNotifierWebhookConf{
+		URL: "http://localhost",
+		TLS: utils.TLSConfig{Enabled: true, CACertsFile: "/non/existent"},
+	}}}, nil)
+	assert.Error(err)
+
+	_, err = newRegistryNotifier([]RegistryNotifierConf{{Type: RegistryNotifierTypeKafka}}, nil)
+	assert.Regexp("FFEC100366.*kafka.brokers", err)
+
+	_, err = newRegistryNotifier([]RegistryNotifierConf{{Type: RegistryNotifierTypeKafka, Kafka: RegistryNotifierKafkaConf{
+		KafkaCommonConf: kafka.KafkaCommonConf{Brokers: []string{"localhost:9092"}},
+	}}}, nil)
+	assert.Regexp("FFEC100366.*kafka.topic", err)
+
+	_, err = newRegistryNotifier([]RegistryNotifierConf{{Type: RegistryNotifierTypeKafka, Kafka: RegistryNotifierKafkaConf{
+		KafkaCommonConf: kafka.KafkaCommonConf{
+			Brokers: []string{"localhost:9092"},
+			TLS:     utils.TLSConfig{Enabled: true, CACertsFile: "/non/existent"},
+		},
+		Topic: "registry",
+	}}}, nil)
+	assert.Error(err)
+
+	_, err = newRegistryNotifier([]RegistryNotifierConf{{Type: RegistryNotifierTypeWebSocket}}, nil)
+	assert.Regexp("FFEC100366.*websocket.topic", err)
+
+	_, err = newRegistryNotifier([]RegistryNotifierConf{{Type: RegistryNotifierTypeWebSocket, WebSocket: RegistryNotifierWebSocketConf{
+		Topic: "registry",
+	}}}, nil)
+	assert.Regexp("FFEC100384", err)
+
+	// Nothing configured is a no-op
+	rn, err := newRegistryNotifier(nil, nil)
+	assert.NoError(err)
+	rn.notify(context.Background(), RegistryEventABIUploaded, &contractregistry.ABIInfo{}, nil)
+	rn.close()
+}
+
+func TestWebhookRegistryNotifier(t *testing.T) {
+	assert := assert.New(t)
+
+	status := 204
+	var received []*RegistryEvent
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		assert.Equal("application/json", req.Header.Get("Content-Type"))
+		assert.Equal("secret", req.Header.Get("x-api-key"))
+		b, _ := ioutil.ReadAll(req.Body)
+		var event RegistryEvent
+		_ = json.Unmarshal(b, &event)
+		received = append(received, &event)
+		res.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	n, err := newWebhookRegistryNotifier(&RegistryNotifierConf{Name: "catalog", Webhook: RegistryNotifierWebhookConf{
+		URL:     server.URL,
+		Headers: map[string]string{"x-api-key": "secret"},
+	}})
+	assert.NoError(err)
+	defer n.close()
+
+	err = n.notify("abi1", []byte(`{"type":"ABIUploaded"}`))
+	assert.NoError(err)
+	assert.Equal(RegistryEventABIUploaded, received[0].Type)
+
+	status = 503
+	err = n.notify("abi1", []byte(`{}`))
+	assert.Regexp("FFEC100367.*catalog.*503", err)
+
+	server.Close()
+	err = n.notify("abi1", []byte(`{}`))
+	assert.Error(err)
+}
+
+func TestKafkaRegistryNotifier(t *testing.T) {
+	assert := assert.New(t)
+
+	mp := saramamocks.NewSyncProducer(t, nil)
+	var produced []*sarama.ProducerMessage
+	mp.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		produced = append(produced, msg)
+		return nil
+	})
+	mp.ExpectSendMessageAndFail(fmt.Errorf("pop"))
+	defer func() { newRegistryKafkaProducer = sarama.NewSyncProducer }()
+	var saramaConf *sarama.Config
+	newRegistryKafkaProducer = func(addrs []string, config *sarama.Config) (sarama.SyncProducer, error) {
+		saramaConf = config
+		return mp, nil
+	}
+
+	conf := &RegistryNotifierConf{Name: "kafka", Kafka: RegistryNotifierKafkaConf{
+		KafkaCommonConf: kafka.KafkaCommonConf{Brokers: []string{"localhost:9092"}},
+		Topic:           "registry",
+	}}
+	conf.Kafka.SASL.Username = "user"
+	conf.Kafka.SASL.Password = "pass"
+	n, err := newKafkaRegistryNotifier(conf)
+	assert.NoError(err)
+	assert.True(saramaConf.Net.SASL.Enable)
+	assert.NotEmpty(saramaConf.ClientID)
+
+	err = n.notify("abi1", []byte(`{}`))
+	assert.NoError(err)
+	assert.Equal("registry", produced[0].Topic)
+	key, _ := produced[0].Key.Encode()
+	assert.Equal("abi1", string(key))
+
+	err = n.notify("abi2", []byte(`{}`))
+	assert.Regexp("pop", err)
+	n.close()
+}
+
+func TestKafkaRegistryNotifierFail(t *testing.T) {
+	defer func() { newRegistryKafkaProducer = sarama.NewSyncProducer }()
+	newRegistryKafkaProducer = func(addrs []string, config *sarama.Config) (sarama.SyncProducer, error) {
+		return nil, fmt.Errorf("pop")
+	}
+	_, err := newKafkaRegistryNotifier(&RegistryNotifierConf{Kafka: RegistryNotifierKafkaConf{
+		KafkaCommonConf: kafka.KafkaCommonConf{Brokers: []string{"localhost:9092"}},
+		Topic:           "registry",
+	}})
+	assert.Regexp(t, "pop", err)
+}
+
+func TestDeleteContractNotifies(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	scgw, mcs, local, router := newTestDeleteGW(t, dir)
+	target := &mockRegistryNotifierTarget{}
+	scgw.notifier = newTestRegistryNotifier([]*RegistryNotifierConf{{Name: "test"}}, []registryNotifierTarget{target})
+
+	local.On("GetContractByAddress", "token").Return(nil, fmt.Errorf("pop")).Once()
+	local.On("ResolveContractAddress", "token").Return("0123456789abcdef0123456789abcdef01234567", nil).Once()
+	mcs.On("DeleteContract", "0123456789abcdef0123456789abcdef01234567").Return(nil).Once()
+	req := httptest.NewRequest("DELETE", "/contracts/token", nil)
+	req = req.WithContext(auth.WithTLSPrincipal(req.Context(), "CN=admin"))
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(204, res.Result().StatusCode)
+
+	scgw.notifier.close()
+	assert.Len(target.events, 1)
+	assert.Equal(RegistryEventContractUnregistered, target.events[0].Type)
+	assert.Equal("CN=admin", target.events[0].Principal)
+	assert.Equal("0123456789abcdef0123456789abcdef01234567", target.events[0].Contract.Address)
+	assert.Equal("token", target.events[0].Contract.RegisteredAs)
+}
+
+type mockRegistryWebSocket struct {
+	topic       string
+	broadcaster chan interface{}
+}
+
+func (m *mockRegistryWebSocket) GetChannels(topic string) (chan<- interface{}, chan<- interface{}, <-chan error) {
+	m.topic = topic
+	return nil, m.broadcaster, nil
+}
+
+func (m *mockRegistryWebSocket) SendReply(message interface{}) {}
+
+func TestWebSocketRegistryNotifier(t *testing.T) {
+	assert := assert.New(t)
+
+	ws := &mockRegistryWebSocket{broadcaster: make(chan interface{}, 1)}
+	rn, err := newRegistryNotifier([]RegistryNotifierConf{{Type: RegistryNotifierTypeWebSocket, WebSocket: RegistryNotifierWebSocketConf{
+		Topic: "registry",
+	}}}, ws)
+	assert.NoError(err)
+	assert.Equal("registry", ws.topic)
+
+	rn.notifyReregistered(context.Background(), &contractregistry.ContractInfo{Address: "0123456789abcdef0123456789abcdef01234567", RegisteredAs: "token2"}, "token")
+	rn.close()
+
+	var event RegistryEvent
+	err = json.Unmarshal((<-ws.broadcaster).(json.RawMessage), &event)
+	assert.NoError(err)
+	assert.Equal(RegistryEventContractReregistered, event.Type)
+	assert.Equal("token2", event.Contract.RegisteredAs)
+	assert.Equal("token", event.PreviousRegisteredAs)
+}
+
+func TestRenameContractNotifies(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	scgw, mcs, local, router := newTestDeleteGW(t, dir)
+	target := &mockRegistryNotifierTarget{}
+	scgw.notifier = newTestRegistryNotifier([]*RegistryNotifierConf{{Name: "test"}}, []registryNotifierTarget{target})
+
+	local.On("GetContractByAddress", "token").Return(nil, fmt.Errorf("pop")).Once()
+	local.On("ResolveContractAddress", "token").Return("0123456789abcdef0123456789abcdef01234567", nil).Once()
+	mcs.On("RenameContract", "0123456789abcdef0123456789abcdef01234567", "token2").Return(&contractregistry.ContractInfo{
+		Address:      "0123456789abcdef0123456789abcdef01234567",
+		RegisteredAs: "token2",
+	}, nil).Once()
+	req := httptest.NewRequest("PATCH", "/contracts/token", bytes.NewReader([]byte(`{"registeredAs":"token2"}`)))
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Result().StatusCode)
+
+	scgw.notifier.close()
+	assert.Len(target.events, 1)
+	assert.Equal(RegistryEventContractReregistered, target.events[0].Type)
+	assert.Equal("token2", target.events[0].Contract.RegisteredAs)
+	assert.Equal("token", target.events[0].PreviousRegisteredAs)
+}
+
+func TestDeleteABINotifies(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	scgw, mcs, _, router := newTestDeleteGW(t, dir)
+	target := &mockRegistryNotifierTarget{}
+	scgw.notifier = newTestRegistryNotifier([]*RegistryNotifierConf{{Name: "test"}}, []registryNotifierTarget{target})
+
+	mcs.On("GetLocalABIInfo", "abi1").Return(&contractregistry.ABIInfo{ID: "abi1", Name: "Token"}, nil)
+	mcs.On("DeleteABI", "abi1").Return(nil).Once()
+	req := httptest.NewRequest("DELETE", "/abis/abi1", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(204, res.Result().StatusCode)
+
+	scgw.notifier.close()
+	assert.Len(target.events, 1)
+	assert.Equal(RegistryEventABIRemoved, target.events[0].Type)
+	assert.Equal([]string{"abi1"}, target.keys)
+	assert.Equal("Token", target.events[0].ABI.Name)
+}
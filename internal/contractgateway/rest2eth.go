@@ -15,12 +15,15 @@
 package contractgateway
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -33,6 +36,7 @@ import (
 	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
 	"github.com/hyperledger/firefly-ethconnect/internal/events"
 	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/openapi"
 	"github.com/hyperledger/firefly-ethconnect/internal/tx"
 	"github.com/hyperledger/firefly-ethconnect/internal/utils"
 	"github.com/julienschmidt/httprouter"
@@ -61,6 +65,21 @@ type rest2EthReplyProcessor interface {
 	ReplyWithReceiptAndError(receipt messages.ReplyWithHeaders, err error)
 }
 
+const (
+	// UnknownFieldsLenient logs and ignores fields in an invocation body that are not inputs of the method
+	UnknownFieldsLenient = "lenient"
+	// UnknownFieldsStrict rejects invocation bodies containing fields that are not inputs of the method
+	UnknownFieldsStrict = "strict"
+)
+
+const (
+	// DefaultStreamCallResults is the size in bytes of the return data of a call, above which the
+	// result is streamed to the caller as it is decoded, unless configured
+	DefaultStreamCallResults = 1024 * 1024
+	// streamCallBufferSize is how much of a streamed result is written to the caller at a time
+	streamCallBufferSize = 64 * 1024
+)
+
 // rest2eth provides the HTTP <-> messages translation and dispatches for processing
 type rest2eth struct {
 	gw              SmartContractGateway
@@ -70,6 +89,9 @@ type rest2eth struct {
 	asyncDispatcher REST2EthAsyncDispatcher
 	syncDispatcher  rest2EthSyncDispatcher
 	subMgr          events.SubscriptionManager
+	unknownFields   string
+	streamThreshold int64
+	tokenDecimals   map[string]uint8
 }
 
 type restAsyncMsg struct {
@@ -145,14 +167,67 @@ func newREST2eth(gw SmartContractGateway, cr contractregistry.ContractResolver,
 		asyncDispatcher: asyncDispatcher,
 		rpc:             rpc,
 		subMgr:          subMgr,
+		unknownFields:   UnknownFieldsLenient,
+		streamThreshold: DefaultStreamCallResults,
+	}
+}
+
+// setUnknownFields sets how fields in an invocation body that do not match a method input are handled
+func (r *rest2eth) setUnknownFields(mode string) {
+	switch mode {
+	case UnknownFieldsLenient, UnknownFieldsStrict:
+		r.unknownFields = mode
+	case "":
+		r.unknownFields = UnknownFieldsLenient
+	default:
+		log.Warnf("Unknown unknownFields mode '%s' - using '%s'", mode, UnknownFieldsLenient)
+		r.unknownFields = UnknownFieldsLenient
+	}
+}
+
+// setTokenDecimals sets the decimals of token contracts, by address, for amounts in the token unit
+func (r *rest2eth) setTokenDecimals(tokenDecimals map[string]uint8) {
+	r.tokenDecimals = make(map[string]uint8, len(tokenDecimals))
+	for addr, decimals := range tokenDecimals {
+		r.tokenDecimals["0x"+strings.TrimPrefix(strings.ToLower(addr), "0x")] = decimals
 	}
 }
 
+func (r *rest2eth) tokenDecimalsFor(addr string) *uint8 {
+	if decimals, ok := r.tokenDecimals[strings.ToLower(addr)]; ok && addr != "" {
+		return &decimals
+	}
+	return nil
+}
+
+// flyAmountParam gets a 'fly' param that is an amount of wei, converting any unit such as gwei or ether
+func flyAmountParam(name string, req *http.Request) (json.Number, error) {
+	v := getFlyParam(name, req)
+	if _, unit := eth.SplitAmountUnit(v); unit != "" {
+		amount, err := eth.ParseAmount(v, nil)
+		if err != nil {
+			return "", err
+		}
+		return json.Number(amount.String()), nil
+	}
+	return json.Number(v), nil
+}
+
+// setStreamCallResults sets the size of return data above which call results are streamed.
+// Zero uses the default, and a negative value means results are never streamed.
+func (r *rest2eth) setStreamCallResults(threshold int64) {
+	if threshold == 0 {
+		threshold = DefaultStreamCallResults
+	}
+	r.streamThreshold = threshold
+}
+
 func (r *rest2eth) addRoutes(router *httprouter.Router) {
 	// Built-in registry managed routes
 	router.POST("/contracts/:address/:method", r.restHandler)
 	router.GET("/contracts/:address/:method", r.restHandler)
 	router.POST("/contracts/:address/:method/:subcommand", r.restHandler)
+	router.GET("/contracts/:address/:method/:subcommand", r.eventSchemaHandler)
 
 	router.POST("/abis/:abi", r.restHandler)
 	router.POST("/abis/:abi/:address/:method", r.restHandler)
@@ -233,8 +308,17 @@ func (r *rest2eth) resolveABI(res http.ResponseWriter, req *http.Request, params
 			addrParam = c.addr
 			var info *contractregistry.ContractInfo
 			if info, err = r.cr.GetContractByAddress(addrParam); err != nil {
-				r.restErrReply(res, req, err, 404)
-				return
+				if ar, ok := r.gw.(contractAutoRegistrar); ok {
+					info = ar.autoRegisterContract(req.Context(), addrParam)
+				}
+				if info == nil {
+					r.restErrReply(res, req, err, 404)
+					return
+				}
+			}
+			if pr, ok := r.gw.(proxyResolver); ok && info.Proxy != nil {
+				// Follow any upgrade of the proxy made outside of ethconnect
+				info = pr.resolveProxy(req.Context(), info)
 			}
 			location.Name = info.ABI
 		}
@@ -251,6 +335,10 @@ func (r *rest2eth) resolveABI(res http.ResponseWriter, req *http.Request, params
 	}
 	c.deployMsg = deployMsg.Contract
 	c.deployMsg.Headers.ABIID = deployMsg.Contract.Headers.ID // Reference to the original ABI needs to flow through for registration
+	if location.ABIType == contractregistry.LocalABI && params.ByName("abi") != "" && c.deployMsg.Headers.ABIID != "" {
+		// The ABI might be referred to by name, but subscriptions stay on the version they were created with
+		location.Name = c.deployMsg.Headers.ABIID
+	}
 	c.abiLocation = &location
 	if deployMsg.Address != "" {
 		c.addr = deployMsg.Address
@@ -259,16 +347,30 @@ func (r *rest2eth) resolveABI(res http.ResponseWriter, req *http.Request, params
 	return
 }
 
+// resolveMethod finds a method by name, or by its full signature - such as 'transfer(address,uint256)'.
+// A name alone resolves to the first declared overload, so the signature is needed to call the others.
 func (r *rest2eth) resolveMethod(res http.ResponseWriter, req *http.Request, c *restCmd, a ethbinding.ABIMarshaling, methodParam string) (err error) {
+	bySig := strings.Contains(methodParam, "(")
+	methodName := methodParam
+	if bySig {
+		methodParam = strings.Join(strings.Fields(methodParam), "")
+		methodName = methodParam[0:strings.Index(methodParam, "(")]
+	}
 	for _, element := range a {
-		if element.Type == "function" && element.Name == methodParam {
-			c.abiMethodElem = &element
-			if c.abiMethod, err = ethbind.API.ABIElementMarshalingToABIMethod(&element); err != nil {
+		if element.Type == "function" && element.Name == methodName {
+			element := element
+			method, err := ethbind.API.ABIElementMarshalingToABIMethod(&element)
+			if err != nil {
 				err = ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayMethodABIInvalid, methodParam, err)
 				r.restErrReply(res, req, err, 400)
-				return
+				return err
 			}
-			return
+			if bySig && method.Sig != methodParam {
+				continue
+			}
+			c.abiMethodElem = &element
+			c.abiMethod = method
+			return nil
 		}
 	}
 	return
@@ -401,7 +503,10 @@ func (r *rest2eth) resolveParams(res http.ResponseWriter, req *http.Request, par
 			return
 		}
 	}
-	c.value = json.Number(getFlyParam("ethvalue", req))
+	if c.value, err = flyAmountParam("ethvalue", req); err != nil {
+		r.restErrReply(res, req, err, 400)
+		return
+	}
 
 	c.body, err = utils.YAMLorJSONPayload(req)
 	if err != nil {
@@ -418,6 +523,7 @@ func (r *rest2eth) resolveParams(res http.ResponseWriter, req *http.Request, par
 
 	c.msgParams = make([]interface{}, len(c.abiMethod.Inputs))
 	queryParams := req.Form
+	argNames := make(map[string]bool, len(c.abiMethod.Inputs))
 	for i, abiParam := range c.abiMethod.Inputs {
 		argName := abiParam.Name
 		// If the ABI input has one or more un-named parameters, look for default names that are passed in.
@@ -428,6 +534,7 @@ func (r *rest2eth) resolveParams(res http.ResponseWriter, req *http.Request, par
 				argName += strconv.Itoa(i)
 			}
 		}
+		argNames[argName] = true
 		if bv, exists := c.body[argName]; exists {
 			c.msgParams[i] = bv
 		} else if vs := queryParams[argName]; len(vs) > 0 {
@@ -437,14 +544,63 @@ func (r *rest2eth) resolveParams(res http.ResponseWriter, req *http.Request, par
 			r.restErrReply(res, req, err, 400)
 			return
 		}
+		// Amounts with units are converted here, as only the REST API knows the contract for the token unit
+		if c.msgParams[i], err = eth.ConvertAmountUnits(&abiParam.Type, c.msgParams[i], r.tokenDecimalsFor(c.addr)); err != nil {
+			r.restErrReply(res, req, err, 400)
+			return
+		}
 	}
+	err = r.checkUnknownFields(res, req, c.abiMethod.Name, c.body, argNames)
 
 	return
 }
 
+// checkUnknownFields looks for body fields that are not inputs of the method, which are
+// often typos that would otherwise be silently ignored
+func (r *rest2eth) checkUnknownFields(res http.ResponseWriter, req *http.Request, methodName string, body map[string]interface{}, argNames map[string]bool) error {
+	var unknown []string
+	for k := range body {
+		if !argNames[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	if r.unknownFields == UnknownFieldsStrict {
+		err := ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayUnknownFields, methodName, strings.Join(unknown, ","))
+		r.restErrReply(res, req, err, 400)
+		return err
+	}
+	log.Warnf("Ignoring unknown fields in body for method '%s': %s", methodName, strings.Join(unknown, ","))
+	return nil
+}
+
 func (r *rest2eth) restHandler(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	// The router cannot hold a static segment alongside the ABI wildcard of the deploy route
+	if ai, ok := r.gw.(abiImporter); ok && params.ByName("abi") == "import" && params.ByName("address") == "" {
+		ai.importABI(res, req, params)
+		return
+	}
+	if bu, ok := r.gw.(bulkABIUploader); ok && params.ByName("abi") == "bulk" && params.ByName("address") == "" {
+		bu.addABIsBulk(res, req, params)
+		return
+	}
+	if av, ok := r.gw.(abiValidator); ok && params.ByName("abi") == abiValidatePathSegment && params.ByName("address") == "" {
+		av.validateABI(res, req, params)
+		return
+	}
+	if ad, ok := r.gw.(abiDiffer); ok && req.Method == http.MethodGet && params.ByName("abi") != "" && params.ByName("address") == abiDiffPathSegment {
+		ad.diffABIs(res, req, params)
+		return
+	}
 	log.Infof("--> %s %s", req.Method, req.URL)
 
+	if req.Method == http.MethodGet && params.ByName("method") == routesPathSegment && r.listRoutes(res, req, params) {
+		return
+	}
+
 	c, err := r.resolveParams(res, req, params)
 	if err != nil {
 		return
@@ -454,6 +610,12 @@ func (r *rest2eth) restHandler(res http.ResponseWriter, req *http.Request, param
 		r.subscribeEvent(res, req, c.addr, c.abiLocation, c.abiEventElem, c.body)
 	} else if c.transactionHash != "" {
 		r.lookupTransaction(res, req, c.transactionHash, c.abiMethod)
+	} else if req.Method == http.MethodPost && getFlyParamBool("simulate", req) {
+		if c.isDeploy {
+			r.restErrReply(res, req, ethconnecterrors.Errorf(ethconnecterrors.RESTGatewaySimulateDeployUnsupported), 400)
+		} else {
+			r.simulateTransaction(res, req, c.from, c.addr, c.value, c.abiMethod, c.msgParams, c.blocknumber)
+		}
 	} else if req.Method != http.MethodPost || c.abiMethod.IsConstant() || getFlyParamBool("call", req) {
 		r.callContract(res, req, c.from, c.addr, c.value, c.abiMethod, c.msgParams, c.blocknumber)
 	} else {
@@ -468,6 +630,43 @@ func (r *rest2eth) restHandler(res http.ResponseWriter, req *http.Request, param
 	}
 }
 
+// eventSchemaHandler serves GET /contracts/:address/events/:event?schema=json with a JSON Schema
+// describing the payload event streams deliver for the event
+func (r *rest2eth) eventSchemaHandler(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	if params.ByName("method") != "events" {
+		r.restErrReply(res, req, ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayMethodNotDeclared, url.QueryEscape(params.ByName("method")), params.ByName("address")), 404)
+		return
+	}
+
+	var c restCmd
+	a, _, err := r.resolveABI(res, req, params, &c, params.ByName("address"))
+	if err != nil {
+		return
+	}
+	eventName := params.ByName("subcommand")
+	if err = r.resolveEvent(res, req, &c, a, eventName, "", ""); err != nil {
+		return
+	}
+	if c.abiEvent == nil {
+		r.restErrReply(res, req, ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayEventNotDeclared, eventName), 404)
+		return
+	}
+	if format := req.URL.Query().Get("schema"); format != "json" {
+		r.restErrReply(res, req, ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayEventSchemaFormat, format), 400)
+		return
+	}
+
+	status := 200
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.Header().Set("Content-Type", "application/schema+json")
+	res.WriteHeader(status)
+	enc := json.NewEncoder(res)
+	enc.SetIndent("", "  ")
+	enc.Encode(openapi.EventJSONSchema(c.abiEvent))
+}
+
 func (r *rest2eth) fromBodyOrForm(req *http.Request, body map[string]interface{}, param string) string {
 	val := body[param]
 	valType := reflect.TypeOf(val)
@@ -496,6 +695,17 @@ func (r *rest2eth) subscribeEvent(res http.ResponseWriter, req *http.Request, ad
 		return
 	}
 	fromBlock := r.fromBodyOrForm(req, body, "fromBlock")
+	if fromTime := r.fromBodyOrForm(req, body, "fromTime"); fromTime != "" {
+		if fromBlock != "" {
+			r.restErrReply(res, req, ethconnecterrors.Errorf(ethconnecterrors.EventStreamsSubscribeBlockAndTime), 400)
+			return
+		}
+		var err error
+		if fromBlock, err = r.subMgr.ResolveFromTime(req.Context(), fromTime); err != nil {
+			r.restErrReply(res, req, err, 400)
+			return
+		}
+	}
 	var addr *ethbinding.Address
 	if addrStr != "" {
 		address := ethbind.API.HexToAddress(addrStr)
@@ -545,6 +755,16 @@ func (r *rest2eth) assignMessageID(headers *messages.RequestHeaders, req *http.R
 	if headers.ID == "" {
 		headers.ID = utils.UUIDv4()
 	}
+	// Sync requests are dispatched without passing through the webhooks, which stamp the
+	// namespace of the caller on async requests, so it is set here for both
+	headers.Namespace = auth.GetNamespace(req.Context())
+}
+
+// assignReplyMode sets how the reply to an async request is delivered, which is validated
+// when the request is dispatched
+func (r *rest2eth) assignReplyMode(headers *messages.RequestHeaders, req *http.Request) {
+	headers.ReplyMode = strings.ToLower(getFlyParam("replymode", req))
+	headers.ReplyWebhook = getFlyParam("replywebhook", req)
 }
 
 func (r *rest2eth) deployContract(res http.ResponseWriter, req *http.Request, from string, value json.Number, abiMethodElem *ethbinding.ABIElementMarshaling, deployMsg *messages.DeployContract, msgParams []interface{}) {
@@ -553,16 +773,24 @@ func (r *rest2eth) deployContract(res http.ResponseWriter, req *http.Request, fr
 	deployMsg.Headers.MsgType = messages.MsgTypeDeployContract
 	deployMsg.From = from
 	deployMsg.Gas = json.Number(getFlyParam("gas", req))
-	deployMsg.GasPrice = json.Number(getFlyParam("gasprice", req))
 	deployMsg.Value = value
 	deployMsg.Parameters = msgParams
+	var err error
+	if deployMsg.GasPrice, err = flyAmountParam("gasprice", req); err != nil {
+		r.restErrReply(res, req, err, 400)
+		return
+	}
 	if err := r.addPrivateTx(&deployMsg.TransactionCommon, req, res); err != nil {
 		r.restErrReply(res, req, err, 400)
 		return
 	}
+	if err := r.resolveLibraries(deployMsg, req); err != nil {
+		r.restErrReply(res, req, err, 400)
+		return
+	}
 	deployMsg.RegisterAs = getFlyParam("register", req)
 	if deployMsg.RegisterAs != "" {
-		if err := r.cr.CheckNameAvailable(deployMsg.RegisterAs, contractregistry.IsRemote(deployMsg.Headers.CommonHeaders)); err != nil {
+		if err := r.cr.CheckNameAvailable(deployMsg.RegisterAs); err != nil {
 			r.restErrReply(res, req, err, 409)
 			return
 		}
@@ -583,6 +811,7 @@ func (r *rest2eth) deployContract(res http.ResponseWriter, req *http.Request, fr
 	} else {
 		ack := !getFlyParamBool("noack", req) // turn on ack's by default
 		deployMsg.AckType = strings.ToLower(getFlyParam("acktype", req))
+		r.assignReplyMode(&deployMsg.Headers, req)
 		immediateReceipt := deployMsg.AckType == "receipt"
 
 		// Async messages are dispatched as generic map payloads.
@@ -599,6 +828,45 @@ func (r *rest2eth) deployContract(res http.ResponseWriter, req *http.Request, fr
 	return
 }
 
+// resolveLibraries works out the address of each library the bytecode must be linked to before
+// it is deployed. A library is supplied as name=address, or name=registeredName for an instance
+// in the contract registry. Otherwise an instance registered under the name of the library is used.
+func (r *rest2eth) resolveLibraries(deployMsg *messages.DeployContract, req *http.Request) error {
+	if len(deployMsg.LinkReferences) == 0 {
+		return nil
+	}
+	supplied := make(map[string]string)
+	for _, library := range getFlyParamMulti("library", req) {
+		if library = strings.TrimSpace(library); library == "" {
+			continue
+		}
+		nameAndValue := strings.SplitN(library, "=", 2)
+		if len(nameAndValue) != 2 || nameAndValue[0] == "" || nameAndValue[1] == "" {
+			return errors.Errorf(errors.RESTGatewayInvalidLibrary, library)
+		}
+		supplied[nameAndValue[0]] = nameAndValue[1]
+	}
+	deployMsg.Libraries = make(map[string]string, len(deployMsg.LinkReferences))
+	for _, ref := range deployMsg.LinkReferences {
+		value, ok := eth.LookupLibrary(supplied, ref.Library)
+		if !ok {
+			value = eth.LibraryShortName(ref.Library)
+		}
+		if !hexAddressRegexp.MatchString(strings.TrimPrefix(strings.ToLower(value), "0x")) {
+			addr, err := r.cr.ResolveContractAddress(value)
+			if err != nil {
+				if !ok {
+					return errors.Errorf(errors.DeployTransactionMissingLibrary, ref.Library)
+				}
+				return err
+			}
+			value = addr
+		}
+		deployMsg.Libraries[ref.Library] = value
+	}
+	return nil
+}
+
 func (r *rest2eth) sendTransaction(res http.ResponseWriter, req *http.Request, from, addr string, value json.Number, abiMethodElem *ethbinding.ABIElementMarshaling, msgParams []interface{}) {
 
 	msg := &messages.SendTransaction{}
@@ -608,9 +876,13 @@ func (r *rest2eth) sendTransaction(res http.ResponseWriter, req *http.Request, f
 	msg.To = addr
 	msg.From = from
 	msg.Gas = json.Number(getFlyParam("gas", req))
-	msg.GasPrice = json.Number(getFlyParam("gasprice", req))
 	msg.Value = value
 	msg.Parameters = msgParams
+	var err error
+	if msg.GasPrice, err = flyAmountParam("gasprice", req); err != nil {
+		r.restErrReply(res, req, err, 400)
+		return
+	}
 	if err := r.addPrivateTx(&msg.TransactionCommon, req, res); err != nil {
 		r.restErrReply(res, req, err, 400)
 		return
@@ -632,6 +904,7 @@ func (r *rest2eth) sendTransaction(res http.ResponseWriter, req *http.Request, f
 	} else {
 		ack := !getFlyParamBool("noack", req) // turn on ack's by default
 		msg.AckType = strings.ToLower(getFlyParam("acktype", req))
+		r.assignReplyMode(&msg.Headers, req)
 		immediateReceipt := msg.AckType == "receipt"
 
 		// Async messages are dispatched as generic map payloads.
@@ -654,12 +927,35 @@ func (r *rest2eth) callContract(res http.ResponseWriter, req *http.Request, from
 		r.restErrReply(res, req, err, 500)
 		return
 	}
+	units := strings.ToLower(getFlyParam("units", req))
+	var unitDecimals int
+	if units != "" {
+		if unitDecimals, err = eth.UnitDecimals(units, r.tokenDecimalsFor(addr)); err != nil {
+			r.restErrReply(res, req, err, 400)
+			return
+		}
+	}
 
-	resBody, err := eth.CallMethod(req.Context(), r.rpc, nil, from, addr, value, abiMethod, msgParams, blocknumber)
+	retBytes, err := eth.CallMethodReturnData(req.Context(), r.rpc, nil, from, addr, value, abiMethod, msgParams, blocknumber)
 	if err != nil {
 		r.restErrReply(res, req, err, 500)
 		return
 	}
+	if r.streamThreshold >= 0 && int64(len(retBytes)) > r.streamThreshold && units == "" {
+		// Check the whole result decodes before committing to a 200 status, as a failure part
+		// way through the stream can only be reported by cutting the response short
+		if err := eth.StreamOutputs(ioutil.Discard, abiMethod.Outputs, retBytes); err == nil {
+			r.streamCallResult(res, req, abiMethod, retBytes)
+			return
+		}
+	}
+	var resBody map[string]interface{}
+	if retBytes != nil {
+		resBody = eth.ProcessRLPBytes(abiMethod.Outputs, retBytes)
+		if units != "" {
+			eth.FormatOutputUnits(abiMethod.Outputs, resBody, unitDecimals)
+		}
+	}
 	resBytes, _ := json.MarshalIndent(&resBody, "", "  ")
 	status := 200
 	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
@@ -670,6 +966,46 @@ func (r *rest2eth) callContract(res http.ResponseWriter, req *http.Request, from
 	return
 }
 
+// streamCallResult writes a large call result as it is decoded, with chunked transfer encoding,
+// rather than building the whole response in memory first
+func (r *rest2eth) streamCallResult(res http.ResponseWriter, req *http.Request, abiMethod *ethbinding.ABIMethod, retBytes []byte) {
+	status := 200
+	log.Infof("<-- %s %s [%d] (streaming %d bytes of return data)", req.Method, req.URL, status, len(retBytes))
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	w := bufio.NewWriterSize(res, streamCallBufferSize)
+	err := eth.StreamOutputs(w, abiMethod.Outputs, retBytes)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		log.Errorf("Failed to stream result of %s %s: %s", req.Method, req.URL, err)
+	}
+}
+
+// simulateTransaction calls the method without submitting a transaction, and where the node
+// supports it includes the balances and storage slots the transaction would change
+func (r *rest2eth) simulateTransaction(res http.ResponseWriter, req *http.Request, from, addr string, value json.Number, abiMethod *ethbinding.ABIMethod, msgParams []interface{}, blocknumber string) {
+	var err error
+	if from, err = r.processor.ResolveAddress(from); err != nil {
+		r.restErrReply(res, req, err, 500)
+		return
+	}
+
+	result, err := eth.SimulateMethod(req.Context(), r.rpc, nil, from, addr, value, abiMethod, msgParams, blocknumber)
+	if err != nil {
+		r.restErrReply(res, req, err, 500)
+		return
+	}
+	resBytes, _ := json.MarshalIndent(result, "", "  ")
+	status := 200
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	log.Debugf("<-- %s", resBytes)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	res.Write(resBytes)
+}
+
 func (r *rest2eth) lookupTransaction(res http.ResponseWriter, req *http.Request, txHash string, abiMethod *ethbinding.ABIMethod) {
 	info, err := eth.GetTransactionInfo(req.Context(), r.rpc, txHash)
 	if err != nil {
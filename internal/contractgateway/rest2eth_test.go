@@ -19,9 +19,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 
@@ -90,8 +92,13 @@ func (m *mockGateway) PreDeploy(msg *messages.DeployContract) error { return nil
 func (m *mockGateway) PostDeploy(msg *messages.TransactionReceipt) error {
 	return m.postDeployError
 }
-func (m *mockGateway) AddRoutes(router *httprouter.Router) { return }
-func (m *mockGateway) Shutdown()                           { return }
+func (m *mockGateway) DecodeReceiptEvents(msg *messages.TransactionReceipt) []*messages.ReceiptEvent {
+	return nil
+}
+func (m *mockGateway) AddRoutes(router *httprouter.Router)                       { return }
+func (m *mockGateway) SetEventDeliveryListener(listener events.DeliveryListener) {}
+func (m *mockGateway) ContractResolver() contractregistry.ContractResolver       { return nil }
+func (m *mockGateway) Shutdown()                                                 { return }
 
 type mockSubMgr struct {
 	err             error
@@ -101,9 +108,24 @@ type mockSubMgr struct {
 	stream          *events.StreamInfo
 	subs            []*events.SubscriptionInfo
 	streams         []*events.StreamInfo
+	health          *events.StreamHealth
 	suspended       bool
 	resumed         bool
 	capturedAddr    *ethbinding.Address
+	capturedExport  *events.SubscriptionExportRequest
+	exportOutput    string
+	exportErr       error
+	exportJob       *events.ExportJobInfo
+	exportJobs      []*events.ExportJobInfo
+	resolvedBlock   string
+	resolveErr      error
+	capturedBlock   string
+	scheduledQuery  *events.ScheduledQueryInfo
+	scheduledList   []*events.ScheduledQueryInfo
+	listener        events.DeliveryListener
+	failEvent       string
+	subscribed      []string
+	deleted         []string
 }
 
 func (m *mockSubMgr) Init() error { return m.err }
@@ -117,6 +139,9 @@ func (m *mockSubMgr) Streams(ctx context.Context) []*events.StreamInfo { return
 func (m *mockSubMgr) StreamByID(ctx context.Context, id string) (*events.StreamInfo, error) {
 	return m.stream, m.err
 }
+func (m *mockSubMgr) StreamHealth(ctx context.Context, id string) (*events.StreamHealth, error) {
+	return m.health, m.err
+}
 func (m *mockSubMgr) SuspendStream(ctx context.Context, id string) error {
 	m.suspended = true
 	return m.err
@@ -128,6 +153,13 @@ func (m *mockSubMgr) ResumeStream(ctx context.Context, id string) error {
 func (m *mockSubMgr) DeleteStream(ctx context.Context, id string) error { return m.err }
 func (m *mockSubMgr) AddSubscription(ctx context.Context, addr *ethbinding.Address, abi *contractregistry.ABILocation, event *ethbinding.ABIElementMarshaling, streamID, initialBlock, name string) (*events.SubscriptionInfo, error) {
 	m.capturedAddr = addr
+	m.capturedBlock = initialBlock
+	if event != nil {
+		m.subscribed = append(m.subscribed, event.Name)
+		if event.Name == m.failEvent {
+			return nil, fmt.Errorf("pop")
+		}
+	}
 	return m.sub, m.err
 }
 func (m *mockSubMgr) AddSubscriptionDirect(ctx context.Context, newSub *events.SubscriptionCreateDTO) (*events.SubscriptionInfo, error) {
@@ -139,11 +171,47 @@ func (m *mockSubMgr) Subscriptions(ctx context.Context) []*events.SubscriptionIn
 func (m *mockSubMgr) SubscriptionByID(ctx context.Context, id string) (*events.SubscriptionInfo, error) {
 	return m.sub, m.err
 }
-func (m *mockSubMgr) DeleteSubscription(ctx context.Context, id string) error { return m.err }
+func (m *mockSubMgr) DeleteSubscription(ctx context.Context, id string) error {
+	m.deleted = append(m.deleted, id)
+	return m.err
+}
 func (m *mockSubMgr) ResetSubscription(ctx context.Context, id, initialBlock string) error {
+	m.capturedBlock = initialBlock
 	return m.err
 }
-func (m *mockSubMgr) Close(wait bool) {}
+func (m *mockSubMgr) ResolveFromTime(ctx context.Context, fromTime string) (string, error) {
+	return m.resolvedBlock, m.resolveErr
+}
+func (m *mockSubMgr) ExportSubscription(ctx context.Context, id string, req *events.SubscriptionExportRequest, w io.Writer) error {
+	m.capturedExport = req
+	if m.exportOutput != "" {
+		_, _ = w.Write([]byte(m.exportOutput))
+	}
+	return m.exportErr
+}
+func (m *mockSubMgr) StartExportJob(ctx context.Context, subID string, req *events.SubscriptionExportRequest) (*events.ExportJobInfo, error) {
+	m.capturedExport = req
+	return m.exportJob, m.err
+}
+func (m *mockSubMgr) ExportJobs(ctx context.Context, subID string) ([]*events.ExportJobInfo, error) {
+	return m.exportJobs, m.err
+}
+func (m *mockSubMgr) ExportJobByID(ctx context.Context, subID, jobID string) (*events.ExportJobInfo, error) {
+	return m.exportJob, m.err
+}
+func (m *mockSubMgr) AddScheduledQuery(ctx context.Context, spec *events.ScheduledQueryInfo) (*events.ScheduledQueryInfo, error) {
+	m.scheduledQuery = spec
+	return spec, m.err
+}
+func (m *mockSubMgr) ScheduledQueries(ctx context.Context) []*events.ScheduledQueryInfo {
+	return m.scheduledList
+}
+func (m *mockSubMgr) ScheduledQueryByID(ctx context.Context, id string) (*events.ScheduledQueryInfo, error) {
+	return m.scheduledQuery, m.err
+}
+func (m *mockSubMgr) DeleteScheduledQuery(ctx context.Context, id string) error { return m.err }
+func (m *mockSubMgr) SetDeliveryListener(listener events.DeliveryListener)      { m.listener = listener }
+func (m *mockSubMgr) Close(wait bool)                                           {}
 
 func newTestDeployMsg(t *testing.T, addr string) *contractregistry.DeployContractWithAddress {
 	compiled, err := eth.CompileContract(simpleEventsSource(), "SimpleEvents", "", "")
@@ -212,6 +280,8 @@ func TestSendTransactionAsyncSuccess(t *testing.T) {
 
 	req.Header.Set("X-Firefly-PrivateFrom", "0xdC416B907857Fa8c0e0d55ec21766Ee3546D5f90")
 	req.Header.Set("X-Firefly-PrivateFor", "0xE7E32f0d5A2D55B2aD27E0C2d663807F28f7c745,0xB92F8CebA52fFb5F08f870bd355B1d32f0fd9f7C")
+	req.Header.Set("X-Firefly-ReplyMode", "Webhook")
+	req.Header.Set("X-Firefly-ReplyWebhook", "https://example.com/replies")
 	router.ServeHTTP(res, req)
 
 	assert.Equal(202, res.Result().StatusCode)
@@ -224,6 +294,9 @@ func TestSendTransactionAsyncSuccess(t *testing.T) {
 	assert.Equal(true, dispatcher.asyncDispatchAck)
 	assert.Equal(from, dispatcher.asyncDispatchMsg["from"])
 	assert.Equal(to, dispatcher.asyncDispatchMsg["to"])
+	headers := dispatcher.asyncDispatchMsg["headers"].(map[string]interface{})
+	assert.Equal("webhook", headers["replyMode"])
+	assert.Equal("https://example.com/replies", headers["replyWebhook"])
 	assert.Equal("0xdC416B907857Fa8c0e0d55ec21766Ee3546D5f90", dispatcher.asyncDispatchMsg["privateFrom"])
 	assert.Equal("0xE7E32f0d5A2D55B2aD27E0C2d663807F28f7c745", dispatcher.asyncDispatchMsg["privateFor"].([]interface{})[0])
 	assert.Equal("0xB92F8CebA52fFb5F08f870bd355B1d32f0fd9f7C", dispatcher.asyncDispatchMsg["privateFor"].([]interface{})[1])
@@ -231,6 +304,22 @@ func TestSendTransactionAsyncSuccess(t *testing.T) {
 	mcr.AssertExpectations(t)
 }
 
+func TestAssignReplyMode(t *testing.T) {
+	assert := assert.New(t)
+	r := &rest2eth{}
+
+	headers := &messages.RequestHeaders{}
+	req := httptest.NewRequest("POST", "/contracts/0x12345/set?fly-replymode=NONE", nil)
+	r.assignReplyMode(headers, req)
+	assert.Equal(messages.ReplyModeNone, headers.ReplyMode)
+	assert.Empty(headers.ReplyWebhook)
+
+	headers = &messages.RequestHeaders{}
+	req = httptest.NewRequest("POST", "/contracts/0x12345/set", nil)
+	r.assignReplyMode(headers, req)
+	assert.Empty(headers.ReplyMode)
+}
+
 func TestDeployContractAsyncSuccess(t *testing.T) {
 	assert := assert.New(t)
 	dir := tempdir()
@@ -329,7 +418,7 @@ func TestDeployContractAsyncDuplicate(t *testing.T) {
 	r, router, res, _ := newTestREST2EthAndMsg(dispatcher, from, "", bodyMap)
 	mcr := r.cr.(*contractregistrymocks.ContractStore)
 	expectABISuccess(t, mcr, "abi1")
-	mcr.On("CheckNameAvailable", "random", false).Return(fmt.Errorf("spent already"))
+	mcr.On("CheckNameAvailable", "random").Return(fmt.Errorf("spent already"))
 
 	body, _ := json.Marshal(&bodyMap)
 	req := httptest.NewRequest("POST", "/abis/abi1?fly-privateFrom=0xdC416B907857Fa8c0e0d55ec21766Ee3546D5f90&fly-privateFor=0xE7E32f0d5A2D55B2aD27E0C2d663807F28f7c745&fly-privateFor=0xB92F8CebA52fFb5F08f870bd355B1d32f0fd9f7C", bytes.NewReader(body))
@@ -345,6 +434,79 @@ func TestDeployContractAsyncDuplicate(t *testing.T) {
 	mcr.AssertExpectations(t)
 }
 
+func testLinkedDeployRequest(t *testing.T, libraries ...string) (*contractregistrymocks.ContractStore, *mockREST2EthDispatcher, *httptest.ResponseRecorder, func()) {
+	from := "0x66c5fe653e7a9ebb628a6d40f0452d1e358baee8"
+	dispatcher := &mockREST2EthDispatcher{
+		asyncDispatchReply: &messages.AsyncSentMsg{Sent: true, Request: "request1"},
+	}
+	r, router, res, _ := newTestREST2EthAndMsg(dispatcher, from, "", map[string]interface{}{})
+	mcr := r.cr.(*contractregistrymocks.ContractStore)
+	mcr.On("GetABI", contractregistry.ABILocation{ABIType: contractregistry.LocalABI, Name: "abi1"}, false).
+		Return(&contractregistry.DeployContractWithAddress{Contract: &messages.DeployContract{
+			ABI:      ethbinding.ABIMarshaling{},
+			Compiled: make([]byte, 22),
+			LinkReferences: []*messages.LinkReference{
+				{Library: "contracts/math.sol:Math", Offsets: []int{1}},
+				{Library: "contracts/strings.sol:Strings", Offsets: []int{1}},
+			},
+		}}, nil)
+
+	query := url.Values{}
+	for _, library := range libraries {
+		query.Add("fly-library", library)
+	}
+	req := httptest.NewRequest("POST", "/abis/abi1?"+query.Encode(), bytes.NewReader([]byte("{}")))
+	req.Header.Add("x-firefly-from", from)
+	return mcr, dispatcher, res, func() { router.ServeHTTP(res, req) }
+}
+
+func TestDeployContractLinkedLibraries(t *testing.T) {
+	assert := assert.New(t)
+
+	mcr, dispatcher, res, serve := testLinkedDeployRequest(t, "Math=0x0123456789abcdef0123456789abcdef01234567")
+	mcr.On("ResolveContractAddress", "Strings").Return("1123456789abcdef0123456789abcdef01234567", nil)
+	serve()
+
+	assert.Equal(202, res.Result().StatusCode)
+	assert.Equal(map[string]interface{}{
+		"contracts/math.sol:Math":       "0x0123456789abcdef0123456789abcdef01234567",
+		"contracts/strings.sol:Strings": "1123456789abcdef0123456789abcdef01234567",
+	}, dispatcher.asyncDispatchMsg["libraries"])
+
+	mcr, dispatcher, res, serve = testLinkedDeployRequest(t, "contracts/math.sol:Math=mathlib,Strings=0x1123456789abcdef0123456789abcdef01234567")
+	mcr.On("ResolveContractAddress", "mathlib").Return("0123456789abcdef0123456789abcdef01234567", nil)
+	serve()
+
+	assert.Equal(202, res.Result().StatusCode)
+	assert.Equal("0123456789abcdef0123456789abcdef01234567", dispatcher.asyncDispatchMsg["libraries"].(map[string]interface{})["contracts/math.sol:Math"])
+}
+
+func TestDeployContractLinkedLibrariesFail(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, test := range []struct {
+		libraries []string
+		resolved  string
+		err       string
+	}{
+		{[]string{"Math"}, "", "FFEC100402.*'Math'"},
+		{[]string{"=0x0123456789abcdef0123456789abcdef01234567"}, "", "FFEC100402"},
+		{nil, "Math", "FFEC100399.*contracts/math.sol:Math"},
+		{[]string{"Math=mathlib"}, "mathlib", "pop"},
+	} {
+		mcr, _, res, serve := testLinkedDeployRequest(t, test.libraries...)
+		if test.resolved != "" {
+			mcr.On("ResolveContractAddress", test.resolved).Return("", fmt.Errorf("pop"))
+		}
+		serve()
+
+		assert.Equal(400, res.Result().StatusCode)
+		var resBody map[string]string
+		json.NewDecoder(res.Body).Decode(&resBody)
+		assert.Regexp(test.err, resBody["code"]+resBody["error"])
+	}
+}
+
 func TestSendTransactionSyncSuccess(t *testing.T) {
 	assert := assert.New(t)
 	dir := tempdir()
@@ -386,6 +548,43 @@ func TestSendTransactionSyncSuccess(t *testing.T) {
 	mcr.AssertExpectations(t)
 }
 
+func TestSendTransactionSyncNamespace(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+
+	bodyMap := make(map[string]interface{})
+	bodyMap["i"] = 12345
+	bodyMap["s"] = "testing"
+	to := "0x567a417717cb6c59ddc1035705f02c0fd1ab1872"
+	from := "0x66c5fe653e7a9ebb628a6d40f0452d1e358baee8"
+	receipt := &messages.TransactionReceipt{
+		ReplyCommon: messages.ReplyCommon{
+			Headers: messages.ReplyHeaders{
+				CommonHeaders: messages.CommonHeaders{
+					MsgType: messages.MsgTypeTransactionSuccess,
+				},
+			},
+		},
+	}
+	dispatcher := &mockREST2EthDispatcher{
+		sendTransactionSyncReceipt: receipt,
+	}
+
+	r, router, res, _ := newTestREST2EthAndMsg(dispatcher, from, to, bodyMap)
+	mcr := r.cr.(*contractregistrymocks.ContractStore)
+	expectContractSuccess(t, mcr, to)
+
+	body, _ := json.Marshal(&bodyMap)
+	req := httptest.NewRequest("POST", "/contracts/"+to+"/set?fly-sync", bytes.NewReader(body))
+	req = req.WithContext(auth.WithNamespace(req.Context(), "tenant1"))
+	req.Header.Add("x-firefly-from", from)
+	router.ServeHTTP(res, req)
+
+	assert.Equal(200, res.Result().StatusCode)
+	assert.Equal("tenant1", dispatcher.sendTransactionMsg.Headers.Namespace)
+}
+
 func TestSendTransactionSyncFailure(t *testing.T) {
 	assert := assert.New(t)
 	dir := tempdir()
@@ -1463,6 +1662,87 @@ func TestCallMethodSuccess(t *testing.T) {
 	mockRPC.AssertExpectations(t)
 }
 
+func TestCallMethodStreamedResult(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+
+	to := "0x567a417717cb6c59ddc1035705f02c0fd1ab1872"
+	dispatcher := &mockREST2EthDispatcher{}
+
+	r, router, res, _ := newTestREST2EthAndMsg(dispatcher, "", to, map[string]interface{}{})
+	r.setStreamCallResults(64)
+	mcr := r.cr.(*contractregistrymocks.ContractStore)
+	expectContractSuccess(t, mcr, to)
+
+	mockRPC := r.rpc.(*ethmocks.RPCClient)
+	mockRPC.On("CallContext", mock.Anything, mock.Anything, "eth_call", mock.Anything, "latest").
+		Run(func(args mock.Arguments) {
+			result := args[1].(*string)
+			*result = "0x000000000000000000000000000000000000000000000000000000000001e2400000000000000000000000000000000000000000000000000000000000000040000000000000000000000000000000000000000000000000000000000000000774657374696e6700000000000000000000000000000000000000000000000000"
+		}).
+		Return(nil)
+
+	req := httptest.NewRequest("GET", "/contracts/"+to+"/get", bytes.NewReader([]byte{}))
+	router.ServeHTTP(res, req)
+
+	assert.Equal(200, res.Result().StatusCode)
+	assert.Equal("application/json", res.Result().Header.Get("Content-Type"))
+	// Streamed results are written compact, as they are decoded
+	assert.Equal(`{"i":"123456","s":"testing"}`, res.Body.String())
+
+	mcr.AssertExpectations(t)
+	mockRPC.AssertExpectations(t)
+}
+
+func TestCallMethodStreamedResultFallback(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+
+	to := "0x567a417717cb6c59ddc1035705f02c0fd1ab1872"
+	dispatcher := &mockREST2EthDispatcher{}
+
+	// Return data that cannot be decoded is not streamed, so the error is reported as before
+	r, router, res, _ := newTestREST2EthAndMsg(dispatcher, "", to, map[string]interface{}{})
+	r.setStreamCallResults(1)
+	mcr := r.cr.(*contractregistrymocks.ContractStore)
+	expectContractSuccess(t, mcr, to)
+	mockRPC := r.rpc.(*ethmocks.RPCClient)
+	mockRPC.On("CallContext", mock.Anything, mock.Anything, "eth_call", mock.Anything, "latest").
+		Run(func(args mock.Arguments) {
+			result := args[1].(*string)
+			*result = "0x000000000000000000000000000000000000000000000000000000000001e240"
+		}).
+		Return(nil)
+
+	req := httptest.NewRequest("GET", "/contracts/"+to+"/get", bytes.NewReader([]byte{}))
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Result().StatusCode)
+	var reply map[string]interface{}
+	err := json.NewDecoder(res.Result().Body).Decode(&reply)
+	assert.NoError(err)
+	assert.Regexp("FFEC100184", reply["error"])
+
+	// A negative threshold turns streaming off
+	r, router, res, _ = newTestREST2EthAndMsg(dispatcher, "", to, map[string]interface{}{})
+	r.setStreamCallResults(-1)
+	mcr = r.cr.(*contractregistrymocks.ContractStore)
+	expectContractSuccess(t, mcr, to)
+	mockRPC = r.rpc.(*ethmocks.RPCClient)
+	mockRPC.On("CallContext", mock.Anything, mock.Anything, "eth_call", mock.Anything, "latest").
+		Run(func(args mock.Arguments) {
+			result := args[1].(*string)
+			*result = "0x000000000000000000000000000000000000000000000000000000000001e2400000000000000000000000000000000000000000000000000000000000000040000000000000000000000000000000000000000000000000000000000000000774657374696e6700000000000000000000000000000000000000000000000000"
+		}).
+		Return(nil)
+
+	req = httptest.NewRequest("GET", "/contracts/"+to+"/get", bytes.NewReader([]byte{}))
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Result().StatusCode)
+	assert.Equal("{\n  \"i\": \"123456\",\n  \"s\": \"testing\"\n}", res.Body.String())
+}
+
 func TestCallMethodHDWalletSuccess(t *testing.T) {
 	log.SetLevel(log.DebugLevel)
 	assert := assert.New(t)
@@ -1652,6 +1932,81 @@ func TestCallMethodFail(t *testing.T) {
 	mcr.AssertExpectations(t)
 }
 
+func TestSimulateTransactionSuccess(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+
+	bodyMap := make(map[string]interface{})
+	bodyMap["i"] = 12345
+	bodyMap["s"] = "testing"
+	to := "0x567a417717cb6c59ddc1035705f02c0fd1ab1872"
+	from := "0x66c5fe653e7a9ebb628a6d40f0452d1e358baee8"
+	dispatcher := &mockREST2EthDispatcher{}
+
+	r, router, res, req := newTestREST2EthAndMsg(dispatcher, from, to, bodyMap)
+	mcr := r.cr.(*contractregistrymocks.ContractStore)
+	expectContractSuccess(t, mcr, to)
+
+	mockRPC := r.rpc.(*ethmocks.RPCClient)
+	mockRPC.On("CallContext", mock.Anything, mock.Anything, "eth_call", mock.Anything, "latest").
+		Run(func(args mock.Arguments) {
+			result := args[1].(*string)
+			*result = "0x"
+		}).
+		Return(nil)
+	mockRPC.On("CallContext", mock.Anything, mock.Anything, "debug_traceCall", mock.Anything, "latest", mock.Anything).
+		Run(func(args mock.Arguments) {
+			json.Unmarshal([]byte(`{
+				"pre": {"0x567a417717cb6c59ddc1035705f02c0fd1ab1872": {"storage": {"0x00": "0x000000000000000000000000000000000000000000000000000000000000000a"}}},
+				"post": {"0x567a417717cb6c59ddc1035705f02c0fd1ab1872": {"storage": {"0x00": "0x0000000000000000000000000000000000000000000000000000000000003039"}}}
+			}`), args[1])
+		}).
+		Return(nil)
+
+	req.URL.RawQuery = "fly-simulate"
+	router.ServeHTTP(res, req)
+
+	assert.Equal(200, res.Result().StatusCode)
+	var reply eth.SimulationResult
+	err := json.NewDecoder(res.Result().Body).Decode(&reply)
+	assert.NoError(err)
+	assert.Empty(reply.StateDiffError)
+	assert.Equal(&eth.StateChange{
+		From: "0x000000000000000000000000000000000000000000000000000000000000000a",
+		To:   "0x0000000000000000000000000000000000000000000000000000000000003039",
+	}, reply.StateDiff[to].Storage["0x00"])
+	assert.Nil(dispatcher.asyncDispatchMsg)
+
+	mcr.AssertExpectations(t)
+	mockRPC.AssertExpectations(t)
+}
+
+func TestSimulateTransactionDeployUnsupported(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+
+	from := "0x66c5fe653e7a9ebb628a6d40f0452d1e358baee8"
+	dispatcher := &mockREST2EthDispatcher{}
+
+	r, router, res, _ := newTestREST2EthAndMsg(dispatcher, from, "", map[string]interface{}{})
+	mcr := r.cr.(*contractregistrymocks.ContractStore)
+	expectABISuccess(t, mcr, "abi1")
+
+	body, _ := json.Marshal(map[string]interface{}{"i": 12345, "s": "testing"})
+	req := httptest.NewRequest("POST", "/abis/abi1?fly-simulate=true", bytes.NewReader(body))
+	req.Header.Add("x-firefly-from", from)
+	router.ServeHTTP(res, req)
+
+	assert.Equal(400, res.Result().StatusCode)
+	reply := errors.RESTError{}
+	err := json.NewDecoder(res.Result().Body).Decode(&reply)
+	assert.NoError(err)
+	assert.Regexp("Simulation is not supported for contract deployment", reply.Message)
+	assert.Nil(dispatcher.asyncDispatchMsg)
+}
+
 func TestCallMethodViaABIBadAddress(t *testing.T) {
 	assert := assert.New(t)
 	dir := tempdir()
@@ -1816,6 +2171,60 @@ func TestSubscribeNoAddressSuccess(t *testing.T) {
 	mcr.AssertExpectations(t)
 }
 
+func TestSubscribeFromTime(t *testing.T) {
+	assert := assert.New(t)
+
+	dispatcher := &mockREST2EthDispatcher{}
+	r, router := newTestREST2Eth(dispatcher)
+	mcr := r.cr.(*contractregistrymocks.ContractStore)
+	expectABISuccess(t, mcr, "ABI1")
+
+	sm := &mockSubMgr{
+		sub:           &events.SubscriptionInfo{ID: "sub1"},
+		resolvedBlock: "12345",
+	}
+	r.subMgr = sm
+	bodyBytes, _ := json.Marshal(&map[string]string{
+		"stream":   "stream1",
+		"fromTime": "2022-01-03T00:00:00Z",
+	})
+	req := httptest.NewRequest("POST", "/abis/ABI1/Changed/subscribe", bytes.NewReader(bodyBytes))
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	assert.Equal(200, res.Result().StatusCode)
+	assert.Equal("12345", sm.capturedBlock)
+}
+
+func TestSubscribeFromTimeFail(t *testing.T) {
+	assert := assert.New(t)
+
+	dispatcher := &mockREST2EthDispatcher{}
+	r, router := newTestREST2Eth(dispatcher)
+	mcr := r.cr.(*contractregistrymocks.ContractStore)
+	expectABISuccess(t, mcr, "ABI1")
+
+	r.subMgr = &mockSubMgr{resolveErr: fmt.Errorf("pop")}
+	bodyBytes, _ := json.Marshal(&map[string]string{
+		"stream":   "stream1",
+		"fromTime": "2022-01-03T00:00:00Z",
+	})
+	req := httptest.NewRequest("POST", "/abis/ABI1/Changed/subscribe", bytes.NewReader(bodyBytes))
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(400, res.Result().StatusCode)
+
+	bodyBytes, _ = json.Marshal(&map[string]string{
+		"stream":    "stream1",
+		"fromBlock": "0",
+		"fromTime":  "2022-01-03T00:00:00Z",
+	})
+	req = httptest.NewRequest("POST", "/abis/ABI1/Changed/subscribe", bytes.NewReader(bodyBytes))
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(400, res.Result().StatusCode)
+}
+
 func TestSubscribeWithAddressSuccess(t *testing.T) {
 	assert := assert.New(t)
 	dir := tempdir()
@@ -2034,3 +2443,309 @@ func TestGetTransactionInfoDecodeFail(t *testing.T) {
 
 	assert.Equal(500, res.Result().StatusCode)
 }
+
+func expectEventSchemaContract(mcr *contractregistrymocks.ContractStore, address string) {
+	mcr.On("GetContractByAddress", address).
+		Return(&contractregistry.ContractInfo{ABI: "abi1"}, nil)
+	mcr.On("GetABI", contractregistry.ABILocation{
+		ABIType: contractregistry.LocalABI,
+		Name:    "abi1",
+	}, false).Return(&contractregistry.DeployContractWithAddress{
+		Contract: &messages.DeployContract{ABI: ethbinding.ABIMarshaling{
+			{
+				Type: "event",
+				Name: "Changed",
+				Inputs: []ethbinding.ABIArgumentMarshaling{
+					{Name: "from", Type: "address", Indexed: true},
+					{Name: "i", Type: "uint256"},
+				},
+			},
+			{
+				Type:   "event",
+				Name:   "Broken",
+				Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "badness"}},
+			},
+		}},
+	}, nil)
+}
+
+func TestEventSchemaSuccess(t *testing.T) {
+	assert := assert.New(t)
+
+	r, router := newTestREST2Eth(&mockREST2EthDispatcher{})
+	mcr := r.cr.(*contractregistrymocks.ContractStore)
+	expectEventSchemaContract(mcr, "567a417717cb6c59ddc1035705f02c0fd1ab1872")
+
+	req := httptest.NewRequest("GET", "/contracts/0x567a417717cb6c59ddc1035705f02c0fd1ab1872/events/Changed?schema=json", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	assert.Equal(200, res.Result().StatusCode)
+	assert.Equal("application/schema+json", res.Header().Get("Content-Type"))
+	var schema map[string]interface{}
+	err := json.NewDecoder(res.Body).Decode(&schema)
+	assert.NoError(err)
+	assert.Equal("Changed", schema["title"])
+	data := schema["properties"].(map[string]interface{})["data"].(map[string]interface{})
+	assert.Equal([]interface{}{"from", "i"}, data["required"])
+}
+
+func TestEventSchemaErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	r, router := newTestREST2Eth(&mockREST2EthDispatcher{})
+	mcr := r.cr.(*contractregistrymocks.ContractStore)
+	expectEventSchemaContract(mcr, "567a417717cb6c59ddc1035705f02c0fd1ab1872")
+	mcr.On("GetContractByAddress", "0000000000000000000000000000000000000001").
+		Return(nil, fmt.Errorf("pop"))
+
+	for _, test := range []struct {
+		path    string
+		status  int
+		message string
+	}{
+		{"/contracts/0x567a417717cb6c59ddc1035705f02c0fd1ab1872/methods/Changed", 404, "Method or Event 'methods' is not declared"},
+		{"/contracts/0x0000000000000000000000000000000000000001/events/Changed?schema=json", 404, "pop"},
+		{"/contracts/0x567a417717cb6c59ddc1035705f02c0fd1ab1872/events/Missing?schema=json", 404, "Event 'Missing' is not declared"},
+		{"/contracts/0x567a417717cb6c59ddc1035705f02c0fd1ab1872/events/Broken?schema=json", 400, "Invalid event 'Broken'"},
+		{"/contracts/0x567a417717cb6c59ddc1035705f02c0fd1ab1872/events/Changed?schema=yaml", 400, "Unsupported schema format 'yaml'"},
+		{"/contracts/0x567a417717cb6c59ddc1035705f02c0fd1ab1872/events/Changed", 400, "Unsupported schema format ''"},
+	} {
+		req := httptest.NewRequest("GET", test.path, nil)
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		assert.Equal(test.status, res.Result().StatusCode, test.path)
+		reply := errors.RESTError{}
+		json.NewDecoder(res.Body).Decode(&reply)
+		assert.Regexp(test.message, reply.Message, test.path)
+	}
+}
+
+func newTestUnknownFieldsREST2Eth(mode string, bodyMap map[string]interface{}) (*rest2eth, *httprouter.Router, *httptest.ResponseRecorder, *http.Request) {
+	dispatcher := &mockREST2EthDispatcher{
+		asyncDispatchReply: &messages.AsyncSentMsg{
+			Sent:    true,
+			Request: "request1",
+		},
+	}
+	r, router := newTestREST2Eth(dispatcher)
+	r.setUnknownFields(mode)
+	mcr := r.cr.(*contractregistrymocks.ContractStore)
+	mcr.On("GetABI", contractregistry.ABILocation{
+		ABIType: contractregistry.LocalABI,
+		Name:    "testabi",
+	}, false).
+		Return(&contractregistry.DeployContractWithAddress{
+			Contract: &messages.DeployContract{
+				ABI: ethbinding.ABIMarshaling{
+					{
+						Name: "set", Type: "function", Inputs: []ethbinding.ABIArgumentMarshaling{
+							{Name: "x", Type: "uint256", InternalType: "uint256"},
+							{Name: "", Type: "uint256", InternalType: "uint256"},
+						},
+					},
+				},
+			},
+		}, nil)
+
+	body, _ := json.Marshal(&bodyMap)
+	req := httptest.NewRequest("POST", "/abis/testabi/0x29fb3f4f7cc82a1456903a506e88cdd63b1d74e8/set", bytes.NewReader(body))
+	req.Header.Add("x-firefly-from", "0x66c5fe653e7a9ebb628a6d40f0452d1e358baee8")
+	return r, router, httptest.NewRecorder(), req
+}
+
+func TestSendTransactionUnknownFieldsStrict(t *testing.T) {
+	assert := assert.New(t)
+
+	_, router, res, req := newTestUnknownFieldsREST2Eth(UnknownFieldsStrict, map[string]interface{}{
+		"x":        1,
+		"input1":   2,
+		"gasLimit": 1000000,
+		"valeu":    10,
+	})
+	router.ServeHTTP(res, req)
+
+	assert.Equal(400, res.Result().StatusCode)
+	reply := errors.RESTError{}
+	err := json.NewDecoder(res.Result().Body).Decode(&reply)
+	assert.NoError(err)
+	assert.Regexp("Unknown field\\(s\\) in body for method 'set': gasLimit,valeu", reply.Message)
+}
+
+func TestSendTransactionUnknownFieldsStrictAllKnown(t *testing.T) {
+	assert := assert.New(t)
+
+	_, router, res, req := newTestUnknownFieldsREST2Eth(UnknownFieldsStrict, map[string]interface{}{
+		"x":      1,
+		"input1": 2,
+	})
+	router.ServeHTTP(res, req)
+
+	assert.Equal(202, res.Result().StatusCode)
+}
+
+func TestSendTransactionUnknownFieldsLenient(t *testing.T) {
+	assert := assert.New(t)
+
+	_, router, res, req := newTestUnknownFieldsREST2Eth("", map[string]interface{}{
+		"x":        1,
+		"input1":   2,
+		"gasLimit": 1000000,
+	})
+	router.ServeHTTP(res, req)
+
+	assert.Equal(202, res.Result().StatusCode)
+}
+
+func TestSetUnknownFieldsBadMode(t *testing.T) {
+	r, _ := newTestREST2Eth(&mockREST2EthDispatcher{})
+	r.setUnknownFields("wrong")
+	assert.Equal(t, UnknownFieldsLenient, r.unknownFields)
+	r.setUnknownFields(UnknownFieldsStrict)
+	assert.Equal(t, UnknownFieldsStrict, r.unknownFields)
+}
+
+func TestSendTransactionOverloadedMethods(t *testing.T) {
+	assert := assert.New(t)
+
+	var overloadedABI ethbinding.ABIMarshaling
+	err := json.Unmarshal([]byte(`[
+		{"name":"transfer","type":"function","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"outputs":[]},
+		{"name":"transfer","type":"function","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"},{"name":"data","type":"bytes"}],"outputs":[]}
+	]`), &overloadedABI)
+	assert.NoError(err)
+	to := "0x567a417717cb6c59ddc1035705f02c0fd1ab1872"
+	from := "0x66c5fe653e7a9ebb628a6d40f0452d1e358baee8"
+	dispatcher := &mockREST2EthDispatcher{
+		asyncDispatchReply: &messages.AsyncSentMsg{Sent: true, Request: "request1"},
+	}
+	r, router := newTestREST2Eth(dispatcher)
+	mcr := r.cr.(*contractregistrymocks.ContractStore)
+	mcr.On("GetContractByAddress", strings.TrimPrefix(to, "0x")).
+		Return(&contractregistry.ContractInfo{ABI: "abi1"}, nil)
+	mcr.On("GetABI", contractregistry.ABILocation{ABIType: contractregistry.LocalABI, Name: "abi1"}, false).
+		Return(&contractregistry.DeployContractWithAddress{Contract: &messages.DeployContract{ABI: overloadedABI}}, nil)
+
+	send := func(method string) *httptest.ResponseRecorder {
+		body := `{"to":"0x66c5fe653e7a9ebb628a6d40f0452d1e358baee8","value":1,"data":"0x01"}`
+		req := httptest.NewRequest("POST", "/contracts/"+to+"/"+method, strings.NewReader(body))
+		req.Header.Add("x-firefly-from", from)
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	// The full signature selects the overload, ignoring whitespace
+	res := send("transfer(address,%20uint256,bytes)")
+	assert.Equal(202, res.Result().StatusCode)
+	method := dispatcher.asyncDispatchMsg["method"].(map[string]interface{})
+	assert.Len(method["inputs"], 3)
+	assert.Len(dispatcher.asyncDispatchMsg["params"], 3)
+
+	// The name alone is the first declared overload
+	res = send("transfer")
+	assert.Equal(202, res.Result().StatusCode)
+	method = dispatcher.asyncDispatchMsg["method"].(map[string]interface{})
+	assert.Len(method["inputs"], 2)
+
+	res = send("transfer(address)")
+	assert.Equal(404, res.Result().StatusCode)
+}
+
+func newTestTokenREST2Eth(t *testing.T, dispatcher *mockREST2EthDispatcher, to string) (*rest2eth, *httprouter.Router) {
+	var tokenABI ethbinding.ABIMarshaling
+	err := json.Unmarshal([]byte(`[
+		{"name":"transfer","type":"function","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"outputs":[]},
+		{"name":"balanceOf","type":"function","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"balance","type":"uint256"},{"name":"count","type":"uint32"}]}
+	]`), &tokenABI)
+	assert.NoError(t, err)
+	r, router := newTestREST2Eth(dispatcher)
+	r.setTokenDecimals(map[string]uint8{strings.ToUpper(strings.TrimPrefix(to, "0x")): 6})
+	mcr := r.cr.(*contractregistrymocks.ContractStore)
+	mcr.On("GetContractByAddress", strings.TrimPrefix(to, "0x")).
+		Return(&contractregistry.ContractInfo{ABI: "abi1"}, nil)
+	mcr.On("GetABI", contractregistry.ABILocation{ABIType: contractregistry.LocalABI, Name: "abi1"}, false).
+		Return(&contractregistry.DeployContractWithAddress{Contract: &messages.DeployContract{ABI: tokenABI}}, nil)
+	return r, router
+}
+
+func TestSendTransactionAmountUnits(t *testing.T) {
+	assert := assert.New(t)
+
+	to := "0x567a417717cb6c59ddc1035705f02c0fd1ab1872"
+	dispatcher := &mockREST2EthDispatcher{
+		asyncDispatchReply: &messages.AsyncSentMsg{Sent: true, Request: "request1"},
+	}
+	_, router := newTestTokenREST2Eth(t, dispatcher, to)
+
+	send := func(value, query string) *httptest.ResponseRecorder {
+		body := `{"to":"0x66c5fe653e7a9ebb628a6d40f0452d1e358baee8","value":"` + value + `"}`
+		req := httptest.NewRequest("POST", "/contracts/"+to+"/transfer?"+query, strings.NewReader(body))
+		req.Header.Add("x-firefly-from", "0x66c5fe653e7a9ebb628a6d40f0452d1e358baee8")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	res := send("12.5 tokens", "fly-ethvalue=0.1%20ether&fly-gasprice=2%20gwei")
+	assert.Equal(202, res.Result().StatusCode)
+	assert.Equal("12500000", dispatcher.asyncDispatchMsg["params"].([]interface{})[1])
+	assert.Equal(float64(100000000000000000), dispatcher.asyncDispatchMsg["value"])
+	assert.Equal(float64(2000000000), dispatcher.asyncDispatchMsg["gasPrice"])
+
+	res = send("0.0000001 token", "")
+	assert.Equal(400, res.Result().StatusCode)
+	reply := errors.RESTError{}
+	err := json.NewDecoder(res.Result().Body).Decode(&reply)
+	assert.NoError(err)
+	assert.Regexp("more than the 6 decimal places allowed by unit 'token'", reply.Message)
+
+	res = send("1", "fly-ethvalue=1%20bitcoin")
+	assert.Equal(400, res.Result().StatusCode)
+
+	res = send("1", "fly-gasprice=1.5%20wei")
+	assert.Equal(400, res.Result().StatusCode)
+}
+
+func TestCallMethodAmountUnits(t *testing.T) {
+	assert := assert.New(t)
+
+	to := "0x567a417717cb6c59ddc1035705f02c0fd1ab1872"
+	r, router := newTestTokenREST2Eth(t, &mockREST2EthDispatcher{}, to)
+	mockRPC := r.rpc.(*ethmocks.RPCClient)
+	mockRPC.On("CallContext", mock.Anything, mock.Anything, "eth_call", mock.Anything, "latest").
+		Run(func(args mock.Arguments) {
+			result := args[1].(*string)
+			*result = "0x00000000000000000000000000000000000000000000000000000000001e84800000000000000000000000000000000000000000000000000000000000000003"
+		}).
+		Return(nil)
+
+	call := func(units string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		req := httptest.NewRequest("GET", "/contracts/"+to+"/balanceOf?owner=0x66c5fe653e7a9ebb628a6d40f0452d1e358baee8&fly-units="+units, nil)
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		var reply map[string]interface{}
+		json.NewDecoder(res.Result().Body).Decode(&reply)
+		return res, reply
+	}
+
+	res, reply := call("token")
+	assert.Equal(200, res.Result().StatusCode)
+	assert.Equal("2", reply["balance"])
+	assert.Equal("3", reply["count"])
+
+	res, reply = call("gwei")
+	assert.Equal(200, res.Result().StatusCode)
+	assert.Equal("0.002", reply["balance"])
+
+	res, _ = call("furlong")
+	assert.Equal(400, res.Result().StatusCode)
+}
+
+func TestTokenDecimalsFor(t *testing.T) {
+	r, _ := newTestREST2Eth(&mockREST2EthDispatcher{})
+	r.setTokenDecimals(map[string]uint8{"0xABCD": 18})
+	assert.Equal(t, uint8(18), *r.tokenDecimalsFor("0xabcd"))
+	assert.Nil(t, r.tokenDecimalsFor("0x1234"))
+	assert.Nil(t, r.tokenDecimalsFor(""))
+}
@@ -0,0 +1,150 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	ethconnecterrors "github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	"github.com/julienschmidt/httprouter"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	log "github.com/sirupsen/logrus"
+)
+
+const routesPathSegment = "routes"
+
+// ContractRoutes lists the REST routes generated from the ABI of a contract
+type ContractRoutes struct {
+	Address string          `json:"address,omitempty"`
+	Routes  []ContractRoute `json:"routes"`
+}
+
+// ContractRoute is a single generated route, for a method or an event subscription
+type ContractRoute struct {
+	Path        string               `json:"path"`
+	Type        string               `json:"type"`
+	Name        string               `json:"name"`
+	Signature   string               `json:"signature"`
+	HTTPMethods []string             `json:"httpMethods"`
+	Constant    bool                 `json:"constant"`
+	Payable     bool                 `json:"payable"`
+	Inputs      []ContractRouteParam `json:"inputs"`
+	Outputs     []ContractRouteParam `json:"outputs,omitempty"`
+}
+
+// ContractRouteParam is an input or output of a route, named as it is in the request or response body
+type ContractRouteParam struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Indexed bool   `json:"indexed,omitempty"`
+}
+
+// listRoutes serves GET /contracts/:address/routes. It returns false without writing a response
+// if the contract declares its own method or event called "routes", which takes precedence.
+func (r *rest2eth) listRoutes(res http.ResponseWriter, req *http.Request, params httprouter.Params) bool {
+	var c restCmd
+	a, _, err := r.resolveABI(res, req, params, &c, params.ByName("address"))
+	if err != nil {
+		return true
+	}
+	for _, element := range a {
+		if element.Name == routesPathSegment && (element.Type == "function" || element.Type == "event") {
+			return false
+		}
+	}
+
+	basePath := strings.TrimSuffix(req.URL.Path, "/"+routesPathSegment)
+	routes, err := buildContractRoutes(basePath, a)
+	if err != nil {
+		r.restErrReply(res, req, err, 400)
+		return true
+	}
+	resBody := &ContractRoutes{Routes: routes}
+	if c.addr != "" {
+		resBody.Address = "0x" + c.addr
+	}
+
+	resBytes, _ := json.MarshalIndent(resBody, "", "  ")
+	status := 200
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	res.Write(resBytes)
+	return true
+}
+
+// buildContractRoutes walks the ABI in the same order as the OpenAPI generator, naming unnamed
+// arguments the same way the request and response bodies do
+func buildContractRoutes(basePath string, a ethbinding.ABIMarshaling) ([]ContractRoute, error) {
+	routes := []ContractRoute{}
+	for i := range a {
+		element := &a[i]
+		switch element.Type {
+		case "function":
+			method, err := ethbind.API.ABIElementMarshalingToABIMethod(element)
+			if err != nil {
+				return nil, ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayMethodABIInvalid, element.Name, err)
+			}
+			routes = append(routes, ContractRoute{
+				Path:        basePath + "/" + method.Name,
+				Type:        element.Type,
+				Name:        method.Name,
+				Signature:   method.Sig,
+				HTTPMethods: []string{http.MethodGet, http.MethodPost},
+				Constant:    method.IsConstant(),
+				Payable:     method.IsPayable(),
+				Inputs:      routeParams(method.Inputs, "input"),
+				Outputs:     routeParams(method.Outputs, "output"),
+			})
+		case "event":
+			event, err := ethbind.API.ABIElementMarshalingToABIEvent(element)
+			if err != nil {
+				return nil, ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayEventABIInvalid, element.Name, err)
+			}
+			routes = append(routes, ContractRoute{
+				Path:        basePath + "/" + event.Name + "/subscribe",
+				Type:        element.Type,
+				Name:        event.Name,
+				Signature:   event.Sig,
+				HTTPMethods: []string{http.MethodPost},
+				Inputs:      routeParams(event.Inputs, "output"),
+			})
+		}
+	}
+	return routes, nil
+}
+
+func routeParams(args ethbinding.ABIArguments, defaultName string) []ContractRouteParam {
+	params := make([]ContractRouteParam, len(args))
+	for i, arg := range args {
+		name := arg.Name
+		if name == "" {
+			name = defaultName
+			if i != 0 {
+				name += strconv.Itoa(i)
+			}
+		}
+		params[i] = ContractRouteParam{
+			Name:    name,
+			Type:    arg.Type.String(),
+			Indexed: arg.Indexed,
+		}
+	}
+	return params
+}
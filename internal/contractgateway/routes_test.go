@@ -0,0 +1,192 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractgateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/contractregistry"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/mocks/contractregistrymocks"
+	"github.com/hyperledger/firefly-ethconnect/mocks/ethmocks"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func testRoutesABI() ethbinding.ABIMarshaling {
+	return ethbinding.ABIMarshaling{
+		{
+			Type:   "constructor",
+			Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "uint256"}},
+		},
+		{
+			Type:            "function",
+			Name:            "set",
+			StateMutability: "payable",
+			Inputs: []ethbinding.ABIArgumentMarshaling{
+				{Name: "i", Type: "uint256"},
+				{Name: "", Type: "string"},
+			},
+		},
+		{
+			Type:            "function",
+			Name:            "get",
+			StateMutability: "view",
+			Outputs: []ethbinding.ABIArgumentMarshaling{
+				{Name: "", Type: "uint256"},
+				{Name: "s", Type: "string"},
+			},
+		},
+		{
+			Type: "event",
+			Name: "Changed",
+			Inputs: []ethbinding.ABIArgumentMarshaling{
+				{Name: "from", Type: "address", Indexed: true},
+				{Name: "i", Type: "uint256"},
+			},
+		},
+	}
+}
+
+func expectRoutesABI(mcr *contractregistrymocks.ContractStore, address string, abi ethbinding.ABIMarshaling) {
+	mcr.On("GetContractByAddress", address).
+		Return(&contractregistry.ContractInfo{ABI: "abi1"}, nil)
+	mcr.On("GetABI", contractregistry.ABILocation{
+		ABIType: contractregistry.LocalABI,
+		Name:    "abi1",
+	}, false).Return(&contractregistry.DeployContractWithAddress{
+		Contract: &messages.DeployContract{ABI: abi},
+	}, nil)
+}
+
+func TestListRoutes(t *testing.T) {
+	assert := assert.New(t)
+
+	to := "567a417717cb6c59ddc1035705f02c0fd1ab1872"
+	r, router := newTestREST2Eth(&mockREST2EthDispatcher{})
+	expectRoutesABI(r.cr.(*contractregistrymocks.ContractStore), to, testRoutesABI())
+
+	req := httptest.NewRequest("GET", "/contracts/0x"+to+"/routes", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	assert.Equal(200, res.Result().StatusCode)
+	var body ContractRoutes
+	err := json.NewDecoder(res.Body).Decode(&body)
+	assert.NoError(err)
+	assert.Equal("0x"+to, body.Address)
+	assert.Equal([]ContractRoute{
+		{
+			Path:        "/contracts/0x" + to + "/set",
+			Type:        "function",
+			Name:        "set",
+			Signature:   "set(uint256,string)",
+			HTTPMethods: []string{"GET", "POST"},
+			Payable:     true,
+			Inputs: []ContractRouteParam{
+				{Name: "i", Type: "uint256"},
+				{Name: "input1", Type: "string"},
+			},
+		},
+		{
+			Path:        "/contracts/0x" + to + "/get",
+			Type:        "function",
+			Name:        "get",
+			Signature:   "get()",
+			HTTPMethods: []string{"GET", "POST"},
+			Constant:    true,
+			Inputs:      []ContractRouteParam{},
+			Outputs: []ContractRouteParam{
+				{Name: "output", Type: "uint256"},
+				{Name: "s", Type: "string"},
+			},
+		},
+		{
+			Path:        "/contracts/0x" + to + "/Changed/subscribe",
+			Type:        "event",
+			Name:        "Changed",
+			Signature:   "Changed(address,uint256)",
+			HTTPMethods: []string{"POST"},
+			Inputs: []ContractRouteParam{
+				{Name: "from", Type: "address", Indexed: true},
+				{Name: "i", Type: "uint256"},
+			},
+		},
+	}, body.Routes)
+}
+
+func TestListRoutesContractNotFound(t *testing.T) {
+	assert := assert.New(t)
+
+	r, router := newTestREST2Eth(&mockREST2EthDispatcher{})
+	mcr := r.cr.(*contractregistrymocks.ContractStore)
+	mcr.On("ResolveContractAddress", "unknown").Return("", fmt.Errorf("pop"))
+
+	req := httptest.NewRequest("GET", "/contracts/unknown/routes", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	assert.Equal(404, res.Result().StatusCode)
+}
+
+func TestListRoutesBadABI(t *testing.T) {
+	assert := assert.New(t)
+
+	to := "567a417717cb6c59ddc1035705f02c0fd1ab1872"
+	r, router := newTestREST2Eth(&mockREST2EthDispatcher{})
+	expectRoutesABI(r.cr.(*contractregistrymocks.ContractStore), to, ethbinding.ABIMarshaling{
+		{Type: "function", Name: "bad", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "badness"}}},
+	})
+
+	req := httptest.NewRequest("GET", "/contracts/0x"+to+"/routes", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	assert.Equal(400, res.Result().StatusCode)
+	var resBody map[string]interface{}
+	json.NewDecoder(res.Body).Decode(&resBody)
+	assert.Equal("FFEC100109", resBody["code"])
+}
+
+func TestBuildContractRoutesBadEvent(t *testing.T) {
+	_, err := buildContractRoutes("/contracts/0x12345", ethbinding.ABIMarshaling{
+		{Type: "event", Name: "Bad", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "badness"}}},
+	})
+	assert.Regexp(t, "FFEC", err)
+}
+
+func TestListRoutesMethodNamedRoutes(t *testing.T) {
+	assert := assert.New(t)
+
+	to := "567a417717cb6c59ddc1035705f02c0fd1ab1872"
+	r, router := newTestREST2Eth(&mockREST2EthDispatcher{})
+	mockRPC := r.rpc.(*ethmocks.RPCClient)
+	mockRPC.On("CallContext", mock.Anything, mock.Anything, "eth_call", mock.Anything, "latest").Return(fmt.Errorf("pop"))
+	expectRoutesABI(r.cr.(*contractregistrymocks.ContractStore), to, ethbinding.ABIMarshaling{
+		{Type: "function", Name: "routes", StateMutability: "view"},
+	})
+
+	// The contract's own method is called, rather than listing the routes
+	req := httptest.NewRequest("GET", "/contracts/0x"+to+"/routes", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	assert.Equal(500, res.Result().StatusCode)
+	mockRPC.AssertCalled(t, "CallContext", mock.Anything, mock.Anything, "eth_call", mock.Anything, "latest")
+}
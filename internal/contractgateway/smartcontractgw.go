@@ -16,8 +16,10 @@ package contractgateway
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
+	goerrors "errors"
 	"io"
 	"io/ioutil"
 	"mime/multipart"
@@ -29,6 +31,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -45,6 +48,7 @@ import (
 	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
 	"github.com/hyperledger/firefly-ethconnect/internal/events"
 	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/migrations"
 	"github.com/hyperledger/firefly-ethconnect/internal/openapi"
 	"github.com/hyperledger/firefly-ethconnect/internal/tx"
 	"github.com/hyperledger/firefly-ethconnect/internal/utils"
@@ -53,7 +57,8 @@ import (
 )
 
 var (
-	maxFormParsingMemory   int64 = 32 << 20 // 32 MB
+	maxFormParsingMemory   int64 = 32 << 20  // 32 MB
+	maxImportArchiveSize   int64 = 256 << 20 // 256 MB
 	errEventSupportMissing       = errors.Errorf(errors.EventSupportNotConfigured)
 )
 
@@ -68,23 +73,38 @@ type remoteContractInfo struct {
 type SmartContractGateway interface {
 	PreDeploy(msg *messages.DeployContract) error
 	PostDeploy(msg *messages.TransactionReceipt) error
+	DecodeReceiptEvents(msg *messages.TransactionReceipt) []*messages.ReceiptEvent
 	AddRoutes(router *httprouter.Router)
 	SendReply(message interface{})
+	SetEventDeliveryListener(listener events.DeliveryListener)
+	ContractResolver() contractregistry.ContractResolver
 	Shutdown()
 }
 
 // SmartContractGatewayConf configuration
 type SmartContractGatewayConf struct {
 	events.SubscriptionManagerConf
-	StoragePath    string                              `json:"storagePath"`
-	BaseURL        string                              `json:"baseURL"`
-	RemoteRegistry contractregistry.RemoteRegistryConf `json:"registry,omitempty"` // JSON only config - no commandline
+	StoragePath        string                              `json:"storagePath"`
+	BaseURL            string                              `json:"baseURL"`
+	RemoteRegistry     contractregistry.RemoteRegistryConf `json:"registry,omitempty"`    // JSON only config - no commandline
+	RemoteCache        contractregistry.RemoteCacheConf    `json:"remoteCache,omitempty"` // JSON only config - no commandline
+	Peers              []contractregistry.PeerConf         `json:"peers,omitempty"`       // JSON only config - no commandline
+	UnknownFields      string                              `json:"unknownFields,omitempty"`
+	Import             ABIImportConf                       `json:"import,omitempty"`             // JSON only config - no commandline
+	EventQueryMaxRange int64                               `json:"eventQueryMaxRange,omitempty"` // JSON only config - no commandline
+	Notifications      []RegistryNotifierConf              `json:"notifications,omitempty"`      // JSON only config - no commandline
+	Replication        *contractregistry.ReplicationConf   `json:"replication,omitempty"`        // JSON only config - no commandline
+	Migrations         migrations.MigrationConf            `json:"migrations,omitempty"`         // JSON only config - no commandline
+	StreamCallResults  int64                               `json:"streamCallResults,omitempty"`  // JSON only config - no commandline
+	ContentHash        string                              `json:"contentHash,omitempty"`        // JSON only config - no commandline
+	TokenDecimals      map[string]uint8                    `json:"tokenDecimals,omitempty"`      // JSON only config - no commandline
 }
 
 // CobraInitContractGateway standard naming for contract gateway command params
 func CobraInitContractGateway(cmd *cobra.Command, conf *SmartContractGatewayConf) {
 	cmd.Flags().StringVarP(&conf.StoragePath, "openapi-path", "I", "", "Path containing ABI + generated OpenAPI/Swagger 2.0 contact definitions")
 	cmd.Flags().StringVarP(&conf.BaseURL, "openapi-baseurl", "U", "", "Base URL for generated OpenAPI/Swagger 2.0 contact definitions")
+	cmd.Flags().StringVarP(&conf.UnknownFields, "openapi-unknown-fields", "", "", "Handling of body fields that are not method inputs: 'lenient' to log and ignore, or 'strict' to reject")
 	events.CobraInitSubscriptionManager(cmd, &conf.SubscriptionManagerConf)
 }
 
@@ -100,6 +120,18 @@ func (g *smartContractGW) withEventsAuth(handler httprouter.Handle) httprouter.H
 	}
 }
 
+func (g *smartContractGW) withAdminAuth(handler httprouter.Handle) httprouter.Handle {
+	return func(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		err := auth.AuthAdmin(req.Context())
+		if err != nil {
+			log.Errorf("Unauthorized: %s", err)
+			g.gatewayErrReply(res, req, errors.Errorf(errors.Unauthorized), 401)
+			return
+		}
+		handler(res, req, params)
+	}
+}
+
 func (g *smartContractGW) AddRoutes(router *httprouter.Router) {
 	g.r2e.addRoutes(router)
 	router.GET("/contracts", g.listContractsOrABIs)
@@ -107,23 +139,47 @@ func (g *smartContractGW) AddRoutes(router *httprouter.Router) {
 	router.POST("/abis", g.addABI)
 	router.GET("/abis", g.listContractsOrABIs)
 	router.GET("/abis/:abi", g.getContractOrABI)
+	router.PATCH("/contracts/:address", g.renameContract)
+	router.DELETE("/contracts/:address", g.deleteContract)
+	router.DELETE("/abis/:abi", g.deleteABI)
 	router.POST("/abis/:abi/:address", g.registerContract)
 	router.GET("/instances/:instance_lookup", g.getRemoteRegistrySwaggerOrABI)
 	router.GET("/i/:instance_lookup", g.getRemoteRegistrySwaggerOrABI)
 	router.GET("/gateways/:gateway_lookup", g.getRemoteRegistrySwaggerOrABI)
 	router.GET("/g/:gateway_lookup", g.getRemoteRegistrySwaggerOrABI)
+	router.GET("/events", g.queryEvents)
+	router.POST("/admin/contractstore/reindex", g.withAdminAuth(g.reindexContractStore))
+	router.GET("/admin/contractstore/export", g.withAdminAuth(g.exportContractStore))
+	router.POST("/admin/contractstore/import", g.withAdminAuth(g.importContractStore))
+	router.HEAD("/contracts", g.listContractsOrABIs)
+	router.HEAD("/contracts/:address", g.getContractOrABI)
+	router.HEAD("/abis", g.listContractsOrABIs)
+	router.HEAD("/abis/:abi", g.getContractOrABI)
+	router.HEAD("/instances/:instance_lookup", g.getRemoteRegistrySwaggerOrABI)
+	router.HEAD("/i/:instance_lookup", g.getRemoteRegistrySwaggerOrABI)
+	router.HEAD("/gateways/:gateway_lookup", g.getRemoteRegistrySwaggerOrABI)
+	router.HEAD("/g/:gateway_lookup", g.getRemoteRegistrySwaggerOrABI)
 	router.POST(events.StreamPathPrefix, g.withEventsAuth(g.createStream))
 	router.PATCH(events.StreamPathPrefix+"/:id", g.withEventsAuth(g.updateStream))
 	router.GET(events.StreamPathPrefix, g.withEventsAuth(g.listStreamsOrSubs))
 	router.POST(events.SubPathPrefix, g.withEventsAuth(g.addSub))
 	router.GET(events.SubPathPrefix, g.withEventsAuth(g.listStreamsOrSubs))
 	router.GET(events.StreamPathPrefix+"/:id", g.withEventsAuth(g.getStreamOrSub))
+	router.GET(events.StreamPathPrefix+"/:id/health", g.withEventsAuth(g.getStreamHealth))
 	router.GET(events.SubPathPrefix+"/:id", g.withEventsAuth(g.getStreamOrSub))
 	router.DELETE(events.StreamPathPrefix+"/:id", g.withEventsAuth(g.deleteStreamOrSub))
 	router.DELETE(events.SubPathPrefix+"/:id", g.withEventsAuth(g.deleteStreamOrSub))
 	router.POST(events.SubPathPrefix+"/:id/reset", g.withEventsAuth(g.resetSub))
+	router.GET(events.SubPathPrefix+"/:id/export", g.withEventsAuth(g.exportSub))
+	router.POST(events.SubPathPrefix+"/:id/exports", g.withEventsAuth(g.startExportJob))
+	router.GET(events.SubPathPrefix+"/:id/exports", g.withEventsAuth(g.listExportJobs))
+	router.GET(events.SubPathPrefix+"/:id/exports/:job", g.withEventsAuth(g.getExportJob))
 	router.POST(events.StreamPathPrefix+"/:id/suspend", g.withEventsAuth(g.suspendOrResumeStream))
 	router.POST(events.StreamPathPrefix+"/:id/resume", g.withEventsAuth(g.suspendOrResumeStream))
+	router.POST(events.ScheduledQueryPathPrefix, g.withEventsAuth(g.createScheduledQuery))
+	router.GET(events.ScheduledQueryPathPrefix, g.withEventsAuth(g.listStreamsOrSubs))
+	router.GET(events.ScheduledQueryPathPrefix+"/:id", g.withEventsAuth(g.getStreamOrSub))
+	router.DELETE(events.ScheduledQueryPathPrefix+"/:id", g.withEventsAuth(g.deleteStreamOrSub))
 }
 
 func (g *smartContractGW) SendReply(message interface{}) {
@@ -152,16 +208,25 @@ func NewSmartContractGateway(conf *SmartContractGatewayConf, txnConf *tx.TxnProc
 			OrionPrivateAPI:  txnConf.OrionPrivateAPIS,
 			BasicAuth:        true,
 		},
-		ws: ws,
+		ws:  ws,
+		rpc: rpc,
 	}
 	rr := contractregistry.NewRemoteRegistry(&conf.RemoteRegistry)
 	gw.cs = contractregistry.NewContractStore(&contractregistry.ContractStoreConf{
 		BaseURL:     conf.BaseURL,
 		StoragePath: conf.StoragePath,
+		RemoteCache: conf.RemoteCache,
+		Peers:       conf.Peers,
+		Replication: conf.Replication,
+		Migrations:  conf.Migrations,
+		ContentHash: conf.ContentHash,
 	}, rr)
 	if err = gw.cs.Init(); err != nil {
 		return nil, err
 	}
+	if gw.notifier, err = newRegistryNotifier(conf.Notifications, ws); err != nil {
+		return nil, err
+	}
 	syncDispatcher := newSyncDispatcher(processor)
 	if conf.EventLevelDBPath != "" {
 		gw.sm, _ = events.NewSubscriptionManager(&conf.SubscriptionManagerConf, rpc, gw.cs, gw.ws)
@@ -171,6 +236,9 @@ func NewSmartContractGateway(conf *SmartContractGatewayConf, txnConf *tx.TxnProc
 		}
 	}
 	gw.r2e = newREST2eth(gw, gw.cs, rpc, gw.sm, processor, asyncDispatcher, syncDispatcher)
+	gw.r2e.setUnknownFields(conf.UnknownFields)
+	gw.r2e.setStreamCallResults(conf.StreamCallResults)
+	gw.r2e.setTokenDecimals(conf.TokenDecimals)
 	return gw, nil
 }
 
@@ -179,8 +247,10 @@ type smartContractGW struct {
 	sm              events.SubscriptionManager
 	cs              contractregistry.ContractStore
 	r2e             *rest2eth
+	rpc             eth.RPCClient
 	ws              ws.WebSocketChannels
 	baseSwaggerConf *openapi.ABI2SwaggerConf
+	notifier        *registryNotifier
 }
 
 // PostDeploy callback processes the transaction receipt and generates the Swagger
@@ -221,13 +291,82 @@ func (g *smartContractGW) PostDeploy(msg *messages.TransactionReceipt) error {
 				// This was invoked against an existing ABI, so we need to add an instance there
 				abiID = msg.Headers.ReqABIID
 			}
-			_, err = g.cs.AddContract(addrHexNo0x, abiID, registeredName, msg.RegisterAs)
+			var info *contractregistry.ContractInfo
+			if info, err = g.cs.AddContract(addrHexNo0x, abiID, registeredName, msg.RegisterAs, nil); err == nil {
+				info = g.resolveProxy(context.Background(), info)
+				g.notifier.notify(context.Background(), RegistryEventContractRegistered, nil, info)
+			}
 		}
 		return err
 	}
 	return nil
 }
 
+// DecodeReceiptEvents decodes the logs in a transaction receipt that were emitted by
+// contracts in the local registry. Logs from other contracts, or that do not match an
+// event in the ABI of the contract, are skipped.
+func (g *smartContractGW) DecodeReceiptEvents(msg *messages.TransactionReceipt) []*messages.ReceiptEvent {
+	requestID := msg.Headers.ReqID
+	g.processProxyUpgrades(msg)
+	abis := make(map[string]*ethbinding.RuntimeABI)
+	var decoded []*messages.ReceiptEvent
+	for _, l := range msg.Logs {
+		if l.Address == nil || len(l.Topics) == 0 || l.Topics[0] == nil {
+			continue
+		}
+		addrHexNo0x := strings.ToLower(l.Address.Hex()[2:])
+		runtimeABI, loaded := abis[addrHexNo0x]
+		if !loaded {
+			runtimeABI = g.receiptEventsABI(requestID, addrHexNo0x)
+			abis[addrHexNo0x] = runtimeABI
+		}
+		if runtimeABI == nil {
+			continue
+		}
+		for _, event := range runtimeABI.Events {
+			if event.Anonymous || event.ID != *l.Topics[0] {
+				continue
+			}
+			data, err := events.DecodeLog(&event, l.Topics, l.Data)
+			if err != nil {
+				log.Warnf("%s: Failed to decode log %s from %s: %s", requestID, l.LogIndexStr, addrHexNo0x, err)
+				break
+			}
+			decoded = append(decoded, &messages.ReceiptEvent{
+				Address:   l.Address,
+				Event:     event.Name,
+				Signature: ethbind.API.ABIEventSignature(&event),
+				LogIndex:  l.LogIndexStr,
+				Data:      data,
+			})
+			break
+		}
+	}
+	return decoded
+}
+
+func (g *smartContractGW) receiptEventsABI(requestID, addrHexNo0x string) *ethbinding.RuntimeABI {
+	info, err := g.cs.GetContractByAddress(addrHexNo0x)
+	if err != nil {
+		log.Debugf("%s: Not decoding logs from %s: %s", requestID, addrHexNo0x, err)
+		return nil
+	}
+	deployMsg, err := g.cs.GetABI(contractregistry.ABILocation{
+		ABIType: contractregistry.LocalABI,
+		Name:    info.ABI,
+	}, false)
+	if err != nil || deployMsg == nil || deployMsg.Contract == nil {
+		log.Warnf("%s: Failed to load ABI '%s' to decode logs from %s: %v", requestID, info.ABI, addrHexNo0x, err)
+		return nil
+	}
+	runtimeABI, err := ethbind.API.ABIMarshalingToABIRuntime(deployMsg.Contract.ABI)
+	if err != nil {
+		log.Warnf("%s: Failed to parse ABI '%s' to decode logs from %s: %s", requestID, info.ABI, addrHexNo0x, err)
+		return nil
+	}
+	return runtimeABI
+}
+
 func (g *smartContractGW) swaggerForRemoteRegistry(swaggerGen *openapi.ABI2Swagger, apiName, addr string, factoryOnly bool, abi *ethbinding.RuntimeABI, devdoc, path string) *spec.Swagger {
 	var swagger *spec.Swagger
 	if addr == "" {
@@ -280,19 +419,34 @@ func (g *smartContractGW) PreDeploy(msg *messages.DeployContract) (err error) {
 		}
 	}
 	if !contractregistry.IsRemote(msg.Headers.CommonHeaders) {
-		_, err = g.storeDeployableABI(msg, compiled)
+		_, err = g.storeDeployableABI(msg, compiled, false)
 	}
 	return err
 }
 
-func (g *smartContractGW) storeDeployableABI(msg *messages.DeployContract, compiled *eth.CompiledSolidity) (*contractregistry.ABIInfo, error) {
+// storeUploadedABI stores an uploaded ABI and notifies listeners. If the same ABI and bytecode
+// is already stored, the existing ABI is returned instead.
+func (g *smartContractGW) storeUploadedABI(ctx context.Context, msg *messages.DeployContract, compiled *eth.CompiledSolidity) (*contractregistry.ABIInfo, error) {
+	info, err := g.storeDeployableABI(msg, compiled, true)
+	if err == nil && info.ID == msg.Headers.ID {
+		g.notifier.notify(ctx, RegistryEventABIUploaded, info, nil)
+	}
+	return info, err
+}
+
+// storeDeployableABI stores the ABI of a message under its ID. With dedupe, an ABI stored
+// earlier with the same content is returned in place of storing a copy - the deployment of a
+// pre-compiled contract does not dedupe, as the ABI must be registered under the request ID.
+func (g *smartContractGW) storeDeployableABI(msg *messages.DeployContract, compiled *eth.CompiledSolidity, dedupe bool) (*contractregistry.ABIInfo, error) {
 
 	if compiled != nil {
 		msg.Compiled = compiled.Compiled
 		msg.ABI = compiled.ABI
 		msg.DevDoc = compiled.DevDoc
+		msg.UserDoc = compiled.UserDoc
 		msg.ContractName = compiled.ContractName
 		msg.CompilerVersion = compiled.ContractInfo.CompilerVersion
+		msg.LinkReferences = compiled.LinkReferences
 	} else if msg.ABI == nil {
 		return nil, errors.Errorf(errors.RESTGatewayLocalStoreMissingABI)
 	}
@@ -303,11 +457,22 @@ func (g *smartContractGW) storeDeployableABI(msg *messages.DeployContract, compi
 	}
 
 	requestID := msg.Headers.ID
+	if dedupe {
+		existing, err := g.cs.FindABIByContent(msg)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			log.Infof("ABI uploaded as %s is identical to %s", requestID, existing.ID)
+			msg.Solidity = ""
+			return existing, nil
+		}
+	}
 	// We store the swagger in a generic format that can be used to deploy
 	// additional instances, or generically call other instances
 	// Generate and store the swagger
-	swagger := g.swaggerForABI(openapi.NewABI2Swagger(g.baseSwaggerConf), requestID, msg.ContractName, false, runtimeABI, msg.DevDoc, "", "")
-	msg.Description = swagger.Info.Description // Swagger generation parses the devdoc
+	swagger := g.swaggerForABI(openapi.NewABI2Swagger(g.baseSwaggerConf), requestID, msg.ContractName, false, runtimeABI, openapi.MergeNatSpec(msg.DevDoc, msg.UserDoc), "", "")
+	msg.Description = swagger.Info.Description // Swagger generation parses the devdoc and userdoc
 	info, err := g.cs.AddABI(requestID, msg, time.Now().UTC())
 	if err != nil {
 		return nil, err
@@ -331,29 +496,132 @@ func (g *smartContractGW) gatewayErrReply(res http.ResponseWriter, req *http.Req
 	return
 }
 
-// listContractsOrABIs sorts by Title then Address and returns an array
+// listingFilter parses the paging and filtering query parameters of a listing, returning nil
+// if there are none
+func listingFilter(req *http.Request) (*contractregistry.ListingFilter, error) {
+	query := req.URL.Query()
+	filter := &contractregistry.ListingFilter{
+		Name:        query.Get("name"),
+		ABI:         query.Get("abi"),
+		ContentHash: query.Get("contentHash"),
+	}
+	labels, err := contractregistry.ParseLabelSelector(query["label"])
+	if err != nil {
+		return nil, err
+	}
+	filter.Labels = labels
+	for _, param := range []string{"limit", "skip"} {
+		if str := query.Get(param); str != "" {
+			i, err := strconv.Atoi(str)
+			if err != nil || i < 0 {
+				return nil, errors.Errorf(errors.RESTGatewayInvalidListingParam, param, str)
+			}
+			if param == "limit" {
+				filter.Limit = i
+			} else {
+				filter.Skip = i
+			}
+		}
+	}
+	if str := query.Get("createdAfter"); str != "" {
+		t, err := time.Parse(time.RFC3339Nano, str)
+		if err != nil {
+			ms, err := strconv.ParseInt(str, 10, 64)
+			if err != nil {
+				return nil, errors.Errorf(errors.RESTGatewayInvalidListingParam, "createdAfter", str)
+			}
+			t = time.Unix(0, ms*int64(time.Millisecond))
+		}
+		filter.CreatedAfter = t
+	}
+	if str := query.Get("method"); str != "" {
+		selector, err := contractregistry.MethodSelector(str)
+		if err != nil {
+			return nil, err
+		}
+		filter.Method = selector
+	}
+	if str := query.Get("event"); str != "" {
+		topic, err := contractregistry.EventTopic(str)
+		if err != nil {
+			return nil, err
+		}
+		filter.Event = topic
+	}
+	if *filter == (contractregistry.ListingFilter{}) {
+		return nil, nil
+	}
+	return filter, nil
+}
+
+// listContractsOrABIs returns the pre-sorted, pre-serialized listing from the contract store.
+// The ETag lets polling clients avoid downloading a listing that has not changed.
+// When paging or filtering parameters are supplied, only the matching items are returned.
 func (g *smartContractGW) listContractsOrABIs(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
 	log.Infof("--> %s %s", req.Method, req.URL)
 
-	var retval []messages.TimeSortable
-	var err error
-	if strings.HasSuffix(req.URL.Path, "contracts") {
-		retval, err = g.cs.ListContracts()
+	isContracts := strings.HasSuffix(req.URL.Path, "contracts")
+	filter, err := listingFilter(req)
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 400)
+		return
+	}
+	if filter != nil {
+		var items []messages.TimeSortable
+		if isContracts {
+			items, err = g.cs.ListContracts(filter)
+		} else {
+			items, err = g.cs.ListABIs(filter)
+		}
+		if err != nil {
+			g.gatewayErrReply(res, req, err, 500)
+			return
+		}
+		status := 200
+		log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(status)
+		enc := json.NewEncoder(res)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(items)
+		return
+	}
+
+	var listing *contractregistry.CachedListing
+	if isContracts {
+		listing, err = g.cs.CachedContractListing()
 	} else {
-		retval, err = g.cs.ListABIs()
+		listing, err = g.cs.CachedABIListing()
 	}
 	if err != nil {
 		g.gatewayErrReply(res, req, err, 500)
 		return
 	}
 
+	res.Header().Set("ETag", listing.ETag)
+	if etagMatches(req.Header.Get("If-None-Match"), listing.ETag) {
+		status := 304
+		log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+		res.WriteHeader(status)
+		return
+	}
+
 	status := 200
 	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
 	res.Header().Set("Content-Type", "application/json")
 	res.WriteHeader(status)
-	enc := json.NewEncoder(res)
-	enc.SetIndent("", "  ")
-	_ = enc.Encode(&retval)
+	_, _ = res.Write(listing.JSON)
+}
+
+// etagMatches checks an If-None-Match header, which can contain a list of (possibly weak) ETags, or '*'
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
 }
 
 // createStream creates a stream
@@ -386,6 +654,36 @@ func (g *smartContractGW) createStream(res http.ResponseWriter, req *http.Reques
 	_ = enc.Encode(&newSpec)
 }
 
+// createScheduledQuery creates a query that runs on a schedule, delivering results to a stream
+func (g *smartContractGW) createScheduledQuery(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	if g.sm == nil {
+		g.gatewayErrReply(res, req, errEventSupportMissing, 405)
+		return
+	}
+
+	var spec events.ScheduledQueryInfo
+	if err := json.NewDecoder(req.Body).Decode(&spec); err != nil {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewayScheduledQueryInvalid, err), 400)
+		return
+	}
+
+	newSpec, err := g.sm.AddScheduledQuery(req.Context(), &spec)
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 400)
+		return
+	}
+
+	status := 200
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	enc := json.NewEncoder(res)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(&newSpec)
+}
+
 // updateStream updates a stream
 func (g *smartContractGW) updateStream(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
 	log.Infof("--> %s %s", req.Method, req.URL)
@@ -437,6 +735,12 @@ func (g *smartContractGW) listStreamsOrSubs(res http.ResponseWriter, req *http.R
 		for i := range subs {
 			results[i] = subs[i]
 		}
+	} else if strings.HasPrefix(req.URL.Path, events.ScheduledQueryPathPrefix) {
+		queries := g.sm.ScheduledQueries(req.Context())
+		results = make([]messages.TimeSortable, len(queries))
+		for i := range queries {
+			results[i] = queries[i]
+		}
 	} else {
 		streams := g.sm.Streams(req.Context())
 		results = make([]messages.TimeSortable, len(streams))
@@ -472,6 +776,8 @@ func (g *smartContractGW) getStreamOrSub(res http.ResponseWriter, req *http.Requ
 	var err error
 	if strings.HasPrefix(req.URL.Path, events.SubPathPrefix) {
 		retval, err = g.sm.SubscriptionByID(req.Context(), params.ByName("id"))
+	} else if strings.HasPrefix(req.URL.Path, events.ScheduledQueryPathPrefix) {
+		retval, err = g.sm.ScheduledQueryByID(req.Context(), params.ByName("id"))
 	} else {
 		retval, err = g.sm.StreamByID(req.Context(), params.ByName("id"))
 	}
@@ -489,6 +795,30 @@ func (g *smartContractGW) getStreamOrSub(res http.ResponseWriter, req *http.Requ
 	_ = enc.Encode(retval)
 }
 
+// getStreamHealth reports the delivery progress of a stream over REST
+func (g *smartContractGW) getStreamHealth(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	if g.sm == nil {
+		g.gatewayErrReply(res, req, errEventSupportMissing, 405)
+		return
+	}
+
+	health, err := g.sm.StreamHealth(req.Context(), params.ByName("id"))
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 404)
+		return
+	}
+
+	status := 200
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	enc := json.NewEncoder(res)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(health)
+}
+
 // deleteStreamOrSub deletes stream over REST
 func (g *smartContractGW) deleteStreamOrSub(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
 	log.Infof("--> %s %s", req.Method, req.URL)
@@ -501,6 +831,8 @@ func (g *smartContractGW) deleteStreamOrSub(res http.ResponseWriter, req *http.R
 	var err error
 	if strings.HasPrefix(req.URL.Path, events.SubPathPrefix) {
 		err = g.sm.DeleteSubscription(req.Context(), params.ByName("id"))
+	} else if strings.HasPrefix(req.URL.Path, events.ScheduledQueryPathPrefix) {
+		err = g.sm.DeleteScheduledQuery(req.Context(), params.ByName("id"))
 	} else {
 		err = g.sm.DeleteStream(req.Context(), params.ByName("id"))
 	}
@@ -555,8 +887,16 @@ func (g *smartContractGW) resetSub(res http.ResponseWriter, req *http.Request, p
 
 	var body struct {
 		FromBlock string `json:"fromBlock"`
+		FromTime  string `json:"fromTime"`
 	}
 	err := json.NewDecoder(req.Body).Decode(&body)
+	if err == nil && body.FromTime != "" {
+		if body.FromBlock != "" {
+			g.gatewayErrReply(res, req, errors.Errorf(errors.EventStreamsSubscribeBlockAndTime), 400)
+			return
+		}
+		body.FromBlock, err = g.sm.ResolveFromTime(req.Context(), body.FromTime)
+	}
 	if err == nil {
 		err = g.sm.ResetSubscription(req.Context(), params.ByName("id"), body.FromBlock)
 	}
@@ -571,6 +911,159 @@ func (g *smartContractGW) resetSub(res http.ResponseWriter, req *http.Request, p
 	res.WriteHeader(status)
 }
 
+// exportWriter defers sending the status and headers until the first write, so
+// failures before any data is available can still be returned as an error
+type exportWriter struct {
+	res         http.ResponseWriter
+	contentType string
+	started     bool
+}
+
+func (ew *exportWriter) Write(b []byte) (int, error) {
+	if !ew.started {
+		ew.started = true
+		ew.res.Header().Set("Content-Type", ew.contentType)
+		ew.res.WriteHeader(200)
+	}
+	return ew.res.Write(b)
+}
+
+// exportSub streams the decoded events for a historical block range of a subscription
+func (g *smartContractGW) exportSub(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	if g.sm == nil {
+		g.gatewayErrReply(res, req, errEventSupportMissing, 405)
+		return
+	}
+
+	subID := params.ByName("id")
+	if _, err := g.sm.SubscriptionByID(req.Context(), subID); err != nil {
+		g.gatewayErrReply(res, req, err, 404)
+		return
+	}
+
+	query := req.URL.Query()
+	exportReq := &events.SubscriptionExportRequest{
+		FromBlock: query.Get("fromBlock"),
+		ToBlock:   query.Get("toBlock"),
+		Format:    query.Get("format"),
+	}
+	if exportReq.Format == "" {
+		exportReq.Format = events.ExportFormatCSV
+	}
+	ew := &exportWriter{res: res, contentType: "text/csv"}
+	switch exportReq.Format {
+	case events.ExportFormatJSON:
+		ew.contentType = "application/x-ndjson"
+	case events.ExportFormatParquet:
+		ew.contentType = "application/vnd.apache.parquet"
+	}
+
+	err := g.sm.ExportSubscription(req.Context(), subID, exportReq, ew)
+	if err != nil {
+		if !ew.started {
+			g.gatewayErrReply(res, req, err, 400)
+		} else {
+			// Too late to change the status, so all we can do is truncate the output
+			log.Errorf("<-- %s %s [%d]: export terminated: %s", req.Method, req.URL, 200, err)
+		}
+		return
+	}
+	if !ew.started {
+		_, _ = ew.Write([]byte{})
+	}
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, 200)
+}
+
+// startExportJob starts writing an export of a subscription to the configured output location
+func (g *smartContractGW) startExportJob(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	if g.sm == nil {
+		g.gatewayErrReply(res, req, errEventSupportMissing, 405)
+		return
+	}
+
+	var exportReq events.SubscriptionExportRequest
+	if err := json.NewDecoder(req.Body).Decode(&exportReq); err != nil {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewayExportJobInvalid, err), 400)
+		return
+	}
+	if exportReq.Format == "" {
+		exportReq.Format = events.ExportFormatCSV
+	}
+
+	job, err := g.sm.StartExportJob(req.Context(), params.ByName("id"), &exportReq)
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 400)
+		return
+	}
+
+	status := 202
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	enc := json.NewEncoder(res)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(job)
+}
+
+// listExportJobs lists the export jobs of a subscription, newest first
+func (g *smartContractGW) listExportJobs(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	if g.sm == nil {
+		g.gatewayErrReply(res, req, errEventSupportMissing, 405)
+		return
+	}
+
+	jobs, err := g.sm.ExportJobs(req.Context(), params.ByName("id"))
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 404)
+		return
+	}
+	results := make([]messages.TimeSortable, len(jobs))
+	for i := range jobs {
+		results[i] = jobs[i]
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].IsLessThan(results[i], results[j])
+	})
+
+	status := 200
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	enc := json.NewEncoder(res)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(&results)
+}
+
+// getExportJob returns the status of an export job
+func (g *smartContractGW) getExportJob(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	if g.sm == nil {
+		g.gatewayErrReply(res, req, errEventSupportMissing, 405)
+		return
+	}
+
+	job, err := g.sm.ExportJobByID(req.Context(), params.ByName("id"), params.ByName("job"))
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 404)
+		return
+	}
+
+	status := 200
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	enc := json.NewEncoder(res)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(job)
+}
+
 // suspendOrResumeStream suspends or resumes a stream
 func (g *smartContractGW) suspendOrResumeStream(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
 	log.Infof("--> %s %s", req.Method, req.URL)
@@ -676,8 +1169,13 @@ func (g *smartContractGW) getContractOrABI(res http.ResponseWriter, req *http.Re
 	var deployMsg *messages.DeployContract
 	var info messages.TimeSortable
 	var abiID string
+	// Lookups made by a peer are only answered from the local registry
+	var resolver contractregistry.ContractResolver = g.cs
+	if req.Header.Get(contractregistry.PeerLookupHeader) != "" {
+		resolver = g.cs.LocalResolver()
+	}
 	if prefix == "contract" {
-		if deployMsg, registeredName, info, err = g.resolveAddressOrName(params.ByName("address")); err != nil {
+		if deployMsg, registeredName, info, err = g.resolveAddressOrName(resolver, params.ByName("address")); err != nil {
 			g.gatewayErrReply(res, req, err, 404)
 			return
 		}
@@ -686,7 +1184,7 @@ func (g *smartContractGW) getContractOrABI(res http.ResponseWriter, req *http.Re
 		info, err = g.cs.GetLocalABIInfo(abiID)
 		if err == nil {
 			var result *contractregistry.DeployContractWithAddress
-			result, err = g.cs.GetABI(contractregistry.ABILocation{
+			result, err = resolver.GetABI(contractregistry.ABILocation{
 				ABIType: contractregistry.LocalABI,
 				Name:    abiID,
 			}, false)
@@ -708,7 +1206,7 @@ func (g *smartContractGW) getContractOrABI(res http.ResponseWriter, req *http.Re
 			g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewayInvalidABI, err), 404)
 			return
 		}
-		swagger := g.swaggerForABI(swaggerGen, abiID, deployMsg.ContractName, factoryOnly, runtimeABI, deployMsg.DevDoc, addr, registeredName)
+		swagger := g.swaggerForABI(swaggerGen, abiID, deployMsg.ContractName, factoryOnly, runtimeABI, openapi.MergeNatSpec(deployMsg.DevDoc, deployMsg.UserDoc), addr, registeredName)
 		g.replyWithSwagger(res, req, swagger, id, from)
 	} else if abiRequest {
 		log.Infof("<-- %s %s [%d]", req.Method, req.URL, 200)
@@ -781,7 +1279,7 @@ func (g *smartContractGW) getRemoteRegistrySwaggerOrABI(res http.ResponseWriter,
 			g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewayInvalidABI, err), 400)
 			return
 		}
-		swagger := g.swaggerForRemoteRegistry(swaggerGen, id, addr, factoryOnly, runtimeABI, deployMsg.DevDoc, req.URL.Path)
+		swagger := g.swaggerForRemoteRegistry(swaggerGen, id, addr, factoryOnly, runtimeABI, openapi.MergeNatSpec(deployMsg.DevDoc, deployMsg.UserDoc), req.URL.Path)
 		g.replyWithSwagger(res, req, swagger, id, from)
 	} else if abiRequest {
 		log.Infof("<-- %s %s [%d]", req.Method, req.URL, 200)
@@ -806,6 +1304,15 @@ func (g *smartContractGW) getRemoteRegistrySwaggerOrABI(res http.ResponseWriter,
 }
 
 func (g *smartContractGW) registerContract(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	// The router cannot hold a static segment alongside the address wildcard
+	switch params.ByName("address") {
+	case "publish":
+		g.publishABI(res, req, params)
+		return
+	case "subscriptions":
+		g.addABISubscriptions(res, req, params)
+		return
+	}
 	log.Infof("--> %s %s", req.Method, req.URL)
 
 	addrHexNo0x := strings.ToLower(strings.TrimPrefix(params.ByName("address"), "0x"))
@@ -832,12 +1339,25 @@ func (g *smartContractGW) registerContract(res http.ResponseWriter, req *http.Re
 	if registeredName == "" {
 		registeredName = addrHexNo0x
 	}
+	labels, err := contractregistry.ParseLabels(getFlyParamMulti("label", req))
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 400)
+		return
+	}
+	if registerAs != "" {
+		if err := g.cs.CheckNameAvailable(registerAs); err != nil {
+			g.gatewayErrReply(res, req, err, 409)
+			return
+		}
+	}
 
-	contractInfo, err := g.cs.AddContract(addrHexNo0x, abiID, registeredName, registerAs)
+	contractInfo, err := g.cs.AddContract(addrHexNo0x, abiID, registeredName, registerAs, labels)
 	if err != nil {
 		g.gatewayErrReply(res, req, err, 409)
 		return
 	}
+	contractInfo = g.resolveProxy(req.Context(), contractInfo)
+	g.notifier.notify(req.Context(), RegistryEventContractRegistered, nil, contractInfo)
 
 	status := 201
 	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
@@ -846,6 +1366,195 @@ func (g *smartContractGW) registerContract(res http.ResponseWriter, req *http.Re
 	_ = json.NewEncoder(res).Encode(&contractInfo)
 }
 
+// publishABI pushes a local ABI to the gateway collection of the remote registry
+func (g *smartContractGW) publishABI(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	abiID := params.ByName("abi")
+	if _, err := g.cs.GetLocalABIInfo(abiID); err != nil {
+		g.gatewayErrReply(res, req, err, 404)
+		return
+	}
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil && err != io.EOF {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewayPublishABIInvalid, err), 400)
+		return
+	}
+	name, err := g.cs.PublishABI(abiID, body.Name)
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 500)
+		return
+	}
+
+	status := 200
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	_ = json.NewEncoder(res).Encode(map[string]string{
+		"id":   abiID,
+		"name": name,
+		"path": "/gateways/" + url.PathEscape(name),
+	})
+}
+
+// resolveLocalContract finds the address of a contract instance in the local registry, by address
+// or registered name. Only local registrations can be changed, so peers are never queried. When
+// found by name, only the address and name are filled in on the returned registration.
+func (g *smartContractGW) resolveLocalContract(addrOrName string) (string, *contractregistry.ContractInfo, error) {
+	resolver := g.cs.LocalResolver()
+	addrHexNo0x := strings.TrimPrefix(strings.ToLower(addrOrName), "0x")
+	info, err := resolver.GetContractByAddress(addrHexNo0x)
+	if err != nil {
+		if addrHexNo0x, err = resolver.ResolveContractAddress(addrOrName); err != nil {
+			return "", nil, err
+		}
+		info = &contractregistry.ContractInfo{Address: addrHexNo0x, RegisteredAs: addrOrName}
+	}
+	return addrHexNo0x, info, nil
+}
+
+// renameContract changes the registered name of a contract instance, by address or registered name
+func (g *smartContractGW) renameContract(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	addrHexNo0x, previous, err := g.resolveLocalContract(params.ByName("address"))
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 404)
+		return
+	}
+	var update struct {
+		RegisteredAs *string `json:"registeredAs"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&update); err != nil {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewayContractUpdateInvalid, err), 400)
+		return
+	}
+	if update.RegisteredAs == nil {
+		g.gatewayErrReply(res, req, errors.Errorf(errors.RESTGatewayContractUpdateInvalid, "missing 'registeredAs'"), 400)
+		return
+	}
+	contractInfo, err := g.cs.RenameContract(addrHexNo0x, *update.RegisteredAs)
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 409)
+		return
+	}
+	g.notifier.notifyReregistered(req.Context(), contractInfo, previous.RegisteredAs)
+
+	status := 200
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	_ = json.NewEncoder(res).Encode(&contractInfo)
+}
+
+// deleteContract removes a contract instance registration, by address or registered name
+func (g *smartContractGW) deleteContract(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	addrHexNo0x, info, err := g.resolveLocalContract(params.ByName("address"))
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 404)
+		return
+	}
+	if err := g.cs.DeleteContract(addrHexNo0x); err != nil {
+		g.gatewayErrReply(res, req, err, 500)
+		return
+	}
+	g.notifier.notify(req.Context(), RegistryEventContractUnregistered, nil, info)
+
+	status := 204
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.WriteHeader(status)
+}
+
+// deleteABI removes an uploaded ABI, which must not have any contract instances registered against it
+func (g *smartContractGW) deleteABI(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	abiID := params.ByName("abi")
+	info, err := g.cs.GetLocalABIInfo(abiID)
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 404)
+		return
+	}
+	if err := g.cs.DeleteABI(abiID); err != nil {
+		g.gatewayErrReply(res, req, err, 409)
+		return
+	}
+	g.notifier.notify(req.Context(), RegistryEventABIRemoved, info, nil)
+
+	status := 204
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.WriteHeader(status)
+}
+
+// reindexContractStore imports ABI and contract files copied into the storage path while running,
+// and rebuilds the in-memory listings so they are visible without a restart
+func (g *smartContractGW) reindexContractStore(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	result, err := g.cs.Reindex()
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 500)
+		return
+	}
+
+	status := 200
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	_ = json.NewEncoder(res).Encode(result)
+}
+
+// exportContractStore downloads an archive of every ABI, contract instance and registered name,
+// for import into another gateway
+func (g *smartContractGW) exportContractStore(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	archive, err := g.cs.Export()
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 500)
+		return
+	}
+
+	status := 200
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Content-Disposition", "attachment; filename=\"contractstore.json\"")
+	res.WriteHeader(status)
+	_ = json.NewEncoder(res).Encode(archive)
+}
+
+// importContractStore loads an archive produced by an export, replacing differing entries only
+// when the overwrite parameter is set
+func (g *smartContractGW) importContractStore(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	var archive contractregistry.ContractStoreArchive
+	if err := json.NewDecoder(http.MaxBytesReader(res, req.Body, maxImportArchiveSize)).Decode(&archive); err != nil {
+		status := 400
+		var tooLarge *http.MaxBytesError
+		if goerrors.As(err, &tooLarge) {
+			status = 413
+		}
+		g.gatewayErrReply(res, req, errors.Errorf(errors.ContractStoreArchiveInvalid, err), status)
+		return
+	}
+	result, err := g.cs.Import(&archive, getFlyParamBool("overwrite", req))
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 409)
+		return
+	}
+
+	status := 200
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	_ = json.NewEncoder(res).Encode(result)
+}
+
 func tempdir() string {
 	dir, _ := ioutil.TempDir("", "fly")
 	log.Infof("tmpdir/create: %s", dir)
@@ -906,8 +1615,20 @@ func (g *smartContractGW) addABI(res http.ResponseWriter, req *http.Request, par
 		return
 	}
 
+	labels, err := contractregistry.ParseLabels(getFlyParamMulti("label", req))
+	if err != nil {
+		g.gatewayErrReply(res, req, err, 400)
+		return
+	}
+
+	// Build artifacts from Truffle, Hardhat or Foundry are stored as they are, without compiling
+	var artifacts map[string]*messages.DeployContract
+	if abi == nil && bytecode == nil && len(req.Form["source"]) == 0 && !hasSolidityFiles(tempdir) {
+		artifacts = findUploadedArtifacts(tempdir)
+	}
+
 	var preCompiled map[string]*ethbinding.Contract
-	if bytecode == nil {
+	if bytecode == nil && len(artifacts) == 0 {
 		var err error
 		preCompiled, err = g.compileMultipartFormSolidity(tempdir, req)
 		if err != nil {
@@ -917,7 +1638,7 @@ func (g *smartContractGW) addABI(res http.ResponseWriter, req *http.Request, par
 	}
 
 	if vs := req.Form["findcontracts"]; len(vs) > 0 {
-		contractNames := make([]string, 0, len(preCompiled))
+		contractNames := artifactNames(artifacts)
 		for contractName := range preCompiled {
 			contractNames = append(contractNames, contractName)
 		}
@@ -928,11 +1649,23 @@ func (g *smartContractGW) addABI(res http.ResponseWriter, req *http.Request, par
 		return
 	}
 
+	// An uploaded ABI can be registered as a version of a name, such as erc20token@1.2.0
+	registerAs := getFlyParam("register", req)
+	if registerAs != "" {
+		if err := g.cs.CheckABIVersionAvailable(registerAs); err != nil {
+			g.gatewayErrReply(res, req, err, 409)
+			return
+		}
+	}
+
 	msg := &messages.DeployContract{}
-	msg.Headers.MsgType = messages.MsgTypeSendTransaction
-	msg.Headers.ID = utils.UUIDv4()
 	var compiled *eth.CompiledSolidity
-	if bytecode == nil && abi == nil {
+	if len(artifacts) > 0 {
+		if msg, err = selectUploadedArtifact(artifacts, req.FormValue("contract")); err != nil {
+			g.gatewayErrReply(res, req, err, 400)
+			return
+		}
+	} else if bytecode == nil && abi == nil {
 		var err error
 		compiled, err = eth.ProcessCompiled(preCompiled, req.FormValue("contract"), false)
 		if err != nil {
@@ -943,12 +1676,27 @@ func (g *smartContractGW) addABI(res http.ResponseWriter, req *http.Request, par
 		msg.ABI = abi
 		msg.Compiled = bytecode
 	}
+	msg.Labels = labels
 
-	info, err := g.storeDeployableABI(msg, compiled)
+	msg.Headers.MsgType = messages.MsgTypeSendTransaction
+	msg.Headers.ID = utils.UUIDv4()
+
+	// Each registered version is its own ABI, so only plain uploads are deduplicated
+	info, err := g.storeDeployableABI(msg, compiled, registerAs == "")
 	if err != nil {
 		g.gatewayErrReply(res, req, err, 500)
 		return
 	}
+	isNew := info.ID == msg.Headers.ID
+	if registerAs != "" {
+		if info, err = g.cs.RegisterABIVersion(info.ID, registerAs); err != nil {
+			g.gatewayErrReply(res, req, err, 409)
+			return
+		}
+	}
+	if isNew {
+		g.notifier.notify(req.Context(), RegistryEventABIUploaded, info, nil)
+	}
 
 	log.Infof("<-- %s %s [%d]", req.Method, req.URL, 200)
 	res.Header().Set("Content-Type", "application/json")
@@ -985,6 +1733,18 @@ func (g *smartContractGW) parseABI(form url.Values) (ethbinding.ABIMarshaling, e
 	return nil, nil
 }
 
+// hasSolidityFiles checks for Solidity source in the root of the extracted upload, which is
+// compiled in preference to any build artifacts uploaded alongside it
+func hasSolidityFiles(dir string) bool {
+	rootFiles, _ := ioutil.ReadDir(dir)
+	for _, file := range rootFiles {
+		if strings.HasSuffix(file.Name(), ".sol") {
+			return true
+		}
+	}
+	return false
+}
+
 func (g *smartContractGW) compileMultipartFormSolidity(dir string, req *http.Request) (map[string]*ethbinding.Contract, error) {
 	solFiles := []string{}
 	rootFiles, err := ioutil.ReadDir(dir)
@@ -1180,6 +1940,18 @@ func (g *smartContractGW) writeHTMLForUI(prefix, id, from string, isGateway, fac
 	res.Write([]byte(html))
 }
 
+// SetEventDeliveryListener registers a listener for the batches delivered by the event streams
+func (g *smartContractGW) SetEventDeliveryListener(listener events.DeliveryListener) {
+	if g.sm != nil {
+		g.sm.SetDeliveryListener(listener)
+	}
+}
+
+// ContractResolver returns the registry used to find the ABIs of contract instances
+func (g *smartContractGW) ContractResolver() contractregistry.ContractResolver {
+	return g.cs
+}
+
 // Shutdown performs a clean shutdown
 func (g *smartContractGW) Shutdown() {
 	if g.sm != nil {
@@ -1188,22 +1960,23 @@ func (g *smartContractGW) Shutdown() {
 	if g.cs != nil {
 		g.cs.Close()
 	}
+	g.notifier.close()
 }
 
-func (g *smartContractGW) resolveAddressOrName(id string) (deployMsg *messages.DeployContract, registeredName string, info *contractregistry.ContractInfo, err error) {
-	info, err = g.cs.GetContractByAddress(id)
+func (g *smartContractGW) resolveAddressOrName(resolver contractregistry.ContractResolver, id string) (deployMsg *messages.DeployContract, registeredName string, info *contractregistry.ContractInfo, err error) {
+	info, err = resolver.GetContractByAddress(id)
 	if err != nil {
 		var origErr = err
 		registeredName = id
-		if id, err = g.cs.ResolveContractAddress(registeredName); err != nil {
+		if id, err = resolver.ResolveContractAddress(registeredName); err != nil {
 			log.Infof("%s is not a friendly name: %s", registeredName, err)
 			return nil, "", nil, origErr
 		}
-		if info, err = g.cs.GetContractByAddress(id); err != nil {
+		if info, err = resolver.GetContractByAddress(id); err != nil {
 			return nil, "", nil, err
 		}
 	}
-	result, err := g.cs.GetABI(contractregistry.ABILocation{
+	result, err := resolver.GetABI(contractregistry.ABILocation{
 		ABIType: contractregistry.LocalABI,
 		Name:    info.ABI,
 	}, false)
@@ -24,8 +24,10 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path"
+	"strings"
 	"testing"
 	"time"
 
@@ -44,6 +46,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 type mockWebSocketServer struct {
@@ -892,6 +895,387 @@ func TestGetContractOrABIFail(t *testing.T) {
 	mcs.AssertExpectations(t)
 }
 
+func TestGetContractPeerLookupLocalOnly(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+
+	s, _ := NewSmartContractGateway(
+		&SmartContractGatewayConf{
+			StoragePath: dir,
+		},
+		&tx.TxnProcessorConf{},
+		nil, nil, nil, nil,
+	)
+	mcs := &contractregistrymocks.ContractStore{}
+	local := &contractregistrymocks.ContractStore{}
+	scgw := s.(*smartContractGW)
+	scgw.cs = mcs
+
+	// Lookups from a peer must not be forwarded on to other peers
+	mcs.On("LocalResolver").Return(local).Once()
+	local.On("GetContractByAddress", "nonexistent").Return(nil, fmt.Errorf("pop")).Once()
+	local.On("ResolveContractAddress", "nonexistent").Return("", fmt.Errorf("pop")).Once()
+	req := httptest.NewRequest("GET", "/contracts/nonexistent", bytes.NewReader([]byte{}))
+	req.Header.Set(contractregistry.PeerLookupHeader, "true")
+	res := httptest.NewRecorder()
+	router := &httprouter.Router{}
+	scgw.AddRoutes(router)
+	router.ServeHTTP(res, req)
+	assert.Equal(404, res.Result().StatusCode)
+
+	mcs.AssertExpectations(t)
+	local.AssertExpectations(t)
+}
+
+func newTestDeleteGW(t *testing.T, dir string) (*smartContractGW, *contractregistrymocks.ContractStore, *contractregistrymocks.ContractStore, *httprouter.Router) {
+	s, _ := NewSmartContractGateway(
+		&SmartContractGatewayConf{
+			StoragePath: dir,
+		},
+		&tx.TxnProcessorConf{},
+		nil, nil, nil, nil,
+	)
+	mcs := &contractregistrymocks.ContractStore{}
+	local := &contractregistrymocks.ContractStore{}
+	mcs.On("LocalResolver").Return(local).Maybe()
+	scgw := s.(*smartContractGW)
+	scgw.cs = mcs
+	router := &httprouter.Router{}
+	scgw.AddRoutes(router)
+	return scgw, mcs, local, router
+}
+
+func TestDeleteContract(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	_, mcs, local, router := newTestDeleteGW(t, dir)
+
+	local.On("GetContractByAddress", "0123456789abcdef0123456789abcdef01234567").Return(&contractregistry.ContractInfo{}, nil).Once()
+	mcs.On("DeleteContract", "0123456789abcdef0123456789abcdef01234567").Return(nil).Once()
+	req := httptest.NewRequest("DELETE", "/contracts/0x0123456789ABCDEF0123456789ABCDEF01234567", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(204, res.Result().StatusCode)
+
+	// By registered name
+	local.On("GetContractByAddress", "token").Return(nil, fmt.Errorf("pop")).Once()
+	local.On("ResolveContractAddress", "token").Return("0123456789abcdef0123456789abcdef01234567", nil).Once()
+	mcs.On("DeleteContract", "0123456789abcdef0123456789abcdef01234567").Return(nil).Once()
+	req = httptest.NewRequest("DELETE", "/contracts/token", nil)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(204, res.Result().StatusCode)
+
+	mcs.AssertExpectations(t)
+	local.AssertExpectations(t)
+}
+
+func TestDeleteContractFail(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	_, mcs, local, router := newTestDeleteGW(t, dir)
+
+	local.On("GetContractByAddress", "unknown").Return(nil, fmt.Errorf("pop")).Once()
+	local.On("ResolveContractAddress", "unknown").Return("", fmt.Errorf("pop")).Once()
+	req := httptest.NewRequest("DELETE", "/contracts/unknown", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(404, res.Result().StatusCode)
+
+	local.On("GetContractByAddress", "0123456789abcdef0123456789abcdef01234567").Return(&contractregistry.ContractInfo{}, nil).Once()
+	mcs.On("DeleteContract", "0123456789abcdef0123456789abcdef01234567").Return(fmt.Errorf("pop")).Once()
+	req = httptest.NewRequest("DELETE", "/contracts/0123456789abcdef0123456789abcdef01234567", nil)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(500, res.Result().StatusCode)
+
+	mcs.AssertExpectations(t)
+	local.AssertExpectations(t)
+}
+
+func TestRenameContract(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	_, mcs, local, router := newTestDeleteGW(t, dir)
+
+	local.On("GetContractByAddress", "token").Return(nil, fmt.Errorf("pop")).Once()
+	local.On("ResolveContractAddress", "token").Return("0123456789abcdef0123456789abcdef01234567", nil).Once()
+	mcs.On("RenameContract", "0123456789abcdef0123456789abcdef01234567", "token2").Return(&contractregistry.ContractInfo{
+		Address:      "0123456789abcdef0123456789abcdef01234567",
+		RegisteredAs: "token2",
+	}, nil).Once()
+	req := httptest.NewRequest("PATCH", "/contracts/token", bytes.NewReader([]byte(`{"registeredAs":"token2"}`)))
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Result().StatusCode)
+	var info contractregistry.ContractInfo
+	json.NewDecoder(res.Body).Decode(&info)
+	assert.Equal("token2", info.RegisteredAs)
+
+	// An empty name removes the registered name
+	local.On("GetContractByAddress", "0123456789abcdef0123456789abcdef01234567").Return(&contractregistry.ContractInfo{}, nil).Once()
+	mcs.On("RenameContract", "0123456789abcdef0123456789abcdef01234567", "").Return(&contractregistry.ContractInfo{}, nil).Once()
+	req = httptest.NewRequest("PATCH", "/contracts/0x0123456789abcdef0123456789abcdef01234567", bytes.NewReader([]byte(`{"registeredAs":""}`)))
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Result().StatusCode)
+
+	mcs.AssertExpectations(t)
+	local.AssertExpectations(t)
+}
+
+func TestRenameContractFail(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	_, mcs, local, router := newTestDeleteGW(t, dir)
+
+	local.On("GetContractByAddress", "unknown").Return(nil, fmt.Errorf("pop")).Once()
+	local.On("ResolveContractAddress", "unknown").Return("", fmt.Errorf("pop")).Once()
+	req := httptest.NewRequest("PATCH", "/contracts/unknown", bytes.NewReader([]byte(`{"registeredAs":"token2"}`)))
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(404, res.Result().StatusCode)
+
+	local.On("GetContractByAddress", "0123456789abcdef0123456789abcdef01234567").Return(&contractregistry.ContractInfo{}, nil)
+	req = httptest.NewRequest("PATCH", "/contracts/0123456789abcdef0123456789abcdef01234567", bytes.NewReader([]byte(`!bad json`)))
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(400, res.Result().StatusCode)
+
+	req = httptest.NewRequest("PATCH", "/contracts/0123456789abcdef0123456789abcdef01234567", bytes.NewReader([]byte(`{}`)))
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(400, res.Result().StatusCode)
+	var resBody errors.RESTError
+	json.NewDecoder(res.Body).Decode(&resBody)
+	assert.Regexp("missing 'registeredAs'", resBody.Message)
+
+	mcs.On("RenameContract", "0123456789abcdef0123456789abcdef01234567", "other").Return(nil, fmt.Errorf("clash")).Once()
+	req = httptest.NewRequest("PATCH", "/contracts/0123456789abcdef0123456789abcdef01234567", bytes.NewReader([]byte(`{"registeredAs":"other"}`)))
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(409, res.Result().StatusCode)
+
+	mcs.AssertExpectations(t)
+}
+
+func TestDeleteABI(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	_, mcs, _, router := newTestDeleteGW(t, dir)
+
+	mcs.On("GetLocalABIInfo", "abi1").Return(&contractregistry.ABIInfo{}, nil)
+	mcs.On("DeleteABI", "abi1").Return(nil).Once()
+	req := httptest.NewRequest("DELETE", "/abis/abi1", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(204, res.Result().StatusCode)
+
+	mcs.On("DeleteABI", "abi1").Return(fmt.Errorf("in use")).Once()
+	req = httptest.NewRequest("DELETE", "/abis/abi1", nil)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(409, res.Result().StatusCode)
+
+	mcs.On("GetLocalABIInfo", "abi2").Return(nil, fmt.Errorf("pop"))
+	req = httptest.NewRequest("DELETE", "/abis/abi2", nil)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(404, res.Result().StatusCode)
+
+	mcs.AssertExpectations(t)
+}
+
+func TestReindexContractStore(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	_, mcs, _, router := newTestDeleteGW(t, dir)
+
+	mcs.On("Reindex").Return(&contractregistry.ReindexResult{MigratedABIs: 1, ABIs: 3}, nil).Once()
+	req := httptest.NewRequest("POST", "/admin/contractstore/reindex", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Result().StatusCode)
+	var result contractregistry.ReindexResult
+	err := json.NewDecoder(res.Body).Decode(&result)
+	assert.NoError(err)
+	assert.Equal(1, result.MigratedABIs)
+	assert.Equal(3, result.ABIs)
+
+	mcs.On("Reindex").Return(nil, fmt.Errorf("pop")).Once()
+	req = httptest.NewRequest("POST", "/admin/contractstore/reindex", nil)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(500, res.Result().StatusCode)
+
+	mcs.AssertExpectations(t)
+}
+
+func TestExportContractStore(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	_, mcs, _, router := newTestDeleteGW(t, dir)
+
+	mcs.On("Export").Return(&contractregistry.ContractStoreArchive{
+		Version:   contractregistry.ContractStoreArchiveVersion,
+		Contracts: []*contractregistry.ContractInfo{{Address: "0123456789abcdef0123456789abcdef01234567"}},
+	}, nil).Once()
+	req := httptest.NewRequest("GET", "/admin/contractstore/export", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Result().StatusCode)
+	assert.Equal("attachment; filename=\"contractstore.json\"", res.Result().Header.Get("Content-Disposition"))
+	var archive contractregistry.ContractStoreArchive
+	err := json.NewDecoder(res.Body).Decode(&archive)
+	assert.NoError(err)
+	assert.Len(archive.Contracts, 1)
+
+	mcs.On("Export").Return(nil, fmt.Errorf("pop")).Once()
+	req = httptest.NewRequest("GET", "/admin/contractstore/export", nil)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(500, res.Result().StatusCode)
+
+	mcs.AssertExpectations(t)
+}
+
+func TestImportContractStore(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	_, mcs, _, router := newTestDeleteGW(t, dir)
+
+	isArchive := mock.MatchedBy(func(a *contractregistry.ContractStoreArchive) bool { return a.Version == 1 })
+	mcs.On("Import", isArchive, false).Return(&contractregistry.ImportResult{ABIs: 2, Unchanged: 1}, nil).Once()
+	req := httptest.NewRequest("POST", "/admin/contractstore/import", bytes.NewReader([]byte(`{"version":1}`)))
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Result().StatusCode)
+	var result contractregistry.ImportResult
+	err := json.NewDecoder(res.Body).Decode(&result)
+	assert.NoError(err)
+	assert.Equal(2, result.ABIs)
+	assert.Equal(1, result.Unchanged)
+
+	mcs.On("Import", isArchive, true).Return(nil, fmt.Errorf("pop")).Once()
+	req = httptest.NewRequest("POST", "/admin/contractstore/import?fly-overwrite", bytes.NewReader([]byte(`{"version":1}`)))
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(409, res.Result().StatusCode)
+
+	req = httptest.NewRequest("POST", "/admin/contractstore/import", bytes.NewReader([]byte(`!json`)))
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(400, res.Result().StatusCode)
+	var errBody map[string]string
+	json.NewDecoder(res.Body).Decode(&errBody)
+	assert.Equal(errors.ContractStoreArchiveInvalid.Code(), errBody["code"])
+
+	defer func(size int64) { maxImportArchiveSize = size }(maxImportArchiveSize)
+	maxImportArchiveSize = 8
+	req = httptest.NewRequest("POST", "/admin/contractstore/import", bytes.NewReader([]byte(`{"version":1}`)))
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(413, res.Result().StatusCode)
+
+	mcs.AssertExpectations(t)
+}
+
+func TestContractStoreAdminUnauthorized(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	_, mcs, _, router := newTestDeleteGW(t, dir)
+
+	auth.RegisterSecurityModule(&authtest.TestSecurityModule{})
+	defer auth.RegisterSecurityModule(nil)
+
+	for _, r := range []struct{ method, path string }{
+		{"POST", "/admin/contractstore/reindex"},
+		{"GET", "/admin/contractstore/export"},
+		{"POST", "/admin/contractstore/import"},
+	} {
+		req := httptest.NewRequest(r.method, r.path, bytes.NewReader([]byte(`{"version":1}`)))
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		assert.Equal(401, res.Result().StatusCode, r.path)
+	}
+
+	mcs.AssertExpectations(t)
+}
+
+func TestPublishABI(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	_, mcs, _, router := newTestDeleteGW(t, dir)
+
+	mcs.On("GetLocalABIInfo", "abi1").Return(&contractregistry.ABIInfo{}, nil)
+	mcs.On("PublishABI", "abi1", "").Return("simple storage", nil).Once()
+	req := httptest.NewRequest("POST", "/abis/abi1/publish", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Result().StatusCode)
+	var published map[string]string
+	json.NewDecoder(res.Body).Decode(&published)
+	assert.Equal(map[string]string{
+		"id":   "abi1",
+		"name": "simple storage",
+		"path": "/gateways/simple%20storage",
+	}, published)
+
+	mcs.On("PublishABI", "abi1", "other").Return("", fmt.Errorf("pop")).Once()
+	req = httptest.NewRequest("POST", "/abis/abi1/publish", bytes.NewReader([]byte(`{"name":"other"}`)))
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(500, res.Result().StatusCode)
+
+	req = httptest.NewRequest("POST", "/abis/abi1/publish", bytes.NewReader([]byte(`!json`)))
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(400, res.Result().StatusCode)
+
+	mcs.On("GetLocalABIInfo", "abi2").Return(nil, fmt.Errorf("pop"))
+	req = httptest.NewRequest("POST", "/abis/abi2/publish", nil)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(404, res.Result().StatusCode)
+
+	mcs.AssertExpectations(t)
+}
+
+func TestHeadABI(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	_, mcs, _, router := newTestDeleteGW(t, dir)
+
+	mcs.On("GetLocalABIInfo", "abi1").Return(&contractregistry.ABIInfo{ID: "abi1"}, nil)
+	mcs.On("GetABI", contractregistry.ABILocation{ABIType: contractregistry.LocalABI, Name: "abi1"}, false).
+		Return(&contractregistry.DeployContractWithAddress{Contract: &messages.DeployContract{}}, nil)
+	req := httptest.NewRequest("HEAD", "/abis/abi1", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Result().StatusCode)
+	assert.Equal("application/json", res.Result().Header.Get("Content-Type"))
+
+	mcs.On("GetLocalABIInfo", "abi2").Return(nil, fmt.Errorf("pop"))
+	req = httptest.NewRequest("HEAD", "/abis/abi2", nil)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(404, res.Result().StatusCode)
+
+	mcs.AssertExpectations(t)
+}
+
 func TestGetContractUI(t *testing.T) {
 	assert := assert.New(t)
 	dir := tempdir()
@@ -1390,7 +1774,7 @@ func TestStoreDeployableABIMissingABI(t *testing.T) {
 	)
 	scgw := s.(*smartContractGW)
 
-	_, err := scgw.storeDeployableABI(&messages.DeployContract{}, nil)
+	_, err := scgw.storeDeployableABI(&messages.DeployContract{}, nil, false)
 	assert.Regexp("Must supply ABI to install an existing ABI into the REST Gateway", err)
 }
 
@@ -1412,6 +1796,234 @@ func testGWPathBody(method, path string, results interface{}, sm *mockSubMgr, bo
 	return
 }
 
+func TestListContractsETag(t *testing.T) {
+	assert := assert.New(t)
+
+	mcs := &contractregistrymocks.ContractStore{}
+	listing := &contractregistry.CachedListing{ETag: `"abc"`, JSON: []byte("[]\n")}
+	mcs.On("CachedContractListing").Return(listing, nil)
+	mcs.On("CachedABIListing").Return(nil, fmt.Errorf("pop"))
+	s := &smartContractGW{cs: mcs}
+	router := &httprouter.Router{}
+	s.AddRoutes(router)
+
+	req := httptest.NewRequest("GET", "/contracts", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Code)
+	assert.Equal(`"abc"`, res.Header().Get("ETag"))
+	assert.Equal("[]\n", res.Body.String())
+
+	req = httptest.NewRequest("GET", "/contracts", nil)
+	req.Header.Set("If-None-Match", `"xyz", W/"abc"`)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(304, res.Code)
+	assert.Empty(res.Body.String())
+
+	req = httptest.NewRequest("GET", "/contracts", nil)
+	req.Header.Set("If-None-Match", `"xyz"`)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Code)
+
+	req = httptest.NewRequest("GET", "/abis", nil)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(500, res.Code)
+
+	mcs.AssertExpectations(t)
+}
+
+func TestListContractsFiltered(t *testing.T) {
+	assert := assert.New(t)
+
+	mcs := &contractregistrymocks.ContractStore{}
+	createdAfter, _ := time.Parse(time.RFC3339, "2022-01-01T00:00:00Z")
+	mcs.On("ListContracts", &contractregistry.ListingFilter{
+		Limit:        10,
+		Skip:         20,
+		CreatedAfter: createdAfter,
+		ABI:          "abi1",
+	}).Return([]messages.TimeSortable{
+		&contractregistry.ContractInfo{Address: "0123456789abcdef0123456789abcdef01234567", ABI: "abi1"},
+	}, nil)
+	mcs.On("ListABIs", &contractregistry.ListingFilter{Name: "Token"}).Return([]messages.TimeSortable{}, nil)
+	mcs.On("ListABIs", &contractregistry.ListingFilter{
+		CreatedAfter: time.Unix(0, 1640995200000*int64(time.Millisecond)),
+	}).Return(nil, fmt.Errorf("pop"))
+	s := &smartContractGW{cs: mcs}
+	router := &httprouter.Router{}
+	s.AddRoutes(router)
+
+	req := httptest.NewRequest("GET", "/contracts?limit=10&skip=20&createdAfter=2022-01-01T00:00:00Z&abi=abi1", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Code)
+	assert.Empty(res.Header().Get("ETag"))
+	var contracts []*contractregistry.ContractInfo
+	json.NewDecoder(res.Body).Decode(&contracts)
+	assert.Len(contracts, 1)
+	assert.Equal("abi1", contracts[0].ABI)
+
+	req = httptest.NewRequest("GET", "/abis?name=Token", nil)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Code)
+	assert.Equal("[]\n", res.Body.String())
+
+	req = httptest.NewRequest("GET", "/abis?createdAfter=1640995200000", nil)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(500, res.Code)
+
+	for _, query := range []string{"limit=-1", "skip=abc", "createdAfter=yesterday"} {
+		req = httptest.NewRequest("GET", "/contracts?"+query, nil)
+		res = httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		assert.Equal(400, res.Code)
+		assert.Regexp("FFEC100345", res.Body.String())
+	}
+
+	mcs.AssertExpectations(t)
+}
+
+func TestLabelledContractsAndABIs(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+
+	scgw, _ := NewSmartContractGateway(
+		&SmartContractGatewayConf{
+			StoragePath: dir,
+			BaseURL:     "http://localhost/api/v1",
+		},
+		&tx.TxnProcessorConf{},
+		nil, nil, nil, nil,
+	)
+	router := &httprouter.Router{}
+	scgw.AddRoutes(router)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("abi", `[{"type":"function","name":"get","inputs":[],"outputs":[{"name":"x","type":"uint256"}]}]`)
+	writer.WriteField("bytecode", "0x00")
+	writer.Close()
+	req := httptest.NewRequest("POST", "/abis?fly-label=team=payments", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Code)
+	var abi contractregistry.ABIInfo
+	json.NewDecoder(res.Body).Decode(&abi)
+	assert.Equal(map[string]string{"team": "payments"}, abi.Labels)
+
+	req = httptest.NewRequest("POST", "/abis/"+abi.ID+"/0x0123456789abcdef0123456789abcdef01234567?fly-label=team=payments&fly-label=env=prod", nil)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(201, res.Code)
+	var contract contractregistry.ContractInfo
+	json.NewDecoder(res.Body).Decode(&contract)
+	assert.Equal(map[string]string{"team": "payments", "env": "prod"}, contract.Labels)
+
+	req = httptest.NewRequest("POST", "/abis/"+abi.ID+"/0x1123456789abcdef0123456789abcdef01234567", nil)
+	req.Header.Set("x-firefly-label", "env=dev")
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(201, res.Code)
+
+	for query, expected := range map[string][]string{
+		"label=env=prod":            {"0123456789abcdef0123456789abcdef01234567"},
+		"label=env!=prod":           {"1123456789abcdef0123456789abcdef01234567"},
+		"label=!team":               {"1123456789abcdef0123456789abcdef01234567"},
+		"label=team&label=env==dev": {},
+		"label=env":                 {"1123456789abcdef0123456789abcdef01234567", "0123456789abcdef0123456789abcdef01234567"},
+	} {
+		req = httptest.NewRequest("GET", "/contracts?"+query, nil)
+		res = httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		assert.Equal(200, res.Code)
+		var contracts []*contractregistry.ContractInfo
+		json.NewDecoder(res.Body).Decode(&contracts)
+		addresses := []string{}
+		for _, c := range contracts {
+			addresses = append(addresses, c.Address)
+		}
+		assert.ElementsMatch(expected, addresses, query)
+	}
+
+	req = httptest.NewRequest("GET", "/abis?label=team=payments", nil)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Code)
+	var abis []*contractregistry.ABIInfo
+	json.NewDecoder(res.Body).Decode(&abis)
+	assert.Len(abis, 1)
+
+	req = httptest.NewRequest("GET", "/contracts?label=bad%20key", nil)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(400, res.Code)
+	assert.Regexp("FFEC100345", res.Body.String())
+
+	req = httptest.NewRequest("POST", "/abis/"+abi.ID+"/0x2123456789abcdef0123456789abcdef01234567?fly-label=nokey", nil)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(400, res.Code)
+	assert.Regexp("FFEC100391", res.Body.String())
+
+	body = &bytes.Buffer{}
+	writer = multipart.NewWriter(body)
+	writer.WriteField("abi", `[]`)
+	writer.Close()
+	req = httptest.NewRequest("POST", "/abis?fly-label=team=pay%20ments", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(400, res.Code)
+	assert.Regexp("FFEC100391", res.Body.String())
+}
+
+func TestListContractsBySignature(t *testing.T) {
+	assert := assert.New(t)
+
+	mcs := &contractregistrymocks.ContractStore{}
+	mcs.On("ListContracts", &contractregistry.ListingFilter{
+		Method: "0xa9059cbb",
+	}).Return([]messages.TimeSortable{
+		&contractregistry.ContractInfo{Address: "0123456789abcdef0123456789abcdef01234567", ABI: "abi1"},
+	}, nil)
+	mcs.On("ListABIs", &contractregistry.ListingFilter{
+		Event: "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+	}).Return([]messages.TimeSortable{}, nil)
+	s := &smartContractGW{cs: mcs}
+	router := &httprouter.Router{}
+	s.AddRoutes(router)
+
+	req := httptest.NewRequest("GET", "/contracts?method="+url.QueryEscape("transfer(address,uint256)"), nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Code)
+	var contracts []*contractregistry.ContractInfo
+	json.NewDecoder(res.Body).Decode(&contracts)
+	assert.Len(contracts, 1)
+
+	req = httptest.NewRequest("GET", "/abis?event="+url.QueryEscape("Transfer(address,address,uint256)"), nil)
+	res = httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Code)
+
+	for _, query := range []string{"method=transfer", "event=Transfer(address,address,badtype)"} {
+		req = httptest.NewRequest("GET", "/contracts?"+url.PathEscape(query), nil)
+		res = httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		assert.Equal(400, res.Code)
+		assert.Regexp("FFEC100349", res.Body.String())
+	}
+
+	mcs.AssertExpectations(t)
+}
+
 func TestAddStreamNoSubMgr(t *testing.T) {
 	assert := assert.New(t)
 	res := testGWPath("POST", events.StreamPathPrefix, nil, nil)
@@ -1458,6 +2070,25 @@ func TestAddStreamDefaultNoTimestamps(t *testing.T) {
 	s.Shutdown()
 }
 
+type testDeliveryListener struct{}
+
+func (l *testDeliveryListener) EventsDelivered(streamID string, batchNumber uint64, txHashes []string) {
+}
+
+func TestSetEventDeliveryListener(t *testing.T) {
+	assert := assert.New(t)
+	listener := &testDeliveryListener{}
+
+	// No-op without a subscription manager
+	s := &smartContractGW{}
+	s.SetEventDeliveryListener(listener)
+
+	sm := &mockSubMgr{}
+	s.sm = sm
+	s.SetEventDeliveryListener(listener)
+	assert.Equal(listener, sm.listener)
+}
+
 func TestAddStreamBadData(t *testing.T) {
 	assert := assert.New(t)
 	req := httptest.NewRequest("POST", events.StreamPathPrefix, bytes.NewReader([]byte(":bad json")))
@@ -1708,6 +2339,84 @@ func TestAddSub(t *testing.T) {
 
 }
 
+func TestAddScheduledQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	mockSubMgr := &mockSubMgr{}
+	var resBody events.ScheduledQueryInfo
+	res := testGWPathBody("POST", events.ScheduledQueryPathPrefix, &resBody, mockSubMgr, bytes.NewReader([]byte(`
+    {
+      "name": "balance",
+      "stream": "stream1",
+      "schedule": "*/5 * * * *",
+      "address": "0x0123456789abcDEF0123456789abCDef01234567",
+      "method": {
+        "name": "balanceOf"
+      },
+      "params": ["0x0123456789abcDEF0123456789abCDef01234567"]
+    }
+  `)))
+	assert.Equal(200, res.Result().StatusCode)
+	assert.Equal("balance", resBody.Name)
+	assert.Equal("*/5 * * * *", mockSubMgr.scheduledQuery.Schedule)
+	assert.Equal("balanceOf", mockSubMgr.scheduledQuery.Method.Name)
+	assert.Equal("stream1", mockSubMgr.scheduledQuery.Stream)
+}
+
+func TestAddScheduledQueryBadBody(t *testing.T) {
+	assert := assert.New(t)
+
+	res := testGWPathBody("POST", events.ScheduledQueryPathPrefix, nil, &mockSubMgr{}, bytes.NewReader([]byte(`!json`)))
+	assert.Equal(400, res.Result().StatusCode)
+}
+
+func TestAddScheduledQueryFail(t *testing.T) {
+	assert := assert.New(t)
+
+	mockSubMgr := &mockSubMgr{err: fmt.Errorf("pop")}
+	res := testGWPathBody("POST", events.ScheduledQueryPathPrefix, nil, mockSubMgr, bytes.NewReader([]byte(`{}`)))
+	assert.Equal(400, res.Result().StatusCode)
+}
+
+func TestAddScheduledQueryNoSubMgr(t *testing.T) {
+	assert := assert.New(t)
+	res := testGWPath("POST", events.ScheduledQueryPathPrefix, nil, nil)
+	assert.Equal(405, res.Result().StatusCode)
+}
+
+func TestListGetDeleteScheduledQueries(t *testing.T) {
+	assert := assert.New(t)
+
+	mockSubMgr := &mockSubMgr{
+		scheduledQuery: &events.ScheduledQueryInfo{ID: "sq-1"},
+		scheduledList: []*events.ScheduledQueryInfo{
+			{
+				TimeSorted: messages.TimeSorted{
+					CreatedISO8601: time.Now().UTC().Format(time.RFC3339),
+				}, ID: "earlier",
+			},
+			{
+				TimeSorted: messages.TimeSorted{
+					CreatedISO8601: time.Now().UTC().Add(1 * time.Hour).Format(time.RFC3339),
+				}, ID: "later",
+			},
+		},
+	}
+	var results []*events.ScheduledQueryInfo
+	res := testGWPath("GET", events.ScheduledQueryPathPrefix, &results, mockSubMgr)
+	assert.Equal(200, res.Result().StatusCode)
+	assert.Equal(2, len(results))
+	assert.Equal("later", results[0].ID)
+
+	var result events.ScheduledQueryInfo
+	res = testGWPath("GET", events.ScheduledQueryPathPrefix+"/sq-1", &result, mockSubMgr)
+	assert.Equal(200, res.Result().StatusCode)
+	assert.Equal("sq-1", result.ID)
+
+	res = testGWPath("DELETE", events.ScheduledQueryPathPrefix+"/sq-1", nil, mockSubMgr)
+	assert.Equal(204, res.Result().StatusCode)
+}
+
 func TestAddSubNoBody(t *testing.T) {
 	assert := assert.New(t)
 
@@ -1751,12 +2460,184 @@ func TestResetSubFail(t *testing.T) {
 	assert.Equal(500, res.Result().StatusCode)
 }
 
+func TestResetSubFromTime(t *testing.T) {
+	assert := assert.New(t)
+
+	b, _ := json.Marshal(map[string]interface{}{
+		"fromTime": "2022-01-03T00:00:00Z",
+	})
+	sm := &mockSubMgr{resolvedBlock: "100"}
+	res := testGWPathBody("POST", events.SubPathPrefix+"/123/reset", nil, sm, bytes.NewReader(b))
+	assert.Equal(204, res.Result().StatusCode)
+	assert.Equal("100", sm.capturedBlock)
+
+	b, _ = json.Marshal(map[string]interface{}{
+		"fromBlock": "0",
+		"fromTime":  "2022-01-03T00:00:00Z",
+	})
+	res = testGWPathBody("POST", events.SubPathPrefix+"/123/reset", nil, &mockSubMgr{}, bytes.NewReader(b))
+	assert.Equal(400, res.Result().StatusCode)
+}
+
 func TestResetSubNoManager(t *testing.T) {
 	assert := assert.New(t)
 	res := testGWPath("POST", events.SubPathPrefix+"/123/reset", nil, nil)
 	assert.Equal(405, res.Result().StatusCode)
 }
 
+func TestExportSubCSVDefault(t *testing.T) {
+	assert := assert.New(t)
+
+	mockSubMgr := &mockSubMgr{
+		sub:          &events.SubscriptionInfo{ID: "123"},
+		exportOutput: "blockNumber\n",
+	}
+	res := testGWPath("GET", events.SubPathPrefix+"/123/export?fromBlock=10&toBlock=20", nil, mockSubMgr)
+	assert.Equal(200, res.Result().StatusCode)
+	assert.Equal("text/csv", res.Result().Header.Get("Content-Type"))
+	assert.Equal(events.ExportFormatCSV, mockSubMgr.capturedExport.Format)
+	assert.Equal("10", mockSubMgr.capturedExport.FromBlock)
+	assert.Equal("20", mockSubMgr.capturedExport.ToBlock)
+}
+
+func TestExportSubJSONEmpty(t *testing.T) {
+	assert := assert.New(t)
+
+	mockSubMgr := &mockSubMgr{
+		sub: &events.SubscriptionInfo{ID: "123"},
+	}
+	res := testGWPath("GET", events.SubPathPrefix+"/123/export?format=json", nil, mockSubMgr)
+	assert.Equal(200, res.Result().StatusCode)
+	assert.Equal("application/x-ndjson", res.Result().Header.Get("Content-Type"))
+	assert.Equal(events.ExportFormatJSON, mockSubMgr.capturedExport.Format)
+}
+
+func TestExportSubFailBeforeOutput(t *testing.T) {
+	assert := assert.New(t)
+
+	mockSubMgr := &mockSubMgr{
+		sub:       &events.SubscriptionInfo{ID: "123"},
+		exportErr: fmt.Errorf("pop"),
+	}
+	var errBody errors.RESTError
+	res := testGWPath("GET", events.SubPathPrefix+"/123/export?format=parquet", &errBody, mockSubMgr)
+	assert.Equal(400, res.Result().StatusCode)
+	assert.Equal("pop", errBody.Message)
+}
+
+func TestExportSubParquet(t *testing.T) {
+	assert := assert.New(t)
+
+	mockSubMgr := &mockSubMgr{
+		sub:          &events.SubscriptionInfo{ID: "123"},
+		exportOutput: "PAR1",
+	}
+	res := testGWPath("GET", events.SubPathPrefix+"/123/export?format=parquet", nil, mockSubMgr)
+	assert.Equal(200, res.Result().StatusCode)
+	assert.Equal("application/vnd.apache.parquet", res.Result().Header.Get("Content-Type"))
+}
+
+func TestExportSubFailAfterOutput(t *testing.T) {
+	assert := assert.New(t)
+
+	mockSubMgr := &mockSubMgr{
+		sub:          &events.SubscriptionInfo{ID: "123"},
+		exportOutput: "blockNumber\n",
+		exportErr:    fmt.Errorf("pop"),
+	}
+	res := testGWPath("GET", events.SubPathPrefix+"/123/export", nil, mockSubMgr)
+	assert.Equal(200, res.Result().StatusCode)
+}
+
+func TestExportSubNotFound(t *testing.T) {
+	assert := assert.New(t)
+
+	mockSubMgr := &mockSubMgr{err: fmt.Errorf("not found")}
+	res := testGWPath("GET", events.SubPathPrefix+"/123/export", nil, mockSubMgr)
+	assert.Equal(404, res.Result().StatusCode)
+}
+
+func TestExportSubNoSubMgr(t *testing.T) {
+	assert := assert.New(t)
+	res := testGWPath("GET", events.SubPathPrefix+"/123/export", nil, nil)
+	assert.Equal(405, res.Result().StatusCode)
+}
+
+func TestStartExportJob(t *testing.T) {
+	assert := assert.New(t)
+
+	mockSubMgr := &mockSubMgr{
+		exportJob: &events.ExportJobInfo{ID: "ej-1", Status: events.ExportJobRunning},
+	}
+	var job events.ExportJobInfo
+	res := testGWPathBody("POST", events.SubPathPrefix+"/123/exports", &job, mockSubMgr, strings.NewReader(`{"fromBlock":"10"}`))
+	assert.Equal(202, res.Result().StatusCode)
+	assert.Equal("ej-1", job.ID)
+	assert.Equal(events.ExportFormatCSV, mockSubMgr.capturedExport.Format)
+	assert.Equal("10", mockSubMgr.capturedExport.FromBlock)
+}
+
+func TestStartExportJobBadBody(t *testing.T) {
+	assert := assert.New(t)
+
+	var errBody errors.RESTError
+	res := testGWPathBody("POST", events.SubPathPrefix+"/123/exports", &errBody, &mockSubMgr{}, strings.NewReader(`!json`))
+	assert.Equal(400, res.Result().StatusCode)
+	assert.Regexp("Invalid export job request", errBody.Message)
+}
+
+func TestStartExportJobFail(t *testing.T) {
+	assert := assert.New(t)
+
+	var errBody errors.RESTError
+	res := testGWPathBody("POST", events.SubPathPrefix+"/123/exports", &errBody, &mockSubMgr{err: fmt.Errorf("pop")}, strings.NewReader(`{}`))
+	assert.Equal(400, res.Result().StatusCode)
+	assert.Equal("pop", errBody.Message)
+}
+
+func TestListExportJobs(t *testing.T) {
+	assert := assert.New(t)
+
+	sm := &mockSubMgr{
+		exportJobs: []*events.ExportJobInfo{
+			{ID: "ej-1", TimeSorted: messages.TimeSorted{CreatedISO8601: "2021-01-01T00:00:00Z"}},
+			{ID: "ej-2", TimeSorted: messages.TimeSorted{CreatedISO8601: "2021-01-02T00:00:00Z"}},
+		},
+	}
+	var jobs []*events.ExportJobInfo
+	res := testGWPath("GET", events.SubPathPrefix+"/123/exports", &jobs, sm)
+	assert.Equal(200, res.Result().StatusCode)
+	assert.Len(jobs, 2)
+	assert.Equal("ej-2", jobs[0].ID)
+
+	res = testGWPath("GET", events.SubPathPrefix+"/123/exports", nil, &mockSubMgr{err: fmt.Errorf("not found")})
+	assert.Equal(404, res.Result().StatusCode)
+}
+
+func TestGetExportJob(t *testing.T) {
+	assert := assert.New(t)
+
+	var job events.ExportJobInfo
+	res := testGWPath("GET", events.SubPathPrefix+"/123/exports/ej-1", &job, &mockSubMgr{
+		exportJob: &events.ExportJobInfo{ID: "ej-1", Status: events.ExportJobCompleted},
+	})
+	assert.Equal(200, res.Result().StatusCode)
+	assert.Equal(events.ExportJobCompleted, job.Status)
+
+	res = testGWPath("GET", events.SubPathPrefix+"/123/exports/ej-1", nil, &mockSubMgr{err: fmt.Errorf("not found")})
+	assert.Equal(404, res.Result().StatusCode)
+}
+
+func TestExportJobsNoSubMgr(t *testing.T) {
+	assert := assert.New(t)
+	res := testGWPathBody("POST", events.SubPathPrefix+"/123/exports", nil, nil, strings.NewReader(`{}`))
+	assert.Equal(405, res.Result().StatusCode)
+	res = testGWPath("GET", events.SubPathPrefix+"/123/exports", nil, nil)
+	assert.Equal(405, res.Result().StatusCode)
+	res = testGWPath("GET", events.SubPathPrefix+"/123/exports/ej-1", nil, nil)
+	assert.Equal(405, res.Result().StatusCode)
+}
+
 func TestDeleteStream(t *testing.T) {
 	assert := assert.New(t)
 
@@ -1791,6 +2672,25 @@ func TestSuspendStream(t *testing.T) {
 	assert.True(mockSubMgr.suspended)
 }
 
+func TestGetStreamHealth(t *testing.T) {
+	assert := assert.New(t)
+
+	var health events.StreamHealth
+	res := testGWPath("GET", events.StreamPathPrefix+"/123/health", &health, &mockSubMgr{
+		health: &events.StreamHealth{ID: "123", Status: events.StreamStatusRetrying},
+	})
+	assert.Equal(200, res.Result().StatusCode)
+	assert.Equal("retrying", health.Status)
+
+	var errInfo = errors.RESTError{}
+	res = testGWPath("GET", events.StreamPathPrefix+"/123/health", &errInfo, &mockSubMgr{err: fmt.Errorf("pop")})
+	assert.Equal(404, res.Result().StatusCode)
+	assert.Equal("pop", errInfo.Message)
+
+	res = testGWPath("GET", events.StreamPathPrefix+"/123/health", nil, nil)
+	assert.Equal(405, res.Result().StatusCode)
+}
+
 func TestResumeStream(t *testing.T) {
 	assert := assert.New(t)
 
@@ -1994,6 +2894,128 @@ func TestPublishPreCompiled(t *testing.T) {
 	assert.NotEmpty(dmsg.Contract.Compiled)
 }
 
+func TestPublishPreCompiledVersions(t *testing.T) {
+	// writes real files and tests end to end
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+
+	scgw, _ := NewSmartContractGateway(
+		&SmartContractGatewayConf{
+			StoragePath: dir,
+			BaseURL:     "http://localhost/api/v1",
+		},
+		&tx.TxnProcessorConf{
+			OrionPrivateAPIS: false,
+		},
+		nil, nil, nil, nil,
+	)
+	router := &httprouter.Router{}
+	scgw.AddRoutes(router)
+
+	b, _ := ioutil.ReadFile(path.Join("..", "..", "test", "simpleevents.solc.output.json"))
+	var contract SolcJson
+	json.Unmarshal(b, &contract)
+
+	publish := func(registerAs string) (int, *contractregistry.ABIInfo) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		fw, _ := writer.CreateFormField("abi")
+		io.Copy(fw, bytes.NewReader([]byte(contract.ABI)))
+		fw, _ = writer.CreateFormField("bytecode")
+		io.Copy(fw, bytes.NewReader([]byte(contract.Bin)))
+		writer.Close()
+		req, _ := http.NewRequest("POST", "/abis?fly-register="+registerAs, bytes.NewReader(body.Bytes()))
+		req.Header.Add("Content-Type", writer.FormDataContentType())
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		var abi contractregistry.ABIInfo
+		json.NewDecoder(res.Body).Decode(&abi)
+		return res.Code, &abi
+	}
+	get := func(ref string) *contractregistry.ABIInfo {
+		req := httptest.NewRequest("GET", "/abis/"+ref, nil)
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		assert.Equal(200, res.Code)
+		var abi contractregistry.ABIInfo
+		json.NewDecoder(res.Body).Decode(&abi)
+		return &abi
+	}
+
+	status, v1 := publish("simpleevents@1.0.0")
+	assert.Equal(200, status)
+	assert.Equal("simpleevents", v1.RegisteredAs)
+	assert.Equal("1.0.0", v1.Version)
+	status, v2 := publish("simpleevents@1.1.0")
+	assert.Equal(200, status)
+	status, _ = publish("simpleevents@1.0.0")
+	assert.Equal(409, status)
+	status, _ = publish("simpleevents")
+	assert.Equal(409, status)
+
+	assert.Equal(v2.ID, get("simpleevents").ID)
+	assert.Equal(v2.ID, get("simpleevents@latest").ID)
+	assert.Equal(v1.ID, get("simpleevents@1.0.0").ID)
+}
+
+func TestAddABIDedupeIdenticalContent(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+
+	scgw, _ := NewSmartContractGateway(
+		&SmartContractGatewayConf{
+			StoragePath: dir,
+			BaseURL:     "http://localhost/api/v1",
+		},
+		&tx.TxnProcessorConf{
+			OrionPrivateAPIS: false,
+		},
+		nil, nil, nil, nil,
+	)
+	router := &httprouter.Router{}
+	scgw.AddRoutes(router)
+
+	b, _ := ioutil.ReadFile(path.Join("..", "..", "test", "simpleevents.solc.output.json"))
+	var contract SolcJson
+	json.Unmarshal(b, &contract)
+
+	publish := func(query string) *contractregistry.ABIInfo {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		fw, _ := writer.CreateFormField("abi")
+		io.Copy(fw, bytes.NewReader([]byte(contract.ABI)))
+		fw, _ = writer.CreateFormField("bytecode")
+		io.Copy(fw, bytes.NewReader([]byte(contract.Bin)))
+		writer.Close()
+		req, _ := http.NewRequest("POST", "/abis"+query, bytes.NewReader(body.Bytes()))
+		req.Header.Add("Content-Type", writer.FormDataContentType())
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		assert.Equal(200, res.Code)
+		var abi contractregistry.ABIInfo
+		json.NewDecoder(res.Body).Decode(&abi)
+		return &abi
+	}
+
+	abi1 := publish("")
+	assert.Regexp("^sha256:", abi1.ContentHash)
+	assert.Equal(abi1.ID, publish("").ID)
+	// A registered version is stored separately
+	abi2 := publish("?fly-register=simpleevents@1.0.0")
+	assert.NotEqual(abi1.ID, abi2.ID)
+	assert.Equal(abi1.ContentHash, abi2.ContentHash)
+
+	req := httptest.NewRequest("GET", "/abis?contentHash="+url.QueryEscape(abi1.ContentHash), nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	assert.Equal(200, res.Code)
+	var abis []*contractregistry.ABIInfo
+	json.NewDecoder(res.Body).Decode(&abis)
+	assert.Len(abis, 2)
+}
+
 func TestResolveAddressFail(t *testing.T) {
 	assert := assert.New(t)
 	dir := tempdir()
@@ -2010,7 +3032,7 @@ func TestResolveAddressFail(t *testing.T) {
 		nil, nil, nil, nil,
 	)
 
-	deployMsg, name, info, err := scgw.(*smartContractGW).resolveAddressOrName("test")
+	deployMsg, name, info, err := scgw.(*smartContractGW).resolveAddressOrName(scgw.(*smartContractGW).cs, "test")
 	assert.Regexp("No contract instance registered with address test", err)
 	assert.Nil(deployMsg)
 	assert.Nil(info)
@@ -2039,9 +3061,109 @@ func TestResolveAddressGetContractFail(t *testing.T) {
 	mcs.On("ResolveContractAddress", "test").Return("address1", nil).Once()
 	mcs.On("GetContractByAddress", "address1").Return(nil, fmt.Errorf("bad address")).Once()
 
-	deployMsg, name, info, err := scgw.(*smartContractGW).resolveAddressOrName("test")
+	deployMsg, name, info, err := scgw.(*smartContractGW).resolveAddressOrName(scgw.(*smartContractGW).cs, "test")
 	assert.Regexp("bad address", err)
 	assert.Nil(deployMsg)
 	assert.Nil(info)
 	assert.Equal("", name)
 }
+
+func TestDecodeReceiptEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	mcs := &contractregistrymocks.ContractStore{}
+	scgw := &smartContractGW{cs: mcs}
+
+	abi := ethbinding.ABIMarshaling{
+		{
+			Type: "event",
+			Name: "Changed",
+			Inputs: []ethbinding.ABIArgumentMarshaling{
+				{Name: "from", Type: "address", Indexed: true},
+				{Name: "value", Type: "uint256"},
+			},
+		},
+	}
+	runtimeABI, err := ethbind.API.ABIMarshalingToABIRuntime(abi)
+	assert.NoError(err)
+	eventID := runtimeABI.Events["Changed"].ID
+	otherID := ethbind.API.HexToHash("0x1111111111111111111111111111111111111111111111111111111111111111")
+	fromTopic := ethbind.API.HexToHash("0x000000000000000000000000aa00000000000000000000000000000000000001")
+
+	knownAddr := ethbind.API.HexToAddress("0x0123456789AbcdeF0123456789abCdef01234567")
+	unknownAddr := ethbind.API.HexToAddress("0x1123456789AbcdeF0123456789abCdef01234567")
+	mcs.On("GetContractByAddress", "0123456789abcdef0123456789abcdef01234567").Return(&contractregistry.ContractInfo{ABI: "abi1"}, nil).Once()
+	mcs.On("GetContractByAddress", "1123456789abcdef0123456789abcdef01234567").Return(nil, fmt.Errorf("not found")).Once()
+	mcs.On("GetABI", contractregistry.ABILocation{ABIType: contractregistry.LocalABI, Name: "abi1"}, false).Return(&contractregistry.DeployContractWithAddress{
+		Contract: &messages.DeployContract{ABI: abi},
+	}, nil).Once()
+
+	receipt := &messages.TransactionReceipt{
+		Logs: []*messages.TransactionLog{
+			{
+				Address:     &knownAddr,
+				Topics:      []*ethbinding.Hash{&eventID, &fromTopic},
+				Data:        "0x000000000000000000000000000000000000000000000000000000000000002a",
+				LogIndexStr: "0",
+			},
+			{
+				Address:     &knownAddr,
+				Topics:      []*ethbinding.Hash{&otherID},
+				Data:        "0x",
+				LogIndexStr: "1",
+			},
+			{
+				Address:     &knownAddr,
+				Topics:      []*ethbinding.Hash{&eventID},
+				Data:        "0x",
+				LogIndexStr: "2",
+			},
+			{
+				Address:     &unknownAddr,
+				Topics:      []*ethbinding.Hash{&eventID, &fromTopic},
+				Data:        "0x",
+				LogIndexStr: "3",
+			},
+			{
+				Address:     &knownAddr,
+				LogIndexStr: "4",
+			},
+		},
+	}
+
+	decoded := scgw.DecodeReceiptEvents(receipt)
+	assert.Len(decoded, 1)
+	assert.Equal("Changed", decoded[0].Event)
+	assert.Equal("Changed(address,uint256)", decoded[0].Signature)
+	assert.Equal("0", decoded[0].LogIndex)
+	assert.Equal(&knownAddr, decoded[0].Address)
+	assert.Equal("0xAA00000000000000000000000000000000000001", decoded[0].Data["from"].(ethbinding.Address).Hex())
+	assert.Equal("42", decoded[0].Data["value"])
+
+	mcs.AssertExpectations(t)
+}
+
+func TestDecodeReceiptEventsABIFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	mcs := &contractregistrymocks.ContractStore{}
+	scgw := &smartContractGW{cs: mcs}
+
+	addr1 := ethbind.API.HexToAddress("0x0123456789AbcdeF0123456789abCdef01234567")
+	addr2 := ethbind.API.HexToAddress("0x1123456789AbcdeF0123456789abCdef01234567")
+	topic := ethbind.API.HexToHash("0x1111111111111111111111111111111111111111111111111111111111111111")
+	mcs.On("GetContractByAddress", "0123456789abcdef0123456789abcdef01234567").Return(&contractregistry.ContractInfo{ABI: "abi1"}, nil)
+	mcs.On("GetContractByAddress", "1123456789abcdef0123456789abcdef01234567").Return(&contractregistry.ContractInfo{ABI: "abi2"}, nil)
+	mcs.On("GetABI", contractregistry.ABILocation{ABIType: contractregistry.LocalABI, Name: "abi1"}, false).Return(nil, fmt.Errorf("pop"))
+	mcs.On("GetABI", contractregistry.ABILocation{ABIType: contractregistry.LocalABI, Name: "abi2"}, false).Return(&contractregistry.DeployContractWithAddress{
+		Contract: &messages.DeployContract{ABI: ethbinding.ABIMarshaling{{Type: "event", Name: "Bad", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "badness"}}}}},
+	}, nil)
+
+	decoded := scgw.DecodeReceiptEvents(&messages.TransactionReceipt{
+		Logs: []*messages.TransactionLog{
+			{Address: &addr1, Topics: []*ethbinding.Hash{&topic}},
+			{Address: &addr2, Topics: []*ethbinding.Hash{&topic}},
+		},
+	})
+	assert.Empty(decoded)
+}
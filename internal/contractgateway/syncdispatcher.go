@@ -90,6 +90,7 @@ func (t *syncTxInflight) Reply(replyMessage messages.ReplyWithHeaders) {
 	replyHeaders := replyMessage.ReplyHeaders()
 	replyHeaders.ID = utils.UUIDv4()
 	replyHeaders.Context = headers.Context
+	replyHeaders.Namespace = headers.Namespace
 	replyHeaders.ReqID = headers.ID
 	replyHeaders.ReqABIID = headers.ABIID
 	replyHeaders.Received = t.timeReceived.UTC().Format(time.RFC3339Nano)
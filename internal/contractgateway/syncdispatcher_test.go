@@ -61,6 +61,9 @@ func (p *mockProcessor) OnMessage(c tx.TxnContext) {
 func (p *mockProcessor) Init(eth.RPCClient) {}
 func (p *mockProcessor) SetReceiptStoreForIdempotencyCheck(receiptStore receipts.ReceiptStorePersistence) {
 }
+func (p *mockProcessor) CancelQueued(msgID string) error { return nil }
+func (p *mockProcessor) SigningStats() *tx.SigningStats  { return nil }
+func (p *mockProcessor) Close()                          {}
 
 type mockReplyProcessor struct {
 	err     error
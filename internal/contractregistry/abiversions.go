@@ -0,0 +1,182 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	ethconnecterrors "github.com/hyperledger/firefly-ethconnect/internal/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// LatestABIVersion selects the highest registered version of a named ABI
+const LatestABIVersion = "latest"
+
+var abiVersionCheck = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+)*(-[0-9A-Za-z.-]+)?$`)
+
+// ParseABIVersionedName splits a name@version reference to an ABI. The version is
+// empty if none is supplied.
+func ParseABIVersionedName(nameAtVersion string) (name, version string) {
+	if i := strings.LastIndex(nameAtVersion, "@"); i >= 0 {
+		return nameAtVersion[:i], nameAtVersion[i+1:]
+	}
+	return nameAtVersion, ""
+}
+
+func parseABIRegistration(registerAs string) (name, version string, err error) {
+	name, version = ParseABIVersionedName(registerAs)
+	if name == "" || strings.ContainsAny(name, "/@") || !abiVersionCheck.MatchString(version) {
+		return "", "", ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayABIVersionInvalid, registerAs)
+	}
+	return name, version, nil
+}
+
+func splitABIVersion(version string) (nums []uint64, preRelease string) {
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.Index(version, "-"); i >= 0 {
+		version, preRelease = version[:i], version[i+1:]
+	}
+	parts := strings.Split(version, ".")
+	nums = make([]uint64, len(parts))
+	for i, p := range parts {
+		nums[i], _ = strconv.ParseUint(p, 10, 64)
+	}
+	return nums, preRelease
+}
+
+// compareABIVersions orders versions by their dot separated numbers, with a release
+// ordered after its pre-releases, so 1.10.0 > 1.2.0 > 1.2.0-rc1
+func compareABIVersions(a, b string) int {
+	aNums, aPre := splitABIVersion(a)
+	bNums, bPre := splitABIVersion(b)
+	for i := 0; i < len(aNums) || i < len(bNums); i++ {
+		var an, bn uint64
+		if i < len(aNums) {
+			an = aNums[i]
+		}
+		if i < len(bNums) {
+			bn = bNums[i]
+		}
+		if an != bn {
+			if an < bn {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+	return strings.Compare(aPre, bPre)
+}
+
+// findABIVersion returns the ABI registered under the name at the version, or the highest
+// version of the name if the version is empty or latest. Returns nil if there is no match.
+func (cs *contractStore) findABIVersion(name, version string) (*ABIInfo, error) {
+	items, err := cs.abiListing.list()
+	if err != nil {
+		return nil, err
+	}
+	var found *ABIInfo
+	for _, item := range items {
+		info := item.(*ABIInfo)
+		if info.RegisteredAs == "" || info.RegisteredAs != name {
+			continue
+		}
+		if version == "" || version == LatestABIVersion {
+			if found == nil || compareABIVersions(info.Version, found.Version) > 0 {
+				found = info
+			}
+		} else if info.Version == version {
+			return info, nil
+		}
+	}
+	return found, nil
+}
+
+// resolveABIID returns the ID of a local ABI referred to by its ID, or by name@version.
+// References that do not match a registered name are returned unchanged, so the lookup
+// by ID reports them as not found.
+func (cs *contractStore) resolveABIID(nameOrID string) string {
+	name, version := ParseABIVersionedName(nameOrID)
+	if version == "" {
+		if info, err := cs.persistence.GetABIInfo(nameOrID); err != nil || info != nil {
+			return nameOrID
+		}
+	}
+	info, err := cs.findABIVersion(name, version)
+	if err != nil || info == nil {
+		return nameOrID
+	}
+	return info.ID
+}
+
+// CheckABIVersionAvailable validates a name@version to register an ABI under, and checks
+// that version of the name is not already held by another ABI
+func (cs *contractStore) CheckABIVersionAvailable(registerAs string) error {
+	name, version, err := parseABIRegistration(registerAs)
+	if err != nil {
+		return err
+	}
+	existing, err := cs.findABIVersion(name, version)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayABIVersionClash, existing.ID, name, version)
+	}
+	return nil
+}
+
+// RegisterABIVersion registers a stored ABI as a version of a name, so it can be referred to
+// as name@version, or by the name alone while it is the highest version
+func (cs *contractStore) RegisterABIVersion(abiID, registerAs string) (*ABIInfo, error) {
+	name, version, err := parseABIRegistration(registerAs)
+	if err != nil {
+		return nil, err
+	}
+	cs.registrationMux.Lock()
+	defer cs.registrationMux.Unlock()
+	existing, err := cs.findABIVersion(name, version)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.ID != abiID {
+		return nil, ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayABIVersionClash, existing.ID, name, version)
+	}
+	storedABI, err := cs.persistence.GetABI(abiID)
+	if err != nil {
+		return nil, err
+	}
+	if storedABI == nil {
+		return nil, ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayLocalStoreABINotFound, abiID)
+	}
+	log.Infof("Registering ABI %s as '%s@%s'", abiID, name, version)
+	storedABI.RegisteredAs = name
+	storedABI.Version = version
+	if err := cs.persistence.PutABI(storedABI); err != nil {
+		return nil, err
+	}
+	abiInfo := storedABI.ABIInfo
+	cs.abiListing.upsert(&abiInfo)
+	return &abiInfo, nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseABIVersionedName(t *testing.T) {
+	name, version := ParseABIVersionedName("erc20token@1.2.0")
+	assert.Equal(t, "erc20token", name)
+	assert.Equal(t, "1.2.0", version)
+
+	name, version = ParseABIVersionedName("erc20token")
+	assert.Equal(t, "erc20token", name)
+	assert.Equal(t, "", version)
+
+	_, _, err := parseABIRegistration("erc20token")
+	assert.Regexp(t, "FFEC100363", err)
+	_, _, err = parseABIRegistration("@1.0.0")
+	assert.Regexp(t, "FFEC100363", err)
+	_, _, err = parseABIRegistration("erc20token@latest")
+	assert.Regexp(t, "FFEC100363", err)
+	_, _, err = parseABIRegistration("erc20/token@1.0.0")
+	assert.Regexp(t, "FFEC100363", err)
+	_, _, err = parseABIRegistration("erc20token@v2.0.0-rc.1")
+	assert.NoError(t, err)
+}
+
+func TestCompareABIVersions(t *testing.T) {
+	assert.Equal(t, 0, compareABIVersions("1.2.0", "1.2.0"))
+	assert.Equal(t, 0, compareABIVersions("1.2", "v1.2.0"))
+	assert.Equal(t, 1, compareABIVersions("1.10.0", "1.2.0"))
+	assert.Equal(t, -1, compareABIVersions("1.2.0", "2"))
+	assert.Equal(t, 1, compareABIVersions("1.2.0", "1.2.0-rc1"))
+	assert.Equal(t, -1, compareABIVersions("1.2.0-rc1", "1.2.0"))
+	assert.Equal(t, -1, compareABIVersions("1.2.0-rc1", "1.2.0-rc2"))
+}
+
+func TestRegisterABIVersions(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+	defer cs.Close()
+
+	for _, id := range []string{"abi1", "abi2", "abi3"} {
+		_, err = cs.AddABI(id, &messages.DeployContract{ContractName: id}, time.Now())
+		assert.NoError(err)
+	}
+	err = cs.CheckABIVersionAvailable("erc20token@1.2.0")
+	assert.NoError(err)
+	info, err := cs.RegisterABIVersion("abi1", "erc20token@1.2.0")
+	assert.NoError(err)
+	assert.Equal("erc20token", info.RegisteredAs)
+	assert.Equal("1.2.0", info.Version)
+	_, err = cs.RegisterABIVersion("abi2", "erc20token@1.10.0")
+	assert.NoError(err)
+	_, err = cs.RegisterABIVersion("abi3", "erc20token@1.10.0-rc1")
+	assert.NoError(err)
+
+	err = cs.CheckABIVersionAvailable("erc20token@1.2.0")
+	assert.Regexp("FFEC100364.*abi1", err)
+	_, err = cs.RegisterABIVersion("abi3", "erc20token@1.2.0")
+	assert.Regexp("FFEC100364", err)
+	_, err = cs.RegisterABIVersion("abi1", "erc20token@1.2.0")
+	assert.NoError(err)
+	_, err = cs.RegisterABIVersion("missing", "other@1.0.0")
+	assert.Regexp("FFEC100127", err)
+	_, err = cs.RegisterABIVersion("abi1", "bad")
+	assert.Regexp("FFEC100363", err)
+	err = cs.CheckABIVersionAvailable("bad")
+	assert.Regexp("FFEC100363", err)
+
+	for ref, expected := range map[string]string{
+		"erc20token":            "abi2",
+		"erc20token@latest":     "abi2",
+		"erc20token@1.2.0":      "abi1",
+		"erc20token@1.10.0-rc1": "abi3",
+		"abi3":                  "abi3",
+		"erc20token@9.9.9":      "erc20token@9.9.9",
+		"unknown":               "unknown",
+		"":                      "",
+	} {
+		assert.Equal(expected, cs.(*contractStore).resolveABIID(ref), ref)
+	}
+
+	deployMsg, err := cs.GetABI(ABILocation{ABIType: LocalABI, Name: "erc20token@1.2.0"}, false)
+	assert.NoError(err)
+	assert.Equal("abi1", deployMsg.Contract.ContractName)
+	abiInfo, err := cs.GetLocalABIInfo("erc20token")
+	assert.NoError(err)
+	assert.Equal("abi2", abiInfo.ID)
+	_, err = cs.GetLocalABIInfo("erc20token@9.9.9")
+	assert.Regexp("FFEC100127", err)
+
+	contractInfo, err := cs.AddContract("0x123456789abcdef0123456789abcdef012345678", "erc20token", "token1", "token1", nil)
+	assert.NoError(err)
+	assert.Equal("abi2", contractInfo.ABI)
+
+	// A new latest version is used straight away
+	_, err = cs.AddABI("abi4", &messages.DeployContract{ContractName: "abi4"}, time.Now())
+	assert.NoError(err)
+	_, err = cs.RegisterABIVersion("abi4", "erc20token@2.0.0")
+	assert.NoError(err)
+	deployMsg, err = cs.GetABI(ABILocation{ABIType: LocalABI, Name: "erc20token"}, false)
+	assert.NoError(err)
+	assert.Equal("abi4", deployMsg.Contract.ContractName)
+
+	abis, err := cs.ListABIs(&ListingFilter{Name: "erc20token"})
+	assert.NoError(err)
+	assert.Len(abis, 4)
+}
@@ -0,0 +1,380 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	ethconnecterrors "github.com/hyperledger/firefly-ethconnect/internal/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ContractStoreArchiveVersion is the version of the archive format written by Export
+const ContractStoreArchiveVersion = 1
+
+// maxReportedConflicts limits how many conflicting entries are named in an import error
+const maxReportedConflicts = 10
+
+var archiveAddressCheck = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// ContractStoreArchive is a copy of everything in the local contract store, that can be
+// imported into another instance to migrate it, or restored after a loss of the storage path
+type ContractStoreArchive struct {
+	Version       int                     `json:"version"`
+	Exported      string                  `json:"exported"`
+	ABIs          []*StoredABI            `json:"abis"`
+	Contracts     []*ContractInfo         `json:"contracts"`
+	Registrations []*ArchivedRegistration `json:"registrations"`
+}
+
+// ArchivedRegistration is a friendly name, and the address of the contract instance it resolves to
+type ArchivedRegistration struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+// ImportResult counts the entries of an archive written to the contract store, and those
+// skipped because an identical entry was already stored
+type ImportResult struct {
+	ABIs          int `json:"abis"`
+	Contracts     int `json:"contracts"`
+	Registrations int `json:"registrations"`
+	Unchanged     int `json:"unchanged"`
+}
+
+// Export reads every ABI, contract instance and registered name from the store into an archive
+func (cs *contractStore) Export() (*ContractStoreArchive, error) {
+	// Names are not changed while they are read, so each one matches the instance it points to
+	cs.registrationMux.Lock()
+	defer cs.registrationMux.Unlock()
+
+	archive := &ContractStoreArchive{
+		Version:       ContractStoreArchiveVersion,
+		Exported:      time.Now().UTC().Format(time.RFC3339),
+		ABIs:          make([]*StoredABI, 0),
+		Registrations: make([]*ArchivedRegistration, 0),
+	}
+	abis, err := cs.persistence.ListABIs()
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range abis {
+		storedABI, err := cs.persistence.GetABI(info.ID)
+		if err != nil {
+			return nil, err
+		}
+		if storedABI != nil {
+			archive.ABIs = append(archive.ABIs, storedABI)
+		}
+	}
+	contracts, err := cs.persistence.ListContracts()
+	if err != nil {
+		return nil, err
+	}
+	archive.Contracts = append(make([]*ContractInfo, 0, len(contracts)), contracts...)
+	for _, info := range archive.Contracts {
+		if info.RegisteredAs == "" {
+			continue
+		}
+		registered, err := cs.persistence.GetRegisteredName(info.RegisteredAs)
+		if err != nil {
+			return nil, err
+		}
+		if registered != nil && registered.Address == info.Address {
+			archive.Registrations = append(archive.Registrations, &ArchivedRegistration{
+				Name:    info.RegisteredAs,
+				Address: info.Address,
+			})
+		}
+	}
+	log.Infof("Exported contract store: abis=%d contracts=%d registrations=%d", len(archive.ABIs), len(archive.Contracts), len(archive.Registrations))
+	return archive, nil
+}
+
+// importPlan is the set of entries to write for an import, worked out before anything is written
+// so an archive that cannot be imported in full leaves the store unchanged
+type importPlan struct {
+	abis          []*StoredABI
+	contracts     []*ContractInfo
+	releasedNames []string
+	registrations []*ContractInfo
+	unchanged     int
+	conflicts     []string
+}
+
+func (p *importPlan) conflict(format string, args ...interface{}) {
+	p.conflicts = append(p.conflicts, fmt.Sprintf(format, args...))
+}
+
+func sameEntry(a, b interface{}) bool {
+	aBytes, _ := json.Marshal(a)
+	bBytes, _ := json.Marshal(b)
+	return bytes.Equal(aBytes, bBytes)
+}
+
+// Import writes the entries of an archive into the store. An entry that differs from one already
+// stored with the same ID or address is only replaced when overwrite is set. A friendly name, or
+// ABI name@version, held by a different entry is always a conflict. Nothing is written unless the
+// whole archive can be imported, and if a write fails the entries already written are restored.
+// The URLs in the archive are re-based on the URL of this gateway.
+func (cs *contractStore) Import(archive *ContractStoreArchive, overwrite bool) (*ImportResult, error) {
+	if archive == nil || archive.Version != ContractStoreArchiveVersion {
+		version := 0
+		if archive != nil {
+			version = archive.Version
+		}
+		return nil, ethconnecterrors.Errorf(ethconnecterrors.ContractStoreArchiveInvalid, fmt.Sprintf("unsupported version %d", version))
+	}
+	cs.indexMux.Lock()
+	defer cs.indexMux.Unlock()
+	cs.registrationMux.Lock()
+	defer cs.registrationMux.Unlock()
+
+	plan, err := cs.planImport(archive, overwrite)
+	if err != nil {
+		return nil, err
+	}
+	if len(plan.conflicts) > 0 {
+		reported := plan.conflicts
+		if len(reported) > maxReportedConflicts {
+			reported = append(reported[:maxReportedConflicts:maxReportedConflicts], "...")
+		}
+		return nil, ethconnecterrors.Errorf(ethconnecterrors.ContractStoreImportConflict, len(plan.conflicts), strings.Join(reported, ", "))
+	}
+
+	// The listings and indexes are rebuilt after the import, including after a failure is rolled back
+	defer func() {
+		cs.contractListing.reset()
+		cs.abiListing.reset()
+		cs.selectors.reset()
+		cs.contents.reset()
+		cs.index.reset()
+		cs.abiCache.Purge()
+	}()
+	if err := cs.writeImport(plan); err != nil {
+		return nil, err
+	}
+	result := &ImportResult{
+		ABIs:          len(plan.abis),
+		Contracts:     len(plan.contracts),
+		Registrations: len(plan.registrations),
+		Unchanged:     plan.unchanged,
+	}
+	log.Infof("Imported contract store archive: %+v", result)
+	return result, nil
+}
+
+// writeImport writes the entries of the plan, recording how to restore each one first. If any
+// write fails, the entries written so far are restored in reverse order.
+func (cs *contractStore) writeImport(plan *importPlan) (err error) {
+	var undo []func() error
+	defer func() {
+		if err != nil {
+			log.Errorf("Contract store import failed, restoring %d entries: %s", len(undo), err)
+			for i := len(undo) - 1; i >= 0; i-- {
+				if undoErr := undo[i](); undoErr != nil {
+					log.Errorf("Failed to restore entry after failed import: %s", undoErr)
+				}
+			}
+		}
+	}()
+	for _, storedABI := range plan.abis {
+		id := storedABI.ID
+		previous, err := cs.persistence.GetABI(id)
+		if err != nil {
+			return err
+		}
+		undo = append(undo, func() error {
+			if previous == nil {
+				return cs.persistence.DeleteABI(id)
+			}
+			return cs.persistence.PutABI(previous)
+		})
+		if err := cs.persistence.PutABI(storedABI); err != nil {
+			return err
+		}
+	}
+	for _, info := range plan.contracts {
+		address := info.Address
+		previous, err := cs.persistence.GetContract(address)
+		if err != nil {
+			return err
+		}
+		undo = append(undo, func() error {
+			if previous == nil {
+				return cs.persistence.DeleteContract(address)
+			}
+			return cs.persistence.PutContract(previous)
+		})
+		if err := cs.persistence.PutContract(info); err != nil {
+			return err
+		}
+	}
+	for _, name := range plan.releasedNames {
+		previous, err := cs.persistence.GetRegisteredName(name)
+		if err != nil {
+			return err
+		}
+		if previous != nil {
+			undo = append(undo, func() error { return cs.persistence.PutRegisteredName(previous) })
+		}
+		if err := cs.persistence.DeleteRegisteredName(name); err != nil {
+			return err
+		}
+	}
+	for _, info := range plan.registrations {
+		name := info.RegisteredAs
+		previous, err := cs.persistence.GetRegisteredName(name)
+		if err != nil {
+			return err
+		}
+		undo = append(undo, func() error {
+			if previous == nil {
+				return cs.persistence.DeleteRegisteredName(name)
+			}
+			return cs.persistence.PutRegisteredName(previous)
+		})
+		if err := cs.persistence.PutRegisteredName(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cs *contractStore) planImport(archive *ContractStoreArchive, overwrite bool) (*importPlan, error) {
+	plan := &importPlan{}
+
+	archivedABIs := make(map[string]bool)
+	archivedVersions := make(map[string]bool)
+	for _, a := range archive.ABIs {
+		if a == nil || a.ID == "" || a.DeployMsg == nil {
+			return nil, ethconnecterrors.Errorf(ethconnecterrors.ContractStoreArchiveInvalid, "ABI entry without an ID and deployment message")
+		}
+		if archivedABIs[a.ID] {
+			return nil, ethconnecterrors.Errorf(ethconnecterrors.ContractStoreArchiveInvalid, fmt.Sprintf("duplicate ABI '%s'", a.ID))
+		}
+		archivedABIs[a.ID] = true
+		storedABI := *a
+		storedABI.Path = "/abis/" + a.ID
+		storedABI.SwaggerURL = cs.conf.BaseURL + storedABI.Path + "?swagger"
+
+		if storedABI.RegisteredAs != "" {
+			nameAtVersion := storedABI.RegisteredAs + "@" + storedABI.Version
+			if archivedVersions[nameAtVersion] {
+				return nil, ethconnecterrors.Errorf(ethconnecterrors.ContractStoreArchiveInvalid, fmt.Sprintf("duplicate ABI version '%s'", nameAtVersion))
+			}
+			archivedVersions[nameAtVersion] = true
+			existing, err := cs.findABIVersion(storedABI.RegisteredAs, storedABI.Version)
+			if err != nil {
+				return nil, err
+			}
+			if existing != nil && existing.ID != storedABI.ID {
+				plan.conflict("abi %s@%s", storedABI.RegisteredAs, storedABI.Version)
+			}
+		}
+		existing, err := cs.persistence.GetABI(storedABI.ID)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case existing == nil:
+			plan.abis = append(plan.abis, &storedABI)
+		case sameEntry(existing, &storedABI):
+			plan.unchanged++
+		case overwrite:
+			plan.abis = append(plan.abis, &storedABI)
+		default:
+			plan.conflict("abi %s", storedABI.ID)
+		}
+	}
+
+	archivedContracts := make(map[string]*ContractInfo)
+	for _, c := range archive.Contracts {
+		if c == nil || !archiveAddressCheck.MatchString(c.Address) || c.ABI == "" {
+			return nil, ethconnecterrors.Errorf(ethconnecterrors.ContractStoreArchiveInvalid, "contract entry without a lower case hex address and an ABI")
+		}
+		if archivedContracts[c.Address] != nil {
+			return nil, ethconnecterrors.Errorf(ethconnecterrors.ContractStoreArchiveInvalid, fmt.Sprintf("duplicate contract '%s'", c.Address))
+		}
+		info := *c
+		info.SwaggerURL = cs.conf.BaseURL + info.Path + "?swagger"
+		archivedContracts[info.Address] = &info
+
+		if !archivedABIs[info.ABI] {
+			abiInfo, err := cs.persistence.GetABIInfo(info.ABI)
+			if err != nil {
+				return nil, err
+			}
+			if abiInfo == nil {
+				return nil, ethconnecterrors.Errorf(ethconnecterrors.ContractStoreImportMissingABI, info.Address, info.ABI)
+			}
+		}
+		existing, err := cs.persistence.GetContract(info.Address)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case existing == nil:
+			plan.contracts = append(plan.contracts, &info)
+		case sameEntry(existing, &info):
+			plan.unchanged++
+		case overwrite:
+			plan.contracts = append(plan.contracts, &info)
+			if existing.RegisteredAs != "" && existing.RegisteredAs != info.RegisteredAs {
+				// The name the replaced instance was registered as is released, if it still holds it
+				registered, err := cs.persistence.GetRegisteredName(existing.RegisteredAs)
+				if err != nil {
+					return nil, err
+				}
+				if registered != nil && registered.Address == existing.Address {
+					plan.releasedNames = append(plan.releasedNames, existing.RegisteredAs)
+				}
+			}
+		default:
+			plan.conflict("contract %s", info.Address)
+		}
+	}
+
+	for _, r := range archive.Registrations {
+		if r == nil || r.Name == "" {
+			return nil, ethconnecterrors.Errorf(ethconnecterrors.ContractStoreArchiveInvalid, "registration without a name")
+		}
+		info := archivedContracts[r.Address]
+		if info == nil || info.RegisteredAs != r.Name {
+			return nil, ethconnecterrors.Errorf(ethconnecterrors.ContractStoreArchiveInvalid, fmt.Sprintf("registration '%s' does not match a contract in the archive", r.Name))
+		}
+		existing, err := cs.persistence.GetRegisteredName(r.Name)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case existing == nil:
+			plan.registrations = append(plan.registrations, info)
+		case existing.Address != info.Address:
+			plan.conflict("name %s", r.Name)
+		case sameEntry(existing, info):
+			plan.unchanged++
+		default:
+			// The registration is refreshed along with the instance it resolves to
+			plan.registrations = append(plan.registrations, info)
+		}
+	}
+	return plan, nil
+}
@@ -0,0 +1,293 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBackupStore(t *testing.T, dir, baseURL string) ContractStore {
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir, BaseURL: baseURL}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(t, err)
+	return cs
+}
+
+func newTestExport(t *testing.T) *ContractStoreArchive {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := newTestBackupStore(t, dir, "http://source")
+	defer cs.Close()
+
+	_, err := cs.AddABI("abi1", &messages.DeployContract{ContractName: "erc20", ABI: erc20ABI()}, time.Now())
+	assert.NoError(err)
+	_, err = cs.RegisterABIVersion("abi1", "erc20@1.0.0")
+	assert.NoError(err)
+	_, err = cs.AddContract("123456789abcdef0123456789abcdef012345678", "abi1", "token", "token", map[string]string{"team": "a"})
+	assert.NoError(err)
+	_, err = cs.AddContract("223456789abcdef0123456789abcdef012345678", "abi1", "223456789abcdef0123456789abcdef012345678", "", nil)
+	assert.NoError(err)
+
+	archive, err := cs.Export()
+	assert.NoError(err)
+
+	// The archive survives the round trip through JSON that the REST API makes
+	b, err := json.Marshal(archive)
+	assert.NoError(err)
+	var decoded ContractStoreArchive
+	err = json.Unmarshal(b, &decoded)
+	assert.NoError(err)
+	return &decoded
+}
+
+func TestExportImport(t *testing.T) {
+	assert := assert.New(t)
+
+	archive := newTestExport(t)
+	assert.Equal(ContractStoreArchiveVersion, archive.Version)
+	assert.NotEmpty(archive.Exported)
+	assert.Len(archive.ABIs, 1)
+	assert.Equal("erc20", archive.ABIs[0].RegisteredAs)
+	assert.NotNil(archive.ABIs[0].DeployMsg)
+	assert.Len(archive.Contracts, 2)
+	assert.Equal([]*ArchivedRegistration{
+		{Name: "token", Address: "123456789abcdef0123456789abcdef012345678"},
+	}, archive.Registrations)
+
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := newTestBackupStore(t, dir, "http://target")
+	defer cs.Close()
+
+	// Populate the listings, so we check they are rebuilt
+	contracts, err := cs.ListContracts(nil)
+	assert.NoError(err)
+	assert.Empty(contracts)
+
+	result, err := cs.Import(archive, false)
+	assert.NoError(err)
+	assert.Equal(&ImportResult{ABIs: 1, Contracts: 2, Registrations: 1}, result)
+
+	contracts, err = cs.ListContracts(nil)
+	assert.NoError(err)
+	assert.Len(contracts, 2)
+	abis, err := cs.ListABIs(&ListingFilter{Method: transferSelector})
+	assert.NoError(err)
+	assert.Len(abis, 1)
+	addr, err := cs.ResolveContractAddress("token")
+	assert.NoError(err)
+	info, err := cs.GetContractByAddress(addr)
+	assert.NoError(err)
+	assert.Equal("http://target/contracts/token?swagger", info.SwaggerURL)
+	assert.Equal(map[string]string{"team": "a"}, info.Labels)
+	abiInfo, err := cs.GetLocalABIInfo("erc20@1.0.0")
+	assert.NoError(err)
+	assert.Equal("http://target/abis/abi1?swagger", abiInfo.SwaggerURL)
+	deployMsg, err := cs.GetABI(ABILocation{ABIType: LocalABI, Name: "abi1"}, false)
+	assert.NoError(err)
+	assert.Equal("erc20", deployMsg.Contract.ContractName)
+
+	// Importing again changes nothing
+	result, err = cs.Import(archive, false)
+	assert.NoError(err)
+	assert.Equal(&ImportResult{Unchanged: 4}, result)
+}
+
+func TestImportConflicts(t *testing.T) {
+	assert := assert.New(t)
+
+	archive := newTestExport(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := newTestBackupStore(t, dir, "http://target")
+	defer cs.Close()
+	_, err := cs.Import(archive, false)
+	assert.NoError(err)
+
+	// A changed instance is only replaced on overwrite
+	archive.Contracts[1].Labels = map[string]string{"team": "b"}
+	_, err = cs.Import(archive, false)
+	assert.Regexp("FFEC100395.*1 existing.*contract 223456789abcdef0123456789abcdef012345678", err)
+	result, err := cs.Import(archive, true)
+	assert.NoError(err)
+	assert.Equal(&ImportResult{Contracts: 1, Unchanged: 3}, result)
+	info, err := cs.GetContractByAddress("223456789abcdef0123456789abcdef012345678")
+	assert.NoError(err)
+	assert.Equal("b", info.Labels["team"])
+
+	// Renaming an instance on overwrite releases its old name
+	archive.Contracts[0].RegisteredAs = "token2"
+	archive.Contracts[0].Path = "/contracts/token2"
+	archive.Registrations[0].Name = "token2"
+	result, err = cs.Import(archive, true)
+	assert.NoError(err)
+	assert.Equal(&ImportResult{Contracts: 1, Registrations: 1, Unchanged: 2}, result)
+	_, err = cs.ResolveContractAddress("token")
+	assert.Regexp("FFEC100125", err)
+	addr, err := cs.ResolveContractAddress("token2")
+	assert.NoError(err)
+	assert.Equal("123456789abcdef0123456789abcdef012345678", addr)
+
+	// Names held by other entries clash, even on overwrite
+	_, err = cs.AddContract("323456789abcdef0123456789abcdef012345678", "abi1", "token3", "token3", nil)
+	assert.NoError(err)
+	_, err = cs.AddABI("abi2", &messages.DeployContract{ContractName: "erc20"}, time.Now())
+	assert.NoError(err)
+	_, err = cs.RegisterABIVersion("abi2", "erc20@2.0.0")
+	assert.NoError(err)
+	archive.ABIs[0].Version = "2.0.0"
+	archive.Contracts[0].RegisteredAs = "token3"
+	archive.Registrations[0].Name = "token3"
+	_, err = cs.Import(archive, true)
+	assert.Regexp("FFEC100395.*2 existing.*abi erc20@2.0.0, name token3", err)
+}
+
+func TestImportReportsLimitedConflicts(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := newTestBackupStore(t, dir, "")
+	defer cs.Close()
+
+	archive := &ContractStoreArchive{Version: ContractStoreArchiveVersion}
+	for i := 0; i < maxReportedConflicts+2; i++ {
+		id := string(rune('a' + i))
+		_, err := cs.AddABI(id, &messages.DeployContract{ContractName: "existing"}, time.Now())
+		assert.NoError(err)
+		archive.ABIs = append(archive.ABIs, &StoredABI{ABIInfo: ABIInfo{ID: id}, DeployMsg: &messages.DeployContract{ContractName: "imported"}})
+	}
+	_, err := cs.Import(archive, false)
+	assert.Regexp("FFEC100395.*12 existing.*abi j, \\.\\.\\.$", err)
+}
+
+func TestImportInvalidArchive(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := newTestBackupStore(t, dir, "")
+	defer cs.Close()
+
+	deployMsg := &messages.DeployContract{ContractName: "erc20"}
+	abi1 := &StoredABI{ABIInfo: ABIInfo{ID: "abi1"}, DeployMsg: deployMsg}
+	token := &ContractInfo{Address: "123456789abcdef0123456789abcdef012345678", ABI: "abi1", RegisteredAs: "token"}
+	for _, test := range []struct {
+		archive *ContractStoreArchive
+		err     string
+	}{
+		{nil, "FFEC100394.*version 0"},
+		{&ContractStoreArchive{Version: 2}, "FFEC100394.*version 2"},
+		{&ContractStoreArchive{Version: 1, ABIs: []*StoredABI{{ABIInfo: ABIInfo{ID: "abi1"}}}}, "FFEC100394.*deployment message"},
+		{&ContractStoreArchive{Version: 1, ABIs: []*StoredABI{abi1, abi1}}, "FFEC100394.*duplicate ABI 'abi1'"},
+		{&ContractStoreArchive{Version: 1, ABIs: []*StoredABI{
+			{ABIInfo: ABIInfo{ID: "abi1", RegisteredAs: "erc20", Version: "1.0.0"}, DeployMsg: deployMsg},
+			{ABIInfo: ABIInfo{ID: "abi2", RegisteredAs: "erc20", Version: "1.0.0"}, DeployMsg: deployMsg},
+		}}, "FFEC100394.*duplicate ABI version 'erc20@1.0.0'"},
+		{&ContractStoreArchive{Version: 1, Contracts: []*ContractInfo{{Address: "0x123456789ABCDEF0123456789abcdef012345678", ABI: "abi1"}}}, "FFEC100394.*lower case hex"},
+		{&ContractStoreArchive{Version: 1, ABIs: []*StoredABI{abi1}, Contracts: []*ContractInfo{token, token}}, "FFEC100394.*duplicate contract"},
+		{&ContractStoreArchive{Version: 1, Contracts: []*ContractInfo{token}}, "FFEC100396"},
+		{&ContractStoreArchive{Version: 1, Registrations: []*ArchivedRegistration{{Address: token.Address}}}, "FFEC100394.*without a name"},
+		{&ContractStoreArchive{Version: 1, ABIs: []*StoredABI{abi1}, Contracts: []*ContractInfo{token}, Registrations: []*ArchivedRegistration{
+			{Name: "other", Address: token.Address},
+		}}, "FFEC100394.*registration 'other'"},
+	} {
+		_, err := cs.Import(test.archive, true)
+		assert.Regexp(test.err, err)
+	}
+
+	// Nothing was written by the failed imports
+	abis, err := cs.ListABIs(nil)
+	assert.NoError(err)
+	assert.Empty(abis)
+}
+
+func TestImportWriteFail(t *testing.T) {
+	assert := assert.New(t)
+
+	archive := newTestExport(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	p := &failPutContractPersistence{ContractStorePersistence: NewLevelDBContractPersistence(path.Join(dir, "contracts")), failPut: true}
+	cs := NewContractStoreWithPersistence(&ContractStoreConf{StoragePath: dir}, &mockRR{}, p)
+	err := cs.Init()
+	assert.NoError(err)
+	defer cs.Close()
+
+	_, err = cs.Import(archive, false)
+	assert.Regexp("pop", err)
+
+	// The ABIs written before the failure are removed again
+	abis, err := cs.ListABIs(nil)
+	assert.NoError(err)
+	assert.Empty(abis)
+}
+
+type failPutNamePersistence struct {
+	ContractStorePersistence
+	failPut bool
+}
+
+func (p *failPutNamePersistence) PutRegisteredName(info *ContractInfo) error {
+	if p.failPut {
+		return fmt.Errorf("pop")
+	}
+	return p.ContractStorePersistence.PutRegisteredName(info)
+}
+
+func TestImportWriteFailRestoresOverwritten(t *testing.T) {
+	assert := assert.New(t)
+
+	archive := newTestExport(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	p := &failPutNamePersistence{ContractStorePersistence: NewLevelDBContractPersistence(path.Join(dir, "contracts"))}
+	cs := NewContractStoreWithPersistence(&ContractStoreConf{StoragePath: dir}, &mockRR{}, p)
+	err := cs.Init()
+	assert.NoError(err)
+	defer cs.Close()
+
+	_, err = cs.Import(archive, false)
+	assert.NoError(err)
+
+	// Overwrite every entry, failing on the last write
+	archive.ABIs[0].Description = "updated"
+	archive.Contracts[0].Labels = map[string]string{"team": "b"}
+	archive.Contracts[1].Labels = map[string]string{"team": "b"}
+	p.failPut = true
+	_, err = cs.Import(archive, true)
+	assert.Regexp("pop", err)
+
+	abiInfo, err := cs.GetLocalABIInfo("abi1")
+	assert.NoError(err)
+	assert.Empty(abiInfo.Description)
+	info, err := cs.GetContractByAddress("123456789abcdef0123456789abcdef012345678")
+	assert.NoError(err)
+	assert.Equal(map[string]string{"team": "a"}, info.Labels)
+	info, err = cs.GetContractByAddress("223456789abcdef0123456789abcdef012345678")
+	assert.NoError(err)
+	assert.Empty(info.Labels)
+	addr, err := cs.ResolveContractAddress("token")
+	assert.NoError(err)
+	assert.Equal("123456789abcdef0123456789abcdef012345678", addr)
+}
@@ -0,0 +1,194 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	// DefaultContentHash is the algorithm used for the content hash of uploaded ABIs
+	DefaultContentHash = "sha256"
+	// ContentHashNone disables content hashing, so identical uploads are stored separately
+	ContentHashNone = "none"
+)
+
+// ContentHashAlgorithm computes the digest of the canonical content of an ABI
+type ContentHashAlgorithm func(content []byte) []byte
+
+var contentHashAlgorithms = map[string]ContentHashAlgorithm{
+	"sha256": func(content []byte) []byte {
+		h := sha256.Sum256(content)
+		return h[:]
+	},
+	"keccak256": func(content []byte) []byte {
+		h := sha3.NewLegacyKeccak256()
+		h.Write(content)
+		return h.Sum(nil)
+	},
+}
+
+// RegisterContentHashAlgorithm makes an additional algorithm available to the contentHash
+// setting of the contract store. It must be called before the store is created.
+func RegisterContentHashAlgorithm(name string, algorithm ContentHashAlgorithm) {
+	contentHashAlgorithms[name] = algorithm
+}
+
+// canonicalABIContent serializes the ABI and bytecode of an upload, so the same contract
+// has the same content regardless of the order of the ABI or the formatting of the JSON
+func canonicalABIContent(deployMsg *messages.DeployContract) []byte {
+	elements := make([]string, 0, len(deployMsg.ABI))
+	for _, element := range deployMsg.ABI {
+		b, _ := json.Marshal(element)
+		elements = append(elements, string(b))
+	}
+	sort.Strings(elements)
+	content, _ := json.Marshal(map[string]interface{}{
+		"abi":      elements,
+		"bytecode": hex.EncodeToString(deployMsg.Compiled),
+	})
+	return content
+}
+
+// contentHash returns the content address of an upload, prefixed with the name of the algorithm
+func contentHash(algorithm string, deployMsg *messages.DeployContract) string {
+	hashFn := contentHashAlgorithms[algorithm]
+	if hashFn == nil || deployMsg == nil {
+		return ""
+	}
+	return algorithm + ":" + hex.EncodeToString(hashFn(canonicalABIContent(deployMsg)))
+}
+
+// contentIndex maps content hashes to the ID of the oldest ABI stored with that content.
+// Like the selector index it is built from the DB on first use, so ABIs stored before
+// content hashing was enabled are matched too.
+type contentIndex struct {
+	mux    sync.Mutex
+	load   func() ([]*StoredABI, error)
+	hashFn func(deployMsg *messages.DeployContract) string
+	byHash map[string]string
+	byABI  map[string]string
+}
+
+func newContentIndex(load func() ([]*StoredABI, error), hashFn func(deployMsg *messages.DeployContract) string) *contentIndex {
+	return &contentIndex{load: load, hashFn: hashFn}
+}
+
+// ensureLoaded must be called with the lock held. The ABIs are loaded oldest first, so
+// the oldest of a set of duplicates is the one returned from lookups.
+func (ci *contentIndex) ensureLoaded() error {
+	if ci.byHash != nil {
+		return nil
+	}
+	abis, err := ci.load()
+	if err != nil {
+		return err
+	}
+	ci.byHash = make(map[string]string, len(abis))
+	ci.byABI = make(map[string]string, len(abis))
+	for _, storedABI := range abis {
+		ci.addLocked(storedABI.ID, ci.hashFn(storedABI.DeployMsg))
+	}
+	return nil
+}
+
+func (ci *contentIndex) addLocked(abiID, hash string) {
+	ci.removeLocked(abiID)
+	if hash == "" {
+		return
+	}
+	ci.byABI[abiID] = hash
+	if _, exists := ci.byHash[hash]; !exists {
+		ci.byHash[hash] = abiID
+	}
+}
+
+func (ci *contentIndex) removeLocked(abiID string) {
+	hash, ok := ci.byABI[abiID]
+	if !ok {
+		return
+	}
+	delete(ci.byABI, abiID)
+	if ci.byHash[hash] != abiID {
+		return
+	}
+	delete(ci.byHash, hash)
+	// Another ABI with the same content takes over, if there is one
+	for otherID, otherHash := range ci.byABI {
+		if otherHash == hash {
+			ci.byHash[hash] = otherID
+			break
+		}
+	}
+}
+
+// add indexes a new ABI, if the index has been built
+func (ci *contentIndex) add(abiID, hash string) {
+	ci.mux.Lock()
+	defer ci.mux.Unlock()
+	if ci.byHash != nil {
+		ci.addLocked(abiID, hash)
+	}
+}
+
+func (ci *contentIndex) remove(abiID string) {
+	ci.mux.Lock()
+	defer ci.mux.Unlock()
+	if ci.byHash != nil {
+		ci.removeLocked(abiID)
+	}
+}
+
+// reset discards the index, so it is rebuilt from the DB on the next lookup
+func (ci *contentIndex) reset() {
+	ci.mux.Lock()
+	defer ci.mux.Unlock()
+	ci.byHash = nil
+	ci.byABI = nil
+}
+
+// lookup returns the ID of an ABI with the content hash, or "" if there is none
+func (ci *contentIndex) lookup(hash string) (string, error) {
+	ci.mux.Lock()
+	defer ci.mux.Unlock()
+	if err := ci.ensureLoaded(); err != nil {
+		return "", err
+	}
+	return ci.byHash[hash], nil
+}
+
+// matching returns the IDs of all the ABIs with the content hash, including duplicates stored
+// before content hashing was enabled
+func (ci *contentIndex) matching(hash string) (map[string]bool, error) {
+	ci.mux.Lock()
+	defer ci.mux.Unlock()
+	if err := ci.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	abiIDs := make(map[string]bool)
+	for abiID, abiHash := range ci.byABI {
+		if abiHash == hash {
+			abiIDs[abiID] = true
+		}
+	}
+	return abiIDs, nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentHashCanonical(t *testing.T) {
+	assert := assert.New(t)
+
+	abi := erc20ABI()
+	reversed := make(ethbinding.ABIMarshaling, len(abi))
+	for i := range abi {
+		reversed[len(abi)-1-i] = abi[i]
+	}
+	hash := contentHash("sha256", &messages.DeployContract{ABI: abi, Compiled: []byte{0x60, 0x80}})
+	assert.Regexp("^sha256:[0-9a-f]{64}$", hash)
+	// The order of the ABI and the other fields of the upload do not matter
+	assert.Equal(hash, contentHash("sha256", &messages.DeployContract{ABI: reversed, Compiled: []byte{0x60, 0x80}, ContractName: "other"}))
+	assert.NotEqual(hash, contentHash("sha256", &messages.DeployContract{ABI: abi, Compiled: []byte{0x60, 0x81}}))
+	assert.NotEqual(hash, contentHash("sha256", &messages.DeployContract{ABI: abi[1:], Compiled: []byte{0x60, 0x80}}))
+
+	assert.Regexp("^keccak256:[0-9a-f]{64}$", contentHash("keccak256", &messages.DeployContract{ABI: abi}))
+	assert.Empty(contentHash(ContentHashNone, &messages.DeployContract{ABI: abi}))
+	assert.Empty(contentHash("sha256", nil))
+}
+
+func TestContentIndex(t *testing.T) {
+	assert := assert.New(t)
+
+	hashFn := func(deployMsg *messages.DeployContract) string {
+		if deployMsg == nil {
+			return ""
+		}
+		return deployMsg.ContractName
+	}
+	ci := newContentIndex(func() ([]*StoredABI, error) {
+		return []*StoredABI{
+			{ABIInfo: ABIInfo{ID: "abi1"}, DeployMsg: &messages.DeployContract{ContractName: "h1"}},
+			{ABIInfo: ABIInfo{ID: "abi2"}, DeployMsg: &messages.DeployContract{ContractName: "h1"}},
+			{ABIInfo: ABIInfo{ID: "abi3"}},
+		}, nil
+	}, hashFn)
+	// Changes before the index is loaded are picked up from the DB on load
+	ci.add("abi4", "h2")
+	ci.remove("abi1")
+
+	abiID, err := ci.lookup("h1")
+	assert.NoError(err)
+	assert.Equal("abi1", abiID)
+	abiID, _ = ci.lookup("h2")
+	assert.Empty(abiID)
+	abiIDs, err := ci.matching("h1")
+	assert.NoError(err)
+	assert.Equal(map[string]bool{"abi1": true, "abi2": true}, abiIDs)
+
+	ci.add("abi4", "h2")
+	abiID, _ = ci.lookup("h2")
+	assert.Equal("abi4", abiID)
+
+	// The duplicate takes over when the first is removed
+	ci.remove("abi1")
+	abiID, _ = ci.lookup("h1")
+	assert.Equal("abi2", abiID)
+	ci.remove("abi2")
+	abiID, _ = ci.lookup("h1")
+	assert.Empty(abiID)
+
+	ci.reset()
+	abiID, _ = ci.lookup("h1")
+	assert.Equal("abi1", abiID)
+}
+
+func TestContentIndexLoadFail(t *testing.T) {
+	ci := newContentIndex(func() ([]*StoredABI, error) {
+		return nil, fmt.Errorf("pop")
+	}, nil)
+	_, err := ci.lookup("h1")
+	assert.Regexp(t, "pop", err)
+	_, err = ci.matching("h1")
+	assert.Regexp(t, "pop", err)
+}
+
+func TestFindABIByContent(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+
+	msg := &messages.DeployContract{ContractName: "erc20", ABI: erc20ABI(), Compiled: []byte{0x60, 0x80}}
+	info, err := cs.FindABIByContent(msg)
+	assert.NoError(err)
+	assert.Nil(info)
+
+	now := time.Now()
+	info, err = cs.AddABI("abi1", msg, now)
+	assert.NoError(err)
+	hash := info.ContentHash
+	assert.Regexp("^sha256:", hash)
+	_, err = cs.AddABI("abi2", &messages.DeployContract{ContractName: "copy", ABI: erc20ABI(), Compiled: []byte{0x60, 0x80}}, now.Add(time.Second))
+	assert.NoError(err)
+	_, err = cs.AddABI("abi3", &messages.DeployContract{ContractName: "other", ABI: erc20ABI()[1:]}, now)
+	assert.NoError(err)
+
+	info, err = cs.FindABIByContent(&messages.DeployContract{ABI: erc20ABI(), Compiled: []byte{0x60, 0x80}})
+	assert.NoError(err)
+	assert.Equal("abi1", info.ID)
+
+	abis, err := cs.ListABIs(&ListingFilter{ContentHash: strings.ToUpper(hash[:7]) + hash[7:]})
+	assert.NoError(err)
+	assert.Len(abis, 2)
+
+	// Rebuilt from the DB, the oldest is returned
+	cs.(*contractStore).contents.reset()
+	info, _ = cs.FindABIByContent(msg)
+	assert.Equal("abi1", info.ID)
+
+	assert.NoError(cs.DeleteABI("abi1"))
+	info, _ = cs.FindABIByContent(msg)
+	assert.Equal("abi2", info.ID)
+}
+
+func TestFindABIByContentDisabled(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir, ContentHash: ContentHashNone}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+
+	msg := &messages.DeployContract{ABI: erc20ABI()}
+	info, err := cs.AddABI("abi1", msg, time.Now())
+	assert.NoError(err)
+	assert.Empty(info.ContentHash)
+	info, err = cs.FindABIByContent(msg)
+	assert.NoError(err)
+	assert.Nil(info)
+}
+
+func TestContentHashUnknownAlgorithm(t *testing.T) {
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir, ContentHash: "md5"}, &mockRR{})
+	err := cs.Init()
+	assert.Regexp(t, "FFEC100411", err)
+}
+
+func TestRegisterContentHashAlgorithm(t *testing.T) {
+	RegisterContentHashAlgorithm("test", func(content []byte) []byte { return []byte{0x01} })
+	defer delete(contentHashAlgorithms, "test")
+	assert.Equal(t, "test:01", contentHash("test", &messages.DeployContract{}))
+}
@@ -0,0 +1,168 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// contractIndexShards is the number of independently locked shards in each map of the
+// contract index, so lookups of different contracts do not contend on a single lock
+const contractIndexShards = 32
+
+// contractIndex caches the contract instances read from the persistence layer by address,
+// and by registered name. Every REST invocation and decoded receipt event looks a contract up,
+// so the lookups are served from memory after the first read, under the read lock of one shard.
+// Changes to the registry read the DB directly, so they are checked against what is stored.
+type contractIndex struct {
+	byAddress *shardedContractMap
+	byName    *shardedContractMap
+}
+
+func newContractIndex() *contractIndex {
+	return &contractIndex{
+		byAddress: newShardedContractMap(),
+		byName:    newShardedContractMap(),
+	}
+}
+
+// reset discards every cached entry, so they are read from the DB again
+func (ci *contractIndex) reset() {
+	ci.byAddress.reset()
+	ci.byName.reset()
+}
+
+type contractMapShard struct {
+	mux sync.RWMutex
+	// generation changes on every invalidation, so a read from the DB that overlaps a write
+	// does not cache the value it read after the write has replaced it
+	generation uint64
+	entries    map[string]*ContractInfo
+}
+
+type shardedContractMap struct {
+	shards [contractIndexShards]*contractMapShard
+}
+
+func newShardedContractMap() *shardedContractMap {
+	m := &shardedContractMap{}
+	for i := range m.shards {
+		m.shards[i] = &contractMapShard{entries: make(map[string]*ContractInfo)}
+	}
+	return m
+}
+
+func (m *shardedContractMap) shard(key string) *contractMapShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return m.shards[h.Sum32()%contractIndexShards]
+}
+
+// get returns the cached entry for the key, or reads it from the DB on a miss. Only entries
+// that exist are cached. Each caller gets its own copy, which it is free to modify.
+func (m *shardedContractMap) get(key string, read func(string) (*ContractInfo, error)) (*ContractInfo, error) {
+	s := m.shard(key)
+	s.mux.RLock()
+	info, ok := s.entries[key]
+	generation := s.generation
+	s.mux.RUnlock()
+	if !ok {
+		var err error
+		if info, err = read(key); err != nil || info == nil {
+			return info, err
+		}
+		s.mux.Lock()
+		if s.generation == generation {
+			s.entries[key] = info
+		}
+		s.mux.Unlock()
+	}
+	return copyContractInfo(info), nil
+}
+
+// copyContractInfo copies the proxy and labels along with the entry, so a caller that changes
+// them does not change the cached entry
+func copyContractInfo(info *ContractInfo) *ContractInfo {
+	infoCopy := *info
+	if info.Proxy != nil {
+		proxyCopy := *info.Proxy
+		infoCopy.Proxy = &proxyCopy
+	}
+	if info.Labels != nil {
+		infoCopy.Labels = make(map[string]string, len(info.Labels))
+		for k, v := range info.Labels {
+			infoCopy.Labels[k] = v
+		}
+	}
+	return &infoCopy
+}
+
+// invalidate must be called after every write of the key to the DB, whether or not it succeeded
+func (m *shardedContractMap) invalidate(key string) {
+	s := m.shard(key)
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	delete(s.entries, key)
+	s.generation++
+}
+
+func (m *shardedContractMap) reset() {
+	for _, s := range m.shards {
+		s.mux.Lock()
+		s.entries = make(map[string]*ContractInfo)
+		s.generation++
+		s.mux.Unlock()
+	}
+}
+
+// indexedPersistence wraps the persistence layer, invalidating the contract index on each write
+type indexedPersistence struct {
+	ContractStorePersistence
+	index *contractIndex
+}
+
+func newIndexedPersistence(inner ContractStorePersistence, index *contractIndex) *indexedPersistence {
+	return &indexedPersistence{
+		ContractStorePersistence: inner,
+		index:                    index,
+	}
+}
+
+// Init discards anything cached before the DB was (re)opened, such as by a rollback of a migration
+func (p *indexedPersistence) Init() error {
+	p.index.reset()
+	return p.ContractStorePersistence.Init()
+}
+
+func (p *indexedPersistence) PutContract(info *ContractInfo) error {
+	defer p.index.byAddress.invalidate(info.Address)
+	return p.ContractStorePersistence.PutContract(info)
+}
+
+func (p *indexedPersistence) DeleteContract(addrHexNo0x string) error {
+	defer p.index.byAddress.invalidate(addrHexNo0x)
+	return p.ContractStorePersistence.DeleteContract(addrHexNo0x)
+}
+
+func (p *indexedPersistence) PutRegisteredName(info *ContractInfo) error {
+	defer p.index.byName.invalidate(info.RegisteredAs)
+	return p.ContractStorePersistence.PutRegisteredName(info)
+}
+
+func (p *indexedPersistence) DeleteRegisteredName(name string) error {
+	defer p.index.byName.invalidate(name)
+	return p.ContractStorePersistence.DeleteRegisteredName(name)
+}
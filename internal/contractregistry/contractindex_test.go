@@ -0,0 +1,178 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedContractMapGet(t *testing.T) {
+	assert := assert.New(t)
+
+	m := newShardedContractMap()
+	reads := 0
+	read := func(key string) (*ContractInfo, error) {
+		reads++
+		switch key {
+		case "missing":
+			return nil, nil
+		case "bad":
+			return nil, fmt.Errorf("pop")
+		}
+		return &ContractInfo{
+			Address: key,
+			ABI:     "abi1",
+			Proxy:   &ProxyInfo{Standard: "eip1967", Implementation: "bbbb"},
+			Labels:  map[string]string{"team": "a"},
+		}, nil
+	}
+
+	info, err := m.get("aaaa", read)
+	assert.NoError(err)
+	assert.Equal("abi1", info.ABI)
+
+	// Served from the cache, as a copy the caller can modify - including its proxy and labels
+	info.ABI = "changed"
+	info.Proxy.Implementation = "cccc"
+	info.Labels["team"] = "b"
+	info, err = m.get("aaaa", read)
+	assert.NoError(err)
+	assert.Equal("abi1", info.ABI)
+	assert.Equal("bbbb", info.Proxy.Implementation)
+	assert.Equal(map[string]string{"team": "a"}, info.Labels)
+	assert.Equal(1, reads)
+
+	// Read again after an invalidation or reset
+	m.invalidate("aaaa")
+	_, err = m.get("aaaa", read)
+	assert.NoError(err)
+	m.reset()
+	_, err = m.get("aaaa", read)
+	assert.NoError(err)
+	assert.Equal(3, reads)
+
+	// Misses and errors are not cached
+	for i := 0; i < 2; i++ {
+		info, err = m.get("missing", read)
+		assert.NoError(err)
+		assert.Nil(info)
+		_, err = m.get("bad", read)
+		assert.Regexp("pop", err)
+	}
+	assert.Equal(7, reads)
+}
+
+func TestShardedContractMapWriteDuringRead(t *testing.T) {
+	assert := assert.New(t)
+
+	m := newShardedContractMap()
+	stored := &ContractInfo{Address: "aaaa", ABI: "abi1"}
+	_, err := m.get("aaaa", func(key string) (*ContractInfo, error) {
+		// A write replaces the entry after it has been read, but before it is cached
+		read := *stored
+		stored = &ContractInfo{Address: "aaaa", ABI: "abi2"}
+		m.invalidate("aaaa")
+		return &read, nil
+	})
+	assert.NoError(err)
+
+	info, err := m.get("aaaa", func(key string) (*ContractInfo, error) { return stored, nil })
+	assert.NoError(err)
+	assert.Equal("abi2", info.ABI)
+}
+
+func TestShardedContractMapConcurrency(t *testing.T) {
+	m := newShardedContractMap()
+	read := func(key string) (*ContractInfo, error) { return &ContractInfo{Address: key}, nil }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				key := fmt.Sprintf("%d", j%20)
+				info, err := m.get(key, read)
+				assert.NoError(t, err)
+				assert.Equal(t, key, info.Address)
+				if j%(i+3) == 0 {
+					m.invalidate(key)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestContractIndexServesLookups(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+	defer cs.Close()
+
+	_, err = cs.AddContract("123456789abcdef0123456789abcdef012345678", "abi1", "token", "token", nil)
+	assert.NoError(err)
+	info, err := cs.GetContractByAddress("123456789abcdef0123456789abcdef012345678")
+	assert.NoError(err)
+	addr, err := cs.ResolveContractAddress("token")
+	assert.NoError(err)
+	assert.Equal(info.Address, addr)
+
+	// Updates are visible straight away
+	info.ABI = "abi2"
+	err = cs.UpdateContract(info)
+	assert.NoError(err)
+	info, err = cs.GetContractByAddress("123456789abcdef0123456789abcdef012345678")
+	assert.NoError(err)
+	assert.Equal("abi2", info.ABI)
+
+	// Changes applied from a peer, underneath the index, invalidate it
+	inner := cs.(*contractStore).persistence.(*indexedPersistence).ContractStorePersistence
+	p := newReplicatingPersistence(&ReplicationConf{InstanceID: "a"}, inner, cs.(*contractStore).applyReplicated)
+	for _, m := range []*registryMutation{
+		{Origin: "b", Op: mutationPutContract, Contract: &ContractInfo{Address: info.Address, ABI: "abi3", RegisteredAs: "token"}},
+		{Origin: "b", Op: mutationDeleteName, ID: "token"},
+	} {
+		err = p.apply(m)
+		assert.NoError(err)
+		p.onApplied(m)
+	}
+	info, err = cs.GetContractByAddress("123456789abcdef0123456789abcdef012345678")
+	assert.NoError(err)
+	assert.Equal("abi3", info.ABI)
+	_, err = cs.ResolveContractAddress("token")
+	assert.Regexp("FFEC100125", err)
+
+	m := &registryMutation{Origin: "b", Op: mutationPutName, Contract: info}
+	err = p.apply(m)
+	assert.NoError(err)
+	p.onApplied(m)
+	m = &registryMutation{Origin: "b", Op: mutationDeleteContract, ID: info.Address}
+	err = p.apply(m)
+	assert.NoError(err)
+	p.onApplied(m)
+	_, err = cs.ResolveContractAddress("token")
+	assert.NoError(err)
+	_, err = cs.GetContractByAddress("123456789abcdef0123456789abcdef012345678")
+	assert.Regexp("FFEC100126", err)
+}
@@ -25,6 +25,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-openapi/spec"
@@ -32,8 +33,9 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	ethconnecterrors "github.com/hyperledger/firefly-ethconnect/internal/errors"
-	"github.com/hyperledger/firefly-ethconnect/internal/kvstore"
 	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/migrations"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
 )
 
 const (
@@ -52,69 +54,145 @@ type ContractResolver interface {
 	ResolveContractAddress(registeredName string) (string, error)
 	GetContractByAddress(addrHex string) (*ContractInfo, error)
 	GetABI(location ABILocation, refresh bool) (deployMsg *DeployContractWithAddress, err error)
-	CheckNameAvailable(name string, isRemote bool) error
+	CheckNameAvailable(name string) error
 }
 
 type ContractStore interface {
 	ContractResolver
+	LocalResolver() ContractResolver
 	Init() error
 	Close()
-	AddContract(addrHexNo0x, abiID, pathName, registerAs string) (*ContractInfo, error)
+	AddContract(addrHexNo0x, abiID, pathName, registerAs string, labels map[string]string) (*ContractInfo, error)
+	UpdateContract(info *ContractInfo) error
+	RenameContract(addrHex, registerAs string) (*ContractInfo, error)
+	DeleteContract(addrHex string) error
 	AddABI(id string, deployMsg *messages.DeployContract, createdTime time.Time) (*ABIInfo, error)
+	DeleteABI(abiID string) error
+	CheckABIVersionAvailable(registerAs string) error
+	RegisterABIVersion(abiID, registerAs string) (*ABIInfo, error)
 	AddRemoteInstance(lookupStr, address string) error
+	PublishABI(abiID, publishAs string) (string, error)
 	GetLocalABIInfo(abiID string) (*ABIInfo, error)
-	ListContracts() ([]messages.TimeSortable, error)
-	ListABIs() ([]messages.TimeSortable, error)
+	FindABIByContent(deployMsg *messages.DeployContract) (*ABIInfo, error)
+	ListContracts(filter *ListingFilter) ([]messages.TimeSortable, error)
+	ListABIs(filter *ListingFilter) ([]messages.TimeSortable, error)
+	CachedContractListing() (*CachedListing, error)
+	CachedABIListing() (*CachedListing, error)
+	Reindex() (*ReindexResult, error)
+	Export() (*ContractStoreArchive, error)
+	Import(archive *ContractStoreArchive, overwrite bool) (*ImportResult, error)
 }
 
 type ContractStoreConf struct {
-	StoragePath  string `json:"storagePath"`
-	LevelDBName  string `json:"ldbName"`
-	BaseURL      string `json:"baseURL"`
-	ABICacheSize *int   `json:"abiCacheSize"`
+	StoragePath  string                   `json:"storagePath"`
+	LevelDBName  string                   `json:"ldbName"`
+	BaseURL      string                   `json:"baseURL"`
+	ABICacheSize *int                     `json:"abiCacheSize"`
+	RemoteCache  RemoteCacheConf          `json:"remoteCache,omitempty"`
+	Peers        []PeerConf               `json:"peers,omitempty"`
+	Replication  *ReplicationConf         `json:"replication,omitempty"`
+	Migrations   migrations.MigrationConf `json:"migrations,omitempty"`
+	ContentHash  string                   `json:"contentHash,omitempty"`
 }
 
 type contractStore struct {
-	conf     *ContractStoreConf
-	rr       RemoteRegistry
-	db       kvstore.KVStore
-	abiCache *lru.Cache
+	conf            *ContractStoreConf
+	rr              RemoteRegistry
+	persistence     ContractStorePersistence
+	abiCache        *lru.Cache
+	contractListing *listingCache
+	abiListing      *listingCache
+	selectors       *selectorIndex
+	contents        *contentIndex
+	contentHashAlg  string
+	index           *contractIndex
+	peers           []*peer
+	// registrationMux serializes changes to registered names, so a name cannot be taken
+	// between the check that it is available and its registration
+	registrationMux sync.Mutex
+	// indexMux serializes scans of the storage path, so files are not imported twice
+	// by concurrent reindex requests
+	indexMux              sync.Mutex
+	remoteCacheTTL        time.Duration
+	remoteRefreshInterval time.Duration
+	refreshStop           chan struct{}
+	refreshDone           chan struct{}
 }
 
-const (
-	ldbRegisteredNamePrefix  = "registered_name"
-	ldbContractAddressPrefix = "contract_address"
-	ldbABIIDPrefix           = "abi_id"
-)
+// ReindexResult reports the files imported from the storage path by a reindex, and the
+// totals held in the store afterwards
+type ReindexResult struct {
+	MigratedABIs      int `json:"migratedABIs"`
+	MigratedContracts int `json:"migratedContracts"`
+	Failed            int `json:"failed"`
+	ABIs              int `json:"abis"`
+	Contracts         int `json:"contracts"`
+}
 
+// NewContractStore creates a contract store persisted to LevelDB in the storage path
 func NewContractStore(conf *ContractStoreConf, rr RemoteRegistry) ContractStore {
-	return &contractStore{
-		conf: conf,
-		rr:   rr,
+	return NewContractStoreWithPersistence(conf, rr, nil)
+}
+
+// NewContractStoreWithPersistence creates a contract store with the supplied persistence layer,
+// or with LevelDB in the storage path if it is nil
+func NewContractStoreWithPersistence(conf *ContractStoreConf, rr RemoteRegistry, persistence ContractStorePersistence) ContractStore {
+	cs := &contractStore{
+		conf:        conf,
+		rr:          rr,
+		persistence: persistence,
+		peers:       newPeers(conf.Peers),
+
+		remoteCacheTTL:        time.Duration(conf.RemoteCache.TTLSec) * time.Second,
+		remoteRefreshInterval: time.Duration(conf.RemoteCache.RefreshIntervalSec) * time.Second,
 	}
+	cs.contractListing = newListingCache(cs.loadContracts)
+	cs.abiListing = newListingCache(cs.loadABIs)
+	cs.selectors = newSelectorIndex(cs.loadABISelectors)
+	cs.contentHashAlg = conf.ContentHash
+	if cs.contentHashAlg == "" {
+		cs.contentHashAlg = DefaultContentHash
+	}
+	cs.contents = newContentIndex(cs.loadStoredABIs, cs.contentHash)
+	cs.index = newContractIndex()
+	return cs
 }
 
 // ContractInfo is the minimal data structure we keep in memory, indexed by address
 // ONLY used for local registry. Remote registry handles its own storage/caching
 type ContractInfo struct {
 	messages.TimeSorted
-	Address      string `json:"address"`
-	Path         string `json:"path"`
-	ABI          string `json:"abi"`
-	SwaggerURL   string `json:"openapi"`
-	RegisteredAs string `json:"registeredAs"`
+	Address      string            `json:"address"`
+	Path         string            `json:"path"`
+	ABI          string            `json:"abi"`
+	SwaggerURL   string            `json:"openapi"`
+	RegisteredAs string            `json:"registeredAs"`
+	Proxy        *ProxyInfo        `json:"proxy,omitempty"`
+	Peer         string            `json:"peer,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+}
+
+// ProxyInfo is recorded for a contract instance that is a proxy, in front of an implementation
+// contract. The instance is registered with the ABI of the implementation, when it is known.
+type ProxyInfo struct {
+	Standard       string `json:"standard"`
+	Implementation string `json:"implementation"`
 }
 
 // ABIInfo is the minimal data structure we keep in memory, indexed by our own UUID
 type ABIInfo struct {
 	messages.TimeSorted
-	ID              string `json:"id"`
-	Name            string `json:"name"`
-	Description     string `json:"description"`
-	Path            string `json:"path"`
-	Deployable      bool   `json:"deployable"`
-	SwaggerURL      string `json:"openapi"`
-	CompilerVersion string `json:"compilerVersion"`
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	Description     string            `json:"description"`
+	Path            string            `json:"path"`
+	Deployable      bool              `json:"deployable"`
+	SwaggerURL      string            `json:"openapi"`
+	CompilerVersion string            `json:"compilerVersion"`
+	RegisteredAs    string            `json:"registeredAs,omitempty"`
+	Version         string            `json:"version,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	ContentHash     string            `json:"contentHash,omitempty"`
 }
 
 func (i *ContractInfo) GetID() string {
@@ -150,13 +228,16 @@ func IsRemote(msg messages.CommonHeaders) bool {
 	return false
 }
 
-func (cs *contractStore) AddContract(addrHexNo0x, abiID, pathName, registerAs string) (*ContractInfo, error) {
+func (cs *contractStore) AddContract(addrHexNo0x, abiID, pathName, registerAs string, labels map[string]string) (*ContractInfo, error) {
+	// An instance is pinned to the ABI version it was registered with, rather than following the name
+	abiID = cs.resolveABIID(abiID)
 	contractInfo := &ContractInfo{
 		Address:      addrHexNo0x,
 		ABI:          abiID,
 		Path:         "/contracts/" + pathName,
 		SwaggerURL:   cs.conf.BaseURL + "/contracts/" + pathName + "?swagger",
 		RegisteredAs: registerAs,
+		Labels:       labels,
 		TimeSorted: messages.TimeSorted{
 			CreatedISO8601: time.Now().UTC().Format(time.RFC3339),
 		},
@@ -168,38 +249,166 @@ func (cs *contractStore) AddContract(addrHexNo0x, abiID, pathName, registerAs st
 }
 
 func (cs *contractStore) storeContractInfo(info *ContractInfo) error {
+	cs.registrationMux.Lock()
+	defer cs.registrationMux.Unlock()
 	if err := cs.addToContractNameIndex(info); err != nil {
 		return err
 	}
 	log.Infof("%s: Storing contract instance JSON for address '%s'", info.ABI, info.Address)
-	return cs.db.PutJSON(fmt.Sprintf("%s/%s", ldbContractAddressPrefix, info.Address), info)
+	if err := cs.persistence.PutContract(info); err != nil {
+		return err
+	}
+	cs.contractListing.upsert(info)
+	return nil
+}
+
+// UpdateContract replaces the stored information for a registered contract instance,
+// keeping its address and registered name
+func (cs *contractStore) UpdateContract(info *ContractInfo) error {
+	cs.registrationMux.Lock()
+	defer cs.registrationMux.Unlock()
+	if info.RegisteredAs != "" {
+		if err := cs.persistence.PutRegisteredName(info); err != nil {
+			return err
+		}
+	}
+	log.Infof("%s: Updating contract instance JSON for address '%s'", info.ABI, info.Address)
+	if err := cs.persistence.PutContract(info); err != nil {
+		return err
+	}
+	cs.contractListing.upsert(info)
+	return nil
+}
+
+// RenameContract changes the registered name of a contract instance, releasing its previous
+// name. An empty name removes the registered name, so the instance is only available by address.
+func (cs *contractStore) RenameContract(addrHex, registerAs string) (*ContractInfo, error) {
+	cs.registrationMux.Lock()
+	defer cs.registrationMux.Unlock()
+	addrHexNo0x := strings.TrimPrefix(strings.ToLower(addrHex), "0x")
+	info, err := cs.persistence.GetContract(addrHexNo0x)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayLocalStoreContractNotFound, addrHexNo0x)
+	}
+	if info.RegisteredAs == registerAs {
+		return info, nil
+	}
+
+	renamed := *info
+	renamed.RegisteredAs = registerAs
+	pathName := registerAs
+	if pathName == "" {
+		pathName = info.Address
+	}
+	renamed.Path = "/contracts/" + pathName
+	renamed.SwaggerURL = cs.conf.BaseURL + "/contracts/" + pathName + "?swagger"
+	if err := cs.addToContractNameIndex(&renamed); err != nil {
+		return nil, err
+	}
+	log.Infof("%s: Renaming contract instance for address '%s' from '%s' to '%s'", info.ABI, info.Address, info.RegisteredAs, registerAs)
+	if err := cs.persistence.PutContract(&renamed); err != nil {
+		// Release the new name, so the instance remains registered only under its old name
+		if registerAs != "" {
+			_ = cs.persistence.DeleteRegisteredName(registerAs)
+		}
+		return nil, err
+	}
+	if err := cs.releaseRegisteredName(info); err != nil {
+		return nil, err
+	}
+	cs.contractListing.upsert(&renamed)
+	return &renamed, nil
+}
+
+// releaseRegisteredName removes the registered name of a contract instance, if the name
+// still refers to the instance
+func (cs *contractStore) releaseRegisteredName(info *ContractInfo) error {
+	if info.RegisteredAs == "" {
+		return nil
+	}
+	registered, err := cs.persistence.GetRegisteredName(info.RegisteredAs)
+	if err != nil {
+		return err
+	}
+	if registered != nil && registered.Address == info.Address {
+		log.Infof("Removing registered name '%s' for %s", info.RegisteredAs, info.Address)
+		return cs.persistence.DeleteRegisteredName(info.RegisteredAs)
+	}
+	return nil
+}
+
+// DeleteContract removes a registered contract instance, along with its registered name
+// if the name still refers to the instance
+func (cs *contractStore) DeleteContract(addrHex string) error {
+	cs.registrationMux.Lock()
+	defer cs.registrationMux.Unlock()
+	addrHexNo0x := strings.TrimPrefix(strings.ToLower(addrHex), "0x")
+	info, err := cs.persistence.GetContract(addrHexNo0x)
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayLocalStoreContractNotFound, addrHexNo0x)
+	}
+	if err := cs.releaseRegisteredName(info); err != nil {
+		return err
+	}
+	log.Infof("%s: Deleting contract instance for address '%s'", info.ABI, info.Address)
+	if err := cs.persistence.DeleteContract(addrHexNo0x); err != nil {
+		return err
+	}
+	cs.contractListing.remove(addrHexNo0x)
+	return nil
 }
 
 func (cs *contractStore) ResolveContractAddress(registeredName string) (string, error) {
+	return cs.resolveContractAddress(registeredName, true)
+}
+
+func (cs *contractStore) resolveContractAddress(registeredName string, queryPeers bool) (string, error) {
 	nameUnescaped, _ := url.QueryUnescape(registeredName)
-	key := fmt.Sprintf("%s/%s", ldbRegisteredNamePrefix, nameUnescaped)
-	var info ContractInfo
-	err := cs.db.GetJSON(key, &info)
-	if err == kvstore.ErrorNotFound {
-		return "", ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayLocalStoreContractLoad, registeredName)
-	} else if err != nil {
+	info, err := cs.index.byName.get(nameUnescaped, cs.persistence.GetRegisteredName)
+	if err != nil {
 		return "", err
 	}
+	if info == nil {
+		if queryPeers {
+			peerInfo, err := cs.lookupPeerContract(nameUnescaped)
+			if err != nil {
+				return "", err
+			}
+			if peerInfo != nil {
+				return peerInfo.Address, nil
+			}
+		}
+		return "", ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayLocalStoreContractLoad, registeredName)
+	}
 	log.Infof("%s -> 0x%s", registeredName, info.Address)
 	return info.Address, nil
 }
 
 func (cs *contractStore) GetContractByAddress(addrHex string) (*ContractInfo, error) {
+	return cs.getContractByAddress(addrHex, true)
+}
+
+func (cs *contractStore) getContractByAddress(addrHex string, queryPeers bool) (*ContractInfo, error) {
 	addrHexNo0x := strings.TrimPrefix(strings.ToLower(addrHex), "0x")
-	var info ContractInfo
-	err := cs.db.GetJSON(fmt.Sprintf("%s/%s", ldbContractAddressPrefix, addrHexNo0x), &info)
-	if err == kvstore.ErrorNotFound {
-		return nil, ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayLocalStoreContractNotFound, addrHexNo0x)
-	}
+	info, err := cs.index.byAddress.get(addrHexNo0x, cs.persistence.GetContract)
 	if err != nil {
 		return nil, err
 	}
-	return &info, nil
+	if info == nil {
+		if queryPeers {
+			if peerInfo, err := cs.lookupPeerContract(addrHexNo0x); err != nil || peerInfo != nil {
+				return peerInfo, err
+			}
+		}
+		return nil, ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayLocalStoreContractNotFound, addrHexNo0x)
+	}
+	return info, nil
 }
 
 func (cs *contractStore) AddABI(abiID string, deployMsg *messages.DeployContract, createdTime time.Time) (*ABIInfo, error) {
@@ -212,31 +421,105 @@ func (cs *contractStore) AddABI(abiID string, deployMsg *messages.DeployContract
 			CompilerVersion: deployMsg.CompilerVersion,
 			Path:            "/abis/" + abiID,
 			SwaggerURL:      cs.conf.BaseURL + "/abis/" + abiID + "?swagger",
+			Labels:          deployMsg.Labels,
+			ContentHash:     cs.contentHash(deployMsg),
 			TimeSorted: messages.TimeSorted{
 				CreatedISO8601: createdTime.UTC().Format(time.RFC3339),
 			},
 		},
 		DeployMsg: deployMsg,
 	}
-	err := cs.db.PutJSON(fmt.Sprintf("%s/%s", ldbABIIDPrefix, abiID), storedABI)
+	err := cs.persistence.PutABI(storedABI)
 	if err != nil {
 		return nil, err
 	}
+	abiInfo := storedABI.ABIInfo
+	cs.abiListing.upsert(&abiInfo)
+	cs.selectors.add(abiID, deployMsg.ABI)
+	cs.contents.add(abiID, abiInfo.ContentHash)
+	cs.invalidateABI(ABILocation{ABIType: LocalABI, Name: abiID})
 	return &storedABI.ABIInfo, nil
 }
 
+// DeleteABI removes an uploaded ABI, as long as no registered contract instance uses it
+func (cs *contractStore) DeleteABI(abiID string) error {
+	info, err := cs.persistence.GetABIInfo(abiID)
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayLocalStoreABINotFound, abiID)
+	}
+	contracts, err := cs.contractListing.list()
+	if err != nil {
+		return err
+	}
+	for _, c := range contracts {
+		if c.(*ContractInfo).ABI == abiID {
+			return ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayLocalStoreABIInUse, abiID, c.GetID())
+		}
+	}
+	log.Infof("Deleting ABI '%s'", abiID)
+	if err := cs.persistence.DeleteABI(abiID); err != nil {
+		return err
+	}
+	cs.abiListing.remove(abiID)
+	cs.selectors.remove(abiID)
+	cs.contents.remove(abiID)
+	cs.invalidateABI(ABILocation{ABIType: LocalABI, Name: abiID})
+	return nil
+}
+
+// FindABIByContent returns a stored ABI with the same ABI and bytecode as an upload, so it can
+// be used in place of storing a duplicate. Nil is returned if there is none, or content hashing
+// is disabled.
+func (cs *contractStore) FindABIByContent(deployMsg *messages.DeployContract) (*ABIInfo, error) {
+	hash := cs.contentHash(deployMsg)
+	if hash == "" {
+		return nil, nil
+	}
+	abiID, err := cs.contents.lookup(hash)
+	if err != nil || abiID == "" {
+		return nil, err
+	}
+	return cs.persistence.GetABIInfo(abiID)
+}
+
+func (cs *contractStore) contentHash(deployMsg *messages.DeployContract) string {
+	return contentHash(cs.contentHashAlg, deployMsg)
+}
+
 // GetLocalABIInfo retrieves just the minimal ABIInfo sub-set of the JSON fields from the contract
 // store for local ABI definitions (ones uploaded on the /abis endpoint).
-func (cs *contractStore) GetLocalABIInfo(abiID string) (info *ABIInfo, err error) {
-	return info, cs.db.GetJSON(fmt.Sprintf("%s/%s", ldbABIIDPrefix, abiID), &info)
+func (cs *contractStore) GetLocalABIInfo(abiID string) (*ABIInfo, error) {
+	abiID = cs.resolveABIID(abiID)
+	info, err := cs.persistence.GetABIInfo(abiID)
+	if err == nil && info == nil {
+		err = ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayLocalStoreABINotFound, abiID)
+	}
+	return info, err
 }
 
 func (cs *contractStore) GetABI(location ABILocation, refresh bool) (deployMsg *DeployContractWithAddress, err error) {
+	return cs.getABI(location, refresh, true)
+}
+
+func (cs *contractStore) getABI(location ABILocation, refresh, queryPeers bool) (deployMsg *DeployContractWithAddress, err error) {
+	if location.ABIType == LocalABI {
+		// Cached by ID, so a name resolves to a newly registered latest version straight away
+		location.Name = cs.resolveABIID(location.Name)
+	}
+	// An expired remote ABI is served if it cannot be reloaded, rather than failing the request
+	var stale *DeployContractWithAddress
 	if !refresh {
 		if cached, ok := cs.abiCache.Get(location); ok {
-			result := cached.(*DeployContractWithAddress)
-			log.Infof("Loaded contract from cache: %+v", location)
-			return result, nil
+			entry := cached.(*cachedABI)
+			if !cs.expired(location, entry) {
+				log.Infof("Loaded contract from cache: %+v", location)
+				return entry.deployMsg, nil
+			}
+			log.Infof("Cached contract expired: %+v", location)
+			stale, refresh = entry.deployMsg, true
 		}
 	}
 
@@ -248,61 +531,119 @@ func (cs *contractStore) GetABI(location ABILocation, refresh bool) (deployMsg *
 		deployMsg, err = cs.rr.LoadFactoryForInstance(location.Name, refresh)
 	case LocalABI:
 		deployMsg, err = cs.getDeployContractByABIID(location.Name)
+		if err != nil && queryPeers && len(cs.peers) > 0 {
+			if peerMsg := cs.lookupPeerABI(location.Name); peerMsg != nil {
+				deployMsg, err = peerMsg, nil
+			}
+		}
 	default:
 		panic("unknown ABI type") // should not happen
 	}
 
+	if err != nil && stale != nil {
+		log.Warnf("Failed to reload expired contract %+v. Using cached copy: %s", location, err)
+		return stale, nil
+	}
+	if err == nil && (deployMsg == nil || deployMsg.Contract == nil) {
+		// The definition no longer exists, so must not be served from the cache
+		cs.invalidateABI(location)
+	}
 	if err != nil || deployMsg == nil || deployMsg.Contract == nil {
 		return nil, err
 	}
 	log.Infof("Adding contract to cache: %+v", location)
-	cs.abiCache.Add(location, deployMsg)
+	cs.abiCache.Add(location, &cachedABI{deployMsg: deployMsg, loaded: time.Now()})
 	return deployMsg, nil
 }
 
-func (cs *contractStore) getDeployContractByABIID(abiID string) (*DeployContractWithAddress, error) {
-	var storedABI StoredABI
-	err := cs.db.GetJSON(fmt.Sprintf("%s/%s", ldbABIIDPrefix, abiID), &storedABI)
-	if err == kvstore.ErrorNotFound {
-		log.Infof("ABI with ID %s not found locally", abiID)
-		return nil, ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayLocalStoreABINotFound, abiID)
+// invalidateABI removes an ABI from the cache when it is updated or deleted, rather
+// than waiting for it to be evicted
+func (cs *contractStore) invalidateABI(location ABILocation) {
+	if cs.abiCache != nil && cs.abiCache.Remove(location) {
+		log.Infof("Removed contract from cache: %+v", location)
 	}
+}
+
+func (cs *contractStore) getDeployContractByABIID(abiID string) (*DeployContractWithAddress, error) {
+	storedABI, err := cs.persistence.GetABI(abiID)
 	if err != nil {
 		return nil, err
 	}
+	if storedABI == nil {
+		log.Infof("ABI with ID %s not found locally", abiID)
+		return nil, ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayLocalStoreABINotFound, abiID)
+	}
 	return &DeployContractWithAddress{Contract: storedABI.DeployMsg}, nil
 }
 
-func (cs *contractStore) migrateFilesToLevelDB() {
-	legacyContractMatcher, _ := regexp.Compile(`^contract_([0-9a-z]{40})\.swagger\.json$`)
+func (cs *contractStore) migrateFilesToLevelDB() (*ReindexResult, error) {
 	instanceMatcher, _ := regexp.Compile(`^contract_([0-9a-z]{40})\.instance\.json$`)
 	abiMatcher, _ := regexp.Compile(`^abi_([0-9a-z-]+)\.deploy.json$`)
 	files, err := ioutil.ReadDir(cs.conf.StoragePath)
 	if err != nil {
-		log.Errorf("Failed to read directory %s: %s", cs.conf.StoragePath, err)
-		return
+		return nil, ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayReadStoragePathFailed, cs.conf.StoragePath, err)
 	}
+	result := &ReindexResult{}
 	for _, file := range files {
 		if !file.IsDir() {
 			fileName := file.Name()
 			log.Infof("Checking file for LevelDB migration: %s", fileName)
-			legacyContractGroups := legacyContractMatcher.FindStringSubmatch(fileName)
 			abiGroups := abiMatcher.FindStringSubmatch(fileName)
 			instanceGroups := instanceMatcher.FindStringSubmatch(fileName)
 			cleanup := false
 			filePath := path.Join(cs.conf.StoragePath, fileName)
-			if legacyContractGroups != nil {
-				cleanup = cs.migrateLegacyContractFile(legacyContractGroups[1], filePath, file.ModTime())
-			} else if instanceGroups != nil {
+			if instanceGroups != nil {
 				cleanup = cs.migrateContractFile(instanceGroups[1], filePath, file.ModTime())
+				result.count(cleanup, &result.MigratedContracts)
 			} else if abiGroups != nil {
 				cleanup = cs.migrateABIFile(abiGroups[1], filePath, file.ModTime())
+				result.count(cleanup, &result.MigratedABIs)
 			}
 			if cleanup {
 				cs.cleanupMigratedFile(filePath)
 			}
 		}
 	}
+	return result, nil
+}
+
+func (r *ReindexResult) count(migrated bool, counter *int) {
+	if migrated {
+		*counter++
+	} else {
+		r.Failed++
+	}
+}
+
+// Reindex imports ABI and contract files copied into the storage path since startup, in the
+// same way as on Init, then discards the in-memory listings, indexes and ABI cache so they
+// are rebuilt from the DB on next use
+func (cs *contractStore) Reindex() (*ReindexResult, error) {
+	cs.indexMux.Lock()
+	defer cs.indexMux.Unlock()
+	result, err := cs.migrateFilesToLevelDB()
+	if err != nil {
+		return nil, err
+	}
+	cs.contractListing.reset()
+	cs.abiListing.reset()
+	cs.selectors.reset()
+	cs.contents.reset()
+	cs.index.reset()
+	cs.abiCache.Purge()
+
+	abis, err := cs.abiListing.list()
+	if err != nil {
+		return nil, err
+	}
+	contracts, err := cs.contractListing.list()
+	if err != nil {
+		return nil, err
+	}
+	result.ABIs = len(abis)
+	result.Contracts = len(contracts)
+	log.Infof("Reindexed contract store: %+v", result)
+	return result, nil
 }
 
 func (cs *contractStore) cleanupMigratedFile(filePath string) {
@@ -316,6 +657,9 @@ func (cs *contractStore) Init() (err error) {
 	if cs.conf.StoragePath == "" {
 		return ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayMissingStoragePath, err)
 	}
+	if _, ok := contentHashAlgorithms[cs.contentHashAlg]; !ok && cs.contentHashAlg != ContentHashNone {
+		return ethconnecterrors.Errorf(ethconnecterrors.ContractStoreUnknownContentHash, cs.contentHashAlg)
+	}
 
 	cacheSize := DefaultABICacheSize
 	if cs.conf.ABICacheSize != nil {
@@ -324,20 +668,97 @@ func (cs *contractStore) Init() (err error) {
 	if cs.abiCache, err = lru.New(cacheSize); err != nil {
 		return ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayResourceErr, err)
 	}
-	ldbName := cs.conf.LevelDBName
-	if ldbName != "" {
-		ldbName = DefaultLevelDBName
+	if cs.persistence == nil {
+		ldbName := cs.conf.LevelDBName
+		if ldbName != "" {
+			ldbName = DefaultLevelDBName
+		}
+		cs.persistence = NewLevelDBContractPersistence(path.Join(cs.conf.StoragePath, ldbName))
+	}
+	if cs.conf.Replication != nil {
+		cs.persistence = newReplicatingPersistence(cs.conf.Replication, cs.persistence, cs.applyReplicated)
+	}
+	cs.persistence = newIndexedPersistence(cs.persistence, cs.index)
+	migrator := migrations.NewMigrator("contract store", cs.conf.StoragePath, &cs.conf.Migrations, cs.migrations()...)
+	if _, err = migrator.Run(cs.persistence.Init, cs.persistence.Close); err != nil {
+		return err
+	}
+	cs.indexMux.Lock()
+	_, err = cs.migrateFilesToLevelDB()
+	cs.indexMux.Unlock()
+	if err != nil {
+		log.Errorf("LevelDB migration skipped: %s", err)
 	}
-	dbPath := path.Join(cs.conf.StoragePath, ldbName)
-	if cs.db, err = kvstore.NewLDBKeyValueStore(dbPath); err != nil {
+	if err = cs.rr.Init(); err != nil {
 		return err
 	}
-	cs.migrateFilesToLevelDB()
-	return cs.rr.Init()
+	cs.startRemoteRefresh()
+	return nil
+}
+
+// applyReplicated updates the in-memory listings, indexes and ABI cache for a change made
+// by a peer, once it has been written to the local persistence
+func (cs *contractStore) applyReplicated(m *registryMutation) {
+	switch m.Op {
+	case mutationPutContract:
+		cs.index.byAddress.invalidate(m.Contract.Address)
+		cs.contractListing.upsert(m.Contract)
+	case mutationDeleteContract:
+		cs.index.byAddress.invalidate(m.ID)
+		cs.contractListing.remove(m.ID)
+	case mutationPutName:
+		cs.index.byName.invalidate(m.Contract.RegisteredAs)
+	case mutationDeleteName:
+		cs.index.byName.invalidate(m.ID)
+	case mutationPutABI:
+		abiInfo := m.ABI.ABIInfo
+		cs.abiListing.upsert(&abiInfo)
+		if m.ABI.DeployMsg != nil {
+			cs.selectors.add(m.ABI.ID, m.ABI.DeployMsg.ABI)
+			cs.contents.add(m.ABI.ID, cs.contentHash(m.ABI.DeployMsg))
+		}
+		cs.invalidateABI(ABILocation{ABIType: LocalABI, Name: m.ABI.ID})
+	case mutationDeleteABI:
+		cs.abiListing.remove(m.ID)
+		cs.selectors.remove(m.ID)
+		cs.contents.remove(m.ID)
+		cs.invalidateABI(ABILocation{ABIType: LocalABI, Name: m.ID})
+	}
+}
+
+// localResolver only resolves contracts and ABIs registered in this instance, without
+// querying peers
+type localResolver struct {
+	cs *contractStore
+}
+
+// LocalResolver returns a resolver that does not query peers, for serving lookups made by peers
+func (cs *contractStore) LocalResolver() ContractResolver {
+	return &localResolver{cs: cs}
+}
+
+func (l *localResolver) ResolveContractAddress(registeredName string) (string, error) {
+	return l.cs.resolveContractAddress(registeredName, false)
+}
+
+func (l *localResolver) GetContractByAddress(addrHex string) (*ContractInfo, error) {
+	return l.cs.getContractByAddress(addrHex, false)
+}
+
+func (l *localResolver) GetABI(location ABILocation, refresh bool) (*DeployContractWithAddress, error) {
+	return l.cs.getABI(location, refresh, false)
+}
+
+func (l *localResolver) CheckNameAvailable(name string) error {
+	return l.cs.checkLocalNameAvailable(name)
 }
 
 func (cs *contractStore) Close() {
+	cs.stopRemoteRefresh()
 	cs.rr.Close()
+	if cs.persistence != nil {
+		cs.persistence.Close()
+	}
 }
 
 func (cs *contractStore) migrateABIFile(abiID, fileName string, createdTime time.Time) bool {
@@ -406,7 +827,7 @@ func (cs *contractStore) migrateLegacyContractFile(address, fileName string, cre
 		registeredAs = ext.(string)
 	}
 	if ext, exists := swagger.Info.Extensions["x-firefly-deployment-id"]; exists {
-		_, err := cs.AddContract(address, ext.(string), address, registeredAs)
+		_, err := cs.AddContract(address, ext.(string), address, registeredAs, nil)
 		if err != nil {
 			log.Errorf("Failed to write migrated instance file to LevelDB: %s", err)
 			return false
@@ -419,24 +840,42 @@ func (cs *contractStore) migrateLegacyContractFile(address, fileName string, cre
 	return true
 }
 
-func (cs *contractStore) CheckNameAvailable(registerAs string, isRemote bool) error {
-	if isRemote {
-		msg, err := cs.rr.LoadFactoryForInstance(registerAs, false)
-		if err != nil {
-			return err
-		} else if msg != nil {
-			return ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayFriendlyNameClash, msg.Address, registerAs)
+// CheckNameAvailable checks a name is free both in the local registry and, when one is configured,
+// in the remote registry, so a registration fails up front wherever it would be registered.
+// When the name is taken in the remote registry, the error says which registry each address is in.
+func (cs *contractStore) CheckNameAvailable(registerAs string) error {
+	var clashes []string
+	info, err := cs.persistence.GetRegisteredName(registerAs)
+	if err != nil {
+		return err
+	}
+	if info != nil {
+		clashes = append(clashes, info.Address)
+	}
+	msg, err := cs.rr.LoadFactoryForInstance(registerAs, false)
+	if err != nil {
+		return err
+	}
+	if msg != nil {
+		if info != nil {
+			clashes[0] = fmt.Sprintf("%s (local registry)", info.Address)
 		}
-		return nil
+		clashes = append(clashes, fmt.Sprintf("%s (remote registry)", msg.Address))
 	}
-	var info ContractInfo
-	err := cs.db.GetJSON(fmt.Sprintf("%s/%s", ldbRegisteredNamePrefix, registerAs), &info)
-	if err == nil {
-		return ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayFriendlyNameClash, info.Address, registerAs)
+	if len(clashes) > 0 {
+		return ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayFriendlyNameClash, strings.Join(clashes, " and "), registerAs)
 	}
-	if err != kvstore.ErrorNotFound {
+	return nil
+}
+
+func (cs *contractStore) checkLocalNameAvailable(registerAs string) error {
+	info, err := cs.persistence.GetRegisteredName(registerAs)
+	if err != nil {
 		return err
 	}
+	if info != nil {
+		return ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayFriendlyNameClash, info.Address, registerAs)
+	}
 	return nil
 }
 
@@ -445,51 +884,185 @@ func (cs *contractStore) addToContractNameIndex(info *ContractInfo) error {
 		return nil
 	}
 	// Protect against overwrite
-	if err := cs.CheckNameAvailable(info.RegisteredAs, false); err != nil {
+	if err := cs.checkLocalNameAvailable(info.RegisteredAs); err != nil {
 		return err
 	}
 	log.Infof("Registering %s as '%s'", info.Address, info.RegisteredAs)
-	return cs.db.PutJSON(fmt.Sprintf("%s/%s", ldbRegisteredNamePrefix, info.RegisteredAs), info)
+	return cs.persistence.PutRegisteredName(info)
 }
 
 func (cs *contractStore) AddRemoteInstance(lookupStr, address string) error {
-	return cs.rr.RegisterInstance(lookupStr, address)
+	if err := cs.rr.RegisterInstance(lookupStr, address); err != nil {
+		return err
+	}
+	cs.invalidateABI(ABILocation{ABIType: RemoteInstance, Name: lookupStr})
+	return nil
+}
+
+// PublishABI pushes a locally stored ABI to the gateway collection of the remote registry, under
+// the name of the ABI unless another is supplied, and returns the name it was published as
+func (cs *contractStore) PublishABI(abiID, publishAs string) (string, error) {
+	info, err := cs.GetLocalABIInfo(abiID)
+	if err != nil {
+		return "", err
+	}
+	deployMsg, err := cs.getDeployContractByABIID(abiID)
+	if err != nil {
+		return "", err
+	}
+	if publishAs == "" {
+		publishAs = info.Name
+	}
+	if err := cs.rr.PublishGateway(publishAs, deployMsg.Contract); err != nil {
+		return "", err
+	}
+	cs.invalidateABI(ABILocation{ABIType: RemoteGateway, Name: publishAs})
+	return publishAs, nil
 }
 
-func (cs *contractStore) ListContracts() ([]messages.TimeSortable, error) {
-	retval := make([]messages.TimeSortable, 0)
-	it := cs.db.NewIteratorWithRange(&kvstore.Range{
-		Start: []byte(ldbContractAddressPrefix + "/"), // the beginning of the key sets with the `prefix/`
-		Limit: []byte(ldbContractAddressPrefix + "0"), // this is after the last key with a `prefix/` ('0' is after '/')
+// ListContracts returns the sorted list of locally registered contract instances, optionally filtered
+func (cs *contractStore) ListContracts(filter *ListingFilter) ([]messages.TimeSortable, error) {
+	items, err := cs.contractListing.list()
+	if err != nil {
+		return nil, err
+	}
+	if items, err = cs.filterBySelector(items, filter); err != nil {
+		return nil, err
+	}
+	return filter.apply(items), nil
+}
+
+// CachedContractListing returns the serialized contract listing, only re-building it after a change
+func (cs *contractStore) CachedContractListing() (*CachedListing, error) {
+	return cs.contractListing.cached()
+}
+
+func (cs *contractStore) loadContracts() ([]messages.TimeSortable, error) {
+	contracts, err := cs.persistence.ListContracts()
+	if err != nil {
+		return nil, err
+	}
+	retval := make([]messages.TimeSortable, len(contracts))
+	for i, info := range contracts {
+		retval[i] = info
+	}
+	sortListing(retval)
+	return retval, nil
+}
+
+// ListABIs returns the sorted list of locally stored ABIs, optionally filtered
+func (cs *contractStore) ListABIs(filter *ListingFilter) ([]messages.TimeSortable, error) {
+	items, err := cs.abiListing.list()
+	if err != nil {
+		return nil, err
+	}
+	if items, err = cs.filterBySelector(items, filter); err != nil {
+		return nil, err
+	}
+	if items, err = cs.filterByContentHash(items, filter); err != nil {
+		return nil, err
+	}
+	return filter.apply(items), nil
+}
+
+// filterByContentHash narrows an ABI listing to the ABIs with the content hash of the filter
+func (cs *contractStore) filterByContentHash(items []messages.TimeSortable, filter *ListingFilter) ([]messages.TimeSortable, error) {
+	if filter == nil || filter.ContentHash == "" {
+		return items, nil
+	}
+	abiIDs, err := cs.contents.matching(strings.ToLower(filter.ContentHash))
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]messages.TimeSortable, 0)
+	for _, item := range items {
+		if abiIDs[item.GetID()] {
+			matched = append(matched, item)
+		}
+	}
+	return matched, nil
+}
+
+// CachedABIListing returns the serialized ABI listing, only re-building it after a change
+func (cs *contractStore) CachedABIListing() (*CachedListing, error) {
+	return cs.abiListing.cached()
+}
+
+func (cs *contractStore) loadABIs() ([]messages.TimeSortable, error) {
+	abis, err := cs.persistence.ListABIs()
+	if err != nil {
+		return nil, err
+	}
+	retval := make([]messages.TimeSortable, len(abis))
+	for i, info := range abis {
+		retval[i] = info
+	}
+	sortListing(retval)
+	return retval, nil
+}
+
+// filterBySelector narrows a listing to the contracts or ABIs whose ABI contains the method
+// and event of the filter, before paging is applied
+func (cs *contractStore) filterBySelector(items []messages.TimeSortable, filter *ListingFilter) ([]messages.TimeSortable, error) {
+	if filter == nil || (filter.Method == "" && filter.Event == "") {
+		return items, nil
+	}
+	abiIDs, err := cs.selectors.lookup(filter.Method, filter.Event)
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]messages.TimeSortable, 0)
+	for _, item := range items {
+		var abiID string
+		switch i := item.(type) {
+		case *ContractInfo:
+			abiID = i.ABI
+		case *ABIInfo:
+			abiID = i.ID
+		}
+		if abiIDs[abiID] {
+			matched = append(matched, item)
+		}
+	}
+	return matched, nil
+}
+
+// loadStoredABIs reads every stored ABI, oldest first
+func (cs *contractStore) loadStoredABIs() ([]*StoredABI, error) {
+	abis, err := cs.persistence.ListABIs()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(abis, func(i, j int) bool {
+		return abis[i].CreatedISO8601 < abis[j].CreatedISO8601
 	})
-	for it.Next() {
-		var info ContractInfo
-		if err := it.ValueJSON(&info); err != nil {
+	retval := make([]*StoredABI, 0, len(abis))
+	for _, info := range abis {
+		storedABI, err := cs.persistence.GetABI(info.ID)
+		if err != nil {
 			return nil, err
 		}
-		retval = append(retval, &info)
+		if storedABI != nil {
+			retval = append(retval, storedABI)
+		}
 	}
-	sort.Slice(retval, func(i, j int) bool {
-		return retval[i].IsLessThan(retval[i], retval[j])
-	})
 	return retval, nil
 }
 
-func (cs *contractStore) ListABIs() ([]messages.TimeSortable, error) {
-	retval := make([]messages.TimeSortable, 0)
-	it := cs.db.NewIteratorWithRange(&kvstore.Range{
-		Start: []byte(ldbABIIDPrefix + "/"), // the beginning of the key sets with the `prefix/`
-		Limit: []byte(ldbABIIDPrefix + "0"), // this is after the last key with a `prefix/` ('0' is after '/')
-	})
-	for it.Next() {
-		var info ABIInfo // we only de-serialize the ABIInfo part of the full record
-		if err := it.ValueJSON(&info); err != nil {
+func (cs *contractStore) loadABISelectors() (map[string]ethbinding.ABIMarshaling, error) {
+	abis, err := cs.persistence.ListABIs()
+	if err != nil {
+		return nil, err
+	}
+	retval := make(map[string]ethbinding.ABIMarshaling, len(abis))
+	for _, info := range abis {
+		storedABI, err := cs.persistence.GetABI(info.ID)
+		if err != nil {
 			return nil, err
 		}
-		retval = append(retval, &info)
+		if storedABI != nil && storedABI.DeployMsg != nil {
+			retval[info.ID] = storedABI.DeployMsg.ABI
+		}
 	}
-	sort.Slice(retval, func(i, j int) bool {
-		return retval[i].IsLessThan(retval[i], retval[j])
-	})
 	return retval, nil
 }
@@ -18,6 +18,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path"
 	"testing"
 	"time"
@@ -45,6 +46,9 @@ func (rr *mockRR) LoadFactoryForInstance(id string, refresh bool) (*DeployContra
 func (rr *mockRR) RegisterInstance(lookupStr, address string) error {
 	return rr.err
 }
+func (rr *mockRR) PublishGateway(lookupStr string, deployMsg *messages.DeployContract) error {
+	return rr.err
+}
 func (rr *mockRR) Close()      {}
 func (rr *mockRR) Init() error { return nil }
 
@@ -128,7 +132,7 @@ func TestLoadDeployMsgFailure(t *testing.T) {
 	err := cs.Init()
 	assert.NoError(err)
 
-	cs.(*contractStore).db.Put(fmt.Sprintf("%s/%s", ldbABIIDPrefix, "abi1"), []byte(":bad json"))
+	testLevelDB(cs).Put(fmt.Sprintf("%s/%s", ldbABIIDPrefix, "abi1"), []byte(":bad json"))
 	_, err = cs.GetABI(ABILocation{
 		ABIType: LocalABI,
 		Name:    "abi1",
@@ -145,7 +149,7 @@ func TestStoreABIWriteFail(t *testing.T) {
 	err := cs.Init()
 	assert.NoError(err)
 
-	cs.(*contractStore).db.Close()
+	testLevelDB(cs).Close()
 
 	i := &ContractInfo{
 		Address: "req1",
@@ -177,9 +181,10 @@ func TestCheckNameAvailableRRDuplicate(t *testing.T) {
 	dir := tempdir()
 	defer cleanup(dir)
 	cs := NewContractStore(&ContractStoreConf{BaseURL: "http://localhost/api/v1", StoragePath: dir}, mrr)
+	cs.Init()
 
-	err := cs.CheckNameAvailable("lobster", true)
-	assert.Regexp("Contract address 12345 is already registered for name 'lobster'", err)
+	err := cs.CheckNameAvailable("lobster")
+	assert.Regexp("FFEC100133.*Contract address 12345 \\(remote registry\\) is already registered for name 'lobster'", err)
 }
 
 func TestCheckNameAvailableRRFail(t *testing.T) {
@@ -191,8 +196,9 @@ func TestCheckNameAvailableRRFail(t *testing.T) {
 	dir := tempdir()
 	defer cleanup(dir)
 	cs := NewContractStore(&ContractStoreConf{BaseURL: "http://localhost/api/v1", StoragePath: dir}, mrr)
+	cs.Init()
 
-	err := cs.CheckNameAvailable("lobster", true)
+	err := cs.CheckNameAvailable("lobster")
 	assert.Regexp("pop", err)
 }
 
@@ -203,8 +209,9 @@ func TestCheckNameAvailableRROk(t *testing.T) {
 	dir := tempdir()
 	defer cleanup(dir)
 	cs := NewContractStore(&ContractStoreConf{BaseURL: "http://localhost/api/v1", StoragePath: dir}, mrr)
+	cs.Init()
 
-	err := cs.CheckNameAvailable("lobster", true)
+	err := cs.CheckNameAvailable("lobster")
 	assert.NoError(err)
 }
 
@@ -217,10 +224,32 @@ func TestCheckNameAvailableLocalFail(t *testing.T) {
 	cs := NewContractStore(&ContractStoreConf{BaseURL: "http://localhost/api/v1", StoragePath: dir}, mrr)
 	cs.Init()
 
-	cs.AddContract("0x12345", "ab1", "name", "lobster")
+	cs.AddContract("0x12345", "ab1", "name", "lobster", nil)
+
+	err := cs.CheckNameAvailable("lobster")
+	assert.Regexp("FFEC100133.*Contract address 0x12345 is already registered for name 'lobster'$", err)
+}
+
+func TestCheckNameAvailableLocalAndRemoteClash(t *testing.T) {
+	assert := assert.New(t)
+
+	mrr := &mockRR{
+		deployMsg: &DeployContractWithAddress{Address: "0x67890"},
+	}
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := NewContractStore(&ContractStoreConf{BaseURL: "http://localhost/api/v1", StoragePath: dir}, mrr)
+	cs.Init()
+
+	cs.AddContract("0x12345", "ab1", "name", "lobster", nil)
 
-	err := cs.CheckNameAvailable("lobster", false)
+	err := cs.CheckNameAvailable("lobster")
+	assert.Regexp("FFEC100133.*Contract address 0x12345 \\(local registry\\) and 0x67890 \\(remote registry\\) is already registered for name 'lobster'", err)
+
+	// Only names registered locally are checked by the local resolver
+	err = cs.LocalResolver().CheckNameAvailable("lobster")
 	assert.Regexp("FFEC100133", err)
+	assert.NoError(cs.LocalResolver().CheckNameAvailable("crab"))
 }
 
 func TestAddRemoteInstance(t *testing.T) {
@@ -238,6 +267,125 @@ func TestAddRemoteInstance(t *testing.T) {
 	assert.Regexp("pop", err)
 }
 
+func TestPublishABI(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+
+	mrr := &mockRR{}
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, mrr)
+	err := cs.Init()
+	assert.NoError(err)
+	defer cs.Close()
+
+	_, err = cs.AddABI("abi1", &messages.DeployContract{ContractName: "simpleevents"}, time.Now())
+	assert.NoError(err)
+	location := ABILocation{ABIType: RemoteGateway, Name: "simpleevents"}
+	mrr.deployMsg = &DeployContractWithAddress{Contract: &messages.DeployContract{ContractName: "remote"}}
+	_, err = cs.GetABI(location, false)
+	assert.NoError(err)
+	assert.True(cs.(*contractStore).abiCache.Contains(location))
+
+	name, err := cs.PublishABI("abi1", "")
+	assert.NoError(err)
+	assert.Equal("simpleevents", name)
+	assert.False(cs.(*contractStore).abiCache.Contains(location))
+
+	mrr.err = fmt.Errorf("pop")
+	_, err = cs.PublishABI("abi1", "other")
+	assert.Regexp("pop", err)
+
+	_, err = cs.PublishABI("missing", "")
+	assert.Regexp("FFEC100127", err)
+}
+
+func TestABICacheInvalidatedOnUpdate(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+	defer cs.Close()
+
+	location := ABILocation{ABIType: LocalABI, Name: "abi1"}
+	_, err = cs.AddABI("abi1", &messages.DeployContract{ContractName: "v1"}, time.Now())
+	assert.NoError(err)
+	info, err := cs.GetABI(location, false)
+	assert.NoError(err)
+	assert.Equal("v1", info.Contract.ContractName)
+
+	_, err = cs.AddABI("abi1", &messages.DeployContract{ContractName: "v2"}, time.Now())
+	assert.NoError(err)
+	assert.False(cs.(*contractStore).abiCache.Contains(location))
+	info, err = cs.GetABI(location, false)
+	assert.NoError(err)
+	assert.Equal("v2", info.Contract.ContractName)
+}
+
+func TestABICacheInvalidatedOnRemoteRefresh(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+
+	mrr := &mockRR{
+		deployMsg: &DeployContractWithAddress{
+			Contract: &messages.DeployContract{ContractName: "remote"},
+			Address:  "12345",
+		},
+	}
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, mrr)
+	err := cs.Init()
+	assert.NoError(err)
+	defer cs.Close()
+
+	location := ABILocation{ABIType: RemoteInstance, Name: "lobster"}
+	info, err := cs.GetABI(location, false)
+	assert.NoError(err)
+	assert.Equal("remote", info.Contract.ContractName)
+
+	// An error on refresh leaves the cached copy in place
+	mrr.err = fmt.Errorf("pop")
+	mrr.deployMsg = nil
+	_, err = cs.GetABI(location, true)
+	assert.Regexp("pop", err)
+	assert.True(cs.(*contractStore).abiCache.Contains(location))
+
+	// Removal from the remote registry removes the cached copy
+	mrr.err = nil
+	info, err = cs.GetABI(location, true)
+	assert.NoError(err)
+	assert.Nil(info)
+	assert.False(cs.(*contractStore).abiCache.Contains(location))
+}
+
+func TestABICacheInvalidatedOnRemoteRegister(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+
+	mrr := &mockRR{
+		deployMsg: &DeployContractWithAddress{
+			Contract: &messages.DeployContract{ContractName: "remote"},
+			Address:  "12345",
+		},
+	}
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, mrr)
+	err := cs.Init()
+	assert.NoError(err)
+	defer cs.Close()
+
+	location := ABILocation{ABIType: RemoteInstance, Name: "lobster"}
+	_, err = cs.GetABI(location, false)
+	assert.NoError(err)
+	assert.True(cs.(*contractStore).abiCache.Contains(location))
+
+	err = cs.AddRemoteInstance("lobster", "0x67890")
+	assert.NoError(err)
+	assert.False(cs.(*contractStore).abiCache.Contains(location))
+}
+
 func TestLDBlBadDir(t *testing.T) {
 	assert := assert.New(t)
 	dir := tempdir()
@@ -255,7 +403,63 @@ func TestMigrateFilesToLevelBadDir(t *testing.T) {
 	cleanup(dir)
 
 	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, &mockRR{})
-	cs.(*contractStore).migrateFilesToLevelDB()
+	_, err := cs.(*contractStore).migrateFilesToLevelDB()
+	assert.Regexp(t, "FFEC100368", err)
+	_, err = cs.Reindex()
+	assert.Regexp(t, "FFEC100368", err)
+}
+
+func TestReindexImportsFilesAddedWhileRunning(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+	defer cs.Close()
+
+	_, err = cs.AddABI("abi1", &messages.DeployContract{ContractName: "existing"}, time.Now())
+	assert.NoError(err)
+	abis, err := cs.ListABIs(nil)
+	assert.NoError(err)
+	assert.Len(abis, 1)
+
+	deployBytes, _ := json.Marshal(&messages.DeployContract{ContractName: "provisioned"})
+	ioutil.WriteFile(path.Join(dir, "abi_840b629f-2e46-413b-9671-553a886ca7bb.deploy.json"), deployBytes, 0644)
+	ioutil.WriteFile(path.Join(dir, "abi_519526b2-0879-41f4-93c0-09acaa62e2da.deploy.json"), []byte(":bad json"), 0644)
+	infoBytes, _ := json.Marshal(&ContractInfo{
+		Address:      "456789abcdef0123456789abcdef012345678901",
+		ABI:          "840b629f-2e46-413b-9671-553a886ca7bb",
+		RegisteredAs: "provisioned",
+	})
+	ioutil.WriteFile(path.Join(dir, "contract_456789abcdef0123456789abcdef012345678901.instance.json"), infoBytes, 0644)
+
+	result, err := cs.Reindex()
+	assert.NoError(err)
+	assert.Equal(&ReindexResult{
+		MigratedABIs:      1,
+		MigratedContracts: 1,
+		Failed:            1,
+		ABIs:              2,
+		Contracts:         1,
+	}, result)
+
+	abis, err = cs.ListABIs(nil)
+	assert.NoError(err)
+	assert.Len(abis, 2)
+	deployMsg, err := cs.GetABI(ABILocation{ABIType: LocalABI, Name: "840b629f-2e46-413b-9671-553a886ca7bb"}, false)
+	assert.NoError(err)
+	assert.Equal("provisioned", deployMsg.Contract.ContractName)
+	addr, err := cs.ResolveContractAddress("provisioned")
+	assert.NoError(err)
+	assert.Equal("456789abcdef0123456789abcdef012345678901", addr)
+	listing, err := cs.CachedContractListing()
+	assert.NoError(err)
+	assert.Contains(string(listing.JSON), "provisioned")
+
+	_, err = os.Stat(path.Join(dir, "abi_840b629f-2e46-413b-9671-553a886ca7bb.deploy.json"))
+	assert.True(os.IsNotExist(err))
 }
 
 func TestBadCacheMissingStorage(t *testing.T) {
@@ -280,6 +484,7 @@ func TestMigrateFilesToLevelDB(t *testing.T) {
 	assert := assert.New(t)
 	dir := tempdir()
 	defer cleanup(dir)
+	defer cleanup(dir + ".backups")
 
 	// Migration of legacy contract interfaces
 
@@ -354,7 +559,7 @@ func TestMigrateFilesToLevelDB(t *testing.T) {
 	err := cs.Init()
 	assert.NoError(err)
 
-	contracts, err := cs.ListContracts()
+	contracts, err := cs.ListContracts(nil)
 	assert.NoError(err)
 	assert.Equal(4, len(contracts))
 	assert.Equal("123456789abcdef0123456789abcdef012345678", contracts[0].(*ContractInfo).Address)
@@ -374,7 +579,7 @@ func TestMigrateFilesToLevelDB(t *testing.T) {
 	assert.NoError(err)
 	assert.Equal("23456789abcdef0123456789abcdef0123456789", migratedcontractAddr)
 
-	abis, err := cs.ListABIs()
+	abis, err := cs.ListABIs(nil)
 	assert.NoError(err)
 	assert.Equal(2, len(abis))
 	assert.Equal("840b629f-2e46-413b-9671-553a886ca7bb", abis[0].(*ABIInfo).ID)
@@ -484,12 +689,42 @@ func TestAddContractFail(t *testing.T) {
 	err := cs.Init()
 	assert.NoError(err)
 
-	cs.(*contractStore).db.Close()
+	testLevelDB(cs).Close()
 
-	_, err = cs.AddContract("0x123456789abcdef0123456789abcdef012345678", "abcd1234", "name", "name")
+	_, err = cs.AddContract("0x123456789abcdef0123456789abcdef012345678", "abcd1234", "name", "name", nil)
 	assert.Error(err)
 }
 
+func TestUpdateContract(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+
+	info, err := cs.AddContract("123456789abcdef0123456789abcdef012345678", "abi1", "proxy1", "proxy1", nil)
+	assert.NoError(err)
+	info.ABI = "abi2"
+	info.Proxy = &ProxyInfo{Standard: "EIP-1967", Implementation: "223456789abcdef0123456789abcdef012345678"}
+	err = cs.UpdateContract(info)
+	assert.NoError(err)
+
+	stored, err := cs.GetContractByAddress("123456789abcdef0123456789abcdef012345678")
+	assert.NoError(err)
+	assert.Equal("abi2", stored.ABI)
+	assert.Equal("223456789abcdef0123456789abcdef012345678", stored.Proxy.Implementation)
+	addr, err := cs.ResolveContractAddress("proxy1")
+	assert.NoError(err)
+	assert.Equal("123456789abcdef0123456789abcdef012345678", addr)
+
+	testLevelDB(cs).Close()
+	assert.Error(cs.UpdateContract(info))
+	info.RegisteredAs = ""
+	assert.Error(cs.UpdateContract(info))
+}
+
 func TestResolveContractByNameNotFound(t *testing.T) {
 	assert := assert.New(t)
 
@@ -518,7 +753,7 @@ func TestResolveContractByNameFail(t *testing.T) {
 	})
 	assert.NoError(err)
 
-	cs.(*contractStore).db.Close()
+	testLevelDB(cs).Close()
 
 	_, err = cs.ResolveContractAddress("name")
 	assert.Error(err)
@@ -539,7 +774,7 @@ func TestGetContractByAddressFail(t *testing.T) {
 	})
 	assert.NoError(err)
 
-	cs.(*contractStore).db.Close()
+	testLevelDB(cs).Close()
 
 	_, err = cs.GetContractByAddress("0x123456789abcdef0123456789abcdef012345678")
 	assert.Error(err)
@@ -554,7 +789,7 @@ func TestGetAddABIFail(t *testing.T) {
 	err := cs.Init()
 	assert.NoError(err)
 
-	cs.(*contractStore).db.Close()
+	testLevelDB(cs).Close()
 
 	_, err = cs.AddABI("abi1", &messages.DeployContract{}, time.Now())
 	assert.Error(err)
@@ -607,7 +842,7 @@ func TestMigrateABIFileFail(t *testing.T) {
 
 	cs.(*contractStore).migrateABIFile("abi1", path.Join(dir, "missing.json"), time.Now())
 
-	cs.(*contractStore).db.Close()
+	testLevelDB(cs).Close()
 
 	ioutil.WriteFile(path.Join(dir, "ok.json"), []byte("{}"), 0644)
 
@@ -630,7 +865,7 @@ func TestMigrateContractFileFail(t *testing.T) {
 
 	cs.(*contractStore).migrateContractFile("0x12345", path.Join(dir, "badjson.json"), time.Now())
 
-	cs.(*contractStore).db.Close()
+	testLevelDB(cs).Close()
 
 	ioutil.WriteFile(path.Join(dir, "ok.json"), []byte("{}"), 0644)
 
@@ -657,7 +892,7 @@ func TestMigrateLegacyContractFileFail(t *testing.T) {
 
 	cs.(*contractStore).migrateLegacyContractFile("0x12345", path.Join(dir, "no_ext.json"), time.Now())
 
-	cs.(*contractStore).db.Close()
+	testLevelDB(cs).Close()
 
 	ioutil.WriteFile(path.Join(dir, "ok.json"), []byte(`{"info":{ "x-firefly-registered-name": "myname", "x-firefly-deployment-id": "12345" }}`), 0644)
 
@@ -674,12 +909,240 @@ func TestListBadJSON(t *testing.T) {
 	err := cs.Init()
 	assert.NoError(err)
 
-	cs.(*contractStore).db.Put(fmt.Sprintf("%s/%s", ldbContractAddressPrefix, "abcd"), []byte(`!bad json{`))
-	_, err = cs.ListContracts()
+	testLevelDB(cs).Put(fmt.Sprintf("%s/%s", ldbContractAddressPrefix, "abcd"), []byte(`!bad json{`))
+	_, err = cs.ListContracts(nil)
+	assert.Regexp("FFEC100223", err)
+
+	testLevelDB(cs).Put(fmt.Sprintf("%s/%s", ldbABIIDPrefix, "abcd"), []byte(`!bad json{`))
+	_, err = cs.ListABIs(nil)
+	assert.Regexp("FFEC100223", err)
+
+}
+
+func TestDeleteContract(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+
+	_, err = cs.AddContract("123456789abcdef0123456789abcdef012345678", "abi1", "token", "token", nil)
+	assert.NoError(err)
+	_, err = cs.AddContract("223456789abcdef0123456789abcdef012345678", "abi1", "223456789abcdef0123456789abcdef012345678", "", nil)
+	assert.NoError(err)
+	contracts, err := cs.ListContracts(nil)
+	assert.NoError(err)
+	assert.Len(contracts, 2)
+
+	err = cs.DeleteContract("0x123456789ABCDEF0123456789ABCDEF012345678")
+	assert.NoError(err)
+	_, err = cs.GetContractByAddress("123456789abcdef0123456789abcdef012345678")
+	assert.Regexp("FFEC100126", err)
+	_, err = cs.ResolveContractAddress("token")
+	assert.Regexp("FFEC100125", err)
+	assert.NoError(cs.CheckNameAvailable("token"))
+	contracts, err = cs.ListContracts(nil)
+	assert.NoError(err)
+	assert.Len(contracts, 1)
+
+	err = cs.DeleteContract("223456789abcdef0123456789abcdef012345678")
+	assert.NoError(err)
+	err = cs.DeleteContract("223456789abcdef0123456789abcdef012345678")
+	assert.Regexp("FFEC100126", err)
+}
+
+func TestDeleteContractKeepsReassignedName(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+
+	info, err := cs.AddContract("123456789abcdef0123456789abcdef012345678", "abi1", "token", "token", nil)
+	assert.NoError(err)
+	info.Address = "223456789abcdef0123456789abcdef012345678"
+	assert.NoError(cs.UpdateContract(info))
+
+	err = cs.DeleteContract("123456789abcdef0123456789abcdef012345678")
+	assert.NoError(err)
+	addr, err := cs.ResolveContractAddress("token")
+	assert.NoError(err)
+	assert.Equal("223456789abcdef0123456789abcdef012345678", addr)
+}
+
+func TestDeleteContractFail(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+
+	_, err = cs.AddContract("123456789abcdef0123456789abcdef012345678", "abi1", "token", "token", nil)
+	assert.NoError(err)
+	testLevelDB(cs).Put(fmt.Sprintf("%s/%s", ldbRegisteredNamePrefix, "token"), []byte(`!bad json{`))
+	err = cs.DeleteContract("123456789abcdef0123456789abcdef012345678")
+	assert.Regexp("FFEC100223", err)
+
+	testLevelDB(cs).Close()
+	err = cs.DeleteContract("123456789abcdef0123456789abcdef012345678")
+	assert.Regexp("leveldb", err)
+}
+
+func TestRenameContract(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir, BaseURL: "http://localhost:8080"}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+
+	_, err = cs.AddContract("123456789abcdef0123456789abcdef012345678", "abi1", "token", "token", nil)
+	assert.NoError(err)
+	_, err = cs.AddContract("223456789abcdef0123456789abcdef012345678", "abi1", "other", "other", nil)
+	assert.NoError(err)
+
+	info, err := cs.RenameContract("0x123456789ABCDEF0123456789ABCDEF012345678", "token2")
+	assert.NoError(err)
+	assert.Equal("token2", info.RegisteredAs)
+	assert.Equal("/contracts/token2", info.Path)
+	assert.Equal("http://localhost:8080/contracts/token2?swagger", info.SwaggerURL)
+	addr, err := cs.ResolveContractAddress("token2")
+	assert.NoError(err)
+	assert.Equal("123456789abcdef0123456789abcdef012345678", addr)
+	assert.NoError(cs.CheckNameAvailable("token"))
+	stored, err := cs.GetContractByAddress("123456789abcdef0123456789abcdef012345678")
+	assert.NoError(err)
+	assert.Equal("token2", stored.RegisteredAs)
+	listing, err := cs.CachedContractListing()
+	assert.NoError(err)
+	assert.Regexp(`"registeredAs":\s*"token2"`, string(listing.JSON))
+
+	// Renaming to the same name is a no-op, and renaming to a name in use fails
+	_, err = cs.RenameContract("123456789abcdef0123456789abcdef012345678", "token2")
+	assert.NoError(err)
+	_, err = cs.RenameContract("123456789abcdef0123456789abcdef012345678", "other")
+	assert.Regexp("FFEC100133", err)
+	addr, err = cs.ResolveContractAddress("token2")
+	assert.NoError(err)
+	assert.Equal("123456789abcdef0123456789abcdef012345678", addr)
+
+	// An empty name leaves the instance registered only by address
+	info, err = cs.RenameContract("123456789abcdef0123456789abcdef012345678", "")
+	assert.NoError(err)
+	assert.Empty(info.RegisteredAs)
+	assert.Equal("/contracts/123456789abcdef0123456789abcdef012345678", info.Path)
+	assert.NoError(cs.CheckNameAvailable("token2"))
+
+	_, err = cs.RenameContract("323456789abcdef0123456789abcdef012345678", "token3")
+	assert.Regexp("FFEC100126", err)
+}
+
+type failPutContractPersistence struct {
+	ContractStorePersistence
+	failPut bool
+}
+
+func (p *failPutContractPersistence) PutContract(info *ContractInfo) error {
+	if p.failPut {
+		return fmt.Errorf("pop")
+	}
+	return p.ContractStorePersistence.PutContract(info)
+}
+
+func TestRenameContractFail(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := tempdir()
+	defer cleanup(dir)
+	p := &failPutContractPersistence{ContractStorePersistence: NewLevelDBContractPersistence(path.Join(dir, "contracts"))}
+	cs := NewContractStoreWithPersistence(&ContractStoreConf{StoragePath: dir}, &mockRR{}, p)
+	err := cs.Init()
+	assert.NoError(err)
+
+	_, err = cs.AddContract("123456789abcdef0123456789abcdef012345678", "abi1", "token", "token", nil)
+	assert.NoError(err)
+
+	// The new name is released if the instance cannot be updated
+	p.failPut = true
+	_, err = cs.RenameContract("123456789abcdef0123456789abcdef012345678", "token2")
+	assert.Regexp("pop", err)
+	assert.NoError(cs.CheckNameAvailable("token2"))
+	addr, err := cs.ResolveContractAddress("token")
+	assert.NoError(err)
+	assert.Equal("123456789abcdef0123456789abcdef012345678", addr)
+	p.failPut = false
+
+	db := p.ContractStorePersistence.(*levelDBContractPersistence).db
+	db.Put(fmt.Sprintf("%s/%s", ldbRegisteredNamePrefix, "token"), []byte(`!bad json{`))
+	_, err = cs.RenameContract("123456789abcdef0123456789abcdef012345678", "token3")
 	assert.Regexp("FFEC100223", err)
 
-	cs.(*contractStore).db.Put(fmt.Sprintf("%s/%s", ldbABIIDPrefix, "abcd"), []byte(`!bad json{`))
-	_, err = cs.ListABIs()
+	db.Close()
+	_, err = cs.RenameContract("123456789abcdef0123456789abcdef012345678", "token4")
+	assert.Regexp("leveldb", err)
+}
+
+func TestDeleteABI(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+
+	_, err = cs.AddABI("abi1", &messages.DeployContract{ContractName: "test"}, time.Now())
+	assert.NoError(err)
+	_, err = cs.AddContract("123456789abcdef0123456789abcdef012345678", "abi1", "token", "token", nil)
+	assert.NoError(err)
+	location := ABILocation{ABIType: LocalABI, Name: "abi1"}
+	_, err = cs.GetABI(location, false)
+	assert.NoError(err)
+	abis, err := cs.ListABIs(nil)
+	assert.NoError(err)
+	assert.Len(abis, 1)
+
+	err = cs.DeleteABI("abi1")
+	assert.Regexp("FFEC100333.*123456789abcdef0123456789abcdef012345678", err)
+
+	assert.NoError(cs.DeleteContract("123456789abcdef0123456789abcdef012345678"))
+	err = cs.DeleteABI("abi1")
+	assert.NoError(err)
+
+	// The cached copy must not be served after the delete
+	_, err = cs.GetABI(location, false)
+	assert.Regexp("FFEC100127", err)
+	abis, err = cs.ListABIs(nil)
+	assert.NoError(err)
+	assert.Len(abis, 0)
+
+	err = cs.DeleteABI("abi1")
+	assert.Regexp("FFEC100127", err)
+}
+
+func TestDeleteABIFail(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+
+	_, err = cs.AddABI("abi1", &messages.DeployContract{ContractName: "test"}, time.Now())
+	assert.NoError(err)
+	testLevelDB(cs).Put(fmt.Sprintf("%s/%s", ldbContractAddressPrefix, "abcd"), []byte(`!bad json{`))
+	err = cs.DeleteABI("abi1")
 	assert.Regexp("FFEC100223", err)
 
+	testLevelDB(cs).Close()
+	err = cs.DeleteABI("abi1")
+	assert.Regexp("leveldb", err)
 }
@@ -0,0 +1,132 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"regexp"
+	"strings"
+
+	ethconnecterrors "github.com/hyperledger/firefly-ethconnect/internal/errors"
+)
+
+var (
+	labelKeyMatcher   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]{0,61}[A-Za-z0-9])?$`)
+	labelValueMatcher = regexp.MustCompile(`^[A-Za-z0-9._/-]{0,63}$`)
+)
+
+// ParseLabels parses labels in the form key=value. Each string can hold a comma-separated list.
+// Returns nil if there are no labels.
+func ParseLabels(strs []string) (map[string]string, error) {
+	var labels map[string]string
+	for _, str := range strs {
+		for _, label := range strings.Split(str, ",") {
+			label = strings.TrimSpace(label)
+			if label == "" {
+				continue
+			}
+			kv := strings.SplitN(label, "=", 2)
+			if len(kv) != 2 {
+				return nil, ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayInvalidLabel, label)
+			}
+			if labels == nil {
+				labels = make(map[string]string)
+			}
+			labels[kv[0]] = kv[1]
+		}
+	}
+	if err := ValidateLabels(labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// ValidateLabels checks label keys are alphanumeric words of up to 63 characters, which can also
+// contain '.', '_', '/' and '-', and that values are up to 63 of the same characters
+func ValidateLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if !labelKeyMatcher.MatchString(k) || !labelValueMatcher.MatchString(v) {
+			return ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayInvalidLabel, k+"="+v)
+		}
+	}
+	return nil
+}
+
+// labelRequirement is one term of a selector
+type labelRequirement struct {
+	key    string
+	value  string
+	equals bool
+	exists bool
+}
+
+func (r *labelRequirement) matches(labels map[string]string) bool {
+	v, ok := labels[r.key]
+	switch {
+	case r.exists:
+		return ok == r.equals
+	case r.equals:
+		return ok && v == r.value
+	default:
+		return !ok || v != r.value
+	}
+}
+
+// LabelSelector matches the labels of a contract or ABI against all of its requirements
+type LabelSelector struct {
+	requirements []*labelRequirement
+}
+
+// ParseLabelSelector parses a comma-separated selector, with requirements of the form
+// key=value, key!=value, key (the label is set) and !key (the label is not set).
+// Returns nil if the selector is empty.
+func ParseLabelSelector(strs []string) (*LabelSelector, error) {
+	selector := &LabelSelector{}
+	for _, str := range strs {
+		for _, term := range strings.Split(str, ",") {
+			term = strings.TrimSpace(term)
+			if term == "" {
+				continue
+			}
+			r := &labelRequirement{equals: true}
+			if kv := strings.SplitN(term, "!=", 2); len(kv) == 2 {
+				r.key, r.value, r.equals = kv[0], kv[1], false
+			} else if kv := strings.SplitN(term, "=", 2); len(kv) == 2 {
+				r.key, r.value = kv[0], strings.TrimPrefix(kv[1], "=")
+			} else if strings.HasPrefix(term, "!") {
+				r.key, r.exists, r.equals = term[1:], true, false
+			} else {
+				r.key, r.exists = term, true
+			}
+			if !labelKeyMatcher.MatchString(r.key) || !labelValueMatcher.MatchString(r.value) {
+				return nil, ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayInvalidListingParam, "label", term)
+			}
+			selector.requirements = append(selector.requirements, r)
+		}
+	}
+	if len(selector.requirements) == 0 {
+		return nil, nil
+	}
+	return selector, nil
+}
+
+// Matches returns true if the labels meet every requirement of the selector
+func (s *LabelSelector) Matches(labels map[string]string) bool {
+	for _, r := range s.requirements {
+		if !r.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
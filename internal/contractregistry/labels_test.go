@@ -0,0 +1,112 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	labels, err := ParseLabels([]string{"team=payments, env=prod", "app.kubernetes.io/name=token", "empty="})
+	assert.NoError(err)
+	assert.Equal(map[string]string{
+		"team":                   "payments",
+		"env":                    "prod",
+		"app.kubernetes.io/name": "token",
+		"empty":                  "",
+	}, labels)
+
+	labels, err = ParseLabels(nil)
+	assert.NoError(err)
+	assert.Nil(labels)
+
+	for _, bad := range []string{"novalue", "=value", "-key=value", "key=a b", "key=" + strings.Repeat("a", 64)} {
+		_, err = ParseLabels([]string{bad})
+		assert.Regexp("FFEC100391", err, bad)
+	}
+}
+
+func TestLabelSelector(t *testing.T) {
+	assert := assert.New(t)
+
+	labels := map[string]string{"team": "payments", "env": "prod"}
+	for selector, expected := range map[string]bool{
+		"team=payments":          true,
+		"team==payments":         true,
+		"team=payments,env=prod": true,
+		"team=payments,env=dev":  false,
+		"env!=dev":               true,
+		"env!=prod":              false,
+		"region!=eu":             true,
+		"env":                    true,
+		"region":                 false,
+		"!region":                true,
+		"!env":                   false,
+	} {
+		s, err := ParseLabelSelector([]string{selector})
+		assert.NoError(err)
+		assert.Equal(expected, s.Matches(labels), selector)
+	}
+
+	s, err := ParseLabelSelector([]string{"", " , "})
+	assert.NoError(err)
+	assert.Nil(s)
+
+	for _, bad := range []string{"!", "a b", "env=a b", "env!=a b"} {
+		_, err = ParseLabelSelector([]string{bad})
+		assert.Regexp("FFEC100345", err, bad)
+	}
+}
+
+func TestListABIsByLabel(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+
+	_, err = cs.AddABI("abi1", &messages.DeployContract{ContractName: "c1", Labels: map[string]string{"team": "a"}}, time.Now())
+	assert.NoError(err)
+	_, err = cs.AddABI("abi2", &messages.DeployContract{ContractName: "c2"}, time.Now())
+	assert.NoError(err)
+	_, err = cs.AddContract("aaaa", "abi1", "aaaa", "", map[string]string{"team": "a"})
+	assert.NoError(err)
+
+	selector, _ := ParseLabelSelector([]string{"team=a"})
+	abis, err := cs.ListABIs(&ListingFilter{Labels: selector})
+	assert.NoError(err)
+	assert.Len(abis, 1)
+	assert.Equal("abi1", abis[0].GetID())
+	contracts, err := cs.ListContracts(&ListingFilter{Labels: selector})
+	assert.NoError(err)
+	assert.Len(contracts, 1)
+
+	// Labels are kept in the store
+	info, err := cs.GetLocalABIInfo("abi1")
+	assert.NoError(err)
+	assert.Equal(map[string]string{"team": "a"}, info.Labels)
+	contract, err := cs.GetContractByAddress("aaaa")
+	assert.NoError(err)
+	assert.Equal(map[string]string{"team": "a"}, contract.Labels)
+}
@@ -0,0 +1,153 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/kvstore"
+)
+
+const (
+	ldbRegisteredNamePrefix  = "registered_name"
+	ldbContractAddressPrefix = "contract_address"
+	ldbABIIDPrefix           = "abi_id"
+)
+
+// levelDBContractPersistence stores each ABI, contract instance and registered name under
+// its own key prefix, so each type can be listed with a prefix scan
+type levelDBContractPersistence struct {
+	path string
+	db   kvstore.KVStore
+}
+
+// NewLevelDBContractPersistence creates the LevelDB persistence layer for the contract store
+func NewLevelDBContractPersistence(path string) ContractStorePersistence {
+	return &levelDBContractPersistence{
+		path: path,
+	}
+}
+
+func (l *levelDBContractPersistence) Init() (err error) {
+	l.db, err = kvstore.NewLDBKeyValueStore(l.path)
+	return err
+}
+
+func (l *levelDBContractPersistence) Close() {
+	if l.db != nil {
+		l.db.Close()
+	}
+}
+
+// getJSON returns false, with no error, if the key does not exist
+func (l *levelDBContractPersistence) getJSON(prefix, id string, obj interface{}) (bool, error) {
+	err := l.db.GetJSON(fmt.Sprintf("%s/%s", prefix, id), obj)
+	if err == kvstore.ErrorNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (l *levelDBContractPersistence) GetContract(addrHexNo0x string) (*ContractInfo, error) {
+	var info ContractInfo
+	if found, err := l.getJSON(ldbContractAddressPrefix, addrHexNo0x, &info); !found {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (l *levelDBContractPersistence) PutContract(info *ContractInfo) error {
+	return l.db.PutJSON(fmt.Sprintf("%s/%s", ldbContractAddressPrefix, info.Address), info)
+}
+
+func (l *levelDBContractPersistence) DeleteContract(addrHexNo0x string) error {
+	return l.db.Delete(fmt.Sprintf("%s/%s", ldbContractAddressPrefix, addrHexNo0x))
+}
+
+func (l *levelDBContractPersistence) GetRegisteredName(name string) (*ContractInfo, error) {
+	var info ContractInfo
+	if found, err := l.getJSON(ldbRegisteredNamePrefix, name, &info); !found {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (l *levelDBContractPersistence) PutRegisteredName(info *ContractInfo) error {
+	return l.db.PutJSON(fmt.Sprintf("%s/%s", ldbRegisteredNamePrefix, info.RegisteredAs), info)
+}
+
+func (l *levelDBContractPersistence) DeleteRegisteredName(name string) error {
+	return l.db.Delete(fmt.Sprintf("%s/%s", ldbRegisteredNamePrefix, name))
+}
+
+func (l *levelDBContractPersistence) GetABI(abiID string) (*StoredABI, error) {
+	var storedABI StoredABI
+	if found, err := l.getJSON(ldbABIIDPrefix, abiID, &storedABI); !found {
+		return nil, err
+	}
+	return &storedABI, nil
+}
+
+func (l *levelDBContractPersistence) GetABIInfo(abiID string) (*ABIInfo, error) {
+	var info ABIInfo // we only de-serialize the ABIInfo part of the full record
+	if found, err := l.getJSON(ldbABIIDPrefix, abiID, &info); !found {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (l *levelDBContractPersistence) PutABI(abi *StoredABI) error {
+	return l.db.PutJSON(fmt.Sprintf("%s/%s", ldbABIIDPrefix, abi.ID), abi)
+}
+
+func (l *levelDBContractPersistence) DeleteABI(abiID string) error {
+	return l.db.Delete(fmt.Sprintf("%s/%s", ldbABIIDPrefix, abiID))
+}
+
+// prefixIterator iterates over all the keys in the set with the supplied prefix
+func (l *levelDBContractPersistence) prefixIterator(prefix string) kvstore.KVIterator {
+	return l.db.NewIteratorWithRange(&kvstore.Range{
+		Start: []byte(prefix + "/"), // the beginning of the key sets with the `prefix/`
+		Limit: []byte(prefix + "0"), // this is after the last key with a `prefix/` ('0' is after '/')
+	})
+}
+
+func (l *levelDBContractPersistence) ListContracts() ([]*ContractInfo, error) {
+	retval := make([]*ContractInfo, 0)
+	it := l.prefixIterator(ldbContractAddressPrefix)
+	defer it.Release()
+	for it.Next() {
+		var info ContractInfo
+		if err := it.ValueJSON(&info); err != nil {
+			return nil, err
+		}
+		retval = append(retval, &info)
+	}
+	return retval, nil
+}
+
+func (l *levelDBContractPersistence) ListABIs() ([]*ABIInfo, error) {
+	retval := make([]*ABIInfo, 0)
+	it := l.prefixIterator(ldbABIIDPrefix)
+	defer it.Release()
+	for it.Next() {
+		var info ABIInfo
+		if err := it.ValueJSON(&info); err != nil {
+			return nil, err
+		}
+		retval = append(retval, &info)
+	}
+	return retval, nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"fmt"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/kvstore"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func testLevelDB(cs ContractStore) kvstore.KVStore {
+	return cs.(*contractStore).persistence.(*indexedPersistence).ContractStorePersistence.(*levelDBContractPersistence).db
+}
+
+func TestLevelDBContractPersistence(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+
+	p := NewLevelDBContractPersistence(path.Join(dir, "contracts"))
+	assert.NoError(p.Init())
+	defer p.Close()
+
+	info, err := p.GetContract("0123456789abcdef0123456789abcdef01234567")
+	assert.NoError(err)
+	assert.Nil(info)
+	info, err = p.GetRegisteredName("token")
+	assert.NoError(err)
+	assert.Nil(info)
+	storedABI, err := p.GetABI("abi1")
+	assert.NoError(err)
+	assert.Nil(storedABI)
+	abiInfo, err := p.GetABIInfo("abi1")
+	assert.NoError(err)
+	assert.Nil(abiInfo)
+
+	contract := &ContractInfo{Address: "0123456789abcdef0123456789abcdef01234567", ABI: "abi1", RegisteredAs: "token"}
+	assert.NoError(p.PutContract(contract))
+	assert.NoError(p.PutRegisteredName(contract))
+	assert.NoError(p.PutABI(&StoredABI{
+		ABIInfo:   ABIInfo{ID: "abi1", Name: "Token"},
+		DeployMsg: &messages.DeployContract{ContractName: "Token"},
+	}))
+	assert.NoError(p.PutABI(&StoredABI{ABIInfo: ABIInfo{ID: "abi2", Name: "Other"}}))
+
+	info, err = p.GetContract("0123456789abcdef0123456789abcdef01234567")
+	assert.NoError(err)
+	assert.Equal("abi1", info.ABI)
+	info, err = p.GetRegisteredName("token")
+	assert.NoError(err)
+	assert.Equal("0123456789abcdef0123456789abcdef01234567", info.Address)
+	storedABI, err = p.GetABI("abi1")
+	assert.NoError(err)
+	assert.Equal("Token", storedABI.DeployMsg.ContractName)
+	abiInfo, err = p.GetABIInfo("abi1")
+	assert.NoError(err)
+	assert.Equal("Token", abiInfo.Name)
+
+	// Each listing only scans the keys with its own prefix
+	contracts, err := p.ListContracts()
+	assert.NoError(err)
+	assert.Len(contracts, 1)
+	abis, err := p.ListABIs()
+	assert.NoError(err)
+	assert.Len(abis, 2)
+	assert.Equal("abi1", abis[0].ID)
+	assert.Equal("abi2", abis[1].ID)
+}
+
+func TestLevelDBContractPersistenceErrors(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+
+	p := NewLevelDBContractPersistence(path.Join(dir, "contracts"))
+	assert.NoError(p.Init())
+	db := p.(*levelDBContractPersistence).db
+	db.Put(fmt.Sprintf("%s/%s", ldbContractAddressPrefix, "abcd"), []byte(`!bad json{`))
+	db.Put(fmt.Sprintf("%s/%s", ldbRegisteredNamePrefix, "abcd"), []byte(`!bad json{`))
+	db.Put(fmt.Sprintf("%s/%s", ldbABIIDPrefix, "abcd"), []byte(`!bad json{`))
+
+	_, err := p.GetContract("abcd")
+	assert.Regexp("FFEC100223", err)
+	_, err = p.GetRegisteredName("abcd")
+	assert.Regexp("FFEC100223", err)
+	_, err = p.GetABI("abcd")
+	assert.Regexp("FFEC100223", err)
+	_, err = p.GetABIInfo("abcd")
+	assert.Regexp("FFEC100223", err)
+
+	p.Close()
+	_, err = p.GetContract("abcd")
+	assert.Regexp("leveldb", err)
+}
+
+func TestLevelDBContractPersistenceInitFail(t *testing.T) {
+	dir := tempdir()
+	defer cleanup(dir)
+
+	p := NewLevelDBContractPersistence(path.Join(dir, "contracts"))
+	assert.NoError(t, p.Init())
+	defer p.Close()
+
+	// The database is locked by the first instance
+	p2 := NewLevelDBContractPersistence(path.Join(dir, "contracts"))
+	assert.Regexp(t, "FFEC100054", p2.Init())
+	p2.Close()
+}
+
+func TestContractStoreWithPersistence(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+
+	p := NewLevelDBContractPersistence(path.Join(dir, "custom"))
+	cs := NewContractStoreWithPersistence(&ContractStoreConf{StoragePath: dir}, &mockRR{}, p)
+	assert.NoError(cs.Init())
+
+	_, err := cs.AddABI("abi1", &messages.DeployContract{ContractName: "Token"}, time.Now())
+	assert.NoError(err)
+	_, err = cs.AddContract("0123456789abcdef0123456789abcdef01234567", "abi1", "token", "token", nil)
+	assert.NoError(err)
+	cs.Close()
+
+	// The registrations are read back through a new store on the same persistence
+	p = NewLevelDBContractPersistence(path.Join(dir, "custom"))
+	cs = NewContractStoreWithPersistence(&ContractStoreConf{StoragePath: dir}, &mockRR{}, p)
+	assert.NoError(cs.Init())
+	defer cs.Close()
+	addr, err := cs.ResolveContractAddress("token")
+	assert.NoError(err)
+	assert.Equal("0123456789abcdef0123456789abcdef01234567", addr)
+	contracts, err := cs.ListContracts(nil)
+	assert.NoError(err)
+	assert.Len(contracts, 1)
+	abis, err := cs.ListABIs(nil)
+	assert.NoError(err)
+	assert.Len(abis, 1)
+
+	_, err = cs.GetLocalABIInfo("abi2")
+	assert.Regexp("FFEC100127", err)
+}
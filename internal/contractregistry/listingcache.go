@@ -0,0 +1,226 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+)
+
+// CachedListing is a pre-serialized listing of contracts or ABIs, with an ETag that
+// changes whenever the content of the listing changes
+type CachedListing struct {
+	ETag string
+	JSON []byte
+}
+
+// ListingFilter selects a page of a listing of contracts or ABIs. Zero values do not filter.
+type ListingFilter struct {
+	Limit        int
+	Skip         int
+	CreatedAfter time.Time
+	// Name is the registered name of a contract instance, or the contract or registered name of an ABI
+	Name string
+	// ABI is the ID of an ABI, or of the ABI a contract instance is registered against
+	ABI string
+	// Method is the 0x prefixed 4-byte selector of a method the ABI must contain
+	Method string
+	// Event is the 0x prefixed topic0 hash of an event the ABI must contain
+	Event string
+	// Labels selects contracts or ABIs by the labels they were registered with
+	Labels *LabelSelector
+	// ContentHash is the algorithm prefixed hash of the ABI and bytecode of an ABI
+	ContentHash string
+}
+
+// apply returns the page of the sorted items that match the filter. A nil filter matches all items.
+func (f *ListingFilter) apply(items []messages.TimeSortable) []messages.TimeSortable {
+	if f == nil {
+		return items
+	}
+	matched := make([]messages.TimeSortable, 0)
+	skipped := 0
+	for _, item := range items {
+		if !f.CreatedAfter.IsZero() {
+			created, err := time.Parse(time.RFC3339Nano, item.GetISO8601())
+			if err != nil {
+				continue
+			}
+			if !created.After(f.CreatedAfter) {
+				// Listings are sorted newest first, so nothing after this can match
+				break
+			}
+		}
+		if !f.matches(item) {
+			continue
+		}
+		if skipped < f.Skip {
+			skipped++
+			continue
+		}
+		matched = append(matched, item)
+		if f.Limit > 0 && len(matched) >= f.Limit {
+			break
+		}
+	}
+	return matched
+}
+
+func (f *ListingFilter) matches(item messages.TimeSortable) bool {
+	switch i := item.(type) {
+	case *ContractInfo:
+		return (f.Name == "" || i.RegisteredAs == f.Name) && (f.ABI == "" || i.ABI == f.ABI) &&
+			(f.Labels == nil || f.Labels.Matches(i.Labels))
+	case *ABIInfo:
+		return (f.Name == "" || i.Name == f.Name || i.RegisteredAs == f.Name) && (f.ABI == "" || i.ID == f.ABI) &&
+			(f.Labels == nil || f.Labels.Matches(i.Labels))
+	}
+	return true
+}
+
+// listingCache holds a sorted in-memory copy of a listing, loaded from the DB on first
+// use and then updated incrementally on each registration. The serialized JSON is
+// rebuilt lazily on the first read after a change, so repeated polling of an unchanged
+// listing does no work beyond returning the cached bytes.
+//
+// The items slice is copy-on-write. Changes build a new slice, so readers can share a
+// snapshot of the listing under a read lock, without copying it or blocking each other.
+type listingCache struct {
+	mux     sync.RWMutex
+	load    func() ([]messages.TimeSortable, error)
+	items   []messages.TimeSortable
+	listing *CachedListing
+}
+
+func newListingCache(load func() ([]messages.TimeSortable, error)) *listingCache {
+	return &listingCache{load: load}
+}
+
+func sortListing(items []messages.TimeSortable) {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].IsLessThan(items[i], items[j])
+	})
+}
+
+// ensureLoaded must be called with the write lock held
+func (lc *listingCache) ensureLoaded() error {
+	if lc.items != nil {
+		return nil
+	}
+	items, err := lc.load()
+	if err != nil {
+		return err
+	}
+	lc.items = items
+	return nil
+}
+
+// list returns a snapshot of the sorted items, which must not be modified
+func (lc *listingCache) list() ([]messages.TimeSortable, error) {
+	lc.mux.RLock()
+	items := lc.items
+	lc.mux.RUnlock()
+	if items != nil {
+		return items, nil
+	}
+	lc.mux.Lock()
+	defer lc.mux.Unlock()
+	if err := lc.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	return lc.items, nil
+}
+
+func (lc *listingCache) cached() (*CachedListing, error) {
+	lc.mux.RLock()
+	listing := lc.listing
+	lc.mux.RUnlock()
+	if listing != nil {
+		return listing, nil
+	}
+	lc.mux.Lock()
+	defer lc.mux.Unlock()
+	if lc.listing != nil {
+		return lc.listing, nil
+	}
+	if err := lc.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	b, err := json.MarshalIndent(lc.items, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, '\n')
+	hash := sha256.Sum256(b)
+	lc.listing = &CachedListing{
+		ETag: fmt.Sprintf(`"%x"`, hash[:16]),
+		JSON: b,
+	}
+	return lc.listing, nil
+}
+
+// upsert inserts an item into its sorted position, replacing any existing item with the same ID.
+// If the listing has not been loaded yet there is nothing to do, as the item will be read from
+// the DB on first use.
+func (lc *listingCache) upsert(item messages.TimeSortable) {
+	lc.mux.Lock()
+	defer lc.mux.Unlock()
+	if lc.items == nil {
+		return
+	}
+	items := make([]messages.TimeSortable, 0, len(lc.items)+1)
+	for _, existing := range lc.items {
+		if existing.GetID() != item.GetID() {
+			items = append(items, existing)
+		}
+	}
+	pos := sort.Search(len(items), func(i int) bool {
+		return !items[i].IsLessThan(items[i], item)
+	})
+	items = append(items, nil)
+	copy(items[pos+1:], items[pos:])
+	items[pos] = item
+	lc.items = items
+	lc.listing = nil
+}
+
+// remove drops the item with the supplied ID from the listing, if it has been loaded
+func (lc *listingCache) remove(id string) {
+	lc.mux.Lock()
+	defer lc.mux.Unlock()
+	for i, existing := range lc.items {
+		if existing.GetID() == id {
+			items := make([]messages.TimeSortable, 0, len(lc.items)-1)
+			items = append(items, lc.items[:i]...)
+			lc.items = append(items, lc.items[i+1:]...)
+			lc.listing = nil
+			return
+		}
+	}
+}
+
+// reset discards the listing, so it is reloaded from the DB on next use
+func (lc *listingCache) reset() {
+	lc.mux.Lock()
+	defer lc.mux.Unlock()
+	lc.items = nil
+	lc.listing = nil
+}
@@ -0,0 +1,260 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedListingsIncrementalUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+
+	_, err = cs.AddContract("aaaa", "abi1", "aaaa", "", nil)
+	assert.NoError(err)
+
+	listing1, err := cs.CachedContractListing()
+	assert.NoError(err)
+	var contracts []*ContractInfo
+	assert.NoError(json.Unmarshal(listing1.JSON, &contracts))
+	assert.Len(contracts, 1)
+
+	// Unchanged listings are served from the cache
+	listing2, err := cs.CachedContractListing()
+	assert.NoError(err)
+	assert.Same(listing1, listing2)
+
+	// Add a contract created later, and re-register the first one with a name
+	cs.(*contractStore).contractListing.upsert(&ContractInfo{
+		Address:    "bbbb",
+		TimeSorted: messages.TimeSorted{CreatedISO8601: time.Now().Add(1 * time.Hour).UTC().Format(time.RFC3339)},
+	})
+	_, err = cs.AddContract("aaaa", "abi1", "aaaa", "named", nil)
+	assert.NoError(err)
+
+	listing3, err := cs.CachedContractListing()
+	assert.NoError(err)
+	assert.NotEqual(listing1.ETag, listing3.ETag)
+	contracts = nil
+	assert.NoError(json.Unmarshal(listing3.JSON, &contracts))
+	assert.Len(contracts, 2)
+	assert.Equal("bbbb", contracts[0].Address)
+	assert.Equal("aaaa", contracts[1].Address)
+	assert.Equal("named", contracts[1].RegisteredAs)
+
+	list, err := cs.ListContracts(nil)
+	assert.NoError(err)
+	assert.Len(list, 2)
+
+	abis, err := cs.CachedABIListing()
+	assert.NoError(err)
+	assert.Equal("[]\n", string(abis.JSON))
+	_, err = cs.AddABI("abi1", &messages.DeployContract{ContractName: "c1"}, time.Now())
+	assert.NoError(err)
+	abis, err = cs.CachedABIListing()
+	assert.NoError(err)
+	var abiInfos []*ABIInfo
+	assert.NoError(json.Unmarshal(abis.JSON, &abiInfos))
+	assert.Len(abiInfos, 1)
+	assert.Equal("c1", abiInfos[0].Name)
+}
+
+func TestCachedListingLoadFail(t *testing.T) {
+	assert := assert.New(t)
+	lc := newListingCache(func() ([]messages.TimeSortable, error) {
+		return nil, fmt.Errorf("pop")
+	})
+	_, err := lc.cached()
+	assert.Regexp("pop", err)
+	lc.upsert(&ABIInfo{ID: "ignored"})
+	assert.Nil(lc.items)
+}
+
+func TestListingCacheSortOrder(t *testing.T) {
+	assert := assert.New(t)
+	lc := newListingCache(func() ([]messages.TimeSortable, error) {
+		return []messages.TimeSortable{}, nil
+	})
+	_, err := lc.list()
+	assert.NoError(err)
+	for _, id := range []string{"c", "a", "d", "b"} {
+		lc.upsert(&ABIInfo{ID: id, TimeSorted: messages.TimeSorted{CreatedISO8601: "2022-01-01T00:00:00Z"}})
+	}
+	lc.upsert(&ABIInfo{ID: "z", TimeSorted: messages.TimeSorted{CreatedISO8601: "2022-01-02T00:00:00Z"}})
+	items, err := lc.list()
+	assert.NoError(err)
+	var ids []string
+	for _, i := range items {
+		ids = append(ids, i.GetID())
+	}
+	assert.Equal([]string{"z", "a", "b", "c", "d"}, ids)
+}
+
+func TestListingCacheRemove(t *testing.T) {
+	assert := assert.New(t)
+	lc := newListingCache(func() ([]messages.TimeSortable, error) {
+		return []messages.TimeSortable{&ABIInfo{ID: "a"}, &ABIInfo{ID: "b"}}, nil
+	})
+	listing1, err := lc.cached()
+	assert.NoError(err)
+
+	lc.remove("unknown")
+	listing2, err := lc.cached()
+	assert.NoError(err)
+	assert.Same(listing1, listing2)
+
+	lc.remove("a")
+	listing3, err := lc.cached()
+	assert.NoError(err)
+	assert.NotEqual(listing1.ETag, listing3.ETag)
+	items, err := lc.list()
+	assert.NoError(err)
+	assert.Len(items, 1)
+	assert.Equal("b", items[0].GetID())
+}
+
+func TestListingCacheSnapshotsAreCopyOnWrite(t *testing.T) {
+	assert := assert.New(t)
+	lc := newListingCache(func() ([]messages.TimeSortable, error) {
+		return []messages.TimeSortable{&ABIInfo{ID: "a"}, &ABIInfo{ID: "b"}}, nil
+	})
+	snapshot, err := lc.list()
+	assert.NoError(err)
+
+	lc.upsert(&ABIInfo{ID: "c", TimeSorted: messages.TimeSorted{CreatedISO8601: "2022-01-01T00:00:00Z"}})
+	lc.upsert(&ABIInfo{ID: "a", Name: "updated"})
+	lc.remove("b")
+	assert.Len(snapshot, 2)
+	assert.Equal("a", snapshot[0].GetID())
+	assert.Empty(snapshot[0].(*ABIInfo).Name)
+	assert.Equal("b", snapshot[1].GetID())
+
+	items, err := lc.list()
+	assert.NoError(err)
+	assert.Len(items, 2)
+}
+
+func TestListingCacheConcurrentReads(t *testing.T) {
+	lc := newListingCache(func() ([]messages.TimeSortable, error) {
+		return []messages.TimeSortable{}, nil
+	})
+	_, err := lc.list()
+	assert.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				lc.upsert(&ABIInfo{ID: fmt.Sprintf("%d-%d", i, j)})
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_, err := lc.list()
+				assert.NoError(t, err)
+				_, err = lc.cached()
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+	items, err := lc.list()
+	assert.NoError(t, err)
+	assert.Len(t, items, 200)
+}
+
+func TestListingFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	var items []messages.TimeSortable
+	for i := 9; i >= 0; i-- {
+		items = append(items, &ContractInfo{
+			Address:      fmt.Sprintf("addr%d", i),
+			ABI:          fmt.Sprintf("abi%d", i%2),
+			RegisteredAs: fmt.Sprintf("name%d", i),
+			TimeSorted:   messages.TimeSorted{CreatedISO8601: fmt.Sprintf("2022-01-%02dT00:00:00Z", i+1)},
+		})
+	}
+	ids := func(items []messages.TimeSortable) []string {
+		ids := []string{}
+		for _, i := range items {
+			ids = append(ids, i.GetID())
+		}
+		return ids
+	}
+
+	var filter *ListingFilter
+	assert.Len(filter.apply(items), 10)
+	assert.Len((&ListingFilter{}).apply(items), 10)
+	assert.Equal([]string{"addr7", "addr6", "addr5"}, ids((&ListingFilter{Skip: 2, Limit: 3}).apply(items)))
+	assert.Equal([]string{"addr7", "addr5", "addr3"}, ids((&ListingFilter{ABI: "abi1", Skip: 1, Limit: 3}).apply(items)))
+	assert.Equal([]string{"addr4"}, ids((&ListingFilter{Name: "name4"}).apply(items)))
+	createdAfter, _ := time.Parse(time.RFC3339, "2022-01-07T00:00:00Z")
+	assert.Equal([]string{"addr9", "addr8", "addr7"}, ids((&ListingFilter{CreatedAfter: createdAfter}).apply(items)))
+	assert.Empty((&ListingFilter{Skip: 10}).apply(items))
+
+	// Items without a valid created time never match a createdAfter filter
+	items = append([]messages.TimeSortable{&ABIInfo{ID: "abi1", Name: "Token"}}, items...)
+	assert.Len((&ListingFilter{CreatedAfter: createdAfter}).apply(items), 3)
+	assert.Equal([]string{"abi1"}, ids((&ListingFilter{Name: "Token"}).apply(items)))
+	assert.Equal([]string{"abi1"}, ids((&ListingFilter{ABI: "abi1", Limit: 1}).apply(items)))
+}
+
+func TestListContractsFiltered(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+
+	_, err = cs.AddContract("aaaa", "abi1", "aaaa", "", nil)
+	assert.NoError(err)
+	_, err = cs.AddContract("bbbb", "abi2", "bbbb", "", nil)
+	assert.NoError(err)
+	_, err = cs.AddABI("abi1", &messages.DeployContract{ContractName: "c1"}, time.Now())
+	assert.NoError(err)
+
+	contracts, err := cs.ListContracts(&ListingFilter{ABI: "abi2"})
+	assert.NoError(err)
+	assert.Len(contracts, 1)
+	assert.Equal("bbbb", contracts[0].GetID())
+	abis, err := cs.ListABIs(&ListingFilter{Name: "c1"})
+	assert.NoError(err)
+	assert.Len(abis, 1)
+
+	loadFail := func() ([]messages.TimeSortable, error) { return nil, fmt.Errorf("pop") }
+	cs.(*contractStore).contractListing = newListingCache(loadFail)
+	cs.(*contractStore).abiListing = newListingCache(loadFail)
+	_, err = cs.ListContracts(&ListingFilter{})
+	assert.Regexp("pop", err)
+	_, err = cs.ListABIs(&ListingFilter{})
+	assert.Regexp("pop", err)
+}
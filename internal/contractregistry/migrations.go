@@ -0,0 +1,93 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+
+	ethconnecterrors "github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/migrations"
+)
+
+var legacySwaggerMatcher = regexp.MustCompile(`^contract_([0-9a-z]{40})\.swagger\.json$`)
+
+// migrations returns the upgrades of the storage path, in version order. Append new migrations
+// to the end, and never renumber released ones.
+func (cs *contractStore) migrations() []*migrations.Migration {
+	return []*migrations.Migration{
+		{
+			Version:     1,
+			Description: "Import contracts registered as legacy Swagger files into LevelDB",
+			Plan:        cs.planLegacySwaggerMigration,
+			Apply:       cs.applyLegacySwaggerMigration,
+		},
+	}
+}
+
+// legacySwaggerFiles lists the legacy Swagger files in the storage path, in name order
+func (cs *contractStore) legacySwaggerFiles() ([]os.FileInfo, error) {
+	files, err := ioutil.ReadDir(cs.conf.StoragePath)
+	if err != nil {
+		return nil, ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayReadStoragePathFailed, cs.conf.StoragePath, err)
+	}
+	var legacyFiles []os.FileInfo
+	for _, file := range files {
+		if !file.IsDir() && legacySwaggerMatcher.MatchString(file.Name()) {
+			legacyFiles = append(legacyFiles, file)
+		}
+	}
+	return legacyFiles, nil
+}
+
+func legacySwaggerAddress(file os.FileInfo) string {
+	return legacySwaggerMatcher.FindStringSubmatch(file.Name())[1]
+}
+
+func (cs *contractStore) planLegacySwaggerMigration() ([]string, error) {
+	legacyFiles, err := cs.legacySwaggerFiles()
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]string, 0, len(legacyFiles))
+	for _, file := range legacyFiles {
+		changes = append(changes, fmt.Sprintf("import contract %s from %s", legacySwaggerAddress(file), file.Name()))
+	}
+	return changes, nil
+}
+
+// applyLegacySwaggerMigration imports each file it can, leaving any that fail in place to be
+// fixed by hand, as they were before migrations were versioned
+func (cs *contractStore) applyLegacySwaggerMigration() ([]string, error) {
+	legacyFiles, err := cs.legacySwaggerFiles()
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]string, 0, len(legacyFiles))
+	for _, file := range legacyFiles {
+		address := legacySwaggerAddress(file)
+		filePath := path.Join(cs.conf.StoragePath, file.Name())
+		if cs.migrateLegacyContractFile(address, filePath, file.ModTime()) {
+			cs.cleanupMigratedFile(filePath)
+			changes = append(changes, fmt.Sprintf("imported contract %s from %s", address, file.Name()))
+		} else {
+			changes = append(changes, fmt.Sprintf("failed to import contract %s from %s", address, file.Name()))
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/go-openapi/spec"
+	"github.com/hyperledger/firefly-ethconnect/internal/migrations"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeLegacySwagger(t *testing.T, dir, address, registeredAs string) string {
+	swagger := spec.Swagger{
+		SwaggerProps: spec.SwaggerProps{
+			Info: &spec.Info{},
+		},
+	}
+	swagger.Info.AddExtension("x-firefly-deployment-id", "840b629f-2e46-413b-9671-553a886ca7bb")
+	swagger.Info.AddExtension("x-firefly-registered-name", registeredAs)
+	b, _ := json.Marshal(&swagger)
+	fileName := path.Join(dir, "contract_"+address+".swagger.json")
+	err := ioutil.WriteFile(fileName, b, 0644)
+	assert.NoError(t, err)
+	return fileName
+}
+
+func TestLegacySwaggerMigrationVersioned(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	backups := t.TempDir()
+	fileName := writeLegacySwagger(t, dir, "0123456789abcdef0123456789abcdef01234567", "legacy1")
+	ioutil.WriteFile(path.Join(dir, "contract_123456789abcdef0123456789abcdef012345678.swagger.json"), []byte(":bad"), 0644)
+
+	cs := NewContractStore(&ContractStoreConf{
+		StoragePath: dir,
+		Migrations:  migrations.MigrationConf{BackupPath: backups},
+	}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+	addr, err := cs.ResolveContractAddress("legacy1")
+	assert.NoError(err)
+	assert.Equal("0123456789abcdef0123456789abcdef01234567", addr)
+	cs.Close()
+
+	_, err = os.Stat(fileName)
+	assert.True(os.IsNotExist(err))
+	entries, _ := ioutil.ReadDir(backups)
+	assert.Len(entries, 1)
+	_, err = os.Stat(path.Join(backups, entries[0].Name(), path.Base(fileName)))
+	assert.NoError(err)
+
+	b, err := ioutil.ReadFile(path.Join(dir, migrations.StateFileName))
+	assert.NoError(err)
+	var state struct {
+		Version int                  `json:"version"`
+		History []*migrations.Record `json:"history"`
+	}
+	json.Unmarshal(b, &state)
+	assert.Equal(1, state.Version)
+	assert.Equal([]string{
+		"imported contract 0123456789abcdef0123456789abcdef01234567 from contract_0123456789abcdef0123456789abcdef01234567.swagger.json",
+		"failed to import contract 123456789abcdef0123456789abcdef012345678 from contract_123456789abcdef0123456789abcdef012345678.swagger.json",
+	}, state.History[0].Changes)
+
+	// Legacy files are not imported once the migration has been applied
+	writeLegacySwagger(t, dir, "23456789abcdef0123456789abcdef0123456789", "legacy2")
+	cs = NewContractStore(&ContractStoreConf{StoragePath: dir}, &mockRR{})
+	err = cs.Init()
+	assert.NoError(err)
+	defer cs.Close()
+	_, err = cs.ResolveContractAddress("legacy2")
+	assert.Regexp("FFEC100125", err)
+}
+
+func TestLegacySwaggerMigrationDryRun(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	fileName := writeLegacySwagger(t, dir, "0123456789abcdef0123456789abcdef01234567", "legacy1")
+
+	cs := NewContractStore(&ContractStoreConf{
+		StoragePath: dir,
+		Migrations:  migrations.MigrationConf{DryRun: true},
+	}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+	defer cs.Close()
+
+	_, err = cs.ResolveContractAddress("legacy1")
+	assert.Error(err)
+	_, err = os.Stat(fileName)
+	assert.NoError(err)
+	_, err = os.Stat(path.Join(dir, migrations.StateFileName))
+	assert.True(os.IsNotExist(err))
+	_, err = os.Stat(dir + ".backups")
+	assert.True(os.IsNotExist(err))
+
+	changes, err := cs.(*contractStore).planLegacySwaggerMigration()
+	assert.NoError(err)
+	assert.Equal([]string{"import contract 0123456789abcdef0123456789abcdef01234567 from contract_0123456789abcdef0123456789abcdef01234567.swagger.json"}, changes)
+}
+
+func TestLegacySwaggerMigrationBadDir(t *testing.T) {
+	dir := path.Join(t.TempDir(), "missing")
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, &mockRR{}).(*contractStore)
+	_, err := cs.planLegacySwaggerMigration()
+	assert.Regexp(t, "FFEC100368", err)
+	_, err = cs.applyLegacySwaggerMigration()
+	assert.Regexp(t, "FFEC100368", err)
+}
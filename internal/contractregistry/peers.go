@@ -0,0 +1,166 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	log "github.com/sirupsen/logrus"
+)
+
+// PeerLookupHeader is set on lookups made against a peer. The peer only answers from its
+// own registry, so lookups do not loop between peers.
+const PeerLookupHeader = "X-Firefly-Peer-Lookup"
+
+// PeerConf configures a peer ethconnect instance, that is queried for contract instances and
+// ABIs that are not registered locally
+type PeerConf struct {
+	utils.HTTPRequesterConf
+	URL string `json:"url"`
+}
+
+type peer struct {
+	url string
+	hr  *utils.HTTPRequester
+}
+
+func newPeers(confs []PeerConf) []*peer {
+	peers := make([]*peer, 0, len(confs))
+	for i := range confs {
+		conf := &confs[i]
+		headers := make(map[string][]string, len(conf.Headers)+1)
+		for k, v := range conf.Headers {
+			headers[k] = v
+		}
+		headers[PeerLookupHeader] = []string{"true"}
+		conf.Headers = headers
+		peers = append(peers, &peer{
+			url: strings.TrimSuffix(conf.URL, "/"),
+			hr:  utils.NewHTTPRequester("Peer "+conf.URL, &conf.HTTPRequesterConf),
+		})
+	}
+	return peers
+}
+
+// getContract looks up a contract instance by address or registered name, returning nil
+// if the peer does not have it
+func (p *peer) getContract(addrOrName string) (*ContractInfo, error) {
+	var info ContractInfo
+	found, err := p.hr.DoRequestInto("GET", p.url+"/contracts/"+url.PathEscape(addrOrName), nil, &info)
+	if err != nil || !found || info.Address == "" {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// getABI retrieves the information and ABI of an uploaded ABI, returning nil if the peer
+// does not have it
+func (p *peer) getABI(abiID string) (*ABIInfo, *messages.DeployContract, error) {
+	var info ABIInfo
+	abiURL := p.url + "/abis/" + url.PathEscape(abiID)
+	found, err := p.hr.DoRequestInto("GET", abiURL, nil, &info)
+	if err != nil || !found {
+		return nil, nil, err
+	}
+	var abi ethbinding.ABIMarshaling
+	if found, err = p.hr.DoRequestInto("GET", abiURL+"?abi", nil, &abi); err != nil || !found {
+		return nil, nil, err
+	}
+	return &info, &messages.DeployContract{
+		ContractName:    info.Name,
+		Description:     info.Description,
+		CompilerVersion: info.CompilerVersion,
+		ABI:             abi,
+	}, nil
+}
+
+// lookupPeerContract queries each peer in turn for a contract instance that is not registered
+// locally, and registers it locally along with its ABI when found
+func (cs *contractStore) lookupPeerContract(addrOrName string) (*ContractInfo, error) {
+	for _, p := range cs.peers {
+		peerInfo, err := p.getContract(addrOrName)
+		if err != nil {
+			log.Warnf("Failed to look up contract '%s' on peer %s: %s", addrOrName, p.url, err)
+			continue
+		}
+		if peerInfo == nil {
+			continue
+		}
+		if _, err := cs.GetLocalABIInfo(peerInfo.ABI); err != nil {
+			if deployMsg, err := cs.cachePeerABI(p, peerInfo.ABI); err != nil || deployMsg == nil {
+				log.Warnf("Failed to load ABI '%s' for contract '%s' from peer %s: %v", peerInfo.ABI, addrOrName, p.url, err)
+				continue
+			}
+		}
+		info := &ContractInfo{
+			Address:    strings.TrimPrefix(strings.ToLower(peerInfo.Address), "0x"),
+			ABI:        peerInfo.ABI,
+			Proxy:      peerInfo.Proxy,
+			TimeSorted: messages.TimeSorted{CreatedISO8601: time.Now().UTC().Format(time.RFC3339)},
+			Peer:       p.url,
+		}
+		info.Path = "/contracts/" + info.Address
+		info.SwaggerURL = cs.conf.BaseURL + info.Path + "?swagger"
+		// The registered name is only kept if it does not clash with a local registration
+		if peerInfo.RegisteredAs != "" && cs.checkLocalNameAvailable(peerInfo.RegisteredAs) == nil {
+			info.RegisteredAs = peerInfo.RegisteredAs
+			info.Path = "/contracts/" + peerInfo.RegisteredAs
+			info.SwaggerURL = cs.conf.BaseURL + info.Path + "?swagger"
+		}
+		log.Infof("Registering contract '%s' (0x%s) from peer %s", addrOrName, info.Address, p.url)
+		if err := cs.storeContractInfo(info); err != nil {
+			return nil, err
+		}
+		return info, nil
+	}
+	return nil, nil
+}
+
+// lookupPeerABI queries each peer in turn for an ABI that has not been uploaded locally
+func (cs *contractStore) lookupPeerABI(abiID string) *DeployContractWithAddress {
+	for _, p := range cs.peers {
+		deployMsg, err := cs.cachePeerABI(p, abiID)
+		if err != nil {
+			log.Warnf("Failed to look up ABI '%s' on peer %s: %s", abiID, p.url, err)
+			continue
+		}
+		if deployMsg != nil {
+			return &DeployContractWithAddress{Contract: deployMsg}
+		}
+	}
+	return nil
+}
+
+// cachePeerABI stores an ABI from a peer locally, under the same ID
+func (cs *contractStore) cachePeerABI(p *peer, abiID string) (*messages.DeployContract, error) {
+	info, deployMsg, err := p.getABI(abiID)
+	if err != nil || deployMsg == nil {
+		return nil, err
+	}
+	createdTime, err := time.Parse(time.RFC3339, info.CreatedISO8601)
+	if err != nil {
+		createdTime = time.Now()
+	}
+	log.Infof("Storing ABI '%s' from peer %s", abiID, p.url)
+	if _, err = cs.AddABI(abiID, deployMsg, createdTime); err != nil {
+		return nil, err
+	}
+	return deployMsg, nil
+}
@@ -0,0 +1,248 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPeerAddress = "0123456789abcdef0123456789abcdef01234567"
+
+type mockPeer struct {
+	mux      sync.Mutex
+	requests []string
+	status   int
+}
+
+func (m *mockPeer) count() int {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return len(m.requests)
+}
+
+func newMockPeer(t *testing.T) (*mockPeer, *httptest.Server) {
+	m := &mockPeer{status: 200}
+	svr := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		m.mux.Lock()
+		m.requests = append(m.requests, req.URL.String())
+		m.mux.Unlock()
+		assert.Equal(t, "true", req.Header.Get(PeerLookupHeader))
+		username, password, _ := req.BasicAuth()
+		assert.Equal(t, "peeruser", username)
+		assert.Equal(t, "peerpass", password)
+		if m.status != 200 {
+			res.WriteHeader(m.status)
+			_, _ = res.Write([]byte(`{"error":"pop"}`))
+			return
+		}
+		var body interface{}
+		switch req.URL.String() {
+		case "/contracts/" + testPeerAddress, "/contracts/peerToken":
+			body = &ContractInfo{
+				Address:      testPeerAddress,
+				ABI:          "abi1",
+				RegisteredAs: "peerToken",
+				Path:         "/contracts/peerToken",
+			}
+		case "/contracts/noABI":
+			body = &ContractInfo{Address: testPeerAddress, ABI: "abi9"}
+		case "/abis/abi1":
+			body = &ABIInfo{ID: "abi1", Name: "Token", Description: "A token"}
+		case "/abis/abi1?abi":
+			body = []map[string]interface{}{{"type": "function", "name": "transfer"}}
+		default:
+			res.WriteHeader(404)
+			_, _ = res.Write([]byte(`{"error":"not found"}`))
+			return
+		}
+		res.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(res).Encode(body)
+	}))
+	return m, svr
+}
+
+func newTestPeerStore(t *testing.T, dir string, peerURLs ...string) ContractStore {
+	var peers []PeerConf
+	for _, u := range peerURLs {
+		peers = append(peers, PeerConf{
+			URL: u + "/",
+			HTTPRequesterConf: utils.HTTPRequesterConf{
+				BasicAuth: &utils.HTTPBasicAuthConf{Username: "peeruser", Password: "peerpass"},
+			},
+		})
+	}
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir, BaseURL: "http://localhost:8080", Peers: peers}, &mockRR{})
+	assert.NoError(t, cs.Init())
+	return cs
+}
+
+func TestPeerContractByAddress(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	m, svr := newMockPeer(t)
+	defer svr.Close()
+
+	cs := newTestPeerStore(t, dir, svr.URL)
+	defer cs.Close()
+
+	info, err := cs.GetContractByAddress("0x" + testPeerAddress)
+	assert.NoError(err)
+	assert.Equal(testPeerAddress, info.Address)
+	assert.Equal("abi1", info.ABI)
+	assert.Equal("peerToken", info.RegisteredAs)
+	assert.Equal("/contracts/peerToken", info.Path)
+	assert.Equal("http://localhost:8080/contracts/peerToken?swagger", info.SwaggerURL)
+	assert.Equal(svr.URL, info.Peer)
+
+	// The contract and ABI are now registered locally
+	requests := m.count()
+	info, err = cs.GetContractByAddress(testPeerAddress)
+	assert.NoError(err)
+	assert.Equal(svr.URL, info.Peer)
+	addr, err := cs.ResolveContractAddress("peerToken")
+	assert.NoError(err)
+	assert.Equal(testPeerAddress, addr)
+	deployMsg, err := cs.GetABI(ABILocation{ABIType: LocalABI, Name: "abi1"}, false)
+	assert.NoError(err)
+	assert.Equal("Token", deployMsg.Contract.ContractName)
+	assert.Equal("transfer", deployMsg.Contract.ABI[0].Name)
+	assert.Equal(requests, m.count())
+}
+
+func TestPeerContractByName(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	_, svr := newMockPeer(t)
+	defer svr.Close()
+
+	cs := newTestPeerStore(t, dir, svr.URL)
+	defer cs.Close()
+
+	addr, err := cs.ResolveContractAddress("peerToken")
+	assert.NoError(err)
+	assert.Equal(testPeerAddress, addr)
+
+	_, err = cs.ResolveContractAddress("unknown")
+	assert.Regexp("FFEC100125", err)
+}
+
+func TestPeerContractNameClash(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	_, svr := newMockPeer(t)
+	defer svr.Close()
+
+	cs := newTestPeerStore(t, dir, svr.URL)
+	defer cs.Close()
+	_, err := cs.AddContract("fedcba9876543210fedcba9876543210fedcba98", "abi2", "peerToken", "peerToken", nil)
+	assert.NoError(err)
+
+	info, err := cs.GetContractByAddress(testPeerAddress)
+	assert.NoError(err)
+	assert.Equal("", info.RegisteredAs)
+	assert.Equal("/contracts/"+testPeerAddress, info.Path)
+}
+
+func TestPeerABI(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	_, svr := newMockPeer(t)
+	defer svr.Close()
+
+	cs := newTestPeerStore(t, dir, svr.URL)
+	defer cs.Close()
+
+	deployMsg, err := cs.GetABI(ABILocation{ABIType: LocalABI, Name: "abi1"}, false)
+	assert.NoError(err)
+	assert.Equal("A token", deployMsg.Contract.Description)
+	abiInfo, err := cs.GetLocalABIInfo("abi1")
+	assert.NoError(err)
+	assert.Equal("Token", abiInfo.Name)
+
+	_, err = cs.GetABI(ABILocation{ABIType: LocalABI, Name: "abi2"}, false)
+	assert.Regexp("FFEC100127", err)
+}
+
+func TestPeerFailureTriesNextPeer(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	m1, svr1 := newMockPeer(t)
+	defer svr1.Close()
+	m1.status = 500
+	m2, svr2 := newMockPeer(t)
+	defer svr2.Close()
+
+	cs := newTestPeerStore(t, dir, svr1.URL, svr2.URL)
+	defer cs.Close()
+
+	info, err := cs.GetContractByAddress(testPeerAddress)
+	assert.NoError(err)
+	assert.Equal(svr2.URL, info.Peer)
+	assert.Equal(1, m1.count())
+	assert.Equal(3, m2.count())
+
+	_, err = cs.GetABI(ABILocation{ABIType: LocalABI, Name: "abi2"}, false)
+	assert.Regexp("FFEC100127", err)
+}
+
+func TestPeerMissingABI(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	_, svr := newMockPeer(t)
+	defer svr.Close()
+
+	cs := newTestPeerStore(t, dir, svr.URL)
+	defer cs.Close()
+
+	// The contract is not registered if its ABI cannot be loaded from the peer
+	_, err := cs.ResolveContractAddress("noABI")
+	assert.Regexp("FFEC100125", err)
+	_, err = cs.GetContractByAddress(testPeerAddress + "00")
+	assert.Regexp("FFEC100126", err)
+}
+
+func TestPeerLocalResolver(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	m, svr := newMockPeer(t)
+	defer svr.Close()
+
+	cs := newTestPeerStore(t, dir, svr.URL)
+	defer cs.Close()
+
+	local := cs.LocalResolver()
+	_, err := local.GetContractByAddress(testPeerAddress)
+	assert.Regexp("FFEC100126", err)
+	_, err = local.ResolveContractAddress("peerToken")
+	assert.Regexp("FFEC100125", err)
+	_, err = local.GetABI(ABILocation{ABIType: LocalABI, Name: "abi1"}, false)
+	assert.Regexp("FFEC100127", err)
+	assert.NoError(local.CheckNameAvailable("peerToken"))
+	assert.Equal(0, m.count())
+}
@@ -0,0 +1,34 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+// ContractStorePersistence interface implemented by the storage layers of the local contract
+// registry. Lookups return nil, with no error, when the entry does not exist.
+type ContractStorePersistence interface {
+	Init() error
+	Close()
+	GetContract(addrHexNo0x string) (*ContractInfo, error)
+	PutContract(info *ContractInfo) error
+	DeleteContract(addrHexNo0x string) error
+	GetRegisteredName(name string) (*ContractInfo, error)
+	PutRegisteredName(info *ContractInfo) error
+	DeleteRegisteredName(name string) error
+	GetABI(abiID string) (*StoredABI, error)
+	GetABIInfo(abiID string) (*ABIInfo, error)
+	PutABI(abi *StoredABI) error
+	DeleteABI(abiID string) error
+	ListContracts() ([]*ContractInfo, error)
+	ListABIs() ([]*ABIInfo, error)
+}
@@ -0,0 +1,97 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RemoteCacheConf configures how long ABIs loaded from the remote registry are served from
+// the in-memory cache. With no TTL they are cached until evicted, or explicitly refreshed.
+type RemoteCacheConf struct {
+	TTLSec int `json:"ttlSec,omitempty"`
+	// RefreshIntervalSec enables a background refresh of the cached remote ABIs, so they are
+	// reloaded before they expire rather than by the next request that uses them
+	RefreshIntervalSec int `json:"refreshIntervalSec,omitempty"`
+}
+
+// cachedABI is an entry in the ABI cache, with the time it was loaded
+type cachedABI struct {
+	deployMsg *DeployContractWithAddress
+	loaded    time.Time
+}
+
+func isRemoteLocation(location ABILocation) bool {
+	return location.ABIType == RemoteGateway || location.ABIType == RemoteInstance
+}
+
+// expired checks whether a cached remote ABI has outlived the TTL. Local ABIs are removed
+// from the cache when they change, so never expire.
+func (cs *contractStore) expired(location ABILocation, entry *cachedABI) bool {
+	return cs.remoteCacheTTL > 0 && isRemoteLocation(location) && time.Since(entry.loaded) >= cs.remoteCacheTTL
+}
+
+func (cs *contractStore) startRemoteRefresh() {
+	if cs.remoteRefreshInterval <= 0 {
+		return
+	}
+	cs.refreshStop = make(chan struct{})
+	cs.refreshDone = make(chan struct{})
+	go cs.remoteRefreshLoop()
+}
+
+func (cs *contractStore) stopRemoteRefresh() {
+	if cs.refreshStop != nil {
+		close(cs.refreshStop)
+		<-cs.refreshDone
+		cs.refreshStop = nil
+	}
+}
+
+func (cs *contractStore) remoteRefreshLoop() {
+	defer close(cs.refreshDone)
+	log.Infof("Remote registry cache refresh enabled ttl=%s interval=%s", cs.remoteCacheTTL, cs.remoteRefreshInterval)
+	for {
+		select {
+		case <-time.After(cs.remoteRefreshInterval):
+			cs.refreshRemoteABIs()
+		case <-cs.refreshStop:
+			return
+		}
+	}
+}
+
+// refreshRemoteABIs reloads the cached remote ABIs that would expire before the next refresh,
+// or all of them if there is no TTL. A failed reload leaves the cached copy in place.
+func (cs *contractStore) refreshRemoteABIs() {
+	for _, key := range cs.abiCache.Keys() {
+		location := key.(ABILocation)
+		if !isRemoteLocation(location) {
+			continue
+		}
+		cached, ok := cs.abiCache.Peek(location)
+		if !ok {
+			continue
+		}
+		if cs.remoteCacheTTL > 0 && time.Since(cached.(*cachedABI).loaded)+cs.remoteRefreshInterval < cs.remoteCacheTTL {
+			continue
+		}
+		if _, err := cs.getABI(location, true, false); err != nil {
+			log.Warnf("Background refresh of %+v from the remote registry failed: %s", location, err)
+		}
+	}
+}
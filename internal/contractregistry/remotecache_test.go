@@ -0,0 +1,177 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingRR serves a new contract name on each load, and counts the refreshes
+type countingRR struct {
+	mockRR
+	mux       sync.Mutex
+	loads     int
+	refreshes int
+}
+
+func (rr *countingRR) load(refresh bool) (*DeployContractWithAddress, error) {
+	rr.mux.Lock()
+	defer rr.mux.Unlock()
+	if rr.err != nil {
+		return nil, rr.err
+	}
+	rr.loads++
+	if refresh {
+		rr.refreshes++
+	}
+	return &DeployContractWithAddress{
+		Contract: &messages.DeployContract{ContractName: fmt.Sprintf("remote%d", rr.loads)},
+		Address:  "12345",
+	}, nil
+}
+
+func (rr *countingRR) LoadFactoryForGateway(id string, refresh bool) (*messages.DeployContract, error) {
+	msg, err := rr.load(refresh)
+	if err != nil {
+		return nil, err
+	}
+	return msg.Contract, nil
+}
+
+func (rr *countingRR) LoadFactoryForInstance(id string, refresh bool) (*DeployContractWithAddress, error) {
+	return rr.load(refresh)
+}
+
+func (rr *countingRR) setErr(err error) {
+	rr.mux.Lock()
+	defer rr.mux.Unlock()
+	rr.err = err
+}
+
+func (rr *countingRR) refreshCount() int {
+	rr.mux.Lock()
+	defer rr.mux.Unlock()
+	return rr.refreshes
+}
+
+func newTestRemoteCacheStore(t *testing.T, ttl, interval time.Duration) (*contractStore, *countingRR) {
+	rr := &countingRR{}
+	cs := NewContractStore(&ContractStoreConf{StoragePath: t.TempDir()}, rr).(*contractStore)
+	cs.remoteCacheTTL = ttl
+	cs.remoteRefreshInterval = interval
+	err := cs.Init()
+	assert.NoError(t, err)
+	return cs, rr
+}
+
+func TestRemoteCacheTTL(t *testing.T) {
+	assert := assert.New(t)
+	cs, rr := newTestRemoteCacheStore(t, 50*time.Millisecond, 0)
+	defer cs.Close()
+
+	location := ABILocation{ABIType: RemoteGateway, Name: "lobster"}
+	info, err := cs.GetABI(location, false)
+	assert.NoError(err)
+	assert.Equal("remote1", info.Contract.ContractName)
+
+	info, err = cs.GetABI(location, false)
+	assert.NoError(err)
+	assert.Equal("remote1", info.Contract.ContractName)
+
+	// Once expired it is reloaded, bypassing the remote registry's own cache
+	time.Sleep(60 * time.Millisecond)
+	info, err = cs.GetABI(location, false)
+	assert.NoError(err)
+	assert.Equal("remote2", info.Contract.ContractName)
+	assert.Equal(1, rr.refreshCount())
+
+	// If it cannot be reloaded, the expired copy is used
+	time.Sleep(60 * time.Millisecond)
+	rr.setErr(fmt.Errorf("pop"))
+	info, err = cs.GetABI(location, false)
+	assert.NoError(err)
+	assert.Equal("remote2", info.Contract.ContractName)
+
+	// But not when a refresh is requested explicitly
+	_, err = cs.GetABI(location, true)
+	assert.Regexp("pop", err)
+}
+
+func TestRemoteCacheTTLLocalABIsDoNotExpire(t *testing.T) {
+	assert := assert.New(t)
+	cs, _ := newTestRemoteCacheStore(t, time.Nanosecond, 0)
+	defer cs.Close()
+
+	location := ABILocation{ABIType: LocalABI, Name: "abi1"}
+	cs.abiCache.Add(location, &cachedABI{
+		deployMsg: &DeployContractWithAddress{Contract: &messages.DeployContract{ContractName: "local"}},
+		loaded:    time.Now().Add(-1 * time.Hour),
+	})
+	info, err := cs.GetABI(location, false)
+	assert.NoError(err)
+	assert.Equal("local", info.Contract.ContractName)
+}
+
+func TestRemoteCacheBackgroundRefresh(t *testing.T) {
+	assert := assert.New(t)
+	cs, rr := newTestRemoteCacheStore(t, time.Hour, 10*time.Millisecond)
+
+	location := ABILocation{ABIType: RemoteInstance, Name: "lobster"}
+	_, err := cs.GetABI(location, false)
+	assert.NoError(err)
+	cs.abiCache.Add(ABILocation{ABIType: LocalABI, Name: "abi1"}, &cachedABI{
+		deployMsg: &DeployContractWithAddress{Contract: &messages.DeployContract{ContractName: "local"}},
+		loaded:    time.Now().Add(-2 * time.Hour),
+	})
+
+	// Nothing is close to expiring
+	time.Sleep(30 * time.Millisecond)
+	assert.Zero(rr.refreshCount())
+
+	// Until the entry gets within an interval of the TTL, when it is reloaded in the background
+	cs.abiCache.Add(location, &cachedABI{
+		deployMsg: &DeployContractWithAddress{Contract: &messages.DeployContract{ContractName: "old"}},
+		loaded:    time.Now().Add(-1 * time.Hour),
+	})
+	assert.Eventually(func() bool { return rr.refreshCount() > 0 }, time.Second, time.Millisecond)
+	info, err := cs.GetABI(location, false)
+	assert.NoError(err)
+	assert.NotEqual("old", info.Contract.ContractName)
+
+	// A failed refresh leaves the cached copy in place
+	rr.setErr(fmt.Errorf("pop"))
+	cs.refreshRemoteABIs()
+	assert.True(cs.abiCache.Contains(location))
+
+	cs.Close()
+	assert.Nil(cs.refreshStop)
+	cs.Close()
+}
+
+func TestRemoteCacheBackgroundRefreshNoTTL(t *testing.T) {
+	assert := assert.New(t)
+	cs, rr := newTestRemoteCacheStore(t, 0, 10*time.Millisecond)
+	defer cs.Close()
+
+	_, err := cs.GetABI(ABILocation{ABIType: RemoteGateway, Name: "lobster"}, false)
+	assert.NoError(err)
+	assert.Eventually(func() bool { return rr.refreshCount() > 1 }, time.Second, time.Millisecond)
+}
@@ -50,6 +50,7 @@ type RemoteRegistry interface {
 	LoadFactoryForGateway(lookupStr string, refresh bool) (*messages.DeployContract, error)
 	LoadFactoryForInstance(lookupStr string, refresh bool) (*DeployContractWithAddress, error)
 	RegisterInstance(lookupStr, address string) error
+	PublishGateway(lookupStr string, deployMsg *messages.DeployContract) error
 	Init() error
 	Close()
 }
@@ -138,9 +139,14 @@ func (rr *remoteRegistry) loadFactoryFromURL(baseURL, ns, lookupStr string, refr
 	}
 	queryURL := baseURL + safeLookupStr
 	jsonRes, err := rr.hr.DoRequest("GET", queryURL, nil)
-	if err != nil || jsonRes == nil {
+	if err != nil {
 		return nil, err
 	}
+	if jsonRes == nil {
+		// Deleted from the registry since we cached it
+		rr.deleteFactoryFromCacheDB(ns + "/" + safeLookupStr)
+		return nil, nil
+	}
 	idString, err := rr.hr.GetResponseString(jsonRes, rr.conf.PropNames.ID, false)
 	if err != nil {
 		return nil, err
@@ -221,6 +227,15 @@ func (rr *remoteRegistry) storeFactoryToCacheDB(cacheKey string, msg *DeployCont
 	}
 }
 
+func (rr *remoteRegistry) deleteFactoryFromCacheDB(cacheKey string) {
+	if rr.db == nil {
+		return
+	}
+	if err := rr.db.Delete(cacheKey); err != nil {
+		log.Warnf("Failed to delete cached bytes for key %s: %s", cacheKey, err)
+	}
+}
+
 func (rr *remoteRegistry) LoadFactoryForGateway(lookupStr string, refresh bool) (*messages.DeployContract, error) {
 	if rr.conf.GatewayURLPrefix == "" {
 		return nil, nil
@@ -254,6 +269,31 @@ func (rr *remoteRegistry) RegisterInstance(lookupStr, address string) error {
 	if err != nil {
 		return errors.Errorf(errors.RemoteRegistryRegistrationFailed, err)
 	}
+	rr.deleteFactoryFromCacheDB("instances/" + safeLookupStr)
+	return nil
+}
+
+// PublishGateway creates or updates a gateway in the remote registry, with the same properties
+// that LoadFactoryForGateway reads back
+func (rr *remoteRegistry) PublishGateway(lookupStr string, deployMsg *messages.DeployContract) error {
+	if rr.conf.GatewayURLPrefix == "" {
+		return errors.Errorf(errors.RemoteRegistryNotConfigured)
+	}
+	safeLookupStr := url.QueryEscape(lookupStr)
+	requestURL := strings.TrimSuffix(rr.conf.GatewayURLPrefix, "/")
+	abiBytes, _ := json.Marshal(deployMsg.ABI)
+	bodyMap := make(map[string]interface{})
+	bodyMap[rr.conf.PropNames.Name] = safeLookupStr
+	bodyMap[rr.conf.PropNames.ABI] = string(abiBytes)
+	bodyMap[rr.conf.PropNames.Bytecode] = "0x" + hex.EncodeToString(deployMsg.Compiled)
+	bodyMap[rr.conf.PropNames.Devdoc] = deployMsg.DevDoc
+	bodyMap[rr.conf.PropNames.Deployable] = len(deployMsg.Compiled) > 0
+	log.Debugf("Publishing gateway: %s", safeLookupStr)
+	_, err := rr.hr.DoRequest("POST", requestURL, bodyMap)
+	if err != nil {
+		return errors.Errorf(errors.RemoteRegistryPublishFailed, err)
+	}
+	rr.deleteFactoryFromCacheDB("gateways/" + safeLookupStr)
 	return nil
 }
 
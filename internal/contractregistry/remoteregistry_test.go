@@ -26,7 +26,9 @@ import (
 
 	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
 	"github.com/hyperledger/firefly-ethconnect/internal/kvstore"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
 	"github.com/julienschmidt/httprouter"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -196,6 +198,49 @@ func TestRemoteRegistryloadFactoryForGatewayCached(t *testing.T) {
 	assert.Equal(2, callCount)
 }
 
+func TestRemoteRegistryloadFactoryForGatewayDeleted(t *testing.T) {
+	dir := tempdir()
+	defer cleanup(dir)
+
+	assert := assert.New(t)
+
+	deleted := false
+	router := &httprouter.Router{}
+	router.GET("/somepath/:id", func(res http.ResponseWriter, req *http.Request, parms httprouter.Params) {
+		if deleted {
+			res.WriteHeader(404)
+			return
+		}
+		testDataBytes, _ := ioutil.ReadFile("../../test/simpleevents.solc.output.json")
+		res.WriteHeader(200)
+		res.Write(testDataBytes)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	r := NewRemoteRegistry(&RemoteRegistryConf{
+		CacheDB:          path.Join(dir, "testdb"),
+		GatewayURLPrefix: server.URL + "/somepath",
+		PropNames: RemoteRegistryPropNamesConf{
+			Bytecode: "bin",
+		},
+	})
+	rr := r.(*remoteRegistry)
+	rr.Init()
+	defer rr.Close()
+
+	res, err := rr.LoadFactoryForGateway("testid", false)
+	assert.NoError(err)
+	assert.NotNil(res)
+	assert.NotNil(rr.loadFactoryFromCacheDB("gateways/testid"))
+
+	deleted = true
+	res, err = rr.LoadFactoryForGateway("testid", true)
+	assert.NoError(err)
+	assert.Nil(res)
+	assert.Nil(rr.loadFactoryFromCacheDB("gateways/testid"))
+}
+
 func TestRemoteRegistryRegisterInstanceSuccess(t *testing.T) {
 	assert := assert.New(t)
 
@@ -259,6 +304,91 @@ func TestRemoteRegistryRegisterNoInstanceURL(t *testing.T) {
 	assert.Regexp("No remote registry is configured", err)
 }
 
+func TestRemoteRegistryPublishGatewayRoundTrip(t *testing.T) {
+	dir := tempdir()
+	defer cleanup(dir)
+	assert := assert.New(t)
+
+	gateways := make(map[string]map[string]interface{})
+	router := &httprouter.Router{}
+	router.POST("/somepath", func(res http.ResponseWriter, req *http.Request, parms httprouter.Params) {
+		var bodyMap map[string]interface{}
+		json.NewDecoder(req.Body).Decode(&bodyMap)
+		bodyMap["id"] = "gw1"
+		gateways[bodyMap["name"].(string)] = bodyMap
+		res.WriteHeader(204)
+	})
+	router.GET("/somepath/:id", func(res http.ResponseWriter, req *http.Request, parms httprouter.Params) {
+		gw, ok := gateways[parms.ByName("id")]
+		if !ok {
+			res.WriteHeader(404)
+			return
+		}
+		res.WriteHeader(200)
+		json.NewEncoder(res).Encode(gw)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	r := NewRemoteRegistry(&RemoteRegistryConf{
+		CacheDB:          path.Join(dir, "testdb"),
+		GatewayURLPrefix: server.URL + "/somepath",
+	})
+	rr := r.(*remoteRegistry)
+	assert.NoError(rr.Init())
+	defer rr.db.Close()
+
+	deployMsg := &messages.DeployContract{
+		ABI: ethbinding.ABIMarshaling{
+			{
+				Type: "function", Name: "set", StateMutability: "nonpayable",
+				Inputs:  []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "uint256"}},
+				Outputs: []ethbinding.ABIArgumentMarshaling{},
+			},
+		},
+		Compiled: []byte{0x60, 0x80},
+		DevDoc:   `{"methods":{}}`,
+	}
+	err := rr.PublishGateway("testid", deployMsg)
+	assert.NoError(err)
+	assert.Equal(true, gateways["testid"]["deployable"])
+
+	res, err := rr.LoadFactoryForGateway("testid", false)
+	assert.NoError(err)
+	assert.Equal(deployMsg.ABI, res.ABI)
+	assert.Equal(deployMsg.Compiled, res.Compiled)
+	assert.Equal(deployMsg.DevDoc, res.DevDoc)
+
+	// Publishing again clears the cached copy
+	deployMsg.DevDoc = "updated"
+	err = rr.PublishGateway("testid", deployMsg)
+	assert.NoError(err)
+	res, err = rr.LoadFactoryForGateway("testid", false)
+	assert.NoError(err)
+	assert.Equal("updated", res.DevDoc)
+}
+
+func TestRemoteRegistryPublishGatewayFail(t *testing.T) {
+	assert := assert.New(t)
+
+	router := &httprouter.Router{}
+	router.POST("/somepath", func(res http.ResponseWriter, req *http.Request, parms httprouter.Params) {
+		res.WriteHeader(500)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	r := NewRemoteRegistry(&RemoteRegistryConf{
+		GatewayURLPrefix: server.URL + "/somepath",
+	})
+	err := r.PublishGateway("testid", &messages.DeployContract{})
+	assert.Regexp("FFEC100347", err)
+
+	r = NewRemoteRegistry(&RemoteRegistryConf{})
+	err = r.PublishGateway("testid", &messages.DeployContract{})
+	assert.Regexp("No remote registry is configured", err)
+}
+
 func TestRemoteRegistryLoadFactoryMissingID(t *testing.T) {
 	assert := assert.New(t)
 
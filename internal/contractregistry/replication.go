@@ -0,0 +1,292 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/IBM/sarama"
+	ethconnecterrors "github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/kafka"
+	log "github.com/sirupsen/logrus"
+)
+
+const replicationReconnectDelay = 5 * time.Second
+
+const (
+	mutationPutContract    = "putContract"
+	mutationDeleteContract = "deleteContract"
+	mutationPutName        = "putName"
+	mutationDeleteName     = "deleteName"
+	mutationPutABI         = "putABI"
+	mutationDeleteABI      = "deleteABI"
+)
+
+// ReplicationConf configures replication of the local registry between ethconnect instances
+// that do not share a storage path. Each change is published to a Kafka topic, which every
+// instance consumes with its own consumer group to apply the changes made by the others.
+type ReplicationConf struct {
+	// InstanceID must be unique to each instance, and stable across restarts, as it
+	// names the consumer group that records how far the instance has replicated
+	InstanceID string `json:"instanceID"`
+	kafka.KafkaCommonConf
+	Topic string `json:"topic"`
+}
+
+// registryMutation is a single write to the persistence layer, as published to peers
+type registryMutation struct {
+	Origin   string        `json:"origin"`
+	Op       string        `json:"op"`
+	Contract *ContractInfo `json:"contract,omitempty"`
+	ABI      *StoredABI    `json:"abi,omitempty"`
+	ID       string        `json:"id,omitempty"`
+}
+
+// key partitions the mutations by the entry they change, so the changes to each entry
+// are applied by peers in the order they were made
+func (m *registryMutation) key() string {
+	switch m.Op {
+	case mutationPutContract:
+		return "contract/" + m.Contract.Address
+	case mutationPutName:
+		return "name/" + m.Contract.RegisteredAs
+	case mutationDeleteName:
+		return "name/" + m.ID
+	case mutationPutABI:
+		return "abi/" + m.ABI.ID
+	case mutationDeleteABI:
+		return "abi/" + m.ID
+	default:
+		return "contract/" + m.ID
+	}
+}
+
+var newReplicationProducer = sarama.NewSyncProducer
+var newReplicationConsumerGroup = sarama.NewConsumerGroup
+
+// replicatingPersistence wraps the local persistence layer, publishing each successful write
+// to the replication topic, and applying the writes of peers consumed from the topic
+type replicatingPersistence struct {
+	ContractStorePersistence
+	conf       *ReplicationConf
+	onApplied  func(m *registryMutation)
+	producer   sarama.SyncProducer
+	consumer   sarama.ConsumerGroup
+	cancelFunc context.CancelFunc
+	done       chan struct{}
+}
+
+func newReplicatingPersistence(conf *ReplicationConf, inner ContractStorePersistence, onApplied func(m *registryMutation)) *replicatingPersistence {
+	return &replicatingPersistence{
+		ContractStorePersistence: inner,
+		conf:                     conf,
+		onApplied:                onApplied,
+	}
+}
+
+func (p *replicatingPersistence) saramaConfig() (*sarama.Config, error) {
+	conf := p.conf
+	if conf.InstanceID == "" {
+		return nil, ethconnecterrors.Errorf(ethconnecterrors.RegistryReplicationMissingConfig, "instanceID")
+	}
+	if len(conf.Brokers) == 0 || conf.Brokers[0] == "" {
+		return nil, ethconnecterrors.Errorf(ethconnecterrors.RegistryReplicationMissingConfig, "brokers")
+	}
+	if conf.Topic == "" {
+		return nil, ethconnecterrors.Errorf(ethconnecterrors.RegistryReplicationMissingConfig, "topic")
+	}
+	clientConf, err := kafka.NewSaramaConfig(conf.ClientID, &conf.TLS, conf.SASL.Username, conf.SASL.Password)
+	if err != nil {
+		return nil, err
+	}
+	clientConf.Producer.Return.Successes = true
+	clientConf.Producer.RequiredAcks = sarama.WaitForAll
+	// A new instance starts from the beginning of the topic, to catch up with the registry
+	clientConf.Consumer.Offsets.Initial = sarama.OffsetOldest
+	clientConf.Consumer.Return.Errors = true
+	return clientConf, nil
+}
+
+// Init initializes the local persistence, then connects to Kafka and starts applying the
+// changes made by peers
+func (p *replicatingPersistence) Init() error {
+	clientConf, err := p.saramaConfig()
+	if err != nil {
+		return err
+	}
+	if err := p.ContractStorePersistence.Init(); err != nil {
+		return err
+	}
+	if p.producer, err = newReplicationProducer(p.conf.Brokers, clientConf); err != nil {
+		return err
+	}
+	if p.consumer, err = newReplicationConsumerGroup(p.conf.Brokers, p.consumerGroup(), clientConf); err != nil {
+		_ = p.producer.Close()
+		return err
+	}
+	var ctx context.Context
+	ctx, p.cancelFunc = context.WithCancel(context.Background())
+	p.done = make(chan struct{})
+	go p.consumeLoop(ctx)
+	log.Infof("Replicating registry on topic '%s' as instance '%s'", p.conf.Topic, p.conf.InstanceID)
+	return nil
+}
+
+func (p *replicatingPersistence) consumerGroup() string {
+	return "ethconnect-registry-" + p.conf.InstanceID
+}
+
+func (p *replicatingPersistence) Close() {
+	if p.cancelFunc != nil {
+		p.cancelFunc()
+		_ = p.consumer.Close()
+		<-p.done
+		_ = p.producer.Close()
+	}
+	p.ContractStorePersistence.Close()
+}
+
+func (p *replicatingPersistence) consumeLoop(ctx context.Context) {
+	defer close(p.done)
+	go func() {
+		for err := range p.consumer.Errors() {
+			log.Errorf("Registry replication consumer error: %s", err)
+		}
+	}()
+	for {
+		err := p.consumer.Consume(ctx, []string{p.conf.Topic}, p)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warnf("Registry replication consumer stopped (reconnecting in %s): %v", replicationReconnectDelay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(replicationReconnectDelay):
+		}
+	}
+}
+
+func (p *replicatingPersistence) Setup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (p *replicatingPersistence) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (p *replicatingPersistence) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		var m registryMutation
+		if err := json.Unmarshal(msg.Value, &m); err != nil {
+			log.Errorf("Discarded invalid registry replication message at %s/%d/%d: %s", msg.Topic, msg.Partition, msg.Offset, err)
+		} else if m.Origin != p.conf.InstanceID {
+			if err := p.apply(&m); err != nil {
+				log.Errorf("Failed to apply replicated registry change %s from '%s': %s", m.Op, m.Origin, err)
+			} else {
+				p.onApplied(&m)
+			}
+		}
+		session.MarkMessage(msg, "")
+	}
+	return nil
+}
+
+// apply writes a change made by a peer to the local persistence, without publishing it again
+func (p *replicatingPersistence) apply(m *registryMutation) error {
+	log.Debugf("Applying replicated registry change %s from '%s'", m.Op, m.Origin)
+	inner := p.ContractStorePersistence
+	switch {
+	case m.Op == mutationPutContract && m.Contract != nil:
+		return inner.PutContract(m.Contract)
+	case m.Op == mutationDeleteContract:
+		return inner.DeleteContract(m.ID)
+	case m.Op == mutationPutName && m.Contract != nil:
+		return inner.PutRegisteredName(m.Contract)
+	case m.Op == mutationDeleteName:
+		return inner.DeleteRegisteredName(m.ID)
+	case m.Op == mutationPutABI && m.ABI != nil:
+		return inner.PutABI(m.ABI)
+	case m.Op == mutationDeleteABI:
+		return inner.DeleteABI(m.ID)
+	}
+	log.Warnf("Ignored unknown registry replication message: %s", m.Op)
+	return nil
+}
+
+// publish sends a local change to peers. The change has already been stored locally, so a
+// failure to publish is logged rather than failing the request.
+func (p *replicatingPersistence) publish(m *registryMutation) {
+	m.Origin = p.conf.InstanceID
+	b, _ := json.Marshal(m)
+	_, _, err := p.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: p.conf.Topic,
+		Key:   sarama.StringEncoder(m.key()),
+		Value: sarama.ByteEncoder(b),
+	})
+	if err != nil {
+		log.Errorf("Failed to replicate registry change %s of '%s': %s", m.Op, m.key(), err)
+	}
+}
+
+func (p *replicatingPersistence) PutContract(info *ContractInfo) error {
+	if err := p.ContractStorePersistence.PutContract(info); err != nil {
+		return err
+	}
+	p.publish(&registryMutation{Op: mutationPutContract, Contract: info})
+	return nil
+}
+
+func (p *replicatingPersistence) DeleteContract(addrHexNo0x string) error {
+	if err := p.ContractStorePersistence.DeleteContract(addrHexNo0x); err != nil {
+		return err
+	}
+	p.publish(&registryMutation{Op: mutationDeleteContract, ID: addrHexNo0x})
+	return nil
+}
+
+func (p *replicatingPersistence) PutRegisteredName(info *ContractInfo) error {
+	if err := p.ContractStorePersistence.PutRegisteredName(info); err != nil {
+		return err
+	}
+	p.publish(&registryMutation{Op: mutationPutName, Contract: info})
+	return nil
+}
+
+func (p *replicatingPersistence) DeleteRegisteredName(name string) error {
+	if err := p.ContractStorePersistence.DeleteRegisteredName(name); err != nil {
+		return err
+	}
+	p.publish(&registryMutation{Op: mutationDeleteName, ID: name})
+	return nil
+}
+
+func (p *replicatingPersistence) PutABI(abi *StoredABI) error {
+	if err := p.ContractStorePersistence.PutABI(abi); err != nil {
+		return err
+	}
+	p.publish(&registryMutation{Op: mutationPutABI, ABI: abi})
+	return nil
+}
+
+func (p *replicatingPersistence) DeleteABI(abiID string) error {
+	if err := p.ContractStorePersistence.DeleteABI(abiID); err != nil {
+		return err
+	}
+	p.publish(&registryMutation{Op: mutationDeleteABI, ID: abiID})
+	return nil
+}
@@ -0,0 +1,277 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/hyperledger/firefly-ethconnect/internal/kafka"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	"github.com/hyperledger/firefly-ethconnect/mocks/saramamocks"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type capturingProducer struct {
+	sarama.SyncProducer
+	messages []*sarama.ProducerMessage
+	err      error
+}
+
+func (p *capturingProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	if p.err != nil {
+		return 0, 0, p.err
+	}
+	p.messages = append(p.messages, msg)
+	return 0, int64(len(p.messages)), nil
+}
+
+func (p *capturingProducer) Close() error {
+	return nil
+}
+
+// newTestConsumerGroup returns a consumer group that delivers the supplied messages
+// to the handler, then waits to be closed
+func newTestConsumerGroup(messages []*sarama.ConsumerMessage, applied chan struct{}) *saramamocks.ConsumerGroup {
+	mcg := &saramamocks.ConsumerGroup{}
+	ms := &saramamocks.ConsumerGroupSession{}
+	mcgc := &saramamocks.ConsumerGroupClaim{}
+	errs := make(chan error)
+	ms.On("MarkMessage", mock.Anything, "").Return()
+	mcg.On("Errors").Return((<-chan error)(errs))
+	mcg.On("Close").Run(func(args mock.Arguments) { close(errs) }).Return(nil)
+	mcg.On("Consume", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		ctx := args[0].(context.Context)
+		handler := args[2].(sarama.ConsumerGroupHandler)
+		ch := make(chan *sarama.ConsumerMessage, len(messages))
+		for _, msg := range messages {
+			ch <- msg
+		}
+		close(ch)
+		mcgc.On("Messages").Return((<-chan *sarama.ConsumerMessage)(ch))
+		_ = handler.Setup(ms)
+		_ = handler.ConsumeClaim(ms, mcgc)
+		_ = handler.Cleanup(ms)
+		if applied != nil {
+			close(applied)
+			applied = nil
+		}
+		<-ctx.Done()
+	}).Return(nil)
+	return mcg
+}
+
+func newTestReplicatedStore(t *testing.T, dir, instanceID string, producer *capturingProducer, mcg *saramamocks.ConsumerGroup) ContractStore {
+	newReplicationProducer = func(addrs []string, config *sarama.Config) (sarama.SyncProducer, error) {
+		return producer, nil
+	}
+	newReplicationConsumerGroup = func(addrs []string, groupID string, config *sarama.Config) (sarama.ConsumerGroup, error) {
+		assert.Equal(t, "ethconnect-registry-"+instanceID, groupID)
+		assert.Equal(t, sarama.OffsetOldest, config.Consumer.Offsets.Initial)
+		return mcg, nil
+	}
+	cs := NewContractStore(&ContractStoreConf{
+		StoragePath: dir,
+		Replication: &ReplicationConf{
+			InstanceID:      instanceID,
+			KafkaCommonConf: kafka.KafkaCommonConf{Brokers: []string{"localhost:9092"}},
+			Topic:           "registry",
+		},
+	}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(t, err)
+	return cs
+}
+
+func TestReplicationBetweenInstances(t *testing.T) {
+	assert := assert.New(t)
+	defer func() {
+		newReplicationProducer = sarama.NewSyncProducer
+		newReplicationConsumerGroup = sarama.NewConsumerGroup
+	}()
+
+	// Changes made on the first instance are published
+	dirA := tempdir()
+	defer cleanup(dirA)
+	producerA := &capturingProducer{}
+	csA := newTestReplicatedStore(t, dirA, "a", producerA, newTestConsumerGroup(nil, nil))
+	_, err := csA.AddABI("abi1", &messages.DeployContract{
+		ContractName: "token",
+		ABI: ethbinding.ABIMarshaling{
+			{Type: "function", Name: "transfer", Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "to", Type: "address"}}},
+		},
+	}, time.Now())
+	assert.NoError(err)
+	_, err = csA.AddContract("0123456789abcdef0123456789abcdef01234567", "abi1", "token", "token", nil)
+	assert.NoError(err)
+	_, err = csA.AddContract("123456789abcdef0123456789abcdef012345678", "abi1", "other", "other", nil)
+	assert.NoError(err)
+	err = csA.DeleteContract("123456789abcdef0123456789abcdef012345678")
+	assert.NoError(err)
+	csA.Close()
+
+	var consumed []*sarama.ConsumerMessage
+	var ops []string
+	for i, msg := range producerA.messages {
+		assert.Equal("registry", msg.Topic)
+		b, _ := msg.Value.Encode()
+		var m registryMutation
+		_ = json.Unmarshal(b, &m)
+		assert.Equal("a", m.Origin)
+		ops = append(ops, m.Op)
+		consumed = append(consumed, &sarama.ConsumerMessage{Topic: "registry", Offset: int64(i), Value: b})
+	}
+	assert.Equal([]string{
+		mutationPutABI,
+		mutationPutName, mutationPutContract,
+		mutationPutName, mutationPutContract,
+		mutationDeleteName, mutationDeleteContract,
+	}, ops)
+	key, _ := producerA.messages[1].Key.Encode()
+	assert.Equal("name/token", string(key))
+
+	// Changes published by the second instance itself, and invalid messages, are skipped
+	ownChange, _ := json.Marshal(&registryMutation{Origin: "b", Op: mutationDeleteABI, ID: "abi1"})
+	consumed = append(consumed,
+		&sarama.ConsumerMessage{Value: ownChange},
+		&sarama.ConsumerMessage{Value: []byte("!json")},
+	)
+
+	// The second instance applies them, and serves them from its listings
+	dirB := tempdir()
+	defer cleanup(dirB)
+	applied := make(chan struct{})
+	csB := newTestReplicatedStore(t, dirB, "b", &capturingProducer{}, newTestConsumerGroup(consumed, applied))
+	defer csB.Close()
+	<-applied
+
+	abis, err := csB.ListABIs(nil)
+	assert.NoError(err)
+	assert.Len(abis, 1)
+	deployMsg, err := csB.GetABI(ABILocation{ABIType: LocalABI, Name: "abi1"}, false)
+	assert.NoError(err)
+	assert.Equal("token", deployMsg.Contract.ContractName)
+	addr, err := csB.ResolveContractAddress("token")
+	assert.NoError(err)
+	assert.Equal("0123456789abcdef0123456789abcdef01234567", addr)
+	contracts, err := csB.ListContracts(nil)
+	assert.NoError(err)
+	assert.Len(contracts, 1)
+	err = csB.CheckNameAvailable("other")
+	assert.NoError(err)
+	found, err := csB.ListABIs(&ListingFilter{Method: "0x1a695230"})
+	assert.NoError(err)
+	assert.Len(found, 1)
+}
+
+func TestReplicationApplyDeleteABI(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+	defer cs.Close()
+	_, err = cs.AddABI("abi1", &messages.DeployContract{ContractName: "token"}, time.Now())
+	assert.NoError(err)
+
+	p := newReplicatingPersistence(&ReplicationConf{InstanceID: "a"}, cs.(*contractStore).persistence, cs.(*contractStore).applyReplicated)
+	m := &registryMutation{Origin: "b", Op: mutationDeleteABI, ID: "abi1"}
+	err = p.apply(m)
+	assert.NoError(err)
+	p.onApplied(m)
+	abis, err := cs.ListABIs(nil)
+	assert.NoError(err)
+	assert.Len(abis, 0)
+
+	err = p.apply(&registryMutation{Op: "unknown"})
+	assert.NoError(err)
+}
+
+func TestReplicationPublishFailureDoesNotFailWrite(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir()
+	defer cleanup(dir)
+	defer func() {
+		newReplicationProducer = sarama.NewSyncProducer
+		newReplicationConsumerGroup = sarama.NewConsumerGroup
+	}()
+
+	producer := &capturingProducer{err: fmt.Errorf("pop")}
+	cs := newTestReplicatedStore(t, dir, "a", producer, newTestConsumerGroup(nil, nil))
+	defer cs.Close()
+	_, err := cs.AddABI("abi1", &messages.DeployContract{ContractName: "token"}, time.Now())
+	assert.NoError(err)
+	abis, err := cs.ListABIs(nil)
+	assert.NoError(err)
+	assert.Len(abis, 1)
+}
+
+func TestReplicationBadConfig(t *testing.T) {
+	assert := assert.New(t)
+	defer func() {
+		newReplicationProducer = sarama.NewSyncProducer
+		newReplicationConsumerGroup = sarama.NewConsumerGroup
+	}()
+
+	for _, test := range []struct {
+		conf     ReplicationConf
+		expected string
+	}{
+		{ReplicationConf{}, "FFEC100369.*instanceID"},
+		{ReplicationConf{InstanceID: "a"}, "FFEC100369.*brokers"},
+		{ReplicationConf{InstanceID: "a", KafkaCommonConf: kafka.KafkaCommonConf{Brokers: []string{"localhost:9092"}}}, "FFEC100369.*topic"},
+		{ReplicationConf{InstanceID: "a", KafkaCommonConf: kafka.KafkaCommonConf{Brokers: []string{"localhost:9092"}, TLS: utils.TLSConfig{Enabled: true, CACertsFile: "/non/existent"}}, Topic: "registry"}, ".+"},
+	} {
+		dir := tempdir()
+		conf := test.conf
+		cs := NewContractStore(&ContractStoreConf{StoragePath: dir, Replication: &conf}, &mockRR{})
+		err := cs.Init()
+		assert.Regexp(test.expected, err)
+		cleanup(dir)
+	}
+
+	newReplicationProducer = func(addrs []string, config *sarama.Config) (sarama.SyncProducer, error) {
+		return nil, fmt.Errorf("pop")
+	}
+	dir := tempdir()
+	defer cleanup(dir)
+	conf := &ReplicationConf{InstanceID: "a", KafkaCommonConf: kafka.KafkaCommonConf{Brokers: []string{"localhost:9092"}}, Topic: "registry"}
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir, Replication: conf}, &mockRR{})
+	err := cs.Init()
+	assert.Regexp("pop", err)
+	cs.Close()
+
+	newReplicationProducer = func(addrs []string, config *sarama.Config) (sarama.SyncProducer, error) {
+		return &capturingProducer{}, nil
+	}
+	newReplicationConsumerGroup = func(addrs []string, groupID string, config *sarama.Config) (sarama.ConsumerGroup, error) {
+		return nil, fmt.Errorf("pop")
+	}
+	dir2 := tempdir()
+	defer cleanup(dir2)
+	cs = NewContractStore(&ContractStoreConf{StoragePath: dir2, Replication: conf}, &mockRR{})
+	err = cs.Init()
+	assert.Regexp("pop", err)
+	cs.Close()
+}
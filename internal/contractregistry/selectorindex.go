@@ -0,0 +1,278 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	ethconnecterrors "github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	methodSelectorRegexp = regexp.MustCompile(`^0x[0-9a-f]{8}$`)
+	eventTopicRegexp     = regexp.MustCompile(`^0x[0-9a-f]{64}$`)
+)
+
+// MethodSelector returns the 4-byte selector of a method signature such as "transfer(address,uint256)",
+// as 0x prefixed hex. A selector supplied in place of the signature is returned as-is.
+func MethodSelector(sig string) (string, error) {
+	if s := strings.ToLower(sig); methodSelectorRegexp.MatchString(s) {
+		return s, nil
+	}
+	element, err := parseSignature("function", sig)
+	if err != nil {
+		return "", err
+	}
+	method, err := ethbind.API.ABIElementMarshalingToABIMethod(element)
+	if err != nil {
+		return "", ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayInvalidSignature, "method", sig)
+	}
+	return "0x" + hex.EncodeToString(method.ID), nil
+}
+
+// EventTopic returns the topic0 hash of an event signature such as "Transfer(address,address,uint256)",
+// as 0x prefixed hex. A topic hash supplied in place of the signature is returned as-is.
+func EventTopic(sig string) (string, error) {
+	if s := strings.ToLower(sig); eventTopicRegexp.MatchString(s) {
+		return s, nil
+	}
+	element, err := parseSignature("event", sig)
+	if err != nil {
+		return "", err
+	}
+	event, err := ethbind.API.ABIElementMarshalingToABIEvent(element)
+	if err != nil {
+		return "", ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayInvalidSignature, "event", sig)
+	}
+	return event.ID.Hex(), nil
+}
+
+// parseSignature builds an ABI element from a signature, so the selector is calculated
+// by the same code as for a method or event in an uploaded ABI
+func parseSignature(elementType, sig string) (*ethbinding.ABIElementMarshaling, error) {
+	trimmed := strings.Join(strings.Fields(sig), "")
+	open := strings.Index(trimmed, "(")
+	if open <= 0 || !strings.HasSuffix(trimmed, ")") {
+		return nil, ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayInvalidSignature, elementType, sig)
+	}
+	inputs, ok := parseSignatureTypes(trimmed[open+1 : len(trimmed)-1])
+	if !ok {
+		return nil, ethconnecterrors.Errorf(ethconnecterrors.RESTGatewayInvalidSignature, elementType, sig)
+	}
+	return &ethbinding.ABIElementMarshaling{
+		Type:   elementType,
+		Name:   trimmed[:open],
+		Inputs: inputs,
+	}, nil
+}
+
+// parseSignatureTypes parses a comma separated list of types, where tuples are in parentheses
+func parseSignatureTypes(list string) ([]ethbinding.ABIArgumentMarshaling, bool) {
+	args := []ethbinding.ABIArgumentMarshaling{}
+	if list == "" {
+		return args, true
+	}
+	depth, start := 0, 0
+	for i := 0; i <= len(list); i++ {
+		if i < len(list) {
+			switch list[i] {
+			case '(':
+				depth++
+				continue
+			case ')':
+				depth--
+				if depth < 0 {
+					return nil, false
+				}
+				continue
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		if depth != 0 {
+			return nil, false
+		}
+		arg, ok := parseSignatureType(list[start:i])
+		if !ok {
+			return nil, false
+		}
+		args = append(args, arg)
+		start = i + 1
+	}
+	return args, true
+}
+
+func parseSignatureType(t string) (ethbinding.ABIArgumentMarshaling, bool) {
+	if t == "" {
+		return ethbinding.ABIArgumentMarshaling{}, false
+	}
+	if !strings.HasPrefix(t, "(") {
+		return ethbinding.ABIArgumentMarshaling{Type: t}, true
+	}
+	end := strings.LastIndex(t, ")")
+	components, ok := parseSignatureTypes(t[1:end])
+	if !ok {
+		return ethbinding.ABIArgumentMarshaling{}, false
+	}
+	for i := range components {
+		// Tuple components must be named, but the names do not contribute to the selector
+		components[i].Name = "c" + strconv.Itoa(i)
+	}
+	return ethbinding.ABIArgumentMarshaling{Type: "tuple" + t[end+1:], Components: components}, true
+}
+
+// selectorIndex maps the method selectors and event topics of the stored ABIs to the IDs
+// of the ABIs that contain them. It is built from the DB on the first search, then kept
+// up to date as ABIs are added and deleted.
+type selectorIndex struct {
+	mux   sync.RWMutex
+	load  func() (map[string]ethbinding.ABIMarshaling, error)
+	byABI map[string][]string
+	byKey map[string]map[string]bool
+}
+
+func newSelectorIndex(load func() (map[string]ethbinding.ABIMarshaling, error)) *selectorIndex {
+	return &selectorIndex{load: load}
+}
+
+// abiSelectors returns the method selectors and event topics of an ABI
+func abiSelectors(abiID string, abi ethbinding.ABIMarshaling) []string {
+	runtimeABI, err := ethbind.API.ABIMarshalingToABIRuntime(abi)
+	if err != nil {
+		log.Warnf("Unable to index selectors of ABI '%s': %s", abiID, err)
+		return nil
+	}
+	keys := make([]string, 0, len(runtimeABI.Methods)+len(runtimeABI.Events))
+	for _, method := range runtimeABI.Methods {
+		keys = append(keys, "0x"+hex.EncodeToString(method.ID))
+	}
+	for _, event := range runtimeABI.Events {
+		keys = append(keys, event.ID.Hex())
+	}
+	return keys
+}
+
+// ensureLoaded must be called with the write lock held
+func (si *selectorIndex) ensureLoaded() error {
+	if si.byABI != nil {
+		return nil
+	}
+	abis, err := si.load()
+	if err != nil {
+		return err
+	}
+	si.byABI = make(map[string][]string, len(abis))
+	si.byKey = make(map[string]map[string]bool)
+	for abiID, abi := range abis {
+		si.addLocked(abiID, abi)
+	}
+	return nil
+}
+
+func (si *selectorIndex) addLocked(abiID string, abi ethbinding.ABIMarshaling) {
+	si.removeLocked(abiID)
+	keys := abiSelectors(abiID, abi)
+	si.byABI[abiID] = keys
+	for _, key := range keys {
+		if si.byKey[key] == nil {
+			si.byKey[key] = make(map[string]bool)
+		}
+		si.byKey[key][abiID] = true
+	}
+}
+
+func (si *selectorIndex) removeLocked(abiID string) {
+	for _, key := range si.byABI[abiID] {
+		delete(si.byKey[key], abiID)
+		if len(si.byKey[key]) == 0 {
+			delete(si.byKey, key)
+		}
+	}
+	delete(si.byABI, abiID)
+}
+
+// add indexes a new or updated ABI. If the index has not been built yet there is
+// nothing to do, as the ABI will be read from the DB on first use.
+func (si *selectorIndex) add(abiID string, abi ethbinding.ABIMarshaling) {
+	si.mux.Lock()
+	defer si.mux.Unlock()
+	if si.byABI != nil {
+		si.addLocked(abiID, abi)
+	}
+}
+
+func (si *selectorIndex) remove(abiID string) {
+	si.mux.Lock()
+	defer si.mux.Unlock()
+	si.removeLocked(abiID)
+}
+
+// reset discards the index, so it is rebuilt from the DB on the next search
+func (si *selectorIndex) reset() {
+	si.mux.Lock()
+	defer si.mux.Unlock()
+	si.byABI = nil
+	si.byKey = nil
+}
+
+// rlockLoaded takes the read lock, building the index first if it has not been built
+func (si *selectorIndex) rlockLoaded() error {
+	si.mux.RLock()
+	if si.byABI != nil {
+		return nil
+	}
+	si.mux.RUnlock()
+	si.mux.Lock()
+	err := si.ensureLoaded()
+	si.mux.Unlock()
+	if err != nil {
+		return err
+	}
+	si.mux.RLock()
+	return nil
+}
+
+// lookup returns the IDs of the ABIs containing all of the supplied selectors and topics
+func (si *selectorIndex) lookup(keys ...string) (map[string]bool, error) {
+	if err := si.rlockLoaded(); err != nil {
+		return nil, err
+	}
+	defer si.mux.RUnlock()
+	var abiIDs map[string]bool
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		matched := make(map[string]bool)
+		for abiID := range si.byKey[key] {
+			if abiIDs == nil || abiIDs[abiID] {
+				matched[abiID] = true
+			}
+		}
+		abiIDs = matched
+	}
+	return abiIDs, nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractregistry
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	transferSelector = "0xa9059cbb"
+	transferTopic    = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+)
+
+func erc20ABI() ethbinding.ABIMarshaling {
+	return ethbinding.ABIMarshaling{
+		{
+			Type: "function", Name: "transfer",
+			Inputs: []ethbinding.ABIArgumentMarshaling{
+				{Name: "to", Type: "address"},
+				{Name: "value", Type: "uint256"},
+			},
+			Outputs: []ethbinding.ABIArgumentMarshaling{{Name: "", Type: "bool"}},
+		},
+		{
+			Type: "event", Name: "Transfer",
+			Inputs: []ethbinding.ABIArgumentMarshaling{
+				{Name: "from", Type: "address", Indexed: true},
+				{Name: "to", Type: "address", Indexed: true},
+				{Name: "value", Type: "uint256"},
+			},
+		},
+	}
+}
+
+func TestMethodSelector(t *testing.T) {
+	assert := assert.New(t)
+
+	selector, err := MethodSelector("transfer(address, uint256)")
+	assert.NoError(err)
+	assert.Equal(transferSelector, selector)
+
+	selector, err = MethodSelector("0xA9059CBB")
+	assert.NoError(err)
+	assert.Equal(transferSelector, selector)
+
+	selector, err = MethodSelector("set()")
+	assert.NoError(err)
+	assert.Equal("0xb8e010de", selector)
+
+	// fill((address,uint256,bytes)[],uint256) from the 0x exchange
+	_, err = MethodSelector("fill((address,uint256,bytes)[],uint256)")
+	assert.NoError(err)
+
+	for _, bad := range []string{"transfer", "(address)", "transfer(address", "transfer(address,)", "transfer(badtype)", "f((address)", "f(address))"} {
+		_, err = MethodSelector(bad)
+		assert.Regexp("FFEC100349", err, bad)
+	}
+}
+
+func TestEventTopic(t *testing.T) {
+	assert := assert.New(t)
+
+	topic, err := EventTopic("Transfer(address,address,uint256)")
+	assert.NoError(err)
+	assert.Equal(transferTopic, topic)
+
+	topic, err = EventTopic(transferTopic)
+	assert.NoError(err)
+	assert.Equal(transferTopic, topic)
+
+	_, err = EventTopic("Transfer(address,address,badtype)")
+	assert.Regexp("FFEC100349", err)
+	_, err = EventTopic("Transfer")
+	assert.Regexp("FFEC100349", err)
+}
+
+func TestSelectorIndexLookup(t *testing.T) {
+	assert := assert.New(t)
+
+	si := newSelectorIndex(func() (map[string]ethbinding.ABIMarshaling, error) {
+		return map[string]ethbinding.ABIMarshaling{
+			"abi1": erc20ABI(),
+			"abi2": erc20ABI()[:1],
+			"abi3": {{Type: "function", Name: "bad", Inputs: []ethbinding.ABIArgumentMarshaling{{Type: "badness"}}}},
+		}, nil
+	})
+	// Changes before the index is loaded are picked up from the DB on load
+	si.add("abi4", erc20ABI())
+
+	abiIDs, err := si.lookup(transferSelector)
+	assert.NoError(err)
+	assert.Equal(map[string]bool{"abi1": true, "abi2": true}, abiIDs)
+	abiIDs, _ = si.lookup(transferSelector, transferTopic)
+	assert.Equal(map[string]bool{"abi1": true}, abiIDs)
+
+	si.add("abi2", erc20ABI())
+	abiIDs, _ = si.lookup("", transferTopic)
+	assert.Equal(map[string]bool{"abi1": true, "abi2": true}, abiIDs)
+
+	si.remove("abi1")
+	si.remove("abi2")
+	abiIDs, _ = si.lookup(transferTopic)
+	assert.Empty(abiIDs)
+	assert.Empty(si.byKey)
+}
+
+func TestSelectorIndexLoadFail(t *testing.T) {
+	si := newSelectorIndex(func() (map[string]ethbinding.ABIMarshaling, error) {
+		return nil, fmt.Errorf("pop")
+	})
+	_, err := si.lookup(transferSelector)
+	assert.Regexp(t, "pop", err)
+}
+
+func TestListContractsBySelector(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := tempdir()
+	defer cleanup(dir)
+	cs := NewContractStore(&ContractStoreConf{StoragePath: dir}, &mockRR{})
+	err := cs.Init()
+	assert.NoError(err)
+
+	_, err = cs.AddABI("abi1", &messages.DeployContract{ContractName: "erc20", ABI: erc20ABI()}, time.Now())
+	assert.NoError(err)
+	_, err = cs.AddABI("abi2", &messages.DeployContract{ContractName: "other", ABI: erc20ABI()[1:]}, time.Now())
+	assert.NoError(err)
+	_, err = cs.AddContract("aaaa", "abi1", "aaaa", "", nil)
+	assert.NoError(err)
+	_, err = cs.AddContract("bbbb", "abi2", "bbbb", "", nil)
+	assert.NoError(err)
+
+	contracts, err := cs.ListContracts(&ListingFilter{Method: transferSelector})
+	assert.NoError(err)
+	assert.Len(contracts, 1)
+	assert.Equal("aaaa", contracts[0].GetID())
+	contracts, err = cs.ListContracts(&ListingFilter{Event: transferTopic})
+	assert.NoError(err)
+	assert.Len(contracts, 2)
+
+	// ABIs added after the index is built are searchable
+	_, err = cs.AddABI("abi3", &messages.DeployContract{ContractName: "erc20-v2", ABI: erc20ABI()}, time.Now())
+	assert.NoError(err)
+	abis, err := cs.ListABIs(&ListingFilter{Method: transferSelector})
+	assert.NoError(err)
+	assert.Len(abis, 2)
+	abis, err = cs.ListABIs(&ListingFilter{Method: transferSelector, Limit: 1})
+	assert.NoError(err)
+	assert.Len(abis, 1)
+
+	cs.(*contractStore).selectors = newSelectorIndex(func() (map[string]ethbinding.ABIMarshaling, error) {
+		return nil, fmt.Errorf("pop")
+	})
+	_, err = cs.ListContracts(&ListingFilter{Method: transferSelector})
+	assert.Regexp("pop", err)
+	_, err = cs.ListABIs(&ListingFilter{Event: transferTopic})
+	assert.Regexp("pop", err)
+}
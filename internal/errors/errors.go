@@ -163,8 +163,8 @@ var (
 
 	// KVStoreDBLoad failed to init DB
 	KVStoreDBLoad = e(100054, "Failed to open DB at %s: %s")
-	// KVStoreMemFilteringUnsupported memory db is really just for testing. No filtering support
-	KVStoreMemFilteringUnsupported = e(100055, "Memory receipts do not support filtering")
+	// KVStoreMemFilteringUnsupported memory db is really just for testing. Only ID and since filtering are supported
+	KVStoreMemFilteringUnsupported = e(100055, "Memory receipts do not support filtering by from/to address")
 
 	// HDWalletSigningFailed problem returned from remote HDWallet API
 	HDWalletSigningFailed = e(100056, "HDWallet signing failed")
@@ -530,6 +530,526 @@ var (
 	CompilerFailedVersion = e(100225, "Failed to invoke solc binary '%s' to check version: %s")
 	// CompilerFailedVersionRegex failed to extract version from output
 	CompilerFailedVersionRegex = e(100226, "Failed to extract version from solc '%s' output: %s")
+	// EventStreamsExportBadFormat unknown output format requested for an export
+	EventStreamsExportBadFormat = e(100227, "Invalid export format '%s'. Valid formats are: 'csv', 'json' and 'parquet'")
+	// EventStreamsExportBadBlockRange block range for an export could not be parsed, or is reversed
+	EventStreamsExportBadBlockRange = e(100228, "Invalid block range for export: fromBlock='%s' toBlock='%s'")
+	// EventStreamsExportWriteFailed failed writing to the output of an export
+	EventStreamsExportWriteFailed = e(100229, "Failed to write export output: %s")
+	// AccessLogFileOpen failed to open the file configured for the HTTP access log
+	AccessLogFileOpen = e(100230, "Failed to open access log file '%s': %s")
+	// AccessLogHijackUnsupported the underlying response writer does not support connection hijacking
+	AccessLogHijackUnsupported = e(100231, "Response writer does not support hijacking the connection")
+	// WebSocketTooManyConnections the maximum number of concurrent WebSocket connections has been reached
+	WebSocketTooManyConnections = e(100232, "Maximum of %d WebSocket connections reached")
+	// WebSocketRateLimitExceeded a client sent messages faster than the configured rate limit
+	WebSocketRateLimitExceeded = e(100233, "Message rate limit of %.2f/s exceeded")
+	// ConfigUnknownChainProfile the chain profile for receipt extensions is not recognized
+	ConfigUnknownChainProfile = e(100234, "Unknown chain profile '%s'. Supported profiles: generic, optimism, arbitrum")
+	// RESTGatewayLegacyRoutesDisabled the unversioned routes have been disabled in config
+	RESTGatewayLegacyRoutesDisabled = e(100235, "Unversioned routes are disabled. Use the %s prefix")
+	// GenAPIInvalidABI the input file for OpenAPI generation did not contain a valid ABI
+	GenAPIInvalidABI = e(100236, "No valid ABI found in '%s': %v")
+	// GenAPIInvalidBaseURL the base URL for OpenAPI generation could not be parsed
+	GenAPIInvalidBaseURL = e(100237, "Invalid base URL '%s': %s")
+	// GenAPIWriteFailed failed to write the generated OpenAPI definition
+	GenAPIWriteFailed = e(100238, "Failed to write output '%s': %s")
+	// ReceiptStoreElasticsearchSetup failed to configure the Elasticsearch indices
+	ReceiptStoreElasticsearchSetup = e(100239, "Unable to set up Elasticsearch receipt index: %s")
+	// ReceiptStoreElasticsearchRequest a request to Elasticsearch failed to complete
+	ReceiptStoreElasticsearchRequest = e(100240, "Elasticsearch request %s %s failed: %s")
+	// ReceiptStoreElasticsearchStatus Elasticsearch returned a failure status
+	ReceiptStoreElasticsearchStatus = e(100241, "Elasticsearch returned [%d]: %s")
+	// ReceiptStoreElasticsearchResponse the response from Elasticsearch could not be parsed
+	ReceiptStoreElasticsearchResponse = e(100242, "Unable to parse Elasticsearch response: %s")
+	// ReceiptStoreElasticsearchSerialize the receipt could not be serialized for indexing
+	ReceiptStoreElasticsearchSerialize = e(100243, "Unable to serialize receipt: %s")
+	// ReceiptStoreElasticsearchBulkMismatch the bulk response did not contain a result for every receipt
+	ReceiptStoreElasticsearchBulkMismatch = e(100244, "Elasticsearch returned %d bulk results for %d receipts")
+	// ReceiptStoreElasticsearchClosed the receipt store has been shut down
+	ReceiptStoreElasticsearchClosed = e(100245, "Elasticsearch receipt store is closed")
+	// ReceiptStoreSearchNotSupported full-text/contract search requires a store with rich query support
+	ReceiptStoreSearchNotSupported = e(100246, "The configured receipt store does not support the 'q' or 'contractAddress' query parameters")
+	// EventStreamsSubscribeBadTime the starting time for a subscription request is invalid
+	EventStreamsSubscribeBadTime = e(100247, "FromTime '%s' cannot be parsed as an RFC3339 timestamp")
+	// EventStreamsSubscribeBlockAndTime both a starting block and time were supplied
+	EventStreamsSubscribeBlockAndTime = e(100248, "Only one of fromBlock and fromTime can be specified")
+	// ReceiptStoreSQLiteDriverMissing the binary was built without a SQLite driver
+	ReceiptStoreSQLiteDriverMissing = e(100249, "SQLite receipt store is not available in this build (requires cgo)")
+	// ReceiptStoreSQLiteOpen failed to open or initialize the SQLite database
+	ReceiptStoreSQLiteOpen = e(100250, "Unable to open SQLite receipt store at %s: %s")
+	// ReceiptExporterUnknownType the exporter type is not one we support
+	ReceiptExporterUnknownType = e(100251, "Unknown type '%s' for receipt exporter '%s'")
+	// ReceiptExporterMissingConfig a required setting for the exporter is missing
+	ReceiptExporterMissingConfig = e(100252, "Receipt exporter '%s' requires '%s' to be configured")
+	// ReceiptExporterHTTPStatus the HTTP endpoint rejected the batch
+	ReceiptExporterHTTPStatus = e(100253, "Receipt exporter '%s' received status %d from %s")
+	// ReceiptExporterWriteFile failed to write a batch file
+	ReceiptExporterWriteFile = e(100254, "Receipt exporter '%s' failed to write batch file: %s")
+	// SecurityModuleNoApprovalSupport the security module does not implement the approval extension
+	SecurityModuleNoApprovalSupport = e(100255, "The configured security module does not support the approval workflow")
+	// ApprovalsConfigPathMissing the approval store path is required when approvals are enabled
+	ApprovalsConfigPathMissing = e(100256, "A path for the approval store must be configured when approvals are enabled")
+	// ApprovalsBadThreshold the value threshold could not be parsed
+	ApprovalsBadThreshold = e(100257, "Invalid approval value threshold '%s'")
+	// ApprovalsNotFound no submission is parked with the ID
+	ApprovalsNotFound = e(100258, "No submission pending approval with id '%s'")
+	// ApprovalsNotPending the submission has already been approved or rejected
+	ApprovalsNotPending = e(100259, "Submission '%s' has already been %s")
+	// ApprovalsSameApprover the submitter attempted to approve their own submission
+	ApprovalsSameApprover = e(100260, "Submission '%s' must be approved by a different principal to the submitter")
+	// ApprovalsStoreFailed failed to read or write the approval store
+	ApprovalsStoreFailed = e(100261, "Failed to update approval store: %s")
+	// ApprovalsRejected reply stored for a submission that was rejected
+	ApprovalsRejected = e(100262, "Submission rejected by '%s': %s")
+	// EventStreamsScheduleInvalid the cron schedule could not be parsed
+	EventStreamsScheduleInvalid = e(100263, "Invalid schedule '%s' - must be a five field cron expression, or '@every <duration>' of at least 1s")
+	// EventStreamsScheduledQueryNotFound the scheduled query does not exist
+	EventStreamsScheduledQueryNotFound = e(100264, "Scheduled query with ID '%s' not found")
+	// EventStreamsScheduledQueryMissingFields the method or address is missing
+	EventStreamsScheduledQueryMissingFields = e(100265, "A contract address and method must be supplied for a scheduled query")
+	// EventStreamsScheduledQueryStoreFailed failed to store a scheduled query
+	EventStreamsScheduledQueryStoreFailed = e(100266, "Failed to store scheduled query: %s")
+	// RESTGatewayScheduledQueryInvalid attempt to create a scheduled query with invalid parameters
+	RESTGatewayScheduledQueryInvalid = e(100267, "Invalid scheduled query specification: %s")
+	// WebSocketRepliesBadSince the since resume point for a reply stream could not be parsed
+	WebSocketRepliesBadSince = e(100268, "since '%s' cannot be parsed as RFC3339 or millisecond timestamp")
+	// WebSocketRepliesNoHistory there is no receipt store to resume a reply stream from
+	WebSocketRepliesNoHistory = e(100269, "Reply history is not available to resume from 'since'")
+	// WebSocketRepliesHistoryFailed failed to query the receipt store to resume a reply stream
+	WebSocketRepliesHistoryFailed = e(100270, "Failed to query reply history: %s")
+	// RPCCallTimeout an RPC call did not complete within the timeout for its class
+	RPCCallTimeout = e(100271, "%s timed out after %s (timeout class '%s')")
+	// RPCTimeoutClassUnknown an RPC method was configured with a timeout class that does not exist
+	RPCTimeoutClassUnknown = e(100272, "Unknown timeout class '%s' for RPC method '%s' - must be fast, medium or slow")
+	// RESTGatewayEventSchemaFormat requested a schema for an event in a format we do not generate
+	RESTGatewayEventSchemaFormat = e(100273, "Unsupported schema format '%s' - only 'json' is supported")
+	// ConfigMTLSCertKey mutual TLS was enabled on the listener without a server certificate and key
+	ConfigMTLSCertKey = e(100274, "Mutual TLS requires a server certificate file and key file")
+	// ConfigMTLSLoadFailed failed to load the certificates for mutual TLS on the listener
+	ConfigMTLSLoadFailed = e(100275, "Failed to load mutual TLS certificates: %s")
+	// ConfigMTLSClientCAs mutual TLS was enabled on the listener without CAs to verify clients against
+	ConfigMTLSClientCAs = e(100276, "Mutual TLS requires a file containing the CA certificates trusted to issue client certificates")
+	// RESTGatewayClientCertNotAllowed the client certificate was valid, but the subject is not in the allow-list
+	RESTGatewayClientCertNotAllowed = e(100277, "Client certificate subject '%s' is not permitted")
+	// HTTPRequesterTLSConfig common HTTP request utility for extensions, the TLS configuration could not be loaded
+	HTTPRequesterTLSConfig = e(100278, "Invalid TLS configuration for %s: %s")
+	// EventStreamsPubSubNoTopic attempt to create a Pub/Sub event stream without a topic
+	EventStreamsPubSubNoTopic = e(100279, "Must specify pubsub.topic for action type 'pubsub'")
+	// EventStreamsPubSubNoProject the project of the Pub/Sub topic could not be determined
+	EventStreamsPubSubNoProject = e(100280, "Must specify pubsub.projectId, or a topic of the form 'projects/{project}/topics/{topic}', when the credentials do not include a project")
+	// EventStreamsPubSubInvalidOrderingKey unknown ordering key selection for a Pub/Sub event stream
+	EventStreamsPubSubInvalidOrderingKey = e(100281, "Invalid pubsub.orderingKey '%s' - must be none, stream, subscription, address or transaction")
+	// EventStreamsPubSubFailedHTTPStatus Pub/Sub rejected a publish request
+	EventStreamsPubSubFailedHTTPStatus = e(100282, "%s: Pub/Sub publish failed with status=%d")
+	// GCPCredentialsInvalid the Google credentials file could not be loaded
+	GCPCredentialsInvalid = e(100283, "Invalid GCP credentials file '%s': %s")
+	// GCPCredentialsNoPrivateKey the service account credentials do not contain an RSA private key
+	GCPCredentialsNoPrivateKey = e(100284, "GCP service account credentials do not contain a PEM encoded RSA private key")
+	// GCPTokenFailed failed to obtain an access token for GCP
+	GCPTokenFailed = e(100285, "Failed to obtain GCP access token: %s")
+	// S3MissingBucket no bucket configured for S3
+	S3MissingBucket = e(100286, "S3 bucket must be configured")
+	// S3MissingCredentials no credentials configured or in the environment for S3
+	S3MissingCredentials = e(100287, "S3 credentials must be configured, or set in AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	// S3RequestFailed non-success status from S3
+	S3RequestFailed = e(100288, "S3 %s of '%s' failed with status %d: %s")
+	// ReceiptArchiveNotSupported the receipt store cannot list receipts oldest first
+	ReceiptArchiveNotSupported = e(100289, "The receipt store does not support archiving receipts")
+	// ReceiptArchiveNotEnabled request for an archived batch when archiving is disabled
+	ReceiptArchiveNotEnabled = e(100290, "Receipt archiving is not enabled")
+	// ReceiptArchiveInvalidBatch the batch ID is not in the format we write
+	ReceiptArchiveInvalidBatch = e(100291, "Invalid receipt archive batch '%s'")
+	// ReceiptArchiveBatchNotFound the batch does not exist in the archive
+	ReceiptArchiveBatchNotFound = e(100292, "Receipt archive batch '%s' not found")
+	// ReceiptArchiveReadFailed failed to read a batch from the archive
+	ReceiptArchiveReadFailed = e(100293, "Failed to read receipt archive batch '%s': %s")
+	// IdempotencyConfigPathMissing the reservation store path is required when idempotency keys are enabled
+	IdempotencyConfigPathMissing = e(100294, "A path for the reservation store must be configured when idempotency keys are enabled")
+	// IdempotencyStoreFailed the reservation store could not be read or written
+	IdempotencyStoreFailed = e(100295, "Reservation store failed: %s")
+	// IdempotencyKeyInUse the client supplied ID has already been used, or is reserved
+	IdempotencyKeyInUse = e(100296, "The id '%s' has already been used")
+	// IdempotencyReservationNotFound no unexpired reservation exists for the ID
+	IdempotencyReservationNotFound = e(100297, "No reservation found for id '%s'")
+	// IdempotencyInvalidID the client supplied ID contains characters that are not allowed
+	IdempotencyInvalidID = e(100298, "Invalid id '%s' - only alphanumeric characters and '-' are allowed")
+	// RawTxnInvalid the raw transaction could not be decoded
+	RawTxnInvalid = e(100299, "Invalid raw transaction: %s")
+	// RawTxnNotReplayProtected the raw transaction was signed without a chain ID, so could be replayed on any chain
+	RawTxnNotReplayProtected = e(100300, "Raw transaction is not replay protected. It must be signed with a chain ID (EIP-155)")
+	// RawTxnChainIDMismatch the raw transaction was signed for a different chain
+	RawTxnChainIDMismatch = e(100301, "Raw transaction is signed for chain ID %s, but the node is on chain ID %s")
+	// RawTxnInvalidSignature the signer could not be recovered from the raw transaction
+	RawTxnInvalidSignature = e(100302, "Unable to recover the signer of the raw transaction: %s")
+	// RawTxnSenderMismatch the raw transaction was signed by a different address to the one supplied
+	RawTxnSenderMismatch = e(100303, "Raw transaction is signed by %s, not by '%s'")
+	// RawTxnNonceUsed the nonce of the raw transaction has already been used, so it is a replay
+	RawTxnNonceUsed = e(100304, "Nonce %d has already been used by %s. The next nonce is %d")
+	// SecurityModuleNoReceiptAdminSupport the security module does not implement the receipt admin extension
+	SecurityModuleNoReceiptAdminSupport = e(100305, "The configured security module does not support deleting replies")
+	// ReceiptStoreFailedDelete the persistence layer failed to delete replies
+	ReceiptStoreFailedDelete = e(100306, "Error deleting replies: %s")
+	// ReceiptStorePurgeInvalidRequest a purge must be restricted by age or by ID
+	ReceiptStorePurgeInvalidRequest = e(100307, "A purge must specify 'olderThan' or a list of 'ids'")
+	// TransactionCancelNonceRequired a cancel transaction must target a specific nonce
+	TransactionCancelNonceRequired = e(100308, "A nonce must be supplied to cancel a transaction")
+	// TransactionSendCancelled the request was withdrawn while waiting to be sent
+	TransactionSendCancelled = e(100309, "Request cancelled before it was submitted to the node")
+	// TransactionCancelNotQueued no queued request with the ID
+	TransactionCancelNotQueued = e(100310, "Request '%s' is not queued for submission")
+	// TransactionCancelAlreadySubmitted the request has already been passed to the node
+	TransactionCancelAlreadySubmitted = e(100311, "Request '%s' has already been submitted to the node")
+	// WebhooksCancelNotSupported requests dispatched over Kafka are not held in this process
+	WebhooksCancelNotSupported = e(100312, "Requests can only be cancelled when transactions are sent directly to the node")
+	// ConfigUnknownKeys the config file contains keys that do not map to a setting
+	ConfigUnknownKeys = e(100313, "Unknown configuration keys in %s: %s")
+	// ConfigUnsupportedVersion the config file is for a newer (or invalid) schema version
+	ConfigUnsupportedVersion = e(100314, "Configuration version %d is not supported. Supported versions: 1-%d")
+	// ConfigEnvOverlayUnknownField an overlay environment variable does not map to a setting
+	ConfigEnvOverlayUnknownField = e(100315, "Environment variable %s does not match a configuration key at '%s'")
+	// ConfigEnvOverlayBadValue an overlay environment variable cannot be converted to the type of the setting
+	ConfigEnvOverlayBadValue = e(100316, "Environment variable %s has an invalid value: %s")
+	// ReceiptStoreSummaryNotSupported the persistence layer cannot count receipts by type
+	ReceiptStoreSummaryNotSupported = e(100317, "The configured receipt store does not support summaries")
+	// ReceiptStoreInvalidSummaryWindow a summary window is not a duration
+	ReceiptStoreInvalidSummaryWindow = e(100318, "Invalid summary window '%s'. Windows must be durations such as '15m' or '24h', or 'all'")
+	// ReceiptStoreFailedSummary the persistence layer failed to count receipts
+	ReceiptStoreFailedSummary = e(100319, "Error summarizing receipts: %s")
+	// RESTGatewaySimulateDeployUnsupported simulation was requested for a contract deployment
+	RESTGatewaySimulateDeployUnsupported = e(100320, "Simulation is not supported for contract deployment")
+	// ReceiptStoreNamespaceNotSupported the persistence layer cannot filter receipts by namespace
+	ReceiptStoreNamespaceNotSupported = e(100321, "The configured receipt store does not support namespaces")
+	// ReceiptStoreNamespaceRestricted the operation spans all namespaces
+	ReceiptStoreNamespaceRestricted = e(100322, "This operation spans all namespaces, and is not available to callers restricted to namespace '%s'")
+	// WebSocketSendBufferFull a slow client did not keep up with the messages queued for it
+	WebSocketSendBufferFull = e(100323, "Send buffer limit of %d messages reached for %s")
+	// ReceiptStoreSSEUnsupported the HTTP connection cannot stream Server-Sent Events
+	ReceiptStoreSSEUnsupported = e(100324, "Streaming responses are not supported on this connection")
+	// ReceiptStoreEncryptionKey the key encryption key for receipts could not be loaded
+	ReceiptStoreEncryptionKey = e(100325, "Invalid receipt encryption key: %s")
+	// ReceiptStoreEncryptFailed a receipt could not be encrypted before it was written
+	ReceiptStoreEncryptFailed = e(100326, "Failed to encrypt receipt '%s': %s")
+	// ReceiptStoreDecryptFailed a stored receipt could not be decrypted
+	ReceiptStoreDecryptFailed = e(100327, "Failed to decrypt receipt '%s': %s")
+	// ReceiptStoreEncryptionUnsupported the store beneath the encryption layer does not support an optional operation
+	ReceiptStoreEncryptionUnsupported = e(100328, "The receipt store does not support %s")
+	// ReceiptStoreVaultRequest a request to the Vault transit engine failed
+	ReceiptStoreVaultRequest = e(100329, "Vault transit %s request failed: %s")
+	// ReceiptStoreInvalidSummaryGroup the groupBy parameter of a summary query is not a field that can be grouped
+	ReceiptStoreInvalidSummaryGroup = e(100330, "Invalid summary groupBy '%s'. Must be one of: %s")
+	// ReceiptStoreGroupSummaryNotSupported the persistence layer cannot group receipts in a summary
+	ReceiptStoreGroupSummaryNotSupported = e(100331, "The configured receipt store does not support grouped summaries")
+	// RESTGatewayUnknownFields the body of a method invocation contained fields that are not inputs of the method
+	RESTGatewayUnknownFields = e(100332, "Unknown field(s) in body for method '%s': %s")
+	// RESTGatewayLocalStoreABIInUse an ABI cannot be deleted while a contract instance is registered with it
+	RESTGatewayLocalStoreABIInUse = e(100333, "ABI %s is in use by contract instance %s")
+	// LintUnsupportedType the request type cannot be checked by the lint endpoint
+	LintUnsupportedType = e(100334, "Requests of type '%s' cannot be linted. Must be one of: %s")
+	// LintMissingField a field required to submit the request is not set
+	LintMissingField = e(100335, "Missing required field '%s'")
+	// LintMethodNotFound the method of a transaction is not declared in the ABI registered for the contract
+	LintMethodNotFound = e(100336, "Method '%s' is not declared in the ABI registered for contract %s")
+	// LintMethodNotResolved there is no ABI for the method of a transaction, so parameters cannot be checked
+	LintMethodNotResolved = e(100337, "No ABI is available for method '%s', so parameters will be typed from their JSON values")
+	// LintGasLow the gas supplied is less than the minimum cost of any transaction
+	LintGasLow = e(100338, "Gas %d is below the intrinsic cost of a transaction (%d)")
+	// LintGasHigh the gas supplied is more than most networks allow in a block
+	LintGasHigh = e(100339, "Gas %d exceeds %d, which is above the block gas limit of most networks")
+	// LintValueNotPayable value is sent to a method or constructor that is not payable, so the transaction will revert
+	LintValueNotPayable = e(100340, "Value %s is sent to '%s', which is not payable")
+	// LintConstantMethod a view or pure method is sent as a transaction
+	LintConstantMethod = e(100341, "Method '%s' is %s, so sending it as a transaction does not change state - use a query instead")
+	// LintSolidityNotCompiled a deployment supplies Solidity source, which is not compiled by the lint endpoint
+	LintSolidityNotCompiled = e(100342, "Constructor parameters are not checked for contracts supplied as Solidity source")
+	// S3PreconditionFailed a conditional write to S3 failed, as another writer changed the object
+	S3PreconditionFailed = e(100343, "S3 object was modified by another writer")
+	// RESTGatewayContractUpdateInvalid the body of a request to update a contract instance registration is invalid
+	RESTGatewayContractUpdateInvalid = e(100344, "Invalid contract instance update: %s")
+	// RESTGatewayInvalidListingParam a paging or filtering parameter of a contract or ABI listing is invalid
+	RESTGatewayInvalidListingParam = e(100345, "Invalid '%s' query parameter: %s")
+	// EventStreamsSubscribeBadGetLogsConf the eth_getLogs retry configuration of a subscription is invalid
+	EventStreamsSubscribeBadGetLogsConf = e(100346, "Invalid getLogs configuration. Ranges and backoff times cannot be negative, maxBackoffMS cannot be less than initialBackoffMS, and backoffFactor must be at least 1")
+	// RemoteRegistryPublishFailed error publishing a local ABI as a gateway in the remote contract registry
+	RemoteRegistryPublishFailed = e(100347, "Failed to publish gateway to remote registry: %s")
+	// RESTGatewayPublishABIInvalid the body of a request to publish an ABI to the remote registry is invalid
+	RESTGatewayPublishABIInvalid = e(100348, "Invalid request to publish ABI: %s")
+	// RESTGatewayInvalidSignature a method or event to search for is neither a valid signature nor a selector
+	RESTGatewayInvalidSignature = e(100349, "Invalid %s signature '%s'. Supply a signature such as 'transfer(address,uint256)', or a hex selector")
+	// RESTGatewayImportABIInvalid the body of a request to import a verified ABI is invalid
+	RESTGatewayImportABIInvalid = e(100350, "Invalid request to import ABI: %s")
+	// ABIImportNoSource no service is configured to import verified contracts from, for the chain
+	ABIImportNoSource = e(100351, "No service is configured to import verified contracts on chain %s")
+	// ABIImportNotVerified the contract is not verified on any of the configured services
+	ABIImportNotVerified = e(100352, "Contract %s on chain %s is not verified on %s")
+	// ABIImportFailed the metadata of a verified contract could not be read
+	ABIImportFailed = e(100353, "Failed to import ABI from %s: %s")
+	// WebSocketInvalidRequest a message from a client using the JSON-RPC WebSocket protocol is not a valid request
+	WebSocketInvalidRequest = e(100354, "Invalid JSON-RPC request: %s")
+	// WebSocketUnknownMethod a client using the JSON-RPC WebSocket protocol called a method that does not exist
+	WebSocketUnknownMethod = e(100355, "Unknown method '%s'")
+	// RESTGatewayEventQueryInvalid the parameters of an ad hoc event query are invalid
+	RESTGatewayEventQueryInvalid = e(100356, "Invalid event query: %s")
+	// RESTGatewayEventQueryRangeTooLarge the block range of an ad hoc event query is too large
+	RESTGatewayEventQueryRangeTooLarge = e(100357, "Block range %d-%d exceeds the maximum of %d blocks for an event query")
+	// RESTGatewayEventQueryUnknownEvent the event of an ad hoc event query is not in the ABI of the contract
+	RESTGatewayEventQueryUnknownEvent = e(100358, "Event '%s' not found in ABI '%s'")
+	// CompilerSerializeUserDocs could not serialize the user docs output from solc
+	CompilerSerializeUserDocs = e(100359, "Serializing UserDoc: %s")
+	// TransactionRelayNotConfigured a meta-transaction was submitted without a forwarder configured
+	TransactionRelayNotConfigured = e(100360, "Meta-transaction relay is not configured. Set a forwarder address")
+	// TransactionRelayInvalid the forward request of a meta-transaction is incomplete
+	TransactionRelayInvalid = e(100361, "Invalid meta-transaction: %s")
+	// TransactionRelayVerifyFailed the forwarder rejected the signature or nonce of a meta-transaction
+	TransactionRelayVerifyFailed = e(100362, "The forwarder rejected the signature of the meta-transaction from %s with nonce %s")
+	// RESTGatewayABIVersionInvalid the name and version to register an ABI under could not be parsed
+	RESTGatewayABIVersionInvalid = e(100363, "Invalid ABI version '%s'. Register ABIs as name@version, such as erc20token@1.2.0")
+	// RESTGatewayABIVersionClash the version of a named ABI is already registered
+	RESTGatewayABIVersionClash = e(100364, "ABI %s is already registered as '%s@%s'")
+	// RegistryNotifierUnknownType the registry notifier type is not one we support
+	RegistryNotifierUnknownType = e(100365, "Unknown type '%s' for registry notifier '%s'")
+	// RegistryNotifierMissingConfig a required setting for the registry notifier is missing
+	RegistryNotifierMissingConfig = e(100366, "Registry notifier '%s' requires '%s' to be configured")
+	// RegistryNotifierHTTPStatus the webhook rejected a registry notification
+	RegistryNotifierHTTPStatus = e(100367, "Registry notifier '%s' received status %d from %s")
+	// RESTGatewayReadStoragePathFailed the storage path could not be scanned for ABI and contract files
+	RESTGatewayReadStoragePathFailed = e(100368, "Failed to read storage path %s: %s")
+	// RegistryReplicationMissingConfig a required setting for registry replication is missing
+	RegistryReplicationMissingConfig = e(100369, "Registry replication requires '%s' to be configured")
+	// ReceiptStoreRevisionConflict a receipt was changed by another writer since it was read
+	ReceiptStoreRevisionConflict = e(100370, "Receipt %s has been updated since revision %d was read")
+	// RESTGatewayBulkABINoArtifacts none of the files uploaded in bulk were contract artifacts
+	RESTGatewayBulkABINoArtifacts = e(100371, "No contract artifacts with an ABI were found in the uploaded files")
+	// RESTGatewayBulkABIInvalidArtifact a contract artifact uploaded in bulk could not be parsed
+	RESTGatewayBulkABIInvalidArtifact = e(100372, "Invalid contract artifact: %s")
+	// LoadTestInvalidConfig the options for the load test are incomplete or inconsistent
+	LoadTestInvalidConfig = e(100373, "Invalid load test options: %s")
+	// LoadTestGatewayNotReady the gateway did not respond before the load test started
+	LoadTestGatewayNotReady = e(100374, "Gateway at %s was not ready within %s: %s")
+	// LoadTestSetupFailed the event stream or subscription for an events load test could not be created
+	LoadTestSetupFailed = e(100375, "Failed to %s for the load test: %s")
+	// KafkaRetryBufferFull the buffer of messages waiting for Kafka to reconnect is full, and the policy is to park new messages
+	KafkaRetryBufferFull = e(100376, "Kafka is unavailable, and %d messages are already buffered to resend when it reconnects")
+	// ConfigKafkaRetryBufferPolicy the policy for a full Kafka retry buffer is not one we support
+	ConfigKafkaRetryBufferPolicy = e(100377, "Invalid Kafka retry buffer policy '%s'. Must be 'drop' or 'park'")
+	// RESTGatewayArtifactNotSelected more than one contract artifact was uploaded, and none was selected
+	RESTGatewayArtifactNotSelected = e(100378, "Found %d contract artifacts. Select one with the 'contract' parameter: %s")
+	// RESTGatewayArtifactNotFound the selected contract was not among the uploaded artifacts
+	RESTGatewayArtifactNotFound = e(100379, "Contract '%s' not found in the uploaded artifacts")
+	// ReplyModeInvalid the reply mode on a request is not recognized
+	ReplyModeInvalid = e(100380, "Invalid reply mode '%s'. Must be one of: kafka, webhook, both, none")
+	// ReplyModeWebhookRequired a reply mode that delivers to a webhook was requested without the URL
+	ReplyModeWebhookRequired = e(100381, "An http or https replyWebhook URL is required with reply mode '%s'")
+	// ReplyWebhookUnsafeAddress the reply webhook resolves to a private address, which is not allowed
+	ReplyWebhookUnsafeAddress = e(100382, "Reply webhook '%s' resolves to a private address, which is not allowed")
+	// ReplyWebhookHTTPStatus the reply webhook returned a non-OK response
+	ReplyWebhookHTTPStatus = e(100383, "Reply webhook '%s' returned status %d")
+	// RegistryNotifierNoWebSocketServer a WebSocket registry notifier is configured where there is no WebSocket server
+	RegistryNotifierNoWebSocketServer = e(100384, "Registry notifier '%s' requires a WebSocket server")
+	// MigrationStateInvalid the migration state file of a data directory could not be read
+	MigrationStateInvalid = e(100385, "Failed to read migration state '%s': %s")
+	// MigrationBackupFailed the data directory could not be backed up before migrating
+	MigrationBackupFailed = e(100386, "Failed to back up %s to '%s': %s")
+	// MigrationFailed a migration failed, and the data was restored from the backup
+	MigrationFailed = e(100387, "Migration %d (%s) of %s failed: %s")
+	// MigrationNoBackup a rollback was requested with no backup to restore
+	MigrationNoBackup = e(100388, "No backup of %s found in '%s' to roll back to")
+	// MigrationRestoreFailed a backup could not be restored
+	MigrationRestoreFailed = e(100389, "Failed to restore %s from backup '%s': %s")
+	// MigrationVersionAhead the data was migrated by a newer version of ethconnect
+	MigrationVersionAhead = e(100390, "The data of %s is at migration version %d, which is newer than the latest known version %d")
+	// RESTGatewayInvalidLabel a label of a contract or ABI is not a valid key=value pair
+	RESTGatewayInvalidLabel = e(100391, "Invalid label '%s'")
+	// WebSocketConnectionNotFound the WebSocket connection requested for its statistics is not connected
+	WebSocketConnectionNotFound = e(100392, "WebSocket connection '%s' not found")
+	// TransactionRequestAbandoned the caller went away, or the processor shut down, before the request completed
+	TransactionRequestAbandoned = e(100393, "Processing of the request was abandoned: %s")
+	// ContractStoreArchiveInvalid the archive supplied to import into the contract store cannot be used
+	ContractStoreArchiveInvalid = e(100394, "Invalid contract store archive: %s")
+	// ContractStoreImportConflict entries in the archive clash with those already in the contract store
+	ContractStoreImportConflict = e(100395, "The archive conflicts with %d existing entries in the contract store: %s")
+	// ContractStoreImportMissingABI a contract instance in the archive uses an ABI that is not available
+	ContractStoreImportMissingABI = e(100396, "Contract instance '%s' in the archive uses ABI '%s', which is not in the archive or the contract store")
+	// RESTGatewayBulkSubscribeInvalid the request to subscribe to the events of an ABI could not be parsed
+	RESTGatewayBulkSubscribeInvalid = e(100397, "Invalid request to subscribe to the events of an ABI: %s")
+	// RESTGatewayBulkSubscribeFailed one of the subscriptions for the events of an ABI failed, and the others were rolled back
+	RESTGatewayBulkSubscribeFailed = e(100398, "Failed to subscribe to event '%s', and the %d subscriptions created before it were removed: %s")
+	// DeployTransactionMissingLibrary the bytecode refers to a library, and no address was supplied to link it to
+	DeployTransactionMissingLibrary = e(100399, "The contract must be linked to library '%s' - supply the address it is deployed at in 'libraries'")
+	// DeployTransactionInvalidLibraryAddress the address supplied to link a library to is not an address
+	DeployTransactionInvalidLibraryAddress = e(100400, "Invalid address '%s' for library '%s'")
+	// DeployTransactionInvalidLinkReference a link reference stored with the bytecode does not fit within it
+	DeployTransactionInvalidLinkReference = e(100401, "The reference to library '%s' at offset %d is outside the bytecode")
+	// RESTGatewayInvalidLibrary a library to link a deployment to is not of the form name=address
+	RESTGatewayInvalidLibrary = e(100402, "Invalid library '%s' - supply the name and address of the library as name=address, or name=registeredContract")
+	// ABIValidateInvalidBody the body to validate is not an ABI, build artifact or compiler output
+	ABIValidateInvalidBody = e(100403, "Unable to read an ABI to validate - supply a JSON ABI, a build artifact or solc output: %s")
+	// ABIValidateUnknownType an element of the ABI has a type that is not in the ABI specification
+	ABIValidateUnknownType = e(100404, "Unknown element type '%s'")
+	// ABIValidateMissingName a function, event or error in the ABI has no name
+	ABIValidateMissingName = e(100405, "The %s has no name")
+	// ABIValidateInvalidElement the inputs or outputs of an element of the ABI cannot be parsed
+	ABIValidateInvalidElement = e(100406, "Invalid %s '%s': %s")
+	// ABIValidateDuplicate an element of the ABI is declared more than once with the same signature
+	ABIValidateDuplicate = e(100407, "Duplicate %s '%s'")
+	// ABIValidateSelectorClash two functions of the ABI have different signatures with the same selector
+	ABIValidateSelectorClash = e(100408, "Function '%s' has the same selector %s as '%s'")
+	// ABIValidateTooManyIndexed an event of the ABI has more indexed inputs than there are topics for
+	ABIValidateTooManyIndexed = e(100409, "Event '%s' has %d indexed inputs, and at most %d are allowed")
+	// ABIValidateTypeAlias an ABI argument has a Solidity type alias, which is not valid in an ABI
+	ABIValidateTypeAlias = e(100410, "The type '%s' of '%s' is an alias for '%s'")
+	// ContractStoreUnknownContentHash the configured content hash algorithm is not registered
+	ContractStoreUnknownContentHash = e(100411, "Unknown content hash algorithm '%s'")
+	// EventStreamsAMQPNoAddress attempt to create an AMQP event stream without a broker URL or target address
+	EventStreamsAMQPNoAddress = e(100412, "Must specify amqp.url and amqp.address for action type 'amqp'")
+	// AMQPInvalidURL the broker URL is not an amqp or amqps URL
+	AMQPInvalidURL = e(100413, "Invalid AMQP URL '%s' - must be of the form amqp://host:port or amqps://host:port")
+	// AMQPConnectFailed failed to establish a connection to the broker
+	AMQPConnectFailed = e(100414, "Failed to connect to AMQP broker %s: %s")
+	// AMQPProtocolError the broker sent a frame that could not be handled
+	AMQPProtocolError = e(100415, "AMQP protocol error: %s")
+	// AMQPAuthFailed the broker did not accept the SASL credentials
+	AMQPAuthFailed = e(100416, "AMQP broker %s rejected authentication (SASL outcome %d)")
+	// AMQPRemoteClosed the broker closed the connection, session or link
+	AMQPRemoteClosed = e(100417, "AMQP broker closed the %s: %s")
+	// AMQPConnectionClosed the connection was closed locally, or lost
+	AMQPConnectionClosed = e(100418, "AMQP connection closed")
+	// AMQPDeliveryRejected the broker rejected a message
+	AMQPDeliveryRejected = e(100419, "AMQP broker rejected message %s: %s")
+	// AMQPDeliveryReleased the broker released or modified a message without accepting it
+	AMQPDeliveryReleased = e(100420, "AMQP broker released message %s without accepting it")
+	// AMQPTimeout timed out waiting for the broker
+	AMQPTimeout = e(100421, "Timed out waiting for AMQP broker %s")
+	// EventStreamsActionPanic the action of an event stream panicked while delivering a batch
+	EventStreamsActionPanic = e(100422, "Event stream action failed unexpectedly: %v")
+	// EventStreamsNATSNoSubject attempt to create a NATS event stream without a server URL or subject
+	EventStreamsNATSNoSubject = e(100423, "Must specify nats.url and nats.subject for action type 'nats'")
+	// NATSInvalidURL the server URL is not a nats or tls URL
+	NATSInvalidURL = e(100424, "Invalid NATS URL '%s' - must be of the form nats://host:port or tls://host:port")
+	// NATSConnectFailed failed to establish a connection to the server
+	NATSConnectFailed = e(100425, "Failed to connect to NATS server %s: %s")
+	// NATSProtocolError the server sent something that could not be handled
+	NATSProtocolError = e(100426, "NATS protocol error: %s")
+	// NATSServerError the server reported an error, such as an authorization failure
+	NATSServerError = e(100427, "NATS server error: %s")
+	// NATSConnectionClosed the connection was closed locally, or lost
+	NATSConnectionClosed = e(100428, "NATS connection closed")
+	// NATSPublishFailed JetStream returned an error instead of acknowledging a message
+	NATSPublishFailed = e(100429, "JetStream did not store message %s: %s")
+	// NATSNoResponders no JetStream stream is bound to the subject
+	NATSNoResponders = e(100430, "No JetStream stream is listening on subject '%s'")
+	// NATSTimeout timed out waiting for the server
+	NATSTimeout = e(100431, "Timed out waiting for NATS server %s")
+	// NATSPayloadTooLarge a message is larger than the server accepts
+	NATSPayloadTooLarge = e(100432, "Message of %d bytes exceeds the max_payload of %d bytes of the NATS server")
+	// UnitsInvalidAmount an amount is neither an integer, nor a decimal number with a unit
+	UnitsInvalidAmount = e(100433, "Invalid amount '%s' - must be an integer, or a decimal number followed by a unit such as ether or gwei")
+	// UnitsUnknown an amount or output format uses a unit that is not known
+	UnitsUnknown = e(100434, "Unknown unit '%s' - must be one of wei, kwei, mwei, gwei, szabo, finney, ether or token")
+	// UnitsTooPrecise an amount has more decimal places than its unit can represent
+	UnitsTooPrecise = e(100435, "Amount '%s' has more than the %d decimal places allowed by unit '%s'")
+	// UnitsNoTokenDecimals the token unit is used for a contract that has no token decimals configured
+	UnitsNoTokenDecimals = e(100436, "Cannot convert '%s' as no token decimals are configured for the contract")
+	// EventStreamsSQSNoQueue attempt to create an SQS event stream without a queue URL
+	EventStreamsSQSNoQueue = e(100437, "Must specify sqs.queueUrl for action type 'sqs'")
+	// EventStreamsSNSNoTopic attempt to create an SNS event stream without a topic ARN
+	EventStreamsSNSNoTopic = e(100438, "Must specify sns.topicArn for action type 'sns'")
+	// EventStreamsAWSNoRegion the region of the queue or topic could not be determined
+	EventStreamsAWSNoRegion = e(100439, "Could not determine the AWS region of '%s' - set the region of the event stream, or AWS_REGION")
+	// EventStreamsAWSFailedHTTPStatus SQS or SNS rejected a request
+	EventStreamsAWSFailedHTTPStatus = e(100440, "%s: %s request failed with status=%d: %s")
+	// EventStreamsAWSEntriesFailed some of the messages in a batch were rejected
+	EventStreamsAWSEntriesFailed = e(100441, "%s: %d of %d messages rejected by %s: %s")
+	// EventStreamsAWSMessageTooLarge an event is too large to send as a single message
+	EventStreamsAWSMessageTooLarge = e(100442, "%s: Event %s is %d bytes, which exceeds the limit of %d bytes for a message")
+	// AWSCredentialsNotFound no credentials are available in the environment
+	AWSCredentialsNotFound = e(100443, "No AWS credentials found - set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN")
+	// AWSAssumeRoleFailed the web identity token could not be exchanged for credentials
+	AWSAssumeRoleFailed = e(100444, "Failed to assume AWS role with web identity: %s")
+	// WebSocketSubmitNotEnabled a client submitted a transaction to a WebSocket server that cannot dispatch them
+	WebSocketSubmitNotEnabled = e(100445, "Submitting transactions is not enabled on this WebSocket server")
+	// WebSocketSubmitInvalid a transaction submitted over a WebSocket is not a JSON object
+	WebSocketSubmitInvalid = e(100446, "Invalid submit request: %s")
+	// EventStreamsKafkaNoTopic attempt to create a Kafka event stream without brokers or a topic
+	EventStreamsKafkaNoTopic = e(100447, "Must specify kafka.brokers and kafka.topic for action type 'kafka'")
+	// EventStreamsKafkaSendFailed some of the events in a batch were not accepted by the Kafka brokers
+	EventStreamsKafkaSendFailed = e(100448, "%s: Failed to send %d of %d events to Kafka topic %s: %s")
+	// EventStreamsKafkaClosed a batch was sent after the Kafka action of the stream was closed
+	EventStreamsKafkaClosed = e(100449, "Kafka producer for topic %s closed")
+	// EventStreamsMQTTNoTopic attempt to create an MQTT event stream without a broker URL or topic
+	EventStreamsMQTTNoTopic = e(100450, "Must specify mqtt.url and mqtt.topic for action type 'mqtt'")
+	// EventStreamsMQTTInvalidTopic the topic template of an MQTT event stream could not be parsed
+	EventStreamsMQTTInvalidTopic = e(100451, "Invalid MQTT topic template '%s': %s")
+	// EventStreamsMQTTInvalidQoS the QoS of an MQTT event stream is not 0, 1 or 2
+	EventStreamsMQTTInvalidQoS = e(100452, "Invalid MQTT QoS %d - must be 0, 1 or 2")
+	// MQTTInvalidURL the broker URL is not an mqtt or mqtts URL
+	MQTTInvalidURL = e(100453, "Invalid MQTT URL '%s' - must be of the form mqtt://host:port or mqtts://host:port")
+	// MQTTInvalidProtocolVersion the protocol version is not one that is supported
+	MQTTInvalidProtocolVersion = e(100454, "Invalid MQTT protocol version '%s' - must be 3.1.1 or 5")
+	// MQTTConnectFailed failed to establish a connection to the broker
+	MQTTConnectFailed = e(100455, "Failed to connect to MQTT broker %s: %s")
+	// MQTTConnectRejected the broker refused the connection, such as for bad credentials
+	MQTTConnectRejected = e(100456, "MQTT broker %s rejected the connection: %s")
+	// MQTTProtocolError the broker sent something that could not be handled
+	MQTTProtocolError = e(100457, "MQTT protocol error: %s")
+	// MQTTConnectionClosed the connection was closed locally, or lost
+	MQTTConnectionClosed = e(100458, "MQTT connection closed")
+	// MQTTDisconnected the broker closed the connection with a reason code
+	MQTTDisconnected = e(100459, "MQTT broker %s disconnected: %s")
+	// MQTTPublishFailed the broker returned an error reason code instead of accepting a message
+	MQTTPublishFailed = e(100460, "MQTT broker did not accept message on topic '%s': %s")
+	// MQTTTimeout timed out waiting for the broker
+	MQTTTimeout = e(100461, "Timed out waiting for MQTT broker %s")
+	// MQTTPacketTooLarge a message is larger than the broker accepts
+	MQTTPacketTooLarge = e(100462, "Message of %d bytes exceeds the maximum packet size of %d bytes of the MQTT broker")
+	// MQTTQoSNotSupported a message requested a higher QoS than the broker supports
+	MQTTQoSNotSupported = e(100463, "Message requested QoS %d, but the MQTT broker only supports up to QoS %d")
+	// MQTTInvalidTopicName a topic to publish to is empty, or contains a wildcard
+	MQTTInvalidTopicName = e(100464, "Invalid MQTT topic name '%s' - must not be empty or contain the wildcards '+' or '#'")
+	// ContractRegistryNameClash a name to register is already taken in the local or remote registry
+	ContractRegistryNameClash = e(100465, "Name '%s' is already registered to %s")
+	// ReplyProcessorUnknownType the reply processor type is not one we support
+	ReplyProcessorUnknownType = e(100466, "Unknown type '%s' for reply processor '%s'")
+	// ReplyProcessorMissingConfig a required setting for the reply processor is missing
+	ReplyProcessorMissingConfig = e(100467, "Reply processor '%s' requires '%s' to be configured")
+	// ReplyProcessorDuplicate a built-in reply processor is configured more than once
+	ReplyProcessorDuplicate = e(100468, "Reply processor '%s' can only be included once in the chain")
+	// ReplyProcessorHTTPStatus the HTTP endpoint rejected the reply
+	ReplyProcessorHTTPStatus = e(100469, "Reply processor '%s' received status %d from %s")
+	// ReplyProcessorInvalidReceipt the reply could not be parsed as a transaction receipt
+	ReplyProcessorInvalidReceipt = e(100470, "Failed to parse message as transaction receipt: %s")
+	// EventStreamsGRPCNoURL attempt to create a gRPC event stream without a service URL
+	EventStreamsGRPCNoURL = e(100471, "Must specify grpc.url for action type 'grpc'")
+	// EventStreamsGRPCBatchRejected the gRPC service acked a batch with an error
+	EventStreamsGRPCBatchRejected = e(100472, "gRPC service rejected batch %d: %s")
+	// GRPCInvalidURL the service URL is not a grpc or grpcs URL
+	GRPCInvalidURL = e(100473, "Invalid gRPC URL '%s' - must be of the form grpc://host:port or grpcs://host:port")
+	// GRPCRequestFailed the Deliver call could not be made, or was refused at the HTTP level
+	GRPCRequestFailed = e(100474, "gRPC request to %s failed: %s")
+	// GRPCStatus the gRPC service ended the Deliver call with an error status
+	GRPCStatus = e(100475, "gRPC service %s returned status %s: %s")
+	// GRPCInvalidMessage the gRPC service sent a message that could not be read
+	GRPCInvalidMessage = e(100476, "Invalid gRPC message: %s")
+	// GRPCStreamClosed the stream was closed locally, or ended by the service
+	GRPCStreamClosed = e(100477, "gRPC stream closed")
+	// GRPCTimeout timed out waiting for the gRPC service
+	GRPCTimeout = e(100478, "Timed out waiting for gRPC service %s")
+	// EventStreamsExportJobsNotConfigured no output location is configured for export jobs
+	EventStreamsExportJobsNotConfigured = e(100479, "Export jobs require an output path or S3 bucket to be configured")
+	// EventStreamsExportJobNotFound the export job does not exist
+	EventStreamsExportJobNotFound = e(100480, "Export job with ID '%s' not found")
+	// RESTGatewayExportJobInvalid failed to parse the request to start an export job
+	RESTGatewayExportJobInvalid = e(100481, "Invalid export job request: %s")
+	// EventStreamsPubSubPublishFailed Pub/Sub did not accept every message of a batch
+	EventStreamsPubSubPublishFailed = e(100482, "%s: Pub/Sub publish failed: %s")
+	// EventStreamsPubSubClosed the Pub/Sub client was closed, as the stream was stopped
+	EventStreamsPubSubClosed = e(100483, "Pub/Sub client closed")
+	// WebhooksCancelNotSubmitter only the submitter, or an approver, can withdraw a request
+	WebhooksCancelNotSubmitter = e(100484, "Request '%s' can only be cancelled by its submitter, or a principal authorized for approvals")
+	// ReceiptStoreRevisionsNotSupported the receipt store does not version receipts
+	ReceiptStoreRevisionsNotSupported = e(100485, "The configured receipt store does not version receipts")
+	// SecurityModuleNoAdminSupport the security module does not implement the admin extension
+	SecurityModuleNoAdminSupport = e(100486, "The configured security module does not support the admin APIs")
 )
 
 type EthconnectError interface {
@@ -0,0 +1,169 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// gen writes the error codes declared in internal/errors/errors.go to the importable
+// pkg/errorcodes package, and to a JSON artifact alongside it. Codes that have been
+// published cannot be removed or renamed, so the catalogue only ever grows.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	goFile   = "errorcodes.go"
+	jsonFile = "errorcodes.json"
+)
+
+type entry struct {
+	Name        string `json:"name"`
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+	Description string `json:"description,omitempty"`
+}
+
+var goTemplate = template.Must(template.New(goFile).Parse(`// Code generated by internal/errors/gen. DO NOT EDIT.
+
+package errorcodes
+
+const (
+{{- range .}}
+	// {{.Name}} {{.Description}}
+	{{.Name}} = "{{.Code}}"
+{{- end}}
+)
+
+// Catalogue is every error code ethconnect can return, in code order
+var Catalogue = []*Entry{
+{{- range .}}
+	{Name: "{{.Name}}", Code: {{.Name}}, Message: {{printf "%q" .Message}}, Description: {{printf "%q" .Description}}},
+{{- end}}
+}
+`))
+
+func parseErrors(filename string) ([]*entry, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*entry
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			vs := spec.(*ast.ValueSpec)
+			if len(vs.Names) != 1 || len(vs.Values) != 1 {
+				continue
+			}
+			call, ok := vs.Values[0].(*ast.CallExpr)
+			if !ok || len(call.Args) != 2 {
+				continue
+			}
+			if fn, ok := call.Fun.(*ast.Ident); !ok || fn.Name != "e" {
+				continue
+			}
+			code, ok1 := call.Args[0].(*ast.BasicLit)
+			msg, ok2 := call.Args[1].(*ast.BasicLit)
+			if !ok1 || !ok2 || code.Kind != token.INT || msg.Kind != token.STRING {
+				return nil, fmt.Errorf("%s: error code %s must be declared with literal values", fset.Position(vs.Pos()), vs.Names[0].Name)
+			}
+			message, _ := strconv.Unquote(msg.Value)
+			name := vs.Names[0].Name
+			description := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(vs.Doc.Text()), name))
+			entries = append(entries, &entry{
+				Name:        name,
+				Code:        "FFEC" + code.Value,
+				Message:     message,
+				Description: strings.Join(strings.Fields(description), " "),
+			})
+		}
+	}
+	return entries, nil
+}
+
+// checkStable fails if any previously published code has been removed or renamed
+func checkStable(published, entries []*entry) error {
+	byCode := make(map[string]*entry, len(entries))
+	for _, e := range entries {
+		byCode[e.Code] = e
+	}
+	for _, p := range published {
+		e, ok := byCode[p.Code]
+		if !ok {
+			return fmt.Errorf("published error code %s (%s) has been removed", p.Code, p.Name)
+		}
+		if e.Name != p.Name {
+			return fmt.Errorf("published error code %s has been renamed from %s to %s", p.Code, p.Name, e.Name)
+		}
+	}
+	return nil
+}
+
+func generate(src, outDir string) (goSrc, jsonSrc []byte, err error) {
+	entries, err := parseErrors(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(outDir, jsonFile)); err == nil {
+		var published []*entry
+		if err := json.Unmarshal(b, &published); err != nil {
+			return nil, nil, err
+		}
+		if err := checkStable(published, entries); err != nil {
+			return nil, nil, err
+		}
+	}
+	var buf bytes.Buffer
+	if err := goTemplate.Execute(&buf, entries); err != nil {
+		return nil, nil, err
+	}
+	if goSrc, err = format.Source(buf.Bytes()); err != nil {
+		return nil, nil, err
+	}
+	jsonSrc, _ = json.MarshalIndent(entries, "", "  ")
+	return goSrc, append(jsonSrc, '\n'), nil
+}
+
+func main() {
+	src := flag.String("src", "errors.go", "Go file declaring the error codes")
+	outDir := flag.String("out", "../../pkg/errorcodes", "Directory of the generated package")
+	flag.Parse()
+	goSrc, jsonSrc, err := generate(*src, *outDir)
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(*outDir, goFile), goSrc, 0644)
+	}
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(*outDir, jsonFile), jsonSrc, 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error code generation failed: %s\n", err)
+		os.Exit(1)
+	}
+}
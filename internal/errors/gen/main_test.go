@@ -0,0 +1,106 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratedCodesUpToDate(t *testing.T) {
+	assert := assert.New(t)
+	goSrc, jsonSrc, err := generate("../errors.go", "../../../pkg/errorcodes")
+	assert.NoError(err)
+	b, _ := ioutil.ReadFile("../../../pkg/errorcodes/errorcodes.go")
+	assert.Equal(string(b), string(goSrc), "run 'go generate ./pkg/errorcodes'")
+	b, _ = ioutil.ReadFile("../../../pkg/errorcodes/errorcodes.json")
+	assert.Equal(string(b), string(jsonSrc), "run 'go generate ./pkg/errorcodes'")
+}
+
+func writeTestErrors(t *testing.T, dir, src string) string {
+	filename := path.Join(dir, "errors.go")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(src), 0644))
+	return filename
+}
+
+func TestGenerateStability(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "errorcodes")
+	defer os.RemoveAll(dir)
+
+	src := writeTestErrors(t, dir, `package errors
+var (
+	// CodeOne the first code
+	CodeOne = e(100000, "one %s")
+	// CodeTwo the second code
+	CodeTwo = e(100001, "two")
+)`)
+	goSrc, jsonSrc, err := generate(src, dir)
+	assert.NoError(err)
+	assert.Contains(string(goSrc), `CodeOne = "FFEC100000"`)
+	assert.Contains(string(goSrc), `Message: "one %s", Description: "the first code"`)
+	assert.NoError(ioutil.WriteFile(path.Join(dir, jsonFile), jsonSrc, 0644))
+
+	src = writeTestErrors(t, dir, `package errors
+var (
+	// CodeOne the first code
+	CodeOne = e(100000, "one %s")
+	// CodeTwo the second code
+	CodeTwo = e(100001, "two")
+	CodeThree = e(100002, "three")
+)`)
+	_, _, err = generate(src, dir)
+	assert.NoError(err)
+
+	src = writeTestErrors(t, dir, `package errors
+var (
+	// CodeOne the first code
+	CodeOne = e(100000, "one %s")
+)`)
+	_, _, err = generate(src, dir)
+	assert.Regexp("FFEC100001 \\(CodeTwo\\) has been removed", err)
+
+	src = writeTestErrors(t, dir, `package errors
+var (
+	CodeOne = e(100000, "one %s")
+	CodeTwoRenamed = e(100001, "two")
+)`)
+	_, _, err = generate(src, dir)
+	assert.Regexp("FFEC100001 has been renamed from CodeTwo to CodeTwoRenamed", err)
+}
+
+func TestGenerateErrors(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "errorcodes")
+	defer os.RemoveAll(dir)
+
+	_, _, err := generate(path.Join(dir, "missing.go"), dir)
+	assert.Error(err)
+
+	src := writeTestErrors(t, dir, `package errors
+var CodeOne = e(100000, msg)`)
+	_, _, err = generate(src, dir)
+	assert.Regexp("CodeOne must be declared with literal values", err)
+
+	src = writeTestErrors(t, dir, `package errors
+var CodeOne = e(100000, "one")`)
+	assert.NoError(ioutil.WriteFile(path.Join(dir, jsonFile), []byte("!json"), 0644))
+	_, _, err = generate(src, dir)
+	assert.Error(err)
+}
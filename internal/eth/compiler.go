@@ -25,6 +25,7 @@ import (
 
 	"github.com/hyperledger/firefly-ethconnect/internal/errors"
 	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
 	"github.com/hyperledger/firefly-ethconnect/internal/utils"
 	ethbinding "github.com/kaleido-io/ethbinding/pkg"
 	log "github.com/sirupsen/logrus"
@@ -42,11 +43,13 @@ type SolcVersion struct {
 
 // CompiledSolidity wraps solc compilation of solidity and ABI generation
 type CompiledSolidity struct {
-	ContractName string
-	Compiled     []byte
-	DevDoc       string
-	ABI          ethbinding.ABIMarshaling
-	ContractInfo *ethbinding.ContractInfo
+	ContractName   string
+	Compiled       []byte
+	DevDoc         string
+	UserDoc        string
+	ABI            ethbinding.ABIMarshaling
+	ContractInfo   *ethbinding.ContractInfo
+	LinkReferences []*messages.LinkReference
 }
 
 var solcVerChecker *regexp.Regexp
@@ -160,10 +163,15 @@ func ProcessCompiled(compiled map[string]*ethbinding.Contract, contractName stri
 		contractName = contractNames[0].String()
 		contract = compiled[contractName]
 	}
-	return packContract(contractName, contract)
+	// Any of the other contracts in the output might be a library the contract is linked to
+	libraryNames := make([]string, 0, len(contractNames))
+	for _, name := range contractNames {
+		libraryNames = append(libraryNames, name.String())
+	}
+	return packContract(contractName, contract, libraryNames)
 }
 
-func packContract(contractName string, contract *ethbinding.Contract) (c *CompiledSolidity, err error) {
+func packContract(contractName string, contract *ethbinding.Contract, libraryNames []string) (c *CompiledSolidity, err error) {
 
 	firstColon := strings.LastIndex(contractName, ":")
 	if firstColon >= 0 && firstColon < (len(contractName)-1) {
@@ -174,13 +182,15 @@ func packContract(contractName string, contract *ethbinding.Contract) (c *Compil
 		ContractName: contractName,
 		ContractInfo: &contract.Info,
 	}
-	c.Compiled, err = ethbind.API.HexDecode(contract.Code)
+	code, linkReferences := ExtractLinkReferences(contract.Code, libraryNames)
+	c.Compiled, err = ethbind.API.HexDecode(code)
 	if err != nil {
 		return nil, errors.Errorf(errors.CompilerBytecodeInvalid, err)
 	}
 	if len(c.Compiled) == 0 {
 		return nil, errors.Errorf(errors.CompilerBytecodeEmpty, contractName)
 	}
+	c.LinkReferences = linkReferences
 	// Pack the arguments for calling the contract
 	abiJSON, err := json.Marshal(contract.Info.AbiDefinition)
 	if err != nil {
@@ -197,5 +207,10 @@ func packContract(contractName string, contract *ethbinding.Contract) (c *Compil
 		return nil, errors.Errorf(errors.CompilerSerializeDevDocs, err)
 	}
 	c.DevDoc = string(devdocBytes)
+	userdocBytes, err := json.Marshal(contract.Info.UserDoc)
+	if err != nil {
+		return nil, errors.Errorf(errors.CompilerSerializeUserDocs, err)
+	}
+	c.UserDoc = string(userdocBytes)
 	return c, nil
 }
@@ -27,7 +27,7 @@ func TestPackContractRemovePrefix(t *testing.T) {
 	contract := &ethbinding.Contract{
 		Code: "0x00",
 	}
-	compiled, err := packContract("<stdin>:stuff:watsit", contract)
+	compiled, err := packContract("<stdin>:stuff:watsit", contract, nil)
 	assert.NoError(err)
 	assert.Equal("watsit", compiled.ContractName)
 }
@@ -37,7 +37,7 @@ func TestPackContractNoPrefix(t *testing.T) {
 	contract := &ethbinding.Contract{
 		Code: "0x00",
 	}
-	compiled, err := packContract("thingymobob", contract)
+	compiled, err := packContract("thingymobob", contract, nil)
 	assert.NoError(err)
 	assert.Equal("thingymobob", compiled.ContractName)
 }
@@ -47,7 +47,7 @@ func TestPackContractFailBadHexCode(t *testing.T) {
 	contract := &ethbinding.Contract{
 		Code: "Not Hex",
 	}
-	_, err := packContract("", contract)
+	_, err := packContract("", contract, nil)
 	assert.Regexp("Decoding bytecode: hex string without 0x prefix", err)
 }
 
@@ -56,7 +56,7 @@ func TestPackContractEmpty(t *testing.T) {
 	contract := &ethbinding.Contract{
 		Code: "0x",
 	}
-	_, err := packContract("", contract)
+	_, err := packContract("", contract, nil)
 	assert.Regexp("Specified contract compiled ok, but did not result in any bytecode: ", err)
 }
 
@@ -68,7 +68,7 @@ func TestPackContractFailMarshalABI(t *testing.T) {
 			AbiDefinition: make(map[bool]bool),
 		},
 	}
-	_, err := packContract("", contract)
+	_, err := packContract("", contract, nil)
 	assert.Regexp("Serializing ABI: json: unsupported type: map\\[bool\\]bool", err)
 }
 
@@ -82,7 +82,7 @@ func TestPackContractFailUnmarshalABIJSON(t *testing.T) {
 			},
 		},
 	}
-	_, err := packContract("", contract)
+	_, err := packContract("", contract, nil)
 	assert.Regexp("Parsing ABI", err)
 }
 
@@ -94,10 +94,22 @@ func TestPackContractFailSerializingDevDoc(t *testing.T) {
 			DeveloperDoc: make(map[bool]bool),
 		},
 	}
-	_, err := packContract("", contract)
+	_, err := packContract("", contract, nil)
 	assert.Regexp("Serializing DevDoc", err.Error())
 }
 
+func TestPackContractFailSerializingUserDoc(t *testing.T) {
+	assert := assert.New(t)
+	contract := &ethbinding.Contract{
+		Code: "0x00",
+		Info: ethbinding.ContractInfo{
+			UserDoc: map[string]interface{}{"notice": func() {}},
+		},
+	}
+	_, err := packContract("", contract, nil)
+	assert.Regexp("Serializing UserDoc", err.Error())
+}
+
 func TestSolcDefaultVersion(t *testing.T) {
 	assert := assert.New(t)
 	os.Setenv("FLY_SOLC_DEFAULT", "")
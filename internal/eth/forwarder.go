@@ -0,0 +1,109 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eth
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+)
+
+// forwardRequestArg is the ForwardRequest struct of an EIP-2771 forwarder, as implemented
+// by the OpenZeppelin MinimalForwarder
+var forwardRequestArg = ethbinding.ABIArgumentMarshaling{
+	Name: "req",
+	Type: "tuple",
+	Components: []ethbinding.ABIArgumentMarshaling{
+		{Name: "from", Type: "address"},
+		{Name: "to", Type: "address"},
+		{Name: "value", Type: "uint256"},
+		{Name: "gas", Type: "uint256"},
+		{Name: "nonce", Type: "uint256"},
+		{Name: "data", Type: "bytes"},
+	},
+}
+
+func forwarderMethod(name, mutability string, outputs []ethbinding.ABIArgumentMarshaling) *ethbinding.ABIMethod {
+	method, _ := ethbind.API.ABIElementMarshalingToABIMethod(&ethbinding.ABIElementMarshaling{
+		Type:            "function",
+		Name:            name,
+		StateMutability: mutability,
+		Inputs:          []ethbinding.ABIArgumentMarshaling{forwardRequestArg, {Name: "signature", Type: "bytes"}},
+		Outputs:         outputs,
+	})
+	return method
+}
+
+// ForwarderExecuteMethod is execute(ForwardRequest,bytes) on the forwarder, which checks the
+// signature and nonce of the request, then calls the target with the signer appended to the calldata
+func ForwarderExecuteMethod() *ethbinding.ABIMethod {
+	return forwarderMethod("execute", "payable", []ethbinding.ABIArgumentMarshaling{{Type: "bool"}, {Type: "bytes"}})
+}
+
+// ForwarderVerifyMethod is verify(ForwardRequest,bytes) on the forwarder, which returns true
+// if execute would accept the signature and nonce of the request
+func ForwarderVerifyMethod() *ethbinding.ABIMethod {
+	return forwarderMethod("verify", "view", []ethbinding.ABIArgumentMarshaling{{Type: "bool"}})
+}
+
+func forwardRequestParams(req *messages.ForwardRequest, signature string) []interface{} {
+	value := req.Value
+	if value == "" {
+		value = "0"
+	}
+	data := req.Data
+	if data == "" {
+		data = "0x"
+	}
+	return []interface{}{
+		map[string]interface{}{
+			"from":  req.From,
+			"to":    req.To,
+			"value": value.String(),
+			"gas":   req.Gas.String(),
+			"nonce": req.Nonce.String(),
+			"data":  data,
+		},
+		signature,
+	}
+}
+
+// NewForwardTxn builds a transaction from the relayer to the forwarder, that executes a
+// meta-transaction signed by the user. The value of the request is sent with the transaction,
+// as the forwarder passes it on to the target.
+func NewForwardTxn(signer TXSigner, relayer, forwarder string, nonce, gas, gasPrice json.Number, req *messages.ForwardRequest, signature string) (*Txn, error) {
+	value := req.Value
+	if value == "" {
+		value = "0"
+	}
+	return buildTX(signer, relayer, forwarder, nonce, value, gas, gasPrice, ForwarderExecuteMethod(), forwardRequestParams(req, signature))
+}
+
+// VerifyForwardRequest calls verify on the forwarder, so a request with a bad signature or a
+// used nonce is rejected before the relayer pays gas to submit it
+func VerifyForwardRequest(ctx context.Context, rpc RPCClient, forwarder string, req *messages.ForwardRequest, signature string) error {
+	res, err := CallMethod(ctx, rpc, nil, "", forwarder, json.Number("0"), ForwarderVerifyMethod(), forwardRequestParams(req, signature), "latest")
+	if err != nil {
+		return err
+	}
+	if verified, _ := res["output"].(bool); !verified {
+		return errors.Errorf(errors.TransactionRelayVerifyFailed, req.From, req.Nonce)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eth
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func testForwardRequest() *messages.ForwardRequest {
+	return &messages.ForwardRequest{
+		From:  "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		To:    "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+		Gas:   "100000",
+		Nonce: "3",
+		Data:  "0x60fe47b1",
+	}
+}
+
+func TestForwarderMethods(t *testing.T) {
+	assert := assert.New(t)
+	// Selectors of the OpenZeppelin MinimalForwarder
+	assert.Equal("47153f82", hex.EncodeToString(ForwarderExecuteMethod().ID))
+	assert.Equal("bf5d3bdb", hex.EncodeToString(ForwarderVerifyMethod().ID))
+}
+
+func TestNewForwardTxn(t *testing.T) {
+	assert := assert.New(t)
+
+	req := testForwardRequest()
+	req.Value = "10"
+	tx, err := NewForwardTxn(nil, "0x83dBC8e329b38cBA0Fc4ed99b1Ce9c2a390ABdC1", "0x5fbdb2315678afecb367f032d93f642f64180aa3", "7", "", "", req, "0x1234")
+	assert.NoError(err)
+	assert.Equal(uint64(7), tx.EthTX.Nonce())
+	assert.Equal(int64(10), tx.EthTX.Value().Int64())
+	assert.Equal("0x5FbDB2315678afecb367f032d93F642f64180aa3", tx.EthTX.To().Hex())
+
+	args, err := ForwarderExecuteMethod().Inputs.Unpack(tx.EthTX.Data()[4:])
+	assert.NoError(err)
+	assert.Equal([]byte{0x12, 0x34}, args[1])
+
+	_, err = NewForwardTxn(nil, "bad", "0x5fbdb2315678afecb367f032d93f642f64180aa3", "7", "", "", testForwardRequest(), "0x1234")
+	assert.Regexp("from", err)
+}
+
+func TestVerifyForwardRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	rpc := &testRPCClient{
+		resultWrangler: func(result interface{}) {
+			*(result.(*string)) = "0x0000000000000000000000000000000000000000000000000000000000000001"
+		},
+	}
+	err := VerifyForwardRequest(context.Background(), rpc, "0x5fbdb2315678afecb367f032d93f642f64180aa3", testForwardRequest(), "0x1234")
+	assert.NoError(err)
+	assert.Equal("eth_call", rpc.capturedMethod)
+
+	rpc = &testRPCClient{
+		resultWrangler: func(result interface{}) {
+			*(result.(*string)) = "0x0000000000000000000000000000000000000000000000000000000000000000"
+		},
+	}
+	err = VerifyForwardRequest(context.Background(), rpc, "0x5fbdb2315678afecb367f032d93f642f64180aa3", testForwardRequest(), "0x1234")
+	assert.Regexp("FFEC100362", err)
+
+	rpc = &testRPCClient{mockError: fmt.Errorf("pop")}
+	err = VerifyForwardRequest(context.Background(), rpc, "0x5fbdb2315678afecb367f032d93f642f64180aa3", testForwardRequest(), "0x1234")
+	assert.Regexp("pop", err)
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eth
+
+import (
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"golang.org/x/crypto/sha3"
+)
+
+// libraryPlaceholderLen is the length in hex characters of a library placeholder in unlinked
+// bytecode, which is the length of the address that replaces it
+const libraryPlaceholderLen = 40
+
+var libraryAddressCheck = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// libraryPlaceholderHash returns the hash solc puts in placeholders since Solidity 0.5, which is
+// the first 17 bytes of the keccak256 hash of the fully qualified library name
+func libraryPlaceholderHash(fqName string) string {
+	h := sha3.NewLegacyKeccak256()
+	_, _ = h.Write([]byte(fqName))
+	return hex.EncodeToString(h.Sum(nil))[0:34]
+}
+
+// ExtractLinkReferences finds the placeholders in hex bytecode for libraries that are deployed
+// separately. It returns the bytecode with each placeholder replaced by a zero address, ready to
+// decode, and the byte offsets of the placeholders for each library. Placeholders from Solidity
+// 0.5 onwards only hold a hash of the library name, so they are named from the fully qualified
+// names (source:Name) in libraryNames, or by the hash if there is no match. Older placeholders
+// hold the name itself.
+func ExtractLinkReferences(codeHex string, libraryNames []string) (string, []*messages.LinkReference) {
+	if !strings.Contains(codeHex, "__") {
+		return codeHex, nil
+	}
+	prefix := 0
+	if strings.HasPrefix(codeHex, "0x") {
+		prefix = 2
+	}
+	hashes := make(map[string]string, len(libraryNames))
+	for _, name := range libraryNames {
+		hashes[libraryPlaceholderHash(name)] = name
+	}
+	var refs []*messages.LinkReference
+	byLibrary := make(map[string]*messages.LinkReference)
+	unlinked := []byte(codeHex)
+	for i := prefix; i+libraryPlaceholderLen <= len(codeHex); i += 2 {
+		placeholder := codeHex[i : i+libraryPlaceholderLen]
+		if !strings.HasPrefix(placeholder, "__") || !strings.HasSuffix(placeholder, "__") {
+			continue
+		}
+		library := strings.TrimRight(placeholder[2:38], "_")
+		if placeholder[2] == '$' && placeholder[37] == '$' {
+			library = placeholder[2:38]
+			if name, ok := hashes[placeholder[3:37]]; ok {
+				library = name
+			}
+		}
+		ref := byLibrary[library]
+		if ref == nil {
+			ref = &messages.LinkReference{Library: library}
+			byLibrary[library] = ref
+			refs = append(refs, ref)
+		}
+		ref.Offsets = append(ref.Offsets, (i-prefix)/2)
+		copy(unlinked[i:], strings.Repeat("0", libraryPlaceholderLen))
+		i += libraryPlaceholderLen - 2
+	}
+	return string(unlinked), refs
+}
+
+// LibraryShortName returns the name of a library without the source file it is declared in
+func LibraryShortName(library string) string {
+	return library[strings.LastIndex(library, ":")+1:]
+}
+
+// LookupLibrary finds the address for a library, supplied under its fully qualified name or
+// its short name
+func LookupLibrary(libraries map[string]string, library string) (string, bool) {
+	if addr, ok := libraries[library]; ok {
+		return addr, true
+	}
+	addr, ok := libraries[LibraryShortName(library)]
+	return addr, ok
+}
+
+// LinkBytecode returns a copy of the bytecode, with the address of each library it refers to
+// written at the offsets of its link reference
+func LinkBytecode(code []byte, refs []*messages.LinkReference, libraries map[string]string) ([]byte, error) {
+	if len(refs) == 0 {
+		return code, nil
+	}
+	linked := make([]byte, len(code))
+	copy(linked, code)
+	for _, ref := range refs {
+		addrStr, ok := LookupLibrary(libraries, ref.Library)
+		if !ok {
+			return nil, errors.Errorf(errors.DeployTransactionMissingLibrary, ref.Library)
+		}
+		addrHex := strings.ToLower(strings.TrimPrefix(addrStr, "0x"))
+		if !libraryAddressCheck.MatchString(addrHex) {
+			return nil, errors.Errorf(errors.DeployTransactionInvalidLibraryAddress, addrStr, ref.Library)
+		}
+		addr, _ := hex.DecodeString(addrHex)
+		for _, offset := range ref.Offsets {
+			if offset < 0 || offset+len(addr) > len(linked) {
+				return nil, errors.Errorf(errors.DeployTransactionInvalidLinkReference, ref.Library, offset)
+			}
+			copy(linked[offset:], addr)
+		}
+	}
+	return linked, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2018, 2021 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eth
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+const testLibAddr = "0x0123456789abcdef0123456789abcdef01234567"
+
+func TestLibraryPlaceholderHash(t *testing.T) {
+	// The placeholder given in the Solidity documentation on library linking
+	assert.Equal(t, "30bbc0abd4d6364515865950d3e0d10953", libraryPlaceholderHash("libraries/bigint.sol:BigInt"))
+}
+
+func TestExtractLinkReferencesHashed(t *testing.T) {
+	assert := assert.New(t)
+
+	placeholder := "__$" + libraryPlaceholderHash("contracts/lib.sol:Lib") + "$__"
+	other := "__$" + libraryPlaceholderHash("contracts/other.sol:Other") + "$__"
+	code, refs := ExtractLinkReferences("0x6001"+placeholder+"6002"+placeholder+other, []string{"contracts/lib.sol:Lib"})
+	assert.Equal("0x6001"+strings.Repeat("0", 40)+"6002"+strings.Repeat("0", 80), code)
+	assert.Equal([]*messages.LinkReference{
+		{Library: "contracts/lib.sol:Lib", Offsets: []int{2, 24}},
+		{Library: other[2:38], Offsets: []int{44}},
+	}, refs)
+}
+
+func TestExtractLinkReferencesLegacy(t *testing.T) {
+	assert := assert.New(t)
+
+	placeholder := "__lib.sol:Lib" + strings.Repeat("_", 27)
+	code, refs := ExtractLinkReferences("73"+placeholder+"3014", nil)
+	assert.Equal("73"+strings.Repeat("0", 40)+"3014", code)
+	assert.Equal([]*messages.LinkReference{{Library: "lib.sol:Lib", Offsets: []int{1}}}, refs)
+}
+
+func TestExtractLinkReferencesNone(t *testing.T) {
+	code, refs := ExtractLinkReferences("0x6001600255", nil)
+	assert.Equal(t, "0x6001600255", code)
+	assert.Nil(t, refs)
+}
+
+func TestLookupLibrary(t *testing.T) {
+	assert := assert.New(t)
+
+	addr, ok := LookupLibrary(map[string]string{"lib.sol:Lib": "a"}, "lib.sol:Lib")
+	assert.True(ok)
+	assert.Equal("a", addr)
+	addr, ok = LookupLibrary(map[string]string{"Lib": "b"}, "lib.sol:Lib")
+	assert.True(ok)
+	assert.Equal("b", addr)
+	_, ok = LookupLibrary(map[string]string{"Other": "c"}, "lib.sol:Lib")
+	assert.False(ok)
+}
+
+func TestLinkBytecode(t *testing.T) {
+	assert := assert.New(t)
+
+	code := make([]byte, 45)
+	refs := []*messages.LinkReference{{Library: "lib.sol:Lib", Offsets: []int{1, 25}}}
+	linked, err := LinkBytecode(code, refs, map[string]string{"Lib": testLibAddr})
+	assert.NoError(err)
+	addr := strings.TrimPrefix(testLibAddr, "0x")
+	assert.Equal("00"+addr+"00000000"+addr, hex.EncodeToString(linked))
+	// The unlinked bytecode is not changed
+	assert.Equal(make([]byte, 45), code)
+
+	linked, err = LinkBytecode(code, nil, nil)
+	assert.NoError(err)
+	assert.Equal(code, linked)
+}
+
+func TestLinkBytecodeErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	refs := []*messages.LinkReference{{Library: "lib.sol:Lib", Offsets: []int{10}}}
+	_, err := LinkBytecode(make([]byte, 20), refs, nil)
+	assert.Regexp("FFEC100399.*lib.sol:Lib", err)
+	_, err = LinkBytecode(make([]byte, 20), refs, map[string]string{"Lib": "0x1234"})
+	assert.Regexp("FFEC100400.*0x1234", err)
+	_, err = LinkBytecode(make([]byte, 20), refs, map[string]string{"Lib": testLibAddr})
+	assert.Regexp("FFEC100401.*offset 10", err)
+}
@@ -0,0 +1,212 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eth
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// CodeMetadata is the metadata solc appends to the runtime bytecode of a contract, which
+// identifies the metadata JSON of the compilation (containing the ABI) by its content hash
+type CodeMetadata struct {
+	// IPFS is the CIDv0 of the metadata JSON, in the form served by IPFS gateways
+	IPFS string `json:"ipfs,omitempty"`
+	// Swarm is the bzzr0 or bzzr1 hash of the metadata JSON, from compilers before 0.6.0
+	Swarm string `json:"swarm,omitempty"`
+	// Solc is the compiler version, from 0.5.9 onwards
+	Solc string `json:"solc,omitempty"`
+}
+
+// GetCode returns the hex encoded runtime bytecode of a contract
+func GetCode(ctx context.Context, rpc RPCClient, addrHexNo0x string) (string, error) {
+	start := time.Now().UTC()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var code string
+	if err := rpc.CallContext(ctx, &code, "eth_getCode", "0x"+addrHexNo0x, "latest"); err != nil {
+		return "", errors.Errorf(errors.RPCCallReturnedError, "eth_getCode", err)
+	}
+	callTime := time.Now().UTC().Sub(start)
+	log.Debugf("eth_getCode(%s) %d bytes [%.2fs]", addrHexNo0x, len(strings.TrimPrefix(code, "0x"))/2, callTime.Seconds())
+	return code, nil
+}
+
+// ParseCodeMetadata decodes the CBOR encoded metadata at the end of hex encoded runtime bytecode.
+// The last two bytes of the code are the length of the CBOR map that precedes them. Nil is returned
+// if the code does not end with a map containing an IPFS or Swarm hash.
+func ParseCodeMetadata(code string) *CodeMetadata {
+	b, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(code), "0x"))
+	if err != nil || len(b) < 2 {
+		return nil
+	}
+	cborLen := int(binary.BigEndian.Uint16(b[len(b)-2:]))
+	if cborLen == 0 || cborLen > len(b)-2 {
+		return nil
+	}
+	d := &cborDecoder{data: b[len(b)-2-cborLen : len(b)-2]}
+	entries, err := d.readMap()
+	if err != nil || len(d.data) != 0 {
+		log.Debugf("Code does not end with a metadata hash: %v", err)
+		return nil
+	}
+	metadata := &CodeMetadata{}
+	for key, value := range entries {
+		switch v := value.(type) {
+		case []byte:
+			switch {
+			case key == "ipfs" && len(v) == 34 && v[0] == 0x12 && v[1] == 0x20:
+				// A sha2-256 multihash, which is the whole of a CIDv0
+				metadata.IPFS = base58Encode(v)
+			case key == "bzzr0" || key == "bzzr1":
+				metadata.Swarm = hex.EncodeToString(v)
+			case key == "solc" && len(v) == 3:
+				metadata.Solc = fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+			}
+		case string:
+			if key == "solc" {
+				// Pre-release compilers write their full version as a string
+				metadata.Solc = v
+			}
+		}
+	}
+	if metadata.IPFS == "" && metadata.Swarm == "" {
+		return nil
+	}
+	return metadata
+}
+
+// cborDecoder reads the subset of CBOR that solc writes in the code metadata: a map of text keys
+// to byte strings, text strings, unsigned integers and booleans
+type cborDecoder struct {
+	data []byte
+}
+
+func (d *cborDecoder) readHead() (major byte, arg uint64, err error) {
+	if len(d.data) == 0 {
+		return 0, 0, fmt.Errorf("unexpected end of data")
+	}
+	major, info := d.data[0]>>5, d.data[0]&0x1f
+	d.data = d.data[1:]
+	if info < 24 {
+		return major, uint64(info), nil
+	}
+	if info > 27 {
+		return 0, 0, fmt.Errorf("unsupported additional info %d", info)
+	}
+	size := 1 << (info - 24)
+	if len(d.data) < size {
+		return 0, 0, fmt.Errorf("unexpected end of data")
+	}
+	for _, b := range d.data[:size] {
+		arg = arg<<8 | uint64(b)
+	}
+	d.data = d.data[size:]
+	return major, arg, nil
+}
+
+func (d *cborDecoder) readBytes(length uint64) ([]byte, error) {
+	if length > uint64(len(d.data)) {
+		return nil, fmt.Errorf("unexpected end of data")
+	}
+	b := d.data[:length]
+	d.data = d.data[length:]
+	return b, nil
+}
+
+func (d *cborDecoder) readMap() (map[string]interface{}, error) {
+	major, count, err := d.readHead()
+	if err != nil {
+		return nil, err
+	}
+	if major != 5 {
+		return nil, fmt.Errorf("major type %d is not a map", major)
+	}
+	entries := make(map[string]interface{})
+	for i := uint64(0); i < count; i++ {
+		major, arg, err := d.readHead()
+		if err != nil {
+			return nil, err
+		}
+		if major != 3 {
+			return nil, fmt.Errorf("major type %d is not a text key", major)
+		}
+		key, err := d.readBytes(arg)
+		if err != nil {
+			return nil, err
+		}
+		if major, arg, err = d.readHead(); err != nil {
+			return nil, err
+		}
+		var value interface{}
+		switch major {
+		case 0:
+			value = arg
+		case 2, 3:
+			b, err := d.readBytes(arg)
+			if err != nil {
+				return nil, err
+			}
+			value = b
+			if major == 3 {
+				value = string(b)
+			}
+		case 7:
+			if arg != 20 && arg != 21 {
+				return nil, fmt.Errorf("unsupported simple value %d", arg)
+			}
+			value = arg == 21
+		default:
+			return nil, fmt.Errorf("unsupported major type %d for '%s'", major, key)
+		}
+		entries[string(key)] = value
+	}
+	return entries, nil
+}
+
+// base58Encode encodes bytes with the Bitcoin alphabet, as used for IPFS CIDv0
+func base58Encode(b []byte) string {
+	var encoded []byte
+	n := new(big.Int).SetBytes(b)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+	// Each leading zero byte is written as the zero digit
+	for _, v := range b {
+		if v != 0 {
+			break
+		}
+		encoded = append(encoded, base58Alphabet[0])
+	}
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
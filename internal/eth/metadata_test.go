@@ -0,0 +1,104 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eth
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The metadata suffix written by solc 0.8.4, for a metadata JSON with the sha256 hash 44136f...
+const testCodeMetadataSuffix = "a264697066735822122044136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a64736f6c634300080400" + "33"
+
+func TestGetCode(t *testing.T) {
+	assert := assert.New(t)
+
+	rpc := &testRPCClient{
+		resultWrangler: func(result interface{}) {
+			*(result.(*string)) = "0x6080" + testCodeMetadataSuffix
+		},
+	}
+	code, err := GetCode(context.Background(), rpc, "2b8c0ecc76d0759a8f50b2e14a6881367d805832")
+	assert.NoError(err)
+	assert.Equal("0x6080"+testCodeMetadataSuffix, code)
+	assert.Equal("eth_getCode", rpc.capturedMethod)
+	assert.Equal([]interface{}{"0x2b8c0ecc76d0759a8f50b2e14a6881367d805832", "latest"}, rpc.capturedArgs)
+
+	rpc = &testRPCClient{mockError: fmt.Errorf("pop")}
+	_, err = GetCode(context.Background(), rpc, "2b8c0ecc76d0759a8f50b2e14a6881367d805832")
+	assert.Regexp("eth_getCode.*pop", err)
+}
+
+func TestParseCodeMetadataIPFS(t *testing.T) {
+	assert := assert.New(t)
+
+	metadata := ParseCodeMetadata("0x608060405260043610fe" + testCodeMetadataSuffix)
+	assert.Equal(&CodeMetadata{
+		IPFS: "QmSvPd3sHK7iWgZuW47fyLy4CaZQe2DwxvRhrJ39VpBVMK",
+		Solc: "0.8.4",
+	}, metadata)
+
+	// Pre-release compilers write the version as text, and add an experimental flag
+	metadata = ParseCodeMetadata("0x6080" +
+		"a3" +
+		"64697066735822122044136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a" +
+		"6c6578706572696d656e74616cf5" +
+		"64736f6c63" + "78" + "1e" + "302e382e352d6e696768746c792e323032312e352e342b636f6d6d69742e" +
+		"005d")
+	assert.Equal("QmSvPd3sHK7iWgZuW47fyLy4CaZQe2DwxvRhrJ39VpBVMK", metadata.IPFS)
+	assert.Equal("0.8.5-nightly.2021.5.4+commit.", metadata.Solc)
+}
+
+func TestParseCodeMetadataSwarm(t *testing.T) {
+	metadata := ParseCodeMetadata("0x6080" +
+		"a165627a7a72305820" + "ab8c0ecc76d0759a8f50b2e14a6881367d805832ab8c0ecc76d0759a8f50b2e1" + "0029")
+	assert.Equal(t, &CodeMetadata{Swarm: "ab8c0ecc76d0759a8f50b2e14a6881367d805832ab8c0ecc76d0759a8f50b2e1"}, metadata)
+}
+
+func TestParseCodeMetadataNone(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(ParseCodeMetadata("0x"))
+	assert.Nil(ParseCodeMetadata("0xzz"))
+	// An ERC-1167 clone has no metadata
+	assert.Nil(ParseCodeMetadata("363d3d373d3d3d363d73ab8c0ecc76d0759a8f50b2e14a6881367d8058325af43d82803e903d91602b57fd5bf3"))
+	// Length longer than the code
+	assert.Nil(ParseCodeMetadata("0xa1ffff"))
+	// Not a map
+	assert.Nil(ParseCodeMetadata("0x4100" + "0002"))
+	// Truncated map
+	assert.Nil(ParseCodeMetadata("0xa26469706673" + "0006"))
+	// A map without a hash
+	assert.Nil(ParseCodeMetadata("0xa164736f6c6343000804" + "000a"))
+	// An IPFS hash that is not a sha2-256 multihash
+	assert.Nil(ParseCodeMetadata("0xa1646970667343010203" + "000a"))
+	// Unsupported values
+	assert.Nil(ParseCodeMetadata("0xa1616180" + "0004"))
+	assert.Nil(ParseCodeMetadata("0xa16161f6" + "0004"))
+	assert.Nil(ParseCodeMetadata("0xa1010203" + "0004"))
+	assert.Nil(ParseCodeMetadata("0xa161611c" + "0004"))
+	assert.Nil(ParseCodeMetadata("0xa1616119" + "0004"))
+	// Trailing data after the map
+	assert.Nil(ParseCodeMetadata("0xa0" + "00" + "0002"))
+}
+
+func TestBase58Encode(t *testing.T) {
+	assert.Equal(t, "StV1DL6CwTryKyV", base58Encode([]byte("hello world")))
+	assert.Equal(t, "112", base58Encode([]byte{0, 0, 1}))
+	assert.Equal(t, "", base58Encode([]byte{}))
+}
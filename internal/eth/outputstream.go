@@ -0,0 +1,342 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eth
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+)
+
+const (
+	abiWordSize = 32
+	// outputStreamChunk is the number of bytes of a bytes or string output encoded at a time
+	outputStreamChunk = 4096
+)
+
+// outputStreamer decodes ABI encoded return data one value at a time, writing each as JSON
+type outputStreamer struct {
+	w    io.Writer
+	data []byte
+	err  error
+}
+
+// StreamOutputs writes the outputs of a method as a JSON object, in the same form as ProcessRLPBytes.
+// Values are decoded straight from the return data as they are written, so the outputs are never
+// held in memory in decoded form. An error part way through leaves the JSON incomplete, so the
+// return data can be checked first by streaming it to ioutil.Discard.
+func StreamOutputs(w io.Writer, args ethbinding.ABIArguments, retBytes []byte) error {
+	if len(args) == 0 {
+		return errors.Errorf(errors.UnpackOutputsMismatchNil, hex.EncodeToString(retBytes))
+	}
+	s := &outputStreamer{w: w, data: retBytes}
+	types := make([]*ethbinding.ABIType, len(args))
+	names := make([]string, len(args))
+	for i, arg := range args {
+		types[i] = &args[i].Type
+		// Match the naming of the outputs in ProcessRLPBytes
+		names[i] = arg.Name
+		if names[i] == "" {
+			names[i] = "output"
+			if i != 0 {
+				names[i] += strconv.Itoa(i)
+			}
+		}
+	}
+	if err := s.writeTuple("", types, names, 0); err != nil {
+		return err
+	}
+	return s.err
+}
+
+func (s *outputStreamer) write(b []byte) {
+	if s.err == nil {
+		_, s.err = s.w.Write(b)
+	}
+}
+
+func (s *outputStreamer) writeString(str string) {
+	s.write([]byte(str))
+}
+
+func (s *outputStreamer) word(argName string, pos uint64) ([]byte, error) {
+	if pos > uint64(len(s.data)) || uint64(len(s.data))-pos < abiWordSize {
+		return nil, errors.Errorf(errors.UnpackOutputsFailed, fmt.Sprintf("%s: data too short at offset %d", argName, pos))
+	}
+	return s.data[pos : pos+abiWordSize], nil
+}
+
+// length reads an offset or length, which must fit within the return data
+func (s *outputStreamer) length(argName string, pos uint64) (uint64, error) {
+	w, err := s.word(argName, pos)
+	if err != nil {
+		return 0, err
+	}
+	v := new(big.Int).SetBytes(w)
+	if !v.IsUint64() || v.Uint64() > uint64(len(s.data)) {
+		return 0, errors.Errorf(errors.UnpackOutputsFailed, fmt.Sprintf("%s: offset or length %s at offset %d is out of range", argName, v, pos))
+	}
+	return v.Uint64(), nil
+}
+
+func isDynamicType(t *ethbinding.ABIType) bool {
+	switch t.T {
+	case ethbinding.StringTy, ethbinding.BytesTy, ethbinding.SliceTy:
+		return true
+	case ethbinding.ArrayTy:
+		return isDynamicType(t.Elem)
+	case ethbinding.TupleTy:
+		for _, elem := range t.TupleElems {
+			if isDynamicType(elem) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// headSize is the space a value takes in the head of the enclosing tuple or array
+func headSize(t *ethbinding.ABIType) uint64 {
+	if isDynamicType(t) {
+		return abiWordSize
+	}
+	switch t.T {
+	case ethbinding.ArrayTy:
+		return uint64(t.Size) * headSize(t.Elem)
+	case ethbinding.TupleTy:
+		size := uint64(0)
+		for _, elem := range t.TupleElems {
+			size += headSize(elem)
+		}
+		return size
+	}
+	return abiWordSize
+}
+
+// valuePos returns the position of a value from its position in the head of the enclosing tuple or
+// array, following the offset for a dynamic value
+func (s *outputStreamer) valuePos(argName string, t *ethbinding.ABIType, base, headPos uint64) (uint64, error) {
+	if !isDynamicType(t) {
+		return headPos, nil
+	}
+	offset, err := s.length(argName, headPos)
+	if err != nil {
+		return 0, err
+	}
+	return base + offset, nil
+}
+
+// writeTuple writes a JSON object of the values of a tuple, with the keys in sorted order
+func (s *outputStreamer) writeTuple(argName string, types []*ethbinding.ABIType, names []string, base uint64) error {
+	positions := make([]uint64, len(types))
+	byName := make(map[string]int, len(types))
+	headPos := base
+	for i, t := range types {
+		positions[i] = headPos
+		headPos += headSize(t)
+		byName[names[i]] = i
+	}
+	sorted := make([]string, 0, len(byName))
+	for name := range byName {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	s.writeString("{")
+	for i, name := range sorted {
+		idx := byName[name]
+		fieldName := name
+		if argName != "" {
+			fieldName = argName + "." + name
+		}
+		pos, err := s.valuePos(fieldName, types[idx], base, positions[idx])
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			s.writeString(",")
+		}
+		key, _ := json.Marshal(name)
+		s.write(key)
+		s.writeString(":")
+		if err := s.writeValue(fieldName, types[idx], pos); err != nil {
+			return err
+		}
+	}
+	s.writeString("}")
+	return nil
+}
+
+func (s *outputStreamer) writeArray(argName string, t *ethbinding.ABIType, pos uint64) error {
+	count := uint64(t.Size)
+	if t.T == ethbinding.SliceTy {
+		var err error
+		if count, err = s.length(argName, pos); err != nil {
+			return err
+		}
+		pos += abiWordSize
+	}
+	elemSize := headSize(t.Elem)
+	if count > 0 && (uint64(len(s.data)) < pos || (uint64(len(s.data))-pos)/count < elemSize) {
+		return errors.Errorf(errors.UnpackOutputsFailed, fmt.Sprintf("%s: data too short for %d elements", argName, count))
+	}
+	s.writeString("[")
+	for i := uint64(0); i < count; i++ {
+		elemName := fmt.Sprintf("%s[%d]", argName, i)
+		elemPos, err := s.valuePos(elemName, t.Elem, pos, pos+i*elemSize)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			s.writeString(",")
+		}
+		if err := s.writeValue(elemName, t.Elem, elemPos); err != nil {
+			return err
+		}
+	}
+	s.writeString("]")
+	return s.err
+}
+
+func (s *outputStreamer) writeValue(argName string, t *ethbinding.ABIType, pos uint64) error {
+	switch t.T {
+	case ethbinding.IntTy, ethbinding.UintTy:
+		w, err := s.word(argName, pos)
+		if err != nil {
+			return err
+		}
+		v := new(big.Int).SetBytes(w)
+		if t.T == ethbinding.IntTy && w[0]&0x80 != 0 {
+			v.Sub(v, new(big.Int).Lsh(big.NewInt(1), abiWordSize*8))
+		}
+		if !integerFits(v, t.T == ethbinding.IntTy, t.Size) {
+			return errors.Errorf(errors.UnpackOutputsFailed, fmt.Sprintf("%s: %s does not fit in %s", argName, v, t))
+		}
+		s.writeString(`"` + v.String() + `"`)
+	case ethbinding.BoolTy:
+		w, err := s.word(argName, pos)
+		if err != nil {
+			return err
+		}
+		for _, b := range w[:abiWordSize-1] {
+			if b != 0 {
+				return errors.Errorf(errors.UnpackOutputsFailed, fmt.Sprintf("%s: improperly encoded boolean", argName))
+			}
+		}
+		switch w[abiWordSize-1] {
+		case 0:
+			s.writeString("false")
+		case 1:
+			s.writeString("true")
+		default:
+			return errors.Errorf(errors.UnpackOutputsFailed, fmt.Sprintf("%s: improperly encoded boolean", argName))
+		}
+	case ethbinding.AddressTy:
+		w, err := s.word(argName, pos)
+		if err != nil {
+			return err
+		}
+		s.writeHex(w[abiWordSize-20:])
+	case ethbinding.FixedBytesTy:
+		w, err := s.word(argName, pos)
+		if err != nil {
+			return err
+		}
+		s.writeHex(w[:t.Size])
+	case ethbinding.BytesTy, ethbinding.StringTy:
+		b, err := s.dynamicBytes(argName, pos)
+		if err != nil {
+			return err
+		}
+		if t.T == ethbinding.BytesTy {
+			s.writeHex(b)
+		} else {
+			s.writeJSONString(b)
+		}
+	case ethbinding.SliceTy, ethbinding.ArrayTy:
+		return s.writeArray(argName, t, pos)
+	case ethbinding.TupleTy:
+		return s.writeTuple(argName, t.TupleElems, t.TupleRawNames, pos)
+	default:
+		return errors.Errorf(errors.UnpackOutputsUnknownType, argName, t.String(), "return data")
+	}
+	return s.err
+}
+
+// integerFits checks a decoded integer is in the range of its ABI type
+func integerFits(v *big.Int, signed bool, size int) bool {
+	if !signed {
+		return v.BitLen() <= size
+	}
+	if v.Sign() < 0 {
+		// -2^(size-1) is the smallest value, and needs one more bit than the largest
+		return new(big.Int).Add(v, big.NewInt(1)).BitLen() <= size-1
+	}
+	return v.BitLen() <= size-1
+}
+
+func (s *outputStreamer) dynamicBytes(argName string, pos uint64) ([]byte, error) {
+	length, err := s.length(argName, pos)
+	if err != nil {
+		return nil, err
+	}
+	start := pos + abiWordSize
+	if start > uint64(len(s.data)) || uint64(len(s.data))-start < length {
+		return nil, errors.Errorf(errors.UnpackOutputsFailed, fmt.Sprintf("%s: data too short for %d bytes", argName, length))
+	}
+	return s.data[start : start+length], nil
+}
+
+// writeHex writes bytes as a 0x prefixed hex JSON string, a chunk at a time
+func (s *outputStreamer) writeHex(b []byte) {
+	s.writeString(`"0x`)
+	buf := make([]byte, hex.EncodedLen(outputStreamChunk))
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > outputStreamChunk {
+			chunk = chunk[:outputStreamChunk]
+		}
+		n := hex.Encode(buf, chunk)
+		s.write(buf[:n])
+		b = b[len(chunk):]
+	}
+	s.writeString(`"`)
+}
+
+// writeJSONString writes a string as JSON a chunk at a time, splitting only between characters
+func (s *outputStreamer) writeJSONString(b []byte) {
+	s.writeString(`"`)
+	for len(b) > 0 {
+		end := len(b)
+		if end > outputStreamChunk {
+			end = outputStreamChunk
+			for back := 0; back < utf8.UTFMax && end > 1 && !utf8.RuneStart(b[end]); back++ {
+				end--
+			}
+		}
+		escaped, _ := json.Marshal(string(b[:end]))
+		s.write(escaped[1 : len(escaped)-1])
+		b = b[end:]
+	}
+	s.writeString(`"`)
+}
@@ -0,0 +1,221 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eth
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func assertStreamMatchesProcessed(t *testing.T, args ethbinding.ABIArguments, retBytes []byte) string {
+	var buf bytes.Buffer
+	err := StreamOutputs(&buf, args, retBytes)
+	assert.NoError(t, err)
+	expected, _ := json.Marshal(ProcessRLPBytes(args, retBytes))
+	assert.JSONEq(t, string(expected), buf.String())
+	return buf.String()
+}
+
+func TestStreamOutputsValidTypes(t *testing.T) {
+	assert := assert.New(t)
+
+	t1, _ := ethbind.API.ABITypeFor("string")
+	t2, _ := ethbind.API.ABITypeFor("int256[]")
+	t3, _ := ethbind.API.ABITypeFor("bool")
+	t4, _ := ethbind.API.ABITypeFor("bytes1")
+	t5, _ := ethbind.API.ABITypeFor("address")
+	t6, _ := ethbind.API.ABITypeFor("bytes")
+	t7, _ := ethbind.API.ABITypeFor("uint256")
+	t8, _ := ethbind.API.ABITypeFor("string[]")
+	args := ethbinding.ABIArguments{
+		{Name: "retval1", Type: t1},
+		{Name: "retval2", Type: t2},
+		{Name: "retval3", Type: t3},
+		{Name: "retval4", Type: t4},
+		{Name: "retval5", Type: t5},
+		{Name: "", Type: t6},
+		{Name: "retval7", Type: t7},
+		{Name: "retval8", Type: t8},
+	}
+	retBytes, err := args.Pack(
+		"string \"1\"\n",
+		[]*big.Int{big.NewInt(123), big.NewInt(-456)},
+		true,
+		[1]byte{18},
+		[20]byte{18, 18, 18, 18, 18, 18, 18, 18, 18, 18, 18, 18, 18, 18, 18, 18, 18, 18, 18, 18},
+		[]byte{0xfe, 0xed, 0xbe, 0xef},
+		new(big.Int).Lsh(big.NewInt(1), 255),
+		[]string{"a", "", "c"},
+	)
+	assert.NoError(err)
+
+	streamed := assertStreamMatchesProcessed(t, args, retBytes)
+	assert.Contains(streamed, `"output5":"0xfeedbeef"`)
+}
+
+func TestStreamOutputsFixedArrays(t *testing.T) {
+	assert := assert.New(t)
+
+	t1, _ := ethbind.API.ABITypeFor("int32[2]")
+	t2, _ := ethbind.API.ABITypeFor("string[2]")
+	t3, _ := ethbind.API.ABITypeFor("bool")
+	args := ethbinding.ABIArguments{{Name: "ints", Type: t1}, {Name: "strs", Type: t2}, {Name: "flag", Type: t3}}
+	retBytes, err := args.Pack([2]int32{-2147483648, 2147483647}, [2]string{"a", "b"}, true)
+	assert.NoError(err)
+
+	var buf bytes.Buffer
+	err = StreamOutputs(&buf, args, retBytes)
+	assert.NoError(err)
+	assert.Equal(`{"flag":true,"ints":["-2147483648","2147483647"],"strs":["a","b"]}`, buf.String())
+}
+
+func TestStreamOutputsV2ABIEncodedStructs(t *testing.T) {
+	assert := assert.New(t)
+
+	var v2abi ethbinding.ABI
+	testABIInput, err := ioutil.ReadFile("../../test/abicoderv2_example.abi.json")
+	assert.NoError(err)
+	err = json.Unmarshal(testABIInput, &v2abi)
+	assert.NoError(err)
+	abiMethod := v2abi.Methods["inOutType1"]
+
+	input1Map := map[string]interface{}{
+		"str1": "test1",
+		"val1": "12345",
+		"nested": map[string]interface{}{
+			"str1":      "test2",
+			"str2":      "test3",
+			"addr1":     "0x1212121212121212121212121212121212121212",
+			"bytearray": "0xfeedbeef",
+		},
+		"nestarray": []interface{}{
+			map[string]interface{}{
+				"str1":      "test4",
+				"str2":      "test5",
+				"addr1":     "0x2121212121212121212121212121212121212121",
+				"bytearray": "0x01010101",
+			},
+			map[string]interface{}{
+				"str1":      "test6",
+				"str2":      "",
+				"addr1":     "0x3131313131313131313131313131313131313131",
+				"bytearray": "0x",
+			},
+		},
+	}
+	tx := Txn{}
+	typedArgs, err := tx.generateTypedArgs([]interface{}{input1Map}, &abiMethod)
+	assert.NoError(err)
+	retBytes, err := abiMethod.Inputs.Pack(typedArgs...)
+	assert.NoError(err)
+
+	streamed := assertStreamMatchesProcessed(t, abiMethod.Outputs, retBytes)
+	var res map[string]interface{}
+	_ = json.Unmarshal([]byte(streamed), &res)
+	assert.Equal(input1Map, res["out1"])
+}
+
+func TestStreamOutputsLargeValues(t *testing.T) {
+	assert := assert.New(t)
+
+	t1, _ := ethbind.API.ABITypeFor("string")
+	t2, _ := ethbind.API.ABITypeFor("bytes")
+	t3, _ := ethbind.API.ABITypeFor("uint256[]")
+	args := ethbinding.ABIArguments{{Name: "s", Type: t1}, {Name: "b", Type: t2}, {Name: "a", Type: t3}}
+	// Multi-byte characters land across the chunk boundaries
+	largeString := strings.Repeat("ab€\"", outputStreamChunk)
+	largeBytes := bytes.Repeat([]byte{0x01, 0xab}, outputStreamChunk*3)
+	largeArray := make([]*big.Int, 10000)
+	for i := range largeArray {
+		largeArray[i] = big.NewInt(int64(i))
+	}
+	retBytes, err := args.Pack(largeString, largeBytes, largeArray)
+	assert.NoError(err)
+
+	streamed := assertStreamMatchesProcessed(t, args, retBytes)
+	var res map[string]interface{}
+	_ = json.Unmarshal([]byte(streamed), &res)
+	assert.Equal(largeString, res["s"])
+	assert.Len(res["a"], 10000)
+}
+
+func TestStreamOutputsNoOutputs(t *testing.T) {
+	err := StreamOutputs(ioutil.Discard, ethbinding.ABIArguments{}, []byte{0x01})
+	assert.Regexp(t, "FFEC100187", err)
+}
+
+func TestStreamOutputsBadData(t *testing.T) {
+	assert := assert.New(t)
+
+	word := func(hexStr string) string {
+		return strings.Repeat("0", 64-len(hexStr)) + hexStr
+	}
+	for _, test := range []struct {
+		abiType  string
+		retBytes string
+		err      string
+	}{
+		{"uint256", "", "data too short"},
+		{"uint8", word("100"), "does not fit in uint8"},
+		{"int8", word("80"), "does not fit in int8"},
+		{"bool", word("2"), "improperly encoded boolean"},
+		{"bool", "01" + word("1")[2:], "improperly encoded boolean"},
+		{"string", word("1000"), "out of range"},
+		{"string", word("20") + word("40"), "data too short for 64 bytes"},
+		{"bytes", word("20") + word("ffffffffffffffffffffffffffffffff"), "out of range"},
+		{"uint256[]", word("20") + word("4"), "data too short for 4 elements"},
+		{"string[]", word("20") + word("1") + word("ffff"), "out of range"},
+		{"uint256[2]", word("1"), "data too short for 2 elements"},
+	} {
+		abiType, err := ethbind.API.ABITypeFor(test.abiType)
+		assert.NoError(err)
+		retBytes, _ := hex.DecodeString(test.retBytes)
+		args := ethbinding.ABIArguments{{Name: "retval", Type: abiType}}
+		err = StreamOutputs(ioutil.Discard, args, retBytes)
+		assert.Regexp(test.err, err, test.abiType)
+	}
+}
+
+func TestStreamOutputsUnsupportedType(t *testing.T) {
+	assert := assert.New(t)
+
+	args := ethbinding.ABIArguments{{Name: "retval", Type: ethbinding.ABIType{T: 255}}}
+	err := StreamOutputs(ioutil.Discard, args, make([]byte, 32))
+	assert.Regexp("FFEC100189", err)
+}
+
+type failingWriter struct{}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	return 0, io.ErrShortWrite
+}
+
+func TestStreamOutputsWriteFail(t *testing.T) {
+	t1, _ := ethbind.API.ABITypeFor("uint256")
+	args := ethbinding.ABIArguments{{Name: "retval", Type: t1}}
+	retBytes, _ := args.Pack(big.NewInt(1))
+	err := StreamOutputs(&failingWriter{}, args, retBytes)
+	assert.Equal(t, io.ErrShortWrite, err)
+}
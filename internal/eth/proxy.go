@@ -0,0 +1,134 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// EIP1967ImplementationSlot is the storage slot that holds the implementation address of an
+	// EIP-1967 proxy, bytes32(uint256(keccak256('eip1967.proxy.implementation')) - 1)
+	EIP1967ImplementationSlot = "0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc"
+	// EIP1967UpgradedTopic is the signature of the Upgraded(address) event, emitted by an
+	// EIP-1967 proxy when its implementation changes
+	EIP1967UpgradedTopic = "0xbc7cd75a20ee27fd9adebab32041f755214dbc6bffa90cc0225b39da2e5c2d3b"
+	// EIP1822ProxiableSlot is the storage slot that holds the implementation address of an
+	// EIP-1822 (UUPS) proxy, keccak256('PROXIABLE')
+	EIP1822ProxiableSlot = "0xc5f16f0fcc639fa48a6947836d9850f504798523bf8c9a3a87d5876cf622bcf7"
+
+	// ProxyStandardEIP1967 identifies a proxy found from its EIP-1967 implementation slot
+	ProxyStandardEIP1967 = "EIP-1967"
+	// ProxyStandardEIP1822 identifies a proxy found from its EIP-1822 PROXIABLE slot
+	ProxyStandardEIP1822 = "EIP-1822"
+	// ProxyStandardERC1167 identifies a minimal proxy (clone), found from its runtime bytecode
+	ProxyStandardERC1167 = "ERC-1167"
+)
+
+// The runtime bytecode of an ERC-1167 minimal proxy is the implementation address wrapped in
+// a fixed prefix and suffix
+const (
+	erc1167CodePrefix = "363d3d373d3d3d363d73"
+	erc1167CodeSuffix = "5af43d82803e903d91602b57fd5bf3"
+)
+
+const zeroAddressHexNo0x = "0000000000000000000000000000000000000000"
+
+// GetEIP1967Implementation reads the implementation slot of a contract, and returns the
+// implementation address in lower case without a 0x prefix. An empty string is returned
+// if the slot is empty, as the contract is not an EIP-1967 proxy.
+func GetEIP1967Implementation(ctx context.Context, rpc RPCClient, addrHexNo0x string) (string, error) {
+	return getStorageAddress(ctx, rpc, addrHexNo0x, EIP1967ImplementationSlot, "implementation")
+}
+
+// GetProxyImplementation checks the EIP-1967 implementation slot of a contract, then the
+// EIP-1822 PROXIABLE slot, then whether its code is an ERC-1167 minimal proxy. It returns the
+// standard of the first match along with the implementation address. Empty strings are returned
+// if the contract is not a proxy.
+func GetProxyImplementation(ctx context.Context, rpc RPCClient, addrHexNo0x string) (standard string, impl string, err error) {
+	if impl, err = GetEIP1967Implementation(ctx, rpc, addrHexNo0x); err != nil || impl != "" {
+		return ProxyStandardEIP1967, impl, err
+	}
+	if impl, err = getStorageAddress(ctx, rpc, addrHexNo0x, EIP1822ProxiableSlot, "proxiable"); err != nil || impl != "" {
+		return ProxyStandardEIP1822, impl, err
+	}
+	if impl, err = GetERC1167Implementation(ctx, rpc, addrHexNo0x); err != nil || impl != "" {
+		return ProxyStandardERC1167, impl, err
+	}
+	return "", "", nil
+}
+
+// GetERC1167Implementation reads the code of a contract, and returns the implementation address
+// embedded in it if it is an ERC-1167 minimal proxy. An empty string is returned for any other code.
+func GetERC1167Implementation(ctx context.Context, rpc RPCClient, addrHexNo0x string) (string, error) {
+	code, err := GetCode(ctx, rpc, addrHexNo0x)
+	if err != nil {
+		return "", err
+	}
+	impl := ERC1167ImplementationFromCode(code)
+	log.Debugf("Code of %s minimal proxy implementation=%s", addrHexNo0x, impl)
+	return impl, nil
+}
+
+// ERC1167ImplementationFromCode returns the implementation address from the hex encoded runtime
+// bytecode of an ERC-1167 minimal proxy, or an empty string if the code is not a minimal proxy
+func ERC1167ImplementationFromCode(code string) string {
+	code = strings.TrimPrefix(strings.ToLower(code), "0x")
+	if len(code) != len(erc1167CodePrefix)+40+len(erc1167CodeSuffix) ||
+		!strings.HasPrefix(code, erc1167CodePrefix) || !strings.HasSuffix(code, erc1167CodeSuffix) {
+		return ""
+	}
+	impl := code[len(erc1167CodePrefix) : len(erc1167CodePrefix)+40]
+	if impl == zeroAddressHexNo0x {
+		return ""
+	}
+	return impl
+}
+
+func getStorageAddress(ctx context.Context, rpc RPCClient, addrHexNo0x, slot, slotName string) (string, error) {
+	start := time.Now().UTC()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var word string
+	if err := rpc.CallContext(ctx, &word, "eth_getStorageAt", "0x"+addrHexNo0x, slot, "latest"); err != nil {
+		return "", errors.Errorf(errors.RPCCallReturnedError, "eth_getStorageAt", err)
+	}
+	impl := AddressFromWord(word)
+	callTime := time.Now().UTC().Sub(start)
+	log.Debugf("eth_getStorageAt(%s,%s)=%s [%.2fs]", addrHexNo0x, slotName, impl, callTime.Seconds())
+	return impl, nil
+}
+
+// AddressFromWord extracts an address from the low 20 bytes of a hex encoded 32 byte word,
+// such as a storage slot or an indexed event topic. An empty string is returned for the
+// zero address.
+func AddressFromWord(word string) string {
+	word = strings.TrimPrefix(strings.ToLower(word), "0x")
+	if len(word) < 40 {
+		word = strings.Repeat("0", 40-len(word)) + word
+	}
+	addr := word[len(word)-40:]
+	if addr == zeroAddressHexNo0x {
+		return ""
+	}
+	return addr
+}
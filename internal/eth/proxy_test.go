@@ -0,0 +1,163 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eth
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetEIP1967Implementation(t *testing.T) {
+	assert := assert.New(t)
+
+	rpc := &testRPCClient{
+		resultWrangler: func(result interface{}) {
+			*(result.(*string)) = "0x000000000000000000000000AB8C0ECC76D0759A8F50B2E14A6881367D805832"
+		},
+	}
+	impl, err := GetEIP1967Implementation(context.Background(), rpc, "2b8c0ecc76d0759a8f50b2e14a6881367d805832")
+	assert.NoError(err)
+	assert.Equal("ab8c0ecc76d0759a8f50b2e14a6881367d805832", impl)
+	assert.Equal("eth_getStorageAt", rpc.capturedMethod)
+	assert.Equal([]interface{}{"0x2b8c0ecc76d0759a8f50b2e14a6881367d805832", EIP1967ImplementationSlot, "latest"}, rpc.capturedArgs)
+}
+
+func TestGetEIP1967ImplementationNotProxy(t *testing.T) {
+	assert := assert.New(t)
+
+	rpc := &testRPCClient{
+		resultWrangler: func(result interface{}) {
+			*(result.(*string)) = "0x0000000000000000000000000000000000000000000000000000000000000000"
+		},
+	}
+	impl, err := GetEIP1967Implementation(context.Background(), rpc, "2b8c0ecc76d0759a8f50b2e14a6881367d805832")
+	assert.NoError(err)
+	assert.Empty(impl)
+}
+
+func TestGetEIP1967ImplementationFail(t *testing.T) {
+	rpc := &testRPCClient{mockError: fmt.Errorf("pop")}
+	_, err := GetEIP1967Implementation(context.Background(), rpc, "2b8c0ecc76d0759a8f50b2e14a6881367d805832")
+	assert.Regexp(t, "eth_getStorageAt.*pop", err)
+}
+
+func TestGetProxyImplementationEIP1967(t *testing.T) {
+	assert := assert.New(t)
+
+	rpc := &testRPCClient{
+		resultWrangler: func(result interface{}) {
+			*(result.(*string)) = "0x000000000000000000000000ab8c0ecc76d0759a8f50b2e14a6881367d805832"
+		},
+	}
+	standard, impl, err := GetProxyImplementation(context.Background(), rpc, "2b8c0ecc76d0759a8f50b2e14a6881367d805832")
+	assert.NoError(err)
+	assert.Equal(ProxyStandardEIP1967, standard)
+	assert.Equal("ab8c0ecc76d0759a8f50b2e14a6881367d805832", impl)
+	assert.Empty(rpc.capturedMethod2)
+}
+
+func TestGetProxyImplementationEIP1822(t *testing.T) {
+	assert := assert.New(t)
+
+	rpc := &testRPCClient{}
+	rpc.resultWrangler = func(result interface{}) {
+		if rpc.capturedMethod2 != "" {
+			*(result.(*string)) = "0x000000000000000000000000ab8c0ecc76d0759a8f50b2e14a6881367d805832"
+		} else {
+			*(result.(*string)) = "0x0"
+		}
+	}
+	standard, impl, err := GetProxyImplementation(context.Background(), rpc, "2b8c0ecc76d0759a8f50b2e14a6881367d805832")
+	assert.NoError(err)
+	assert.Equal(ProxyStandardEIP1822, standard)
+	assert.Equal("ab8c0ecc76d0759a8f50b2e14a6881367d805832", impl)
+	assert.Equal([]interface{}{"0x2b8c0ecc76d0759a8f50b2e14a6881367d805832", EIP1822ProxiableSlot, "latest"}, rpc.capturedArgs2)
+}
+
+func TestGetProxyImplementationNotProxy(t *testing.T) {
+	assert := assert.New(t)
+
+	rpc := &testRPCClient{
+		resultWrangler: func(result interface{}) {
+			*(result.(*string)) = "0x0"
+		},
+	}
+	standard, impl, err := GetProxyImplementation(context.Background(), rpc, "2b8c0ecc76d0759a8f50b2e14a6881367d805832")
+	assert.NoError(err)
+	assert.Empty(standard)
+	assert.Empty(impl)
+}
+
+func TestGetProxyImplementationFail(t *testing.T) {
+	rpc := &testRPCClient{
+		resultWrangler: func(result interface{}) {
+			*(result.(*string)) = "0x0"
+		},
+		mockError2: fmt.Errorf("pop"),
+	}
+	_, _, err := GetProxyImplementation(context.Background(), rpc, "2b8c0ecc76d0759a8f50b2e14a6881367d805832")
+	assert.Regexp(t, "eth_getStorageAt.*pop", err)
+}
+
+func TestAddressFromWord(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("2b8c0ecc76d0759a8f50b2e14a6881367d805832", AddressFromWord("0x2b8c0ECc76d0759a8F50b2E14A6881367D805832"))
+	assert.Equal("00000000000000000000000000000000000000ff", AddressFromWord("0xff"))
+	assert.Equal("", AddressFromWord("0x0"))
+	assert.Equal("", AddressFromWord(""))
+}
+
+func TestGetProxyImplementationERC1167(t *testing.T) {
+	assert := assert.New(t)
+
+	calls := 0
+	rpc := &testRPCClient{}
+	rpc.resultWrangler = func(result interface{}) {
+		calls++
+		if calls < 3 {
+			*(result.(*string)) = "0x0"
+		} else {
+			*(result.(*string)) = "0x363d3d373d3d3d363d73AB8C0ECC76D0759A8F50B2E14A6881367D8058325af43d82803e903d91602b57fd5bf3"
+		}
+	}
+	standard, impl, err := GetProxyImplementation(context.Background(), rpc, "2b8c0ecc76d0759a8f50b2e14a6881367d805832")
+	assert.NoError(err)
+	assert.Equal(ProxyStandardERC1167, standard)
+	assert.Equal("ab8c0ecc76d0759a8f50b2e14a6881367d805832", impl)
+	assert.Equal("eth_getCode", rpc.capturedMethod2)
+	assert.Equal([]interface{}{"0x2b8c0ecc76d0759a8f50b2e14a6881367d805832", "latest"}, rpc.capturedArgs2)
+}
+
+func TestGetERC1167ImplementationFail(t *testing.T) {
+	rpc := &testRPCClient{
+		mockError: fmt.Errorf("pop"),
+	}
+	_, err := GetERC1167Implementation(context.Background(), rpc, "2b8c0ecc76d0759a8f50b2e14a6881367d805832")
+	assert.Regexp(t, "eth_getCode.*pop", err)
+}
+
+func TestERC1167ImplementationFromCode(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("ab8c0ecc76d0759a8f50b2e14a6881367d805832",
+		ERC1167ImplementationFromCode("363d3d373d3d3d363d73ab8c0ecc76d0759a8f50b2e14a6881367d8058325af43d82803e903d91602b57fd5bf3"))
+	assert.Empty(ERC1167ImplementationFromCode("0x"))
+	assert.Empty(ERC1167ImplementationFromCode("0x608060405234801561001057600080fd5b50"))
+	assert.Empty(ERC1167ImplementationFromCode("0x363d3d373d3d3d363d7300000000000000000000000000000000000000005af43d82803e903d91602b57fd5bf3"))
+	assert.Empty(ERC1167ImplementationFromCode("0x363d3d373d3d3d363d73ab8c0ecc76d0759a8f50b2e14a6881367d8058325af43d82803e903d91602b57fd5bf300"))
+}
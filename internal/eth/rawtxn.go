@@ -0,0 +1,113 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eth
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	log "github.com/sirupsen/logrus"
+)
+
+// RawTxn is a transaction signed outside of ethconnect, decoded so it can be checked
+// before it is passed to the node
+type RawTxn struct {
+	Raw   []byte
+	EthTX *ethbinding.Transaction
+	From  *ethbinding.Address
+}
+
+// DecodeRawTransaction decodes a hex encoded signed transaction, of any type.
+// Legacy transactions must be EIP-155 signed, as otherwise they are valid on every chain.
+func DecodeRawTransaction(rawHex string) (*RawTxn, error) {
+	raw, err := ethbind.API.HexDecode(rawHex)
+	if err != nil {
+		return nil, errors.Errorf(errors.RawTxnInvalid, err)
+	}
+	tx := new(ethbinding.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return nil, errors.Errorf(errors.RawTxnInvalid, err)
+	}
+	if !tx.Protected() {
+		return nil, errors.Errorf(errors.RawTxnNotReplayProtected)
+	}
+	return &RawTxn{Raw: raw, EthTX: tx}, nil
+}
+
+// GetChainID gets the chain ID of the node
+func GetChainID(ctx context.Context, rpc RPCClient) (*big.Int, error) {
+	var chainID ethbinding.HexBigInt
+	if err := rpc.CallContext(ctx, &chainID, "eth_chainId"); err != nil {
+		return nil, errors.Errorf(errors.RPCCallReturnedError, "eth_chainId", err)
+	}
+	return chainID.ToInt(), nil
+}
+
+// Check verifies the transaction is signed for the chain the node is on, and that its nonce
+// is not already used by a mined or pending transaction from the same signer.
+// The signer is only recovered from legacy transactions. For typed transactions the nonce
+// is checked against the supplied from address, if there is one.
+func (r *RawTxn) Check(ctx context.Context, rpc RPCClient, from string) error {
+	chainID, err := GetChainID(ctx, rpc)
+	if err != nil {
+		return err
+	}
+	if r.EthTX.ChainId().Cmp(chainID) != 0 {
+		return errors.Errorf(errors.RawTxnChainIDMismatch, r.EthTX.ChainId(), chainID)
+	}
+
+	if r.EthTX.Type() == 0 {
+		sender, err := ethbind.API.NewEIP155Signer(chainID).Sender(r.EthTX)
+		if err != nil {
+			return errors.Errorf(errors.RawTxnInvalidSignature, err)
+		}
+		if from != "" && !strings.EqualFold(from, sender.Hex()) {
+			return errors.Errorf(errors.RawTxnSenderMismatch, sender.Hex(), from)
+		}
+		r.From = &sender
+	} else if from != "" {
+		if !ethbind.API.IsHexAddress(from) {
+			return errors.Errorf(errors.HelperStrToAddressBadAddress, "from")
+		}
+		addr := ethbind.API.HexToAddress(from)
+		r.From = &addr
+	}
+
+	if r.From == nil {
+		log.Warnf("Unable to check the nonce of raw transaction %s without a from address", r.EthTX.Hash().Hex())
+		return nil
+	}
+	nextNonce, err := GetTransactionCount(ctx, rpc, r.From, "pending")
+	if err != nil {
+		return err
+	}
+	if r.EthTX.Nonce() < uint64(nextNonce) {
+		return errors.Errorf(errors.RawTxnNonceUsed, r.EthTX.Nonce(), r.From.Hex(), nextNonce)
+	}
+	return nil
+}
+
+// Send passes the transaction to the node with eth_sendRawTransaction, returning the transaction hash
+func (r *RawTxn) Send(ctx context.Context, rpc RPCClient) (string, error) {
+	var txHash string
+	if err := rpc.CallContext(ctx, &txHash, "eth_sendRawTransaction", ethbind.API.HexEncode(r.Raw)); err != nil {
+		return "", errors.Errorf(errors.RPCCallReturnedError, "eth_sendRawTransaction", err)
+	}
+	return txHash, nil
+}
@@ -0,0 +1,165 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eth
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+// EIP-1559 transaction with nonce 5 on chain 1337, signed by 0x2c7536E3605D9C16a7a3D7b1898e529396a65c23
+const testDynamicFeeRawTx = "0x02f8648205390501028252089433333333333333333333333333333333333333330180c080a07b62bd71b7fc2fcc65a4a71fd4dcfd414cd7e47581f14c95e9fef482ca116224a03f43961b20e383510043271e99a1d1e7fe9f269f7262516fddf0c4ac3396f36c"
+
+// rawTxnTestRPC answers the calls made while checking a raw transaction
+type rawTxnTestRPC struct {
+	chainID   int64
+	nonce     uint64
+	errMethod string
+	calls     []string
+}
+
+func (r *rawTxnTestRPC) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	r.calls = append(r.calls, method)
+	if method == r.errMethod {
+		return fmt.Errorf("pop")
+	}
+	switch method {
+	case "eth_chainId":
+		*(result.(*ethbinding.HexBigInt)) = ethbinding.HexBigInt(*big.NewInt(r.chainID))
+	case "eth_getTransactionCount":
+		*(result.(*ethbinding.HexUint64)) = ethbinding.HexUint64(r.nonce)
+	case "eth_sendRawTransaction":
+		*(result.(*string)) = "0xab"
+	}
+	return nil
+}
+
+func signTestLegacyTx(t *testing.T, nonce uint64, chainID int64) (string, ethbinding.Address) {
+	key, err := ethbind.API.GenerateKey()
+	assert.NoError(t, err)
+	tx := ethbind.API.NewTransaction(nonce, ethbind.API.HexToAddress("0x3333333333333333333333333333333333333333"), big.NewInt(0), 21000, big.NewInt(0), nil)
+	signed, err := ethbind.API.SignTx(tx, ethbind.API.NewEIP155Signer(big.NewInt(chainID)), key)
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	assert.NoError(t, signed.EncodeRLP(&buf))
+	return ethbind.API.HexEncode(buf.Bytes()), ethbind.API.PubkeyToAddress(key.PublicKey)
+}
+
+func TestRawTxnLegacyCheckAndSend(t *testing.T) {
+	assert := assert.New(t)
+
+	rawHex, from := signTestLegacyTx(t, 10, 1337)
+	tx, err := DecodeRawTransaction(rawHex)
+	assert.NoError(err)
+
+	rpc := &rawTxnTestRPC{chainID: 1337, nonce: 10}
+	err = tx.Check(context.Background(), rpc, "")
+	assert.NoError(err)
+	assert.Equal(from, *tx.From)
+
+	txHash, err := tx.Send(context.Background(), rpc)
+	assert.NoError(err)
+	assert.Equal("0xab", txHash)
+	assert.Equal([]string{"eth_chainId", "eth_getTransactionCount", "eth_sendRawTransaction"}, rpc.calls)
+
+	// A replay once the nonce is used, whether pending or mined
+	rpc.nonce = 11
+	err = tx.Check(context.Background(), rpc, from.Hex())
+	assert.Regexp("FFEC100304.*Nonce 10.*next nonce is 11", err)
+}
+
+func TestRawTxnLegacyWrongChain(t *testing.T) {
+	assert := assert.New(t)
+
+	rawHex, _ := signTestLegacyTx(t, 0, 1)
+	tx, err := DecodeRawTransaction(rawHex)
+	assert.NoError(err)
+	err = tx.Check(context.Background(), &rawTxnTestRPC{chainID: 1337}, "")
+	assert.Regexp("FFEC100301.*chain ID 1, but the node is on chain ID 1337", err)
+}
+
+func TestRawTxnLegacySenderMismatch(t *testing.T) {
+	assert := assert.New(t)
+
+	rawHex, _ := signTestLegacyTx(t, 0, 1337)
+	tx, err := DecodeRawTransaction(rawHex)
+	assert.NoError(err)
+	err = tx.Check(context.Background(), &rawTxnTestRPC{chainID: 1337}, "0x3333333333333333333333333333333333333333")
+	assert.Regexp("FFEC100303", err)
+}
+
+func TestRawTxnNotReplayProtected(t *testing.T) {
+	assert := assert.New(t)
+
+	// Signing with a zero chain ID gives a pre-EIP-155 signature
+	rawHex, _ := signTestLegacyTx(t, 0, 0)
+	_, err := DecodeRawTransaction(rawHex)
+	assert.Regexp("FFEC100300", err)
+}
+
+func TestRawTxnDynamicFee(t *testing.T) {
+	assert := assert.New(t)
+
+	tx, err := DecodeRawTransaction(testDynamicFeeRawTx)
+	assert.NoError(err)
+	assert.Equal(uint64(5), tx.EthTX.Nonce())
+
+	// Without a from address the nonce cannot be checked
+	rpc := &rawTxnTestRPC{chainID: 1337, nonce: 6}
+	err = tx.Check(context.Background(), rpc, "")
+	assert.NoError(err)
+	assert.Equal([]string{"eth_chainId"}, rpc.calls)
+
+	err = tx.Check(context.Background(), rpc, "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23")
+	assert.Regexp("FFEC100304", err)
+
+	err = tx.Check(context.Background(), rpc, "bad address")
+	assert.Regexp("FFEC100060", err)
+
+	err = tx.Check(context.Background(), &rawTxnTestRPC{chainID: 1}, "")
+	assert.Regexp("FFEC100301", err)
+}
+
+func TestRawTxnDecodeErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := DecodeRawTransaction("not hex")
+	assert.Regexp("FFEC100299", err)
+	_, err = DecodeRawTransaction("0x")
+	assert.Regexp("FFEC100299", err)
+	_, err = DecodeRawTransaction("0xf8")
+	assert.Regexp("FFEC100299", err)
+}
+
+func TestRawTxnRPCErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	tx, err := DecodeRawTransaction(testDynamicFeeRawTx)
+	assert.NoError(err)
+
+	err = tx.Check(context.Background(), &rawTxnTestRPC{errMethod: "eth_chainId"}, "")
+	assert.Regexp("FFEC100135.*eth_chainId.*pop", err)
+	err = tx.Check(context.Background(), &rawTxnTestRPC{chainID: 1337, errMethod: "eth_getTransactionCount"}, "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23")
+	assert.Regexp("FFEC100135.*eth_getTransactionCount.*pop", err)
+	_, err = tx.Send(context.Background(), &rawTxnTestRPC{errMethod: "eth_sendRawTransaction"})
+	assert.Regexp("FFEC100135.*eth_sendRawTransaction.*pop", err)
+}
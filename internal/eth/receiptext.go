@@ -0,0 +1,146 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eth
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+)
+
+const (
+	// ChainProfileNone drops any receipt fields that are not part of the standard Ethereum receipt
+	ChainProfileNone = ""
+	// ChainProfileGeneric preserves all non-standard receipt fields as returned by the node
+	ChainProfileGeneric = "generic"
+	// ChainProfileOptimism decodes the L1 fee fields of OP Stack rollups
+	ChainProfileOptimism = "optimism"
+	// ChainProfileArbitrum decodes the L1 gas and block fields of Arbitrum Nitro rollups
+	ChainProfileArbitrum = "arbitrum"
+)
+
+// chainProfile lists the receipt extension fields surfaced for a chain. Quantities are hex
+// encoded numbers on the wire, that we convert to decimal strings like the standard fields.
+type chainProfile struct {
+	quantities []string
+	raw        []string
+}
+
+var chainProfiles = map[string]*chainProfile{
+	ChainProfileOptimism: {
+		quantities: []string{"effectiveGasPrice", "l1GasUsed", "l1GasPrice", "l1Fee", "l1BaseFeeScalar", "l1BlobBaseFee", "l1BlobBaseFeeScalar", "depositNonce", "depositReceiptVersion"},
+		raw:        []string{"l1FeeScalar"},
+	},
+	ChainProfileArbitrum: {
+		quantities: []string{"effectiveGasPrice", "gasUsedForL1", "l1BlockNumber"},
+	},
+}
+
+// ValidateChainProfile checks the configured chain profile is one we know how to decode
+func ValidateChainProfile(profile string) error {
+	if profile == ChainProfileNone || profile == ChainProfileGeneric || chainProfiles[profile] != nil {
+		return nil
+	}
+	return errors.Errorf(errors.ConfigUnknownChainProfile, profile)
+}
+
+// UnmarshalJSON parses the standard receipt fields, and keeps any others the node
+// returned so they can be surfaced according to the chain profile
+func (r *TxnReceipt) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		return nil
+	}
+	type txnReceipt TxnReceipt
+	receipt := txnReceipt(*r)
+	if err := json.Unmarshal(b, &receipt); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	for _, name := range standardReceiptFields {
+		delete(fields, name)
+	}
+	if len(fields) > 0 {
+		if receipt.Extensions == nil {
+			receipt.Extensions = make(map[string]json.RawMessage, len(fields))
+		}
+		for name, value := range fields {
+			receipt.Extensions[name] = value
+		}
+	}
+	*r = TxnReceipt(receipt)
+	return nil
+}
+
+// standardReceiptFields are either parsed into TxnReceipt, or deliberately not passed on
+var standardReceiptFields = []string{
+	"blockHash", "blockNumber", "contractAddress", "cumulativeGasUsed", "transactionHash",
+	"from", "gasUsed", "status", "to", "transactionIndex", "logs", "logsBloom", "type", "root",
+}
+
+// ChainExtensions returns the extension fields of the receipt selected by the chain profile.
+// When hexValues is set, quantities are also returned in their original hex form with a Hex suffix.
+func (r *TxnReceipt) ChainExtensions(profile string, hexValues bool) map[string]interface{} {
+	if profile == ChainProfileNone || len(r.Extensions) == 0 {
+		return nil
+	}
+	extensions := make(map[string]interface{})
+	cp := chainProfiles[profile]
+	if cp == nil {
+		for name, raw := range r.Extensions {
+			extensions[name] = rawExtensionValue(raw)
+		}
+		return extensions
+	}
+	for _, name := range cp.quantities {
+		raw, ok := r.Extensions[name]
+		if !ok {
+			continue
+		}
+		var hexStr string
+		if err := json.Unmarshal(raw, &hexStr); err == nil && strings.HasPrefix(hexStr, "0x") {
+			if i, ok := new(big.Int).SetString(hexStr[2:], 16); ok {
+				extensions[name] = i.Text(10)
+				if hexValues {
+					extensions[name+"Hex"] = hexStr
+				}
+				continue
+			}
+		}
+		extensions[name] = rawExtensionValue(raw)
+	}
+	for _, name := range cp.raw {
+		if raw, ok := r.Extensions[name]; ok {
+			extensions[name] = rawExtensionValue(raw)
+		}
+	}
+	if len(extensions) == 0 {
+		return nil
+	}
+	return extensions
+}
+
+func rawExtensionValue(raw json.RawMessage) interface{} {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return string(raw)
+	}
+	return v
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eth
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleOptimismReceipt = `{
+	"blockHash": "0x6ba8f2a37e8fd1d9b7ef5c1bb2c6ac1fb4b9f01d46b9c3f9e7a1b4d1a8f0b1c2",
+	"blockNumber": "0x7b",
+	"gasUsed": "0x5208",
+	"status": "0x1",
+	"logs": [],
+	"logsBloom": "0x00",
+	"type": "0x2",
+	"effectiveGasPrice": "0x3b9aca00",
+	"l1GasUsed": "0x640",
+	"l1Fee": "0xde0b6b3a7640000",
+	"l1FeeScalar": "0.684",
+	"l1GasPrice": "not-hex",
+	"gasUsedForL1": "0x10"
+}`
+
+func TestTxnReceiptUnmarshalKeepsExtensions(t *testing.T) {
+	assert := assert.New(t)
+
+	var r TxnReceipt
+	err := json.Unmarshal([]byte(sampleOptimismReceipt), &r)
+	assert.NoError(err)
+	assert.Equal(int64(123), r.BlockNumber.ToInt().Int64())
+	assert.Equal(int64(21000), r.GasUsed.ToInt().Int64())
+	assert.Len(r.Extensions, 6)
+	assert.NotContains(r.Extensions, "logs")
+	assert.NotContains(r.Extensions, "blockNumber")
+
+	// A private receipt overlays the public one
+	err = json.Unmarshal([]byte(`{"status":"0x0","privateFrom":"abc"}`), &r)
+	assert.NoError(err)
+	assert.Equal(int64(123), r.BlockNumber.ToInt().Int64())
+	assert.Equal(int64(0), r.Status.ToInt().Int64())
+	assert.Len(r.Extensions, 7)
+
+	// Not yet mined
+	err = json.Unmarshal([]byte(`null`), &r)
+	assert.NoError(err)
+	assert.Equal(int64(123), r.BlockNumber.ToInt().Int64())
+}
+
+func TestTxnReceiptUnmarshalLogs(t *testing.T) {
+	assert := assert.New(t)
+
+	var r TxnReceipt
+	err := json.Unmarshal([]byte(`{
+		"status": "0x1",
+		"logs": [{
+			"address": "0x0123456789abcdef0123456789abcdef01234567",
+			"topics": ["0x1111111111111111111111111111111111111111111111111111111111111111"],
+			"data": "0x2a",
+			"logIndex": "0x3",
+			"removed": false
+		}]
+	}`), &r)
+	assert.NoError(err)
+	assert.Len(r.Logs, 1)
+	assert.Equal("0x0123456789abcDEF0123456789abCDef01234567", r.Logs[0].Address.Hex())
+	assert.Equal("0x1111111111111111111111111111111111111111111111111111111111111111", r.Logs[0].Topics[0].Hex())
+	assert.Equal("0x2a", r.Logs[0].Data)
+	assert.Equal(uint(3), uint(*r.Logs[0].LogIndex))
+	assert.Empty(r.Extensions)
+}
+
+func TestTxnReceiptUnmarshalBadJSON(t *testing.T) {
+	var r TxnReceipt
+	err := r.UnmarshalJSON([]byte(`{"blockNumber": false}`))
+	assert.Error(t, err)
+	err = r.UnmarshalJSON([]byte(`[]`))
+	assert.Error(t, err)
+}
+
+func TestChainExtensionsProfiles(t *testing.T) {
+	assert := assert.New(t)
+
+	var r TxnReceipt
+	err := json.Unmarshal([]byte(sampleOptimismReceipt), &r)
+	assert.NoError(err)
+
+	assert.Nil(r.ChainExtensions(ChainProfileNone, false))
+
+	ext := r.ChainExtensions(ChainProfileOptimism, false)
+	assert.Equal(map[string]interface{}{
+		"effectiveGasPrice": "1000000000",
+		"l1GasUsed":         "1600",
+		"l1Fee":             "1000000000000000000",
+		"l1FeeScalar":       "0.684",
+		"l1GasPrice":        "not-hex",
+	}, ext)
+
+	ext = r.ChainExtensions(ChainProfileArbitrum, true)
+	assert.Equal(map[string]interface{}{
+		"effectiveGasPrice":    "1000000000",
+		"effectiveGasPriceHex": "0x3b9aca00",
+		"gasUsedForL1":         "16",
+		"gasUsedForL1Hex":      "0x10",
+	}, ext)
+
+	ext = r.ChainExtensions(ChainProfileGeneric, false)
+	assert.Len(ext, 6)
+	assert.Equal("0x640", ext["l1GasUsed"])
+
+	r.Extensions = map[string]json.RawMessage{"other": json.RawMessage(`"x"`)}
+	assert.Nil(r.ChainExtensions(ChainProfileArbitrum, false))
+}
+
+func TestValidateChainProfile(t *testing.T) {
+	assert := assert.New(t)
+	assert.NoError(ValidateChainProfile(""))
+	assert.NoError(ValidateChainProfile(ChainProfileGeneric))
+	assert.NoError(ValidateChainProfile(ChainProfileOptimism))
+	assert.NoError(ValidateChainProfile(ChainProfileArbitrum))
+	assert.Regexp("FFEC100234", ValidateChainProfile("zksync"))
+}
+
+func TestRawExtensionValueInvalid(t *testing.T) {
+	assert.Equal(t, "{bad", rawExtensionValue(json.RawMessage(`{bad`)))
+}
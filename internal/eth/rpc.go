@@ -18,6 +18,8 @@ import (
 	"context"
 	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/hyperledger/firefly-ethconnect/internal/auth"
 	"github.com/hyperledger/firefly-ethconnect/internal/errors"
@@ -38,7 +40,82 @@ type RPCConf struct {
 
 // RPCConnOpts configuration params
 type RPCConnOpts struct {
-	URL string `json:"url"`
+	URL      string          `json:"url"`
+	Timeouts RPCTimeoutsConf `json:"timeouts,omitempty"`
+}
+
+// RPCTimeoutsConf sets a timeout for each class of RPC call, so quick lookups fail fast
+// without cutting short legitimately long queries. Zero means the caller's context
+// is used unchanged
+type RPCTimeoutsConf struct {
+	FastMS   int               `json:"fastMS,omitempty"`
+	MediumMS int               `json:"mediumMS,omitempty"`
+	SlowMS   int               `json:"slowMS,omitempty"`
+	Methods  map[string]string `json:"methods,omitempty"` // overrides the class of individual methods
+}
+
+const (
+	// RPCTimeoutClassFast is for cheap lookups of chain state
+	RPCTimeoutClassFast = "fast"
+	// RPCTimeoutClassMedium is for calls that execute EVM code, and is the default
+	RPCTimeoutClassMedium = "medium"
+	// RPCTimeoutClassSlow is for log queries over block ranges, and tracing
+	RPCTimeoutClassSlow = "slow"
+)
+
+var defaultRPCMethodClasses = map[string]string{
+	"eth_blockNumber":           RPCTimeoutClassFast,
+	"eth_chainId":               RPCTimeoutClassFast,
+	"net_version":               RPCTimeoutClassFast,
+	"eth_gasPrice":              RPCTimeoutClassFast,
+	"eth_getTransactionCount":   RPCTimeoutClassFast,
+	"eth_getTransactionReceipt": RPCTimeoutClassFast,
+	"eth_getBlockByNumber":      RPCTimeoutClassFast,
+	"eth_getBlockByHash":        RPCTimeoutClassFast,
+	"eth_getFilterChanges":      RPCTimeoutClassFast,
+	"eth_newFilter":             RPCTimeoutClassFast,
+	"eth_newBlockFilter":        RPCTimeoutClassFast,
+	"eth_uninstallFilter":       RPCTimeoutClassFast,
+	"eth_getLogs":               RPCTimeoutClassSlow,
+	"eth_getFilterLogs":         RPCTimeoutClassSlow,
+}
+
+// rpcTimeouts is the resolved form of RPCTimeoutsConf
+type rpcTimeouts struct {
+	byClass map[string]time.Duration
+	methods map[string]string
+}
+
+func newRPCTimeouts(conf *RPCTimeoutsConf) (*rpcTimeouts, error) {
+	t := &rpcTimeouts{
+		byClass: map[string]time.Duration{
+			RPCTimeoutClassFast:   time.Duration(conf.FastMS) * time.Millisecond,
+			RPCTimeoutClassMedium: time.Duration(conf.MediumMS) * time.Millisecond,
+			RPCTimeoutClassSlow:   time.Duration(conf.SlowMS) * time.Millisecond,
+		},
+		methods: make(map[string]string, len(defaultRPCMethodClasses)+len(conf.Methods)),
+	}
+	for method, class := range defaultRPCMethodClasses {
+		t.methods[method] = class
+	}
+	for method, class := range conf.Methods {
+		class = strings.ToLower(class)
+		if _, ok := t.byClass[class]; !ok {
+			return nil, errors.Errorf(errors.RPCTimeoutClassUnknown, class, method)
+		}
+		t.methods[method] = class
+	}
+	return t, nil
+}
+
+func (t *rpcTimeouts) classOf(method string) string {
+	if class, ok := t.methods[method]; ok {
+		return class
+	}
+	if strings.HasPrefix(method, "debug_") || strings.HasPrefix(method, "trace_") {
+		return RPCTimeoutClassSlow
+	}
+	return RPCTimeoutClassMedium
 }
 
 // RPCConnect wraps rpc.Dial with useful logging, avoiding logging username/password
@@ -47,18 +124,25 @@ func RPCConnect(conf *RPCConnOpts) (RPCClientAll, error) {
 	if u.User != nil {
 		u.User = url.UserPassword(u.User.Username(), "xxxxxx")
 	}
+	timeouts, err := newRPCTimeouts(&conf.Timeouts)
+	if err != nil {
+		return nil, err
+	}
 	rpcClient, err := ethbind.API.Dial(conf.URL)
 	if err != nil {
 		return nil, errors.Errorf(errors.RPCConnectFailed, u, err)
 	}
 	log.Infof("New JSON/RPC connection established")
 	log.Debugf("JSON/RPC connected to %s", u)
-	return &rpcWrapper{rpc: rpcClient}, nil
+	return &rpcWrapper{rpc: rpcClient, timeouts: timeouts}, nil
 }
 
 // CobraInitRPC sets the standard command-line parameters for RPC
 func CobraInitRPC(cmd *cobra.Command, rconf *RPCConf) {
 	cmd.Flags().StringVarP(&rconf.RPC.URL, "rpc-url", "r", os.Getenv("ETH_RPC_URL"), "JSON/RPC URL for Ethereum node")
+	cmd.Flags().IntVar(&rconf.RPC.Timeouts.FastMS, "rpc-timeout-fast", 0, "Timeout (ms) for fast JSON/RPC calls such as eth_getTransactionCount (0 for none)")
+	cmd.Flags().IntVar(&rconf.RPC.Timeouts.MediumMS, "rpc-timeout-medium", 0, "Timeout (ms) for JSON/RPC calls such as eth_call and eth_estimateGas (0 for none)")
+	cmd.Flags().IntVar(&rconf.RPC.Timeouts.SlowMS, "rpc-timeout-slow", 0, "Timeout (ms) for slow JSON/RPC calls such as eth_getLogs and debug traces (0 for none)")
 }
 
 // rpc.RPCClient methods with original types that we expose - only used within this package.
@@ -70,7 +154,8 @@ type rcpClient interface {
 }
 
 type rpcWrapper struct {
-	rpc rcpClient
+	rpc      rcpClient
+	timeouts *rpcTimeouts
 }
 
 // RPCClientSubscription local alias type for ClientSubscription
@@ -96,9 +181,24 @@ func (w *rpcWrapper) CallContext(ctx context.Context, result interface{}, method
 		log.Errorf("JSON/RPC %s - not authorized: %s", method, err)
 		return errors.Errorf(errors.Unauthorized)
 	}
+	callCtx := ctx
+	var class string
+	var timeout time.Duration
+	if w.timeouts != nil {
+		class = w.timeouts.classOf(method)
+		if timeout = w.timeouts.byClass[class]; timeout > 0 {
+			var cancel context.CancelFunc
+			callCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+	}
 	log.Tracef("RPC [%s] --> %+v", method, args)
-	err := w.rpc.CallContext(ctx, result, method, args...)
+	err := w.rpc.CallContext(callCtx, result, method, args...)
 	log.Tracef("RPC [%s] <-- %+v", method, result)
+	if err != nil && timeout > 0 && ctx.Err() == nil && callCtx.Err() == context.DeadlineExceeded {
+		// Only report our own timeout, not a deadline the caller set
+		return errors.Errorf(errors.RPCCallTimeout, method, timeout, class)
+	}
 	return err
 }
 
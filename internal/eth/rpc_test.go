@@ -19,6 +19,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/spf13/cobra"
@@ -78,8 +79,13 @@ func TestCobraInitTxnProcessor(t *testing.T) {
 	CobraInitRPC(cmd, rconf)
 	cmd.ParseFlags([]string{
 		"-r", "http://localhost:8545",
+		"--rpc-timeout-fast", "500",
+		"--rpc-timeout-slow", "60000",
 	})
 	assert.Equal("http://localhost:8545", rconf.RPC.URL)
+	assert.Equal(500, rconf.RPC.Timeouts.FastMS)
+	assert.Equal(0, rconf.RPC.Timeouts.MediumMS)
+	assert.Equal(60000, rconf.RPC.Timeouts.SlowMS)
 }
 
 func TestRPCConnectOK(t *testing.T) {
@@ -138,3 +144,83 @@ func TestCallContextWrapperAuth(t *testing.T) {
 
 	auth.RegisterSecurityModule(nil)
 }
+
+// blockingEthClient waits for the context to be cancelled, capturing the deadline it was given
+type blockingEthClient struct {
+	mockEthClient
+	deadlines map[string]time.Duration
+}
+
+func (w *blockingEthClient) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		w.deadlines[method] = 0
+		return nil
+	}
+	w.deadlines[method] = time.Until(deadline)
+	if method == "eth_getLogs" {
+		return nil
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestCallContextTimeoutClasses(t *testing.T) {
+	assert := assert.New(t)
+
+	timeouts, err := newRPCTimeouts(&RPCTimeoutsConf{
+		FastMS:  10,
+		SlowMS:  60000,
+		Methods: map[string]string{"eth_call": "Fast"},
+	})
+	assert.NoError(err)
+	client := &blockingEthClient{deadlines: make(map[string]time.Duration)}
+	w := &rpcWrapper{rpc: client, timeouts: timeouts}
+
+	err = w.CallContext(context.Background(), nil, "eth_getTransactionCount")
+	assert.Regexp("FFEC100271.*eth_getTransactionCount.*fast", err)
+
+	// Reclassified by configuration
+	err = w.CallContext(context.Background(), nil, "eth_call")
+	assert.Regexp("FFEC100271.*eth_call.*fast", err)
+
+	err = w.CallContext(context.Background(), nil, "eth_getLogs")
+	assert.NoError(err)
+	assert.Greater(client.deadlines["eth_getLogs"], 59*time.Second)
+
+	// Medium has no timeout configured, so the caller's context is used unchanged
+	err = w.CallContext(context.Background(), nil, "eth_estimateGas")
+	assert.NoError(err)
+	assert.Equal(time.Duration(0), client.deadlines["eth_estimateGas"])
+
+	// A deadline set by the caller is reported as-is
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Millisecond)
+	defer cancel()
+	err = w.CallContext(ctx, nil, "debug_traceTransaction")
+	assert.Equal(context.DeadlineExceeded, err)
+	assert.Less(client.deadlines["debug_traceTransaction"], time.Second)
+}
+
+func TestRPCTimeoutClassOf(t *testing.T) {
+	assert := assert.New(t)
+
+	timeouts, err := newRPCTimeouts(&RPCTimeoutsConf{})
+	assert.NoError(err)
+	assert.Equal(RPCTimeoutClassFast, timeouts.classOf("eth_gasPrice"))
+	assert.Equal(RPCTimeoutClassMedium, timeouts.classOf("eth_call"))
+	assert.Equal(RPCTimeoutClassMedium, timeouts.classOf("eth_sendRawTransaction"))
+	assert.Equal(RPCTimeoutClassSlow, timeouts.classOf("eth_getFilterLogs"))
+	assert.Equal(RPCTimeoutClassSlow, timeouts.classOf("trace_block"))
+}
+
+func TestRPCConnectBadTimeoutClass(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := RPCConnect(&RPCConnOpts{
+		URL: "http://localhost:8545",
+		Timeouts: RPCTimeoutsConf{
+			Methods: map[string]string{"eth_call": "glacial"},
+		},
+	})
+	assert.Regexp("FFEC100272", err)
+}
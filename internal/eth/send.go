@@ -125,7 +125,8 @@ func (tx *Txn) Call(ctx context.Context, rpc RPCClient, blocknumber string) (res
 	return
 }
 
-func (tx *Txn) CallAndProcessReply(ctx context.Context, rpc RPCClient, blocknumber string) (map[string]interface{}, error) {
+// callBlockNumber converts the block number supplied for a call into the JSON/RPC parameter
+func callBlockNumber(blocknumber string) (string, error) {
 	callOption := "latest"
 	// only allowed values are "earliest/latest/pending", "", a number string "12345" or a hex number "0xab23"
 	// "latest" and "" (no fly-blocknumber given) are equivalent
@@ -137,19 +138,33 @@ func (tx *Txn) CallAndProcessReply(ctx context.Context, rpc RPCClient, blocknumb
 			n := new(big.Int)
 			n, ok := n.SetString(blocknumber, 10)
 			if !ok {
-				return nil, errors.Errorf(errors.TransactionCallInvalidBlockNumber)
+				return "", errors.Errorf(errors.TransactionCallInvalidBlockNumber)
 			}
 			callOption = ethbind.API.EncodeBig(n)
 		}
 	}
+	return callOption, nil
+}
 
-	retBytes, _, err := tx.Call(ctx, rpc, callOption)
+func (tx *Txn) CallAndProcessReply(ctx context.Context, rpc RPCClient, blocknumber string) (map[string]interface{}, error) {
+	retBytes, err := tx.CallReturnData(ctx, rpc, blocknumber)
 	if err != nil || retBytes == nil {
 		return nil, err
 	}
 	return ProcessRLPBytes(tx.Method.Outputs, retBytes), nil
 }
 
+// CallReturnData calls the method at the supplied block, and returns the ABI encoded return data
+// without decoding it
+func (tx *Txn) CallReturnData(ctx context.Context, rpc RPCClient, blocknumber string) ([]byte, error) {
+	callOption, err := callBlockNumber(blocknumber)
+	if err != nil {
+		return nil, err
+	}
+	retBytes, _, err := tx.Call(ctx, rpc, callOption)
+	return retBytes, err
+}
+
 // Send sends an individual transaction, choosing external or internal signing
 func (tx *Txn) Send(ctx context.Context, rpc RPCClient, estimationFactor float64) (err error) {
 	start := time.Now().UTC()
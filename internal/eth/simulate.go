@@ -0,0 +1,182 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	log "github.com/sirupsen/logrus"
+)
+
+const zeroStorageValue = "0x0000000000000000000000000000000000000000000000000000000000000000"
+
+// SimulationResult is the outcome of simulating a transaction against the current state of
+// the chain. The state diff is only available on nodes that support debug_traceCall with the
+// prestate tracer in diff mode, otherwise the reason it is missing is returned.
+type SimulationResult struct {
+	Outputs        map[string]interface{}       `json:"outputs"`
+	StateDiff      map[string]*AccountStateDiff `json:"stateDiff,omitempty"`
+	StateDiffError string                       `json:"stateDiffError,omitempty"`
+}
+
+// AccountStateDiff lists the fields of an account that the transaction would change. Balances
+// and nonces are decimal strings, and code and storage values are hex.
+type AccountStateDiff struct {
+	Balance *StateChange            `json:"balance,omitempty"`
+	Nonce   *StateChange            `json:"nonce,omitempty"`
+	Code    *StateChange            `json:"code,omitempty"`
+	Storage map[string]*StateChange `json:"storage,omitempty"`
+}
+
+// StateChange is the value before and after the transaction
+type StateChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// prestateAccount is an account in the output of the prestate tracer
+type prestateAccount struct {
+	Balance *ethbinding.HexBigInt `json:"balance,omitempty"`
+	Nonce   *uint64               `json:"nonce,omitempty"`
+	Code    *string               `json:"code,omitempty"`
+	Storage map[string]string     `json:"storage,omitempty"`
+}
+
+type prestateDiff struct {
+	Pre  map[string]*prestateAccount `json:"pre"`
+	Post map[string]*prestateAccount `json:"post"`
+}
+
+// SimulateMethod calls the method to obtain its outputs, and then traces the same call to
+// obtain the changes it would make to the state, without submitting a transaction
+func SimulateMethod(ctx context.Context, rpc RPCClient, signer TXSigner, from, addr string, value json.Number, methodABI *ethbinding.ABIMethod, msgParams []interface{}, blocknumber string) (*SimulationResult, error) {
+	log.Debugf("Simulating method. ABI: %+v Params: %+v", methodABI, msgParams)
+	tx, err := buildTX(signer, from, addr, "", value, "", "", methodABI, msgParams)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := tx.CallAndProcessReply(ctx, rpc, blocknumber)
+	if err != nil {
+		return nil, err
+	}
+	result := &SimulationResult{Outputs: outputs}
+	if result.Outputs == nil {
+		result.Outputs = map[string]interface{}{}
+	}
+	// The block number has already been validated by the call
+	callOption, _ := callBlockNumber(blocknumber)
+	if result.StateDiff, err = tx.TraceStateDiff(ctx, rpc, callOption); err != nil {
+		log.Warnf("State diff unavailable for simulation: %s", err)
+		result.StateDiffError = err.Error()
+	}
+	return result, nil
+}
+
+// TraceStateDiff uses debug_traceCall with the prestate tracer in diff mode, to find the
+// accounts, balances and storage slots the transaction would change
+func (tx *Txn) TraceStateDiff(ctx context.Context, rpc RPCClient, blocknumber string) (map[string]*AccountStateDiff, error) {
+	txArgs := tx.buildCallArgs()
+	tracerConfig := map[string]interface{}{
+		"tracer":       "prestateTracer",
+		"tracerConfig": map[string]interface{}{"diffMode": true},
+	}
+	var diff prestateDiff
+	if err := rpc.CallContext(ctx, &diff, "debug_traceCall", txArgs, blocknumber, tracerConfig); err != nil {
+		return nil, errors.Errorf(errors.RPCCallReturnedError, "debug_traceCall", err)
+	}
+	return diff.accountDiffs(), nil
+}
+
+// accountDiffs merges the pre and post state of each account. In diff mode the tracer only
+// includes the fields that changed, and omits accounts that were deleted and storage slots
+// that were cleared from the post state.
+func (d *prestateDiff) accountDiffs() map[string]*AccountStateDiff {
+	diffs := make(map[string]*AccountStateDiff)
+	for addr, post := range d.Post {
+		pre := d.Pre[addr]
+		if pre == nil {
+			pre = &prestateAccount{}
+		}
+		diff := &AccountStateDiff{}
+		if post.Balance != nil {
+			diff.Balance = &StateChange{From: balanceString(pre.Balance), To: balanceString(post.Balance)}
+		}
+		if post.Nonce != nil {
+			diff.Nonce = &StateChange{From: nonceString(pre.Nonce), To: nonceString(post.Nonce)}
+		}
+		if post.Code != nil {
+			diff.Code = &StateChange{From: codeString(pre.Code), To: codeString(post.Code)}
+		}
+		diff.Storage = storageChanges(pre.Storage, post.Storage)
+		diffs[addr] = diff
+	}
+	for addr, pre := range d.Pre {
+		if _, inPost := d.Post[addr]; inPost {
+			continue
+		}
+		diffs[addr] = &AccountStateDiff{
+			Balance: &StateChange{From: balanceString(pre.Balance), To: "0"},
+			Nonce:   &StateChange{From: nonceString(pre.Nonce), To: "0"},
+			Code:    &StateChange{From: codeString(pre.Code), To: "0x"},
+			Storage: storageChanges(pre.Storage, nil),
+		}
+	}
+	return diffs
+}
+
+func storageChanges(pre, post map[string]string) map[string]*StateChange {
+	changes := make(map[string]*StateChange)
+	for slot, to := range post {
+		from, ok := pre[slot]
+		if !ok {
+			from = zeroStorageValue
+		}
+		changes[slot] = &StateChange{From: from, To: to}
+	}
+	for slot, from := range pre {
+		if _, ok := post[slot]; !ok {
+			changes[slot] = &StateChange{From: from, To: zeroStorageValue}
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+func balanceString(balance *ethbinding.HexBigInt) string {
+	if balance == nil {
+		return "0"
+	}
+	return balance.ToInt().Text(10)
+}
+
+func nonceString(nonce *uint64) string {
+	if nonce == nil {
+		return "0"
+	}
+	return strconv.FormatUint(*nonce, 10)
+}
+
+func codeString(code *string) string {
+	if code == nil || *code == "" {
+		return "0x"
+	}
+	return *code
+}
@@ -0,0 +1,170 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+const testStateDiff = `{
+	"pre": {
+		"0xaa983ad2a0e0ed8ac639277f37be42f2a5d2618c": {"balance": "0xde0b6b3a7640000", "nonce": 5},
+		"0x2b8c0ecc76d0759a8f50b2e14a6881367d805832": {
+			"balance": "0x0",
+			"code": "0x6080",
+			"storage": {
+				"0x01": "0x000000000000000000000000000000000000000000000000000000000000000a",
+				"0x02": "0x000000000000000000000000000000000000000000000000000000000000000b"
+			}
+		},
+		"0x1111111111111111111111111111111111111111": {"balance": "0x10", "nonce": 1, "code": "0x60"}
+	},
+	"post": {
+		"0xaa983ad2a0e0ed8ac639277f37be42f2a5d2618c": {"balance": "0xde0b6b3a763cfc7", "nonce": 6},
+		"0x2b8c0ecc76d0759a8f50b2e14a6881367d805832": {
+			"balance": "0x3039",
+			"storage": {
+				"0x01": "0x000000000000000000000000000000000000000000000000000000000000000c",
+				"0x03": "0x000000000000000000000000000000000000000000000000000000000000000d"
+			}
+		},
+		"0x2222222222222222222222222222222222222222": {"code": "0x6001"}
+	}
+}`
+
+func testSimulateMethod() *ethbinding.ABIMethod {
+	uint256Type, _ := ethbind.API.ABITypeFor("uint256")
+	inputs := ethbinding.ABIArguments{ethbinding.ABIArgument{Name: "x", Type: uint256Type}}
+	outputs := ethbinding.ABIArguments{ethbinding.ABIArgument{Name: "retval1", Type: uint256Type}}
+	method := ethbind.API.NewMethod("set", "set", ethbinding.Function, "payable", false, true, inputs, outputs)
+	return &method
+}
+
+func TestSimulateMethodStateDiff(t *testing.T) {
+	assert := assert.New(t)
+
+	rpc := &testRPCClient{
+		resultWrangler: func(result interface{}) {
+			switch r := result.(type) {
+			case *string:
+				*r = "0x0000000000000000000000000000000000000000000000000000000000000001"
+			case *prestateDiff:
+				json.Unmarshal([]byte(testStateDiff), r)
+			}
+		},
+	}
+
+	res, err := SimulateMethod(context.Background(), rpc, nil,
+		"0xAA983AD2a0e0eD8ac639277F37be42F2A5d2618c",
+		"0x2b8c0ECc76d0759a8F50b2E14A6881367D805832",
+		json.Number("12345"), testSimulateMethod(), []interface{}{"12"}, "12345")
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{"retval1": "1"}, res.Outputs)
+	assert.Empty(res.StateDiffError)
+
+	assert.Equal("eth_call", rpc.capturedMethod)
+	assert.Equal("debug_traceCall", rpc.capturedMethod2)
+	assert.Equal(rpc.capturedArgs[0], rpc.capturedArgs2[0])
+	assert.Equal("0x3039", rpc.capturedArgs2[1])
+	assert.Equal(map[string]interface{}{
+		"tracer":       "prestateTracer",
+		"tracerConfig": map[string]interface{}{"diffMode": true},
+	}, rpc.capturedArgs2[2])
+
+	assert.Len(res.StateDiff, 4)
+	sender := res.StateDiff["0xaa983ad2a0e0ed8ac639277f37be42f2a5d2618c"]
+	assert.Equal(&StateChange{From: "1000000000000000000", To: "999999999999987655"}, sender.Balance)
+	assert.Equal(&StateChange{From: "5", To: "6"}, sender.Nonce)
+	assert.Nil(sender.Code)
+	assert.Nil(sender.Storage)
+
+	contract := res.StateDiff["0x2b8c0ecc76d0759a8f50b2e14a6881367d805832"]
+	assert.Equal(&StateChange{From: "0", To: "12345"}, contract.Balance)
+	assert.Nil(contract.Nonce)
+	assert.Nil(contract.Code)
+	assert.Equal(map[string]*StateChange{
+		"0x01": {
+			From: "0x000000000000000000000000000000000000000000000000000000000000000a",
+			To:   "0x000000000000000000000000000000000000000000000000000000000000000c",
+		},
+		"0x02": {
+			From: "0x000000000000000000000000000000000000000000000000000000000000000b",
+			To:   zeroStorageValue,
+		},
+		"0x03": {
+			From: zeroStorageValue,
+			To:   "0x000000000000000000000000000000000000000000000000000000000000000d",
+		},
+	}, contract.Storage)
+
+	deleted := res.StateDiff["0x1111111111111111111111111111111111111111"]
+	assert.Equal(&StateChange{From: "16", To: "0"}, deleted.Balance)
+	assert.Equal(&StateChange{From: "1", To: "0"}, deleted.Nonce)
+	assert.Equal(&StateChange{From: "0x60", To: "0x"}, deleted.Code)
+
+	created := res.StateDiff["0x2222222222222222222222222222222222222222"]
+	assert.Equal(&StateChange{From: "0x", To: "0x6001"}, created.Code)
+	assert.Nil(created.Balance)
+}
+
+func TestSimulateMethodTraceUnsupported(t *testing.T) {
+	assert := assert.New(t)
+
+	rpc := &testRPCClient{
+		resultWrangler: func(result interface{}) {
+			if r, ok := result.(*string); ok {
+				*r = "0x0000000000000000000000000000000000000000000000000000000000000001"
+			}
+		},
+		mockError2: fmt.Errorf("the method debug_traceCall does not exist/is not available"),
+	}
+
+	res, err := SimulateMethod(context.Background(), rpc, nil,
+		"0xAA983AD2a0e0eD8ac639277F37be42F2A5d2618c",
+		"0x2b8c0ECc76d0759a8F50b2E14A6881367D805832",
+		json.Number("0"), testSimulateMethod(), []interface{}{"12"}, "")
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{"retval1": "1"}, res.Outputs)
+	assert.Equal("latest", rpc.capturedArgs2[1])
+	assert.Nil(res.StateDiff)
+	assert.Regexp("debug_traceCall.*does not exist", res.StateDiffError)
+}
+
+func TestSimulateMethodCallFail(t *testing.T) {
+	assert := assert.New(t)
+
+	rpc := &testRPCClient{
+		mockError: fmt.Errorf("pop"),
+	}
+	_, err := SimulateMethod(context.Background(), rpc, nil,
+		"0xAA983AD2a0e0eD8ac639277F37be42F2A5d2618c",
+		"0x2b8c0ECc76d0759a8F50b2E14A6881367D805832",
+		json.Number("0"), testSimulateMethod(), []interface{}{"12"}, "")
+	assert.Regexp("pop", err)
+	assert.Empty(rpc.capturedMethod2)
+
+	_, err = SimulateMethod(context.Background(), rpc, nil,
+		"0xAA983AD2a0e0eD8ac639277F37be42F2A5d2618c",
+		"0x2b8c0ECc76d0759a8F50b2E14A6881367D805832",
+		json.Number("0"), testSimulateMethod(), []interface{}{"not a number"}, "")
+	assert.Error(err)
+}
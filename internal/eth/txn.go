@@ -51,16 +51,26 @@ type Txn struct {
 
 // TxnReceipt is the receipt obtained over JSON/RPC from the ethereum client
 type TxnReceipt struct {
-	BlockHash         *ethbinding.Hash      `json:"blockHash"`
-	BlockNumber       *ethbinding.HexBigInt `json:"blockNumber"`
-	ContractAddress   *ethbinding.Address   `json:"contractAddress"`
-	CumulativeGasUsed *ethbinding.HexBigInt `json:"cumulativeGasUsed"`
-	TransactionHash   *ethbinding.Hash      `json:"transactionHash"`
-	From              *ethbinding.Address   `json:"from"`
-	GasUsed           *ethbinding.HexBigInt `json:"gasUsed"`
-	Status            *ethbinding.HexBigInt `json:"status"`
-	To                *ethbinding.Address   `json:"to"`
-	TransactionIndex  *ethbinding.HexUint   `json:"transactionIndex"`
+	BlockHash         *ethbinding.Hash           `json:"blockHash"`
+	BlockNumber       *ethbinding.HexBigInt      `json:"blockNumber"`
+	ContractAddress   *ethbinding.Address        `json:"contractAddress"`
+	CumulativeGasUsed *ethbinding.HexBigInt      `json:"cumulativeGasUsed"`
+	TransactionHash   *ethbinding.Hash           `json:"transactionHash"`
+	From              *ethbinding.Address        `json:"from"`
+	GasUsed           *ethbinding.HexBigInt      `json:"gasUsed"`
+	Status            *ethbinding.HexBigInt      `json:"status"`
+	To                *ethbinding.Address        `json:"to"`
+	TransactionIndex  *ethbinding.HexUint        `json:"transactionIndex"`
+	Logs              []*TxnLog                  `json:"logs"`
+	Extensions        map[string]json.RawMessage `json:"-"`
+}
+
+// TxnLog is a log entry emitted by the transaction, within the receipt
+type TxnLog struct {
+	Address  *ethbinding.Address `json:"address"`
+	Topics   []*ethbinding.Hash  `json:"topics"`
+	Data     string              `json:"data"`
+	LogIndex *ethbinding.HexUint `json:"logIndex"`
 }
 
 // TxnInfo is the detailed transaction info returned by eth_getTransactionByXXXXX
@@ -88,8 +98,9 @@ func NewContractDeployTxn(msg *messages.DeployContract, signer TXSigner) (tx *Tx
 
 	if msg.Compiled != nil && msg.ABI != nil {
 		compiled = &CompiledSolidity{
-			Compiled: msg.Compiled,
-			ABI:      msg.ABI,
+			Compiled:       msg.Compiled,
+			ABI:            msg.ABI,
+			LinkReferences: msg.LinkReferences,
 		}
 	} else if msg.Solidity != "" {
 		// Compile the solidity contract
@@ -101,6 +112,12 @@ func NewContractDeployTxn(msg *messages.DeployContract, signer TXSigner) (tx *Tx
 		return
 	}
 
+	// Write the addresses of any libraries into the bytecode
+	bytecode, err := LinkBytecode(compiled.Compiled, compiled.LinkReferences, msg.Libraries)
+	if err != nil {
+		return
+	}
+
 	// Build a runtime ABI from the serialized one
 	var typedArgs []interface{}
 	abi, err := ethbind.API.ABIMarshalingToABIRuntime(compiled.ABI)
@@ -120,7 +137,7 @@ func NewContractDeployTxn(msg *messages.DeployContract, signer TXSigner) (tx *Tx
 	}
 
 	// Join the EVM bytecode with the packed call
-	data := append(compiled.Compiled, packedCall...)
+	data := append(bytecode, packedCall...)
 
 	from := msg.From
 	if tx.Signer != nil {
@@ -149,6 +166,17 @@ func CallMethod(ctx context.Context, rpc RPCClient, signer TXSigner, from, addr
 	return tx.CallAndProcessReply(ctx, rpc, blocknumber)
 }
 
+// CallMethodReturnData performs eth_call like CallMethod, but returns the ABI encoded return data,
+// for the caller to decode
+func CallMethodReturnData(ctx context.Context, rpc RPCClient, signer TXSigner, from, addr string, value json.Number, methodABI *ethbinding.ABIMethod, msgParams []interface{}, blocknumber string) ([]byte, error) {
+	log.Debugf("Calling method. ABI: %+v Params: %+v", methodABI, msgParams)
+	tx, err := buildTX(signer, from, addr, "", value, "", "", methodABI, msgParams)
+	if err != nil {
+		return nil, err
+	}
+	return tx.CallReturnData(ctx, rpc, blocknumber)
+}
+
 // Decode the "input" bytes from a transaction, which are composed of a method ID + encoded arguments
 func DecodeInputs(method *ethbinding.ABIMethod, inputs *ethbinding.HexBytes) (map[string]interface{}, error) {
 	methodIDLen := len(method.ID)
@@ -433,8 +461,14 @@ func (tx *Txn) genEthTransaction(msgFrom, msgTo string, msgNonce, msgValue, msgG
 	value := big.NewInt(0)
 	if msgValue.String() != "" {
 		if _, ok := value.SetString(msgValue.String(), 10); !ok {
-			err = errors.Errorf(errors.TransactionSendBadValue, err)
-			return
+			if _, unit := SplitAmountUnit(msgValue.String()); unit != "" {
+				if value, err = ParseAmount(msgValue.String(), nil); err != nil {
+					return
+				}
+			} else {
+				err = errors.Errorf(errors.TransactionSendBadValue, err)
+				return
+			}
 		}
 	}
 
@@ -450,8 +484,14 @@ func (tx *Txn) genEthTransaction(msgFrom, msgTo string, msgNonce, msgValue, msgG
 	gasPrice := big.NewInt(0)
 	if msgGasPrice.String() != "" {
 		if _, ok := gasPrice.SetString(msgGasPrice.String(), 10); !ok {
-			err = errors.Errorf(errors.TransactionSendBadGasPrice)
-			return
+			if _, unit := SplitAmountUnit(msgGasPrice.String()); unit != "" {
+				if gasPrice, err = ParseAmount(msgGasPrice.String(), nil); err != nil {
+					return
+				}
+			} else {
+				err = errors.Errorf(errors.TransactionSendBadGasPrice)
+				return
+			}
 		}
 	}
 
@@ -476,8 +516,14 @@ func (tx *Txn) genEthTransaction(msgFrom, msgTo string, msgNonce, msgValue, msgG
 func (tx *Txn) getInteger(methodName string, path string, requiredType *ethbinding.ABIType, suppliedType reflect.Type, param interface{}) (val int64, err error) {
 	if suppliedType.Kind() == reflect.String {
 		if val, err = strconv.ParseInt(param.(string), 10, 64); err != nil {
-			err = errors.Errorf(errors.TransactionSendInputTypeBadNumber, methodName, path)
-			return
+			var amount *big.Int
+			if amount, err = parseAmountParam(methodName, path, param.(string)); err == nil && !amount.IsInt64() {
+				err = errors.Errorf(errors.TransactionSendInputTypeBadNumber, methodName, path)
+			}
+			if err != nil {
+				return
+			}
+			val = amount.Int64()
 		}
 	} else if suppliedType.Kind() == reflect.Float64 {
 		val = int64(param.(float64))
@@ -490,8 +536,14 @@ func (tx *Txn) getInteger(methodName string, path string, requiredType *ethbindi
 func (tx *Txn) getUnsignedInteger(methodName string, path string, requiredType *ethbinding.ABIType, suppliedType reflect.Type, param interface{}) (val uint64, err error) {
 	if suppliedType.Kind() == reflect.String {
 		if val, err = strconv.ParseUint(param.(string), 10, 64); err != nil {
-			err = errors.Errorf(errors.TransactionSendInputTypeBadNumber, methodName, path)
-			return
+			var amount *big.Int
+			if amount, err = parseAmountParam(methodName, path, param.(string)); err == nil && !amount.IsUint64() {
+				err = errors.Errorf(errors.TransactionSendInputTypeBadNumber, methodName, path)
+			}
+			if err != nil {
+				return
+			}
+			val = amount.Uint64()
 		}
 	} else if suppliedType.Kind() == reflect.Float64 {
 		val = uint64(param.(float64))
@@ -505,7 +557,7 @@ func (tx *Txn) getBigInteger(methodName string, path string, requiredType *ethbi
 	bigInt = big.NewInt(0)
 	if suppliedType.Kind() == reflect.String {
 		if _, ok := bigInt.SetString(param.(string), 10); !ok {
-			err = errors.Errorf(errors.TransactionSendInputTypeBadNumber, methodName, path)
+			bigInt, err = parseAmountParam(methodName, path, param.(string))
 		}
 	} else if suppliedType.Kind() == reflect.Float64 {
 		bigInt.SetInt64(int64(param.(float64)))
@@ -294,6 +294,33 @@ func TestNewContractDeployPrecompiledSimpleStorage(t *testing.T) {
 
 }
 
+func TestNewContractDeployLinkedLibrary(t *testing.T) {
+	assert := assert.New(t)
+
+	var msg messages.DeployContract
+	msg.Compiled = make([]byte, 22)
+	msg.ABI = ethbinding.ABIMarshaling{}
+	msg.LinkReferences = []*messages.LinkReference{{Library: "lib.sol:Lib", Offsets: []int{1}}}
+	msg.Libraries = map[string]string{"Lib": "0x0123456789abcdef0123456789abcdef01234567"}
+	msg.From = "0xAA983AD2a0e0eD8ac639277F37be42F2A5d2618c"
+	msg.Nonce = "123"
+	msg.Gas = "456"
+	tx, err := NewContractDeployTxn(&msg, nil)
+	assert.NoError(err)
+	rpc := testRPCClient{}
+
+	tx.Send(context.Background(), &rpc, 1.2)
+
+	jsonBytesSent, _ := json.Marshal(rpc.capturedArgs[0])
+	var jsonSent map[string]interface{}
+	json.Unmarshal(jsonBytesSent, &jsonSent)
+	assert.Equal("0x000123456789abcdef0123456789abcdef0123456700", jsonSent["data"])
+
+	msg.Libraries = nil
+	_, err = NewContractDeployTxn(&msg, nil)
+	assert.Regexp("FFEC100399", err)
+}
+
 func TestNewContractDeployTxnBadNonce(t *testing.T) {
 	assert := assert.New(t)
 
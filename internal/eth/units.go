@@ -0,0 +1,196 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eth
+
+import (
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+)
+
+// TokenUnit is the unit for an amount of a token, scaled by the decimals configured for its contract
+const TokenUnit = "token"
+
+var unitDecimals = map[string]int{
+	"wei":        0,
+	"kwei":       3,
+	"babbage":    3,
+	"mwei":       6,
+	"lovelace":   6,
+	"gwei":       9,
+	"shannon":    9,
+	"szabo":      12,
+	"microether": 12,
+	"finney":     15,
+	"milliether": 15,
+	"ether":      18,
+}
+
+var decimalAmount = regexp.MustCompile(`^(-?)([0-9]+)(?:\.([0-9]+))?$`)
+
+// SplitAmountUnit splits an amount such as "1.5 ether" into the number and the lower case unit.
+// The unit is empty when there is none.
+func SplitAmountUnit(amount string) (number, unit string) {
+	fields := strings.Fields(amount)
+	if len(fields) == 2 {
+		unit = strings.ToLower(fields[1])
+		if unit == "tokens" {
+			unit = TokenUnit
+		}
+		return fields[0], unit
+	}
+	return strings.TrimSpace(amount), ""
+}
+
+// UnitDecimals returns how many decimal places a unit has, where tokenDecimals are those of the
+// token contract, or nil when none are configured
+func UnitDecimals(unit string, tokenDecimals *uint8) (int, error) {
+	if unit == TokenUnit {
+		if tokenDecimals == nil {
+			return 0, errors.Errorf(errors.UnitsNoTokenDecimals, unit)
+		}
+		return int(*tokenDecimals), nil
+	}
+	decimals, ok := unitDecimals[unit]
+	if !ok {
+		return 0, errors.Errorf(errors.UnitsUnknown, unit)
+	}
+	return decimals, nil
+}
+
+// ParseAmount converts an integer, or a decimal amount with a unit such as "1.5 ether", "2000 gwei"
+// or "12.5 token", to an integer in the smallest denomination. The amount must convert exactly.
+func ParseAmount(amount string, tokenDecimals *uint8) (*big.Int, error) {
+	number, unit := SplitAmountUnit(amount)
+	decimals := 0
+	if unit != "" {
+		var err error
+		if unit == TokenUnit && tokenDecimals == nil {
+			return nil, errors.Errorf(errors.UnitsNoTokenDecimals, amount)
+		}
+		if decimals, err = UnitDecimals(unit, tokenDecimals); err != nil {
+			return nil, err
+		}
+	}
+	parts := decimalAmount.FindStringSubmatch(number)
+	if parts == nil {
+		return nil, errors.Errorf(errors.UnitsInvalidAmount, amount)
+	}
+	fraction := strings.TrimRight(parts[3], "0")
+	if len(fraction) > decimals {
+		return nil, errors.Errorf(errors.UnitsTooPrecise, amount, decimals, unit)
+	}
+	digits := parts[1] + parts[2] + fraction + strings.Repeat("0", decimals-len(fraction))
+	value, _ := new(big.Int).SetString(digits, 10)
+	return value, nil
+}
+
+// FormatAmount formats an integer in the smallest denomination as a decimal number of the unit,
+// with no trailing zeros
+func FormatAmount(value *big.Int, decimals int) string {
+	digits := new(big.Int).Abs(value).String()
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	whole, fraction := digits[:len(digits)-decimals], strings.TrimRight(digits[len(digits)-decimals:], "0")
+	s := whole
+	if fraction != "" {
+		s += "." + fraction
+	}
+	if value.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// ConvertAmountUnits replaces every amount with a unit in a parameter for an integer input, or an
+// array or tuple of them, with the integer it converts to. Other values are returned unchanged,
+// and are validated when the transaction is built.
+func ConvertAmountUnits(t *ethbinding.ABIType, param interface{}, tokenDecimals *uint8) (interface{}, error) {
+	switch t.T {
+	case ethbinding.IntTy, ethbinding.UintTy:
+		if s, ok := param.(string); ok {
+			if _, unit := SplitAmountUnit(s); unit != "" {
+				value, err := ParseAmount(s, tokenDecimals)
+				if err != nil {
+					return nil, err
+				}
+				return value.String(), nil
+			}
+		}
+	case ethbinding.SliceTy, ethbinding.ArrayTy:
+		if params, ok := param.([]interface{}); ok {
+			converted := make([]interface{}, len(params))
+			for i, p := range params {
+				var err error
+				if converted[i], err = ConvertAmountUnits(t.Elem, p, tokenDecimals); err != nil {
+					return nil, err
+				}
+			}
+			return converted, nil
+		}
+	case ethbinding.TupleTy:
+		if params, ok := param.(map[string]interface{}); ok {
+			converted := make(map[string]interface{}, len(params))
+			for k, v := range params {
+				converted[k] = v
+			}
+			for i, name := range t.TupleRawNames {
+				if v, ok := params[name]; ok {
+					var err error
+					if converted[name], err = ConvertAmountUnits(t.TupleElems[i], v, tokenDecimals); err != nil {
+						return nil, err
+					}
+				}
+			}
+			return converted, nil
+		}
+	}
+	return param, nil
+}
+
+// FormatOutputUnits formats the integer outputs wider than 64 bits, which is how amounts are
+// declared, as decimal numbers of the unit. Narrower integers such as counts are left unchanged.
+func FormatOutputUnits(args ethbinding.ABIArguments, retval map[string]interface{}, decimals int) {
+	for idx, output := range args {
+		if (output.Type.T != ethbinding.IntTy && output.Type.T != ethbinding.UintTy) || output.Type.Size <= 64 {
+			continue
+		}
+		argName := output.Name
+		if argName == "" {
+			argName = "output"
+			if idx != 0 {
+				argName += strconv.Itoa(idx)
+			}
+		}
+		if s, ok := retval[argName].(string); ok {
+			if value, ok := new(big.Int).SetString(s, 10); ok {
+				retval[argName] = FormatAmount(value, decimals)
+			}
+		}
+	}
+}
+
+// parseAmountParam parses a string integer input that is not a plain integer, as an amount with a unit
+func parseAmountParam(methodName, path, s string) (*big.Int, error) {
+	if _, unit := SplitAmountUnit(s); unit == "" {
+		return nil, errors.Errorf(errors.TransactionSendInputTypeBadNumber, methodName, path)
+	}
+	return ParseAmount(s, nil)
+}
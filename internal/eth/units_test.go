@@ -0,0 +1,158 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eth
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAmount(t *testing.T) {
+	assert := assert.New(t)
+	six := uint8(6)
+
+	for amount, expected := range map[string]string{
+		"12345":            "12345",
+		"1.5 ether":        "1500000000000000000",
+		"2000 GWEI":        "2000000000000",
+		"0.10 finney":      "100000000000000",
+		"-3 kwei":          "-3000",
+		"12.5 tokens":      "12500000",
+		"  7 wei  ":        "7",
+		"1.000000 token":   "1000000",
+		"0.000001 token":   "1",
+		"99999999999 mwei": "99999999999000000",
+	} {
+		v, err := ParseAmount(amount, &six)
+		assert.NoError(err, amount)
+		assert.Equal(expected, v.String(), amount)
+	}
+
+	_, err := ParseAmount("1.5 wei", nil)
+	assert.Regexp("FFEC100435", err)
+	_, err = ParseAmount("1.5", nil)
+	assert.Regexp("FFEC100435", err)
+	_, err = ParseAmount("1e18 wei", nil)
+	assert.Regexp("FFEC100433", err)
+	_, err = ParseAmount("1 bitcoin", nil)
+	assert.Regexp("FFEC100434", err)
+	_, err = ParseAmount("1 token", nil)
+	assert.Regexp("FFEC100436", err)
+}
+
+func TestFormatAmount(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("1.5", FormatAmount(big.NewInt(1500000000000000000), 18))
+	assert.Equal("0.000001", FormatAmount(big.NewInt(1000000000000), 18))
+	assert.Equal("-0.25", FormatAmount(big.NewInt(-250), 3))
+	assert.Equal("42", FormatAmount(big.NewInt(42), 0))
+	assert.Equal("0", FormatAmount(big.NewInt(0), 6))
+	assert.Equal("2", FormatAmount(big.NewInt(2000000), 6))
+}
+
+func testABIType(t *testing.T, arg string) *ethbinding.ABIType {
+	var args ethbinding.ABIArguments
+	err := json.Unmarshal([]byte(`[`+arg+`]`), &args)
+	assert.NoError(t, err)
+	return &args[0].Type
+}
+
+func TestConvertAmountUnits(t *testing.T) {
+	assert := assert.New(t)
+	two := uint8(2)
+
+	uintType := testABIType(t, `{"type":"uint256"}`)
+	v, err := ConvertAmountUnits(uintType, "1 gwei", nil)
+	assert.NoError(err)
+	assert.Equal("1000000000", v)
+	v, err = ConvertAmountUnits(uintType, float64(10), nil)
+	assert.NoError(err)
+	assert.Equal(float64(10), v)
+	_, err = ConvertAmountUnits(uintType, "1 token", nil)
+	assert.Regexp("FFEC100436", err)
+
+	sliceType := testABIType(t, `{"type":"uint256[]"}`)
+	v, err = ConvertAmountUnits(sliceType, []interface{}{"1.5 token", "7"}, &two)
+	assert.NoError(err)
+	assert.Equal([]interface{}{"150", "7"}, v)
+	_, err = ConvertAmountUnits(sliceType, []interface{}{"1.555 token"}, &two)
+	assert.Regexp("FFEC100435", err)
+
+	tupleType := testABIType(t, `{"type":"tuple","components":[{"name":"amount","type":"uint256"},{"name":"memo","type":"string"}]}`)
+	v, err = ConvertAmountUnits(tupleType, map[string]interface{}{"amount": "3 kwei", "memo": "5 wei"}, nil)
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{"amount": "3000", "memo": "5 wei"}, v)
+	_, err = ConvertAmountUnits(tupleType, map[string]interface{}{"amount": "x wei"}, nil)
+	assert.Regexp("FFEC100433", err)
+
+	stringType := testABIType(t, `{"type":"string"}`)
+	v, err = ConvertAmountUnits(stringType, "1 ether", nil)
+	assert.NoError(err)
+	assert.Equal("1 ether", v)
+}
+
+func TestFormatOutputUnits(t *testing.T) {
+	var outputs ethbinding.ABIArguments
+	err := json.Unmarshal([]byte(`[{"type":"uint256"},{"type":"uint8"},{"type":"int128"}]`), &outputs)
+	assert.NoError(t, err)
+	retval := map[string]interface{}{"output": "1500", "output1": "18", "output2": "-5"}
+	FormatOutputUnits(outputs, retval, 3)
+	assert.Equal(t, map[string]interface{}{"output": "1.5", "output1": "18", "output2": "-0.005"}, retval)
+}
+
+func TestIntegerParamsWithUnits(t *testing.T) {
+	assert := assert.New(t)
+	tx := &Txn{}
+	stringType := reflect.TypeOf("")
+
+	bigInt, err := tx.getBigInteger("m", "p", nil, stringType, "2 gwei")
+	assert.NoError(err)
+	assert.Equal("2000000000", bigInt.String())
+	_, err = tx.getBigInteger("m", "p", nil, stringType, "abc")
+	assert.Regexp("FFEC100160", err)
+
+	u, err := tx.getUnsignedInteger("m", "p", nil, stringType, "5 kwei")
+	assert.NoError(err)
+	assert.Equal(uint64(5000), u)
+	_, err = tx.getUnsignedInteger("m", "p", nil, stringType, "-5 kwei")
+	assert.Regexp("FFEC100160", err)
+
+	i, err := tx.getInteger("m", "p", nil, stringType, "-5 kwei")
+	assert.NoError(err)
+	assert.Equal(int64(-5000), i)
+	_, err = tx.getInteger("m", "p", nil, stringType, "100 ether")
+	assert.Regexp("FFEC100160", err)
+	_, err = tx.getInteger("m", "p", nil, stringType, "1.5 wei")
+	assert.Regexp("FFEC100435", err)
+}
+
+func TestGenEthTransactionWithUnits(t *testing.T) {
+	assert := assert.New(t)
+	tx := &Txn{}
+	err := tx.genEthTransaction("0xAA983AD2a0e0eD8ac639277F37be42F2A5d2618c", "", "1", "1.5 ether", "100000", "2 gwei", nil)
+	assert.NoError(err)
+	assert.Equal("1500000000000000000", tx.EthTX.Value().String())
+	assert.Equal("2000000000", tx.EthTX.GasPrice().String())
+
+	err = tx.genEthTransaction("0xAA983AD2a0e0eD8ac639277F37be42F2A5d2618c", "", "1", "1 bitcoin", "100000", "", nil)
+	assert.Regexp("FFEC100434", err)
+	err = tx.genEthTransaction("0xAA983AD2a0e0eD8ac639277F37be42F2A5d2618c", "", "1", "", "100000", "0.5 wei", nil)
+	assert.Regexp("FFEC100435", err)
+}
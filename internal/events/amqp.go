@@ -0,0 +1,265 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type amqpActionInfo struct {
+	URL               string `json:"url,omitempty"`
+	Address           string `json:"address,omitempty"`
+	Username          string `json:"username,omitempty"`
+	Password          string `json:"password,omitempty"`
+	TLSkipHostVerify  bool   `json:"tlsSkipHostVerify,omitempty"`
+	RequestTimeoutSec uint32 `json:"requestTimeoutSec,omitempty"`
+}
+
+// amqpSender is the part of an AMQP connection used by the action, so tests can replace it
+type amqpSender interface {
+	Send(ctx context.Context, msgs ...*amqp.Message) error
+	Close()
+}
+
+// amqpAction holds a connection open to the broker between batches, and drops it on any failure so
+// the next attempt reconnects
+type amqpAction struct {
+	es     *eventStream
+	spec   *amqpActionInfo
+	dial   func(ctx context.Context, spec *amqpActionInfo) (amqpSender, error)
+	mux    sync.Mutex
+	sender amqpSender
+	closed bool
+}
+
+// amqpConnection is a connection to the broker, with a single session and sender link
+type amqpConnection struct {
+	host   string
+	conn   *amqp.Conn
+	sender *amqp.Sender
+}
+
+func validateAMQP(spec *amqpActionInfo) error {
+	if spec == nil || spec.URL == "" || spec.Address == "" {
+		return errors.Errorf(errors.EventStreamsAMQPNoAddress)
+	}
+	if u, err := url.Parse(spec.URL); err != nil || (u.Scheme != "amqp" && u.Scheme != "amqps") || u.Host == "" {
+		return errors.Errorf(errors.AMQPInvalidURL, spec.URL)
+	}
+	return nil
+}
+
+func newAMQPAction(es *eventStream, spec *amqpActionInfo) (*amqpAction, error) {
+	if err := validateAMQP(spec); err != nil {
+		return nil, err
+	}
+	if spec.RequestTimeoutSec == 0 {
+		spec.RequestTimeoutSec = 120
+	}
+	return &amqpAction{
+		es:   es,
+		spec: spec,
+		dial: dialAMQP,
+	}, nil
+}
+
+// dialAMQP connects to the broker and attaches a sender link to the address. Credentials in the
+// stream take precedence over any in the URL, and SASL ANONYMOUS is used when there are none.
+func dialAMQP(ctx context.Context, spec *amqpActionInfo) (amqpSender, error) {
+	u, err := url.Parse(spec.URL)
+	if err != nil {
+		return nil, errors.Errorf(errors.AMQPInvalidURL, spec.URL)
+	}
+	username, password := spec.Username, spec.Password
+	if username == "" && u.User != nil {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+	u.User = nil
+	opts := &amqp.ConnOptions{
+		ContainerID: "ethconnect-" + utils.UUIDv4(),
+		SASLType:    amqp.SASLTypeAnonymous(),
+	}
+	if username != "" {
+		opts.SASLType = amqp.SASLTypePlain(username, password)
+	}
+	if u.Scheme == "amqps" {
+		if opts.TLSConfig, err = utils.CreateTLSConfiguration(&utils.TLSConfig{
+			Enabled:            true,
+			InsecureSkipVerify: spec.TLSkipHostVerify,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	host := u.Host
+	conn, err := amqp.Dial(ctx, u.String(), opts)
+	if err != nil {
+		return nil, errors.Errorf(errors.AMQPConnectFailed, host, err)
+	}
+	session, err := conn.NewSession(ctx, nil)
+	if err == nil {
+		var sender *amqp.Sender
+		if sender, err = session.NewSender(ctx, spec.Address, nil); err == nil {
+			log.Infof("AMQP connected to %s address=%s", host, spec.Address)
+			return &amqpConnection{host: host, conn: conn, sender: sender}, nil
+		}
+	}
+	conn.Close()
+	return nil, errors.Errorf(errors.AMQPConnectFailed, host, err)
+}
+
+// Send transfers each of the messages in turn, then waits for the broker to settle all of them.
+// The first message that is not accepted is returned as an error.
+func (c *amqpConnection) Send(ctx context.Context, msgs ...*amqp.Message) error {
+	receipts := make([]amqp.SendReceipt, len(msgs))
+	for i, msg := range msgs {
+		var err error
+		if receipts[i], err = c.sender.SendWithReceipt(ctx, msg, nil); err != nil {
+			return c.sendError(ctx, err)
+		}
+	}
+	for i, receipt := range receipts {
+		state, err := receipt.Wait(ctx)
+		if err != nil {
+			return c.sendError(ctx, err)
+		}
+		messageID := msgs[i].Properties.MessageID
+		switch state := state.(type) {
+		case *amqp.StateRejected:
+			return errors.Errorf(errors.AMQPDeliveryRejected, messageID, state.Error)
+		case *amqp.StateReleased, *amqp.StateModified:
+			return errors.Errorf(errors.AMQPDeliveryReleased, messageID)
+		}
+	}
+	return nil
+}
+
+func (c *amqpConnection) sendError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return errors.Errorf(errors.AMQPTimeout, c.host)
+	}
+	return err
+}
+
+func (c *amqpConnection) Close() {
+	_ = c.conn.Close()
+}
+
+// buildMessages creates a message for each event. The message ID identifies the event, so brokers
+// with duplicate detection (such as Azure Service Bus) can discard redelivered batches.
+func (a *amqpAction) buildMessages(events []*eventData) ([]*amqp.Message, error) {
+	msgs := make([]*amqp.Message, len(events))
+	for i, event := range events {
+		b, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		subject, contentType := event.Signature, "application/json"
+		msgs[i] = &amqp.Message{
+			Header: &amqp.MessageHeader{
+				Durable: true,
+			},
+			Properties: &amqp.MessageProperties{
+				MessageID:   event.SubID + ":" + event.TransactionHash + ":" + event.LogIndex,
+				Subject:     &subject,
+				ContentType: &contentType,
+			},
+			ApplicationProperties: map[string]interface{}{
+				"streamId":        a.es.spec.ID,
+				"subId":           event.SubID,
+				"signature":       event.Signature,
+				"address":         event.Address,
+				"blockNumber":     event.BlockNumber,
+				"transactionHash": event.TransactionHash,
+				"logIndex":        event.LogIndex,
+			},
+			Data: [][]byte{b},
+		}
+	}
+	return msgs, nil
+}
+
+func (a *amqpAction) connect(ctx context.Context) (amqpSender, error) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.closed {
+		return nil, errors.Errorf(errors.AMQPConnectionClosed)
+	}
+	if a.sender == nil {
+		sender, err := a.dial(ctx, a.spec)
+		if err != nil {
+			return nil, err
+		}
+		a.sender = sender
+	}
+	return a.sender, nil
+}
+
+func (a *amqpAction) disconnect(sender amqpSender) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.sender == sender {
+		a.sender = nil
+	}
+	sender.Close()
+}
+
+// close is called when the stream is stopped
+func (a *amqpAction) close() {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.closed = true
+	if a.sender != nil {
+		a.sender.Close()
+		a.sender = nil
+	}
+}
+
+// attemptBatch sends every event of the batch, and only succeeds once the broker has accepted all
+// of them. A batch that is retried may deliver some events more than once.
+func (a *amqpAction) attemptBatch(batchNumber, attempt uint64, events []*eventData) error {
+	esID := a.es.spec.ID
+	log.Infof("%s: AMQP send --> %s batch=%d events=%d (attempt=%d)", esID, a.spec.Address, batchNumber, len(events), attempt)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(a.spec.RequestTimeoutSec)*time.Second)
+	defer cancel()
+
+	msgs, err := a.buildMessages(events)
+	var sender amqpSender
+	if err == nil {
+		sender, err = a.connect(ctx)
+	}
+	if err == nil {
+		if err = sender.Send(ctx, msgs...); err != nil {
+			a.disconnect(sender)
+		}
+	}
+	if err != nil {
+		log.Errorf("%s: AMQP send to %s failed (attempt=%d): %s", esID, a.spec.Address, attempt, err)
+		return err
+	}
+	log.Infof("%s: AMQP send <-- %s batch=%d accepted", esID, a.spec.Address, batchNumber)
+	return nil
+}
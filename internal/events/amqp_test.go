@@ -0,0 +1,144 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/Azure/go-amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+type testAMQPSender struct {
+	sent   [][]*amqp.Message
+	err    error
+	closed bool
+}
+
+func (s *testAMQPSender) Send(ctx context.Context, msgs ...*amqp.Message) error {
+	s.sent = append(s.sent, msgs)
+	return s.err
+}
+
+func (s *testAMQPSender) Close() {
+	s.closed = true
+}
+
+func TestAMQPStreamSends(t *testing.T) {
+	assert := assert.New(t)
+
+	sm := newTestSubscriptionManager()
+	spec, err := sm.AddStream(context.Background(), &StreamInfo{
+		Type: "AMQP",
+		AMQP: &amqpActionInfo{
+			URL:     "amqps://bus1.servicebus.windows.net",
+			Address: "events",
+		},
+	})
+	assert.NoError(err)
+	defer sm.Close(true)
+	assert.Equal(uint32(120), spec.AMQP.RequestTimeoutSec)
+
+	var senders []*testAMQPSender
+	action := sm.streams[spec.ID].action.(*amqpAction)
+	action.dial = func(ctx context.Context, spec *amqpActionInfo) (amqpSender, error) {
+		s := &testAMQPSender{}
+		senders = append(senders, s)
+		return s, nil
+	}
+
+	err = action.attemptBatch(1, 1, testPubSubEvents())
+	assert.NoError(err)
+	err = action.attemptBatch(2, 1, testPubSubEvents())
+	assert.NoError(err)
+	assert.Len(senders, 1)
+	assert.Len(senders[0].sent, 2)
+	msg := senders[0].sent[0][0]
+	assert.Equal("sb-1:0xd2d4c7f4b2b1e4f6c5a3e7d9b1c3a5e7f9b1d3c5a7e9f1b3d5c7a9e1f3b5d7c9:2", msg.Properties.MessageID)
+	assert.Equal("Changed(uint256)", *msg.Properties.Subject)
+	assert.Equal("application/json", *msg.Properties.ContentType)
+	assert.True(msg.Header.Durable)
+	assert.Equal(spec.ID, msg.ApplicationProperties["streamId"])
+	assert.Equal("0x167f57a13a9c35ff92f0649d2be0e52b4f8ac3ca", msg.ApplicationProperties["address"])
+	var event eventData
+	json.Unmarshal(msg.GetData(), &event)
+	assert.Equal("10", event.Data["i"])
+
+	// A failed send drops the connection, and the next attempt reconnects
+	senders[0].err = fmt.Errorf("pop")
+	err = action.attemptBatch(3, 1, testPubSubEvents())
+	assert.Regexp("pop", err)
+	assert.True(senders[0].closed)
+	err = action.attemptBatch(3, 2, testPubSubEvents())
+	assert.NoError(err)
+	assert.Len(senders, 2)
+
+	action.dial = func(ctx context.Context, spec *amqpActionInfo) (amqpSender, error) {
+		return nil, fmt.Errorf("pop")
+	}
+	sm.streams[spec.ID].stop(false)
+	assert.True(senders[1].closed)
+	err = action.attemptBatch(4, 1, testPubSubEvents())
+	assert.Regexp("FFEC100418", err)
+}
+
+func TestAMQPValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	es := &eventStream{spec: &StreamInfo{ID: "es-1"}}
+	_, err := newAMQPAction(es, nil)
+	assert.Regexp("FFEC100412", err)
+	_, err = newAMQPAction(es, &amqpActionInfo{URL: "amqp://localhost"})
+	assert.Regexp("FFEC100412", err)
+	_, err = newAMQPAction(es, &amqpActionInfo{URL: "http://localhost", Address: "events"})
+	assert.Regexp("FFEC100413", err)
+
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := l.Addr().String()
+	l.Close()
+	a, err := newAMQPAction(es, &amqpActionInfo{URL: "amqp://" + addr, Address: "events", RequestTimeoutSec: 1})
+	assert.NoError(err)
+	err = a.attemptBatch(1, 1, testPubSubEvents())
+	assert.Regexp("FFEC100414", err)
+
+	a, err = newAMQPAction(es, &amqpActionInfo{URL: "amqps://user:pass@" + addr, Address: "events", RequestTimeoutSec: 1})
+	assert.NoError(err)
+	err = a.attemptBatch(1, 1, testPubSubEvents())
+	assert.Regexp("FFEC100414", err)
+}
+
+func TestAMQPStreamUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	sm := newTestSubscriptionManager()
+	ctx := context.Background()
+	spec, err := sm.AddStream(ctx, &StreamInfo{
+		Type: "amqp",
+		AMQP: &amqpActionInfo{URL: "amqp://localhost", Address: "events"},
+	})
+	assert.NoError(err)
+	defer sm.Close(true)
+
+	updated, err := sm.UpdateStream(ctx, spec.ID, &StreamInfo{
+		AMQP: &amqpActionInfo{RequestTimeoutSec: 10},
+	})
+	assert.NoError(err)
+	assert.Equal(uint32(10), updated.AMQP.RequestTimeoutSec)
+	assert.Equal("events", updated.AMQP.Address)
+}
@@ -0,0 +1,351 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	awsServiceSQS = "sqs"
+	awsServiceSNS = "sns"
+
+	// SQS and SNS both limit a batch request to 10 messages, and 256KiB in total
+	awsMaxBatchEntries       = 10
+	awsMaxBatchBytes         = 256 * 1024
+	awsMaxDeduplicationIDLen = 128
+)
+
+type awsActionInfo struct {
+	QueueURL          string `json:"queueUrl,omitempty"`
+	TopicARN          string `json:"topicArn,omitempty"`
+	Region            string `json:"region,omitempty"`
+	Endpoint          string `json:"endpoint,omitempty"`
+	RequestTimeoutSec uint32 `json:"requestTimeoutSec,omitempty"`
+}
+
+type awsMessage struct {
+	eventID    string
+	body       string
+	groupID    string
+	dedupID    string
+	attributes map[string]string
+	size       int
+}
+
+// awsFailedEntry is a message rejected by SendMessageBatch or PublishBatch
+type awsFailedEntry struct {
+	id      string
+	code    string
+	message string
+}
+
+// awsAction sends events to an SQS queue, or publishes them to an SNS topic, with the AWS SDK.
+// Credentials are found by the default chain of the SDK, when the first batch is sent.
+type awsAction struct {
+	es      *eventStream
+	spec    *awsActionInfo
+	service string
+	target  string
+	region  string
+	fifo    bool
+	sqs     *sqs.Client
+	sns     *sns.Client
+}
+
+func validateSQS(spec *awsActionInfo) error {
+	if spec == nil || spec.QueueURL == "" {
+		return errors.Errorf(errors.EventStreamsSQSNoQueue)
+	}
+	if u, err := url.Parse(spec.QueueURL); err != nil || u.Host == "" {
+		return errors.Errorf(errors.EventStreamsSQSNoQueue)
+	}
+	return nil
+}
+
+func validateSNS(spec *awsActionInfo) error {
+	if spec == nil || !strings.HasPrefix(spec.TopicARN, "arn:") || len(strings.Split(spec.TopicARN, ":")) != 6 {
+		return errors.Errorf(errors.EventStreamsSNSNoTopic)
+	}
+	return nil
+}
+
+// awsRegionOf finds the region in the host of a queue URL such as
+// https://sqs.eu-west-1.amazonaws.com/123456789012/queue1, or in a topic ARN
+func awsRegionOf(service, target string) string {
+	if service == awsServiceSNS {
+		return strings.Split(target, ":")[3]
+	}
+	u, _ := url.Parse(target)
+	parts := strings.Split(u.Hostname(), ".")
+	if len(parts) >= 4 && parts[0] == awsServiceSQS && parts[len(parts)-2] == "amazonaws" {
+		return parts[1]
+	}
+	return ""
+}
+
+func newAWSAction(es *eventStream, service string, spec *awsActionInfo) (*awsAction, error) {
+	var err error
+	var target string
+	if service == awsServiceSQS {
+		err = validateSQS(spec)
+	} else {
+		err = validateSNS(spec)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if spec.RequestTimeoutSec == 0 {
+		spec.RequestTimeoutSec = 120
+	}
+	if service == awsServiceSQS {
+		target = spec.QueueURL
+	} else {
+		target = spec.TopicARN
+	}
+	a := &awsAction{
+		es:      es,
+		spec:    spec,
+		service: service,
+		target:  target,
+		region:  spec.Region,
+		// FIFO queues and topics are identified by their name
+		fifo: strings.HasSuffix(target, ".fifo"),
+	}
+	for _, region := range []string{awsRegionOf(service, target), os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION")} {
+		if a.region == "" {
+			a.region = region
+		}
+	}
+	if a.region == "" {
+		return nil, errors.Errorf(errors.EventStreamsAWSNoRegion, target)
+	}
+	awsConf, err := utils.NewAWSConfig(context.Background(), a.region, time.Duration(spec.RequestTimeoutSec)*time.Second, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var endpoint *string
+	if spec.Endpoint != "" {
+		endpoint = aws.String(spec.Endpoint)
+	}
+	if service == awsServiceSQS {
+		a.sqs = sqs.NewFromConfig(awsConf, func(o *sqs.Options) { o.BaseEndpoint = endpoint })
+	} else {
+		a.sns = sns.NewFromConfig(awsConf, func(o *sns.Options) { o.BaseEndpoint = endpoint })
+	}
+	return a, nil
+}
+
+// buildMessages creates a message for each event. On a FIFO queue or topic every event of a
+// subscription is in one message group, so they are delivered in order, and the deduplication ID
+// identifies the event so that any redelivered by a retry of the batch are discarded.
+func (a *awsAction) buildMessages(events []*eventData) ([]*awsMessage, error) {
+	msgs := make([]*awsMessage, len(events))
+	for i, event := range events {
+		b, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		msg := &awsMessage{
+			eventID: event.SubID + ":" + event.TransactionHash + ":" + event.LogIndex,
+			body:    string(b),
+			attributes: map[string]string{
+				"streamId":        a.es.spec.ID,
+				"subId":           event.SubID,
+				"signature":       event.Signature,
+				"address":         event.Address,
+				"blockNumber":     event.BlockNumber,
+				"transactionHash": event.TransactionHash,
+				"logIndex":        event.LogIndex,
+			},
+		}
+		if a.fifo {
+			msg.groupID = event.SubID
+			msg.dedupID = msg.eventID
+			if len(msg.dedupID) > awsMaxDeduplicationIDLen {
+				hash := sha256.Sum256([]byte(msg.dedupID))
+				msg.dedupID = hex.EncodeToString(hash[:])
+			}
+		}
+		msg.size = len(msg.body)
+		for name, value := range msg.attributes {
+			// Empty attribute values are rejected, so they are omitted
+			if value == "" {
+				delete(msg.attributes, name)
+				continue
+			}
+			msg.size += len(name) + len("String") + len(value)
+		}
+		if msg.size > awsMaxBatchBytes {
+			return nil, errors.Errorf(errors.EventStreamsAWSMessageTooLarge, a.es.spec.ID, msg.eventID, msg.size, awsMaxBatchBytes)
+		}
+		msgs[i] = msg
+	}
+	return msgs, nil
+}
+
+// splitAWSBatches splits the messages, in order, into requests within the limits of the service
+func splitAWSBatches(msgs []*awsMessage) [][]*awsMessage {
+	var batches [][]*awsMessage
+	var batch []*awsMessage
+	size := 0
+	for _, msg := range msgs {
+		if len(batch) == awsMaxBatchEntries || (len(batch) > 0 && size+msg.size > awsMaxBatchBytes) {
+			batches = append(batches, batch)
+			batch, size = nil, 0
+		}
+		batch = append(batch, msg)
+		size += msg.size
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+func (a *awsAction) sendSQSBatch(ctx context.Context, msgs []*awsMessage) ([]awsFailedEntry, error) {
+	input := &sqs.SendMessageBatchInput{
+		QueueUrl: aws.String(a.spec.QueueURL),
+		Entries:  make([]sqstypes.SendMessageBatchRequestEntry, len(msgs)),
+	}
+	for i, msg := range msgs {
+		input.Entries[i] = sqstypes.SendMessageBatchRequestEntry{
+			Id:                     aws.String(strconv.Itoa(i)),
+			MessageBody:            aws.String(msg.body),
+			MessageGroupId:         optionalString(msg.groupID),
+			MessageDeduplicationId: optionalString(msg.dedupID),
+			MessageAttributes:      make(map[string]sqstypes.MessageAttributeValue),
+		}
+		for name, value := range msg.attributes {
+			input.Entries[i].MessageAttributes[name] = sqstypes.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+		}
+	}
+	res, err := a.sqs.SendMessageBatch(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	failed := make([]awsFailedEntry, len(res.Failed))
+	for i, entry := range res.Failed {
+		failed[i] = awsFailedEntry{aws.ToString(entry.Id), aws.ToString(entry.Code), aws.ToString(entry.Message)}
+	}
+	return failed, nil
+}
+
+func (a *awsAction) sendSNSBatch(ctx context.Context, msgs []*awsMessage) ([]awsFailedEntry, error) {
+	input := &sns.PublishBatchInput{
+		TopicArn:                   aws.String(a.spec.TopicARN),
+		PublishBatchRequestEntries: make([]snstypes.PublishBatchRequestEntry, len(msgs)),
+	}
+	for i, msg := range msgs {
+		input.PublishBatchRequestEntries[i] = snstypes.PublishBatchRequestEntry{
+			Id:                     aws.String(strconv.Itoa(i)),
+			Message:                aws.String(msg.body),
+			MessageGroupId:         optionalString(msg.groupID),
+			MessageDeduplicationId: optionalString(msg.dedupID),
+			MessageAttributes:      make(map[string]snstypes.MessageAttributeValue),
+		}
+		for name, value := range msg.attributes {
+			input.PublishBatchRequestEntries[i].MessageAttributes[name] = snstypes.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+		}
+	}
+	res, err := a.sns.PublishBatch(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	failed := make([]awsFailedEntry, len(res.Failed))
+	for i, entry := range res.Failed {
+		failed[i] = awsFailedEntry{aws.ToString(entry.Id), aws.ToString(entry.Code), aws.ToString(entry.Message)}
+	}
+	return failed, nil
+}
+
+func (a *awsAction) sendBatch(msgs []*awsMessage) error {
+	esID := a.es.spec.ID
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(a.spec.RequestTimeoutSec)*time.Second)
+	defer cancel()
+	var failed []awsFailedEntry
+	var err error
+	if a.service == awsServiceSQS {
+		failed, err = a.sendSQSBatch(ctx, msgs)
+	} else {
+		failed, err = a.sendSNSBatch(ctx, msgs)
+	}
+	if err != nil {
+		status, detail := utils.AWSErrorDetail(err)
+		if status == 0 {
+			return err
+		}
+		return errors.Errorf(errors.EventStreamsAWSFailedHTTPStatus, esID, strings.ToUpper(a.service), status, detail)
+	}
+	log.Infof("%s: %s send <-- %s messages=%d failed=%d", esID, strings.ToUpper(a.service), a.target, len(msgs), len(failed))
+	if len(failed) > 0 {
+		reasons := make([]string, len(failed))
+		for i, entry := range failed {
+			eventID := entry.id
+			if idx, err := strconv.Atoi(entry.id); err == nil && idx >= 0 && idx < len(msgs) {
+				eventID = msgs[idx].eventID
+			}
+			reasons[i] = eventID + " " + entry.code + ": " + entry.message
+		}
+		return errors.Errorf(errors.EventStreamsAWSEntriesFailed, esID, len(failed), len(msgs), a.target, strings.Join(reasons, ", "))
+	}
+	return nil
+}
+
+// attemptBatch sends the events in requests of up to 10 messages, in order. Any rejected message
+// fails the attempt, so the whole batch is retried - on a FIFO queue or topic the messages that
+// were accepted the first time are discarded as duplicates.
+func (a *awsAction) attemptBatch(batchNumber, attempt uint64, events []*eventData) error {
+	esID := a.es.spec.ID
+	log.Infof("%s: %s send --> %s batch=%d events=%d (attempt=%d)", esID, strings.ToUpper(a.service), a.target, batchNumber, len(events), attempt)
+
+	msgs, err := a.buildMessages(events)
+	if err == nil {
+		for _, batch := range splitAWSBatches(msgs) {
+			if err = a.sendBatch(batch); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		log.Errorf("%s: %s send to %s failed (attempt=%d): %s", esID, strings.ToUpper(a.service), a.target, attempt, err)
+	}
+	return err
+}
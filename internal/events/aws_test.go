@@ -0,0 +1,334 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var awsTestEnvVars = []string{
+	"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE",
+	"AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_ROLE_ARN", "AWS_ROLE_SESSION_NAME", "AWS_ENDPOINT_URL_STS",
+	"AWS_REGION", "AWS_DEFAULT_REGION", "AWS_EC2_METADATA_DISABLED",
+}
+
+// setTestAWSEnv replaces all the AWS environment variables, returning a function to restore them.
+// The instance metadata service is never used in tests.
+func setTestAWSEnv(env map[string]string) func() {
+	saved := make(map[string]string)
+	for _, name := range awsTestEnvVars {
+		saved[name] = os.Getenv(name)
+		os.Setenv(name, env[name])
+	}
+	os.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	return func() {
+		for name, value := range saved {
+			os.Setenv(name, value)
+		}
+	}
+}
+
+// testSQSBatch is the JSON body of a SendMessageBatch request
+type testSQSBatch struct {
+	QueueURL string `json:"QueueUrl"`
+	Entries  []struct {
+		ID                     string `json:"Id"`
+		MessageBody            string
+		MessageGroupID         string `json:"MessageGroupId"`
+		MessageDeduplicationID string `json:"MessageDeduplicationId"`
+		MessageAttributes      map[string]struct {
+			DataType    string
+			StringValue string
+		}
+	}
+}
+
+// testAWSServer responds to SQS requests with the JSON protocol, and SNS requests with the query protocol
+type testAWSServer struct {
+	*httptest.Server
+	mux      sync.Mutex
+	status   int
+	response string
+	sqs      []*testSQSBatch
+	forms    []url.Values
+	auth     []string
+}
+
+func newTestAWSServer() *testAWSServer {
+	s := &testAWSServer{status: 200}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		s.mux.Lock()
+		defer s.mux.Unlock()
+		s.auth = append(s.auth, req.Header.Get("Authorization"))
+		sqsTarget := req.Header.Get("X-Amz-Target")
+		if sqsTarget != "" {
+			var batch testSQSBatch
+			b, _ := ioutil.ReadAll(req.Body)
+			json.Unmarshal(b, &batch)
+			s.sqs = append(s.sqs, &batch)
+			res.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		} else {
+			req.ParseForm()
+			s.forms = append(s.forms, req.PostForm)
+		}
+		res.WriteHeader(s.status)
+		switch {
+		case s.response != "":
+			res.Write([]byte(s.response))
+		case sqsTarget == "AmazonSQS.SendMessageBatch":
+			res.Write([]byte(`{"Successful":[],"Failed":[]}`))
+		default:
+			res.Write([]byte(`<PublishBatchResponse><PublishBatchResult><Successful></Successful><Failed></Failed></PublishBatchResult></PublishBatchResponse>`))
+		}
+	}))
+	return s
+}
+
+func (s *testAWSServer) requests() ([]*testSQSBatch, []url.Values, []string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	batches, forms, auth := s.sqs, s.forms, s.auth
+	s.sqs, s.forms, s.auth = nil, nil, nil
+	return batches, forms, auth
+}
+
+func testAWSEvents(count int) []*eventData {
+	events := make([]*eventData, count)
+	for i := range events {
+		events[i] = testPubSubEvents()[0]
+		events[i].LogIndex = fmt.Sprintf("%d", i)
+	}
+	return events
+}
+
+func testAWSEnv() func() {
+	return setTestAWSEnv(map[string]string{
+		"AWS_ACCESS_KEY_ID":     "AKIATEST",
+		"AWS_SECRET_ACCESS_KEY": "secret1",
+	})
+}
+
+func TestSQSStreamSendsBatches(t *testing.T) {
+	assert := assert.New(t)
+	defer testAWSEnv()()
+	s := newTestAWSServer()
+	defer s.Close()
+
+	sm := newTestSubscriptionManager()
+	spec, err := sm.AddStream(context.Background(), &StreamInfo{
+		Type: "sqs",
+		SQS: &awsActionInfo{
+			QueueURL: "https://sqs.eu-west-1.amazonaws.com/123456789012/events",
+			Endpoint: s.URL,
+		},
+	})
+	assert.NoError(err)
+	defer sm.Close(true)
+	assert.Equal(uint32(120), spec.SQS.RequestTimeoutSec)
+
+	stream := sm.streams[spec.ID]
+	err = stream.action.attemptBatch(1, 1, testAWSEvents(12))
+	assert.NoError(err)
+
+	batches, _, auth := s.requests()
+	assert.Len(batches, 2)
+	assert.Regexp("^AWS4-HMAC-SHA256 Credential=AKIATEST/[0-9]{8}/eu-west-1/sqs/aws4_request, ", auth[0])
+	batch := batches[0]
+	assert.Equal(spec.SQS.QueueURL, batch.QueueURL)
+	assert.Len(batch.Entries, 10)
+	assert.Equal("0", batch.Entries[0].ID)
+	assert.Equal("9", batch.Entries[9].ID)
+	assert.Empty(batch.Entries[0].MessageGroupID)
+	assert.Equal(spec.ID, batch.Entries[0].MessageAttributes["streamId"].StringValue)
+	assert.Equal("String", batch.Entries[0].MessageAttributes["streamId"].DataType)
+	var event eventData
+	json.Unmarshal([]byte(batch.Entries[0].MessageBody), &event)
+	assert.Equal("10", event.Data["i"])
+	// The remaining events are sent in order in a second request
+	assert.Len(batches[1].Entries, 2)
+	json.Unmarshal([]byte(batches[1].Entries[1].MessageBody), &event)
+	assert.Equal("11", event.LogIndex)
+	assert.Equal("1", batches[1].Entries[1].ID)
+
+	updated, err := sm.UpdateStream(context.Background(), spec.ID, &StreamInfo{
+		SQS: &awsActionInfo{RequestTimeoutSec: 10},
+	})
+	assert.NoError(err)
+	assert.Equal(uint32(10), updated.SQS.RequestTimeoutSec)
+}
+
+func TestSQSFIFOQueue(t *testing.T) {
+	assert := assert.New(t)
+	defer testAWSEnv()()
+	s := newTestAWSServer()
+	defer s.Close()
+
+	es := &eventStream{spec: &StreamInfo{ID: "es-1"}}
+	a, err := newAWSAction(es, awsServiceSQS, &awsActionInfo{
+		QueueURL: "https://sqs.us-east-2.amazonaws.com/123456789012/events.fifo",
+		Endpoint: s.URL,
+	})
+	assert.NoError(err)
+	assert.True(a.fifo)
+	assert.Equal("us-east-2", a.region)
+
+	events := testAWSEvents(2)
+	events[1].SubID = strings.Repeat("s", 100)
+	err = a.attemptBatch(1, 1, events)
+	assert.NoError(err)
+	batches, _, _ := s.requests()
+	entries := batches[0].Entries
+	assert.Equal("sb-1", entries[0].MessageGroupID)
+	assert.Equal("sb-1:"+events[0].TransactionHash+":0", entries[0].MessageDeduplicationID)
+	// Deduplication IDs over the limit are hashed
+	assert.Regexp("^[0-9a-f]{64}$", entries[1].MessageDeduplicationID)
+}
+
+func TestSNSTopicPublishes(t *testing.T) {
+	assert := assert.New(t)
+	defer testAWSEnv()()
+	s := newTestAWSServer()
+	defer s.Close()
+
+	es := &eventStream{spec: &StreamInfo{ID: "es-1"}}
+	a, err := newAWSAction(es, awsServiceSNS, &awsActionInfo{
+		TopicARN: "arn:aws:sns:ap-south-1:123456789012:events.fifo",
+		Endpoint: s.URL,
+	})
+	assert.NoError(err)
+	assert.Equal("ap-south-1", a.region)
+
+	err = a.attemptBatch(1, 1, testAWSEvents(1))
+	assert.NoError(err)
+	_, forms, auth := s.requests()
+	assert.Regexp("/ap-south-1/sns/aws4_request, ", auth[0])
+	form := forms[0]
+	assert.Equal("PublishBatch", form.Get("Action"))
+	assert.Equal("arn:aws:sns:ap-south-1:123456789012:events.fifo", form.Get("TopicArn"))
+	assert.Equal("sb-1", form.Get("PublishBatchRequestEntries.member.1.MessageGroupId"))
+	attrs := make(map[string]string)
+	for i := 1; form.Get(fmt.Sprintf("PublishBatchRequestEntries.member.1.MessageAttributes.entry.%d.Name", i)) != ""; i++ {
+		entry := fmt.Sprintf("PublishBatchRequestEntries.member.1.MessageAttributes.entry.%d.", i)
+		attrs[form.Get(entry+"Name")] = form.Get(entry + "Value.StringValue")
+	}
+	assert.Equal("sb-1", attrs["subId"])
+	assert.NotEmpty(form.Get("PublishBatchRequestEntries.member.1.Message"))
+
+	s.response = `<PublishBatchResponse><PublishBatchResult><Failed><member><Id>0</Id><Code>InternalError</Code><Message>try again</Message><SenderFault>false</SenderFault></member></Failed></PublishBatchResult></PublishBatchResponse>`
+	err = a.attemptBatch(1, 2, testAWSEvents(1))
+	assert.Regexp("FFEC100441.*1 of 1.*sb-1:0x[0-9a-f]+:0 InternalError: try again", err)
+}
+
+func TestAWSActionErrors(t *testing.T) {
+	assert := assert.New(t)
+	defer testAWSEnv()()
+	s := newTestAWSServer()
+	defer s.Close()
+
+	es := &eventStream{spec: &StreamInfo{ID: "es-1"}}
+	a, err := newAWSAction(es, awsServiceSQS, &awsActionInfo{
+		QueueURL: "https://sqs.eu-west-1.amazonaws.com/123456789012/events",
+		Endpoint: s.URL,
+	})
+	assert.NoError(err)
+
+	s.response = `{"Successful":[],"Failed":[{"Id":"1","Code":"InvalidParameterValue","Message":"bad","SenderFault":true}]}`
+	err = a.attemptBatch(1, 1, testAWSEvents(2))
+	assert.Regexp("FFEC100441.*1 of 2.*:1 InvalidParameterValue: bad", err)
+
+	s.status = 403
+	s.response = `{"__type":"com.amazon.coral.service#AccessDeniedException","message":"denied"}`
+	err = a.attemptBatch(1, 2, testAWSEvents(1))
+	assert.Regexp("FFEC100440.*status=403: AccessDeniedException: denied", err)
+
+	s.status = 500
+	s.response = `not json`
+	err = a.attemptBatch(1, 3, testAWSEvents(1))
+	assert.Regexp("FFEC100440.*status=500", err)
+
+	s.status = 200
+	err = a.attemptBatch(1, 4, testAWSEvents(1))
+	assert.Error(err)
+
+	big := testAWSEvents(1)
+	big[0].Data = map[string]interface{}{"big": strings.Repeat("x", awsMaxBatchBytes)}
+	err = a.attemptBatch(1, 5, big)
+	assert.Regexp("FFEC100442", err)
+
+	a, err = newAWSAction(es, awsServiceSQS, &awsActionInfo{
+		QueueURL: "https://sqs.eu-west-1.amazonaws.com/123456789012/events",
+		Endpoint: "http://localhost:1",
+	})
+	assert.NoError(err)
+	err = a.attemptBatch(1, 6, testAWSEvents(1))
+	assert.Error(err)
+
+	// Credentials are not required until a batch is sent
+	setTestAWSEnv(map[string]string{})
+	a, err = newAWSAction(es, awsServiceSNS, &awsActionInfo{
+		TopicARN: "arn:aws:sns:eu-central-1:123456789012:events",
+		Endpoint: s.URL,
+	})
+	assert.NoError(err)
+	err = a.attemptBatch(1, 7, testAWSEvents(1))
+	assert.Error(err)
+}
+
+func TestAWSActionValidation(t *testing.T) {
+	assert := assert.New(t)
+	restore := testAWSEnv()
+	defer restore()
+
+	es := &eventStream{spec: &StreamInfo{ID: "es-1"}}
+	_, err := newAWSAction(es, awsServiceSQS, nil)
+	assert.Regexp("FFEC100437", err)
+	_, err = newAWSAction(es, awsServiceSQS, &awsActionInfo{QueueURL: "events"})
+	assert.Regexp("FFEC100437", err)
+	_, err = newAWSAction(es, awsServiceSNS, &awsActionInfo{TopicARN: "events"})
+	assert.Regexp("FFEC100438", err)
+	_, err = newAWSAction(es, awsServiceSQS, &awsActionInfo{QueueURL: "http://localhost:4566/000000000000/events"})
+	assert.Regexp("FFEC100439", err)
+
+	a, err := newAWSAction(es, awsServiceSQS, &awsActionInfo{QueueURL: "http://localhost:4566/000000000000/events", Region: "us-west-2"})
+	assert.NoError(err)
+	assert.Equal("us-west-2", a.sqs.Options().Region)
+	assert.Nil(a.sqs.Options().BaseEndpoint)
+
+	setTestAWSEnv(map[string]string{"AWS_DEFAULT_REGION": "eu-central-1"})
+	a, err = newAWSAction(es, awsServiceSQS, &awsActionInfo{QueueURL: "http://localhost:4566/000000000000/events"})
+	assert.NoError(err)
+	assert.Equal("eu-central-1", a.region)
+}
+
+func TestSplitAWSBatches(t *testing.T) {
+	msgs := []*awsMessage{{size: 100}, {size: awsMaxBatchBytes - 50}, {size: 10}, {size: awsMaxBatchBytes}}
+	batches := splitAWSBatches(msgs)
+	assert.Len(t, batches, 3)
+	assert.Len(t, batches[0], 1)
+	assert.Len(t, batches[1], 2)
+	assert.Len(t, batches[2], 1)
+}
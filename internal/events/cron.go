@@ -0,0 +1,167 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+)
+
+// cronSchedule is a standard five field cron spec (minute hour day-of-month month day-of-week),
+// or a fixed "@every <duration>" interval. Times are evaluated in UTC.
+type cronSchedule struct {
+	every   time.Duration
+	minutes uint64
+	hours   uint64
+	doms    uint64
+	months  uint64
+	dows    uint64
+	domStar bool
+	dowStar bool
+}
+
+var cronShortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+func parseCronSchedule(spec string) (*cronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(spec[len("@every "):]))
+		if err != nil || d < time.Second {
+			return nil, errors.Errorf(errors.EventStreamsScheduleInvalid, spec)
+		}
+		return &cronSchedule{every: d}, nil
+	}
+	if expanded, ok := cronShortcuts[spec]; ok {
+		spec = expanded
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errors.Errorf(errors.EventStreamsScheduleInvalid, spec)
+	}
+	cs := &cronSchedule{
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+	var err error
+	ranges := []struct {
+		target   *uint64
+		min, max int
+	}{
+		{&cs.minutes, 0, 59},
+		{&cs.hours, 0, 23},
+		{&cs.doms, 1, 31},
+		{&cs.months, 1, 12},
+		{&cs.dows, 0, 7},
+	}
+	for i, r := range ranges {
+		if *r.target, err = parseCronField(fields[i], r.min, r.max); err != nil {
+			return nil, errors.Errorf(errors.EventStreamsScheduleInvalid, spec)
+		}
+	}
+	// Sunday can be 0 or 7
+	if cs.dows&(1<<7) != 0 {
+		cs.dows |= 1
+	}
+	return cs, nil
+}
+
+// parseCronField parses comma separated values, ranges and steps into a bitmask
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[idx+1:]); err != nil || step <= 0 {
+				return 0, errors.Errorf(errors.EventStreamsScheduleInvalid, field)
+			}
+			part = part[:idx]
+		}
+		start, end := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if start, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, errors.Errorf(errors.EventStreamsScheduleInvalid, field)
+			}
+			end = start
+			if len(bounds) == 2 {
+				if end, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, errors.Errorf(errors.EventStreamsScheduleInvalid, field)
+				}
+			} else if step > 1 {
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return 0, errors.Errorf(errors.EventStreamsScheduleInvalid, field)
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (cs *cronSchedule) matchesDay(t time.Time) bool {
+	domMatch := cs.doms&(1<<uint(t.Day())) != 0
+	dowMatch := cs.dows&(1<<uint(t.Weekday())) != 0
+	// As per standard cron, if both are restricted then either can match
+	if !cs.domStar && !cs.dowStar {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// next returns the first time strictly after the supplied time that matches the schedule
+func (cs *cronSchedule) next(after time.Time) time.Time {
+	if cs.every > 0 {
+		return after.Add(cs.every)
+	}
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	// Bound the search to five years, which covers a Feb 29th that falls on a restricted weekday
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if cs.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !cs.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if cs.hours&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if cs.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func cronNext(t *testing.T, spec, after string) string {
+	cs, err := parseCronSchedule(spec)
+	assert.NoError(t, err)
+	afterTime, _ := time.Parse(time.RFC3339, after)
+	next := cs.next(afterTime)
+	if next.IsZero() {
+		return ""
+	}
+	return next.Format(time.RFC3339)
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("2022-01-01T00:01:00Z", cronNext(t, "* * * * *", "2022-01-01T00:00:00Z"))
+	assert.Equal("2022-01-01T00:05:00Z", cronNext(t, "*/5 * * * *", "2022-01-01T00:00:30Z"))
+	assert.Equal("2022-01-01T01:00:00Z", cronNext(t, "@hourly", "2022-01-01T00:00:00Z"))
+	assert.Equal("2022-01-02T00:00:00Z", cronNext(t, "@daily", "2022-01-01T00:00:00Z"))
+	assert.Equal("2022-01-01T09:30:00Z", cronNext(t, "30 9-17 * * *", "2022-01-01T08:59:00Z"))
+	assert.Equal("2022-01-01T17:30:00Z", cronNext(t, "30 9-17 * * *", "2022-01-01T17:29:59Z"))
+	assert.Equal("2022-01-02T09:30:00Z", cronNext(t, "30 9-17 * * *", "2022-01-01T17:30:00Z"))
+	assert.Equal("2022-01-01T00:15:00Z", cronNext(t, "0,15,45 * * * *", "2022-01-01T00:00:00Z"))
+	// 2022-01-01 was a Saturday, so the next Monday is the 3rd
+	assert.Equal("2022-01-03T00:00:00Z", cronNext(t, "0 0 * * 1", "2022-01-01T00:00:00Z"))
+	// Sunday as 7
+	assert.Equal("2022-01-02T00:00:00Z", cronNext(t, "0 0 * * 7", "2022-01-01T00:00:00Z"))
+	// Day of month OR day of week, when both are restricted
+	assert.Equal("2022-01-03T00:00:00Z", cronNext(t, "0 0 15 * 1", "2022-01-01T00:00:00Z"))
+	assert.Equal("2022-03-01T00:00:00Z", cronNext(t, "0 0 1 3 *", "2022-01-01T00:00:00Z"))
+	assert.Equal("2024-02-29T00:00:00Z", cronNext(t, "0 0 29 2 *", "2022-01-01T00:00:00Z"))
+	// Never matches
+	assert.Equal("", cronNext(t, "0 0 31 2 *", "2022-01-01T00:00:00Z"))
+}
+
+func TestCronScheduleEvery(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("2022-01-01T00:00:10Z", cronNext(t, "@every 10s", "2022-01-01T00:00:00Z"))
+}
+
+func TestCronScheduleInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-b * * * *",
+		"*/x * * * *",
+		"@every 10ms",
+		"@every forever",
+		"@fortnightly",
+	} {
+		_, err := parseCronSchedule(spec)
+		assert.Regexp("FFEC100263", err, spec)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"math/big"
 	"net"
 	"net/url"
+	"runtime/debug"
 	"strconv"
 	"strings"
 	"sync"
@@ -28,6 +29,7 @@ import (
 	"github.com/hyperledger/firefly-ethconnect/internal/auth"
 	"github.com/hyperledger/firefly-ethconnect/internal/errors"
 	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
 	"github.com/hyperledger/firefly-ethconnect/internal/ws"
 	ethbinding "github.com/kaleido-io/ethbinding/pkg"
 
@@ -73,8 +75,17 @@ type StreamInfo struct {
 	RetryTimeoutSec      uint64               `json:"retryTimeoutSec,omitempty"`
 	TypoReryDelaySec     uint64               `json:"blockedReryDelaySec,omitempty"`
 	BlockedRetryDelaySec *uint64              `json:"blockedRetryDelaySec,omitempty"`
+	MaxEventsPerSec      uint64               `json:"maxEventsPerSec,omitempty"`
 	Webhook              *webhookActionInfo   `json:"webhook,omitempty"`
 	WebSocket            *webSocketActionInfo `json:"websocket,omitempty"`
+	PubSub               *pubSubActionInfo    `json:"pubsub,omitempty"`
+	AMQP                 *amqpActionInfo      `json:"amqp,omitempty"`
+	NATS                 *natsActionInfo      `json:"nats,omitempty"`
+	SQS                  *awsActionInfo       `json:"sqs,omitempty"`
+	SNS                  *awsActionInfo       `json:"sns,omitempty"`
+	Kafka                *kafkaActionInfo     `json:"kafka,omitempty"`
+	MQTT                 *mqttActionInfo      `json:"mqtt,omitempty"`
+	GRPC                 *grpcActionInfo      `json:"grpc,omitempty"`
 	Timestamps           bool                 `json:"timestamps,omitempty"` // Include block timestamps in the events generated
 	TimestampCacheSize   int                  `json:"timestampCacheSize,omitempty"`
 	Inputs               bool                 `json:"inputs,omitempty"` // Include input args in the events generated
@@ -111,6 +122,8 @@ type eventStream struct {
 	action                  eventStreamAction
 	wsChannels              ws.WebSocketChannels
 	decimalTransactionIndex bool
+	nextBatchTime           time.Time
+	healthState             streamHealthState
 
 	eventPollerDone     chan struct{}
 	batchProcessorDone  chan struct{}
@@ -121,6 +134,11 @@ type eventStreamAction interface {
 	attemptBatch(batchNumber, attempt uint64, events []*eventData) error
 }
 
+// eventStreamActionCloser is implemented by actions that hold a connection open between batches
+type eventStreamActionCloser interface {
+	close()
+}
+
 func validateWebSocket(w *webSocketActionInfo) error {
 	if w.DistributionMode != "" && w.DistributionMode != DistributionModeBroadcast && w.DistributionMode != DistributionModeWLD {
 		return errors.Errorf(errors.EventStreamsInvalidDistributionMode, w.DistributionMode)
@@ -193,6 +211,38 @@ func newEventStream(sm subscriptionManager, spec *StreamInfo, wsChannels ws.WebS
 		if a.action, err = newWebSocketAction(a, spec.WebSocket); err != nil {
 			return nil, err
 		}
+	case "pubsub":
+		if a.action, err = newPubSubAction(a, spec.PubSub); err != nil {
+			return nil, err
+		}
+	case "amqp":
+		if a.action, err = newAMQPAction(a, spec.AMQP); err != nil {
+			return nil, err
+		}
+	case "nats":
+		if a.action, err = newNATSAction(a, spec.NATS); err != nil {
+			return nil, err
+		}
+	case "sqs":
+		if a.action, err = newAWSAction(a, awsServiceSQS, spec.SQS); err != nil {
+			return nil, err
+		}
+	case "sns":
+		if a.action, err = newAWSAction(a, awsServiceSNS, spec.SNS); err != nil {
+			return nil, err
+		}
+	case "kafka":
+		if a.action, err = newKafkaAction(a, spec.Kafka); err != nil {
+			return nil, err
+		}
+	case "mqtt":
+		if a.action, err = newMQTTAction(a, spec.MQTT); err != nil {
+			return nil, err
+		}
+	case "grpc":
+		if a.action, err = newGRPCAction(a, spec.GRPC); err != nil {
+			return nil, err
+		}
 	default:
 		return nil, errors.Errorf(errors.EventStreamsInvalidActionType, spec.Type)
 	}
@@ -330,6 +380,52 @@ func (a *eventStream) checkUpdate(newSpec *StreamInfo) (updatedSpec *StreamInfo,
 			}
 		}
 	}
+	if specCopy.Type == "pubsub" && newSpec.PubSub != nil {
+		if newSpec.PubSub.OrderingKey != "" && !strings.EqualFold(newSpec.PubSub.OrderingKey, specCopy.PubSub.OrderingKey) {
+			if err := validatePubSub(&pubSubActionInfo{Topic: specCopy.PubSub.Topic, OrderingKey: newSpec.PubSub.OrderingKey}); err != nil {
+				return nil, err
+			}
+			setUpdated().PubSub.OrderingKey = strings.ToLower(newSpec.PubSub.OrderingKey)
+		}
+		if newSpec.PubSub.RequestTimeoutSec != 0 && newSpec.PubSub.RequestTimeoutSec != specCopy.PubSub.RequestTimeoutSec {
+			setUpdated().PubSub.RequestTimeoutSec = newSpec.PubSub.RequestTimeoutSec
+		}
+	}
+	if specCopy.Type == "amqp" && newSpec.AMQP != nil {
+		if newSpec.AMQP.RequestTimeoutSec != 0 && newSpec.AMQP.RequestTimeoutSec != specCopy.AMQP.RequestTimeoutSec {
+			setUpdated().AMQP.RequestTimeoutSec = newSpec.AMQP.RequestTimeoutSec
+		}
+	}
+	if specCopy.Type == "nats" && newSpec.NATS != nil {
+		if newSpec.NATS.RequestTimeoutSec != 0 && newSpec.NATS.RequestTimeoutSec != specCopy.NATS.RequestTimeoutSec {
+			setUpdated().NATS.RequestTimeoutSec = newSpec.NATS.RequestTimeoutSec
+		}
+	}
+	if specCopy.Type == "sqs" && newSpec.SQS != nil {
+		if newSpec.SQS.RequestTimeoutSec != 0 && newSpec.SQS.RequestTimeoutSec != specCopy.SQS.RequestTimeoutSec {
+			setUpdated().SQS.RequestTimeoutSec = newSpec.SQS.RequestTimeoutSec
+		}
+	}
+	if specCopy.Type == "sns" && newSpec.SNS != nil {
+		if newSpec.SNS.RequestTimeoutSec != 0 && newSpec.SNS.RequestTimeoutSec != specCopy.SNS.RequestTimeoutSec {
+			setUpdated().SNS.RequestTimeoutSec = newSpec.SNS.RequestTimeoutSec
+		}
+	}
+	if specCopy.Type == "kafka" && newSpec.Kafka != nil {
+		if newSpec.Kafka.RequestTimeoutSec != 0 && newSpec.Kafka.RequestTimeoutSec != specCopy.Kafka.RequestTimeoutSec {
+			setUpdated().Kafka.RequestTimeoutSec = newSpec.Kafka.RequestTimeoutSec
+		}
+	}
+	if specCopy.Type == "mqtt" && newSpec.MQTT != nil {
+		if newSpec.MQTT.RequestTimeoutSec != 0 && newSpec.MQTT.RequestTimeoutSec != specCopy.MQTT.RequestTimeoutSec {
+			setUpdated().MQTT.RequestTimeoutSec = newSpec.MQTT.RequestTimeoutSec
+		}
+	}
+	if specCopy.Type == "grpc" && newSpec.GRPC != nil {
+		if newSpec.GRPC.RequestTimeoutSec != 0 && newSpec.GRPC.RequestTimeoutSec != specCopy.GRPC.RequestTimeoutSec {
+			setUpdated().GRPC.RequestTimeoutSec = newSpec.GRPC.RequestTimeoutSec
+		}
+	}
 
 	if specCopy.BatchSize != newSpec.BatchSize && newSpec.BatchSize != 0 && newSpec.BatchSize < MaxBatchSize {
 		setUpdated().BatchSize = newSpec.BatchSize
@@ -341,6 +437,9 @@ func (a *eventStream) checkUpdate(newSpec *StreamInfo) (updatedSpec *StreamInfo,
 		blockedRetryDelaySec := newSpec.blockedRetryDelaySec()
 		setUpdated().BlockedRetryDelaySec = &blockedRetryDelaySec
 	}
+	if newSpec.MaxEventsPerSec != 0 && newSpec.MaxEventsPerSec != specCopy.MaxEventsPerSec {
+		setUpdated().MaxEventsPerSec = newSpec.MaxEventsPerSec
+	}
 	if newSpec.ErrorHandling != "" && newSpec.ErrorHandling != specCopy.ErrorHandling {
 		if strings.ToLower(newSpec.ErrorHandling) == ErrorHandlingBlock {
 			setUpdated().ErrorHandling = ErrorHandlingBlock
@@ -416,6 +515,9 @@ func (a *eventStream) stop(wait bool) {
 		<-a.batchProcessorDone
 		<-a.batchDispatcherDone
 	}
+	if closer, ok := a.action.(eventStreamActionCloser); ok {
+		closer.close()
+	}
 }
 
 // suspend only stops the dispatcher, pushing back as if we're in blocking mode
@@ -472,6 +574,14 @@ func (a *eventStream) isBlocked() bool {
 	return isBlocked
 }
 
+// isSuspendedOrBlocked is used by scheduled queries, which skip a run rather than queue behind a stalled stream
+func (a *eventStream) isSuspendedOrBlocked() bool {
+	a.batchCond.L.Lock()
+	suspended := a.suspendOrStop()
+	a.batchCond.L.Unlock()
+	return suspended || a.isBlocked()
+}
+
 func (a *eventStream) markAllSubscriptionsStale(ctx context.Context) {
 	// Mark all subscriptions stale, so they will re-start from the checkpoint if/when we re-run the poller
 	subs := a.sm.subscriptionsForStream(a.spec.ID)
@@ -542,7 +652,13 @@ func (a *eventStream) eventPoller() {
 				checkpoint[sub.info.ID] = new(big.Int).Set(&i2)
 			}
 			if changed {
-				if err = a.sm.storeCheckpoint(a.spec.ID, checkpoint); err != nil {
+				if err = a.sm.storeCheckpoint(a.spec.ID, checkpoint); err == utils.ErrS3PreconditionFailed {
+					// Another instance has stored a checkpoint for this stream since we loaded ours,
+					// so reload it and restart the subscriptions from there
+					log.Warnf("%s: Checkpoint was updated by another instance. Restarting from the stored checkpoint", a.spec.ID)
+					checkpoint = nil
+					a.markAllSubscriptionsStale(ctx)
+				} else if err != nil {
 					log.Errorf("%s: Failed to store checkpoint: %s", a.spec.ID, err)
 				}
 			}
@@ -622,6 +738,7 @@ func (a *eventStream) batchDispatcher() {
 				a.inFlight++
 			}
 			a.batchQueue.PushBack(currentBatch)
+			a.healthState.queuedEvents += uint64(len(currentBatch))
 			a.batchCond.Broadcast()
 			a.batchCond.L.Unlock()
 			currentBatch = []*eventData{}
@@ -648,7 +765,7 @@ func (a *eventStream) batchProcessor() {
 	for {
 		// Wait for the next batch, or to be stopped
 		a.batchCond.L.Lock()
-		for !a.suspendOrStop() && a.batchQueue.Len() == 0 {
+		for !a.suspendOrStop() && (a.batchQueue.Len() == 0 || a.throttleDelay() > 0) {
 			if a.updateInProgress {
 				a.batchCond.L.Unlock()
 				<-a.updateInterrupt
@@ -656,6 +773,9 @@ func (a *eventStream) batchProcessor() {
 				log.Infof("%s: Notified of an ongoing stream update, existing batch processor", a.spec.ID)
 				return
 			} else {
+				if delay := a.throttleDelay(); a.batchQueue.Len() > 0 && delay > 0 {
+					time.AfterFunc(delay, a.wakeBatchProcessor)
+				}
 				a.batchCond.Wait()
 			}
 		}
@@ -668,14 +788,37 @@ func (a *eventStream) batchProcessor() {
 		a.batchCount++
 		batchNumber := a.batchCount
 		a.batchQueue.Remove(batchElem)
+		events := batchElem.Value.([]*eventData)
+		a.healthState.queuedEvents -= uint64(len(events))
 		a.batchCond.L.Unlock()
 		// Process the batch - could block for a very long time, particularly if
 		// ErrorHandlingBlock is configured.
 		// Track this as an item in the update wait group
-		a.processBatch(batchNumber, batchElem.Value.([]*eventData))
+		batchStart := time.Now()
+		a.processBatch(batchNumber, events)
+		a.batchCond.L.Lock()
+		if a.spec.MaxEventsPerSec > 0 {
+			a.nextBatchTime = batchStart.Add(time.Duration(len(events)) * time.Second / time.Duration(a.spec.MaxEventsPerSec))
+		}
+		a.batchCond.L.Unlock()
 	}
 }
 
+// throttleDelay is how long the batch processor must wait before starting the next batch, so
+// the stream does not exceed maxEventsPerSec. Called with the batch lock held.
+func (a *eventStream) throttleDelay() time.Duration {
+	if a.spec.MaxEventsPerSec == 0 {
+		return 0
+	}
+	return time.Until(a.nextBatchTime)
+}
+
+func (a *eventStream) wakeBatchProcessor() {
+	a.batchCond.L.Lock()
+	a.batchCond.Broadcast()
+	a.batchCond.L.Unlock()
+}
+
 // processBatch is the blocking function to process a batch of events
 // It never returns an error, and uses the chosen block/skip ErrorHandling
 // behavior combined with the parameters on the event itself
@@ -684,6 +827,7 @@ func (a *eventStream) processBatch(batchNumber uint64, events []*eventData) {
 		return
 	}
 	processed := false
+	delivered := false
 	attempt := 0
 	for !a.suspendOrStop() && !processed {
 		if attempt > 0 {
@@ -701,6 +845,7 @@ func (a *eventStream) processBatch(batchNumber uint64, events []*eventData) {
 		// If we got an error after all of the internal retries within the event
 		// handler failed, then the ErrorHandling strategy kicks in
 		processed = (err == nil)
+		delivered = processed
 		if !processed {
 			log.Errorf("%s: Batch %d attempt %d failed. ErrorHandling=%s BlockedRetryDelay=%ds err=%s",
 				a.spec.ID, batchNumber, attempt, a.spec.ErrorHandling, a.spec.BlockedRetryDelaySec, err)
@@ -720,6 +865,8 @@ func (a *eventStream) processBatch(batchNumber uint64, events []*eventData) {
 		return
 	}
 
+	a.recordBatchComplete(delivered)
+
 	// Call all the callbacks on the events, so they can update their high water marks
 	// If there are multiple events from one SubID, we call it only once with the
 	// last message in the batch
@@ -730,6 +877,27 @@ func (a *eventStream) processBatch(batchNumber uint64, events []*eventData) {
 	for _, event := range cbs {
 		event.batchComplete(event)
 	}
+
+	if delivered {
+		a.notifyDelivered(batchNumber, events)
+	}
+}
+
+// notifyDelivered passes the transactions of a delivered batch to the delivery listener
+func (a *eventStream) notifyDelivered(batchNumber uint64, events []*eventData) {
+	listener := a.sm.deliveryListener()
+	if listener == nil {
+		return
+	}
+	seen := make(map[string]bool)
+	var txHashes []string
+	for _, event := range events {
+		if event.TransactionHash != "" && !seen[event.TransactionHash] {
+			seen[event.TransactionHash] = true
+			txHashes = append(txHashes, event.TransactionHash)
+		}
+	}
+	listener.EventsDelivered(a.spec.ID, batchNumber, txHashes)
 }
 
 // performActionWithRetry performs an action, with exponential backoff retry up
@@ -754,12 +922,25 @@ func (a *eventStream) performActionWithRetry(batchNumber uint64, events []*event
 			delay = time.Duration(float64(delay) * a.backoffFactor)
 		}
 		attempt++
-		err = a.action.attemptBatch(batchNumber, attempt, events)
+		err = a.safeAttemptBatch(batchNumber, attempt, events)
+		a.recordAttempt(err)
 		complete = err == nil || time.Until(endTime) < 0
 	}
 	return err
 }
 
+// safeAttemptBatch treats a panic in the action as a failed attempt, so a fault in the action of
+// one stream does not bring down every other stream in the process
+func (a *eventStream) safeAttemptBatch(batchNumber, attempt uint64, events []*eventData) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("%s: Action panicked on batch %d: %v\n%s", a.spec.ID, batchNumber, r, debug.Stack())
+			err = errors.Errorf(errors.EventStreamsActionPanic, r)
+		}
+	}()
+	return a.action.attemptBatch(batchNumber, attempt, events)
+}
+
 // isAddressSafe checks for local IPs
 func (a *eventStream) isAddressUnsafe(ip *net.IPAddr) bool {
 	ip4 := ip.IP.To4()
@@ -23,6 +23,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -340,6 +341,75 @@ func TestSkippingBehavior(t *testing.T) {
 	// reaching here despite the 404s means we passed
 }
 
+type mockDeliveryListener struct {
+	delivered chan []string
+	streamID  string
+	batch     uint64
+}
+
+func (m *mockDeliveryListener) EventsDelivered(streamID string, batchNumber uint64, txHashes []string) {
+	m.streamID = streamID
+	m.batch = batchNumber
+	m.delivered <- txHashes
+}
+
+func TestDeliveryListener(t *testing.T) {
+	assert := assert.New(t)
+	sm, stream, svr, eventStream := newTestStreamForBatching(
+		&StreamInfo{
+			BatchSize:      3,
+			BatchTimeoutMS: 50,
+			Webhook:        &webhookActionInfo{},
+		}, nil, 200)
+	defer close(eventStream)
+	defer svr.Close()
+	defer stream.stop(false)
+
+	listener := &mockDeliveryListener{delivered: make(chan []string, 1)}
+	sm.SetDeliveryListener(listener)
+
+	go func() { <-eventStream }()
+	for _, txHash := range []string{"0x111", "0x111", "0x222"} {
+		event := testEvent("sub1")
+		event.TransactionHash = txHash
+		stream.handleEvent(event)
+	}
+	assert.Equal([]string{"0x111", "0x222"}, <-listener.delivered)
+	assert.Equal(stream.spec.ID, listener.streamID)
+	assert.Equal(uint64(1), listener.batch)
+}
+
+func TestDeliveryListenerSkippedBatch(t *testing.T) {
+	assert := assert.New(t)
+	one := uint64(1)
+	sm, stream, svr, eventStream := newTestStreamForBatching(
+		&StreamInfo{
+			BatchSize:            1,
+			Webhook:              &webhookActionInfo{},
+			ErrorHandling:        ErrorHandlingSkip,
+			BlockedRetryDelaySec: &one,
+		}, nil, 404)
+	defer close(eventStream)
+	defer svr.Close()
+	defer stream.stop(false)
+
+	listener := &mockDeliveryListener{delivered: make(chan []string, 1)}
+	sm.SetDeliveryListener(listener)
+
+	var complete int32
+	go func() { <-eventStream }()
+	stream.handleEvent(&eventData{
+		SubID:           "sub1",
+		TransactionHash: "0x111",
+		batchComplete:   func(*eventData) { atomic.StoreInt32(&complete, 1) },
+	})
+	for atomic.LoadInt32(&complete) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	assert.Empty(listener.delivered)
+}
+
 func TestBackoffRetry(t *testing.T) {
 	assert := assert.New(t)
 	one := uint64(1)
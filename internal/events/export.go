@@ -0,0 +1,333 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ExportFormatCSV writes a header row, then one row per event with a column for each event input
+	ExportFormatCSV = "csv"
+	// ExportFormatJSON writes one JSON object per event, separated by newlines
+	ExportFormatJSON = "json"
+	// ExportFormatParquet writes a Parquet file, with a column for each event input in the data group
+	ExportFormatParquet = "parquet"
+)
+
+// SubscriptionExportRequest is the historical block range, and output format, for an export
+type SubscriptionExportRequest struct {
+	FromBlock string `json:"fromBlock,omitempty"`
+	ToBlock   string `json:"toBlock,omitempty"`
+	Format    string `json:"format,omitempty"`
+}
+
+// exportedEvent is the flattened record written for each event in an export
+type exportedEvent struct {
+	BlockNumber      string                 `json:"blockNumber"`
+	BlockHash        string                 `json:"blockHash"`
+	TransactionHash  string                 `json:"transactionHash"`
+	TransactionIndex string                 `json:"transactionIndex"`
+	LogIndex         string                 `json:"logIndex"`
+	Address          string                 `json:"address"`
+	Signature        string                 `json:"signature"`
+	Timestamp        string                 `json:"timestamp,omitempty"`
+	Data             map[string]interface{} `json:"data"`
+}
+
+type eventExporter interface {
+	writeEvent(e *exportedEvent) error
+	flush() error
+	close() error
+}
+
+func newEventExporter(format string, w io.Writer, event *ethbinding.ABIEvent, timestamps bool) (eventExporter, error) {
+	switch format {
+	case ExportFormatCSV:
+		return newCSVExporter(w, event, timestamps), nil
+	case ExportFormatJSON:
+		return &jsonExporter{enc: json.NewEncoder(w)}, nil
+	case ExportFormatParquet:
+		return newParquetExporter(w, event, timestamps), nil
+	default:
+		return nil, errors.Errorf(errors.EventStreamsExportBadFormat, format)
+	}
+}
+
+// ExportSubscription queries the logs for a historical block range of a subscription, and writes
+// them decoded against the ABI of the event. Nothing is written to the output if the request fails
+// validation, so the caller can still return an error status.
+func (s *subscriptionMGR) ExportSubscription(ctx context.Context, id string, req *SubscriptionExportRequest, w io.Writer) error {
+	sub, err := s.subscriptionByID(id)
+	if err != nil {
+		return err
+	}
+	return sub.export(ctx, req, w)
+}
+
+func (s *subscription) exportBlockRange(ctx context.Context, req *SubscriptionExportRequest) (from, to *big.Int, err error) {
+	fromStr := req.FromBlock
+	if fromStr == "" {
+		// Default to where the subscription itself started
+		fromStr = s.info.FromBlock
+		if fromStr == "" || fromStr == FromBlockLatest {
+			fromStr = "0"
+		}
+	}
+	from, ok := new(big.Int).SetString(fromStr, 0)
+	if !ok {
+		return nil, nil, errors.Errorf(errors.EventStreamsExportBadBlockRange, req.FromBlock, req.ToBlock)
+	}
+	if req.ToBlock == "" || req.ToBlock == FromBlockLatest {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		blockNumber := ethbinding.HexBigInt{}
+		if err := s.rpc.CallContext(ctx, &blockNumber, "eth_blockNumber"); err != nil {
+			return nil, nil, errors.Errorf(errors.RPCCallReturnedError, "eth_blockNumber", err)
+		}
+		to = blockNumber.ToInt()
+	} else if to, ok = new(big.Int).SetString(req.ToBlock, 0); !ok {
+		return nil, nil, errors.Errorf(errors.EventStreamsExportBadBlockRange, req.FromBlock, req.ToBlock)
+	}
+	if from.Sign() < 0 || to.Cmp(from) < 0 {
+		return nil, nil, errors.Errorf(errors.EventStreamsExportBadBlockRange, req.FromBlock, req.ToBlock)
+	}
+	return from, to, nil
+}
+
+func validateExportFormat(format string) error {
+	if format != ExportFormatCSV && format != ExportFormatJSON && format != ExportFormatParquet {
+		return errors.Errorf(errors.EventStreamsExportBadFormat, format)
+	}
+	return nil
+}
+
+func (s *subscription) export(ctx context.Context, req *SubscriptionExportRequest, w io.Writer) error {
+	if err := validateExportFormat(req.Format); err != nil {
+		return err
+	}
+	from, to, err := s.exportBlockRange(ctx, req)
+	if err != nil {
+		return err
+	}
+	_, err = s.exportRange(ctx, from, to, req.Format, w)
+	return err
+}
+
+// exportRange writes the events in the block range, returning the number written
+func (s *subscription) exportRange(ctx context.Context, from, to *big.Int, format string, w io.Writer) (int, error) {
+	timestamps := s.lp.stream.spec.Timestamps
+	exporter, err := newEventExporter(format, w, s.lp.event, timestamps)
+	if err != nil {
+		return 0, err
+	}
+
+	pageSize := s.catchupModePageSize
+	if pageSize <= 0 {
+		pageSize = defaultCatchupModePageSize
+	}
+	count := 0
+	log.Infof("%s: exporting blocks %s -> %s as %s", s.logName, from.String(), to.String(), format)
+	for pageStart := new(big.Int).Set(from); pageStart.Cmp(to) <= 0; {
+		pageEnd := new(big.Int).Add(pageStart, big.NewInt(pageSize-1))
+		if pageEnd.Cmp(to) > 0 {
+			pageEnd.Set(to)
+		}
+		f := &ethFilter{}
+		f.persistedFilter = s.info.Filter
+		f.FromBlock.ToInt().Set(pageStart)
+		f.ToBlock = "0x" + pageEnd.Text(16)
+
+		var logs []*logEntry
+		rpcCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		err := s.rpc.CallContext(rpcCtx, &logs, "eth_getLogs", f)
+		cancel()
+		if err != nil {
+			return count, errors.Errorf(errors.RPCCallReturnedError, "eth_getLogs", err)
+		}
+		for idx, l := range logs {
+			if l.Removed {
+				continue
+			}
+			if timestamps {
+				s.getEventTimestamp(ctx, l)
+			}
+			e, err := s.exportedEvent(l, idx)
+			if err != nil {
+				return count, err
+			}
+			if err := exporter.writeEvent(e); err != nil {
+				return count, errors.Errorf(errors.EventStreamsExportWriteFailed, err)
+			}
+			count++
+		}
+		if err := exporter.flush(); err != nil {
+			return count, errors.Errorf(errors.EventStreamsExportWriteFailed, err)
+		}
+		pageStart = pageEnd.Add(pageEnd, big.NewInt(1))
+	}
+	if err := exporter.close(); err != nil {
+		return count, errors.Errorf(errors.EventStreamsExportWriteFailed, err)
+	}
+	log.Infof("%s: exported %d events", s.logName, count)
+	return count, nil
+}
+
+// exportedEvent builds the output record, numbering the log index by position in the
+// query result in the same way as events dispatched to a stream
+func (s *subscription) exportedEvent(l *logEntry, idx int) (*exportedEvent, error) {
+	data, err := decodeLogData(s.logName, s.lp.event, l)
+	if err != nil {
+		return nil, err
+	}
+	e := &exportedEvent{
+		BlockNumber:      l.BlockNumber.ToInt().String(),
+		BlockHash:        l.BlockHash.String(),
+		TransactionHash:  l.TransactionHash.String(),
+		TransactionIndex: strconv.FormatUint(uint64(l.TransactionIndex), 10),
+		LogIndex:         strconv.Itoa(idx),
+		Address:          l.Address.String(),
+		Signature:        ethbind.API.ABIEventSignature(s.lp.event),
+		Data:             data,
+	}
+	if s.lp.stream.spec.Timestamps {
+		e.Timestamp = strconv.FormatUint(l.Timestamp, 10)
+	}
+	return e, nil
+}
+
+type jsonExporter struct {
+	enc *json.Encoder
+}
+
+func (je *jsonExporter) writeEvent(e *exportedEvent) error {
+	return je.enc.Encode(e)
+}
+
+func (je *jsonExporter) flush() error {
+	return nil
+}
+
+func (je *jsonExporter) close() error {
+	return nil
+}
+
+type csvExporter struct {
+	w             *csv.Writer
+	fields        []string
+	timestamps    bool
+	headerWritten bool
+}
+
+// exportFieldNames returns the names of the event inputs, in ABI order, as they
+// appear in the decoded data. Unnamed data inputs follow the output naming convention.
+func exportFieldNames(event *ethbinding.ABIEvent) []string {
+	fields := make([]string, 0, len(event.Inputs))
+	dataIdx := 0
+	for _, input := range event.Inputs {
+		name := input.Name
+		if !input.Indexed {
+			if name == "" {
+				name = "output"
+				if dataIdx != 0 {
+					name += strconv.Itoa(dataIdx)
+				}
+			}
+			dataIdx++
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+func newCSVExporter(w io.Writer, event *ethbinding.ABIEvent, timestamps bool) *csvExporter {
+	return &csvExporter{
+		w:          csv.NewWriter(w),
+		fields:     exportFieldNames(event),
+		timestamps: timestamps,
+	}
+}
+
+func (ce *csvExporter) header() []string {
+	header := []string{"blockNumber", "blockHash", "transactionHash", "transactionIndex", "logIndex", "address", "signature"}
+	if ce.timestamps {
+		header = append(header, "timestamp")
+	}
+	return append(header, ce.fields...)
+}
+
+func (ce *csvExporter) writeHeader() error {
+	if ce.headerWritten {
+		return nil
+	}
+	ce.headerWritten = true
+	return ce.w.Write(ce.header())
+}
+
+func (ce *csvExporter) writeEvent(e *exportedEvent) error {
+	if err := ce.writeHeader(); err != nil {
+		return err
+	}
+	row := []string{e.BlockNumber, e.BlockHash, e.TransactionHash, e.TransactionIndex, e.LogIndex, e.Address, e.Signature}
+	if ce.timestamps {
+		row = append(row, e.Timestamp)
+	}
+	for _, field := range ce.fields {
+		row = append(row, csvValue(e.Data[field]))
+	}
+	return ce.w.Write(row)
+}
+
+func (ce *csvExporter) flush() error {
+	// We always want a header, even if there were no events in the range
+	if err := ce.writeHeader(); err != nil {
+		return err
+	}
+	ce.w.Flush()
+	return ce.w.Error()
+}
+
+func (ce *csvExporter) close() error {
+	return nil
+}
+
+// csvValue formats simple values directly, and complex values (arrays/structs) as JSON
+func csvValue(v interface{}) string {
+	switch vt := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return vt
+	case bool:
+		return strconv.FormatBool(vt)
+	case fmt.Stringer:
+		return vt.String()
+	default:
+		b, _ := json.Marshal(vt)
+		return string(b)
+	}
+}
@@ -0,0 +1,333 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	"github.com/hyperledger/firefly-ethconnect/mocks/ethmocks"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestExportSubscription(t *testing.T, rpc *ethmocks.RPCClient, pageSize int64) *subscription {
+	var marshaling ethbinding.ABIElementMarshaling
+	err := json.Unmarshal([]byte(sampleEventABIAllIndexedNoData), &marshaling)
+	assert.NoError(t, err)
+	event, err := ethbind.API.ABIElementMarshalingToABIEvent(&marshaling)
+	assert.NoError(t, err)
+	return &subscription{
+		info:                &SubscriptionInfo{ID: "sub1", FromBlock: FromBlockLatest},
+		rpc:                 rpc,
+		logName:             "sub1",
+		catchupModePageSize: pageSize,
+		lp: &logProcessor{
+			event:  event,
+			stream: &eventStream{spec: &StreamInfo{}},
+		},
+	}
+}
+
+func mockExportLogs(rpc *ethmocks.RPCClient, toBlock string, count int) {
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_getLogs", mock.MatchedBy(func(f *ethFilter) bool {
+		return f.ToBlock == toBlock
+	})).
+		Run(func(args mock.Arguments) {
+			les := args[1].(*[]*logEntry)
+			for i := 0; i < count; i++ {
+				var l logEntry
+				_ = json.Unmarshal([]byte(sampleEventLogAllIndexedNoData), &l)
+				*les = append(*les, &l)
+			}
+		}).
+		Return(nil)
+}
+
+func TestExportCSV(t *testing.T) {
+	assert := assert.New(t)
+
+	rpc := &ethmocks.RPCClient{}
+	mockExportLogs(rpc, "0x9", 1)
+	mockExportLogs(rpc, "0xa", 0)
+	s := newTestExportSubscription(t, rpc, 10)
+
+	var out bytes.Buffer
+	err := s.export(context.Background(), &SubscriptionExportRequest{
+		FromBlock: "0",
+		ToBlock:   "10",
+		Format:    ExportFormatCSV,
+	}, &out)
+	assert.NoError(err)
+	assert.Equal("blockNumber,blockHash,transactionHash,transactionIndex,logIndex,address,signature,data1,data2\n"+
+		"475266,0xb6d8a38a89ac35a04ee6ebd5789a4a805dfa26c1b753c311db523ec9bf204384,0x23307094299f08a1041de9f1e7ecb67197a5a3c11ce5be775a8147de266b7524,0,0,0x19E75d0d337e17835dc5246f007A1fB17f0bAC89,\"SampleEvent(string,uint256)\",0x51b201b016025d42c9a0718b75aacc12b1e9c7f16e4bd2c6618aa944ca399156,1000\n",
+		out.String())
+	rpc.AssertExpectations(t)
+}
+
+func TestExportCSVNoEventsHeaderOnly(t *testing.T) {
+	assert := assert.New(t)
+
+	rpc := &ethmocks.RPCClient{}
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_blockNumber").
+		Run(func(args mock.Arguments) {
+			args[1].(*ethbinding.HexBigInt).ToInt().SetInt64(5)
+		}).
+		Return(nil)
+	mockExportLogs(rpc, "0x5", 0)
+	s := newTestExportSubscription(t, rpc, 0)
+	s.lp.stream.spec.Timestamps = true
+
+	var out bytes.Buffer
+	err := s.export(context.Background(), &SubscriptionExportRequest{Format: ExportFormatCSV}, &out)
+	assert.NoError(err)
+	assert.Equal("blockNumber,blockHash,transactionHash,transactionIndex,logIndex,address,signature,timestamp,data1,data2\n", out.String())
+	rpc.AssertExpectations(t)
+}
+
+func TestExportJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	rpc := &ethmocks.RPCClient{}
+	mockExportLogs(rpc, "0x64", 2)
+	s := newTestExportSubscription(t, rpc, 250)
+
+	var out bytes.Buffer
+	err := s.export(context.Background(), &SubscriptionExportRequest{
+		FromBlock: "0x0",
+		ToBlock:   "100",
+		Format:    ExportFormatJSON,
+	}, &out)
+	assert.NoError(err)
+	dec := json.NewDecoder(&out)
+	var events []*exportedEvent
+	for dec.More() {
+		var e exportedEvent
+		assert.NoError(dec.Decode(&e))
+		events = append(events, &e)
+	}
+	assert.Len(events, 2)
+	assert.Equal("475266", events[1].BlockNumber)
+	assert.Equal("1", events[1].LogIndex)
+	assert.Equal("1000", events[1].Data["data2"])
+	rpc.AssertExpectations(t)
+}
+
+func readTestParquet(t *testing.T, b []byte) []map[string]interface{} {
+	r := parquet.NewReader(bytes.NewReader(b))
+	defer r.Close()
+	var rows []map[string]interface{}
+	for {
+		row := map[string]interface{}{}
+		if err := r.Read(&row); err == io.EOF {
+			return rows
+		} else {
+			assert.NoError(t, err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestExportParquet(t *testing.T) {
+	assert := assert.New(t)
+
+	rpc := &ethmocks.RPCClient{}
+	mockExportLogs(rpc, "0x1", 2)
+	s := newTestExportSubscription(t, rpc, 10)
+
+	var out bytes.Buffer
+	err := s.export(context.Background(), &SubscriptionExportRequest{
+		FromBlock: "0",
+		ToBlock:   "1",
+		Format:    ExportFormatParquet,
+	}, &out)
+	assert.NoError(err)
+	rows := readTestParquet(t, out.Bytes())
+	assert.Len(rows, 2)
+	assert.Equal(int64(475266), rows[1]["blockNumber"])
+	assert.Equal(int64(1), rows[1]["logIndex"])
+	assert.NotContains(rows[1], "timestamp")
+	assert.Equal("SampleEvent(string,uint256)", rows[1]["signature"])
+	assert.Equal(map[string]interface{}{
+		"data1": "0x51b201b016025d42c9a0718b75aacc12b1e9c7f16e4bd2c6618aa944ca399156",
+		"data2": "1000",
+	}, rows[1]["data"])
+	rpc.AssertExpectations(t)
+}
+
+func TestExportParquetSchemaFromABI(t *testing.T) {
+	assert := assert.New(t)
+
+	var marshaling ethbinding.ABIElementMarshaling
+	err := json.Unmarshal([]byte(`{
+		"name": "Typed",
+		"type": "event",
+		"inputs": [
+			{"name": "small", "type": "uint64", "indexed": true},
+			{"name": "signed", "type": "int8"},
+			{"name": "flag", "type": "bool"},
+			{"name": "list", "type": "uint256[]"},
+			{"name": "text", "type": "string"}
+		]
+	}`), &marshaling)
+	assert.NoError(err)
+	event, err := ethbind.API.ABIElementMarshalingToABIEvent(&marshaling)
+	assert.NoError(err)
+
+	var out bytes.Buffer
+	pe := newParquetExporter(&out, event, false)
+	err = pe.writeEvent(&exportedEvent{
+		BlockNumber:      "1",
+		TransactionIndex: "2",
+		LogIndex:         "3",
+		Data: map[string]interface{}{
+			"small":  "18446744073709551615",
+			"signed": "-5",
+			"flag":   true,
+			"list":   []interface{}{"1", "2"},
+			"text":   "hello",
+		},
+	})
+	assert.NoError(err)
+	err = pe.writeEvent(&exportedEvent{BlockNumber: "4", TransactionIndex: "0", LogIndex: "0", Data: map[string]interface{}{}})
+	assert.NoError(err)
+	assert.NoError(pe.flush())
+	assert.NoError(pe.close())
+
+	schema := pe.w.Schema().String()
+	assert.Contains(schema, "optional int64 small (INT(64,false));")
+	assert.Contains(schema, "optional int64 signed (INT(64,true));")
+	assert.Contains(schema, "optional boolean flag;")
+	assert.Contains(schema, "optional binary list (JSON);")
+	assert.Contains(schema, "optional binary text (STRING);")
+	assert.NotContains(schema, "timestamp")
+
+	// Values are read back by physical type, so unsigned columns are int64
+	rows := readTestParquet(t, out.Bytes())
+	assert.Len(rows, 2)
+	assert.Equal(int64(3), rows[0]["logIndex"])
+	data := rows[0]["data"].(map[string]interface{})
+	assert.Equal(int64(-1), data["small"])
+	assert.Equal(int64(-5), data["signed"])
+	assert.Equal(true, data["flag"])
+	assert.Equal([]interface{}{"1", "2"}, data["list"])
+	assert.Equal("hello", data["text"])
+	assert.Nil(rows[1]["data"].(map[string]interface{})["flag"])
+}
+
+func TestExportParquetBadValues(t *testing.T) {
+	assert := assert.New(t)
+
+	event := &ethbinding.ABIEvent{
+		RawName: "Bad",
+		Inputs: ethbinding.ABIArguments{
+			{Name: "i", Type: ethbinding.ABIType{T: ethbinding.IntTy, Size: 32}},
+			{Name: "j", Type: ethbinding.ABIType{T: ethbinding.SliceTy}},
+		},
+	}
+	pe := newParquetExporter(&bytes.Buffer{}, event, true)
+	err := pe.writeEvent(&exportedEvent{Data: map[string]interface{}{"i": "abc"}})
+	assert.Error(err)
+	err = pe.writeEvent(&exportedEvent{Data: map[string]interface{}{"j": make(chan int)}})
+	assert.Error(err)
+	err = pe.writeEvent(&exportedEvent{BlockNumber: "1", TransactionIndex: "0", LogIndex: "0", Timestamp: "", Data: map[string]interface{}{}})
+	assert.Error(err)
+}
+
+func TestExportBadFormat(t *testing.T) {
+	assert := assert.New(t)
+	s := newTestExportSubscription(t, &ethmocks.RPCClient{}, 0)
+	err := s.export(context.Background(), &SubscriptionExportRequest{Format: "xml"}, &bytes.Buffer{})
+	assert.Regexp("FFEC100227", err)
+}
+
+func TestExportBadBlockRange(t *testing.T) {
+	assert := assert.New(t)
+	s := newTestExportSubscription(t, &ethmocks.RPCClient{}, 0)
+	err := s.export(context.Background(), &SubscriptionExportRequest{Format: ExportFormatCSV, FromBlock: "bad"}, &bytes.Buffer{})
+	assert.Regexp("FFEC100228", err)
+	err = s.export(context.Background(), &SubscriptionExportRequest{Format: ExportFormatCSV, FromBlock: "0", ToBlock: "bad"}, &bytes.Buffer{})
+	assert.Regexp("FFEC100228", err)
+	err = s.export(context.Background(), &SubscriptionExportRequest{Format: ExportFormatCSV, FromBlock: "10", ToBlock: "5"}, &bytes.Buffer{})
+	assert.Regexp("FFEC100228", err)
+}
+
+func TestExportBlockNumberFail(t *testing.T) {
+	assert := assert.New(t)
+	rpc := &ethmocks.RPCClient{}
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_blockNumber").Return(fmt.Errorf("pop"))
+	s := newTestExportSubscription(t, rpc, 0)
+	err := s.export(context.Background(), &SubscriptionExportRequest{Format: ExportFormatCSV}, &bytes.Buffer{})
+	assert.Regexp("eth_blockNumber returned: pop", err)
+}
+
+func TestExportGetLogsFail(t *testing.T) {
+	assert := assert.New(t)
+	rpc := &ethmocks.RPCClient{}
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).Return(fmt.Errorf("pop"))
+	s := newTestExportSubscription(t, rpc, 0)
+	var out bytes.Buffer
+	err := s.export(context.Background(), &SubscriptionExportRequest{Format: ExportFormatCSV, FromBlock: "0", ToBlock: "0"}, &out)
+	assert.Regexp("eth_getLogs returned: pop", err)
+	assert.Empty(out.String())
+}
+
+func TestExportDecodeFail(t *testing.T) {
+	assert := assert.New(t)
+	rpc := &ethmocks.RPCClient{}
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).
+		Run(func(args mock.Arguments) {
+			les := args[1].(*[]*logEntry)
+			*les = append(*les, &logEntry{Data: "0x no hex here sorry"})
+		}).
+		Return(nil)
+	s := newTestExportSubscription(t, rpc, 0)
+	err := s.export(context.Background(), &SubscriptionExportRequest{Format: ExportFormatJSON, FromBlock: "0", ToBlock: "0"}, &bytes.Buffer{})
+	assert.Regexp("FFEC100043", err)
+}
+
+func TestExportSubscriptionNotFound(t *testing.T) {
+	assert := assert.New(t)
+	sm := newTestSubscriptionManager()
+	err := sm.ExportSubscription(context.Background(), "unknown", &SubscriptionExportRequest{}, &bytes.Buffer{})
+	assert.Regexp("FFEC100039", err)
+}
+
+func TestExportFieldNamesUnnamed(t *testing.T) {
+	assert := assert.New(t)
+	event := &ethbinding.ABIEvent{
+		Inputs: ethbinding.ABIArguments{
+			{Name: "", Indexed: false},
+			{Name: "idx", Indexed: true},
+			{Name: "", Indexed: false},
+		},
+	}
+	assert.Equal([]string{"output", "idx", "output1"}, exportFieldNames(event))
+}
+
+func TestCSVValue(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("", csvValue(nil))
+	assert.Equal("true", csvValue(true))
+	assert.Equal("0x0000000000000000000000000000000000000001", csvValue(ethbind.API.HexToAddress("0x01")))
+	assert.Equal(`["a","b"]`, csvValue([]interface{}{"a", "b"}))
+}
@@ -0,0 +1,311 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	exportJobIDPrefix     = "ej-"
+	defaultS3ExportPrefix = "exports/"
+
+	// ExportJobRunning the job is still writing its output
+	ExportJobRunning = "running"
+	// ExportJobCompleted the output has been written to its location
+	ExportJobCompleted = "completed"
+	// ExportJobFailed the job stopped, and no output was written
+	ExportJobFailed = "failed"
+)
+
+// ExportJobConf configures where export jobs write their output. When an S3 bucket is
+// configured the output is uploaded under the prefix, otherwise it is written to the
+// local directory in Path. Export jobs are unavailable if neither is configured.
+type ExportJobConf struct {
+	Path   string       `json:"path,omitempty"`
+	S3     utils.S3Conf `json:"s3,omitempty"`
+	Prefix string       `json:"prefix,omitempty"`
+}
+
+// ExportJobInfo is the status of an export job, which writes the same output as an export
+// to a file or object named <subscription>/<fromBlock>-<toBlock>.<format>. Jobs are held in
+// memory, so they are not listed after a restart.
+type ExportJobInfo struct {
+	messages.TimeSorted
+	ID               string `json:"id"`
+	Subscription     string `json:"subscription"`
+	FromBlock        string `json:"fromBlock"`
+	ToBlock          string `json:"toBlock"`
+	Format           string `json:"format"`
+	Status           string `json:"status"`
+	Location         string `json:"location,omitempty"`
+	Events           int    `json:"events"`
+	Error            string `json:"error,omitempty"`
+	CompletedISO8601 string `json:"completed,omitempty"`
+}
+
+// GetID returns the ID (for sorting)
+func (info *ExportJobInfo) GetID() string {
+	return info.ID
+}
+
+// exportOutput creates the files for export jobs. Nothing is visible at the final
+// location until the file is committed, so a failed job leaves no partial output.
+type exportOutput interface {
+	create(name string) (exportFile, error)
+}
+
+type exportFile interface {
+	io.Writer
+	commit() (location string, err error)
+	abort()
+}
+
+func newExportOutput(conf *ExportJobConf) (exportOutput, error) {
+	if conf.S3.Bucket != "" {
+		if conf.Prefix == "" {
+			conf.Prefix = defaultS3ExportPrefix
+		}
+		s3, err := utils.NewS3Client(&conf.S3)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("Export jobs write to S3 bucket=%s prefix=%s", conf.S3.Bucket, conf.Prefix)
+		return &s3ExportOutput{conf: conf, s3: s3}, nil
+	}
+	if conf.Path != "" {
+		if err := os.MkdirAll(conf.Path, 0755); err != nil {
+			return nil, err
+		}
+		log.Infof("Export jobs write to path=%s", conf.Path)
+		return &localExportOutput{dir: conf.Path}, nil
+	}
+	return nil, nil
+}
+
+// localExportOutput writes to a temporary file in the target directory, and renames it into place
+type localExportOutput struct {
+	dir string
+}
+
+type localExportFile struct {
+	*os.File
+	target string
+}
+
+func (o *localExportOutput) create(name string) (exportFile, error) {
+	target := filepath.Join(o.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return nil, err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(target), ".export-")
+	if err != nil {
+		return nil, err
+	}
+	return &localExportFile{File: f, target: target}, nil
+}
+
+func (f *localExportFile) commit() (string, error) {
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	if err := os.Rename(f.Name(), f.target); err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return f.target, nil
+}
+
+func (f *localExportFile) abort() {
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+}
+
+// s3ExportOutput spools the output to a local temporary file, and uploads it once complete
+type s3ExportOutput struct {
+	conf *ExportJobConf
+	s3   *utils.S3Client
+}
+
+type s3ExportFile struct {
+	*os.File
+	o   *s3ExportOutput
+	key string
+}
+
+func (o *s3ExportOutput) create(name string) (exportFile, error) {
+	f, err := ioutil.TempFile("", "ethconnect-export-")
+	if err != nil {
+		return nil, err
+	}
+	return &s3ExportFile{File: f, o: o, key: o.conf.Prefix + name}, nil
+}
+
+func (f *s3ExportFile) commit() (string, error) {
+	defer f.abort()
+	b, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	if err := f.o.s3.PutObject(f.key, "application/octet-stream", b); err != nil {
+		return "", err
+	}
+	return "s3://" + f.o.conf.S3.Bucket + "/" + f.key, nil
+}
+
+func (f *s3ExportFile) abort() {
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+}
+
+// exportJob is the runtime of a job, which stops if the subscription manager is closed
+type exportJob struct {
+	info   *ExportJobInfo
+	mux    sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (j *exportJob) status() *ExportJobInfo {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	info := *j.info
+	return &info
+}
+
+func (j *exportJob) run(ctx context.Context, sub *subscription, from, to *big.Int, f exportFile) {
+	defer close(j.done)
+	count, err := sub.exportRange(ctx, from, to, j.info.Format, f)
+	var location string
+	if err == nil {
+		location, err = f.commit()
+	} else {
+		f.abort()
+	}
+
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	j.info.Events = count
+	j.info.CompletedISO8601 = time.Now().UTC().Format(time.RFC3339)
+	if err != nil {
+		log.Errorf("Export job %s failed: %s", j.info.ID, err)
+		j.info.Status = ExportJobFailed
+		j.info.Error = err.Error()
+		return
+	}
+	log.Infof("Export job %s completed: %s", j.info.ID, location)
+	j.info.Status = ExportJobCompleted
+	j.info.Location = location
+}
+
+// StartExportJob resolves the block range of the export, and starts writing it in the background
+func (s *subscriptionMGR) StartExportJob(ctx context.Context, subID string, req *SubscriptionExportRequest) (*ExportJobInfo, error) {
+	if s.exports == nil {
+		return nil, errors.Errorf(errors.EventStreamsExportJobsNotConfigured)
+	}
+	sub, err := s.subscriptionByID(subID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateExportFormat(req.Format); err != nil {
+		return nil, err
+	}
+	from, to, err := sub.exportBlockRange(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.exports.create(path.Join(subID, fmt.Sprintf("%s-%s.%s", from.String(), to.String(), req.Format)))
+	if err != nil {
+		return nil, errors.Errorf(errors.EventStreamsExportWriteFailed, err)
+	}
+
+	jobCtx, cancel := context.WithCancel(context.Background())
+	j := &exportJob{
+		info: &ExportJobInfo{
+			ID:           exportJobIDPrefix + utils.UUIDv4(),
+			Subscription: subID,
+			FromBlock:    from.String(),
+			ToBlock:      to.String(),
+			Format:       req.Format,
+			Status:       ExportJobRunning,
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	j.info.CreatedISO8601 = time.Now().UTC().Format(time.RFC3339)
+	s.exportMutex.Lock()
+	s.exportJobs[j.info.ID] = j
+	s.exportMutex.Unlock()
+	log.Infof("Started export job %s for subscription %s", j.info.ID, subID)
+	go j.run(jobCtx, sub, from, to, f)
+	return j.status(), nil
+}
+
+// ExportJobs lists the export jobs of a subscription
+func (s *subscriptionMGR) ExportJobs(ctx context.Context, subID string) ([]*ExportJobInfo, error) {
+	if _, err := s.subscriptionByID(subID); err != nil {
+		return nil, err
+	}
+	s.exportMutex.Lock()
+	defer s.exportMutex.Unlock()
+	l := make([]*ExportJobInfo, 0)
+	for _, j := range s.exportJobs {
+		if j.info.Subscription == subID {
+			l = append(l, j.status())
+		}
+	}
+	return l, nil
+}
+
+// ExportJobByID returns the status of an export job of a subscription
+func (s *subscriptionMGR) ExportJobByID(ctx context.Context, subID, jobID string) (*ExportJobInfo, error) {
+	s.exportMutex.Lock()
+	j, exists := s.exportJobs[jobID]
+	s.exportMutex.Unlock()
+	if !exists || j.info.Subscription != subID {
+		return nil, errors.Errorf(errors.EventStreamsExportJobNotFound, jobID)
+	}
+	return j.status(), nil
+}
+
+func (s *subscriptionMGR) stopExportJobs(wait bool) {
+	s.exportMutex.Lock()
+	jobs := make([]*exportJob, 0, len(s.exportJobs))
+	for _, j := range s.exportJobs {
+		j.cancel()
+		jobs = append(jobs, j)
+	}
+	s.exportMutex.Unlock()
+	if wait {
+		for _, j := range jobs {
+			<-j.done
+		}
+	}
+}
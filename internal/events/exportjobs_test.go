@@ -0,0 +1,217 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	"github.com/hyperledger/firefly-ethconnect/mocks/ethmocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestExportJobManager(t *testing.T, conf *ExportJobConf, rpc *ethmocks.RPCClient) *subscriptionMGR {
+	sm := newTestSubscriptionManager()
+	var err error
+	sm.exports, err = newExportOutput(conf)
+	assert.NoError(t, err)
+	s := newTestExportSubscription(t, rpc, 10)
+	sm.subscriptions[s.info.ID] = s
+	return sm
+}
+
+func waitExportJob(sm *subscriptionMGR, id string) {
+	sm.exportMutex.Lock()
+	j := sm.exportJobs[id]
+	sm.exportMutex.Unlock()
+	<-j.done
+}
+
+func TestExportJobLocal(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir(t)
+	defer cleanup(t, dir)
+
+	rpc := &ethmocks.RPCClient{}
+	mockExportLogs(rpc, "0x1", 1)
+	sm := newTestExportJobManager(t, &ExportJobConf{Path: path.Join(dir, "exports")}, rpc)
+	ctx := context.Background()
+
+	job, err := sm.StartExportJob(ctx, "sub1", &SubscriptionExportRequest{FromBlock: "0", ToBlock: "1", Format: ExportFormatCSV})
+	assert.NoError(err)
+	assert.Equal(ExportJobRunning, job.Status)
+	assert.Equal("0", job.FromBlock)
+	assert.Equal("1", job.ToBlock)
+	waitExportJob(sm, job.ID)
+
+	job, err = sm.ExportJobByID(ctx, "sub1", job.ID)
+	assert.NoError(err)
+	assert.Equal(ExportJobCompleted, job.Status)
+	assert.Equal(1, job.Events)
+	assert.NotEmpty(job.CompletedISO8601)
+	assert.Equal(path.Join(dir, "exports", "sub1", "0-1.csv"), job.Location)
+	b, err := ioutil.ReadFile(job.Location)
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(string(b), "blockNumber,"))
+
+	// Only the output is left in the directory
+	files, _ := ioutil.ReadDir(path.Join(dir, "exports", "sub1"))
+	assert.Len(files, 1)
+
+	jobs, err := sm.ExportJobs(ctx, "sub1")
+	assert.NoError(err)
+	assert.Len(jobs, 1)
+	_, err = sm.ExportJobs(ctx, "sub2")
+	assert.Regexp("FFEC100039", err)
+	_, err = sm.ExportJobByID(ctx, "sub2", job.ID)
+	assert.Regexp("FFEC100480", err)
+	_, err = sm.ExportJobByID(ctx, "sub1", "ej-unknown")
+	assert.Regexp("FFEC100480", err)
+}
+
+func TestExportJobLocalFailRemovesOutput(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir(t)
+	defer cleanup(t, dir)
+
+	rpc := &ethmocks.RPCClient{}
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).Return(fmt.Errorf("pop"))
+	sm := newTestExportJobManager(t, &ExportJobConf{Path: dir}, rpc)
+
+	job, err := sm.StartExportJob(context.Background(), "sub1", &SubscriptionExportRequest{FromBlock: "0", ToBlock: "1", Format: ExportFormatJSON})
+	assert.NoError(err)
+	waitExportJob(sm, job.ID)
+	job, _ = sm.ExportJobByID(context.Background(), "sub1", job.ID)
+	assert.Equal(ExportJobFailed, job.Status)
+	assert.Regexp("pop", job.Error)
+	assert.Empty(job.Location)
+	files, _ := ioutil.ReadDir(path.Join(dir, "sub1"))
+	assert.Empty(files)
+}
+
+func TestExportJobS3(t *testing.T) {
+	assert := assert.New(t)
+	m := newMockS3()
+	defer m.svr.Close()
+
+	rpc := &ethmocks.RPCClient{}
+	mockExportLogs(rpc, "0x1", 2)
+	sm := newTestExportJobManager(t, &ExportJobConf{
+		S3: utils.S3Conf{
+			Endpoint:        m.svr.URL,
+			Bucket:          "bucket1",
+			AccessKeyID:     "ak1",
+			SecretAccessKey: "sk1",
+			PathStyle:       true,
+		},
+	}, rpc)
+
+	job, err := sm.StartExportJob(context.Background(), "sub1", &SubscriptionExportRequest{FromBlock: "0", ToBlock: "1", Format: ExportFormatParquet})
+	assert.NoError(err)
+	waitExportJob(sm, job.ID)
+	job, _ = sm.ExportJobByID(context.Background(), "sub1", job.ID)
+	assert.Equal(ExportJobCompleted, job.Status)
+	assert.Equal("s3://bucket1/exports/sub1/0-1.parquet", job.Location)
+	assert.Len(readTestParquet(t, m.objects["/bucket1/exports/sub1/0-1.parquet"]), 2)
+
+	m.failPuts = true
+	job, err = sm.StartExportJob(context.Background(), "sub1", &SubscriptionExportRequest{FromBlock: "0", ToBlock: "1", Format: ExportFormatParquet})
+	assert.NoError(err)
+	waitExportJob(sm, job.ID)
+	job, _ = sm.ExportJobByID(context.Background(), "sub1", job.ID)
+	assert.Equal(ExportJobFailed, job.Status)
+}
+
+func TestExportJobValidation(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir(t)
+	defer cleanup(t, dir)
+	ctx := context.Background()
+
+	sm := newTestSubscriptionManager()
+	_, err := sm.StartExportJob(ctx, "sub1", &SubscriptionExportRequest{Format: ExportFormatCSV})
+	assert.Regexp("FFEC100479", err)
+
+	sm = newTestExportJobManager(t, &ExportJobConf{Path: dir}, &ethmocks.RPCClient{})
+	_, err = sm.StartExportJob(ctx, "sub2", &SubscriptionExportRequest{Format: ExportFormatCSV})
+	assert.Regexp("FFEC100039", err)
+	_, err = sm.StartExportJob(ctx, "sub1", &SubscriptionExportRequest{Format: "xml"})
+	assert.Regexp("FFEC100227", err)
+	_, err = sm.StartExportJob(ctx, "sub1", &SubscriptionExportRequest{Format: ExportFormatCSV, FromBlock: "10", ToBlock: "5"})
+	assert.Regexp("FFEC100228", err)
+
+	// The output directory for the subscription cannot be created
+	assert.NoError(ioutil.WriteFile(path.Join(dir, "sub1"), []byte{}, 0644))
+	_, err = sm.StartExportJob(ctx, "sub1", &SubscriptionExportRequest{Format: ExportFormatCSV, FromBlock: "0", ToBlock: "1"})
+	assert.Regexp("FFEC100229", err)
+	assert.Empty(sm.exportJobs)
+}
+
+func TestExportJobStoppedOnClose(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir(t)
+	defer cleanup(t, dir)
+
+	rpc := &ethmocks.RPCClient{}
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).
+		Run(func(args mock.Arguments) {
+			<-args[0].(context.Context).Done()
+		}).
+		Return(fmt.Errorf("cancelled"))
+	sm := newTestExportJobManager(t, &ExportJobConf{Path: dir}, rpc)
+
+	job, err := sm.StartExportJob(context.Background(), "sub1", &SubscriptionExportRequest{FromBlock: "0", ToBlock: "1", Format: ExportFormatCSV})
+	assert.NoError(err)
+	sm.Close(true)
+	job, _ = sm.ExportJobByID(context.Background(), "sub1", job.ID)
+	assert.Equal(ExportJobFailed, job.Status)
+}
+
+func TestInitExportJobs(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir(t)
+	defer cleanup(t, dir)
+
+	sm := newTestSubscriptionManager()
+	sm.config().EventLevelDBPath = path.Join(dir, "db")
+	sm.config().Exports = ExportJobConf{Path: path.Join(dir, "exports")}
+	assert.NoError(sm.Init())
+	defer sm.Close(false)
+	assert.IsType(&localExportOutput{}, sm.exports)
+	_, err := os.Stat(path.Join(dir, "exports"))
+	assert.NoError(err)
+}
+
+func TestInitExportJobsFail(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir(t)
+	defer cleanup(t, dir)
+
+	sm := newTestSubscriptionManager()
+	sm.config().EventLevelDBPath = path.Join(dir, "db")
+	sm.config().Exports = ExportJobConf{S3: utils.S3Conf{Bucket: "bucket1", Endpoint: ":::"}}
+	assert.Error(sm.Init())
+
+	assert.NoError(ioutil.WriteFile(path.Join(dir, "file"), []byte{}, 0644))
+	_, err := newExportOutput(&ExportJobConf{Path: path.Join(dir, "file", "exports")})
+	assert.Error(err)
+}
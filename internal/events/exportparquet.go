@@ -0,0 +1,179 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetKind is how a decoded event input is stored in its Parquet column
+type parquetKind int
+
+const (
+	parquetString parquetKind = iota
+	parquetInt64
+	parquetUint64
+	parquetBool
+	parquetJSON
+)
+
+type parquetField struct {
+	name string
+	kind parquetKind
+}
+
+// parquetExporter writes one row per event. The event inputs are in a "data" group, so their
+// names cannot clash with the columns that describe the log. The writer buffers rows into row
+// groups itself, and writes the footer when it is closed.
+type parquetExporter struct {
+	w          *parquet.Writer
+	fields     []parquetField
+	timestamps bool
+}
+
+// parquetKindForInput derives the column type from the ABI. Integers up to 64 bits are stored
+// as integers, and wider ones as decimal strings, as Parquet has no integer type that holds them.
+// Indexed inputs of dynamic types are only available as the hash in the topic.
+func parquetKindForInput(input *ethbinding.ABIArgument) parquetKind {
+	switch input.Type.T {
+	case ethbinding.IntTy:
+		if input.Type.Size <= 64 {
+			return parquetInt64
+		}
+	case ethbinding.UintTy:
+		if input.Type.Size <= 64 {
+			return parquetUint64
+		}
+	case ethbinding.BoolTy:
+		return parquetBool
+	case ethbinding.SliceTy, ethbinding.ArrayTy, ethbinding.TupleTy:
+		if !input.Indexed {
+			return parquetJSON
+		}
+	}
+	return parquetString
+}
+
+func (k parquetKind) node() parquet.Node {
+	switch k {
+	case parquetInt64:
+		return parquet.Int(64)
+	case parquetUint64:
+		return parquet.Uint(64)
+	case parquetBool:
+		return parquet.Leaf(parquet.BooleanType)
+	case parquetJSON:
+		return parquet.JSON()
+	default:
+		return parquet.String()
+	}
+}
+
+func newParquetExporter(w io.Writer, event *ethbinding.ABIEvent, timestamps bool) *parquetExporter {
+	pe := &parquetExporter{timestamps: timestamps}
+	data := parquet.Group{}
+	for i, name := range exportFieldNames(event) {
+		if name == "" {
+			// An unnamed indexed input has no key in the decoded data
+			continue
+		}
+		kind := parquetKindForInput(&event.Inputs[i])
+		pe.fields = append(pe.fields, parquetField{name: name, kind: kind})
+		data[name] = parquet.Optional(kind.node())
+	}
+	columns := parquet.Group{
+		"blockNumber":      parquet.Uint(64),
+		"blockHash":        parquet.String(),
+		"transactionHash":  parquet.String(),
+		"transactionIndex": parquet.Uint(64),
+		"logIndex":         parquet.Uint(64),
+		"address":          parquet.String(),
+		"signature":        parquet.String(),
+		"data":             data,
+	}
+	if timestamps {
+		columns["timestamp"] = parquet.Uint(64)
+	}
+	pe.w = parquet.NewWriter(w, parquet.NewSchema(event.RawName, columns))
+	return pe
+}
+
+func (pe *parquetExporter) value(field parquetField, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch field.kind {
+	case parquetInt64:
+		return strconv.ParseInt(csvValue(v), 10, 64)
+	case parquetUint64:
+		return strconv.ParseUint(csvValue(v), 10, 64)
+	case parquetBool:
+		return v, nil
+	case parquetJSON:
+		b, err := json.Marshal(v)
+		return string(b), err
+	default:
+		return csvValue(v), nil
+	}
+}
+
+func (pe *parquetExporter) writeEvent(e *exportedEvent) error {
+	data := make(map[string]interface{}, len(pe.fields))
+	for _, field := range pe.fields {
+		v, err := pe.value(field, e.Data[field.name])
+		if err != nil {
+			return err
+		}
+		data[field.name] = v
+	}
+	row := map[string]interface{}{
+		"blockHash":       e.BlockHash,
+		"transactionHash": e.TransactionHash,
+		"address":         e.Address,
+		"signature":       e.Signature,
+		"data":            data,
+	}
+	var err error
+	for _, c := range []struct {
+		name  string
+		value string
+	}{
+		{"blockNumber", e.BlockNumber},
+		{"transactionIndex", e.TransactionIndex},
+		{"logIndex", e.LogIndex},
+		{"timestamp", e.Timestamp},
+	} {
+		if c.name == "timestamp" && !pe.timestamps {
+			continue
+		}
+		if row[c.name], err = strconv.ParseUint(c.value, 10, 64); err != nil {
+			return err
+		}
+	}
+	return pe.w.Write(row)
+}
+
+func (pe *parquetExporter) flush() error {
+	return nil
+}
+
+func (pe *parquetExporter) close() error {
+	return pe.w.Close()
+}
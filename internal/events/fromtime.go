@@ -0,0 +1,85 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	log "github.com/sirupsen/logrus"
+)
+
+// ResolveFromTime converts an RFC3339 timestamp into the number of the first block
+// mined at or after that time, so a subscription can be started from a point in time.
+// If the time is after the current head of the chain, the latest block is returned.
+func (s *subscriptionMGR) ResolveFromTime(ctx context.Context, fromTime string) (string, error) {
+	t, err := time.Parse(time.RFC3339Nano, fromTime)
+	if err != nil {
+		return "", errors.Errorf(errors.EventStreamsSubscribeBadTime, fromTime)
+	}
+	block, err := s.blockAtTime(ctx, uint64(t.Unix()))
+	if err != nil {
+		return "", err
+	}
+	log.Infof("Resolved fromTime %s to block %d", fromTime, block)
+	return strconv.FormatUint(block, 10), nil
+}
+
+func (s *subscriptionMGR) blockTimestamp(ctx context.Context, blockNumber uint64) (uint64, error) {
+	var info *blockInfo
+	if err := s.rpc.CallContext(ctx, &info, "eth_getBlockByNumber", ethbinding.HexUint64(blockNumber), false /* only the txn hashes */); err != nil {
+		return 0, errors.Errorf(errors.RPCCallReturnedError, "eth_getBlockByNumber", err)
+	}
+	if info == nil {
+		return 0, errors.Errorf(errors.RPCCallReturnedError, "eth_getBlockByNumber", "block not found")
+	}
+	return uint64(info.Timestamp), nil
+}
+
+// blockAtTime binary searches block timestamps for the lowest block with a timestamp
+// at or after the target. Block timestamps are monotonic, so this is O(log n) RPC calls.
+func (s *subscriptionMGR) blockAtTime(ctx context.Context, target uint64) (uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var head ethbinding.HexUint64
+	if err := s.rpc.CallContext(ctx, &head, "eth_blockNumber"); err != nil {
+		return 0, errors.Errorf(errors.RPCCallReturnedError, "eth_blockNumber", err)
+	}
+	latest := uint64(head)
+	ts, err := s.blockTimestamp(ctx, latest)
+	if err != nil {
+		return 0, err
+	}
+	if ts < target {
+		return latest, nil
+	}
+	low, high := uint64(0), latest
+	for low < high {
+		mid := low + (high-low)/2
+		if ts, err = s.blockTimestamp(ctx, mid); err != nil {
+			return 0, err
+		}
+		if ts < target {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+	return low, nil
+}
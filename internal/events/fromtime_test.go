@@ -0,0 +1,125 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/mocks/ethmocks"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mockChainTimestamps mocks a chain where block N was mined at time 1000+10*N
+func mockChainTimestamps(rpc *ethmocks.RPCClient, head uint64) {
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_blockNumber").
+		Run(func(args mock.Arguments) {
+			*(args[1].(*ethbinding.HexUint64)) = ethbinding.HexUint64(head)
+		}).
+		Return(nil)
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_getBlockByNumber", mock.Anything, false).
+		Run(func(args mock.Arguments) {
+			n := uint64(args[3].(ethbinding.HexUint64))
+			*(args[1].(**blockInfo)) = &blockInfo{
+				Number:    ethbinding.HexUint(n),
+				Timestamp: ethbinding.HexUint(1000 + 10*n),
+			}
+		}).
+		Return(nil)
+}
+
+func TestResolveFromTime(t *testing.T) {
+	assert := assert.New(t)
+	sm := newTestSubscriptionManager()
+	rpc := sm.rpc.(*ethmocks.RPCClient)
+	mockChainTimestamps(rpc, 1000)
+
+	// Exact match on block 50 (t=1500)
+	block, err := sm.ResolveFromTime(context.Background(), "1970-01-01T00:25:00Z")
+	assert.NoError(err)
+	assert.Equal("50", block)
+
+	// Between blocks 50 and 51, so start from 51
+	block, err = sm.ResolveFromTime(context.Background(), "1970-01-01T00:25:05.5+00:00")
+	assert.NoError(err)
+	assert.Equal("51", block)
+
+	// Before genesis
+	block, err = sm.ResolveFromTime(context.Background(), "1970-01-01T00:00:00Z")
+	assert.NoError(err)
+	assert.Equal("0", block)
+
+	// After the head of the chain
+	block, err = sm.ResolveFromTime(context.Background(), "2022-01-03T00:00:00Z")
+	assert.NoError(err)
+	assert.Equal("1000", block)
+}
+
+func TestResolveFromTimeBadTime(t *testing.T) {
+	assert := assert.New(t)
+	sm := newTestSubscriptionManager()
+	_, err := sm.ResolveFromTime(context.Background(), "monday")
+	assert.Regexp("FFEC100247", err)
+}
+
+func TestResolveFromTimeBlockNumberFail(t *testing.T) {
+	assert := assert.New(t)
+	sm := newTestSubscriptionManager()
+	rpc := sm.rpc.(*ethmocks.RPCClient)
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_blockNumber").Return(fmt.Errorf("pop"))
+	_, err := sm.ResolveFromTime(context.Background(), "2022-01-03T00:00:00Z")
+	assert.Regexp("eth_blockNumber returned: pop", err)
+}
+
+func TestResolveFromTimeGetBlockFail(t *testing.T) {
+	assert := assert.New(t)
+	sm := newTestSubscriptionManager()
+	rpc := sm.rpc.(*ethmocks.RPCClient)
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_blockNumber").Return(nil)
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_getBlockByNumber", mock.Anything, false).Return(fmt.Errorf("pop"))
+	_, err := sm.ResolveFromTime(context.Background(), "2022-01-03T00:00:00Z")
+	assert.Regexp("eth_getBlockByNumber returned: pop", err)
+}
+
+func TestResolveFromTimeBlockNotFound(t *testing.T) {
+	assert := assert.New(t)
+	sm := newTestSubscriptionManager()
+	rpc := sm.rpc.(*ethmocks.RPCClient)
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_blockNumber").Return(nil)
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_getBlockByNumber", mock.Anything, false).Return(nil)
+	_, err := sm.ResolveFromTime(context.Background(), "2022-01-03T00:00:00Z")
+	assert.Regexp("FFEC100", err)
+}
+
+func TestAddSubscriptionFromTime(t *testing.T) {
+	assert := assert.New(t)
+	sm := newTestSubscriptionManager()
+	rpc := sm.rpc.(*ethmocks.RPCClient)
+	mockChainTimestamps(rpc, 100)
+
+	_, err := sm.AddSubscriptionDirect(context.Background(), &SubscriptionCreateDTO{
+		FromBlock: "0",
+		FromTime:  "2022-01-03T00:00:00Z",
+	})
+	assert.Regexp("FFEC100248", err)
+
+	_, err = sm.AddSubscriptionDirect(context.Background(), &SubscriptionCreateDTO{
+		FromTime: "bad",
+	})
+	assert.Regexp("FFEC100247", err)
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+)
+
+const (
+	defaultGetLogsBackoffFactor = 2.0
+	defaultGetLogsMaxBackoff    = 60 * time.Second
+)
+
+// Nodes do not agree on an error code for a query that matches too many logs, so
+// the messages of the common implementations are matched instead
+var tooManyResultsErrors = []string{
+	"query returned more than",
+	"too many results",
+	"response size exceeded",
+	"block range is too",
+	"range too large",
+}
+
+// GetLogsConf controls how a subscription calls eth_getLogs while catching up on historical
+// blocks. The zero value keeps the behavior of the stream manager configuration: pages of
+// catchupModePageSize blocks, retried on the next polling interval after a failure.
+type GetLogsConf struct {
+	MaxRange         int64   `json:"maxRange,omitempty"`
+	SplitRange       bool    `json:"splitRange,omitempty"`
+	InitialBackoffMS int64   `json:"initialBackoffMS,omitempty"`
+	MaxBackoffMS     int64   `json:"maxBackoffMS,omitempty"`
+	BackoffFactor    float64 `json:"backoffFactor,omitempty"`
+}
+
+func (c *GetLogsConf) validate() error {
+	if c.MaxRange < 0 || c.InitialBackoffMS < 0 || c.MaxBackoffMS < 0 ||
+		(c.BackoffFactor != 0 && c.BackoffFactor < 1) ||
+		(c.MaxBackoffMS != 0 && c.MaxBackoffMS < c.InitialBackoffMS) {
+		return errors.Errorf(errors.EventStreamsSubscribeBadGetLogsConf)
+	}
+	return nil
+}
+
+// getLogsRetry holds the runtime state of the policy for one subscription. The block range shrinks
+// each time the node reports too many results, and grows back after each successful query.
+type getLogsRetry struct {
+	maxRange   int64
+	blockRange int64
+	splitRange bool
+	initial    time.Duration
+	max        time.Duration
+	factor     float64
+	backoff    time.Duration
+	retryAfter time.Time
+}
+
+func newGetLogsRetry(conf *GetLogsConf, pageSize int64) *getLogsRetry {
+	if conf == nil {
+		conf = &GetLogsConf{}
+	}
+	r := &getLogsRetry{
+		maxRange:   pageSize,
+		splitRange: conf.SplitRange,
+		initial:    time.Duration(conf.InitialBackoffMS) * time.Millisecond,
+		max:        time.Duration(conf.MaxBackoffMS) * time.Millisecond,
+		factor:     conf.BackoffFactor,
+	}
+	if conf.MaxRange > 0 && (r.maxRange <= 0 || conf.MaxRange < r.maxRange) {
+		r.maxRange = conf.MaxRange
+	}
+	if r.max == 0 {
+		r.max = defaultGetLogsMaxBackoff
+	}
+	if r.factor == 0 {
+		r.factor = defaultGetLogsBackoffFactor
+	}
+	r.blockRange = r.maxRange
+	return r
+}
+
+// ready is false while backing off after a failure
+func (r *getLogsRetry) ready() bool {
+	return r.retryAfter.IsZero() || !time.Now().Before(r.retryAfter)
+}
+
+// split halves the block range if the error reports too many results, returning
+// false if the query should not be retried with the smaller range
+func (r *getLogsRetry) split(err error) bool {
+	if !r.splitRange || r.blockRange <= 1 {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range tooManyResultsErrors {
+		if strings.Contains(msg, s) {
+			r.blockRange /= 2
+			return true
+		}
+	}
+	return false
+}
+
+func (r *getLogsRetry) failed() {
+	if r.initial == 0 {
+		return
+	}
+	if r.backoff == 0 {
+		r.backoff = r.initial
+	} else {
+		r.backoff = time.Duration(float64(r.backoff) * r.factor)
+	}
+	if r.backoff > r.max {
+		r.backoff = r.max
+	}
+	r.retryAfter = time.Now().Add(r.backoff)
+}
+
+func (r *getLogsRetry) succeeded() {
+	r.backoff = 0
+	r.retryAfter = time.Time{}
+	if r.blockRange < r.maxRange {
+		r.blockRange *= 2
+		if r.blockRange > r.maxRange {
+			r.blockRange = r.maxRange
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/mocks/ethmocks"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func getLogsRange(f interface{}) (int64, int64) {
+	filter := f.(*ethFilter)
+	var to big.Int
+	to.SetString(filter.ToBlock[2:], 16)
+	return filter.FromBlock.ToInt().Int64(), to.Int64()
+}
+
+func newTestGetLogsSub(rpc *ethmocks.RPCClient, conf *GetLogsConf) *subscription {
+	return &subscription{
+		info:                &SubscriptionInfo{GetLogs: conf},
+		rpc:                 rpc,
+		lp:                  newLogProcessor("test", nil, nil, nil),
+		catchupBlock:        big.NewInt(1000),
+		catchupModePageSize: 100,
+	}
+}
+
+func TestGetLogsConfValidate(t *testing.T) {
+	assert := assert.New(t)
+	assert.NoError((&GetLogsConf{}).validate())
+	assert.NoError((&GetLogsConf{MaxRange: 10, SplitRange: true, InitialBackoffMS: 100, MaxBackoffMS: 1000, BackoffFactor: 1.5}).validate())
+	assert.Regexp("FFEC100346", (&GetLogsConf{MaxRange: -1}).validate())
+	assert.Regexp("FFEC100346", (&GetLogsConf{InitialBackoffMS: -1}).validate())
+	assert.Regexp("FFEC100346", (&GetLogsConf{BackoffFactor: 0.5}).validate())
+	assert.Regexp("FFEC100346", (&GetLogsConf{InitialBackoffMS: 1000, MaxBackoffMS: 100}).validate())
+}
+
+func TestGetLogsRetryMaxRange(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(int64(250), newGetLogsRetry(nil, 250).blockRange)
+	assert.Equal(int64(50), newGetLogsRetry(&GetLogsConf{MaxRange: 50}, 250).blockRange)
+	assert.Equal(int64(250), newGetLogsRetry(&GetLogsConf{MaxRange: 500}, 250).blockRange)
+}
+
+func TestGetLogsRetryBackoff(t *testing.T) {
+	assert := assert.New(t)
+	r := newGetLogsRetry(&GetLogsConf{InitialBackoffMS: 100, MaxBackoffMS: 250}, 250)
+	assert.True(r.ready())
+	r.failed()
+	assert.Equal(100*time.Millisecond, r.backoff)
+	assert.False(r.ready())
+	r.failed()
+	assert.Equal(200*time.Millisecond, r.backoff)
+	r.failed()
+	assert.Equal(250*time.Millisecond, r.backoff)
+	r.succeeded()
+	assert.Equal(time.Duration(0), r.backoff)
+	assert.True(r.ready())
+
+	// Without an initial backoff, failures are retried on the next polling interval
+	r = newGetLogsRetry(nil, 250)
+	r.failed()
+	assert.True(r.ready())
+}
+
+func TestProcessCatchupBlocksSplitRange(t *testing.T) {
+	assert := assert.New(t)
+	rpc := &ethmocks.RPCClient{}
+	var ranges [][2]int64
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).
+		Run(func(args mock.Arguments) {
+			from, to := getLogsRange(args[3])
+			ranges = append(ranges, [2]int64{from, to})
+		}).
+		Return(fmt.Errorf("query returned more than 10000 results")).Times(3)
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).
+		Run(func(args mock.Arguments) {
+			from, to := getLogsRange(args[3])
+			ranges = append(ranges, [2]int64{from, to})
+		}).
+		Return(nil)
+
+	s := newTestGetLogsSub(rpc, &GetLogsConf{MaxRange: 80, SplitRange: true})
+	err := s.processCatchupBlocks(context.Background())
+	assert.NoError(err)
+	assert.Equal([][2]int64{{1000, 1079}, {1000, 1039}, {1000, 1019}, {1000, 1009}}, ranges)
+	assert.Equal(int64(1010), s.catchupBlock.Int64())
+
+	// The range grows back after each successful query
+	assert.Equal(int64(20), s.getLogs.blockRange)
+	err = s.processCatchupBlocks(context.Background())
+	assert.NoError(err)
+	assert.Equal([2]int64{1010, 1029}, ranges[4])
+	assert.Equal(int64(40), s.getLogs.blockRange)
+	rpc.AssertExpectations(t)
+}
+
+func TestProcessCatchupBlocksSplitRangeToOneBlock(t *testing.T) {
+	assert := assert.New(t)
+	rpc := &ethmocks.RPCClient{}
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).
+		Return(fmt.Errorf("Log response size exceeded"))
+
+	s := newTestGetLogsSub(rpc, &GetLogsConf{SplitRange: true})
+	err := s.processCatchupBlocks(context.Background())
+	assert.Regexp("eth_getLogs returned: Log response size exceeded", err)
+	assert.Equal(int64(1), s.getLogs.blockRange)
+	assert.Equal(int64(1000), s.catchupBlock.Int64())
+	rpc.AssertNumberOfCalls(t, "CallContext", 7)
+}
+
+func TestProcessCatchupBlocksNoSplitWithoutConf(t *testing.T) {
+	assert := assert.New(t)
+	rpc := &ethmocks.RPCClient{}
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).
+		Return(fmt.Errorf("query returned more than 10000 results")).Once()
+
+	s := newTestGetLogsSub(rpc, nil)
+	err := s.processCatchupBlocks(context.Background())
+	assert.Regexp("eth_getLogs returned", err)
+	assert.Equal(int64(100), s.getLogs.blockRange)
+	rpc.AssertExpectations(t)
+}
+
+func TestProcessCatchupBlocksBackoff(t *testing.T) {
+	assert := assert.New(t)
+	rpc := &ethmocks.RPCClient{}
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).
+		Return(fmt.Errorf("pop")).Once()
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_getLogs", mock.Anything).
+		Run(func(args mock.Arguments) {
+			*(args[1].(*[]*logEntry)) = []*logEntry{}
+		}).
+		Return(nil).Once()
+
+	s := newTestGetLogsSub(rpc, &GetLogsConf{SplitRange: true, InitialBackoffMS: 10})
+	err := s.processCatchupBlocks(context.Background())
+	assert.Regexp("eth_getLogs returned: pop", err)
+
+	// Polls during the backoff do not call the node
+	err = s.processCatchupBlocks(context.Background())
+	assert.NoError(err)
+	rpc.AssertNumberOfCalls(t, "CallContext", 1)
+
+	for !s.getLogs.ready() {
+		time.Sleep(1 * time.Millisecond)
+	}
+	err = s.processCatchupBlocks(context.Background())
+	assert.NoError(err)
+	assert.Equal(int64(1100), s.catchupBlock.Int64())
+	rpc.AssertExpectations(t)
+}
+
+func TestAddSubscriptionBadGetLogsConf(t *testing.T) {
+	assert := assert.New(t)
+	sm := newTestSubscriptionManager()
+	_, err := sm.AddSubscriptionDirect(context.Background(), &SubscriptionCreateDTO{
+		Event:   &ethbinding.ABIElementMarshaling{Name: "ping"},
+		GetLogs: &GetLogsConf{MaxRange: -1},
+	})
+	assert.Regexp("FFEC100346", err)
+}
@@ -0,0 +1,378 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/eventspb"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type grpcActionInfo struct {
+	URL               string            `json:"url,omitempty"`
+	Headers           map[string]string `json:"headers,omitempty"`
+	TLS               utils.TLSConfig   `json:"tls,omitempty"`
+	RequestTimeoutSec uint32            `json:"requestTimeoutSec,omitempty"`
+}
+
+// grpcStream is the part of a Deliver stream used by the action, so tests can replace it
+type grpcStream interface {
+	Send(ctx context.Context, batch *eventspb.EventBatch) error
+	Recv(ctx context.Context) (*eventspb.BatchAck, error)
+	Close()
+}
+
+// grpcAction holds a Deliver stream open to the service between batches. A batch is only
+// complete once the service acks it, so the checkpoint of the stream never moves past events
+// the service has not processed.
+type grpcAction struct {
+	es     *eventStream
+	spec   *grpcActionInfo
+	open   func(ctx context.Context, spec *grpcActionInfo) (grpcStream, error)
+	mux    sync.Mutex
+	stream grpcStream
+	closed bool
+}
+
+func validateGRPC(spec *grpcActionInfo) error {
+	if spec == nil || spec.URL == "" {
+		return errors.Errorf(errors.EventStreamsGRPCNoURL)
+	}
+	_, _, err := parseGRPCURL(spec.URL)
+	return err
+}
+
+func newGRPCAction(es *eventStream, spec *grpcActionInfo) (*grpcAction, error) {
+	if err := validateGRPC(spec); err != nil {
+		return nil, err
+	}
+	if spec.RequestTimeoutSec == 0 {
+		spec.RequestTimeoutSec = 120
+	}
+	return &grpcAction{
+		es:   es,
+		spec: spec,
+		open: openGRPC,
+	}, nil
+}
+
+// parseGRPCURL checks the URL is grpc:// for plain text HTTP/2, or grpcs:// for TLS, and returns
+// the host and port to dial
+func parseGRPCURL(rawURL string) (string, bool, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return "", false, errors.Errorf(errors.GRPCInvalidURL, rawURL)
+	}
+	var useTLS bool
+	port := "80"
+	switch strings.ToLower(u.Scheme) {
+	case "grpc", "http":
+	case "grpcs", "https":
+		useTLS = true
+		port = "443"
+	default:
+		return "", false, errors.Errorf(errors.GRPCInvalidURL, rawURL)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	return net.JoinHostPort(u.Hostname(), port), useTLS, nil
+}
+
+// grpcConnection is a Deliver stream on its own connection to the service. Acks are read in the
+// background, so Send and Recv can give up when their context is done.
+type grpcConnection struct {
+	url    string
+	conn   *grpc.ClientConn
+	stream eventspb.EventStreamService_DeliverClient
+	cancel context.CancelFunc
+	acks   chan *eventspb.BatchAck
+	done   chan struct{}
+	err    error
+}
+
+// openGRPC opens a Deliver stream, with the headers sent as metadata. The stream lives until it
+// is closed, but opening it is bounded by the context.
+func openGRPC(ctx context.Context, spec *grpcActionInfo) (grpcStream, error) {
+	host, useTLS, err := parseGRPCURL(spec.URL)
+	if err != nil {
+		return nil, err
+	}
+	creds := insecure.NewCredentials()
+	if useTLS {
+		tlsConf := spec.TLS
+		tlsConf.Enabled = true
+		tlsConfig, err := utils.CreateTLSConfiguration(&tlsConf)
+		if err != nil {
+			return nil, err
+		}
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.Dial(host, grpc.WithTransportCredentials(creds), grpc.WithUserAgent("ethconnect"))
+	if err != nil {
+		return nil, errors.Errorf(errors.GRPCRequestFailed, spec.URL, err)
+	}
+
+	streamCtx, cancel := context.WithCancel(context.Background())
+	if len(spec.Headers) > 0 {
+		md := metadata.MD{}
+		for k, v := range spec.Headers {
+			md.Set(k, v)
+		}
+		streamCtx = metadata.NewOutgoingContext(streamCtx, md)
+	}
+	stopOpen := context.AfterFunc(ctx, cancel)
+	stream, err := eventspb.NewEventStreamServiceClient(conn).Deliver(streamCtx)
+	if !stopOpen() {
+		err = errors.Errorf(errors.GRPCTimeout, spec.URL)
+	}
+	if err != nil {
+		cancel()
+		conn.Close()
+		if _, ok := err.(errors.EthconnectError); ok {
+			return nil, err
+		}
+		return nil, errors.Errorf(errors.GRPCRequestFailed, spec.URL, err)
+	}
+	log.Infof("gRPC stream opened to %s", spec.URL)
+
+	c := &grpcConnection{
+		url:    spec.URL,
+		conn:   conn,
+		stream: stream,
+		cancel: cancel,
+		acks:   make(chan *eventspb.BatchAck),
+		done:   make(chan struct{}),
+	}
+	go c.readLoop(streamCtx)
+	return c, nil
+}
+
+func (c *grpcConnection) readLoop(ctx context.Context) {
+	defer close(c.done)
+	for {
+		ack, err := c.stream.Recv()
+		if err != nil {
+			c.err = c.streamError(ctx, err)
+			return
+		}
+		select {
+		case c.acks <- ack:
+		case <-ctx.Done():
+			c.err = errors.Errorf(errors.GRPCStreamClosed)
+			return
+		}
+	}
+}
+
+// streamError returns why the stream ended - closed by either side, or failed with a status
+func (c *grpcConnection) streamError(ctx context.Context, err error) error {
+	if err == io.EOF || ctx.Err() != nil {
+		return errors.Errorf(errors.GRPCStreamClosed)
+	}
+	if s, ok := status.FromError(err); ok {
+		return errors.Errorf(errors.GRPCStatus, c.url, s.Code(), s.Message())
+	}
+	return errors.Errorf(errors.GRPCRequestFailed, c.url, err)
+}
+
+// Send writes a batch to the stream. The write can block on HTTP/2 flow control, until the
+// service reads what was sent before, so it gives up when the context is done.
+func (c *grpcConnection) Send(ctx context.Context, batch *eventspb.EventBatch) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- c.stream.Send(batch)
+	}()
+	select {
+	case err := <-result:
+		if err == nil {
+			return nil
+		}
+		// Send returns io.EOF once the stream has ended, with the reason returned by Recv
+		select {
+		case <-c.done:
+			return c.err
+		case <-ctx.Done():
+			return errors.Errorf(errors.GRPCTimeout, c.url)
+		}
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		return errors.Errorf(errors.GRPCTimeout, c.url)
+	}
+}
+
+// Recv waits for the next ack from the service
+func (c *grpcConnection) Recv(ctx context.Context) (*eventspb.BatchAck, error) {
+	select {
+	case ack := <-c.acks:
+		return ack, nil
+	case <-c.done:
+		return nil, c.err
+	case <-ctx.Done():
+		return nil, errors.Errorf(errors.GRPCTimeout, c.url)
+	}
+}
+
+// Close ends the stream, and any calls waiting on it
+func (c *grpcConnection) Close() {
+	c.cancel()
+	<-c.done
+	c.conn.Close()
+}
+
+// buildBatch converts the events to their protobuf form, with the decoded data as JSON
+func (a *grpcAction) buildBatch(batchNumber, attempt uint64, events []*eventData) (*eventspb.EventBatch, error) {
+	batch := &eventspb.EventBatch{
+		StreamId:    a.es.spec.ID,
+		BatchNumber: batchNumber,
+		Attempt:     attempt,
+		Events:      make([]*eventspb.Event, len(events)),
+	}
+	for i, event := range events {
+		data, err := json.Marshal(event.Data)
+		if err != nil {
+			return nil, err
+		}
+		e := &eventspb.Event{
+			SubId:            event.SubID,
+			Signature:        event.Signature,
+			Address:          event.Address,
+			BlockNumber:      event.BlockNumber,
+			BlockHash:        event.BlockHash,
+			TransactionHash:  event.TransactionHash,
+			TransactionIndex: event.TransactionIndex,
+			LogIndex:         event.LogIndex,
+			Timestamp:        event.Timestamp,
+			Data:             string(data),
+			InputMethod:      event.InputMethod,
+			InputSigner:      event.InputSigner,
+		}
+		if event.InputArgs != nil {
+			inputArgs, err := json.Marshal(event.InputArgs)
+			if err != nil {
+				return nil, err
+			}
+			e.InputArgs = string(inputArgs)
+		}
+		batch.Events[i] = e
+	}
+	return batch, nil
+}
+
+func (a *grpcAction) connect(ctx context.Context) (grpcStream, error) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.closed {
+		return nil, errors.Errorf(errors.GRPCStreamClosed)
+	}
+	if a.stream == nil {
+		stream, err := a.open(ctx, a.spec)
+		if err != nil {
+			return nil, err
+		}
+		a.stream = stream
+	}
+	return a.stream, nil
+}
+
+func (a *grpcAction) disconnect(stream grpcStream) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.stream == stream {
+		a.stream = nil
+	}
+	stream.Close()
+}
+
+// close is called when the stream is stopped
+func (a *grpcAction) close() {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.closed = true
+	if a.stream != nil {
+		a.stream.Close()
+		a.stream = nil
+	}
+}
+
+// waitForAck reads acks until the one for this batch. Acks for other batch numbers are left
+// over from attempts that timed out, and are skipped.
+func (a *grpcAction) waitForAck(ctx context.Context, stream grpcStream, batchNumber uint64) (*eventspb.BatchAck, error) {
+	for {
+		ack, err := stream.Recv(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if ack.BatchNumber == batchNumber {
+			return ack, nil
+		}
+		log.Warnf("%s: gRPC ack for batch %d received while waiting for batch %d", a.es.spec.ID, ack.BatchNumber, batchNumber)
+	}
+}
+
+// attemptBatch sends the batch and waits for the service to ack it. A batch the service rejects
+// leaves the stream open for the retry, while any other failure drops it so the next attempt
+// starts a new one.
+func (a *grpcAction) attemptBatch(batchNumber, attempt uint64, events []*eventData) error {
+	esID := a.es.spec.ID
+	log.Infof("%s: gRPC deliver --> %s batch=%d events=%d (attempt=%d)", esID, a.spec.URL, batchNumber, len(events), attempt)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(a.spec.RequestTimeoutSec)*time.Second)
+	defer cancel()
+
+	batch, err := a.buildBatch(batchNumber, attempt, events)
+	var stream grpcStream
+	if err == nil {
+		stream, err = a.connect(ctx)
+	}
+	var ack *eventspb.BatchAck
+	if err == nil {
+		if err = stream.Send(ctx, batch); err == nil {
+			ack, err = a.waitForAck(ctx, stream, batchNumber)
+		}
+		if err != nil {
+			a.disconnect(stream)
+		}
+	}
+	if err == nil && ack.Error != "" {
+		err = errors.Errorf(errors.EventStreamsGRPCBatchRejected, batchNumber, ack.Error)
+	}
+	if err != nil {
+		log.Errorf("%s: gRPC deliver to %s failed (attempt=%d): %s", esID, a.spec.URL, attempt, err)
+		return err
+	}
+	log.Infof("%s: gRPC deliver <-- %s batch=%d acked", esID, a.spec.URL, batchNumber)
+	return nil
+}
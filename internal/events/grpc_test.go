@@ -0,0 +1,285 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/eventspb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type testGRPCStream struct {
+	sent    []*eventspb.EventBatch
+	acks    []*eventspb.BatchAck
+	sendErr error
+	closed  bool
+}
+
+func (s *testGRPCStream) Send(ctx context.Context, batch *eventspb.EventBatch) error {
+	s.sent = append(s.sent, batch)
+	return s.sendErr
+}
+
+// Recv returns the queued acks, then acks the last batch sent
+func (s *testGRPCStream) Recv(ctx context.Context) (*eventspb.BatchAck, error) {
+	if len(s.acks) > 0 {
+		ack := s.acks[0]
+		s.acks = s.acks[1:]
+		return ack, nil
+	}
+	return &eventspb.BatchAck{BatchNumber: s.sent[len(s.sent)-1].BatchNumber}, nil
+}
+
+func (s *testGRPCStream) Close() {
+	s.closed = true
+}
+
+func TestGRPCStreamDelivers(t *testing.T) {
+	assert := assert.New(t)
+
+	sm := newTestSubscriptionManager()
+	spec, err := sm.AddStream(context.Background(), &StreamInfo{
+		Type: "grpc",
+		GRPC: &grpcActionInfo{
+			URL:     "grpcs://localhost:50051",
+			Headers: map[string]string{"Authorization": "Bearer abc"},
+		},
+	})
+	assert.NoError(err)
+	defer sm.Close(true)
+	assert.Equal(uint32(120), spec.GRPC.RequestTimeoutSec)
+
+	var streams []*testGRPCStream
+	action := sm.streams[spec.ID].action.(*grpcAction)
+	action.open = func(ctx context.Context, spec *grpcActionInfo) (grpcStream, error) {
+		assert.Equal("grpcs://localhost:50051", spec.URL)
+		assert.Equal("Bearer abc", spec.Headers["Authorization"])
+		s := &testGRPCStream{}
+		streams = append(streams, s)
+		return s, nil
+	}
+
+	err = action.attemptBatch(1, 1, testPubSubEvents())
+	assert.NoError(err)
+	assert.Len(streams, 1)
+	batch := streams[0].sent[0]
+	assert.Equal(spec.ID, batch.StreamId)
+	assert.Equal(uint64(1), batch.BatchNumber)
+	assert.Equal(uint64(1), batch.Attempt)
+	assert.Equal("sb-1", batch.Events[0].SubId)
+	assert.Equal("Changed(uint256)", batch.Events[0].Signature)
+	assert.Equal(`{"i":"10"}`, batch.Events[0].Data)
+	assert.Empty(batch.Events[0].InputArgs)
+
+	// A late ack from an earlier attempt is skipped
+	streams[0].acks = []*eventspb.BatchAck{{BatchNumber: 1}}
+	err = action.attemptBatch(2, 1, testPubSubEvents())
+	assert.NoError(err)
+
+	// A rejected batch keeps the stream for the retry
+	streams[0].acks = []*eventspb.BatchAck{{BatchNumber: 3, Error: "pop"}}
+	err = action.attemptBatch(3, 1, testPubSubEvents())
+	assert.Regexp("FFEC100472.*3.*pop", err)
+	assert.False(streams[0].closed)
+	err = action.attemptBatch(3, 2, testPubSubEvents())
+	assert.NoError(err)
+	assert.Equal(uint64(2), streams[0].sent[3].Attempt)
+	assert.Len(streams, 1)
+
+	// A failed send drops the stream, and the next attempt opens a new one
+	streams[0].sendErr = fmt.Errorf("pop")
+	err = action.attemptBatch(4, 1, testPubSubEvents())
+	assert.Regexp("pop", err)
+	assert.True(streams[0].closed)
+	err = action.attemptBatch(4, 2, testPubSubEvents())
+	assert.NoError(err)
+	assert.Len(streams, 2)
+
+	sm.streams[spec.ID].stop(false)
+	assert.True(streams[1].closed)
+	err = action.attemptBatch(5, 1, testPubSubEvents())
+	assert.Regexp("FFEC100477", err)
+}
+
+func TestGRPCInputArgs(t *testing.T) {
+	assert := assert.New(t)
+
+	es := &eventStream{spec: &StreamInfo{ID: "es-1"}}
+	a, err := newGRPCAction(es, &grpcActionInfo{URL: "grpc://localhost:50051"})
+	assert.NoError(err)
+	events := testPubSubEvents()
+	events[0].InputMethod = "set"
+	events[0].InputArgs = map[string]interface{}{"x": "10"}
+	batch, err := a.buildBatch(1, 1, events)
+	assert.NoError(err)
+	assert.Equal("set", batch.Events[0].InputMethod)
+	assert.Equal(`{"x":"10"}`, batch.Events[0].InputArgs)
+
+	events[0].InputArgs = map[string]interface{}{"x": make(chan int)}
+	_, err = a.buildBatch(1, 1, events)
+	assert.Error(err)
+	events[0].Data = map[string]interface{}{"x": make(chan int)}
+	_, err = a.buildBatch(1, 1, events)
+	assert.Error(err)
+}
+
+func TestGRPCValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	es := &eventStream{spec: &StreamInfo{ID: "es-1"}}
+	_, err := newGRPCAction(es, nil)
+	assert.Regexp("FFEC100471", err)
+	_, err = newGRPCAction(es, &grpcActionInfo{})
+	assert.Regexp("FFEC100471", err)
+	_, err = newGRPCAction(es, &grpcActionInfo{URL: "http2://localhost"})
+	assert.Regexp("FFEC100473", err)
+
+	a, err := newGRPCAction(es, &grpcActionInfo{URL: "grpc://localhost:50051"})
+	assert.NoError(err)
+	a.open = func(ctx context.Context, spec *grpcActionInfo) (grpcStream, error) {
+		return nil, fmt.Errorf("pop")
+	}
+	err = a.attemptBatch(1, 1, testPubSubEvents())
+	assert.Regexp("pop", err)
+}
+
+// testGRPCService acks each batch it receives, but ends the call with a status on the first
+// attempt of batch failOn
+type testGRPCService struct {
+	eventspb.UnimplementedEventStreamServiceServer
+	batches chan *eventspb.EventBatch
+	headers chan metadata.MD
+	failOn  uint64
+}
+
+func (s *testGRPCService) Deliver(stream eventspb.EventStreamService_DeliverServer) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	s.headers <- md
+	for {
+		batch, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.batches <- batch
+		if batch.BatchNumber == s.failOn && batch.Attempt == 1 {
+			return status.Error(codes.PermissionDenied, "not allowed")
+		}
+		if err := stream.Send(&eventspb.BatchAck{BatchNumber: batch.BatchNumber}); err != nil {
+			return err
+		}
+	}
+}
+
+func newTestGRPCService(t *testing.T) (*testGRPCService, string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	svc := &testGRPCService{
+		batches: make(chan *eventspb.EventBatch, 10),
+		headers: make(chan metadata.MD, 10),
+		failOn:  99,
+	}
+	svr := grpc.NewServer()
+	eventspb.RegisterEventStreamServiceServer(svr, svc)
+	go svr.Serve(l)
+	return svc, "grpc://" + l.Addr().String(), svr.Stop
+}
+
+func TestGRPCStreamToService(t *testing.T) {
+	assert := assert.New(t)
+	svc, url, stop := newTestGRPCService(t)
+	defer stop()
+
+	es := &eventStream{spec: &StreamInfo{ID: "es-1"}}
+	a, err := newGRPCAction(es, &grpcActionInfo{
+		URL:     url,
+		Headers: map[string]string{"Authorization": "Bearer abc"},
+	})
+	assert.NoError(err)
+	defer a.close()
+	err = a.attemptBatch(7, 1, testPubSubEvents())
+	assert.NoError(err)
+	batch := <-svc.batches
+	assert.Equal("es-1", batch.StreamId)
+	assert.Equal(uint64(7), batch.BatchNumber)
+	assert.Len(batch.Events, 1)
+	assert.Equal([]string{"Bearer abc"}, (<-svc.headers).Get("authorization"))
+
+	// The service failing the call drops the stream, and the retry opens a new one
+	err = a.attemptBatch(99, 1, testPubSubEvents())
+	assert.Regexp("FFEC100475.*PermissionDenied.*not allowed", err)
+	<-svc.batches
+	err = a.attemptBatch(99, 2, testPubSubEvents())
+	assert.NoError(err)
+	assert.Len(svc.headers, 1)
+}
+
+func TestGRPCStreamServiceUnavailable(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := l.Addr().String()
+	l.Close()
+
+	es := &eventStream{spec: &StreamInfo{ID: "es-1"}}
+	a, err := newGRPCAction(es, &grpcActionInfo{URL: "grpc://" + addr, RequestTimeoutSec: 5})
+	assert.NoError(t, err)
+	err = a.attemptBatch(1, 1, testPubSubEvents())
+	assert.Regexp(t, "FFEC100474", err)
+}
+
+func TestGRPCParseURL(t *testing.T) {
+	assert := assert.New(t)
+
+	host, useTLS, err := parseGRPCURL("grpcs://events.example.com")
+	assert.NoError(err)
+	assert.Equal("events.example.com:443", host)
+	assert.True(useTLS)
+	host, useTLS, err = parseGRPCURL("grpc://localhost:50051/ignored")
+	assert.NoError(err)
+	assert.Equal("localhost:50051", host)
+	assert.False(useTLS)
+	_, _, err = parseGRPCURL("grpc://")
+	assert.Regexp("FFEC100473", err)
+}
+
+func TestGRPCStreamUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	sm := newTestSubscriptionManager()
+	ctx := context.Background()
+	spec, err := sm.AddStream(ctx, &StreamInfo{
+		Type: "grpc",
+		GRPC: &grpcActionInfo{URL: "grpc://localhost:50051"},
+	})
+	assert.NoError(err)
+	defer sm.Close(true)
+
+	updated, err := sm.UpdateStream(ctx, spec.ID, &StreamInfo{
+		GRPC: &grpcActionInfo{RequestTimeoutSec: 10},
+	})
+	assert.NoError(err)
+	assert.Equal(uint32(10), updated.GRPC.RequestTimeoutSec)
+	assert.Equal("grpc://localhost:50051", updated.GRPC.URL)
+}
@@ -0,0 +1,177 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/kafka"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type kafkaActionInfo struct {
+	Brokers  []string        `json:"brokers,omitempty"`
+	Topic    string          `json:"topic,omitempty"`
+	ClientID string          `json:"clientID,omitempty"`
+	TLS      utils.TLSConfig `json:"tls,omitempty"`
+	SASL     struct {
+		Username string `json:"username,omitempty"`
+		Password string `json:"password,omitempty"`
+	} `json:"sasl,omitempty"`
+	RequestTimeoutSec uint32 `json:"requestTimeoutSec,omitempty"`
+}
+
+// kafkaAction holds a producer open between batches, and drops it on any failure so the next
+// attempt reconnects to the brokers
+type kafkaAction struct {
+	es          *eventStream
+	spec        *kafkaActionInfo
+	newProducer func(addrs []string, config *sarama.Config) (sarama.SyncProducer, error)
+	mux         sync.Mutex
+	producer    sarama.SyncProducer
+	closed      bool
+}
+
+func validateKafka(spec *kafkaActionInfo) error {
+	if spec == nil || len(spec.Brokers) == 0 || spec.Brokers[0] == "" || spec.Topic == "" {
+		return errors.Errorf(errors.EventStreamsKafkaNoTopic)
+	}
+	if !utils.AllOrNoneReqd(spec.SASL.Username, spec.SASL.Password) {
+		return errors.Errorf(errors.ConfigKafkaMissingBadSASL)
+	}
+	return nil
+}
+
+func newKafkaAction(es *eventStream, spec *kafkaActionInfo) (*kafkaAction, error) {
+	if err := validateKafka(spec); err != nil {
+		return nil, err
+	}
+	if spec.RequestTimeoutSec == 0 {
+		spec.RequestTimeoutSec = 120
+	}
+	return &kafkaAction{
+		es:          es,
+		spec:        spec,
+		newProducer: sarama.NewSyncProducer,
+	}, nil
+}
+
+// buildMessages creates a message for each event, keyed by the subscription so the events of a
+// subscription are kept in order on a single partition
+func (a *kafkaAction) buildMessages(events []*eventData) ([]*sarama.ProducerMessage, error) {
+	msgs := make([]*sarama.ProducerMessage, len(events))
+	for i, event := range events {
+		b, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		headers := make([]sarama.RecordHeader, 0, 7)
+		for _, h := range [][2]string{
+			{"streamId", a.es.spec.ID},
+			{"subId", event.SubID},
+			{"signature", event.Signature},
+			{"address", event.Address},
+			{"blockNumber", event.BlockNumber},
+			{"transactionHash", event.TransactionHash},
+			{"logIndex", event.LogIndex},
+		} {
+			headers = append(headers, sarama.RecordHeader{Key: []byte(h[0]), Value: []byte(h[1])})
+		}
+		msgs[i] = &sarama.ProducerMessage{
+			Topic:   a.spec.Topic,
+			Key:     sarama.StringEncoder(event.SubID),
+			Value:   sarama.ByteEncoder(b),
+			Headers: headers,
+		}
+	}
+	return msgs, nil
+}
+
+func (a *kafkaAction) connect() (sarama.SyncProducer, error) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.closed {
+		return nil, errors.Errorf(errors.EventStreamsKafkaClosed, a.spec.Topic)
+	}
+	if a.producer == nil {
+		clientConf, err := kafka.NewSaramaConfig(a.spec.ClientID, &a.spec.TLS, a.spec.SASL.Username, a.spec.SASL.Password)
+		if err != nil {
+			return nil, err
+		}
+		clientConf.Producer.Return.Successes = true
+		clientConf.Producer.RequiredAcks = sarama.WaitForAll
+		clientConf.Producer.Timeout = time.Duration(a.spec.RequestTimeoutSec) * time.Second
+		if a.producer, err = a.newProducer(a.spec.Brokers, clientConf); err != nil {
+			return nil, err
+		}
+	}
+	return a.producer, nil
+}
+
+func (a *kafkaAction) disconnect(producer sarama.SyncProducer) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.producer == producer {
+		a.producer = nil
+	}
+	_ = producer.Close()
+}
+
+// close is called when the stream is stopped
+func (a *kafkaAction) close() {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.closed = true
+	if a.producer != nil {
+		_ = a.producer.Close()
+		a.producer = nil
+	}
+}
+
+// attemptBatch sends every event of the batch, and only succeeds once all the in-sync replicas
+// have acknowledged every message, so the checkpoint never moves past an undelivered event
+func (a *kafkaAction) attemptBatch(batchNumber, attempt uint64, events []*eventData) error {
+	esID := a.es.spec.ID
+	log.Infof("%s: Kafka send --> %s batch=%d events=%d (attempt=%d)", esID, a.spec.Topic, batchNumber, len(events), attempt)
+	msgs, err := a.buildMessages(events)
+	var producer sarama.SyncProducer
+	if err == nil {
+		producer, err = a.connect()
+	}
+	if err == nil {
+		if err = producer.SendMessages(msgs); err != nil {
+			a.disconnect(producer)
+			failed := len(msgs)
+			if producerErrs, ok := err.(sarama.ProducerErrors); ok {
+				failed = len(producerErrs)
+				err = producerErrs[0].Err
+			}
+			err = errors.Errorf(errors.EventStreamsKafkaSendFailed, esID, failed, len(msgs), a.spec.Topic, err)
+		}
+	}
+	if err != nil {
+		log.Errorf("%s: Kafka send to %s failed (attempt=%d): %s", esID, a.spec.Topic, attempt, err)
+		return err
+	}
+	last := msgs[len(msgs)-1]
+	log.Infof("%s: Kafka send <-- %s batch=%d partition=%d offset=%d", esID, a.spec.Topic, batchNumber, last.Partition, last.Offset)
+	return nil
+}
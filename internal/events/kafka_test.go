@@ -0,0 +1,147 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKafkaStreamSends(t *testing.T) {
+	assert := assert.New(t)
+
+	sm := newTestSubscriptionManager()
+	spec, err := sm.AddStream(context.Background(), &StreamInfo{
+		Type: "kafka",
+		Kafka: &kafkaActionInfo{
+			Brokers: []string{"localhost:9092"},
+			Topic:   "ethereum-events",
+		},
+	})
+	assert.NoError(err)
+	defer sm.Close(true)
+	assert.Equal(uint32(120), spec.Kafka.RequestTimeoutSec)
+
+	var saramaConf *sarama.Config
+	var produced []*sarama.ProducerMessage
+	mp := mocks.NewSyncProducer(t, nil)
+	for i := 0; i < 2; i++ {
+		mp.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+			produced = append(produced, msg)
+			return nil
+		})
+	}
+	action := sm.streams[spec.ID].action.(*kafkaAction)
+	action.newProducer = func(addrs []string, config *sarama.Config) (sarama.SyncProducer, error) {
+		assert.Equal([]string{"localhost:9092"}, addrs)
+		saramaConf = config
+		return mp, nil
+	}
+
+	err = action.attemptBatch(1, 1, testPubSubEvents())
+	assert.NoError(err)
+	assert.Equal(sarama.WaitForAll, saramaConf.Producer.RequiredAcks)
+	assert.Equal(120*time.Second, saramaConf.Producer.Timeout)
+	assert.False(saramaConf.Net.SASL.Enable)
+	msg := produced[0]
+	assert.Equal("ethereum-events", msg.Topic)
+	key, _ := msg.Key.Encode()
+	assert.Equal("sb-1", string(key))
+	value, _ := msg.Value.Encode()
+	var event eventData
+	assert.NoError(json.Unmarshal(value, &event))
+	assert.Equal("10", event.Data["i"])
+	assert.Equal("streamId", string(msg.Headers[0].Key))
+	assert.Equal(spec.ID, string(msg.Headers[0].Value))
+
+	// The producer is kept for the next batch
+	err = action.attemptBatch(2, 1, testPubSubEvents())
+	assert.NoError(err)
+	assert.Len(produced, 2)
+
+	updated, err := sm.UpdateStream(context.Background(), spec.ID, &StreamInfo{
+		Kafka: &kafkaActionInfo{RequestTimeoutSec: 10},
+	})
+	assert.NoError(err)
+	assert.Equal(uint32(10), updated.Kafka.RequestTimeoutSec)
+}
+
+func TestKafkaStreamSendFailReconnects(t *testing.T) {
+	assert := assert.New(t)
+
+	es := &eventStream{spec: &StreamInfo{ID: "es-1"}}
+	spec := &kafkaActionInfo{
+		Brokers: []string{"localhost:9092"},
+		Topic:   "ethereum-events",
+	}
+	spec.SASL.Username = "user"
+	spec.SASL.Password = "pass"
+	a, err := newKafkaAction(es, spec)
+	assert.NoError(err)
+
+	var producers []*mocks.SyncProducer
+	a.newProducer = func(addrs []string, config *sarama.Config) (sarama.SyncProducer, error) {
+		assert.True(config.Net.SASL.Enable)
+		if len(producers) == 0 {
+			mp := mocks.NewSyncProducer(t, nil)
+			mp.ExpectSendMessageAndFail(fmt.Errorf("pop"))
+			producers = append(producers, mp)
+			return mp, nil
+		}
+		return nil, fmt.Errorf("bang")
+	}
+	err = a.attemptBatch(1, 1, testPubSubEvents())
+	assert.Regexp("FFEC100448.*es-1: Failed to send 1 of 1 events to Kafka topic ethereum-events: pop", err)
+	assert.Nil(a.producer)
+
+	err = a.attemptBatch(1, 2, testPubSubEvents())
+	assert.Regexp("bang", err)
+
+	a.close()
+	err = a.attemptBatch(1, 3, testPubSubEvents())
+	assert.Regexp("FFEC100449", err)
+}
+
+func TestKafkaStreamValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	es := &eventStream{spec: &StreamInfo{ID: "es-1"}}
+	_, err := newKafkaAction(es, nil)
+	assert.Regexp("FFEC100447", err)
+	_, err = newKafkaAction(es, &kafkaActionInfo{Brokers: []string{""}, Topic: "events"})
+	assert.Regexp("FFEC100447", err)
+	_, err = newKafkaAction(es, &kafkaActionInfo{Brokers: []string{"localhost:9092"}})
+	assert.Regexp("FFEC100447", err)
+
+	spec := &kafkaActionInfo{Brokers: []string{"localhost:9092"}, Topic: "events"}
+	spec.SASL.Username = "user"
+	_, err = newKafkaAction(es, spec)
+	assert.Regexp("FFEC100018", err)
+
+	spec = &kafkaActionInfo{Brokers: []string{"localhost:9092"}, Topic: "events"}
+	spec.TLS.Enabled = true
+	spec.TLS.CACertsFile = "/does/not/exist"
+	a, err := newKafkaAction(es, spec)
+	assert.NoError(err)
+	err = a.attemptBatch(1, 1, testPubSubEvents())
+	assert.Error(err)
+}
@@ -154,50 +154,11 @@ func (lp *logProcessor) processLogEntry(subInfo string, entry *logEntry, idx int
 		return nil
 	}
 
-	var data []byte
-	if strings.HasPrefix(entry.Data, "0x") {
-		data, err = ethbind.API.HexDecode(entry.Data)
-		if err != nil {
-			return errors.Errorf(errors.EventStreamsLogDecode, subInfo, err)
-		}
-	}
-
 	if lp.stream.spec.Timestamps {
 		result.Timestamp = strconv.FormatUint(entry.Timestamp, 10)
 	}
-	topicIdx := 0
-	if !lp.event.Anonymous {
-		topicIdx++ // first index is the hash of the event description
-	}
-
-	// We need split out the indexed args that we parse out of the topic, from the data args
-	var dataArgs ethbinding.ABIArguments
-	dataArgs = make([]ethbinding.ABIArgument, 0, len(lp.event.Inputs))
-	for idx, input := range lp.event.Inputs {
-		var val interface{}
-		if input.Indexed {
-			if topicIdx >= len(entry.Topics) {
-				return errors.Errorf(errors.EventStreamsLogDecodeInsufficientTopics, subInfo, idx, ethbind.API.ABIEventSignature(lp.event))
-			}
-			topic := entry.Topics[topicIdx]
-			topicIdx++
-			if topic != nil {
-				val = topicToValue(topic, &input)
-			} else {
-				val = nil
-			}
-			result.Data[input.Name] = val
-		} else {
-			dataArgs = append(dataArgs, input)
-		}
-	}
-
-	// Retrieve the data args from the RLP and merge the results
-	if len(dataArgs) > 0 {
-		dataMap := eth.ProcessRLPBytes(dataArgs, data)
-		for k, v := range dataMap {
-			result.Data[k] = v
-		}
+	if result.Data, err = decodeLogData(subInfo, lp.event, entry); err != nil {
+		return err
 	}
 
 	// Ok, now we have the full event in a friendly map output. Pass it down to the event processor
@@ -245,3 +206,59 @@ func topicToValue(topic *ethbinding.Hash, input *ethbinding.ABIArgument) interfa
 		return topic.String()
 	}
 }
+
+// DecodeLog decodes the topics and data of a log entry, such as one from a transaction receipt,
+// using the supplied event definition
+func DecodeLog(event *ethbinding.ABIEvent, topics []*ethbinding.Hash, data string) (map[string]interface{}, error) {
+	return decodeLogData(ethbind.API.ABIEventSignature(event), event, &logEntry{Topics: topics, Data: data})
+}
+
+// decodeLogData parses the indexed fields out of the topics, and the remaining
+// fields out of the RLP encoded data, into a single map keyed by field name
+func decodeLogData(subInfo string, event *ethbinding.ABIEvent, entry *logEntry) (map[string]interface{}, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(entry.Data, "0x") {
+		data, err = ethbind.API.HexDecode(entry.Data)
+		if err != nil {
+			return nil, errors.Errorf(errors.EventStreamsLogDecode, subInfo, err)
+		}
+	}
+
+	topicIdx := 0
+	if !event.Anonymous {
+		topicIdx++ // first index is the hash of the event description
+	}
+
+	// We need split out the indexed args that we parse out of the topic, from the data args
+	result := make(map[string]interface{})
+	var dataArgs ethbinding.ABIArguments
+	dataArgs = make([]ethbinding.ABIArgument, 0, len(event.Inputs))
+	for idx, input := range event.Inputs {
+		var val interface{}
+		if input.Indexed {
+			if topicIdx >= len(entry.Topics) {
+				return nil, errors.Errorf(errors.EventStreamsLogDecodeInsufficientTopics, subInfo, idx, ethbind.API.ABIEventSignature(event))
+			}
+			topic := entry.Topics[topicIdx]
+			topicIdx++
+			if topic != nil {
+				val = topicToValue(topic, &input)
+			} else {
+				val = nil
+			}
+			result[input.Name] = val
+		} else {
+			dataArgs = append(dataArgs, input)
+		}
+	}
+
+	// Retrieve the data args from the RLP and merge the results
+	if len(dataArgs) > 0 {
+		dataMap := eth.ProcessRLPBytes(dataArgs, data)
+		for k, v := range dataMap {
+			result[k] = v
+		}
+	}
+	return result, nil
+}
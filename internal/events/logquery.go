@@ -0,0 +1,118 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"math/big"
+	"strconv"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/eth"
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	log "github.com/sirupsen/logrus"
+)
+
+// LogQuery is an on-demand eth_getLogs query for the events of one contract, outside of any
+// subscription. A nil ToBlock queries up to the latest block.
+type LogQuery struct {
+	Address   ethbinding.Address
+	Events    []*ethbinding.ABIEvent
+	FromBlock *big.Int
+	ToBlock   *big.Int
+}
+
+// QueriedEvent is a log returned by QueryLogs, decoded with the matching event of the query
+type QueriedEvent struct {
+	Address          string                 `json:"address"`
+	BlockNumber      string                 `json:"blockNumber"`
+	BlockHash        string                 `json:"blockHash"`
+	TransactionIndex string                 `json:"transactionIndex"`
+	TransactionHash  string                 `json:"transactionHash"`
+	LogIndex         string                 `json:"logIndex"`
+	Event            string                 `json:"event"`
+	Signature        string                 `json:"signature"`
+	Data             map[string]interface{} `json:"data"`
+}
+
+// queriedLogEntry adds the position of the log within its block, which subscriptions
+// do not read from the node
+type queriedLogEntry struct {
+	logEntry
+	LogIndex ethbinding.HexUint `json:"logIndex"`
+}
+
+// LatestBlock returns the current block number
+func LatestBlock(ctx context.Context, rpc eth.RPCClient) (*big.Int, error) {
+	blockNumber := ethbinding.HexBigInt{}
+	if err := rpc.CallContext(ctx, &blockNumber, "eth_blockNumber"); err != nil {
+		return nil, errors.Errorf(errors.RPCCallReturnedError, "eth_blockNumber", err)
+	}
+	return blockNumber.ToInt(), nil
+}
+
+// QueryLogs performs a single eth_getLogs call for the events of the query, returning the
+// logs oldest first. Logs that cannot be decoded are skipped, as the query is for
+// investigation rather than delivery.
+func QueryLogs(ctx context.Context, rpc eth.RPCClient, q *LogQuery) ([]*QueriedEvent, error) {
+	f := &ethFilter{}
+	f.Addresses = []ethbinding.Address{q.Address}
+	byTopic := make(map[ethbinding.Hash]*ethbinding.ABIEvent, len(q.Events))
+	topics := make([]ethbinding.Hash, 0, len(q.Events))
+	for _, event := range q.Events {
+		byTopic[event.ID] = event
+		topics = append(topics, event.ID)
+	}
+	f.Topics = [][]ethbinding.Hash{topics}
+	f.FromBlock.ToInt().Set(q.FromBlock)
+	f.ToBlock = "latest"
+	if q.ToBlock != nil {
+		f.ToBlock = "0x" + q.ToBlock.Text(16)
+	}
+
+	var logs []*queriedLogEntry
+	if err := rpc.CallContext(ctx, &logs, "eth_getLogs", f); err != nil {
+		return nil, errors.Errorf(errors.RPCCallReturnedError, "eth_getLogs", err)
+	}
+	results := make([]*QueriedEvent, 0, len(logs))
+	for _, entry := range logs {
+		if entry.Removed || len(entry.Topics) == 0 || entry.Topics[0] == nil {
+			continue
+		}
+		event, ok := byTopic[*entry.Topics[0]]
+		if !ok {
+			continue
+		}
+		signature := ethbind.API.ABIEventSignature(event)
+		data, err := decodeLogData(signature, event, &entry.logEntry)
+		if err != nil {
+			log.Warnf("Skipping log %d of transaction %s: %s", entry.LogIndex, entry.TransactionHash.String(), err)
+			continue
+		}
+		results = append(results, &QueriedEvent{
+			Address:          entry.Address.String(),
+			BlockNumber:      entry.BlockNumber.ToInt().String(),
+			BlockHash:        entry.BlockHash.String(),
+			TransactionIndex: strconv.FormatUint(uint64(entry.TransactionIndex), 10),
+			TransactionHash:  entry.TransactionHash.String(),
+			LogIndex:         strconv.FormatUint(uint64(entry.LogIndex), 10),
+			Event:            event.Name,
+			Signature:        signature,
+			Data:             data,
+		})
+	}
+	return results, nil
+}
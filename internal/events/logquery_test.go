@@ -0,0 +1,136 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	"github.com/hyperledger/firefly-ethconnect/mocks/ethmocks"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	testTransferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	testQueryAddr     = "0x0123456789abcdef0123456789abcdef01234567"
+)
+
+func testTransferEvent(t *testing.T) *ethbinding.ABIEvent {
+	event, err := ethbind.API.ABIElementMarshalingToABIEvent(&ethbinding.ABIElementMarshaling{
+		Type: "event",
+		Name: "Transfer",
+		Inputs: []ethbinding.ABIArgumentMarshaling{
+			{Name: "from", Type: "address", Indexed: true},
+			{Name: "to", Type: "address", Indexed: true},
+			{Name: "value", Type: "uint256"},
+		},
+	})
+	assert.NoError(t, err)
+	return event
+}
+
+func testTopic(hex string) *ethbinding.Hash {
+	h := ethbind.API.HexToHash(hex)
+	return &h
+}
+
+func TestQueryLogs(t *testing.T) {
+	assert := assert.New(t)
+
+	rpc := &ethmocks.RPCClient{}
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_getLogs", mock.MatchedBy(func(f *ethFilter) bool {
+		return f.FromBlock.ToInt().Int64() == 100 && f.ToBlock == "0xc8" &&
+			f.Addresses[0].String() == "0x0123456789abcDEF0123456789abCDef01234567" &&
+			f.Topics[0][0].String() == testTransferTopic
+	})).Run(func(args mock.Arguments) {
+		logs := args[1].(*[]*queriedLogEntry)
+		*logs = []*queriedLogEntry{
+			{
+				logEntry: logEntry{
+					Address:         ethbind.API.HexToAddress(testQueryAddr),
+					BlockNumber:     ethbinding.HexBigInt(*big.NewInt(150)),
+					TransactionHash: ethbind.API.HexToHash("0xabcd"),
+					Topics: []*ethbinding.Hash{
+						testTopic(testTransferTopic),
+						testTopic("0x000000000000000000000000aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"),
+						testTopic("0x000000000000000000000000bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"),
+					},
+					Data: "0x000000000000000000000000000000000000000000000000000000000000000a",
+				},
+				LogIndex: 3,
+			},
+			// Skipped: removed, not matching, and missing indexed topics
+			{logEntry: logEntry{Removed: true, Topics: []*ethbinding.Hash{testTopic(testTransferTopic)}}},
+			{logEntry: logEntry{Topics: []*ethbinding.Hash{testTopic("0x1234")}}},
+			{},
+			{logEntry: logEntry{Topics: []*ethbinding.Hash{testTopic(testTransferTopic)}}},
+		}
+	}).Return(nil)
+
+	results, err := QueryLogs(context.Background(), rpc, &LogQuery{
+		Address:   ethbind.API.HexToAddress(testQueryAddr),
+		Events:    []*ethbinding.ABIEvent{testTransferEvent(t)},
+		FromBlock: big.NewInt(100),
+		ToBlock:   big.NewInt(200),
+	})
+	assert.NoError(err)
+	assert.Len(results, 1)
+	assert.Equal("150", results[0].BlockNumber)
+	assert.Equal("3", results[0].LogIndex)
+	assert.Equal("Transfer", results[0].Event)
+	assert.Equal("Transfer(address,address,uint256)", results[0].Signature)
+	assert.Equal("10", results[0].Data["value"])
+	assert.Equal("0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", strings.ToLower(fmt.Sprintf("%v", results[0].Data["to"])))
+
+	rpc.AssertExpectations(t)
+}
+
+func TestQueryLogsLatestFail(t *testing.T) {
+	assert := assert.New(t)
+
+	rpc := &ethmocks.RPCClient{}
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_getLogs", mock.MatchedBy(func(f *ethFilter) bool {
+		return f.ToBlock == "latest"
+	})).Return(fmt.Errorf("pop"))
+
+	_, err := QueryLogs(context.Background(), rpc, &LogQuery{
+		Events:    []*ethbinding.ABIEvent{testTransferEvent(t)},
+		FromBlock: big.NewInt(0),
+	})
+	assert.Regexp("FFEC100.*eth_getLogs.*pop", err)
+}
+
+func TestLatestBlock(t *testing.T) {
+	assert := assert.New(t)
+
+	rpc := &ethmocks.RPCClient{}
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_blockNumber").
+		Run(func(args mock.Arguments) {
+			args[1].(*ethbinding.HexBigInt).ToInt().SetInt64(12345)
+		}).Return(nil).Once()
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_blockNumber").Return(fmt.Errorf("pop"))
+
+	blockNumber, err := LatestBlock(context.Background(), rpc)
+	assert.NoError(err)
+	assert.Equal(int64(12345), blockNumber.Int64())
+	_, err = LatestBlock(context.Background(), rpc)
+	assert.Regexp("pop", err)
+}
@@ -0,0 +1,439 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/eclipse/paho.golang/paho"
+	pahomqtt "github.com/eclipse/paho.mqtt.golang"
+	packets3 "github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMQTTQoS = 1
+	// mqttProtocolV311 is the protocol level of MQTT 3.1.1
+	mqttProtocolV311 byte = 4
+	// mqttProtocolV5 is the protocol level of MQTT 5
+	mqttProtocolV5          byte = 5
+	mqttKeepAliveSec             = 60
+	mqttDisconnectQuiesceMS      = 250
+)
+
+type mqttActionInfo struct {
+	URL               string          `json:"url,omitempty"`
+	Topic             string          `json:"topic,omitempty"`
+	QoS               *uint8          `json:"qos,omitempty"`
+	Retain            bool            `json:"retain,omitempty"`
+	ProtocolVersion   string          `json:"protocolVersion,omitempty"`
+	ClientID          string          `json:"clientID,omitempty"`
+	Username          string          `json:"username,omitempty"`
+	Password          string          `json:"password,omitempty"`
+	TLS               utils.TLSConfig `json:"tls,omitempty"`
+	RequestTimeoutSec uint32          `json:"requestTimeoutSec,omitempty"`
+}
+
+// mqttTopicData is what the topic template is executed against, for each event
+type mqttTopicData struct {
+	*eventData
+	StreamID string
+	Event    string
+}
+
+// mqttPublisher is the part of an MQTT connection used by the action, so tests can replace it
+type mqttPublisher interface {
+	Publish(ctx context.Context, msgs ...*paho.Publish) error
+	Close()
+}
+
+// mqttAction holds a connection open to the broker between batches, and drops it on any failure
+// so the next attempt reconnects
+type mqttAction struct {
+	es        *eventStream
+	spec      *mqttActionInfo
+	version   byte
+	topic     *template.Template
+	dial      func(ctx context.Context, spec *mqttActionInfo, version byte) (mqttPublisher, error)
+	mux       sync.Mutex
+	publisher mqttPublisher
+	closed    bool
+}
+
+// parseMQTTProtocolVersion parses the version as configured, to the protocol level sent to the broker
+func parseMQTTProtocolVersion(version string) (byte, error) {
+	switch version {
+	case "", "3.1.1", "4":
+		return mqttProtocolV311, nil
+	case "5", "5.0":
+		return mqttProtocolV5, nil
+	default:
+		return 0, errors.Errorf(errors.MQTTInvalidProtocolVersion, version)
+	}
+}
+
+// parseMQTTURL checks the broker URL, and returns the host and port to connect to and whether to use TLS
+func parseMQTTURL(brokerURL string) (host string, useTLS bool, err error) {
+	u, err := url.Parse(brokerURL)
+	if err != nil || u.Hostname() == "" {
+		return "", false, errors.Errorf(errors.MQTTInvalidURL, brokerURL)
+	}
+	port := "1883"
+	switch u.Scheme {
+	case "mqtt", "tcp":
+	case "mqtts", "ssl", "tls":
+		useTLS = true
+		port = "8883"
+	default:
+		return "", false, errors.Errorf(errors.MQTTInvalidURL, brokerURL)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	return net.JoinHostPort(u.Hostname(), port), useTLS, nil
+}
+
+// validateMQTT checks the configuration, and parses the topic template. The template is tried
+// against an empty event, so a reference to a field that does not exist fails here rather than
+// on every batch.
+func validateMQTT(spec *mqttActionInfo) (byte, *template.Template, error) {
+	if spec == nil || spec.URL == "" || spec.Topic == "" {
+		return 0, nil, errors.Errorf(errors.EventStreamsMQTTNoTopic)
+	}
+	if _, _, err := parseMQTTURL(spec.URL); err != nil {
+		return 0, nil, err
+	}
+	version, err := parseMQTTProtocolVersion(spec.ProtocolVersion)
+	if err != nil {
+		return 0, nil, err
+	}
+	if spec.QoS != nil && *spec.QoS > 2 {
+		return 0, nil, errors.Errorf(errors.EventStreamsMQTTInvalidQoS, *spec.QoS)
+	}
+	topic, err := template.New("topic").Parse(spec.Topic)
+	if err == nil {
+		err = topic.Execute(&strings.Builder{}, &mqttTopicData{eventData: &eventData{}})
+	}
+	if err != nil {
+		return 0, nil, errors.Errorf(errors.EventStreamsMQTTInvalidTopic, spec.Topic, err)
+	}
+	return version, topic, nil
+}
+
+func newMQTTAction(es *eventStream, spec *mqttActionInfo) (*mqttAction, error) {
+	version, topic, err := validateMQTT(spec)
+	if err != nil {
+		return nil, err
+	}
+	if spec.QoS == nil {
+		qos := uint8(defaultMQTTQoS)
+		spec.QoS = &qos
+	}
+	if spec.RequestTimeoutSec == 0 {
+		spec.RequestTimeoutSec = 120
+	}
+	return &mqttAction{
+		es:      es,
+		spec:    spec,
+		version: version,
+		topic:   topic,
+		dial:    dialMQTT,
+	}, nil
+}
+
+// dialMQTT connects to the broker with a clean session, using paho.golang for MQTT 5 and
+// paho.mqtt.golang for MQTT 3.1.1. Neither reconnects, as the action reconnects on the next
+// attempt after any failure.
+func dialMQTT(ctx context.Context, spec *mqttActionInfo, version byte) (mqttPublisher, error) {
+	host, useTLS, err := parseMQTTURL(spec.URL)
+	if err != nil {
+		return nil, err
+	}
+	username, password := spec.Username, spec.Password
+	if username == "" {
+		if u, _ := url.Parse(spec.URL); u.User != nil {
+			username = u.User.Username()
+			password, _ = u.User.Password()
+		}
+	}
+	clientID := spec.ClientID
+	if clientID == "" {
+		// 23 characters is the longest client identifier every broker has to accept
+		clientID = "ethconnect-" + strings.ReplaceAll(utils.UUIDv4(), "-", "")[:12]
+	}
+	tlsConf := spec.TLS
+	if useTLS {
+		tlsConf.Enabled = true
+	}
+	tlsConfig, err := utils.CreateTLSConfiguration(&tlsConf)
+	if err != nil {
+		return nil, err
+	}
+	timeout := time.Duration(spec.RequestTimeoutSec) * time.Second
+	if version == mqttProtocolV5 {
+		return dialMQTTv5(ctx, host, tlsConfig, timeout, &paho.Connect{
+			ClientID:     clientID,
+			KeepAlive:    mqttKeepAliveSec,
+			CleanStart:   true,
+			Username:     username,
+			UsernameFlag: username != "",
+			Password:     []byte(password),
+			PasswordFlag: password != "",
+		})
+	}
+	scheme := "tcp://"
+	if tlsConfig != nil {
+		scheme = "ssl://"
+	}
+	opts := pahomqtt.NewClientOptions().
+		AddBroker(scheme + host).
+		SetClientID(clientID).
+		SetUsername(username).
+		SetPassword(password).
+		SetTLSConfig(tlsConfig).
+		SetProtocolVersion(uint(mqttProtocolV311)).
+		SetCleanSession(true).
+		SetAutoReconnect(false).
+		SetKeepAlive(mqttKeepAliveSec * time.Second).
+		SetConnectTimeout(timeout)
+	return dialMQTTv311(ctx, host, opts)
+}
+
+// mqttV5Connection publishes with MQTT 5, so the content type and user properties are sent
+type mqttV5Connection struct {
+	host   string
+	client *paho.Client
+}
+
+func dialMQTTv5(ctx context.Context, host string, tlsConfig *tls.Config, timeout time.Duration, connect *paho.Connect) (*mqttV5Connection, error) {
+	var netConn net.Conn
+	var err error
+	if tlsConfig != nil {
+		dialer := &tls.Dialer{Config: tlsConfig}
+		netConn, err = dialer.DialContext(ctx, "tcp", host)
+	} else {
+		dialer := &net.Dialer{}
+		netConn, err = dialer.DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return nil, errors.Errorf(errors.MQTTConnectFailed, host, err)
+	}
+	client := paho.NewClient(paho.ClientConfig{
+		ClientID:      connect.ClientID,
+		Conn:          packets.NewThreadSafeConn(netConn),
+		PacketTimeout: timeout,
+	})
+	connack, err := client.Connect(ctx, connect)
+	if err != nil {
+		if connack != nil {
+			return nil, errors.Errorf(errors.MQTTConnectRejected, host, fmt.Sprintf("reason code 0x%02X %s", connack.ReasonCode, err))
+		}
+		return nil, errors.Errorf(errors.MQTTConnectFailed, host, err)
+	}
+	log.Infof("MQTT connected to %s (version=5)", host)
+	return &mqttV5Connection{host: host, client: client}, nil
+}
+
+// Publish sends each message in turn, waiting for the broker to acknowledge it at its QoS
+func (c *mqttV5Connection) Publish(ctx context.Context, msgs ...*paho.Publish) error {
+	for _, msg := range msgs {
+		res, err := c.client.Publish(ctx, msg)
+		if err != nil {
+			if ctx.Err() != nil {
+				return errors.Errorf(errors.MQTTTimeout, c.host)
+			}
+			return errors.Errorf(errors.MQTTPublishFailed, msg.Topic, err)
+		}
+		// With QoS 2 a failure is returned in the PUBREC, without an error
+		if res != nil && res.ReasonCode >= 0x80 {
+			return errors.Errorf(errors.MQTTPublishFailed, msg.Topic, fmt.Sprintf("reason code 0x%02X", res.ReasonCode))
+		}
+	}
+	return nil
+}
+
+func (c *mqttV5Connection) Close() {
+	_ = c.client.Disconnect(&paho.Disconnect{ReasonCode: 0})
+}
+
+// mqttV311Connection publishes with MQTT 3.1.1, which has no message properties
+type mqttV311Connection struct {
+	host   string
+	client pahomqtt.Client
+}
+
+func waitMQTTToken(ctx context.Context, host string, token pahomqtt.Token) error {
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return errors.Errorf(errors.MQTTTimeout, host)
+	}
+}
+
+func dialMQTTv311(ctx context.Context, host string, opts *pahomqtt.ClientOptions) (*mqttV311Connection, error) {
+	client := pahomqtt.NewClient(opts)
+	token := client.Connect()
+	if err := waitMQTTToken(ctx, host, token); err != nil {
+		if _, ok := err.(errors.EthconnectError); ok {
+			return nil, err
+		}
+		// Return codes 1-5 are sent by the broker in the CONNACK, higher ones are local failures
+		if ct, ok := token.(*pahomqtt.ConnectToken); ok && ct.ReturnCode() > packets3.Accepted && ct.ReturnCode() <= packets3.ErrRefusedNotAuthorised {
+			return nil, errors.Errorf(errors.MQTTConnectRejected, host, err)
+		}
+		return nil, errors.Errorf(errors.MQTTConnectFailed, host, err)
+	}
+	log.Infof("MQTT connected to %s (version=3.1.1)", host)
+	return &mqttV311Connection{host: host, client: client}, nil
+}
+
+// Publish sends all of the messages, then waits for the broker to acknowledge each at its QoS
+func (c *mqttV311Connection) Publish(ctx context.Context, msgs ...*paho.Publish) error {
+	tokens := make([]pahomqtt.Token, len(msgs))
+	for i, msg := range msgs {
+		tokens[i] = c.client.Publish(msg.Topic, msg.QoS, msg.Retain, msg.Payload)
+	}
+	for i, token := range tokens {
+		if err := waitMQTTToken(ctx, c.host, token); err != nil {
+			if _, ok := err.(errors.EthconnectError); ok {
+				return err
+			}
+			return errors.Errorf(errors.MQTTPublishFailed, msgs[i].Topic, err)
+		}
+	}
+	return nil
+}
+
+func (c *mqttV311Connection) Close() {
+	c.client.Disconnect(mqttDisconnectQuiesceMS)
+}
+
+// buildMessages creates a message for each event, on the topic the template gives for that event
+func (a *mqttAction) buildMessages(events []*eventData) ([]*paho.Publish, error) {
+	msgs := make([]*paho.Publish, len(events))
+	for i, event := range events {
+		b, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		topic := &strings.Builder{}
+		data := &mqttTopicData{
+			eventData: event,
+			StreamID:  a.es.spec.ID,
+			Event:     strings.SplitN(event.Signature, "(", 2)[0],
+		}
+		if err = a.topic.Execute(topic, data); err != nil {
+			return nil, errors.Errorf(errors.EventStreamsMQTTInvalidTopic, a.spec.Topic, err)
+		}
+		if topic.Len() == 0 || strings.ContainsAny(topic.String(), "+#\x00") {
+			return nil, errors.Errorf(errors.MQTTInvalidTopicName, topic.String())
+		}
+		msgs[i] = &paho.Publish{
+			Topic:  topic.String(),
+			QoS:    *a.spec.QoS,
+			Retain: a.spec.Retain,
+			Properties: &paho.PublishProperties{
+				ContentType: "application/json",
+				User: paho.UserProperties{
+					{Key: "streamId", Value: a.es.spec.ID},
+					{Key: "subId", Value: event.SubID},
+					{Key: "signature", Value: event.Signature},
+					{Key: "address", Value: event.Address},
+					{Key: "blockNumber", Value: event.BlockNumber},
+					{Key: "transactionHash", Value: event.TransactionHash},
+					{Key: "logIndex", Value: event.LogIndex},
+				},
+			},
+			Payload: b,
+		}
+	}
+	return msgs, nil
+}
+
+func (a *mqttAction) connect(ctx context.Context) (mqttPublisher, error) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.closed {
+		return nil, errors.Errorf(errors.MQTTConnectionClosed)
+	}
+	if a.publisher == nil {
+		publisher, err := a.dial(ctx, a.spec, a.version)
+		if err != nil {
+			return nil, err
+		}
+		a.publisher = publisher
+	}
+	return a.publisher, nil
+}
+
+func (a *mqttAction) disconnect(publisher mqttPublisher) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.publisher == publisher {
+		a.publisher = nil
+	}
+	publisher.Close()
+}
+
+// close is called when the stream is stopped
+func (a *mqttAction) close() {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.closed = true
+	if a.publisher != nil {
+		a.publisher.Close()
+		a.publisher = nil
+	}
+}
+
+// attemptBatch publishes every event of the batch. With QoS 1 or 2 it only succeeds once the
+// broker has acknowledged all of them, while with QoS 0 events lost in transit are not retried.
+func (a *mqttAction) attemptBatch(batchNumber, attempt uint64, events []*eventData) error {
+	esID := a.es.spec.ID
+	log.Infof("%s: MQTT publish --> %s batch=%d events=%d qos=%d (attempt=%d)", esID, a.spec.URL, batchNumber, len(events), *a.spec.QoS, attempt)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(a.spec.RequestTimeoutSec)*time.Second)
+	defer cancel()
+
+	msgs, err := a.buildMessages(events)
+	var publisher mqttPublisher
+	if err == nil {
+		publisher, err = a.connect(ctx)
+	}
+	if err == nil {
+		if err = publisher.Publish(ctx, msgs...); err != nil {
+			a.disconnect(publisher)
+		}
+	}
+	if err != nil {
+		log.Errorf("%s: MQTT publish to %s failed (attempt=%d): %s", esID, a.spec.URL, attempt, err)
+		return err
+	}
+	log.Infof("%s: MQTT publish <-- %s batch=%d", esID, a.spec.URL, batchNumber)
+	return nil
+}
@@ -0,0 +1,366 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/eclipse/paho.golang/paho"
+	packets3 "github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/stretchr/testify/assert"
+)
+
+type testMQTTPublisher struct {
+	published [][]*paho.Publish
+	err       error
+	closed    bool
+}
+
+func (p *testMQTTPublisher) Publish(ctx context.Context, msgs ...*paho.Publish) error {
+	p.published = append(p.published, msgs)
+	return p.err
+}
+
+func (p *testMQTTPublisher) Close() {
+	p.closed = true
+}
+
+func TestMQTTStreamPublishes(t *testing.T) {
+	assert := assert.New(t)
+
+	sm := newTestSubscriptionManager()
+	spec, err := sm.AddStream(context.Background(), &StreamInfo{
+		Type: "mqtt",
+		MQTT: &mqttActionInfo{
+			URL:             "mqtts://localhost",
+			Topic:           "ethconnect/{{.StreamID}}/{{.Event}}/{{.SubID}}",
+			ProtocolVersion: "5",
+			Retain:          true,
+		},
+	})
+	assert.NoError(err)
+	defer sm.Close(true)
+	assert.Equal(uint32(120), spec.MQTT.RequestTimeoutSec)
+	assert.Equal(uint8(1), *spec.MQTT.QoS)
+
+	var publishers []*testMQTTPublisher
+	action := sm.streams[spec.ID].action.(*mqttAction)
+	action.dial = func(ctx context.Context, spec *mqttActionInfo, version byte) (mqttPublisher, error) {
+		assert.Equal(mqttProtocolV5, version)
+		assert.Equal("mqtts://localhost", spec.URL)
+		p := &testMQTTPublisher{}
+		publishers = append(publishers, p)
+		return p, nil
+	}
+
+	err = action.attemptBatch(1, 1, testPubSubEvents())
+	assert.NoError(err)
+	err = action.attemptBatch(2, 1, testPubSubEvents())
+	assert.NoError(err)
+	assert.Len(publishers, 1)
+	assert.Len(publishers[0].published, 2)
+	msg := publishers[0].published[0][0]
+	assert.Equal(fmt.Sprintf("ethconnect/%s/Changed/sb-1", spec.ID), msg.Topic)
+	assert.Equal(byte(1), msg.QoS)
+	assert.True(msg.Retain)
+	assert.Equal("application/json", msg.Properties.ContentType)
+	assert.Equal(spec.ID, msg.Properties.User.Get("streamId"))
+	assert.Equal("Changed(uint256)", msg.Properties.User.Get("signature"))
+	var event eventData
+	json.Unmarshal(msg.Payload, &event)
+	assert.Equal("10", event.Data["i"])
+
+	// A failed publish drops the connection, and the next attempt reconnects
+	publishers[0].err = fmt.Errorf("pop")
+	err = action.attemptBatch(3, 1, testPubSubEvents())
+	assert.Regexp("pop", err)
+	assert.True(publishers[0].closed)
+	err = action.attemptBatch(3, 2, testPubSubEvents())
+	assert.NoError(err)
+	assert.Len(publishers, 2)
+
+	action.dial = func(ctx context.Context, spec *mqttActionInfo, version byte) (mqttPublisher, error) {
+		return nil, fmt.Errorf("pop")
+	}
+	sm.streams[spec.ID].stop(false)
+	assert.True(publishers[1].closed)
+	err = action.attemptBatch(4, 1, testPubSubEvents())
+	assert.Regexp("FFEC100458", err)
+}
+
+func TestMQTTStreamQoS0(t *testing.T) {
+	assert := assert.New(t)
+
+	qos := uint8(0)
+	es := &eventStream{spec: &StreamInfo{ID: "es-1"}}
+	a, err := newMQTTAction(es, &mqttActionInfo{URL: "mqtt://localhost", Topic: "events", QoS: &qos})
+	assert.NoError(err)
+	assert.Equal(mqttProtocolV311, a.version)
+
+	p := &testMQTTPublisher{}
+	a.dial = func(ctx context.Context, spec *mqttActionInfo, version byte) (mqttPublisher, error) {
+		return p, nil
+	}
+	err = a.attemptBatch(1, 1, testPubSubEvents())
+	assert.NoError(err)
+	assert.Equal(byte(0), p.published[0][0].QoS)
+	assert.Equal("events", p.published[0][0].Topic)
+}
+
+func TestMQTTValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	es := &eventStream{spec: &StreamInfo{ID: "es-1"}}
+	_, err := newMQTTAction(es, nil)
+	assert.Regexp("FFEC100450", err)
+	_, err = newMQTTAction(es, &mqttActionInfo{URL: "mqtt://localhost"})
+	assert.Regexp("FFEC100450", err)
+	_, err = newMQTTAction(es, &mqttActionInfo{URL: "http://localhost", Topic: "events"})
+	assert.Regexp("FFEC100453", err)
+	_, err = newMQTTAction(es, &mqttActionInfo{URL: "mqtt://localhost", Topic: "events", ProtocolVersion: "3"})
+	assert.Regexp("FFEC100454", err)
+	qos := uint8(3)
+	_, err = newMQTTAction(es, &mqttActionInfo{URL: "mqtt://localhost", Topic: "events", QoS: &qos})
+	assert.Regexp("FFEC100452", err)
+	_, err = newMQTTAction(es, &mqttActionInfo{URL: "mqtt://localhost", Topic: "events/{{.SubID"})
+	assert.Regexp("FFEC100451", err)
+	_, err = newMQTTAction(es, &mqttActionInfo{URL: "mqtt://localhost", Topic: "events/{{.Unknown}}"})
+	assert.Regexp("FFEC100451", err)
+
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := l.Addr().String()
+	l.Close()
+	a, err := newMQTTAction(es, &mqttActionInfo{URL: "mqtt://" + addr, Topic: "events", RequestTimeoutSec: 1})
+	assert.NoError(err)
+	err = a.attemptBatch(1, 1, testPubSubEvents())
+	assert.Regexp("FFEC100455", err)
+
+	// A topic that is only invalid for some events fails the batch
+	a, err = newMQTTAction(es, &mqttActionInfo{URL: "mqtt://" + addr, Topic: `{{if .SubID}}{{index .SubID 99}}{{end}}`})
+	assert.NoError(err)
+	err = a.attemptBatch(1, 1, testPubSubEvents())
+	assert.Regexp("FFEC100451", err)
+}
+
+func TestMQTTStreamUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	sm := newTestSubscriptionManager()
+	ctx := context.Background()
+	spec, err := sm.AddStream(ctx, &StreamInfo{
+		Type: "mqtt",
+		MQTT: &mqttActionInfo{URL: "mqtt://localhost", Topic: "events"},
+	})
+	assert.NoError(err)
+	defer sm.Close(true)
+
+	updated, err := sm.UpdateStream(ctx, spec.ID, &StreamInfo{
+		MQTT: &mqttActionInfo{RequestTimeoutSec: 10},
+	})
+	assert.NoError(err)
+	assert.Equal(uint32(10), updated.MQTT.RequestTimeoutSec)
+	assert.Equal("events", updated.MQTT.Topic)
+}
+
+// testMQTTBroker accepts one connection, and replies to each packet the client sends with reply
+func testMQTTBroker(t *testing.T, reply func(conn net.Conn) bool) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for reply(conn) {
+		}
+	}()
+	return l.Addr().String(), func() {
+		l.Close()
+		<-done
+	}
+}
+
+func TestMQTTConnectionV5(t *testing.T) {
+	assert := assert.New(t)
+
+	var published []*packets.Publish
+	addr, stop := testMQTTBroker(t, func(conn net.Conn) bool {
+		cp, err := packets.ReadPacket(conn)
+		if err != nil {
+			return false
+		}
+		switch p := cp.Content.(type) {
+		case *packets.Connect:
+			assert.Equal("client1", p.ClientID)
+			assert.Equal("user1", p.Username)
+			assert.Equal("pass1", string(p.Password))
+			assert.True(p.CleanStart)
+			packets.NewControlPacket(packets.CONNACK).WriteTo(conn)
+		case *packets.Publish:
+			published = append(published, p)
+			ack := packets.NewControlPacket(packets.PUBACK)
+			ack.Content.(*packets.Puback).PacketID = p.PacketID
+			if p.Topic == "rejected" {
+				ack.Content.(*packets.Puback).ReasonCode = packets.PubackNotAuthorized
+			}
+			ack.WriteTo(conn)
+		case *packets.Disconnect:
+			return false
+		}
+		return true
+	})
+	defer stop()
+
+	ctx := context.Background()
+	p, err := dialMQTT(ctx, &mqttActionInfo{
+		URL:               "mqtt://user1:pass1@" + addr,
+		ClientID:          "client1",
+		RequestTimeoutSec: 5,
+	}, mqttProtocolV5)
+	assert.NoError(err)
+	err = p.Publish(ctx, &paho.Publish{
+		Topic:   "events",
+		QoS:     1,
+		Payload: []byte(`{}`),
+		Properties: &paho.PublishProperties{
+			ContentType: "application/json",
+			User:        paho.UserProperties{{Key: "streamId", Value: "es-1"}},
+		},
+	})
+	assert.NoError(err)
+	err = p.Publish(ctx, &paho.Publish{Topic: "rejected", QoS: 1, Payload: []byte(`{}`)})
+	assert.Regexp("FFEC100460", err)
+	p.Close()
+	stop()
+
+	assert.Len(published, 2)
+	assert.Equal("events", published[0].Topic)
+	assert.Equal("application/json", published[0].Properties.ContentType)
+	assert.Equal(packets.User{Key: "streamId", Value: "es-1"}, published[0].Properties.User[0])
+}
+
+func TestMQTTConnectionV5Rejected(t *testing.T) {
+	addr, stop := testMQTTBroker(t, func(conn net.Conn) bool {
+		if _, err := packets.ReadPacket(conn); err != nil {
+			return false
+		}
+		connack := packets.NewControlPacket(packets.CONNACK)
+		connack.Content.(*packets.Connack).ReasonCode = packets.ConnackNotAuthorized
+		connack.WriteTo(conn)
+		return false
+	})
+	defer stop()
+
+	_, err := dialMQTT(context.Background(), &mqttActionInfo{URL: "mqtt://" + addr, RequestTimeoutSec: 5}, mqttProtocolV5)
+	assert.Regexp(t, "FFEC100456", err)
+}
+
+func TestMQTTConnectionV311(t *testing.T) {
+	assert := assert.New(t)
+
+	var published []*packets3.PublishPacket
+	addr, stop := testMQTTBroker(t, func(conn net.Conn) bool {
+		cp, err := packets3.ReadPacket(conn)
+		if err != nil {
+			return false
+		}
+		switch p := cp.(type) {
+		case *packets3.ConnectPacket:
+			assert.Equal("client1", p.ClientIdentifier)
+			assert.Equal("user1", p.Username)
+			assert.Equal(byte(4), p.ProtocolVersion)
+			packets3.NewControlPacket(packets3.Connack).Write(conn)
+		case *packets3.PublishPacket:
+			published = append(published, p)
+			ack := packets3.NewControlPacket(packets3.Puback).(*packets3.PubackPacket)
+			ack.MessageID = p.MessageID
+			ack.Write(conn)
+		case *packets3.DisconnectPacket:
+			return false
+		}
+		return true
+	})
+	defer stop()
+
+	ctx := context.Background()
+	p, err := dialMQTT(ctx, &mqttActionInfo{
+		URL:               "mqtt://" + addr,
+		ClientID:          "client1",
+		Username:          "user1",
+		Password:          "pass1",
+		RequestTimeoutSec: 5,
+	}, mqttProtocolV311)
+	assert.NoError(err)
+	err = p.Publish(ctx,
+		&paho.Publish{Topic: "events", QoS: 1, Payload: []byte(`{"i":1}`)},
+		&paho.Publish{Topic: "events", QoS: 1, Payload: []byte(`{"i":2}`)},
+	)
+	assert.NoError(err)
+	p.Close()
+	stop()
+
+	assert.Len(published, 2)
+	assert.Equal("events", published[0].TopicName)
+	assert.Equal(`{"i":2}`, string(published[1].Payload))
+}
+
+func TestMQTTConnectionV311Rejected(t *testing.T) {
+	addr, stop := testMQTTBroker(t, func(conn net.Conn) bool {
+		if _, err := packets3.ReadPacket(conn); err != nil {
+			return false
+		}
+		connack := packets3.NewControlPacket(packets3.Connack).(*packets3.ConnackPacket)
+		connack.ReturnCode = packets3.ErrRefusedBadUsernameOrPassword
+		connack.Write(conn)
+		return false
+	})
+	defer stop()
+
+	_, err := dialMQTT(context.Background(), &mqttActionInfo{URL: "mqtt://" + addr, RequestTimeoutSec: 5}, mqttProtocolV311)
+	assert.Regexp(t, "FFEC100456", err)
+}
+
+func TestMQTTParseURL(t *testing.T) {
+	assert := assert.New(t)
+
+	host, useTLS, err := parseMQTTURL("mqtts://broker.example.com")
+	assert.NoError(err)
+	assert.Equal("broker.example.com:8883", host)
+	assert.True(useTLS)
+	host, useTLS, err = parseMQTTURL("tcp://localhost:1884")
+	assert.NoError(err)
+	assert.Equal("localhost:1884", host)
+	assert.False(useTLS)
+	_, _, err = parseMQTTURL("mqtt://")
+	assert.Regexp("FFEC100453", err)
+	_, _, err = parseMQTTURL(":::")
+	assert.Regexp("FFEC100453", err)
+}
+
+func TestMQTTInvalidTopicName(t *testing.T) {
+	es := &eventStream{spec: &StreamInfo{ID: "es-1"}}
+	a, err := newMQTTAction(es, &mqttActionInfo{URL: "mqtt://localhost", Topic: "events/{{.Event}}/#"})
+	assert.NoError(t, err)
+	_, err = a.buildMessages(testPubSubEvents())
+	assert.Regexp(t, "FFEC100464", err)
+}
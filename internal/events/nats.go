@@ -0,0 +1,261 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	goerrors "errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type natsActionInfo struct {
+	URL               string `json:"url,omitempty"`
+	Subject           string `json:"subject,omitempty"`
+	Username          string `json:"username,omitempty"`
+	Password          string `json:"password,omitempty"`
+	Token             string `json:"token,omitempty"`
+	TLSkipHostVerify  bool   `json:"tlsSkipHostVerify,omitempty"`
+	RequestTimeoutSec uint32 `json:"requestTimeoutSec,omitempty"`
+}
+
+// natsPublisher is the part of a NATS connection used by the action, so tests can replace it
+type natsPublisher interface {
+	Publish(ctx context.Context, msgs ...*nats.Msg) ([]*jetstream.PubAck, error)
+	Close()
+}
+
+// natsAction holds a connection open to the server between batches, and drops it on any failure
+// so the next attempt reconnects
+type natsAction struct {
+	es        *eventStream
+	spec      *natsActionInfo
+	dial      func(ctx context.Context, spec *natsActionInfo) (natsPublisher, error)
+	mux       sync.Mutex
+	publisher natsPublisher
+	closed    bool
+}
+
+func validateNATS(spec *natsActionInfo) error {
+	if spec == nil || spec.URL == "" || spec.Subject == "" {
+		return errors.Errorf(errors.EventStreamsNATSNoSubject)
+	}
+	if u, err := url.Parse(spec.URL); err != nil || (u.Scheme != "nats" && u.Scheme != "tls") || u.Host == "" {
+		return errors.Errorf(errors.NATSInvalidURL, spec.URL)
+	}
+	return nil
+}
+
+func newNATSAction(es *eventStream, spec *natsActionInfo) (*natsAction, error) {
+	if err := validateNATS(spec); err != nil {
+		return nil, err
+	}
+	if spec.RequestTimeoutSec == 0 {
+		spec.RequestTimeoutSec = 120
+	}
+	return &natsAction{
+		es:   es,
+		spec: spec,
+		dial: dialNATS,
+	}, nil
+}
+
+// natsConnection is a connection to the server, publishing to JetStream
+type natsConnection struct {
+	host string
+	conn *nats.Conn
+	js   jetstream.JetStream
+}
+
+// dialNATS connects to the server without reconnecting, as the action reconnects on the next
+// attempt after any failure. TLS is used for a tls:// URL, or whenever the server requires it.
+func dialNATS(ctx context.Context, spec *natsActionInfo) (natsPublisher, error) {
+	u, err := url.Parse(spec.URL)
+	if err != nil {
+		return nil, errors.Errorf(errors.NATSInvalidURL, spec.URL)
+	}
+	opts := []nats.Option{
+		nats.Name("ethconnect"),
+		nats.NoReconnect(),
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		opts = append(opts, nats.Timeout(time.Until(deadline)))
+	}
+	if spec.Username != "" {
+		opts = append(opts, nats.UserInfo(spec.Username, spec.Password))
+	}
+	if spec.Token != "" {
+		opts = append(opts, nats.Token(spec.Token))
+	}
+	if spec.TLSkipHostVerify {
+		tlsConfig, err := utils.CreateTLSConfiguration(&utils.TLSConfig{Enabled: true, InsecureSkipVerify: true})
+		if err != nil {
+			return nil, err
+		}
+		// Only sets the configuration used if TLS is needed, unlike nats.Secure
+		opts = append(opts, func(o *nats.Options) error {
+			o.TLSConfig = tlsConfig
+			return nil
+		})
+	}
+	conn, err := nats.Connect(spec.URL, opts...)
+	if err != nil {
+		return nil, errors.Errorf(errors.NATSConnectFailed, u.Host, err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, errors.Errorf(errors.NATSConnectFailed, u.Host, err)
+	}
+	log.Infof("NATS connected to %s", conn.ConnectedUrlRedacted())
+	return &natsConnection{host: u.Host, conn: conn, js: js}, nil
+}
+
+// Publish sends each of the messages in turn, then waits for JetStream to acknowledge all of them.
+// The first message that is not stored is returned as an error.
+func (c *natsConnection) Publish(ctx context.Context, msgs ...*nats.Msg) ([]*jetstream.PubAck, error) {
+	futures := make([]jetstream.PubAckFuture, len(msgs))
+	for i, msg := range msgs {
+		var err error
+		if futures[i], err = c.js.PublishMsgAsync(msg); err != nil {
+			return nil, err
+		}
+	}
+	acks := make([]*jetstream.PubAck, len(msgs))
+	for i, f := range futures {
+		select {
+		case acks[i] = <-f.Ok():
+		case err := <-f.Err():
+			if goerrors.Is(err, jetstream.ErrNoStreamResponse) {
+				return nil, errors.Errorf(errors.NATSNoResponders, msgs[i].Subject)
+			}
+			return nil, errors.Errorf(errors.NATSPublishFailed, msgs[i].Header.Get(nats.MsgIdHdr), err)
+		case <-ctx.Done():
+			return nil, errors.Errorf(errors.NATSTimeout, c.host)
+		}
+	}
+	return acks, nil
+}
+
+func (c *natsConnection) Close() {
+	c.conn.Close()
+}
+
+// buildMessages creates a message for each event. The message ID identifies the event, so a
+// JetStream stream discards events from a redelivered batch that it has already stored.
+func (a *natsAction) buildMessages(events []*eventData) ([]*nats.Msg, error) {
+	msgs := make([]*nats.Msg, len(events))
+	for i, event := range events {
+		b, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		msgs[i] = &nats.Msg{
+			Subject: a.spec.Subject,
+			Header: nats.Header{
+				nats.MsgIdHdr:     {event.SubID + ":" + event.TransactionHash + ":" + event.LogIndex},
+				"streamId":        {a.es.spec.ID},
+				"subId":           {event.SubID},
+				"signature":       {event.Signature},
+				"address":         {event.Address},
+				"blockNumber":     {event.BlockNumber},
+				"transactionHash": {event.TransactionHash},
+				"logIndex":        {event.LogIndex},
+			},
+			Data: b,
+		}
+	}
+	return msgs, nil
+}
+
+func (a *natsAction) connect(ctx context.Context) (natsPublisher, error) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.closed {
+		return nil, errors.Errorf(errors.NATSConnectionClosed)
+	}
+	if a.publisher == nil {
+		publisher, err := a.dial(ctx, a.spec)
+		if err != nil {
+			return nil, err
+		}
+		a.publisher = publisher
+	}
+	return a.publisher, nil
+}
+
+func (a *natsAction) disconnect(publisher natsPublisher) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.publisher == publisher {
+		a.publisher = nil
+	}
+	publisher.Close()
+}
+
+// close is called when the stream is stopped
+func (a *natsAction) close() {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	a.closed = true
+	if a.publisher != nil {
+		a.publisher.Close()
+		a.publisher = nil
+	}
+}
+
+// attemptBatch publishes every event of the batch, and only succeeds once JetStream has
+// acknowledged storing all of them, so the checkpoint never moves past an unstored event
+func (a *natsAction) attemptBatch(batchNumber, attempt uint64, events []*eventData) error {
+	esID := a.es.spec.ID
+	log.Infof("%s: NATS publish --> %s batch=%d events=%d (attempt=%d)", esID, a.spec.Subject, batchNumber, len(events), attempt)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(a.spec.RequestTimeoutSec)*time.Second)
+	defer cancel()
+
+	msgs, err := a.buildMessages(events)
+	var publisher natsPublisher
+	if err == nil {
+		publisher, err = a.connect(ctx)
+	}
+	var acks []*jetstream.PubAck
+	if err == nil {
+		if acks, err = publisher.Publish(ctx, msgs...); err != nil {
+			a.disconnect(publisher)
+		}
+	}
+	if err != nil {
+		log.Errorf("%s: NATS publish to %s failed (attempt=%d): %s", esID, a.spec.Subject, attempt, err)
+		return err
+	}
+	duplicates := 0
+	last := &jetstream.PubAck{}
+	for _, ack := range acks {
+		if ack.Duplicate {
+			duplicates++
+		}
+		last = ack
+	}
+	log.Infof("%s: NATS publish <-- %s batch=%d stream=%s seq=%d duplicates=%d", esID, a.spec.Subject, batchNumber, last.Stream, last.Sequence, duplicates)
+	return nil
+}
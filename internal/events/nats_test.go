@@ -0,0 +1,205 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+)
+
+type testNATSPublisher struct {
+	published [][]*nats.Msg
+	err       error
+	closed    bool
+}
+
+func (p *testNATSPublisher) Publish(ctx context.Context, msgs ...*nats.Msg) ([]*jetstream.PubAck, error) {
+	p.published = append(p.published, msgs)
+	if p.err != nil {
+		return nil, p.err
+	}
+	acks := make([]*jetstream.PubAck, len(msgs))
+	for i := range msgs {
+		acks[i] = &jetstream.PubAck{Stream: "EVENTS", Sequence: uint64(i + 1), Duplicate: i == 0}
+	}
+	return acks, nil
+}
+
+func (p *testNATSPublisher) Close() {
+	p.closed = true
+}
+
+type testPubAckFuture struct {
+	ok  chan *jetstream.PubAck
+	err chan error
+	msg *nats.Msg
+}
+
+func (f *testPubAckFuture) Ok() <-chan *jetstream.PubAck { return f.ok }
+func (f *testPubAckFuture) Err() <-chan error            { return f.err }
+func (f *testPubAckFuture) Msg() *nats.Msg               { return f.msg }
+
+// testJetStream resolves each publish with the next result: an ack, an error, or nothing
+type testJetStream struct {
+	jetstream.JetStream
+	results []interface{}
+}
+
+func (js *testJetStream) PublishMsgAsync(msg *nats.Msg, opts ...jetstream.PublishOpt) (jetstream.PubAckFuture, error) {
+	f := &testPubAckFuture{ok: make(chan *jetstream.PubAck, 1), err: make(chan error, 1), msg: msg}
+	result := js.results[0]
+	js.results = js.results[1:]
+	switch r := result.(type) {
+	case *jetstream.PubAck:
+		f.ok <- r
+	case error:
+		f.err <- r
+	}
+	return f, nil
+}
+
+func TestNATSConnectionPublish(t *testing.T) {
+	assert := assert.New(t)
+
+	msgs := []*nats.Msg{
+		{Subject: "events", Header: nats.Header{nats.MsgIdHdr: {"id1"}}},
+		{Subject: "events", Header: nats.Header{nats.MsgIdHdr: {"id2"}}},
+	}
+	js := &testJetStream{}
+	c := &natsConnection{host: "localhost:4222", js: js}
+	ctx := context.Background()
+
+	js.results = []interface{}{&jetstream.PubAck{Sequence: 1}, &jetstream.PubAck{Sequence: 2}}
+	acks, err := c.Publish(ctx, msgs...)
+	assert.NoError(err)
+	assert.Equal(uint64(2), acks[1].Sequence)
+
+	js.results = []interface{}{&jetstream.PubAck{Sequence: 3}, fmt.Errorf("pop")}
+	_, err = c.Publish(ctx, msgs...)
+	assert.Regexp("FFEC100429.*id2.*pop", err)
+
+	js.results = []interface{}{jetstream.ErrNoStreamResponse, nil}
+	_, err = c.Publish(ctx, msgs...)
+	assert.Regexp("FFEC100430.*events", err)
+
+	js.results = []interface{}{nil}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = c.Publish(cancelled, msgs[0])
+	assert.Regexp("FFEC100431", err)
+}
+
+func TestNATSStreamPublishes(t *testing.T) {
+	assert := assert.New(t)
+
+	sm := newTestSubscriptionManager()
+	spec, err := sm.AddStream(context.Background(), &StreamInfo{
+		Type: "NATS",
+		NATS: &natsActionInfo{
+			URL:     "nats://localhost:4222",
+			Subject: "ethereum.events",
+		},
+	})
+	assert.NoError(err)
+	defer sm.Close(true)
+	assert.Equal(uint32(120), spec.NATS.RequestTimeoutSec)
+
+	var publishers []*testNATSPublisher
+	action := sm.streams[spec.ID].action.(*natsAction)
+	action.dial = func(ctx context.Context, spec *natsActionInfo) (natsPublisher, error) {
+		p := &testNATSPublisher{}
+		publishers = append(publishers, p)
+		return p, nil
+	}
+
+	err = action.attemptBatch(1, 1, testPubSubEvents())
+	assert.NoError(err)
+	err = action.attemptBatch(2, 1, testPubSubEvents())
+	assert.NoError(err)
+	assert.Len(publishers, 1)
+	assert.Len(publishers[0].published, 2)
+	msg := publishers[0].published[0][0]
+	assert.Equal("ethereum.events", msg.Subject)
+	assert.Equal("sb-1:0xd2d4c7f4b2b1e4f6c5a3e7d9b1c3a5e7f9b1d3c5a7e9f1b3d5c7a9e1f3b5d7c9:2", msg.Header.Get(nats.MsgIdHdr))
+	assert.Equal(spec.ID, msg.Header.Get("streamId"))
+	assert.Equal("Changed(uint256)", msg.Header.Get("signature"))
+	var event eventData
+	json.Unmarshal(msg.Data, &event)
+	assert.Equal("10", event.Data["i"])
+
+	// A failed publish drops the connection, and the next attempt reconnects
+	publishers[0].err = fmt.Errorf("pop")
+	err = action.attemptBatch(3, 1, testPubSubEvents())
+	assert.Regexp("pop", err)
+	assert.True(publishers[0].closed)
+	err = action.attemptBatch(3, 2, testPubSubEvents())
+	assert.NoError(err)
+	assert.Len(publishers, 2)
+
+	action.dial = func(ctx context.Context, spec *natsActionInfo) (natsPublisher, error) {
+		return nil, fmt.Errorf("pop")
+	}
+	sm.streams[spec.ID].stop(false)
+	assert.True(publishers[1].closed)
+	err = action.attemptBatch(4, 1, testPubSubEvents())
+	assert.Regexp("FFEC100428", err)
+}
+
+func TestNATSValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	es := &eventStream{spec: &StreamInfo{ID: "es-1"}}
+	_, err := newNATSAction(es, nil)
+	assert.Regexp("FFEC100423", err)
+	_, err = newNATSAction(es, &natsActionInfo{URL: "nats://localhost"})
+	assert.Regexp("FFEC100423", err)
+	_, err = newNATSAction(es, &natsActionInfo{URL: "http://localhost", Subject: "events"})
+	assert.Regexp("FFEC100424", err)
+
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := l.Addr().String()
+	l.Close()
+	a, err := newNATSAction(es, &natsActionInfo{URL: "nats://" + addr, Subject: "events", RequestTimeoutSec: 1})
+	assert.NoError(err)
+	err = a.attemptBatch(1, 1, testPubSubEvents())
+	assert.Regexp("FFEC100425", err)
+}
+
+func TestNATSStreamUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	sm := newTestSubscriptionManager()
+	ctx := context.Background()
+	spec, err := sm.AddStream(ctx, &StreamInfo{
+		Type: "nats",
+		NATS: &natsActionInfo{URL: "tls://localhost", Subject: "events"},
+	})
+	assert.NoError(err)
+	defer sm.Close(true)
+
+	updated, err := sm.UpdateStream(ctx, spec.ID, &StreamInfo{
+		NATS: &natsActionInfo{RequestTimeoutSec: 10},
+	})
+	assert.NoError(err)
+	assert.Equal(uint32(10), updated.NATS.RequestTimeoutSec)
+	assert.Equal("events", updated.NATS.Subject)
+}
@@ -0,0 +1,274 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/option"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// OrderingKeyNone publishes without an ordering key
+	OrderingKeyNone = "none"
+	// OrderingKeyStream orders all events on the stream
+	OrderingKeyStream = "stream"
+	// OrderingKeySubscription orders the events of each subscription
+	OrderingKeySubscription = "subscription"
+	// OrderingKeyAddress orders the events emitted by each contract address
+	OrderingKeyAddress = "address"
+	// OrderingKeyTransaction orders the events emitted by each transaction
+	OrderingKeyTransaction = "transaction"
+)
+
+type pubSubActionInfo struct {
+	ProjectID         string `json:"projectId,omitempty"`
+	Topic             string `json:"topic,omitempty"`
+	OrderingKey       string `json:"orderingKey,omitempty"`
+	CredentialsFile   string `json:"credentialsFile,omitempty"`
+	Endpoint          string `json:"endpoint,omitempty"`
+	RequestTimeoutSec uint32 `json:"requestTimeoutSec,omitempty"`
+}
+
+// pubSubAction publishes through a client that is created on the first batch, and held open
+// until the stream is stopped. The topic splits each batch into publish requests within the
+// limits of Pub/Sub.
+type pubSubAction struct {
+	es         *eventStream
+	spec       *pubSubActionInfo
+	project    string
+	topicID    string
+	clientOpts []option.ClientOption
+	mux        sync.Mutex
+	client     *pubsub.Client
+	topic      *pubsub.Topic
+	closed     bool
+}
+
+func validatePubSub(spec *pubSubActionInfo) error {
+	if spec == nil || spec.Topic == "" {
+		return errors.Errorf(errors.EventStreamsPubSubNoTopic)
+	}
+	switch strings.ToLower(spec.OrderingKey) {
+	case "", OrderingKeyNone, OrderingKeyStream, OrderingKeySubscription, OrderingKeyAddress, OrderingKeyTransaction:
+		spec.OrderingKey = strings.ToLower(spec.OrderingKey)
+		return nil
+	default:
+		return errors.Errorf(errors.EventStreamsPubSubInvalidOrderingKey, spec.OrderingKey)
+	}
+}
+
+func newPubSubAction(es *eventStream, spec *pubSubActionInfo) (*pubSubAction, error) {
+	if err := validatePubSub(spec); err != nil {
+		return nil, err
+	}
+	if spec.RequestTimeoutSec == 0 {
+		spec.RequestTimeoutSec = 120
+	}
+	p := &pubSubAction{
+		es:      es,
+		spec:    spec,
+		topicID: spec.Topic,
+		project: spec.ProjectID,
+	}
+	if parts := strings.Split(spec.Topic, "/"); len(parts) == 4 && parts[0] == "projects" && parts[2] == "topics" {
+		p.project, p.topicID = parts[1], parts[3]
+	}
+	if spec.Endpoint != "" {
+		p.clientOpts = append(p.clientOpts, option.WithEndpoint(pubSubEndpoint(spec.Endpoint)))
+	}
+	// The client connects to the emulator without credentials, when it is configured
+	if os.Getenv("PUBSUB_EMULATOR_HOST") == "" {
+		creds, err := pubSubCredentials(spec.CredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		if p.project == "" {
+			p.project = creds.ProjectID
+		}
+		p.clientOpts = append(p.clientOpts, option.WithCredentials(creds))
+	}
+	if p.project == "" {
+		return nil, errors.Errorf(errors.EventStreamsPubSubNoProject)
+	}
+	if spec.ProjectID == "" && !strings.HasPrefix(spec.Topic, "projects/") {
+		spec.ProjectID = p.project
+	}
+	return p, nil
+}
+
+// pubSubCredentials loads the credentials file, or finds Application Default Credentials when
+// there is none. Every type of credentials supported by the Google client libraries can be used,
+// including workload identity federation (external_account) and impersonated service accounts.
+func pubSubCredentials(credentialsFile string) (*google.Credentials, error) {
+	ctx := context.Background()
+	if credentialsFile == "" {
+		creds, err := google.FindDefaultCredentials(ctx, pubsub.ScopePubSub)
+		if err != nil {
+			return nil, errors.Errorf(errors.GCPTokenFailed, err)
+		}
+		return creds, nil
+	}
+	b, err := ioutil.ReadFile(credentialsFile)
+	if err == nil {
+		var creds *google.Credentials
+		if creds, err = google.CredentialsFromJSON(ctx, b, pubsub.ScopePubSub); err == nil {
+			return creds, nil
+		}
+	}
+	return nil, errors.Errorf(errors.GCPCredentialsInvalid, credentialsFile, err)
+}
+
+// pubSubEndpoint returns the host:port of the gRPC endpoint, which can be configured as a URL
+func pubSubEndpoint(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return endpoint
+	}
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return u.Host
+}
+
+// topicName is the full resource name of the topic
+func (p *pubSubAction) topicName() string {
+	return "projects/" + p.project + "/topics/" + p.topicID
+}
+
+func (p *pubSubAction) orderingKey(event *eventData) string {
+	switch p.spec.OrderingKey {
+	case OrderingKeyStream:
+		return p.es.spec.ID
+	case OrderingKeySubscription:
+		return event.SubID
+	case OrderingKeyAddress:
+		return event.Address
+	case OrderingKeyTransaction:
+		return event.TransactionHash
+	default:
+		return ""
+	}
+}
+
+func (p *pubSubAction) buildMessages(events []*eventData) ([]*pubsub.Message, error) {
+	msgs := make([]*pubsub.Message, len(events))
+	for i, event := range events {
+		b, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		msgs[i] = &pubsub.Message{
+			Data: b,
+			Attributes: map[string]string{
+				"streamId":        p.es.spec.ID,
+				"subId":           event.SubID,
+				"signature":       event.Signature,
+				"address":         event.Address,
+				"blockNumber":     event.BlockNumber,
+				"transactionHash": event.TransactionHash,
+				"logIndex":        event.LogIndex,
+			},
+			OrderingKey: p.orderingKey(event),
+		}
+	}
+	return msgs, nil
+}
+
+func (p *pubSubAction) connect(ctx context.Context) (*pubsub.Topic, error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.closed {
+		return nil, errors.Errorf(errors.EventStreamsPubSubClosed)
+	}
+	if p.topic == nil {
+		client, err := pubsub.NewClient(ctx, p.project, p.clientOpts...)
+		if err != nil {
+			return nil, errors.Errorf(errors.EventStreamsPubSubPublishFailed, p.es.spec.ID, err)
+		}
+		topic := client.TopicInProject(p.topicID, p.project)
+		topic.PublishSettings.CountThreshold = pubsub.MaxPublishRequestCount
+		topic.PublishSettings.ByteThreshold = pubsub.MaxPublishRequestBytes
+		topic.PublishSettings.Timeout = time.Duration(p.spec.RequestTimeoutSec) * time.Second
+		topic.EnableMessageOrdering = p.spec.OrderingKey != "" && p.spec.OrderingKey != OrderingKeyNone
+		p.client, p.topic = client, topic
+	}
+	return p.topic, nil
+}
+
+// close is called when the stream is stopped
+func (p *pubSubAction) close() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.closed = true
+	if p.topic != nil {
+		p.topic.Stop()
+		_ = p.client.Close()
+		p.client, p.topic = nil, nil
+	}
+}
+
+// attemptBatch publishes every event of the batch, and only succeeds once Pub/Sub has accepted all
+// of them. The topic sends them in as many requests as the limits of Pub/Sub require, so a batch that
+// is retried may publish some events twice. When a publish with an ordering key fails, the topic
+// pauses the key, failing every later event with it. Publishing is resumed before the batch is
+// retried, so the retry publishes the events of each key in order.
+func (p *pubSubAction) attemptBatch(batchNumber, attempt uint64, events []*eventData) error {
+	esID := p.es.spec.ID
+	log.Infof("%s: Pub/Sub publish --> %s batch=%d events=%d (attempt=%d)", esID, p.topicName(), batchNumber, len(events), attempt)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.spec.RequestTimeoutSec)*time.Second)
+	defer cancel()
+
+	msgs, err := p.buildMessages(events)
+	var topic *pubsub.Topic
+	if err == nil {
+		topic, err = p.connect(ctx)
+	}
+	if err == nil {
+		results := make([]*pubsub.PublishResult, len(msgs))
+		for i, msg := range msgs {
+			results[i] = topic.Publish(ctx, msg)
+		}
+		for i, result := range results {
+			if _, pubErr := result.Get(ctx); pubErr != nil {
+				if err == nil {
+					err = errors.Errorf(errors.EventStreamsPubSubPublishFailed, esID, pubErr)
+				}
+				if msgs[i].OrderingKey != "" {
+					topic.ResumePublish(msgs[i].OrderingKey)
+				}
+			}
+		}
+	}
+	if err != nil {
+		log.Errorf("%s: Pub/Sub publish to %s failed (attempt=%d): %s", esID, p.topicName(), attempt, err)
+		return err
+	}
+	log.Infof("%s: Pub/Sub publish <-- %s batch=%d accepted", esID, p.topicName(), batchNumber)
+	return nil
+}
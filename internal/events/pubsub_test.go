@@ -0,0 +1,253 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"cloud.google.com/go/pubsub/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestPubSubServer starts the fake Pub/Sub server of the client library with a topic, and
+// points the client at it as the emulator
+func newTestPubSubServer(t *testing.T, topic string) (*pstest.Server, func()) {
+	srv := pstest.NewServer()
+	_, err := srv.GServer.CreateTopic(context.Background(), &pubsubpb.Topic{Name: topic})
+	assert.NoError(t, err)
+	os.Setenv("PUBSUB_EMULATOR_HOST", srv.Addr)
+	return srv, func() {
+		os.Unsetenv("PUBSUB_EMULATOR_HOST")
+		srv.Close()
+	}
+}
+
+func writeTestCredentials(t *testing.T, dir string, creds map[string]interface{}) string {
+	b, _ := json.Marshal(creds)
+	filename := path.Join(dir, "creds.json")
+	assert.NoError(t, ioutil.WriteFile(filename, b, 0600))
+	return filename
+}
+
+func testPubSubEvents() []*eventData {
+	return []*eventData{
+		{
+			Address:         "0x167f57a13a9c35ff92f0649d2be0e52b4f8ac3ca",
+			BlockNumber:     "123",
+			TransactionHash: "0xd2d4c7f4b2b1e4f6c5a3e7d9b1c3a5e7f9b1d3c5a7e9f1b3d5c7a9e1f3b5d7c9",
+			SubID:           "sb-1",
+			Signature:       "Changed(uint256)",
+			LogIndex:        "2",
+			Data:            map[string]interface{}{"i": "10"},
+		},
+	}
+}
+
+func TestPubSubStreamPublishes(t *testing.T) {
+	assert := assert.New(t)
+	srv, done := newTestPubSubServer(t, "projects/p1/topics/events")
+	defer done()
+
+	sm := newTestSubscriptionManager()
+	spec, err := sm.AddStream(context.Background(), &StreamInfo{
+		Type: "PubSub",
+		PubSub: &pubSubActionInfo{
+			ProjectID:   "p1",
+			Topic:       "events",
+			OrderingKey: "Address",
+		},
+	})
+	assert.NoError(err)
+	defer sm.Close(true)
+	assert.Equal(OrderingKeyAddress, spec.PubSub.OrderingKey)
+
+	stream := sm.streams[spec.ID]
+	err = stream.action.attemptBatch(1, 1, testPubSubEvents())
+	assert.NoError(err)
+	msgs := srv.Messages()
+	assert.Len(msgs, 1)
+	assert.Equal("0x167f57a13a9c35ff92f0649d2be0e52b4f8ac3ca", msgs[0].OrderingKey)
+	assert.Equal("sb-1", msgs[0].Attributes["subId"])
+	assert.Equal(spec.ID, msgs[0].Attributes["streamId"])
+	var event eventData
+	json.Unmarshal(msgs[0].Data, &event)
+	assert.Equal("10", event.Data["i"])
+
+	// A failed publish pauses the ordering key, which is resumed for the retry
+	srv.SetAutoPublishResponse(false)
+	srv.AddPublishResponse(nil, status.Error(codes.PermissionDenied, "pop"))
+	err = stream.action.attemptBatch(2, 1, testPubSubEvents())
+	assert.Regexp("FFEC100482.*pop", err)
+	srv.SetAutoPublishResponse(true)
+	err = stream.action.attemptBatch(2, 2, testPubSubEvents())
+	assert.NoError(err)
+
+	sm.streams[spec.ID].stop(false)
+	err = stream.action.attemptBatch(3, 1, testPubSubEvents())
+	assert.Regexp("FFEC100483", err)
+}
+
+func TestPubSubLargeBatch(t *testing.T) {
+	assert := assert.New(t)
+	srv, done := newTestPubSubServer(t, "projects/p1/topics/t1")
+	defer done()
+
+	es := &eventStream{spec: &StreamInfo{ID: "es-1"}}
+	p, err := newPubSubAction(es, &pubSubActionInfo{Topic: "projects/p1/topics/t1", OrderingKey: OrderingKeyStream})
+	assert.NoError(err)
+	defer p.close()
+
+	// More events than Pub/Sub accepts in a single publish request
+	events := make([]*eventData, 2500)
+	for i := range events {
+		events[i] = testPubSubEvents()[0]
+	}
+	err = p.attemptBatch(1, 1, events)
+	assert.NoError(err)
+	assert.Len(srv.Messages(), 2500)
+}
+
+func TestPubSubEmulatorOrderingKeys(t *testing.T) {
+	assert := assert.New(t)
+	srv, done := newTestPubSubServer(t, "projects/p1/topics/t1")
+	defer done()
+
+	es := &eventStream{spec: &StreamInfo{ID: "es-1"}}
+	for key, expected := range map[string]string{
+		"":                      "",
+		OrderingKeyNone:         "",
+		OrderingKeyStream:       "es-1",
+		OrderingKeySubscription: "sb-1",
+		OrderingKeyTransaction:  testPubSubEvents()[0].TransactionHash,
+	} {
+		srv.ClearMessages()
+		p, err := newPubSubAction(es, &pubSubActionInfo{
+			Topic:       "projects/p1/topics/t1",
+			OrderingKey: key,
+		})
+		assert.NoError(err)
+		assert.Equal("projects/p1/topics/t1", p.topicName())
+		err = p.attemptBatch(1, 1, testPubSubEvents())
+		assert.NoError(err)
+		p.close()
+		assert.Equal(expected, srv.Messages()[0].OrderingKey)
+	}
+}
+
+func TestPubSubCredentials(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir(t)
+	defer cleanup(t, dir)
+	es := &eventStream{spec: &StreamInfo{ID: "es-1"}}
+
+	// The project defaults to the one of a service account
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	keyDER, _ := x509.MarshalPKCS8PrivateKey(key)
+	spec := &pubSubActionInfo{
+		Topic: "t1",
+		CredentialsFile: writeTestCredentials(t, dir, map[string]interface{}{
+			"type":           "service_account",
+			"project_id":     "test-project",
+			"client_email":   "ethconnect@test-project.iam.gserviceaccount.com",
+			"private_key_id": "key1",
+			"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})),
+		}),
+		Endpoint: "https://us-east1-pubsub.googleapis.com",
+	}
+	p, err := newPubSubAction(es, spec)
+	assert.NoError(err)
+	assert.Equal("test-project", spec.ProjectID)
+	assert.Equal("projects/test-project/topics/t1", p.topicName())
+	assert.Equal("us-east1-pubsub.googleapis.com:443", pubSubEndpoint(spec.Endpoint))
+
+	// Workload identity federation credentials do not include a project
+	externalAccount := writeTestCredentials(t, dir, map[string]interface{}{
+		"type":               "external_account",
+		"audience":           "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool1/providers/provider1",
+		"subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+		"token_url":          "https://sts.googleapis.com/v1/token",
+		"credential_source":  map[string]string{"file": path.Join(dir, "token")},
+	})
+	_, err = newPubSubAction(es, &pubSubActionInfo{Topic: "t1", CredentialsFile: externalAccount})
+	assert.Regexp("FFEC100280", err)
+	p, err = newPubSubAction(es, &pubSubActionInfo{Topic: "t1", ProjectID: "p1", CredentialsFile: externalAccount})
+	assert.NoError(err)
+	assert.Equal("projects/p1/topics/t1", p.topicName())
+
+	_, err = newPubSubAction(es, &pubSubActionInfo{Topic: "t1", CredentialsFile: writeTestCredentials(t, dir, map[string]interface{}{"type": "unknown"})})
+	assert.Regexp("FFEC100283", err)
+	_, err = newPubSubAction(es, &pubSubActionInfo{Topic: "t1", CredentialsFile: "/does/not/exist"})
+	assert.Regexp("FFEC100283", err)
+}
+
+func TestPubSubValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	es := &eventStream{spec: &StreamInfo{ID: "es-1"}}
+	_, err := newPubSubAction(es, nil)
+	assert.Regexp("FFEC100279", err)
+	_, err = newPubSubAction(es, &pubSubActionInfo{Topic: "t1", OrderingKey: "block"})
+	assert.Regexp("FFEC100281", err)
+
+	// Metadata server credentials do not give us a project
+	os.Setenv("GCE_METADATA_HOST", "localhost:1")
+	defer os.Unsetenv("GCE_METADATA_HOST")
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", "/does/not/exist")
+	defer os.Setenv("HOME", home)
+	_, err = newPubSubAction(es, &pubSubActionInfo{Topic: "t1"})
+	assert.Regexp("FFEC100280", err)
+	_, err = newPubSubAction(es, &pubSubActionInfo{Topic: "t1", ProjectID: "p1"})
+	assert.NoError(err)
+}
+
+func TestPubSubStreamUpdate(t *testing.T) {
+	assert := assert.New(t)
+	_, done := newTestPubSubServer(t, "projects/p1/topics/t1")
+	defer done()
+
+	sm := newTestSubscriptionManager()
+	ctx := context.Background()
+	spec, err := sm.AddStream(ctx, &StreamInfo{
+		Type:   "pubsub",
+		PubSub: &pubSubActionInfo{Topic: "projects/p1/topics/t1"},
+	})
+	assert.NoError(err)
+	defer sm.Close(true)
+
+	_, err = sm.UpdateStream(ctx, spec.ID, &StreamInfo{
+		PubSub: &pubSubActionInfo{OrderingKey: "unknown"},
+	})
+	assert.Regexp("FFEC100281", err)
+
+	updated, err := sm.UpdateStream(ctx, spec.ID, &StreamInfo{
+		PubSub: &pubSubActionInfo{OrderingKey: "Subscription", RequestTimeoutSec: 10},
+	})
+	assert.NoError(err)
+	assert.Equal(OrderingKeySubscription, updated.PubSub.OrderingKey)
+	assert.Equal(uint32(10), updated.PubSub.RequestTimeoutSec)
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"encoding/json"
+	"math/big"
+	"sync"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultS3CheckpointPrefix = "checkpoints/"
+	s3CheckpointSuffix        = ".json"
+)
+
+// S3CheckpointConf configures storage of event stream checkpoints in S3 compatible object storage,
+// rather than the events LevelDB, so a container can resume its streams without a persistent volume
+type S3CheckpointConf struct {
+	Enabled bool         `json:"enabled"`
+	S3      utils.S3Conf `json:"s3"`
+	Prefix  string       `json:"prefix,omitempty"`
+}
+
+// s3Checkpoints stores one object per stream, and only overwrites a checkpoint if it has not
+// changed since this instance last read or wrote it. The ETags of those objects are held in
+// memory, with an empty ETag recording that the checkpoint did not exist.
+type s3Checkpoints struct {
+	conf  *S3CheckpointConf
+	s3    *utils.S3Client
+	etags map[string]string
+	mux   sync.Mutex
+}
+
+func newS3Checkpoints(conf *S3CheckpointConf) (*s3Checkpoints, error) {
+	if conf.Prefix == "" {
+		conf.Prefix = defaultS3CheckpointPrefix
+	}
+	s3, err := utils.NewS3Client(&conf.S3)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Event stream checkpoints stored in S3 bucket=%s prefix=%s", conf.S3.Bucket, conf.Prefix)
+	return &s3Checkpoints{
+		conf:  conf,
+		s3:    s3,
+		etags: make(map[string]string),
+	}, nil
+}
+
+func (c *s3Checkpoints) key(streamID string) string {
+	return c.conf.Prefix + checkpointIDPrefix + streamID + s3CheckpointSuffix
+}
+
+func (c *s3Checkpoints) load(streamID string) (map[string]*big.Int, error) {
+	b, etag, err := c.s3.GetObjectWithETag(c.key(streamID))
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := make(map[string]*big.Int)
+	if b != nil {
+		log.Debugf("Loaded checkpoint %s (etag=%s): %s", c.key(streamID), etag, string(b))
+		if err = json.Unmarshal(b, &checkpoint); err != nil {
+			return nil, err
+		}
+	}
+	c.mux.Lock()
+	c.etags[streamID] = etag
+	c.mux.Unlock()
+	return checkpoint, nil
+}
+
+// store returns utils.ErrS3PreconditionFailed if another instance has written the checkpoint
+// since it was loaded, in which case the checkpoint must be loaded again before it can be stored
+func (c *s3Checkpoints) store(streamID string, checkpoint map[string]*big.Int) error {
+	b, _ := json.MarshalIndent(&checkpoint, "", "  ")
+	c.mux.Lock()
+	etag := c.etags[streamID]
+	c.mux.Unlock()
+	log.Tracef("Storing checkpoint %s (etag=%s): %s", c.key(streamID), etag, string(b))
+	newETag, err := c.s3.PutObjectIfMatch(c.key(streamID), "application/json", b, etag)
+	if err != nil {
+		return err
+	}
+	c.mux.Lock()
+	c.etags[streamID] = newETag
+	c.mux.Unlock()
+	return nil
+}
+
+func (c *s3Checkpoints) delete(streamID string) {
+	if err := c.s3.DeleteObject(c.key(streamID)); err != nil {
+		log.Warnf("Failed to delete checkpoint %s: %s", c.key(streamID), err)
+	}
+	c.mux.Lock()
+	delete(c.etags, streamID)
+	c.mux.Unlock()
+}
@@ -0,0 +1,261 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockS3 is an in-memory bucket that honours If-Match and If-None-Match on writes
+type mockS3 struct {
+	objects    map[string][]byte
+	etags      map[string]string
+	version    int
+	gets       int
+	failPuts   bool
+	mux        sync.Mutex
+	svr        *httptest.Server
+	statusCode int
+}
+
+func newMockS3() *mockS3 {
+	m := &mockS3{
+		objects: make(map[string][]byte),
+		etags:   make(map[string]string),
+	}
+	m.svr = httptest.NewServer(http.HandlerFunc(m.handler))
+	return m
+}
+
+func (m *mockS3) handler(res http.ResponseWriter, req *http.Request) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.statusCode != 0 {
+		res.WriteHeader(m.statusCode)
+		return
+	}
+	key := req.URL.Path
+	switch req.Method {
+	case http.MethodGet:
+		m.gets++
+		b, ok := m.objects[key]
+		if !ok {
+			res.WriteHeader(404)
+			return
+		}
+		res.Header().Set("ETag", m.etags[key])
+		res.Write(b)
+	case http.MethodPut:
+		etag, exists := m.etags[key]
+		if m.failPuts ||
+			(req.Header.Get("If-None-Match") == "*" && exists) ||
+			(req.Header.Get("If-Match") != "" && req.Header.Get("If-Match") != etag) {
+			res.WriteHeader(412)
+			return
+		}
+		m.version++
+		m.objects[key], _ = ioutil.ReadAll(req.Body)
+		m.etags[key] = fmt.Sprintf(`"v%d"`, m.version)
+		res.Header().Set("ETag", m.etags[key])
+	case http.MethodDelete:
+		delete(m.objects, key)
+		delete(m.etags, key)
+		res.WriteHeader(204)
+	}
+}
+
+func (m *mockS3) getCount() int {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.gets
+}
+
+func newTestS3Checkpoints(t *testing.T, m *mockS3) *s3Checkpoints {
+	c, err := newS3Checkpoints(&S3CheckpointConf{
+		Enabled: true,
+		S3: utils.S3Conf{
+			Endpoint:        m.svr.URL,
+			Bucket:          "bucket1",
+			AccessKeyID:     "ak1",
+			SecretAccessKey: "sk1",
+			PathStyle:       true,
+		},
+	})
+	assert.NoError(t, err)
+	return c
+}
+
+func TestS3CheckpointsStoreLoad(t *testing.T) {
+	assert := assert.New(t)
+	m := newMockS3()
+	defer m.svr.Close()
+	c := newTestS3Checkpoints(t, m)
+
+	cp, err := c.load("es-1")
+	assert.NoError(err)
+	assert.Empty(cp)
+
+	cp["sb-1"] = big.NewInt(12345)
+	assert.NoError(c.store("es-1", cp))
+	assert.Contains(string(m.objects["/bucket1/checkpoints/cp-es-1.json"]), "12345")
+	cp["sb-1"] = big.NewInt(23456)
+	assert.NoError(c.store("es-1", cp))
+
+	// A new instance resumes from the stored checkpoint
+	c2 := newTestS3Checkpoints(t, m)
+	cp, err = c2.load("es-1")
+	assert.NoError(err)
+	assert.Equal(int64(23456), cp["sb-1"].Int64())
+
+	c2.delete("es-1")
+	assert.Empty(m.objects)
+	cp, err = c2.load("es-1")
+	assert.NoError(err)
+	assert.Empty(cp)
+}
+
+func TestS3CheckpointsConflict(t *testing.T) {
+	assert := assert.New(t)
+	m := newMockS3()
+	defer m.svr.Close()
+	c1 := newTestS3Checkpoints(t, m)
+	c2 := newTestS3Checkpoints(t, m)
+
+	// Both instances find no checkpoint, so only the first to store it succeeds
+	cp1, _ := c1.load("es-1")
+	cp2, _ := c2.load("es-1")
+	cp1["sb-1"] = big.NewInt(100)
+	cp2["sb-1"] = big.NewInt(200)
+	assert.NoError(c1.store("es-1", cp1))
+	assert.Equal(utils.ErrS3PreconditionFailed, c2.store("es-1", cp2))
+
+	// After reloading, the second instance can store on top of the first
+	cp2, _ = c2.load("es-1")
+	assert.Equal(int64(100), cp2["sb-1"].Int64())
+	cp2["sb-1"] = big.NewInt(200)
+	assert.NoError(c2.store("es-1", cp2))
+	assert.Equal(utils.ErrS3PreconditionFailed, c1.store("es-1", cp1))
+}
+
+func TestS3CheckpointsErrors(t *testing.T) {
+	assert := assert.New(t)
+	m := newMockS3()
+	defer m.svr.Close()
+	c := newTestS3Checkpoints(t, m)
+
+	m.objects["/bucket1/checkpoints/cp-es-1.json"] = []byte("!bad json")
+	_, err := c.load("es-1")
+	assert.Error(err)
+
+	m.statusCode = 500
+	_, err = c.load("es-1")
+	assert.Regexp("FFEC100288", err)
+	assert.Regexp("FFEC100288", c.store("es-1", map[string]*big.Int{}))
+	c.delete("es-1")
+
+	_, err = newS3Checkpoints(&S3CheckpointConf{Enabled: true})
+	assert.Regexp("FFEC100286", err)
+}
+
+func TestInitS3Checkpoints(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir(t)
+	defer cleanup(t, dir)
+	m := newMockS3()
+	defer m.svr.Close()
+
+	sm := newTestSubscriptionManager()
+	sm.config().EventLevelDBPath = path.Join(dir, "db")
+	sm.config().S3Checkpoints = S3CheckpointConf{
+		Enabled: true,
+		Prefix:  "stack1/",
+		S3: utils.S3Conf{
+			Endpoint:        m.svr.URL,
+			Bucket:          "bucket1",
+			AccessKeyID:     "ak1",
+			SecretAccessKey: "sk1",
+			PathStyle:       true,
+		},
+	}
+	assert.NoError(sm.Init())
+	defer sm.Close(false)
+
+	assert.NoError(sm.storeCheckpoint("es-1", map[string]*big.Int{"sb-1": big.NewInt(1)}))
+	assert.Contains(m.objects, "/bucket1/stack1/cp-es-1.json")
+	cp, err := sm.loadCheckpoint("es-1")
+	assert.NoError(err)
+	assert.Equal(int64(1), cp["sb-1"].Int64())
+	sm.deleteCheckpoint("es-1")
+	assert.Empty(m.objects)
+
+	// Nothing is stored in LevelDB
+	_, err = sm.db.Get(checkpointIDPrefix + "es-1")
+	assert.Error(err)
+}
+
+func TestInitS3CheckpointsFail(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir(t)
+	defer cleanup(t, dir)
+
+	sm := newTestSubscriptionManager()
+	sm.config().EventLevelDBPath = path.Join(dir, "db")
+	sm.config().S3Checkpoints = S3CheckpointConf{Enabled: true}
+	assert.Regexp("FFEC100286", sm.Init())
+}
+
+func TestEventPollerReloadsConflictingCheckpoint(t *testing.T) {
+	assert := assert.New(t)
+	m := newMockS3()
+	defer m.svr.Close()
+	sm, stream, svr, eventStream := newTestStreamForBatching(
+		&StreamInfo{
+			ErrorHandling: ErrorHandlingBlock,
+			Webhook:       &webhookActionInfo{},
+		}, nil, 200)
+	defer close(eventStream)
+	defer svr.Close()
+	defer stream.stop(false)
+
+	stream.suspend()
+	<-stream.eventPollerDone
+	m.failPuts = true
+	sm.checkpoints = newTestS3Checkpoints(t, m)
+
+	go func() {
+		for range eventStream {
+		}
+	}()
+	setupTestSubscription(assert, sm, stream, "")
+	stream.resume()
+
+	// Each failed store causes the checkpoint to be loaded again
+	for m.getCount() < 2 {
+		time.Sleep(1 * time.Millisecond)
+	}
+	stream.suspend()
+	<-stream.eventPollerDone
+}
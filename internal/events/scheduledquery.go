@@ -0,0 +1,230 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/eth"
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ScheduledQueryPathPrefix is the path prefix for scheduled queries
+	ScheduledQueryPathPrefix = "/scheduledqueries"
+	scheduledQueryIDPrefix   = "sq-"
+
+	scheduledQueryTimeout = 60 * time.Second
+)
+
+// ScheduledQueryInfo is the persisted data for a scheduled query, which performs
+// an eth_call against a contract on a cron schedule, and delivers the result to
+// an event stream in the same way as an event from a subscription
+type ScheduledQueryInfo struct {
+	messages.TimeSorted
+	ID        string                           `json:"id,omitempty"`
+	Path      string                           `json:"path"`
+	Name      string                           `json:"name,omitempty"`
+	Stream    string                           `json:"stream"`
+	Schedule  string                           `json:"schedule"`
+	Address   *ethbinding.Address              `json:"address"`
+	Method    *ethbinding.ABIElementMarshaling `json:"method"`
+	Params    []interface{}                    `json:"params,omitempty"`
+	From      string                           `json:"from,omitempty"`
+	LastRun   string                           `json:"lastRun,omitempty"`
+	LastError string                           `json:"lastError,omitempty"`
+}
+
+// scheduledQuery is the runtime that fires the query on its schedule
+type scheduledQuery struct {
+	sm       *subscriptionMGR
+	info     *ScheduledQueryInfo
+	schedule *cronSchedule
+	sig      string
+	mux      sync.Mutex
+	stopChan chan struct{}
+	done     chan struct{}
+}
+
+// GetID returns the ID (for sorting)
+func (info *ScheduledQueryInfo) GetID() string {
+	return info.ID
+}
+
+func newScheduledQuery(sm *subscriptionMGR, info *ScheduledQueryInfo) (*scheduledQuery, error) {
+	if info.Address == nil || info.Method == nil || info.Method.Name == "" {
+		return nil, errors.Errorf(errors.EventStreamsScheduledQueryMissingFields)
+	}
+	schedule, err := parseCronSchedule(info.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.next(time.Now()).IsZero() {
+		return nil, errors.Errorf(errors.EventStreamsScheduleInvalid, info.Schedule)
+	}
+	if _, err := sm.streamByID(info.Stream); err != nil {
+		return nil, err
+	}
+	// Build the call once up-front, so bad parameters are rejected at creation time
+	if _, err := info.buildTxn(); err != nil {
+		return nil, err
+	}
+	methodABI, err := ethbind.API.ABIElementMarshalingToABIMethod(info.Method)
+	if err != nil {
+		return nil, err
+	}
+	return &scheduledQuery{
+		sm:       sm,
+		info:     info,
+		schedule: schedule,
+		sig:      methodABI.Sig,
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+func (info *ScheduledQueryInfo) buildTxn() (*eth.Txn, error) {
+	return eth.NewSendTxn(&messages.SendTransaction{
+		To:     info.Address.String(),
+		Method: info.Method,
+		TransactionCommon: messages.TransactionCommon{
+			From:       info.From,
+			Parameters: info.Params,
+		},
+	}, nil)
+}
+
+func (q *scheduledQuery) start() {
+	go q.scheduleLoop()
+}
+
+func (q *scheduledQuery) stop(wait bool) {
+	q.mux.Lock()
+	select {
+	case <-q.stopChan:
+	default:
+		close(q.stopChan)
+	}
+	q.mux.Unlock()
+	if wait {
+		<-q.done
+	}
+}
+
+func (q *scheduledQuery) isStopped() bool {
+	select {
+	case <-q.stopChan:
+		return true
+	default:
+		return false
+	}
+}
+
+func (q *scheduledQuery) scheduleLoop() {
+	defer close(q.done)
+	for {
+		now := time.Now()
+		next := q.schedule.next(now)
+		if next.IsZero() {
+			log.Warnf("%s: No further runs for schedule '%s'", q.info.ID, q.info.Schedule)
+			return
+		}
+		log.Debugf("%s: Next run at %s", q.info.ID, next.UTC().Format(time.RFC3339))
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-q.stopChan:
+			timer.Stop()
+			log.Debugf("%s: Scheduled query stopped", q.info.ID)
+			return
+		case <-timer.C:
+		}
+		q.run()
+	}
+}
+
+// run performs a single execution of the query, and hands the result to the stream
+func (q *scheduledQuery) run() {
+	stream, err := q.sm.streamByID(q.info.Stream)
+	if err == nil && stream.isSuspendedOrBlocked() {
+		log.Warnf("%s: Skipping scheduled run as stream %s is suspended or blocked", q.info.ID, q.info.Stream)
+		return
+	}
+	var event *eventData
+	if err == nil {
+		event, err = q.query()
+	}
+	if q.isStopped() {
+		// Deleted while the query was in-flight, so do not re-store the record
+		return
+	}
+	q.mux.Lock()
+	q.info.LastRun = time.Now().UTC().Format(time.RFC3339)
+	q.info.LastError = ""
+	if err != nil {
+		log.Errorf("%s: Scheduled query failed: %s", q.info.ID, err)
+		q.info.LastError = err.Error()
+	}
+	q.mux.Unlock()
+	if _, storeErr := q.sm.storeScheduledQuery(q.lastRunInfo()); storeErr != nil {
+		log.Warnf("%s: Failed to record last run: %s", q.info.ID, storeErr)
+	}
+	if err == nil {
+		stream.handleEvent(event)
+	}
+}
+
+func (q *scheduledQuery) query() (*eventData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), scheduledQueryTimeout)
+	defer cancel()
+
+	// Pin the call to a specific block, so the result can be correlated on the receiving side
+	var blockNumber ethbinding.HexBigInt
+	if err := q.sm.rpc.CallContext(ctx, &blockNumber, "eth_blockNumber"); err != nil {
+		return nil, errors.Errorf(errors.RPCCallReturnedError, "eth_blockNumber", err)
+	}
+	tx, err := q.info.buildTxn()
+	if err != nil {
+		return nil, err
+	}
+	blockNumberStr := blockNumber.ToInt().String()
+	result, err := tx.CallAndProcessReply(ctx, q.sm.rpc, blockNumberStr)
+	if err != nil {
+		return nil, err
+	}
+	return &eventData{
+		Address:       q.info.Address.String(),
+		BlockNumber:   blockNumberStr,
+		SubID:         q.info.ID,
+		Signature:     q.sig,
+		Data:          result,
+		Timestamp:     strconv.FormatInt(time.Now().Unix(), 10),
+		batchComplete: func(*eventData) {},
+	}, nil
+}
+
+// lastRunInfo returns a copy of the info safe for serialization while the schedule is running
+func (q *scheduledQuery) lastRunInfo() *ScheduledQueryInfo {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	infoCopy := *q.info
+	return &infoCopy
+}
@@ -0,0 +1,240 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"path"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	"github.com/hyperledger/firefly-ethconnect/internal/kvstore"
+	"github.com/hyperledger/firefly-ethconnect/mocks/ethmocks"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func testScheduledQuerySpec(streamID string) *ScheduledQueryInfo {
+	addr := ethbind.API.HexToAddress("0x0123456789abcDEF0123456789abCDef01234567")
+	return &ScheduledQueryInfo{
+		Name:     "balance",
+		Stream:   streamID,
+		Schedule: "@every 1h",
+		Address:  &addr,
+		Method: &ethbinding.ABIElementMarshaling{
+			Name: "balanceOf",
+			Type: "function",
+			Inputs: []ethbinding.ABIArgumentMarshaling{
+				{Name: "owner", Type: "address"},
+			},
+			Outputs: []ethbinding.ABIArgumentMarshaling{
+				{Name: "balance", Type: "uint256"},
+			},
+		},
+		Params: []interface{}{"0x0123456789abcDEF0123456789abCDef01234567"},
+	}
+}
+
+func mockScheduledQueryRPC(rpc *ethmocks.RPCClient) {
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_blockNumber").Run(func(args mock.Arguments) {
+		*args[1].(*ethbinding.HexBigInt) = ethbinding.HexBigInt(*big.NewInt(12345))
+	}).Return(nil)
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_call", mock.Anything, "0x3039").Run(func(args mock.Arguments) {
+		*args[1].(*string) = "0x000000000000000000000000000000000000000000000000000000000000000a"
+	}).Return(nil)
+}
+
+func TestScheduledQueryRunDeliversToStream(t *testing.T) {
+	assert := assert.New(t)
+	sm, stream, svr, eventStream := newTestStreamForBatching(
+		&StreamInfo{
+			BatchSize: 1,
+			Webhook:   &webhookActionInfo{},
+		}, nil, 200)
+	defer close(eventStream)
+	defer svr.Close()
+	defer stream.stop(false)
+	mockScheduledQueryRPC(sm.rpc.(*ethmocks.RPCClient))
+
+	ctx := context.Background()
+	info, err := sm.AddScheduledQuery(ctx, testScheduledQuerySpec(stream.spec.ID))
+	assert.NoError(err)
+	assert.Regexp("^sq-", info.ID)
+	assert.Equal(ScheduledQueryPathPrefix+"/"+info.ID, info.Path)
+
+	q, err := sm.scheduledQueryByID(info.ID)
+	assert.NoError(err)
+	go q.run()
+	events := <-eventStream
+	assert.Equal(1, len(events))
+	assert.Equal(info.ID, events[0].SubID)
+	assert.Equal("12345", events[0].BlockNumber)
+	assert.Equal("balanceOf(address)", events[0].Signature)
+	assert.Equal("10", events[0].Data["balance"])
+
+	info, err = sm.ScheduledQueryByID(ctx, info.ID)
+	assert.NoError(err)
+	assert.NotEmpty(info.LastRun)
+	assert.Empty(info.LastError)
+	assert.Equal(1, len(sm.ScheduledQueries(ctx)))
+
+	err = sm.DeleteScheduledQuery(ctx, info.ID)
+	assert.NoError(err)
+	_, err = sm.ScheduledQueryByID(ctx, info.ID)
+	assert.Regexp("FFEC100264", err)
+	err = sm.DeleteScheduledQuery(ctx, info.ID)
+	assert.Regexp("FFEC100264", err)
+}
+
+func TestScheduledQueryRunRecordsError(t *testing.T) {
+	assert := assert.New(t)
+	sm, stream, svr, eventStream := newTestStreamForBatching(
+		&StreamInfo{
+			BatchSize: 1,
+			Webhook:   &webhookActionInfo{},
+		}, nil, 200)
+	defer close(eventStream)
+	defer svr.Close()
+	defer stream.stop(false)
+	rpc := sm.rpc.(*ethmocks.RPCClient)
+	rpc.On("CallContext", mock.Anything, mock.Anything, "eth_blockNumber").Return(fmt.Errorf("pop"))
+
+	ctx := context.Background()
+	info, err := sm.AddScheduledQuery(ctx, testScheduledQuerySpec(stream.spec.ID))
+	assert.NoError(err)
+	q, _ := sm.scheduledQueryByID(info.ID)
+	q.run()
+
+	info, _ = sm.ScheduledQueryByID(ctx, info.ID)
+	assert.Regexp("pop", info.LastError)
+	sm.Close(true)
+}
+
+func TestScheduledQuerySkipsSuspendedStream(t *testing.T) {
+	assert := assert.New(t)
+	sm, stream, svr, eventStream := newTestStreamForBatching(
+		&StreamInfo{
+			BatchSize: 1,
+			Webhook:   &webhookActionInfo{},
+		}, nil, 200)
+	defer close(eventStream)
+	defer svr.Close()
+	defer stream.stop(false)
+
+	ctx := context.Background()
+	info, err := sm.AddScheduledQuery(ctx, testScheduledQuerySpec(stream.spec.ID))
+	assert.NoError(err)
+	err = sm.SuspendStream(ctx, stream.spec.ID)
+	assert.NoError(err)
+
+	q, _ := sm.scheduledQueryByID(info.ID)
+	q.run()
+	info, _ = sm.ScheduledQueryByID(ctx, info.ID)
+	assert.Empty(info.LastRun)
+	sm.rpc.(*ethmocks.RPCClient).AssertNotCalled(t, "CallContext", mock.Anything, mock.Anything, "eth_blockNumber")
+}
+
+func TestScheduledQueryValidation(t *testing.T) {
+	assert := assert.New(t)
+	sm, stream, svr, eventStream := newTestStreamForBatching(
+		&StreamInfo{
+			Webhook: &webhookActionInfo{},
+		}, nil, 200)
+	defer close(eventStream)
+	defer svr.Close()
+	defer stream.stop(false)
+	ctx := context.Background()
+
+	spec := testScheduledQuerySpec(stream.spec.ID)
+	spec.Method = nil
+	_, err := sm.AddScheduledQuery(ctx, spec)
+	assert.Regexp("FFEC100265", err)
+
+	spec = testScheduledQuerySpec(stream.spec.ID)
+	spec.Schedule = "every day"
+	_, err = sm.AddScheduledQuery(ctx, spec)
+	assert.Regexp("FFEC100263", err)
+
+	spec = testScheduledQuerySpec(stream.spec.ID)
+	spec.Schedule = "0 0 30 2 *"
+	_, err = sm.AddScheduledQuery(ctx, spec)
+	assert.Regexp("FFEC100263", err)
+
+	spec = testScheduledQuerySpec("unknown")
+	_, err = sm.AddScheduledQuery(ctx, spec)
+	assert.Regexp("FFEC100042", err)
+
+	spec = testScheduledQuerySpec(stream.spec.ID)
+	spec.Params = []interface{}{}
+	_, err = sm.AddScheduledQuery(ctx, spec)
+	assert.Error(err)
+
+	assert.Equal(0, len(sm.ScheduledQueries(ctx)))
+}
+
+func TestScheduledQueryStoreFail(t *testing.T) {
+	assert := assert.New(t)
+	sm, stream, svr, eventStream := newTestStreamForBatching(
+		&StreamInfo{
+			Webhook: &webhookActionInfo{},
+		}, nil, 200)
+	defer close(eventStream)
+	defer svr.Close()
+	defer stream.stop(false)
+
+	sm.db = kvstore.NewMockKV(fmt.Errorf("pop"))
+	_, err := sm.AddScheduledQuery(context.Background(), testScheduledQuerySpec(stream.spec.ID))
+	assert.Regexp("FFEC100266", err)
+}
+
+func TestScheduledQueryRecoverAndDeleteWithStream(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir(t)
+	defer cleanup(t, dir)
+
+	sm := newTestSubscriptionManager()
+	sm.db, _ = kvstore.NewLDBKeyValueStore(path.Join(dir, "db"))
+	ctx := context.Background()
+	stream, err := sm.AddStream(ctx, &StreamInfo{
+		Type:    "webhook",
+		Webhook: &webhookActionInfo{URL: "http://test.invalid"},
+	})
+	assert.NoError(err)
+	info, err := sm.AddScheduledQuery(ctx, testScheduledQuerySpec(stream.ID))
+	assert.NoError(err)
+	sm.db.Put(scheduledQueryIDPrefix+"bad1", []byte(":bad json"))
+	sm.db.Put(scheduledQueryIDPrefix+"bad2", []byte("{}"))
+	sm.Close(true)
+
+	sm = newTestSubscriptionManager()
+	sm.conf.EventLevelDBPath = path.Join(dir, "db")
+	err = sm.Init()
+	assert.NoError(err)
+	assert.Equal(1, len(sm.scheduledQueries))
+	recovered, err := sm.ScheduledQueryByID(ctx, info.ID)
+	assert.NoError(err)
+	assert.Equal("balanceOf", recovered.Method.Name)
+
+	// Deleting the stream removes the scheduled queries that target it
+	err = sm.DeleteStream(ctx, stream.ID)
+	assert.NoError(err)
+	assert.Equal(0, len(sm.scheduledQueries))
+	_, err = sm.db.Get(info.ID)
+	assert.Equal(kvstore.ErrorNotFound, err)
+	sm.Close(true)
+}
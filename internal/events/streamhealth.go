@@ -0,0 +1,112 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"time"
+)
+
+const (
+	// StreamStatusHealthy the stream is delivering, or waiting for events
+	StreamStatusHealthy = "healthy"
+	// StreamStatusRetrying the last attempt to deliver a batch failed, and the stream is retrying
+	StreamStatusRetrying = "retrying"
+	// StreamStatusSuspended the stream has been suspended
+	StreamStatusSuspended = "suspended"
+)
+
+// StreamHealth reports the delivery progress of a single event stream. Each stream has its own
+// poller, dispatcher and processor, so an unhealthy stream does not hold up the others.
+type StreamHealth struct {
+	ID                  string `json:"id"`
+	Status              string `json:"status"`
+	Blocked             bool   `json:"blocked"`
+	Throttled           bool   `json:"throttled"`
+	InFlightEvents      uint64 `json:"inFlightEvents"`
+	QueuedBatches       int    `json:"queuedBatches"`
+	QueuedEvents        uint64 `json:"queuedEvents"`
+	BatchesDelivered    uint64 `json:"batchesDelivered"`
+	BatchesSkipped      uint64 `json:"batchesSkipped"`
+	ConsecutiveFailures uint64 `json:"consecutiveFailures"`
+	LastDeliveredISO    string `json:"lastDelivered,omitempty"`
+	LastError           string `json:"lastError,omitempty"`
+	LastErrorISO        string `json:"lastErrorTime,omitempty"`
+}
+
+// streamHealthState is the mutable part of the health, updated under the batch lock
+type streamHealthState struct {
+	queuedEvents        uint64
+	batchesDelivered    uint64
+	batchesSkipped      uint64
+	consecutiveFailures uint64
+	lastDelivered       time.Time
+	lastError           error
+	lastErrorTime       time.Time
+}
+
+func (a *eventStream) recordAttempt(err error) {
+	a.batchCond.L.Lock()
+	defer a.batchCond.L.Unlock()
+	if err != nil {
+		a.healthState.consecutiveFailures++
+		a.healthState.lastError = err
+		a.healthState.lastErrorTime = time.Now().UTC()
+	} else {
+		a.healthState.consecutiveFailures = 0
+	}
+}
+
+func (a *eventStream) recordBatchComplete(delivered bool) {
+	a.batchCond.L.Lock()
+	defer a.batchCond.L.Unlock()
+	if delivered {
+		a.healthState.batchesDelivered++
+		a.healthState.lastDelivered = time.Now().UTC()
+	} else {
+		a.healthState.batchesSkipped++
+		// The next batch starts afresh
+		a.healthState.consecutiveFailures = 0
+	}
+}
+
+func (a *eventStream) health() *StreamHealth {
+	a.batchCond.L.Lock()
+	defer a.batchCond.L.Unlock()
+	h := &StreamHealth{
+		ID:                  a.spec.ID,
+		Status:              StreamStatusHealthy,
+		Blocked:             a.inFlight >= a.spec.BatchSize,
+		Throttled:           a.throttleDelay() > 0,
+		InFlightEvents:      a.inFlight,
+		QueuedBatches:       a.batchQueue.Len(),
+		QueuedEvents:        a.healthState.queuedEvents,
+		BatchesDelivered:    a.healthState.batchesDelivered,
+		BatchesSkipped:      a.healthState.batchesSkipped,
+		ConsecutiveFailures: a.healthState.consecutiveFailures,
+	}
+	if a.spec.Suspended {
+		h.Status = StreamStatusSuspended
+	} else if a.healthState.consecutiveFailures > 0 {
+		h.Status = StreamStatusRetrying
+	}
+	if !a.healthState.lastDelivered.IsZero() {
+		h.LastDeliveredISO = a.healthState.lastDelivered.Format(time.RFC3339)
+	}
+	if a.healthState.lastError != nil {
+		h.LastError = a.healthState.lastError.Error()
+		h.LastErrorISO = a.healthState.lastErrorTime.Format(time.RFC3339)
+	}
+	return h
+}
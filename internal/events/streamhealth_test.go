@@ -0,0 +1,158 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testStreamAction struct {
+	mux       sync.Mutex
+	panics    int
+	attempts  int
+	delivered []time.Time
+}
+
+func (ta *testStreamAction) attemptBatch(batchNumber, attempt uint64, events []*eventData) error {
+	ta.mux.Lock()
+	defer ta.mux.Unlock()
+	ta.attempts++
+	if ta.attempts <= ta.panics {
+		panic("pop")
+	}
+	ta.delivered = append(ta.delivered, time.Now())
+	return nil
+}
+
+func (ta *testStreamAction) deliveredCount() int {
+	ta.mux.Lock()
+	defer ta.mux.Unlock()
+	return len(ta.delivered)
+}
+
+func newTestStreamWithAction(t *testing.T, spec *StreamInfo, action *testStreamAction) (*subscriptionMGR, *eventStream) {
+	sm := newTestSubscriptionManager()
+	sm.config().EventPollingIntervalSec = 0
+	spec.Type = "websocket"
+	info, err := sm.AddStream(context.Background(), spec)
+	assert.NoError(t, err)
+	stream := sm.streams[info.ID]
+	stream.batchCond.L.Lock()
+	stream.action = action
+	stream.batchCond.L.Unlock()
+	return sm, stream
+}
+
+func waitForDelivered(action *testStreamAction, count int) {
+	for action.deliveredCount() < count {
+		time.Sleep(1 * time.Millisecond)
+	}
+}
+
+func TestStreamHealthActionPanic(t *testing.T) {
+	assert := assert.New(t)
+	zero := uint64(0)
+	action := &testStreamAction{panics: 1}
+	sm, stream := newTestStreamWithAction(t, &StreamInfo{
+		ErrorHandling:        ErrorHandlingBlock,
+		BlockedRetryDelaySec: &zero,
+	}, action)
+	defer sm.Close(true)
+
+	h, err := sm.StreamHealth(context.Background(), stream.spec.ID)
+	assert.NoError(err)
+	assert.Equal(StreamStatusHealthy, h.Status)
+	assert.Empty(h.LastDeliveredISO)
+
+	stream.handleEvent(testEvent("sub1"))
+	waitForDelivered(action, 1)
+	for stream.health().BatchesDelivered == 0 {
+		time.Sleep(1 * time.Millisecond)
+	}
+
+	h = stream.health()
+	assert.Equal(StreamStatusHealthy, h.Status)
+	assert.Equal(uint64(0), h.ConsecutiveFailures)
+	assert.Regexp("FFEC100422.*pop", h.LastError)
+	assert.NotEmpty(h.LastErrorISO)
+	assert.NotEmpty(h.LastDeliveredISO)
+	assert.Equal(uint64(0), h.QueuedEvents)
+	assert.Equal(2, action.attempts)
+}
+
+func TestStreamHealthStatus(t *testing.T) {
+	assert := assert.New(t)
+	action := &testStreamAction{}
+	sm, stream := newTestStreamWithAction(t, &StreamInfo{}, action)
+	defer sm.Close(true)
+
+	stream.recordAttempt(fmt.Errorf("pop"))
+	assert.Equal(StreamStatusRetrying, stream.health().Status)
+	stream.recordBatchComplete(false)
+	h := stream.health()
+	assert.Equal(StreamStatusHealthy, h.Status)
+	assert.Equal(uint64(1), h.BatchesSkipped)
+
+	err := sm.SuspendStream(context.Background(), stream.spec.ID)
+	assert.NoError(err)
+	assert.Equal(StreamStatusSuspended, stream.health().Status)
+
+	_, err = sm.StreamHealth(context.Background(), "nope")
+	assert.Regexp("FFEC100042", err)
+}
+
+func TestStreamHealthThrottle(t *testing.T) {
+	assert := assert.New(t)
+	action := &testStreamAction{}
+	sm, stream := newTestStreamWithAction(t, &StreamInfo{
+		BatchSize:       1,
+		MaxEventsPerSec: 20,
+	}, action)
+	defer sm.Close(true)
+
+	for i := 0; i < 3; i++ {
+		stream.handleEvent(testEvent("sub1"))
+	}
+	waitForDelivered(action, 3)
+	assert.GreaterOrEqual(action.delivered[2].Sub(action.delivered[0]), 90*time.Millisecond)
+
+	updated, err := sm.UpdateStream(context.Background(), stream.spec.ID, &StreamInfo{MaxEventsPerSec: 1000})
+	assert.NoError(err)
+	assert.Equal(uint64(1000), updated.MaxEventsPerSec)
+}
+
+func TestStreamHealthStopWhileThrottled(t *testing.T) {
+	action := &testStreamAction{}
+	sm, stream := newTestStreamWithAction(t, &StreamInfo{
+		BatchSize:       1,
+		MaxEventsPerSec: 1,
+	}, action)
+
+	stream.handleEvent(testEvent("sub1"))
+	stream.handleEvent(testEvent("sub1"))
+	waitForDelivered(action, 1)
+	for !stream.health().Throttled {
+		time.Sleep(1 * time.Millisecond)
+	}
+	// The second batch is held back for a second, but stopping must not wait for it
+	sm.Close(true)
+	assert.Equal(t, 1, action.deliveredCount())
+}
@@ -17,6 +17,7 @@ package events
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"math/big"
 	"strings"
 	"sync"
@@ -55,6 +56,7 @@ type SubscriptionManager interface {
 	AddStream(ctx context.Context, spec *StreamInfo) (*StreamInfo, error)
 	Streams(ctx context.Context) []*StreamInfo
 	StreamByID(ctx context.Context, id string) (*StreamInfo, error)
+	StreamHealth(ctx context.Context, id string) (*StreamHealth, error)
 	UpdateStream(ctx context.Context, id string, spec *StreamInfo) (*StreamInfo, error)
 	SuspendStream(ctx context.Context, id string) error
 	ResumeStream(ctx context.Context, id string) error
@@ -64,10 +66,27 @@ type SubscriptionManager interface {
 	Subscriptions(ctx context.Context) []*SubscriptionInfo
 	SubscriptionByID(ctx context.Context, id string) (*SubscriptionInfo, error)
 	ResetSubscription(ctx context.Context, id, initialBlock string) error
+	ResolveFromTime(ctx context.Context, fromTime string) (string, error)
 	DeleteSubscription(ctx context.Context, id string) error
+	ExportSubscription(ctx context.Context, id string, req *SubscriptionExportRequest, w io.Writer) error
+	StartExportJob(ctx context.Context, subID string, req *SubscriptionExportRequest) (*ExportJobInfo, error)
+	ExportJobs(ctx context.Context, subID string) ([]*ExportJobInfo, error)
+	ExportJobByID(ctx context.Context, subID, jobID string) (*ExportJobInfo, error)
+	AddScheduledQuery(ctx context.Context, spec *ScheduledQueryInfo) (*ScheduledQueryInfo, error)
+	ScheduledQueries(ctx context.Context) []*ScheduledQueryInfo
+	ScheduledQueryByID(ctx context.Context, id string) (*ScheduledQueryInfo, error)
+	DeleteScheduledQuery(ctx context.Context, id string) error
+	SetDeliveryListener(listener DeliveryListener)
 	Close(wait bool)
 }
 
+// DeliveryListener is notified each time an event stream has delivered a batch of events,
+// with the hashes of the transactions that emitted them. Batches skipped after a failure
+// are not notified.
+type DeliveryListener interface {
+	EventsDelivered(streamID string, batchNumber uint64, txHashes []string)
+}
+
 type subscriptionManager interface {
 	config() *SubscriptionManagerConf
 	streamByID(string) (*eventStream, error)
@@ -76,22 +95,26 @@ type subscriptionManager interface {
 	loadCheckpoint(string) (map[string]*big.Int, error)
 	storeCheckpoint(string, map[string]*big.Int) error
 	confirmationManager() *blockConfirmationManager
+	deliveryListener() DeliveryListener
 }
 
 // SubscriptionManagerConf configuration
 type SubscriptionManagerConf struct {
-	EventLevelDBPath        string          `json:"eventsDB"`
-	EventPollingIntervalSec uint64          `json:"eventPollingIntervalSec,omitempty"`
-	CatchupModeBlockGap     int64           `json:"catchupModeBlockGap,omitempty"`
-	CatchupModePageSize     int64           `json:"catchupModePageSize,omitempty"`
-	WebhooksAllowPrivateIPs bool            `json:"webhooksAllowPrivateIPs,omitempty"`
-	DecimalTransactionIndex bool            `json:"decimalTransactionIndex,omitempty"`
-	Confirmations           bcmConfExternal `json:"confirmations,omitempty"`
+	EventLevelDBPath        string           `json:"eventsDB"`
+	EventPollingIntervalSec uint64           `json:"eventPollingIntervalSec,omitempty"`
+	CatchupModeBlockGap     int64            `json:"catchupModeBlockGap,omitempty"`
+	CatchupModePageSize     int64            `json:"catchupModePageSize,omitempty"`
+	WebhooksAllowPrivateIPs bool             `json:"webhooksAllowPrivateIPs,omitempty"`
+	DecimalTransactionIndex bool             `json:"decimalTransactionIndex,omitempty"`
+	Confirmations           bcmConfExternal  `json:"confirmations,omitempty"`
+	S3Checkpoints           S3CheckpointConf `json:"s3Checkpoints,omitempty"`
+	Exports                 ExportJobConf    `json:"exports,omitempty"`
 }
 
 type subscriptionMGR struct {
 	conf               *SubscriptionManagerConf
 	db                 kvstore.KVStore
+	checkpoints        *s3Checkpoints
 	rpc                eth.RPCClient
 	subscriptions      map[string]*subscription
 	bcm                *blockConfirmationManager
@@ -100,6 +123,13 @@ type subscriptionMGR struct {
 	cr                 contractregistry.ContractResolver
 	wsChannels         ws.WebSocketChannels
 	subscriptionsMutex sync.RWMutex
+	scheduledQueries   map[string]*scheduledQuery
+	scheduledMutex     sync.Mutex
+	listener           DeliveryListener
+	listenerMutex      sync.Mutex
+	exports            exportOutput
+	exportJobs         map[string]*exportJob
+	exportMutex        sync.Mutex
 }
 
 // CobraInitSubscriptionManager standard naming for cobra command params
@@ -112,12 +142,14 @@ func CobraInitSubscriptionManager(cmd *cobra.Command, conf *SubscriptionManagerC
 // NewSubscriptionManager constructor
 func NewSubscriptionManager(conf *SubscriptionManagerConf, rpc eth.RPCClient, cr contractregistry.ContractResolver, wsChannels ws.WebSocketChannels) (s SubscriptionManager, err error) {
 	sm := &subscriptionMGR{
-		conf:          conf,
-		rpc:           rpc,
-		subscriptions: make(map[string]*subscription),
-		streams:       make(map[string]*eventStream),
-		cr:            cr,
-		wsChannels:    wsChannels,
+		conf:             conf,
+		rpc:              rpc,
+		subscriptions:    make(map[string]*subscription),
+		streams:          make(map[string]*eventStream),
+		scheduledQueries: make(map[string]*scheduledQuery),
+		exportJobs:       make(map[string]*exportJob),
+		cr:               cr,
+		wsChannels:       wsChannels,
 	}
 	if conf.EventPollingIntervalSec <= 0 {
 		conf.EventPollingIntervalSec = 1
@@ -216,8 +248,25 @@ func (s *subscriptionMGR) addSubscriptionCommon(ctx context.Context, abi *ABIRef
 	}
 	i.Path = SubPathPrefix + "/" + i.ID
 
-	// Check initial block number to subscribe from
-	if err := s.setInitialBlock(i, newSub.FromBlock); err != nil {
+	if newSub.GetLogs != nil {
+		if err := newSub.GetLogs.validate(); err != nil {
+			return nil, err
+		}
+		i.GetLogs = newSub.GetLogs
+	}
+
+	// Check initial block number to subscribe from, which might be given as a time
+	fromBlock := newSub.FromBlock
+	if newSub.FromTime != "" {
+		if fromBlock != "" {
+			return nil, errors.Errorf(errors.EventStreamsSubscribeBlockAndTime)
+		}
+		var err error
+		if fromBlock, err = s.ResolveFromTime(ctx, newSub.FromTime); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.setInitialBlock(i, fromBlock); err != nil {
 		return nil, err
 	}
 
@@ -296,6 +345,15 @@ func (s *subscriptionMGR) StreamByID(ctx context.Context, id string) (*StreamInf
 	return stream.spec, nil
 }
 
+// StreamHealth reports the delivery progress of a stream
+func (s *subscriptionMGR) StreamHealth(ctx context.Context, id string) (*StreamHealth, error) {
+	stream, err := s.streamByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return stream.health(), nil
+}
+
 // Streams used externally to get list streams
 func (s *subscriptionMGR) Streams(ctx context.Context) []*StreamInfo {
 	l := make([]*StreamInfo, 0, len(s.streams))
@@ -359,6 +417,11 @@ func (s *subscriptionMGR) DeleteStream(ctx context.Context, id string) error {
 		_ = s.deleteSubscription(ctx, sub)
 	}
 
+	// As well as any scheduled queries delivering to it
+	for _, q := range s.scheduledQueriesForStream(stream.spec.ID) {
+		_ = s.deleteScheduledQuery(q)
+	}
+
 	delete(s.streams, stream.spec.ID)
 	stream.stop(false)
 	if err = s.db.Delete(stream.spec.ID); err != nil {
@@ -427,6 +490,9 @@ func (s *subscriptionMGR) streamByID(id string) (*eventStream, error) {
 }
 
 func (s *subscriptionMGR) loadCheckpoint(streamID string) (map[string]*big.Int, error) {
+	if s.checkpoints != nil {
+		return s.checkpoints.load(streamID)
+	}
 	cpID := checkpointIDPrefix + streamID
 	b, err := s.db.Get(cpID)
 	if err == leveldb.ErrNotFound {
@@ -444,6 +510,9 @@ func (s *subscriptionMGR) loadCheckpoint(streamID string) (map[string]*big.Int,
 }
 
 func (s *subscriptionMGR) storeCheckpoint(streamID string, checkpoint map[string]*big.Int) error {
+	if s.checkpoints != nil {
+		return s.checkpoints.store(streamID, checkpoint)
+	}
 	cpID := checkpointIDPrefix + streamID
 	b, _ := json.MarshalIndent(&checkpoint, "", "  ")
 	log.Tracef("Storing checkpoint %s: %s", cpID, string(b))
@@ -451,6 +520,10 @@ func (s *subscriptionMGR) storeCheckpoint(streamID string, checkpoint map[string
 }
 
 func (s *subscriptionMGR) deleteCheckpoint(streamID string) {
+	if s.checkpoints != nil {
+		s.checkpoints.delete(streamID)
+		return
+	}
 	cpID := checkpointIDPrefix + streamID
 	_ = s.db.Delete(cpID)
 }
@@ -459,8 +532,19 @@ func (s *subscriptionMGR) Init() (err error) {
 	if s.db, err = kvstore.NewLDBKeyValueStore(s.conf.EventLevelDBPath); err != nil {
 		return errors.Errorf(errors.EventStreamsDBLoad, s.conf.EventLevelDBPath, err)
 	}
+	if s.conf.S3Checkpoints.Enabled {
+		if s.checkpoints, err = newS3Checkpoints(&s.conf.S3Checkpoints); err != nil {
+			s.db.Close()
+			return err
+		}
+	}
+	if s.exports, err = newExportOutput(&s.conf.Exports); err != nil {
+		s.db.Close()
+		return err
+	}
 	s.recoverStreams()
 	s.recoverSubscriptions()
+	s.recoverScheduledQueries()
 	return nil
 }
 
@@ -514,6 +598,12 @@ func (s *subscriptionMGR) recoverSubscriptions() {
 
 func (s *subscriptionMGR) Close(wait bool) {
 	log.Infof("Event stream subscription manager shutting down")
+	s.stopExportJobs(wait)
+	s.scheduledMutex.Lock()
+	for _, q := range s.scheduledQueries {
+		q.stop(wait)
+	}
+	s.scheduledMutex.Unlock()
 	for _, stream := range s.streams {
 		stream.stop(wait)
 	}
@@ -526,6 +616,134 @@ func (s *subscriptionMGR) Close(wait bool) {
 	s.closed = true
 }
 
+func (s *subscriptionMGR) recoverScheduledQueries() {
+	// Recover all the scheduled queries, which requires the streams to be recovered first
+	iSQ := s.db.NewIterator()
+	defer iSQ.Release()
+	for iSQ.Next() {
+		k := iSQ.Key()
+		if strings.HasPrefix(k, scheduledQueryIDPrefix) {
+			var info ScheduledQueryInfo
+			err := json.Unmarshal(iSQ.Value(), &info)
+			if err != nil {
+				log.Errorf("Failed to recover scheduled query '%s': %s", string(iSQ.Value()), err)
+				continue
+			}
+			q, err := newScheduledQuery(s, &info)
+			if err != nil {
+				log.Errorf("Failed to recover scheduled query '%s': %s", info.ID, err)
+			} else {
+				s.scheduledMutex.Lock()
+				s.scheduledQueries[info.ID] = q
+				s.scheduledMutex.Unlock()
+				q.start()
+			}
+		}
+	}
+}
+
 func (s *subscriptionMGR) confirmationManager() *blockConfirmationManager {
 	return s.bcm
 }
+
+// SetDeliveryListener registers the listener to notify as batches are delivered, which can be
+// after the event streams have started
+func (s *subscriptionMGR) SetDeliveryListener(listener DeliveryListener) {
+	s.listenerMutex.Lock()
+	defer s.listenerMutex.Unlock()
+	s.listener = listener
+}
+
+func (s *subscriptionMGR) deliveryListener() DeliveryListener {
+	s.listenerMutex.Lock()
+	defer s.listenerMutex.Unlock()
+	return s.listener
+}
+
+// AddScheduledQuery creates a new scheduled query, and starts its schedule
+func (s *subscriptionMGR) AddScheduledQuery(ctx context.Context, spec *ScheduledQueryInfo) (*ScheduledQueryInfo, error) {
+	spec.ID = scheduledQueryIDPrefix + utils.UUIDv4()
+	spec.CreatedISO8601 = time.Now().UTC().Format(time.RFC3339)
+	spec.Path = ScheduledQueryPathPrefix + "/" + spec.ID
+	spec.LastRun = ""
+	spec.LastError = ""
+	q, err := newScheduledQuery(s, spec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.storeScheduledQuery(spec); err != nil {
+		return nil, err
+	}
+	s.scheduledMutex.Lock()
+	s.scheduledQueries[spec.ID] = q
+	s.scheduledMutex.Unlock()
+	q.start()
+	return q.lastRunInfo(), nil
+}
+
+// ScheduledQueries used externally to list scheduled queries
+func (s *subscriptionMGR) ScheduledQueries(ctx context.Context) []*ScheduledQueryInfo {
+	s.scheduledMutex.Lock()
+	defer s.scheduledMutex.Unlock()
+	l := make([]*ScheduledQueryInfo, 0, len(s.scheduledQueries))
+	for _, q := range s.scheduledQueries {
+		l = append(l, q.lastRunInfo())
+	}
+	return l
+}
+
+// ScheduledQueryByID used externally to get serializable details
+func (s *subscriptionMGR) ScheduledQueryByID(ctx context.Context, id string) (*ScheduledQueryInfo, error) {
+	q, err := s.scheduledQueryByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return q.lastRunInfo(), nil
+}
+
+// DeleteScheduledQuery stops and deletes a scheduled query
+func (s *subscriptionMGR) DeleteScheduledQuery(ctx context.Context, id string) error {
+	q, err := s.scheduledQueryByID(id)
+	if err != nil {
+		return err
+	}
+	return s.deleteScheduledQuery(q)
+}
+
+func (s *subscriptionMGR) deleteScheduledQuery(q *scheduledQuery) error {
+	s.scheduledMutex.Lock()
+	delete(s.scheduledQueries, q.info.ID)
+	s.scheduledMutex.Unlock()
+	q.stop(false)
+	return s.db.Delete(q.info.ID)
+}
+
+func (s *subscriptionMGR) scheduledQueryByID(id string) (*scheduledQuery, error) {
+	s.scheduledMutex.Lock()
+	q, exists := s.scheduledQueries[id]
+	s.scheduledMutex.Unlock()
+	if !exists {
+		return nil, errors.Errorf(errors.EventStreamsScheduledQueryNotFound, id)
+	}
+	return q, nil
+}
+
+func (s *subscriptionMGR) scheduledQueriesForStream(streamID string) []*scheduledQuery {
+	s.scheduledMutex.Lock()
+	defer s.scheduledMutex.Unlock()
+	l := make([]*scheduledQuery, 0)
+	for _, q := range s.scheduledQueries {
+		if q.info.Stream == streamID {
+			l = append(l, q)
+		}
+	}
+	return l
+}
+
+func (s *subscriptionMGR) storeScheduledQuery(info *ScheduledQueryInfo) (*ScheduledQueryInfo, error) {
+	infoBytes, _ := json.MarshalIndent(info, "", "  ")
+	if err := s.db.Put(info.ID, infoBytes); err != nil {
+		return nil, errors.Errorf(errors.EventStreamsScheduledQueryStoreFailed, err)
+	}
+	return info, nil
+}
@@ -48,7 +48,9 @@ type SubscriptionCreateDTO struct {
 	Event     *ethbinding.ABIElementMarshaling `json:"event,omitempty"`
 	Methods   ethbinding.ABIMarshaling         `json:"methods,omitempty"` // an inline set of methods that might emit the event
 	FromBlock string                           `json:"fromBlock,omitempty"`
+	FromTime  string                           `json:"fromTime,omitempty"`
 	Address   *ethbinding.Address              `json:"address,omitempty"`
+	GetLogs   *GetLogsConf                     `json:"getLogs,omitempty"`
 }
 
 type ABIRefOrInline struct {
@@ -68,6 +70,7 @@ type SubscriptionInfo struct {
 	Event        *ethbinding.ABIElementMarshaling `json:"event"`
 	FromBlock    string                           `json:"fromBlock,omitempty"`
 	ABI          *ABIRefOrInline                  `json:"abi,omitempty"`
+	GetLogs      *GetLogsConf                     `json:"getLogs,omitempty"`
 	Synchronized bool                             `json:"synchronized"`
 }
 
@@ -86,6 +89,7 @@ type subscription struct {
 	catchupBlock        *big.Int
 	catchupModeBlockGap int64
 	catchupModePageSize int64
+	getLogs             *getLogsRetry
 }
 
 func newSubscription(sm subscriptionManager, rpc eth.RPCClient, cr contractregistry.ContractResolver, addr *ethbinding.Address, i *SubscriptionInfo) (*subscription, error) {
@@ -307,29 +311,44 @@ func (s *subscription) getTransactionInputs(ctx context.Context, l *logEntry) {
 }
 
 func (s *subscription) processCatchupBlocks(ctx context.Context) error {
+	if s.getLogs == nil {
+		s.getLogs = newGetLogsRetry(s.info.GetLogs, s.catchupModePageSize)
+	}
+	if !s.getLogs.ready() {
+		// Backing off after a failure, without holding up the other subscriptions on the stream
+		return nil
+	}
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
-	var logs []*logEntry
-
-	f := &ethFilter{}
-	f.persistedFilter = s.info.Filter
-	f.FromBlock.ToInt().Set(s.catchupBlock)
-	endBlock := new(big.Int).Add(s.catchupBlock, big.NewInt(s.catchupModePageSize-1))
-	f.ToBlock = "0x" + endBlock.Text(16)
-
-	log.Infof("%s: catchup mode. Blocks %d -> %d", s.logName, s.catchupBlock.Int64(), endBlock.Int64())
-	if err := s.rpc.CallContext(ctx, &logs, "eth_getLogs", f); err != nil {
-		return errors.Errorf(errors.RPCCallReturnedError, "eth_getLogs", err)
-	}
-	if len(logs) == 0 {
-		// We only want to catch up once - so see if we can update our HWM based on the fact
-		// we know these historical blocks are empty.
-		s.lp.markNoEvents(endBlock)
-	} else {
-		s.processLogs(ctx, "eth_getLogs", logs)
+	for {
+		var logs []*logEntry
+
+		f := &ethFilter{}
+		f.persistedFilter = s.info.Filter
+		f.FromBlock.ToInt().Set(s.catchupBlock)
+		endBlock := new(big.Int).Add(s.catchupBlock, big.NewInt(s.getLogs.blockRange-1))
+		f.ToBlock = "0x" + endBlock.Text(16)
+
+		log.Infof("%s: catchup mode. Blocks %d -> %d", s.logName, s.catchupBlock.Int64(), endBlock.Int64())
+		if err := s.rpc.CallContext(ctx, &logs, "eth_getLogs", f); err != nil {
+			if s.getLogs.split(err) {
+				log.Warnf("%s: eth_getLogs failed for blocks %d -> %d (%s). Retrying with a range of %d blocks", s.logName, s.catchupBlock.Int64(), endBlock.Int64(), err, s.getLogs.blockRange)
+				continue
+			}
+			s.getLogs.failed()
+			return errors.Errorf(errors.RPCCallReturnedError, "eth_getLogs", err)
+		}
+		s.getLogs.succeeded()
+		if len(logs) == 0 {
+			// We only want to catch up once - so see if we can update our HWM based on the fact
+			// we know these historical blocks are empty.
+			s.lp.markNoEvents(endBlock)
+		} else {
+			s.processLogs(ctx, "eth_getLogs", logs)
+		}
+		s.catchupBlock = endBlock.Add(endBlock, big.NewInt(1))
+		return nil
 	}
-	s.catchupBlock = endBlock.Add(endBlock, big.NewInt(1))
-	return nil
 }
 
 func (s *subscription) processLogs(ctx context.Context, rpcMethod string, logs []*logEntry) {
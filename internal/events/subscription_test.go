@@ -65,6 +65,10 @@ func (m *mockSubMgr) confirmationManager() *blockConfirmationManager {
 	return nil
 }
 
+func (m *mockSubMgr) deliveryListener() DeliveryListener {
+	return nil
+}
+
 func newTestStream() *eventStream {
 	a, _ := newEventStream(newTestSubscriptionManager(), &StreamInfo{
 		ID:   "123",
@@ -43,15 +43,23 @@ type KafkaBridgeConf struct {
 	eth.RPCConf
 }
 
+// LocalReplyHandler is implemented by a REST gateway running in the same process as the bridge,
+// to record replies in its receipt store without a round trip through the reply topic
+type LocalReplyHandler interface {
+	ProcessReply(msgBytes []byte)
+}
+
 // KafkaBridge receives messages from Kafka and dispatches them to go-ethereum over JSON/RPC
 type KafkaBridge struct {
-	printYAML    *bool
-	conf         KafkaBridgeConf
-	kafka        KafkaCommon
-	rpc          eth.RPCClient
-	processor    tx.TxnProcessor
-	inFlight     map[string]*msgContext
-	inFlightCond *sync.Cond
+	printYAML         *bool
+	conf              KafkaBridgeConf
+	kafka             KafkaCommon
+	rpc               eth.RPCClient
+	processor         tx.TxnProcessor
+	inFlight          map[string]*msgContext
+	inFlightCond      *sync.Cond
+	localReplyHandler LocalReplyHandler
+	replySender       *tx.ReplyWebhookSender
 }
 
 // Conf gets the config for this bridge
@@ -64,6 +72,11 @@ func (k *KafkaBridge) SetConf(conf *KafkaBridgeConf) {
 	k.conf = *conf
 }
 
+// SetLocalReplyHandler sets the handler for replies to requests that opted out of the reply topic
+func (k *KafkaBridge) SetLocalReplyHandler(handler LocalReplyHandler) {
+	k.localReplyHandler = handler
+}
+
 // ValidateConf validates the configuration
 func (k *KafkaBridge) ValidateConf() (err error) {
 	if k.conf.RPC.URL == "" {
@@ -78,7 +91,7 @@ func (k *KafkaBridge) ValidateConf() (err error) {
 	if k.conf.MaxInFlight <= 0 {
 		k.conf.MaxInFlight = 10
 	}
-	return
+	return eth.ValidateChainProfile(k.conf.ChainProfile)
 }
 
 // CobraInit retruns a cobra command to configure this KafkaBridge
@@ -110,6 +123,7 @@ type msgContext struct {
 	timeReceived  time.Time
 	ctx           context.Context
 	producer      KafkaProducer
+	consumer      KafkaConsumer
 	requestCommon messages.RequestCommon
 	reqOffset     string
 	saramaMsg     *sarama.ConsumerMessage
@@ -169,6 +183,12 @@ func (k *KafkaBridge) addInflightMsg(msg *sarama.ConsumerMessage, producer Kafka
 		return
 	}
 	ctx.ctx = authCtx
+	if err = tx.ValidateReplyMode(headers); err != nil {
+		log.Errorf("Invalid reply mode: %s - Message=%+v", err, ctx.requestCommon)
+		// The error is replied on the reply topic
+		headers.ReplyMode, headers.ReplyWebhook = "", ""
+		return
+	}
 	if headers.ID == "" {
 		headers.ID = utils.UUIDv4()
 	}
@@ -278,6 +298,7 @@ func (c *msgContext) Reply(replyMessage messages.ReplyWithHeaders) {
 	c.replyType = replyHeaders.MsgType
 	replyHeaders.ID = utils.UUIDv4()
 	replyHeaders.Context = c.requestCommon.Headers.Context
+	replyHeaders.Namespace = c.requestCommon.Headers.Namespace
 	replyHeaders.ReqID = c.requestCommon.Headers.ID
 	replyHeaders.ReqABIID = c.requestCommon.Headers.ABIID
 	replyHeaders.ReqOffset = c.reqOffset
@@ -287,6 +308,18 @@ func (c *msgContext) Reply(replyMessage messages.ReplyWithHeaders) {
 	replyHeaders.Elapsed = c.replyTime.Sub(c.timeReceived).Seconds()
 	c.replyBytes, _ = json.Marshal(replyMessage)
 
+	headers := &c.requestCommon.Headers
+	if tx.ReplyToWebhook(headers) {
+		c.bridge.replySender.SendAsync(headers.ReplyWebhook, c.replyBytes)
+	}
+	if !tx.ReplyToKafka(headers) {
+		if c.bridge.localReplyHandler != nil {
+			c.replyLocal()
+			return
+		}
+		log.Warnf("No receipt store in this process for reply mode '%s'. Sending reply to topic: %s", headers.ReplyMode, c)
+	}
+
 	log.Infof("Sending reply: %s", c)
 	topic := c.bridge.kafka.Conf().TopicOut
 	var input chan<- *sarama.ProducerMessage
@@ -307,6 +340,18 @@ func (c *msgContext) Reply(replyMessage messages.ReplyWithHeaders) {
 	}
 }
 
+// replyLocal records the reply in the receipt store of the co-located REST gateway, and
+// completes the message as a successful send to the reply topic would
+func (c *msgContext) replyLocal() {
+	log.Infof("Storing reply without sending to topic: %s", c)
+	c.bridge.localReplyHandler.ProcessReply(c.replyBytes)
+	k := c.bridge
+	k.inFlightCond.L.Lock()
+	defer k.inFlightCond.L.Unlock()
+	_ = k.setInFlightComplete(c, c.consumer)
+	k.inFlightCond.Broadcast()
+}
+
 func (c *msgContext) String() string {
 	retval := fmt.Sprintf("MsgContext[%s:%s reqOffset=%s complete=%t received=%s",
 		c.requestCommon.Headers.MsgType, c.requestCommon.Headers.ID,
@@ -336,6 +381,7 @@ func NewKafkaBridge(printYAML *bool) *KafkaBridge {
 		inFlight:     make(map[string]*msgContext),
 		inFlightCond: sync.NewCond(&sync.Mutex{}),
 	}
+	k.replySender = tx.NewReplyWebhookSender(&k.conf.ReplyWebhook)
 	k.processor = tx.NewTxnProcessor(&k.conf.TxnProcessorConf, &k.conf.RPCConf)
 	k.kafka = NewKafkaCommon(&SaramaKafkaFactory{}, &k.conf.Kafka, k)
 	return k
@@ -356,6 +402,9 @@ func (k *KafkaBridge) ConsumerMessagesLoop(consumer KafkaConsumer, producer Kafk
 		// addInflightMsg always adds the message, even if it cannot
 		// be parsed
 		msgCtx, err := k.addInflightMsg(msg, producer)
+		if msgCtx != nil {
+			msgCtx.consumer = consumer
+		}
 		// Unlock before any further processing
 		k.inFlightCond.L.Unlock()
 		if msgCtx == nil {
@@ -18,6 +18,9 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"sync"
 	"testing"
@@ -97,6 +100,9 @@ func (p *testKafkaMsgProcessor) OnMessage(msg tx.TxnContext) {
 
 func (p *testKafkaMsgProcessor) SetReceiptStoreForIdempotencyCheck(receiptStore receipts.ReceiptStorePersistence) {
 }
+func (p *testKafkaMsgProcessor) CancelQueued(msgID string) error { return nil }
+func (p *testKafkaMsgProcessor) SigningStats() *tx.SigningStats  { return nil }
+func (p *testKafkaMsgProcessor) Close()                          {}
 
 func TestNewKafkaBridge(t *testing.T) {
 	assert := assert.New(t)
@@ -611,3 +617,111 @@ func TestProducerSuccessLoopPanicsMsgNotInflight(t *testing.T) {
 	wg.Wait()
 
 }
+
+type testLocalReplyHandler struct {
+	replies chan []byte
+}
+
+func (h *testLocalReplyHandler) ProcessReply(msgBytes []byte) {
+	h.replies <- msgBytes
+}
+
+func sendReplyModeTestMsg(mockConsumer *MockKafkaConsumer, replyMode, replyWebhook string) {
+	msg1 := messages.RequestCommon{}
+	msg1.Headers.MsgType = "TestReplyMode"
+	msg1.Headers.ID = "request1"
+	msg1.Headers.ReplyMode = replyMode
+	msg1.Headers.ReplyWebhook = replyWebhook
+	msg1bytes, _ := json.Marshal(&msg1)
+	mockConsumer.MockMessages <- &sarama.ConsumerMessage{
+		Topic:     "in-topic",
+		Partition: 5,
+		Offset:    500,
+		Value:     msg1bytes,
+	}
+}
+
+func TestReplyModeNoneStoresLocally(t *testing.T) {
+	assert := assert.New(t)
+
+	k, processor, mockConsumer, mockProducer, wg := setupMocks(true)
+	handler := &testLocalReplyHandler{replies: make(chan []byte, 1)}
+	k.SetLocalReplyHandler(handler)
+
+	sendReplyModeTestMsg(mockConsumer, messages.ReplyModeNone, "")
+	msgContext1 := <-processor.messages
+	reply1 := messages.ReplyCommon{}
+	reply1.Headers.MsgType = "TestReply"
+	msgContext1.Reply(&reply1)
+
+	var replySent messages.ReplyCommon
+	err := json.Unmarshal(<-handler.replies, &replySent)
+	assert.NoError(err)
+	assert.Equal("request1", replySent.Headers.ReqID)
+	assert.Equal("in-topic:5:500", replySent.Headers.ReqOffset)
+
+	// Nothing was sent to the reply topic, but the offset is still committed
+	assert.Empty(mockProducer.MockInput)
+	k.inFlightCond.L.Lock()
+	assert.Empty(k.inFlight)
+	assert.Equal(int64(500), mockConsumer.OffsetsByPartition[5])
+	k.inFlightCond.L.Unlock()
+
+	mockProducer.AsyncClose()
+	mockConsumer.Close()
+	wg.Wait()
+}
+
+func TestReplyModeWebhookWithoutLocalHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	received := make(chan []byte, 1)
+	svr := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		received <- b
+	}))
+	defer svr.Close()
+
+	k, processor, mockConsumer, mockProducer, wg := setupMocks(true)
+	k.conf.ReplyWebhook.AllowPrivateIPs = true
+
+	sendReplyModeTestMsg(mockConsumer, messages.ReplyModeWebhook, svr.URL)
+	msgContext1 := <-processor.messages
+	go func() {
+		reply1 := messages.ReplyCommon{}
+		reply1.Headers.MsgType = "TestReply"
+		msgContext1.Reply(&reply1)
+	}()
+
+	// With no receipt store in the process, the reply still goes to the reply topic
+	replyKafkaMsg := <-mockProducer.MockInput
+	mockProducer.MockSuccesses <- replyKafkaMsg
+	replyBytes, _ := replyKafkaMsg.Value.Encode()
+	assert.Equal(replyBytes, <-received)
+
+	mockProducer.AsyncClose()
+	mockConsumer.Close()
+	wg.Wait()
+}
+
+func TestReplyModeInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	k, _, mockConsumer, mockProducer, wg := setupMocks(true)
+	k.SetLocalReplyHandler(&testLocalReplyHandler{replies: make(chan []byte, 1)})
+
+	sendReplyModeTestMsg(mockConsumer, messages.ReplyModeWebhook, "")
+
+	// The error is sent to the reply topic
+	replyKafkaMsg := <-mockProducer.MockInput
+	mockProducer.MockSuccesses <- replyKafkaMsg
+	replyBytes, _ := replyKafkaMsg.Value.Encode()
+	var errorReply messages.ErrorReply
+	err := json.Unmarshal(replyBytes, &errorReply)
+	assert.NoError(err)
+	assert.Equal("FFEC100381", errorReply.ErrorCode)
+
+	mockProducer.AsyncClose()
+	mockConsumer.Close()
+	wg.Wait()
+}
@@ -15,7 +15,6 @@
 package kafka
 
 import (
-	"crypto/tls"
 	"os"
 	"os/signal"
 	"strconv"
@@ -52,7 +51,8 @@ type KafkaCommonConf struct {
 		Username string
 		Password string
 	} `json:"sasl"`
-	TLS utils.TLSConfig `json:"tls"`
+	TLS         utils.TLSConfig `json:"tls"`
+	RetryBuffer RetryBufferConf `json:"retryBuffer,omitempty"`
 
 	// Computed
 	sendRetryDelay time.Duration
@@ -124,6 +124,11 @@ func KafkaValidateConf(kconf *KafkaCommonConf) (err error) {
 		err = errors.Errorf(errors.ConfigKafkaMissingBadSASL)
 		return
 	}
+	switch kconf.RetryBuffer.FullPolicy {
+	case "", RetryBufferDrop, RetryBufferPark:
+	default:
+		return errors.Errorf(errors.ConfigKafkaRetryBufferPolicy, kconf.RetryBuffer.FullPolicy)
+	}
 	return
 }
 
@@ -196,19 +201,11 @@ func (k *kafkaCommon) connect() (err error) {
 	}
 
 	sarama.Logger = k.saramaLogger
-	clientConf := sarama.NewConfig()
-
-	var tlsConfig *tls.Config
-	if tlsConfig, err = utils.CreateTLSConfiguration(&k.conf.TLS); err != nil {
+	var clientConf *sarama.Config
+	if clientConf, err = NewSaramaConfig(k.conf.ClientID, &k.conf.TLS, k.conf.SASL.Username, k.conf.SASL.Password); err != nil {
 		return
 	}
 
-	if k.conf.SASL.Username != "" && k.conf.SASL.Password != "" {
-		clientConf.Net.SASL.Enable = true
-		clientConf.Net.SASL.User = k.conf.SASL.Username
-		clientConf.Net.SASL.Password = k.conf.SASL.Password
-	}
-
 	clientConf.Consumer.Fetch.Default = getFetchDefault()
 
 	clientConf.Producer.Return.Successes = true
@@ -219,13 +216,6 @@ func (k *kafkaCommon) connect() (err error) {
 	clientConf.Producer.Flush.Bytes = k.conf.ProducerFlush.Bytes
 	clientConf.Metadata.Retry.Backoff = 2 * time.Second
 	clientConf.Consumer.Return.Errors = true
-	clientConf.Version = sarama.V2_0_0_0
-	clientConf.Net.TLS.Enable = (tlsConfig != nil)
-	clientConf.Net.TLS.Config = tlsConfig
-	clientConf.ClientID = k.conf.ClientID
-	if clientConf.ClientID == "" {
-		clientConf.ClientID = utils.UUIDv4()
-	}
 	log.Debugf("Kafka ClientID: %s", clientConf.ClientID)
 
 	if k.client, err = k.factory.NewClient(k, clientConf); err != nil {
@@ -241,12 +231,41 @@ func (k *kafkaCommon) connect() (err error) {
 	return
 }
 
+// NewSaramaConfig creates the configuration of a client that connects to the brokers with TLS
+// and SASL/PLAIN authentication, as configured for the bridge, with a generated ID if none is set
+func NewSaramaConfig(clientID string, tlsConf *utils.TLSConfig, saslUsername, saslPassword string) (*sarama.Config, error) {
+	tlsConfig, err := utils.CreateTLSConfiguration(tlsConf)
+	if err != nil {
+		return nil, err
+	}
+	clientConf := sarama.NewConfig()
+	clientConf.Version = sarama.V2_0_0_0
+	clientConf.Net.TLS.Enable = (tlsConfig != nil)
+	clientConf.Net.TLS.Config = tlsConfig
+	if saslUsername != "" && saslPassword != "" {
+		clientConf.Net.SASL.Enable = true
+		clientConf.Net.SASL.User = saslUsername
+		clientConf.Net.SASL.Password = saslPassword
+	}
+	clientConf.ClientID = clientID
+	if clientConf.ClientID == "" {
+		clientConf.ClientID = utils.UUIDv4()
+	}
+	return clientConf, nil
+}
+
 func (k *kafkaCommon) createProducer() (err error) {
 	log.Debugf("Kafka Producer Topic=%s", k.conf.TopicOut)
 	if k.producer, err = k.client.NewProducer(k); err != nil {
 		log.Errorf("Failed to create Kafka producer: %s", err)
 		return
 	}
+	if k.conf.RetryBuffer.MaxMessages > 0 {
+		if k.producer, err = newRetryBufferProducer(k.producer, &k.conf.RetryBuffer, k.conf.sendRetryDelay); err != nil {
+			log.Errorf("Failed to create Kafka retry buffer: %s", err)
+			return
+		}
+	}
 	return
 }
 
@@ -0,0 +1,364 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/kvstore"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// RetryBufferDrop fails messages back to the sender once the buffer is full
+	RetryBufferDrop = "drop"
+	// RetryBufferPark keeps buffering the messages already in flight once the buffer is full,
+	// but refuses new messages until it drains
+	RetryBufferPark = "park"
+)
+
+// RetryBufferConf configures the buffering of messages that fail to send while the
+// brokers are unavailable, so they are resent when the producer reconnects rather
+// than being failed back to the sender. Buffering is enabled by setting maxMessages.
+type RetryBufferConf struct {
+	MaxMessages int    `json:"maxMessages"`
+	Path        string `json:"path,omitempty"`
+	FullPolicy  string `json:"fullPolicy,omitempty"`
+}
+
+// nonRetriableErrors are failures of the message itself, which resending will not fix
+var nonRetriableErrors = map[error]bool{
+	sarama.ErrMessageSizeTooLarge:        true,
+	sarama.ErrMessageTooLarge:            true,
+	sarama.ErrInvalidMessage:             true,
+	sarama.ErrInvalidMessageSize:         true,
+	sarama.ErrTopicAuthorizationFailed:   true,
+	sarama.ErrClusterAuthorizationFailed: true,
+}
+
+// bufferedMessage is a message waiting to be resent, in the form it is persisted
+type bufferedMessage struct {
+	Seq      uint64                `json:"seq"`
+	Topic    string                `json:"topic"`
+	Key      []byte                `json:"key,omitempty"`
+	Value    []byte                `json:"value,omitempty"`
+	Headers  []sarama.RecordHeader `json:"headers,omitempty"`
+	Metadata interface{}           `json:"metadata,omitempty"`
+
+	// recovered messages were buffered before a restart, so there is no sender waiting for them
+	recovered bool
+	inFlight  bool
+}
+
+func (m *bufferedMessage) storeKey() string {
+	return fmt.Sprintf("%020d", m.Seq)
+}
+
+// retryBufferProducer wraps the producer, intercepting the errors for messages that could
+// not be delivered because of a broker or network failure, and resending them once the
+// brokers are available again. While disconnected it probes with the oldest message every
+// retry interval, and resends all the others once that succeeds.
+type retryBufferProducer struct {
+	inner      KafkaProducer
+	conf       *RetryBufferConf
+	retryDelay time.Duration
+	store      kvstore.KVStore
+	successes  chan *sarama.ProducerMessage
+	errors     chan *sarama.ProducerError
+	mux        sync.Mutex
+	buffer     []*bufferedMessage
+	nextSeq    uint64
+	connected  bool
+	wake       chan struct{}
+	closing    chan struct{}
+	retryDone  chan struct{}
+	forwardWG  sync.WaitGroup
+}
+
+func newRetryBufferProducer(inner KafkaProducer, conf *RetryBufferConf, retryDelay time.Duration) (*retryBufferProducer, error) {
+	p := &retryBufferProducer{
+		inner:      inner,
+		conf:       conf,
+		retryDelay: retryDelay,
+		successes:  make(chan *sarama.ProducerMessage),
+		errors:     make(chan *sarama.ProducerError),
+		connected:  true,
+		wake:       make(chan struct{}, 1),
+		closing:    make(chan struct{}),
+		retryDone:  make(chan struct{}),
+	}
+	if conf.Path != "" {
+		var err error
+		if p.store, err = kvstore.NewLDBKeyValueStore(conf.Path); err != nil {
+			return nil, err
+		}
+		p.recover()
+	}
+	p.forwardWG.Add(2)
+	go p.successLoop()
+	go p.errorLoop()
+	go p.retryLoop()
+	return p, nil
+}
+
+// recover loads the messages that were still buffered when we last stopped
+func (p *retryBufferProducer) recover() {
+	it := p.store.NewIterator()
+	defer it.Release()
+	for it.Next() {
+		var m bufferedMessage
+		if err := it.ValueJSON(&m); err != nil {
+			log.Errorf("Discarding invalid buffered Kafka message %s: %s", it.Key(), err)
+			_ = p.store.Delete(it.Key())
+			continue
+		}
+		m.recovered = true
+		p.buffer = append(p.buffer, &m)
+		if m.Seq >= p.nextSeq {
+			p.nextSeq = m.Seq + 1
+		}
+	}
+	if len(p.buffer) > 0 {
+		log.Infof("Resending %d Kafka messages buffered before restart", len(p.buffer))
+		p.connected = false
+	}
+}
+
+func (p *retryBufferProducer) AsyncClose() {
+	close(p.closing)
+	<-p.retryDone
+	p.inner.AsyncClose()
+	go func() {
+		p.forwardWG.Wait()
+		if remaining := p.bufferLen(); remaining > 0 && p.store == nil {
+			log.Warnf("%d buffered Kafka messages were not resent before shutdown", remaining)
+		}
+		if p.store != nil {
+			p.store.Close()
+		}
+		close(p.successes)
+		close(p.errors)
+	}()
+}
+
+// Input refuses new messages when the buffer is full and the policy is to park them, so
+// the sender waits (or fails its request) until the brokers are back
+func (p *retryBufferProducer) Input(topic string) (chan<- *sarama.ProducerMessage, error) {
+	if p.conf.FullPolicy == RetryBufferPark {
+		if buffered := p.bufferLen(); buffered >= p.conf.MaxMessages {
+			return nil, errors.Errorf(errors.KafkaRetryBufferFull, buffered)
+		}
+	}
+	return p.inner.Input(topic)
+}
+
+func (p *retryBufferProducer) bufferLen() int {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return len(p.buffer)
+}
+
+func (p *retryBufferProducer) Successes() <-chan *sarama.ProducerMessage {
+	return p.successes
+}
+
+func (p *retryBufferProducer) Errors() <-chan *sarama.ProducerError {
+	return p.errors
+}
+
+func (p *retryBufferProducer) notifyRetry() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *retryBufferProducer) successLoop() {
+	defer p.forwardWG.Done()
+	for msg := range p.inner.Successes() {
+		m, resent := msg.Metadata.(*bufferedMessage)
+		if !resent {
+			p.successes <- msg
+			continue
+		}
+		p.remove(m)
+		p.mux.Lock()
+		if !p.connected {
+			log.Infof("Kafka producer reconnected. Resending %d buffered messages", len(p.buffer))
+			p.connected = true
+		}
+		p.mux.Unlock()
+		p.notifyRetry()
+		if m.recovered {
+			log.Infof("Resent Kafka message buffered before restart to %s:%d:%d", msg.Topic, msg.Partition, msg.Offset)
+			continue
+		}
+		msg.Metadata = m.Metadata
+		p.successes <- msg
+	}
+}
+
+func (p *retryBufferProducer) errorLoop() {
+	defer p.forwardWG.Done()
+	for pErr := range p.inner.Errors() {
+		if m, resent := pErr.Msg.Metadata.(*bufferedMessage); resent {
+			if p.isRetriable(pErr.Err) {
+				p.mux.Lock()
+				m.inFlight = false
+				p.connected = false
+				p.mux.Unlock()
+				log.Debugf("Resend of buffered Kafka message %d failed: %s", m.Seq, pErr.Err)
+				continue
+			}
+			p.remove(m)
+			if m.recovered {
+				log.Errorf("Dropped Kafka message buffered before restart: %s", pErr.Err)
+				continue
+			}
+			pErr.Msg.Metadata = m.Metadata
+			p.errors <- pErr
+			continue
+		}
+		if !p.isRetriable(pErr.Err) || !p.add(pErr.Msg, pErr.Err) {
+			p.errors <- pErr
+		}
+	}
+}
+
+func (p *retryBufferProducer) isRetriable(err error) bool {
+	return !nonRetriableErrors[err]
+}
+
+// add buffers a message that failed to send, unless the buffer is full and the policy
+// is to drop it
+func (p *retryBufferProducer) add(msg *sarama.ProducerMessage, sendErr error) bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if len(p.buffer) >= p.conf.MaxMessages && p.conf.FullPolicy != RetryBufferPark {
+		log.Errorf("Kafka retry buffer is full with %d messages. Failing message: %s", len(p.buffer), sendErr)
+		return false
+	}
+	m := &bufferedMessage{
+		Seq:      p.nextSeq,
+		Topic:    msg.Topic,
+		Headers:  msg.Headers,
+		Metadata: msg.Metadata,
+	}
+	p.nextSeq++
+	var err error
+	if msg.Key != nil {
+		if m.Key, err = msg.Key.Encode(); err != nil {
+			return false
+		}
+	}
+	if msg.Value != nil {
+		if m.Value, err = msg.Value.Encode(); err != nil {
+			return false
+		}
+	}
+	if p.store != nil {
+		if err := p.store.PutJSON(m.storeKey(), m); err != nil {
+			log.Errorf("Failed to persist buffered Kafka message: %s", err)
+		}
+	}
+	if p.connected {
+		log.Warnf("Kafka producer disconnected (%s). Buffering messages to resend every %s", sendErr, p.retryDelay)
+		p.connected = false
+	}
+	p.buffer = append(p.buffer, m)
+	return true
+}
+
+func (p *retryBufferProducer) remove(m *bufferedMessage) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	for i, b := range p.buffer {
+		if b == m {
+			p.buffer = append(p.buffer[:i], p.buffer[i+1:]...)
+			break
+		}
+	}
+	if p.store != nil {
+		_ = p.store.Delete(m.storeKey())
+	}
+}
+
+// nextToSend returns all the buffered messages while connected, or the oldest one as a
+// probe if we are not and no probe is in flight
+func (p *retryBufferProducer) nextToSend() []*bufferedMessage {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	toSend := []*bufferedMessage{}
+	for _, m := range p.buffer {
+		if m.inFlight {
+			if !p.connected {
+				return nil
+			}
+			continue
+		}
+		m.inFlight = true
+		toSend = append(toSend, m)
+		if !p.connected {
+			break
+		}
+	}
+	return toSend
+}
+
+func (p *retryBufferProducer) retryLoop() {
+	defer close(p.retryDone)
+	for {
+		select {
+		case <-p.closing:
+			return
+		case <-p.wake:
+		case <-time.After(p.retryDelay):
+		}
+		for _, m := range p.nextToSend() {
+			input, err := p.inner.Input(m.Topic)
+			if err == nil {
+				select {
+				case input <- m.producerMessage():
+					continue
+				case <-p.closing:
+					return
+				}
+			}
+			log.Debugf("Unable to resend buffered Kafka message %d: %s", m.Seq, err)
+			p.mux.Lock()
+			m.inFlight = false
+			p.mux.Unlock()
+		}
+	}
+}
+
+func (m *bufferedMessage) producerMessage() *sarama.ProducerMessage {
+	msg := &sarama.ProducerMessage{
+		Topic:    m.Topic,
+		Headers:  m.Headers,
+		Metadata: m,
+	}
+	if m.Key != nil {
+		msg.Key = sarama.ByteEncoder(m.Key)
+	}
+	if m.Value != nil {
+		msg.Value = sarama.ByteEncoder(m.Value)
+	}
+	return msg
+}
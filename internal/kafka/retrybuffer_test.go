@@ -0,0 +1,190 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRetryBufferProducer(t *testing.T, conf *RetryBufferConf) (*retryBufferProducer, *MockKafkaProducer) {
+	inner := &MockKafkaProducer{
+		MockInput:     make(chan *sarama.ProducerMessage),
+		MockSuccesses: make(chan *sarama.ProducerMessage),
+		MockErrors:    make(chan *sarama.ProducerError),
+	}
+	p, err := newRetryBufferProducer(inner, conf, 10*time.Millisecond)
+	assert.NoError(t, err)
+	return p, inner
+}
+
+func TestRetryBufferResendsAfterReconnect(t *testing.T) {
+	assert := assert.New(t)
+	p, inner := newTestRetryBufferProducer(t, &RetryBufferConf{MaxMessages: 10})
+
+	// Two messages fail while the broker is down
+	for _, id := range []string{"msg1", "msg2"} {
+		inner.MockErrors <- &sarama.ProducerError{
+			Msg: &sarama.ProducerMessage{Topic: "out", Key: sarama.StringEncoder("key"), Value: sarama.StringEncoder(id), Metadata: id},
+			Err: sarama.ErrOutOfBrokers,
+		}
+	}
+
+	// The oldest is resent alone as a probe, and fails again
+	probe := <-inner.MockInput
+	assert.Equal("out", probe.Topic)
+	v, _ := probe.Value.Encode()
+	assert.Equal("msg1", string(v))
+	inner.MockErrors <- &sarama.ProducerError{Msg: probe, Err: sarama.ErrNotConnected}
+
+	// The next probe succeeds, and the original metadata is restored for the sender
+	probe = <-inner.MockInput
+	inner.MockSuccesses <- probe
+	success := <-p.Successes()
+	assert.Equal("msg1", success.Metadata)
+	k, _ := success.Key.Encode()
+	assert.Equal("key", string(k))
+
+	// Then the rest are resent straight away
+	resent := <-inner.MockInput
+	v, _ = resent.Value.Encode()
+	assert.Equal("msg2", string(v))
+	inner.MockSuccesses <- resent
+	success = <-p.Successes()
+	assert.Equal("msg2", success.Metadata)
+
+	// Messages that were never buffered pass straight through
+	input, err := p.Input("out")
+	assert.NoError(err)
+	go func() { input <- &sarama.ProducerMessage{Topic: "out", Metadata: "msg3"} }()
+	inner.MockSuccesses <- <-inner.MockInput
+	success = <-p.Successes()
+	assert.Equal("msg3", success.Metadata)
+
+	p.AsyncClose()
+	_, open := <-p.Successes()
+	assert.False(open)
+	_, open = <-p.Errors()
+	assert.False(open)
+}
+
+func TestRetryBufferNonRetriableErrors(t *testing.T) {
+	assert := assert.New(t)
+	p, inner := newTestRetryBufferProducer(t, &RetryBufferConf{MaxMessages: 10})
+	defer p.AsyncClose()
+
+	inner.MockErrors <- &sarama.ProducerError{Msg: &sarama.ProducerMessage{Metadata: "msg1"}, Err: sarama.ErrMessageSizeTooLarge}
+	pErr := <-p.Errors()
+	assert.Equal("msg1", pErr.Msg.Metadata)
+
+	// A resend that fails with a non-retriable error is reported to the sender
+	inner.MockErrors <- &sarama.ProducerError{Msg: &sarama.ProducerMessage{Topic: "out", Metadata: "msg2"}, Err: sarama.ErrOutOfBrokers}
+	probe := <-inner.MockInput
+	inner.MockErrors <- &sarama.ProducerError{Msg: probe, Err: sarama.ErrTopicAuthorizationFailed}
+	pErr = <-p.Errors()
+	assert.Equal("msg2", pErr.Msg.Metadata)
+	assert.Equal(sarama.ErrTopicAuthorizationFailed, pErr.Err)
+}
+
+func TestRetryBufferFullDrop(t *testing.T) {
+	assert := assert.New(t)
+	p, inner := newTestRetryBufferProducer(t, &RetryBufferConf{MaxMessages: 1, FullPolicy: RetryBufferDrop})
+	defer p.AsyncClose()
+
+	inner.MockErrors <- &sarama.ProducerError{Msg: &sarama.ProducerMessage{Topic: "out", Metadata: "msg1"}, Err: sarama.ErrOutOfBrokers}
+	inner.MockErrors <- &sarama.ProducerError{Msg: &sarama.ProducerMessage{Topic: "out", Metadata: "msg2"}, Err: sarama.ErrOutOfBrokers}
+	pErr := <-p.Errors()
+	assert.Equal("msg2", pErr.Msg.Metadata)
+
+	// New messages are still accepted, to try the brokers
+	_, err := p.Input("out")
+	assert.NoError(err)
+}
+
+func TestRetryBufferFullPark(t *testing.T) {
+	assert := assert.New(t)
+	p, inner := newTestRetryBufferProducer(t, &RetryBufferConf{MaxMessages: 1, FullPolicy: RetryBufferPark})
+	defer p.AsyncClose()
+
+	// Messages already in flight are still buffered beyond the limit
+	inner.MockErrors <- &sarama.ProducerError{Msg: &sarama.ProducerMessage{Topic: "out", Metadata: "msg1"}, Err: sarama.ErrOutOfBrokers}
+	inner.MockErrors <- &sarama.ProducerError{Msg: &sarama.ProducerMessage{Topic: "out", Metadata: "msg2"}, Err: sarama.ErrOutOfBrokers}
+
+	// But new messages are refused until the buffer drains
+	assert.Eventually(func() bool {
+		_, err := p.Input("out")
+		return err != nil && assert.Regexp("FFEC100376", err) && p.bufferLen() == 2
+	}, time.Second, time.Millisecond)
+
+	for _, id := range []string{"msg1", "msg2"} {
+		resent := <-inner.MockInput
+		inner.MockSuccesses <- resent
+		success := <-p.Successes()
+		assert.Equal(id, success.Metadata)
+	}
+	_, err := p.Input("out")
+	assert.NoError(err)
+}
+
+func TestRetryBufferPersistedAcrossRestart(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	p, inner := newTestRetryBufferProducer(t, &RetryBufferConf{MaxMessages: 10, Path: dir})
+	inner.MockErrors <- &sarama.ProducerError{
+		Msg: &sarama.ProducerMessage{Topic: "out", Value: sarama.StringEncoder("reply1"), Metadata: "msg1"},
+		Err: sarama.ErrOutOfBrokers,
+	}
+	p.AsyncClose()
+	for range p.Successes() {
+	}
+
+	// The message is resent after restart, but there is no sender to report the success to
+	p, inner = newTestRetryBufferProducer(t, &RetryBufferConf{MaxMessages: 10, Path: dir})
+	resent := <-inner.MockInput
+	v, _ := resent.Value.Encode()
+	assert.Equal("reply1", string(v))
+	inner.MockSuccesses <- resent
+	inner.MockSuccesses <- &sarama.ProducerMessage{Metadata: "msg2"}
+	success := <-p.Successes()
+	assert.Equal("msg2", success.Metadata)
+	p.AsyncClose()
+	for range p.Successes() {
+	}
+
+	// Nothing is left to resend
+	p, _ = newTestRetryBufferProducer(t, &RetryBufferConf{MaxMessages: 10, Path: dir})
+	assert.Zero(p.bufferLen())
+	p.AsyncClose()
+}
+
+func TestRetryBufferBadPath(t *testing.T) {
+	dir := t.TempDir()
+	_, err := newRetryBufferProducer(&MockKafkaProducer{}, &RetryBufferConf{MaxMessages: 10, Path: dir + "/\x00"}, time.Second)
+	assert.Error(t, err)
+}
+
+func TestKafkaCommonRetryBufferPolicy(t *testing.T) {
+	assert := assert.New(t)
+	conf := &KafkaCommonConf{TopicIn: "in", TopicOut: "out", ConsumerGroup: "cg"}
+	conf.RetryBuffer.FullPolicy = "unknown"
+	err := KafkaValidateConf(conf)
+	assert.Regexp("FFEC100377.*unknown", err)
+	conf.RetryBuffer.FullPolicy = RetryBufferPark
+	assert.NoError(KafkaValidateConf(conf))
+}
@@ -18,6 +18,7 @@ import (
 	"encoding/json"
 
 	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
 	log "github.com/sirupsen/logrus"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/iterator"
@@ -54,19 +55,25 @@ type KVStore interface {
 }
 
 type levelDBKeyValueStore struct {
-	path string
-	db   *leveldb.DB
+	path    string
+	db      *leveldb.DB
+	metrics *utils.StoreMetrics
 }
 
-func (k *levelDBKeyValueStore) warnIfErr(op, key string, err error) {
+// warnIfErr also records the outcome of the operation, where a missing key is not an error
+func (k *levelDBKeyValueStore) warnIfErr(op, key string, err error, done func(error)) {
 	if err != nil && err != leveldb.ErrNotFound {
 		log.Warnf("LDB %s %s '%s' failed: %s", k.path, op, key, err)
+		done(err)
+		return
 	}
+	done(nil)
 }
 
 func (k *levelDBKeyValueStore) Put(key string, val []byte) error {
+	done := k.metrics.Start("Put")
 	err := k.db.Put([]byte(key), val, nil)
-	k.warnIfErr("Put", key, err)
+	k.warnIfErr("Put", key, err, done)
 	return err
 }
 
@@ -79,14 +86,16 @@ func (k *levelDBKeyValueStore) PutJSON(key string, obj interface{}) error {
 }
 
 func (k *levelDBKeyValueStore) Get(key string) ([]byte, error) {
+	done := k.metrics.Start("Get")
 	b, err := k.db.Get([]byte(key), nil)
-	k.warnIfErr("Get", key, err)
+	k.warnIfErr("Get", key, err, done)
 	return b, err
 }
 
 func (k *levelDBKeyValueStore) GetJSON(key string, obj interface{}) error {
+	done := k.metrics.Start("Get")
 	b, err := k.db.Get([]byte(key), nil)
-	k.warnIfErr("Get", key, err)
+	k.warnIfErr("Get", key, err, done)
 	if err != nil {
 		return err
 	}
@@ -98,8 +107,9 @@ func (k *levelDBKeyValueStore) GetJSON(key string, obj interface{}) error {
 }
 
 func (k *levelDBKeyValueStore) Delete(key string) error {
+	done := k.metrics.Start("Delete")
 	err := k.db.Delete([]byte(key), nil)
-	k.warnIfErr("Delete", key, err)
+	k.warnIfErr("Delete", key, err, done)
 	return err
 }
 
@@ -157,6 +167,7 @@ func (k *levelDBKeyIterator) Release() {
 }
 
 func (k *levelDBKeyValueStore) Close() {
+	k.metrics.Close()
 	k.db.Close()
 }
 
@@ -168,6 +179,7 @@ func NewLDBKeyValueStore(ldbPath string) (kv KVStore, err error) {
 	if store.db, err = leveldb.OpenFile(ldbPath, nil); err != nil {
 		return nil, errors.Errorf(errors.KVStoreDBLoad, ldbPath, err)
 	}
+	store.metrics = utils.NewStoreMetrics("leveldb:" + ldbPath)
 	kv = store
 	return
 }
@@ -21,6 +21,7 @@ import (
 	"path"
 	"testing"
 
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -146,6 +147,29 @@ func TestLevelDBBadPath(t *testing.T) {
 }
 
 func TestLevelDBWarnIfError(t *testing.T) {
-	db := &levelDBKeyValueStore{}
-	db.warnIfErr("Put", "A Key", fmt.Errorf("pop"))
+	db := &levelDBKeyValueStore{metrics: utils.NewStoreMetrics("test")}
+	defer db.metrics.Close()
+	db.warnIfErr("Put", "A Key", fmt.Errorf("pop"), db.metrics.Start("Put"))
+	db.warnIfErr("Get", "A Key", ErrorNotFound, db.metrics.Start("Get"))
+	stats := db.metrics.Stats()
+	assert.Equal(t, int64(1), stats["Put"].Errors)
+	assert.Equal(t, int64(0), stats["Get"].Errors)
+}
+
+func TestLevelDBMetrics(t *testing.T) {
+	assert := assert.New(t)
+	dir := tempdir(t)
+	defer cleanup(t, dir)
+	dbPath := path.Join(dir, "db")
+	kv, err := NewLDBKeyValueStore(dbPath)
+	assert.NoError(err)
+	kv.Put("things", []byte("stuff"))
+	kv.Get("things")
+	kv.Get("missing")
+	stats := utils.AllStoreMetrics()["leveldb:"+dbPath]
+	assert.Equal(int64(1), stats["Put"].Count)
+	assert.Equal(int64(2), stats["Get"].Count)
+	assert.Equal(int64(0), stats["Get"].Errors)
+	kv.Close()
+	assert.NotContains(utils.AllStoreMetrics(), "leveldb:"+dbPath)
 }
@@ -29,6 +29,12 @@ const (
 	MsgTypeDeployContract = "DeployContract"
 	// MsgTypeSendTransaction - send a transaction
 	MsgTypeSendTransaction = "SendTransaction"
+	// MsgTypeSendRawTransaction - check and submit a transaction that was signed by the client
+	MsgTypeSendRawTransaction = "SendRawTransaction"
+	// MsgTypeCancelTransaction - replace a submitted transaction with a zero value transfer at the same nonce
+	MsgTypeCancelTransaction = "CancelTransaction"
+	// MsgTypeRelayTransaction - submit a meta-transaction signed by the user through the forwarder, paying gas from the relayer
+	MsgTypeRelayTransaction = "RelayTransaction"
 	// MsgTypeQuery - perform a call against the blockchain, and return a result
 	MsgTypeQuery = "Query"
 	// MsgTypeTransactionSuccess - a transaction receipt where status is 1
@@ -37,8 +43,18 @@ const (
 	MsgTypeTransactionFailure = "TransactionFailure"
 	// MsgTypeTransactionRedeliveryPrevented - idempotency check caught a redelivery of the message
 	MsgTypeTransactionRedeliveryPrevented = "TransactionRedeliveryPrevented"
+	// MsgTypeTransactionCancelled - the request was withdrawn before it was submitted to the node
+	MsgTypeTransactionCancelled = "TransactionCancelled"
 	// RecordHeaderAccessToken - record header name for passing JWT token over messaging
 	RecordHeaderAccessToken = "fly-accesstoken"
+	// ReplyModeKafka - the reply is sent to the Kafka reply topic (the default)
+	ReplyModeKafka = "kafka"
+	// ReplyModeWebhook - the reply is posted to the replyWebhook instead of the Kafka reply topic
+	ReplyModeWebhook = "webhook"
+	// ReplyModeBoth - the reply is sent to the Kafka reply topic, and posted to the replyWebhook
+	ReplyModeBoth = "both"
+	// ReplyModeNone - the reply is only recorded in the receipt store
+	ReplyModeNone = "none"
 )
 
 type WebhookReply interface {
@@ -68,11 +84,12 @@ func (sqr SyncQueryReply) RequestID() string {
 
 // CommonHeaders are common to all messages
 type CommonHeaders struct {
-	ID      string                 `json:"id,omitempty"`
-	ABIID   string                 `json:"abiId,omitempty"`
-	MsgType string                 `json:"type"`
-	Account string                 `json:"account,omitempty"`
-	Context map[string]interface{} `json:"ctx,omitempty"`
+	ID        string                 `json:"id,omitempty"`
+	ABIID     string                 `json:"abiId,omitempty"`
+	MsgType   string                 `json:"type"`
+	Account   string                 `json:"account,omitempty"`
+	Namespace string                 `json:"namespace,omitempty"`
+	Context   map[string]interface{} `json:"ctx,omitempty"`
 }
 
 // RequestCommon is a common interface to all requests
@@ -83,6 +100,8 @@ type RequestCommon struct {
 // RequestHeaders are common to all replies
 type RequestHeaders struct {
 	CommonHeaders
+	ReplyMode    string `json:"replyMode,omitempty"`
+	ReplyWebhook string `json:"replyWebhook,omitempty"`
 }
 
 // ReplyHeaders are common to all replies
@@ -147,12 +166,57 @@ type SendTransaction struct {
 	MethodName string                           `json:"methodName,omitempty"`
 }
 
+// CancelTransaction message replaces a transaction that is stuck waiting to be mined, by sending
+// a zero value transfer from the sender to itself at the same nonce. The gas price must be high
+// enough for the node to accept it as a replacement.
+type CancelTransaction struct {
+	TransactionCommon
+}
+
+// ForwardRequest is an EIP-2771 meta-transaction, that the user signs as EIP-712 typed data
+// for the forwarder contract
+type ForwardRequest struct {
+	From  string      `json:"from"`
+	To    string      `json:"to"`
+	Value json.Number `json:"value,omitempty"`
+	Gas   json.Number `json:"gas"`
+	Nonce json.Number `json:"nonce"`
+	Data  string      `json:"data,omitempty"`
+}
+
+// RelayTransaction submits a signed ForwardRequest to the configured forwarder. The transaction
+// is sent from the relayer, which pays the gas, unless a different from address is supplied.
+type RelayTransaction struct {
+	TransactionCommon
+	Request   ForwardRequest `json:"request"`
+	Signature string         `json:"signature"`
+}
+
 // QueryTransaction message performs a synchronous invocation call to the blockchain
 type QueryTransaction struct {
 	SendTransaction
 	BlockNumber string `json:"blockNumber,omitempty"`
 }
 
+// SendRawTransaction message submits a pre-signed transaction to the node, after checking it
+// is signed for the chain the node is on, and that its nonce has not already been used
+type SendRawTransaction struct {
+	RequestCommon
+	From           string `json:"from,omitempty"`
+	RawTransaction string `json:"rawTransaction"`
+}
+
+// RawTransactionSent is the synchronous response to a SendRawTransaction message
+type RawTransactionSent struct {
+	Sent            bool   `json:"sent"`
+	Request         string `json:"id"`
+	TransactionHash string `json:"transactionHash"`
+}
+
+func (rts *RawTransactionSent) RequestID() string {
+	return rts.Request
+}
+
 // DeployContract message instructs the bridge to install a contract
 type DeployContract struct {
 	TransactionCommon
@@ -161,10 +225,22 @@ type DeployContract struct {
 	EVMVersion      string                   `json:"evmVersion,omitempty"`
 	ABI             ethbinding.ABIMarshaling `json:"abi,omitempty"`
 	DevDoc          string                   `json:"devDocs,omitempty"`
+	UserDoc         string                   `json:"userDocs,omitempty"`
 	Compiled        []byte                   `json:"compiled,omitempty"`
 	ContractName    string                   `json:"contractName,omitempty"`
 	Description     string                   `json:"description,omitempty"`
 	RegisterAs      string                   `json:"registerAs,omitempty"`
+	Labels          map[string]string        `json:"labels,omitempty"`
+	LinkReferences  []*LinkReference         `json:"linkReferences,omitempty"`
+	Libraries       map[string]string        `json:"libraries,omitempty"`
+}
+
+// LinkReference records where the compiled bytecode of a contract refers to a library that
+// is deployed separately. The address of the library is written at each of the byte offsets
+// when the contract is deployed.
+type LinkReference struct {
+	Library string `json:"library"`
+	Offsets []int  `json:"offsets"`
 }
 
 // TransactionReceipt is sent when a transaction has been successfully mined
@@ -172,26 +248,46 @@ type DeployContract struct {
 // ethereum hex encoding version
 type TransactionReceipt struct {
 	ReplyCommon
-	BlockHash            *ethbinding.Hash      `json:"blockHash"`
-	BlockNumberStr       string                `json:"blockNumber"`
-	BlockNumberHex       *ethbinding.HexBigInt `json:"blockNumberHex,omitempty"`
-	ContractSwagger      string                `json:"openapi,omitempty"`
-	ContractUI           string                `json:"apiexerciser,omitempty"`
-	ContractAddress      *ethbinding.Address   `json:"contractAddress,omitempty"`
-	CumulativeGasUsedStr string                `json:"cumulativeGasUsed"`
-	CumulativeGasUsedHex *ethbinding.HexBigInt `json:"cumulativeGasUsedHex,omitempty"`
-	From                 *ethbinding.Address   `json:"from"`
-	GasUsedStr           string                `json:"gasUsed"`
-	GasUsedHex           *ethbinding.HexBigInt `json:"gasUsedHex,omitempty"`
-	NonceStr             string                `json:"nonce"`
-	NonceHex             *ethbinding.HexUint64 `json:"nonceHex,omitempty"`
-	StatusStr            string                `json:"status"`
-	StatusHex            *ethbinding.HexBigInt `json:"statusHex,omitempty"`
-	To                   *ethbinding.Address   `json:"to"`
-	TransactionHash      *ethbinding.Hash      `json:"transactionHash"`
-	TransactionIndexStr  string                `json:"transactionIndex"`
-	TransactionIndexHex  *ethbinding.HexUint   `json:"transactionIndexHex,omitempty"`
-	RegisterAs           string                `json:"registerAs,omitempty"`
+	BlockHash            *ethbinding.Hash       `json:"blockHash"`
+	BlockNumberStr       string                 `json:"blockNumber"`
+	BlockNumberHex       *ethbinding.HexBigInt  `json:"blockNumberHex,omitempty"`
+	ContractSwagger      string                 `json:"openapi,omitempty"`
+	ContractUI           string                 `json:"apiexerciser,omitempty"`
+	ContractAddress      *ethbinding.Address    `json:"contractAddress,omitempty"`
+	CumulativeGasUsedStr string                 `json:"cumulativeGasUsed"`
+	CumulativeGasUsedHex *ethbinding.HexBigInt  `json:"cumulativeGasUsedHex,omitempty"`
+	From                 *ethbinding.Address    `json:"from"`
+	GasUsedStr           string                 `json:"gasUsed"`
+	GasUsedHex           *ethbinding.HexBigInt  `json:"gasUsedHex,omitempty"`
+	NonceStr             string                 `json:"nonce"`
+	NonceHex             *ethbinding.HexUint64  `json:"nonceHex,omitempty"`
+	StatusStr            string                 `json:"status"`
+	StatusHex            *ethbinding.HexBigInt  `json:"statusHex,omitempty"`
+	To                   *ethbinding.Address    `json:"to"`
+	TransactionHash      *ethbinding.Hash       `json:"transactionHash"`
+	TransactionIndexStr  string                 `json:"transactionIndex"`
+	TransactionIndexHex  *ethbinding.HexUint    `json:"transactionIndexHex,omitempty"`
+	RegisterAs           string                 `json:"registerAs,omitempty"`
+	Extensions           map[string]interface{} `json:"extensions,omitempty"`
+	Logs                 []*TransactionLog      `json:"logs,omitempty"`
+	Events               []*ReceiptEvent        `json:"events,omitempty"`
+}
+
+// TransactionLog is a raw log entry emitted by a transaction
+type TransactionLog struct {
+	Address     *ethbinding.Address `json:"address"`
+	Topics      []*ethbinding.Hash  `json:"topics"`
+	Data        string              `json:"data"`
+	LogIndexStr string              `json:"logIndex"`
+}
+
+// ReceiptEvent is a log entry from a transaction receipt, decoded using the ABI of the contract that emitted it
+type ReceiptEvent struct {
+	Address   *ethbinding.Address    `json:"address"`
+	Event     string                 `json:"event"`
+	Signature string                 `json:"signature"`
+	LogIndex  string                 `json:"logIndex"`
+	Data      map[string]interface{} `json:"data"`
 }
 
 // TransactionRedeliveryNotification is sent on redelivery of a message, when the ackmode=receipt
@@ -246,6 +342,9 @@ func NewErrorReply(err error, origMsg interface{}) *ErrorReply {
 		case errors.EthconnectError:
 			errMsg.ErrorMessage = err.ErrorNoCode()
 			errMsg.ErrorCode = err.Code()
+			if errMsg.ErrorCode == errors.TransactionSendCancelled.Code() {
+				errMsg.Headers.MsgType = MsgTypeTransactionCancelled
+			}
 		default:
 			errMsg.ErrorMessage = err.Error()
 		}
@@ -75,6 +75,12 @@ func TestNewErrorReplyFFEC(t *testing.T) {
 	assert.Equal(t, "Unauthorized", errReply.ErrorMessage)
 }
 
+func TestNewErrorReplyCancelled(t *testing.T) {
+	errReply := NewErrorReply(errors.Errorf(errors.TransactionSendCancelled), map[string]interface{}{})
+	assert.Equal(t, MsgTypeTransactionCancelled, errReply.Headers.MsgType)
+	assert.Equal(t, errors.TransactionSendCancelled.Code(), errReply.ErrorCode)
+}
+
 func TestNewErrorReplyNonFFEC(t *testing.T) {
 	errReply := NewErrorReply(fmt.Errorf("non FFEC error"), map[string]interface{}{})
 	assert.Empty(t, errReply.ErrorCode)
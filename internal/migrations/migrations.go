@@ -0,0 +1,352 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrations
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// StateFileName is the file in a data directory that records its migration version and history
+	StateFileName = "migrations.json"
+	// ActionApplied is recorded in the history when a migration is applied
+	ActionApplied = "applied"
+	// ActionRolledBack is recorded in the history when the data is restored from a backup
+	ActionRolledBack = "rolledBack"
+
+	backupTimeFormat = "20060102T150405.000Z"
+)
+
+// MigrationConf configures how the on-disk data of a component is upgraded at startup
+type MigrationConf struct {
+	DryRun     bool   `json:"dryRun,omitempty"`
+	Rollback   bool   `json:"rollback,omitempty"`
+	BackupPath string `json:"backupPath,omitempty"`
+}
+
+// Migration is one versioned upgrade of the data in a directory. Versions start at 1, are applied
+// in order, and must never be reused once released.
+type Migration struct {
+	Version     int
+	Description string
+	// Plan lists the changes the migration would make, without making them
+	Plan func() ([]string, error)
+	// Apply makes the changes, and lists what was changed
+	Apply func() ([]string, error)
+}
+
+// Record is an entry in the history kept in the state file, and in the report of a run
+type Record struct {
+	Version     int      `json:"version"`
+	Description string   `json:"description,omitempty"`
+	Action      string   `json:"action"`
+	Time        string   `json:"time"`
+	Backup      string   `json:"backup,omitempty"`
+	Changes     []string `json:"changes,omitempty"`
+}
+
+// Report describes the outcome of a run of the migrations for one data directory. For a dry-run
+// the migrations are the pending ones, with the changes they would make.
+type Report struct {
+	Name        string    `json:"name"`
+	FromVersion int       `json:"fromVersion"`
+	ToVersion   int       `json:"toVersion"`
+	DryRun      bool      `json:"dryRun,omitempty"`
+	Pending     int       `json:"pending"`
+	Backup      string    `json:"backup,omitempty"`
+	Migrations  []*Record `json:"migrations"`
+}
+
+type migrationState struct {
+	Version int       `json:"version"`
+	History []*Record `json:"history"`
+}
+
+// Migrator runs the migrations of one data directory
+type Migrator struct {
+	name       string
+	dataPath   string
+	backupPath string
+	conf       *MigrationConf
+	migrations []*Migration
+}
+
+// NewMigrator constructor. Backups are written to the configured backup path, or alongside the
+// data directory with a ".backups" suffix, so they are not themselves included in later backups.
+func NewMigrator(name, dataPath string, conf *MigrationConf, migrations ...*Migration) *Migrator {
+	backupPath := conf.BackupPath
+	if backupPath == "" {
+		backupPath = filepath.Clean(dataPath) + ".backups"
+	}
+	sorted := make([]*Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return &Migrator{
+		name:       name,
+		dataPath:   dataPath,
+		backupPath: backupPath,
+		conf:       conf,
+		migrations: sorted,
+	}
+}
+
+// Run applies the pending migrations around the opening of the data store. The data directory is
+// backed up before open is called, so the copy is consistent. If a migration fails, close is called
+// and the backup restored, so the data is left as it was before the upgrade.
+func (m *Migrator) Run(open func() error, close func()) (*Report, error) {
+	report := &Report{Name: m.name, DryRun: m.conf.DryRun, Migrations: []*Record{}}
+	if info, err := os.Stat(m.dataPath); err != nil || !info.IsDir() {
+		// Nothing has been stored yet, or the store will report the problem when it is opened
+		return report, open()
+	}
+
+	if m.conf.Rollback {
+		record, err := m.restoreLatest()
+		if err != nil {
+			return nil, err
+		}
+		report.FromVersion, report.ToVersion = record.Version, record.Version
+		report.Backup = record.Backup
+		report.Migrations = append(report.Migrations, record)
+		return report, open()
+	}
+
+	state, err := m.loadState()
+	if err != nil {
+		return nil, err
+	}
+	report.FromVersion, report.ToVersion = state.Version, state.Version
+	if latest := m.latestVersion(); state.Version > latest {
+		return nil, errors.Errorf(errors.MigrationVersionAhead, m.name, state.Version, latest)
+	}
+
+	pending := m.pending(state.Version)
+	report.Pending = len(pending)
+	if len(pending) == 0 {
+		return report, open()
+	}
+
+	planned := 0
+	for _, migration := range pending {
+		changes, err := migration.Plan()
+		if err != nil {
+			return nil, errors.Errorf(errors.MigrationFailed, migration.Version, migration.Description, m.name, err)
+		}
+		planned += len(changes)
+		if m.conf.DryRun {
+			log.Infof("Migration %d of %s is pending (dry-run): %s", migration.Version, m.name, migration.Description)
+			for _, change := range changes {
+				log.Infof("  %s", change)
+			}
+			report.Migrations = append(report.Migrations, &Record{
+				Version:     migration.Version,
+				Description: migration.Description,
+				Changes:     changes,
+			})
+		}
+	}
+	if m.conf.DryRun {
+		return report, open()
+	}
+
+	// A migration that will not change anything, such as on a new install, does not need a backup
+	if planned > 0 {
+		if report.Backup, err = m.backup(state.Version); err != nil {
+			return nil, err
+		}
+	}
+	if err = open(); err != nil {
+		return nil, err
+	}
+	for _, migration := range pending {
+		log.Infof("Applying migration %d of %s: %s", migration.Version, m.name, migration.Description)
+		changes, err := migration.Apply()
+		if err != nil {
+			close()
+			err = errors.Errorf(errors.MigrationFailed, migration.Version, migration.Description, m.name, err)
+			if report.Backup != "" {
+				if restoreErr := m.restore(report.Backup); restoreErr != nil {
+					log.Errorf("%s", restoreErr)
+				}
+			}
+			return nil, err
+		}
+		record := &Record{
+			Version:     migration.Version,
+			Description: migration.Description,
+			Action:      ActionApplied,
+			Time:        time.Now().UTC().Format(time.RFC3339),
+			Backup:      report.Backup,
+			Changes:     changes,
+		}
+		state.Version = migration.Version
+		state.History = append(state.History, record)
+		if err = m.writeState(state); err != nil {
+			log.Errorf("Failed to record migration %d of %s: %s", migration.Version, m.name, err)
+		}
+		report.Migrations = append(report.Migrations, record)
+		report.ToVersion = migration.Version
+	}
+	report.Pending = 0
+	log.Infof("Migrated %s from version %d to %d", m.name, report.FromVersion, report.ToVersion)
+	return report, nil
+}
+
+func (m *Migrator) latestVersion() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+func (m *Migrator) pending(version int) []*Migration {
+	var pending []*Migration
+	for _, migration := range m.migrations {
+		if migration.Version > version {
+			pending = append(pending, migration)
+		}
+	}
+	return pending
+}
+
+func (m *Migrator) loadState() (*migrationState, error) {
+	state := &migrationState{History: []*Record{}}
+	stateFile := filepath.Join(m.dataPath, StateFileName)
+	b, err := ioutil.ReadFile(stateFile)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(b, state)
+	}
+	if err != nil {
+		return nil, errors.Errorf(errors.MigrationStateInvalid, stateFile, err)
+	}
+	return state, nil
+}
+
+func (m *Migrator) writeState(state *migrationState) error {
+	b, _ := json.MarshalIndent(state, "", "  ")
+	return ioutil.WriteFile(filepath.Join(m.dataPath, StateFileName), b, 0644)
+}
+
+// backup copies the data directory to a new directory under the backup path, named so the
+// most recent sorts last
+func (m *Migrator) backup(version int) (string, error) {
+	backupDir := filepath.Join(m.backupPath, fmt.Sprintf("%s-v%d", time.Now().UTC().Format(backupTimeFormat), version))
+	if err := copyDir(m.dataPath, backupDir); err != nil {
+		return "", errors.Errorf(errors.MigrationBackupFailed, m.name, backupDir, err)
+	}
+	log.Infof("Backed up %s at version %d to '%s'", m.name, version, backupDir)
+	return backupDir, nil
+}
+
+// restoreLatest restores the most recent backup, and records the rollback in the restored history
+func (m *Migrator) restoreLatest() (*Record, error) {
+	entries, _ := ioutil.ReadDir(m.backupPath)
+	var latest string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			latest = entry.Name()
+		}
+	}
+	if latest == "" {
+		return nil, errors.Errorf(errors.MigrationNoBackup, m.name, m.backupPath)
+	}
+	backupDir := filepath.Join(m.backupPath, latest)
+
+	// Restarting with rollback still configured must not discard the data written since
+	state, err := m.loadState()
+	if err != nil {
+		return nil, err
+	}
+	if n := len(state.History); n > 0 && state.History[n-1].Action == ActionRolledBack && state.History[n-1].Backup == backupDir {
+		log.Warnf("%s has already been rolled back to '%s'", m.name, backupDir)
+		return state.History[n-1], nil
+	}
+
+	if err := m.restore(backupDir); err != nil {
+		return nil, err
+	}
+	if state, err = m.loadState(); err != nil {
+		return nil, err
+	}
+	record := &Record{
+		Version: state.Version,
+		Action:  ActionRolledBack,
+		Time:    time.Now().UTC().Format(time.RFC3339),
+		Backup:  backupDir,
+	}
+	state.History = append(state.History, record)
+	if err = m.writeState(state); err != nil {
+		return nil, errors.Errorf(errors.MigrationRestoreFailed, m.name, backupDir, err)
+	}
+	return record, nil
+}
+
+func (m *Migrator) restore(backupDir string) error {
+	log.Warnf("Restoring %s from backup '%s'", m.name, backupDir)
+	if err := os.RemoveAll(m.dataPath); err != nil {
+		return errors.Errorf(errors.MigrationRestoreFailed, m.name, backupDir, err)
+	}
+	if err := copyDir(backupDir, m.dataPath); err != nil {
+		return errors.Errorf(errors.MigrationRestoreFailed, m.name, backupDir, err)
+	}
+	return nil
+}
+
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, p)
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return copyFile(p, target, info.Mode().Perm())
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
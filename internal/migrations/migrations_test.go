@@ -0,0 +1,279 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrations
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestDataDir creates a data directory with a file, and a subdirectory like a LevelDB
+func newTestDataDir(t *testing.T) (string, string) {
+	root := t.TempDir()
+	dataPath := filepath.Join(root, "data")
+	err := os.MkdirAll(filepath.Join(dataPath, "db"), 0755)
+	assert.NoError(t, err)
+	ioutil.WriteFile(filepath.Join(dataPath, "file.txt"), []byte("v0"), 0644)
+	ioutil.WriteFile(filepath.Join(dataPath, "db", "000001.log"), []byte("log"), 0644)
+	return root, dataPath
+}
+
+// fileMigration rewrites file.txt to the version of the migration
+func fileMigration(dataPath string, version int) *Migration {
+	return &Migration{
+		Version:     version,
+		Description: fmt.Sprintf("Upgrade to v%d", version),
+		Plan: func() ([]string, error) {
+			return []string{fmt.Sprintf("rewrite file.txt as v%d", version)}, nil
+		},
+		Apply: func() ([]string, error) {
+			err := ioutil.WriteFile(filepath.Join(dataPath, "file.txt"), []byte(fmt.Sprintf("v%d", version)), 0644)
+			return []string{fmt.Sprintf("rewrote file.txt as v%d", version)}, err
+		},
+	}
+}
+
+func readFile(t *testing.T, p string) string {
+	b, err := ioutil.ReadFile(p)
+	assert.NoError(t, err)
+	return string(b)
+}
+
+func readState(t *testing.T, dataPath string) *migrationState {
+	var state migrationState
+	err := json.Unmarshal([]byte(readFile(t, filepath.Join(dataPath, StateFileName))), &state)
+	assert.NoError(t, err)
+	return &state
+}
+
+func TestRunAppliesPendingMigrations(t *testing.T) {
+	assert := assert.New(t)
+	root, dataPath := newTestDataDir(t)
+
+	opened := 0
+	open := func() error { opened++; return nil }
+	m := NewMigrator("test store", dataPath, &MigrationConf{}, fileMigration(dataPath, 2), fileMigration(dataPath, 1))
+	report, err := m.Run(open, func() {})
+	assert.NoError(err)
+	assert.Equal(1, opened)
+	assert.Equal(0, report.FromVersion)
+	assert.Equal(2, report.ToVersion)
+	assert.Zero(report.Pending)
+	assert.Len(report.Migrations, 2)
+	assert.Equal(1, report.Migrations[0].Version)
+	assert.Equal(ActionApplied, report.Migrations[0].Action)
+	assert.Equal([]string{"rewrote file.txt as v1"}, report.Migrations[0].Changes)
+	assert.Equal("v2", readFile(t, filepath.Join(dataPath, "file.txt")))
+
+	// The backup is of the data before the migrations, outside the data directory
+	assert.Equal(filepath.Join(root, "data.backups"), filepath.Dir(report.Backup))
+	assert.Regexp("-v0$", report.Backup)
+	assert.Equal("v0", readFile(t, filepath.Join(report.Backup, "file.txt")))
+	assert.Equal("log", readFile(t, filepath.Join(report.Backup, "db", "000001.log")))
+
+	state := readState(t, dataPath)
+	assert.Equal(2, state.Version)
+	assert.Len(state.History, 2)
+	assert.Equal(report.Backup, state.History[1].Backup)
+
+	// Nothing is pending on the next start
+	report, err = m.Run(open, func() {})
+	assert.NoError(err)
+	assert.Equal(2, opened)
+	assert.Equal(2, report.FromVersion)
+	assert.Empty(report.Migrations)
+	assert.Empty(report.Backup)
+}
+
+func TestRunNoDataDir(t *testing.T) {
+	assert := assert.New(t)
+	dataPath := filepath.Join(t.TempDir(), "missing")
+
+	opened := false
+	m := NewMigrator("test store", dataPath, &MigrationConf{}, fileMigration(dataPath, 1))
+	report, err := m.Run(func() error { opened = true; return nil }, func() {})
+	assert.NoError(err)
+	assert.True(opened)
+	assert.Empty(report.Migrations)
+
+	_, err = m.Run(func() error { return fmt.Errorf("pop") }, func() {})
+	assert.Regexp("pop", err)
+}
+
+func TestRunNoChangesNoBackup(t *testing.T) {
+	assert := assert.New(t)
+	root, dataPath := newTestDataDir(t)
+
+	m := NewMigrator("test store", dataPath, &MigrationConf{}, &Migration{
+		Version: 1,
+		Plan:    func() ([]string, error) { return nil, nil },
+		Apply:   func() ([]string, error) { return nil, nil },
+	})
+	report, err := m.Run(func() error { return nil }, func() {})
+	assert.NoError(err)
+	assert.Equal(1, report.ToVersion)
+	assert.Empty(report.Backup)
+	_, err = os.Stat(filepath.Join(root, "data.backups"))
+	assert.True(os.IsNotExist(err))
+	assert.Equal(1, readState(t, dataPath).Version)
+}
+
+func TestRunDryRun(t *testing.T) {
+	assert := assert.New(t)
+	root, dataPath := newTestDataDir(t)
+
+	opened := false
+	m := NewMigrator("test store", dataPath, &MigrationConf{DryRun: true}, fileMigration(dataPath, 1))
+	report, err := m.Run(func() error { opened = true; return nil }, func() {})
+	assert.NoError(err)
+	assert.True(opened)
+	assert.True(report.DryRun)
+	assert.Equal(1, report.Pending)
+	assert.Equal(0, report.ToVersion)
+	assert.Len(report.Migrations, 1)
+	assert.Empty(report.Migrations[0].Action)
+	assert.Equal([]string{"rewrite file.txt as v1"}, report.Migrations[0].Changes)
+
+	assert.Equal("v0", readFile(t, filepath.Join(dataPath, "file.txt")))
+	_, err = os.Stat(filepath.Join(dataPath, StateFileName))
+	assert.True(os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(root, "data.backups"))
+	assert.True(os.IsNotExist(err))
+}
+
+func TestRunFailureRestoresBackup(t *testing.T) {
+	assert := assert.New(t)
+	_, dataPath := newTestDataDir(t)
+
+	closed := false
+	m := NewMigrator("test store", dataPath, &MigrationConf{BackupPath: filepath.Join(t.TempDir(), "backups")},
+		fileMigration(dataPath, 1),
+		&Migration{
+			Version:     2,
+			Description: "Break things",
+			Plan:        func() ([]string, error) { return []string{"break"}, nil },
+			Apply: func() ([]string, error) {
+				ioutil.WriteFile(filepath.Join(dataPath, "file.txt"), []byte("broken"), 0644)
+				return nil, fmt.Errorf("pop")
+			},
+		})
+	_, err := m.Run(func() error { return nil }, func() { closed = true })
+	assert.Regexp("FFEC100387.*2.*Break things.*pop", err)
+	assert.True(closed)
+
+	// The data is as it was before the first migration
+	assert.Equal("v0", readFile(t, filepath.Join(dataPath, "file.txt")))
+	assert.Equal("log", readFile(t, filepath.Join(dataPath, "db", "000001.log")))
+	_, err = os.Stat(filepath.Join(dataPath, StateFileName))
+	assert.True(os.IsNotExist(err))
+}
+
+func TestRunPlanFails(t *testing.T) {
+	_, dataPath := newTestDataDir(t)
+
+	m := NewMigrator("test store", dataPath, &MigrationConf{}, &Migration{
+		Version: 1,
+		Plan:    func() ([]string, error) { return nil, fmt.Errorf("pop") },
+	})
+	_, err := m.Run(func() error { return nil }, func() {})
+	assert.Regexp(t, "FFEC100387.*pop", err)
+}
+
+func TestRunOpenFailsAfterBackup(t *testing.T) {
+	_, dataPath := newTestDataDir(t)
+
+	m := NewMigrator("test store", dataPath, &MigrationConf{}, fileMigration(dataPath, 1))
+	_, err := m.Run(func() error { return fmt.Errorf("pop") }, func() {})
+	assert.Regexp(t, "pop", err)
+	assert.Equal(t, "v0", readFile(t, filepath.Join(dataPath, "file.txt")))
+}
+
+func TestRunBackupFails(t *testing.T) {
+	root, dataPath := newTestDataDir(t)
+	ioutil.WriteFile(filepath.Join(root, "notadir"), []byte{}, 0644)
+
+	m := NewMigrator("test store", dataPath, &MigrationConf{BackupPath: filepath.Join(root, "notadir")}, fileMigration(dataPath, 1))
+	_, err := m.Run(func() error { return nil }, func() {})
+	assert.Regexp(t, "FFEC100386", err)
+	assert.Equal(t, "v0", readFile(t, filepath.Join(dataPath, "file.txt")))
+}
+
+func TestRunBadState(t *testing.T) {
+	_, dataPath := newTestDataDir(t)
+	ioutil.WriteFile(filepath.Join(dataPath, StateFileName), []byte("!json"), 0644)
+
+	m := NewMigrator("test store", dataPath, &MigrationConf{}, fileMigration(dataPath, 1))
+	_, err := m.Run(func() error { return nil }, func() {})
+	assert.Regexp(t, "FFEC100385", err)
+}
+
+func TestRunVersionAhead(t *testing.T) {
+	_, dataPath := newTestDataDir(t)
+	ioutil.WriteFile(filepath.Join(dataPath, StateFileName), []byte(`{"version":5}`), 0644)
+
+	m := NewMigrator("test store", dataPath, &MigrationConf{}, fileMigration(dataPath, 1))
+	_, err := m.Run(func() error { return nil }, func() {})
+	assert.Regexp(t, "FFEC100390.*5.*1", err)
+}
+
+func TestRollback(t *testing.T) {
+	assert := assert.New(t)
+	_, dataPath := newTestDataDir(t)
+
+	migration := fileMigration(dataPath, 1)
+	_, err := NewMigrator("test store", dataPath, &MigrationConf{}, migration).Run(func() error { return nil }, func() {})
+	assert.NoError(err)
+	assert.Equal("v1", readFile(t, filepath.Join(dataPath, "file.txt")))
+
+	m := NewMigrator("test store", dataPath, &MigrationConf{Rollback: true}, migration)
+	report, err := m.Run(func() error { return nil }, func() {})
+	assert.NoError(err)
+	assert.Equal(0, report.ToVersion)
+	assert.Equal(ActionRolledBack, report.Migrations[0].Action)
+	assert.Equal("v0", readFile(t, filepath.Join(dataPath, "file.txt")))
+	state := readState(t, dataPath)
+	assert.Equal(0, state.Version)
+	assert.Equal(ActionRolledBack, state.History[0].Action)
+
+	// Starting again with rollback set keeps what was written since
+	ioutil.WriteFile(filepath.Join(dataPath, "file.txt"), []byte("v0-updated"), 0644)
+	_, err = m.Run(func() error { return nil }, func() {})
+	assert.NoError(err)
+	assert.Equal("v0-updated", readFile(t, filepath.Join(dataPath, "file.txt")))
+}
+
+func TestRollbackNoBackup(t *testing.T) {
+	_, dataPath := newTestDataDir(t)
+
+	m := NewMigrator("test store", dataPath, &MigrationConf{Rollback: true}, fileMigration(dataPath, 1))
+	_, err := m.Run(func() error { return nil }, func() {})
+	assert.Regexp(t, "FFEC100388", err)
+}
+
+func TestRollbackBadState(t *testing.T) {
+	root, dataPath := newTestDataDir(t)
+	os.MkdirAll(filepath.Join(root, "data.backups", "20220101T000000.000Z-v0"), 0755)
+	ioutil.WriteFile(filepath.Join(dataPath, StateFileName), []byte("!json"), 0644)
+
+	m := NewMigrator("test store", dataPath, &MigrationConf{Rollback: true}, fileMigration(dataPath, 1))
+	_, err := m.Run(func() error { return nil }, func() {})
+	assert.Regexp(t, "FFEC100385", err)
+}
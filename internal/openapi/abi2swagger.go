@@ -87,7 +87,7 @@ func (c *ABI2Swagger) convert(basePath, name string, abi *ethbinding.ABI, devdoc
 				InfoProps: spec.InfoProps{
 					Version:     "1.0",
 					Title:       name,
-					Description: devdocs.Get("details").String(),
+					Description: docDescription(devdocs),
 				},
 			},
 			Host:        c.conf.ExternalHost,
@@ -113,14 +113,18 @@ func (c *ABI2Swagger) convert(basePath, name string, abi *ethbinding.ABI, devdoc
 func (c *ABI2Swagger) buildDefinitionsAndPaths(inst, factoryOnly, externalRegistry bool, abi *ethbinding.ABI, defs map[string]spec.Schema, paths map[string]spec.PathItem, devdocs gjson.Result) {
 	methodsDocs := devdocs.Get("methods")
 	if !inst {
-		c.buildMethodDefinitionsAndPath(inst, defs, paths, "constructor", abi.Constructor, methodsDocs)
+		c.buildMethodDefinitionsAndPath(inst, defs, paths, "constructor", abi.Constructor, methodsDocs, false)
 	}
 	if !factoryOnly {
 		if !inst && !externalRegistry {
 			c.addRegisterPath(paths)
 		}
+		overloads := make(map[string]int)
 		for _, method := range abi.Methods {
-			c.buildMethodDefinitionsAndPath(inst, defs, paths, method.Name, method, methodsDocs)
+			overloads[method.RawName]++
+		}
+		for _, method := range abi.Methods {
+			c.buildMethodDefinitionsAndPath(inst, defs, paths, method.RawName, method, methodsDocs, overloads[method.RawName] > 1)
 		}
 		for _, event := range abi.Events {
 			c.buildEventDefinitionsAndPath(inst, defs, paths, event.Name, event, devdocs.Get("events"))
@@ -170,22 +174,29 @@ func (c *ABI2Swagger) getDeclaredIDDetails(inst bool, declaredID string, inputs
 	return constructor, sig, path, methodDocs
 }
 
-func (c *ABI2Swagger) buildMethodDefinitionsAndPath(inst bool, defs map[string]spec.Schema, paths map[string]spec.PathItem, name string, method ethbinding.ABIMethod, devdocs gjson.Result) {
+func (c *ABI2Swagger) buildMethodDefinitionsAndPath(inst bool, defs map[string]spec.Schema, paths map[string]spec.PathItem, name string, method ethbinding.ABIMethod, devdocs gjson.Result, overloaded bool) {
 
 	constructor, methodSig, path, methodDocs := c.getDeclaredIDDetails(inst, name, method.Inputs, devdocs)
+	opName := name
+	if overloaded {
+		// Overloads cannot share a path, so each is called by its full signature, and the
+		// operations and schemas are told apart by the selector
+		path = strings.TrimSuffix(path, name) + methodSig
+		opName = fmt.Sprintf("%s_%x", name, method.ID)
+	}
 	if method.IsConstant() {
 		methodSig += " [read only]"
 	}
 
-	inputSchema := url.QueryEscape(name) + inputSchemaNameSuffix
-	outputSchema := url.QueryEscape(name) + outputSchemaNameSuffix
+	inputSchema := url.QueryEscape(opName) + inputSchemaNameSuffix
+	outputSchema := url.QueryEscape(opName) + outputSchemaNameSuffix
 	c.buildArgumentsDefinition(defs, outputSchema, method.Outputs, methodDocs)
 	pathItem := spec.PathItem{}
 	if !constructor {
-		pathItem.Get = c.buildGETPath(outputSchema, inst, name, method, methodSig, methodDocs)
+		pathItem.Get = c.buildGETPath(outputSchema, inst, opName, method, methodSig, methodDocs)
 	}
 	c.buildArgumentsDefinition(defs, inputSchema, method.Inputs, methodDocs)
-	pathItem.Post = c.buildPOSTPath(inputSchema, outputSchema, inst, constructor, name, method, methodSig, methodDocs)
+	pathItem.Post = c.buildPOSTPath(inputSchema, outputSchema, inst, constructor, opName, method, methodSig, methodDocs)
 	paths[path] = pathItem
 
 	return
@@ -335,6 +346,30 @@ func (c *ABI2Swagger) getCommonParameters() map[string]spec.Parameter {
 			Type: "string",
 		},
 	}
+	params["replymodeParam"] = spec.Parameter{
+		ParamProps: spec.ParamProps{
+			Description:     fmt.Sprintf("Deliver the reply to an async request to 'kafka' (default), 'webhook', 'both' or 'none' - the receipt is always stored (header: x-%s-replymode)", utils.GetenvOrDefaultLowerCase("PREFIX_LONG", "firefly")),
+			Name:            fmt.Sprintf("%s-replymode", utils.GetenvOrDefaultLowerCase("PREFIX_SHORT", "fly")),
+			In:              "query",
+			Required:        false,
+			AllowEmptyValue: true,
+		},
+		SimpleSchema: spec.SimpleSchema{
+			Type: "string",
+		},
+	}
+	params["replywebhookParam"] = spec.Parameter{
+		ParamProps: spec.ParamProps{
+			Description:     fmt.Sprintf("URL the reply is posted to, with a replymode of 'webhook' or 'both' (header: x-%s-replywebhook)", utils.GetenvOrDefaultLowerCase("PREFIX_LONG", "firefly")),
+			Name:            fmt.Sprintf("%s-replywebhook", utils.GetenvOrDefaultLowerCase("PREFIX_SHORT", "fly")),
+			In:              "query",
+			Required:        false,
+			AllowEmptyValue: true,
+		},
+		SimpleSchema: spec.SimpleSchema{
+			Type: "string",
+		},
+	}
 	params["callParam"] = spec.Parameter{
 		ParamProps: spec.ParamProps{
 			Description:     fmt.Sprintf("Perform a read-only call with the same parameters that would be used to invoke, and return result (header: x-%s-call)", utils.GetenvOrDefaultLowerCase("PREFIX_LONG", "firefly")),
@@ -441,6 +476,8 @@ func (c *ABI2Swagger) addCommonParams(op *spec.Operation, isPOST bool, isConstru
 	registerParam, _ := spec.NewRef("#/parameters/registerParam")
 	blocknumberParam, _ := spec.NewRef("#/parameters/blocknumberParam")
 	acktypeParam, _ := spec.NewRef("#/parameters/acktypeParam")
+	replymodeParam, _ := spec.NewRef("#/parameters/replymodeParam")
+	replywebhookParam, _ := spec.NewRef("#/parameters/replywebhookParam")
 	transactionParam, _ := spec.NewRef("#/parameters/transactionParam")
 	op.Parameters = append(op.Parameters, spec.Parameter{
 		Refable: spec.Refable{
@@ -498,6 +535,16 @@ func (c *ABI2Swagger) addCommonParams(op *spec.Operation, isPOST bool, isConstru
 				Ref: acktypeParam,
 			},
 		})
+		op.Parameters = append(op.Parameters, spec.Parameter{
+			Refable: spec.Refable{
+				Ref: replymodeParam,
+			},
+		})
+		op.Parameters = append(op.Parameters, spec.Parameter{
+			Refable: spec.Refable{
+				Ref: replywebhookParam,
+			},
+		})
 		if c.conf.OrionPrivateAPI {
 			op.Parameters = append(op.Parameters, spec.Parameter{
 				Refable: spec.Refable{
@@ -570,7 +617,7 @@ func (c *ABI2Swagger) buildGETPath(outputSchema string, inst bool, name string,
 		OperationProps: spec.OperationProps{
 			ID:          name + "_get",
 			Summary:     methodSig,
-			Description: devdocs.Get("details").String(),
+			Description: docDescription(devdocs),
 			Produces:    []string{"application/json"},
 			Responses:   c.buildResponses(outputSchema, devdocs),
 			Parameters:  parameters,
@@ -614,7 +661,7 @@ func (c *ABI2Swagger) buildPOSTPath(inputSchema, outputSchema string, inst, cons
 		OperationProps: spec.OperationProps{
 			ID:          name + "_post",
 			Summary:     methodSig,
-			Description: devdocs.Get("details").String(),
+			Description: docDescription(devdocs),
 			Consumes:    []string{"application/json", "application/x-yaml"},
 			Produces:    []string{"application/json"},
 			Responses:   c.buildResponses(outputSchema, devdocs),
@@ -664,6 +711,13 @@ func (c *ABI2Swagger) buildEventPOSTPath(eventSchema string, inst bool, event et
 								Default:     "latest",
 							},
 						},
+						"fromTime": {
+							SchemaProps: spec.SchemaProps{
+								Description: "An RFC3339 timestamp to start the subscription from, as an alternative to fromBlock",
+								Type:        []string{"string"},
+								Format:      "date-time",
+							},
+						},
 					},
 				},
 			},
@@ -673,7 +727,7 @@ func (c *ABI2Swagger) buildEventPOSTPath(eventSchema string, inst bool, event et
 		OperationProps: spec.OperationProps{
 			ID:          id,
 			Summary:     eventSig,
-			Description: devdocs.Get("details").String(),
+			Description: docDescription(devdocs),
 			Consumes:    []string{"application/json", "application/x-yaml"},
 			Produces:    []string{"application/json"},
 			Responses:   c.buildResponses(eventSchema, devdocs),
@@ -148,3 +148,32 @@ func TestABI2SwaggerUnnamedInputsABI(t *testing.T) {
 	assert.NotNil(swagger.SecurityDefinitions)
 	return
 }
+
+func TestABI2SwaggerOverloadedMethods(t *testing.T) {
+	assert := assert.New(t)
+
+	c := NewABI2Swagger(&ABI2SwaggerConf{})
+	abi, err := ethbind.API.JSON(strings.NewReader(`[
+		{"name":"transfer","type":"function","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+		{"name":"transfer","type":"function","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"},{"name":"data","type":"bytes"}],"outputs":[{"name":"","type":"bool"}]},
+		{"name":"name","type":"function","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]}
+	]`))
+	assert.NoError(err)
+
+	swagger := c.Gen4Instance("/token", "token", &abi, "")
+	assert.NotContains(swagger.Paths.Paths, "/transfer")
+	assert.NotContains(swagger.Paths.Paths, "/transfer0")
+	transfer := swagger.Paths.Paths["/transfer(address,uint256)"]
+	assert.Equal("transfer_a9059cbb_post", transfer.Post.ID)
+	assert.Equal("transfer_a9059cbb_get", transfer.Get.ID)
+	assert.Equal("transfer(address,uint256)", transfer.Post.Summary)
+	transferData := swagger.Paths.Paths["/transfer(address,uint256,bytes)"]
+	assert.Equal("transfer_be45fd62_post", transferData.Post.ID)
+	assert.Equal("#/definitions/transfer_be45fd62_inputs", transferData.Post.Parameters[0].Schema.Ref.String())
+	assert.Contains(swagger.Definitions["transfer_be45fd62_inputs"].Properties, "data")
+	assert.NotContains(swagger.Definitions["transfer_a9059cbb_inputs"].Properties, "data")
+	assert.Equal("name_get", swagger.Paths.Paths["/name"].Get.ID)
+
+	swagger = c.Gen4Factory("/token", "token", false, false, &abi, "")
+	assert.Contains(swagger.Paths.Paths, "/{address}/transfer(address,uint256,bytes)")
+}
@@ -0,0 +1,148 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openapi
+
+import (
+	"strconv"
+
+	"github.com/go-openapi/spec"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+)
+
+const (
+	jsonSchemaDraft07 = "http://json-schema.org/draft-07/schema#"
+
+	decimalPattern = "^-?[0-9]+$"
+	hexPattern     = "^0x[a-fA-F0-9]*$"
+	addressPattern = "^0x[a-fA-F0-9]{40}$"
+	hashPattern    = "^0x[a-fA-F0-9]{64}$"
+)
+
+// EventJSONSchema builds a JSON Schema for the payload an event stream delivers for the event,
+// including the decoded "data" in exactly the form it is serialized by the log processor.
+// Unlike the swagger definitions, which describe the flexible formats we accept as input,
+// this describes the fixed formats we emit
+func EventJSONSchema(event *ethbinding.ABIEvent) *spec.Schema {
+	data := objectSchema()
+	data.AdditionalProperties = &spec.SchemaOrBool{Allows: false}
+	dataIdx := 0
+	for _, input := range event.Inputs {
+		argName := input.Name
+		if input.Indexed {
+			data.Properties[argName] = indexedTopicSchema(input.Type)
+		} else {
+			// Un-named data fields are named by their position in the data, as they are for method outputs
+			if argName == "" {
+				argName = "output"
+				if dataIdx != 0 {
+					argName += strconv.Itoa(dataIdx)
+				}
+			}
+			data.Properties[argName] = outputTypeSchema(input.Type)
+			dataIdx++
+		}
+		data.Required = append(data.Required, argName)
+	}
+
+	s := objectSchema()
+	s.Schema = jsonSchemaDraft07
+	s.Title = event.Name
+	s.Description = "Event payload delivered by event streams for " + event.Sig
+	s.Properties["address"] = patternSchema(addressPattern)
+	s.Properties["blockNumber"] = patternSchema(decimalPattern)
+	s.Properties["blockHash"] = patternSchema(hashPattern)
+	s.Properties["transactionIndex"] = stringSchema()
+	s.Properties["transactionHash"] = patternSchema(hashPattern)
+	s.Properties["logIndex"] = patternSchema(decimalPattern)
+	s.Properties["subId"] = stringSchema()
+	s.Properties["signature"] = spec.Schema{SchemaProps: spec.SchemaProps{Type: []string{"string"}, Enum: []interface{}{event.Sig}}}
+	s.Properties["timestamp"] = patternSchema(decimalPattern)
+	s.Properties["inputMethod"] = stringSchema()
+	s.Properties["inputArgs"] = spec.Schema{SchemaProps: spec.SchemaProps{Type: []string{"object"}}}
+	s.Properties["inputSigner"] = stringSchema()
+	s.Properties["data"] = *data
+	s.Required = []string{"address", "blockNumber", "blockHash", "transactionIndex", "transactionHash", "logIndex", "subId", "signature", "data"}
+	return s
+}
+
+func objectSchema() *spec.Schema {
+	return &spec.Schema{
+		SchemaProps: spec.SchemaProps{
+			Type:       []string{"object"},
+			Properties: make(map[string]spec.Schema),
+		},
+	}
+}
+
+func stringSchema() spec.Schema {
+	return spec.Schema{SchemaProps: spec.SchemaProps{Type: []string{"string"}}}
+}
+
+func patternSchema(pattern string) spec.Schema {
+	s := stringSchema()
+	s.Pattern = pattern
+	return s
+}
+
+// indexedTopicSchema describes values parsed from topics. Only value types can be recovered,
+// for everything else the topic is the hash of the value
+func indexedTopicSchema(t ethbinding.ABIType) spec.Schema {
+	switch t.T {
+	case ethbinding.IntTy, ethbinding.UintTy, ethbinding.AddressTy, ethbinding.BoolTy:
+		return outputTypeSchema(t)
+	default:
+		s := patternSchema(hashPattern)
+		s.Description = t.String() + " (indexed - keccak256 hash of the value)"
+		return s
+	}
+}
+
+func outputTypeSchema(t ethbinding.ABIType) spec.Schema {
+	var s spec.Schema
+	switch t.T {
+	case ethbinding.IntTy, ethbinding.UintTy:
+		s = patternSchema(decimalPattern)
+	case ethbinding.BoolTy:
+		s = spec.Schema{SchemaProps: spec.SchemaProps{Type: []string{"boolean"}}}
+	case ethbinding.AddressTy:
+		s = patternSchema(addressPattern)
+	case ethbinding.StringTy:
+		s = stringSchema()
+	case ethbinding.FixedBytesTy:
+		s = patternSchema("^0x[a-fA-F0-9]{" + strconv.Itoa(t.Size*2) + "}$")
+	case ethbinding.BytesTy:
+		s = patternSchema(hexPattern)
+	case ethbinding.SliceTy, ethbinding.ArrayTy:
+		elem := outputTypeSchema(*t.Elem)
+		s = spec.Schema{SchemaProps: spec.SchemaProps{
+			Type:  []string{"array"},
+			Items: &spec.SchemaOrArray{Schema: &elem},
+		}}
+		if t.T == ethbinding.ArrayTy {
+			size := int64(t.Size)
+			s.MinItems = &size
+			s.MaxItems = &size
+		}
+	case ethbinding.TupleTy:
+		tuple := objectSchema()
+		for i, fieldName := range t.TupleRawNames {
+			tuple.Properties[fieldName] = outputTypeSchema(*t.TupleElems[i])
+			tuple.Required = append(tuple.Required, fieldName)
+		}
+		s = *tuple
+	}
+	s.Description = t.String()
+	return s
+}
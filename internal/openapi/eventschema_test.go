@@ -0,0 +1,79 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventJSONSchema(t *testing.T) {
+	assert := assert.New(t)
+
+	event, err := ethbind.API.ABIElementMarshalingToABIEvent(&ethbinding.ABIElementMarshaling{
+		Type: "event",
+		Name: "Changed",
+		Inputs: []ethbinding.ABIArgumentMarshaling{
+			{Name: "from", Type: "address", Indexed: true},
+			{Name: "amount", Type: "int256", Indexed: true},
+			{Name: "label", Type: "string", Indexed: true},
+			{Name: "", Type: "bool"},
+			{Name: "", Type: "bytes4[2]"},
+			{Name: "rec", Type: "tuple", Components: []ethbinding.ABIArgumentMarshaling{
+				{Name: "id", Type: "uint8"},
+				{Name: "data", Type: "bytes"},
+			}},
+		},
+	})
+	assert.NoError(err)
+
+	s := EventJSONSchema(event)
+	b, err := json.Marshal(s)
+	assert.NoError(err)
+	var schema map[string]interface{}
+	json.Unmarshal(b, &schema)
+
+	assert.Equal(jsonSchemaDraft07, schema["$schema"])
+	assert.Equal("Changed", schema["title"])
+	assert.Contains(schema["required"], "data")
+	assert.NotContains(schema["required"], "timestamp")
+	props := schema["properties"].(map[string]interface{})
+	assert.Equal([]interface{}{"Changed(address,int256,string,bool,bytes4[2],(uint8,bytes))"}, props["signature"].(map[string]interface{})["enum"])
+
+	data := props["data"].(map[string]interface{})
+	assert.Equal(false, data["additionalProperties"])
+	assert.Equal([]interface{}{"from", "amount", "label", "arg3", "arg4", "rec"}, data["required"])
+	dataProps := data["properties"].(map[string]interface{})
+	assert.Equal(addressPattern, dataProps["from"].(map[string]interface{})["pattern"])
+	assert.Equal(decimalPattern, dataProps["amount"].(map[string]interface{})["pattern"])
+	assert.Equal(hashPattern, dataProps["label"].(map[string]interface{})["pattern"])
+	assert.Equal("boolean", dataProps["arg3"].(map[string]interface{})["type"])
+
+	fixedArray := dataProps["arg4"].(map[string]interface{})
+	assert.Equal("array", fixedArray["type"])
+	assert.Equal(float64(2), fixedArray["minItems"])
+	assert.Equal(float64(2), fixedArray["maxItems"])
+	assert.Equal("^0x[a-fA-F0-9]{8}$", fixedArray["items"].(map[string]interface{})["pattern"])
+
+	tuple := dataProps["rec"].(map[string]interface{})
+	assert.Equal("object", tuple["type"])
+	assert.Equal([]interface{}{"id", "data"}, tuple["required"])
+	tupleProps := tuple["properties"].(map[string]interface{})
+	assert.Equal(hexPattern, tupleProps["data"].(map[string]interface{})["pattern"])
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openapi
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// MergeNatSpec adds the "notice" entries of the Solidity userdoc output to the devdoc
+// output, for the contract and each method and event, so both can be passed to
+// Gen4Instance and Gen4Factory as a single set of docs. The devdoc is returned
+// unchanged if there is no userdoc, or either cannot be parsed.
+func MergeNatSpec(devdocJSON, userdocJSON string) string {
+	var userdoc map[string]interface{}
+	if userdocJSON == "" || json.Unmarshal([]byte(userdocJSON), &userdoc) != nil || userdoc == nil {
+		return devdocJSON
+	}
+	devdoc := map[string]interface{}{}
+	if devdocJSON != "" && json.Unmarshal([]byte(devdocJSON), &devdoc) != nil {
+		return devdocJSON
+	}
+	if devdoc == nil {
+		devdoc = map[string]interface{}{}
+	}
+	if notice, ok := userdoc["notice"].(string); ok {
+		devdoc["notice"] = notice
+	}
+	for _, section := range []string{"methods", "events"} {
+		userEntries, _ := userdoc[section].(map[string]interface{})
+		for sig, userEntry := range userEntries {
+			userEntryMap, _ := userEntry.(map[string]interface{})
+			notice, ok := userEntryMap["notice"].(string)
+			if !ok {
+				continue
+			}
+			devEntries, ok := devdoc[section].(map[string]interface{})
+			if !ok {
+				devEntries = map[string]interface{}{}
+				devdoc[section] = devEntries
+			}
+			devEntry, ok := devEntries[sig].(map[string]interface{})
+			if !ok {
+				devEntry = map[string]interface{}{}
+				devEntries[sig] = devEntry
+			}
+			devEntry["notice"] = notice
+		}
+	}
+	b, _ := json.Marshal(devdoc)
+	return string(b)
+}
+
+// docDescription is the user facing notice followed by the developer details of a NatSpec entry
+func docDescription(docs gjson.Result) string {
+	parts := make([]string, 0, 2)
+	for _, field := range []string{"notice", "details"} {
+		if s := strings.TrimSpace(docs.Get(field).String()); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,72 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openapi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeNatSpec(t *testing.T) {
+	assert := assert.New(t)
+
+	merged := MergeNatSpec(
+		`{"details":"dev","methods":{"set(uint256)":{"details":"Sets x","params":{"x":"the value"}}}}`,
+		`{"kind":"user","notice":"user","methods":{"set(uint256)":{"notice":"Store a value"},"get()":{"notice":"Read the value"},"constructor":"old format"},"events":{"Changed(uint256)":{"notice":"The value changed"}}}`,
+	)
+	assert.JSONEq(`{
+		"details": "dev",
+		"notice": "user",
+		"methods": {
+			"set(uint256)": {"details": "Sets x", "params": {"x": "the value"}, "notice": "Store a value"},
+			"get()": {"notice": "Read the value"}
+		},
+		"events": {
+			"Changed(uint256)": {"notice": "The value changed"}
+		}
+	}`, merged)
+
+	assert.JSONEq(`{"notice":"user"}`, MergeNatSpec("", `{"notice":"user"}`))
+	assert.JSONEq(`{"notice":"user"}`, MergeNatSpec("null", `{"notice":"user"}`))
+	assert.Equal(`{"details":"dev"}`, MergeNatSpec(`{"details":"dev"}`, ""))
+	assert.Equal(`{"details":"dev"}`, MergeNatSpec(`{"details":"dev"}`, "null"))
+	assert.Equal(`{"details":"dev"}`, MergeNatSpec(`{"details":"dev"}`, "!json"))
+	assert.Equal("!json", MergeNatSpec("!json", `{"notice":"user"}`))
+}
+
+func TestGen4InstanceUserDoc(t *testing.T) {
+	assert := assert.New(t)
+
+	abi, err := ethbind.API.JSON(strings.NewReader(`[
+		{"type":"function","name":"set","inputs":[{"name":"x","type":"uint256"}],"outputs":[]},
+		{"type":"function","name":"get","inputs":[],"outputs":[{"name":"x","type":"uint256"}],"stateMutability":"view"},
+		{"type":"event","name":"Changed","inputs":[{"name":"x","type":"uint256"}]}
+	]`))
+	assert.NoError(err)
+	docs := MergeNatSpec(
+		`{"methods":{"set(uint256)":{"details":"Overwrites the stored value","params":{"x":"the new value"}}}}`,
+		`{"notice":"Simple storage","methods":{"set(uint256)":{"notice":"Store a value"},"get()":{"notice":"Read the value"}},"events":{"Changed(uint256)":{"notice":"The value changed"}}}`,
+	)
+	swagger := NewABI2Swagger(&ABI2SwaggerConf{}).Gen4Instance("/contracts/simple", "simple", &abi, docs)
+
+	assert.Equal("Simple storage", swagger.Info.Description)
+	assert.Equal("Store a value\n\nOverwrites the stored value", swagger.Paths.Paths["/set"].Post.Description)
+	assert.Equal("Read the value", swagger.Paths.Paths["/get"].Get.Description)
+	assert.Equal("The value changed", swagger.Paths.Paths["/Changed/subscribe"].Post.Description)
+	assert.Equal("uint256: the new value", swagger.Definitions["set_inputs"].Properties["x"].Description)
+}
@@ -0,0 +1,769 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receipts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultElasticsearchIndex          = "ethconnect-receipts"
+	defaultElasticsearchRequestTimeout = 30 * 1000
+	defaultElasticsearchBulkMaxDocs    = 100
+	defaultElasticsearchBulkFlushMS    = 250
+	defaultElasticsearchRolloverMaxAge = "1d"
+)
+
+type ElasticsearchReceipts struct {
+	conf    *ElasticsearchReceiptStoreConf
+	client  *http.Client
+	baseURL string
+	queue   chan *esBulkOp
+	closed  chan struct{}
+	done    chan struct{}
+}
+
+// esBulkOp is a single receipt waiting to be written in the next bulk request
+type esBulkOp struct {
+	requestID string
+	doc       []byte
+	overwrite bool
+	result    chan error
+}
+
+type esBulkResponse struct {
+	Errors bool                            `json:"errors"`
+	Items  []map[string]esBulkItemResponse `json:"items"`
+}
+
+type esBulkItemResponse struct {
+	ID     string                 `json:"_id"`
+	Status int                    `json:"status"`
+	Error  map[string]interface{} `json:"error,omitempty"`
+}
+
+type esSummaryResponse struct {
+	Hits struct {
+		Total struct {
+			Value int `json:"value"`
+		} `json:"total"`
+	} `json:"hits"`
+	Aggregations struct {
+		Pending struct {
+			DocCount int `json:"doc_count"`
+		} `json:"pending"`
+		Types struct {
+			Buckets []struct {
+				Key      string `json:"key"`
+				DocCount int    `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"types"`
+	} `json:"aggregations"`
+}
+
+type esGroupAggregation struct {
+	Buckets []*esGroupBucket `json:"buckets"`
+}
+
+type esGroupBucket struct {
+	Key      string              `json:"key"`
+	DocCount int                 `json:"doc_count"`
+	Group    *esGroupAggregation `json:"group"`
+	Latency  *struct {
+		Values map[string]*float64 `json:"values"`
+	} `json:"latency"`
+}
+
+type esGroupResponse struct {
+	Aggregations struct {
+		Group esGroupAggregation `json:"group"`
+	} `json:"aggregations"`
+}
+
+// esStatusScript derives the status of a receipt in the same way as receiptStatus
+const esStatusScript = `if (doc.containsKey('pending') && doc['pending'].size() > 0 && doc['pending'].value) { return params.pending; }
+if (doc.containsKey('headers.type') && doc['headers.type'].size() > 0) { return params.statuses.getOrDefault(doc['headers.type'].value, params.other); }
+return params.other;`
+
+type esSearchResponse struct {
+	Hits struct {
+		Hits []struct {
+			ID     string                 `json:"_id"`
+			Source map[string]interface{} `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+func NewElasticsearchReceipts(conf *ElasticsearchReceiptStoreConf) *ElasticsearchReceipts {
+	if conf.Index == "" {
+		conf.Index = defaultElasticsearchIndex
+	}
+	if conf.RequestTimeoutMS <= 0 {
+		conf.RequestTimeoutMS = defaultElasticsearchRequestTimeout
+	}
+	if conf.BulkMaxDocs <= 0 {
+		conf.BulkMaxDocs = defaultElasticsearchBulkMaxDocs
+	}
+	if conf.BulkFlushMS <= 0 {
+		conf.BulkFlushMS = defaultElasticsearchBulkFlushMS
+	}
+	if conf.RolloverMaxAge == "" {
+		conf.RolloverMaxAge = defaultElasticsearchRolloverMaxAge
+	}
+	return &ElasticsearchReceipts{
+		conf:    conf,
+		baseURL: strings.TrimSuffix(conf.URL, "/"),
+		queue:   make(chan *esBulkOp),
+		closed:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Connect installs the ILM policy and index template, creates the first index behind
+// the write alias if it does not exist, and starts the bulk indexing loop
+func (e *ElasticsearchReceipts) Connect() (err error) {
+	tlsConfig, err := utils.CreateTLSConfiguration(&e.conf.TLS)
+	if err != nil {
+		return err
+	}
+	e.client = &http.Client{
+		Timeout: time.Duration(e.conf.RequestTimeoutMS) * time.Millisecond,
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+	}
+
+	policyName := e.conf.Index + "-policy"
+	settings := map[string]interface{}{}
+	if e.conf.RetentionDays > 0 {
+		policy := map[string]interface{}{
+			"policy": map[string]interface{}{
+				"phases": map[string]interface{}{
+					"hot": map[string]interface{}{
+						"actions": map[string]interface{}{
+							"rollover": map[string]interface{}{"max_age": e.conf.RolloverMaxAge},
+						},
+					},
+					"delete": map[string]interface{}{
+						"min_age": fmt.Sprintf("%dd", e.conf.RetentionDays),
+						"actions": map[string]interface{}{"delete": map[string]interface{}{}},
+					},
+				},
+			},
+		}
+		if _, err = e.request("PUT", "/_ilm/policy/"+policyName, "application/json", policy); err != nil {
+			return errors.Errorf(errors.ReceiptStoreElasticsearchSetup, err)
+		}
+		settings["index.lifecycle.name"] = policyName
+		settings["index.lifecycle.rollover_alias"] = e.conf.Index
+	}
+
+	template := map[string]interface{}{
+		"index_patterns": []string{e.conf.Index + "-*"},
+		"template": map[string]interface{}{
+			"settings": settings,
+			"mappings": map[string]interface{}{
+				// Receipt fields vary by message type, so we index strings as exact values by default,
+				// and only analyze the fields that are useful for full-text search
+				"dynamic_templates": []interface{}{
+					map[string]interface{}{
+						"strings": map[string]interface{}{
+							"match_mapping_type": "string",
+							"mapping":            map[string]interface{}{"type": "keyword", "ignore_above": 1024},
+						},
+					},
+				},
+				"properties": map[string]interface{}{
+					"receivedAt":   map[string]interface{}{"type": "date", "format": "epoch_millis"},
+					"errorMessage": map[string]interface{}{"type": "text"},
+					// An elapsed time of zero would otherwise map the field as an integer
+					"headers": map[string]interface{}{
+						"properties": map[string]interface{}{
+							"timeElapsed": map[string]interface{}{"type": "double"},
+						},
+					},
+				},
+			},
+		},
+	}
+	if _, err = e.request("PUT", "/_index_template/"+e.conf.Index, "application/json", template); err != nil {
+		return errors.Errorf(errors.ReceiptStoreElasticsearchSetup, err)
+	}
+
+	exists, err := e.aliasExists()
+	if err != nil {
+		return errors.Errorf(errors.ReceiptStoreElasticsearchSetup, err)
+	}
+	if !exists {
+		firstIndex := map[string]interface{}{
+			"aliases": map[string]interface{}{
+				e.conf.Index: map[string]interface{}{"is_write_index": true},
+			},
+		}
+		if _, err = e.request("PUT", "/"+url.PathEscape(e.conf.Index+"-000001"), "application/json", firstIndex); err != nil && !strings.Contains(err.Error(), "resource_already_exists_exception") {
+			return errors.Errorf(errors.ReceiptStoreElasticsearchSetup, err)
+		}
+	}
+
+	go e.bulkLoop()
+	log.Infof("Connected to Elasticsearch on %s Index=%s", e.conf.URL, e.conf.Index)
+	return nil
+}
+
+// Close stops the bulk indexing loop, failing any pending writes
+func (e *ElasticsearchReceipts) Close() {
+	close(e.closed)
+	<-e.done
+}
+
+func (e *ElasticsearchReceipts) aliasExists() (bool, error) {
+	req, _ := http.NewRequest("HEAD", e.baseURL+"/_alias/"+url.PathEscape(e.conf.Index), nil)
+	e.addAuth(req)
+	res, err := e.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+	switch {
+	case res.StatusCode == 404:
+		return false, nil
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return true, nil
+	default:
+		return false, errors.Errorf(errors.ReceiptStoreElasticsearchStatus, res.StatusCode, "")
+	}
+}
+
+func (e *ElasticsearchReceipts) addAuth(req *http.Request) {
+	if e.conf.Username != "" {
+		req.SetBasicAuth(e.conf.Username, e.conf.Password)
+	}
+}
+
+// request performs a request against Elasticsearch, returning an error for non-2xx responses
+func (e *ElasticsearchReceipts) request(method, path, contentType string, body interface{}) ([]byte, error) {
+	var bodyBytes []byte
+	switch b := body.(type) {
+	case []byte:
+		bodyBytes = b
+	default:
+		bodyBytes, _ = json.Marshal(b)
+	}
+	req, _ := http.NewRequest(method, e.baseURL+path, bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", contentType)
+	e.addAuth(req)
+	res, err := e.client.Do(req)
+	if err != nil {
+		return nil, errors.Errorf(errors.ReceiptStoreElasticsearchRequest, method, path, err)
+	}
+	defer res.Body.Close()
+	resBody, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, errors.Errorf(errors.ReceiptStoreElasticsearchStatus, res.StatusCode, string(resBody))
+	}
+	return resBody, nil
+}
+
+// AddReceipt queues the receipt for the next bulk request, and waits for the result.
+func (e *ElasticsearchReceipts) AddReceipt(requestID string, receipt *map[string]interface{}, overwrite bool) error {
+	op, err := newESBulkOp(requestID, *receipt, overwrite)
+	if err != nil {
+		return err
+	}
+	if err := e.enqueue(op); err != nil {
+		return err
+	}
+	return <-op.result
+}
+
+// AddReceipts queues all the receipts before waiting, so they share bulk requests
+func (e *ElasticsearchReceipts) AddReceipts(receipts []map[string]interface{}) error {
+	ops := make([]*esBulkOp, 0, len(receipts))
+	for _, receipt := range receipts {
+		requestID, _ := receipt["_id"].(string)
+		op, err := newESBulkOp(requestID, receipt, true)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, op)
+	}
+	var firstErr error
+	for i, op := range ops {
+		if err := e.enqueue(op); err != nil {
+			// Wait for those already queued, so none are written after we return
+			ops = ops[:i]
+			firstErr = err
+			break
+		}
+	}
+	for _, op := range ops {
+		if err := <-op.result; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// newESBulkOp serializes the receipt for a bulk request. The _id is metadata in Elasticsearch,
+// so is removed from the document body.
+func newESBulkOp(requestID string, receipt map[string]interface{}, overwrite bool) (*esBulkOp, error) {
+	doc := make(map[string]interface{}, len(receipt))
+	for k, v := range receipt {
+		if k != "_id" {
+			doc[k] = v
+		}
+	}
+	docBytes, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.Errorf(errors.ReceiptStoreElasticsearchSerialize, err)
+	}
+	return &esBulkOp{
+		requestID: requestID,
+		doc:       docBytes,
+		overwrite: overwrite,
+		result:    make(chan error, 1),
+	}, nil
+}
+
+func (e *ElasticsearchReceipts) enqueue(op *esBulkOp) error {
+	select {
+	case e.queue <- op:
+		return nil
+	case <-e.closed:
+		return errors.Errorf(errors.ReceiptStoreElasticsearchClosed)
+	}
+}
+
+func (e *ElasticsearchReceipts) bulkLoop() {
+	defer close(e.done)
+	flushInterval := time.Duration(e.conf.BulkFlushMS) * time.Millisecond
+	for {
+		var batch []*esBulkOp
+		select {
+		case op := <-e.queue:
+			batch = append(batch, op)
+		case <-e.closed:
+			return
+		}
+		timer := time.NewTimer(flushInterval)
+	collect:
+		for len(batch) < e.conf.BulkMaxDocs {
+			select {
+			case op := <-e.queue:
+				batch = append(batch, op)
+			case <-timer.C:
+				break collect
+			case <-e.closed:
+				break collect
+			}
+		}
+		timer.Stop()
+		e.flushBulk(batch)
+	}
+}
+
+func (e *ElasticsearchReceipts) flushBulk(batch []*esBulkOp) {
+	var buf bytes.Buffer
+	for _, op := range batch {
+		action := "create"
+		if op.overwrite {
+			action = "index"
+		}
+		meta, _ := json.Marshal(map[string]interface{}{
+			action: map[string]string{"_id": op.requestID},
+		})
+		buf.Write(meta)
+		buf.WriteByte('\n')
+		buf.Write(op.doc)
+		buf.WriteByte('\n')
+	}
+
+	resBody, err := e.request("POST", "/"+url.PathEscape(e.conf.Index)+"/_bulk", "application/x-ndjson", buf.Bytes())
+	var bulkRes esBulkResponse
+	if err == nil {
+		if err = json.Unmarshal(resBody, &bulkRes); err == nil && len(bulkRes.Items) != len(batch) {
+			err = errors.Errorf(errors.ReceiptStoreElasticsearchBulkMismatch, len(bulkRes.Items), len(batch))
+		}
+	}
+	if err != nil {
+		log.Errorf("Elasticsearch bulk request for %d receipts failed: %s", len(batch), err)
+		for _, op := range batch {
+			op.result <- err
+		}
+		return
+	}
+	log.Debugf("Elasticsearch bulk request for %d receipts complete (errors=%t)", len(batch), bulkRes.Errors)
+	for i, op := range batch {
+		var opErr error
+		for _, item := range bulkRes.Items[i] {
+			if item.Status < 200 || item.Status >= 300 {
+				errBytes, _ := json.Marshal(item.Error)
+				opErr = errors.Errorf(errors.ReceiptStoreElasticsearchStatus, item.Status, string(errBytes))
+			}
+		}
+		op.result <- opErr
+	}
+}
+
+func (e *ElasticsearchReceipts) search(query map[string]interface{}, order string, skip, size int) (*[]map[string]interface{}, error) {
+	body := map[string]interface{}{
+		"query": query,
+		"sort":  []interface{}{map[string]interface{}{"receivedAt": map[string]string{"order": order}}},
+		"from":  skip,
+		"size":  size,
+	}
+	resBody, err := e.request("POST", "/"+url.PathEscape(e.conf.Index)+"/_search", "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+	var searchRes esSearchResponse
+	if err = json.Unmarshal(resBody, &searchRes); err != nil {
+		return nil, errors.Errorf(errors.ReceiptStoreElasticsearchResponse, err)
+	}
+	results := make([]map[string]interface{}, 0, len(searchRes.Hits.Hits))
+	for _, hit := range searchRes.Hits.Hits {
+		if hit.Source == nil {
+			hit.Source = make(map[string]interface{})
+		}
+		hit.Source["_id"] = hit.ID
+		results = append(results, hit.Source)
+	}
+	return &results, nil
+}
+
+func caseInsensitiveTerm(field, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"term": map[string]interface{}{
+			field: map[string]interface{}{"value": value, "case_insensitive": true},
+		},
+	}
+}
+
+// SearchReceipts returns receipts matching the standard filters, as well as a full-text
+// query across all fields, and/or a contract address
+func (e *ElasticsearchReceipts) SearchReceipts(skip, limit int, ids []string, sinceEpochMS int64, from, to string, search *ReceiptSearch) (*[]map[string]interface{}, error) {
+	filters := []interface{}{}
+	must := []interface{}{}
+	if len(ids) > 0 {
+		filters = append(filters, map[string]interface{}{
+			"ids": map[string]interface{}{"values": ids},
+		})
+	}
+	if sinceEpochMS > 0 {
+		filters = append(filters, map[string]interface{}{
+			"range": map[string]interface{}{"receivedAt": map[string]interface{}{"gt": sinceEpochMS}},
+		})
+	}
+	if from != "" {
+		filters = append(filters, caseInsensitiveTerm("from", from))
+	}
+	if to != "" {
+		filters = append(filters, caseInsensitiveTerm("to", to))
+	}
+	if search != nil {
+		if search.Namespace != "" {
+			filters = append(filters, map[string]interface{}{
+				"term": map[string]interface{}{"namespace": search.Namespace},
+			})
+		}
+		if search.ContractAddress != "" {
+			filters = append(filters, caseInsensitiveTerm("contractAddress", search.ContractAddress))
+		}
+		if search.Text != "" {
+			must = append(must, map[string]interface{}{
+				"simple_query_string": map[string]interface{}{
+					"query":            search.Text,
+					"default_operator": "and",
+					"lenient":          true,
+				},
+			})
+		}
+	}
+	size := limit
+	if size <= 0 {
+		size = len(ids)
+		if size < e.conf.QueryLimit {
+			size = e.conf.QueryLimit
+		}
+	}
+	return e.search(map[string]interface{}{
+		"bool": map[string]interface{}{
+			"filter": filters,
+			"must":   must,
+		},
+	}, "desc", skip, size)
+}
+
+// SummarizeReceipts counts receipts by outcome with a single aggregation query
+func (e *ElasticsearchReceipts) SummarizeReceipts(sinceEpochMS int64) (*ReceiptSummary, error) {
+	query := map[string]interface{}{"match_all": map[string]interface{}{}}
+	if sinceEpochMS > 0 {
+		query = map[string]interface{}{
+			"range": map[string]interface{}{"receivedAt": map[string]interface{}{"gt": sinceEpochMS}},
+		}
+	}
+	body := map[string]interface{}{
+		"query":            query,
+		"size":             0,
+		"track_total_hits": true,
+		"aggs": map[string]interface{}{
+			"pending": map[string]interface{}{
+				"filter": map[string]interface{}{"term": map[string]interface{}{"pending": true}},
+			},
+			"types": map[string]interface{}{
+				"terms": map[string]interface{}{"field": "headers.type", "size": 20},
+			},
+		},
+	}
+	resBody, err := e.request("POST", "/"+url.PathEscape(e.conf.Index)+"/_search", "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+	var summaryRes esSummaryResponse
+	if err = json.Unmarshal(resBody, &summaryRes); err != nil {
+		return nil, errors.Errorf(errors.ReceiptStoreElasticsearchResponse, err)
+	}
+	summary := &ReceiptSummary{
+		Total:   summaryRes.Hits.Total.Value,
+		Pending: summaryRes.Aggregations.Pending.DocCount,
+	}
+	for _, bucket := range summaryRes.Aggregations.Types.Buckets {
+		switch bucket.Key {
+		case messages.MsgTypeTransactionSuccess:
+			summary.Success += bucket.DocCount
+		case messages.MsgTypeTransactionFailure:
+			summary.Failure += bucket.DocCount
+		case messages.MsgTypeError, messages.MsgTypeTransactionRedeliveryPrevented, messages.MsgTypeTransactionCancelled:
+			summary.Error += bucket.DocCount
+		}
+	}
+	return summary, nil
+}
+
+// GetReceipts Returns recent receipts with skip & limit
+func (e *ElasticsearchReceipts) GetReceipts(skip, limit int, ids []string, sinceEpochMS int64, from, to, start string) (*[]map[string]interface{}, error) {
+	return e.SearchReceipts(skip, limit, ids, sinceEpochMS, from, to, nil)
+}
+
+// GetNamespaceReceipts returns recent receipts in the namespace
+func (e *ElasticsearchReceipts) GetNamespaceReceipts(namespace string, skip, limit int, ids []string, sinceEpochMS int64, from, to, start string) (*[]map[string]interface{}, error) {
+	return e.SearchReceipts(skip, limit, ids, sinceEpochMS, from, to, &ReceiptSearch{Namespace: namespace})
+}
+
+// GetReceipt searches rather than using the document API, as after a rollover the
+// alias spans multiple indices
+func (e *ElasticsearchReceipts) GetReceipt(requestID string) (*map[string]interface{}, error) {
+	results, err := e.search(map[string]interface{}{
+		"ids": map[string]interface{}{"values": []string{requestID}},
+	}, "desc", 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(*results) == 0 {
+		return nil, nil
+	}
+	return &(*results)[0], nil
+}
+
+// esMaxPruneBatch is the most receipts we look past in one search when pruning by count,
+// which is the default Elasticsearch max_result_window
+const esMaxPruneBatch = 10000
+
+func (e *ElasticsearchReceipts) deleteByQuery(query map[string]interface{}) (int, error) {
+	resBody, err := e.request("POST", "/"+url.PathEscape(e.conf.Index)+"/_delete_by_query?conflicts=proceed", "application/json", map[string]interface{}{
+		"query": query,
+	})
+	if err != nil {
+		return 0, err
+	}
+	var res struct {
+		Deleted int `json:"deleted"`
+	}
+	if err = json.Unmarshal(resBody, &res); err != nil {
+		return 0, errors.Errorf(errors.ReceiptStoreElasticsearchResponse, err)
+	}
+	return res.Deleted, nil
+}
+
+// GetOldestReceipts returns receipts in the range, sorted oldest first
+func (e *ElasticsearchReceipts) GetOldestReceipts(afterEpochMS, beforeEpochMS int64, limit int) (*[]map[string]interface{}, error) {
+	return e.search(map[string]interface{}{
+		"range": map[string]interface{}{"receivedAt": map[string]interface{}{"gt": afterEpochMS, "lt": beforeEpochMS}},
+	}, "asc", 0, limit)
+}
+
+// PruneReceipts deletes receipts received before the cutoff. For the maximum count, the
+// receivedAt time of the newest surplus receipt is found by sorting oldest first, and
+// everything up to that time is deleted. Large backlogs are pruned in batches over
+// multiple calls.
+func (e *ElasticsearchReceipts) PruneReceipts(olderThanEpochMS int64, maxCount int) (int, error) {
+	pruned := 0
+	if olderThanEpochMS > 0 {
+		deleted, err := e.deleteByQuery(map[string]interface{}{
+			"range": map[string]interface{}{"receivedAt": map[string]interface{}{"lt": olderThanEpochMS}},
+		})
+		if err != nil {
+			return 0, err
+		}
+		pruned += deleted
+	}
+	if maxCount <= 0 {
+		return pruned, nil
+	}
+
+	resBody, err := e.request("POST", "/"+url.PathEscape(e.conf.Index)+"/_count", "application/json", map[string]interface{}{})
+	if err != nil {
+		return pruned, err
+	}
+	var countRes struct {
+		Count int `json:"count"`
+	}
+	if err = json.Unmarshal(resBody, &countRes); err != nil {
+		return pruned, errors.Errorf(errors.ReceiptStoreElasticsearchResponse, err)
+	}
+	excess := countRes.Count - maxCount
+	if excess <= 0 {
+		return pruned, nil
+	}
+	if excess > esMaxPruneBatch {
+		excess = esMaxPruneBatch
+	}
+	resBody, err = e.request("POST", "/"+url.PathEscape(e.conf.Index)+"/_search", "application/json", map[string]interface{}{
+		"query":   map[string]interface{}{"match_all": map[string]interface{}{}},
+		"sort":    []interface{}{map[string]interface{}{"receivedAt": map[string]string{"order": "asc"}}},
+		"from":    excess - 1,
+		"size":    1,
+		"_source": []string{"receivedAt"},
+	})
+	if err != nil {
+		return pruned, err
+	}
+	var searchRes esSearchResponse
+	if err = json.Unmarshal(resBody, &searchRes); err != nil {
+		return pruned, errors.Errorf(errors.ReceiptStoreElasticsearchResponse, err)
+	}
+	if len(searchRes.Hits.Hits) == 0 {
+		return pruned, nil
+	}
+	deleted, err := e.deleteByQuery(map[string]interface{}{
+		"range": map[string]interface{}{"receivedAt": map[string]interface{}{"lte": ReceiptReceivedAt(searchRes.Hits.Hits[0].Source)}},
+	})
+	return pruned + deleted, err
+}
+
+// DeleteReceipts deletes the receipts with the given request IDs. Receipts that are still
+// queued for a bulk write are not affected.
+func (e *ElasticsearchReceipts) DeleteReceipts(requestIDs []string) (int, error) {
+	if len(requestIDs) == 0 {
+		return 0, nil
+	}
+	return e.deleteByQuery(map[string]interface{}{
+		"ids": map[string]interface{}{"values": requestIDs},
+	})
+}
+
+// SummarizeReceiptGroups nests a terms aggregation for each field in groupBy, with a percentiles
+// aggregation of the elapsed time in each group
+func (e *ElasticsearchReceipts) SummarizeReceiptGroups(sinceEpochMS int64, groupBy []string) ([]*ReceiptGroup, error) {
+	query := map[string]interface{}{"match_all": map[string]interface{}{}}
+	if sinceEpochMS > 0 {
+		query = map[string]interface{}{
+			"range": map[string]interface{}{"receivedAt": map[string]interface{}{"gt": sinceEpochMS}},
+		}
+	}
+	statuses := map[string]string{
+		messages.MsgTypeTransactionSuccess: ReceiptStatusSuccess,
+		messages.MsgTypeTransactionFailure: ReceiptStatusFailure,
+	}
+	for _, t := range receiptErrorTypes {
+		statuses[t] = ReceiptStatusError
+	}
+	aggs := map[string]interface{}{
+		"latency": map[string]interface{}{
+			"percentiles": map[string]interface{}{"field": "headers.timeElapsed", "percents": []int{50, 90, 99}},
+		},
+	}
+	for i := len(groupBy) - 1; i >= 0; i-- {
+		terms := map[string]interface{}{"field": "method", "missing": "", "size": 1000}
+		if groupBy[i] == ReceiptGroupByStatus {
+			terms = map[string]interface{}{
+				"script": map[string]interface{}{
+					"source": esStatusScript,
+					"params": map[string]interface{}{
+						"statuses": statuses,
+						"pending":  ReceiptStatusPending,
+						"other":    ReceiptStatusOther,
+					},
+				},
+				"size": 10,
+			}
+		}
+		aggs = map[string]interface{}{
+			"group": map[string]interface{}{"terms": terms, "aggs": aggs},
+		}
+	}
+	body := map[string]interface{}{
+		"query": query,
+		"size":  0,
+		"aggs":  aggs,
+	}
+	resBody, err := e.request("POST", "/"+url.PathEscape(e.conf.Index)+"/_search", "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+	var groupRes esGroupResponse
+	if err = json.Unmarshal(resBody, &groupRes); err != nil {
+		return nil, errors.Errorf(errors.ReceiptStoreElasticsearchResponse, err)
+	}
+	var result []*ReceiptGroup
+	var collect func(agg *esGroupAggregation, level int, group ReceiptGroup)
+	collect = func(agg *esGroupAggregation, level int, group ReceiptGroup) {
+		for _, bucket := range agg.Buckets {
+			g := group
+			if groupBy[level] == ReceiptGroupByStatus {
+				g.Status = bucket.Key
+			} else {
+				g.Method = bucket.Key
+			}
+			if bucket.Group != nil && level < len(groupBy)-1 {
+				collect(bucket.Group, level+1, g)
+				continue
+			}
+			g.Count = bucket.DocCount
+			if bucket.Latency != nil && bucket.Latency.Values["50.0"] != nil {
+				g.Latency = &ReceiptLatency{
+					P50: *bucket.Latency.Values["50.0"],
+					P90: *bucket.Latency.Values["90.0"],
+					P99: *bucket.Latency.Values["99.0"],
+				}
+			}
+			result = append(result, &g)
+		}
+	}
+	collect(&groupRes.Aggregations.Group, 0, ReceiptGroup{})
+	return result, nil
+}
@@ -0,0 +1,559 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receipts
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockElasticsearch records requests, and replies with canned responses by method and path
+type mockElasticsearch struct {
+	mux       sync.Mutex
+	requests  []string
+	bodies    map[string][]byte
+	responses map[string]func(body []byte) (int, string)
+}
+
+func newMockElasticsearch() (*mockElasticsearch, *httptest.Server) {
+	m := &mockElasticsearch{
+		bodies:    make(map[string][]byte),
+		responses: make(map[string]func(body []byte) (int, string)),
+	}
+	return m, httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		key := req.Method + " " + req.URL.Path
+		body, _ := ioutil.ReadAll(req.Body)
+		m.mux.Lock()
+		m.requests = append(m.requests, key)
+		m.bodies[key] = body
+		handler := m.responses[key]
+		m.mux.Unlock()
+		status, reply := 200, "{}"
+		if handler != nil {
+			status, reply = handler(body)
+		}
+		res.WriteHeader(status)
+		_, _ = res.Write([]byte(reply))
+	}))
+}
+
+func (m *mockElasticsearch) on(key string, status int, reply string) {
+	m.responses[key] = func([]byte) (int, string) { return status, reply }
+}
+
+// bulkEcho replies to every item in a bulk request with the supplied status
+func bulkEcho(status int) func(body []byte) (int, string) {
+	return func(body []byte) (int, string) {
+		var items []map[string]interface{}
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		for i := 0; scanner.Scan(); i++ {
+			if i%2 == 0 {
+				var action map[string]map[string]string
+				_ = json.Unmarshal(scanner.Bytes(), &action)
+				for a, meta := range action {
+					item := map[string]interface{}{"_id": meta["_id"], "status": status}
+					if status >= 300 {
+						item["error"] = map[string]string{"type": "version_conflict_engine_exception"}
+					}
+					items = append(items, map[string]interface{}{a: item})
+				}
+			}
+		}
+		b, _ := json.Marshal(map[string]interface{}{"errors": status >= 300, "items": items})
+		return 200, string(b)
+	}
+}
+
+func newTestElasticsearchReceipts(t *testing.T, url string, conf *ElasticsearchReceiptStoreConf) *ElasticsearchReceipts {
+	conf.URL = url
+	e := NewElasticsearchReceipts(conf)
+	err := e.Connect()
+	assert.NoError(t, err)
+	return e
+}
+
+func TestElasticsearchConnectCreatesPolicyTemplateAndIndex(t *testing.T) {
+	assert := assert.New(t)
+	m, svr := newMockElasticsearch()
+	defer svr.Close()
+	m.on("HEAD /_alias/receipts", 404, "")
+
+	e := newTestElasticsearchReceipts(t, svr.URL, &ElasticsearchReceiptStoreConf{
+		Index:         "receipts",
+		RetentionDays: 30,
+		Username:      "user",
+		Password:      "pass",
+	})
+	defer e.Close()
+
+	assert.Equal([]string{
+		"PUT /_ilm/policy/receipts-policy",
+		"HEAD /_alias/receipts",
+		"PUT /receipts-000001",
+	}, []string{m.requests[0], m.requests[2], m.requests[3]})
+	assert.Equal("PUT /_index_template/receipts", m.requests[1])
+	assert.Contains(string(m.bodies["PUT /_ilm/policy/receipts-policy"]), `"min_age":"30d"`)
+	assert.Contains(string(m.bodies["PUT /_index_template/receipts"]), `"index.lifecycle.rollover_alias":"receipts"`)
+	assert.Contains(string(m.bodies["PUT /receipts-000001"]), `"is_write_index":true`)
+}
+
+func TestElasticsearchConnectExistingAlias(t *testing.T) {
+	assert := assert.New(t)
+	m, svr := newMockElasticsearch()
+	defer svr.Close()
+
+	e := newTestElasticsearchReceipts(t, svr.URL+"/", &ElasticsearchReceiptStoreConf{})
+	defer e.Close()
+	assert.Equal([]string{
+		"PUT /_index_template/ethconnect-receipts",
+		"HEAD /_alias/ethconnect-receipts",
+	}, m.requests)
+}
+
+func TestElasticsearchConnectFailures(t *testing.T) {
+	assert := assert.New(t)
+	m, svr := newMockElasticsearch()
+	defer svr.Close()
+
+	m.on("PUT /_ilm/policy/ethconnect-receipts-policy", 403, `{"error":"forbidden"}`)
+	err := NewElasticsearchReceipts(&ElasticsearchReceiptStoreConf{URL: svr.URL, RetentionDays: 1}).Connect()
+	assert.Regexp("FFEC100239.*forbidden", err)
+
+	m.on("PUT /_index_template/ethconnect-receipts", 500, `{}`)
+	err = NewElasticsearchReceipts(&ElasticsearchReceiptStoreConf{URL: svr.URL}).Connect()
+	assert.Regexp("FFEC100239", err)
+
+	m.on("PUT /_index_template/ethconnect-receipts", 200, `{}`)
+	m.on("HEAD /_alias/ethconnect-receipts", 500, "")
+	err = NewElasticsearchReceipts(&ElasticsearchReceiptStoreConf{URL: svr.URL}).Connect()
+	assert.Regexp("FFEC100239", err)
+
+	m.on("HEAD /_alias/ethconnect-receipts", 404, "")
+	m.on("PUT /ethconnect-receipts-000001", 400, `{"error":{"type":"resource_already_exists_exception"}}`)
+	e := NewElasticsearchReceipts(&ElasticsearchReceiptStoreConf{URL: svr.URL})
+	assert.NoError(e.Connect())
+	e.Close()
+
+	m.on("PUT /ethconnect-receipts-000001", 400, `{"error":{"type":"other"}}`)
+	err = NewElasticsearchReceipts(&ElasticsearchReceiptStoreConf{URL: svr.URL}).Connect()
+	assert.Regexp("FFEC100239", err)
+
+	err = NewElasticsearchReceipts(&ElasticsearchReceiptStoreConf{URL: "http://localhost:0"}).Connect()
+	assert.Regexp("FFEC100239", err)
+
+	badTLS := &ElasticsearchReceiptStoreConf{URL: svr.URL}
+	badTLS.TLS.Enabled = true
+	badTLS.TLS.CACertsFile = "/non/existent/ca.pem"
+	err = NewElasticsearchReceipts(badTLS).Connect()
+	assert.Error(err)
+}
+
+func TestElasticsearchAddReceiptsBulk(t *testing.T) {
+	assert := assert.New(t)
+	m, svr := newMockElasticsearch()
+	defer svr.Close()
+	m.responses["POST /ethconnect-receipts/_bulk"] = bulkEcho(201)
+
+	e := newTestElasticsearchReceipts(t, svr.URL, &ElasticsearchReceiptStoreConf{BulkMaxDocs: 3, BulkFlushMS: 50})
+	defer e.Close()
+
+	wg := sync.WaitGroup{}
+	for _, id := range []string{"r1", "r2", "r3"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			err := e.AddReceipt(id, &map[string]interface{}{"_id": id, "receivedAt": 12345}, id != "r3")
+			assert.NoError(err)
+		}(id)
+	}
+	wg.Wait()
+
+	body := string(m.bodies["POST /ethconnect-receipts/_bulk"])
+	assert.Contains(body, `{"create":{"_id":"r3"}}`)
+	assert.Contains(body, `{"index":{"_id":"r1"}}`)
+	assert.NotContains(body, `"_id":"r1","receivedAt"`)
+	assert.Equal(7, len(strings.Split(body, "\n")))
+}
+
+func TestElasticsearchAddReceiptsBatch(t *testing.T) {
+	assert := assert.New(t)
+	m, svr := newMockElasticsearch()
+	defer svr.Close()
+	m.responses["POST /ethconnect-receipts/_bulk"] = bulkEcho(201)
+
+	e := newTestElasticsearchReceipts(t, svr.URL, &ElasticsearchReceiptStoreConf{BulkMaxDocs: 2, BulkFlushMS: 60000})
+
+	err := e.AddReceipts([]map[string]interface{}{
+		{"_id": "r1", "receivedAt": 1},
+		{"_id": "r2", "receivedAt": 2},
+	})
+	assert.NoError(err)
+	body := string(m.bodies["POST /ethconnect-receipts/_bulk"])
+	assert.Contains(body, `{"index":{"_id":"r1"}}`)
+	assert.Contains(body, `{"index":{"_id":"r2"}}`)
+
+	err = e.AddReceipts([]map[string]interface{}{
+		{"_id": "r3", "bad": map[bool]bool{true: true}},
+	})
+	assert.Regexp("FFEC100243", err)
+
+	e.Close()
+	err = e.AddReceipts([]map[string]interface{}{{"_id": "r4"}})
+	assert.Regexp("FFEC100245", err)
+}
+
+func TestElasticsearchAddReceiptItemFailure(t *testing.T) {
+	assert := assert.New(t)
+	m, svr := newMockElasticsearch()
+	defer svr.Close()
+	m.responses["POST /ethconnect-receipts/_bulk"] = bulkEcho(409)
+
+	e := newTestElasticsearchReceipts(t, svr.URL, &ElasticsearchReceiptStoreConf{BulkFlushMS: 1})
+	defer e.Close()
+
+	err := e.AddReceipt("r1", &map[string]interface{}{}, false)
+	assert.Regexp("FFEC100241.*409.*version_conflict", err)
+}
+
+func TestElasticsearchAddReceiptBulkFailures(t *testing.T) {
+	assert := assert.New(t)
+	m, svr := newMockElasticsearch()
+	defer svr.Close()
+
+	e := newTestElasticsearchReceipts(t, svr.URL, &ElasticsearchReceiptStoreConf{BulkFlushMS: 1})
+	defer e.Close()
+
+	m.on("POST /ethconnect-receipts/_bulk", 500, `{}`)
+	err := e.AddReceipt("r1", &map[string]interface{}{}, true)
+	assert.Regexp("FFEC100241", err)
+
+	m.on("POST /ethconnect-receipts/_bulk", 200, `{"items":[]}`)
+	err = e.AddReceipt("r1", &map[string]interface{}{}, true)
+	assert.Regexp("FFEC100244", err)
+
+	m.on("POST /ethconnect-receipts/_bulk", 200, `!json`)
+	err = e.AddReceipt("r1", &map[string]interface{}{}, true)
+	assert.Error(err)
+
+	err = e.AddReceipt("r1", &map[string]interface{}{"bad": map[bool]bool{true: true}}, true)
+	assert.Regexp("FFEC100243", err)
+}
+
+func TestElasticsearchAddReceiptClosed(t *testing.T) {
+	_, svr := newMockElasticsearch()
+	defer svr.Close()
+	e := newTestElasticsearchReceipts(t, svr.URL, &ElasticsearchReceiptStoreConf{})
+	e.Close()
+	err := e.AddReceipt("r1", &map[string]interface{}{}, true)
+	assert.Regexp(t, "FFEC100245", err)
+}
+
+func TestElasticsearchSearchReceipts(t *testing.T) {
+	assert := assert.New(t)
+	m, svr := newMockElasticsearch()
+	defer svr.Close()
+	m.on("POST /ethconnect-receipts/_search", 200, `{"hits":{"hits":[
+		{"_id":"r2","_source":{"receivedAt":2}},
+		{"_id":"r1"}
+	]}}`)
+
+	e := newTestElasticsearchReceipts(t, svr.URL, &ElasticsearchReceiptStoreConf{ReceiptStoreConf: ReceiptStoreConf{QueryLimit: 50}})
+	defer e.Close()
+
+	results, err := e.SearchReceipts(5, 0, []string{"r1", "r2"}, 1000, "0xAAA", "0xBBB", &ReceiptSearch{
+		Text:            "out of gas",
+		ContractAddress: "0xCCC",
+	})
+	assert.NoError(err)
+	assert.Len(*results, 2)
+	assert.Equal("r2", (*results)[0]["_id"])
+	assert.Equal(float64(2), (*results)[0]["receivedAt"])
+	assert.Equal("r1", (*results)[1]["_id"])
+
+	var query map[string]interface{}
+	assert.NoError(json.Unmarshal(m.bodies["POST /ethconnect-receipts/_search"], &query))
+	assert.Equal(float64(5), query["from"])
+	assert.Equal(float64(50), query["size"])
+	boolQuery := query["query"].(map[string]interface{})["bool"].(map[string]interface{})
+	assert.Len(boolQuery["filter"], 5)
+	assert.Len(boolQuery["must"], 1)
+
+	results, err = e.GetReceipts(0, 10, nil, 0, "", "", "")
+	assert.NoError(err)
+	assert.Len(*results, 2)
+	assert.NoError(json.Unmarshal(m.bodies["POST /ethconnect-receipts/_search"], &query))
+	assert.Equal(float64(10), query["size"])
+	boolQuery = query["query"].(map[string]interface{})["bool"].(map[string]interface{})
+	assert.Len(boolQuery["filter"], 0)
+
+	_, err = e.GetNamespaceReceipts("tenant1", 0, 10, nil, 0, "", "", "")
+	assert.NoError(err)
+	assert.NoError(json.Unmarshal(m.bodies["POST /ethconnect-receipts/_search"], &query))
+	boolQuery = query["query"].(map[string]interface{})["bool"].(map[string]interface{})
+	assert.Equal([]interface{}{
+		map[string]interface{}{"term": map[string]interface{}{"namespace": "tenant1"}},
+	}, boolQuery["filter"])
+}
+
+func TestElasticsearchSearchFailures(t *testing.T) {
+	assert := assert.New(t)
+	m, svr := newMockElasticsearch()
+	defer svr.Close()
+	e := newTestElasticsearchReceipts(t, svr.URL, &ElasticsearchReceiptStoreConf{})
+	defer e.Close()
+
+	m.on("POST /ethconnect-receipts/_search", 400, `{}`)
+	_, err := e.GetReceipts(0, 10, nil, 0, "", "", "")
+	assert.Regexp("FFEC100241", err)
+	_, err = e.GetReceipt("r1")
+	assert.Regexp("FFEC100241", err)
+
+	m.on("POST /ethconnect-receipts/_search", 200, `!json`)
+	_, err = e.GetReceipts(0, 10, nil, 0, "", "", "")
+	assert.Regexp("FFEC100242", err)
+}
+
+func TestElasticsearchGetReceipt(t *testing.T) {
+	assert := assert.New(t)
+	m, svr := newMockElasticsearch()
+	defer svr.Close()
+	e := newTestElasticsearchReceipts(t, svr.URL, &ElasticsearchReceiptStoreConf{})
+	defer e.Close()
+
+	m.on("POST /ethconnect-receipts/_search", 200, `{"hits":{"hits":[{"_id":"r1","_source":{"status":"1"}}]}}`)
+	result, err := e.GetReceipt("r1")
+	assert.NoError(err)
+	assert.Equal("r1", (*result)["_id"])
+	assert.Equal("1", (*result)["status"])
+	assert.Contains(string(m.bodies["POST /ethconnect-receipts/_search"]), `"ids":{"values":["r1"]}`)
+
+	m.on("POST /ethconnect-receipts/_search", 200, `{"hits":{"hits":[]}}`)
+	result, err = e.GetReceipt("r2")
+	assert.NoError(err)
+	assert.Nil(result)
+}
+
+func TestElasticsearchPruneReceipts(t *testing.T) {
+	assert := assert.New(t)
+	m, svr := newMockElasticsearch()
+	defer svr.Close()
+	e := newTestElasticsearchReceipts(t, svr.URL, &ElasticsearchReceiptStoreConf{Index: "receipts"})
+	defer e.Close()
+
+	m.on("POST /receipts/_delete_by_query", 200, `{"deleted":3}`)
+	m.on("POST /receipts/_count", 200, `{"count":15}`)
+	m.on("POST /receipts/_search", 200, `{"hits":{"hits":[{"_id":"r5","_source":{"receivedAt":5000}}]}}`)
+
+	pruned, err := e.PruneReceipts(1000, 10)
+	assert.NoError(err)
+	assert.Equal(6, pruned)
+	assert.Contains(string(m.bodies["POST /receipts/_search"]), `"from":4`)
+	assert.Contains(string(m.bodies["POST /receipts/_search"]), `"order":"asc"`)
+	assert.Contains(string(m.bodies["POST /receipts/_delete_by_query"]), `"lte":5000`)
+}
+
+func TestElasticsearchPruneReceiptsUnderMaxCount(t *testing.T) {
+	assert := assert.New(t)
+	m, svr := newMockElasticsearch()
+	defer svr.Close()
+	e := newTestElasticsearchReceipts(t, svr.URL, &ElasticsearchReceiptStoreConf{Index: "receipts"})
+	defer e.Close()
+
+	m.on("POST /receipts/_count", 200, `{"count":5}`)
+	pruned, err := e.PruneReceipts(0, 10)
+	assert.NoError(err)
+	assert.Equal(0, pruned)
+
+	m.on("POST /receipts/_count", 200, `{"count":50000}`)
+	m.on("POST /receipts/_search", 200, `{"hits":{"hits":[]}}`)
+	pruned, err = e.PruneReceipts(0, 10)
+	assert.NoError(err)
+	assert.Equal(0, pruned)
+	assert.Contains(string(m.bodies["POST /receipts/_search"]), `"from":9999`)
+}
+
+func TestElasticsearchPruneReceiptsFailures(t *testing.T) {
+	assert := assert.New(t)
+	m, svr := newMockElasticsearch()
+	defer svr.Close()
+	e := newTestElasticsearchReceipts(t, svr.URL, &ElasticsearchReceiptStoreConf{Index: "receipts"})
+	defer e.Close()
+
+	m.on("POST /receipts/_delete_by_query", 500, `{}`)
+	_, err := e.PruneReceipts(1000, 0)
+	assert.Regexp("FFEC100241", err)
+
+	m.on("POST /receipts/_delete_by_query", 200, `!json`)
+	_, err = e.PruneReceipts(1000, 0)
+	assert.Regexp("FFEC100242", err)
+
+	m.on("POST /receipts/_count", 500, `{}`)
+	_, err = e.PruneReceipts(0, 10)
+	assert.Regexp("FFEC100241", err)
+
+	m.on("POST /receipts/_count", 200, `!json`)
+	_, err = e.PruneReceipts(0, 10)
+	assert.Regexp("FFEC100242", err)
+
+	m.on("POST /receipts/_count", 200, `{"count":15}`)
+	m.on("POST /receipts/_search", 500, `{}`)
+	_, err = e.PruneReceipts(0, 10)
+	assert.Regexp("FFEC100241", err)
+
+	m.on("POST /receipts/_search", 200, `!json`)
+	_, err = e.PruneReceipts(0, 10)
+	assert.Regexp("FFEC100242", err)
+}
+
+func TestElasticsearchDeleteReceipts(t *testing.T) {
+	assert := assert.New(t)
+	m, svr := newMockElasticsearch()
+	defer svr.Close()
+	e := newTestElasticsearchReceipts(t, svr.URL, &ElasticsearchReceiptStoreConf{Index: "receipts"})
+	defer e.Close()
+
+	deleted, err := e.DeleteReceipts(nil)
+	assert.NoError(err)
+	assert.Equal(0, deleted)
+
+	m.on("POST /receipts/_delete_by_query", 200, `{"deleted":2}`)
+	deleted, err = e.DeleteReceipts([]string{"r1", "r2"})
+	assert.NoError(err)
+	assert.Equal(2, deleted)
+	assert.Contains(string(m.bodies["POST /receipts/_delete_by_query"]), `{"ids":{"values":["r1","r2"]}}`)
+
+	m.on("POST /receipts/_delete_by_query", 500, `{}`)
+	_, err = e.DeleteReceipts([]string{"r1"})
+	assert.Regexp("FFEC100241", err)
+}
+
+func TestElasticsearchGetOldestReceipts(t *testing.T) {
+	assert := assert.New(t)
+	m, svr := newMockElasticsearch()
+	defer svr.Close()
+	e := newTestElasticsearchReceipts(t, svr.URL, &ElasticsearchReceiptStoreConf{Index: "receipts"})
+	defer e.Close()
+
+	m.on("POST /receipts/_search", 200, `{"hits":{"hits":[{"_id":"r1","_source":{"receivedAt":1001}}]}}`)
+	results, err := e.GetOldestReceipts(1000, 2000, 10)
+	assert.NoError(err)
+	assert.Len(*results, 1)
+	assert.Equal("r1", (*results)[0]["_id"])
+	body := string(m.bodies["POST /receipts/_search"])
+	assert.Contains(body, `"order":"asc"`)
+	assert.Contains(body, `"size":10`)
+	assert.Contains(body, `"receivedAt":{"gt":1000,"lt":2000}`)
+}
+
+func TestElasticsearchSummarizeReceipts(t *testing.T) {
+	assert := assert.New(t)
+	m, svr := newMockElasticsearch()
+	defer svr.Close()
+	m.on("POST /ethconnect-receipts/_search", 200, `{
+		"hits": {"total": {"value": 11}},
+		"aggregations": {
+			"pending": {"doc_count": 1},
+			"types": {"buckets": [
+				{"key": "TransactionSuccess", "doc_count": 5},
+				{"key": "TransactionFailure", "doc_count": 2},
+				{"key": "Error", "doc_count": 1},
+				{"key": "TransactionRedeliveryPrevented", "doc_count": 1},
+				{"key": "TransactionCancelled", "doc_count": 1},
+				{"key": "SendTransaction", "doc_count": 1}
+			]}
+		}
+	}`)
+
+	e := newTestElasticsearchReceipts(t, svr.URL, &ElasticsearchReceiptStoreConf{})
+	defer e.Close()
+
+	summary, err := e.SummarizeReceipts(1000)
+	assert.NoError(err)
+	assert.Equal(&ReceiptSummary{Total: 11, Success: 5, Failure: 2, Error: 3, Pending: 1}, summary)
+
+	var query map[string]interface{}
+	assert.NoError(json.Unmarshal(m.bodies["POST /ethconnect-receipts/_search"], &query))
+	assert.Equal(float64(0), query["size"])
+	assert.Equal(float64(1000), query["query"].(map[string]interface{})["range"].(map[string]interface{})["receivedAt"].(map[string]interface{})["gt"])
+
+	_, err = e.SummarizeReceipts(0)
+	assert.NoError(err)
+	assert.NoError(json.Unmarshal(m.bodies["POST /ethconnect-receipts/_search"], &query))
+	assert.NotNil(query["query"].(map[string]interface{})["match_all"])
+
+	m.on("POST /ethconnect-receipts/_search", 500, `{}`)
+	_, err = e.SummarizeReceipts(0)
+	assert.Regexp("FFEC100241", err)
+
+	m.on("POST /ethconnect-receipts/_search", 200, `!json`)
+	_, err = e.SummarizeReceipts(0)
+	assert.Regexp("FFEC100242", err)
+}
+
+func TestElasticsearchSummarizeReceiptGroups(t *testing.T) {
+	assert := assert.New(t)
+	m, svr := newMockElasticsearch()
+	defer svr.Close()
+	m.on("POST /ethconnect-receipts/_search", 200, `{
+		"aggregations": {
+			"group": {"buckets": [
+				{"key": "success", "doc_count": 3, "group": {"buckets": [
+					{"key": "set", "doc_count": 2, "latency": {"values": {"50.0": 1, "90.0": 3, "99.0": 3}}},
+					{"key": "get", "doc_count": 1, "latency": {"values": {"50.0": 0.5, "90.0": 0.5, "99.0": 0.5}}}
+				]}},
+				{"key": "pending", "doc_count": 1, "group": {"buckets": [
+					{"key": "set", "doc_count": 1, "latency": {"values": {"50.0": null, "90.0": null, "99.0": null}}}
+				]}}
+			]}
+		}
+	}`)
+
+	e := newTestElasticsearchReceipts(t, svr.URL, &ElasticsearchReceiptStoreConf{})
+	defer e.Close()
+
+	groups, err := e.SummarizeReceiptGroups(1000, []string{ReceiptGroupByStatus, ReceiptGroupByMethod})
+	assert.NoError(err)
+	assert.Equal([]*ReceiptGroup{
+		{Status: "success", Method: "set", Count: 2, Latency: &ReceiptLatency{P50: 1, P90: 3, P99: 3}},
+		{Status: "success", Method: "get", Count: 1, Latency: &ReceiptLatency{P50: 0.5, P90: 0.5, P99: 0.5}},
+		{Status: "pending", Method: "set", Count: 1},
+	}, groups)
+
+	var query map[string]interface{}
+	assert.NoError(json.Unmarshal(m.bodies["POST /ethconnect-receipts/_search"], &query))
+	assert.Equal(float64(1000), query["query"].(map[string]interface{})["range"].(map[string]interface{})["receivedAt"].(map[string]interface{})["gt"])
+	statusAgg := query["aggs"].(map[string]interface{})["group"].(map[string]interface{})
+	assert.NotNil(statusAgg["terms"].(map[string]interface{})["script"])
+	methodAgg := statusAgg["aggs"].(map[string]interface{})["group"].(map[string]interface{})
+	assert.Equal("method", methodAgg["terms"].(map[string]interface{})["field"])
+	assert.NotNil(methodAgg["aggs"].(map[string]interface{})["latency"])
+
+	m.on("POST /ethconnect-receipts/_search", 500, `{}`)
+	_, err = e.SummarizeReceiptGroups(0, []string{ReceiptGroupByMethod})
+	assert.Regexp("FFEC100241", err)
+
+	m.on("POST /ethconnect-receipts/_search", 200, `!json`)
+	_, err = e.SummarizeReceiptGroups(0, []string{ReceiptGroupByMethod})
+	assert.Regexp("FFEC100242", err)
+}
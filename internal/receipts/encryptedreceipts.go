@@ -0,0 +1,368 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receipts
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultDEKRotationCount = 1000
+	dataKeyCacheSize        = 100
+	encryptedReceiptField   = "encrypted"
+)
+
+// ReceiptEncryptionConf configures envelope encryption of receipts before they are written to
+// the receipt store. Each receipt is encrypted with AES-256-GCM using a data encryption key,
+// which is itself encrypted with the key encryption key from keyFile, keyEnv or Vault.
+type ReceiptEncryptionConf struct {
+	Enabled          bool             `json:"enabled"`
+	KeyID            string           `json:"keyId,omitempty"`
+	KeyFile          string           `json:"keyFile,omitempty"`
+	KeyEnv           string           `json:"keyEnv,omitempty"`
+	Vault            VaultTransitConf `json:"vault,omitempty"`
+	DEKRotationCount int              `json:"dekRotationCount,omitempty"`
+}
+
+// plaintextReceiptFields are stored unencrypted alongside the envelope, as the persistence
+// layers filter, sort and summarize on them
+var plaintextReceiptFields = []string{"_id", "receivedAt", "pending", "namespace", "from", "to", "contractAddress", "method", ReceiptRevisionField}
+
+var plaintextHeaderFields = []string{"requestId", "type", "timeElapsed"}
+
+type encryptedEnvelope struct {
+	KeyID string `json:"keyId"`
+	DEK   string `json:"dek"`
+	Data  string `json:"data"`
+}
+
+type dataKey struct {
+	wrapped string
+	aead    cipher.AEAD
+	uses    int
+}
+
+// EncryptedReceipts encrypts receipts before they are written to another persistence layer,
+// and decrypts them transparently on read. Receipts written before encryption was enabled
+// are returned as they are. All of the optional interfaces are implemented, but each returns
+// an error if the layer beneath does not implement it - see ReceiptStoreWrapper.
+type EncryptedReceipts struct {
+	ReceiptStorePersistence
+	conf     *ReceiptEncryptionConf
+	kek      keyWrapper
+	mux      sync.Mutex
+	current  *dataKey
+	dekCache *lru.Cache
+}
+
+// NewEncryptedReceipts wraps a persistence layer with envelope encryption
+func NewEncryptedReceipts(conf *ReceiptEncryptionConf, persistence ReceiptStorePersistence) (ReceiptStorePersistence, error) {
+	return newEncryptedReceipts(conf, persistence)
+}
+
+func newEncryptedReceipts(conf *ReceiptEncryptionConf, persistence ReceiptStorePersistence) (*EncryptedReceipts, error) {
+	kek, err := newKeyWrapper(conf)
+	if err != nil {
+		return nil, err
+	}
+	if conf.DEKRotationCount <= 0 {
+		conf.DEKRotationCount = defaultDEKRotationCount
+	}
+	dekCache, _ := lru.New(dataKeyCacheSize)
+	log.Infof("Receipt encryption enabled keyId=%s dekRotationCount=%d", kek.keyID(), conf.DEKRotationCount)
+	return &EncryptedReceipts{
+		ReceiptStorePersistence: persistence,
+		conf:                    conf,
+		kek:                     kek,
+		dekCache:                dekCache,
+	}, nil
+}
+
+// dataKeyForWrite returns the current data encryption key, generating and wrapping a new
+// one after it has been used for the configured number of receipts
+func (e *EncryptedReceipts) dataKeyForWrite() (*dataKey, error) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if e.current == nil || e.current.uses >= e.conf.DEKRotationCount {
+		dek := make([]byte, 32)
+		if _, err := rand.Read(dek); err != nil {
+			return nil, err
+		}
+		wrapped, err := e.kek.wrap(dek)
+		if err != nil {
+			return nil, err
+		}
+		aead, _ := newAESGCM(dek)
+		e.current = &dataKey{wrapped: wrapped, aead: aead}
+		e.dekCache.Add(wrapped, aead)
+	}
+	e.current.uses++
+	return e.current, nil
+}
+
+func (e *EncryptedReceipts) dataKeyForRead(wrapped string) (cipher.AEAD, error) {
+	if aead, ok := e.dekCache.Get(wrapped); ok {
+		return aead.(cipher.AEAD), nil
+	}
+	dek, err := e.kek.unwrap(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAESGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+	e.dekCache.Add(wrapped, aead)
+	return aead, nil
+}
+
+// receiptAdditionalData binds an envelope to the plaintext request ID and namespace stored
+// alongside it, so an envelope copied to another receipt cannot be decrypted
+func receiptAdditionalData(receipt map[string]interface{}) []byte {
+	b, _ := json.Marshal([]string{utils.GetMapString(receipt, "_id"), utils.GetMapString(receipt, "namespace")})
+	return b
+}
+
+func (e *EncryptedReceipts) encrypt(receipt map[string]interface{}) (map[string]interface{}, error) {
+	requestID := utils.GetMapString(receipt, "_id")
+	plaintext, err := json.Marshal(receipt)
+	if err != nil {
+		return nil, errors.Errorf(errors.ReceiptStoreEncryptFailed, requestID, err)
+	}
+	dk, err := e.dataKeyForWrite()
+	if err != nil {
+		return nil, errors.Errorf(errors.ReceiptStoreEncryptFailed, requestID, err)
+	}
+	sealed, err := seal(dk.aead, plaintext, receiptAdditionalData(receipt))
+	if err != nil {
+		return nil, errors.Errorf(errors.ReceiptStoreEncryptFailed, requestID, err)
+	}
+
+	stored := make(map[string]interface{})
+	for _, f := range plaintextReceiptFields {
+		if v, ok := receipt[f]; ok {
+			stored[f] = v
+		}
+	}
+	if headers, ok := receipt["headers"].(map[string]interface{}); ok {
+		storedHeaders := make(map[string]interface{})
+		for _, f := range plaintextHeaderFields {
+			if v, ok := headers[f]; ok {
+				storedHeaders[f] = v
+			}
+		}
+		stored["headers"] = storedHeaders
+	}
+	stored[encryptedReceiptField] = &encryptedEnvelope{
+		KeyID: e.kek.keyID(),
+		DEK:   dk.wrapped,
+		Data:  base64.StdEncoding.EncodeToString(sealed),
+	}
+	return stored, nil
+}
+
+func (e *EncryptedReceipts) decrypt(stored map[string]interface{}) (map[string]interface{}, error) {
+	envelopeField, isEncrypted := stored[encryptedReceiptField]
+	if !isEncrypted {
+		return stored, nil
+	}
+	requestID := utils.GetMapString(stored, "_id")
+	var envelope encryptedEnvelope
+	b, _ := json.Marshal(envelopeField)
+	if err := json.Unmarshal(b, &envelope); err != nil {
+		return nil, errors.Errorf(errors.ReceiptStoreDecryptFailed, requestID, err)
+	}
+	aead, err := e.dataKeyForRead(envelope.DEK)
+	if err != nil {
+		return nil, errors.Errorf(errors.ReceiptStoreDecryptFailed, requestID, err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(envelope.Data)
+	if err != nil {
+		return nil, errors.Errorf(errors.ReceiptStoreDecryptFailed, requestID, err)
+	}
+	plaintext, err := open(aead, sealed, receiptAdditionalData(stored))
+	if err != nil {
+		return nil, errors.Errorf(errors.ReceiptStoreDecryptFailed, requestID, err)
+	}
+	var receipt map[string]interface{}
+	if err := json.Unmarshal(plaintext, &receipt); err != nil {
+		return nil, errors.Errorf(errors.ReceiptStoreDecryptFailed, requestID, err)
+	}
+	return receipt, nil
+}
+
+func (e *EncryptedReceipts) decryptAll(stored *[]map[string]interface{}, err error) (*[]map[string]interface{}, error) {
+	if err != nil || stored == nil {
+		return stored, err
+	}
+	results := make([]map[string]interface{}, len(*stored))
+	for i, s := range *stored {
+		if results[i], err = e.decrypt(s); err != nil {
+			return nil, err
+		}
+	}
+	return &results, nil
+}
+
+// GetReceipts decrypts the receipts returned by the underlying persistence layer
+func (e *EncryptedReceipts) GetReceipts(skip, limit int, ids []string, sinceEpochMS int64, from, to, start string) (*[]map[string]interface{}, error) {
+	return e.decryptAll(e.ReceiptStorePersistence.GetReceipts(skip, limit, ids, sinceEpochMS, from, to, start))
+}
+
+// GetReceipt decrypts a single receipt
+func (e *EncryptedReceipts) GetReceipt(requestID string) (*map[string]interface{}, error) {
+	stored, err := e.ReceiptStorePersistence.GetReceipt(requestID)
+	if err != nil || stored == nil {
+		return stored, err
+	}
+	receipt, err := e.decrypt(*stored)
+	if err != nil {
+		return nil, err
+	}
+	return &receipt, nil
+}
+
+// AddReceipt encrypts the receipt before it is written
+func (e *EncryptedReceipts) AddReceipt(requestID string, receipt *map[string]interface{}, overwriteAndRetry bool) error {
+	stored, err := e.encrypt(*receipt)
+	if err != nil {
+		return err
+	}
+	return e.ReceiptStorePersistence.AddReceipt(requestID, &stored, overwriteAndRetry)
+}
+
+// AddReceipts encrypts each receipt, and writes them in a batch if the underlying
+// persistence layer supports it
+func (e *EncryptedReceipts) AddReceipts(receipts []map[string]interface{}) error {
+	stored := make([]map[string]interface{}, len(receipts))
+	for i, receipt := range receipts {
+		var err error
+		if stored[i], err = e.encrypt(receipt); err != nil {
+			return err
+		}
+	}
+	if batcher, ok := e.ReceiptStorePersistence.(ReceiptStoreBatchWriter); ok {
+		return batcher.AddReceipts(stored)
+	}
+	for i := range stored {
+		if err := e.ReceiptStorePersistence.AddReceipt(utils.GetMapString(stored[i], "_id"), &stored[i], true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WrappedPersistence returns the persistence layer beneath the encryption layer, which
+// determines the optional interfaces that are available
+func (e *EncryptedReceipts) WrappedPersistence() ReceiptStorePersistence {
+	return e.ReceiptStorePersistence
+}
+
+// SearchReceipts passes the search to the underlying persistence layer. Text search only
+// matches the fields that are stored in plaintext.
+func (e *EncryptedReceipts) SearchReceipts(skip, limit int, ids []string, sinceEpochMS int64, from, to string, search *ReceiptSearch) (*[]map[string]interface{}, error) {
+	searcher, ok := AsSearcher(e.ReceiptStorePersistence)
+	if !ok {
+		return nil, errors.Errorf(errors.ReceiptStoreSearchNotSupported)
+	}
+	return e.decryptAll(searcher.SearchReceipts(skip, limit, ids, sinceEpochMS, from, to, search))
+}
+
+// GetNamespaceReceipts decrypts the receipts in a namespace
+func (e *EncryptedReceipts) GetNamespaceReceipts(namespace string, skip, limit int, ids []string, sinceEpochMS int64, from, to, start string) (*[]map[string]interface{}, error) {
+	nsReader, ok := AsNamespaceReader(e.ReceiptStorePersistence)
+	if !ok {
+		return nil, errors.Errorf(errors.ReceiptStoreNamespaceNotSupported)
+	}
+	return e.decryptAll(nsReader.GetNamespaceReceipts(namespace, skip, limit, ids, sinceEpochMS, from, to, start))
+}
+
+// GetOldestReceipts decrypts the receipts read for archiving
+func (e *EncryptedReceipts) GetOldestReceipts(afterEpochMS, beforeEpochMS int64, limit int) (*[]map[string]interface{}, error) {
+	reader, ok := AsArchiveReader(e.ReceiptStorePersistence)
+	if !ok {
+		return nil, errors.Errorf(errors.ReceiptArchiveNotSupported)
+	}
+	return e.decryptAll(reader.GetOldestReceipts(afterEpochMS, beforeEpochMS, limit))
+}
+
+// SummarizeReceipts is performed by the underlying persistence layer, on the plaintext fields
+func (e *EncryptedReceipts) SummarizeReceipts(sinceEpochMS int64) (*ReceiptSummary, error) {
+	summarizer, ok := AsSummarizer(e.ReceiptStorePersistence)
+	if !ok {
+		return nil, errors.Errorf(errors.ReceiptStoreSummaryNotSupported)
+	}
+	return summarizer.SummarizeReceipts(sinceEpochMS)
+}
+
+// SummarizeReceiptGroups is performed by the underlying persistence layer, on the plaintext fields
+func (e *EncryptedReceipts) SummarizeReceiptGroups(sinceEpochMS int64, groupBy []string) ([]*ReceiptGroup, error) {
+	groupSummarizer, ok := AsGroupSummarizer(e.ReceiptStorePersistence)
+	if !ok {
+		return nil, errors.Errorf(errors.ReceiptStoreGroupSummaryNotSupported)
+	}
+	return groupSummarizer.SummarizeReceiptGroups(sinceEpochMS, groupBy)
+}
+
+// UpdateReceipt merges the update into the decrypted receipt, and writes it back encrypted
+// with a compare-and-set on the revision, which is stored in plaintext. Fields of the stored
+// receipt that are not in the new one are removed, so nothing is left in plaintext that
+// should now be in the envelope.
+func (e *EncryptedReceipts) UpdateReceipt(requestID string, expectedRevision int64, fields map[string]interface{}) (*map[string]interface{}, error) {
+	rw, ok := AsRevisionWriter(e.ReceiptStorePersistence)
+	if !ok {
+		return nil, errors.Errorf(errors.ReceiptStoreRevisionsNotSupported)
+	}
+	stored, err := e.ReceiptStorePersistence.GetReceipt(requestID)
+	if err != nil {
+		return nil, err
+	}
+	var existing *map[string]interface{}
+	if stored != nil {
+		receipt, err := e.decrypt(*stored)
+		if err != nil {
+			return nil, err
+		}
+		existing = &receipt
+	}
+	if ReceiptRevision(existing) != expectedRevision {
+		return nil, errors.Errorf(errors.ReceiptStoreRevisionConflict, requestID, expectedRevision)
+	}
+	updated := mergeReceiptUpdate(requestID, existing, fields)
+	storedFields, err := e.encrypt(updated)
+	if err != nil {
+		return nil, err
+	}
+	if stored != nil {
+		for k := range *stored {
+			if _, ok := storedFields[k]; !ok {
+				storedFields[k] = nil
+			}
+		}
+	}
+	if _, err := rw.UpdateReceipt(requestID, expectedRevision, storedFields); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
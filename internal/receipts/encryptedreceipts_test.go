@@ -0,0 +1,447 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receipts
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testKEKHex = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func newTestEncryptedReceipts(t *testing.T) (*EncryptedReceipts, *MemoryReceipts) {
+	os.Setenv("TEST_RECEIPT_KEK", testKEKHex)
+	mem := NewMemoryReceipts(&ReceiptStoreConf{MaxDocs: 10})
+	e, err := newEncryptedReceipts(&ReceiptEncryptionConf{KeyEnv: "TEST_RECEIPT_KEK"}, mem)
+	assert.NoError(t, err)
+	return e, mem
+}
+
+func testEncryptionReceipt(id string) map[string]interface{} {
+	return map[string]interface{}{
+		"_id":        id,
+		"receivedAt": 1000,
+		"from":       "0x0123",
+		"headers": map[string]interface{}{
+			"requestId": id,
+			"type":      "TransactionSuccess",
+			"context":   map[string]interface{}{"secret": "value"},
+		},
+		"transactionHash": "0xabcd",
+	}
+}
+
+func TestEncryptedReceiptsRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	e, mem := newTestEncryptedReceipts(t)
+
+	receipt := testEncryptionReceipt("req1")
+	err := e.AddReceipt("req1", &receipt, false)
+	assert.NoError(err)
+
+	// The payload is not visible in the underlying store
+	stored, err := mem.GetReceipt("req1")
+	assert.NoError(err)
+	assert.Equal("0x0123", (*stored)["from"])
+	assert.Nil((*stored)["transactionHash"])
+	assert.Equal(map[string]interface{}{"requestId": "req1", "type": "TransactionSuccess"}, (*stored)["headers"])
+	envelope := (*stored)["encrypted"].(*encryptedEnvelope)
+	assert.Equal("local", envelope.KeyID)
+
+	decrypted, err := e.GetReceipt("req1")
+	assert.NoError(err)
+	assert.Equal("0xabcd", (*decrypted)["transactionHash"])
+	assert.Equal("value", (*decrypted)["headers"].(map[string]interface{})["context"].(map[string]interface{})["secret"])
+
+	results, err := e.GetReceipts(0, 10, nil, 0, "", "", "")
+	assert.NoError(err)
+	assert.Len(*results, 1)
+	assert.Equal("0xabcd", (*results)[0]["transactionHash"])
+
+	missing, err := e.GetReceipt("req2")
+	assert.NoError(err)
+	assert.Nil(missing)
+}
+
+func TestEncryptedReceiptsUnencryptedPassThrough(t *testing.T) {
+	assert := assert.New(t)
+	e, mem := newTestEncryptedReceipts(t)
+
+	receipt := testEncryptionReceipt("req1")
+	mem.AddReceipt("req1", &receipt, false)
+
+	decrypted, err := e.GetReceipt("req1")
+	assert.NoError(err)
+	assert.Equal("0xabcd", (*decrypted)["transactionHash"])
+}
+
+func TestEncryptedReceiptsDEKRotation(t *testing.T) {
+	assert := assert.New(t)
+	e, mem := newTestEncryptedReceipts(t)
+	e.conf.DEKRotationCount = 2
+
+	for i := 0; i < 3; i++ {
+		receipt := testEncryptionReceipt(fmt.Sprintf("req%d", i))
+		assert.NoError(e.AddReceipt(receipt["_id"].(string), &receipt, false))
+	}
+	dek := func(id string) string {
+		stored, _ := mem.GetReceipt(id)
+		return (*stored)["encrypted"].(*encryptedEnvelope).DEK
+	}
+	assert.Equal(dek("req0"), dek("req1"))
+	assert.NotEqual(dek("req1"), dek("req2"))
+
+	// A new instance, with an empty cache, unwraps the keys with the KEK
+	e2, err := newEncryptedReceipts(&ReceiptEncryptionConf{KeyEnv: "TEST_RECEIPT_KEK"}, mem)
+	assert.NoError(err)
+	results, err := e2.GetReceipts(0, 10, nil, 0, "", "", "")
+	assert.NoError(err)
+	assert.Len(*results, 3)
+}
+
+func TestEncryptedReceiptsWrongKey(t *testing.T) {
+	assert := assert.New(t)
+	e, mem := newTestEncryptedReceipts(t)
+
+	receipt := testEncryptionReceipt("req1")
+	assert.NoError(e.AddReceipt("req1", &receipt, false))
+
+	os.Setenv("TEST_RECEIPT_KEK_2", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	e2, err := newEncryptedReceipts(&ReceiptEncryptionConf{KeyEnv: "TEST_RECEIPT_KEK_2"}, mem)
+	assert.NoError(err)
+	_, err = e2.GetReceipt("req1")
+	assert.Regexp("FFEC100327.*req1", err)
+	_, err = e2.GetReceipts(0, 10, nil, 0, "", "", "")
+	assert.Regexp("FFEC100327", err)
+}
+
+func TestEncryptedReceiptsBoundToIDAndNamespace(t *testing.T) {
+	assert := assert.New(t)
+	e, mem := newTestEncryptedReceipts(t)
+
+	receipt := testEncryptionReceipt("req1")
+	receipt["namespace"] = "ns1"
+	err := e.AddReceipt("req1", &receipt, false)
+	assert.NoError(err)
+	stored, _ := mem.GetReceipt("req1")
+
+	// The envelope cannot be moved to another request
+	moved := map[string]interface{}{"_id": "req2", "namespace": "ns1", "encrypted": (*stored)["encrypted"]}
+	_, err = e.decrypt(moved)
+	assert.Regexp("FFEC100327", err)
+
+	// Or into another namespace
+	moved = map[string]interface{}{"_id": "req1", "namespace": "ns2", "encrypted": (*stored)["encrypted"]}
+	_, err = e.decrypt(moved)
+	assert.Regexp("FFEC100327", err)
+
+	decrypted, err := e.decrypt(*stored)
+	assert.NoError(err)
+	assert.Equal("0xabcd", decrypted["transactionHash"])
+}
+
+func TestEncryptedReceiptsBadEnvelope(t *testing.T) {
+	assert := assert.New(t)
+	e, _ := newTestEncryptedReceipts(t)
+
+	_, err := e.decrypt(map[string]interface{}{"encrypted": "wrong"})
+	assert.Regexp("FFEC100327", err)
+	_, err = e.decrypt(map[string]interface{}{"encrypted": map[string]interface{}{"dek": "!!"}})
+	assert.Regexp("FFEC100327", err)
+
+	dk, _ := e.dataKeyForWrite()
+	_, err = e.decrypt(map[string]interface{}{"encrypted": map[string]interface{}{"dek": dk.wrapped, "data": "!!"}})
+	assert.Regexp("FFEC100327", err)
+	_, err = e.decrypt(map[string]interface{}{"encrypted": map[string]interface{}{"dek": dk.wrapped, "data": "AA=="}})
+	assert.Regexp("FFEC100327.*too short", err)
+	sealed, _ := seal(dk.aead, []byte("not json"), receiptAdditionalData(map[string]interface{}{}))
+	_, err = e.decrypt(map[string]interface{}{"encrypted": map[string]interface{}{"dek": dk.wrapped, "data": base64.StdEncoding.EncodeToString(sealed)}})
+	assert.Regexp("FFEC100327", err)
+}
+
+func TestEncryptedReceiptsOptionalInterfaces(t *testing.T) {
+	assert := assert.New(t)
+	os.Setenv("TEST_RECEIPT_KEK", testKEKHex)
+	mem := NewMemoryReceipts(&ReceiptStoreConf{MaxDocs: 10})
+	p, err := NewEncryptedReceipts(&ReceiptEncryptionConf{KeyEnv: "TEST_RECEIPT_KEK"}, mem)
+	assert.NoError(err)
+
+	batch := []map[string]interface{}{testEncryptionReceipt("req1"), testEncryptionReceipt("req2")}
+	batch[0]["method"] = "set"
+	batch[0]["headers"].(map[string]interface{})["timeElapsed"] = 1.5
+	assert.NoError(p.(ReceiptStoreBatchWriter).AddReceipts(batch))
+
+	// The memory store does not search, so neither does the encryption layer
+	_, ok := AsSearcher(p)
+	assert.False(ok)
+	_, err = p.(ReceiptStoreSearcher).SearchReceipts(0, 10, nil, 0, "", "", &ReceiptSearch{Text: "set"})
+	assert.Regexp("FFEC100246", err)
+
+	results, err := p.(ReceiptStoreNamespaceReader).GetNamespaceReceipts("", 0, 10, nil, 0, "", "", "")
+	assert.NoError(err)
+	assert.Len(*results, 2)
+	assert.Equal("0xabcd", (*results)[0]["transactionHash"])
+
+	summary, err := p.(ReceiptStoreSummarizer).SummarizeReceipts(0)
+	assert.NoError(err)
+	assert.Equal(2, summary.Success)
+
+	// The fields used for grouping remain in plaintext
+	groups, err := p.(ReceiptStoreGroupSummarizer).SummarizeReceiptGroups(0, []string{ReceiptGroupByMethod})
+	assert.NoError(err)
+	assert.Equal([]*ReceiptGroup{
+		{Count: 1},
+		{Method: "set", Count: 1, Latency: &ReceiptLatency{P50: 1.5, P90: 1.5, P99: 1.5}},
+	}, groups)
+
+	results, err = p.(ReceiptStoreArchiveReader).GetOldestReceipts(0, 2000, 10)
+	assert.NoError(err)
+	assert.Len(*results, 2)
+
+	// A store without any of the optional interfaces
+	p, err = NewEncryptedReceipts(&ReceiptEncryptionConf{KeyEnv: "TEST_RECEIPT_KEK"}, &struct{ ReceiptStorePersistence }{mem})
+	assert.NoError(err)
+	assert.IsType(&EncryptedReceipts{}, p)
+	assert.NoError(p.(ReceiptStoreBatchWriter).AddReceipts(batch))
+	_, ok = AsNamespaceReader(p)
+	assert.False(ok)
+	_, err = p.(ReceiptStoreNamespaceReader).GetNamespaceReceipts("", 0, 10, nil, 0, "", "", "")
+	assert.Regexp("FFEC100321", err)
+	_, ok = AsArchiveReader(p)
+	assert.False(ok)
+	_, err = p.(ReceiptStoreArchiveReader).GetOldestReceipts(0, 2000, 10)
+	assert.Regexp("FFEC100289", err)
+	_, ok = AsSummarizer(p)
+	assert.False(ok)
+	_, err = p.(ReceiptStoreSummarizer).SummarizeReceipts(0)
+	assert.Regexp("FFEC100317", err)
+	_, ok = AsGroupSummarizer(p)
+	assert.False(ok)
+	_, err = p.(ReceiptStoreGroupSummarizer).SummarizeReceiptGroups(0, []string{ReceiptGroupByMethod})
+	assert.Regexp("FFEC100331", err)
+	_, ok = AsRevisionWriter(p)
+	assert.False(ok)
+	_, err = p.(ReceiptStoreRevisionWriter).UpdateReceipt("req1", 0, map[string]interface{}{})
+	assert.Regexp("FFEC100485", err)
+
+	// A store with all of them
+	p, err = NewEncryptedReceipts(&ReceiptEncryptionConf{KeyEnv: "TEST_RECEIPT_KEK"}, &mockSearchStore{
+		MemoryReceipts: NewMemoryReceipts(&ReceiptStoreConf{MaxDocs: 10}),
+	})
+	assert.NoError(err)
+	assert.NoError(p.(ReceiptStoreBatchWriter).AddReceipts(batch))
+	results, err = p.(ReceiptStoreSearcher).SearchReceipts(0, 10, nil, 0, "", "", &ReceiptSearch{Text: "set"})
+	assert.NoError(err)
+	assert.Len(*results, 2)
+	assert.Equal("0xabcd", (*results)[0]["transactionHash"])
+	_, ok = AsSearcher(p)
+	assert.True(ok)
+	_, ok = AsRevisionWriter(p)
+	assert.True(ok)
+}
+
+type mockSearchStore struct {
+	*MemoryReceipts
+}
+
+func (m *mockSearchStore) SearchReceipts(skip, limit int, ids []string, sinceEpochMS int64, from, to string, search *ReceiptSearch) (*[]map[string]interface{}, error) {
+	return m.GetReceipts(skip, limit, ids, sinceEpochMS, from, to, "")
+}
+
+func TestEncryptedReceiptsUpdateReceipt(t *testing.T) {
+	assert := assert.New(t)
+	os.Setenv("TEST_RECEIPT_KEK", testKEKHex)
+	mem := NewMemoryReceipts(&ReceiptStoreConf{MaxDocs: 10})
+	p, err := NewEncryptedReceipts(&ReceiptEncryptionConf{KeyEnv: "TEST_RECEIPT_KEK"}, mem)
+	assert.NoError(err)
+
+	// A receipt written before encryption was enabled is encrypted by the update
+	legacy := testEncryptionReceipt("req1")
+	assert.NoError(mem.AddReceipt("req1", &legacy, true))
+	updated, err := UpdateReceiptFields(p, "req1", func(existing *map[string]interface{}) map[string]interface{} {
+		assert.Equal("0xabcd", (*existing)["transactionHash"])
+		return map[string]interface{}{"confirmedEvents": true}
+	})
+	assert.NoError(err)
+	assert.Equal(int64(1), ReceiptRevision(updated))
+	assert.Equal(true, (*updated)["confirmedEvents"])
+
+	stored, _ := mem.GetReceipt("req1")
+	assert.Equal(int64(1), ReceiptRevision(stored))
+	assert.Nil((*stored)["transactionHash"])
+	assert.Nil((*stored)["confirmedEvents"])
+	assert.Equal("0x0123", (*stored)["from"])
+
+	decrypted, err := p.GetReceipt("req1")
+	assert.NoError(err)
+	assert.Equal("0xabcd", (*decrypted)["transactionHash"])
+	assert.Equal(true, (*decrypted)["confirmedEvents"])
+
+	// The revision is checked against the stored receipt
+	rw := p.(ReceiptStoreRevisionWriter)
+	_, err = rw.UpdateReceipt("req1", 0, map[string]interface{}{"pending": true})
+	assert.Regexp("FFEC100370", err)
+	updated, err = rw.UpdateReceipt("req1", 1, map[string]interface{}{"confirmedEvents": nil})
+	assert.NoError(err)
+	assert.Equal(int64(2), ReceiptRevision(updated))
+	decrypted, _ = p.GetReceipt("req1")
+	assert.Nil((*decrypted)["confirmedEvents"])
+
+	// A new receipt is created at revision one
+	updated, err = rw.UpdateReceipt("req2", 0, map[string]interface{}{"pending": true})
+	assert.NoError(err)
+	assert.Equal(int64(1), ReceiptRevision(updated))
+	stored, _ = mem.GetReceipt("req2")
+	assert.Equal(true, (*stored)["pending"])
+	assert.NotNil((*stored)[encryptedReceiptField])
+}
+
+func TestEncryptedReceiptsUpdateReceiptFail(t *testing.T) {
+	assert := assert.New(t)
+	rw, mem := newTestEncryptedReceipts(t)
+
+	bad := map[string]interface{}{"_id": "req1", encryptedReceiptField: "not an envelope"}
+	assert.NoError(mem.AddReceipt("req1", &bad, true))
+	_, err := rw.UpdateReceipt("req1", 0, map[string]interface{}{})
+	assert.Regexp("FFEC100327", err)
+
+	_, err = rw.UpdateReceipt("req2", 0, map[string]interface{}{"bad": make(chan int)})
+	assert.Regexp("FFEC100326", err)
+}
+
+func TestEncryptedReceiptsKeyFile(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, _ := ioutil.TempDir("", "kek")
+	defer os.RemoveAll(dir)
+	keyFile := path.Join(dir, "kek")
+	ioutil.WriteFile(keyFile, []byte("0x"+testKEKHex+"\n"), 0600)
+
+	e, err := newEncryptedReceipts(&ReceiptEncryptionConf{KeyFile: keyFile, KeyID: "kek1"}, NewMemoryReceipts(&ReceiptStoreConf{}))
+	assert.NoError(err)
+	assert.Equal("kek1", e.kek.keyID())
+
+	_, err = newEncryptedReceipts(&ReceiptEncryptionConf{KeyFile: path.Join(dir, "missing")}, nil)
+	assert.Regexp("FFEC100325", err)
+}
+
+func TestEncryptedReceiptsBadKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := newEncryptedReceipts(&ReceiptEncryptionConf{}, nil)
+	assert.Regexp("FFEC100325.*keyFile", err)
+
+	os.Setenv("TEST_RECEIPT_KEK_BAD", "!!")
+	_, err = newEncryptedReceipts(&ReceiptEncryptionConf{KeyEnv: "TEST_RECEIPT_KEK_BAD"}, nil)
+	assert.Regexp("FFEC100325.*hex or base64", err)
+
+	os.Setenv("TEST_RECEIPT_KEK_BAD", "0011")
+	_, err = newEncryptedReceipts(&ReceiptEncryptionConf{KeyEnv: "TEST_RECEIPT_KEK_BAD"}, nil)
+	assert.Regexp("FFEC100325.*32 bytes, found 2", err)
+
+	_, err = newEncryptedReceipts(&ReceiptEncryptionConf{Vault: VaultTransitConf{URL: "http://localhost"}}, nil)
+	assert.Regexp("FFEC100325.*keyName", err)
+}
+
+func newTestVault(t *testing.T, fail bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "vault-token", r.Header.Get("X-Vault-Token"))
+		if fail {
+			w.WriteHeader(403)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/receipts":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{"ciphertext": "vault:v1:" + body["plaintext"]},
+			})
+		case "/v1/transit/decrypt/receipts":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{"plaintext": body["ciphertext"][len("vault:v1:"):]},
+			})
+		default:
+			w.Write([]byte("not json"))
+		}
+	}))
+}
+
+func TestEncryptedReceiptsVault(t *testing.T) {
+	assert := assert.New(t)
+
+	vault := newTestVault(t, false)
+	defer vault.Close()
+
+	os.Setenv("TEST_VAULT_TOKEN", "vault-token")
+	mem := NewMemoryReceipts(&ReceiptStoreConf{MaxDocs: 10})
+	e, err := newEncryptedReceipts(&ReceiptEncryptionConf{
+		Vault: VaultTransitConf{URL: vault.URL, KeyName: "receipts", TokenEnv: "TEST_VAULT_TOKEN"},
+	}, mem)
+	assert.NoError(err)
+	assert.Equal("vault:receipts", e.kek.keyID())
+
+	receipt := testEncryptionReceipt("req1")
+	assert.NoError(e.AddReceipt("req1", &receipt, false))
+
+	e.dekCache.Purge()
+	decrypted, err := e.GetReceipt("req1")
+	assert.NoError(err)
+	assert.Equal("0xabcd", (*decrypted)["transactionHash"])
+
+	e.conf.Vault.KeyName = "other"
+	_, err = e.kek.wrap([]byte("key"))
+	assert.Regexp("FFEC100329.*encrypt", err)
+}
+
+func TestEncryptedReceiptsVaultFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	vault := newTestVault(t, true)
+	mem := NewMemoryReceipts(&ReceiptStoreConf{MaxDocs: 10})
+	e, err := newEncryptedReceipts(&ReceiptEncryptionConf{
+		Vault: VaultTransitConf{URL: vault.URL, KeyName: "receipts", Token: "vault-token"},
+	}, mem)
+	assert.NoError(err)
+
+	receipt := testEncryptionReceipt("req1")
+	err = e.AddReceipt("req1", &receipt, false)
+	assert.Regexp("FFEC100326.*FFEC100329.*403.*permission denied", err)
+	err = e.AddReceipts([]map[string]interface{}{receipt})
+	assert.Regexp("FFEC100326", err)
+
+	_, err = e.dataKeyForRead("vault:v1:AAAA")
+	assert.Regexp("FFEC100329.*decrypt", err)
+
+	vault.Close()
+	_, err = e.kek.wrap([]byte("key"))
+	assert.Regexp("FFEC100329", err)
+
+	badTLS := &ReceiptEncryptionConf{Vault: VaultTransitConf{URL: vault.URL, KeyName: "receipts"}}
+	badTLS.Vault.TLS.Enabled = true
+	badTLS.Vault.TLS.CACertsFile = "/non/existent/ca.pem"
+	_, err = newEncryptedReceipts(badTLS, mem)
+	assert.Error(err)
+}
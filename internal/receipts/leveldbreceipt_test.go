@@ -656,3 +656,167 @@ func TestGetReceiptsByLookupUnmarshalFail(t *testing.T) {
 	results := r.getReceiptsByLookupKey([]string{"key1", "key2"}, 1)
 	assert.Empty(results)
 }
+
+func TestLevelDBReceiptsPrune(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := NewLevelDBReceipts(&LevelDBReceiptStoreConf{
+		Path: path.Join(tmpdir, "prune"),
+	})
+	assert.NoError(err)
+	defer r.store.Close()
+
+	for i := 1; i <= 10; i++ {
+		reqID := fmt.Sprintf("r%02d", i)
+		receipt := map[string]interface{}{
+			"_id":        reqID,
+			"from":       "addr1",
+			"to":         "addr2",
+			"receivedAt": int64(1000000000000 + i),
+		}
+		err = r.AddReceipt(reqID, &receipt, false)
+		assert.NoError(err)
+	}
+
+	pruned, err := r.PruneReceipts(1000000000004, 0)
+	assert.NoError(err)
+	assert.Equal(3, pruned)
+	receipt, err := r.GetReceipt("r03")
+	assert.NoError(err)
+	assert.Nil(receipt)
+
+	pruned, err = r.PruneReceipts(0, 5)
+	assert.NoError(err)
+	assert.Equal(2, pruned)
+
+	results, err := r.GetReceipts(0, 100, nil, 0, "", "", "")
+	assert.NoError(err)
+	assert.Len(*results, 5)
+	assert.Equal("r10", (*results)[0]["_id"])
+	assert.Equal("r06", (*results)[4]["_id"])
+	results, err = r.GetReceipts(0, 100, nil, 0, "addr1", "addr2", "")
+	assert.NoError(err)
+	assert.Len(*results, 5)
+
+	// All index entries for the pruned receipts have been removed
+	itr := r.store.NewIterator()
+	keys := 0
+	for itr.Next() {
+		keys++
+	}
+	itr.Release()
+	assert.Equal(5*5, keys)
+}
+
+func TestLevelDBReceiptsDelete(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := NewLevelDBReceipts(&LevelDBReceiptStoreConf{
+		Path: path.Join(tmpdir, "delete"),
+	})
+	assert.NoError(err)
+	defer r.store.Close()
+
+	for i := 1; i <= 3; i++ {
+		reqID := fmt.Sprintf("r%02d", i)
+		receipt := map[string]interface{}{
+			"_id":        reqID,
+			"from":       "addr1",
+			"receivedAt": int64(1000000000000 + i),
+		}
+		err = r.AddReceipt(reqID, &receipt, false)
+		assert.NoError(err)
+	}
+
+	deleted, err := r.DeleteReceipts([]string{"r02", "unknown"})
+	assert.NoError(err)
+	assert.Equal(1, deleted)
+	receipt, err := r.GetReceipt("r02")
+	assert.NoError(err)
+	assert.Nil(receipt)
+
+	results, err := r.GetReceipts(0, 100, nil, 0, "addr1", "", "")
+	assert.NoError(err)
+	assert.Len(*results, 2)
+
+	r.store.Close()
+	_, err = r.DeleteReceipts([]string{"r01"})
+	assert.Error(err)
+}
+
+func TestLevelDBReceiptsGetOldest(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := NewLevelDBReceipts(&LevelDBReceiptStoreConf{
+		Path: path.Join(tmpdir, "oldest"),
+	})
+	assert.NoError(err)
+	defer r.store.Close()
+
+	for i := 1; i <= 10; i++ {
+		reqID := fmt.Sprintf("r%02d", i)
+		receipt := map[string]interface{}{
+			"_id":        reqID,
+			"receivedAt": int64(1000000000000 + i),
+		}
+		err = r.AddReceipt(reqID, &receipt, false)
+		assert.NoError(err)
+	}
+
+	results, err := r.GetOldestReceipts(1000000000002, 1000000000009, 4)
+	assert.NoError(err)
+	assert.Len(*results, 4)
+	assert.Equal("r03", (*results)[0]["_id"])
+	assert.Equal("r06", (*results)[3]["_id"])
+
+	results, err = r.GetOldestReceipts(1000000000006, 1000000000009, 4)
+	assert.NoError(err)
+	assert.Len(*results, 2)
+	assert.Equal("r08", (*results)[1]["_id"])
+}
+
+func TestLevelDBReceiptsSummarize(t *testing.T) {
+	assert := assert.New(t)
+
+	r, err := NewLevelDBReceipts(&LevelDBReceiptStoreConf{
+		Path: path.Join(tmpdir, "summarize"),
+	})
+	assert.NoError(err)
+	defer r.store.Close()
+
+	for i, msgType := range []string{"TransactionSuccess", "TransactionFailure", "Error", "SendTransaction", "SendTransaction"} {
+		reqID := fmt.Sprintf("r%02d", i)
+		err = r.AddReceipt(reqID, summaryTestReceipt(reqID, int64(1000000000001+i), msgType, msgType == "SendTransaction"), false)
+		assert.NoError(err)
+	}
+	// The reply for a pending request overwrites it, leaving two index entries for the receipt
+	err = r.AddReceipt("r03", summaryTestReceipt("r03", 1000000000010, "TransactionSuccess", false), true)
+	assert.NoError(err)
+
+	summary, err := r.SummarizeReceipts(0)
+	assert.NoError(err)
+	assert.Equal(&ReceiptSummary{Total: 5, Success: 2, Failure: 1, Error: 1, Pending: 1}, summary)
+
+	summary, err = r.SummarizeReceipts(1000000000004)
+	assert.NoError(err)
+	assert.Equal(&ReceiptSummary{Total: 2, Success: 1, Pending: 1}, summary)
+
+	// Entries that cannot be read are skipped
+	r.store.Put("receivedAt:1000000000020:zbad", []byte("zbad"))
+	r.store.Put("receivedAt:1000000000021:zmissing", []byte("zmissing"))
+	r.store.Put("zbad", []byte("!json"))
+	summary, err = r.SummarizeReceipts(1000000000010)
+	assert.NoError(err)
+	assert.Equal(&ReceiptSummary{}, summary)
+}
+
+func TestLevelDBReceiptsSummarizeGroups(t *testing.T) {
+	r, err := NewLevelDBReceipts(&LevelDBReceiptStoreConf{
+		Path: path.Join(tmpdir, "summarizegroups"),
+	})
+	assert.NoError(t, err)
+	defer r.store.Close()
+
+	addGroupTestReceipts(t, r, 1000000000000)
+	assertReceiptGroups(t, r, 1000000000000)
+}
@@ -25,6 +25,7 @@ import (
 
 	"github.com/hyperledger/firefly-ethconnect/internal/errors"
 	"github.com/hyperledger/firefly-ethconnect/internal/kvstore"
+	"github.com/hyperledger/firefly-ethconnect/internal/migrations"
 	"github.com/oklog/ulid/v2"
 	log "github.com/sirupsen/logrus"
 	"github.com/syndtr/goleveldb/leveldb/util"
@@ -34,14 +35,25 @@ type LevelDBReceipts struct {
 	conf         *LevelDBReceiptStoreConf
 	store        kvstore.KVStore
 	entropyLock  sync.Mutex
+	updateLock   sync.Mutex
 	idEntropy    *ulid.MonotonicEntropy
 	defaultLimit int
 }
 
+// leveldbReceiptMigrations are the upgrades of the receipt store path, in version order
+var leveldbReceiptMigrations = []*migrations.Migration{}
+
 func NewLevelDBReceipts(conf *LevelDBReceiptStoreConf) (*LevelDBReceipts, error) {
-	store, err := kvstore.NewLDBKeyValueStore(conf.Path)
+	var store kvstore.KVStore
+	migrator := migrations.NewMigrator("receipt store", conf.Path, &conf.Migrations, leveldbReceiptMigrations...)
+	_, err := migrator.Run(func() (err error) {
+		if store, err = kvstore.NewLDBKeyValueStore(conf.Path); err != nil {
+			return errors.Errorf(errors.ReceiptStoreLevelDBConnect, err)
+		}
+		return nil
+	}, func() { store.Close() })
 	if err != nil {
-		return nil, errors.Errorf(errors.ReceiptStoreLevelDBConnect, err)
+		return nil, err
 	}
 	t := time.Unix(1000000, 0)
 	entropy := ulid.Monotonic(rand.New(rand.NewSource(t.UnixNano())), 0)
@@ -109,6 +121,11 @@ func (l *LevelDBReceipts) AddReceipt(requestID string, receipt *map[string]inter
 	return err
 }
 
+// UpdateReceipt applies a partial update to a receipt, if it is still at the expected revision
+func (l *LevelDBReceipts) UpdateReceipt(requestID string, expectedRevision int64, fields map[string]interface{}) (*map[string]interface{}, error) {
+	return lockedRevisionUpdate(&l.updateLock, l, requestID, expectedRevision, fields)
+}
+
 // GetReceipts Returns recent receipts with skip, limit and other query parameters
 func (l *LevelDBReceipts) GetReceipts(skip, limit int, ids []string, sinceEpochMS int64, from, to, start string) (*[]map[string]interface{}, error) {
 	// the application of the parameters are implemented to match mongo queries:
@@ -383,3 +400,162 @@ func applyBound(arr []string, start, end string) []string {
 	}
 	return arr
 }
+
+// GetOldestReceipts uses the "receivedAt" index, which sorts oldest first
+func (l *LevelDBReceipts) GetOldestReceipts(afterEpochMS, beforeEpochMS int64, limit int) (*[]map[string]interface{}, error) {
+	itr := l.store.NewIteratorWithRange(&util.Range{
+		Start: []byte(fmt.Sprintf("receivedAt:%d:", afterEpochMS+1)),
+		Limit: []byte(fmt.Sprintf("receivedAt:%d:", beforeEpochMS)),
+	})
+	defer itr.Release()
+	var lookupKeys []string
+	for len(lookupKeys) < limit && itr.Next() {
+		segments := strings.Split(itr.Key(), ":")
+		if len(segments) == 3 {
+			lookupKeys = append(lookupKeys, segments[2])
+		}
+	}
+	return l.getReceiptsByLookupKey(lookupKeys, limit), nil
+}
+
+// SummarizeReceipts uses the "receivedAt" index to find the receipts in the window. An overwritten
+// receipt has an index entry for each time it was written, so we only count each receipt once.
+func (l *LevelDBReceipts) SummarizeReceipts(sinceEpochMS int64) (*ReceiptSummary, error) {
+	summary := &ReceiptSummary{}
+	l.scanReceivedAt(sinceEpochMS, summary.add)
+	return summary, nil
+}
+
+// SummarizeReceiptGroups scans the window using the "receivedAt" index, like SummarizeReceipts
+func (l *LevelDBReceipts) SummarizeReceiptGroups(sinceEpochMS int64, groupBy []string) ([]*ReceiptGroup, error) {
+	groups := newReceiptGroups(groupBy)
+	l.scanReceivedAt(sinceEpochMS, groups.addReceipt)
+	return groups.result(), nil
+}
+
+// scanReceivedAt calls fn for each receipt received after sinceEpochMS once, using the
+// "receivedAt" index
+func (l *LevelDBReceipts) scanReceivedAt(sinceEpochMS int64, fn func(receipt map[string]interface{})) {
+	start := "receivedAt:"
+	if sinceEpochMS > 0 {
+		start = fmt.Sprintf("receivedAt:%d:", sinceEpochMS+1)
+	}
+	itr := l.store.NewIteratorWithRange(&util.Range{
+		Start: []byte(start),
+		Limit: []byte("receivedAt;"),
+	})
+	defer itr.Release()
+	counted := make(map[string]bool)
+	for itr.Next() {
+		segments := strings.Split(itr.Key(), ":")
+		if len(segments) != 3 || counted[segments[2]] {
+			continue
+		}
+		counted[segments[2]] = true
+		val, err := l.store.Get(segments[2])
+		if err != nil {
+			log.Errorf("Failed to find entry for lookup key %s", segments[2])
+			continue
+		}
+		receipt := make(map[string]interface{})
+		if err = json.Unmarshal(val, &receipt); err != nil {
+			log.Errorf("Failed to decode stored receipt for lookup key %s", segments[2])
+			continue
+		}
+		fn(receipt)
+	}
+}
+
+// PruneReceipts removes receipts received before the cutoff (using the "receivedAt" index), and
+// then the oldest receipts beyond the maximum count (using the insertion ordered "z" keys)
+func (l *LevelDBReceipts) PruneReceipts(olderThanEpochMS int64, maxCount int) (int, error) {
+	var lookupKeys []string
+	if olderThanEpochMS > 0 {
+		itr := l.store.NewIteratorWithRange(&util.Range{
+			Start: []byte("receivedAt:"),
+			Limit: []byte(fmt.Sprintf("receivedAt:%d:", olderThanEpochMS)),
+		})
+		for itr.Next() {
+			segments := strings.Split(itr.Key(), ":")
+			if len(segments) == 3 {
+				lookupKeys = append(lookupKeys, segments[2])
+			}
+		}
+		itr.Release()
+	}
+	pruned, err := l.deleteByLookupKeys(lookupKeys)
+	if err != nil || maxCount <= 0 {
+		return pruned, err
+	}
+
+	var allKeys []string
+	itr := l.store.NewIteratorWithRange(&util.Range{
+		Start: []byte("z"),
+	})
+	for itr.Next() {
+		// Request IDs are also stored as keys, and could start with "z", so check it looks like a ULID
+		if key := itr.Key(); len(key) == 1+ulid.EncodedSize {
+			allKeys = append(allKeys, key)
+		}
+	}
+	itr.Release()
+	if len(allKeys) <= maxCount {
+		return pruned, nil
+	}
+	count, err := l.deleteByLookupKeys(allKeys[:len(allKeys)-maxCount])
+	return pruned + count, err
+}
+
+// DeleteReceipts removes the receipts with the given request IDs, along with their index entries
+func (l *LevelDBReceipts) DeleteReceipts(requestIDs []string) (int, error) {
+	var lookupKeys []string
+	for _, requestID := range requestIDs {
+		val, err := l.store.Get(requestID)
+		if err == kvstore.ErrorNotFound {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		lookupKeys = append(lookupKeys, string(val))
+	}
+	return l.deleteByLookupKeys(lookupKeys)
+}
+
+func (l *LevelDBReceipts) deleteByLookupKeys(lookupKeys []string) (int, error) {
+	deleted := 0
+	for _, lookupKey := range lookupKeys {
+		val, err := l.store.Get(lookupKey)
+		if err == kvstore.ErrorNotFound {
+			continue
+		} else if err != nil {
+			return deleted, err
+		}
+		receipt := make(map[string]interface{})
+		if err := json.Unmarshal(val, &receipt); err != nil {
+			log.Errorf("Failed to decode stored receipt for lookup key %s during pruning", lookupKey)
+		}
+		// Remove the index entries, using the same formatting as AddReceipt
+		indexKeys := []string{
+			fmt.Sprintf("from:%s:%s", receipt["from"], lookupKey),
+			fmt.Sprintf("receivedAt:%d:%s", ReceiptReceivedAt(receipt), lookupKey),
+		}
+		if to, ok := receipt["to"]; ok && to != "" {
+			indexKeys = append(indexKeys, fmt.Sprintf("to:%s:%s", to, lookupKey))
+		}
+		if requestID, ok := receipt["_id"].(string); ok {
+			// Only remove the ID lookup if it still points at this entry
+			l.entropyLock.Lock()
+			if current, err := l.store.Get(requestID); err == nil && string(current) == lookupKey {
+				indexKeys = append(indexKeys, requestID)
+			}
+			l.entropyLock.Unlock()
+		}
+		for _, key := range append(indexKeys, lookupKey) {
+			if err := l.store.Delete(key); err != nil && err != kvstore.ErrorNotFound {
+				return deleted, err
+			}
+		}
+		deleted++
+	}
+	return deleted, nil
+}
@@ -23,10 +23,11 @@ import (
 )
 
 type MemoryReceipts struct {
-	conf     *ReceiptStoreConf
-	receipts *list.List
-	byID     map[string]*map[string]interface{}
-	mux      sync.Mutex
+	conf      *ReceiptStoreConf
+	receipts  *list.List
+	byID      map[string]*map[string]interface{}
+	mux       sync.Mutex
+	updateMux sync.Mutex
 }
 
 func NewMemoryReceipts(conf *ReceiptStoreConf) *MemoryReceipts {
@@ -44,21 +45,51 @@ func (m *MemoryReceipts) Receipts() *list.List {
 }
 
 func (m *MemoryReceipts) GetReceipts(skip, limit int, ids []string, sinceEpochMS int64, from, to, start string) (*[]map[string]interface{}, error) {
+	return m.GetNamespaceReceipts("", skip, limit, ids, sinceEpochMS, from, to, start)
+}
+
+// GetNamespaceReceipts returns the receipts in the namespace, or all receipts for an empty namespace
+func (m *MemoryReceipts) GetNamespaceReceipts(namespace string, skip, limit int, ids []string, sinceEpochMS int64, from, to, start string) (*[]map[string]interface{}, error) {
 	m.mux.Lock()
 	defer m.mux.Unlock()
 
-	if len(ids) > 0 || sinceEpochMS != 0 || from != "" || to != "" {
+	if from != "" || to != "" {
 		return nil, errors.Errorf(errors.KVStoreMemFilteringUnsupported)
 	}
 
-	results := make([]map[string]interface{}, 0, limit)
-	curElem := m.receipts.Front()
-	for i := 0; i < skip && curElem != nil; i++ {
-		curElem = curElem.Next()
+	var idFilter map[string]bool
+	if len(ids) > 0 {
+		idFilter = make(map[string]bool, len(ids))
+		for _, id := range ids {
+			idFilter[id] = true
+		}
 	}
-	for i := 0; i < limit && curElem != nil; i++ {
-		results = append(results, *curElem.Value.(*map[string]interface{}))
-		curElem = curElem.Next()
+
+	// A zero limit is unlimited, as it is for the other stores (used when querying by ID)
+	if limit <= 0 {
+		limit = m.receipts.Len()
+	}
+	results := make([]map[string]interface{}, 0, limit)
+	matched := 0
+	for curElem := m.receipts.Front(); curElem != nil && len(results) < limit; curElem = curElem.Next() {
+		receipt := *curElem.Value.(*map[string]interface{})
+		if idFilter != nil {
+			if id, _ := receipt["_id"].(string); !idFilter[id] {
+				continue
+			}
+		}
+		if sinceEpochMS > 0 && ReceiptReceivedAt(receipt) <= sinceEpochMS {
+			continue
+		}
+		if namespace != "" {
+			if ns, _ := receipt["namespace"].(string); ns != namespace {
+				continue
+			}
+		}
+		if matched >= skip {
+			results = append(results, receipt)
+		}
+		matched++
 	}
 	return &results, nil
 }
@@ -94,3 +125,114 @@ func (m *MemoryReceipts) AddReceipt(requestID string, receipt *map[string]interf
 	m.byID[requestID] = receipt
 	return nil
 }
+
+// UpdateReceipt applies a partial update to a receipt, if it is still at the expected revision
+func (m *MemoryReceipts) UpdateReceipt(requestID string, expectedRevision int64, fields map[string]interface{}) (*map[string]interface{}, error) {
+	return lockedRevisionUpdate(&m.updateMux, m, requestID, expectedRevision, fields)
+}
+
+// GetOldestReceipts walks from the back of the list, where the oldest receipts are
+func (m *MemoryReceipts) GetOldestReceipts(afterEpochMS, beforeEpochMS int64, limit int) (*[]map[string]interface{}, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	results := []map[string]interface{}{}
+	for elem := m.receipts.Back(); elem != nil && len(results) < limit; elem = elem.Prev() {
+		receipt := *(elem.Value.(*map[string]interface{}))
+		receivedAt := ReceiptReceivedAt(receipt)
+		if receivedAt >= beforeEpochMS {
+			break
+		}
+		if receivedAt > afterEpochMS {
+			results = append(results, receipt)
+		}
+	}
+	return &results, nil
+}
+
+// PruneReceipts removes receipts from the back of the list (oldest first) that are older than
+// the cutoff, or beyond the maximum count
+func (m *MemoryReceipts) PruneReceipts(olderThanEpochMS int64, maxCount int) (int, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	pruned := 0
+	for back := m.receipts.Back(); back != nil; back = m.receipts.Back() {
+		receipt := *(back.Value.(*map[string]interface{}))
+		overCount := maxCount > 0 && m.receipts.Len() > maxCount
+		tooOld := olderThanEpochMS > 0 && ReceiptReceivedAt(receipt) < olderThanEpochMS
+		if !overCount && !tooOld {
+			break
+		}
+		if existingID, ok := receipt["_id"].(string); ok {
+			delete(m.byID, existingID)
+		}
+		m.receipts.Remove(back)
+		pruned++
+	}
+	return pruned, nil
+}
+
+// DeleteReceipts removes the receipts with the given request IDs, wherever they are in the list
+func (m *MemoryReceipts) DeleteReceipts(requestIDs []string) (int, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	toDelete := make(map[*map[string]interface{}]bool, len(requestIDs))
+	for _, requestID := range requestIDs {
+		if receipt, ok := m.byID[requestID]; ok {
+			toDelete[receipt] = true
+			delete(m.byID, requestID)
+		}
+	}
+	deleted := 0
+	for elem := m.receipts.Front(); elem != nil && deleted < len(toDelete); {
+		next := elem.Next()
+		if toDelete[elem.Value.(*map[string]interface{})] {
+			m.receipts.Remove(elem)
+			deleted++
+		}
+		elem = next
+	}
+	return deleted, nil
+}
+
+// SummarizeReceipts counts receipts from the front of the list (newest first), until the window is passed
+func (m *MemoryReceipts) SummarizeReceipts(sinceEpochMS int64) (*ReceiptSummary, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	summary := &ReceiptSummary{}
+	for elem := m.receipts.Front(); elem != nil; elem = elem.Next() {
+		receipt := *(elem.Value.(*map[string]interface{}))
+		if sinceEpochMS > 0 && ReceiptReceivedAt(receipt) <= sinceEpochMS {
+			break
+		}
+		// An overwritten receipt is added to the front of the list again, so we only count
+		// the entry that is current for the ID
+		if id, ok := receipt["_id"].(string); ok && m.byID[id] != elem.Value {
+			continue
+		}
+		summary.add(receipt)
+	}
+	return summary, nil
+}
+
+// SummarizeReceiptGroups groups the current receipts in the window in the same way
+func (m *MemoryReceipts) SummarizeReceiptGroups(sinceEpochMS int64, groupBy []string) ([]*ReceiptGroup, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	groups := newReceiptGroups(groupBy)
+	for elem := m.receipts.Front(); elem != nil; elem = elem.Next() {
+		receipt := *(elem.Value.(*map[string]interface{}))
+		if sinceEpochMS > 0 && ReceiptReceivedAt(receipt) <= sinceEpochMS {
+			break
+		}
+		if id, ok := receipt["_id"].(string); ok && m.byID[id] != elem.Value {
+			continue
+		}
+		groups.addReceipt(receipt)
+	}
+	return groups.result(), nil
+}
@@ -46,7 +46,7 @@ func TestMemReceiptsWrapping(t *testing.T) {
 	}
 }
 
-func TestMemReceiptsNoIDFilterImpl(t *testing.T) {
+func TestMemReceiptsNoAddressFilterImpl(t *testing.T) {
 	assert := assert.New(t)
 
 	conf := &ReceiptStoreConf{
@@ -56,4 +56,225 @@ func TestMemReceiptsNoIDFilterImpl(t *testing.T) {
 
 	_, err := r.GetReceipts(0, 0, []string{"test"}, 0, "t", "t", "")
 	assert.Regexp("Memory receipts do not support filtering", err)
+	_, err = r.GetReceipts(0, 0, nil, 0, "", "t", "")
+	assert.Regexp("Memory receipts do not support filtering", err)
+}
+
+func TestMemReceiptsFilterIDsAndSince(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewMemoryReceipts(&ReceiptStoreConf{MaxDocs: 50})
+	for i := 0; i < 10; i++ {
+		reqID := fmt.Sprintf("receipt_%d", i)
+		receipt := map[string]interface{}{"_id": reqID, "receivedAt": int64(i * 1000)}
+		r.AddReceipt(reqID, &receipt, false)
+	}
+
+	results, err := r.GetReceipts(0, 10, []string{"receipt_1", "receipt_4", "receipt_8", "unknown"}, 0, "", "", "")
+	assert.NoError(err)
+	assert.Equal(3, len(*results))
+	assert.Equal("receipt_8", (*results)[0]["_id"])
+	assert.Equal("receipt_1", (*results)[2]["_id"])
+
+	results, err = r.GetReceipts(0, 10, nil, 6000, "", "", "")
+	assert.NoError(err)
+	assert.Equal(3, len(*results))
+	assert.Equal("receipt_9", (*results)[0]["_id"])
+	assert.Equal("receipt_7", (*results)[2]["_id"])
+
+	results, err = r.GetReceipts(1, 1, []string{"receipt_1", "receipt_4", "receipt_8"}, 2000, "", "", "")
+	assert.NoError(err)
+	assert.Equal(1, len(*results))
+	assert.Equal("receipt_4", (*results)[0]["_id"])
+}
+
+func TestMemReceiptsPrune(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewMemoryReceipts(&ReceiptStoreConf{MaxDocs: 50})
+	for i := 0; i < 10; i++ {
+		reqID := fmt.Sprintf("receipt_%d", i)
+		receipt := map[string]interface{}{"_id": reqID, "receivedAt": int64(i * 1000)}
+		r.AddReceipt(reqID, &receipt, false)
+	}
+
+	pruned, err := r.PruneReceipts(3000, 0)
+	assert.NoError(err)
+	assert.Equal(3, pruned)
+	assert.Equal(7, r.receipts.Len())
+	receipt, _ := r.GetReceipt("receipt_2")
+	assert.Nil(receipt)
+
+	pruned, err = r.PruneReceipts(0, 5)
+	assert.NoError(err)
+	assert.Equal(2, pruned)
+	assert.Equal(5, r.receipts.Len())
+	receipt, _ = r.GetReceipt("receipt_5")
+	assert.NotNil(receipt)
+
+	pruned, err = r.PruneReceipts(0, 0)
+	assert.NoError(err)
+	assert.Equal(0, pruned)
+}
+
+func TestMemReceiptsDelete(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewMemoryReceipts(&ReceiptStoreConf{MaxDocs: 50})
+	for i := 0; i < 5; i++ {
+		reqID := fmt.Sprintf("receipt_%d", i)
+		receipt := map[string]interface{}{"_id": reqID, "receivedAt": int64(i * 1000)}
+		r.AddReceipt(reqID, &receipt, false)
+	}
+
+	deleted, err := r.DeleteReceipts([]string{"receipt_1", "receipt_3", "unknown"})
+	assert.NoError(err)
+	assert.Equal(2, deleted)
+	assert.Equal(3, r.receipts.Len())
+	receipt, _ := r.GetReceipt("receipt_3")
+	assert.Nil(receipt)
+	receipt, _ = r.GetReceipt("receipt_4")
+	assert.NotNil(receipt)
+
+	deleted, err = r.DeleteReceipts(nil)
+	assert.NoError(err)
+	assert.Equal(0, deleted)
+}
+
+func TestMemReceiptsGetOldest(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewMemoryReceipts(&ReceiptStoreConf{MaxDocs: 50})
+	for i := 0; i < 10; i++ {
+		reqID := fmt.Sprintf("receipt_%d", i)
+		receipt := map[string]interface{}{"_id": reqID, "receivedAt": int64(i * 1000)}
+		r.AddReceipt(reqID, &receipt, false)
+	}
+
+	results, err := r.GetOldestReceipts(1000, 8000, 3)
+	assert.NoError(err)
+	assert.Len(*results, 3)
+	assert.Equal("receipt_2", (*results)[0]["_id"])
+	assert.Equal("receipt_4", (*results)[2]["_id"])
+
+	results, err = r.GetOldestReceipts(5000, 8000, 10)
+	assert.NoError(err)
+	assert.Len(*results, 2)
+	assert.Equal("receipt_7", (*results)[1]["_id"])
+}
+
+func summaryTestReceipt(id string, receivedAt int64, msgType string, pending bool) *map[string]interface{} {
+	receipt := map[string]interface{}{
+		"_id":        id,
+		"receivedAt": receivedAt,
+		"headers":    map[string]interface{}{"type": msgType},
+	}
+	if pending {
+		receipt["pending"] = true
+	}
+	return &receipt
+}
+
+func TestMemReceiptsSummarize(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewMemoryReceipts(&ReceiptStoreConf{MaxDocs: 50})
+	for i, msgType := range []string{"TransactionSuccess", "TransactionFailure", "Error", "TransactionRedeliveryPrevented", "TransactionSuccess", "SendTransaction", "Other"} {
+		reqID := fmt.Sprintf("receipt_%d", i)
+		r.AddReceipt(reqID, summaryTestReceipt(reqID, int64((i+1)*1000), msgType, msgType == "SendTransaction"), false)
+	}
+
+	summary, err := r.SummarizeReceipts(0)
+	assert.NoError(err)
+	assert.Equal(&ReceiptSummary{Total: 7, Success: 2, Failure: 1, Error: 2, Pending: 1}, summary)
+
+	r.AddReceipt("receipt_5", summaryTestReceipt("receipt_5", 8000, "TransactionFailure", false), true)
+	summary, err = r.SummarizeReceipts(0)
+	assert.NoError(err)
+	assert.Equal(&ReceiptSummary{Total: 7, Success: 2, Failure: 2, Error: 2}, summary)
+
+	summary, err = r.SummarizeReceipts(4000)
+	assert.NoError(err)
+	assert.Equal(&ReceiptSummary{Total: 3, Success: 1, Failure: 1}, summary)
+}
+
+// addGroupTestReceipts adds receipts for two methods, with one still pending
+func addGroupTestReceipts(t *testing.T, p ReceiptStorePersistence, receivedAtBase int64) {
+	for i, r := range []struct {
+		msgType string
+		method  string
+		elapsed float64
+	}{
+		{"TransactionSuccess", "set", 1.0},
+		{"TransactionSuccess", "set", 3.0},
+		{"TransactionFailure", "set", 2.0},
+		{"TransactionSuccess", "get", 0.5},
+		{"SendTransaction", "set", 0},
+	} {
+		reqID := fmt.Sprintf("r%d", i)
+		receipt := summaryTestReceipt(reqID, receivedAtBase+int64((i+1)*1000), r.msgType, r.msgType == "SendTransaction")
+		(*receipt)["method"] = r.method
+		if r.elapsed > 0 {
+			(*receipt)["headers"].(map[string]interface{})["timeElapsed"] = r.elapsed
+		}
+		assert.NoError(t, p.AddReceipt(reqID, receipt, false))
+	}
+}
+
+func assertReceiptGroups(t *testing.T, s ReceiptStoreGroupSummarizer, receivedAtBase int64) {
+	assert := assert.New(t)
+
+	groups, err := s.SummarizeReceiptGroups(0, []string{ReceiptGroupByStatus, ReceiptGroupByMethod})
+	assert.NoError(err)
+	assert.Equal([]*ReceiptGroup{
+		{Status: "failure", Method: "set", Count: 1, Latency: &ReceiptLatency{P50: 2, P90: 2, P99: 2}},
+		{Status: "pending", Method: "set", Count: 1},
+		{Status: "success", Method: "get", Count: 1, Latency: &ReceiptLatency{P50: 0.5, P90: 0.5, P99: 0.5}},
+		{Status: "success", Method: "set", Count: 2, Latency: &ReceiptLatency{P50: 1, P90: 3, P99: 3}},
+	}, groups)
+
+	groups, err = s.SummarizeReceiptGroups(0, []string{ReceiptGroupByStatus})
+	assert.NoError(err)
+	assert.Equal([]*ReceiptGroup{
+		{Status: "failure", Count: 1, Latency: &ReceiptLatency{P50: 2, P90: 2, P99: 2}},
+		{Status: "pending", Count: 1},
+		{Status: "success", Count: 3, Latency: &ReceiptLatency{P50: 1, P90: 3, P99: 3}},
+	}, groups)
+
+	groups, err = s.SummarizeReceiptGroups(receivedAtBase+3000, []string{ReceiptGroupByMethod})
+	assert.NoError(err)
+	assert.Equal([]*ReceiptGroup{
+		{Method: "get", Count: 1, Latency: &ReceiptLatency{P50: 0.5, P90: 0.5, P99: 0.5}},
+		{Method: "set", Count: 1},
+	}, groups)
+}
+
+func TestMemReceiptsSummarizeGroups(t *testing.T) {
+	r := NewMemoryReceipts(&ReceiptStoreConf{MaxDocs: 50})
+	addGroupTestReceipts(t, r, 0)
+	assertReceiptGroups(t, r, 0)
+}
+
+func TestMemReceiptsFilterNamespace(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewMemoryReceipts(&ReceiptStoreConf{MaxDocs: 50})
+	for i := 0; i < 6; i++ {
+		reqID := fmt.Sprintf("receipt_%d", i)
+		receipt := map[string]interface{}{"_id": reqID}
+		if i%2 == 0 {
+			receipt["namespace"] = "tenant1"
+		}
+		r.AddReceipt(reqID, &receipt, false)
+	}
+
+	results, err := r.GetNamespaceReceipts("tenant1", 1, 10, nil, 0, "", "", "")
+	assert.NoError(err)
+	assert.Equal(2, len(*results))
+	assert.Equal("receipt_2", (*results)[0]["_id"])
+	assert.Equal("receipt_0", (*results)[1]["_id"])
+
+	results, err = r.GetNamespaceReceipts("tenant2", 0, 10, nil, 0, "", "", "")
+	assert.NoError(err)
+	assert.Empty(*results)
 }
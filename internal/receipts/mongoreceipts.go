@@ -20,6 +20,7 @@ import (
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
 	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -83,9 +84,51 @@ func (m *MongoReceipts) AddReceipt(requestID string, receipt *map[string]interfa
 	}
 }
 
+// AddReceipts upserts a batch of receipts in a single ordered bulk request
+func (m *MongoReceipts) AddReceipts(receipts []map[string]interface{}) error {
+	pairs := make([]interface{}, 0, len(receipts)*2)
+	for _, receipt := range receipts {
+		pairs = append(pairs, bson.M{"_id": receipt["_id"]}, receipt)
+	}
+	return m.collection.BulkUpsert(pairs...)
+}
+
+// UpdateReceipt applies a partial update to a receipt, if it is still at the expected revision.
+// The revision is part of the upsert query, so if another writer has changed the receipt the
+// upsert attempts an insert, which fails on the duplicate _id.
+func (m *MongoReceipts) UpdateReceipt(requestID string, expectedRevision int64, fields map[string]interface{}) (*map[string]interface{}, error) {
+	existing, err := m.GetReceipt(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if ReceiptRevision(existing) != expectedRevision {
+		return nil, errors.Errorf(errors.ReceiptStoreRevisionConflict, requestID, expectedRevision)
+	}
+	updated := mergeReceiptUpdate(requestID, existing, fields)
+	query := bson.M{"_id": requestID, ReceiptRevisionField: expectedRevision}
+	if expectedRevision == 0 {
+		query[ReceiptRevisionField] = bson.M{"$exists": false}
+	}
+	if err := m.collection.Upsert(query, updated); err != nil {
+		if mgo.IsDup(err) {
+			return nil, errors.Errorf(errors.ReceiptStoreRevisionConflict, requestID, expectedRevision)
+		}
+		return nil, err
+	}
+	return &updated, nil
+}
+
 // GetReceipts Returns recent receipts with skip & limit
 func (m *MongoReceipts) GetReceipts(skip, limit int, ids []string, sinceEpochMS int64, from, to, start string) (*[]map[string]interface{}, error) {
+	return m.GetNamespaceReceipts("", skip, limit, ids, sinceEpochMS, from, to, start)
+}
+
+// GetNamespaceReceipts returns the receipts in the namespace, or all receipts for an empty namespace
+func (m *MongoReceipts) GetNamespaceReceipts(namespace string, skip, limit int, ids []string, sinceEpochMS int64, from, to, start string) (*[]map[string]interface{}, error) {
 	filter := bson.M{}
+	if namespace != "" {
+		filter["namespace"] = namespace
+	}
 	if len(ids) > 0 {
 		filter["_id"] = bson.M{
 			"$in": ids,
@@ -131,3 +174,136 @@ func (m *MongoReceipts) GetReceipt(requestID string) (*map[string]interface{}, e
 		return &result, nil
 	}
 }
+
+// GetOldestReceipts returns receipts in the range, sorted oldest first
+func (m *MongoReceipts) GetOldestReceipts(afterEpochMS, beforeEpochMS int64, limit int) (*[]map[string]interface{}, error) {
+	query := m.collection.Find(bson.M{
+		"receivedAt": bson.M{
+			"$gt": afterEpochMS,
+			"$lt": beforeEpochMS,
+		},
+	})
+	query.Sort("receivedAt")
+	query.Limit(limit)
+	results := make([]map[string]interface{}, 0, limit)
+	if err := query.All(&results); err != nil && err != mgo.ErrNotFound {
+		return nil, err
+	}
+	return &results, nil
+}
+
+// PruneReceipts removes receipts received before the cutoff. For the maximum count, we find the
+// receivedAt time of the oldest receipt we should keep, and remove everything older.
+func (m *MongoReceipts) PruneReceipts(olderThanEpochMS int64, maxCount int) (int, error) {
+	pruned := 0
+	if olderThanEpochMS > 0 {
+		removed, err := m.collection.RemoveAll(bson.M{"receivedAt": bson.M{"$lt": olderThanEpochMS}})
+		if err != nil {
+			return 0, err
+		}
+		pruned += removed
+	}
+	if maxCount > 0 {
+		query := m.collection.Find(bson.M{})
+		query.Sort("-receivedAt")
+		query.Skip(maxCount - 1)
+		query.Limit(1)
+		oldestKept := make(map[string]interface{})
+		if err := query.One(&oldestKept); err == mgo.ErrNotFound {
+			return pruned, nil
+		} else if err != nil {
+			return pruned, err
+		}
+		removed, err := m.collection.RemoveAll(bson.M{"receivedAt": bson.M{"$lt": ReceiptReceivedAt(oldestKept)}})
+		if err != nil {
+			return pruned, err
+		}
+		pruned += removed
+	}
+	return pruned, nil
+}
+
+// SummarizeReceipts counts the receipts of each outcome with a query per outcome, which can use
+// the index on receivedAt to narrow down to the window
+func (m *MongoReceipts) SummarizeReceipts(sinceEpochMS int64) (*ReceiptSummary, error) {
+	count := func(filter bson.M) (int, error) {
+		if sinceEpochMS > 0 {
+			filter["receivedAt"] = bson.M{"$gt": sinceEpochMS}
+		}
+		return m.collection.Find(filter).Count()
+	}
+	summary := &ReceiptSummary{}
+	counts := []struct {
+		filter bson.M
+		result *int
+	}{
+		{bson.M{}, &summary.Total},
+		{bson.M{"pending": true}, &summary.Pending},
+		{bson.M{"headers.type": messages.MsgTypeTransactionSuccess}, &summary.Success},
+		{bson.M{"headers.type": messages.MsgTypeTransactionFailure}, &summary.Failure},
+		{bson.M{"headers.type": bson.M{"$in": receiptErrorTypes}}, &summary.Error},
+	}
+	for _, c := range counts {
+		n, err := count(c.filter)
+		if err != nil {
+			return nil, err
+		}
+		*c.result = n
+	}
+	return summary, nil
+}
+
+type mongoReceiptGroupID struct {
+	Pending bool   `bson:"pending"`
+	Type    string `bson:"type"`
+	Method  string `bson:"method"`
+}
+
+// mongoReceiptGroup is a result of the aggregation. The elapsed times are decoded generically,
+// as they are missing from pending receipts.
+type mongoReceiptGroup struct {
+	ID      mongoReceiptGroupID `bson:"_id"`
+	Count   int                 `bson:"count"`
+	Elapsed []interface{}       `bson:"elapsed"`
+}
+
+// SummarizeReceiptGroups groups the receipts in an aggregation pipeline, by the fields that
+// determine the status and the method, and returns the elapsed times to calculate percentiles
+func (m *MongoReceipts) SummarizeReceiptGroups(sinceEpochMS int64, groupBy []string) ([]*ReceiptGroup, error) {
+	var pipeline []bson.M
+	if sinceEpochMS > 0 {
+		pipeline = append(pipeline, bson.M{"$match": bson.M{"receivedAt": bson.M{"$gt": sinceEpochMS}}})
+	}
+	pipeline = append(pipeline, bson.M{"$group": bson.M{
+		"_id": bson.M{
+			"pending": bson.M{"$ifNull": []interface{}{"$pending", false}},
+			"type":    bson.M{"$ifNull": []interface{}{"$headers.type", ""}},
+			"method":  bson.M{"$ifNull": []interface{}{"$method", ""}},
+		},
+		"count":   bson.M{"$sum": 1},
+		"elapsed": bson.M{"$push": "$headers.timeElapsed"},
+	}})
+	var results []mongoReceiptGroup
+	if err := m.collection.Pipe(pipeline).All(&results); err != nil {
+		return nil, err
+	}
+	groups := newReceiptGroups(groupBy)
+	for _, r := range results {
+		elapsed := make([]float64, 0, len(r.Elapsed))
+		for _, v := range r.Elapsed {
+			if e, ok := receiptElapsed(v); ok {
+				elapsed = append(elapsed, e)
+			}
+		}
+		groups.add(receiptStatus(r.ID.Pending, r.ID.Type), r.ID.Method, r.Count, elapsed...)
+	}
+	return groups.result(), nil
+}
+
+// DeleteReceipts removes the receipts with the given request IDs
+func (m *MongoReceipts) DeleteReceipts(requestIDs []string) (int, error) {
+	if len(requestIDs) == 0 {
+		return 0, nil
+	}
+	return m.collection.RemoveAll(bson.M{"_id": bson.M{"$in": requestIDs}})
+}
@@ -51,6 +51,13 @@ type mockCollection struct {
 	ensureIndexErr error
 	mockQuery      mockQuery
 	captureQuery   interface{}
+	queries        []interface{}
+	removed        []interface{}
+	removeCount    int
+	removeErr      error
+	bulkUpserted   []interface{}
+	upsertQuery    interface{}
+	pipeline       interface{}
 }
 
 func (m *mockCollection) Insert(payloads ...interface{}) error {
@@ -59,10 +66,16 @@ func (m *mockCollection) Insert(payloads ...interface{}) error {
 }
 
 func (m *mockCollection) Upsert(query interface{}, payload interface{}) error {
+	m.upsertQuery = query
 	m.inserted = payload.(map[string]interface{})
 	return m.insertErr
 }
 
+func (m *mockCollection) BulkUpsert(pairs ...interface{}) error {
+	m.bulkUpserted = pairs
+	return m.insertErr
+}
+
 func (m *mockCollection) Create(info *mgo.CollectionInfo) error {
 	m.collInfo = info
 	return m.collErr
@@ -70,9 +83,20 @@ func (m *mockCollection) Create(info *mgo.CollectionInfo) error {
 
 func (m *mockCollection) Find(query interface{}) MongoQuery {
 	m.captureQuery = query
+	m.queries = append(m.queries, query)
 	return &m.mockQuery
 }
 
+func (m *mockCollection) Pipe(pipeline interface{}) MongoPipe {
+	m.pipeline = pipeline
+	return &m.mockQuery
+}
+
+func (m *mockCollection) RemoveAll(selector interface{}) (int, error) {
+	m.removed = append(m.removed, selector)
+	return m.removeCount, m.removeErr
+}
+
 func (m *mockCollection) EnsureIndex(index mgo.Index) error {
 	return m.ensureIndexErr
 }
@@ -81,6 +105,8 @@ type mockQuery struct {
 	allErr        error
 	oneErr        error
 	resultWranger func(interface{})
+	count         int
+	countErr      error
 	limit         int
 	skip          int
 	sort          []string
@@ -115,6 +141,10 @@ func (m *mockQuery) One(result interface{}) error {
 	return m.oneErr
 }
 
+func (m *mockQuery) Count() (int, error) {
+	return m.count, m.countErr
+}
+
 func TestNewMongoReceipts(t *testing.T) {
 	assert := assert.New(t)
 	conf := &MongoDBReceiptStoreConf{}
@@ -216,6 +246,48 @@ func TestMongoReceiptsUpsertReceiptOK(t *testing.T) {
 	assert.NoError(err)
 }
 
+func TestMongoReceiptsUpdateReceipt(t *testing.T) {
+	assert := assert.New(t)
+
+	mgoMock := &mockMongo{}
+	r := &MongoReceipts{
+		conf: &MongoDBReceiptStoreConf{},
+		mgo:  mgoMock,
+	}
+	r.Connect()
+
+	mgoMock.collection.mockQuery.resultWranger = func(result interface{}) {
+		*(result.(*map[string]interface{})) = map[string]interface{}{"_id": "key", "pending": true, "revision": 2}
+	}
+	updated, err := r.UpdateReceipt("key", 2, map[string]interface{}{"pending": nil, "transactionHash": "0x12345"})
+	assert.NoError(err)
+	assert.Equal(bson.M{"_id": "key", "revision": int64(2)}, mgoMock.collection.upsertQuery)
+	assert.Equal(map[string]interface{}{"_id": "key", "transactionHash": "0x12345", "revision": int64(3)}, *updated)
+
+	_, err = r.UpdateReceipt("key", 1, map[string]interface{}{})
+	assert.Regexp("FFEC100370", err)
+
+	// Another writer updates the receipt between our read and write
+	mgoMock.collection.insertErr = &mgo.LastError{Code: 11000}
+	_, err = r.UpdateReceipt("key", 2, map[string]interface{}{})
+	assert.Regexp("FFEC100370", err)
+
+	mgoMock.collection.insertErr = fmt.Errorf("pop")
+	_, err = r.UpdateReceipt("key", 2, map[string]interface{}{})
+	assert.Regexp("pop", err)
+
+	mgoMock.collection.insertErr = nil
+	mgoMock.collection.mockQuery.resultWranger = nil
+	mgoMock.collection.mockQuery.oneErr = mgo.ErrNotFound
+	_, err = r.UpdateReceipt("new", 0, map[string]interface{}{"pending": true})
+	assert.NoError(err)
+	assert.Equal(bson.M{"_id": "new", "revision": bson.M{"$exists": false}}, mgoMock.collection.upsertQuery)
+
+	mgoMock.collection.mockQuery.oneErr = fmt.Errorf("pop")
+	_, err = r.UpdateReceipt("new", 0, map[string]interface{}{})
+	assert.Regexp("pop", err)
+}
+
 func TestMongoReceiptsAddReceiptFailed(t *testing.T) {
 	assert := assert.New(t)
 
@@ -232,6 +304,30 @@ func TestMongoReceiptsAddReceiptFailed(t *testing.T) {
 	assert.Regexp("pop", err)
 }
 
+func TestMongoReceiptsAddReceiptsBulk(t *testing.T) {
+	assert := assert.New(t)
+
+	mgoMock := &mockMongo{}
+	r := &MongoReceipts{
+		conf: &MongoDBReceiptStoreConf{},
+		mgo:  mgoMock,
+	}
+
+	r.Connect()
+	receipt1 := map[string]interface{}{"_id": "r1"}
+	receipt2 := map[string]interface{}{"_id": "r2"}
+	err := r.AddReceipts([]map[string]interface{}{receipt1, receipt2})
+	assert.NoError(err)
+	assert.Equal([]interface{}{
+		bson.M{"_id": "r1"}, receipt1,
+		bson.M{"_id": "r2"}, receipt2,
+	}, mgoMock.collection.bulkUpserted)
+
+	mgoMock.collection.insertErr = fmt.Errorf("pop")
+	err = r.AddReceipts([]map[string]interface{}{receipt1})
+	assert.Regexp("pop", err)
+}
+
 func TestMongoReceiptsGetReceiptsOK(t *testing.T) {
 	assert := assert.New(t)
 
@@ -294,6 +390,27 @@ func TestMongoReceiptsFilter(t *testing.T) {
 	assert.Equal("value2", (*results)[1]["key2"])
 }
 
+func TestMongoReceiptsFilterNamespace(t *testing.T) {
+	assert := assert.New(t)
+
+	mgoMock := &mockMongo{}
+	r := &MongoReceipts{
+		conf: &MongoDBReceiptStoreConf{},
+		mgo:  mgoMock,
+	}
+
+	r.Connect()
+	_, err := r.GetNamespaceReceipts("tenant1", 0, 10, nil, 0, "", "", "")
+	assert.NoError(err)
+	queryBSON := mgoMock.collection.captureQuery.(bson.M)
+	assert.Equal("tenant1", queryBSON["namespace"])
+
+	_, err = r.GetReceipts(0, 10, nil, 0, "", "", "")
+	assert.NoError(err)
+	queryBSON = mgoMock.collection.captureQuery.(bson.M)
+	assert.NotContains(queryBSON, "namespace")
+}
+
 func TestMongoReceiptsGetReceiptsNotFound(t *testing.T) {
 	assert := assert.New(t)
 
@@ -383,3 +500,166 @@ func TestMongoReceiptsGetReceiptError(t *testing.T) {
 	_, err := r.GetReceipt("receipt1")
 	assert.Regexp("pop", err)
 }
+
+func TestMongoReceiptsPrune(t *testing.T) {
+	assert := assert.New(t)
+
+	r := &MongoReceipts{conf: &MongoDBReceiptStoreConf{}}
+	coll := &mockCollection{removeCount: 5}
+	coll.mockQuery.resultWranger = func(result interface{}) {
+		(*result.(*map[string]interface{}))["receivedAt"] = int64(2000)
+	}
+	r.collection = coll
+
+	pruned, err := r.PruneReceipts(1000, 10)
+	assert.NoError(err)
+	assert.Equal(10, pruned)
+	assert.Equal([]interface{}{
+		bson.M{"receivedAt": bson.M{"$lt": int64(1000)}},
+		bson.M{"receivedAt": bson.M{"$lt": int64(2000)}},
+	}, coll.removed)
+	assert.Equal([]string{"-receivedAt"}, coll.mockQuery.sort)
+	assert.Equal(9, coll.mockQuery.skip)
+	assert.Equal(1, coll.mockQuery.limit)
+}
+
+func TestMongoReceiptsPruneUnderMaxCount(t *testing.T) {
+	assert := assert.New(t)
+
+	coll := &mockCollection{}
+	coll.mockQuery.oneErr = mgo.ErrNotFound
+	r := &MongoReceipts{conf: &MongoDBReceiptStoreConf{}, collection: coll}
+
+	pruned, err := r.PruneReceipts(0, 10)
+	assert.NoError(err)
+	assert.Equal(0, pruned)
+	assert.Empty(coll.removed)
+}
+
+func TestMongoReceiptsPruneErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	coll := &mockCollection{removeErr: fmt.Errorf("pop")}
+	r := &MongoReceipts{conf: &MongoDBReceiptStoreConf{}, collection: coll}
+	_, err := r.PruneReceipts(1000, 0)
+	assert.Regexp("pop", err)
+	_, err = r.PruneReceipts(0, 10)
+	assert.Regexp("pop", err)
+
+	coll = &mockCollection{}
+	coll.mockQuery.oneErr = fmt.Errorf("pop")
+	r.collection = coll
+	_, err = r.PruneReceipts(0, 10)
+	assert.Regexp("pop", err)
+}
+
+func TestMongoReceiptsDelete(t *testing.T) {
+	assert := assert.New(t)
+
+	coll := &mockCollection{removeCount: 2}
+	r := &MongoReceipts{conf: &MongoDBReceiptStoreConf{}, collection: coll}
+
+	deleted, err := r.DeleteReceipts([]string{"r1", "r2"})
+	assert.NoError(err)
+	assert.Equal(2, deleted)
+	assert.Equal([]interface{}{bson.M{"_id": bson.M{"$in": []string{"r1", "r2"}}}}, coll.removed)
+
+	deleted, err = r.DeleteReceipts([]string{})
+	assert.NoError(err)
+	assert.Equal(0, deleted)
+
+	coll.removeErr = fmt.Errorf("pop")
+	_, err = r.DeleteReceipts([]string{"r1"})
+	assert.Regexp("pop", err)
+}
+
+func TestMongoReceiptsGetOldest(t *testing.T) {
+	assert := assert.New(t)
+
+	mgoMock := &mockMongo{}
+	r := &MongoReceipts{
+		conf: &MongoDBReceiptStoreConf{},
+		mgo:  mgoMock,
+	}
+	r.Connect()
+
+	mgoMock.collection.mockQuery.resultWranger = func(result interface{}) {
+		resArray := result.(*[]map[string]interface{})
+		*resArray = append(*resArray, map[string]interface{}{"_id": "r1"})
+	}
+	results, err := r.GetOldestReceipts(1000, 2000, 10)
+	assert.NoError(err)
+	assert.Len(*results, 1)
+	queryBSON := mgoMock.collection.captureQuery.(bson.M)
+	assert.Equal(int64(1000), queryBSON["receivedAt"].(bson.M)["$gt"])
+	assert.Equal(int64(2000), queryBSON["receivedAt"].(bson.M)["$lt"])
+	assert.Equal([]string{"receivedAt"}, mgoMock.collection.mockQuery.sort)
+	assert.Equal(10, mgoMock.collection.mockQuery.limit)
+
+	mgoMock.collection.mockQuery.allErr = fmt.Errorf("pop")
+	_, err = r.GetOldestReceipts(1000, 2000, 10)
+	assert.EqualError(err, "pop")
+}
+
+func TestMongoReceiptsSummarize(t *testing.T) {
+	assert := assert.New(t)
+
+	coll := &mockCollection{}
+	coll.mockQuery.count = 3
+	r := &MongoReceipts{conf: &MongoDBReceiptStoreConf{}, collection: coll}
+
+	summary, err := r.SummarizeReceipts(1000)
+	assert.NoError(err)
+	assert.Equal(&ReceiptSummary{Total: 3, Success: 3, Failure: 3, Error: 3, Pending: 3}, summary)
+	since := bson.M{"$gt": int64(1000)}
+	assert.Equal([]interface{}{
+		bson.M{"receivedAt": since},
+		bson.M{"pending": true, "receivedAt": since},
+		bson.M{"headers.type": "TransactionSuccess", "receivedAt": since},
+		bson.M{"headers.type": "TransactionFailure", "receivedAt": since},
+		bson.M{"headers.type": bson.M{"$in": []string{"Error", "TransactionRedeliveryPrevented", "TransactionCancelled"}}, "receivedAt": since},
+	}, coll.queries)
+
+	coll.queries = nil
+	_, err = r.SummarizeReceipts(0)
+	assert.NoError(err)
+	assert.Equal(bson.M{}, coll.queries[0])
+
+	coll.mockQuery.countErr = fmt.Errorf("pop")
+	_, err = r.SummarizeReceipts(0)
+	assert.EqualError(err, "pop")
+}
+
+func TestMongoReceiptsSummarizeGroups(t *testing.T) {
+	assert := assert.New(t)
+
+	coll := &mockCollection{}
+	coll.mockQuery.resultWranger = func(result interface{}) {
+		*(result.(*[]mongoReceiptGroup)) = []mongoReceiptGroup{
+			{ID: mongoReceiptGroupID{Type: "TransactionSuccess", Method: "set"}, Count: 2, Elapsed: []interface{}{float64(3), 1}},
+			{ID: mongoReceiptGroupID{Type: "Error", Method: "set"}, Count: 1},
+			{ID: mongoReceiptGroupID{Pending: true, Method: "get"}, Count: 1},
+			{ID: mongoReceiptGroupID{Type: "TransactionSuccess", Method: "get"}, Count: 1, Elapsed: []interface{}{int64(2), nil}},
+		}
+	}
+	r := &MongoReceipts{conf: &MongoDBReceiptStoreConf{}, collection: coll}
+
+	groups, err := r.SummarizeReceiptGroups(1000, []string{ReceiptGroupByStatus})
+	assert.NoError(err)
+	assert.Equal([]*ReceiptGroup{
+		{Status: "error", Count: 1},
+		{Status: "pending", Count: 1},
+		{Status: "success", Count: 3, Latency: &ReceiptLatency{P50: 2, P90: 3, P99: 3}},
+	}, groups)
+	pipeline := coll.pipeline.([]bson.M)
+	assert.Len(pipeline, 2)
+	assert.Equal(bson.M{"receivedAt": bson.M{"$gt": int64(1000)}}, pipeline[0]["$match"])
+
+	_, err = r.SummarizeReceiptGroups(0, []string{ReceiptGroupByMethod})
+	assert.NoError(err)
+	assert.Len(coll.pipeline.([]bson.M), 1)
+
+	coll.mockQuery.allErr = fmt.Errorf("pop")
+	_, err = r.SummarizeReceiptGroups(0, []string{ReceiptGroupByMethod})
+	assert.EqualError(err, "pop")
+}
@@ -36,9 +36,12 @@ type MongoDatabase interface {
 type MongoCollection interface {
 	Insert(...interface{}) error
 	Upsert(query interface{}, doc interface{}) error
+	BulkUpsert(pairs ...interface{}) error
 	Create(info *mgo.CollectionInfo) error
 	EnsureIndex(index mgo.Index) error
 	Find(query interface{}) MongoQuery
+	Pipe(pipeline interface{}) MongoPipe
+	RemoveAll(selector interface{}) (int, error)
 }
 
 type mgoWrapper struct {
@@ -74,11 +77,30 @@ func (m *collWrapper) Find(query interface{}) MongoQuery {
 	return m.coll.Find(query)
 }
 
+func (m *collWrapper) Pipe(pipeline interface{}) MongoPipe {
+	return m.coll.Pipe(pipeline)
+}
+
+func (m *collWrapper) RemoveAll(selector interface{}) (int, error) {
+	info, err := m.coll.RemoveAll(selector)
+	if err != nil {
+		return 0, err
+	}
+	return info.Removed, nil
+}
+
 func (m *collWrapper) Upsert(query interface{}, doc interface{}) error {
 	_, err := m.coll.Upsert(query, doc)
 	return err
 }
 
+func (m *collWrapper) BulkUpsert(pairs ...interface{}) error {
+	bulk := m.coll.Bulk()
+	bulk.Upsert(pairs...)
+	_, err := bulk.Run()
+	return err
+}
+
 // MongoQuery is the subset of mgo that we use, allowing stubbing
 type MongoQuery interface {
 	Limit(n int) *mgo.Query
@@ -86,4 +108,10 @@ type MongoQuery interface {
 	Sort(fields ...string) *mgo.Query
 	All(result interface{}) error
 	One(result interface{}) error
+	Count() (int, error)
+}
+
+// MongoPipe is the subset of mgo that we use for aggregation pipelines, allowing stubbing
+type MongoPipe interface {
+	All(result interface{}) error
 }
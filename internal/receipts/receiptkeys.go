@@ -0,0 +1,233 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receipts
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+)
+
+const (
+	defaultVaultTransitMount     = "transit"
+	defaultVaultRequestTimeoutMS = 10000
+)
+
+// VaultTransitConf configures a HashiCorp Vault transit secrets engine as the key encryption key
+type VaultTransitConf struct {
+	URL              string          `json:"url,omitempty"`
+	Mount            string          `json:"mount,omitempty"`
+	KeyName          string          `json:"keyName,omitempty"`
+	Token            string          `json:"token,omitempty"`
+	TokenEnv         string          `json:"tokenEnv,omitempty"`
+	TLS              utils.TLSConfig `json:"tls,omitempty"`
+	RequestTimeoutMS int             `json:"requestTimeout,omitempty"`
+}
+
+// keyWrapper encrypts and decrypts the data encryption keys, using a key encryption key
+// that never leaves the wrapper
+type keyWrapper interface {
+	keyID() string
+	wrap(dek []byte) (string, error)
+	unwrap(wrapped string) ([]byte, error)
+}
+
+func newKeyWrapper(conf *ReceiptEncryptionConf) (keyWrapper, error) {
+	switch {
+	case conf.Vault.URL != "":
+		return newVaultKeyWrapper(&conf.Vault)
+	case conf.KeyFile != "":
+		b, err := ioutil.ReadFile(conf.KeyFile)
+		if err != nil {
+			return nil, errors.Errorf(errors.ReceiptStoreEncryptionKey, err)
+		}
+		return newLocalKeyWrapper(conf.KeyID, string(b))
+	case conf.KeyEnv != "":
+		return newLocalKeyWrapper(conf.KeyID, os.Getenv(conf.KeyEnv))
+	}
+	return nil, errors.Errorf(errors.ReceiptStoreEncryptionKey, "one of keyFile, keyEnv or vault must be configured")
+}
+
+// localKeyWrapper uses an AES-256 key from a file or environment variable
+type localKeyWrapper struct {
+	id   string
+	aead cipher.AEAD
+}
+
+func newLocalKeyWrapper(id, encoded string) (*localKeyWrapper, error) {
+	encoded = strings.TrimSpace(encoded)
+	key, err := hex.DecodeString(strings.TrimPrefix(encoded, "0x"))
+	if err != nil {
+		if key, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, errors.Errorf(errors.ReceiptStoreEncryptionKey, "key must be hex or base64 encoded")
+		}
+	}
+	if len(key) != 32 {
+		return nil, errors.Errorf(errors.ReceiptStoreEncryptionKey, fmt.Sprintf("key must be 32 bytes, found %d", len(key)))
+	}
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, errors.Errorf(errors.ReceiptStoreEncryptionKey, err)
+	}
+	if id == "" {
+		id = "local"
+	}
+	return &localKeyWrapper{id: id, aead: aead}, nil
+}
+
+func (w *localKeyWrapper) keyID() string {
+	return w.id
+}
+
+func (w *localKeyWrapper) wrap(dek []byte) (string, error) {
+	sealed, err := seal(w.aead, dek, nil)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (w *localKeyWrapper) unwrap(wrapped string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return open(w.aead, sealed, nil)
+}
+
+// vaultKeyWrapper uses the encrypt and decrypt endpoints of a Vault transit key, so the
+// key encryption key is held by Vault
+type vaultKeyWrapper struct {
+	conf    *VaultTransitConf
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func newVaultKeyWrapper(conf *VaultTransitConf) (*vaultKeyWrapper, error) {
+	if conf.KeyName == "" {
+		return nil, errors.Errorf(errors.ReceiptStoreEncryptionKey, "vault.keyName must be set")
+	}
+	if conf.Mount == "" {
+		conf.Mount = defaultVaultTransitMount
+	}
+	if conf.RequestTimeoutMS <= 0 {
+		conf.RequestTimeoutMS = defaultVaultRequestTimeoutMS
+	}
+	tlsConfig, err := utils.CreateTLSConfiguration(&conf.TLS)
+	if err != nil {
+		return nil, err
+	}
+	token := conf.Token
+	if conf.TokenEnv != "" {
+		token = os.Getenv(conf.TokenEnv)
+	}
+	return &vaultKeyWrapper{
+		conf:    conf,
+		baseURL: strings.TrimSuffix(conf.URL, "/") + "/v1/" + conf.Mount,
+		token:   token,
+		client: &http.Client{
+			Timeout:   time.Duration(conf.RequestTimeoutMS) * time.Millisecond,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+func (w *vaultKeyWrapper) keyID() string {
+	return "vault:" + w.conf.KeyName
+}
+
+func (w *vaultKeyWrapper) request(op string, body map[string]string) (map[string]interface{}, error) {
+	bodyBytes, _ := json.Marshal(body)
+	path := "/" + op + "/" + w.conf.KeyName
+	req, _ := http.NewRequest("POST", w.baseURL+path, bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", w.token)
+	res, err := w.client.Do(req)
+	if err != nil {
+		return nil, errors.Errorf(errors.ReceiptStoreVaultRequest, op, err)
+	}
+	defer res.Body.Close()
+	resBody, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != 200 {
+		return nil, errors.Errorf(errors.ReceiptStoreVaultRequest, op, fmt.Sprintf("[%d] %s", res.StatusCode, resBody))
+	}
+	var resJSON struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(resBody, &resJSON); err != nil {
+		return nil, errors.Errorf(errors.ReceiptStoreVaultRequest, op, err)
+	}
+	return resJSON.Data, nil
+}
+
+func (w *vaultKeyWrapper) wrap(dek []byte) (string, error) {
+	data, err := w.request("encrypt", map[string]string{
+		"plaintext": base64.StdEncoding.EncodeToString(dek),
+	})
+	if err != nil {
+		return "", err
+	}
+	return utils.GetMapString(data, "ciphertext"), nil
+}
+
+func (w *vaultKeyWrapper) unwrap(wrapped string) ([]byte, error) {
+	data, err := w.request("decrypt", map[string]string{
+		"ciphertext": wrapped,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(utils.GetMapString(data, "plaintext"))
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts with a random nonce, which is prefixed to the ciphertext. The additional data
+// is authenticated but not encrypted, and must be passed unchanged to open.
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce := sealed[:aead.NonceSize()]
+	return aead.Open(nil, nonce, sealed[aead.NonceSize():], additionalData)
+}
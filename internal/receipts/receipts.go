@@ -14,19 +14,359 @@
 
 package receipts
 
+import (
+	"encoding/json"
+	"math"
+	"sort"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/migrations"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+)
+
 // ReceiptStorePersistence interface implemented by persistence layers
 type ReceiptStorePersistence interface {
 	GetReceipts(skip, limit int, ids []string, sinceEpochMS int64, from, to, start string) (*[]map[string]interface{}, error)
 	GetReceipt(requestID string) (*map[string]interface{}, error)
 	AddReceipt(requestID string, receipt *map[string]interface{}, overwriteAndRetry bool) error
+	PruneReceipts(olderThanEpochMS int64, maxCount int) (int, error)
+	DeleteReceipts(requestIDs []string) (int, error)
+}
+
+// ReceiptSearch contains the additional filters for persistence layers that support rich queries
+type ReceiptSearch struct {
+	Text            string
+	ContractAddress string
+	Namespace       string
+}
+
+// ReceiptStoreSearcher is optionally implemented by persistence layers that support rich queries
+type ReceiptStoreSearcher interface {
+	SearchReceipts(skip, limit int, ids []string, sinceEpochMS int64, from, to string, search *ReceiptSearch) (*[]map[string]interface{}, error)
+}
+
+// ReceiptStoreNamespaceReader is optionally implemented by persistence layers that can list the
+// receipts in a single namespace, which is recorded in the top-level namespace field of the receipt
+type ReceiptStoreNamespaceReader interface {
+	GetNamespaceReceipts(namespace string, skip, limit int, ids []string, sinceEpochMS int64, from, to, start string) (*[]map[string]interface{}, error)
+}
+
+// ReceiptStoreArchiveReader is optionally implemented by persistence layers that can list receipts
+// oldest first, which is required to archive them. Receipts are returned with receivedAt greater
+// than afterEpochMS, and less than beforeEpochMS.
+type ReceiptStoreArchiveReader interface {
+	GetOldestReceipts(afterEpochMS, beforeEpochMS int64, limit int) (*[]map[string]interface{}, error)
+}
+
+// ReceiptStoreBatchWriter is optionally implemented by persistence layers that can write many
+// receipts in one request. Each receipt is keyed by its _id, and replaces any existing receipt.
+type ReceiptStoreBatchWriter interface {
+	AddReceipts(receipts []map[string]interface{}) error
+}
+
+// ReceiptRevisionField holds the revision of a receipt, in persistence layers that version them.
+// A receipt written without a revision is at revision zero.
+const ReceiptRevisionField = "revision"
+
+// ReceiptStoreRevisionWriter is optionally implemented by persistence layers that version receipts,
+// so concurrent writers do not overwrite each other's changes. UpdateReceipt sets the fields on the
+// stored receipt, removing those with a nil value, and increments its revision. It only succeeds if
+// the receipt is still at expectedRevision, and otherwise fails with ReceiptStoreRevisionConflict.
+// A receipt that does not exist is created by an update at revision zero.
+type ReceiptStoreRevisionWriter interface {
+	UpdateReceipt(requestID string, expectedRevision int64, fields map[string]interface{}) (*map[string]interface{}, error)
+}
+
+// ReceiptSummary counts the receipts received in a window, by outcome. Pending receipts are
+// those for requests that have been accepted, but have not yet had a reply.
+type ReceiptSummary struct {
+	Total   int `json:"total"`
+	Success int `json:"success"`
+	Failure int `json:"failure"`
+	Error   int `json:"error"`
+	Pending int `json:"pending"`
+}
+
+// ReceiptStoreSummarizer is optionally implemented by persistence layers that can count
+// receipts by outcome, for receipts received after sinceEpochMS (zero for all receipts)
+type ReceiptStoreSummarizer interface {
+	SummarizeReceipts(sinceEpochMS int64) (*ReceiptSummary, error)
+}
+
+// ReceiptStoreGroupSummarizer is optionally implemented by persistence layers that can count
+// receipts received after sinceEpochMS in groups, with the latency percentiles of each group.
+// groupBy contains one or both of ReceiptGroupByStatus and ReceiptGroupByMethod.
+type ReceiptStoreGroupSummarizer interface {
+	SummarizeReceiptGroups(sinceEpochMS int64, groupBy []string) ([]*ReceiptGroup, error)
+}
+
+// ReceiptStoreWrapper is implemented by persistence layers that wrap another, such as the
+// encryption layer. A wrapper implements all of the optional interfaces, but each is only
+// available when the persistence layer beneath it implements it too. Use the As functions
+// below, rather than a type assertion, to check for an optional interface.
+type ReceiptStoreWrapper interface {
+	WrappedPersistence() ReceiptStorePersistence
+}
+
+// AsSearcher returns the persistence layer as a ReceiptStoreSearcher, if it supports search
+func AsSearcher(p ReceiptStorePersistence) (ReceiptStoreSearcher, bool) {
+	searcher, ok := p.(ReceiptStoreSearcher)
+	if w, isWrapper := p.(ReceiptStoreWrapper); ok && isWrapper {
+		_, ok = AsSearcher(w.WrappedPersistence())
+	}
+	return searcher, ok
+}
+
+// AsNamespaceReader returns the persistence layer as a ReceiptStoreNamespaceReader, if it supports namespaces
+func AsNamespaceReader(p ReceiptStorePersistence) (ReceiptStoreNamespaceReader, bool) {
+	nsReader, ok := p.(ReceiptStoreNamespaceReader)
+	if w, isWrapper := p.(ReceiptStoreWrapper); ok && isWrapper {
+		_, ok = AsNamespaceReader(w.WrappedPersistence())
+	}
+	return nsReader, ok
+}
+
+// AsArchiveReader returns the persistence layer as a ReceiptStoreArchiveReader, if it supports archiving
+func AsArchiveReader(p ReceiptStorePersistence) (ReceiptStoreArchiveReader, bool) {
+	reader, ok := p.(ReceiptStoreArchiveReader)
+	if w, isWrapper := p.(ReceiptStoreWrapper); ok && isWrapper {
+		_, ok = AsArchiveReader(w.WrappedPersistence())
+	}
+	return reader, ok
+}
+
+// AsRevisionWriter returns the persistence layer as a ReceiptStoreRevisionWriter, if it versions receipts
+func AsRevisionWriter(p ReceiptStorePersistence) (ReceiptStoreRevisionWriter, bool) {
+	rw, ok := p.(ReceiptStoreRevisionWriter)
+	if w, isWrapper := p.(ReceiptStoreWrapper); ok && isWrapper {
+		_, ok = AsRevisionWriter(w.WrappedPersistence())
+	}
+	return rw, ok
+}
+
+// AsSummarizer returns the persistence layer as a ReceiptStoreSummarizer, if it supports summaries
+func AsSummarizer(p ReceiptStorePersistence) (ReceiptStoreSummarizer, bool) {
+	summarizer, ok := p.(ReceiptStoreSummarizer)
+	if w, isWrapper := p.(ReceiptStoreWrapper); ok && isWrapper {
+		_, ok = AsSummarizer(w.WrappedPersistence())
+	}
+	return summarizer, ok
+}
+
+// AsGroupSummarizer returns the persistence layer as a ReceiptStoreGroupSummarizer, if it supports grouped summaries
+func AsGroupSummarizer(p ReceiptStorePersistence) (ReceiptStoreGroupSummarizer, bool) {
+	groupSummarizer, ok := p.(ReceiptStoreGroupSummarizer)
+	if w, isWrapper := p.(ReceiptStoreWrapper); ok && isWrapper {
+		_, ok = AsGroupSummarizer(w.WrappedPersistence())
+	}
+	return groupSummarizer, ok
+}
+
+const (
+	// ReceiptGroupByStatus groups receipts by their outcome
+	ReceiptGroupByStatus = "status"
+	// ReceiptGroupByMethod groups receipts by the contract method that was invoked
+	ReceiptGroupByMethod = "method"
+)
+
+// The outcomes of a receipt, as used when grouping by status
+const (
+	ReceiptStatusPending = "pending"
+	ReceiptStatusSuccess = "success"
+	ReceiptStatusFailure = "failure"
+	ReceiptStatusError   = "error"
+	ReceiptStatusOther   = "other"
+)
+
+// ReceiptGroup is the count of receipts in a group. The latency is the time in seconds between
+// the request being received and its reply, so it is only available for receipts with a reply.
+type ReceiptGroup struct {
+	Status  string          `json:"status,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Count   int             `json:"count"`
+	Latency *ReceiptLatency `json:"latency,omitempty"`
+}
+
+// ReceiptLatency contains latency percentiles in seconds
+type ReceiptLatency struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+// receiptErrorTypes are the reply types counted as errors. A prevented redelivery is
+// stored as an error by the receipt store, as the outcome of the transaction is unknown.
+// A cancelled request never reached the node, so is also an error.
+var receiptErrorTypes = []string{messages.MsgTypeError, messages.MsgTypeTransactionRedeliveryPrevented, messages.MsgTypeTransactionCancelled}
+
+// receiptStatus returns the outcome of a receipt from its pending flag and reply type
+func receiptStatus(pending bool, msgType string) string {
+	if pending {
+		return ReceiptStatusPending
+	}
+	switch msgType {
+	case messages.MsgTypeTransactionSuccess:
+		return ReceiptStatusSuccess
+	case messages.MsgTypeTransactionFailure:
+		return ReceiptStatusFailure
+	}
+	for _, t := range receiptErrorTypes {
+		if msgType == t {
+			return ReceiptStatusError
+		}
+	}
+	return ReceiptStatusOther
+}
+
+func receiptStatusOf(receipt map[string]interface{}) string {
+	pending, _ := receipt["pending"].(bool)
+	headers, _ := receipt["headers"].(map[string]interface{})
+	msgType, _ := headers["type"].(string)
+	return receiptStatus(pending, msgType)
+}
+
+// add counts a receipt, for stores that summarize by scanning
+func (s *ReceiptSummary) add(receipt map[string]interface{}) {
+	s.Total++
+	switch receiptStatusOf(receipt) {
+	case ReceiptStatusPending:
+		s.Pending++
+	case ReceiptStatusSuccess:
+		s.Success++
+	case ReceiptStatusFailure:
+		s.Failure++
+	case ReceiptStatusError:
+		s.Error++
+	}
+}
+
+type receiptGroupKey struct {
+	status string
+	method string
+}
+
+type receiptGroupValues struct {
+	count   int
+	elapsed []float64
+}
+
+// receiptGroups accumulates receipts into groups, for stores that cannot calculate
+// percentiles in the database
+type receiptGroups struct {
+	byStatus bool
+	byMethod bool
+	groups   map[receiptGroupKey]*receiptGroupValues
+}
+
+func newReceiptGroups(groupBy []string) *receiptGroups {
+	g := &receiptGroups{groups: make(map[receiptGroupKey]*receiptGroupValues)}
+	for _, field := range groupBy {
+		switch field {
+		case ReceiptGroupByStatus:
+			g.byStatus = true
+		case ReceiptGroupByMethod:
+			g.byMethod = true
+		}
+	}
+	return g
+}
+
+// add counts receipts in the group for the status and method, with the elapsed times of
+// those that have them
+func (g *receiptGroups) add(status, method string, count int, elapsed ...float64) {
+	var key receiptGroupKey
+	if g.byStatus {
+		key.status = status
+	}
+	if g.byMethod {
+		key.method = method
+	}
+	values, ok := g.groups[key]
+	if !ok {
+		values = &receiptGroupValues{}
+		g.groups[key] = values
+	}
+	values.count += count
+	values.elapsed = append(values.elapsed, elapsed...)
+}
+
+func (g *receiptGroups) addReceipt(receipt map[string]interface{}) {
+	method, _ := receipt["method"].(string)
+	headers, _ := receipt["headers"].(map[string]interface{})
+	if elapsed, ok := receiptElapsed(headers["timeElapsed"]); ok {
+		g.add(receiptStatusOf(receipt), method, 1, elapsed)
+	} else {
+		g.add(receiptStatusOf(receipt), method, 1)
+	}
+}
+
+// result returns the groups ordered by status then method, with the latency percentiles
+func (g *receiptGroups) result() []*ReceiptGroup {
+	result := make([]*ReceiptGroup, 0, len(g.groups))
+	for key, values := range g.groups {
+		group := &ReceiptGroup{
+			Status: key.status,
+			Method: key.method,
+			Count:  values.count,
+		}
+		if len(values.elapsed) > 0 {
+			sort.Float64s(values.elapsed)
+			group.Latency = &ReceiptLatency{
+				P50: percentile(values.elapsed, 50),
+				P90: percentile(values.elapsed, 90),
+				P99: percentile(values.elapsed, 99),
+			}
+		}
+		result = append(result, group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Status != result[j].Status {
+			return result[i].Status < result[j].Status
+		}
+		return result[i].Method < result[j].Method
+	})
+	return result
+}
+
+// percentile uses the nearest-rank method on sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func receiptElapsed(v interface{}) (float64, bool) {
+	switch e := v.(type) {
+	case float64:
+		return e, true
+	case int:
+		return float64(e), true
+	case int64:
+		return float64(e), true
+	case json.Number:
+		f, err := e.Float64()
+		return f, err == nil
+	}
+	return 0, false
 }
 
 // ReceiptStoreConf is the common configuration for all receipt stores
 type ReceiptStoreConf struct {
-	MaxDocs             int `json:"maxDocs"`
-	QueryLimit          int `json:"queryLimit"`
-	RetryInitialDelayMS int `json:"retryInitialDelay"`
-	RetryTimeoutMS      int `json:"retryTimeout"`
+	MaxDocs             int                  `json:"maxDocs"`
+	QueryLimit          int                  `json:"queryLimit"`
+	RetryInitialDelayMS int                  `json:"retryInitialDelay"`
+	RetryTimeoutMS      int                  `json:"retryTimeout"`
+	Retention           ReceiptRetentionConf `json:"retention,omitempty"`
+	SummaryWindows      []string             `json:"summaryWindows,omitempty"`
+}
+
+// ReceiptRetentionConf configures background pruning of receipts, by age and/or total count
+type ReceiptRetentionConf struct {
+	MaxAgeSec        int64 `json:"maxAgeSec,omitempty"`
+	MaxCount         int   `json:"maxCount,omitempty"`
+	PruneIntervalSec int   `json:"pruneIntervalSec,omitempty"`
 }
 
 // MongoDBReceiptStoreConf is the configuration for a MongoDB receipt store
@@ -41,5 +381,47 @@ type MongoDBReceiptStoreConf struct {
 // LevelDBReceiptStoreConf is the configuration for a LevelDB receipt store
 type LevelDBReceiptStoreConf struct {
 	ReceiptStoreConf
-	Path string `json:"path"`
+	Path       string                   `json:"path"`
+	Migrations migrations.MigrationConf `json:"migrations,omitempty"`
+}
+
+// SQLiteReceiptStoreConf is the configuration for an embedded SQLite receipt store.
+// MaxDocs caps the number of rows, with the oldest receipts pruned on insert.
+type SQLiteReceiptStoreConf struct {
+	ReceiptStoreConf
+	Path          string `json:"path"`
+	BusyTimeoutMS int    `json:"busyTimeout,omitempty"`
+}
+
+// ElasticsearchReceiptStoreConf is the configuration for an Elasticsearch receipt store.
+// Receipts are written through an alias, onto indices managed by an ILM policy when RetentionDays is set.
+type ElasticsearchReceiptStoreConf struct {
+	ReceiptStoreConf
+	URL              string          `json:"url"`
+	Index            string          `json:"index"`
+	Username         string          `json:"username,omitempty"`
+	Password         string          `json:"password,omitempty"`
+	TLS              utils.TLSConfig `json:"tls"`
+	RequestTimeoutMS int             `json:"requestTimeout,omitempty"`
+	BulkMaxDocs      int             `json:"bulkMaxDocs,omitempty"`
+	BulkFlushMS      int             `json:"bulkFlushInterval,omitempty"`
+	RetentionDays    int             `json:"retentionDays,omitempty"`
+	RolloverMaxAge   string          `json:"rolloverMaxAge,omitempty"`
+}
+
+// ReceiptReceivedAt extracts the receivedAt timestamp set by the receipt store, which will be
+// a float64 if the receipt has been round-tripped through JSON
+func ReceiptReceivedAt(receipt map[string]interface{}) int64 {
+	switch v := receipt["receivedAt"].(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	case json.Number:
+		i, _ := v.Int64()
+		return i
+	}
+	return 0
 }
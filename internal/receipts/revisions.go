@@ -0,0 +1,124 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receipts
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxRevisionConflicts bounds the re-reads of a receipt that is being updated by other writers
+const maxRevisionConflicts = 10
+
+// ReceiptRevision returns the revision of a receipt, which is zero if it does not exist or was
+// written without one
+func ReceiptRevision(receipt *map[string]interface{}) int64 {
+	if receipt == nil {
+		return 0
+	}
+	switch v := (*receipt)[ReceiptRevisionField].(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	case json.Number:
+		i, _ := v.Int64()
+		return i
+	}
+	return 0
+}
+
+// IsRevisionConflict checks if an update failed because the receipt had already been updated
+func IsRevisionConflict(err error) bool {
+	ee, ok := err.(errors.EthconnectError)
+	return ok && ee.Code() == errors.ReceiptStoreRevisionConflict.Code()
+}
+
+// mergeReceiptUpdate returns a copy of the existing receipt, or a new one, with the fields set
+// or removed and the revision incremented
+func mergeReceiptUpdate(requestID string, existing *map[string]interface{}, fields map[string]interface{}) map[string]interface{} {
+	updated := make(map[string]interface{}, len(fields)+2)
+	if existing != nil {
+		for k, v := range *existing {
+			updated[k] = v
+		}
+	}
+	for k, v := range fields {
+		if v == nil {
+			delete(updated, k)
+		} else {
+			updated[k] = v
+		}
+	}
+	updated["_id"] = requestID
+	updated[ReceiptRevisionField] = ReceiptRevision(existing) + 1
+	return updated
+}
+
+// lockedRevisionUpdate implements UpdateReceipt for persistence layers that are only written by
+// this process, where holding a lock across the read and write is enough to compare-and-set
+func lockedRevisionUpdate(mux *sync.Mutex, p ReceiptStorePersistence, requestID string, expectedRevision int64, fields map[string]interface{}) (*map[string]interface{}, error) {
+	mux.Lock()
+	defer mux.Unlock()
+	existing, err := p.GetReceipt(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if ReceiptRevision(existing) != expectedRevision {
+		return nil, errors.Errorf(errors.ReceiptStoreRevisionConflict, requestID, expectedRevision)
+	}
+	updated := mergeReceiptUpdate(requestID, existing, fields)
+	if err := p.AddReceipt(requestID, &updated, true); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// UpdateReceiptFields applies a partial update to a receipt. The update function is passed the
+// current receipt, or nil if there is none, and returns the fields to set (nil values remove a
+// field), or nil to leave the receipt unchanged. If the persistence layer versions receipts, the
+// write is a compare-and-set against the revision that was read, and is re-read and retried if
+// another writer got there first. Otherwise the last writer wins.
+func UpdateReceiptFields(p ReceiptStorePersistence, requestID string, update func(existing *map[string]interface{}) map[string]interface{}) (*map[string]interface{}, error) {
+	rw, versioned := AsRevisionWriter(p)
+	for attempt := 1; ; attempt++ {
+		existing, err := p.GetReceipt(requestID)
+		if err != nil {
+			return nil, err
+		}
+		fields := update(existing)
+		if fields == nil {
+			return existing, nil
+		}
+		if !versioned {
+			updated := mergeReceiptUpdate(requestID, existing, fields)
+			delete(updated, ReceiptRevisionField)
+			if err := p.AddReceipt(requestID, &updated, true); err != nil {
+				return nil, err
+			}
+			return &updated, nil
+		}
+		updated, err := rw.UpdateReceipt(requestID, ReceiptRevision(existing), fields)
+		if err == nil || !IsRevisionConflict(err) || attempt >= maxRevisionConflicts {
+			return updated, err
+		}
+		log.Debugf("%s: Receipt updated concurrently, retrying: %s", requestID, err)
+	}
+}
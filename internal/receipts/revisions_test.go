@@ -0,0 +1,130 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receipts
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type unversionedReceipts struct {
+	ReceiptStorePersistence
+}
+
+func TestReceiptRevision(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(int64(0), ReceiptRevision(nil))
+	for _, v := range []interface{}{int64(3), 3, float64(3), json.Number("3")} {
+		assert.Equal(int64(3), ReceiptRevision(&map[string]interface{}{"revision": v}))
+	}
+	assert.Equal(int64(0), ReceiptRevision(&map[string]interface{}{"revision": "3"}))
+	assert.False(IsRevisionConflict(fmt.Errorf("pop")))
+}
+
+func TestUpdateReceiptCompareAndSet(t *testing.T) {
+	assert := assert.New(t)
+	m := NewMemoryReceipts(&ReceiptStoreConf{MaxDocs: 10})
+
+	created, err := m.UpdateReceipt("id1", 0, map[string]interface{}{"pending": true, "from": "0x12345"})
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{"_id": "id1", "pending": true, "from": "0x12345", "revision": int64(1)}, *created)
+
+	_, err = m.UpdateReceipt("id1", 0, map[string]interface{}{"pending": false})
+	assert.True(IsRevisionConflict(err))
+	assert.Regexp("FFEC100370.*id1.*0", err)
+
+	updated, err := m.UpdateReceipt("id1", 1, map[string]interface{}{"pending": nil, "transactionHash": "0xabcde"})
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{"_id": "id1", "from": "0x12345", "transactionHash": "0xabcde", "revision": int64(2)}, *updated)
+	stored, _ := m.GetReceipt("id1")
+	assert.Equal(*updated, *stored)
+}
+
+func TestUpdateReceiptFieldsRetriesOnConflict(t *testing.T) {
+	assert := assert.New(t)
+	m := NewMemoryReceipts(&ReceiptStoreConf{MaxDocs: 10})
+	_, err := m.UpdateReceipt("id1", 0, map[string]interface{}{"pending": true})
+	assert.NoError(err)
+
+	// A concurrent writer changes the receipt after our first read
+	attempts := 0
+	updated, err := UpdateReceiptFields(m, "id1", func(existing *map[string]interface{}) map[string]interface{} {
+		attempts++
+		if attempts == 1 {
+			_, err := m.UpdateReceipt("id1", ReceiptRevision(existing), map[string]interface{}{"confirmedEvents": true})
+			assert.NoError(err)
+		}
+		return map[string]interface{}{"transactionHash": "0xabcde"}
+	})
+	assert.NoError(err)
+	assert.Equal(2, attempts)
+	assert.Equal(true, (*updated)["confirmedEvents"])
+	assert.Equal("0xabcde", (*updated)["transactionHash"])
+	assert.Equal(int64(3), ReceiptRevision(updated))
+
+	// Gives up if the receipt never stops changing
+	attempts = 0
+	_, err = UpdateReceiptFields(m, "id1", func(existing *map[string]interface{}) map[string]interface{} {
+		attempts++
+		_, _ = m.UpdateReceipt("id1", ReceiptRevision(existing), map[string]interface{}{})
+		return map[string]interface{}{}
+	})
+	assert.True(IsRevisionConflict(err))
+	assert.Equal(maxRevisionConflicts, attempts)
+
+	// Nothing to update
+	unchanged, err := UpdateReceiptFields(m, "missing", func(existing *map[string]interface{}) map[string]interface{} {
+		return nil
+	})
+	assert.NoError(err)
+	assert.Nil(unchanged)
+}
+
+func TestUpdateReceiptFieldsUnversioned(t *testing.T) {
+	assert := assert.New(t)
+	m := NewMemoryReceipts(&ReceiptStoreConf{MaxDocs: 10})
+	receipt := map[string]interface{}{"_id": "id1", "pending": true}
+	err := m.AddReceipt("id1", &receipt, false)
+	assert.NoError(err)
+
+	updated, err := UpdateReceiptFields(&unversionedReceipts{m}, "id1", func(existing *map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"pending": nil, "transactionHash": "0xabcde"}
+	})
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{"_id": "id1", "transactionHash": "0xabcde"}, *updated)
+}
+
+func TestLevelDBReceiptsUpdateReceipt(t *testing.T) {
+	assert := assert.New(t)
+	r, err := NewLevelDBReceipts(&LevelDBReceiptStoreConf{Path: t.TempDir()})
+	assert.NoError(err)
+	defer r.store.Close()
+
+	_, err = r.UpdateReceipt("id1", 0, map[string]interface{}{"pending": true, "receivedAt": 1000})
+	assert.NoError(err)
+	updated, err := r.UpdateReceipt("id1", 1, map[string]interface{}{"pending": nil})
+	assert.NoError(err)
+	assert.Equal(int64(2), ReceiptRevision(updated))
+	_, err = r.UpdateReceipt("id1", 1, map[string]interface{}{})
+	assert.Regexp("FFEC100370", err)
+
+	stored, err := r.GetReceipt("id1")
+	assert.NoError(err)
+	assert.Equal(int64(2), ReceiptRevision(stored))
+	assert.Nil((*stored)["pending"])
+}
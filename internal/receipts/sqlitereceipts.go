@@ -0,0 +1,377 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receipts
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	_ "github.com/mattn/go-sqlite3" // registers the sqlite3 database/sql driver
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultSQLiteBusyTimeout = 5000
+)
+
+// sqliteDriverName is the database/sql driver registered by the SQLite driver package
+var sqliteDriverName = "sqlite3"
+
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS receipts (
+		seq         INTEGER PRIMARY KEY AUTOINCREMENT,
+		id          TEXT NOT NULL UNIQUE,
+		received_at INTEGER NOT NULL DEFAULT 0,
+		from_addr   TEXT NOT NULL DEFAULT '',
+		to_addr     TEXT NOT NULL DEFAULT '',
+		body        TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS receipts_received_at ON receipts(received_at)`,
+	`CREATE INDEX IF NOT EXISTS receipts_from ON receipts(from_addr)`,
+	`CREATE INDEX IF NOT EXISTS receipts_to ON receipts(to_addr)`,
+}
+
+// SQLiteReceipts is an embedded receipt store, for single node deployments that
+// want durable receipts without running an external database
+type SQLiteReceipts struct {
+	conf      *SQLiteReceiptStoreConf
+	db        *sql.DB
+	maxDocs   int
+	updateMux sync.Mutex
+}
+
+func NewSQLiteReceipts(conf *SQLiteReceiptStoreConf) *SQLiteReceipts {
+	return &SQLiteReceipts{
+		conf: conf,
+	}
+}
+
+func sqliteDriverAvailable() bool {
+	for _, d := range sql.Drivers() {
+		if d == sqliteDriverName {
+			return true
+		}
+	}
+	return false
+}
+
+// Connect opens the database in WAL mode, so readers of the REST API do not block
+// the writer, and creates the schema if required
+func (s *SQLiteReceipts) Connect() (err error) {
+	if !sqliteDriverAvailable() {
+		return errors.Errorf(errors.ReceiptStoreSQLiteDriverMissing)
+	}
+	if s.conf.BusyTimeoutMS <= 0 {
+		s.conf.BusyTimeoutMS = defaultSQLiteBusyTimeout
+	}
+	// Captured before the REST receipt store applies its in-memory MaxDocs default to the shared config
+	s.maxDocs = s.conf.MaxDocs
+	if s.db, err = sql.Open(sqliteDriverName, s.conf.Path); err != nil {
+		return errors.Errorf(errors.ReceiptStoreSQLiteOpen, s.conf.Path, err)
+	}
+	// SQLite only supports a single writer, and the pragmas below are per-connection
+	s.db.SetMaxOpenConns(1)
+	statements := append([]string{
+		"PRAGMA journal_mode=WAL",
+		"PRAGMA synchronous=NORMAL",
+		fmt.Sprintf("PRAGMA busy_timeout=%d", s.conf.BusyTimeoutMS),
+	}, sqliteSchema...)
+	for _, stmt := range statements {
+		if _, err = s.db.Exec(stmt); err != nil {
+			s.db.Close()
+			return errors.Errorf(errors.ReceiptStoreSQLiteOpen, s.conf.Path, err)
+		}
+	}
+	log.Infof("Opened SQLite receipt store at %s (maxDocs=%d)", s.conf.Path, s.maxDocs)
+	return nil
+}
+
+// Close closes the database
+func (s *SQLiteReceipts) Close() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+// AddReceipt inserts the receipt, or replaces it in-place if overwrite is set, then prunes
+// the oldest rows beyond MaxDocs
+func (s *SQLiteReceipts) AddReceipt(requestID string, receipt *map[string]interface{}, overwrite bool) error {
+	body, err := json.Marshal(receipt)
+	if err != nil {
+		return err
+	}
+	receivedAt := ReceiptReceivedAt(*receipt)
+	from := utils.GetMapString(*receipt, "from")
+	to := utils.GetMapString(*receipt, "to")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var seq int64
+	err = tx.QueryRow(`SELECT seq FROM receipts WHERE id = ?`, requestID).Scan(&seq)
+	switch {
+	case err == sql.ErrNoRows:
+		res, err := tx.Exec(`INSERT INTO receipts (id, received_at, from_addr, to_addr, body) VALUES (?, ?, ?, ?, ?)`,
+			requestID, receivedAt, from, to, string(body))
+		if err != nil {
+			return err
+		}
+		if seq, err = res.LastInsertId(); err != nil {
+			return err
+		}
+		if s.maxDocs > 0 && seq > int64(s.maxDocs) {
+			// Sequence numbers are only allocated on insert, so everything at or below this point is surplus
+			if _, err = tx.Exec(`DELETE FROM receipts WHERE seq <= ?`, seq-int64(s.maxDocs)); err != nil {
+				return err
+			}
+		}
+	case err != nil:
+		return err
+	case !overwrite:
+		return errors.Errorf(errors.ReceiptStoreKeyNotUnique)
+	default:
+		if _, err = tx.Exec(`UPDATE receipts SET received_at = ?, from_addr = ?, to_addr = ?, body = ? WHERE seq = ?`,
+			receivedAt, from, to, string(body), seq); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UpdateReceipt applies a partial update to a receipt, if it is still at the expected revision.
+// The database is embedded, so only this process writes to it.
+func (s *SQLiteReceipts) UpdateReceipt(requestID string, expectedRevision int64, fields map[string]interface{}) (*map[string]interface{}, error) {
+	return lockedRevisionUpdate(&s.updateMux, s, requestID, expectedRevision, fields)
+}
+
+// GetReceipts Returns recent receipts with skip, limit and other query parameters,
+// newest first. The start parameter is a _sequenceKey from a previous query.
+func (s *SQLiteReceipts) GetReceipts(skip, limit int, ids []string, sinceEpochMS int64, from, to, start string) (*[]map[string]interface{}, error) {
+	var conditions []string
+	var args []interface{}
+	if len(ids) > 0 {
+		conditions = append(conditions, "id IN (?"+strings.Repeat(", ?", len(ids)-1)+")")
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	if sinceEpochMS > 0 {
+		conditions = append(conditions, "received_at > ?")
+		args = append(args, sinceEpochMS)
+	}
+	if from != "" {
+		conditions = append(conditions, "from_addr = ?")
+		args = append(args, from)
+	}
+	if to != "" {
+		conditions = append(conditions, "to_addr = ?")
+		args = append(args, to)
+	}
+	if start != "" {
+		startSeq, err := strconv.ParseInt(start, 10, 64)
+		if err != nil {
+			return nil, errors.Errorf(errors.ReceiptStoreFailedQuery, err)
+		}
+		conditions = append(conditions, "seq <= ?")
+		args = append(args, startSeq)
+	}
+	query := "SELECT seq, body FROM receipts"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY seq DESC"
+	if limit <= 0 {
+		limit = s.conf.QueryLimit
+	}
+	if limit > 0 || skip > 0 {
+		if limit <= 0 {
+			limit = -1 // no limit
+		}
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, skip)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := []map[string]interface{}{}
+	for rows.Next() {
+		var seq int64
+		var body string
+		if err := rows.Scan(&seq, &body); err != nil {
+			return nil, err
+		}
+		receipt := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(body), &receipt); err != nil {
+			log.Errorf("Failed to decode stored receipt with sequence %d", seq)
+			continue
+		}
+		receipt["_sequenceKey"] = strconv.FormatInt(seq, 10)
+		results = append(results, receipt)
+	}
+	return &results, rows.Err()
+}
+
+// GetReceipt returns an individual receipt, or nil if it does not exist
+func (s *SQLiteReceipts) GetReceipt(requestID string) (*map[string]interface{}, error) {
+	var body string
+	err := s.db.QueryRow(`SELECT body FROM receipts WHERE id = ?`, requestID).Scan(&body)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(body), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetOldestReceipts returns receipts in the range oldest first, using the received_at index
+func (s *SQLiteReceipts) GetOldestReceipts(afterEpochMS, beforeEpochMS int64, limit int) (*[]map[string]interface{}, error) {
+	rows, err := s.db.Query(`SELECT seq, body FROM receipts WHERE received_at > ? AND received_at < ? ORDER BY received_at ASC, seq ASC LIMIT ?`,
+		afterEpochMS, beforeEpochMS, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := []map[string]interface{}{}
+	for rows.Next() {
+		var seq int64
+		var body string
+		if err := rows.Scan(&seq, &body); err != nil {
+			return nil, err
+		}
+		receipt := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(body), &receipt); err != nil {
+			log.Errorf("Failed to decode stored receipt with sequence %d", seq)
+			continue
+		}
+		results = append(results, receipt)
+	}
+	return &results, rows.Err()
+}
+
+// PruneReceipts deletes receipts received before the cutoff, and the oldest receipts beyond the maximum count
+func (s *SQLiteReceipts) PruneReceipts(olderThanEpochMS int64, maxCount int) (int, error) {
+	pruned := int64(0)
+	if olderThanEpochMS > 0 {
+		res, err := s.db.Exec(`DELETE FROM receipts WHERE received_at < ?`, olderThanEpochMS)
+		if err != nil {
+			return 0, err
+		}
+		count, _ := res.RowsAffected()
+		pruned += count
+	}
+	if maxCount > 0 {
+		res, err := s.db.Exec(`DELETE FROM receipts WHERE seq NOT IN (SELECT seq FROM receipts ORDER BY seq DESC LIMIT ?)`, maxCount)
+		if err != nil {
+			return int(pruned), err
+		}
+		count, _ := res.RowsAffected()
+		pruned += count
+	}
+	return int(pruned), nil
+}
+
+// DeleteReceipts deletes the receipts with the given request IDs
+func (s *SQLiteReceipts) DeleteReceipts(requestIDs []string) (int, error) {
+	if len(requestIDs) == 0 {
+		return 0, nil
+	}
+	args := make([]interface{}, len(requestIDs))
+	for i, requestID := range requestIDs {
+		args[i] = requestID
+	}
+	res, err := s.db.Exec(`DELETE FROM receipts WHERE id IN (?`+strings.Repeat(`,?`, len(requestIDs)-1)+`)`, args...)
+	if err != nil {
+		return 0, err
+	}
+	count, _ := res.RowsAffected()
+	return int(count), nil
+}
+
+// SummarizeReceipts counts receipts by outcome in a single query, using the received_at index
+// for the window and the SQLite JSON functions to extract the outcome from the body
+func (s *SQLiteReceipts) SummarizeReceipts(sinceEpochMS int64) (*ReceiptSummary, error) {
+	summary := &ReceiptSummary{}
+	err := s.db.QueryRow(`SELECT
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN json_extract(body, '$.pending') = 1 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN json_extract(body, '$.headers.type') = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN json_extract(body, '$.headers.type') = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN json_extract(body, '$.headers.type') IN (?, ?, ?) THEN 1 ELSE 0 END), 0)
+		FROM receipts WHERE received_at > ?`,
+		messages.MsgTypeTransactionSuccess,
+		messages.MsgTypeTransactionFailure,
+		receiptErrorTypes[0], receiptErrorTypes[1], receiptErrorTypes[2],
+		sinceEpochMS,
+	).Scan(&summary.Total, &summary.Pending, &summary.Success, &summary.Failure, &summary.Error)
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// SummarizeReceiptGroups counts receipts in SQL, grouped by the fields that determine the status
+// and the method. SQLite has no percentile function, so the elapsed times are read back for each
+// group, without reading the full body of each receipt.
+func (s *SQLiteReceipts) SummarizeReceiptGroups(sinceEpochMS int64, groupBy []string) ([]*ReceiptGroup, error) {
+	rows, err := s.db.Query(`SELECT
+			COALESCE(json_extract(body, '$.pending'), 0),
+			COALESCE(json_extract(body, '$.headers.type'), ''),
+			COALESCE(json_extract(body, '$.method'), ''),
+			COUNT(*),
+			COALESCE(GROUP_CONCAT(json_extract(body, '$.headers.timeElapsed')), '')
+		FROM receipts WHERE received_at > ?
+		GROUP BY 1, 2, 3`,
+		sinceEpochMS,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	groups := newReceiptGroups(groupBy)
+	for rows.Next() {
+		var pending, count int
+		var msgType, method, elapsedList string
+		if err := rows.Scan(&pending, &msgType, &method, &count, &elapsedList); err != nil {
+			return nil, err
+		}
+		var elapsed []float64
+		if elapsedList != "" {
+			for _, e := range strings.Split(elapsedList, ",") {
+				if f, err := strconv.ParseFloat(e, 64); err == nil {
+					elapsed = append(elapsed, f)
+				}
+			}
+		}
+		groups.add(receiptStatus(pending == 1, msgType), method, count, elapsed...)
+	}
+	return groups.result(), rows.Err()
+}
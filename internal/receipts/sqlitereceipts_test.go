@@ -0,0 +1,240 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receipts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteDriverMissing(t *testing.T) {
+	assert := assert.New(t)
+	defer func(name string) { sqliteDriverName = name }(sqliteDriverName)
+	sqliteDriverName = "not-registered"
+
+	s := NewSQLiteReceipts(&SQLiteReceiptStoreConf{Path: "unused"})
+	err := s.Connect()
+	assert.Regexp("FFEC100249", err)
+	s.Close()
+}
+
+func TestSQLiteReceiptReceivedAt(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(int64(12345), ReceiptReceivedAt(map[string]interface{}{"receivedAt": int64(12345)}))
+	assert.Equal(int64(12345), ReceiptReceivedAt(map[string]interface{}{"receivedAt": float64(12345)}))
+	assert.Equal(int64(12345), ReceiptReceivedAt(map[string]interface{}{"receivedAt": json.Number("12345")}))
+	assert.Equal(int64(0), ReceiptReceivedAt(map[string]interface{}{}))
+}
+
+func newTestSQLiteReceipts(t *testing.T, maxDocs int) (*SQLiteReceipts, func()) {
+	dir, err := ioutil.TempDir("", "sqlite")
+	assert.NoError(t, err)
+	s := NewSQLiteReceipts(&SQLiteReceiptStoreConf{
+		ReceiptStoreConf: ReceiptStoreConf{MaxDocs: maxDocs, QueryLimit: 100},
+		Path:             path.Join(dir, "receipts.sqlite"),
+	})
+	assert.NoError(t, s.Connect())
+	return s, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func addTestSQLiteReceipt(t *testing.T, s *SQLiteReceipts, id string, receivedAt int64, from, to string) {
+	receipt := map[string]interface{}{
+		"_id":        id,
+		"receivedAt": receivedAt,
+		"from":       from,
+		"to":         to,
+	}
+	assert.NoError(t, s.AddReceipt(id, &receipt, false))
+}
+
+func TestSQLiteAddGetReceipts(t *testing.T) {
+	assert := assert.New(t)
+	s, done := newTestSQLiteReceipts(t, 0)
+	defer done()
+
+	for i := 1; i <= 5; i++ {
+		addTestSQLiteReceipt(t, s, fmt.Sprintf("r%d", i), int64(i*1000), fmt.Sprintf("from%d", i%2), "to1")
+	}
+
+	results, err := s.GetReceipts(0, 0, nil, 0, "", "", "")
+	assert.NoError(err)
+	assert.Len(*results, 5)
+	assert.Equal("r5", (*results)[0]["_id"])
+	assert.Equal("5", (*results)[0]["_sequenceKey"])
+
+	results, err = s.GetReceipts(1, 2, nil, 0, "", "", "")
+	assert.NoError(err)
+	assert.Len(*results, 2)
+	assert.Equal("r4", (*results)[0]["_id"])
+
+	results, err = s.GetReceipts(0, 0, []string{"r1", "r2", "r3"}, 1500, "from1", "to1", "")
+	assert.NoError(err)
+	assert.Len(*results, 1)
+	assert.Equal("r3", (*results)[0]["_id"])
+
+	results, err = s.GetReceipts(0, 0, nil, 0, "", "", "3")
+	assert.NoError(err)
+	assert.Len(*results, 3)
+	assert.Equal("r3", (*results)[0]["_id"])
+
+	_, err = s.GetReceipts(0, 0, nil, 0, "", "", "bad")
+	assert.Regexp("FFEC100083", err)
+
+	receipt, err := s.GetReceipt("r2")
+	assert.NoError(err)
+	assert.Equal("from0", (*receipt)["from"])
+
+	receipt, err = s.GetReceipt("unknown")
+	assert.NoError(err)
+	assert.Nil(receipt)
+}
+
+func TestSQLiteAddReceiptDuplicate(t *testing.T) {
+	assert := assert.New(t)
+	s, done := newTestSQLiteReceipts(t, 0)
+	defer done()
+
+	addTestSQLiteReceipt(t, s, "r1", 1000, "from1", "")
+	receipt := map[string]interface{}{"_id": "r1", "status": "updated"}
+	err := s.AddReceipt("r1", &receipt, false)
+	assert.Regexp("FFEC100219", err)
+
+	err = s.AddReceipt("r1", &receipt, true)
+	assert.NoError(err)
+	stored, err := s.GetReceipt("r1")
+	assert.NoError(err)
+	assert.Equal("updated", (*stored)["status"])
+}
+
+func TestSQLiteMaxDocsPruning(t *testing.T) {
+	assert := assert.New(t)
+	s, done := newTestSQLiteReceipts(t, 3)
+	defer done()
+
+	for i := 1; i <= 10; i++ {
+		addTestSQLiteReceipt(t, s, fmt.Sprintf("r%d", i), int64(i), "from1", "")
+	}
+	results, err := s.GetReceipts(0, 0, nil, 0, "", "", "")
+	assert.NoError(err)
+	assert.Len(*results, 3)
+	assert.Equal("r10", (*results)[0]["_id"])
+	assert.Equal("r8", (*results)[2]["_id"])
+}
+
+func TestSQLiteConnectBadPath(t *testing.T) {
+	assert := assert.New(t)
+	s := NewSQLiteReceipts(&SQLiteReceiptStoreConf{Path: "/non/existent/dir/receipts.sqlite"})
+	err := s.Connect()
+	assert.Regexp("FFEC100250", err)
+}
+
+func TestSQLitePruneReceipts(t *testing.T) {
+	assert := assert.New(t)
+	s, done := newTestSQLiteReceipts(t, 0)
+	defer done()
+
+	for i := 1; i <= 10; i++ {
+		addTestSQLiteReceipt(t, s, fmt.Sprintf("r%d", i), int64(i*1000), "from1", "")
+	}
+	pruned, err := s.PruneReceipts(3500, 5)
+	assert.NoError(err)
+	assert.Equal(5, pruned)
+
+	results, err := s.GetReceipts(0, 0, nil, 0, "", "", "")
+	assert.NoError(err)
+	assert.Len(*results, 5)
+	assert.Equal("r6", (*results)[4]["_id"])
+}
+
+func TestSQLiteDeleteReceipts(t *testing.T) {
+	assert := assert.New(t)
+	s, done := newTestSQLiteReceipts(t, 0)
+	defer done()
+
+	for i := 1; i <= 3; i++ {
+		addTestSQLiteReceipt(t, s, fmt.Sprintf("r%d", i), int64(i*1000), "from1", "")
+	}
+	deleted, err := s.DeleteReceipts([]string{"r1", "r3", "unknown"})
+	assert.NoError(err)
+	assert.Equal(2, deleted)
+
+	results, err := s.GetReceipts(0, 0, nil, 0, "", "", "")
+	assert.NoError(err)
+	assert.Len(*results, 1)
+	assert.Equal("r2", (*results)[0]["_id"])
+
+	deleted, err = s.DeleteReceipts(nil)
+	assert.NoError(err)
+	assert.Equal(0, deleted)
+}
+
+func TestSQLiteGetOldestReceipts(t *testing.T) {
+	assert := assert.New(t)
+	s, done := newTestSQLiteReceipts(t, 0)
+	defer done()
+
+	for i := 1; i <= 10; i++ {
+		addTestSQLiteReceipt(t, s, fmt.Sprintf("r%d", i), int64(i*1000), "from1", "")
+	}
+	results, err := s.GetOldestReceipts(2000, 9000, 4)
+	assert.NoError(err)
+	assert.Len(*results, 4)
+	assert.Equal("r3", (*results)[0]["_id"])
+	assert.Equal("r6", (*results)[3]["_id"])
+}
+
+func TestSQLiteSummarizeReceipts(t *testing.T) {
+	assert := assert.New(t)
+	s, done := newTestSQLiteReceipts(t, 0)
+	defer done()
+
+	for i, msgType := range []string{"TransactionSuccess", "TransactionFailure", "Error", "TransactionRedeliveryPrevented", "TransactionCancelled", "SendTransaction"} {
+		reqID := fmt.Sprintf("r%d", i)
+		assert.NoError(s.AddReceipt(reqID, summaryTestReceipt(reqID, int64((i+1)*1000), msgType, msgType == "SendTransaction"), false))
+	}
+
+	summary, err := s.SummarizeReceipts(0)
+	assert.NoError(err)
+	assert.Equal(&ReceiptSummary{Total: 6, Success: 1, Failure: 1, Error: 3, Pending: 1}, summary)
+
+	summary, err = s.SummarizeReceipts(3000)
+	assert.NoError(err)
+	assert.Equal(&ReceiptSummary{Total: 3, Error: 2, Pending: 1}, summary)
+
+	s.Close()
+	_, err = s.SummarizeReceipts(0)
+	assert.Error(err)
+}
+
+func TestSQLiteSummarizeReceiptGroups(t *testing.T) {
+	s, done := newTestSQLiteReceipts(t, 0)
+	defer done()
+
+	addGroupTestReceipts(t, s, 0)
+	assertReceiptGroups(t, s, 0)
+
+	s.Close()
+	_, err := s.SummarizeReceiptGroups(0, []string{ReceiptGroupByStatus})
+	assert.Error(t, err)
+}
@@ -0,0 +1,314 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAccessLogMaxBody = 4096
+	redactedValue           = "***"
+)
+
+var (
+	// Headers that carry credentials are always redacted
+	alwaysRedactedHeaders = []string{"authorization", "proxy-authorization", "cookie", "set-cookie", "x-api-key"}
+	// Fields that could contain key material are always redacted, regardless of config
+	alwaysRedactedFields = []string{"privatekey", "private_key", "privkey", "mnemonic", "seed", "password"}
+)
+
+// AccessLogConf configures structured JSON logging of HTTP requests, written separately to the application log
+type AccessLogConf struct {
+	Enabled       bool                 `json:"enabled"`
+	File          string               `json:"file,omitempty"`
+	SampleRate    *float64             `json:"sampleRate,omitempty"`
+	Bodies        bool                 `json:"bodies,omitempty"`
+	MaxBodySize   int                  `json:"maxBodySize,omitempty"`
+	RedactHeaders []string             `json:"redactHeaders,omitempty"`
+	RedactFields  []string             `json:"redactFields,omitempty"`
+	Routes        []AccessLogRouteConf `json:"routes,omitempty"`
+}
+
+// AccessLogRouteConf overrides the access log settings for requests under a path prefix
+type AccessLogRouteConf struct {
+	Prefix     string   `json:"prefix"`
+	Disabled   bool     `json:"disabled,omitempty"`
+	SampleRate *float64 `json:"sampleRate,omitempty"`
+	Bodies     *bool    `json:"bodies,omitempty"`
+}
+
+type accessLogger struct {
+	conf          *AccessLogConf
+	logger        *logrus.Logger
+	closer        io.Closer
+	redactHeaders map[string]bool
+	redactFields  map[string]bool
+	sample        func() float64
+}
+
+type accessLogRoute struct {
+	disabled   bool
+	sampleRate float64
+	bodies     bool
+}
+
+func newAccessLogger(conf *AccessLogConf) (*accessLogger, error) {
+	al := &accessLogger{
+		conf:          conf,
+		logger:        logrus.New(),
+		redactHeaders: make(map[string]bool),
+		redactFields:  make(map[string]bool),
+		sample:        rand.Float64,
+	}
+	al.logger.SetFormatter(&logrus.JSONFormatter{})
+	al.logger.SetLevel(logrus.InfoLevel)
+	if conf.File != "" {
+		f, err := os.OpenFile(conf.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, errors.Errorf(errors.AccessLogFileOpen, conf.File, err)
+		}
+		al.logger.SetOutput(f)
+		al.closer = f
+	} else {
+		al.logger.SetOutput(os.Stdout)
+	}
+	if conf.MaxBodySize <= 0 {
+		conf.MaxBodySize = defaultAccessLogMaxBody
+	}
+	for _, h := range append(alwaysRedactedHeaders, conf.RedactHeaders...) {
+		al.redactHeaders[strings.ToLower(h)] = true
+	}
+	for _, f := range append(alwaysRedactedFields, conf.RedactFields...) {
+		al.redactFields[strings.ToLower(f)] = true
+	}
+	return al, nil
+}
+
+func (al *accessLogger) close() {
+	if al.closer != nil {
+		_ = al.closer.Close()
+	}
+}
+
+// routeFor finds the settings for the longest matching route prefix, falling back to the defaults
+func (al *accessLogger) routeFor(path string) *accessLogRoute {
+	route := &accessLogRoute{
+		sampleRate: 1,
+		bodies:     al.conf.Bodies,
+	}
+	if al.conf.SampleRate != nil {
+		route.sampleRate = *al.conf.SampleRate
+	}
+	matchLen := -1
+	for _, r := range al.conf.Routes {
+		if strings.HasPrefix(path, r.Prefix) && len(r.Prefix) > matchLen {
+			matchLen = len(r.Prefix)
+			route.disabled = r.Disabled
+			if r.SampleRate != nil {
+				route.sampleRate = *r.SampleRate
+			}
+			if r.Bodies != nil {
+				route.bodies = *r.Bodies
+			}
+		}
+	}
+	return route
+}
+
+func (al *accessLogger) redactJSON(v interface{}) interface{} {
+	switch vt := v.(type) {
+	case map[string]interface{}:
+		for k, fv := range vt {
+			if al.redactFields[strings.ToLower(k)] {
+				vt[k] = redactedValue
+			} else {
+				vt[k] = al.redactJSON(fv)
+			}
+		}
+	case []interface{}:
+		for i, iv := range vt {
+			vt[i] = al.redactJSON(iv)
+		}
+	}
+	return v
+}
+
+// redactBody redacts JSON bodies field by field. Anything we cannot parse is logged
+// as a (truncated) string, unless it looks like a form submission that might contain
+// a redacted field.
+func (al *accessLogger) redactBody(b []byte, truncated bool) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+	var v interface{}
+	if !truncated && json.Unmarshal(b, &v) == nil {
+		return al.redactJSON(v)
+	}
+	s := string(b)
+	lower := strings.ToLower(s)
+	for f := range al.redactFields {
+		if strings.Contains(lower, f) {
+			return redactedValue
+		}
+	}
+	if truncated {
+		s += "..."
+	}
+	return s
+}
+
+func (al *accessLogger) redactedHeaders(h http.Header) map[string]string {
+	headers := make(map[string]string, len(h))
+	for k, v := range h {
+		if al.redactHeaders[strings.ToLower(k)] {
+			headers[k] = redactedValue
+		} else {
+			headers[k] = strings.Join(v, ",")
+		}
+	}
+	return headers
+}
+
+func (al *accessLogger) redactedQuery(req *http.Request) string {
+	q := req.URL.Query()
+	if len(q) == 0 {
+		return ""
+	}
+	for k := range q {
+		if al.redactFields[strings.ToLower(k)] {
+			q.Set(k, redactedValue)
+		}
+	}
+	return q.Encode()
+}
+
+// limitedBuffer captures up to a maximum number of bytes, recording whether anything was dropped
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (lb *limitedBuffer) Write(b []byte) (int, error) {
+	remaining := lb.max - lb.buf.Len()
+	if remaining < len(b) {
+		lb.truncated = true
+		if remaining > 0 {
+			lb.buf.Write(b[:remaining])
+		}
+		return len(b), nil
+	}
+	return lb.buf.Write(b)
+}
+
+// accessLogResponseWriter records the status and size of the response, and optionally the body.
+// It passes through the optional interfaces needed for WebSocket upgrades and streamed responses.
+type accessLogResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+	body   *limitedBuffer
+}
+
+func (w *accessLogResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = 200
+	}
+	if w.body != nil {
+		_, _ = w.body.Write(b)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+func (w *accessLogResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *accessLogResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.Errorf(errors.AccessLogHijackUnsupported)
+	}
+	w.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+func (al *accessLogger) wrap(parent http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		route := al.routeFor(req.URL.Path)
+		if route.disabled || (route.sampleRate < 1 && al.sample() >= route.sampleRate) {
+			parent.ServeHTTP(res, req)
+			return
+		}
+
+		start := time.Now()
+		var reqBody *limitedBuffer
+		if route.bodies && req.Body != nil {
+			reqBody = &limitedBuffer{max: al.conf.MaxBodySize}
+			req.Body = ioutil.NopCloser(io.TeeReader(req.Body, reqBody))
+		}
+		w := &accessLogResponseWriter{ResponseWriter: res}
+		if route.bodies {
+			w.body = &limitedBuffer{max: al.conf.MaxBodySize}
+		}
+
+		parent.ServeHTTP(w, req)
+
+		fields := logrus.Fields{
+			"method":     req.Method,
+			"path":       req.URL.Path,
+			"status":     w.status,
+			"durationMs": float64(time.Since(start).Microseconds()) / 1000,
+			"remote":     req.RemoteAddr,
+			"reqHeaders": al.redactedHeaders(req.Header),
+			"resBytes":   w.size,
+		}
+		if q := al.redactedQuery(req); q != "" {
+			fields["query"] = q
+		}
+		if reqBody != nil {
+			fields["reqBody"] = al.redactBody(reqBody.buf.Bytes(), reqBody.truncated)
+		}
+		if w.body != nil {
+			fields["resBody"] = al.redactBody(w.body.buf.Bytes(), w.body.truncated)
+		}
+		al.logger.WithFields(fields).Info("access")
+	})
+}
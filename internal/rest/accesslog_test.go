@@ -0,0 +1,194 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAccessLogger(t *testing.T, conf *AccessLogConf) (*accessLogger, *bytes.Buffer) {
+	al, err := newAccessLogger(conf)
+	assert.NoError(t, err)
+	buf := &bytes.Buffer{}
+	al.logger.SetOutput(buf)
+	return al, buf
+}
+
+func echoHandler(status int, reply string) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		_, _ = ioutil.ReadAll(req.Body)
+		res.WriteHeader(status)
+		_, _ = res.Write([]byte(reply))
+	})
+}
+
+func parseAccessLog(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var entries []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var entry map[string]interface{}
+		assert.NoError(t, dec.Decode(&entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestAccessLogRedactsHeadersQueryAndBody(t *testing.T) {
+	assert := assert.New(t)
+
+	al, buf := newTestAccessLogger(t, &AccessLogConf{
+		Enabled:      true,
+		Bodies:       true,
+		RedactFields: []string{"secretParam"},
+	})
+	handler := al.wrap(echoHandler(202, `{"id":"abc","privateKey":"0x1234"}`))
+
+	req := httptest.NewRequest("POST", "/contracts/0x123/set?secretParam=hide&fly-sync=true", strings.NewReader(`{"x":"visible","nested":[{"password":"hide"}]}`))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("X-Custom", "keep")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	assert.Equal(202, res.Code)
+
+	entries := parseAccessLog(t, buf)
+	assert.Len(entries, 1)
+	entry := entries[0]
+	assert.Equal("POST", entry["method"])
+	assert.Equal("/contracts/0x123/set", entry["path"])
+	assert.Equal(float64(202), entry["status"])
+	assert.Equal("fly-sync=true&secretParam=%2A%2A%2A", entry["query"])
+	headers := entry["reqHeaders"].(map[string]interface{})
+	assert.Equal("***", headers["Authorization"])
+	assert.Equal("keep", headers["X-Custom"])
+	reqBody := entry["reqBody"].(map[string]interface{})
+	assert.Equal("visible", reqBody["x"])
+	assert.Equal("***", reqBody["nested"].([]interface{})[0].(map[string]interface{})["password"])
+	resBody := entry["resBody"].(map[string]interface{})
+	assert.Equal("abc", resBody["id"])
+	assert.Equal("***", resBody["privateKey"])
+	assert.NotContains(buf.String(), "0x1234")
+}
+
+func TestAccessLogNoBodiesByDefault(t *testing.T) {
+	assert := assert.New(t)
+
+	al, buf := newTestAccessLogger(t, &AccessLogConf{Enabled: true})
+	handler := al.wrap(echoHandler(200, `{"ok":true}`))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/status", nil))
+
+	entries := parseAccessLog(t, buf)
+	assert.Len(entries, 1)
+	assert.Nil(entries[0]["reqBody"])
+	assert.Nil(entries[0]["resBody"])
+	assert.Equal(float64(11), entries[0]["resBytes"])
+}
+
+func TestAccessLogRouteOverrides(t *testing.T) {
+	assert := assert.New(t)
+
+	zero := 0.0
+	bodies := true
+	al, buf := newTestAccessLogger(t, &AccessLogConf{
+		Enabled: true,
+		Routes: []AccessLogRouteConf{
+			{Prefix: "/status", Disabled: true},
+			{Prefix: "/replies", SampleRate: &zero},
+			{Prefix: "/abis", Bodies: &bodies},
+			{Prefix: "/abis/internal", Disabled: true},
+		},
+	})
+	al.sample = func() float64 { return 0.5 }
+	handler := al.wrap(echoHandler(200, "plain text"))
+	for _, p := range []string{"/status", "/replies/123", "/abis/internal/x", "/abis/123"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", p, nil))
+	}
+
+	entries := parseAccessLog(t, buf)
+	assert.Len(entries, 1)
+	assert.Equal("/abis/123", entries[0]["path"])
+	assert.Equal("plain text", entries[0]["resBody"])
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	assert := assert.New(t)
+
+	half := 0.5
+	al, buf := newTestAccessLogger(t, &AccessLogConf{Enabled: true, SampleRate: &half})
+	handler := al.wrap(echoHandler(200, ""))
+	al.sample = func() float64 { return 0.7 }
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	al.sample = func() float64 { return 0.2 }
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	entries := parseAccessLog(t, buf)
+	assert.Len(entries, 1)
+	assert.Equal(float64(200), entries[0]["status"])
+}
+
+func TestAccessLogTruncatedBody(t *testing.T) {
+	assert := assert.New(t)
+
+	al, buf := newTestAccessLogger(t, &AccessLogConf{Enabled: true, Bodies: true, MaxBodySize: 5})
+	handler := al.wrap(echoHandler(200, `{"password":"secret"}`))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader("0123456789")))
+
+	entries := parseAccessLog(t, buf)
+	assert.Len(entries, 1)
+	assert.Equal("01234...", entries[0]["reqBody"])
+	assert.Equal("{\"pas...", entries[0]["resBody"])
+
+	assert.Equal("***", al.redactBody([]byte("password=secret"), false))
+	assert.Nil(al.redactBody([]byte{}, false))
+}
+
+func TestAccessLogFile(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, _ := ioutil.TempDir("", "fly")
+	defer os.RemoveAll(dir)
+	logFile := path.Join(dir, "access.log")
+	al, err := newAccessLogger(&AccessLogConf{Enabled: true, File: logFile})
+	assert.NoError(err)
+	al.wrap(echoHandler(204, "")).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/abis/1", nil))
+	al.close()
+
+	b, err := ioutil.ReadFile(logFile)
+	assert.NoError(err)
+	assert.Contains(string(b), `"status":204`)
+}
+
+func TestAccessLogFileBadPath(t *testing.T) {
+	assert := assert.New(t)
+	_, err := newAccessLogger(&AccessLogConf{Enabled: true, File: "/non/existent/dir/access.log"})
+	assert.Regexp("FFEC100230", err)
+}
+
+func TestAccessLogHijackUnsupported(t *testing.T) {
+	assert := assert.New(t)
+	w := &accessLogResponseWriter{ResponseWriter: httptest.NewRecorder()}
+	_, _, err := w.Hijack()
+	assert.Regexp("FFEC100231", err)
+	w.Flush()
+}
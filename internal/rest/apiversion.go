@@ -0,0 +1,51 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+)
+
+const (
+	// APIv1Prefix is the route prefix for version 1 of the REST API
+	APIv1Prefix = "/api/v1"
+)
+
+// legacyRoutesEnabled defaults to serving the unversioned routes, for existing clients
+func (g *RESTGateway) legacyRoutesEnabled() bool {
+	return g.conf.HTTP.LegacyRoutes == nil || *g.conf.HTTP.LegacyRoutes
+}
+
+// newAPIVersionHandler serves the routes of the API under the versioned prefix, and
+// optionally on the legacy unversioned paths. When a future version introduces breaking
+// changes, its routes can be registered separately and dispatched here by prefix.
+func (g *RESTGateway) newAPIVersionHandler(v1 http.Handler) http.Handler {
+	stripped := http.StripPrefix(APIv1Prefix, v1)
+	legacy := g.legacyRoutesEnabled()
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == APIv1Prefix || strings.HasPrefix(req.URL.Path, APIv1Prefix+"/") {
+			stripped.ServeHTTP(res, req)
+			return
+		}
+		if !legacy {
+			g.sendError(res, errors.Errorf(errors.RESTGatewayLegacyRoutesDisabled, APIv1Prefix).Error(), 404)
+			return
+		}
+		v1.ServeHTTP(res, req)
+	})
+}
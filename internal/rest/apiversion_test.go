@@ -0,0 +1,69 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pathEchoHandler() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		_, _ = res.Write([]byte(req.URL.Path))
+	})
+}
+
+func TestAPIVersionHandlerPrefixAndLegacy(t *testing.T) {
+	assert := assert.New(t)
+
+	printYAML := false
+	g := NewRESTGateway(&printYAML)
+	handler := g.newAPIVersionHandler(pathEchoHandler())
+
+	for path, expected := range map[string]string{
+		"/api/v1/replies/123": "/replies/123",
+		"/api/v1":             "",
+		"/replies/123":        "/replies/123",
+		"/api/v10/replies":    "/api/v10/replies",
+	} {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest("GET", path, nil))
+		assert.Equal(200, res.Code)
+		assert.Equal(expected, res.Body.String())
+	}
+}
+
+func TestAPIVersionHandlerLegacyDisabled(t *testing.T) {
+	assert := assert.New(t)
+
+	printYAML := false
+	g := NewRESTGateway(&printYAML)
+	legacy := false
+	g.conf.HTTP.LegacyRoutes = &legacy
+	handler := g.newAPIVersionHandler(pathEchoHandler())
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", "/status", nil))
+	assert.Equal(404, res.Code)
+	assert.Regexp("FFEC100235", res.Body.String())
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", "/api/v1/status", nil))
+	assert.Equal(200, res.Code)
+	assert.Equal("/status", res.Body.String())
+}
@@ -0,0 +1,378 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/auth"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/kvstore"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ApprovalStatusPending a submission is parked, waiting for a second principal to approve it
+	ApprovalStatusPending = "pending-approval"
+	// ApprovalStatusApproved a submission was approved, and dispatched
+	ApprovalStatusApproved = "approved"
+	// ApprovalStatusRejected a submission was rejected, and will never be dispatched
+	ApprovalStatusRejected = "rejected"
+	// ApprovalStatusCancelled a submission was withdrawn before a decision, and will never be dispatched
+	ApprovalStatusCancelled = "cancelled"
+)
+
+// ApprovalsConf configures the optional four-eyes approval workflow, where submissions
+// that match the policy are parked until a different principal approves them
+type ApprovalsConf struct {
+	Enabled         bool     `json:"enabled"`
+	Path            string   `json:"path"`
+	ValueThreshold  string   `json:"valueThreshold,omitempty"`
+	Methods         []string `json:"methods,omitempty"`
+	DeployContracts bool     `json:"deployContracts,omitempty"`
+}
+
+// PendingApproval is a submission parked in the approval store
+type PendingApproval struct {
+	ID               string                 `json:"id"`
+	Status           string                 `json:"status"`
+	Submitter        string                 `json:"submitter,omitempty"`
+	Approver         string                 `json:"approver,omitempty"`
+	Reason           string                 `json:"reason,omitempty"`
+	Created          time.Time              `json:"created"`
+	Updated          *time.Time             `json:"updated,omitempty"`
+	Key              string                 `json:"key"`
+	Ack              bool                   `json:"ack"`
+	ImmediateReceipt bool                   `json:"immediateReceipt"`
+	Msg              map[string]interface{} `json:"msg"`
+}
+
+// namespace returns the namespace stamped on the headers of the submission, if any
+func (pa *PendingApproval) namespace() string {
+	headers, _ := pa.Msg["headers"].(map[string]interface{})
+	ns, _ := headers["namespace"].(string)
+	return ns
+}
+
+type approvals struct {
+	conf      *ApprovalsConf
+	kv        kvstore.KVStore
+	webhooks  *webhooks
+	threshold *big.Int
+	methods   map[string]bool
+	mux       sync.Mutex
+}
+
+func newApprovals(conf *ApprovalsConf, kv kvstore.KVStore, w *webhooks) (*approvals, error) {
+	a := &approvals{
+		conf:     conf,
+		kv:       kv,
+		webhooks: w,
+		methods:  make(map[string]bool),
+	}
+	if conf.ValueThreshold != "" {
+		var ok bool
+		if a.threshold, ok = new(big.Int).SetString(conf.ValueThreshold, 0); !ok {
+			return nil, errors.Errorf(errors.ApprovalsBadThreshold, conf.ValueThreshold)
+		}
+	}
+	for _, m := range conf.Methods {
+		a.methods[m] = true
+	}
+	return a, nil
+}
+
+func (a *approvals) close() {
+	a.kv.Close()
+}
+
+func (a *approvals) addRoutes(router *httprouter.Router) {
+	router.GET("/approvals", a.listApprovals)
+	router.GET("/approvals/:id", a.getApproval)
+	router.POST("/approvals/:id/approve", a.approveSubmission)
+	router.POST("/approvals/:id/reject", a.rejectSubmission)
+}
+
+// msgValue parses the value of a submission, whether it arrived as a JSON number, or a decimal/hex string
+func msgValue(v interface{}) *big.Int {
+	switch vt := v.(type) {
+	case string:
+		i, _ := new(big.Int).SetString(vt, 0)
+		return i
+	case float64:
+		i, _ := new(big.Float).SetFloat64(vt).Int(nil)
+		return i
+	case int:
+		return big.NewInt(int64(vt))
+	case json.Number:
+		i, _ := new(big.Int).SetString(vt.String(), 10)
+		return i
+	}
+	return nil
+}
+
+// requiresApproval applies the policy to an incoming transaction or deployment
+func (a *approvals) requiresApproval(msgType string, msg map[string]interface{}) bool {
+	if msgType == messages.MsgTypeDeployContract && a.conf.DeployContracts {
+		return true
+	}
+	if methodName, ok := msg["methodName"].(string); ok && a.methods[methodName] {
+		return true
+	}
+	if a.threshold != nil {
+		if value := msgValue(msg["value"]); value != nil && value.Cmp(a.threshold) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// park stores the submission, without sending it anywhere
+func (a *approvals) park(ctx context.Context, key, msgID string, msg map[string]interface{}, ack, immediateReceipt bool) (messages.WebhookReply, int, error) {
+	pa := &PendingApproval{
+		ID:               msgID,
+		Status:           ApprovalStatusPending,
+		Submitter:        auth.GetPrincipal(ctx),
+		Created:          time.Now().UTC(),
+		Key:              key,
+		Ack:              ack,
+		ImmediateReceipt: immediateReceipt,
+		Msg:              msg,
+	}
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if _, err := a.kv.Get(msgID); err != kvstore.ErrorNotFound {
+		if err == nil {
+			err = errors.Errorf(errors.ReceiptStoreKeyNotUnique)
+		}
+		return nil, 409, err
+	}
+	if err := a.kv.PutJSON(msgID, pa); err != nil {
+		return nil, 500, errors.Errorf(errors.ApprovalsStoreFailed, err)
+	}
+	log.Infof("Submission %s parked pending approval (submitter='%s')", msgID, pa.Submitter)
+	return &messages.AsyncSentMsg{
+		Sent:    false,
+		Request: msgID,
+		Msg:     ApprovalStatusPending,
+	}, 200, nil
+}
+
+func (a *approvals) get(id string) (*PendingApproval, int, error) {
+	var pa PendingApproval
+	if err := a.kv.GetJSON(id, &pa); err != nil {
+		if err == kvstore.ErrorNotFound {
+			return nil, 404, errors.Errorf(errors.ApprovalsNotFound, id)
+		}
+		return nil, 500, errors.Errorf(errors.ApprovalsStoreFailed, err)
+	}
+	return &pa, 200, nil
+}
+
+// decide moves a pending submission to approved or rejected. The approver must be a different
+// principal to the submitter - this can only be enforced when a security module is configured
+func (a *approvals) decide(ctx context.Context, id string, approve bool, reason string) (*PendingApproval, messages.WebhookReply, int, error) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	pa, status, err := a.get(id)
+	if err != nil {
+		return nil, nil, status, err
+	}
+	if pa.Status != ApprovalStatusPending {
+		return nil, nil, 409, errors.Errorf(errors.ApprovalsNotPending, id, pa.Status)
+	}
+	approver := auth.GetPrincipal(ctx)
+	if approve && pa.Submitter != "" && approver == pa.Submitter {
+		return nil, nil, 403, errors.Errorf(errors.ApprovalsSameApprover, id)
+	}
+
+	var reply messages.WebhookReply
+	if approve {
+		if reply, status, err = a.webhooks.dispatchMsg(ctx, pa.Key, pa.ID, pa.Msg, pa.Ack, pa.ImmediateReceipt); err != nil {
+			return nil, nil, status, err
+		}
+		pa.Status = ApprovalStatusApproved
+	} else {
+		pa.Status = ApprovalStatusRejected
+	}
+	now := time.Now().UTC()
+	pa.Approver = approver
+	pa.Reason = reason
+	pa.Updated = &now
+	if err := a.kv.PutJSON(id, pa); err != nil {
+		return nil, nil, 500, errors.Errorf(errors.ApprovalsStoreFailed, err)
+	}
+	if !approve && pa.ImmediateReceipt && a.webhooks.receipts != nil && a.webhooks.receipts.persistence != nil {
+		a.storeErrorReceipt(pa, errors.Errorf(errors.ApprovalsRejected, pa.Approver, pa.Reason))
+	}
+	log.Infof("Submission %s %s by '%s'", id, pa.Status, approver)
+	return pa, reply, 200, nil
+}
+
+// cancel withdraws a pending submission, so it is never dispatched. The cancelled receipt
+// is written whenever a receipt store is configured, as the submitter has a request ID to poll
+func (a *approvals) cancel(ctx context.Context, id string) (*PendingApproval, int, error) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	pa, status, err := a.get(id)
+	if err != nil {
+		return nil, status, err
+	}
+	if pa.Status != ApprovalStatusPending {
+		return nil, 409, errors.Errorf(errors.ApprovalsNotPending, id, pa.Status)
+	}
+	if ns := auth.GetNamespace(ctx); ns != "" && ns != pa.namespace() {
+		return nil, 404, errors.Errorf(errors.ApprovalsNotFound, id)
+	}
+	if err := authorizeCancel(ctx, id, pa.Submitter); err != nil {
+		return nil, 403, err
+	}
+	now := time.Now().UTC()
+	pa.Status = ApprovalStatusCancelled
+	pa.Approver = auth.GetPrincipal(ctx)
+	pa.Updated = &now
+	if err := a.kv.PutJSON(id, pa); err != nil {
+		return nil, 500, errors.Errorf(errors.ApprovalsStoreFailed, err)
+	}
+	if a.webhooks.receipts != nil && a.webhooks.receipts.persistence != nil {
+		a.storeErrorReceipt(pa, errors.Errorf(errors.TransactionSendCancelled))
+	}
+	log.Infof("Submission %s cancelled by '%s'", id, pa.Approver)
+	return pa, 200, nil
+}
+
+// storeErrorReceipt writes an error reply, so anyone polling for the receipt learns the outcome
+func (a *approvals) storeErrorReceipt(pa *PendingApproval, err error) {
+	errReply := messages.NewErrorReply(err, pa.Msg)
+	errReply.Headers.ReqID = pa.ID
+	errReply.Headers.ID = utils.UUIDv4()
+	b, _ := json.Marshal(errReply)
+	var receipt map[string]interface{}
+	_ = json.Unmarshal(b, &receipt)
+	receipt["receivedAt"] = time.Now().UnixNano() / int64(time.Millisecond)
+	receipt["_id"] = pa.ID
+	if err := a.webhooks.receipts.writeReceipt(pa.ID, receipt, false); err != nil {
+		log.Errorf("Failed to store outcome of %s in receipt store: %s", pa.ID, err)
+	}
+}
+
+func (a *approvals) list(status string) ([]*PendingApproval, error) {
+	results := []*PendingApproval{}
+	it := a.kv.NewIterator()
+	defer it.Release()
+	for it.Next() {
+		var pa PendingApproval
+		if err := it.ValueJSON(&pa); err != nil {
+			return nil, errors.Errorf(errors.ApprovalsStoreFailed, err)
+		}
+		if status == "" || pa.Status == status {
+			results = append(results, &pa)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Created.Before(results[j].Created) })
+	return results, nil
+}
+
+func (a *approvals) replyJSON(res http.ResponseWriter, req *http.Request, result interface{}) {
+	resBytes, _ := json.MarshalIndent(result, "", "  ")
+	status := 200
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	_, _ = res.Write(resBytes)
+}
+
+func (a *approvals) listApprovals(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+	if err := auth.AuthApprovals(req.Context()); err != nil {
+		log.Errorf("Error listing approvals: %s", err)
+		sendRESTError(res, req, errors.Errorf(errors.Unauthorized), 401)
+		return
+	}
+	status := ApprovalStatusPending
+	if s, ok := req.URL.Query()["status"]; ok {
+		status = s[0]
+	}
+	results, err := a.list(status)
+	if err != nil {
+		sendRESTError(res, req, err, 500)
+		return
+	}
+	if limitStr := req.URL.Query().Get("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 && limit < len(results) {
+			results = results[:limit]
+		}
+	}
+	a.replyJSON(res, req, results)
+}
+
+func (a *approvals) getApproval(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+	if err := auth.AuthApprovals(req.Context()); err != nil {
+		log.Errorf("Error querying approval: %s", err)
+		sendRESTError(res, req, errors.Errorf(errors.Unauthorized), 401)
+		return
+	}
+	pa, status, err := a.get(params.ByName("id"))
+	if err != nil {
+		sendRESTError(res, req, err, status)
+		return
+	}
+	a.replyJSON(res, req, pa)
+}
+
+func (a *approvals) approveSubmission(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	a.decisionHandler(res, req, params, true)
+}
+
+func (a *approvals) rejectSubmission(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	a.decisionHandler(res, req, params, false)
+}
+
+func (a *approvals) decisionHandler(res http.ResponseWriter, req *http.Request, params httprouter.Params, approve bool) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+	if err := auth.AuthApprovals(req.Context()); err != nil {
+		log.Errorf("Error processing approval: %s", err)
+		sendRESTError(res, req, errors.Errorf(errors.Unauthorized), 401)
+		return
+	}
+	body, err := utils.YAMLorJSONPayload(req)
+	if err != nil {
+		sendRESTError(res, req, err, 400)
+		return
+	}
+	pa, reply, status, err := a.decide(req.Context(), params.ByName("id"), approve, utils.GetMapString(body, "reason"))
+	if err != nil {
+		sendRESTError(res, req, err, status)
+		return
+	}
+	if reply != nil {
+		a.replyJSON(res, req, reply)
+		return
+	}
+	a.replyJSON(res, req, pa)
+}
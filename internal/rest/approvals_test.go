@@ -0,0 +1,398 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/auth"
+	"github.com/hyperledger/firefly-ethconnect/internal/auth/authtest"
+	"github.com/hyperledger/firefly-ethconnect/internal/eth"
+	"github.com/hyperledger/firefly-ethconnect/internal/kvstore"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingHandler struct {
+	mockHandler
+	sent   []string
+	status int
+	err    error
+}
+
+func (h *recordingHandler) sendWebhookMsg(ctx context.Context, key, msgID string, msg map[string]interface{}, ack bool) (string, int, error) {
+	if h.err != nil {
+		return "", h.status, h.err
+	}
+	h.sent = append(h.sent, msgID)
+	return "ack", 200, nil
+}
+
+func newTestApprovals(t *testing.T, conf *ApprovalsConf) (*approvals, *recordingHandler, *httptest.Server, func()) {
+	dir, _ := ioutil.TempDir("", "fly")
+	kv, err := kvstore.NewLDBKeyValueStore(path.Join(dir, "approvals"))
+	assert.NoError(t, err)
+	r, _ := newReceiptsTestStore(nil)
+	h := &recordingHandler{}
+	w := newWebhooks(h, r, nil, nil, eth.EthCommonConf{})
+	a, err := newApprovals(conf, kv, w)
+	assert.NoError(t, err)
+	w.approvals = a
+	router := &httprouter.Router{}
+	w.addRoutes(router)
+	r.addRoutes(router)
+	a.addRoutes(router)
+	ts := httptest.NewServer(router)
+	return a, h, ts, func() {
+		ts.Close()
+		a.close()
+		os.RemoveAll(dir)
+	}
+}
+
+func postApprovalTest(t *testing.T, url, body string) (int, map[string]interface{}) {
+	res, err := http.Post(url, "application/json", strings.NewReader(body))
+	assert.NoError(t, err)
+	var reply map[string]interface{}
+	_ = json.NewDecoder(res.Body).Decode(&reply)
+	return res.StatusCode, reply
+}
+
+func TestApprovalsPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	a, err := newApprovals(&ApprovalsConf{
+		ValueThreshold:  "1000",
+		Methods:         []string{"transferOwnership"},
+		DeployContracts: true,
+	}, nil, nil)
+	assert.NoError(err)
+
+	assert.True(a.requiresApproval(messages.MsgTypeDeployContract, map[string]interface{}{}))
+	assert.True(a.requiresApproval(messages.MsgTypeSendTransaction, map[string]interface{}{"methodName": "transferOwnership"}))
+	assert.True(a.requiresApproval(messages.MsgTypeSendTransaction, map[string]interface{}{"value": float64(1000)}))
+	assert.True(a.requiresApproval(messages.MsgTypeSendTransaction, map[string]interface{}{"value": "0x3e8"}))
+	assert.True(a.requiresApproval(messages.MsgTypeSendTransaction, map[string]interface{}{"value": 2000}))
+	assert.True(a.requiresApproval(messages.MsgTypeSendTransaction, map[string]interface{}{"value": json.Number("1001")}))
+	assert.False(a.requiresApproval(messages.MsgTypeSendTransaction, map[string]interface{}{"value": "999"}))
+	assert.False(a.requiresApproval(messages.MsgTypeSendTransaction, map[string]interface{}{"value": "not a number"}))
+	assert.False(a.requiresApproval(messages.MsgTypeSendTransaction, map[string]interface{}{"value": true}))
+	assert.False(a.requiresApproval(messages.MsgTypeSendTransaction, map[string]interface{}{"methodName": "set"}))
+
+	a, err = newApprovals(&ApprovalsConf{}, nil, nil)
+	assert.NoError(err)
+	assert.False(a.requiresApproval(messages.MsgTypeDeployContract, map[string]interface{}{"value": "1"}))
+
+	_, err = newApprovals(&ApprovalsConf{ValueThreshold: "lots"}, nil, nil)
+	assert.Regexp("FFEC100257", err)
+
+	assert.Equal(big.NewInt(5), msgValue(float64(5)))
+}
+
+func TestApprovalsParkListApprove(t *testing.T) {
+	assert := assert.New(t)
+
+	_, h, ts, done := newTestApprovals(t, &ApprovalsConf{ValueThreshold: "100"})
+	defer done()
+
+	status, reply := postApprovalTest(t, ts.URL+"/hook", `{"headers":{"type":"SendTransaction","id":"tx1"},"from":"0x12345","value":"100","acktype":"receipt"}`)
+	assert.Equal(200, status)
+	assert.Equal(false, reply["sent"])
+	assert.Equal("tx1", reply["id"])
+	assert.Equal(ApprovalStatusPending, reply["msg"])
+	assert.Empty(h.sent)
+
+	status, reply = postApprovalTest(t, ts.URL+"/hook", `{"headers":{"type":"SendTransaction","id":"tx1"},"from":"0x12345","value":"100"}`)
+	assert.Equal(409, status)
+
+	status, _ = postApprovalTest(t, ts.URL+"/hook", `{"headers":{"type":"SendTransaction","id":"tx2"},"from":"0x12345","value":"99"}`)
+	assert.Equal(200, status)
+	assert.Equal([]string{"tx2"}, h.sent)
+
+	res, err := http.Get(ts.URL + "/approvals")
+	assert.NoError(err)
+	var pending []*PendingApproval
+	_ = json.NewDecoder(res.Body).Decode(&pending)
+	assert.Len(pending, 1)
+	assert.Equal("tx1", pending[0].ID)
+	assert.Equal("0x12345", pending[0].Key)
+
+	res, err = http.Get(ts.URL + "/approvals/tx1")
+	assert.NoError(err)
+	assert.Equal(200, res.StatusCode)
+
+	status, reply = postApprovalTest(t, ts.URL+"/approvals/tx1/approve", "")
+	assert.Equal(200, status)
+	assert.Equal(true, reply["sent"])
+	assert.Equal("ack", reply["msg"])
+	assert.Equal([]string{"tx2", "tx1"}, h.sent)
+
+	res, err = http.Get(ts.URL + "/replies/tx1")
+	assert.NoError(err)
+	assert.Equal(200, res.StatusCode)
+
+	status, reply = postApprovalTest(t, ts.URL+"/approvals/tx1/reject", "")
+	assert.Equal(409, status)
+	assert.Regexp("already been approved", reply["error"])
+
+	res, err = http.Get(ts.URL + "/approvals?status=approved&limit=1")
+	assert.NoError(err)
+	_ = json.NewDecoder(res.Body).Decode(&pending)
+	assert.Len(pending, 1)
+	assert.Equal(ApprovalStatusApproved, pending[0].Status)
+	assert.NotNil(pending[0].Updated)
+}
+
+func TestApprovalsReject(t *testing.T) {
+	assert := assert.New(t)
+
+	_, h, ts, done := newTestApprovals(t, &ApprovalsConf{DeployContracts: true})
+	defer done()
+
+	status, _ := postApprovalTest(t, ts.URL+"/hook", `{"headers":{"type":"DeployContract","id":"d1"},"from":"0x12345","acktype":"receipt"}`)
+	assert.Equal(200, status)
+
+	status, reply := postApprovalTest(t, ts.URL+"/approvals/d1/reject", `{"reason":"not today"}`)
+	assert.Equal(200, status)
+	assert.Equal(ApprovalStatusRejected, reply["status"])
+	assert.Equal("not today", reply["reason"])
+	assert.Empty(h.sent)
+
+	res, err := http.Get(ts.URL + "/replies/d1")
+	assert.NoError(err)
+	var receipt map[string]interface{}
+	_ = json.NewDecoder(res.Body).Decode(&receipt)
+	assert.Equal(messages.MsgTypeError, receipt["headers"].(map[string]interface{})["type"])
+	assert.Regexp("not today", receipt["errorMessage"])
+
+	status, _ = postApprovalTest(t, ts.URL+"/approvals/d1/approve", "")
+	assert.Equal(409, status)
+}
+
+func TestApprovalsCancel(t *testing.T) {
+	assert := assert.New(t)
+
+	_, h, ts, done := newTestApprovals(t, &ApprovalsConf{DeployContracts: true})
+	defer done()
+
+	status, _ := postApprovalTest(t, ts.URL+"/fasthook", `{"headers":{"type":"DeployContract","id":"d1"},"from":"0x12345"}`)
+	assert.Equal(200, status)
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/requests/d1", nil)
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(err)
+	assert.Equal(200, res.StatusCode)
+	assert.Empty(h.sent)
+
+	res, err = http.Get(ts.URL + "/approvals/d1")
+	assert.NoError(err)
+	var pa PendingApproval
+	_ = json.NewDecoder(res.Body).Decode(&pa)
+	assert.Equal(ApprovalStatusCancelled, pa.Status)
+
+	res, err = http.Get(ts.URL + "/replies/d1")
+	assert.NoError(err)
+	var receipt map[string]interface{}
+	_ = json.NewDecoder(res.Body).Decode(&receipt)
+	assert.Equal(messages.MsgTypeTransactionCancelled, receipt["headers"].(map[string]interface{})["type"])
+	assert.Equal("FFEC100309", receipt["errorCode"])
+
+	// Cannot cancel twice, or approve once cancelled
+	status, _ = postApprovalTest(t, ts.URL+"/requests/d1/cancel", "")
+	assert.Equal(409, status)
+	status, _ = postApprovalTest(t, ts.URL+"/approvals/d1/approve", "")
+	assert.Equal(409, status)
+
+	// An approved request is dispatched, so can only be cancelled by the handler
+	status, _ = postApprovalTest(t, ts.URL+"/fasthook", `{"headers":{"type":"DeployContract","id":"d2"},"from":"0x12345"}`)
+	assert.Equal(200, status)
+	status, _ = postApprovalTest(t, ts.URL+"/approvals/d2/approve", "")
+	assert.Equal(200, status)
+	status, _ = postApprovalTest(t, ts.URL+"/requests/d2/cancel", "")
+	assert.Equal(405, status)
+
+	// Unknown to the approvals store
+	status, _ = postApprovalTest(t, ts.URL+"/requests/d3/cancel", "")
+	assert.Equal(405, status)
+}
+
+func TestApprovalsCancelAuthorization(t *testing.T) {
+	assert := assert.New(t)
+
+	a, h, _, done := newTestApprovals(t, &ApprovalsConf{ValueThreshold: "1"})
+	defer done()
+
+	auth.RegisterSecurityModule(&authtest.TestSecurityModule{})
+	defer auth.RegisterSecurityModule(nil)
+
+	submitterCtx, _ := auth.WithAuthContext(context.Background(), "testat")
+	msg := map[string]interface{}{
+		"headers": map[string]interface{}{"namespace": "ns-verified"},
+		"value":   "10",
+	}
+	_, _, err := a.park(submitterCtx, "0x12345", "c1", msg, false, false)
+	assert.NoError(err)
+
+	// A different principal, without the approvals permission
+	otherCtx := auth.WithTLSPrincipal(context.Background(), "other")
+	_, status, err := a.cancel(auth.WithNamespace(otherCtx, "ns-verified"), "c1")
+	assert.Equal(403, status)
+	assert.Regexp("FFEC100484", err)
+
+	// A caller restricted to another namespace cannot see the request
+	_, status, err = a.cancel(auth.WithNamespace(otherCtx, "ns-other"), "c1")
+	assert.Equal(404, status)
+	assert.Regexp("FFEC100258", err)
+
+	pa, _, _ := a.get("c1")
+	assert.Equal(ApprovalStatusPending, pa.Status)
+
+	_, status, err = a.cancel(submitterCtx, "c1")
+	assert.NoError(err)
+	assert.Equal(200, status)
+	assert.Empty(h.sent)
+
+	// A principal authorized for approvals can withdraw the submissions of others
+	_, _, err = a.park(submitterCtx, "0x12345", "c2", msg, false, false)
+	assert.NoError(err)
+	_, status, err = a.cancel(auth.NewSystemAuthContext(), "c2")
+	assert.NoError(err)
+	assert.Equal(200, status)
+}
+
+func TestApprovalsErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	a, h, ts, done := newTestApprovals(t, &ApprovalsConf{Methods: []string{"mint"}})
+	defer done()
+
+	status, _ := postApprovalTest(t, ts.URL+"/approvals/unknown/approve", "")
+	assert.Equal(404, status)
+	res, _ := http.Get(ts.URL + "/approvals/unknown")
+	assert.Equal(404, res.StatusCode)
+	status, _ = postApprovalTest(t, ts.URL+"/approvals/unknown/approve", "!!! not json or yaml")
+	assert.Equal(400, status)
+
+	_, _, err := a.park(context.Background(), "0x12345", "m1", map[string]interface{}{"methodName": "mint"}, true, false)
+	assert.NoError(err)
+	h.err = fmt.Errorf("pop")
+	h.status = 503
+	status, reply := postApprovalTest(t, ts.URL+"/approvals/m1/approve", "")
+	assert.Equal(503, status)
+	assert.Regexp("pop", reply["error"])
+	pa, _, _ := a.get("m1")
+	assert.Equal(ApprovalStatusPending, pa.Status)
+
+	a.kv = kvstore.NewMockKV(fmt.Errorf("pop"))
+	_, status, err = a.park(context.Background(), "0x12345", "m2", map[string]interface{}{}, false, false)
+	assert.Equal(409, status)
+	assert.Regexp("pop", err)
+	_, status, err = a.get("m2")
+	assert.Equal(500, status)
+	assert.Regexp("FFEC100261", err)
+
+	mkv := kvstore.NewMockKV(nil)
+	a.kv = mkv
+	_, _, err = a.park(context.Background(), "0x12345", "m3", map[string]interface{}{}, false, false)
+	assert.NoError(err)
+	mkv.StoreErr = fmt.Errorf("pop")
+	_, _, status, err = a.decide(context.Background(), "m3", false, "")
+	assert.Equal(500, status)
+	assert.Regexp("FFEC100261", err)
+	_, status, err = a.park(context.Background(), "0x12345", "m4", map[string]interface{}{}, false, false)
+	assert.Equal(500, status)
+	assert.Regexp("FFEC100261", err)
+}
+
+func TestApprovalsFourEyes(t *testing.T) {
+	assert := assert.New(t)
+
+	a, h, _, done := newTestApprovals(t, &ApprovalsConf{ValueThreshold: "1"})
+	defer done()
+
+	auth.RegisterSecurityModule(&authtest.TestSecurityModule{})
+	defer auth.RegisterSecurityModule(nil)
+
+	ctx, _ := auth.WithAuthContext(context.Background(), "testat")
+	_, _, err := a.park(ctx, "0x12345", "v1", map[string]interface{}{"value": "10"}, false, false)
+	assert.NoError(err)
+
+	_, _, status, err := a.decide(ctx, "v1", true, "")
+	assert.Equal(403, status)
+	assert.Regexp("FFEC100260", err)
+
+	_, _, status, err = a.decide(auth.NewSystemAuthContext(), "v1", true, "")
+	assert.NoError(err)
+	assert.Equal(200, status)
+	assert.Equal([]string{"v1"}, h.sent)
+}
+
+func TestApprovalsUnauthorized(t *testing.T) {
+	assert := assert.New(t)
+
+	_, _, ts, done := newTestApprovals(t, &ApprovalsConf{})
+	defer done()
+
+	auth.RegisterSecurityModule(&authtest.TestSecurityModule{})
+	defer auth.RegisterSecurityModule(nil)
+
+	res, _ := http.Get(ts.URL + "/approvals")
+	assert.Equal(401, res.StatusCode)
+	res, _ = http.Get(ts.URL + "/approvals/a1")
+	assert.Equal(401, res.StatusCode)
+	status, _ := postApprovalTest(t, ts.URL+"/approvals/a1/approve", "")
+	assert.Equal(401, status)
+}
+
+func TestInitApprovals(t *testing.T) {
+	assert := assert.New(t)
+
+	var printYAML = false
+	g := NewRESTGateway(&printYAML)
+	g.webhooks = &webhooks{}
+	router := &httprouter.Router{}
+
+	err := g.initApprovals(router)
+	assert.Regexp("FFEC100256", err)
+
+	dir, _ := ioutil.TempDir("", "fly")
+	defer os.RemoveAll(dir)
+	g.conf.Approvals.Path = path.Join(dir, "approvals")
+	g.conf.Approvals.ValueThreshold = "bad"
+	err = g.initApprovals(router)
+	assert.Regexp("FFEC100257", err)
+
+	g.conf.Approvals.ValueThreshold = "1000000000000000000"
+	err = g.initApprovals(router)
+	assert.NoError(err)
+	assert.NotNil(g.webhooks.approvals)
+	g.webhooks.approvals.close()
+
+	_ = ioutil.WriteFile(path.Join(dir, "file"), []byte{}, 0644)
+	g.conf.Approvals.Path = path.Join(dir, "file")
+	err = g.initApprovals(router)
+	assert.Regexp("FFEC100261", err)
+}
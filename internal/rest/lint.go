@@ -0,0 +1,297 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/contractregistry"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/eth"
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/tx"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	"github.com/julienschmidt/httprouter"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// lintIntrinsicGas is the gas cost of the simplest transaction
+	lintIntrinsicGas = 21000
+	// lintMaxGas is above the block gas limit of most networks
+	lintMaxGas = 30000000
+
+	lintSeverityError   = "error"
+	lintSeverityWarning = "warning"
+)
+
+// LintIssue is a problem found in a request. Requests with errors would be rejected, or
+// fail on chain. Warnings are for requests that are valid, but are likely to be mistakes.
+type LintIssue struct {
+	Field    string `json:"field"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// LintResult is the result of checking a request, which is valid if there are no errors
+type LintResult struct {
+	Valid  bool         `json:"valid"`
+	Issues []*LintIssue `json:"issues"`
+}
+
+// linter statically checks transaction requests in the same format as the webhooks,
+// without submitting them
+type linter struct {
+	resolver contractregistry.ContractResolver
+}
+
+func newLinter(resolver contractregistry.ContractResolver) *linter {
+	return &linter{
+		resolver: resolver,
+	}
+}
+
+func (l *linter) addRoutes(router *httprouter.Router) {
+	router.POST("/lint", l.lintHandler)
+}
+
+func (r *LintResult) add(field, severity string, err error) {
+	if severity == lintSeverityError {
+		r.Valid = false
+	}
+	msg := err.Error()
+	if ee, ok := err.(errors.EthconnectError); ok {
+		msg = ee.ErrorNoCode()
+	}
+	r.Issues = append(r.Issues, &LintIssue{Field: field, Severity: severity, Message: msg})
+}
+
+func (l *linter) lintHandler(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+	msg, err := utils.YAMLorJSONPayload(req)
+	if err != nil {
+		sendRESTError(res, req, err, 400)
+		return
+	}
+	result, err := l.lint(msg)
+	if err != nil {
+		sendRESTError(res, req, err, 400)
+		return
+	}
+	status := 200
+	log.Infof("<-- %s %s [%d] valid=%t issues=%d", req.Method, req.URL, status, result.Valid, len(result.Issues))
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	resBytes, _ := json.MarshalIndent(result, "", "  ")
+	_, _ = res.Write(resBytes)
+}
+
+// lint checks a request, returning an error only if the request cannot be parsed
+func (l *linter) lint(msg map[string]interface{}) (*LintResult, error) {
+	result := &LintResult{Valid: true, Issues: []*LintIssue{}}
+	headers, _ := msg["headers"].(map[string]interface{})
+	msgType := utils.GetMapString(headers, "type")
+	msgBytes, _ := json.Marshal(msg)
+	switch msgType {
+	case messages.MsgTypeSendTransaction:
+		var sendMsg messages.SendTransaction
+		if err := json.Unmarshal(msgBytes, &sendMsg); err != nil {
+			return nil, err
+		}
+		l.lintSendTransaction(result, &sendMsg)
+	case messages.MsgTypeDeployContract:
+		var deployMsg messages.DeployContract
+		if err := json.Unmarshal(msgBytes, &deployMsg); err != nil {
+			return nil, err
+		}
+		l.lintDeployContract(result, &deployMsg)
+	default:
+		result.add("headers.type", lintSeverityError, errors.Errorf(errors.LintUnsupportedType, msgType,
+			strings.Join([]string{messages.MsgTypeSendTransaction, messages.MsgTypeDeployContract}, ",")))
+	}
+	return result, nil
+}
+
+func (l *linter) lintSendTransaction(result *LintResult, msg *messages.SendTransaction) {
+	l.lintFrom(result, msg.From)
+	if _, err := utils.StrToAddress("to", msg.To); err != nil {
+		result.add("to", lintSeverityError, err)
+	}
+	l.lintGas(result, &msg.TransactionCommon)
+
+	method, ok := l.resolveMethod(result, msg)
+	if !ok {
+		return
+	}
+
+	// Build the call data from the parameters alone, so other problems are not reported twice
+	paramsMsg := &messages.SendTransaction{
+		TransactionCommon: messages.TransactionCommon{Parameters: msg.Parameters},
+		Method:            method,
+		MethodName:        msg.MethodName,
+	}
+	if _, err := eth.NewSendTxn(paramsMsg, nil); err != nil {
+		result.add("params", lintSeverityError, err)
+	}
+	if method == nil {
+		return
+	}
+	l.lintValue(result, msg.Value, method)
+	if mutability := method.StateMutability; mutability == "view" || mutability == "pure" || method.Constant {
+		if mutability == "" {
+			mutability = "constant"
+		}
+		result.add("method", lintSeverityWarning, errors.Errorf(errors.LintConstantMethod, method.Name, mutability))
+	}
+}
+
+// resolveMethod returns the ABI of the method from the request, or from the ABI registered
+// for the contract when only the method name is supplied. The method is nil if there is no
+// ABI to check against, and ok is false if the method is invalid.
+func (l *linter) resolveMethod(result *LintResult, msg *messages.SendTransaction) (method *ethbinding.ABIElementMarshaling, ok bool) {
+	if msg.Method != nil && msg.Method.Name != "" {
+		if _, err := ethbind.API.ABIElementMarshalingToABIMethod(msg.Method); err != nil {
+			result.add("method", lintSeverityError, err)
+			return nil, false
+		}
+		return msg.Method, true
+	}
+	if msg.MethodName == "" {
+		result.add("method", lintSeverityError, errors.Errorf(errors.TransactionSendMissingMethod))
+		return nil, false
+	}
+	abi := l.registeredABI(msg.To)
+	if abi == nil {
+		result.add("methodName", lintSeverityWarning, errors.Errorf(errors.LintMethodNotResolved, msg.MethodName))
+		return nil, true
+	}
+	for i, element := range abi {
+		if element.Type == "function" && element.Name == msg.MethodName {
+			// Prefer the overload that takes the number of parameters supplied
+			if method == nil || len(element.Inputs) == len(msg.Parameters) {
+				method = &abi[i]
+			}
+		}
+	}
+	if method == nil {
+		result.add("methodName", lintSeverityError, errors.Errorf(errors.LintMethodNotFound, msg.MethodName, msg.To))
+		return nil, false
+	}
+	return method, true
+}
+
+// registeredABI returns the ABI of a contract instance in the local registry, or nil
+func (l *linter) registeredABI(addr string) ethbinding.ABIMarshaling {
+	if l.resolver == nil || addr == "" {
+		return nil
+	}
+	info, err := l.resolver.GetContractByAddress(addr)
+	if err != nil {
+		return nil
+	}
+	deployMsg, err := l.resolver.GetABI(contractregistry.ABILocation{
+		ABIType: contractregistry.LocalABI,
+		Name:    info.ABI,
+	}, false)
+	if err != nil || deployMsg == nil || deployMsg.Contract == nil {
+		return nil
+	}
+	return deployMsg.Contract.ABI
+}
+
+func (l *linter) lintDeployContract(result *LintResult, msg *messages.DeployContract) {
+	l.lintFrom(result, msg.From)
+	l.lintGas(result, &msg.TransactionCommon)
+
+	switch {
+	case len(msg.Compiled) > 0 && msg.ABI != nil:
+		if _, err := eth.LinkBytecode(msg.Compiled, msg.LinkReferences, msg.Libraries); err != nil {
+			result.add("libraries", lintSeverityError, err)
+		}
+		if _, err := eth.NewContractDeployTxn(&messages.DeployContract{
+			TransactionCommon: messages.TransactionCommon{Parameters: msg.Parameters},
+			Compiled:          msg.Compiled,
+			ABI:               msg.ABI,
+		}, nil); err != nil {
+			result.add("params", lintSeverityError, err)
+		}
+		constructor := &ethbinding.ABIElementMarshaling{Type: "constructor", Name: "constructor"}
+		for i, element := range msg.ABI {
+			if element.Type == "constructor" {
+				constructor = &msg.ABI[i]
+				constructor.Name = "constructor"
+			}
+		}
+		l.lintValue(result, msg.Value, constructor)
+	case msg.Solidity != "":
+		result.add("solidity", lintSeverityWarning, errors.Errorf(errors.LintSolidityNotCompiled))
+	default:
+		result.add("compiled", lintSeverityError, errors.Errorf(errors.DeployTransactionMissingCode))
+	}
+}
+
+func (l *linter) lintFrom(result *LintResult, from string) {
+	if from == "" {
+		result.add("from", lintSeverityError, errors.Errorf(errors.LintMissingField, "from"))
+		return
+	}
+	if tx.IsHDWalletRequest(from) != nil {
+		return
+	}
+	if _, err := utils.StrToAddress("from", from); err != nil {
+		result.add("from", lintSeverityError, err)
+	}
+}
+
+func (l *linter) lintGas(result *LintResult, msg *messages.TransactionCommon) {
+	if msg.Gas != "" {
+		gas, err := msg.Gas.Int64()
+		switch {
+		case err != nil:
+			result.add("gas", lintSeverityError, errors.Errorf(errors.TransactionSendBadGas, err))
+		case gas < lintIntrinsicGas:
+			result.add("gas", lintSeverityWarning, errors.Errorf(errors.LintGasLow, gas, lintIntrinsicGas))
+		case gas > lintMaxGas:
+			result.add("gas", lintSeverityWarning, errors.Errorf(errors.LintGasHigh, gas, lintMaxGas))
+		}
+	}
+	if msg.GasPrice != "" {
+		if _, ok := new(big.Int).SetString(msg.GasPrice.String(), 10); !ok {
+			result.add("gasPrice", lintSeverityError, errors.Errorf(errors.TransactionSendBadGasPrice))
+		}
+	}
+	if msg.Value != "" {
+		if _, ok := new(big.Int).SetString(msg.Value.String(), 10); !ok {
+			result.add("value", lintSeverityError, errors.Errorf(errors.TransactionSendBadValue, msg.Value))
+		}
+	}
+}
+
+// lintValue checks that value is only sent to payable methods, as the transaction would revert
+func (l *linter) lintValue(result *LintResult, value json.Number, method *ethbinding.ABIElementMarshaling) {
+	v, ok := new(big.Int).SetString(value.String(), 10)
+	if !ok || v.Sign() <= 0 {
+		return
+	}
+	if method.Payable || method.StateMutability == "payable" {
+		return
+	}
+	result.add("value", lintSeverityError, errors.Errorf(errors.LintValueNotPayable, value, method.Name))
+}
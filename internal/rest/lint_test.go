@@ -0,0 +1,304 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/contractregistry"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/mocks/contractregistrymocks"
+	"github.com/julienschmidt/httprouter"
+	ethbinding "github.com/kaleido-io/ethbinding/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const lintTestAddr = "0x0123456789abcdef0123456789abcdef01234567"
+
+var lintTestABI = ethbinding.ABIMarshaling{
+	{
+		Type: "function", Name: "set", StateMutability: "nonpayable",
+		Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "uint256"}},
+	},
+	{
+		Type: "function", Name: "set", StateMutability: "nonpayable",
+		Inputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "uint256"}, {Name: "y", Type: "string"}},
+	},
+	{
+		Type: "function", Name: "deposit", StateMutability: "payable",
+	},
+	{
+		Type: "function", Name: "get", StateMutability: "view",
+		Outputs: []ethbinding.ABIArgumentMarshaling{{Name: "x", Type: "uint256"}},
+	},
+}
+
+func newTestLinter(resolver contractregistry.ContractResolver) (*httptest.Server, func()) {
+	router := &httprouter.Router{}
+	newLinter(resolver).addRoutes(router)
+	ts := httptest.NewServer(router)
+	return ts, ts.Close
+}
+
+func lintTest(t *testing.T, url, body string) (int, *LintResult) {
+	res, err := http.Post(url+"/lint", "application/json", strings.NewReader(body))
+	assert.NoError(t, err)
+	var result LintResult
+	json.NewDecoder(res.Body).Decode(&result)
+	return res.StatusCode, &result
+}
+
+func lintIssueFields(result *LintResult) []string {
+	fields := []string{}
+	for _, issue := range result.Issues {
+		fields = append(fields, fmt.Sprintf("%s:%s", issue.Field, issue.Severity))
+	}
+	return fields
+}
+
+func newTestLintResolver() *contractregistrymocks.ContractStore {
+	cs := &contractregistrymocks.ContractStore{}
+	cs.On("GetContractByAddress", lintTestAddr).Return(&contractregistry.ContractInfo{
+		Address: lintTestAddr,
+		ABI:     "abi1",
+	}, nil)
+	cs.On("GetContractByAddress", mock.Anything).Return(nil, fmt.Errorf("pop"))
+	cs.On("GetABI", contractregistry.ABILocation{ABIType: contractregistry.LocalABI, Name: "abi1"}, false).Return(&contractregistry.DeployContractWithAddress{
+		Contract: &messages.DeployContract{ABI: lintTestABI},
+	}, nil)
+	return cs
+}
+
+func TestLintSendTransactionValid(t *testing.T) {
+	assert := assert.New(t)
+	ts, done := newTestLinter(newTestLintResolver())
+	defer done()
+
+	status, result := lintTest(t, ts.URL, `{
+		"headers": {"type": "SendTransaction"},
+		"from": "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		"to": "`+lintTestAddr+`",
+		"methodName": "set",
+		"params": [12345, "abc"],
+		"gas": 100000
+	}`)
+	assert.Equal(200, status)
+	assert.True(result.Valid)
+	assert.Empty(result.Issues)
+}
+
+func TestLintSendTransactionErrors(t *testing.T) {
+	assert := assert.New(t)
+	ts, done := newTestLinter(newTestLintResolver())
+	defer done()
+
+	status, result := lintTest(t, ts.URL, `{
+		"headers": {"type": "SendTransaction"},
+		"from": "bad address",
+		"to": "`+lintTestAddr+`",
+		"methodName": "set",
+		"params": ["not a number"],
+		"gas": "1.5",
+		"gasPrice": 2.5,
+		"value": 10
+	}`)
+	assert.Equal(200, status)
+	assert.False(result.Valid)
+	assert.Equal([]string{
+		"from:error",
+		"gas:error",
+		"gasPrice:error",
+		"params:error",
+		"value:error",
+	}, lintIssueFields(result))
+	assert.Regexp("'set', which is not payable", result.Issues[4].Message)
+}
+
+func TestLintSendTransactionWarnings(t *testing.T) {
+	assert := assert.New(t)
+	ts, done := newTestLinter(newTestLintResolver())
+	defer done()
+
+	status, result := lintTest(t, ts.URL, `{
+		"headers": {"type": "SendTransaction"},
+		"from": "hd-testinst-testwallet-1234",
+		"to": "`+lintTestAddr+`",
+		"methodName": "get",
+		"gas": 1000
+	}`)
+	assert.Equal(200, status)
+	assert.True(result.Valid)
+	assert.Equal([]string{"gas:warning", "method:warning"}, lintIssueFields(result))
+	assert.Regexp("below the intrinsic cost", result.Issues[0].Message)
+	assert.Regexp("'get' is view", result.Issues[1].Message)
+
+	_, result = lintTest(t, ts.URL, `{
+		"headers": {"type": "SendTransaction"},
+		"from": "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		"to": "`+lintTestAddr+`",
+		"methodName": "deposit",
+		"value": "1000000000000000000",
+		"gas": 50000000
+	}`)
+	assert.True(result.Valid)
+	assert.Equal([]string{"gas:warning"}, lintIssueFields(result))
+	assert.Regexp("above the block gas limit", result.Issues[0].Message)
+}
+
+func TestLintSendTransactionUnknownMethod(t *testing.T) {
+	assert := assert.New(t)
+	ts, done := newTestLinter(newTestLintResolver())
+	defer done()
+
+	_, result := lintTest(t, ts.URL, `{
+		"headers": {"type": "SendTransaction"},
+		"from": "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		"to": "`+lintTestAddr+`",
+		"methodName": "missing"
+	}`)
+	assert.False(result.Valid)
+	assert.Equal([]string{"methodName:error"}, lintIssueFields(result))
+	assert.Regexp("'missing' is not declared", result.Issues[0].Message)
+}
+
+func TestLintSendTransactionUnregisteredContract(t *testing.T) {
+	assert := assert.New(t)
+	ts, done := newTestLinter(newTestLintResolver())
+	defer done()
+
+	_, result := lintTest(t, ts.URL, `{
+		"headers": {"type": "SendTransaction"},
+		"from": "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		"to": "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+		"methodName": "set",
+		"params": [{"type":"uint256","value":"1"}]
+	}`)
+	assert.True(result.Valid)
+	assert.Equal([]string{"methodName:warning"}, lintIssueFields(result))
+}
+
+func TestLintSendTransactionInlineMethod(t *testing.T) {
+	assert := assert.New(t)
+	ts, done := newTestLinter(nil)
+	defer done()
+
+	_, result := lintTest(t, ts.URL, `{
+		"headers": {"type": "SendTransaction"},
+		"from": "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		"to": "`+lintTestAddr+`",
+		"method": {"name": "set", "inputs": [{"name": "x", "type": "uint256"}], "outputs": []},
+		"params": [1]
+	}`)
+	assert.True(result.Valid)
+	assert.Empty(result.Issues)
+
+	_, result = lintTest(t, ts.URL, `{
+		"headers": {"type": "SendTransaction"},
+		"from": "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		"method": {"name": "set", "inputs": [{"name": "x", "type": "badness"}], "outputs": []},
+		"params": [1]
+	}`)
+	assert.False(result.Valid)
+	assert.Equal([]string{"to:error", "method:error"}, lintIssueFields(result))
+
+	_, result = lintTest(t, ts.URL, `{
+		"headers": {"type": "SendTransaction"},
+		"to": "`+lintTestAddr+`"
+	}`)
+	assert.False(result.Valid)
+	assert.Equal([]string{"from:error", "method:error"}, lintIssueFields(result))
+}
+
+func TestLintDeployContract(t *testing.T) {
+	assert := assert.New(t)
+	ts, done := newTestLinter(nil)
+	defer done()
+
+	_, result := lintTest(t, ts.URL, `{
+		"headers": {"type": "DeployContract"},
+		"from": "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		"compiled": "YWJjZA==",
+		"abi": [{"type": "constructor", "inputs": [{"name": "x", "type": "uint256"}]}],
+		"params": [1]
+	}`)
+	assert.True(result.Valid)
+	assert.Empty(result.Issues)
+
+	_, result = lintTest(t, ts.URL, `{
+		"headers": {"type": "DeployContract"},
+		"from": "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		"compiled": "YWJjZA==",
+		"abi": [{"type": "constructor", "inputs": [{"name": "x", "type": "uint256"}]}],
+		"params": [],
+		"value": 1
+	}`)
+	assert.False(result.Valid)
+	assert.Equal([]string{"params:error", "value:error"}, lintIssueFields(result))
+
+	_, result = lintTest(t, ts.URL, `{
+		"headers": {"type": "DeployContract"},
+		"from": "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		"compiled": "YWJjZA==",
+		"abi": [],
+		"linkReferences": [{"library": "lib.sol:Lib", "offsets": [0]}]
+	}`)
+	assert.False(result.Valid)
+	assert.Equal([]string{"libraries:error"}, lintIssueFields(result))
+	assert.Regexp("library .lib.sol:Lib.", result.Issues[0].Message)
+
+	_, result = lintTest(t, ts.URL, `{
+		"headers": {"type": "DeployContract"},
+		"from": "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		"solidity": "pragma solidity ^0.8.0; contract A {}"
+	}`)
+	assert.True(result.Valid)
+	assert.Equal([]string{"solidity:warning"}, lintIssueFields(result))
+
+	_, result = lintTest(t, ts.URL, `{
+		"headers": {"type": "DeployContract"},
+		"from": "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	}`)
+	assert.False(result.Valid)
+	assert.Equal([]string{"compiled:error"}, lintIssueFields(result))
+}
+
+func TestLintUnsupportedType(t *testing.T) {
+	assert := assert.New(t)
+	ts, done := newTestLinter(nil)
+	defer done()
+
+	_, result := lintTest(t, ts.URL, `{"headers": {"type": "Query"}}`)
+	assert.False(result.Valid)
+	assert.Equal([]string{"headers.type:error"}, lintIssueFields(result))
+	assert.Regexp("'Query' cannot be linted", result.Issues[0].Message)
+}
+
+func TestLintBadBody(t *testing.T) {
+	assert := assert.New(t)
+	ts, done := newTestLinter(nil)
+	defer done()
+
+	status, _ := lintTest(t, ts.URL, `!bad json`)
+	assert.Equal(400, status)
+
+	status, _ = lintTest(t, ts.URL, `{"headers": {"type": "SendTransaction"}, "params": {"not": "an array"}}`)
+	assert.Equal(400, status)
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/auth"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// MTLSConf configures TLS on the HTTP/WebSocket listener, requiring every caller to present a
+// client certificate issued by one of the trusted CAs.
+// AllowedSubjects optionally restricts the callers to a set of certificate subjects, each mapped
+// to the principal name used for the caller (an empty principal means the subject common name).
+// Keys can be the common name, or the full distinguished name (such as "CN=client1,O=Example")
+type MTLSConf struct {
+	Enabled         bool              `json:"enabled"`
+	CertFile        string            `json:"certFile"`
+	KeyFile         string            `json:"keyFile"`
+	ClientCAsFile   string            `json:"clientCAsFile"`
+	AllowedSubjects map[string]string `json:"allowedSubjects,omitempty"`
+}
+
+func newMTLSConfig(conf *MTLSConf) (*tls.Config, error) {
+	if conf.CertFile == "" || conf.KeyFile == "" {
+		return nil, errors.Errorf(errors.ConfigMTLSCertKey)
+	}
+	if conf.ClientCAsFile == "" {
+		return nil, errors.Errorf(errors.ConfigMTLSClientCAs)
+	}
+	cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+	if err != nil {
+		return nil, errors.Errorf(errors.ConfigMTLSLoadFailed, err)
+	}
+	caPEM, err := ioutil.ReadFile(conf.ClientCAsFile)
+	if err != nil {
+		return nil, errors.Errorf(errors.ConfigMTLSLoadFailed, err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, errors.Errorf(errors.ConfigMTLSLoadFailed, conf.ClientCAsFile)
+	}
+	log.Infof("Mutual TLS enabled with %d allowed client subjects", len(conf.AllowedSubjects))
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// clientCertPrincipal checks the verified client certificate against the allow-list,
+// and returns the principal it maps to
+func clientCertPrincipal(conf *MTLSConf, cert *x509.Certificate) (string, error) {
+	cn := cert.Subject.CommonName
+	if len(conf.AllowedSubjects) == 0 {
+		return cn, nil
+	}
+	principal, ok := conf.AllowedSubjects[cert.Subject.String()]
+	if !ok {
+		principal, ok = conf.AllowedSubjects[cn]
+	}
+	if !ok {
+		return "", errors.Errorf(errors.RESTGatewayClientCertNotAllowed, cert.Subject.String())
+	}
+	if principal == "" {
+		principal = cn
+	}
+	return principal, nil
+}
+
+func newMTLSHandler(conf *MTLSConf, parent http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		// The TLS handshake will already have rejected connections without a verified certificate
+		if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+			sendRESTError(res, req, errors.Errorf(errors.Unauthorized), 401)
+			return
+		}
+		principal, err := clientCertPrincipal(conf, req.TLS.PeerCertificates[0])
+		if err != nil {
+			sendRESTError(res, req, err, 403)
+			return
+		}
+		parent.ServeHTTP(res, req.WithContext(auth.WithTLSPrincipal(req.Context(), principal)))
+	})
+}
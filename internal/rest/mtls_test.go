@@ -0,0 +1,199 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCert(t *testing.T, cn string, issuer *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn, Organization: []string{"Example"}},
+		NotBefore:    time.Now().Add(-1 * time.Hour),
+		NotAfter:     time.Now().Add(1 * time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	parent, parentKey := template, key
+	if issuer == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		parent, parentKey = issuer.cert, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	assert.NoError(t, err)
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func newTestMTLSServer(t *testing.T, dir string, conf *MTLSConf) (*httptest.Server, *testCert, chan string) {
+	ca := newTestCert(t, "ca", nil)
+	server := newTestCert(t, "server", ca)
+	conf.Enabled = true
+	conf.CertFile = path.Join(dir, "server.crt")
+	conf.KeyFile = path.Join(dir, "server.key")
+	conf.ClientCAsFile = path.Join(dir, "ca.crt")
+	ioutil.WriteFile(conf.CertFile, server.certPEM, 0600)
+	ioutil.WriteFile(conf.KeyFile, server.keyPEM, 0600)
+	ioutil.WriteFile(conf.ClientCAsFile, ca.certPEM, 0600)
+
+	tlsConfig, err := newMTLSConfig(conf)
+	assert.NoError(t, err)
+	principals := make(chan string, 1)
+	ts := httptest.NewUnstartedServer(newMTLSHandler(conf, http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		principals <- auth.GetPrincipal(req.Context())
+		res.WriteHeader(204)
+	})))
+	ts.TLS = tlsConfig
+	ts.StartTLS()
+	return ts, ca, principals
+}
+
+func mtlsClient(ca *testCert, client *testCert) *http.Client {
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(ca.cert)
+	tlsConfig := &tls.Config{RootCAs: rootCAs}
+	if client != nil {
+		clientCert, _ := tls.X509KeyPair(client.certPEM, client.keyPEM)
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+}
+
+func TestMTLSVerifiesClientAndSetsPrincipal(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "fly")
+	defer os.RemoveAll(dir)
+
+	ts, ca, principals := newTestMTLSServer(t, dir, &MTLSConf{})
+	defer ts.Close()
+
+	res, err := mtlsClient(ca, newTestCert(t, "client1", ca)).Get(ts.URL)
+	assert.NoError(err)
+	assert.Equal(204, res.StatusCode)
+	assert.Equal("client1", <-principals)
+
+	// No client certificate, or one from an untrusted CA, fails the handshake
+	_, err = mtlsClient(ca, nil).Get(ts.URL)
+	assert.Error(err)
+	otherCA := newTestCert(t, "other", nil)
+	_, err = mtlsClient(ca, newTestCert(t, "client1", otherCA)).Get(ts.URL)
+	assert.Error(err)
+}
+
+func TestMTLSAllowedSubjects(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "fly")
+	defer os.RemoveAll(dir)
+
+	ts, ca, principals := newTestMTLSServer(t, dir, &MTLSConf{
+		AllowedSubjects: map[string]string{
+			"CN=client1,O=Example": "app1",
+			"client2":              "",
+		},
+	})
+	defer ts.Close()
+
+	res, err := mtlsClient(ca, newTestCert(t, "client1", ca)).Get(ts.URL)
+	assert.NoError(err)
+	assert.Equal(204, res.StatusCode)
+	assert.Equal("app1", <-principals)
+
+	res, err = mtlsClient(ca, newTestCert(t, "client2", ca)).Get(ts.URL)
+	assert.NoError(err)
+	assert.Equal(204, res.StatusCode)
+	assert.Equal("client2", <-principals)
+
+	res, err = mtlsClient(ca, newTestCert(t, "client3", ca)).Get(ts.URL)
+	assert.NoError(err)
+	assert.Equal(403, res.StatusCode)
+}
+
+func TestMTLSHandlerNoTLS(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := httptest.NewServer(newMTLSHandler(&MTLSConf{}, http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(204)
+	})))
+	defer ts.Close()
+	res, err := http.Get(ts.URL)
+	assert.NoError(err)
+	assert.Equal(401, res.StatusCode)
+}
+
+func TestMTLSConfigErrors(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "fly")
+	defer os.RemoveAll(dir)
+
+	_, err := newMTLSConfig(&MTLSConf{})
+	assert.Regexp("FFEC100274", err)
+	_, err = newMTLSConfig(&MTLSConf{CertFile: "a", KeyFile: "b"})
+	assert.Regexp("FFEC100276", err)
+	_, err = newMTLSConfig(&MTLSConf{CertFile: "a", KeyFile: "b", ClientCAsFile: "c"})
+	assert.Regexp("FFEC100275", err)
+
+	ca := newTestCert(t, "ca", nil)
+	conf := &MTLSConf{
+		CertFile:      path.Join(dir, "ca.crt"),
+		KeyFile:       path.Join(dir, "ca.key"),
+		ClientCAsFile: path.Join(dir, "missing.crt"),
+	}
+	ioutil.WriteFile(conf.CertFile, ca.certPEM, 0600)
+	ioutil.WriteFile(conf.KeyFile, ca.keyPEM, 0600)
+	_, err = newMTLSConfig(conf)
+	assert.Regexp("FFEC100275", err)
+
+	conf.ClientCAsFile = path.Join(dir, "bad.crt")
+	ioutil.WriteFile(conf.ClientCAsFile, []byte("not a cert"), 0600)
+	_, err = newMTLSConfig(conf)
+	assert.Regexp("FFEC100275", err)
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/auth"
+	"github.com/hyperledger/firefly-ethconnect/internal/contractgateway"
+	log "github.com/sirupsen/logrus"
+)
+
+// Names of the security module checks applied to a route, in the reply to OPTIONS
+const (
+	permissionEventStreams       = "eventStreams"
+	permissionListAsyncReplies   = "listAsyncReplies"
+	permissionReadAsyncReply     = "readAsyncReplyByUUID"
+	permissionDeleteAsyncReplies = "deleteAsyncReplies"
+	permissionApprovals          = "approvals"
+)
+
+// RouteCapabilities is the reply to an OPTIONS request, describing what the route accepts
+type RouteCapabilities struct {
+	Methods   []string   `json:"methods"`
+	FlyParams []string   `json:"flyParams,omitempty"`
+	SyncModes []string   `json:"syncModes,omitempty"`
+	Auth      *RouteAuth `json:"auth"`
+}
+
+// RouteAuth describes the authentication required on a route, and the security module
+// check applied to each method
+type RouteAuth struct {
+	Required    bool              `json:"required"`
+	Scheme      string            `json:"scheme,omitempty"`
+	Permissions map[string]string `json:"permissions,omitempty"`
+}
+
+// routeCapabilities works out the capabilities of a path from its segments, as the router
+// does not tell the OPTIONS handler which route matched
+func routeCapabilities(path string) *RouteCapabilities {
+	caps := &RouteCapabilities{}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	permissions := map[string]string{}
+	switch segments[0] {
+	case "contracts", "instances", "i":
+		if len(segments) >= 3 {
+			caps.FlyParams = contractgateway.FlyParamNames()
+			caps.SyncModes = []string{"sync", "ack", "noack", "receipt"}
+		}
+	case "abis", "gateways", "g":
+		if len(segments) == 2 || len(segments) >= 4 {
+			caps.FlyParams = contractgateway.FlyParamNames()
+			caps.SyncModes = []string{"sync", "ack", "noack", "receipt"}
+		}
+	case "hook":
+		caps.SyncModes = []string{"ack", "receipt"}
+	case "", "fasthook":
+		caps.SyncModes = []string{"noack", "receipt"}
+	case "eventstreams", "subscriptions", "scheduledqueries":
+		permissions["*"] = permissionEventStreams
+	case "replies", "reply":
+		switch {
+		case segments[0] == "replies" && len(segments) == 1:
+			permissions[http.MethodGet] = permissionListAsyncReplies
+		case len(segments) == 2 && segments[1] == "purge":
+			permissions[http.MethodPost] = permissionDeleteAsyncReplies
+		default:
+			permissions[http.MethodGet] = permissionReadAsyncReply
+			permissions[http.MethodHead] = permissionReadAsyncReply
+			permissions[http.MethodDelete] = permissionDeleteAsyncReplies
+		}
+	case "approvals":
+		permissions["*"] = permissionApprovals
+	}
+	caps.Auth = &RouteAuth{Required: auth.SecurityModuleEnabled()}
+	if caps.Auth.Required {
+		caps.Auth.Scheme = "Bearer"
+		if len(permissions) > 0 {
+			caps.Auth.Permissions = permissions
+		}
+	}
+	return caps
+}
+
+// optionsHandler is called by the router for OPTIONS requests on any route, after it has set the Allow header
+func (g *RESTGateway) optionsHandler(res http.ResponseWriter, req *http.Request) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+	caps := routeCapabilities(req.URL.Path)
+	for _, method := range strings.Split(res.Header().Get("Allow"), ",") {
+		if method = strings.TrimSpace(method); method != "" {
+			caps.Methods = append(caps.Methods, method)
+		}
+	}
+	status := 200
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	_ = json.NewEncoder(res).Encode(caps)
+}
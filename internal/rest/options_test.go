@@ -0,0 +1,95 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/auth"
+	"github.com/hyperledger/firefly-ethconnect/internal/auth/authtest"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteCapabilities(t *testing.T) {
+	assert := assert.New(t)
+
+	caps := routeCapabilities("/contracts/0x12345/set")
+	assert.Contains(caps.FlyParams, "fly-sync")
+	assert.Contains(caps.FlyParams, "fly-privatefor")
+	assert.Equal([]string{"sync", "ack", "noack", "receipt"}, caps.SyncModes)
+	assert.False(caps.Auth.Required)
+
+	assert.Empty(routeCapabilities("/contracts/0x12345").FlyParams)
+	assert.NotEmpty(routeCapabilities("/abis/abi1").FlyParams)
+	assert.Empty(routeCapabilities("/abis/abi1/0x12345").FlyParams)
+	assert.NotEmpty(routeCapabilities("/g/gw1/0x12345/set").FlyParams)
+	assert.NotEmpty(routeCapabilities("/i/inst1/set").FlyParams)
+	assert.Equal([]string{"ack", "receipt"}, routeCapabilities("/hook").SyncModes)
+	assert.Equal([]string{"noack", "receipt"}, routeCapabilities("/fasthook").SyncModes)
+	assert.Equal([]string{"noack", "receipt"}, routeCapabilities("/").SyncModes)
+	assert.Empty(routeCapabilities("/status").SyncModes)
+}
+
+func TestRouteCapabilitiesWithSecurityModule(t *testing.T) {
+	assert := assert.New(t)
+	auth.RegisterSecurityModule(&authtest.TestSecurityModule{})
+	defer auth.RegisterSecurityModule(nil)
+
+	caps := routeCapabilities("/eventstreams/es1")
+	assert.True(caps.Auth.Required)
+	assert.Equal("Bearer", caps.Auth.Scheme)
+	assert.Equal(map[string]string{"*": "eventStreams"}, caps.Auth.Permissions)
+
+	assert.Equal(map[string]string{"GET": "listAsyncReplies"}, routeCapabilities("/replies").Auth.Permissions)
+	assert.Equal(map[string]string{"POST": "deleteAsyncReplies"}, routeCapabilities("/replies/purge").Auth.Permissions)
+	assert.Equal("readAsyncReplyByUUID", routeCapabilities("/reply/abc").Auth.Permissions["GET"])
+	assert.Equal("deleteAsyncReplies", routeCapabilities("/replies/abc").Auth.Permissions["DELETE"])
+	assert.Equal(map[string]string{"*": "approvals"}, routeCapabilities("/approvals/abc").Auth.Permissions)
+	assert.Nil(routeCapabilities("/status").Auth.Permissions)
+}
+
+func TestOptionsWithoutToken(t *testing.T) {
+	assert := assert.New(t)
+	auth.RegisterSecurityModule(&authtest.TestSecurityModule{})
+	defer auth.RegisterSecurityModule(nil)
+
+	g := &RESTGateway{}
+	r, _ := newReceiptsTestStore(nil)
+	router := httprouter.New()
+	router.GlobalOPTIONS = http.HandlerFunc(g.optionsHandler)
+	r.addRoutes(router)
+	ts := httptest.NewServer(g.newAccessTokenContextHandler(router))
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodOptions, ts.URL+"/replies/abc", nil)
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(err)
+	assert.Equal(200, res.StatusCode)
+	assert.Equal("DELETE, GET, HEAD, OPTIONS", res.Header.Get("Allow"))
+	var caps RouteCapabilities
+	assert.NoError(json.NewDecoder(res.Body).Decode(&caps))
+	assert.Equal([]string{"DELETE", "GET", "HEAD", "OPTIONS"}, caps.Methods)
+	assert.True(caps.Auth.Required)
+
+	// Other methods still require a token
+	req, _ = http.NewRequest(http.MethodHead, ts.URL+"/replies/abc", nil)
+	res, err = http.DefaultClient.Do(req)
+	assert.NoError(err)
+	assert.Equal(401, res.StatusCode)
+}
@@ -0,0 +1,255 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/receipts"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultArchivePrefix       = "receipts/"
+	defaultArchiveOlderThanSec = 24 * 60 * 60
+	defaultArchiveIntervalSec  = 5 * 60
+	defaultArchiveBatchSize    = 1000
+	archiveCheckpointName      = "checkpoint.json"
+	archiveBatchSuffix         = ".json.gz"
+)
+
+var archiveBatchVerifier = regexp.MustCompile("^[0-9]{13}-[0-9]{13}$")
+
+// ReceiptArchiveConf configures periodic export of older receipts, as gzipped JSON batches,
+// to S3 compatible object storage
+type ReceiptArchiveConf struct {
+	Enabled        bool         `json:"enabled"`
+	S3             utils.S3Conf `json:"s3"`
+	Prefix         string       `json:"prefix,omitempty"`
+	OlderThanSec   int64        `json:"olderThanSec,omitempty"`
+	IntervalSec    int          `json:"intervalSec,omitempty"`
+	BatchSize      int          `json:"batchSize,omitempty"`
+	DeleteArchived bool         `json:"deleteArchived,omitempty"`
+}
+
+// receiptArchiveCheckpoint records the receivedAt time up to which receipts have been archived,
+// and is stored alongside the batches so archiving resumes where it left off after a restart
+type receiptArchiveCheckpoint struct {
+	ArchivedTo int64  `json:"archivedTo"`
+	LastBatch  string `json:"lastBatch,omitempty"`
+}
+
+type receiptArchive struct {
+	conf        *ReceiptArchiveConf
+	persistence receipts.ReceiptStorePersistence
+	reader      receipts.ReceiptStoreArchiveReader
+	s3          *utils.S3Client
+	checkpoint  *receiptArchiveCheckpoint
+	metrics     *utils.StoreMetrics
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+func newReceiptArchive(conf *ReceiptArchiveConf, persistence receipts.ReceiptStorePersistence) (*receiptArchive, error) {
+	reader, ok := receipts.AsArchiveReader(persistence)
+	if !ok {
+		return nil, errors.Errorf(errors.ReceiptArchiveNotSupported)
+	}
+	if conf.Prefix == "" {
+		conf.Prefix = defaultArchivePrefix
+	}
+	if conf.OlderThanSec <= 0 {
+		conf.OlderThanSec = defaultArchiveOlderThanSec
+	}
+	if conf.IntervalSec <= 0 {
+		conf.IntervalSec = defaultArchiveIntervalSec
+	}
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = defaultArchiveBatchSize
+	}
+	s3, err := utils.NewS3Client(&conf.S3)
+	if err != nil {
+		return nil, err
+	}
+	return &receiptArchive{
+		conf:        conf,
+		persistence: persistence,
+		reader:      reader,
+		s3:          s3,
+	}, nil
+}
+
+func (a *receiptArchive) start() {
+	a.stop = make(chan struct{})
+	a.done = make(chan struct{})
+	go a.archiveLoop()
+}
+
+// close stops the archive loop, waiting for any batch in flight to complete
+func (a *receiptArchive) close() {
+	if a == nil || a.stop == nil {
+		return
+	}
+	close(a.stop)
+	<-a.done
+	a.stop = nil
+}
+
+func (a *receiptArchive) archiveLoop() {
+	defer close(a.done)
+	interval := time.Duration(a.conf.IntervalSec) * time.Second
+	log.Infof("Receipt archive enabled bucket=%s prefix=%s olderThanSec=%d interval=%s", a.conf.S3.Bucket, a.conf.Prefix, a.conf.OlderThanSec, interval)
+	for {
+		a.archiveReceipts(time.Now())
+		select {
+		case <-time.After(interval):
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+func (a *receiptArchive) loadCheckpoint() error {
+	b, err := a.s3.GetObject(a.conf.Prefix + archiveCheckpointName)
+	if err != nil {
+		return err
+	}
+	checkpoint := &receiptArchiveCheckpoint{}
+	if b != nil {
+		if err = json.Unmarshal(b, checkpoint); err != nil {
+			return err
+		}
+	}
+	log.Infof("Receipt archive checkpoint archivedTo=%d lastBatch=%s", checkpoint.ArchivedTo, checkpoint.LastBatch)
+	a.checkpoint = checkpoint
+	return nil
+}
+
+// archiveReceipts writes batches until every receipt older than the threshold is archived.
+// We never move the checkpoint until a batch is safely written, so a failure is retried
+// from the same point on the next interval.
+func (a *receiptArchive) archiveReceipts(now time.Time) {
+	if a.checkpoint == nil {
+		if err := a.loadCheckpoint(); err != nil {
+			log.Errorf("Failed to load receipt archive checkpoint: %s", err)
+			return
+		}
+	}
+	beforeEpochMS := now.Add(-time.Duration(a.conf.OlderThanSec)*time.Second).UnixNano() / int64(time.Millisecond)
+	for {
+		more, err := a.archiveBatch(beforeEpochMS)
+		if err != nil {
+			log.Errorf("Failed to archive receipts: %s", err)
+			return
+		}
+		if !more {
+			return
+		}
+		select {
+		case <-a.stop:
+			return
+		default:
+		}
+	}
+}
+
+func (a *receiptArchive) archiveBatch(beforeEpochMS int64) (more bool, err error) {
+	done := a.metrics.Start("GetOldestReceipts")
+	results, err := a.reader.GetOldestReceipts(a.checkpoint.ArchivedTo, beforeEpochMS, a.conf.BatchSize)
+	done(err)
+	if err != nil {
+		return false, err
+	}
+	batch := *results
+	if len(batch) == 0 {
+		return false, nil
+	}
+	more = len(batch) == a.conf.BatchSize
+	first := receipts.ReceiptReceivedAt(batch[0])
+	last := receipts.ReceiptReceivedAt(batch[len(batch)-1])
+	if more && first != last {
+		// Receipts from the same millisecond as the last one might continue into the next
+		// page, so we leave them all for the next batch
+		for receipts.ReceiptReceivedAt(batch[len(batch)-1]) == last {
+			batch = batch[:len(batch)-1]
+		}
+		last = receipts.ReceiptReceivedAt(batch[len(batch)-1])
+	} else if more {
+		log.Warnf("Receipt archive batch filled by receipts received at %d - increase the batch size to avoid skipping receipts", last)
+	}
+
+	batchID := fmt.Sprintf("%013d-%013d", first, last)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err = json.NewEncoder(zw).Encode(batch); err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = a.s3.PutObject(a.conf.Prefix+batchID+archiveBatchSuffix, "application/gzip", buf.Bytes())
+	}
+	if err != nil {
+		return false, err
+	}
+
+	checkpoint := &receiptArchiveCheckpoint{ArchivedTo: last, LastBatch: batchID}
+	b, _ := json.Marshal(checkpoint)
+	if err = a.s3.PutObject(a.conf.Prefix+archiveCheckpointName, "application/json", b); err != nil {
+		return false, err
+	}
+	a.checkpoint = checkpoint
+	log.Infof("Archived %d receipts to batch %s", len(batch), batchID)
+
+	if a.conf.DeleteArchived {
+		done := a.metrics.Start("PruneReceipts")
+		pruned, err := a.persistence.PruneReceipts(last+1, 0)
+		done(err)
+		if err != nil {
+			// The receipts are safely archived, so retention will catch up with them later
+			log.Errorf("Failed to delete archived receipts up to %d: %s", last, err)
+		} else {
+			log.Infof("Deleted %d archived receipts", pruned)
+		}
+	}
+	return more, nil
+}
+
+// readBatch reads an archived batch, returning nil if it does not exist
+func (a *receiptArchive) readBatch(batchID string) (*[]map[string]interface{}, error) {
+	b, err := a.s3.GetObject(a.conf.Prefix + batchID + archiveBatchSuffix)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	jsonBytes, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	var batch []map[string]interface{}
+	if err = json.Unmarshal(jsonBytes, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
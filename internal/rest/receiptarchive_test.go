@@ -0,0 +1,246 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/auth"
+	"github.com/hyperledger/firefly-ethconnect/internal/auth/authtest"
+	"github.com/hyperledger/firefly-ethconnect/internal/receipts"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockS3 is an in-memory path style object store, that fails requests while failStatus is set
+type mockS3 struct {
+	*httptest.Server
+	mux        sync.Mutex
+	objects    map[string][]byte
+	failStatus int
+}
+
+func newMockS3() *mockS3 {
+	m := &mockS3{objects: make(map[string][]byte)}
+	m.Server = httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		m.mux.Lock()
+		defer m.mux.Unlock()
+		key := strings.TrimPrefix(req.URL.Path, "/bucket1/")
+		if m.failStatus != 0 {
+			res.WriteHeader(m.failStatus)
+			return
+		}
+		switch req.Method {
+		case http.MethodPut:
+			m.objects[key], _ = ioutil.ReadAll(req.Body)
+		default:
+			if b, ok := m.objects[key]; ok {
+				res.Write(b)
+			} else {
+				res.WriteHeader(404)
+			}
+		}
+	}))
+	return m
+}
+
+func (m *mockS3) batch(t *testing.T, key string) []map[string]interface{} {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	zr, err := gzip.NewReader(bytes.NewReader(m.objects[key]))
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	var batch []map[string]interface{}
+	assert.NoError(t, json.NewDecoder(zr).Decode(&batch))
+	return batch
+}
+
+func newTestReceiptArchive(t *testing.T, s3 *mockS3, conf *ReceiptArchiveConf) (*receiptArchive, *receipts.MemoryReceipts) {
+	conf.Enabled = true
+	conf.S3 = utils.S3Conf{
+		Endpoint:        s3.URL,
+		Bucket:          "bucket1",
+		AccessKeyID:     "ak1",
+		SecretAccessKey: "sk1",
+		PathStyle:       true,
+	}
+	p := receipts.NewMemoryReceipts(&receipts.ReceiptStoreConf{MaxDocs: 100})
+	a, err := newReceiptArchive(conf, p)
+	assert.NoError(t, err)
+	return a, p
+}
+
+func addTestArchiveReceipts(p *receipts.MemoryReceipts, receivedAt ...int64) {
+	for i, ts := range receivedAt {
+		id := fmt.Sprintf("r%d", i)
+		receipt := map[string]interface{}{"_id": id, "receivedAt": ts}
+		p.AddReceipt(id, &receipt, false)
+	}
+}
+
+func TestReceiptArchiveBatches(t *testing.T) {
+	assert := assert.New(t)
+	s3 := newMockS3()
+	defer s3.Close()
+
+	a, p := newTestReceiptArchive(t, s3, &ReceiptArchiveConf{
+		BatchSize:      3,
+		OlderThanSec:   1,
+		DeleteArchived: true,
+	})
+	// The two receipts at 1000000000003 must land in the same batch
+	addTestArchiveReceipts(p, 1000000000001, 1000000000002, 1000000000003, 1000000000003, 1000000000004, 1000000002000)
+	a.archiveReceipts(time.Unix(0, 1000000002000*int64(time.Millisecond)))
+
+	assert.Len(s3.batch(t, "receipts/1000000000001-1000000000002.json.gz"), 2)
+	batch := s3.batch(t, "receipts/1000000000003-1000000000003.json.gz")
+	assert.Len(batch, 2)
+	assert.Equal("r2", batch[0]["_id"])
+	assert.Len(s3.batch(t, "receipts/1000000000004-1000000000004.json.gz"), 1)
+	assert.Equal(`{"archivedTo":1000000000004,"lastBatch":"1000000000004-1000000000004"}`, string(s3.objects["receipts/checkpoint.json"]))
+	assert.Equal(1, p.Receipts().Len())
+
+	// A restart resumes from the checkpoint in the bucket
+	a, _ = newTestReceiptArchive(t, s3, &ReceiptArchiveConf{BatchSize: 3, OlderThanSec: 1})
+	a.reader = p
+	a.persistence = p
+	addTestArchiveReceipts(p, 1000000002500)
+	a.archiveReceipts(time.Unix(0, 1000000002000*int64(time.Millisecond)))
+	assert.Equal(int64(1000000000004), a.checkpoint.ArchivedTo)
+	a.archiveReceipts(time.Unix(0, 1000000004000*int64(time.Millisecond)))
+	assert.Len(s3.batch(t, "receipts/1000000002000-1000000002500.json.gz"), 2)
+	assert.Equal(int64(1000000002500), a.checkpoint.ArchivedTo)
+}
+
+func TestReceiptArchiveS3Failures(t *testing.T) {
+	assert := assert.New(t)
+	s3 := newMockS3()
+	defer s3.Close()
+
+	a, p := newTestReceiptArchive(t, s3, &ReceiptArchiveConf{})
+	assert.Equal(defaultArchivePrefix, a.conf.Prefix)
+	assert.Equal(defaultArchiveBatchSize, a.conf.BatchSize)
+	addTestArchiveReceipts(p, 1000000000001)
+	now := time.Unix(0, 1000000000001*int64(time.Millisecond)).Add(48 * time.Hour)
+
+	s3.failStatus = 500
+	a.archiveReceipts(now)
+	assert.Nil(a.checkpoint)
+
+	s3.failStatus = 0
+	s3.objects["receipts/checkpoint.json"] = []byte("!json")
+	a.archiveReceipts(now)
+	assert.Nil(a.checkpoint)
+
+	delete(s3.objects, "receipts/checkpoint.json")
+	a.archiveReceipts(now)
+	assert.Equal(int64(1000000000001), a.checkpoint.ArchivedTo)
+
+	addTestArchiveReceipts(p, 1000000000002)
+	s3.failStatus = 403
+	a.archiveReceipts(now)
+	assert.Equal(int64(1000000000001), a.checkpoint.ArchivedTo)
+	assert.Equal(2, p.Receipts().Len())
+}
+
+func TestReceiptArchiveConfigErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := newReceiptArchive(&ReceiptArchiveConf{}, &mockReceiptErrs{})
+	assert.Regexp("FFEC100289", err)
+	_, err = newReceiptArchive(&ReceiptArchiveConf{}, receipts.NewMemoryReceipts(&receipts.ReceiptStoreConf{}))
+	assert.Regexp("FFEC100286", err)
+}
+
+func TestReceiptArchiveLoopStartStop(t *testing.T) {
+	s3 := newMockS3()
+	defer s3.Close()
+
+	a, _ := newTestReceiptArchive(t, s3, &ReceiptArchiveConf{})
+	a.start()
+	a.close()
+	a.close()
+}
+
+func TestGetArchivedReplies(t *testing.T) {
+	assert := assert.New(t)
+	s3 := newMockS3()
+	defer s3.Close()
+
+	r, p, ts := newReceiptsTestServer()
+	defer ts.Close()
+
+	status, respJSON, err := testGETObject(ts, "/replies/archive/1000000000001-1000000000001")
+	assert.NoError(err)
+	assert.Equal(405, status)
+	assert.Equal("Receipt archiving is not enabled", respJSON["error"])
+
+	r.archive, _ = newTestReceiptArchive(t, s3, &ReceiptArchiveConf{OlderThanSec: 1})
+	r.archive.reader = p
+	addTestArchiveReceipts(p, 1000000000001)
+	r.archive.archiveReceipts(time.Now())
+
+	status, results, err := testGETArray(ts, "/replies/archive/1000000000001-1000000000001")
+	assert.NoError(err)
+	assert.Equal(200, status)
+	assert.Len(results, 1)
+	assert.Equal("r0", results[0]["_id"])
+
+	status, respJSON, _ = testGETObject(ts, "/replies/archive/1000000000002-1000000000002")
+	assert.Equal(404, status)
+	assert.Equal("Receipt archive batch '1000000000002-1000000000002' not found", respJSON["error"])
+
+	status, _, _ = testGETObject(ts, "/replies/archive/latest")
+	assert.Equal(400, status)
+
+	status, _, _ = testGETObject(ts, "/replies/r0/1000000000001-1000000000001")
+	assert.Equal(404, status)
+
+	s3.objects["receipts/1000000000003-1000000000003.json.gz"] = []byte("not gzip")
+	status, _, _ = testGETObject(ts, "/replies/archive/1000000000003-1000000000003")
+	assert.Equal(500, status)
+
+	r.close()
+}
+
+func TestGetArchivedRepliesUnauthorized(t *testing.T) {
+	auth.RegisterSecurityModule(&authtest.TestSecurityModule{})
+
+	assert := assert.New(t)
+	r, _ := newReceiptsTestStore(nil)
+	router := &httprouter.Router{}
+	r.addRoutes(router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	status, respJSON, httpErr := testGETObject(ts, "/replies/archive/1000000000001-1000000000001")
+	assert.NoError(httpErr)
+	assert.Equal(401, status)
+	assert.Equal("Unauthorized", respJSON["error"])
+
+	auth.RegisterSecurityModule(nil)
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"strings"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/receipts"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// eventCorrelationCacheSize is the number of recent successful transactions that can be
+	// correlated with event deliveries, as receipts are stored by request ID
+	eventCorrelationCacheSize = 10000
+)
+
+// trackTransaction remembers the request for a successful transaction, so event deliveries
+// from the transaction can be correlated with the receipt
+func (r *receiptStore) trackTransaction(requestID string, receipt map[string]interface{}) {
+	if txHash := utils.GetMapString(receipt, "transactionHash"); txHash != "" {
+		r.txRequests.Add(strings.ToLower(txHash), requestID)
+	}
+}
+
+// EventsDelivered is called by the event streams after each batch is delivered, and adds a
+// reference to the batch to the receipt of each transaction that emitted events in it
+func (r *receiptStore) EventsDelivered(streamID string, batchNumber uint64, txHashes []string) {
+	if r.persistence == nil {
+		return
+	}
+	for _, txHash := range txHashes {
+		requestID, ok := r.txRequests.Get(strings.ToLower(txHash))
+		if !ok {
+			continue
+		}
+		r.addEventDelivery(requestID.(string), streamID, batchNumber)
+	}
+}
+
+// addEventDelivery appends the stream and batch to the eventDeliveries of the receipt
+func (r *receiptStore) addEventDelivery(requestID, streamID string, batchNumber uint64) {
+	// Deliveries from multiple streams update the same receipt
+	r.correlationMux.Lock()
+	defer r.correlationMux.Unlock()
+
+	delivery := map[string]interface{}{
+		"streamId":    streamID,
+		"batchNumber": batchNumber,
+	}
+	if r.writeBehind != nil {
+		existing, err := r.getReceipt(requestID)
+		if err != nil || existing == nil {
+			log.Warnf("%s: Unable to record event delivery from stream %s: %v", requestID, streamID, err)
+			return
+		}
+		receipt := make(map[string]interface{}, len(*existing)+2)
+		for k, v := range *existing {
+			receipt[k] = v
+		}
+		for k, v := range eventDeliveryFields(*existing, delivery) {
+			receipt[k] = v
+		}
+		r.writeBehind.write(requestID, receipt)
+		return
+	}
+
+	// Only our fields are updated, so a reply written concurrently is not overwritten
+	done := r.metrics.Start("UpdateReceipt")
+	receipt, err := receipts.UpdateReceiptFields(r.persistence, requestID, func(existing *map[string]interface{}) map[string]interface{} {
+		if existing == nil {
+			return nil
+		}
+		return eventDeliveryFields(*existing, delivery)
+	})
+	done(err)
+	if err != nil {
+		log.Errorf("%s: Failed to record event delivery from stream %s: %s", requestID, streamID, err)
+		return
+	}
+	if receipt == nil {
+		log.Warnf("%s: Unable to record event delivery from stream %s: receipt not found", requestID, streamID)
+		return
+	}
+	log.Infof("%s: Recorded delivery of events in batch %d of stream %s", requestID, batchNumber, streamID)
+	r.receiptWritten(*receipt)
+}
+
+// eventDeliveryFields appends a delivery to the eventDeliveries of the receipt
+func eventDeliveryFields(existing map[string]interface{}, delivery map[string]interface{}) map[string]interface{} {
+	deliveries, _ := existing["eventDeliveries"].([]interface{})
+	return map[string]interface{}{
+		"eventDeliveries": append(append([]interface{}{}, deliveries...), delivery),
+		"confirmedEvents": true,
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/receipts"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+const testEventsTxHash = "0x02587104e9879911bea3d5bf6ccd7e1a6cb9a03145b8a1141804cebd6aa67c5c"
+
+func processTestReply(r *receiptStore, msgType string) string {
+	replyMsg := &messages.TransactionReceipt{}
+	replyMsg.Headers.MsgType = msgType
+	replyMsg.Headers.ReqID = utils.UUIDv4()
+	txHash := ethbind.API.HexToHash(testEventsTxHash)
+	replyMsg.TransactionHash = &txHash
+	replyMsgBytes, _ := json.Marshal(&replyMsg)
+	r.processReply(replyMsgBytes)
+	return replyMsg.Headers.ReqID
+}
+
+func TestEventsDeliveredUpdatesReceipt(t *testing.T) {
+	assert := assert.New(t)
+	r, p := newReceiptsTestStore(nil)
+
+	requestID := processTestReply(r, messages.MsgTypeTransactionSuccess)
+
+	r.EventsDelivered("es-1", 5, []string{"0x1234", "0x02587104E9879911BEA3D5BF6CCD7E1A6CB9A03145B8A1141804CEBD6AA67C5C"})
+	r.EventsDelivered("es-2", 1, []string{testEventsTxHash})
+
+	receipt, err := p.GetReceipt(requestID)
+	assert.NoError(err)
+	assert.Equal(true, (*receipt)["confirmedEvents"])
+	assert.Equal([]interface{}{
+		map[string]interface{}{"streamId": "es-1", "batchNumber": uint64(5)},
+		map[string]interface{}{"streamId": "es-2", "batchNumber": uint64(1)},
+	}, (*receipt)["eventDeliveries"])
+	assert.Equal(testEventsTxHash, (*receipt)["transactionHash"])
+}
+
+func TestEventsDeliveredFailedTransaction(t *testing.T) {
+	assert := assert.New(t)
+	r, p := newReceiptsTestStore(nil)
+
+	requestID := processTestReply(r, messages.MsgTypeTransactionFailure)
+	r.EventsDelivered("es-1", 1, []string{testEventsTxHash})
+
+	receipt, err := p.GetReceipt(requestID)
+	assert.NoError(err)
+	assert.Nil((*receipt)["confirmedEvents"])
+}
+
+func TestEventsDeliveredMissingReceipt(t *testing.T) {
+	assert := assert.New(t)
+	r, p := newReceiptsTestStore(nil)
+
+	requestID := processTestReply(r, messages.MsgTypeTransactionSuccess)
+	_, err := p.DeleteReceipts([]string{requestID})
+	assert.NoError(err)
+
+	r.EventsDelivered("es-1", 1, []string{testEventsTxHash})
+	assert.Equal(0, p.Receipts().Len())
+}
+
+func TestEventsDeliveredWriteBehind(t *testing.T) {
+	assert := assert.New(t)
+	r, p := newReceiptsTestStore(nil)
+	r.writeBehind = newReceiptWriteBehind(&ReceiptWriteBehindConf{Enabled: true, LingerMS: 60000}, r)
+
+	requestID := processTestReply(r, messages.MsgTypeTransactionSuccess)
+	r.EventsDelivered("es-1", 1, []string{testEventsTxHash})
+	r.close()
+
+	receipt, err := p.GetReceipt(requestID)
+	assert.NoError(err)
+	assert.Equal(true, (*receipt)["confirmedEvents"])
+}
+
+func TestEventsDeliveredNoPersistence(t *testing.T) {
+	r := newReceiptStore(&receipts.ReceiptStoreConf{}, nil, nil)
+	r.EventsDelivered("es-1", 1, []string{testEventsTxHash})
+}
@@ -0,0 +1,398 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/kafka"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ReceiptExporterTypeHTTP posts each batch as a JSON array
+	ReceiptExporterTypeHTTP = "http"
+	// ReceiptExporterTypeKafka produces one message per receipt, keyed by request ID
+	ReceiptExporterTypeKafka = "kafka"
+	// ReceiptExporterTypeFile writes each batch as a newline delimited JSON file, for warehouse load jobs
+	ReceiptExporterTypeFile = "file"
+
+	defaultExporterQueueSize        = 1000
+	defaultExporterBatchSize        = 50
+	defaultExporterBatchTimeoutMS   = 500
+	defaultExporterMaxAttempts      = 5
+	defaultExporterRetryInitDelayMS = 500
+	defaultExporterHTTPTimeoutMS    = 30000
+)
+
+// ReceiptExporterConf configures one exporter in the chain invoked after each receipt write
+type ReceiptExporterConf struct {
+	Name                string                   `json:"name"`
+	Type                string                   `json:"type"`
+	QueueSize           int                      `json:"queueSize,omitempty"`
+	BatchSize           int                      `json:"batchSize,omitempty"`
+	BatchTimeoutMS      int                      `json:"batchTimeoutMS,omitempty"`
+	MaxAttempts         int                      `json:"maxAttempts,omitempty"`
+	RetryInitialDelayMS int                      `json:"retryInitialDelayMS,omitempty"`
+	HTTP                ReceiptExporterHTTPConf  `json:"http,omitempty"`
+	Kafka               ReceiptExporterKafkaConf `json:"kafka,omitempty"`
+	File                ReceiptExporterFileConf  `json:"file,omitempty"`
+}
+
+// ReceiptExporterHTTPConf configures a HTTP exporter
+type ReceiptExporterHTTPConf struct {
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	TimeoutMS int               `json:"timeoutMS,omitempty"`
+	TLS       utils.TLSConfig   `json:"tls,omitempty"`
+}
+
+// ReceiptExporterKafkaConf configures a Kafka exporter
+type ReceiptExporterKafkaConf struct {
+	Brokers  []string        `json:"brokers"`
+	Topic    string          `json:"topic"`
+	ClientID string          `json:"clientID,omitempty"`
+	TLS      utils.TLSConfig `json:"tls,omitempty"`
+	SASL     struct {
+		Username string `json:"username,omitempty"`
+		Password string `json:"password,omitempty"`
+	} `json:"sasl,omitempty"`
+}
+
+// ReceiptExporterFileConf configures a batch file exporter
+type ReceiptExporterFileConf struct {
+	Dir string `json:"dir"`
+}
+
+// receiptExporter delivers a batch of receipts to an external system
+type receiptExporter interface {
+	export(batch []json.RawMessage) error
+	close()
+}
+
+// receiptExporterWorker isolates each exporter behind its own queue and goroutine, so that
+// a slow or failing exporter never blocks the receipt store, or the other exporters
+type receiptExporterWorker struct {
+	conf     *ReceiptExporterConf
+	exporter receiptExporter
+	queue    chan json.RawMessage
+	stopping chan struct{}
+	done     chan struct{}
+	dropped  int64
+}
+
+type receiptExporters struct {
+	mux     sync.RWMutex
+	workers []*receiptExporterWorker
+}
+
+var newKafkaSyncProducer = sarama.NewSyncProducer
+
+func newReceiptExporters(confs []ReceiptExporterConf) (*receiptExporters, error) {
+	re := &receiptExporters{}
+	for i := range confs {
+		conf := &confs[i]
+		if conf.Name == "" {
+			conf.Name = fmt.Sprintf("%s%d", conf.Type, i)
+		}
+		exporter, err := newReceiptExporter(conf)
+		if err != nil {
+			re.close()
+			return nil, err
+		}
+		re.workers = append(re.workers, newReceiptExporterWorker(conf, exporter))
+	}
+	return re, nil
+}
+
+func newReceiptExporter(conf *ReceiptExporterConf) (receiptExporter, error) {
+	switch conf.Type {
+	case ReceiptExporterTypeHTTP:
+		return newHTTPReceiptExporter(conf)
+	case ReceiptExporterTypeKafka:
+		return newKafkaReceiptExporter(conf)
+	case ReceiptExporterTypeFile:
+		return newFileReceiptExporter(conf)
+	default:
+		return nil, errors.Errorf(errors.ReceiptExporterUnknownType, conf.Type, conf.Name)
+	}
+}
+
+func newReceiptExporterWorker(conf *ReceiptExporterConf, exporter receiptExporter) *receiptExporterWorker {
+	if conf.QueueSize <= 0 {
+		conf.QueueSize = defaultExporterQueueSize
+	}
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = defaultExporterBatchSize
+	}
+	if conf.BatchTimeoutMS <= 0 {
+		conf.BatchTimeoutMS = defaultExporterBatchTimeoutMS
+	}
+	if conf.MaxAttempts <= 0 {
+		conf.MaxAttempts = defaultExporterMaxAttempts
+	}
+	if conf.RetryInitialDelayMS <= 0 {
+		conf.RetryInitialDelayMS = defaultExporterRetryInitDelayMS
+	}
+	w := &receiptExporterWorker{
+		conf:     conf,
+		exporter: exporter,
+		queue:    make(chan json.RawMessage, conf.QueueSize),
+		stopping: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// export snapshots the receipt and queues it for every exporter, without blocking
+func (re *receiptExporters) export(receipt map[string]interface{}) {
+	if re == nil || len(re.workers) == 0 {
+		return
+	}
+	b, err := json.Marshal(receipt)
+	if err != nil {
+		log.Errorf("Failed to serialize receipt for export: %s", err)
+		return
+	}
+	re.mux.RLock()
+	defer re.mux.RUnlock()
+	for _, w := range re.workers {
+		select {
+		case w.queue <- b:
+		default:
+			dropped := atomic.AddInt64(&w.dropped, 1)
+			log.Warnf("Receipt exporter '%s' queue full - dropped receipt (total dropped=%d)", w.conf.Name, dropped)
+		}
+	}
+}
+
+// close flushes any queued receipts, then stops each exporter
+func (re *receiptExporters) close() {
+	if re == nil {
+		return
+	}
+	re.mux.Lock()
+	defer re.mux.Unlock()
+	for _, w := range re.workers {
+		close(w.stopping)
+		close(w.queue)
+	}
+	for _, w := range re.workers {
+		<-w.done
+		w.exporter.close()
+	}
+	re.workers = nil
+}
+
+func (w *receiptExporterWorker) run() {
+	defer close(w.done)
+	batchTimeout := time.Duration(w.conf.BatchTimeoutMS) * time.Millisecond
+	var batch []json.RawMessage
+	var timer <-chan time.Time
+	for {
+		select {
+		case b, ok := <-w.queue:
+			if !ok {
+				if len(batch) > 0 {
+					w.deliver(batch)
+				}
+				return
+			}
+			batch = append(batch, b)
+			if len(batch) == 1 {
+				timer = time.After(batchTimeout)
+			}
+			if len(batch) < w.conf.BatchSize {
+				continue
+			}
+		case <-timer:
+		}
+		w.deliver(batch)
+		batch = nil
+		timer = nil
+	}
+}
+
+// deliver retries with backoff until the exporter accepts the batch, or we run out of attempts.
+// Once shutdown starts we stop waiting between attempts.
+func (w *receiptExporterWorker) deliver(batch []json.RawMessage) {
+	delay := time.Duration(w.conf.RetryInitialDelayMS) * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := w.exporter.export(batch)
+		if err == nil {
+			log.Debugf("Receipt exporter '%s' exported %d receipts", w.conf.Name, len(batch))
+			return
+		}
+		log.Errorf("Receipt exporter '%s' attempt %d failed to export %d receipts: %s", w.conf.Name, attempt, len(batch), err)
+		if attempt >= w.conf.MaxAttempts {
+			dropped := atomic.AddInt64(&w.dropped, int64(len(batch)))
+			log.Errorf("Receipt exporter '%s' dropped %d receipts after %d attempts (total dropped=%d)", w.conf.Name, len(batch), attempt, dropped)
+			return
+		}
+		select {
+		case <-time.After(delay):
+			delay = time.Duration(float64(delay) * 2)
+		case <-w.stopping:
+			attempt = w.conf.MaxAttempts - 1
+		}
+	}
+}
+
+type httpReceiptExporter struct {
+	conf   *ReceiptExporterConf
+	client *http.Client
+}
+
+func newHTTPReceiptExporter(conf *ReceiptExporterConf) (*httpReceiptExporter, error) {
+	if conf.HTTP.URL == "" {
+		return nil, errors.Errorf(errors.ReceiptExporterMissingConfig, conf.Name, "http.url")
+	}
+	tlsConfig, err := utils.CreateTLSConfiguration(&conf.HTTP.TLS)
+	if err != nil {
+		return nil, err
+	}
+	timeoutMS := conf.HTTP.TimeoutMS
+	if timeoutMS <= 0 {
+		timeoutMS = defaultExporterHTTPTimeoutMS
+	}
+	return &httpReceiptExporter{
+		conf: conf,
+		client: &http.Client{
+			Timeout:   time.Duration(timeoutMS) * time.Millisecond,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+func (e *httpReceiptExporter) export(batch []json.RawMessage) error {
+	body, _ := json.Marshal(batch)
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, e.conf.HTTP.URL, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.conf.HTTP.Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = ioutil.ReadAll(res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.Errorf(errors.ReceiptExporterHTTPStatus, e.conf.Name, res.StatusCode, e.conf.HTTP.URL)
+	}
+	return nil
+}
+
+func (e *httpReceiptExporter) close() {
+	e.client.CloseIdleConnections()
+}
+
+type kafkaReceiptExporter struct {
+	conf     *ReceiptExporterConf
+	producer sarama.SyncProducer
+}
+
+func newKafkaReceiptExporter(conf *ReceiptExporterConf) (*kafkaReceiptExporter, error) {
+	kconf := &conf.Kafka
+	if len(kconf.Brokers) == 0 || kconf.Brokers[0] == "" {
+		return nil, errors.Errorf(errors.ReceiptExporterMissingConfig, conf.Name, "kafka.brokers")
+	}
+	if kconf.Topic == "" {
+		return nil, errors.Errorf(errors.ReceiptExporterMissingConfig, conf.Name, "kafka.topic")
+	}
+	clientConf, err := kafka.NewSaramaConfig(kconf.ClientID, &kconf.TLS, kconf.SASL.Username, kconf.SASL.Password)
+	if err != nil {
+		return nil, err
+	}
+	clientConf.Producer.Return.Successes = true
+	clientConf.Producer.RequiredAcks = sarama.WaitForAll
+	producer, err := newKafkaSyncProducer(kconf.Brokers, clientConf)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaReceiptExporter{conf: conf, producer: producer}, nil
+}
+
+func (e *kafkaReceiptExporter) export(batch []json.RawMessage) error {
+	msgs := make([]*sarama.ProducerMessage, len(batch))
+	for i, b := range batch {
+		var headers struct {
+			ID string `json:"_id"`
+		}
+		_ = json.Unmarshal(b, &headers)
+		msgs[i] = &sarama.ProducerMessage{
+			Topic: e.conf.Kafka.Topic,
+			Key:   sarama.StringEncoder(headers.ID),
+			Value: sarama.ByteEncoder(b),
+		}
+	}
+	return e.producer.SendMessages(msgs)
+}
+
+func (e *kafkaReceiptExporter) close() {
+	_ = e.producer.Close()
+}
+
+type fileReceiptExporter struct {
+	conf *ReceiptExporterConf
+	seq  int64
+}
+
+func newFileReceiptExporter(conf *ReceiptExporterConf) (*fileReceiptExporter, error) {
+	if conf.File.Dir == "" {
+		return nil, errors.Errorf(errors.ReceiptExporterMissingConfig, conf.Name, "file.dir")
+	}
+	if err := os.MkdirAll(conf.File.Dir, 0755); err != nil {
+		return nil, errors.Errorf(errors.ReceiptExporterWriteFile, conf.Name, err)
+	}
+	return &fileReceiptExporter{conf: conf}, nil
+}
+
+// export writes to a temporary file and renames it into place, so a loader
+// watching the directory never picks up a partially written batch
+func (e *fileReceiptExporter) export(batch []json.RawMessage) error {
+	e.seq++
+	name := fmt.Sprintf("receipts-%d-%06d.ndjson", time.Now().UnixNano(), e.seq)
+	tmpFile := path.Join(e.conf.File.Dir, "."+name+".tmp")
+	var buf bytes.Buffer
+	for _, b := range batch {
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+	if err := ioutil.WriteFile(tmpFile, buf.Bytes(), 0644); err != nil {
+		return errors.Errorf(errors.ReceiptExporterWriteFile, e.conf.Name, err)
+	}
+	if err := os.Rename(tmpFile, path.Join(e.conf.File.Dir, name)); err != nil {
+		_ = os.Remove(tmpFile)
+		return errors.Errorf(errors.ReceiptExporterWriteFile, e.conf.Name, err)
+	}
+	return nil
+}
+
+func (e *fileReceiptExporter) close() {}
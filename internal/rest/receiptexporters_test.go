@@ -0,0 +1,346 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	saramamocks "github.com/IBM/sarama/mocks"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockReceiptExporter struct {
+	mux      sync.Mutex
+	failures int
+	batches  [][]json.RawMessage
+	attempts int
+	closed   bool
+}
+
+func (m *mockReceiptExporter) export(batch []json.RawMessage) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.attempts++
+	if m.failures > 0 {
+		m.failures--
+		return fmt.Errorf("pop")
+	}
+	m.batches = append(m.batches, batch)
+	return nil
+}
+
+func (m *mockReceiptExporter) close() {
+	m.closed = true
+}
+
+func (m *mockReceiptExporter) counts() (attempts, batches int) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.attempts, len(m.batches)
+}
+
+func newTestExporterWorkers(confs []*ReceiptExporterConf, exporters []receiptExporter) *receiptExporters {
+	re := &receiptExporters{}
+	for i, conf := range confs {
+		re.workers = append(re.workers, newReceiptExporterWorker(conf, exporters[i]))
+	}
+	return re
+}
+
+func TestReceiptExportersBatchAndFlushOnClose(t *testing.T) {
+	assert := assert.New(t)
+
+	me := &mockReceiptExporter{}
+	re := newTestExporterWorkers([]*ReceiptExporterConf{
+		{Name: "test", BatchSize: 2, BatchTimeoutMS: 60000},
+	}, []receiptExporter{me})
+	for i := 0; i < 3; i++ {
+		re.export(map[string]interface{}{"_id": fmt.Sprintf("id%d", i)})
+	}
+	re.close()
+	re.close()
+
+	assert.True(me.closed)
+	assert.Len(me.batches, 2)
+	assert.Len(me.batches[0], 2)
+	assert.JSONEq(`{"_id":"id2"}`, string(me.batches[1][0]))
+}
+
+func TestReceiptExportersBatchTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	me := &mockReceiptExporter{}
+	re := newTestExporterWorkers([]*ReceiptExporterConf{
+		{Name: "test", BatchSize: 10, BatchTimeoutMS: 1},
+	}, []receiptExporter{me})
+	re.export(map[string]interface{}{"_id": "id1"})
+	for _, n := me.counts(); n == 0; _, n = me.counts() {
+		time.Sleep(1 * time.Millisecond)
+	}
+	re.close()
+	assert.Len(me.batches, 1)
+}
+
+func TestReceiptExportersRetryAndIsolation(t *testing.T) {
+	assert := assert.New(t)
+
+	failing := &mockReceiptExporter{failures: 100}
+	flaky := &mockReceiptExporter{failures: 2}
+	re := newTestExporterWorkers([]*ReceiptExporterConf{
+		{Name: "failing", BatchSize: 1, MaxAttempts: 3, RetryInitialDelayMS: 1},
+		{Name: "flaky", BatchSize: 1, MaxAttempts: 3, RetryInitialDelayMS: 1},
+	}, []receiptExporter{failing, flaky})
+	failingWorker := re.workers[0]
+	re.export(map[string]interface{}{"_id": "id1"})
+	for a, _ := failing.counts(); a < 3; a, _ = failing.counts() {
+		time.Sleep(1 * time.Millisecond)
+	}
+	for _, n := flaky.counts(); n == 0; _, n = flaky.counts() {
+		time.Sleep(1 * time.Millisecond)
+	}
+	re.close()
+
+	assert.Equal(3, failing.attempts)
+	assert.Empty(failing.batches)
+	assert.Equal(int64(1), failingWorker.dropped)
+	assert.Equal(3, flaky.attempts)
+	assert.Len(flaky.batches, 1)
+}
+
+func TestReceiptExportersCloseStopsRetrying(t *testing.T) {
+	assert := assert.New(t)
+
+	failing := &mockReceiptExporter{failures: 100}
+	re := newTestExporterWorkers([]*ReceiptExporterConf{
+		{Name: "failing", BatchSize: 1, MaxAttempts: 100, RetryInitialDelayMS: 60000},
+	}, []receiptExporter{failing})
+	re.export(map[string]interface{}{"_id": "id1"})
+	for a, _ := failing.counts(); a == 0; a, _ = failing.counts() {
+		time.Sleep(1 * time.Millisecond)
+	}
+	re.close()
+	assert.Equal(2, failing.attempts)
+}
+
+func TestReceiptExportersQueueFull(t *testing.T) {
+	assert := assert.New(t)
+
+	me := &mockReceiptExporter{}
+	w := &receiptExporterWorker{
+		conf:  &ReceiptExporterConf{Name: "test"},
+		queue: make(chan json.RawMessage, 1),
+	}
+	re := &receiptExporters{workers: []*receiptExporterWorker{w}}
+	re.export(map[string]interface{}{"_id": "id1"})
+	re.export(map[string]interface{}{"_id": "id2"})
+	assert.Equal(int64(1), w.dropped)
+	assert.Empty(me.batches)
+
+	re.export(map[string]interface{}{"bad": map[bool]bool{true: true}})
+	assert.Equal(int64(1), w.dropped)
+
+	var nilExporters *receiptExporters
+	nilExporters.export(map[string]interface{}{})
+	nilExporters.close()
+}
+
+func TestNewReceiptExportersBadConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := newReceiptExporters([]ReceiptExporterConf{{Type: "bigquery"}})
+	assert.Regexp("FFEC100251.*bigquery0", err)
+
+	dir, _ := ioutil.TempDir("", "fly")
+	defer os.RemoveAll(dir)
+	_, err = newReceiptExporters([]ReceiptExporterConf{
+		{Type: ReceiptExporterTypeFile, File: ReceiptExporterFileConf{Dir: dir}},
+		{Type: ReceiptExporterTypeHTTP},
+	})
+	assert.Regexp("FFEC100252.*http.url", err)
+
+	_, err = newReceiptExporters([]ReceiptExporterConf{{Type: ReceiptExporterTypeHTTP, HTTP: ReceiptExporterHTTPConf{
+		URL: "http://localhost",
+		TLS: utils.TLSConfig{Enabled: true, CACertsFile: "/non/existent"},
+	}}})
+	assert.Error(err)
+
+	_, err = newReceiptExporters([]ReceiptExporterConf{{Type: ReceiptExporterTypeKafka}})
+	assert.Regexp("FFEC100252.*kafka.brokers", err)
+
+	_, err = newReceiptExporters([]ReceiptExporterConf{{Type: ReceiptExporterTypeKafka, Kafka: ReceiptExporterKafkaConf{
+		Brokers: []string{"localhost:9092"},
+	}}})
+	assert.Regexp("FFEC100252.*kafka.topic", err)
+
+	_, err = newReceiptExporters([]ReceiptExporterConf{{Type: ReceiptExporterTypeFile}})
+	assert.Regexp("FFEC100252.*file.dir", err)
+
+	_, err = newReceiptExporters([]ReceiptExporterConf{{Type: ReceiptExporterTypeFile, File: ReceiptExporterFileConf{
+		Dir: path.Join(dir, "exists"),
+	}}})
+	assert.NoError(err)
+	_ = ioutil.WriteFile(path.Join(dir, "file"), []byte{}, 0644)
+	_, err = newReceiptExporters([]ReceiptExporterConf{{Type: ReceiptExporterTypeFile, File: ReceiptExporterFileConf{
+		Dir: path.Join(dir, "file", "sub"),
+	}}})
+	assert.Regexp("FFEC100254", err)
+}
+
+func TestHTTPReceiptExporter(t *testing.T) {
+	assert := assert.New(t)
+
+	status := 204
+	var received []map[string]interface{}
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		auth = req.Header.Get("Authorization")
+		_ = json.NewDecoder(req.Body).Decode(&received)
+		res.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	e, err := newHTTPReceiptExporter(&ReceiptExporterConf{Name: "warehouse", HTTP: ReceiptExporterHTTPConf{
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer abc"},
+	}})
+	assert.NoError(err)
+	defer e.close()
+
+	err = e.export([]json.RawMessage{json.RawMessage(`{"_id":"id1"}`), json.RawMessage(`{"_id":"id2"}`)})
+	assert.NoError(err)
+	assert.Equal("Bearer abc", auth)
+	assert.Len(received, 2)
+	assert.Equal("id2", received[1]["_id"])
+
+	status = 503
+	err = e.export([]json.RawMessage{json.RawMessage(`{}`)})
+	assert.Regexp("FFEC100253.*warehouse.*503", err)
+
+	server.Close()
+	err = e.export([]json.RawMessage{json.RawMessage(`{}`)})
+	assert.Error(err)
+}
+
+func TestKafkaReceiptExporter(t *testing.T) {
+	assert := assert.New(t)
+
+	mp := saramamocks.NewSyncProducer(t, nil)
+	var produced []*sarama.ProducerMessage
+	mp.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		produced = append(produced, msg)
+		return nil
+	})
+	mp.ExpectSendMessageAndFail(fmt.Errorf("pop"))
+	defer func() { newKafkaSyncProducer = sarama.NewSyncProducer }()
+	var saramaConf *sarama.Config
+	newKafkaSyncProducer = func(addrs []string, config *sarama.Config) (sarama.SyncProducer, error) {
+		saramaConf = config
+		return mp, nil
+	}
+
+	conf := &ReceiptExporterConf{Name: "kafka", Kafka: ReceiptExporterKafkaConf{
+		Brokers: []string{"localhost:9092"},
+		Topic:   "receipts",
+	}}
+	conf.Kafka.SASL.Username = "user"
+	conf.Kafka.SASL.Password = "pass"
+	e, err := newKafkaReceiptExporter(conf)
+	assert.NoError(err)
+	assert.True(saramaConf.Net.SASL.Enable)
+	assert.NotEmpty(saramaConf.ClientID)
+
+	err = e.export([]json.RawMessage{json.RawMessage(`{"_id":"id1"}`)})
+	assert.NoError(err)
+	assert.Equal("receipts", produced[0].Topic)
+	key, _ := produced[0].Key.Encode()
+	assert.Equal("id1", string(key))
+
+	err = e.export([]json.RawMessage{json.RawMessage(`{"_id":"id2"}`)})
+	assert.Regexp("pop", err)
+	e.close()
+}
+
+func TestKafkaReceiptExporterFail(t *testing.T) {
+	assert := assert.New(t)
+
+	defer func() { newKafkaSyncProducer = sarama.NewSyncProducer }()
+	newKafkaSyncProducer = func(addrs []string, config *sarama.Config) (sarama.SyncProducer, error) {
+		return nil, fmt.Errorf("pop")
+	}
+	_, err := newKafkaReceiptExporter(&ReceiptExporterConf{Kafka: ReceiptExporterKafkaConf{
+		Brokers: []string{"localhost:9092"},
+		Topic:   "receipts",
+	}})
+	assert.Regexp("pop", err)
+
+	_, err = newKafkaReceiptExporter(&ReceiptExporterConf{Kafka: ReceiptExporterKafkaConf{
+		Brokers: []string{"localhost:9092"},
+		Topic:   "receipts",
+		TLS:     utils.TLSConfig{Enabled: true, CACertsFile: "/non/existent"},
+	}})
+	assert.Error(err)
+}
+
+func TestFileReceiptExporter(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, _ := ioutil.TempDir("", "fly")
+	defer os.RemoveAll(dir)
+	e, err := newFileReceiptExporter(&ReceiptExporterConf{Name: "files", File: ReceiptExporterFileConf{Dir: dir}})
+	assert.NoError(err)
+	defer e.close()
+
+	err = e.export([]json.RawMessage{json.RawMessage(`{"_id":"id1"}`), json.RawMessage(`{"_id":"id2"}`)})
+	assert.NoError(err)
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(files, 1)
+	assert.True(strings.HasSuffix(files[0].Name(), ".ndjson"))
+	b, _ := ioutil.ReadFile(path.Join(dir, files[0].Name()))
+	assert.Equal("{\"_id\":\"id1\"}\n{\"_id\":\"id2\"}\n", string(b))
+
+	os.RemoveAll(dir)
+	err = e.export([]json.RawMessage{json.RawMessage(`{}`)})
+	assert.Regexp("FFEC100254", err)
+}
+
+func TestReceiptStoreExportsWrites(t *testing.T) {
+	assert := assert.New(t)
+
+	r, _ := newReceiptsTestStore(nil)
+	me := &mockReceiptExporter{}
+	r.exporters = newTestExporterWorkers([]*ReceiptExporterConf{{Name: "test"}}, []receiptExporter{me})
+
+	err := r.writeAccepted("id1", "ack", map[string]interface{}{})
+	assert.NoError(err)
+	r.close()
+
+	assert.Len(me.batches, 1)
+	var exported map[string]interface{}
+	_ = json.Unmarshal(me.batches[0][0], &exported)
+	assert.Equal("id1", exported["_id"])
+	assert.Equal(true, exported["pending"])
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/auth"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// sseRouteID is matched in the handler for GET /replies/:id, like summaryRouteID
+	sseRouteID           = "sse"
+	sseSubscriberBuffer  = 100
+	sseKeepAliveInterval = 30 * time.Second
+)
+
+// receiptFeed fans out each stored receipt to the Server-Sent Events clients.
+// A client that cannot keep up misses receipts, rather than holding up the reply processor.
+type receiptFeed struct {
+	mux         sync.Mutex
+	subscribers map[chan map[string]interface{}]bool
+	closed      chan struct{}
+	keepAlive   time.Duration
+}
+
+func newReceiptFeed() *receiptFeed {
+	return &receiptFeed{
+		subscribers: make(map[chan map[string]interface{}]bool),
+		closed:      make(chan struct{}),
+		keepAlive:   sseKeepAliveInterval,
+	}
+}
+
+func (f *receiptFeed) subscribe() chan map[string]interface{} {
+	ch := make(chan map[string]interface{}, sseSubscriberBuffer)
+	f.mux.Lock()
+	f.subscribers[ch] = true
+	f.mux.Unlock()
+	return ch
+}
+
+func (f *receiptFeed) unsubscribe(ch chan map[string]interface{}) {
+	f.mux.Lock()
+	delete(f.subscribers, ch)
+	f.mux.Unlock()
+}
+
+func (f *receiptFeed) publish(receipt map[string]interface{}) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	for ch := range f.subscribers {
+		select {
+		case ch <- receipt:
+		default:
+			log.Warnf("SSE client is not keeping up. Dropped receipt %s", utils.GetMapString(receipt, "_id"))
+		}
+	}
+}
+
+func (f *receiptFeed) close() {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	select {
+	case <-f.closed:
+	default:
+		close(f.closed)
+	}
+}
+
+// getRepliesSSE handles a HTTP request to stream each new receipt as a Server-Sent Event,
+// until the client disconnects
+func (r *receiptStore) getRepliesSSE(res http.ResponseWriter, req *http.Request) {
+	err := auth.AuthListAsyncReplies(req.Context())
+	if err != nil {
+		log.Errorf("Error streaming replies: %s", err)
+		sendRESTError(res, req, errors.Errorf(errors.Unauthorized), 401)
+		return
+	}
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreSSEUnsupported), 500)
+		return
+	}
+	namespace := auth.GetNamespace(req.Context())
+
+	ch := r.sse.subscribe()
+	defer r.sse.unsubscribe(ch)
+
+	log.Infof("<-- %s %s [%d] streaming", req.Method, req.URL, 200)
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.WriteHeader(200)
+	// An initial comment lets the client know the stream is open
+	_, _ = fmt.Fprint(res, ": connected\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case receipt := <-ch:
+			if !r.inNamespace(receipt, namespace) {
+				continue
+			}
+			data, _ := json.Marshal(receipt)
+			_, err := fmt.Fprintf(res, "id: %s\nevent: receipt\ndata: %s\n\n", utils.GetMapString(receipt, "_id"), data)
+			if err != nil {
+				log.Infof("SSE client disconnected: %s", err)
+				return
+			}
+		case <-time.After(r.sse.keepAlive):
+			// Keeps the connection alive through proxies that close idle connections
+			if _, err := fmt.Fprint(res, ": keepalive\n\n"); err != nil {
+				log.Infof("SSE client disconnected: %s", err)
+				return
+			}
+		case <-req.Context().Done():
+			log.Infof("SSE client disconnected")
+			return
+		case <-r.sse.closed:
+			return
+		}
+		flusher.Flush()
+	}
+}
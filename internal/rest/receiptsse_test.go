@@ -0,0 +1,163 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/auth"
+	"github.com/hyperledger/firefly-ethconnect/internal/auth/authtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func openSSE(t *testing.T, ts *httptest.Server, namespace string) (*http.Response, *bufio.Reader) {
+	req, _ := http.NewRequest("GET", ts.URL+"/replies/sse", nil)
+	if namespace != "" {
+		req.Header.Set("x-firefly-namespace", namespace)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	reader := bufio.NewReader(resp.Body)
+	assert.Equal(t, ": connected\n", readSSELine(t, reader))
+	assert.Equal(t, "\n", readSSELine(t, reader))
+	return resp, reader
+}
+
+func readSSELine(t *testing.T, reader *bufio.Reader) string {
+	line, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	return line
+}
+
+// waitSSESubscribers waits for the handler to subscribe, as the headers are flushed first
+func waitSSESubscribers(r *receiptStore, count int) {
+	for {
+		r.sse.mux.Lock()
+		n := len(r.sse.subscribers)
+		r.sse.mux.Unlock()
+		if n == count {
+			return
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+}
+
+func TestRepliesSSE(t *testing.T) {
+	assert := assert.New(t)
+	r, _, ts := newReceiptsTestServer()
+	defer ts.Close()
+
+	resp, reader := openSSE(t, ts, "")
+	defer resp.Body.Close()
+	waitSSESubscribers(r, 1)
+
+	r.receiptWritten(map[string]interface{}{"_id": "req1", "transactionHash": "0x12345"})
+
+	assert.Equal("id: req1\n", readSSELine(t, reader))
+	assert.Equal("event: receipt\n", readSSELine(t, reader))
+	data := readSSELine(t, reader)
+	assert.True(strings.HasPrefix(data, "data: "))
+	var receipt map[string]interface{}
+	err := json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), &receipt)
+	assert.NoError(err)
+	assert.Equal("0x12345", receipt["transactionHash"])
+	assert.Equal("\n", readSSELine(t, reader))
+
+	// Closing the store ends the stream
+	r.close()
+	_, err = reader.ReadString('\n')
+	assert.Error(err)
+}
+
+func TestRepliesSSENamespaced(t *testing.T) {
+	assert := assert.New(t)
+	r, _, ts := newNamespacedReceiptsTestServer()
+	defer ts.Close()
+
+	resp, reader := openSSE(t, ts, "tenant1")
+	defer resp.Body.Close()
+	waitSSESubscribers(r, 1)
+
+	r.receiptWritten(map[string]interface{}{"_id": "req1", "namespace": "tenant2"})
+	r.receiptWritten(map[string]interface{}{"_id": "req2", "namespace": "tenant1"})
+
+	assert.Equal("id: req2\n", readSSELine(t, reader))
+	r.close()
+}
+
+func TestRepliesSSEKeepAlive(t *testing.T) {
+	assert := assert.New(t)
+	r, _, ts := newReceiptsTestServer()
+	defer ts.Close()
+	r.sse.keepAlive = 1 * time.Millisecond
+
+	resp, reader := openSSE(t, ts, "")
+	assert.Equal(": keepalive\n", readSSELine(t, reader))
+
+	// The subscription is removed when the client goes away
+	resp.Body.Close()
+	waitSSESubscribers(r, 0)
+	r.close()
+}
+
+func TestRepliesSSEUnauthorized(t *testing.T) {
+	auth.RegisterSecurityModule(&authtest.TestSecurityModule{})
+
+	assert := assert.New(t)
+	_, _, ts := newReceiptsTestServer()
+	defer ts.Close()
+
+	status, respJSON, httpErr := testGETObject(ts, "/replies/sse")
+	assert.NoError(httpErr)
+	assert.Equal(401, status)
+	assert.Equal("Unauthorized", respJSON["error"])
+
+	auth.RegisterSecurityModule(nil)
+}
+
+type noFlushResponseWriter struct {
+	http.ResponseWriter
+}
+
+func TestRepliesSSENoFlusher(t *testing.T) {
+	assert := assert.New(t)
+	r, _ := newReceiptsTestStore(nil)
+
+	res := httptest.NewRecorder()
+	r.getRepliesSSE(&noFlushResponseWriter{res}, httptest.NewRequest("GET", "/replies/sse", nil))
+	assert.Equal(500, res.Code)
+	assert.Regexp("FFEC100324", res.Body.String())
+}
+
+func TestReceiptFeedDropsForSlowClient(t *testing.T) {
+	assert := assert.New(t)
+
+	f := newReceiptFeed()
+	ch := f.subscribe()
+	for i := 0; i < sseSubscriberBuffer+1; i++ {
+		f.publish(map[string]interface{}{"_id": "req1"})
+	}
+	assert.Len(ch, sseSubscriberBuffer)
+	f.close()
+	f.close()
+}
@@ -19,9 +19,11 @@ import (
 	"net/http"
 	"regexp"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/hyperledger/firefly-ethconnect/internal/auth"
 	"github.com/hyperledger/firefly-ethconnect/internal/contractgateway"
 	"github.com/hyperledger/firefly-ethconnect/internal/errors"
@@ -37,9 +39,16 @@ const (
 	defaultRetryTimeout      = 120 * 1000
 	defaultRetryInitialDelay = 500
 	defaultMaxDocs           = 250
+	defaultPruneIntervalSec  = 60
 	backoffFactor            = 1.1
+	summaryWindowAll         = "all"
+	// summaryRouteID is matched in the handler for GET /replies/:id, as the router does not allow
+	// a static path segment alongside a parameter
+	summaryRouteID = "summary"
 )
 
+var defaultSummaryWindows = []string{"1h", "24h"}
+
 var uuidCharsVerifier, _ = regexp.Compile("^[0-9a-zA-Z-]+$")
 
 type receiptStore struct {
@@ -48,6 +57,16 @@ type receiptStore struct {
 	smartContractGW contractgateway.SmartContractGateway
 	reservedIDs     map[string]bool
 	reservationMux  sync.Mutex
+	exporters       *receiptExporters
+	archive         *receiptArchive
+	writeBehind     *receiptWriteBehind
+	sse             *receiptFeed
+	txRequests      *lru.Cache
+	correlationMux  sync.Mutex
+	pruneStop       chan struct{}
+	pruneDone       chan struct{}
+	metrics         *utils.StoreMetrics
+	processors      *replyProcessors
 }
 
 func newReceiptStore(conf *receipts.ReceiptStoreConf, persistence receipts.ReceiptStorePersistence, smartContractGW contractgateway.SmartContractGateway) *receiptStore {
@@ -60,18 +79,87 @@ func newReceiptStore(conf *receipts.ReceiptStoreConf, persistence receipts.Recei
 	if conf.MaxDocs <= 0 {
 		conf.MaxDocs = defaultMaxDocs
 	}
-	return &receiptStore{
+	if len(conf.SummaryWindows) == 0 {
+		conf.SummaryWindows = defaultSummaryWindows
+	}
+	txRequests, _ := lru.New(eventCorrelationCacheSize)
+	r := &receiptStore{
 		conf:            conf,
 		persistence:     persistence,
 		smartContractGW: smartContractGW,
 		reservedIDs:     make(map[string]bool),
+		sse:             newReceiptFeed(),
+		txRequests:      txRequests,
+		metrics:         utils.NewStoreMetrics("receipts"),
+	}
+	if persistence != nil && (conf.Retention.MaxAgeSec > 0 || conf.Retention.MaxCount > 0) {
+		if conf.Retention.PruneIntervalSec <= 0 {
+			conf.Retention.PruneIntervalSec = defaultPruneIntervalSec
+		}
+		r.pruneStop = make(chan struct{})
+		r.pruneDone = make(chan struct{})
+		go r.pruneLoop()
+	}
+	// The default chain cannot fail to build, and is replaced if a chain is configured
+	r.processors, _ = newReplyProcessors(nil, r)
+	return r
+}
+
+// pruneLoop applies the retention policy in the background, until the store is closed
+func (r *receiptStore) pruneLoop() {
+	defer close(r.pruneDone)
+	interval := time.Duration(r.conf.Retention.PruneIntervalSec) * time.Second
+	log.Infof("Receipt retention enabled maxAgeSec=%d maxCount=%d interval=%s", r.conf.Retention.MaxAgeSec, r.conf.Retention.MaxCount, interval)
+	for {
+		r.pruneReceipts(time.Now())
+		select {
+		case <-time.After(interval):
+		case <-r.pruneStop:
+			return
+		}
+	}
+}
+
+func (r *receiptStore) pruneReceipts(now time.Time) {
+	var olderThan int64
+	if r.conf.Retention.MaxAgeSec > 0 {
+		olderThan = now.Add(-time.Duration(r.conf.Retention.MaxAgeSec)*time.Second).UnixNano() / int64(time.Millisecond)
+	}
+	done := r.metrics.Start("PruneReceipts")
+	pruned, err := r.persistence.PruneReceipts(olderThan, r.conf.Retention.MaxCount)
+	done(err)
+	if err != nil {
+		log.Errorf("Failed to prune receipts: %s", err)
+	} else if pruned > 0 {
+		log.Infof("Pruned %d receipts", pruned)
+	}
+}
+
+// close stops background pruning and archiving, and flushes any receipts queued for writing or export
+func (r *receiptStore) close() {
+	r.writeBehind.close()
+	r.processors.close()
+	r.exporters.close()
+	r.archive.close()
+	r.sse.close()
+	r.metrics.Close()
+	if r.pruneStop != nil {
+		close(r.pruneStop)
+		<-r.pruneDone
+		r.pruneStop = nil
 	}
 }
 
 func (r *receiptStore) addRoutes(router *httprouter.Router) {
 	router.GET("/replies", r.getReplies)
 	router.GET("/replies/:id", r.getReply)
+	router.GET("/replies/:id/:batch", r.getArchivedReplies)
 	router.GET("/reply/:id", r.getReply)
+	router.HEAD("/replies", r.getReplies)
+	router.HEAD("/replies/:id", r.getReply)
+	router.HEAD("/reply/:id", r.getReply)
+	router.DELETE("/replies/:id", r.deleteReply)
+	router.POST("/replies/purge", r.purgeReplies)
 }
 
 func (r *receiptStore) extractHeaders(parsedMsg map[string]interface{}) map[string]interface{} {
@@ -88,7 +176,7 @@ func (r *receiptStore) reserveID(msgID string) (release func(), err error) {
 	r.reservationMux.Lock()
 	defer r.reservationMux.Unlock()
 
-	m, err := r.persistence.GetReceipt(msgID)
+	m, err := r.getReceipt(msgID)
 	if err != nil {
 		return nil, err
 	}
@@ -111,9 +199,56 @@ func (r *receiptStore) writeAccepted(msgID, msgAck string, msg map[string]interf
 	msg["pending"] = true
 	msg["msgAck"] = msgAck
 	msg["_id"] = msgID
+	if r.versioned() {
+		msg[receipts.ReceiptRevisionField] = 1
+	}
+	r.setNamespace(msg, r.extractHeaders(msg))
+	if method := requestMethodName(msg); method != "" {
+		msg["method"] = method
+	}
 	return r.writeReceipt(msgID, msg, false)
 }
 
+// requestMethodName returns the name of the contract method invoked by a request, which is
+// stored at the top level of the receipt so summaries can be grouped by it
+func requestMethodName(msg map[string]interface{}) string {
+	if name := utils.GetMapString(msg, "methodName"); name != "" {
+		return name
+	}
+	if method, ok := msg["method"].(map[string]interface{}); ok {
+		return utils.GetMapString(method, "name")
+	}
+	return ""
+}
+
+// carryMethodName copies the method name from the pending receipt of the request into its
+// reply, as replies do not include it. It is only looked up for stores that group by it.
+func (r *receiptStore) carryMethodName(requestID string, parsedMsg map[string]interface{}) {
+	if _, ok := receipts.AsGroupSummarizer(r.persistence); !ok {
+		return
+	}
+	existingReceipt, err := r.getReceipt(requestID)
+	if err != nil || existingReceipt == nil {
+		return
+	}
+	if method := utils.GetMapString(*existingReceipt, "method"); method != "" {
+		parsedMsg["method"] = method
+	}
+}
+
+// setNamespace copies the namespace of the request to the top level of the receipt, where
+// the persistence layers filter on it
+func (r *receiptStore) setNamespace(receipt, headers map[string]interface{}) {
+	if ns := utils.GetMapString(headers, "namespace"); ns != "" {
+		receipt["namespace"] = ns
+	}
+}
+
+// inNamespace checks a receipt is visible to a caller restricted to the namespace
+func (r *receiptStore) inNamespace(receipt map[string]interface{}, namespace string) bool {
+	return namespace == "" || utils.GetMapString(receipt, "namespace") == namespace
+}
+
 func (r *receiptStore) processReply(msgBytes []byte) {
 
 	// Parse the reply as JSON
@@ -138,17 +273,16 @@ func (r *receiptStore) processReply(msgBytes []byte) {
 	}
 	reqOffset := utils.GetMapString(headers, "reqOffset")
 	msgType := utils.GetMapString(headers, "type")
-	contractAddr := utils.GetMapString(parsedMsg, "contractAddress")
 	result := ""
 	switch msgType {
-	case messages.MsgTypeError:
+	case messages.MsgTypeError, messages.MsgTypeTransactionCancelled:
 		result = utils.GetMapString(parsedMsg, "errorMessage")
 	case messages.MsgTypeTransactionRedeliveryPrevented:
 		// If we receive this, then we need to make sure either:
 		// a) We have a good receipt in our DB already
 		// b) We swap the status into an error - as the application might have to check the transaction status themselves from the TX Hash
 		result = utils.GetMapString(parsedMsg, "transactionHash")
-		existingReceipt, err := r.persistence.GetReceipt(requestID)
+		existingReceipt, err := r.getReceipt(requestID)
 		if err == nil && existingReceipt != nil {
 			existingHeaders := r.extractHeaders(*existingReceipt)
 			msgType := utils.GetMapString(existingHeaders, "type")
@@ -167,28 +301,109 @@ func (r *receiptStore) processReply(msgBytes []byte) {
 	}
 	log.Infof("Received reply message. requestId='%s' reqOffset='%s' type='%s': %s", requestID, reqOffset, msgType, result)
 
-	if r.smartContractGW != nil && msgType == messages.MsgTypeTransactionSuccess && contractAddr != "" {
-		var receipt messages.TransactionReceipt
-		if err := json.Unmarshal(msgBytes, &receipt); err == nil {
-			if err = r.smartContractGW.PostDeploy(&receipt); err != nil {
-				log.Errorf("Failed to process receipt in smart contract gateway: %s", err)
-			}
-		} else {
-			log.Errorf("Failed to parse message as transaction receipt: %s", err)
-		}
-	}
-
 	parsedMsg["receivedAt"] = time.Now().UnixNano() / int64(time.Millisecond)
 	parsedMsg["_id"] = requestID
+	r.setNamespace(parsedMsg, headers)
+	r.carryMethodName(requestID, parsedMsg)
+	if msgType == messages.MsgTypeTransactionSuccess {
+		r.trackTransaction(requestID, parsedMsg)
+	}
+
+	r.processors.process(&processedReply{
+		requestID: requestID,
+		msgType:   msgType,
+		msgBytes:  msgBytes,
+		receipt:   parsedMsg,
+	})
 
-	// Insert the receipt into persistence - performs retry for errors, so will succeed or panic
-	if requestID != "" && r.persistence != nil {
-		_ = r.writeReceipt(requestID, parsedMsg, true /* overwrite, and succeed or panic */)
+}
+
+// afterStored calls the function once the receipts for replies already processed have been
+// stored. Without the write-behind queue they are written as each reply is processed.
+func (r *receiptStore) afterStored(fn func()) {
+	if r.writeBehind != nil {
+		r.writeBehind.afterWritten(fn)
+		return
 	}
+	fn()
+}
+
+// versioned is true when the persistence layer versions receipts, so replies can be written with
+// compare-and-set. Receipts are not versioned with the write-behind queue, as it replaces receipts
+// in batches.
+func (r *receiptStore) versioned() bool {
+	_, ok := receipts.AsRevisionWriter(r.persistence)
+	return ok && r.writeBehind == nil
+}
+
+// receiptAnnotations are fields added to a receipt by writers other than the reply processor,
+// which are kept when the reply replaces the pending receipt
+var receiptAnnotations = map[string]bool{
+	"eventDeliveries": true,
+	"confirmedEvents": true,
+}
 
+// writeReply replaces the receipt of a request with its reply, without losing any annotations
+// written concurrently. Retries for errors, so will succeed or panic.
+func (r *receiptStore) writeReply(requestID string, reply map[string]interface{}) {
+	var receipt *map[string]interface{}
+	_ = r.retryWrite(requestID, true, func() (err error) {
+		done := r.metrics.Start("UpdateReceipt")
+		receipt, err = receipts.UpdateReceiptFields(r.persistence, requestID, func(existing *map[string]interface{}) map[string]interface{} {
+			fields := make(map[string]interface{}, len(reply))
+			if existing != nil {
+				for k := range *existing {
+					if !receiptAnnotations[k] {
+						fields[k] = nil
+					}
+				}
+			}
+			for k, v := range reply {
+				fields[k] = v
+			}
+			return fields
+		})
+		done(err)
+		return err
+	})
+	log.Infof("%s: Inserted receipt into receipt store at revision %d", requestID, receipts.ReceiptRevision(receipt))
+	r.receiptWritten(*receipt)
+}
+
+// addDecodedEvents stores the logs of the receipt that could be decoded using the ABI of
+// a registered contract, as generic JSON so they are persisted consistently by every store
+func (r *receiptStore) addDecodedEvents(requestID string, parsedMsg map[string]interface{}, receipt *messages.TransactionReceipt) {
+	decoded := r.smartContractGW.DecodeReceiptEvents(receipt)
+	if len(decoded) == 0 {
+		return
+	}
+	var events []interface{}
+	b, _ := json.Marshal(decoded)
+	if err := json.Unmarshal(b, &events); err != nil {
+		log.Errorf("%s: Failed to serialize decoded events: %s", requestID, err)
+		return
+	}
+	parsedMsg["events"] = events
 }
 
 func (r *receiptStore) writeReceipt(requestID string, receipt map[string]interface{}, overwriteAndRetry bool) error {
+	err := r.retryWrite(requestID, overwriteAndRetry, func() error {
+		done := r.metrics.Start("AddReceipt")
+		err := r.persistence.AddReceipt(requestID, &receipt, overwriteAndRetry)
+		done(err)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	log.Infof("%s: Inserted receipt into receipt store", receipt["_id"])
+	r.receiptWritten(receipt)
+	return nil
+}
+
+// retryWrite returns the error from a single attempt if retry is false. Otherwise it retries
+// with backoff, and panics if the write has not succeeded by the retry timeout.
+func (r *receiptStore) retryWrite(requestID string, retry bool, write func() error) error {
 	startTime := time.Now()
 	delay := time.Duration(r.conf.RetryInitialDelayMS) * time.Millisecond
 	attempt := 0
@@ -201,13 +416,12 @@ func (r *receiptStore) writeReceipt(requestID string, receipt map[string]interfa
 			delay = time.Duration(float64(delay) * backoffFactor)
 		}
 		attempt++
-		err := r.persistence.AddReceipt(requestID, &receipt, overwriteAndRetry)
+		err := write()
 		if err == nil {
-			log.Infof("%s: Inserted receipt into receipt store", receipt["_id"])
-			break
+			return nil
 		}
 
-		if !overwriteAndRetry {
+		if !retry {
 			return err
 		}
 
@@ -215,14 +429,51 @@ func (r *receiptStore) writeReceipt(requestID string, receipt map[string]interfa
 
 		timeRetrying := time.Since(startTime)
 		if timeRetrying > retryTimeout {
-			log.Infof("%s: receipt: %+v", requestID, receipt)
 			log.Panicf("%s: Failed to insert into receipt store after %.2fs: %s", requestID, timeRetrying.Seconds(), err)
 		}
 	}
+}
+
+// receiptWritten passes a receipt that has been stored on to the exporters, and the SSE and WebSocket listeners
+func (r *receiptStore) receiptWritten(receipt map[string]interface{}) {
+	r.exporters.export(receipt)
+	r.sse.publish(receipt)
 	if r.smartContractGW != nil {
 		r.smartContractGW.SendReply(receipt)
 	}
-	return nil
+}
+
+// getReceipt looks up a receipt, including one for a reply that is queued for writing
+func (r *receiptStore) getReceipt(requestID string) (*map[string]interface{}, error) {
+	if r.writeBehind != nil {
+		if receipt := r.writeBehind.get(requestID); receipt != nil {
+			return &receipt, nil
+		}
+	}
+	done := r.metrics.Start("GetReceipt")
+	receipt, err := r.persistence.GetReceipt(requestID)
+	done(err)
+	return receipt, err
+}
+
+// replyHistory is used to resume a WebSocket reply stream, returning up to the query limit of
+// the most recent receipts since the supplied time, in the order they were received
+func (r *receiptStore) replyHistory(sinceEpochMS int64, from string) ([]map[string]interface{}, error) {
+	limit := r.conf.QueryLimit
+	if limit <= 0 {
+		limit = defaultReceiptLimit
+	}
+	done := r.metrics.Start("GetReceipts")
+	results, err := r.persistence.GetReceipts(0, limit, nil, sinceEpochMS, from, "", "")
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	history := make([]map[string]interface{}, len(*results))
+	for i, receipt := range *results {
+		history[len(history)-1-i] = receipt
+	}
+	return history, nil
 }
 
 func (r *receiptStore) marshalAndReply(res http.ResponseWriter, req *http.Request, result interface{}) {
@@ -321,8 +572,37 @@ func (r *receiptStore) getReplies(res http.ResponseWriter, req *http.Request, pa
 	to := req.FormValue("to")
 	start := req.FormValue("start")
 
+	search := &receipts.ReceiptSearch{
+		Text:            req.FormValue("q"),
+		ContractAddress: req.FormValue("contractAddress"),
+		Namespace:       auth.GetNamespace(req.Context()),
+	}
+
 	// Call the persistence tier - which must return an empty array when no results (not an error)
-	results, err := r.persistence.GetReceipts(skip, limit, ids, sinceEpochMS, from, to, start)
+	var results *[]map[string]interface{}
+	if search.Text != "" || search.ContractAddress != "" {
+		searcher, ok := receipts.AsSearcher(r.persistence)
+		if !ok {
+			sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreSearchNotSupported), 400)
+			return
+		}
+		done := r.metrics.Start("SearchReceipts")
+		results, err = searcher.SearchReceipts(skip, limit, ids, sinceEpochMS, from, to, search)
+		done(err)
+	} else if search.Namespace != "" {
+		nsReader, ok := receipts.AsNamespaceReader(r.persistence)
+		if !ok {
+			sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreNamespaceNotSupported), 405)
+			return
+		}
+		done := r.metrics.Start("GetNamespaceReceipts")
+		results, err = nsReader.GetNamespaceReceipts(search.Namespace, skip, limit, ids, sinceEpochMS, from, to, start)
+		done(err)
+	} else {
+		done := r.metrics.Start("GetReceipts")
+		results, err = r.persistence.GetReceipts(skip, limit, ids, sinceEpochMS, from, to, start)
+		done(err)
+	}
 	if err != nil {
 		log.Errorf("Error querying replies: %s", err)
 		sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreFailedQuery, err), 500)
@@ -345,13 +625,26 @@ func (r *receiptStore) getReply(res http.ResponseWriter, req *http.Request, para
 	}
 
 	requestID := params.ByName("id")
+	switch requestID {
+	case summaryRouteID:
+		r.getRepliesSummary(res, req)
+		return
+	case sseRouteID:
+		if req.Method == http.MethodHead {
+			res.Header().Set("Content-Type", "text/event-stream")
+			res.WriteHeader(200)
+			return
+		}
+		r.getRepliesSSE(res, req)
+		return
+	}
 	// Call the persistence tier - which must return an empty array when no results (not an error)
-	result, err := r.persistence.GetReceipt(requestID)
+	result, err := r.getReceipt(requestID)
 	if err != nil {
 		log.Errorf("Error querying reply: %s", err)
 		sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreFailedQuerySingle, err), 500)
 		return
-	} else if result == nil {
+	} else if result == nil || !r.inNamespace(*result, auth.GetNamespace(req.Context())) {
 		sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreFailedNotFound), 404)
 		log.Infof("Reply not found")
 		return
@@ -359,3 +652,289 @@ func (r *receiptStore) getReply(res http.ResponseWriter, req *http.Request, para
 	log.Infof("Reply found")
 	r.marshalAndReply(res, req, result)
 }
+
+// receiptSummaryWindow is the summary for one window, with the start of the window
+type receiptSummaryWindow struct {
+	Since string `json:"since,omitempty"`
+	*receipts.ReceiptSummary
+	Groups []*receipts.ReceiptGroup `json:"groups,omitempty"`
+}
+
+var summaryGroupByFields = []string{receipts.ReceiptGroupByStatus, receipts.ReceiptGroupByMethod}
+
+// parseSummaryGroupBy validates the comma separated groupBy query parameter
+func parseSummaryGroupBy(values []string) ([]string, error) {
+	var groupBy []string
+	for _, v := range values {
+		for _, field := range strings.Split(v, ",") {
+			field = strings.TrimSpace(field)
+			valid := false
+			for _, f := range summaryGroupByFields {
+				valid = valid || field == f
+			}
+			if !valid {
+				return nil, errors.Errorf(errors.ReceiptStoreInvalidSummaryGroup, field, strings.Join(summaryGroupByFields, ", "))
+			}
+			groupBy = append(groupBy, field)
+		}
+	}
+	return groupBy, nil
+}
+
+// getRepliesSummary handles a HTTP request for the counts of replies by outcome, over each
+// of the requested (or configured) time windows. With groupBy, each window also contains the
+// counts and latency percentiles grouped by status and/or method.
+func (r *receiptStore) getRepliesSummary(res http.ResponseWriter, req *http.Request) {
+	err := auth.AuthListAsyncReplies(req.Context())
+	if err != nil {
+		log.Errorf("Error querying replies summary: %s", err)
+		sendRESTError(res, req, errors.Errorf(errors.Unauthorized), 401)
+		return
+	}
+
+	if ns := auth.GetNamespace(req.Context()); ns != "" {
+		sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreNamespaceRestricted, ns), 403)
+		return
+	}
+
+	summarizer, ok := receipts.AsSummarizer(r.persistence)
+	if !ok {
+		sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreSummaryNotSupported), 405)
+		return
+	}
+
+	_ = req.ParseForm()
+	groupBy, err := parseSummaryGroupBy(req.Form["groupBy"])
+	if err != nil {
+		sendRESTError(res, req, err, 400)
+		return
+	}
+	var groupSummarizer receipts.ReceiptStoreGroupSummarizer
+	if len(groupBy) > 0 {
+		if groupSummarizer, ok = receipts.AsGroupSummarizer(r.persistence); !ok {
+			sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreGroupSummaryNotSupported), 405)
+			return
+		}
+	}
+
+	windows := r.conf.SummaryWindows
+	if requested := req.Form["window"]; len(requested) > 0 {
+		windows = nil
+		for _, w := range requested {
+			windows = append(windows, strings.Split(w, ",")...)
+		}
+	}
+
+	now := time.Now()
+	result := make(map[string]*receiptSummaryWindow, len(windows))
+	for _, w := range windows {
+		w = strings.TrimSpace(w)
+		var sinceEpochMS int64
+		window := &receiptSummaryWindow{}
+		if w != summaryWindowAll {
+			duration, err := time.ParseDuration(w)
+			if err != nil || duration <= 0 {
+				sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreInvalidSummaryWindow, w), 400)
+				return
+			}
+			since := now.Add(-duration)
+			sinceEpochMS = since.UnixNano() / int64(time.Millisecond)
+			window.Since = since.UTC().Format(time.RFC3339Nano)
+		}
+		done := r.metrics.Start("SummarizeReceipts")
+		window.ReceiptSummary, err = summarizer.SummarizeReceipts(sinceEpochMS)
+		done(err)
+		if err != nil {
+			log.Errorf("Error summarizing replies: %s", err)
+			sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreFailedSummary, err), 500)
+			return
+		}
+		if groupSummarizer != nil {
+			done := r.metrics.Start("SummarizeReceiptGroups")
+			window.Groups, err = groupSummarizer.SummarizeReceiptGroups(sinceEpochMS, groupBy)
+			done(err)
+			if err != nil {
+				log.Errorf("Error summarizing replies: %s", err)
+				sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreFailedSummary, err), 500)
+				return
+			}
+		}
+		result[w] = window
+	}
+	r.marshalAndReply(res, req, result)
+}
+
+// deleteReply handles a HTTP request to delete an individual reply
+func (r *receiptStore) deleteReply(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	err := auth.AuthDeleteAsyncReplies(req.Context())
+	if err != nil {
+		log.Errorf("Error deleting reply: %s", err)
+		sendRESTError(res, req, errors.Errorf(errors.Unauthorized), 401)
+		return
+	}
+
+	if r.persistence == nil {
+		sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreDisabled), 405)
+		return
+	}
+
+	requestID := params.ByName("id")
+	if ns := auth.GetNamespace(req.Context()); ns != "" {
+		existing, err := r.getReceipt(requestID)
+		if err != nil {
+			log.Errorf("Error deleting reply: %s", err)
+			sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreFailedQuerySingle, err), 500)
+			return
+		} else if existing == nil || !r.inNamespace(*existing, ns) {
+			sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreFailedNotFound), 404)
+			return
+		}
+	}
+	done := r.metrics.Start("DeleteReceipts")
+	deleted, err := r.persistence.DeleteReceipts([]string{requestID})
+	done(err)
+	if err != nil {
+		log.Errorf("Error deleting reply: %s", err)
+		sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreFailedDelete, err), 500)
+		return
+	} else if deleted == 0 {
+		sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreFailedNotFound), 404)
+		return
+	}
+	log.Infof("Reply %s deleted", requestID)
+	status := 204
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.WriteHeader(status)
+}
+
+// purgeReplies handles a HTTP request to delete replies received before a point in time,
+// and/or a list of replies by ID
+func (r *receiptStore) purgeReplies(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	err := auth.AuthDeleteAsyncReplies(req.Context())
+	if err != nil {
+		log.Errorf("Error purging replies: %s", err)
+		sendRESTError(res, req, errors.Errorf(errors.Unauthorized), 401)
+		return
+	}
+
+	if r.persistence == nil {
+		sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreDisabled), 405)
+		return
+	}
+
+	if ns := auth.GetNamespace(req.Context()); ns != "" {
+		sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreNamespaceRestricted, ns), 403)
+		return
+	}
+
+	body, err := utils.YAMLorJSONPayload(req)
+	if err != nil {
+		sendRESTError(res, req, err, 400)
+		return
+	}
+	var ids []string
+	if idList, ok := body["ids"].([]interface{}); ok {
+		for _, v := range idList {
+			id, ok := v.(string)
+			if !ok || !uuidCharsVerifier.MatchString(id) {
+				sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreInvalidRequestID), 400)
+				return
+			}
+			ids = append(ids, id)
+		}
+	}
+	// olderThan is a RFC3339 timestamp, or a number of milliseconds since the epoch
+	var olderThanEpochMS int64
+	switch olderThan := body["olderThan"].(type) {
+	case nil:
+	case float64:
+		olderThanEpochMS = int64(olderThan)
+	case string:
+		if isoTime, err := time.Parse(time.RFC3339Nano, olderThan); err == nil {
+			olderThanEpochMS = isoTime.UnixNano() / int64(time.Millisecond)
+		} else if olderThanEpochMS, err = strconv.ParseInt(olderThan, 10, 64); err != nil {
+			sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreInvalidRequestBadSince), 400)
+			return
+		}
+	default:
+		sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreInvalidRequestBadSince), 400)
+		return
+	}
+	if olderThanEpochMS <= 0 && len(ids) == 0 {
+		sendRESTError(res, req, errors.Errorf(errors.ReceiptStorePurgeInvalidRequest), 400)
+		return
+	}
+
+	deleted := 0
+	if len(ids) > 0 {
+		done := r.metrics.Start("DeleteReceipts")
+		deleted, err = r.persistence.DeleteReceipts(ids)
+		done(err)
+		if err != nil {
+			log.Errorf("Error purging replies: %s", err)
+			sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreFailedDelete, err), 500)
+			return
+		}
+	}
+	if olderThanEpochMS > 0 {
+		done := r.metrics.Start("PruneReceipts")
+		pruned, err := r.persistence.PruneReceipts(olderThanEpochMS, 0)
+		done(err)
+		if err != nil {
+			log.Errorf("Error purging replies: %s", err)
+			sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreFailedDelete, err), 500)
+			return
+		}
+		deleted += pruned
+	}
+	log.Infof("Purged %d replies", deleted)
+	r.marshalAndReply(res, req, map[string]int{"deleted": deleted})
+}
+
+// getArchivedReplies handles a HTTP request for a batch of receipts in the archive, at
+// /replies/archive/:batch (the router requires we share the :id wildcard with getReply)
+func (r *receiptStore) getArchivedReplies(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	if params.ByName("id") != "archive" {
+		sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreFailedNotFound), 404)
+		return
+	}
+
+	err := auth.AuthListAsyncReplies(req.Context())
+	if err != nil {
+		log.Errorf("Error querying archived replies: %s", err)
+		sendRESTError(res, req, errors.Errorf(errors.Unauthorized), 401)
+		return
+	}
+
+	if ns := auth.GetNamespace(req.Context()); ns != "" {
+		sendRESTError(res, req, errors.Errorf(errors.ReceiptStoreNamespaceRestricted, ns), 403)
+		return
+	}
+
+	if r.archive == nil {
+		sendRESTError(res, req, errors.Errorf(errors.ReceiptArchiveNotEnabled), 405)
+		return
+	}
+
+	batchID := params.ByName("batch")
+	if !archiveBatchVerifier.MatchString(batchID) {
+		sendRESTError(res, req, errors.Errorf(errors.ReceiptArchiveInvalidBatch, batchID), 400)
+		return
+	}
+	results, err := r.archive.readBatch(batchID)
+	if err != nil {
+		log.Errorf("Error reading archived replies: %s", err)
+		sendRESTError(res, req, errors.Errorf(errors.ReceiptArchiveReadFailed, batchID, err), 500)
+		return
+	} else if results == nil {
+		sendRESTError(res, req, errors.Errorf(errors.ReceiptArchiveBatchNotFound, batchID), 404)
+		return
+	}
+	r.marshalAndReply(res, req, results)
+}
@@ -17,10 +17,16 @@ package rest
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 
 	"net/http/httptest"
 
@@ -30,6 +36,7 @@ import (
 	"github.com/hyperledger/firefly-ethconnect/internal/messages"
 	"github.com/hyperledger/firefly-ethconnect/internal/receipts"
 	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	"github.com/hyperledger/firefly-ethconnect/mocks/receiptsmocks"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -54,6 +61,32 @@ func (m *mockReceiptErrs) AddReceipt(requestID string, receipt *map[string]inter
 	return m.addReceiptErr
 }
 
+func (m *mockReceiptErrs) DeleteReceipts(requestIDs []string) (int, error) {
+	return 0, m.addReceiptErr
+}
+
+func (m *mockReceiptErrs) PruneReceipts(olderThanEpochMS int64, maxCount int) (int, error) {
+	return 0, m.addReceiptErr
+}
+
+type mockReceiptSummarizer struct {
+	mockReceiptErrs
+	summaryErr error
+}
+
+func (m *mockReceiptSummarizer) SummarizeReceipts(sinceEpochMS int64) (*receipts.ReceiptSummary, error) {
+	return nil, m.summaryErr
+}
+
+type mockReceiptGroupSummarizer struct {
+	mockReceiptSummarizer
+	groupErr error
+}
+
+func (m *mockReceiptGroupSummarizer) SummarizeReceiptGroups(sinceEpochMS int64, groupBy []string) ([]*receipts.ReceiptGroup, error) {
+	return nil, m.groupErr
+}
+
 func newReceiptsErrTestServer(err error) (*receiptStore, *httptest.Server) {
 	r := newReceiptStore(&receipts.ReceiptStoreConf{
 		RetryTimeoutMS:      1,
@@ -110,6 +143,37 @@ func TestReplyProcessorWithValidReply(t *testing.T) {
 
 }
 
+func TestReplyProcessorReplacesPendingReceipt(t *testing.T) {
+	assert := assert.New(t)
+
+	r, p := newReceiptsTestStore(nil)
+
+	reqID := utils.UUIDv4()
+	err := r.writeAccepted(reqID, "ack", map[string]interface{}{"from": "0x12345"})
+	assert.NoError(err)
+	pending, _ := p.GetReceipt(reqID)
+	assert.Equal(int64(1), receipts.ReceiptRevision(pending))
+
+	// Events are delivered before the reply arrives
+	r.addEventDelivery(reqID, "es-1", 1)
+
+	replyMsg := &messages.TransactionReceipt{}
+	replyMsg.Headers.MsgType = messages.MsgTypeTransactionSuccess
+	replyMsg.Headers.ReqID = reqID
+	txHash := ethbind.API.HexToHash("0x02587104e9879911bea3d5bf6ccd7e1a6cb9a03145b8a1141804cebd6aa67c5c")
+	replyMsg.TransactionHash = &txHash
+	replyMsgBytes, _ := json.Marshal(&replyMsg)
+	r.processReply(replyMsgBytes)
+
+	receipt, _ := p.GetReceipt(reqID)
+	assert.Equal(int64(3), receipts.ReceiptRevision(receipt))
+	assert.Nil((*receipt)["pending"])
+	assert.Nil((*receipt)["from"])
+	assert.Equal(true, (*receipt)["confirmedEvents"])
+	assert.Len((*receipt)["eventDeliveries"], 1)
+	assert.Equal(txHash.String(), (*receipt)["transactionHash"])
+}
+
 func TestReplyProcessorWithContractGWSuccess(t *testing.T) {
 	assert := assert.New(t)
 
@@ -162,6 +226,70 @@ func TestReplyProcessorWithContractGWFailure(t *testing.T) {
 
 }
 
+func TestReplyProcessorWithDecodedEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	r, p := newReceiptsTestStore(nil)
+	addr := ethbind.API.HexToAddress("0x0123456789AbcdeF0123456789abCdef0123456")
+	r.smartContractGW = &mockContractGW{
+		receiptEvents: []*messages.ReceiptEvent{
+			{
+				Address:   &addr,
+				Event:     "Changed",
+				Signature: "Changed(uint256)",
+				LogIndex:  "0",
+				Data:      map[string]interface{}{"value": "42"},
+			},
+		},
+	}
+
+	replyMsg := &messages.TransactionReceipt{}
+	replyMsg.Headers.MsgType = messages.MsgTypeTransactionSuccess
+	replyMsg.Headers.ReqID = utils.UUIDv4()
+	txHash := ethbind.API.HexToHash("0x02587104e9879911bea3d5bf6ccd7e1a6cb9a03145b8a1141804cebd6aa67c5c")
+	replyMsg.TransactionHash = &txHash
+	replyMsg.To = &addr
+	replyMsg.Logs = []*messages.TransactionLog{
+		{Address: &addr, Data: "0x", LogIndexStr: "0"},
+	}
+	replyMsgBytes, _ := json.Marshal(&replyMsg)
+
+	r.processReply(replyMsgBytes)
+
+	assert.Equal(1, p.Receipts().Len())
+	front := *p.Receipts().Front().Value.(*map[string]interface{})
+	events := front["events"].([]interface{})
+	assert.Len(events, 1)
+	event := events[0].(map[string]interface{})
+	assert.Equal("Changed", event["event"])
+	assert.Equal("Changed(uint256)", event["signature"])
+	assert.Equal("42", event["data"].(map[string]interface{})["value"])
+	assert.Len(front["logs"], 1)
+}
+
+func TestReplyProcessorWithNoDecodedEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	r, p := newReceiptsTestStore(nil)
+	r.smartContractGW = &mockContractGW{}
+
+	addr := ethbind.API.HexToAddress("0x0123456789AbcdeF0123456789abCdef0123456")
+	replyMsg := &messages.TransactionReceipt{}
+	replyMsg.Headers.MsgType = messages.MsgTypeTransactionSuccess
+	replyMsg.Headers.ReqID = utils.UUIDv4()
+	txHash := ethbind.API.HexToHash("0x02587104e9879911bea3d5bf6ccd7e1a6cb9a03145b8a1141804cebd6aa67c5c")
+	replyMsg.TransactionHash = &txHash
+	replyMsg.Logs = []*messages.TransactionLog{
+		{Address: &addr, Data: "0x", LogIndexStr: "0"},
+	}
+	replyMsgBytes, _ := json.Marshal(&replyMsg)
+
+	r.processReply(replyMsgBytes)
+
+	front := *p.Receipts().Front().Value.(*map[string]interface{})
+	assert.Nil(front["events"])
+}
+
 func TestReplyProcessorWithContractGWBadReceipt(t *testing.T) {
 	r, _ := newReceiptsTestStore(nil)
 	r.smartContractGW = &mockContractGW{}
@@ -469,6 +597,25 @@ func TestGetRepliesCustomFiltersTS(t *testing.T) {
 	assert.Regexp("Error querying replies.*Memory receipts do not support filtering", resObj["error"])
 }
 
+func TestGetRepliesFilterIDsMemory(t *testing.T) {
+	assert := assert.New(t)
+	_, p, ts := newReceiptsTestServer()
+	defer ts.Close()
+
+	for i := 0; i < 5; i++ {
+		fakeReply := make(map[string]interface{})
+		fakeReply["_id"] = fmt.Sprintf("reply%d", i)
+		p.AddReceipt(fakeReply["_id"].(string), &fakeReply, true)
+	}
+
+	status, respArr, httpErr := testGETArray(ts, "/replies?id=reply1&id=reply3")
+	assert.NoError(httpErr)
+	assert.Equal(200, status)
+	assert.Equal(2, len(respArr))
+	assert.Equal("reply3", respArr[0]["_id"])
+	assert.Equal("reply1", respArr[1]["_id"])
+}
+
 func TestGetRepliesBadSinceTS(t *testing.T) {
 	assert := assert.New(t)
 	_, p, ts := newReceiptsTestServer()
@@ -652,3 +799,618 @@ func TestReserveIDFail(t *testing.T) {
 	_, err := r.reserveID("12345")
 	assert.Regexp("pop", err)
 }
+
+func TestGetRepliesSearchNotSupported(t *testing.T) {
+	assert := assert.New(t)
+	_, _, ts := newReceiptsTestServer()
+	defer ts.Close()
+
+	status, resObj, httpErr := testGETObject(ts, "/replies?q=revert")
+	assert.NoError(httpErr)
+	assert.Equal(400, status)
+	assert.Regexp("does not support the .q. or .contractAddress. query parameters", resObj["error"])
+}
+
+func TestGetRepliesSearchNotSupportedEncrypted(t *testing.T) {
+	assert := assert.New(t)
+	r, p, ts := newReceiptsTestServer()
+	defer ts.Close()
+	os.Setenv("TEST_RECEIPT_KEK", "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	defer os.Unsetenv("TEST_RECEIPT_KEK")
+	var err error
+	r.persistence, err = receipts.NewEncryptedReceipts(&receipts.ReceiptEncryptionConf{KeyEnv: "TEST_RECEIPT_KEK"}, p)
+	assert.NoError(err)
+
+	// The encryption layer only searches if the store beneath it does
+	status, resObj, httpErr := testGETObject(ts, "/replies?q=revert")
+	assert.NoError(httpErr)
+	assert.Equal(400, status)
+	assert.Regexp("does not support the .q. or .contractAddress. query parameters", resObj["error"])
+}
+
+type mockSearchPersistence struct {
+	*receipts.MemoryReceipts
+	search *receipts.ReceiptSearch
+}
+
+func (m *mockSearchPersistence) SearchReceipts(skip, limit int, ids []string, sinceEpochMS int64, from, to string, search *receipts.ReceiptSearch) (*[]map[string]interface{}, error) {
+	m.search = search
+	return m.GetReceipts(skip, limit, ids, sinceEpochMS, from, to, "")
+}
+
+func TestGetRepliesSearch(t *testing.T) {
+	assert := assert.New(t)
+	r, p, ts := newReceiptsTestServer()
+	defer ts.Close()
+	sp := &mockSearchPersistence{MemoryReceipts: p}
+	r.persistence = sp
+
+	fakeReply := map[string]interface{}{"_id": "reply1"}
+	p.AddReceipt("reply1", &fakeReply, true)
+
+	status, respArr, httpErr := testGETArray(ts, "/replies?q=revert&contractAddress=0x123")
+	assert.NoError(httpErr)
+	assert.Equal(200, status)
+	assert.Len(respArr, 1)
+	assert.Equal("revert", sp.search.Text)
+	assert.Equal("0x123", sp.search.ContractAddress)
+}
+
+func TestReceiptStorePruneLoop(t *testing.T) {
+	assert := assert.New(t)
+
+	p := &receiptsmocks.ReceiptStorePersistence{}
+	pruned := make(chan bool, 1)
+	minCutoff := time.Now().Add(-1*time.Hour).UnixNano() / int64(time.Millisecond)
+	p.On("PruneReceipts", mock.MatchedBy(func(olderThan int64) bool {
+		return olderThan >= minCutoff
+	}), 10).Return(3, nil).Run(func(args mock.Arguments) {
+		select {
+		case pruned <- true:
+		default:
+		}
+	})
+
+	r := newReceiptStore(&receipts.ReceiptStoreConf{
+		Retention: receipts.ReceiptRetentionConf{
+			MaxAgeSec: 3600,
+			MaxCount:  10,
+		},
+	}, p, nil)
+	<-pruned
+	r.close()
+	r.close()
+	assert.Equal(defaultPruneIntervalSec, r.conf.Retention.PruneIntervalSec)
+	p.AssertExpectations(t)
+}
+
+func TestReceiptStorePruneCountOnly(t *testing.T) {
+	p := &receiptsmocks.ReceiptStorePersistence{}
+	p.On("PruneReceipts", int64(0), 5).Return(0, fmt.Errorf("pop")).Once()
+	p.On("PruneReceipts", int64(0), 5).Return(0, nil).Once()
+
+	r := newReceiptStore(&receipts.ReceiptStoreConf{}, p, nil)
+	r.conf.Retention.MaxCount = 5
+	r.pruneReceipts(time.Now())
+	r.pruneReceipts(time.Now())
+	r.close()
+	p.AssertExpectations(t)
+}
+
+func TestReceiptStoreReplyHistoryOldestFirst(t *testing.T) {
+	assert := assert.New(t)
+
+	p := &receiptsmocks.ReceiptStorePersistence{}
+	p.On("GetReceipts", 0, defaultReceiptLimit, []string(nil), int64(12345), "0xaaaa", "", "").Return(&[]map[string]interface{}{
+		{"_id": "newest"},
+		{"_id": "oldest"},
+	}, nil).Once()
+	p.On("GetReceipts", 0, defaultReceiptLimit, []string(nil), int64(0), "", "", "").Return(nil, fmt.Errorf("pop")).Once()
+
+	r := newReceiptStore(&receipts.ReceiptStoreConf{}, p, nil)
+	history, err := r.replyHistory(12345, "0xaaaa")
+	assert.NoError(err)
+	assert.Equal("oldest", history[0]["_id"])
+	assert.Equal("newest", history[1]["_id"])
+
+	_, err = r.replyHistory(0, "")
+	assert.Regexp("pop", err)
+	p.AssertExpectations(t)
+}
+
+func testDELETEStatus(ts *httptest.Server, path string) (int, error) {
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+path, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	return resp.StatusCode, nil
+}
+
+func testPOSTObject(ts *httptest.Server, path, body string) (int, map[string]interface{}, error) {
+	resp, httpErr := http.Post(ts.URL+path, "application/json", strings.NewReader(body))
+	if httpErr != nil {
+		return 0, nil, httpErr
+	}
+	respJSON := make(map[string]interface{})
+	err := json.NewDecoder(resp.Body).Decode(&respJSON)
+	return resp.StatusCode, respJSON, err
+}
+
+func TestDeleteReply(t *testing.T) {
+	assert := assert.New(t)
+	_, p, ts := newReceiptsTestServer()
+	defer ts.Close()
+
+	fakeReply := map[string]interface{}{"_id": "ABCDEFG"}
+	p.AddReceipt("ABCDEFG", &fakeReply, false)
+
+	status, err := testDELETEStatus(ts, "/replies/ABCDEFG")
+	assert.NoError(err)
+	assert.Equal(204, status)
+
+	status, _, _ = testGETObject(ts, "/replies/ABCDEFG")
+	assert.Equal(404, status)
+
+	status, err = testDELETEStatus(ts, "/replies/ABCDEFG")
+	assert.NoError(err)
+	assert.Equal(404, status)
+}
+
+func TestDeleteReplyErrors(t *testing.T) {
+	assert := assert.New(t)
+	r, ts := newReceiptsErrTestServer(fmt.Errorf("pop"))
+	defer ts.Close()
+
+	status, err := testDELETEStatus(ts, "/replies/ABCDEFG")
+	assert.NoError(err)
+	assert.Equal(500, status)
+
+	r.persistence = nil
+	status, err = testDELETEStatus(ts, "/replies/ABCDEFG")
+	assert.NoError(err)
+	assert.Equal(405, status)
+}
+
+func TestPurgeReplies(t *testing.T) {
+	assert := assert.New(t)
+	_, p, ts := newReceiptsTestServer()
+	defer ts.Close()
+
+	for i, receivedAt := range []int64{1000, 2000, 3000, 4000} {
+		id := fmt.Sprintf("r%d", i)
+		fakeReply := map[string]interface{}{"_id": id, "receivedAt": receivedAt}
+		p.AddReceipt(id, &fakeReply, false)
+	}
+
+	status, respJSON, err := testPOSTObject(ts, "/replies/purge", `{"olderThan":2500,"ids":["r3"]}`)
+	assert.NoError(err)
+	assert.Equal(200, status)
+	assert.Equal(float64(3), respJSON["deleted"])
+	assert.Equal(1, p.Receipts().Len())
+
+	status, respJSON, err = testPOSTObject(ts, "/replies/purge", `{"olderThan":"1970-01-01T00:00:03.5Z"}`)
+	assert.NoError(err)
+	assert.Equal(200, status)
+	assert.Equal(float64(1), respJSON["deleted"])
+	assert.Equal(0, p.Receipts().Len())
+
+	status, respJSON, err = testPOSTObject(ts, "/replies/purge", `{"olderThan":"5000"}`)
+	assert.NoError(err)
+	assert.Equal(200, status)
+	assert.Equal(float64(0), respJSON["deleted"])
+}
+
+func TestPurgeRepliesBadRequests(t *testing.T) {
+	assert := assert.New(t)
+	r, _, ts := newReceiptsTestServer()
+	defer ts.Close()
+
+	status, respJSON, _ := testPOSTObject(ts, "/replies/purge", `{}`)
+	assert.Equal(400, status)
+	assert.Equal("A purge must specify 'olderThan' or a list of 'ids'", respJSON["error"])
+
+	status, _, _ = testPOSTObject(ts, "/replies/purge", `{"ids":["bad/id"]}`)
+	assert.Equal(400, status)
+	status, _, _ = testPOSTObject(ts, "/replies/purge", `{"ids":[12345]}`)
+	assert.Equal(400, status)
+	status, _, _ = testPOSTObject(ts, "/replies/purge", `{"olderThan":"yesterday"}`)
+	assert.Equal(400, status)
+	status, _, _ = testPOSTObject(ts, "/replies/purge", `{"olderThan":true}`)
+	assert.Equal(400, status)
+	status, _, _ = testPOSTObject(ts, "/replies/purge", `!!! not json or yaml`)
+	assert.Equal(400, status)
+
+	r.persistence = nil
+	status, _, _ = testPOSTObject(ts, "/replies/purge", `{"ids":["r1"]}`)
+	assert.Equal(405, status)
+}
+
+func TestPurgeRepliesErrors(t *testing.T) {
+	assert := assert.New(t)
+	_, ts := newReceiptsErrTestServer(fmt.Errorf("pop"))
+	defer ts.Close()
+
+	status, respJSON, _ := testPOSTObject(ts, "/replies/purge", `{"ids":["r1"]}`)
+	assert.Equal(500, status)
+	assert.Equal("Error deleting replies: pop", respJSON["error"])
+
+	status, _, _ = testPOSTObject(ts, "/replies/purge", `{"olderThan":1000}`)
+	assert.Equal(500, status)
+}
+
+func TestDeleteRepliesUnauthorized(t *testing.T) {
+	auth.RegisterSecurityModule(&authtest.TestSecurityModule{})
+
+	assert := assert.New(t)
+	_, _, ts := newReceiptsTestServer()
+	defer ts.Close()
+
+	status, err := testDELETEStatus(ts, "/replies/12345")
+	assert.NoError(err)
+	assert.Equal(401, status)
+
+	status, respJSON, _ := testPOSTObject(ts, "/replies/purge", `{"ids":["12345"]}`)
+	assert.Equal(401, status)
+	assert.Equal("Unauthorized", respJSON["error"])
+
+	auth.RegisterSecurityModule(nil)
+}
+
+func TestGetRepliesSummary(t *testing.T) {
+	assert := assert.New(t)
+	_, p, ts := newReceiptsTestServer()
+	defer ts.Close()
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	for i, msgType := range []string{messages.MsgTypeTransactionSuccess, messages.MsgTypeTransactionFailure, messages.MsgTypeError} {
+		// The first receipt is two hours old
+		receivedAt := now
+		if i == 0 {
+			receivedAt = now - 2*60*60*1000
+		}
+		receipt := map[string]interface{}{
+			"_id":        utils.UUIDv4(),
+			"receivedAt": receivedAt,
+			"headers":    map[string]interface{}{"type": msgType},
+		}
+		p.AddReceipt(receipt["_id"].(string), &receipt, false)
+	}
+
+	status, respJSON, err := testGETObject(ts, "/replies/summary")
+	assert.NoError(err)
+	assert.Equal(200, status)
+	assert.Len(respJSON, 2)
+	hour := respJSON["1h"].(map[string]interface{})
+	assert.Equal(float64(2), hour["total"])
+	assert.Equal(float64(0), hour["success"])
+	assert.Equal(float64(1), hour["failure"])
+	assert.Equal(float64(1), hour["error"])
+	assert.NotEmpty(hour["since"])
+	day := respJSON["24h"].(map[string]interface{})
+	assert.Equal(float64(3), day["total"])
+	assert.Equal(float64(1), day["success"])
+
+	status, respJSON, err = testGETObject(ts, "/replies/summary?window=15m,all&window=3h")
+	assert.NoError(err)
+	assert.Equal(200, status)
+	assert.Len(respJSON, 3)
+	all := respJSON["all"].(map[string]interface{})
+	assert.Equal(float64(3), all["total"])
+	assert.Nil(all["since"])
+	assert.Equal(float64(3), respJSON["3h"].(map[string]interface{})["total"])
+}
+
+func TestGetRepliesSummaryBadWindow(t *testing.T) {
+	assert := assert.New(t)
+	_, _, ts := newReceiptsTestServer()
+	defer ts.Close()
+
+	status, respJSON, err := testGETObject(ts, "/replies/summary?window=1d")
+	assert.NoError(err)
+	assert.Equal(400, status)
+	assert.Regexp("Invalid summary window '1d'", respJSON["error"])
+
+	status, _, _ = testGETObject(ts, "/replies/summary?window=-1h")
+	assert.Equal(400, status)
+}
+
+func TestGetRepliesSummaryNotSupported(t *testing.T) {
+	assert := assert.New(t)
+	_, ts := newReceiptsErrTestServer(nil)
+	defer ts.Close()
+
+	status, respJSON, err := testGETObject(ts, "/replies/summary")
+	assert.NoError(err)
+	assert.Equal(405, status)
+	assert.Equal("The configured receipt store does not support summaries", respJSON["error"])
+}
+
+func TestGetRepliesSummaryFailure(t *testing.T) {
+	assert := assert.New(t)
+	r := newReceiptStore(&receipts.ReceiptStoreConf{}, &mockReceiptSummarizer{summaryErr: fmt.Errorf("pop")}, nil)
+	router := &httprouter.Router{}
+	r.addRoutes(router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	status, respJSON, err := testGETObject(ts, "/replies/summary")
+	assert.NoError(err)
+	assert.Equal(500, status)
+	assert.Equal("Error summarizing receipts: pop", respJSON["error"])
+}
+
+func TestGetRepliesSummaryGroupBy(t *testing.T) {
+	assert := assert.New(t)
+	r, _, ts := newReceiptsTestServer()
+	defer ts.Close()
+
+	for i, method := range []string{"set", "get", "set"} {
+		reqID := fmt.Sprintf("req%d", i)
+		msg := map[string]interface{}{"headers": map[string]interface{}{"id": reqID}}
+		if method == "get" {
+			msg["method"] = map[string]interface{}{"name": method}
+		} else {
+			msg["methodName"] = method
+		}
+		assert.NoError(r.writeAccepted(reqID, "ack", msg))
+		if i == 2 {
+			// Left pending
+			break
+		}
+		replyMsg := &messages.TransactionReceipt{}
+		replyMsg.Headers.MsgType = messages.MsgTypeTransactionSuccess
+		replyMsg.Headers.ReqID = reqID
+		replyMsg.Headers.Elapsed = float64(i + 1)
+		txHash := ethbind.API.HexToHash("0x02587104e9879911bea3d5bf6ccd7e1a6cb9a03145b8a1141804cebd6aa67c5c")
+		replyMsg.TransactionHash = &txHash
+		replyMsgBytes, _ := json.Marshal(&replyMsg)
+		r.processReply(replyMsgBytes)
+	}
+
+	status, respJSON, err := testGETObject(ts, "/replies/summary?window=all&groupBy=status,method")
+	assert.NoError(err)
+	assert.Equal(200, status)
+	all := respJSON["all"].(map[string]interface{})
+	assert.Equal(float64(3), all["total"])
+	assert.Equal([]interface{}{
+		map[string]interface{}{"status": "pending", "method": "set", "count": float64(1)},
+		map[string]interface{}{"status": "success", "method": "get", "count": float64(1),
+			"latency": map[string]interface{}{"p50": float64(2), "p90": float64(2), "p99": float64(2)}},
+		map[string]interface{}{"status": "success", "method": "set", "count": float64(1),
+			"latency": map[string]interface{}{"p50": float64(1), "p90": float64(1), "p99": float64(1)}},
+	}, all["groups"])
+
+	// Groups are omitted unless requested
+	status, respJSON, err = testGETObject(ts, "/replies/summary?window=all")
+	assert.NoError(err)
+	assert.Equal(200, status)
+	assert.Nil(respJSON["all"].(map[string]interface{})["groups"])
+}
+
+func TestGetRepliesSummaryBadGroupBy(t *testing.T) {
+	assert := assert.New(t)
+	_, _, ts := newReceiptsTestServer()
+	defer ts.Close()
+
+	status, respJSON, err := testGETObject(ts, "/replies/summary?groupBy=status,from")
+	assert.NoError(err)
+	assert.Equal(400, status)
+	assert.Regexp("Invalid summary groupBy 'from'", respJSON["error"])
+}
+
+func TestGetRepliesSummaryGroupByNotSupported(t *testing.T) {
+	assert := assert.New(t)
+	r := newReceiptStore(&receipts.ReceiptStoreConf{}, &mockReceiptSummarizer{}, nil)
+	router := &httprouter.Router{}
+	r.addRoutes(router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	status, respJSON, err := testGETObject(ts, "/replies/summary?groupBy=method")
+	assert.NoError(err)
+	assert.Equal(405, status)
+	assert.Equal("The configured receipt store does not support grouped summaries", respJSON["error"])
+}
+
+func TestGetRepliesSummaryGroupByFailure(t *testing.T) {
+	assert := assert.New(t)
+	r := newReceiptStore(&receipts.ReceiptStoreConf{}, &mockReceiptGroupSummarizer{groupErr: fmt.Errorf("pop")}, nil)
+	router := &httprouter.Router{}
+	r.addRoutes(router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	status, respJSON, err := testGETObject(ts, "/replies/summary?groupBy=method")
+	assert.NoError(err)
+	assert.Equal(500, status)
+	assert.Equal("Error summarizing receipts: pop", respJSON["error"])
+}
+
+func TestGetRepliesSummaryUnauthorized(t *testing.T) {
+	auth.RegisterSecurityModule(&authtest.TestSecurityModule{})
+
+	assert := assert.New(t)
+	_, _, ts := newReceiptsTestServer()
+	defer ts.Close()
+
+	status, respJSON, _ := testGETObject(ts, "/replies/summary")
+	assert.Equal(401, status)
+	assert.Equal("Unauthorized", respJSON["error"])
+
+	auth.RegisterSecurityModule(nil)
+}
+
+func testNamespaceRequest(ts *httptest.Server, method, path, namespace string, body io.Reader) (int, []byte, error) {
+	req, _ := http.NewRequest(method, ts.URL+path, body)
+	req.Header.Set("x-firefly-namespace", namespace)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, b, err
+}
+
+func newNamespacedReceiptsTestServer() (*receiptStore, *receipts.MemoryReceipts, *httptest.Server) {
+	r, p := newReceiptsTestStore(nil)
+	router := &httprouter.Router{}
+	r.addRoutes(router)
+	return r, p, httptest.NewServer((&RESTGateway{}).newAccessTokenContextHandler(router))
+}
+
+func TestReplyProcessorStampsNamespace(t *testing.T) {
+	assert := assert.New(t)
+
+	r, p := newReceiptsTestStore(nil)
+
+	replyMsg := &messages.TransactionReceipt{}
+	replyMsg.Headers.MsgType = messages.MsgTypeTransactionSuccess
+	replyMsg.Headers.ID = utils.UUIDv4()
+	replyMsg.Headers.ReqID = utils.UUIDv4()
+	replyMsg.Headers.Namespace = "tenant1"
+	txHash := ethbind.API.HexToHash("0x02587104e9879911bea3d5bf6ccd7e1a6cb9a03145b8a1141804cebd6aa67c5c")
+	replyMsg.TransactionHash = &txHash
+	replyMsgBytes, _ := json.Marshal(&replyMsg)
+
+	r.processReply(replyMsgBytes)
+
+	receipt, err := p.GetReceipt(replyMsg.Headers.ReqID)
+	assert.NoError(err)
+	assert.Equal("tenant1", (*receipt)["namespace"])
+}
+
+func TestRepliesNamespaced(t *testing.T) {
+	assert := assert.New(t)
+	_, p, ts := newNamespacedReceiptsTestServer()
+	defer ts.Close()
+
+	for _, fakeReply := range []map[string]interface{}{
+		{"_id": "reply1", "namespace": "tenant1"},
+		{"_id": "reply2", "namespace": "tenant2"},
+		{"_id": "reply3"},
+	} {
+		fakeReply := fakeReply
+		p.AddReceipt(fakeReply["_id"].(string), &fakeReply, true)
+	}
+
+	status, body, err := testNamespaceRequest(ts, "GET", "/replies", "tenant1", nil)
+	assert.NoError(err)
+	assert.Equal(200, status)
+	var results []map[string]interface{}
+	assert.NoError(json.Unmarshal(body, &results))
+	assert.Len(results, 1)
+	assert.Equal("reply1", results[0]["_id"])
+
+	status, _, err = testNamespaceRequest(ts, "GET", "/replies", "", nil)
+	assert.NoError(err)
+	assert.Equal(200, status)
+
+	status, _, err = testNamespaceRequest(ts, "GET", "/reply/reply1", "tenant1", nil)
+	assert.NoError(err)
+	assert.Equal(200, status)
+	status, _, err = testNamespaceRequest(ts, "GET", "/reply/reply2", "tenant1", nil)
+	assert.NoError(err)
+	assert.Equal(404, status)
+	status, _, err = testNamespaceRequest(ts, "GET", "/reply/reply3", "tenant1", nil)
+	assert.NoError(err)
+	assert.Equal(404, status)
+
+	status, _, err = testNamespaceRequest(ts, "DELETE", "/replies/reply2", "tenant1", nil)
+	assert.NoError(err)
+	assert.Equal(404, status)
+	status, _, err = testNamespaceRequest(ts, "DELETE", "/replies/reply1", "tenant1", nil)
+	assert.NoError(err)
+	assert.Equal(204, status)
+	assert.Equal(2, p.Receipts().Len())
+
+	status, body, err = testNamespaceRequest(ts, "GET", "/replies/summary", "tenant1", nil)
+	assert.NoError(err)
+	assert.Equal(403, status)
+	assert.Regexp("tenant1.*FFEC100322", string(body))
+	status, _, err = testNamespaceRequest(ts, "POST", "/replies/purge", "tenant1", strings.NewReader(`{"olderThan":1}`))
+	assert.NoError(err)
+	assert.Equal(403, status)
+	status, _, err = testNamespaceRequest(ts, "GET", "/replies/archive/batch1", "tenant1", nil)
+	assert.NoError(err)
+	assert.Equal(403, status)
+}
+
+func TestRepliesNamespaceNotSupported(t *testing.T) {
+	assert := assert.New(t)
+	r, p, ts := newNamespacedReceiptsTestServer()
+	defer ts.Close()
+	r.persistence = struct {
+		receipts.ReceiptStorePersistence
+	}{p}
+
+	status, body, err := testNamespaceRequest(ts, "GET", "/replies", "tenant1", nil)
+	assert.NoError(err)
+	assert.Equal(405, status)
+	assert.Regexp("FFEC100321", string(body))
+
+	// Searches are filtered by the persistence layer
+	sp := &mockSearchPersistence{MemoryReceipts: p}
+	r.persistence = sp
+	status, _, err = testNamespaceRequest(ts, "GET", "/replies?q=revert", "tenant1", nil)
+	assert.NoError(err)
+	assert.Equal(200, status)
+	assert.Equal("tenant1", sp.search.Namespace)
+}
+
+func TestDeleteReplyNamespacedError(t *testing.T) {
+	assert := assert.New(t)
+	r, _, ts := newNamespacedReceiptsTestServer()
+	defer ts.Close()
+	r.persistence = &mockReceiptErrs{getReceiptErr: fmt.Errorf("pop")}
+
+	status, _, err := testNamespaceRequest(ts, "DELETE", "/replies/reply1", "tenant1", nil)
+	assert.NoError(err)
+	assert.Equal(500, status)
+}
+
+func TestHeadReply(t *testing.T) {
+	assert := assert.New(t)
+	_, p, ts := newReceiptsTestServer()
+	defer ts.Close()
+
+	fakeReply := map[string]interface{}{"_id": "ABCDEFG"}
+	p.AddReceipt("ABCDEFG", &fakeReply, true)
+	res, err := http.Head(ts.URL + "/replies/ABCDEFG")
+	assert.NoError(err)
+	assert.Equal(200, res.StatusCode)
+	assert.Equal("application/json", res.Header.Get("Content-Type"))
+
+	res, err = http.Head(ts.URL + "/reply/BCDEFG")
+	assert.NoError(err)
+	assert.Equal(404, res.StatusCode)
+
+	res, err = http.Head(ts.URL + "/replies")
+	assert.NoError(err)
+	assert.Equal(200, res.StatusCode)
+
+	res, err = http.Head(ts.URL + "/replies/sse")
+	assert.NoError(err)
+	assert.Equal(200, res.StatusCode)
+	assert.Equal("text/event-stream", res.Header.Get("Content-Type"))
+}
+
+func TestReceiptStoreMetricsCountErrors(t *testing.T) {
+	assert := assert.New(t)
+	r := newReceiptStore(&receipts.ReceiptStoreConf{}, &mockReceiptErrs{
+		getReceiptErr:  fmt.Errorf("pop"),
+		getReceiptsErr: fmt.Errorf("bang"),
+	}, nil)
+	defer r.close()
+
+	_, err := r.getReceipt("req1")
+	assert.Regexp("pop", err)
+	_, err = r.replyHistory(0, "")
+	assert.Regexp("bang", err)
+
+	stats := r.metrics.Stats()
+	assert.Equal(int64(1), stats["GetReceipt"].Errors)
+	assert.Equal(1.0, stats["GetReceipt"].ErrorRate)
+	assert.Equal("bang", stats["GetReceipts"].LastError)
+}
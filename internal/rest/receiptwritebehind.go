@@ -0,0 +1,224 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/receipts"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWriteBehindMaxBatchSize = 100
+	defaultWriteBehindLingerMS     = 50
+	defaultWriteBehindQueueSize    = 1000
+)
+
+// ReceiptWriteBehindConf configures batching of the receipts written for replies. Replies are
+// queued and written in batches of up to maxBatchSize, waiting at most lingerMS for a batch to
+// fill. A batch that cannot be written within the retry timeout of the receipt store causes a
+// panic, in the same way as an individual write. Replies consumed from Kafka are only acked once
+// the batch holding their receipt has been written, so they are redelivered after a panic.
+type ReceiptWriteBehindConf struct {
+	Enabled      bool `json:"enabled"`
+	MaxBatchSize int  `json:"maxBatchSize,omitempty"`
+	LingerMS     int  `json:"lingerMS,omitempty"`
+	QueueSize    int  `json:"queueSize,omitempty"`
+}
+
+type queuedReceipt struct {
+	requestID string
+	receipt   map[string]interface{}
+	onWritten func()
+}
+
+// receiptWriteBehind holds the receipts that have been queued but not yet written, so that
+// lookups by ID see them before they reach the persistence layer
+type receiptWriteBehind struct {
+	conf     *ReceiptWriteBehindConf
+	r        *receiptStore
+	batcher  receipts.ReceiptStoreBatchWriter
+	queue    chan *queuedReceipt
+	closeMux sync.RWMutex
+	closed   bool
+	mux      sync.Mutex
+	pending  map[string]*queuedReceipt
+	done     chan struct{}
+}
+
+func newReceiptWriteBehind(conf *ReceiptWriteBehindConf, r *receiptStore) *receiptWriteBehind {
+	if conf.MaxBatchSize <= 0 {
+		conf.MaxBatchSize = defaultWriteBehindMaxBatchSize
+	}
+	if conf.LingerMS <= 0 {
+		conf.LingerMS = defaultWriteBehindLingerMS
+	}
+	if conf.QueueSize <= 0 {
+		conf.QueueSize = defaultWriteBehindQueueSize
+	}
+	wb := &receiptWriteBehind{
+		conf:    conf,
+		r:       r,
+		queue:   make(chan *queuedReceipt, conf.QueueSize),
+		pending: make(map[string]*queuedReceipt),
+		done:    make(chan struct{}),
+	}
+	wb.batcher, _ = r.persistence.(receipts.ReceiptStoreBatchWriter)
+	log.Infof("Receipt write-behind enabled maxBatchSize=%d lingerMS=%d batchWriter=%t", conf.MaxBatchSize, conf.LingerMS, wb.batcher != nil)
+	go wb.run()
+	return wb
+}
+
+// write queues the receipt, blocking if the queue is full. Once closed, receipts are
+// written directly.
+func (wb *receiptWriteBehind) write(requestID string, receipt map[string]interface{}) {
+	wb.closeMux.RLock()
+	defer wb.closeMux.RUnlock()
+	if wb.closed {
+		_ = wb.r.writeReceipt(requestID, receipt, true)
+		return
+	}
+	qr := &queuedReceipt{requestID: requestID, receipt: receipt}
+	wb.mux.Lock()
+	wb.pending[requestID] = qr
+	wb.mux.Unlock()
+	wb.queue <- qr
+}
+
+// afterWritten calls the function once every receipt queued before it has been written.
+// Once closed, receipts are written directly, so it is called immediately.
+func (wb *receiptWriteBehind) afterWritten(fn func()) {
+	wb.closeMux.RLock()
+	defer wb.closeMux.RUnlock()
+	if wb.closed {
+		fn()
+		return
+	}
+	wb.queue <- &queuedReceipt{onWritten: fn}
+}
+
+// get returns the most recently queued receipt for the ID, if it has not been written yet
+func (wb *receiptWriteBehind) get(requestID string) map[string]interface{} {
+	wb.mux.Lock()
+	defer wb.mux.Unlock()
+	if qr, ok := wb.pending[requestID]; ok {
+		return qr.receipt
+	}
+	return nil
+}
+
+// close writes any queued receipts, then stops the background writer
+func (wb *receiptWriteBehind) close() {
+	if wb == nil {
+		return
+	}
+	wb.closeMux.Lock()
+	defer wb.closeMux.Unlock()
+	if wb.closed {
+		return
+	}
+	wb.closed = true
+	close(wb.queue)
+	<-wb.done
+}
+
+func (wb *receiptWriteBehind) run() {
+	defer close(wb.done)
+	linger := time.Duration(wb.conf.LingerMS) * time.Millisecond
+	var batch []*queuedReceipt
+	var callbacks []func()
+	var timer <-chan time.Time
+	for {
+		select {
+		case qr, ok := <-wb.queue:
+			if !ok {
+				if len(batch) > 0 {
+					wb.flush(batch)
+				}
+				runCallbacks(callbacks)
+				return
+			}
+			if qr.onWritten != nil {
+				if len(batch) == 0 {
+					qr.onWritten()
+				} else {
+					callbacks = append(callbacks, qr.onWritten)
+				}
+				continue
+			}
+			batch = append(batch, qr)
+			if len(batch) == 1 {
+				timer = time.After(linger)
+			}
+			if len(batch) < wb.conf.MaxBatchSize {
+				continue
+			}
+		case <-timer:
+		}
+		wb.flush(batch)
+		runCallbacks(callbacks)
+		batch = nil
+		callbacks = nil
+		timer = nil
+	}
+}
+
+func runCallbacks(callbacks []func()) {
+	for _, fn := range callbacks {
+		fn()
+	}
+}
+
+// flush writes the batch, retrying until it succeeds or the retry timeout is reached
+func (wb *receiptWriteBehind) flush(batch []*queuedReceipt) {
+	written := 0
+	wb.r.retryWrite(batch[0].requestID, true, func() error {
+		if wb.batcher != nil {
+			docs := make([]map[string]interface{}, len(batch))
+			for i, qr := range batch {
+				docs[i] = qr.receipt
+			}
+			done := wb.r.metrics.Start("AddReceipts")
+			err := wb.batcher.AddReceipts(docs)
+			done(err)
+			return err
+		}
+		// Continue from the first receipt that failed on the previous attempt
+		for ; written < len(batch); written++ {
+			qr := batch[written]
+			done := wb.r.metrics.Start("AddReceipt")
+			err := wb.r.persistence.AddReceipt(qr.requestID, &qr.receipt, true)
+			done(err)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	log.Infof("Inserted batch of %d receipts into receipt store", len(batch))
+
+	wb.mux.Lock()
+	for _, qr := range batch {
+		if wb.pending[qr.requestID] == qr {
+			delete(wb.pending, qr.requestID)
+		}
+	}
+	wb.mux.Unlock()
+	for _, qr := range batch {
+		wb.r.receiptWritten(qr.receipt)
+	}
+}
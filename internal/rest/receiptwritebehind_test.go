@@ -0,0 +1,264 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/receipts"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockBatchPersistence struct {
+	*receipts.MemoryReceipts
+	mux        sync.Mutex
+	batchSizes []int
+	batchErrs  []error
+}
+
+func (m *mockBatchPersistence) AddReceipts(batch []map[string]interface{}) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if len(m.batchErrs) > 0 {
+		err := m.batchErrs[0]
+		m.batchErrs = m.batchErrs[1:]
+		return err
+	}
+	m.batchSizes = append(m.batchSizes, len(batch))
+	for _, receipt := range batch {
+		receipt := receipt
+		_ = m.MemoryReceipts.AddReceipt(receipt["_id"].(string), &receipt, true)
+	}
+	return nil
+}
+
+func (m *mockBatchPersistence) sizes() []int {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return append([]int{}, m.batchSizes...)
+}
+
+type mockFlakyPersistence struct {
+	*receipts.MemoryReceipts
+	failures int
+	attempts []string
+}
+
+func (m *mockFlakyPersistence) AddReceipt(requestID string, receipt *map[string]interface{}, overwrite bool) error {
+	m.attempts = append(m.attempts, requestID)
+	if m.failures > 0 {
+		m.failures--
+		return fmt.Errorf("pop")
+	}
+	return m.MemoryReceipts.AddReceipt(requestID, receipt, overwrite)
+}
+
+func testWriteBehindReply(reqID, msgType string) []byte {
+	replyMsg := &messages.TransactionReceipt{}
+	replyMsg.Headers.MsgType = msgType
+	replyMsg.Headers.ID = utils.UUIDv4()
+	replyMsg.Headers.ReqID = reqID
+	txHash := ethbind.API.HexToHash("0x02587104e9879911bea3d5bf6ccd7e1a6cb9a03145b8a1141804cebd6aa67c5c")
+	replyMsg.TransactionHash = &txHash
+	b, _ := json.Marshal(&replyMsg)
+	return b
+}
+
+func TestWriteBehindBatchesReplies(t *testing.T) {
+	assert := assert.New(t)
+
+	conf := &receipts.ReceiptStoreConf{MaxDocs: 50}
+	p := &mockBatchPersistence{MemoryReceipts: receipts.NewMemoryReceipts(conf)}
+	var replies []interface{}
+	var repliesMux sync.Mutex
+	r := newReceiptStore(conf, p, &mockContractGW{replyCallback: func(message interface{}) {
+		repliesMux.Lock()
+		defer repliesMux.Unlock()
+		replies = append(replies, message)
+	}})
+	r.writeBehind = newReceiptWriteBehind(&ReceiptWriteBehindConf{
+		Enabled:      true,
+		MaxBatchSize: 2,
+		LingerMS:     60000,
+	}, r)
+
+	r.processReply(testWriteBehindReply("req1", messages.MsgTypeTransactionSuccess))
+	// Visible while queued
+	receipt, err := r.getReceipt("req1")
+	assert.NoError(err)
+	assert.Equal("req1", (*receipt)["_id"])
+	_, err = r.reserveID("req1")
+	assert.Regexp("FFEC100", err)
+
+	// The second reply fills the batch
+	r.processReply(testWriteBehindReply("req2", messages.MsgTypeTransactionFailure))
+	for len(p.sizes()) == 0 {
+		time.Sleep(1 * time.Millisecond)
+	}
+	assert.Equal([]int{2}, p.sizes())
+
+	// Closing flushes the partial batch
+	r.processReply(testWriteBehindReply("req3", messages.MsgTypeTransactionSuccess))
+	r.close()
+	assert.Equal([]int{2, 1}, p.sizes())
+	assert.Nil(r.writeBehind.get("req3"))
+	stored, err := p.GetReceipt("req3")
+	assert.NoError(err)
+	assert.NotNil(stored)
+	repliesMux.Lock()
+	assert.Len(replies, 3)
+	repliesMux.Unlock()
+
+	// Once closed, replies are written directly
+	r.processReply(testWriteBehindReply("req4", messages.MsgTypeTransactionSuccess))
+	stored, err = p.GetReceipt("req4")
+	assert.NoError(err)
+	assert.NotNil(stored)
+	assert.Equal([]int{2, 1}, p.sizes())
+	r.writeBehind.close()
+}
+
+func TestWriteBehindAcksAfterBatchWritten(t *testing.T) {
+	assert := assert.New(t)
+
+	conf := &receipts.ReceiptStoreConf{MaxDocs: 50}
+	p := &mockBatchPersistence{MemoryReceipts: receipts.NewMemoryReceipts(conf)}
+	r := newReceiptStore(conf, p, nil)
+	r.writeBehind = newReceiptWriteBehind(&ReceiptWriteBehindConf{
+		Enabled:      true,
+		MaxBatchSize: 2,
+		LingerMS:     60000,
+	}, r)
+
+	// Nothing is queued, so the ack is immediate
+	acked := make(chan string, 3)
+	r.afterStored(func() { acked <- "none" })
+	assert.Equal("none", <-acked)
+
+	// Not acked until the batch holding the receipt is written
+	r.processReply(testWriteBehindReply("req1", messages.MsgTypeTransactionSuccess))
+	r.afterStored(func() { acked <- "req1" })
+	time.Sleep(10 * time.Millisecond)
+	assert.Empty(acked)
+	assert.Empty(p.sizes())
+
+	r.processReply(testWriteBehindReply("req2", messages.MsgTypeTransactionSuccess))
+	r.afterStored(func() { acked <- "req2" })
+	assert.Equal("req1", <-acked)
+	assert.Equal("req2", <-acked)
+	assert.Equal([]int{2}, p.sizes())
+
+	// Closing flushes the partial batch before acking
+	r.processReply(testWriteBehindReply("req3", messages.MsgTypeTransactionSuccess))
+	r.afterStored(func() { acked <- "req3" })
+	r.close()
+	assert.Equal("req3", <-acked)
+	assert.Equal([]int{2, 1}, p.sizes())
+
+	// Once closed, acks are immediate
+	r.afterStored(func() { acked <- "closed" })
+	assert.Equal("closed", <-acked)
+}
+
+func TestWriteBehindRedeliveryKeepsQueuedSuccess(t *testing.T) {
+	assert := assert.New(t)
+
+	conf := &receipts.ReceiptStoreConf{MaxDocs: 50}
+	p := &mockBatchPersistence{MemoryReceipts: receipts.NewMemoryReceipts(conf)}
+	r := newReceiptStore(conf, p, nil)
+	r.writeBehind = newReceiptWriteBehind(&ReceiptWriteBehindConf{Enabled: true, LingerMS: 60000}, r)
+
+	r.processReply(testWriteBehindReply("req1", messages.MsgTypeTransactionSuccess))
+	r.processReply(testWriteBehindReply("req1", messages.MsgTypeTransactionRedeliveryPrevented))
+	r.close()
+
+	assert.Equal([]int{1}, p.sizes())
+	stored, err := p.GetReceipt("req1")
+	assert.NoError(err)
+	assert.Equal(messages.MsgTypeTransactionSuccess, (*stored)["headers"].(map[string]interface{})["type"])
+}
+
+func TestWriteBehindRetriesBatch(t *testing.T) {
+	assert := assert.New(t)
+
+	conf := &receipts.ReceiptStoreConf{
+		MaxDocs:             50,
+		RetryInitialDelayMS: 1,
+		RetryTimeoutMS:      60000,
+	}
+	p := &mockBatchPersistence{
+		MemoryReceipts: receipts.NewMemoryReceipts(conf),
+		batchErrs:      []error{fmt.Errorf("pop"), fmt.Errorf("pop")},
+	}
+	r := newReceiptStore(conf, p, nil)
+	wb := &receiptWriteBehind{r: r, batcher: p, pending: map[string]*queuedReceipt{}}
+
+	wb.flush([]*queuedReceipt{
+		{requestID: "req1", receipt: map[string]interface{}{"_id": "req1"}},
+		{requestID: "req2", receipt: map[string]interface{}{"_id": "req2"}},
+	})
+	assert.Equal([]int{2}, p.sizes())
+	assert.Equal(2, p.Receipts().Len())
+}
+
+func TestWriteBehindFallbackResumesAfterFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	conf := &receipts.ReceiptStoreConf{
+		MaxDocs:             50,
+		RetryInitialDelayMS: 1,
+		RetryTimeoutMS:      60000,
+	}
+	p := &mockFlakyPersistence{MemoryReceipts: receipts.NewMemoryReceipts(conf)}
+	r := newReceiptStore(conf, p, nil)
+	wb := newReceiptWriteBehind(&ReceiptWriteBehindConf{Enabled: true}, r)
+	defer wb.close()
+	assert.Nil(wb.batcher)
+	p.failures = 1
+
+	batch := []*queuedReceipt{
+		{requestID: "req1", receipt: map[string]interface{}{"_id": "req1"}},
+		{requestID: "req2", receipt: map[string]interface{}{"_id": "req2"}},
+	}
+	wb.flush(batch)
+	assert.Equal([]string{"req1", "req1", "req2"}, p.attempts)
+	assert.Equal(2, p.Receipts().Len())
+}
+
+func TestWriteBehindFlushPanicsAfterRetryTimeout(t *testing.T) {
+	conf := &receipts.ReceiptStoreConf{
+		RetryInitialDelayMS: 1,
+		RetryTimeoutMS:      1,
+	}
+	p := &mockBatchPersistence{
+		MemoryReceipts: receipts.NewMemoryReceipts(conf),
+		batchErrs:      []error{fmt.Errorf("pop"), fmt.Errorf("pop"), fmt.Errorf("pop"), fmt.Errorf("pop")},
+	}
+	r := newReceiptStore(conf, p, nil)
+	wb := &receiptWriteBehind{r: r, batcher: p, pending: map[string]*queuedReceipt{}}
+
+	assert.Panics(t, func() {
+		wb.flush([]*queuedReceipt{
+			{requestID: "req1", receipt: map[string]interface{}{"_id": "req1"}},
+		})
+	})
+}
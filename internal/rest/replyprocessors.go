@@ -0,0 +1,337 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ReplyProcessorTypePostDeploy registers deployed contracts with the gateway, and decodes the events in receipts
+	ReplyProcessorTypePostDeploy = "postDeploy"
+	// ReplyProcessorTypePersistence writes the receipt to the receipt store
+	ReplyProcessorTypePersistence = "persistence"
+	// ReplyProcessorTypeMetrics counts replies by type, for the status endpoint
+	ReplyProcessorTypeMetrics = "metrics"
+	// ReplyProcessorTypeHTTP posts the receipt to a URL, and waits for it to be accepted
+	ReplyProcessorTypeHTTP = "http"
+
+	defaultReplyProcessorHTTPTimeoutMS = 10000
+)
+
+// ReplyProcessorConf configures one processor in the chain each reply passes through, in order.
+// The processor is only invoked for the listed reply types, or for every reply if none are listed.
+type ReplyProcessorConf struct {
+	Name       string                 `json:"name"`
+	Type       string                 `json:"type"`
+	ReplyTypes []string               `json:"replyTypes,omitempty"`
+	HTTP       ReplyProcessorHTTPConf `json:"http,omitempty"`
+}
+
+// ReplyProcessorHTTPConf configures a HTTP callout processor
+type ReplyProcessorHTTPConf struct {
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	TimeoutMS int               `json:"timeoutMS,omitempty"`
+	TLS       utils.TLSConfig   `json:"tls,omitempty"`
+}
+
+// ReplyTypeStats are the replies of one type received since startup, with the time each
+// request took from being received to its reply
+type ReplyTypeStats struct {
+	Count             int64   `json:"count"`
+	AverageElapsedSec float64 `json:"averageElapsedSec"`
+	MaxElapsedSec     float64 `json:"maxElapsedSec"`
+}
+
+// processedReply is a reply on its way through the chain. Each processor can add to the
+// receipt before it is passed to the next.
+type processedReply struct {
+	requestID string
+	msgType   string
+	msgBytes  []byte
+	receipt   map[string]interface{}
+}
+
+type replyProcessor interface {
+	process(reply *processedReply) error
+	close()
+}
+
+type replyProcessorStep struct {
+	conf       *ReplyProcessorConf
+	replyTypes map[string]bool
+	processor  replyProcessor
+}
+
+type replyProcessors struct {
+	steps   []*replyProcessorStep
+	metrics *metricsReplyProcessor
+}
+
+// defaultReplyProcessors is the chain used unless one is configured
+func defaultReplyProcessors() []ReplyProcessorConf {
+	return []ReplyProcessorConf{
+		{Type: ReplyProcessorTypePostDeploy},
+		{Type: ReplyProcessorTypePersistence},
+	}
+}
+
+func newReplyProcessors(confs []ReplyProcessorConf, r *receiptStore) (*replyProcessors, error) {
+	if len(confs) == 0 {
+		confs = defaultReplyProcessors()
+	}
+	rp := &replyProcessors{}
+	builtins := make(map[string]bool)
+	for i := range confs {
+		conf := &confs[i]
+		if conf.Name == "" {
+			conf.Name = conf.Type
+			if conf.Type == ReplyProcessorTypeHTTP {
+				conf.Name = fmt.Sprintf("%s%d", conf.Type, i)
+			}
+		}
+		if conf.Type != ReplyProcessorTypeHTTP {
+			if builtins[conf.Type] {
+				rp.close()
+				return nil, errors.Errorf(errors.ReplyProcessorDuplicate, conf.Type)
+			}
+			builtins[conf.Type] = true
+		}
+		var processor replyProcessor
+		var err error
+		switch conf.Type {
+		case ReplyProcessorTypePostDeploy:
+			processor = &postDeployReplyProcessor{r: r}
+		case ReplyProcessorTypePersistence:
+			processor = &persistenceReplyProcessor{r: r}
+		case ReplyProcessorTypeMetrics:
+			rp.metrics = &metricsReplyProcessor{stats: make(map[string]*replyTypeTotals)}
+			processor = rp.metrics
+		case ReplyProcessorTypeHTTP:
+			processor, err = newHTTPReplyProcessor(conf)
+		default:
+			err = errors.Errorf(errors.ReplyProcessorUnknownType, conf.Type, conf.Name)
+		}
+		if err != nil {
+			rp.close()
+			return nil, err
+		}
+		step := &replyProcessorStep{conf: conf, processor: processor}
+		if len(conf.ReplyTypes) > 0 {
+			step.replyTypes = make(map[string]bool, len(conf.ReplyTypes))
+			for _, t := range conf.ReplyTypes {
+				step.replyTypes[t] = true
+			}
+		}
+		rp.steps = append(rp.steps, step)
+	}
+	if !builtins[ReplyProcessorTypePersistence] {
+		log.Warnf("The reply processor chain does not include '%s', so replies will not be stored", ReplyProcessorTypePersistence)
+	}
+	return rp, nil
+}
+
+// process passes the reply through each processor in turn. A processor that fails is logged,
+// and the reply continues down the chain, so a failing callout never stops a receipt being stored.
+func (rp *replyProcessors) process(reply *processedReply) {
+	for _, step := range rp.steps {
+		if step.replyTypes != nil && !step.replyTypes[reply.msgType] {
+			continue
+		}
+		if err := step.processor.process(reply); err != nil {
+			log.Errorf("%s: Reply processor '%s' failed: %s", reply.requestID, step.conf.Name, err)
+		}
+	}
+}
+
+// replyStats returns the counts of the metrics processor, if there is one in the chain
+func (rp *replyProcessors) replyStats() map[string]*ReplyTypeStats {
+	if rp == nil || rp.metrics == nil {
+		return nil
+	}
+	return rp.metrics.replyStats()
+}
+
+func (rp *replyProcessors) close() {
+	if rp == nil {
+		return
+	}
+	for _, step := range rp.steps {
+		step.processor.close()
+	}
+}
+
+type postDeployReplyProcessor struct {
+	r *receiptStore
+}
+
+// process registers a contract deployed by the transaction, then decodes the events in the
+// receipt. Decoding happens after registration, so events emitted by the constructor of a
+// newly registered contract are decoded too.
+func (p *postDeployReplyProcessor) process(reply *processedReply) error {
+	if p.r.smartContractGW == nil || reply.msgType != messages.MsgTypeTransactionSuccess {
+		return nil
+	}
+	var receipt messages.TransactionReceipt
+	if err := json.Unmarshal(reply.msgBytes, &receipt); err != nil {
+		return errors.Errorf(errors.ReplyProcessorInvalidReceipt, err)
+	}
+	var err error
+	if utils.GetMapString(reply.receipt, "contractAddress") != "" {
+		err = p.r.smartContractGW.PostDeploy(&receipt)
+	}
+	if len(receipt.Logs) > 0 {
+		p.r.addDecodedEvents(reply.requestID, reply.receipt, &receipt)
+	}
+	return err
+}
+
+func (p *postDeployReplyProcessor) close() {}
+
+type persistenceReplyProcessor struct {
+	r *receiptStore
+}
+
+// process inserts the receipt into persistence. It retries on errors, so will succeed or panic.
+func (p *persistenceReplyProcessor) process(reply *processedReply) error {
+	r := p.r
+	if r.persistence == nil {
+		return nil
+	}
+	if r.writeBehind != nil {
+		r.writeBehind.write(reply.requestID, reply.receipt)
+	} else if r.versioned() {
+		r.writeReply(reply.requestID, reply.receipt)
+	} else {
+		_ = r.writeReceipt(reply.requestID, reply.receipt, true /* overwrite, and succeed or panic */)
+	}
+	return nil
+}
+
+func (p *persistenceReplyProcessor) close() {}
+
+type replyTypeTotals struct {
+	count        int64
+	totalElapsed float64
+	maxElapsed   float64
+}
+
+type metricsReplyProcessor struct {
+	mux   sync.Mutex
+	stats map[string]*replyTypeTotals
+}
+
+func (p *metricsReplyProcessor) process(reply *processedReply) error {
+	var elapsed float64
+	if headers, ok := reply.receipt["headers"].(map[string]interface{}); ok {
+		elapsed, _ = headers["timeElapsed"].(float64)
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	totals := p.stats[reply.msgType]
+	if totals == nil {
+		totals = &replyTypeTotals{}
+		p.stats[reply.msgType] = totals
+	}
+	totals.count++
+	totals.totalElapsed += elapsed
+	if elapsed > totals.maxElapsed {
+		totals.maxElapsed = elapsed
+	}
+	return nil
+}
+
+func (p *metricsReplyProcessor) replyStats() map[string]*ReplyTypeStats {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	stats := make(map[string]*ReplyTypeStats, len(p.stats))
+	for msgType, totals := range p.stats {
+		stats[msgType] = &ReplyTypeStats{
+			Count:             totals.count,
+			AverageElapsedSec: totals.totalElapsed / float64(totals.count),
+			MaxElapsedSec:     totals.maxElapsed,
+		}
+	}
+	return stats
+}
+
+func (p *metricsReplyProcessor) close() {}
+
+type httpReplyProcessor struct {
+	conf   *ReplyProcessorConf
+	client *http.Client
+}
+
+func newHTTPReplyProcessor(conf *ReplyProcessorConf) (*httpReplyProcessor, error) {
+	if conf.HTTP.URL == "" {
+		return nil, errors.Errorf(errors.ReplyProcessorMissingConfig, conf.Name, "http.url")
+	}
+	tlsConfig, err := utils.CreateTLSConfiguration(&conf.HTTP.TLS)
+	if err != nil {
+		return nil, err
+	}
+	timeoutMS := conf.HTTP.TimeoutMS
+	if timeoutMS <= 0 {
+		timeoutMS = defaultReplyProcessorHTTPTimeoutMS
+	}
+	return &httpReplyProcessor{
+		conf: conf,
+		client: &http.Client{
+			Timeout:   time.Duration(timeoutMS) * time.Millisecond,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+// process posts the receipt as it stands at this point in the chain. The reply waits for the
+// callout, so the timeout bounds how long a slow endpoint can hold up each receipt.
+func (p *httpReplyProcessor) process(reply *processedReply) error {
+	body, err := json.Marshal(reply.receipt)
+	if err != nil {
+		return err
+	}
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, p.conf.HTTP.URL, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range p.conf.HTTP.Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = ioutil.ReadAll(res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.Errorf(errors.ReplyProcessorHTTPStatus, p.conf.Name, res.StatusCode, p.conf.HTTP.URL)
+	}
+	return nil
+}
+
+func (p *httpReplyProcessor) close() {
+	p.client.CloseIdleConnections()
+}
@@ -0,0 +1,209 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/ethbind"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func testReplyBytes(msgType string, elapsed float64) (string, []byte) {
+	replyMsg := &messages.TransactionReceipt{}
+	replyMsg.Headers.MsgType = msgType
+	replyMsg.Headers.ID = utils.UUIDv4()
+	replyMsg.Headers.ReqID = utils.UUIDv4()
+	replyMsg.Headers.Elapsed = elapsed
+	txHash := ethbind.API.HexToHash("0x02587104e9879911bea3d5bf6ccd7e1a6cb9a03145b8a1141804cebd6aa67c5c")
+	replyMsg.TransactionHash = &txHash
+	b, _ := json.Marshal(&replyMsg)
+	return replyMsg.Headers.ReqID, b
+}
+
+func TestReplyProcessorsDefaultChain(t *testing.T) {
+	assert := assert.New(t)
+
+	r, _ := newReceiptsTestStore(nil)
+	defer r.close()
+	assert.Len(r.processors.steps, 2)
+	assert.Equal(ReplyProcessorTypePostDeploy, r.processors.steps[0].conf.Name)
+	assert.Equal(ReplyProcessorTypePersistence, r.processors.steps[1].conf.Name)
+	assert.Nil(r.processors.replyStats())
+}
+
+func TestReplyProcessorsChainWithCallout(t *testing.T) {
+	assert := assert.New(t)
+
+	var mux sync.Mutex
+	var calledOut []map[string]interface{}
+	svr := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		assert.Equal("application/json", req.Header.Get("Content-Type"))
+		assert.Equal("Bearer abc", req.Header.Get("Authorization"))
+		var receipt map[string]interface{}
+		json.NewDecoder(req.Body).Decode(&receipt)
+		mux.Lock()
+		calledOut = append(calledOut, receipt)
+		mux.Unlock()
+		res.WriteHeader(204)
+	}))
+	defer svr.Close()
+
+	r, p := newReceiptsTestStore(nil)
+	defer r.close()
+	var err error
+	r.processors, err = newReplyProcessors([]ReplyProcessorConf{
+		{Type: ReplyProcessorTypeMetrics},
+		{Type: ReplyProcessorTypePersistence},
+		{
+			Name:       "ticketing",
+			Type:       ReplyProcessorTypeHTTP,
+			ReplyTypes: []string{messages.MsgTypeError, messages.MsgTypeTransactionFailure},
+			HTTP: ReplyProcessorHTTPConf{
+				URL:     svr.URL,
+				Headers: map[string]string{"Authorization": "Bearer abc"},
+			},
+		},
+	}, r)
+	assert.NoError(err)
+
+	_, b := testReplyBytes(messages.MsgTypeTransactionSuccess, 1.5)
+	r.processReply(b)
+	failedID, b := testReplyBytes(messages.MsgTypeTransactionFailure, 0.5)
+	r.processReply(b)
+	_, b = testReplyBytes(messages.MsgTypeTransactionSuccess, 2.5)
+	r.processReply(b)
+
+	assert.Equal(3, p.Receipts().Len())
+	assert.Len(calledOut, 1)
+	assert.Equal(failedID, calledOut[0]["_id"])
+
+	stats := r.processors.replyStats()
+	assert.Equal(int64(2), stats[messages.MsgTypeTransactionSuccess].Count)
+	assert.Equal(2.0, stats[messages.MsgTypeTransactionSuccess].AverageElapsedSec)
+	assert.Equal(2.5, stats[messages.MsgTypeTransactionSuccess].MaxElapsedSec)
+	assert.Equal(int64(1), stats[messages.MsgTypeTransactionFailure].Count)
+
+	g := &RESTGateway{receipts: r}
+	res := httptest.NewRecorder()
+	g.statusHandler(res, httptest.NewRequest("GET", "/status", nil), nil)
+	var status statusMsg
+	assert.NoError(json.NewDecoder(res.Body).Decode(&status))
+	assert.Equal(int64(2), status.Replies[messages.MsgTypeTransactionSuccess].Count)
+}
+
+func TestReplyProcessorsFailedCalloutContinuesChain(t *testing.T) {
+	assert := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(500)
+	}))
+	defer svr.Close()
+
+	r, p := newReceiptsTestStore(nil)
+	defer r.close()
+	var err error
+	r.processors, err = newReplyProcessors([]ReplyProcessorConf{
+		{Type: ReplyProcessorTypeHTTP, HTTP: ReplyProcessorHTTPConf{URL: svr.URL}},
+		{Type: ReplyProcessorTypeHTTP, HTTP: ReplyProcessorHTTPConf{URL: "http://localhost:0"}},
+		{Type: ReplyProcessorTypePersistence},
+	}, r)
+	assert.NoError(err)
+	assert.Equal("http0", r.processors.steps[0].conf.Name)
+
+	reply := &processedReply{requestID: "req1", receipt: map[string]interface{}{}}
+	err = r.processors.steps[0].processor.process(reply)
+	assert.Regexp("FFEC100469.*http0.*500", err)
+	err = r.processors.steps[1].processor.process(reply)
+	assert.Error(err)
+
+	_, b := testReplyBytes(messages.MsgTypeTransactionSuccess, 1)
+	r.processReply(b)
+	assert.Equal(1, p.Receipts().Len())
+}
+
+func TestReplyProcessorsWithoutPersistence(t *testing.T) {
+	assert := assert.New(t)
+
+	r, p := newReceiptsTestStore(nil)
+	defer r.close()
+	var err error
+	r.processors, err = newReplyProcessors([]ReplyProcessorConf{
+		{Type: ReplyProcessorTypePostDeploy},
+	}, r)
+	assert.NoError(err)
+
+	_, b := testReplyBytes(messages.MsgTypeTransactionSuccess, 1)
+	r.processReply(b)
+	assert.Equal(0, p.Receipts().Len())
+}
+
+func TestReplyProcessorsPostDeployFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	gw := &mockContractGW{postDeployErr: fmt.Errorf("pop")}
+	r, _ := newReceiptsTestStore(nil)
+	defer r.close()
+	r.smartContractGW = gw
+
+	p := &postDeployReplyProcessor{r: r}
+	err := p.process(&processedReply{
+		msgType:  messages.MsgTypeTransactionSuccess,
+		msgBytes: []byte(`{"contractAddress":"0x2b8c0ECc76d0759a8F50b2E14A6881367D805832"}`),
+		receipt:  map[string]interface{}{"contractAddress": "0x2b8c0ECc76d0759a8F50b2E14A6881367D805832"},
+	})
+	assert.EqualError(err, "pop")
+
+	err = p.process(&processedReply{
+		msgType:  messages.MsgTypeTransactionSuccess,
+		msgBytes: []byte(`{"blockNumber":false}`),
+	})
+	assert.Regexp("FFEC100470", err)
+}
+
+func TestReplyProcessorsBadConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	r, _ := newReceiptsTestStore(nil)
+	defer r.close()
+
+	_, err := newReplyProcessors([]ReplyProcessorConf{{Type: "ticketing"}}, r)
+	assert.Regexp("FFEC100466", err)
+
+	_, err = newReplyProcessors([]ReplyProcessorConf{{Type: ReplyProcessorTypeHTTP}}, r)
+	assert.Regexp("FFEC100467.*http.url", err)
+
+	_, err = newReplyProcessors([]ReplyProcessorConf{
+		{Type: ReplyProcessorTypePersistence},
+		{Type: ReplyProcessorTypeMetrics},
+		{Type: ReplyProcessorTypePersistence},
+	}, r)
+	assert.Regexp("FFEC100468", err)
+
+	_, err = newReplyProcessors([]ReplyProcessorConf{
+		{Type: ReplyProcessorTypeHTTP, HTTP: ReplyProcessorHTTPConf{
+			URL: "https://localhost",
+			TLS: utils.TLSConfig{Enabled: true, CACertsFile: "/does/not/exist"},
+		}},
+	}, r)
+	assert.Error(err)
+}
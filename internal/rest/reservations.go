@@ -0,0 +1,316 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/kvstore"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultReservationTTLSec = 24 * 60 * 60
+	defaultSweepIntervalSec  = 5 * 60
+)
+
+// IdempotencyConf configures persisted reservations for client supplied message IDs (fly-id),
+// so a retried submission is rejected rather than sent twice
+type IdempotencyConf struct {
+	Enabled           bool   `json:"enabled"`
+	Path              string `json:"path"`
+	ReservationTTLSec int64  `json:"reservationTTLSec,omitempty"`
+	SweepIntervalSec  int    `json:"sweepIntervalSec,omitempty"`
+}
+
+// Reservation records a client supplied ID. A reservation created through the API is
+// claimed by the first submission that uses the ID, after which the ID cannot be reused
+// until the reservation expires.
+type Reservation struct {
+	ID        string    `json:"id"`
+	Submitted bool      `json:"submitted"`
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires"`
+}
+
+type reservations struct {
+	conf     *IdempotencyConf
+	kv       kvstore.KVStore
+	receipts *receiptStore
+	mux      sync.Mutex
+	stop     chan struct{}
+	done     chan struct{}
+	now      func() time.Time
+}
+
+func newReservations(conf *IdempotencyConf, kv kvstore.KVStore, receipts *receiptStore) *reservations {
+	if conf.ReservationTTLSec <= 0 {
+		conf.ReservationTTLSec = defaultReservationTTLSec
+	}
+	if conf.SweepIntervalSec <= 0 {
+		conf.SweepIntervalSec = defaultSweepIntervalSec
+	}
+	return &reservations{
+		conf:     conf,
+		kv:       kv,
+		receipts: receipts,
+		now:      time.Now,
+	}
+}
+
+func (rs *reservations) addRoutes(router *httprouter.Router) {
+	router.POST("/reservations", rs.createReservation)
+	router.GET("/reservations/:id", rs.getReservation)
+	router.DELETE("/reservations/:id", rs.deleteReservation)
+}
+
+func (rs *reservations) start() {
+	rs.stop = make(chan struct{})
+	rs.done = make(chan struct{})
+	go rs.sweepLoop()
+}
+
+func (rs *reservations) close() {
+	if rs.stop != nil {
+		close(rs.stop)
+		<-rs.done
+		rs.stop = nil
+	}
+	rs.kv.Close()
+}
+
+// sweepLoop deletes expired reservations, including any left behind by a previous run
+func (rs *reservations) sweepLoop() {
+	defer close(rs.done)
+	interval := time.Duration(rs.conf.SweepIntervalSec) * time.Second
+	log.Infof("Idempotency reservations enabled ttlSec=%d interval=%s", rs.conf.ReservationTTLSec, interval)
+	for {
+		rs.sweep()
+		select {
+		case <-time.After(interval):
+		case <-rs.stop:
+			return
+		}
+	}
+}
+
+func (rs *reservations) sweep() {
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+	now := rs.now()
+	var expired []string
+	it := rs.kv.NewIterator()
+	for it.Next() {
+		var r Reservation
+		if err := it.ValueJSON(&r); err != nil || !r.Expires.After(now) {
+			expired = append(expired, it.Key())
+		}
+	}
+	it.Release()
+	for _, id := range expired {
+		if err := rs.kv.Delete(id); err != nil {
+			log.Errorf("Failed to delete expired reservation %s: %s", id, err)
+		}
+	}
+	if len(expired) > 0 {
+		log.Infof("Deleted %d expired reservations", len(expired))
+	}
+}
+
+// lookup returns the unexpired reservation for an ID, or nil. Must be called holding the lock.
+func (rs *reservations) lookup(id string) (*Reservation, error) {
+	var r Reservation
+	if err := rs.kv.GetJSON(id, &r); err != nil {
+		if err == kvstore.ErrorNotFound {
+			return nil, nil
+		}
+		return nil, errors.Errorf(errors.IdempotencyStoreFailed, err)
+	}
+	if !r.Expires.After(rs.now()) {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+// checkUnused fails if a receipt already exists for the ID. Must be called holding the lock.
+func (rs *reservations) checkUnused(id string) (int, error) {
+	if rs.receipts == nil || rs.receipts.persistence == nil {
+		return 200, nil
+	}
+	existing, err := rs.receipts.getReceipt(id)
+	if err != nil {
+		return 500, err
+	}
+	if existing != nil {
+		return 409, errors.Errorf(errors.IdempotencyKeyInUse, id)
+	}
+	return 200, nil
+}
+
+// reserve creates a reservation through the API, ahead of any submission
+func (rs *reservations) reserve(id string) (*Reservation, int, error) {
+	if !uuidCharsVerifier.MatchString(id) {
+		return nil, 400, errors.Errorf(errors.IdempotencyInvalidID, id)
+	}
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+	existing, err := rs.lookup(id)
+	if err != nil {
+		return nil, 500, err
+	}
+	if existing != nil {
+		return nil, 409, errors.Errorf(errors.IdempotencyKeyInUse, id)
+	}
+	if status, err := rs.checkUnused(id); err != nil {
+		return nil, status, err
+	}
+	now := rs.now().UTC()
+	r := &Reservation{
+		ID:      id,
+		Created: now,
+		Expires: now.Add(time.Duration(rs.conf.ReservationTTLSec) * time.Second),
+	}
+	if err := rs.kv.PutJSON(id, r); err != nil {
+		return nil, 500, errors.Errorf(errors.IdempotencyStoreFailed, err)
+	}
+	return r, 200, nil
+}
+
+// claim marks the ID as submitted, whether or not it was reserved in advance.
+// The returned function reverts the claim, for when the submission is not accepted.
+func (rs *reservations) claim(id string) (undo func(), status int, err error) {
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+	existing, err := rs.lookup(id)
+	if err != nil {
+		return nil, 500, err
+	}
+	if existing != nil && existing.Submitted {
+		return nil, 409, errors.Errorf(errors.IdempotencyKeyInUse, id)
+	}
+	if status, err := rs.checkUnused(id); err != nil {
+		return nil, status, err
+	}
+	now := rs.now().UTC()
+	r := &Reservation{ID: id, Created: now}
+	if existing != nil {
+		r.Created = existing.Created
+	}
+	r.Submitted = true
+	r.Expires = now.Add(time.Duration(rs.conf.ReservationTTLSec) * time.Second)
+	if err := rs.kv.PutJSON(id, r); err != nil {
+		return nil, 500, errors.Errorf(errors.IdempotencyStoreFailed, err)
+	}
+	return func() {
+		rs.mux.Lock()
+		defer rs.mux.Unlock()
+		var err error
+		if existing != nil {
+			err = rs.kv.PutJSON(id, existing)
+		} else {
+			err = rs.kv.Delete(id)
+		}
+		if err != nil {
+			log.Errorf("Failed to release reservation %s: %s", id, err)
+		}
+	}, 200, nil
+}
+
+func (rs *reservations) get(id string) (*Reservation, int, error) {
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+	r, err := rs.lookup(id)
+	if err != nil {
+		return nil, 500, err
+	}
+	if r == nil {
+		return nil, 404, errors.Errorf(errors.IdempotencyReservationNotFound, id)
+	}
+	return r, 200, nil
+}
+
+func (rs *reservations) release(id string) (int, error) {
+	rs.mux.Lock()
+	defer rs.mux.Unlock()
+	r, err := rs.lookup(id)
+	if err != nil {
+		return 500, err
+	}
+	if r == nil {
+		return 404, errors.Errorf(errors.IdempotencyReservationNotFound, id)
+	}
+	if err := rs.kv.Delete(id); err != nil {
+		return 500, errors.Errorf(errors.IdempotencyStoreFailed, err)
+	}
+	return 204, nil
+}
+
+func (rs *reservations) replyJSON(res http.ResponseWriter, req *http.Request, status int, result interface{}) {
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	if result != nil {
+		resBytes, _ := json.MarshalIndent(result, "", "  ")
+		_, _ = res.Write(resBytes)
+	}
+}
+
+func (rs *reservations) createReservation(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+	var body map[string]interface{}
+	if req.ContentLength != 0 {
+		var err error
+		if body, err = utils.YAMLorJSONPayload(req); err != nil {
+			sendRESTError(res, req, err, 400)
+			return
+		}
+	}
+	id := utils.GetMapString(body, "id")
+	if id == "" {
+		id = utils.UUIDv4()
+	}
+	r, status, err := rs.reserve(id)
+	if err != nil {
+		sendRESTError(res, req, err, status)
+		return
+	}
+	rs.replyJSON(res, req, status, r)
+}
+
+func (rs *reservations) getReservation(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+	r, status, err := rs.get(params.ByName("id"))
+	if err != nil {
+		sendRESTError(res, req, err, status)
+		return
+	}
+	rs.replyJSON(res, req, status, r)
+}
+
+func (rs *reservations) deleteReservation(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+	status, err := rs.release(params.ByName("id"))
+	if err != nil {
+		sendRESTError(res, req, err, status)
+		return
+	}
+	rs.replyJSON(res, req, status, nil)
+}
@@ -0,0 +1,252 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/eth"
+	"github.com/hyperledger/firefly-ethconnect/internal/kvstore"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestReservations(t *testing.T, conf *IdempotencyConf) (*reservations, *recordingHandler, *httptest.Server, func()) {
+	dir, _ := ioutil.TempDir("", "fly")
+	kv, err := kvstore.NewLDBKeyValueStore(path.Join(dir, "reservations"))
+	assert.NoError(t, err)
+	r, _ := newReceiptsTestStore(nil)
+	h := &recordingHandler{}
+	w := newWebhooks(h, r, nil, nil, eth.EthCommonConf{})
+	rs := newReservations(conf, kv, r)
+	w.reservations = rs
+	router := &httprouter.Router{}
+	w.addRoutes(router)
+	r.addRoutes(router)
+	rs.addRoutes(router)
+	ts := httptest.NewServer(router)
+	return rs, h, ts, func() {
+		ts.Close()
+		rs.close()
+		os.RemoveAll(dir)
+	}
+}
+
+func deleteReservationTest(t *testing.T, url string) int {
+	req, _ := http.NewRequest(http.MethodDelete, url, nil)
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	return res.StatusCode
+}
+
+func TestReservationsSubmitWithClientID(t *testing.T) {
+	assert := assert.New(t)
+
+	_, h, ts, done := newTestReservations(t, &IdempotencyConf{})
+	defer done()
+
+	status, reply := postApprovalTest(t, ts.URL+"/hook", `{"headers":{"type":"SendTransaction","id":"tx1"},"from":"0x12345"}`)
+	assert.Equal(200, status)
+	assert.Equal("tx1", reply["id"])
+
+	status, reply = postApprovalTest(t, ts.URL+"/hook", `{"headers":{"type":"SendTransaction","id":"tx1"},"from":"0x12345"}`)
+	assert.Equal(409, status)
+	assert.Regexp("FFEC100296", reply["error"])
+	assert.Equal([]string{"tx1"}, h.sent)
+
+	res, err := http.Get(ts.URL + "/reservations/tx1")
+	assert.NoError(err)
+	assert.Equal(200, res.StatusCode)
+
+	// Generated IDs are not reserved
+	status, _ = postApprovalTest(t, ts.URL+"/hook", `{"headers":{"type":"SendTransaction"},"from":"0x12345"}`)
+	assert.Equal(200, status)
+	assert.Len(h.sent, 2)
+	res, err = http.Get(ts.URL + "/reservations/" + h.sent[1])
+	assert.NoError(err)
+	assert.Equal(404, res.StatusCode)
+}
+
+func TestReservationsReleasedOnFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	_, h, ts, done := newTestReservations(t, &IdempotencyConf{})
+	defer done()
+
+	status, _ := postApprovalTest(t, ts.URL+"/reservations", `{"id":"tx1"}`)
+	assert.Equal(200, status)
+
+	h.err = fmt.Errorf("pop")
+	h.status = 503
+	status, _ = postApprovalTest(t, ts.URL+"/hook", `{"headers":{"type":"SendTransaction","id":"tx1"},"from":"0x12345"}`)
+	assert.Equal(503, status)
+	status, _ = postApprovalTest(t, ts.URL+"/hook", `{"headers":{"type":"SendTransaction","id":"tx2"},"from":"0x12345"}`)
+	assert.Equal(503, status)
+
+	// The advance reservation is restored, and the unreserved ID is free again
+	res, err := http.Get(ts.URL + "/reservations/tx1")
+	assert.NoError(err)
+	assert.Equal(200, res.StatusCode)
+	res, err = http.Get(ts.URL + "/reservations/tx2")
+	assert.NoError(err)
+	assert.Equal(404, res.StatusCode)
+
+	h.err = nil
+	status, _ = postApprovalTest(t, ts.URL+"/hook", `{"headers":{"type":"SendTransaction","id":"tx1"},"from":"0x12345"}`)
+	assert.Equal(200, status)
+	status, _ = postApprovalTest(t, ts.URL+"/hook", `{"headers":{"type":"SendTransaction","id":"tx2"},"from":"0x12345"}`)
+	assert.Equal(200, status)
+}
+
+func TestReservationsAPI(t *testing.T) {
+	assert := assert.New(t)
+
+	rs, _, ts, done := newTestReservations(t, &IdempotencyConf{ReservationTTLSec: 60})
+	defer done()
+
+	status, reply := postApprovalTest(t, ts.URL+"/reservations", "")
+	assert.Equal(200, status)
+	id := reply["id"].(string)
+	assert.NotEmpty(id)
+	assert.Equal(false, reply["submitted"])
+
+	status, reply = postApprovalTest(t, ts.URL+"/reservations", `{"id":"`+id+`"}`)
+	assert.Equal(409, status)
+
+	status, _ = postApprovalTest(t, ts.URL+"/reservations", `{"id":"not/valid"}`)
+	assert.Equal(400, status)
+	status, _ = postApprovalTest(t, ts.URL+"/reservations", `!!! not json or yaml`)
+	assert.Equal(400, status)
+
+	status, reply = postApprovalTest(t, ts.URL+"/hook", `{"headers":{"type":"SendTransaction","id":"`+id+`"},"from":"0x12345"}`)
+	assert.Equal(200, status)
+	r, _, err := rs.get(id)
+	assert.NoError(err)
+	assert.True(r.Submitted)
+	assert.True(r.Expires.After(r.Created))
+
+	assert.Equal(204, deleteReservationTest(t, ts.URL+"/reservations/"+id))
+	assert.Equal(404, deleteReservationTest(t, ts.URL+"/reservations/"+id))
+
+	// IDs that already have a receipt cannot be reserved
+	rs.receipts.writeAccepted("r1", "ack", map[string]interface{}{})
+	status, _ = postApprovalTest(t, ts.URL+"/reservations", `{"id":"r1"}`)
+	assert.Equal(409, status)
+	status, _ = postApprovalTest(t, ts.URL+"/hook", `{"headers":{"type":"SendTransaction","id":"r1"},"from":"0x12345"}`)
+	assert.Equal(409, status)
+}
+
+func TestReservationsExpiry(t *testing.T) {
+	assert := assert.New(t)
+
+	rs, _, _, done := newTestReservations(t, &IdempotencyConf{ReservationTTLSec: 10})
+	defer done()
+	assert.Equal(defaultSweepIntervalSec, rs.conf.SweepIntervalSec)
+
+	now := time.Now()
+	rs.now = func() time.Time { return now }
+	_, _, err := rs.claim("tx1")
+	assert.NoError(err)
+	_, _, err = rs.claim("tx1")
+	assert.Regexp("FFEC100296", err)
+	_ = rs.kv.Put("bad", []byte("!json"))
+
+	now = now.Add(11 * time.Second)
+	_, _, err = rs.get("tx1")
+	assert.Regexp("FFEC100297", err)
+	_, _, err = rs.claim("tx1")
+	assert.NoError(err)
+
+	now = now.Add(11 * time.Second)
+	rs.sweep()
+	_, err = rs.kv.Get("tx1")
+	assert.Equal(kvstore.ErrorNotFound, err)
+	_, err = rs.kv.Get("bad")
+	assert.Equal(kvstore.ErrorNotFound, err)
+}
+
+func TestReservationsStoreErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	rs, _, _, done := newTestReservations(t, &IdempotencyConf{})
+	defer done()
+	rs.kv.Close()
+	rs.kv = kvstore.NewMockKV(fmt.Errorf("pop"))
+
+	_, status, err := rs.reserve("tx1")
+	assert.Equal(500, status)
+	assert.Regexp("FFEC100295.*pop", err)
+	_, status, err = rs.claim("tx1")
+	assert.Equal(500, status)
+	assert.Regexp("FFEC100295.*pop", err)
+	status, err = rs.release("tx1")
+	assert.Equal(500, status)
+	assert.Regexp("pop", err)
+
+	mkv := kvstore.NewMockKV(nil)
+	mkv.StoreErr = fmt.Errorf("pop")
+	rs.kv = mkv
+	_, status, err = rs.reserve("tx1")
+	assert.Equal(500, status)
+	_, status, err = rs.claim("tx1")
+	assert.Equal(500, status)
+	assert.Regexp("pop", err)
+
+	r, ts := newReceiptsErrTestServer(fmt.Errorf("pop"))
+	defer ts.Close()
+	rs.receipts = r
+	rs.kv = kvstore.NewMockKV(nil)
+	_, status, err = rs.reserve("tx1")
+	assert.Equal(500, status)
+	assert.Regexp("pop", err)
+}
+
+func TestReservationsLoopStartStop(t *testing.T) {
+	rs, _, _, done := newTestReservations(t, &IdempotencyConf{})
+	rs.start()
+	done()
+}
+
+func TestInitReservations(t *testing.T) {
+	assert := assert.New(t)
+
+	var printYAML = false
+	g := NewRESTGateway(&printYAML)
+	g.webhooks = &webhooks{}
+	router := &httprouter.Router{}
+
+	err := g.initReservations(router)
+	assert.Regexp("FFEC100294", err)
+
+	dir, _ := ioutil.TempDir("", "fly")
+	defer os.RemoveAll(dir)
+	g.conf.Idempotency.Path = path.Join(dir, "reservations")
+	err = g.initReservations(router)
+	assert.NoError(err)
+	assert.NotNil(g.webhooks.reservations)
+	g.webhooks.reservations.close()
+
+	_ = ioutil.WriteFile(path.Join(dir, "file"), []byte{}, 0644)
+	g.conf.Idempotency.Path = path.Join(dir, "file")
+	err = g.initReservations(router)
+	assert.Regexp("FFEC100295", err)
+}
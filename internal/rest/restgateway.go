@@ -28,14 +28,17 @@ import (
 
 	"github.com/hyperledger/firefly-ethconnect/internal/auth"
 	"github.com/hyperledger/firefly-ethconnect/internal/contractgateway"
+	"github.com/hyperledger/firefly-ethconnect/internal/contractregistry"
 	"github.com/hyperledger/firefly-ethconnect/internal/errors"
 	"github.com/hyperledger/firefly-ethconnect/internal/eth"
 	"github.com/hyperledger/firefly-ethconnect/internal/kafka"
+	"github.com/hyperledger/firefly-ethconnect/internal/kvstore"
 	"github.com/hyperledger/firefly-ethconnect/internal/messages"
 	"github.com/hyperledger/firefly-ethconnect/internal/receipts"
 	"github.com/hyperledger/firefly-ethconnect/internal/tx"
 	"github.com/hyperledger/firefly-ethconnect/internal/utils"
 	"github.com/hyperledger/firefly-ethconnect/internal/ws"
+	"github.com/hyperledger/firefly-ethconnect/pkg/errorcodes"
 
 	"github.com/IBM/sarama"
 	"github.com/julienschmidt/httprouter"
@@ -50,16 +53,29 @@ const (
 
 // RESTGatewayConf defines the YAML config structure for a webhooks bridge instance
 type RESTGatewayConf struct {
-	Kafka    kafka.KafkaCommonConf                    `json:"kafka"`
-	MongoDB  receipts.MongoDBReceiptStoreConf         `json:"mongodb"`
-	LevelDB  receipts.LevelDBReceiptStoreConf         `json:"leveldb"`
-	MemStore receipts.ReceiptStoreConf                `json:"memstore"`
-	OpenAPI  contractgateway.SmartContractGatewayConf `json:"openapi"`
-	HTTP     struct {
-		LocalAddr string          `json:"localAddr"`
-		Port      int             `json:"port"`
-		TLS       utils.TLSConfig `json:"tls"`
+	Kafka         kafka.KafkaCommonConf                    `json:"kafka"`
+	MongoDB       receipts.MongoDBReceiptStoreConf         `json:"mongodb"`
+	LevelDB       receipts.LevelDBReceiptStoreConf         `json:"leveldb"`
+	SQLite        receipts.SQLiteReceiptStoreConf          `json:"sqlite"`
+	Elasticsearch receipts.ElasticsearchReceiptStoreConf   `json:"elasticsearch"`
+	MemStore      receipts.ReceiptStoreConf                `json:"memstore"`
+	Exporters     []ReceiptExporterConf                    `json:"receiptExporters,omitempty"`
+	Processors    []ReplyProcessorConf                     `json:"replyProcessors,omitempty"`
+	Archive       ReceiptArchiveConf                       `json:"receiptArchive,omitempty"`
+	WriteBehind   ReceiptWriteBehindConf                   `json:"receiptWriteBehind,omitempty"`
+	Encryption    receipts.ReceiptEncryptionConf           `json:"receiptEncryption,omitempty"`
+	Approvals     ApprovalsConf                            `json:"approvals"`
+	Idempotency   IdempotencyConf                          `json:"idempotency"`
+	OpenAPI       contractgateway.SmartContractGatewayConf `json:"openapi"`
+	HTTP          struct {
+		LocalAddr    string          `json:"localAddr"`
+		Port         int             `json:"port"`
+		TLS          utils.TLSConfig `json:"tls"`
+		MTLS         MTLSConf        `json:"mtls"`
+		AccessLog    AccessLogConf   `json:"accessLog"`
+		LegacyRoutes *bool           `json:"legacyRoutes,omitempty"`
 	} `json:"http"`
+	WebSockets ws.WebSocketServerConf `json:"ws"`
 	WebhooksDirectConf
 }
 
@@ -75,7 +91,9 @@ type RESTGateway struct {
 	receipts        *receiptStore
 	webhooks        *webhooks
 	smartContractGW contractgateway.SmartContractGateway
+	processor       tx.TxnProcessor
 	ws              ws.WebSocketServer
+	accessLog       *accessLogger
 }
 
 // Conf gets the config for this bridge
@@ -100,10 +118,17 @@ func (g *RESTGateway) ValidateConf() (err error) {
 	if g.conf.LevelDB.QueryLimit < 1 {
 		g.conf.LevelDB.QueryLimit = 100
 	}
+	if g.conf.SQLite.QueryLimit < 1 {
+		g.conf.SQLite.QueryLimit = 100
+	}
+	if g.conf.Elasticsearch.QueryLimit < 1 {
+		g.conf.Elasticsearch.QueryLimit = 100
+	}
 	if g.conf.OpenAPI.StoragePath != "" && g.conf.RPC.URL == "" {
 		err = errors.Errorf(errors.ConfigRESTGatewayRequiredRPC)
 		return
 	}
+	err = eth.ValidateChainProfile(g.conf.ChainProfile)
 	return
 }
 
@@ -115,7 +140,6 @@ func NewRESTGateway(printYAML *bool) (g *RESTGateway) {
 		pendingMsgs: make(map[string]bool),
 		successMsgs: make(map[string]*sarama.ProducerMessage),
 		failedMsgs:  make(map[string]error),
-		ws:          ws.NewWebSocketServer(),
 	}
 	return
 }
@@ -164,11 +188,16 @@ func (g *RESTGateway) CobraInit(cmdName string) (cmd *cobra.Command) {
 	cmd.Flags().IntVarP(&g.conf.MemStore.MaxDocs, "memstore-receipt-maxdocs", "v", utils.DefInt("MEMSTORE_MAXDOCS", 10), "In-memory receipt store capped size")
 	cmd.Flags().IntVarP(&g.conf.MemStore.QueryLimit, "memstore-query-limit", "V", utils.DefInt("MEMSTORE_QUERYLIM", 0), "In-memory maximum docs to return on a rest call")
 	cmd.Flags().IntVarP(&g.conf.LevelDB.QueryLimit, "leveldb-query-limit", "B", utils.DefInt("LEVELDB_QUERYLIM", 0), "Maximum docs to return on a rest call (cap on limit)")
+	cmd.Flags().StringVar(&g.conf.SQLite.Path, "sqlite-path", os.Getenv("SQLITE_PATH"), "Path to an embedded SQLite receipt store")
+	cmd.Flags().IntVar(&g.conf.SQLite.MaxDocs, "sqlite-receipt-maxdocs", utils.DefInt("SQLITE_MAXDOCS", 0), "SQLite receipt store capped size, with the oldest receipts pruned")
 	return
 }
 
 type statusMsg struct {
-	OK bool `json:"ok"`
+	OK          bool                                      `json:"ok"`
+	Signing     *tx.SigningStats                          `json:"signing,omitempty"`
+	Persistence map[string]map[string]*utils.StoreOpStats `json:"persistence,omitempty"`
+	Replies     map[string]*ReplyTypeStats                `json:"replies,omitempty"`
 }
 
 type errMsg struct {
@@ -176,7 +205,23 @@ type errMsg struct {
 }
 
 func (g *RESTGateway) statusHandler(res http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	reply, _ := json.Marshal(&statusMsg{OK: true})
+	status := &statusMsg{OK: true, Persistence: utils.AllStoreMetrics()}
+	if g.processor != nil {
+		status.Signing = g.processor.SigningStats()
+	}
+	if g.receipts != nil {
+		status.Replies = g.receipts.processors.replyStats()
+	}
+	reply, _ := json.Marshal(status)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(200)
+	_, _ = res.Write(reply)
+}
+
+// errorCodesHandler serves the catalogue of error codes, so other components can map
+// the "code" of an ethconnect error without importing the errorcodes package
+func (g *RESTGateway) errorCodesHandler(res http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	reply, _ := json.Marshal(errorcodes.Catalogue)
 	res.Header().Set("Content-Type", "application/json")
 	res.WriteHeader(200)
 	_, _ = res.Write(reply)
@@ -195,9 +240,22 @@ func (g *RESTGateway) DispatchMsgAsync(ctx context.Context, msg map[string]inter
 	return reply, status, err
 }
 
+// ProcessReply records a reply from a Kafka bridge in the same process, which was not sent to
+// the reply topic because of the reply mode of the request
+func (g *RESTGateway) ProcessReply(msgBytes []byte) {
+	g.receipts.processReply(msgBytes)
+}
+
 func (g *RESTGateway) newAccessTokenContextHandler(parent http.Handler) http.Handler {
 	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
 
+		// OPTIONS replies only describe the routes, so they are available before a client
+		// has a token - including to discover that it needs one
+		if req.Method == http.MethodOptions {
+			parent.ServeHTTP(res, req)
+			return
+		}
+
 		// Extract an access token from bearer token (only - no support for query params)
 		accessToken := ""
 		hSplit := strings.SplitN(req.Header.Get("Authorization"), " ", 2)
@@ -211,6 +269,10 @@ func (g *RESTGateway) newAccessTokenContextHandler(parent http.Handler) http.Han
 			return
 		}
 
+		if ns := req.Header.Get("x-firefly-namespace"); ns != "" {
+			authCtx = auth.WithNamespace(authCtx, ns)
+		}
+
 		parent.ServeHTTP(res, req.WithContext(authCtx))
 	})
 }
@@ -222,8 +284,14 @@ func (g *RESTGateway) Init() (receipts.ReceiptStorePersistence, error) {
 	if err != nil {
 		return nil, err
 	}
+	if g.conf.HTTP.MTLS.Enabled {
+		if tlsConfig, err = newMTLSConfig(&g.conf.HTTP.MTLS); err != nil {
+			return nil, err
+		}
+	}
 
 	router := httprouter.New()
+	router.GlobalOPTIONS = http.HandlerFunc(g.optionsHandler)
 
 	var processor tx.TxnProcessor
 	var rpcClient eth.RPCClient
@@ -235,7 +303,9 @@ func (g *RESTGateway) Init() (receipts.ReceiptStorePersistence, error) {
 		processor = tx.NewTxnProcessor(&g.conf.TxnProcessorConf, &g.conf.RPCConf)
 		processor.Init(rpcClient)
 	}
+	g.processor = processor
 
+	g.ws = ws.NewWebSocketServer(&g.conf.WebSockets)
 	g.ws.AddRoutes(router)
 
 	if g.conf.OpenAPI.StoragePath != "" {
@@ -262,15 +332,62 @@ func (g *RESTGateway) Init() (receipts.ReceiptStorePersistence, error) {
 			return nil, err
 		}
 		receiptStorePersistence = leveldbStore
+	} else if g.conf.SQLite.Path != "" {
+		receiptStoreConf = &g.conf.SQLite.ReceiptStoreConf
+		sqliteStore := receipts.NewSQLiteReceipts(&g.conf.SQLite)
+		receiptStorePersistence = sqliteStore
+		if err := sqliteStore.Connect(); err != nil {
+			return nil, err
+		}
+	} else if g.conf.Elasticsearch.URL != "" {
+		receiptStoreConf = &g.conf.Elasticsearch.ReceiptStoreConf
+		esStore := receipts.NewElasticsearchReceipts(&g.conf.Elasticsearch)
+		receiptStorePersistence = esStore
+		if err := esStore.Connect(); err != nil {
+			return nil, err
+		}
 	} else {
 		receiptStoreConf = &g.conf.MemStore
 		memStore := receipts.NewMemoryReceipts(&g.conf.MemStore)
 		receiptStorePersistence = memStore
 	}
 
+	if g.conf.Encryption.Enabled {
+		if receiptStorePersistence, err = receipts.NewEncryptedReceipts(&g.conf.Encryption, receiptStorePersistence); err != nil {
+			return nil, err
+		}
+	}
+
 	router.GET("/status", g.statusHandler)
+	router.GET("/errorcodes", g.errorCodesHandler)
 	g.receipts = newReceiptStore(receiptStoreConf, receiptStorePersistence, g.smartContractGW)
+	if g.smartContractGW != nil {
+		g.smartContractGW.SetEventDeliveryListener(g.receipts)
+	}
+	if g.receipts.exporters, err = newReceiptExporters(g.conf.Exporters); err != nil {
+		g.receipts.close()
+		return nil, err
+	}
+	if len(g.conf.Processors) > 0 {
+		g.receipts.processors.close()
+		if g.receipts.processors, err = newReplyProcessors(g.conf.Processors, g.receipts); err != nil {
+			g.receipts.close()
+			return nil, err
+		}
+	}
+	if g.conf.WriteBehind.Enabled {
+		g.receipts.writeBehind = newReceiptWriteBehind(&g.conf.WriteBehind, g.receipts)
+	}
+	if g.conf.Archive.Enabled {
+		if g.receipts.archive, err = newReceiptArchive(&g.conf.Archive, receiptStorePersistence); err != nil {
+			g.receipts.close()
+			return nil, err
+		}
+		g.receipts.archive.metrics = g.receipts.metrics
+		g.receipts.archive.start()
+	}
 	g.receipts.addRoutes(router)
+	g.ws.SetReplyHistory(g.receipts.replyHistory)
 	if len(g.conf.Kafka.Brokers) > 0 {
 		wk := newWebhooksKafka(&g.conf.Kafka, g.receipts)
 		g.webhooks = newWebhooks(wk, g.receipts, g.smartContractGW, rpcClient, g.conf.EthCommonConf)
@@ -279,11 +396,38 @@ func (g *RESTGateway) Init() (receipts.ReceiptStorePersistence, error) {
 		g.webhooks = newWebhooks(wd, g.receipts, g.smartContractGW, rpcClient, g.conf.EthCommonConf)
 	}
 	g.webhooks.addRoutes(router)
+	g.ws.SetSubmitter(g.webhooks.wsSubmit)
+	var resolver contractregistry.ContractResolver
+	if g.smartContractGW != nil {
+		resolver = g.smartContractGW.ContractResolver()
+	}
+	newLinter(resolver).addRoutes(router)
+	if g.conf.Approvals.Enabled {
+		if err = g.initApprovals(router); err != nil {
+			return nil, err
+		}
+	}
+	if g.conf.Idempotency.Enabled {
+		if err = g.initReservations(router); err != nil {
+			return nil, err
+		}
+	}
+
+	handler := g.newAccessTokenContextHandler(g.newAPIVersionHandler(router))
+	if g.conf.HTTP.MTLS.Enabled {
+		handler = newMTLSHandler(&g.conf.HTTP.MTLS, handler)
+	}
+	if g.conf.HTTP.AccessLog.Enabled {
+		if g.accessLog, err = newAccessLogger(&g.conf.HTTP.AccessLog); err != nil {
+			return nil, err
+		}
+		handler = g.accessLog.wrap(handler)
+	}
 
 	g.srv = &http.Server{
 		Addr:           fmt.Sprintf("%s:%d", g.conf.HTTP.LocalAddr, g.conf.HTTP.Port),
 		TLSConfig:      tlsConfig,
-		Handler:        g.newAccessTokenContextHandler(router),
+		Handler:        handler,
 		MaxHeaderBytes: MaxHeaderSize,
 	}
 
@@ -313,8 +457,14 @@ func (g *RESTGateway) Start() (err error) {
 
 	go func() {
 		<-readyToListen
-		log.Printf("HTTP server listening on %s", g.srv.Addr)
-		err := g.srv.ListenAndServe()
+		var err error
+		if g.conf.HTTP.MTLS.Enabled {
+			log.Printf("HTTPS server listening on %s (mutual TLS)", g.srv.Addr)
+			err = g.srv.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("HTTP server listening on %s", g.srv.Addr)
+			err = g.srv.ListenAndServe()
+		}
 		if err != nil {
 			log.Errorf("Listening ended with: %s", err)
 		}
@@ -353,6 +503,53 @@ func (g *RESTGateway) Start() (err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	_ = g.srv.Shutdown(ctx)
 	defer cancel()
+	if g.processor != nil {
+		// Stop waiting on sends and receipts, so their replies are written before the receipt store closes
+		g.processor.Close()
+	}
+	if g.accessLog != nil {
+		g.accessLog.close()
+	}
+	g.receipts.close()
+	if g.webhooks.approvals != nil {
+		g.webhooks.approvals.close()
+	}
+	if g.webhooks.reservations != nil {
+		g.webhooks.reservations.close()
+	}
 
 	return
 }
+
+func (g *RESTGateway) initApprovals(router *httprouter.Router) error {
+	if g.conf.Approvals.Path == "" {
+		return errors.Errorf(errors.ApprovalsConfigPathMissing)
+	}
+	kv, err := kvstore.NewLDBKeyValueStore(g.conf.Approvals.Path)
+	if err != nil {
+		return errors.Errorf(errors.ApprovalsStoreFailed, err)
+	}
+	a, err := newApprovals(&g.conf.Approvals, kv, g.webhooks)
+	if err != nil {
+		kv.Close()
+		return err
+	}
+	g.webhooks.approvals = a
+	a.addRoutes(router)
+	return nil
+}
+
+func (g *RESTGateway) initReservations(router *httprouter.Router) error {
+	if g.conf.Idempotency.Path == "" {
+		return errors.Errorf(errors.IdempotencyConfigPathMissing)
+	}
+	kv, err := kvstore.NewLDBKeyValueStore(g.conf.Idempotency.Path)
+	if err != nil {
+		return errors.Errorf(errors.IdempotencyStoreFailed, err)
+	}
+	rs := newReservations(&g.conf.Idempotency, kv, g.receipts)
+	g.webhooks.reservations = rs
+	rs.addRoutes(router)
+	rs.start()
+	return nil
+}
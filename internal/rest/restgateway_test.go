@@ -30,6 +30,9 @@ import (
 	"github.com/hyperledger/firefly-ethconnect/internal/auth"
 	"github.com/hyperledger/firefly-ethconnect/internal/auth/authtest"
 	"github.com/hyperledger/firefly-ethconnect/internal/eth"
+	"github.com/hyperledger/firefly-ethconnect/internal/receipts"
+	"github.com/hyperledger/firefly-ethconnect/internal/tx"
+	"github.com/hyperledger/firefly-ethconnect/pkg/errorcodes"
 	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/assert"
 )
@@ -64,6 +67,15 @@ func TestValidateConfInvalidOpenAPIArgs(t *testing.T) {
 	assert.Regexp("RPC URL and Storage Path must be supplied to enable the Open API REST Gateway", err)
 }
 
+func TestValidateConfInvalidChainProfile(t *testing.T) {
+	assert := assert.New(t)
+	var printYAML = false
+	g := NewRESTGateway(&printYAML)
+	g.conf.ChainProfile = "unknown"
+	err := g.ValidateConf()
+	assert.Regexp("FFEC100234", err)
+}
+
 func TestStartStatusStopNoKafkaWebhooksAccessToken(t *testing.T) {
 	assert := assert.New(t)
 
@@ -212,6 +224,32 @@ func TestStartWithBadTLS(t *testing.T) {
 	assert.Regexp("Client private key and certificate must both be provided for mutual auth", err)
 }
 
+func TestStartWithBadMTLS(t *testing.T) {
+	assert := assert.New(t)
+
+	var printYAML = false
+	g := NewRESTGateway(&printYAML)
+	g.conf.HTTP.Port = lastPort
+	g.conf.HTTP.LocalAddr = "127.0.0.1"
+	g.conf.HTTP.MTLS.Enabled = true
+	lastPort++
+	err := g.Start()
+	assert.Regexp("FFEC100274", err)
+}
+
+func TestStartWithBadReceiptEncryption(t *testing.T) {
+	assert := assert.New(t)
+
+	var printYAML = false
+	g := NewRESTGateway(&printYAML)
+	g.conf.HTTP.Port = lastPort
+	g.conf.HTTP.LocalAddr = "127.0.0.1"
+	g.conf.Encryption.Enabled = true
+	lastPort++
+	err := g.Start()
+	assert.Regexp("FFEC100325", err)
+}
+
 func TestStartInvalidMongo(t *testing.T) {
 	assert := assert.New(t)
 
@@ -336,3 +374,66 @@ func TestDispatchMsgAsyncPassesThroughToWebhooks(t *testing.T) {
 	assert.Equal(400, status)
 	assert.Regexp("Invalid message - missing 'headers' \\(or not an object\\)", err)
 }
+
+func TestErrorCodesHandler(t *testing.T) {
+	assert := assert.New(t)
+	g := &RESTGateway{}
+	res := httptest.NewRecorder()
+	g.errorCodesHandler(res, httptest.NewRequest("GET", "/errorcodes", nil), nil)
+	assert.Equal(200, res.Code)
+	var catalogue []*errorcodes.Entry
+	assert.NoError(json.NewDecoder(res.Body).Decode(&catalogue))
+	assert.Equal(errorcodes.Catalogue, catalogue)
+}
+
+// statusWithoutPersistence removes the metrics of the stores opened by other tests from a status
+func statusWithoutPersistence(t *testing.T, body []byte) string {
+	var status map[string]interface{}
+	assert.NoError(t, json.Unmarshal(body, &status))
+	delete(status, "persistence")
+	b, _ := json.Marshal(status)
+	return string(b)
+}
+
+func TestStatusHandlerSigningStats(t *testing.T) {
+	assert := assert.New(t)
+	g := &RESTGateway{}
+	res := httptest.NewRecorder()
+	g.statusHandler(res, httptest.NewRequest("GET", "/status", nil), nil)
+	assert.JSONEq(`{"ok": true}`, statusWithoutPersistence(t, res.Body.Bytes()))
+
+	g.processor = &mockProcessor{signingStats: &tx.SigningStats{Workers: 4, Completed: 10, AverageMS: 1.5}}
+	res = httptest.NewRecorder()
+	g.statusHandler(res, httptest.NewRequest("GET", "/status", nil), nil)
+	assert.JSONEq(`{"ok": true, "signing": {"workers": 4, "busy": 0, "queued": 0, "senders": 0, "completed": 10, "averageMS": 1.5}}`, statusWithoutPersistence(t, res.Body.Bytes()))
+}
+
+func TestStatusHandlerPersistenceMetrics(t *testing.T) {
+	assert := assert.New(t)
+	rsc := &receipts.ReceiptStoreConf{}
+	r := newReceiptStore(rsc, receipts.NewMemoryReceipts(rsc), nil)
+	defer r.close()
+	r.writeReceipt("req1", map[string]interface{}{"_id": "req1"}, false)
+	r.getReceipt("req1")
+
+	g := &RESTGateway{}
+	res := httptest.NewRecorder()
+	g.statusHandler(res, httptest.NewRequest("GET", "/status", nil), nil)
+	var status statusMsg
+	assert.NoError(json.NewDecoder(res.Body).Decode(&status))
+	assert.Equal(int64(1), status.Persistence["receipts"]["AddReceipt"].Count)
+	assert.Equal(int64(1), status.Persistence["receipts"]["GetReceipt"].Count)
+	assert.Equal(int64(0), status.Persistence["receipts"]["GetReceipt"].Errors)
+}
+
+func TestProcessReplyFromLocalBridge(t *testing.T) {
+	assert := assert.New(t)
+	rsc := &receipts.ReceiptStoreConf{}
+	r := receipts.NewMemoryReceipts(rsc)
+	g := &RESTGateway{receipts: newReceiptStore(rsc, r, nil)}
+
+	g.ProcessReply([]byte(`{"headers":{"requestId":"request1","type":"TransactionSuccess"},"transactionHash":"0x12345"}`))
+	receipt, err := r.GetReceipt("request1")
+	assert.NoError(err)
+	assert.Equal("0x12345", (*receipt)["transactionHash"])
+}
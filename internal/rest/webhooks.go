@@ -20,10 +20,12 @@ import (
 	"net/http"
 	"reflect"
 
+	"github.com/hyperledger/firefly-ethconnect/internal/auth"
 	"github.com/hyperledger/firefly-ethconnect/internal/contractgateway"
 	"github.com/hyperledger/firefly-ethconnect/internal/errors"
 	"github.com/hyperledger/firefly-ethconnect/internal/eth"
 	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/tx"
 	"github.com/hyperledger/firefly-ethconnect/internal/utils"
 	"github.com/julienschmidt/httprouter"
 	log "github.com/sirupsen/logrus"
@@ -35,12 +37,33 @@ type webhooksHandler interface {
 	isInitialized() bool
 }
 
+// queuedCanceller is implemented by handlers that hold requests in this process
+// until they are sent to the node, and so are able to withdraw them
+type queuedCanceller interface {
+	cancelQueued(ctx context.Context, msgID string) (statusCode int, err error)
+}
+
+// authorizeCancel checks the caller is the principal that submitted the request, or
+// is authorized to decide on the submissions of others
+func authorizeCancel(ctx context.Context, msgID, submitter string) error {
+	if auth.GetPrincipal(ctx) == submitter {
+		return nil
+	}
+	if err := auth.AuthApprovals(ctx); err != nil {
+		log.Warnf("Cancel of %s by '%s' rejected: %s", msgID, auth.GetPrincipal(ctx), err)
+		return errors.Errorf(errors.WebhooksCancelNotSubmitter, msgID)
+	}
+	return nil
+}
+
 // webhooks provides the async HTTP to eth TX bridge
 type webhooks struct {
 	smartContractGW contractgateway.SmartContractGateway
 	handler         webhooksHandler
 	receipts        *receiptStore
 	rpcClient       eth.RPCClient
+	approvals       *approvals
+	reservations    *reservations
 }
 
 func newWebhooks(handler webhooksHandler, receipts *receiptStore, smartContractGW contractgateway.SmartContractGateway, rpcClient eth.RPCClient, ethCommonConf eth.EthCommonConf) *webhooks {
@@ -52,6 +75,11 @@ func newWebhooks(handler webhooksHandler, receipts *receiptStore, smartContractG
 	}
 }
 
+type cancelReply struct {
+	ID        string `json:"id"`
+	Cancelled bool   `json:"cancelled"`
+}
+
 type hookErrMsg struct {
 	Sent    bool   `json:"sent"`
 	Message string `json:"error"`
@@ -78,6 +106,53 @@ func (w *webhooks) addRoutes(router *httprouter.Router) {
 	router.POST("/", w.webhookHandlerNoAck) // Default on base URL
 	router.POST("/hook", w.webhookHandlerWithAck)
 	router.POST("/fasthook", w.webhookHandlerNoAck)
+	router.POST("/requests/:id/cancel", w.cancelRequest)
+	router.DELETE("/requests/:id", w.cancelRequest)
+}
+
+// cancelRequest withdraws a request that is waiting to be sent to the node, writing a
+// TransactionCancelled receipt. A submission parked for approval can be withdrawn in any
+// mode, but a dispatched request only while it is held in this process. Only the submitter,
+// or a principal authorized for approvals, can withdraw a request in the namespace of the caller.
+func (w *webhooks) cancelRequest(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+
+	msgID := params.ByName("id")
+	if w.approvals != nil {
+		// Once approved, the request has been dispatched like any other
+		pa, statusCode, err := w.approvals.get(msgID)
+		if err != nil && statusCode != 404 {
+			w.hookErrReply(res, req, err, statusCode)
+			return
+		}
+		if pa != nil && pa.Status != ApprovalStatusApproved {
+			if _, statusCode, err := w.approvals.cancel(req.Context(), msgID); err != nil {
+				w.hookErrReply(res, req, err, statusCode)
+				return
+			}
+			w.sendCancelReply(res, req, msgID)
+			return
+		}
+	}
+	canceller, ok := w.handler.(queuedCanceller)
+	if !ok {
+		w.hookErrReply(res, req, errors.Errorf(errors.WebhooksCancelNotSupported), 405)
+		return
+	}
+	if statusCode, err := canceller.cancelQueued(req.Context(), msgID); err != nil {
+		w.hookErrReply(res, req, err, statusCode)
+		return
+	}
+	w.sendCancelReply(res, req, msgID)
+}
+
+func (w *webhooks) sendCancelReply(res http.ResponseWriter, req *http.Request, msgID string) {
+	reply, _ := json.Marshal(&cancelReply{ID: msgID, Cancelled: true})
+	status := 200
+	log.Infof("<-- %s %s [%d]", req.Method, req.URL, status)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	_, _ = res.Write(reply)
 }
 
 func (w *webhooks) webhookHandlerWithAck(res http.ResponseWriter, req *http.Request, _ httprouter.Params) {
@@ -118,6 +193,17 @@ func (w *webhooks) webhookHandler(res http.ResponseWriter, req *http.Request, ac
 	w.sendWebhookReply(res, req, reply)
 }
 
+// wsSubmit processes a request submitted over a WebSocket, as if it was posted to the webhooks API.
+// Only requests that are dispatched asynchronously have a receipt to follow.
+func (w *webhooks) wsSubmit(ctx context.Context, msg map[string]interface{}) (interface{}, bool, error) {
+	reply, _, err := w.processMsg(ctx, msg, true, false)
+	if err != nil {
+		return nil, false, err
+	}
+	_, async := reply.(*messages.AsyncSentMsg)
+	return reply, async, nil
+}
+
 func (w *webhooks) syncCallContract(ctx context.Context, msg map[string]interface{}) (messages.WebhookReply, int, error) {
 	msgBytes, _ := json.Marshal(&msg)
 	var qm messages.QueryTransaction
@@ -135,6 +221,49 @@ func (w *webhooks) syncCallContract(ctx context.Context, msg map[string]interfac
 	return messages.SyncQueryReply(res), 200, nil
 }
 
+// sendRawTransaction checks a pre-signed transaction against the chain, so replayed or
+// mis-chained payloads get a specific error rather than an opaque failure from the node
+func (w *webhooks) sendRawTransaction(ctx context.Context, msg map[string]interface{}) (messages.WebhookReply, int, error) {
+	msgBytes, _ := json.Marshal(&msg)
+	var rm messages.SendRawTransaction
+	if err := json.Unmarshal(msgBytes, &rm); err != nil {
+		return nil, 400, err
+	}
+	tx, err := eth.DecodeRawTransaction(rm.RawTransaction)
+	if err != nil {
+		return nil, 400, err
+	}
+	if err := tx.Check(ctx, w.rpcClient, rm.From); err != nil {
+		return nil, rawTxnErrStatus(err), err
+	}
+	txHash, err := tx.Send(ctx, w.rpcClient)
+	if err != nil {
+		return nil, 500, err
+	}
+	reqID := rm.Headers.ID
+	if reqID == "" {
+		reqID = utils.UUIDv4()
+	}
+	log.Infof("Raw transaction %s sent. MsgID: %s", txHash, reqID)
+	return &messages.RawTransactionSent{
+		Sent:            true,
+		Request:         reqID,
+		TransactionHash: txHash,
+	}, 200, nil
+}
+
+func rawTxnErrStatus(err error) int {
+	if ethErr, ok := err.(errors.EthconnectError); ok {
+		switch ethErr.Code() {
+		case errors.RawTxnNonceUsed.Code():
+			return 409
+		case errors.RPCCallReturnedError.Code():
+			return 500
+		}
+	}
+	return 400
+}
+
 func (w *webhooks) processMsg(ctx context.Context, msg map[string]interface{}, ack, immediateReceipt bool) (messages.WebhookReply, int, error) {
 	// Check we understand the type, and can get the key.
 	// The rest of the validation is performed by the bridge listening to Kafka
@@ -148,18 +277,44 @@ func (w *webhooks) processMsg(ctx context.Context, msg map[string]interface{}, a
 	}
 	var key string
 	switch msgType {
-	case messages.MsgTypeDeployContract, messages.MsgTypeSendTransaction:
+	case messages.MsgTypeDeployContract, messages.MsgTypeSendTransaction, messages.MsgTypeCancelTransaction:
 		from, exists := msg["from"]
 		if !exists || reflect.TypeOf(from).Kind() != reflect.String {
 			return nil, 400, errors.Errorf(errors.WebhooksInvalidMsgFromMissing)
 		}
 		key = from.(string)
+	case messages.MsgTypeRelayTransaction:
+		// Meta-transactions are sent from the relayer configured on the bridge, unless the
+		// message overrides it, so they share a key to keep the relayer nonces in order
+		key = messages.MsgTypeRelayTransaction
+		if from, ok := msg["from"].(string); ok && from != "" {
+			key = from
+		}
 	case messages.MsgTypeQuery:
 		return w.syncCallContract(ctx, msg)
+	case messages.MsgTypeSendRawTransaction:
+		return w.sendRawTransaction(ctx, msg)
 	default:
 		return nil, 400, errors.Errorf(errors.WebhooksInvalidMsgType, msgType)
 	}
 
+	// The reply mode must be valid before the request is accepted, as any error is only
+	// reported asynchronously once it has been dispatched
+	headersMap := headers.(map[string]interface{})
+	replyMode, _ := headersMap["replyMode"].(string)
+	replyWebhook, _ := headersMap["replyWebhook"].(string)
+	if err := tx.ValidateReplyMode(&messages.RequestHeaders{ReplyMode: replyMode, ReplyWebhook: replyWebhook}); err != nil {
+		return nil, 400, err
+	}
+
+	// The namespace of the caller is stamped on the request, overriding anything supplied in
+	// the message, so the receipt for the reply is only visible within that namespace
+	if ns := auth.GetNamespace(ctx); ns != "" {
+		headers.(map[string]interface{})["namespace"] = ns
+	} else {
+		delete(headers.(map[string]interface{}), "namespace")
+	}
+
 	// Generate a message ID if not already set
 	var msgID string
 	incomingID := headers.(map[string]interface{})["id"]
@@ -177,6 +332,33 @@ func (w *webhooks) processMsg(ctx context.Context, msg map[string]interface{}, a
 		}
 	}
 
+	// Client supplied IDs are claimed for the reservation TTL, so a retry after a crash
+	// or a timeout is rejected rather than submitted a second time
+	var undoClaim func()
+	if incomingID != nil && w.reservations != nil {
+		var status int
+		var err error
+		if undoClaim, status, err = w.reservations.claim(msgID); err != nil {
+			return nil, status, err
+		}
+	}
+
+	var reply messages.WebhookReply
+	var status int
+	var err error
+	if w.approvals != nil && w.approvals.requiresApproval(msgType.(string), msg) {
+		reply, status, err = w.approvals.park(ctx, key, msgID, msg, ack, immediateReceipt)
+	} else {
+		reply, status, err = w.dispatchMsg(ctx, key, msgID, msg, ack, immediateReceipt)
+	}
+	if err != nil && undoClaim != nil {
+		undoClaim()
+	}
+	return reply, status, err
+}
+
+// dispatchMsg passes a validated message to the handler, on submission or on approval
+func (w *webhooks) dispatchMsg(ctx context.Context, key, msgID string, msg map[string]interface{}, ack, immediateReceipt bool) (messages.WebhookReply, int, error) {
 	// We reserve the ID before we do the call to Kafka. This is as good as we
 	// can get for idempotence in this model - there is still a window where it's possible
 	// Kafka accepts the message, but we are terminated before we get an error back.
@@ -189,7 +371,7 @@ func (w *webhooks) processMsg(ctx context.Context, msg map[string]interface{}, a
 	}
 
 	// Pass to the handler
-	log.Infof("Webhook accepted message. MsgID: %s Type: %s", msgID, msgType)
+	log.Infof("Webhook accepted message. MsgID: %s", msgID)
 	msgAck, status, err := w.handler.sendWebhookMsg(ctx, key, msgID, msg, ack)
 	if err != nil {
 		return nil, status, err
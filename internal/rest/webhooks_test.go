@@ -20,11 +20,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
 	"testing"
 
+	"github.com/hyperledger/firefly-ethconnect/internal/auth"
+	"github.com/hyperledger/firefly-ethconnect/internal/contractregistry"
+	"github.com/hyperledger/firefly-ethconnect/internal/events"
 	"github.com/hyperledger/firefly-ethconnect/internal/messages"
 	"github.com/hyperledger/firefly-ethconnect/internal/receipts"
 	"github.com/hyperledger/firefly-ethconnect/mocks/ethmocks"
@@ -41,16 +45,23 @@ func (r *popReader) Read(b []byte) (n int, err error) {
 }
 
 type mockContractGW struct {
-	preDeployErr  error
-	postDeployErr error
-	testValue     interface{}
-	replyCallback func(message interface{})
+	preDeployErr     error
+	postDeployErr    error
+	receiptEvents    []*messages.ReceiptEvent
+	testValue        interface{}
+	replyCallback    func(message interface{})
+	deliveryListener events.DeliveryListener
+	resolver         contractregistry.ContractResolver
 }
 
 func (m *mockContractGW) PreDeploy(*messages.DeployContract) error { return m.preDeployErr }
 
 func (m *mockContractGW) PostDeploy(*messages.TransactionReceipt) error { return m.postDeployErr }
 
+func (m *mockContractGW) DecodeReceiptEvents(*messages.TransactionReceipt) []*messages.ReceiptEvent {
+	return m.receiptEvents
+}
+
 func (m *mockContractGW) AddRoutes(*httprouter.Router) {}
 
 func (m *mockContractGW) SendReply(message interface{}) {
@@ -59,6 +70,14 @@ func (m *mockContractGW) SendReply(message interface{}) {
 	}
 }
 
+func (m *mockContractGW) SetEventDeliveryListener(listener events.DeliveryListener) {
+	m.deliveryListener = listener
+}
+
+func (m *mockContractGW) ContractResolver() contractregistry.ContractResolver {
+	return m.resolver
+}
+
 func (m *mockContractGW) Shutdown() {}
 
 type mockHandler struct{}
@@ -153,6 +172,49 @@ func TestWebhookHandlerContractGWImmeidateReceiptSuccess(t *testing.T) {
 
 }
 
+func TestWebhookProcessMsgStampsNamespace(t *testing.T) {
+	assert := assert.New(t)
+
+	r := receipts.NewMemoryReceipts(&receipts.ReceiptStoreConf{})
+	w := &webhooks{
+		handler:  &mockHandler{},
+		receipts: newReceiptStore(&receipts.ReceiptStoreConf{}, r, nil),
+	}
+	msg := map[string]interface{}{
+		"headers": map[string]interface{}{
+			"type":      messages.MsgTypeSendTransaction,
+			"namespace": "spoofed",
+		},
+		"from": "0x4b098809e68c88e26442c7ac2f2ca1fb9bb2e37c",
+	}
+	ctx := auth.WithNamespace(context.Background(), "tenant1")
+	reply, status, err := w.processMsg(ctx, msg, true, true)
+	assert.NoError(err)
+	assert.Equal(200, status)
+
+	headers := msg["headers"].(map[string]interface{})
+	assert.Equal("tenant1", headers["namespace"])
+	receipt, err := r.GetReceipt(reply.(*messages.AsyncSentMsg).Request)
+	assert.NoError(err)
+	assert.Equal("tenant1", (*receipt)["namespace"])
+
+	// Callers without a namespace cannot choose one in the message
+	headers = map[string]interface{}{
+		"type":      messages.MsgTypeSendTransaction,
+		"namespace": "spoofed",
+	}
+	msg = map[string]interface{}{
+		"headers": headers,
+		"from":    "0x4b098809e68c88e26442c7ac2f2ca1fb9bb2e37c",
+	}
+	reply, _, err = w.processMsg(context.Background(), msg, true, true)
+	assert.NoError(err)
+	assert.NotContains(headers, "namespace")
+	receipt, err = r.GetReceipt(reply.(*messages.AsyncSentMsg).Request)
+	assert.NoError(err)
+	assert.NotContains(*receipt, "namespace")
+}
+
 func TestWebhookHandlerContractGWFail(t *testing.T) {
 	assert := assert.New(t)
 
@@ -266,6 +328,53 @@ func TestWebhookHandlerTransactionWithID(t *testing.T) {
 	assert.Equal("test-id", asyncResponse.Request)
 }
 
+type keyRecordingHandler struct {
+	mockHandler
+	keys []string
+}
+
+func (h *keyRecordingHandler) sendWebhookMsg(ctx context.Context, key, msgID string, msg map[string]interface{}, ack bool) (msgAck string, statusCode int, err error) {
+	h.keys = append(h.keys, key)
+	return "", 200, nil
+}
+
+func TestWebhookProcessMsgRelayTransactionKey(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := &keyRecordingHandler{}
+	w := &webhooks{handler: handler}
+	_, status, err := w.processMsg(context.Background(), map[string]interface{}{
+		"headers": map[string]interface{}{"type": messages.MsgTypeRelayTransaction},
+	}, false, false)
+	assert.NoError(err)
+	assert.Equal(200, status)
+	_, _, err = w.processMsg(context.Background(), map[string]interface{}{
+		"headers": map[string]interface{}{"type": messages.MsgTypeRelayTransaction},
+		"from":    "0x83dBC8e329b38cBA0Fc4ed99b1Ce9c2a390ABdC1",
+	}, false, false)
+	assert.NoError(err)
+	assert.Equal([]string{messages.MsgTypeRelayTransaction, "0x83dBC8e329b38cBA0Fc4ed99b1Ce9c2a390ABdC1"}, handler.keys)
+}
+
+func TestWebhookWSSubmit(t *testing.T) {
+	assert := assert.New(t)
+
+	w := &webhooks{handler: &keyRecordingHandler{}}
+	reply, awaitReceipt, err := w.wsSubmit(context.Background(), map[string]interface{}{
+		"headers": map[string]interface{}{"type": messages.MsgTypeSendTransaction, "id": "req1"},
+		"from":    "0x83dBC8e329b38cBA0Fc4ed99b1Ce9c2a390ABdC1",
+	})
+	assert.NoError(err)
+	assert.True(awaitReceipt)
+	assert.Equal("req1", reply.(*messages.AsyncSentMsg).Request)
+
+	_, awaitReceipt, err = w.wsSubmit(context.Background(), map[string]interface{}{
+		"headers": map[string]interface{}{"type": "unknown"},
+	})
+	assert.Regexp("FFEC100196", err)
+	assert.False(awaitReceipt)
+}
+
 func TestWebhookHandlerQuery(t *testing.T) {
 	assert := assert.New(t)
 
@@ -362,3 +471,93 @@ func TestWebhookHandlerQueryFail(t *testing.T) {
 	w.webhookHandler(rec, req, false)
 	assert.Equal(500, rec.Result().StatusCode)
 }
+
+// EIP-1559 transaction with nonce 5 on chain 1337, signed by 0x2c7536E3605D9C16a7a3D7b1898e529396a65c23
+const testRawTx = "0x02f8648205390501028252089433333333333333333333333333333333333333330180c080a07b62bd71b7fc2fcc65a4a71fd4dcfd414cd7e47581f14c95e9fef482ca116224a03f43961b20e383510043271e99a1d1e7fe9f269f7262516fddf0c4ac3396f36c"
+
+func newRawTxTestRPC(chainID int64, nonce uint64, sendErr error) *ethmocks.RPCClient {
+	mockRPC := &ethmocks.RPCClient{}
+	mockRPC.On("CallContext", mock.Anything, mock.Anything, "eth_chainId").Run(func(args mock.Arguments) {
+		*(args[1].(*ethbinding.HexBigInt)) = ethbinding.HexBigInt(*big.NewInt(chainID))
+	}).Return(nil)
+	mockRPC.On("CallContext", mock.Anything, mock.Anything, "eth_getTransactionCount", mock.Anything, "pending").Run(func(args mock.Arguments) {
+		*(args[1].(*ethbinding.HexUint64)) = ethbinding.HexUint64(nonce)
+	}).Return(nil)
+	mockRPC.On("CallContext", mock.Anything, mock.Anything, "eth_sendRawTransaction", testRawTx).Run(func(args mock.Arguments) {
+		*(args[1].(*string)) = "0x6c9e8b9a"
+	}).Return(sendErr)
+	return mockRPC
+}
+
+func postRawTxTest(w *webhooks, from, rawTx string) *httptest.ResponseRecorder {
+	msgBytes, _ := json.Marshal(map[string]interface{}{
+		"headers":        map[string]interface{}{"type": messages.MsgTypeSendRawTransaction, "id": "raw1"},
+		"from":           from,
+		"rawTransaction": rawTx,
+	})
+	req, _ := http.NewRequest("POST", "/any", bytes.NewReader(msgBytes))
+	rec := httptest.NewRecorder()
+	w.webhookHandler(rec, req, false)
+	return rec
+}
+
+func TestWebhookHandlerRawTransaction(t *testing.T) {
+	assert := assert.New(t)
+
+	mockRPC := newRawTxTestRPC(1337, 5, nil)
+	rec := postRawTxTest(&webhooks{rpcClient: mockRPC}, "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23", testRawTx)
+	assert.Equal(200, rec.Result().StatusCode)
+	var reply messages.RawTransactionSent
+	assert.NoError(json.NewDecoder(rec.Body).Decode(&reply))
+	assert.True(reply.Sent)
+	assert.Equal("raw1", reply.Request)
+	assert.Equal("0x6c9e8b9a", reply.TransactionHash)
+	mockRPC.AssertExpectations(t)
+}
+
+func TestWebhookHandlerRawTransactionReplayed(t *testing.T) {
+	assert := assert.New(t)
+
+	rec := postRawTxTest(&webhooks{rpcClient: newRawTxTestRPC(1337, 6, nil)}, "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23", testRawTx)
+	assert.Equal(409, rec.Result().StatusCode)
+	assert.Regexp("FFEC100304", rec.Body.String())
+}
+
+func TestWebhookHandlerRawTransactionWrongChain(t *testing.T) {
+	assert := assert.New(t)
+
+	rec := postRawTxTest(&webhooks{rpcClient: newRawTxTestRPC(1, 0, nil)}, "", testRawTx)
+	assert.Equal(400, rec.Result().StatusCode)
+	assert.Regexp("FFEC100301", rec.Body.String())
+}
+
+func TestWebhookHandlerRawTransactionBadPayload(t *testing.T) {
+	assert := assert.New(t)
+
+	w := &webhooks{}
+	rec := postRawTxTest(w, "", "0xf8")
+	assert.Equal(400, rec.Result().StatusCode)
+	assert.Regexp("FFEC100299", rec.Body.String())
+
+	msgBytes, _ := json.Marshal(map[string]interface{}{
+		"headers":        map[string]interface{}{"type": messages.MsgTypeSendRawTransaction},
+		"rawTransaction": false,
+	})
+	req, _ := http.NewRequest("POST", "/any", bytes.NewReader(msgBytes))
+	rec = httptest.NewRecorder()
+	w.webhookHandler(rec, req, false)
+	assert.Equal(400, rec.Result().StatusCode)
+}
+
+func TestWebhookHandlerRawTransactionRPCFail(t *testing.T) {
+	assert := assert.New(t)
+
+	mockRPC := &ethmocks.RPCClient{}
+	mockRPC.On("CallContext", mock.Anything, mock.Anything, "eth_chainId").Return(fmt.Errorf("pop"))
+	rec := postRawTxTest(&webhooks{rpcClient: mockRPC}, "", testRawTx)
+	assert.Equal(500, rec.Result().StatusCode)
+
+	rec = postRawTxTest(&webhooks{rpcClient: newRawTxTestRPC(1337, 5, fmt.Errorf("pop"))}, "", testRawTx)
+	assert.Equal(500, rec.Result().StatusCode)
+	assert.Regexp("eth_sendRawTransaction returned: pop", rec.Body.String())
+}
@@ -21,6 +21,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/hyperledger/firefly-ethconnect/internal/auth"
 	"github.com/hyperledger/firefly-ethconnect/internal/errors"
 	"github.com/hyperledger/firefly-ethconnect/internal/eth"
 	"github.com/hyperledger/firefly-ethconnect/internal/messages"
@@ -45,15 +46,17 @@ type webhooksDirect struct {
 	inFlightMutex sync.Mutex
 	inFlight      map[string]*msgContext
 	stopChan      chan error
+	replySender   *tx.ReplyWebhookSender
 }
 
 func newWebhooksDirect(conf *WebhooksDirectConf, processor tx.TxnProcessor, receipts *receiptStore) *webhooksDirect {
 	return &webhooksDirect{
-		processor: processor,
-		receipts:  receipts,
-		conf:      conf,
-		inFlight:  make(map[string]*msgContext),
-		stopChan:  make(chan error),
+		processor:   processor,
+		receipts:    receipts,
+		conf:        conf,
+		inFlight:    make(map[string]*msgContext),
+		stopChan:    make(chan error),
+		replySender: tx.NewReplyWebhookSender(&conf.ReplyWebhook),
 	}
 }
 
@@ -65,6 +68,7 @@ type msgContext struct {
 	msgID        string
 	msg          map[string]interface{}
 	headers      *messages.CommonHeaders
+	replyWebhook string
 }
 
 func (t *msgContext) Context() context.Context {
@@ -106,6 +110,7 @@ func (t *msgContext) Reply(replyMessage messages.ReplyWithHeaders) {
 	replyHeaders := replyMessage.ReplyHeaders()
 	replyHeaders.ID = utils.UUIDv4()
 	replyHeaders.Context = t.headers.Context
+	replyHeaders.Namespace = t.headers.Namespace
 	replyHeaders.ReqID = t.headers.ID
 	replyHeaders.ReqABIID = t.headers.ABIID
 	replyHeaders.Received = t.timeReceived.UTC().Format(time.RFC3339Nano)
@@ -113,6 +118,9 @@ func (t *msgContext) Reply(replyMessage messages.ReplyWithHeaders) {
 	replyHeaders.Elapsed = replyTime.Sub(t.timeReceived).Seconds()
 	msgBytes, _ := json.Marshal(&replyMessage)
 	t.w.receipts.processReply(msgBytes)
+	if t.replyWebhook != "" {
+		t.w.replySender.SendAsync(t.replyWebhook, msgBytes)
+	}
 	delete(t.w.inFlight, t.msgID)
 }
 
@@ -130,7 +138,7 @@ func (w *webhooksDirect) sendWebhookMsg(ctx context.Context, key, msgID string,
 		return "", 429, errors.Errorf(errors.WebhooksDirectTooManyInflight)
 	}
 
-	var headers messages.CommonHeaders
+	var headers messages.RequestHeaders
 	var headerBytes []byte
 	var err error
 	headersMap := msg["headers"]
@@ -143,13 +151,17 @@ func (w *webhooksDirect) sendWebhookMsg(ctx context.Context, key, msgID string,
 		return "", 400, errors.Errorf(errors.WebhooksDirectBadHeaders)
 	}
 	msgContext := &msgContext{
-		ctx:          context.Background(),
+		// The request completes before the transaction, so only the values of its context are kept
+		ctx:          context.WithoutCancel(ctx),
 		w:            w,
 		timeReceived: time.Now().UTC(),
 		key:          key,
 		msgID:        msgID,
 		msg:          msg,
-		headers:      &headers,
+		headers:      &headers.CommonHeaders,
+	}
+	if tx.ReplyToWebhook(&headers) {
+		msgContext.replyWebhook = headers.ReplyWebhook
 	}
 	w.inFlight[msgID] = msgContext
 	w.inFlightMutex.Unlock()
@@ -158,6 +170,34 @@ func (w *webhooksDirect) sendWebhookMsg(ctx context.Context, key, msgID string,
 	return "", 200, nil
 }
 
+func (w *webhooksDirect) cancelQueued(ctx context.Context, msgID string) (int, error) {
+	w.inFlightMutex.Lock()
+	inFlight := w.inFlight[msgID]
+	w.inFlightMutex.Unlock()
+	if inFlight != nil {
+		if ns := auth.GetNamespace(ctx); ns != "" && ns != inFlight.headers.Namespace {
+			return 404, errors.Errorf(errors.TransactionCancelNotQueued, msgID)
+		}
+		if err := authorizeCancel(ctx, msgID, auth.GetPrincipal(inFlight.ctx)); err != nil {
+			return 403, err
+		}
+	}
+	err := w.processor.CancelQueued(msgID)
+	if ethErr, ok := err.(errors.EthconnectError); ok {
+		switch ethErr.Code() {
+		case errors.TransactionCancelNotQueued.Code():
+			return 404, err
+		case errors.TransactionCancelAlreadySubmitted.Code():
+			return 409, err
+		}
+	}
+	if err != nil {
+		return 500, err
+	}
+	log.Infof("Cancelled queued request %s", msgID)
+	return 200, nil
+}
+
 func validateWebhooksDirectConf(conf *WebhooksDirectConf) error {
 	if conf.RPC.URL == "" {
 		return errors.Errorf(errors.ConfigWebhooksDirectRPC)
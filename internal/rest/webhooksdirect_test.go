@@ -24,6 +24,9 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/hyperledger/firefly-ethconnect/internal/auth"
+	"github.com/hyperledger/firefly-ethconnect/internal/auth/authtest"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
 	"github.com/hyperledger/firefly-ethconnect/internal/eth"
 	"github.com/hyperledger/firefly-ethconnect/internal/messages"
 	"github.com/hyperledger/firefly-ethconnect/internal/receipts"
@@ -34,7 +37,10 @@ import (
 )
 
 type mockProcessor struct {
-	capturedCtx *msgContext
+	capturedCtx  *msgContext
+	cancelled    []string
+	cancelErr    error
+	signingStats *tx.SigningStats
 }
 
 func (p *mockProcessor) ResolveAddress(from string) (string, error) { return "", nil }
@@ -44,6 +50,12 @@ func (p *mockProcessor) OnMessage(ctx tx.TxnContext) {
 func (p *mockProcessor) Init(eth.RPCClient) {}
 func (p *mockProcessor) SetReceiptStoreForIdempotencyCheck(receiptStore receipts.ReceiptStorePersistence) {
 }
+func (p *mockProcessor) CancelQueued(msgID string) error {
+	p.cancelled = append(p.cancelled, msgID)
+	return p.cancelErr
+}
+func (p *mockProcessor) SigningStats() *tx.SigningStats { return p.signingStats }
+func (p *mockProcessor) Close()                         {}
 
 func newTestWebhooksDirect(maxMsgs int) (*webhooksDirect, *receipts.MemoryReceipts, *mockProcessor) {
 	rsc := &receipts.ReceiptStoreConf{}
@@ -115,6 +127,9 @@ func TestWebhooksDirectSubmitSendTransaction(t *testing.T) {
 	err = p.capturedCtx.Unmarshal(&reconstructed)
 	assert.NoError(err)
 	assert.Equal("0xd912641Eb51a311A1C6BD32c1ED200C2a5abD7FE", reconstructed.From)
+
+	// The transaction is processed after the HTTP request has completed
+	assert.NoError(p.capturedCtx.Context().Err())
 }
 
 func TestWebhooksDirectMsgLimit(t *testing.T) {
@@ -157,6 +172,54 @@ func TestWebhooksDirectMsgLimit(t *testing.T) {
 
 }
 
+func TestWebhooksDirectReplyModeBoth(t *testing.T) {
+	assert := assert.New(t)
+
+	received := make(chan []byte, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		received <- b
+	}))
+	defer hook.Close()
+
+	wd, ts, r, p := newTestWebhooksDirectServer(1)
+	defer ts.Close()
+	wd.conf.ReplyWebhook.AllowPrivateIPs = true
+
+	msg := newTestMsg()
+	msg.Headers.ReplyMode = messages.ReplyModeBoth
+	msg.Headers.ReplyWebhook = hook.URL
+	msgBytes, _ := json.Marshal(&msg)
+	resp, err := http.Post(fmt.Sprintf("%s/hook", ts.URL), "application/json", bytes.NewReader(msgBytes))
+	assert.NoError(err)
+	assert.Equal(200, resp.StatusCode)
+
+	p.capturedCtx.SendErrorReply(500, fmt.Errorf("pop"))
+	var reply messages.ErrorReply
+	err = json.Unmarshal(<-received, &reply)
+	assert.NoError(err)
+	assert.Equal("pop", reply.ErrorMessage)
+	receipt, _ := r.GetReceipt(reply.Headers.ReqID)
+	assert.NotNil(receipt)
+}
+
+func TestWebhooksDirectReplyModeInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	_, ts, _, p := newTestWebhooksDirectServer(1)
+	defer ts.Close()
+
+	msg := newTestMsg()
+	msg.Headers.ReplyMode = "pigeon"
+	msgBytes, _ := json.Marshal(&msg)
+	resp, err := http.Post(fmt.Sprintf("%s/hook", ts.URL), "application/json", bytes.NewReader(msgBytes))
+	assert.NoError(err)
+	assert.Equal(400, resp.StatusCode)
+	replyBytes, _ := ioutil.ReadAll(resp.Body)
+	assert.Regexp("FFEC100380", string(replyBytes))
+	assert.Nil(p.capturedCtx)
+}
+
 func TestWebhooksDirectSendWebhooksMsgBadHeaders(t *testing.T) {
 	assert := assert.New(t)
 	wd, _, _ := newTestWebhooksDirect(1)
@@ -175,3 +238,99 @@ func TestWebhooksDirectUnmarshalBadMsg(t *testing.T) {
 	err := ctx.Unmarshal(nil)
 	assert.Regexp("json: unsupported type: map\\[bool\\]string", err)
 }
+
+func TestWebhooksDirectCancelQueued(t *testing.T) {
+	assert := assert.New(t)
+
+	_, ts, _, p := newTestWebhooksDirectServer(1)
+	defer ts.Close()
+	url := fmt.Sprintf("%s/requests/abc123/cancel", ts.URL)
+
+	resp, err := http.Post(url, "application/json", nil)
+	assert.NoError(err)
+	assert.Equal(200, resp.StatusCode)
+	var reply cancelReply
+	json.NewDecoder(resp.Body).Decode(&reply)
+	assert.Equal("abc123", reply.ID)
+	assert.True(reply.Cancelled)
+	assert.Equal([]string{"abc123"}, p.cancelled)
+
+	p.cancelErr = errors.Errorf(errors.TransactionCancelNotQueued, "abc123")
+	resp, err = http.Post(url, "application/json", nil)
+	assert.NoError(err)
+	assert.Equal(404, resp.StatusCode)
+
+	p.cancelErr = errors.Errorf(errors.TransactionCancelAlreadySubmitted, "abc123")
+	resp, err = http.Post(url, "application/json", nil)
+	assert.NoError(err)
+	assert.Equal(409, resp.StatusCode)
+
+	p.cancelErr = fmt.Errorf("pop")
+	resp, err = http.Post(url, "application/json", nil)
+	assert.NoError(err)
+	assert.Equal(500, resp.StatusCode)
+}
+
+func TestWebhooksDirectCancelQueuedAuthorization(t *testing.T) {
+	assert := assert.New(t)
+
+	wd, ts, _, p := newTestWebhooksDirectServer(1)
+	defer ts.Close()
+
+	auth.RegisterSecurityModule(&authtest.TestSecurityModule{})
+	defer auth.RegisterSecurityModule(nil)
+
+	submitterCtx, _ := auth.WithAuthContext(context.Background(), "testat")
+	wd.inFlight["abc123"] = &msgContext{
+		ctx:     submitterCtx,
+		msgID:   "abc123",
+		headers: &messages.CommonHeaders{Namespace: "ns-verified"},
+	}
+
+	otherCtx := auth.WithTLSPrincipal(context.Background(), "other")
+	status, err := wd.cancelQueued(auth.WithNamespace(otherCtx, "ns-verified"), "abc123")
+	assert.Equal(403, status)
+	assert.Regexp("FFEC100484", err)
+
+	status, err = wd.cancelQueued(auth.WithNamespace(otherCtx, "ns-other"), "abc123")
+	assert.Equal(404, status)
+	assert.Regexp("FFEC100310", err)
+	assert.Empty(p.cancelled)
+
+	status, err = wd.cancelQueued(submitterCtx, "abc123")
+	assert.NoError(err)
+	assert.Equal(200, status)
+	assert.Equal([]string{"abc123"}, p.cancelled)
+}
+
+func TestWebhooksDirectDeleteQueued(t *testing.T) {
+	assert := assert.New(t)
+
+	_, ts, _, p := newTestWebhooksDirectServer(1)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/requests/abc123", nil)
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(err)
+	assert.Equal(200, resp.StatusCode)
+	var reply cancelReply
+	json.NewDecoder(resp.Body).Decode(&reply)
+	assert.True(reply.Cancelled)
+	assert.Equal([]string{"abc123"}, p.cancelled)
+}
+
+func TestWebhooksCancelNotSupported(t *testing.T) {
+	assert := assert.New(t)
+
+	router := &httprouter.Router{}
+	newWebhooks(&mockHandler{}, nil, nil, nil, eth.EthCommonConf{}).addRoutes(router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/requests/abc123/cancel", "application/json", nil)
+	assert.NoError(err)
+	assert.Equal(405, resp.StatusCode)
+	var reply hookErrMsg
+	json.NewDecoder(resp.Body).Decode(&reply)
+	assert.Regexp("FFEC100312", reply.Message)
+}
@@ -84,8 +84,9 @@ func (w *webhooksKafka) ConsumerMessagesLoop(consumer kafka.KafkaConsumer, produ
 	for msg := range consumer.Messages() {
 		w.receipts.processReply(msg.Value)
 
-		// Regardless of outcome, we ack
-		consumer.MarkOffset(msg, "")
+		// Regardless of outcome, we ack - once any receipt for the reply has been stored
+		msg := msg
+		w.receipts.afterStored(func() { consumer.MarkOffset(msg, "") })
 	}
 	wg.Done()
 }
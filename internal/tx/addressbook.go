@@ -61,6 +61,7 @@ func NewAddressBook(conf *AddressBookConf, rpcConf *eth.RPCConf) AddressBook {
 	ab := &addressBook{
 		conf:                conf,
 		fallbackRPCEndpoint: rpcConf.RPC.URL,
+		rpcTimeouts:         rpcConf.RPC.Timeouts,
 		hr:                  utils.NewHTTPRequester("Addressbook", &conf.HTTPRequesterConf),
 		addrToHost:          make(map[string]string),
 		hostToRPC:           make(map[string]*cachedRPC),
@@ -97,6 +98,7 @@ type addressBook struct {
 	hr                   *utils.HTTPRequester
 	mtx                  sync.Mutex
 	fallbackRPCEndpoint  string
+	rpcTimeouts          eth.RPCTimeoutsConf
 	healthcheckFrequency time.Duration
 	addrToHost           map[string]string
 	hostToRPC            map[string]*cachedRPC
@@ -175,7 +177,8 @@ func (ab *addressBook) mapEndpoint(ctx context.Context, endpoint string) (eth.RP
 
 	// Connect and cache the RPC connection
 	rpc, err := eth.RPCConnect(&eth.RPCConnOpts{
-		URL: url.String(),
+		URL:      url.String(),
+		Timeouts: ab.rpcTimeouts,
 	})
 	if err != nil {
 		return nil, err
@@ -0,0 +1,97 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tx
+
+import (
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/eth"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+)
+
+// RelayConf configures the submission of EIP-2771 meta-transactions, through a trusted forwarder
+// contract, with gas paid by a relayer account
+type RelayConf struct {
+	Forwarder string `json:"forwarder"`
+	Relayer   string `json:"relayer"`
+}
+
+// validateForwardRequest checks the fields the user must have signed are present, so they
+// are not silently encoded as zero values
+func validateForwardRequest(msg *messages.RelayTransaction) error {
+	req := &msg.Request
+	if _, err := utils.StrToAddress("request.from", req.From); err != nil {
+		return err
+	}
+	if _, err := utils.StrToAddress("request.to", req.To); err != nil {
+		return err
+	}
+	switch {
+	case req.Gas == "":
+		return errors.Errorf(errors.TransactionRelayInvalid, "request.gas is required")
+	case req.Nonce == "":
+		return errors.Errorf(errors.TransactionRelayInvalid, "request.nonce is required")
+	case req.Value != "" && req.Value != "0":
+		// The forwarder passes on value sent by the relayer, so the relayer would pay it
+		return errors.Errorf(errors.TransactionRelayInvalid, "request.value must be zero")
+	case msg.Signature == "":
+		return errors.Errorf(errors.TransactionRelayInvalid, "signature is required")
+	}
+	return nil
+}
+
+// OnRelayTransactionMessage wraps a meta-transaction signed by the user in a call to execute on
+// the forwarder. The transaction is sent from the relayer, which pays the gas, and its nonces are
+// always assigned here so many relayed transactions can be in-flight at once.
+func (p *txnProcessor) OnRelayTransactionMessage(txnContext TxnContext, msg *messages.RelayTransaction) {
+
+	if p.conf.Relay.Forwarder == "" {
+		txnContext.SendErrorReply(400, errors.Errorf(errors.TransactionRelayNotConfigured))
+		return
+	}
+	if msg.From == "" {
+		msg.From = p.conf.Relay.Relayer
+	}
+	if err := validateForwardRequest(msg); err != nil {
+		txnContext.SendErrorReply(400, err)
+		return
+	}
+
+	// The forwarder checks the signature and nonce on-chain, but the relayer would pay for
+	// the failed transaction, so check first with a call
+	if err := eth.VerifyForwardRequest(txnContext.Context(), p.rpc, p.conf.Relay.Forwarder, &msg.Request, msg.Signature); err != nil {
+		txnContext.SendErrorReply(400, err)
+		return
+	}
+
+	inflight, err := p.addInflightWrapper(txnContext, &msg.TransactionCommon)
+	if err != nil {
+		txnContext.SendErrorReply(400, err)
+		return
+	}
+	if inflight == nil {
+		// Skip sending due to idempotency check - any reply is already handled
+		return
+	}
+
+	tx, err := eth.NewForwardTxn(inflight.signer, msg.From, p.conf.Relay.Forwarder, inflight.nonceNumber(), msg.Gas, msg.GasPrice, &msg.Request, msg.Signature)
+	if err != nil {
+		p.cancelInFlight(inflight, false /* not yet submitted */)
+		txnContext.SendErrorReply(400, err)
+		return
+	}
+
+	p.sendTransactionCommon(txnContext, inflight, tx)
+}
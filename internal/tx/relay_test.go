@@ -0,0 +1,128 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tx
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/eth"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testForwarderAddr = "0x5fbdb2315678afecb367f032d93f642f64180aa3"
+	testVerifyTrue    = "0x0000000000000000000000000000000000000000000000000000000000000001"
+	testVerifyFalse   = "0x0000000000000000000000000000000000000000000000000000000000000000"
+)
+
+func testRelayTxnJSON(extra string) string {
+	return `{
+		"headers": {"type": "RelayTransaction"},
+		"request": {
+			"from": "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+			"to": "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+			"gas": "100000",
+			"nonce": "3",
+			"data": "0x60fe47b1000000000000000000000000000000000000000000000000000000000000000a"
+		},
+		"signature": "0x1234"` + extra + `
+	}`
+}
+
+func newTestRelayProcessor(testRPC *testRPC) *txnProcessor {
+	txnProcessor := NewTxnProcessor(&TxnProcessorConf{
+		MaxTXWaitTime:   1,
+		SendConcurrency: 1,
+		Relay: RelayConf{
+			Forwarder: testForwarderAddr,
+			Relayer:   testFromAddr,
+		},
+	}, &eth.RPCConf{}).(*txnProcessor)
+	txnProcessor.Init(testRPC)
+	return txnProcessor
+}
+
+func TestOnRelayTransactionMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	testRPC := goodMessageRPC()
+	testRPC.ethCallResult = testVerifyTrue
+	testRPC.ethGetTransactionCountResult = 5
+	txnProcessor := newTestRelayProcessor(testRPC)
+
+	testTxnContext := &testTxnContext{jsonMsg: testRelayTxnJSON(`, "gas": "200000"`)}
+	txnProcessor.OnMessage(testTxnContext)
+
+	for len(testTxnContext.replies) == 0 && len(testTxnContext.errorReplies) == 0 {
+		time.Sleep(1 * time.Millisecond)
+	}
+	assert.Empty(testTxnContext.errorReplies)
+	// The relayer nonce is assigned here, even though the node could sign for it
+	assert.Equal([]string{"eth_call", "eth_getTransactionCount", "eth_sendTransaction"}, testRPC.calls[0:3])
+	callTX := testRPC.params[0][0].(*eth.SendTXArgs)
+	assert.Equal(testForwarderAddr, strings.ToLower(callTX.To))
+	assert.Equal(hex.EncodeToString(eth.ForwarderVerifyMethod().ID), hex.EncodeToString((*callTX.Data)[0:4]))
+
+	sendTX := testRPC.params[2][0].(*eth.SendTXArgs)
+	assert.Equal(uint64(5), uint64(*sendTX.Nonce))
+	assert.Equal(uint64(200000), uint64(*sendTX.Gas))
+	assert.True(strings.EqualFold(testFromAddr, sendTX.From))
+	assert.Equal(testForwarderAddr, strings.ToLower(sendTX.To))
+	assert.Equal(int64(0), sendTX.Value.ToInt().Int64())
+	assert.Equal(hex.EncodeToString(eth.ForwarderExecuteMethod().ID), hex.EncodeToString((*sendTX.Data)[0:4]))
+	assert.Contains(hex.EncodeToString(*sendTX.Data), "60fe47b1000000000000000000000000000000000000000000000000000000000000000a")
+}
+
+func TestOnRelayTransactionMessageErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	txnProcessor := NewTxnProcessor(&TxnProcessorConf{}, &eth.RPCConf{}).(*txnProcessor)
+	txnProcessor.Init(&testRPC{})
+	txnContext := &testTxnContext{jsonMsg: testRelayTxnJSON("")}
+	txnProcessor.OnMessage(txnContext)
+	assert.Regexp("FFEC100360", txnContext.errorReplies[0].err)
+
+	testRPC := &testRPC{ethCallResult: testVerifyFalse}
+	txnProcessor = newTestRelayProcessor(testRPC)
+	for _, test := range []struct {
+		jsonMsg string
+		regexp  string
+	}{
+		{`{"headers":{"type":"RelayTransaction"},"request":{"from":"bad"}}`, "request.from"},
+		{`{"headers":{"type":"RelayTransaction"},"request":{"from":"` + testFromAddr + `"}}`, "request.to"},
+		{`{"headers":{"type":"RelayTransaction"},"request":{"from":"` + testFromAddr + `","to":"` + testFromAddr + `"}}`, "request.gas"},
+		{`{"headers":{"type":"RelayTransaction"},"request":{"from":"` + testFromAddr + `","to":"` + testFromAddr + `","gas":"1"}}`, "request.nonce"},
+		{`{"headers":{"type":"RelayTransaction"},"request":{"from":"` + testFromAddr + `","to":"` + testFromAddr + `","gas":"1","nonce":"1","value":"10"}}`, "request.value"},
+		{`{"headers":{"type":"RelayTransaction"},"request":{"from":"` + testFromAddr + `","to":"` + testFromAddr + `","gas":"1","nonce":"1"}}`, "signature"},
+		{testRelayTxnJSON(""), "FFEC100362.*0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa.*3"},
+		{`{"headers":{"type":"RelayTransaction"},"request":false}`, "cannot unmarshal"},
+	} {
+		txnContext := &testTxnContext{jsonMsg: test.jsonMsg}
+		txnProcessor.OnMessage(txnContext)
+		assert.Equal(400, txnContext.errorReplies[0].status, test.jsonMsg)
+		assert.Regexp(test.regexp, txnContext.errorReplies[0].err, test.jsonMsg)
+	}
+	assert.Equal([]string{"eth_call"}, testRPC.calls)
+
+	// Failures building the transaction release the nonce
+	testRPC.ethCallResult = testVerifyTrue
+	txnContext = &testTxnContext{jsonMsg: testRelayTxnJSON(`, "gasPrice": "not a number"`)}
+	txnProcessor.OnMessage(txnContext)
+	assert.Equal(400, txnContext.errorReplies[0].status)
+	assert.Empty(txnProcessor.inflightTxns)
+}
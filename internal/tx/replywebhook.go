@@ -0,0 +1,131 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tx
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultReplyWebhookTimeout = 30 * time.Second
+
+// ReplyWebhookConf configures the delivery of replies to the replyWebhook of a request
+type ReplyWebhookConf struct {
+	TimeoutMS       int  `json:"timeoutMS,omitempty"`
+	AllowPrivateIPs bool `json:"allowPrivateIPs,omitempty"`
+}
+
+// ValidateReplyMode checks the reply mode and webhook of a request, before it is accepted
+func ValidateReplyMode(headers *messages.RequestHeaders) error {
+	switch headers.ReplyMode {
+	case "", messages.ReplyModeKafka, messages.ReplyModeNone:
+		return nil
+	case messages.ReplyModeWebhook, messages.ReplyModeBoth:
+		u, err := url.Parse(headers.ReplyWebhook)
+		if headers.ReplyWebhook == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.Errorf(errors.ReplyModeWebhookRequired, headers.ReplyMode)
+		}
+		return nil
+	default:
+		return errors.Errorf(errors.ReplyModeInvalid, headers.ReplyMode)
+	}
+}
+
+// ReplyToKafka checks if the reply to a request is sent to the Kafka reply topic
+func ReplyToKafka(headers *messages.RequestHeaders) bool {
+	return headers.ReplyMode == "" || headers.ReplyMode == messages.ReplyModeKafka || headers.ReplyMode == messages.ReplyModeBoth
+}
+
+// ReplyToWebhook checks if the reply to a request is posted to its replyWebhook
+func ReplyToWebhook(headers *messages.RequestHeaders) bool {
+	return headers.ReplyMode == messages.ReplyModeWebhook || headers.ReplyMode == messages.ReplyModeBoth
+}
+
+// ReplyWebhookSender posts replies to the webhooks requested with a reply mode. Delivery is
+// best effort, as the reply is always recorded in the receipt store.
+type ReplyWebhookSender struct {
+	conf   *ReplyWebhookConf
+	client *http.Client
+}
+
+// NewReplyWebhookSender constructor
+func NewReplyWebhookSender(conf *ReplyWebhookConf) *ReplyWebhookSender {
+	return &ReplyWebhookSender{
+		conf:   conf,
+		client: &http.Client{},
+	}
+}
+
+// SendAsync posts the reply in the background, logging any failure
+func (s *ReplyWebhookSender) SendAsync(webhook string, replyBytes []byte) {
+	timeout := defaultReplyWebhookTimeout
+	if s.conf.TimeoutMS > 0 {
+		timeout = time.Duration(s.conf.TimeoutMS) * time.Millisecond
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := s.Send(ctx, webhook, replyBytes); err != nil {
+			log.Errorf("Failed to deliver reply to webhook '%s': %s", webhook, err)
+		}
+	}()
+}
+
+// Send posts the reply to the webhook
+func (s *ReplyWebhookSender) Send(ctx context.Context, webhook string, replyBytes []byte) error {
+	u, err := url.Parse(webhook)
+	if err != nil {
+		return err
+	}
+	if !s.conf.AllowPrivateIPs {
+		addr, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+		if err != nil {
+			return err
+		}
+		for _, ip := range addr {
+			if isPrivateIP(ip.IP) {
+				return errors.Errorf(errors.ReplyWebhookUnsafeAddress, webhook)
+			}
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(replyBytes))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.Errorf(errors.ReplyWebhookHTTPStatus, webhook, res.StatusCode)
+	}
+	log.Infof("Delivered reply to webhook '%s' [%d]", webhook, res.StatusCode)
+	return nil
+}
+
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast()
+}
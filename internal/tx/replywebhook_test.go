@@ -0,0 +1,106 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tx
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/messages"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateReplyMode(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, mode := range []string{"", messages.ReplyModeKafka, messages.ReplyModeNone} {
+		assert.NoError(ValidateReplyMode(&messages.RequestHeaders{ReplyMode: mode}))
+	}
+	for _, mode := range []string{messages.ReplyModeWebhook, messages.ReplyModeBoth} {
+		assert.NoError(ValidateReplyMode(&messages.RequestHeaders{ReplyMode: mode, ReplyWebhook: "https://example.com/replies"}))
+		err := ValidateReplyMode(&messages.RequestHeaders{ReplyMode: mode})
+		assert.Regexp("FFEC100381", err)
+		err = ValidateReplyMode(&messages.RequestHeaders{ReplyMode: mode, ReplyWebhook: "ftp://example.com"})
+		assert.Regexp("FFEC100381", err)
+		err = ValidateReplyMode(&messages.RequestHeaders{ReplyMode: mode, ReplyWebhook: ":::"})
+		assert.Regexp("FFEC100381", err)
+	}
+	err := ValidateReplyMode(&messages.RequestHeaders{ReplyMode: "pigeon"})
+	assert.Regexp("FFEC100380.*pigeon", err)
+}
+
+func TestReplyModeDestinations(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(ReplyToKafka(&messages.RequestHeaders{}))
+	assert.False(ReplyToWebhook(&messages.RequestHeaders{}))
+	assert.True(ReplyToKafka(&messages.RequestHeaders{ReplyMode: messages.ReplyModeKafka}))
+	assert.True(ReplyToKafka(&messages.RequestHeaders{ReplyMode: messages.ReplyModeBoth}))
+	assert.True(ReplyToWebhook(&messages.RequestHeaders{ReplyMode: messages.ReplyModeBoth}))
+	assert.False(ReplyToKafka(&messages.RequestHeaders{ReplyMode: messages.ReplyModeWebhook}))
+	assert.True(ReplyToWebhook(&messages.RequestHeaders{ReplyMode: messages.ReplyModeWebhook}))
+	assert.False(ReplyToKafka(&messages.RequestHeaders{ReplyMode: messages.ReplyModeNone}))
+	assert.False(ReplyToWebhook(&messages.RequestHeaders{ReplyMode: messages.ReplyModeNone}))
+}
+
+func TestReplyWebhookSend(t *testing.T) {
+	assert := assert.New(t)
+
+	received := make(chan string, 1)
+	svr := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		assert.Equal("application/json", req.Header.Get("Content-Type"))
+		b, _ := ioutil.ReadAll(req.Body)
+		received <- string(b)
+		res.WriteHeader(204)
+	}))
+	defer svr.Close()
+
+	s := NewReplyWebhookSender(&ReplyWebhookConf{AllowPrivateIPs: true, TimeoutMS: 1000})
+	s.SendAsync(svr.URL, []byte(`{"headers":{"type":"TransactionSuccess"}}`))
+	assert.Equal(`{"headers":{"type":"TransactionSuccess"}}`, <-received)
+}
+
+func TestReplyWebhookSendFailStatus(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(500)
+	}))
+	defer svr.Close()
+
+	s := NewReplyWebhookSender(&ReplyWebhookConf{AllowPrivateIPs: true})
+	err := s.Send(context.Background(), svr.URL, []byte(`{}`))
+	assert.Regexp(t, "FFEC100383.*500", err)
+}
+
+func TestReplyWebhookSendPrivateAddress(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		t.Fatal("unexpected request")
+	}))
+	defer svr.Close()
+
+	s := NewReplyWebhookSender(&ReplyWebhookConf{})
+	err := s.Send(context.Background(), svr.URL, []byte(`{}`))
+	assert.Regexp(t, "FFEC100382", err)
+}
+
+func TestReplyWebhookSendBadURL(t *testing.T) {
+	s := NewReplyWebhookSender(&ReplyWebhookConf{AllowPrivateIPs: true})
+	err := s.Send(context.Background(), ":::", []byte(`{}`))
+	assert.Error(t, err)
+	err = s.Send(context.Background(), "http://localhost:0", []byte(`{}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tx
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SigningStats reports the activity of the signing worker pool
+type SigningStats struct {
+	Workers   int     `json:"workers"`
+	Busy      int     `json:"busy"`
+	Queued    int     `json:"queued"`
+	Senders   int     `json:"senders"`
+	Completed int64   `json:"completed"`
+	AverageMS float64 `json:"averageMS"`
+}
+
+// signingPool signs, encodes and submits transactions on a bounded set of workers. Each sender
+// has a queue that is worked by one worker at a time, so a sender's transactions are submitted
+// in the order their nonces were assigned, while the transactions of different senders are
+// submitted in parallel.
+type signingPool struct {
+	lock      sync.Mutex
+	cond      *sync.Cond
+	workers   int
+	pending   map[string][]func()
+	runnable  []string
+	busy      int
+	queued    int
+	completed int64
+	totalTime time.Duration
+	closed    bool
+}
+
+func newSigningPool(workers int) *signingPool {
+	sp := &signingPool{
+		workers: workers,
+		pending: make(map[string][]func()),
+	}
+	sp.cond = sync.NewCond(&sp.lock)
+	for i := 0; i < workers; i++ {
+		go sp.worker()
+	}
+	log.Infof("Signing worker pool started with %d workers", workers)
+	return sp
+}
+
+// submit queues the work behind any other work for the same sender. Once the pool is closed
+// the workers exit when there is no queued work, so a worker is started for a sender that is
+// not already queued or being worked, and exits again once the queued work is done.
+func (sp *signingPool) submit(sender string, work func()) {
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	queue, exists := sp.pending[sender]
+	if !exists {
+		// The sender is not queued or being worked, so is ready for a worker
+		sp.runnable = append(sp.runnable, sender)
+		if sp.closed {
+			go sp.worker()
+		} else {
+			sp.cond.Signal()
+		}
+	}
+	sp.pending[sender] = append(queue, work)
+	sp.queued++
+}
+
+// next waits for a sender that has work and no worker, and takes its oldest work. The
+// sender stays in the pending map while it is worked, so no other worker picks it up.
+// Once the pool is closed, and the queued work has been taken, next returns false.
+func (sp *signingPool) next() (string, func(), bool) {
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	for len(sp.runnable) == 0 {
+		if sp.closed {
+			return "", nil, false
+		}
+		sp.cond.Wait()
+	}
+	sender := sp.runnable[0]
+	sp.runnable = sp.runnable[1:]
+	work := sp.pending[sender][0]
+	sp.pending[sender] = sp.pending[sender][1:]
+	sp.queued--
+	sp.busy++
+	return sender, work, true
+}
+
+func (sp *signingPool) done(sender string, elapsed time.Duration) {
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	sp.busy--
+	sp.completed++
+	sp.totalTime += elapsed
+	if len(sp.pending[sender]) > 0 {
+		sp.runnable = append(sp.runnable, sender)
+		sp.cond.Signal()
+	} else {
+		delete(sp.pending, sender)
+	}
+}
+
+func (sp *signingPool) worker() {
+	for {
+		sender, work, ok := sp.next()
+		if !ok {
+			return
+		}
+		start := time.Now()
+		work()
+		sp.done(sender, time.Since(start))
+	}
+}
+
+// close stops the workers once they have run the work already queued. That work completes
+// quickly after the processor is closed, as requests that have not been sent are cancelled.
+func (sp *signingPool) close() {
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	sp.closed = true
+	sp.cond.Broadcast()
+}
+
+func (sp *signingPool) stats() *SigningStats {
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	stats := &SigningStats{
+		Workers:   sp.workers,
+		Busy:      sp.busy,
+		Queued:    sp.queued,
+		Senders:   len(sp.pending),
+		Completed: sp.completed,
+	}
+	if sp.completed > 0 {
+		stats.AverageMS = float64(sp.totalTime.Microseconds()) / float64(sp.completed) / 1000
+	}
+	return stats
+}
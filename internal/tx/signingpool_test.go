@@ -0,0 +1,238 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tx
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/eth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigningPoolOrderedPerSender(t *testing.T) {
+	assert := assert.New(t)
+	sp := newSigningPool(4)
+
+	var lock sync.Mutex
+	order := map[string][]int{}
+	running := map[string]bool{}
+	overlapped := false
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		for _, sender := range []string{"0xaaaa", "0xbbbb", "0xcccc"} {
+			sender, i := sender, i
+			wg.Add(1)
+			sp.submit(sender, func() {
+				defer wg.Done()
+				lock.Lock()
+				overlapped = overlapped || running[sender]
+				running[sender] = true
+				lock.Unlock()
+				time.Sleep(100 * time.Microsecond)
+				lock.Lock()
+				running[sender] = false
+				order[sender] = append(order[sender], i)
+				lock.Unlock()
+			})
+		}
+	}
+	wg.Wait()
+
+	assert.False(overlapped)
+	for _, sender := range []string{"0xaaaa", "0xbbbb", "0xcccc"} {
+		for i, n := range order[sender] {
+			assert.Equal(i, n)
+		}
+	}
+	assert.Eventually(func() bool { return sp.stats().Completed == 60 }, time.Second, time.Millisecond)
+	stats := sp.stats()
+	assert.Equal(4, stats.Workers)
+	assert.Zero(stats.Busy)
+	assert.Zero(stats.Queued)
+	assert.Zero(stats.Senders)
+	assert.Greater(stats.AverageMS, float64(0))
+}
+
+func TestSigningPoolParallelAcrossSenders(t *testing.T) {
+	assert := assert.New(t)
+	sp := newSigningPool(2)
+
+	// The first transaction of one sender blocks, which does not hold up another sender
+	block := make(chan struct{})
+	done := make(chan string, 3)
+	sp.submit("0xaaaa", func() { <-block; done <- "a1" })
+	sp.submit("0xaaaa", func() { done <- "a2" })
+	sp.submit("0xbbbb", func() { done <- "b1" })
+	assert.Equal("b1", <-done)
+
+	assert.Eventually(func() bool {
+		stats := sp.stats()
+		return stats.Busy == 1 && stats.Queued == 1 && stats.Senders == 1
+	}, time.Second, time.Millisecond)
+
+	close(block)
+	assert.Equal("a1", <-done)
+	assert.Equal("a2", <-done)
+}
+
+func TestOnSendTransactionMessageSigningPool(t *testing.T) {
+	assert := assert.New(t)
+
+	txnProcessor := NewTxnProcessor(&TxnProcessorConf{
+		MaxTXWaitTime:      1,
+		AlwaysManageNonce:  true,
+		SigningConcurrency: 2,
+	}, &eth.RPCConf{}).(*txnProcessor)
+	assert.Nil(txnProcessor.SigningStats())
+	testRPC := goodMessageRPC()
+	testRPC.ethGetTransactionCountResult = 10
+	txnProcessor.Init(testRPC)
+
+	contexts := []*testTxnContext{}
+	for i := 0; i < 3; i++ {
+		testTxnContext := &testTxnContext{jsonMsg: goodSendTxnJSON}
+		contexts = append(contexts, testTxnContext)
+		txnProcessor.OnMessage(testTxnContext)
+	}
+	for _, testTxnContext := range contexts {
+		for len(testTxnContext.replies) == 0 {
+			time.Sleep(1 * time.Millisecond)
+		}
+	}
+
+	// The transactions were sent in nonce order
+	nonces := []uint64{}
+	for i, method := range testRPC.calls {
+		if method == "eth_sendTransaction" {
+			nonces = append(nonces, uint64(*testRPC.params[i][0].(*eth.SendTXArgs).Nonce))
+		}
+	}
+	assert.Equal([]uint64{10, 11, 12}, nonces)
+	assert.Equal(int64(3), txnProcessor.SigningStats().Completed)
+}
+
+func TestSigningPoolClose(t *testing.T) {
+	assert := assert.New(t)
+	sp := newSigningPool(2)
+
+	block := make(chan struct{})
+	done := make(chan string, 3)
+	sp.submit("0xaaaa", func() { <-block; done <- "a1" })
+	sp.submit("0xaaaa", func() { done <- "a2" })
+
+	// Work already queued is still run when the pool is closed
+	sp.close()
+	close(block)
+	assert.Equal("a1", <-done)
+	assert.Equal("a2", <-done)
+	assert.Eventually(func() bool {
+		stats := sp.stats()
+		return stats.Busy == 0 && stats.Senders == 0
+	}, time.Second, time.Millisecond)
+
+	// Work submitted after the workers have gone is still run in order for the sender
+	block = make(chan struct{})
+	sp.submit("0xaaaa", func() { <-block; done <- "a3" })
+	sp.submit("0xaaaa", func() { done <- "a4" })
+	time.Sleep(10 * time.Millisecond)
+	assert.Empty(done)
+	close(block)
+	assert.Equal("a3", <-done)
+	assert.Equal("a4", <-done)
+	assert.Eventually(func() bool {
+		stats := sp.stats()
+		return stats.Busy == 0 && stats.Senders == 0
+	}, time.Second, time.Millisecond)
+	_, _, ok := sp.next()
+	assert.False(ok)
+}
+
+func TestSigningPoolQueueBounded(t *testing.T) {
+	assert := assert.New(t)
+
+	processor := NewTxnProcessor(&TxnProcessorConf{
+		AlwaysManageNonce:  true,
+		SigningConcurrency: 2,
+	}, &eth.RPCConf{}).(*txnProcessor)
+	processor.Init(goodMessageRPC())
+	defer processor.Close()
+	assert.Equal(2, cap(processor.concurrencySlots))
+
+	// A higher sendConcurrency allows more sends to queue for the workers
+	processor2 := NewTxnProcessor(&TxnProcessorConf{
+		AlwaysManageNonce:  true,
+		SigningConcurrency: 2,
+		SendConcurrency:    4,
+	}, &eth.RPCConf{}).(*txnProcessor)
+	processor2.Init(goodMessageRPC())
+	defer processor2.Close()
+	assert.Equal(4, cap(processor2.concurrencySlots))
+}
+
+func TestSigningPoolCancelQueued(t *testing.T) {
+	assert := assert.New(t)
+
+	zero := 0
+	txnProcessor := NewTxnProcessor(&TxnProcessorConf{
+		MaxTXWaitTime:      1,
+		AlwaysManageNonce:  true,
+		SigningConcurrency: 1,
+		SendRetryMax:       &zero,
+	}, &eth.RPCConf{}).(*txnProcessor)
+	testRPC := goodMessageRPC()
+	txnProcessor.Init(testRPC)
+	defer txnProcessor.Close()
+
+	// With every slot taken, the consumer loop waits and the request can be cancelled
+	txnProcessor.concurrencySlots <- true
+	txnContext := &testTxnContext{}
+	txnContext.jsonMsg = "{" +
+		"  \"headers\":{\"type\": \"SendTransaction\", \"id\": \"queued1\"}," +
+		"  \"from\":\"" + testFromAddr + "\"," +
+		"  \"gas\":\"123\"," +
+		"  \"method\":{\"name\":\"test\"}" +
+		"}"
+	done := make(chan struct{})
+	go func() {
+		txnProcessor.OnMessage(txnContext)
+		close(done)
+	}()
+	assert.Eventually(func() bool {
+		return txnProcessor.CancelQueued("queued1") == nil
+	}, time.Second, time.Millisecond)
+	<-done
+	assert.Equal(409, txnContext.errorReplies[0].status)
+	assert.Regexp("FFEC100309", txnContext.errorReplies[0].err)
+	assert.NotContains(testRPC.calls, "eth_sendTransaction")
+	<-txnProcessor.concurrencySlots
+
+	// Work cancelled while waiting for a worker is not sent, and gives back its slot
+	block := make(chan struct{})
+	txnProcessor.signingPool.submit(strings.ToLower(testFromAddr), func() { <-block })
+	txnContext2 := &testTxnContext{}
+	txnContext2.jsonMsg = strings.Replace(txnContext.jsonMsg, "queued1", "queued2", 1)
+	txnProcessor.OnMessage(txnContext2)
+	assert.Len(txnProcessor.concurrencySlots, 1)
+	assert.NoError(txnProcessor.CancelQueued("queued2"))
+	close(block)
+	assert.Eventually(func() bool {
+		return len(txnProcessor.concurrencySlots) == 0
+	}, time.Second, time.Millisecond)
+	assert.Equal(409, txnContext2.errorReplies[0].status)
+	assert.NotContains(testRPC.calls, "eth_sendTransaction")
+}
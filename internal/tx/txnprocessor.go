@@ -15,8 +15,10 @@
 package tx
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -49,6 +51,9 @@ type TxnProcessor interface {
 	Init(eth.RPCClient)
 	ResolveAddress(from string) (resolvedFrom string, err error)
 	SetReceiptStoreForIdempotencyCheck(receiptStore receipts.ReceiptStorePersistence)
+	CancelQueued(msgID string) error
+	SigningStats() *SigningStats
+	Close()
 }
 
 var highestID = 1000000
@@ -62,6 +67,8 @@ type inflightTxn struct {
 	privacyGroupID   string
 	initialWaitDelay time.Duration
 	txnContext       TxnContext
+	ctx              context.Context // cancelled if the caller goes away, or the processor is closed
+	cancelCtx        func()
 	tx               *eth.Txn
 	wg               sync.WaitGroup
 	registerAs       string // passed from request to reply
@@ -70,6 +77,9 @@ type inflightTxn struct {
 	gapFillSucceeded bool
 	gapFillTxHash    string
 	idempotencyCheck bool
+	priority         bool          // replaces a nonce already in-flight, so does not wait for a send slot
+	queued           bool          // nonce assigned, but not yet passed to the node
+	cancelled        chan struct{} // closed by CancelQueued
 }
 
 func (i *inflightTxn) nonceNumber() json.Number {
@@ -87,19 +97,24 @@ func (i *inflightTxn) String() string {
 // TxnProcessorConf configuration for the message processor
 type TxnProcessorConf struct {
 	eth.EthCommonConf
-	AlwaysManageNonce   bool            `json:"alwaysManageNonce"`
-	AttemptGapFill      bool            `json:"attemptGapFill"`
-	MaxTXWaitTime       int             `json:"maxTXWaitTime"`
-	SendConcurrency     int             `json:"sendConcurrency"`
-	OrionPrivateAPIS    bool            `json:"orionPrivateAPIs"`
-	HexValuesInReceipt  bool            `json:"hexValuesInReceipt"`
-	AddressBookConf     AddressBookConf `json:"addressBook"`
-	HDWalletConf        HDWalletConf    `json:"hdWallet"`
-	SendRetryForce      bool            `json:"sendRetryForce,omitempty"`
-	SendRetryDelayMinMS *int            `json:"sendRetryDelayMinMS,omitempty"`
-	SendRetryDelayMaxMS *int            `json:"sendRetryDelayMaxMS,omitempty"`
-	SendRetryMax        *int            `json:"sendRetryMax,omitempty"`
-	SendRetryFactor     *float64        `json:"sendRetryFactor,omitempty"`
+	AlwaysManageNonce   bool             `json:"alwaysManageNonce"`
+	AttemptGapFill      bool             `json:"attemptGapFill"`
+	MaxTXWaitTime       int              `json:"maxTXWaitTime"`
+	SendConcurrency     int              `json:"sendConcurrency"`
+	SigningConcurrency  int              `json:"signingConcurrency,omitempty"`
+	OrionPrivateAPIS    bool             `json:"orionPrivateAPIs"`
+	HexValuesInReceipt  bool             `json:"hexValuesInReceipt"`
+	LogsInReceipt       bool             `json:"logsInReceipt"`
+	ChainProfile        string           `json:"chainProfile,omitempty"`
+	AddressBookConf     AddressBookConf  `json:"addressBook"`
+	HDWalletConf        HDWalletConf     `json:"hdWallet"`
+	Relay               RelayConf        `json:"relay"`
+	ReplyWebhook        ReplyWebhookConf `json:"replyWebhook,omitempty"`
+	SendRetryForce      bool             `json:"sendRetryForce,omitempty"`
+	SendRetryDelayMinMS *int             `json:"sendRetryDelayMinMS,omitempty"`
+	SendRetryDelayMaxMS *int             `json:"sendRetryDelayMaxMS,omitempty"`
+	SendRetryMax        *int             `json:"sendRetryMax,omitempty"`
+	SendRetryFactor     *float64         `json:"sendRetryFactor,omitempty"`
 }
 
 type inflightTxnState struct {
@@ -119,8 +134,11 @@ type txnProcessor struct {
 	rpcConf             *eth.RPCConf
 	concurrencySlots    chan bool
 	concurrency         int64
+	signingPool         *signingPool
 	gasEstimationFactor float64
 	receiptStore        receipts.ReceiptStorePersistence
+	ctx                 context.Context
+	cancelCtx           func()
 
 	sendRetryForce    bool
 	sendRetryDelayMin time.Duration
@@ -142,9 +160,35 @@ func NewTxnProcessor(conf *TxnProcessorConf, rpcConf *eth.RPCConf) TxnProcessor
 		rpcConf:             rpcConf,
 		gasEstimationFactor: conf.GasEstimationFactor,
 	}
+	p.ctx, p.cancelCtx = context.WithCancel(context.Background())
 	return p
 }
 
+// Close cancels the context of every request in-flight, so sends waiting to be retried or for
+// a slot, and transactions waiting for a receipt, are completed with an error straight away
+func (p *txnProcessor) Close() {
+	p.cancelCtx()
+	if p.signingPool != nil {
+		p.signingPool.close()
+	}
+}
+
+// requestContext is the context used for the processing of a request. It is cancelled when the
+// context of the request is, such as when a synchronous REST caller disconnects, or when the
+// processor is closed.
+func (p *txnProcessor) requestContext(txnContext TxnContext) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(txnContext.Context())
+	stop := context.AfterFunc(p.ctx, cancel)
+	if p.ctx.Err() != nil {
+		// AfterFunc cancels on another goroutine, and we must not start a send after closing
+		cancel()
+	}
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
 func (p *txnProcessor) Init(rpc eth.RPCClient) {
 	p.rpc = rpc
 	p.maxTXWaitTime = time.Duration(p.conf.MaxTXWaitTime) * time.Second
@@ -154,7 +198,16 @@ func (p *txnProcessor) Init(rpc eth.RPCClient) {
 	if p.conf.HDWalletConf.URLTemplate != "" {
 		p.hdwallet = newHDWallet(&p.conf.HDWalletConf)
 	}
-	p.concurrencySlots = make(chan bool, p.conf.SendConcurrency)
+	sendSlots := p.conf.SendConcurrency
+	if p.conf.SigningConcurrency > 0 {
+		p.signingPool = newSigningPool(p.conf.SigningConcurrency)
+		// Sends queued for the pool hold a slot, so there are never more than this many
+		// transactions waiting on the workers, and the consumer loop waits for a slot
+		if sendSlots < p.conf.SigningConcurrency {
+			sendSlots = p.conf.SigningConcurrency
+		}
+	}
+	p.concurrencySlots = make(chan bool, sendSlots)
 
 	p.sendRetryForce = p.conf.SendRetryForce
 	p.sendRetryDelayMin = defaultSendRetryMinDelay
@@ -189,8 +242,10 @@ func (p *txnProcessor) SetReceiptStoreForIdempotencyCheck(receiptStore receipts.
 func CobraInitTxnProcessor(cmd *cobra.Command, txconf *TxnProcessorConf) {
 	cmd.Flags().IntVarP(&txconf.MaxTXWaitTime, "tx-timeout", "x", utils.DefInt("ETH_TX_TIMEOUT", 0), "Maximum wait time for an individual transaction (seconds)")
 	cmd.Flags().BoolVarP(&txconf.HexValuesInReceipt, "hex-values", "H", false, "Include hex values for large numbers in receipts (as well as numeric strings)")
+	cmd.Flags().BoolVarP(&txconf.LogsInReceipt, "receipt-logs", "", false, "Include the logs emitted by the transaction in receipts")
 	cmd.Flags().BoolVarP(&txconf.AlwaysManageNonce, "predict-nonces", "P", false, "Predict the next nonce before sending (default=false for node-signed txns)")
 	cmd.Flags().BoolVarP(&txconf.OrionPrivateAPIS, "orion-privapi", "G", false, "Use Orion JSON/RPC API semantics for private transactions")
+	cmd.Flags().StringVarP(&txconf.ChainProfile, "chain-profile", "", os.Getenv("ETH_CHAIN_PROFILE"), "Chain specific receipt fields to include: generic, optimism, arbitrum")
 }
 
 // OnMessage checks the type and dispatches to the correct logic
@@ -215,6 +270,18 @@ func (p *txnProcessor) OnMessage(txnContext TxnContext) {
 			break
 		}
 		p.OnSendTransactionMessage(txnContext, &sendTransactionMsg)
+	case messages.MsgTypeCancelTransaction:
+		var cancelTransactionMsg messages.CancelTransaction
+		if unmarshalErr = txnContext.Unmarshal(&cancelTransactionMsg); unmarshalErr != nil {
+			break
+		}
+		p.OnCancelTransactionMessage(txnContext, &cancelTransactionMsg)
+	case messages.MsgTypeRelayTransaction:
+		var relayTransactionMsg messages.RelayTransaction
+		if unmarshalErr = txnContext.Unmarshal(&relayTransactionMsg); unmarshalErr != nil {
+			break
+		}
+		p.OnRelayTransactionMessage(txnContext, &relayTransactionMsg)
 	default:
 		unmarshalErr = errors.Errorf(errors.TransactionSendMsgTypeUnknown, headers.MsgType)
 	}
@@ -289,14 +356,16 @@ func (p *txnProcessor) idempotencyCheck(inflight *inflightTxn, inflightForAddr *
 // This doesn't stop it being sent to Kafka and then re-written by the REST API Gateway when it receives it,
 // and emits that update on the webhook.
 func (p *txnProcessor) idempotencyUpdateSubmitted(inflight *inflightTxn) {
-	r, err := p.receiptStore.GetReceipt(inflight.msgID)
-	if r != nil && err == nil {
-		// We mark it submitted by setting the transaction hash - this means even if the reply doesn't get through,
-		// anyone checking the receipt store will find the transaction hash and be able to call our API to
-		// check the chain directly for the receipt.
-		(*r)["transactionHash"] = inflight.tx.Hash
-		err = p.receiptStore.AddReceipt(inflight.msgID, r, true)
-	}
+	// We mark it submitted by setting the transaction hash - this means even if the reply doesn't get through,
+	// anyone checking the receipt store will find the transaction hash and be able to call our API to
+	// check the chain directly for the receipt.
+	// Only the transaction hash is updated, so we do not overwrite a reply written concurrently.
+	_, err := receipts.UpdateReceiptFields(p.receiptStore, inflight.msgID, func(existing *map[string]interface{}) map[string]interface{} {
+		if existing == nil {
+			return nil
+		}
+		return map[string]interface{}{"transactionHash": inflight.tx.Hash}
+	})
 	if err != nil {
 		log.Errorf("Failed to write dispatched record %s for idempotency checking: %s", inflight.msgID, err)
 	}
@@ -309,9 +378,19 @@ func (p *txnProcessor) idempotencyUpdateSubmitted(inflight *inflightTxn) {
 // the inflight list if the transaction is submitted
 func (p *txnProcessor) addInflightWrapper(txnContext TxnContext, msg *messages.TransactionCommon) (inflight *inflightTxn, err error) {
 
+	ctx, cancelCtx := p.requestContext(txnContext)
+	defer func() {
+		if inflight == nil {
+			cancelCtx()
+		}
+	}()
 	inflight = &inflightTxn{
 		msgID:      msg.Headers.ID,
 		txnContext: txnContext,
+		ctx:        ctx,
+		cancelCtx:  cancelCtx,
+		queued:     true,
+		cancelled:  make(chan struct{}),
 	}
 
 	// Use the correct RPC for sending transactions
@@ -342,13 +421,15 @@ func (p *txnProcessor) addInflightWrapper(txnContext TxnContext, msg *messages.T
 		} else if msg.PrivacyGroupID != "" {
 			inflight.privacyGroupID = msg.PrivacyGroupID
 		} else if len(msg.PrivateFor) > 0 {
-			if inflight.privacyGroupID, err = eth.GetOrionPrivacyGroup(txnContext.Context(), p.rpc, &from, msg.PrivateFrom, msg.PrivateFor); err != nil {
+			if inflight.privacyGroupID, err = eth.GetOrionPrivacyGroup(ctx, p.rpc, &from, msg.PrivateFrom, msg.PrivateFor); err != nil {
 				return nil, err
 			}
 		}
 	}
 
-	nodeAssignNonce := inflight.signer == nil && !p.conf.AlwaysManageNonce
+	// The relayer nonce is always managed here, as the relayer is shared by all meta-transactions
+	relayed := msg.Headers.MsgType == messages.MsgTypeRelayTransaction
+	nodeAssignNonce := inflight.signer == nil && !p.conf.AlwaysManageNonce && !relayed
 
 	// Hold the lock just while we're adding it to the map and dealing with nonce checking.
 	p.inflightTxnsLock.Lock()
@@ -402,12 +483,21 @@ func (p *txnProcessor) addInflightWrapper(txnContext TxnContext, msg *messages.T
 			err = errors.Errorf(errors.TransactionSendBadNonce, err)
 			return nil, err
 		}
+		// A supplied nonce that matches one we already have in-flight is a replacement, such as
+		// a gas price bump or a cancel. It must not queue behind other sends, as those are likely
+		// to be stuck behind the transaction it is replacing.
+		for _, alreadyInflight := range inflightForAddr.txnsInFlight {
+			if alreadyInflight.nonce == inflight.nonce && !alreadyInflight.nodeAssignNonce {
+				log.Infof("In-flight %s replaces %s at nonce %d", inflight.msgID, alreadyInflight.msgID, inflight.nonce)
+				inflight.priority = true
+			}
+		}
 	} else if p.conf.OrionPrivateAPIS && (len(msg.PrivateFor) > 0 || msg.PrivacyGroupID != "") {
 		// If are using orion private transactions, then we need the private TX
 		// group ID and nonce (the public transaction will be submitted by the pantheon node)
 		// Note: We do not have highestNonce calculation for in-flight private transactions,
 		//       so attempting to submit more than one per block currently will FAIL
-		if inflight.nonce, err = eth.GetOrionTXCount(txnContext.Context(), p.rpc, &from, inflight.privacyGroupID); err != nil {
+		if inflight.nonce, err = eth.GetOrionTXCount(ctx, p.rpc, &from, inflight.privacyGroupID); err != nil {
 			return nil, err
 		}
 		fromNode = true
@@ -425,7 +515,7 @@ func (p *txnProcessor) addInflightWrapper(txnContext TxnContext, msg *messages.T
 		// we need to accept the possibility of 'replacement transaction underpriced'
 		// (or if gas price is being varied by the submitter the potential of
 		// overwriting a transaction)
-		if inflight.nonce, err = eth.GetTransactionCount(txnContext.Context(), p.rpc, &from, "pending"); err != nil {
+		if inflight.nonce, err = eth.GetTransactionCount(ctx, p.rpc, &from, "pending"); err != nil {
 			return nil, err
 		}
 		inflightForAddr.highestNonce = inflight.nonce // store the nonce in our inflight txns state
@@ -484,12 +574,16 @@ func (p *txnProcessor) cancelInFlight(inflight *inflightTxn, submitted bool) {
 	log.Infof("In-flight %s complete (%d). nonce=%d addr=%s nan=%t sub=%t before=%d after=%d highest=%d", inflight.msgID, inflight.id, inflight.nonce, inflight.from, inflight.nodeAssignNonce, submitted, before, after, highestNonce)
 
 	// If we've got a gap potential, we need to submit a gap-fill TX
-	if !submitted && highestNonce > inflight.nonce && !inflight.nodeAssignNonce {
+	// A failed replacement leaves no gap, as the transaction it was replacing holds the nonce
+	if !submitted && highestNonce > inflight.nonce && !inflight.nodeAssignNonce && !inflight.priority {
 		log.Warnf("Potential nonce gap. Nonce %d failed to send. Nonce %d in-flight. Attempting fill=%t", inflight.nonce, highestNonce, inflight.rpc != nil)
 		if inflight.rpc != nil {
 			p.submitGapFillTX(inflight)
 		}
 	}
+	if inflight.cancelCtx != nil {
+		inflight.cancelCtx()
+	}
 }
 
 // submitGapFillTX attempts to send a zero gas, no data, transfer of zero ether transaction
@@ -500,7 +594,8 @@ func (p *txnProcessor) submitGapFillTX(inflight *inflightTxn) {
 		tx, err := eth.NewNilTX(inflight.from, inflight.nonce, inflight.signer)
 		if err == nil {
 			inflight.gapFillTxHash = tx.EthTX.Hash().String()
-			err = tx.Send(inflight.txnContext.Context(), inflight.rpc, p.gasEstimationFactor)
+			// The gap must be filled for the transactions behind it, even if the caller has gone away
+			err = tx.Send(context.WithoutCancel(inflight.ctx), inflight.rpc, p.gasEstimationFactor)
 			if err != nil {
 				inflight.gapFillSucceeded = false
 				log.Warnf("Submission of gap-fill TX '%s' failed: %s", tx.Hash, err)
@@ -521,15 +616,15 @@ func (p *txnProcessor) waitForCompletion(inflight *inflightTxn, initialWaitDelay
 	// both latency beyond the block period, and avoiding spamming the node
 	// with REST calls for long block periods, or when there is a backlog
 	replyWaitStart := time.Now().UTC()
-	time.Sleep(initialWaitDelay)
+	abandoned := !p.sleep(inflight, initialWaitDelay)
 
 	var isMined, timedOut bool
 	var err error
 	var retries int
 	var elapsed time.Duration
-	for !isMined && !timedOut {
+	for !isMined && !timedOut && !abandoned {
 
-		if isMined, err = inflight.tx.GetTXReceipt(inflight.txnContext.Context(), p.rpc); err != nil {
+		if isMined, err = inflight.tx.GetTXReceipt(inflight.ctx, p.rpc); err != nil {
 			// We wait even on connectivity errors, as we've submitted the transaction and
 			// we want to provide a receipt if connectivity resumes within the timeout
 			log.Infof("Failed to get receipt for %s (retries=%d): %s", inflight, retries, err)
@@ -545,7 +640,7 @@ func (p *txnProcessor) waitForCompletion(inflight *inflightTxn, initialWaitDelay
 			p.inflightTxnsLock.Unlock()
 
 			log.Debugf("Receipt not available after %.2fs (retries=%d): %s", elapsed.Seconds(), retries, inflight)
-			time.Sleep(delayBeforeRetry)
+			abandoned = !p.sleep(inflight, delayBeforeRetry)
 			retries++
 		}
 	}
@@ -556,7 +651,10 @@ func (p *txnProcessor) waitForCompletion(inflight *inflightTxn, initialWaitDelay
 		p.idempotencyUpdateSubmitted(inflight)
 	}
 
-	if timedOut {
+	if abandoned {
+		log.Warnf("Stopped waiting for receipt for %s after %.2fs (retries=%d): %s", inflight.tx.Hash, time.Now().UTC().Sub(replyWaitStart).Seconds(), retries, inflight.ctx.Err())
+		inflight.txnContext.SendErrorReplyWithTX(500, errors.Errorf(errors.TransactionRequestAbandoned, inflight.ctx.Err()), inflight.tx.Hash)
+	} else if timedOut {
 		if err != nil {
 			inflight.txnContext.SendErrorReplyWithTX(500, errors.Errorf(errors.TransactionSendReceiptCheckError, retries, err), inflight.tx.Hash)
 		} else {
@@ -620,6 +718,10 @@ func (p *txnProcessor) waitForCompletion(inflight *inflightTxn, initialWaitDelay
 		if receipt.TransactionIndex != nil {
 			reply.TransactionIndexStr = strconv.FormatUint(uint64(*receipt.TransactionIndex), 10)
 		}
+		reply.Extensions = receipt.ChainExtensions(p.conf.ChainProfile, p.conf.HexValuesInReceipt)
+		if p.conf.LogsInReceipt {
+			reply.Logs = receiptLogs(receipt.Logs)
+		}
 		inflight.txnContext.Reply(&reply)
 	}
 
@@ -628,6 +730,37 @@ func (p *txnProcessor) waitForCompletion(inflight *inflightTxn, initialWaitDelay
 	inflight.wg.Done()
 }
 
+// sleep waits for the delay, returning false if the request is abandoned first
+func (p *txnProcessor) sleep(inflight *inflightTxn, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-inflight.ctx.Done():
+		return false
+	}
+}
+
+// receiptLogs converts the logs from the JSON/RPC receipt for the reply
+func receiptLogs(logs []*eth.TxnLog) []*messages.TransactionLog {
+	if len(logs) == 0 {
+		return nil
+	}
+	replyLogs := make([]*messages.TransactionLog, len(logs))
+	for i, l := range logs {
+		replyLogs[i] = &messages.TransactionLog{
+			Address: l.Address,
+			Topics:  l.Topics,
+			Data:    l.Data,
+		}
+		if l.LogIndex != nil {
+			replyLogs[i].LogIndexStr = strconv.FormatUint(uint64(*l.LogIndex), 10)
+		}
+	}
+	return replyLogs
+}
+
 // addInflight adds a transaction to the inflight list, and kick off
 // a goroutine to check for its completion and send the result
 func (p *txnProcessor) trackMining(inflight *inflightTxn, tx *eth.Txn) {
@@ -686,15 +819,66 @@ func (p *txnProcessor) OnSendTransactionMessage(txnContext TxnContext, msg *mess
 	p.sendTransactionCommon(txnContext, inflight, tx)
 }
 
+// OnCancelTransactionMessage sends a zero value transfer from the sender to itself, to replace a
+// transaction at the same nonce that is stuck waiting to be mined
+func (p *txnProcessor) OnCancelTransactionMessage(txnContext TxnContext, msg *messages.CancelTransaction) {
+
+	if msg.Nonce == "" {
+		txnContext.SendErrorReply(400, errors.Errorf(errors.TransactionCancelNonceRequired))
+		return
+	}
+
+	inflight, err := p.addInflightWrapper(txnContext, &msg.TransactionCommon)
+	if err != nil {
+		txnContext.SendErrorReply(400, err)
+		return
+	}
+	if inflight == nil {
+		// Idempotency check has already sent a reply
+		return
+	}
+	// Even if we are not tracking the transaction being cancelled, the nonce is
+	// in use so there is nothing to gain by waiting for a send slot
+	inflight.priority = true
+
+	tx, err := eth.NewRawSendTxn(inflight.signer, msg.From, msg.From, inflight.nonceNumber(), json.Number("0"), msg.Gas, msg.GasPrice, []byte{})
+	if err != nil {
+		p.cancelInFlight(inflight, false /* not yet submitted */)
+		txnContext.SendErrorReply(400, err)
+		return
+	}
+
+	p.sendTransactionCommon(txnContext, inflight, tx)
+}
+
 func (p *txnProcessor) sendTransactionCommon(txnContext TxnContext, inflight *inflightTxn, tx *eth.Txn) {
 	tx.OrionPrivateAPIS = p.conf.OrionPrivateAPIS
 	tx.PrivacyGroupID = inflight.privacyGroupID
 	tx.NodeAssignNonce = inflight.nodeAssignNonce
 
-	if p.conf.SendConcurrency > 1 {
+	if inflight.priority {
+		// Replacements go straight to the node, without taking a send slot or
+		// blocking the messages behind them
+		log.Infof("Send %s/%d (msg=%s) in the priority lane", inflight.from, inflight.nonce, inflight.msgID)
+		go p.sendAndTrackMining(txnContext, inflight, tx)
+	} else if p.conf.SendConcurrency > 1 || p.signingPool != nil {
 		// The above must happen synchronously for each partition in Kafka - as it is where we assign the nonce.
 		// However, the send to the node can happen at high concurrency.
-		p.concurrencySlots <- true
+		select {
+		case p.concurrencySlots <- true:
+		case <-inflight.cancelled:
+			p.cancelQueuedSend(txnContext, inflight, false)
+			return
+		case <-inflight.ctx.Done():
+			p.cancelQueuedSend(txnContext, inflight, false)
+			return
+		}
+		if p.signingPool != nil {
+			// Signing and sending moves off the consumer loop to the pool, which keeps the nonce
+			// order for each sender, but signs for different senders in parallel
+			p.signingPool.submit(inflight.from, func() { p.sendAndTrackMining(txnContext, inflight, tx) })
+			return
+		}
 		log.Debugf("Send with concurrency config=%d", p.conf.SendConcurrency)
 		go p.sendAndTrackMining(txnContext, inflight, tx)
 	} else {
@@ -703,21 +887,96 @@ func (p *txnProcessor) sendTransactionCommon(txnContext TxnContext, inflight *in
 	}
 }
 
+// startSend moves the transaction out of the queued state, unless it has been cancelled
+func (p *txnProcessor) startSend(inflight *inflightTxn) bool {
+	p.inflightTxnsLock.Lock()
+	defer p.inflightTxnsLock.Unlock()
+	select {
+	case <-inflight.cancelled:
+		return false
+	case <-inflight.ctx.Done():
+		return false
+	default:
+		inflight.queued = false
+		return true
+	}
+}
+
+// cancelQueuedSend completes a request that was cancelled before it was sent
+func (p *txnProcessor) cancelQueuedSend(txnContext TxnContext, inflight *inflightTxn, holdingSlot bool) {
+	if holdingSlot {
+		<-p.concurrencySlots
+	}
+	status, err := 409, errors.Errorf(errors.TransactionSendCancelled)
+	select {
+	case <-inflight.cancelled:
+	default:
+		// Not cancelled explicitly, so the caller went away or we are shutting down
+		status, err = 503, errors.Errorf(errors.TransactionRequestAbandoned, inflight.ctx.Err())
+	}
+	log.Infof("Send %s/%d (msg=%s) cancelled while queued: %s", inflight.from, inflight.nonce, inflight.msgID, err)
+	p.cancelInFlight(inflight, false /* never submitted */)
+	txnContext.SendErrorReplyWithGapFill(status, err, inflight.gapFillTxHash, inflight.gapFillSucceeded)
+}
+
+// CancelQueued withdraws a request that has been assigned a nonce, but is still waiting for
+// a send slot. Once the request has been passed to the node it can only be replaced, by
+// sending a transaction with the same nonce.
+func (p *txnProcessor) CancelQueued(msgID string) error {
+	p.inflightTxnsLock.Lock()
+	defer p.inflightTxnsLock.Unlock()
+	for _, inflightForAddr := range p.inflightTxns {
+		for _, inflight := range inflightForAddr.txnsInFlight {
+			if inflight.msgID != msgID {
+				continue
+			}
+			if !inflight.queued {
+				return errors.Errorf(errors.TransactionCancelAlreadySubmitted, msgID)
+			}
+			select {
+			case <-inflight.cancelled:
+			default:
+				close(inflight.cancelled)
+			}
+			return nil
+		}
+	}
+	return errors.Errorf(errors.TransactionCancelNotQueued, msgID)
+}
+
+// SigningStats returns the activity of the signing worker pool, or nil if it is not enabled
+func (p *txnProcessor) SigningStats() *SigningStats {
+	if p.signingPool == nil {
+		return nil
+	}
+	return p.signingPool.stats()
+}
+
 func (p *txnProcessor) sendAndTrackMining(txnContext TxnContext, inflight *inflightTxn, tx *eth.Txn) {
 
+	pooled := p.signingPool != nil && !inflight.priority
+	holdingSlot := (p.conf.SendConcurrency > 1 && !inflight.priority) || pooled
+	// Work queued for the pool might have been cancelled while it waited for a worker
+	if !p.startSend(inflight) {
+		p.cancelQueuedSend(txnContext, inflight, holdingSlot)
+		return
+	}
+
 	concurrency := atomic.AddInt64(&p.concurrency, 1)
 	log.Infof("--> send %s/%d (msg=%s,concurrency=%d)", inflight.from, inflight.nonce, inflight.msgID, concurrency)
 
 	// If the RPC client is nil here, we need to resolve it.
 	var err error
 	if inflight.rpc == nil {
-		inflight.rpc, err = p.addressBook.lookup(txnContext.Context(), inflight.from)
+		inflight.rpc, err = p.addressBook.lookup(inflight.ctx, inflight.from)
 	}
 	if err == nil {
 		err = p.sendWithRetry(txnContext, inflight, tx)
 	}
-	if p.conf.SendConcurrency > 1 {
+	if holdingSlot {
 		<-p.concurrencySlots // return our slot as soon as send is complete, to let an awaiting send go
+	}
+	if p.conf.SendConcurrency > 1 || pooled {
 		concurrency = atomic.AddInt64(&p.concurrency, -1)
 		log.Debugf("<-- send %s/%d (msg=%s,concurrency=%d)", inflight.from, inflight.nonce, inflight.msgID, concurrency)
 	}
@@ -733,7 +992,7 @@ func (p *txnProcessor) sendAndTrackMining(txnContext TxnContext, inflight *infli
 func (p *txnProcessor) sendWithRetry(txnContext TxnContext, inflight *inflightTxn, tx *eth.Txn) error {
 	retries := 0
 	for {
-		err := tx.Send(txnContext.Context(), inflight.rpc, p.gasEstimationFactor)
+		err := tx.Send(inflight.ctx, inflight.rpc, p.gasEstimationFactor)
 		if err == nil {
 			return nil
 		}
@@ -765,7 +1024,9 @@ func (p *txnProcessor) sendWithRetry(txnContext TxnContext, inflight *inflightTx
 		if !retry {
 			return err
 		}
-		time.Sleep(retryDelay)
+		if !p.sleep(inflight, retryDelay) {
+			return errors.Errorf(errors.TransactionRequestAbandoned, inflight.ctx.Err())
+		}
 		retries++
 	}
 }
@@ -50,6 +50,7 @@ type errorReply struct {
 }
 
 type testTxnContext struct {
+	ctx          context.Context
 	jsonMsg      string
 	badMsgType   string
 	replies      []messages.ReplyWithHeaders
@@ -72,6 +73,7 @@ type testRPC struct {
 	privFindPrivacyGroupErr        error
 	ethEstimateGasResult           ethbinding.HexUint64
 	ethEstimateGasErr              error
+	ethCallResult                  string
 	condLock                       sync.Mutex
 	calls                          []string
 	params                         [][]interface{}
@@ -178,6 +180,7 @@ func (r *testRPC) CallContext(ctx context.Context, result interface{}, method st
 		reflect.ValueOf(result).Elem().Set(reflect.ValueOf(&r.ethEstimateGasResult))
 		return r.ethEstimateGasErr
 	} else if method == "eth_call" {
+		reflect.ValueOf(result).Elem().Set(reflect.ValueOf(r.ethCallResult))
 		return nil
 	} else if method == "priv_getTransactionReceipt" {
 		return nil
@@ -186,6 +189,9 @@ func (r *testRPC) CallContext(ctx context.Context, result interface{}, method st
 }
 
 func (c *testTxnContext) Context() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
 	return context.Background()
 }
 
@@ -1598,6 +1604,81 @@ func TestSendRetryMax(t *testing.T) {
 
 }
 
+func TestSendRetryAbandoned(t *testing.T) {
+	assert := assert.New(t)
+
+	oneMinute := 60000
+	txnProcessor := NewTxnProcessor(&TxnProcessorConf{
+		SendRetryDelayMinMS: &oneMinute,
+		SendConcurrency:     1,
+		AlwaysManageNonce:   true,
+	}, &eth.RPCConf{}).(*txnProcessor)
+
+	// The caller goes away while we wait to retry
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	testTxnContext := &testTxnContext{ctx: ctx}
+	testTxnContext.jsonMsg = goodSendTxnJSON
+	testRPC := &testRPC{
+		ethSendTransactionErr: fmt.Errorf("pop"),
+	}
+	txnProcessor.Init(testRPC)
+
+	txnProcessor.OnMessage(testTxnContext)
+
+	assert.Equal([]string{"eth_getTransactionCount", "eth_sendTransaction"}, testRPC.calls)
+	assert.Len(testTxnContext.errorReplies, 1)
+	assert.Regexp("FFEC100393.*deadline exceeded", testTxnContext.errorReplies[0].err)
+	assert.Empty(txnProcessor.inflightTxns)
+}
+
+func TestSendAbandonedOnClose(t *testing.T) {
+	assert := assert.New(t)
+
+	txnProcessor := NewTxnProcessor(&TxnProcessorConf{
+		AlwaysManageNonce: true,
+	}, &eth.RPCConf{}).(*txnProcessor)
+	testTxnContext := &testTxnContext{}
+	testTxnContext.jsonMsg = goodSendTxnJSON
+	testRPC := goodMessageRPC()
+	txnProcessor.Init(testRPC)
+	txnProcessor.Close()
+
+	txnProcessor.OnMessage(testTxnContext)
+
+	assert.Equal([]string{"eth_getTransactionCount"}, testRPC.calls)
+	assert.Len(testTxnContext.errorReplies, 1)
+	assert.Equal(503, testTxnContext.errorReplies[0].status)
+	assert.Regexp("FFEC100393.*canceled", testTxnContext.errorReplies[0].err)
+}
+
+func TestReceiptWaitAbandonedOnClose(t *testing.T) {
+	assert := assert.New(t)
+
+	txHash := "0xac18e98664e160305cdb77e75e5eae32e55447e94ad8ceb0123729589ed09f8b"
+	txnProcessor := NewTxnProcessor(&TxnProcessorConf{
+		MaxTXWaitTime: 60,
+	}, &eth.RPCConf{}).(*txnProcessor)
+	testTxnContext := &testTxnContext{}
+	testTxnContext.jsonMsg = goodSendTxnJSON
+	testRPC := &testRPC{
+		ethSendTransactionResult: txHash,
+	}
+	txnProcessor.Init(testRPC)
+
+	txnProcessor.OnMessage(testTxnContext)
+	txnProcessor.inflightTxnsLock.Lock()
+	txnWG := &txnProcessor.inflightTxns[strings.ToLower(testFromAddr)].txnsInFlight[0].wg
+	txnProcessor.inflightTxnsLock.Unlock()
+	txnProcessor.Close()
+	txnWG.Wait()
+
+	assert.Empty(testTxnContext.replies)
+	assert.Len(testTxnContext.errorReplies, 1)
+	assert.Regexp("FFEC100393", testTxnContext.errorReplies[0].err)
+	assert.Equal(txHash, testTxnContext.errorReplies[0].txHash)
+}
+
 func TestSendRetryNoRetryNonce(t *testing.T) {
 	assert := assert.New(t)
 
@@ -1654,3 +1735,260 @@ func TestSendRetryForce(t *testing.T) {
 	assert.Equal([]string{"eth_getTransactionCount", "eth_sendTransaction", "eth_sendTransaction", "eth_sendTransaction", "eth_sendTransaction"}, testRPC.calls)
 
 }
+
+func TestOnSendTransactionMessageReplacementPriority(t *testing.T) {
+	assert := assert.New(t)
+
+	zero := 0
+	txnProcessor := NewTxnProcessor(&TxnProcessorConf{
+		MaxTXWaitTime:   1,
+		SendConcurrency: 2,
+		SendRetryMax:    &zero,
+	}, &eth.RPCConf{}).(*txnProcessor)
+	testRPC := goodMessageRPC()
+	txnProcessor.Init(testRPC)
+
+	// Every send slot is taken, and nonce 100 is stuck in-flight
+	txnProcessor.concurrencySlots <- true
+	txnProcessor.concurrencySlots <- true
+	from := strings.ToLower(testFromAddr)
+	txnProcessor.inflightTxns[from] = &inflightTxnState{
+		txnsInFlight: []*inflightTxn{{msgID: "stuck", nonce: 100}},
+		highestNonce: 100,
+	}
+
+	testTxnContext := &testTxnContext{}
+	testTxnContext.jsonMsg = "{" +
+		"  \"headers\":{\"type\": \"SendTransaction\", \"id\": \"bump\"}," +
+		"  \"from\":\"" + testFromAddr + "\"," +
+		"  \"nonce\":\"100\"," +
+		"  \"gas\":\"123\"," +
+		"  \"gasPrice\":\"2000000000\"," +
+		"  \"method\":{\"name\":\"test\"}" +
+		"}"
+	txnProcessor.OnMessage(testTxnContext)
+
+	for len(testTxnContext.replies) == 0 {
+		time.Sleep(1 * time.Millisecond)
+	}
+	assert.Empty(testTxnContext.errorReplies)
+	assert.Equal("eth_sendTransaction", testRPC.calls[0])
+	assert.Equal(int64(100), txnProcessor.inflightTxns[from].highestNonce)
+	assert.Len(txnProcessor.concurrencySlots, 2)
+}
+
+func TestOnSendTransactionMessageFailedReplacementNoGapFill(t *testing.T) {
+	assert := assert.New(t)
+
+	zero := 0
+	txnProcessor := NewTxnProcessor(&TxnProcessorConf{
+		MaxTXWaitTime:  1,
+		AttemptGapFill: true,
+		SendRetryMax:   &zero,
+	}, &eth.RPCConf{}).(*txnProcessor)
+	testRPC := &testRPC{ethSendTransactionErr: fmt.Errorf("replacement transaction underpriced")}
+	txnProcessor.Init(testRPC)
+
+	from := strings.ToLower(testFromAddr)
+	txnProcessor.inflightTxns[from] = &inflightTxnState{
+		txnsInFlight: []*inflightTxn{{msgID: "stuck", nonce: 100}, {msgID: "next", nonce: 101}},
+		highestNonce: 101,
+	}
+
+	testTxnContext := &testTxnContext{}
+	testTxnContext.jsonMsg = "{" +
+		"  \"headers\":{\"type\": \"SendTransaction\"}," +
+		"  \"from\":\"" + testFromAddr + "\"," +
+		"  \"nonce\":\"100\"," +
+		"  \"gas\":\"123\"," +
+		"  \"method\":{\"name\":\"test\"}" +
+		"}"
+	txnProcessor.OnMessage(testTxnContext)
+
+	for len(testTxnContext.errorReplies) == 0 {
+		time.Sleep(1 * time.Millisecond)
+	}
+	assert.Regexp("underpriced", testTxnContext.errorReplies[0].err)
+	assert.Empty(testTxnContext.errorReplies[0].gapFillTxHash)
+	assert.EqualValues([]string{"eth_sendTransaction"}, testRPC.calls)
+}
+
+func TestOnCancelTransactionMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	txnProcessor := NewTxnProcessor(&TxnProcessorConf{
+		MaxTXWaitTime:   1,
+		SendConcurrency: 1,
+	}, &eth.RPCConf{}).(*txnProcessor)
+	testRPC := goodMessageRPC()
+	txnProcessor.Init(testRPC)
+
+	testTxnContext := &testTxnContext{}
+	testTxnContext.jsonMsg = "{" +
+		"  \"headers\":{\"type\": \"CancelTransaction\"}," +
+		"  \"from\":\"" + testFromAddr + "\"," +
+		"  \"nonce\":\"7\"," +
+		"  \"gas\":\"21000\"," +
+		"  \"gasPrice\":\"3000000000\"" +
+		"}"
+	txnProcessor.OnMessage(testTxnContext)
+
+	for len(testTxnContext.replies) == 0 {
+		time.Sleep(1 * time.Millisecond)
+	}
+	assert.Empty(testTxnContext.errorReplies)
+	assert.Equal("eth_sendTransaction", testRPC.calls[0])
+	sendTX := testRPC.params[0][0].(*eth.SendTXArgs)
+	assert.Equal(uint64(7), uint64(*sendTX.Nonce))
+	assert.True(strings.EqualFold(testFromAddr, sendTX.To))
+	assert.Equal(int64(0), sendTX.Value.ToInt().Int64())
+	assert.Equal(int64(3000000000), sendTX.GasPrice.ToInt().Int64())
+}
+
+func TestOnCancelTransactionMessageErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	txnProcessor := NewTxnProcessor(&TxnProcessorConf{}, &eth.RPCConf{}).(*txnProcessor)
+	txnProcessor.Init(&testRPC{})
+
+	txnContext := &testTxnContext{}
+	txnContext.jsonMsg = "{" +
+		"  \"headers\":{\"type\": \"CancelTransaction\"}," +
+		"  \"from\":\"" + testFromAddr + "\"" +
+		"}"
+	txnProcessor.OnMessage(txnContext)
+	assert.Regexp("FFEC100308", txnContext.errorReplies[0].err)
+
+	txnContext = &testTxnContext{}
+	txnContext.jsonMsg = "{" +
+		"  \"headers\":{\"type\": \"CancelTransaction\"}," +
+		"  \"from\":\"bad\"," +
+		"  \"nonce\":\"1\"" +
+		"}"
+	txnProcessor.OnMessage(txnContext)
+	assert.Regexp("from", txnContext.errorReplies[0].err)
+
+	txnContext = &testTxnContext{}
+	txnContext.jsonMsg = "{" +
+		"  \"headers\":{\"type\": \"CancelTransaction\"}," +
+		"  \"from\":\"" + testFromAddr + "\"," +
+		"  \"nonce\":\"1\"," +
+		"  \"gasPrice\":\"not a number\"" +
+		"}"
+	txnProcessor.OnMessage(txnContext)
+	assert.Equal(400, txnContext.errorReplies[0].status)
+	assert.Empty(txnProcessor.inflightTxns)
+
+	txnContext = &testTxnContext{}
+	txnContext.jsonMsg = "{\"headers\":{\"type\": \"CancelTransaction\"},\"nonce\":false}"
+	txnProcessor.OnMessage(txnContext)
+	assert.Equal(400, txnContext.errorReplies[0].status)
+}
+
+func TestCancelQueued(t *testing.T) {
+	assert := assert.New(t)
+
+	zero := 0
+	txnProcessor := NewTxnProcessor(&TxnProcessorConf{
+		MaxTXWaitTime:     1,
+		SendConcurrency:   2,
+		AlwaysManageNonce: true,
+		SendRetryMax:      &zero,
+	}, &eth.RPCConf{}).(*txnProcessor)
+	testRPC := goodMessageRPC()
+	testRPC.ethGetTransactionCountResult = 10
+	txnProcessor.Init(testRPC)
+
+	txnProcessor.concurrencySlots <- true
+	txnProcessor.concurrencySlots <- true
+
+	testTxnContext := &testTxnContext{}
+	testTxnContext.jsonMsg = "{" +
+		"  \"headers\":{\"type\": \"SendTransaction\", \"id\": \"queued1\"}," +
+		"  \"from\":\"" + testFromAddr + "\"," +
+		"  \"gas\":\"123\"," +
+		"  \"method\":{\"name\":\"test\"}" +
+		"}"
+	done := make(chan struct{})
+	go func() {
+		txnProcessor.OnMessage(testTxnContext)
+		close(done)
+	}()
+
+	from := strings.ToLower(testFromAddr)
+	for {
+		txnProcessor.inflightTxnsLock.Lock()
+		inflightForAddr := txnProcessor.inflightTxns[from]
+		txnProcessor.inflightTxnsLock.Unlock()
+		if inflightForAddr != nil {
+			break
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+
+	err := txnProcessor.CancelQueued("unknown")
+	assert.Regexp("FFEC100310", err)
+
+	err = txnProcessor.CancelQueued("queued1")
+	assert.NoError(err)
+	<-done
+
+	assert.Equal(409, testTxnContext.errorReplies[0].status)
+	assert.Regexp("FFEC100309", testTxnContext.errorReplies[0].err)
+	assert.Empty(txnProcessor.inflightTxns)
+	assert.NotContains(testRPC.calls, "eth_sendTransaction")
+
+	err = txnProcessor.CancelQueued("queued1")
+	assert.Regexp("FFEC100310", err)
+
+	txnProcessor.inflightTxns[from] = &inflightTxnState{
+		txnsInFlight: []*inflightTxn{{msgID: "sent1", nonce: 10}},
+	}
+	err = txnProcessor.CancelQueued("sent1")
+	assert.Regexp("FFEC100311", err)
+}
+
+func TestCancelQueuedAfterSlotTaken(t *testing.T) {
+	assert := assert.New(t)
+
+	txnProcessor := NewTxnProcessor(&TxnProcessorConf{
+		SendConcurrency: 2,
+	}, &eth.RPCConf{}).(*txnProcessor)
+	txnProcessor.Init(&testRPC{})
+
+	testTxnContext := &testTxnContext{}
+	inflight := &inflightTxn{
+		msgID:      "queued1",
+		from:       strings.ToLower(testFromAddr),
+		txnContext: testTxnContext,
+		ctx:        context.Background(),
+		queued:     true,
+		cancelled:  make(chan struct{}),
+	}
+	txnProcessor.inflightTxns[inflight.from] = &inflightTxnState{txnsInFlight: []*inflightTxn{inflight}}
+	assert.NoError(txnProcessor.CancelQueued("queued1"))
+	assert.NoError(txnProcessor.CancelQueued("queued1"))
+
+	txnProcessor.concurrencySlots <- true
+	txnProcessor.sendAndTrackMining(testTxnContext, inflight, &eth.Txn{})
+	assert.Regexp("FFEC100309", testTxnContext.errorReplies[0].err)
+	assert.Len(txnProcessor.concurrencySlots, 0)
+}
+
+func TestReceiptLogs(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(receiptLogs(nil))
+
+	addr := ethbind.API.HexToAddress("0x0123456789AbcdeF0123456789abCdef01234567")
+	topic := ethbind.API.HexToHash("0x1111111111111111111111111111111111111111111111111111111111111111")
+	logIndex := ethbinding.HexUint(5)
+	logs := receiptLogs([]*eth.TxnLog{
+		{Address: &addr, Topics: []*ethbinding.Hash{&topic}, Data: "0x2a", LogIndex: &logIndex},
+		{Address: &addr},
+	})
+	assert.Equal([]*messages.TransactionLog{
+		{Address: &addr, Topics: []*ethbinding.Hash{&topic}, Data: "0x2a", LogIndexStr: "5"},
+		{Address: &addr},
+	}, logs)
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	goerrors "errors"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/smithy-go"
+)
+
+const awsDefaultSessionName = "ethconnect"
+
+// AWSCredentials are the access key, and the session token of temporary credentials, configured for a client
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// NewAWSConfig loads the configuration for an AWS SDK client in a region. Unless static credentials
+// are supplied, they are found by the default chain of the SDK: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY
+// and AWS_SESSION_TOKEN, a shared profile, a web identity token in AWS_WEB_IDENTITY_TOKEN_FILE exchanged
+// with STS for the role in AWS_ROLE_ARN (as IRSA provides in EKS), then the role of the task or instance.
+// The SDK does not retry, as each caller has its own retry policy. A CA bundle in AWS_CA_BUNDLE is
+// added to the TLS configuration, if one is supplied.
+func NewAWSConfig(ctx context.Context, region string, timeout time.Duration, tlsConfig *tls.Config, static *AWSCredentials) (aws.Config, error) {
+	client := awshttp.NewBuildableClient().
+		WithTimeout(timeout).
+		WithTransportOptions(func(tr *http.Transport) {
+			if tlsConfig != nil {
+				tr.TLSClientConfig = tlsConfig
+			}
+		})
+	opts := []func(*config.LoadOptions) error{
+		config.WithRegion(region),
+		config.WithHTTPClient(client),
+		config.WithRetryer(func() aws.Retryer { return aws.NopRetryer{} }),
+		config.WithWebIdentityRoleCredentialOptions(func(o *stscreds.WebIdentityRoleOptions) {
+			// AWS_ROLE_SESSION_NAME is applied first, if set
+			if o.RoleSessionName == "" {
+				o.RoleSessionName = awsDefaultSessionName
+			}
+		}),
+	}
+	if static != nil {
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(static.AccessKeyID, static.SecretAccessKey, static.SessionToken),
+		))
+	}
+	return config.LoadDefaultConfig(ctx, opts...)
+}
+
+// AWSErrorDetail returns the HTTP status of a failed AWS SDK request, or zero if there was no response,
+// and the error code and message returned by the service
+func AWSErrorDetail(err error) (status int, detail string) {
+	var resErr *awshttp.ResponseError
+	if goerrors.As(err, &resErr) {
+		status = resErr.HTTPStatusCode()
+	}
+	detail = err.Error()
+	var apiErr smithy.APIError
+	if goerrors.As(err, &apiErr) {
+		detail = apiErr.ErrorCode()
+		if msg := apiErr.ErrorMessage(); msg != "" {
+			detail += ": " + msg
+		}
+	}
+	return status, detail
+}
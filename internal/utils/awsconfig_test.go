@@ -0,0 +1,92 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAWSConfigWebIdentity(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "awsconfig")
+	defer os.RemoveAll(dir)
+	tokenFile := path.Join(dir, "token")
+	assert.NoError(ioutil.WriteFile(tokenFile, []byte("token1"), 0600))
+
+	sts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		assert.Equal("AssumeRoleWithWebIdentity", req.Form.Get("Action"))
+		assert.Equal("arn:aws:iam::123456789012:role/ethconnect", req.Form.Get("RoleArn"))
+		assert.Equal(awsDefaultSessionName, req.Form.Get("RoleSessionName"))
+		assert.Equal("token1", req.Form.Get("WebIdentityToken"))
+		res.Write([]byte(`<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>ASIATEST</AccessKeyId>
+      <SecretAccessKey>secret1</SecretAccessKey>
+      <SessionToken>session1</SessionToken>
+      <Expiration>` + time.Now().Add(1*time.Hour).UTC().Format(time.RFC3339) + `</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`))
+	}))
+	defer sts.Close()
+
+	for name, value := range map[string]string{
+		"AWS_WEB_IDENTITY_TOKEN_FILE": tokenFile,
+		"AWS_ROLE_ARN":                "arn:aws:iam::123456789012:role/ethconnect",
+		"AWS_ENDPOINT_URL_STS":        sts.URL,
+		"AWS_EC2_METADATA_DISABLED":   "true",
+	} {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	conf, err := NewAWSConfig(context.Background(), "eu-west-1", 10*time.Second, nil, nil)
+	assert.NoError(err)
+	creds, err := conf.Credentials.Retrieve(context.Background())
+	assert.NoError(err)
+	assert.Equal("ASIATEST", creds.AccessKeyID)
+	assert.Equal("session1", creds.SessionToken)
+}
+
+func TestAWSConfigStaticCredentials(t *testing.T) {
+	assert := assert.New(t)
+	conf, err := NewAWSConfig(context.Background(), "eu-west-1", 10*time.Second, nil, &AWSCredentials{
+		AccessKeyID:     "ak1",
+		SecretAccessKey: "sk1",
+	})
+	assert.NoError(err)
+	creds, err := conf.Credentials.Retrieve(context.Background())
+	assert.NoError(err)
+	assert.Equal("ak1", creds.AccessKeyID)
+	assert.Equal("eu-west-1", conf.Region)
+}
+
+func TestAWSErrorDetailNoResponse(t *testing.T) {
+	status, detail := AWSErrorDetail(fmt.Errorf("pop"))
+	assert.Equal(t, 0, status)
+	assert.Equal(t, "pop", detail)
+}
@@ -0,0 +1,244 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/icza/dyno"
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigEnvSeparator separates the path segments in the name of a config overlay environment variable
+const ConfigEnvSeparator = "__"
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// configFields returns the JSON names of the fields of a struct type, flattening
+// untagged embedded structs in the same way as encoding/json
+func configFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for n, t := range configFields(ft) {
+					if _, exists := fields[n]; !exists {
+						fields[n] = t
+					}
+				}
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// lookupConfigField finds a field case-insensitively, as encoding/json does
+func lookupConfigField(fields map[string]reflect.Type, key string) (string, reflect.Type, bool) {
+	if t, ok := fields[key]; ok {
+		return key, t, true
+	}
+	for name, t := range fields {
+		if strings.EqualFold(name, key) {
+			return name, t, true
+		}
+	}
+	return "", nil, false
+}
+
+func isOpaqueConfigType(t reflect.Type) bool {
+	return t.Kind() == reflect.Interface || reflect.PtrTo(t).Implements(jsonUnmarshalerType)
+}
+
+// ConfigUnknownFields returns the path of every key in a generic config payload that
+// does not map to a field of the target type, sorted for stable error messages
+func ConfigUnknownFields(payload interface{}, target reflect.Type) []string {
+	unknown := []string{}
+	walkConfigUnknownFields("", payload, target, &unknown)
+	sort.Strings(unknown)
+	return unknown
+}
+
+func walkConfigUnknownFields(path string, payload interface{}, t reflect.Type, unknown *[]string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if isOpaqueConfigType(t) {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := payload.(map[string]interface{})
+		if !ok {
+			return // type mismatches are reported by the JSON parser
+		}
+		fields := configFields(t)
+		for k, v := range m {
+			if _, ft, ok := lookupConfigField(fields, k); ok {
+				walkConfigUnknownFields(joinConfigPath(path, k), v, ft, unknown)
+			} else {
+				*unknown = append(*unknown, joinConfigPath(path, k))
+			}
+		}
+	case reflect.Map:
+		if m, ok := payload.(map[string]interface{}); ok {
+			for k, v := range m {
+				walkConfigUnknownFields(joinConfigPath(path, k), v, t.Elem(), unknown)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if a, ok := payload.([]interface{}); ok {
+			for i, v := range a {
+				walkConfigUnknownFields(path+"["+strconv.Itoa(i)+"]", v, t.Elem(), unknown)
+			}
+		}
+	}
+}
+
+func joinConfigPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// ApplyConfigEnvOverlay sets values on a generic config payload from environment variables
+// named with the prefix, followed by the path to the key with each segment separated by a
+// double underscore. For example MYAPP_CONFIG__REST__GATEWAY1__HTTP__PORT=8080.
+// Field names are matched case-insensitively against the target type. Map keys match an
+// existing key in the payload case-insensitively, or are added in lower case.
+// Values are converted to the type of the field they set. Lists, maps and structures can
+// be supplied as JSON or YAML.
+func ApplyConfigEnvOverlay(payload map[string]interface{}, target reflect.Type, prefix string, environ []string) error {
+	prefix += ConfigEnvSeparator
+	var vars []string
+	for _, kv := range environ {
+		if strings.HasPrefix(strings.ToUpper(kv), strings.ToUpper(prefix)) {
+			vars = append(vars, kv)
+		}
+	}
+	// Apply in a consistent order, so a variable setting a whole structure is
+	// applied before one setting a field within it
+	sort.Strings(vars)
+	for _, kv := range vars {
+		eq := strings.Index(kv, "=")
+		if eq < 0 {
+			continue
+		}
+		name, value := kv[:eq], kv[eq+1:]
+		path := strings.Split(name[len(prefix):], ConfigEnvSeparator)
+		if err := setConfigPath(payload, target, path, name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setConfigPath(m map[string]interface{}, t reflect.Type, path []string, envName, value string) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	segment := path[0]
+	var key string
+	var valueType reflect.Type
+	switch {
+	case segment == "":
+		return errors.Errorf(errors.ConfigEnvOverlayUnknownField, envName, segment)
+	case t.Kind() == reflect.Struct && !isOpaqueConfigType(t):
+		var ok bool
+		if key, valueType, ok = lookupConfigField(configFields(t), segment); !ok {
+			return errors.Errorf(errors.ConfigEnvOverlayUnknownField, envName, segment)
+		}
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String:
+		key = strings.ToLower(segment)
+		for existing := range m {
+			if strings.EqualFold(existing, segment) {
+				key = existing
+				break
+			}
+		}
+		valueType = t.Elem()
+	default:
+		return errors.Errorf(errors.ConfigEnvOverlayUnknownField, envName, segment)
+	}
+
+	if len(path) == 1 {
+		v, err := parseConfigEnvValue(valueType, value)
+		if err != nil {
+			return errors.Errorf(errors.ConfigEnvOverlayBadValue, envName, err)
+		}
+		m[key] = v
+		return nil
+	}
+	child, ok := m[key].(map[string]interface{})
+	if !ok {
+		child = make(map[string]interface{})
+		m[key] = child
+	}
+	return setConfigPath(child, valueType, path[1:], envName, value)
+}
+
+func parseConfigEnvValue(t reflect.Type, value string) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if isOpaqueConfigType(t) {
+		return parseConfigEnvYAML(value)
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return value, nil
+	case reflect.Bool:
+		return strconv.ParseBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(value, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(value, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(value, 64)
+	default:
+		return parseConfigEnvYAML(value)
+	}
+}
+
+func parseConfigEnvYAML(value string) (interface{}, error) {
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(value), &v); err != nil {
+		return nil, err
+	}
+	return dyno.ConvertMapI2MapS(v), nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testConfEmbedded struct {
+	URL string `json:"url"`
+}
+
+type testConfHTTP struct {
+	Port    int      `json:"port"`
+	Enabled bool     `json:"enabled"`
+	Hosts   []string `json:"hosts"`
+}
+
+type testConfGateway struct {
+	testConfEmbedded
+	HTTP     testConfHTTP           `json:"http"`
+	Factor   *float64               `json:"factor,omitempty"`
+	Limit    json.Number            `json:"limit"`
+	Extra    map[string]interface{} `json:"extra"`
+	Ignored  string                 `json:"-"`
+	Untagged string
+	internal string
+}
+
+type testConf struct {
+	Version  int                         `json:"version"`
+	Gateways map[string]*testConfGateway `json:"gateways"`
+	List     []testConfHTTP              `json:"list"`
+}
+
+func TestConfigUnknownFields(t *testing.T) {
+	assert := assert.New(t)
+
+	var payload map[string]interface{}
+	err := json.Unmarshal([]byte(`{
+		"version": 1,
+		"gateways": {
+			"gw1": {
+				"url": "http://example.com",
+				"http": {"port": 8080, "prot": 1},
+				"EXTRA": {"anything": {"goes": true}},
+				"untagged": "ok",
+				"Ignored": "not ok",
+				"internal": "not ok"
+			},
+			"gw2": "type errors are left to the parser"
+		},
+		"list": [{"port": 1}, {"hosts": [], "host": "x"}],
+		"unknown": true
+	}`), &payload)
+	assert.NoError(err)
+
+	unknown := ConfigUnknownFields(payload, reflect.TypeOf(&testConf{}))
+	assert.Equal([]string{
+		"gateways.gw1.Ignored",
+		"gateways.gw1.http.prot",
+		"gateways.gw1.internal",
+		"list[1].host",
+		"unknown",
+	}, unknown)
+
+	assert.Empty(ConfigUnknownFields(map[string]interface{}{"version": 1}, reflect.TypeOf(testConf{})))
+}
+
+func TestApplyConfigEnvOverlay(t *testing.T) {
+	assert := assert.New(t)
+
+	payload := map[string]interface{}{
+		"gateways": map[string]interface{}{
+			"GW1": map[string]interface{}{
+				"url": "http://example.com",
+			},
+		},
+	}
+	err := ApplyConfigEnvOverlay(payload, reflect.TypeOf(&testConf{}), "TEST_CONFIG", []string{
+		"PATH=/usr/bin",
+		"TEST_CONFIG__VERSION=1",
+		"TEST_CONFIG__GATEWAYS__GW1__URL=http://override.example.com",
+		"TEST_CONFIG__GATEWAYS__GW1__HTTP__PORT=9090",
+		"TEST_CONFIG__GATEWAYS__GW1__HTTP__ENABLED=true",
+		"TEST_CONFIG__GATEWAYS__GW1__HTTP__HOSTS=[a, b]",
+		"TEST_CONFIG__GATEWAYS__GW1__FACTOR=1.5",
+		"TEST_CONFIG__GATEWAYS__GW1__LIMIT=100",
+		"TEST_CONFIG__GATEWAYS__GW1__EXTRA={key: value}",
+		"TEST_CONFIG__GATEWAYS__NEW_GW__URL=http://new.example.com",
+	})
+	assert.NoError(err)
+
+	b, _ := json.Marshal(payload)
+	var conf testConf
+	assert.NoError(json.Unmarshal(b, &conf))
+	assert.Equal(1, conf.Version)
+	gw1 := conf.Gateways["GW1"]
+	assert.Equal("http://override.example.com", gw1.URL)
+	assert.Equal(9090, gw1.HTTP.Port)
+	assert.True(gw1.HTTP.Enabled)
+	assert.Equal([]string{"a", "b"}, gw1.HTTP.Hosts)
+	assert.Equal(1.5, *gw1.Factor)
+	assert.Equal(json.Number("100"), gw1.Limit)
+	assert.Equal("value", gw1.Extra["key"])
+	assert.Equal("http://new.example.com", conf.Gateways["new_gw"].URL)
+}
+
+func TestApplyConfigEnvOverlayErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	confType := reflect.TypeOf(&testConf{})
+	err := ApplyConfigEnvOverlay(map[string]interface{}{}, confType, "TEST_CONFIG", []string{"TEST_CONFIG__VERSOIN=1"})
+	assert.Regexp("FFEC100315.*TEST_CONFIG__VERSOIN.*VERSOIN", err)
+
+	err = ApplyConfigEnvOverlay(map[string]interface{}{}, confType, "TEST_CONFIG", []string{"TEST_CONFIG__VERSION__MAJOR=1"})
+	assert.Regexp("FFEC100315.*MAJOR", err)
+
+	err = ApplyConfigEnvOverlay(map[string]interface{}{}, confType, "TEST_CONFIG", []string{"TEST_CONFIG__GATEWAYS____URL=x"})
+	assert.Regexp("FFEC100315", err)
+
+	err = ApplyConfigEnvOverlay(map[string]interface{}{}, confType, "TEST_CONFIG", []string{"TEST_CONFIG__VERSION=one"})
+	assert.Regexp("FFEC100316.*TEST_CONFIG__VERSION", err)
+
+	err = ApplyConfigEnvOverlay(map[string]interface{}{}, confType, "TEST_CONFIG", []string{"TEST_CONFIG__GATEWAYS__GW1__HTTP__ENABLED=maybe"})
+	assert.Regexp("FFEC100316", err)
+
+	err = ApplyConfigEnvOverlay(map[string]interface{}{}, confType, "TEST_CONFIG", []string{"TEST_CONFIG__LIST=[!!!"})
+	assert.Regexp("FFEC100316", err)
+
+	err = ApplyConfigEnvOverlay(map[string]interface{}{}, confType, "TEST_CONFIG", []string{"TEST_CONFIG__GATEWAYS__GW1__EXTRA=[!!!"})
+	assert.Regexp("FFEC100316", err)
+}
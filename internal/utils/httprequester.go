@@ -27,73 +27,146 @@ import (
 
 // HTTPRequester performs common HTTP request logging/processing for utilities
 type HTTPRequester struct {
-	name   string
-	client *http.Client
-	conf   *HTTPRequesterConf
+	name       string
+	client     *http.Client
+	hostClient map[string]*http.Client
+	conf       *HTTPRequesterConf
+	confErr    error
 }
 
 // HTTPRequesterConf configuration for making HTTP reuqests
 type HTTPRequesterConf struct {
-	Headers map[string][]string `json:"headers"`
+	Headers     map[string][]string  `json:"headers"`
+	BasicAuth   *HTTPBasicAuthConf   `json:"basicAuth,omitempty"`
+	BearerToken string               `json:"bearerToken,omitempty"`
+	TLS         TLSConfig            `json:"tls"`
+	HostTLS     map[string]TLSConfig `json:"hostTLS,omitempty"`
 }
 
-// NewHTTPRequester constructor
+// HTTPBasicAuthConf credentials for basic auth on HTTP requests
+type HTTPBasicAuthConf struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// NewHTTPRequester constructor. TLS configuration errors are reported on each request,
+// rather than on construction
 func NewHTTPRequester(name string, conf *HTTPRequesterConf) *HTTPRequester {
-	return &HTTPRequester{
-		name: name,
-		conf: conf,
-		client: &http.Client{
-			Transport: &http.Transport{
-				MaxIdleConns: 1,
-			},
+	hr := &HTTPRequester{
+		name:       name,
+		conf:       conf,
+		hostClient: make(map[string]*http.Client),
+	}
+	hr.client, hr.confErr = newHTTPRequesterClient(&conf.TLS)
+	// Per-host TLS settings are keyed by hostname, or host:port
+	for host, tlsConf := range conf.HostTLS {
+		tlsConf := tlsConf
+		client, err := newHTTPRequesterClient(&tlsConf)
+		if err != nil {
+			hr.confErr = err
+		}
+		hr.hostClient[host] = client
+	}
+	if hr.confErr != nil {
+		log.Errorf("%s: Invalid TLS configuration: %s", name, hr.confErr)
+	}
+	return hr
+}
+
+func newHTTPRequesterClient(tlsConf *TLSConfig) (*http.Client, error) {
+	tlsConfig, err := CreateTLSConfiguration(tlsConf)
+	return &http.Client{
+		Transport: &http.Transport{
+			MaxIdleConns:    1,
+			TLSClientConfig: tlsConfig,
 		},
+	}, err
+}
+
+func (hr *HTTPRequester) clientFor(req *http.Request) *http.Client {
+	if client, ok := hr.hostClient[req.URL.Host]; ok {
+		return client
+	}
+	if client, ok := hr.hostClient[req.URL.Hostname()]; ok {
+		return client
 	}
+	return hr.client
 }
 
 // DoRequest performs a single HTTP request processing the response as JSON
 func (hr *HTTPRequester) DoRequest(method, url string, bodyMap map[string]interface{}) (map[string]interface{}, error) {
+	var jsonBody map[string]interface{}
+	found, err := hr.DoRequestInto(method, url, bodyMap, &jsonBody)
+	if err != nil || !found {
+		return nil, err
+	}
+	if jsonBody == nil {
+		jsonBody = make(map[string]interface{})
+	}
+	return jsonBody, nil
+}
+
+// DoRequestInto performs a single HTTP request, unmarshaling the JSON response into result,
+// for responses that are not JSON objects. A 404 response returns false with no error.
+func (hr *HTTPRequester) DoRequestInto(method, url string, bodyMap map[string]interface{}, result interface{}) (bool, error) {
 	log.Infof("%s %s -->", method, url)
 	var body io.Reader
 	if bodyMap != nil {
 		bodyBytes, ehr := json.Marshal(bodyMap)
 		if ehr != nil {
-			return nil, errors.Errorf(errors.HTTPRequesterSerializeFailed, ehr)
+			return false, errors.Errorf(errors.HTTPRequesterSerializeFailed, ehr)
 		}
 		body = bytes.NewReader(bodyBytes)
 	}
-	req, _ := http.NewRequest(method, url, body)
+	if hr.confErr != nil {
+		return false, errors.Errorf(errors.HTTPRequesterTLSConfig, hr.name, hr.confErr)
+	}
+	req, ehr := http.NewRequest(method, url, body)
+	if ehr != nil {
+		log.Errorf("%s %s <-- !Failed: %s", method, url, ehr)
+		return false, errors.Errorf(errors.HTTPRequesterNonStatusError, hr.name)
+	}
+	// Copy the static headers, so the shared configuration is not modified
 	req.Header = http.Header{}
-	if hr.conf.Headers != nil {
-		req.Header = hr.conf.Headers
+	for k, v := range hr.conf.Headers {
+		req.Header[k] = append([]string{}, v...)
 	}
 	req.Header.Add("content-type", "application/json")
-	res, ehr := hr.client.Do(req)
+	if hr.conf.BasicAuth != nil {
+		req.SetBasicAuth(hr.conf.BasicAuth.Username, hr.conf.BasicAuth.Password)
+	} else if hr.conf.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+hr.conf.BearerToken)
+	}
+	res, ehr := hr.clientFor(req).Do(req)
 	if ehr != nil {
 		log.Errorf("%s %s <-- !Failed: %s", method, url, ehr)
-		return nil, errors.Errorf(errors.HTTPRequesterNonStatusError, hr.name)
+		return false, errors.Errorf(errors.HTTPRequesterNonStatusError, hr.name)
 	}
 	log.Infof("%s %s <-- [%d]", method, url, res.StatusCode)
 	if res.StatusCode == 404 {
-		return nil, nil
+		return false, nil
 	}
-	var jsonBody map[string]interface{}
 	if res.StatusCode == 204 {
-		jsonBody = make(map[string]interface{})
-	} else {
-		resBody, _ := ioutil.ReadAll(res.Body)
+		return true, nil
+	}
+	resBody, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		var jsonBody map[string]interface{}
 		if err := json.Unmarshal(resBody, &jsonBody); err != nil {
 			log.Errorf("%s %s <-- [%d] !Failed to read body: %s", method, url, res.StatusCode, ehr)
-			return nil, errors.Errorf(errors.HTTPRequesterStatusErrorNoData, hr.name, res.StatusCode)
+			return false, errors.Errorf(errors.HTTPRequesterStatusErrorNoData, hr.name, res.StatusCode)
 		}
-		if res.StatusCode < 200 || res.StatusCode >= 300 {
-			log.Errorf("%s %s <-- [%d]: %+v", method, url, res.StatusCode, jsonBody)
-			if ehrMsg, ok := jsonBody["errorMessage"]; ok {
-				return nil, errors.Errorf(errors.HTTPRequesterStatusErrorWithData, hr.name, res.StatusCode, ehrMsg)
-			}
-			return nil, errors.Errorf(errors.HTTPRequesterStatusError, hr.name)
+		log.Errorf("%s %s <-- [%d]: %+v", method, url, res.StatusCode, jsonBody)
+		if ehrMsg, ok := jsonBody["errorMessage"]; ok {
+			return false, errors.Errorf(errors.HTTPRequesterStatusErrorWithData, hr.name, res.StatusCode, ehrMsg)
 		}
+		return false, errors.Errorf(errors.HTTPRequesterStatusError, hr.name)
 	}
-	return jsonBody, nil
+	if err := json.Unmarshal(resBody, result); err != nil {
+		log.Errorf("%s %s <-- [%d] !Failed to read body: %s", method, url, res.StatusCode, ehr)
+		return false, errors.Errorf(errors.HTTPRequesterStatusErrorNoData, hr.name, res.StatusCode)
+	}
+	return true, nil
 }
 
 // GetResponseString returns a string from a response map, asserting its existencer
@@ -16,8 +16,13 @@ package utils
 
 import (
 	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
 	"testing"
 
 	"github.com/julienschmidt/httprouter"
@@ -187,3 +192,95 @@ func TestHTTPRequesterGetResponseStringVariants(t *testing.T) {
 	assert.Regexp("'nil-value' empty \\(or null\\) in unit test response", err)
 
 }
+
+func TestHTTPRequesterHeadersAndBasicAuth(t *testing.T) {
+	assert := assert.New(t)
+
+	var headers []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		headers = append(headers, req.Header)
+		res.WriteHeader(204)
+	}))
+	defer server.Close()
+
+	conf := &HTTPRequesterConf{
+		Headers: map[string][]string{
+			"X-Static": {"value1"},
+		},
+		BasicAuth:   &HTTPBasicAuthConf{Username: "user1", Password: "pass1"},
+		BearerToken: "ignored when basic auth is set",
+	}
+	hr := NewHTTPRequester("unit test", conf)
+	for i := 0; i < 2; i++ {
+		_, err := hr.DoRequest("GET", server.URL, nil)
+		assert.NoError(err)
+	}
+
+	assert.Equal([]string{"value1"}, headers[1]["X-Static"])
+	assert.Equal([]string{"application/json"}, headers[1]["Content-Type"])
+	assert.Equal("Basic dXNlcjE6cGFzczE=", headers[1].Get("Authorization"))
+	assert.Equal(1, len(conf.Headers))
+}
+
+func TestHTTPRequesterBearerToken(t *testing.T) {
+	assert := assert.New(t)
+
+	var authHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		authHeader = req.Header.Get("Authorization")
+		res.WriteHeader(204)
+	}))
+	defer server.Close()
+
+	hr := NewHTTPRequester("unit test", &HTTPRequesterConf{BearerToken: "token1"})
+	_, err := hr.DoRequest("GET", server.URL, nil)
+	assert.NoError(err)
+	assert.Equal("Bearer token1", authHeader)
+}
+
+func TestHTTPRequesterPerHostTLS(t *testing.T) {
+	assert := assert.New(t)
+	dir, _ := ioutil.TempDir("", "fly")
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(204)
+	}))
+	defer server.Close()
+	caFile := path.Join(dir, "ca.pem")
+	ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	u, _ := url.Parse(server.URL)
+
+	// The default client does not trust the test server
+	hr := NewHTTPRequester("unit test", &HTTPRequesterConf{})
+	_, err := hr.DoRequest("GET", server.URL, nil)
+	assert.Regexp("Error querying unit test", err)
+
+	for _, host := range []string{u.Host, u.Hostname()} {
+		hr = NewHTTPRequester("unit test", &HTTPRequesterConf{
+			HostTLS: map[string]TLSConfig{
+				host: {Enabled: true, CACertsFile: caFile},
+			},
+		})
+		_, err = hr.DoRequest("GET", server.URL, nil)
+		assert.NoError(err)
+	}
+}
+
+func TestHTTPRequesterBadTLSConf(t *testing.T) {
+	assert := assert.New(t)
+
+	hr := NewHTTPRequester("unit test", &HTTPRequesterConf{
+		HostTLS: map[string]TLSConfig{
+			"example.com": {Enabled: true, CACertsFile: "/does/not/exist"},
+		},
+	})
+	_, err := hr.DoRequest("GET", "http://localhost", nil)
+	assert.Regexp("FFEC100278", err)
+
+	hr = NewHTTPRequester("unit test", &HTTPRequesterConf{
+		TLS: TLSConfig{Enabled: true, ClientKeyFile: "incomplete"},
+	})
+	_, err = hr.DoRequest("GET", "http://localhost", nil)
+	assert.Regexp("FFEC100278", err)
+}
@@ -0,0 +1,201 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	s3DefaultRegion    = "us-east-1"
+	s3DefaultTimeoutMS = 30000
+)
+
+// ErrS3PreconditionFailed is returned by a conditional write when the object has been
+// modified (or created) by another writer since its ETag was read
+var ErrS3PreconditionFailed = errors.Errorf(errors.S3PreconditionFailed)
+
+// S3Conf configures access to a bucket in AWS S3, or an S3 compatible object store such as MinIO.
+// Credentials are found in the same way as the AWS SDK, unless they are configured here.
+type S3Conf struct {
+	Endpoint        string    `json:"endpoint,omitempty"`
+	Region          string    `json:"region,omitempty"`
+	Bucket          string    `json:"bucket"`
+	AccessKeyID     string    `json:"accessKeyId,omitempty"`
+	SecretAccessKey string    `json:"secretAccessKey,omitempty"`
+	SessionToken    string    `json:"sessionToken,omitempty"`
+	PathStyle       bool      `json:"pathStyle,omitempty"`
+	TimeoutMS       int       `json:"timeoutMS,omitempty"`
+	TLS             TLSConfig `json:"tls,omitempty"`
+}
+
+// S3Client is a minimal client for reading and writing objects in a bucket, using the AWS SDK
+type S3Client struct {
+	conf   S3Conf
+	client *s3.Client
+}
+
+// NewS3Client constructor
+func NewS3Client(conf *S3Conf) (*S3Client, error) {
+	s := &S3Client{
+		conf: *conf,
+	}
+	if s.conf.Bucket == "" {
+		return nil, errors.Errorf(errors.S3MissingBucket)
+	}
+	if s.conf.Region == "" {
+		s.conf.Region = os.Getenv("AWS_REGION")
+	}
+	if s.conf.Region == "" {
+		s.conf.Region = s3DefaultRegion
+	}
+	if s.conf.Endpoint != "" {
+		if _, err := url.Parse(s.conf.Endpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	tlsConfig, err := CreateTLSConfiguration(&s.conf.TLS)
+	if err != nil {
+		return nil, err
+	}
+	timeoutMS := s.conf.TimeoutMS
+	if timeoutMS <= 0 {
+		timeoutMS = s3DefaultTimeoutMS
+	}
+	var static *AWSCredentials
+	if s.conf.AccessKeyID != "" {
+		static = &AWSCredentials{
+			AccessKeyID:     s.conf.AccessKeyID,
+			SecretAccessKey: s.conf.SecretAccessKey,
+			SessionToken:    s.conf.SessionToken,
+		}
+	}
+	awsConf, err := NewAWSConfig(context.Background(), s.conf.Region, time.Duration(timeoutMS)*time.Millisecond, tlsConfig, static)
+	if err != nil {
+		return nil, err
+	}
+	s.client = s3.NewFromConfig(awsConf, func(o *s3.Options) {
+		if s.conf.Endpoint != "" {
+			o.BaseEndpoint = aws.String(s.conf.Endpoint)
+		}
+		o.UsePathStyle = s.conf.PathStyle
+		// Only send and check checksums where S3 requires them, as not every S3 compatible store supports them
+		o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+		o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+	})
+	return s, nil
+}
+
+func (s *S3Client) putInput(key, contentType string, body []byte) *s3.PutObjectInput {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.conf.Bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(body),
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	return input
+}
+
+// PutObject writes an object to the bucket, replacing any existing object with the same key
+func (s *S3Client) PutObject(key, contentType string, body []byte) error {
+	log.Debugf("S3 --> PUT %s", key)
+	_, err := s.client.PutObject(context.Background(), s.putInput(key, contentType, body))
+	return s.requestError(http.MethodPut, key, err)
+}
+
+// PutObjectIfMatch writes an object only if its current ETag matches the one supplied, or only if
+// it does not exist when the ETag is empty. Returns the ETag of the new object, or
+// ErrS3PreconditionFailed if the object has changed.
+func (s *S3Client) PutObjectIfMatch(key, contentType string, body []byte, etag string) (string, error) {
+	input := s.putInput(key, contentType, body)
+	if etag == "" {
+		input.IfNoneMatch = aws.String("*")
+	} else {
+		input.IfMatch = aws.String(etag)
+	}
+	log.Debugf("S3 --> PUT %s (etag=%s)", key, etag)
+	res, err := s.client.PutObject(context.Background(), input)
+	if err != nil {
+		// S3 returns 409 rather than 412 when a conditional write races another in flight
+		if status, _ := AWSErrorDetail(err); status == http.StatusPreconditionFailed || status == http.StatusConflict {
+			return "", ErrS3PreconditionFailed
+		}
+		return "", s.requestError(http.MethodPut, key, err)
+	}
+	return aws.ToString(res.ETag), nil
+}
+
+// GetObject reads an object from the bucket, returning nil if it does not exist
+func (s *S3Client) GetObject(key string) ([]byte, error) {
+	b, _, err := s.GetObjectWithETag(key)
+	return b, err
+}
+
+// GetObjectWithETag reads an object and its ETag, returning nil and an empty ETag if it does not exist
+func (s *S3Client) GetObjectWithETag(key string) ([]byte, string, error) {
+	log.Debugf("S3 --> GET %s", key)
+	res, err := s.client.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(s.conf.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if status, _ := AWSErrorDetail(err); status == http.StatusNotFound {
+			return nil, "", nil
+		}
+		return nil, "", s.requestError(http.MethodGet, key, err)
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return b, aws.ToString(res.ETag), nil
+}
+
+// DeleteObject removes an object from the bucket. Deleting an object that does not exist succeeds.
+func (s *S3Client) DeleteObject(key string) error {
+	log.Debugf("S3 --> DELETE %s", key)
+	_, err := s.client.DeleteObject(context.Background(), &s3.DeleteObjectInput{
+		Bucket: aws.String(s.conf.Bucket),
+		Key:    aws.String(key),
+	})
+	return s.requestError(http.MethodDelete, key, err)
+}
+
+// requestError reports the status returned by S3, or returns the error unchanged if there was no response
+func (s *S3Client) requestError(method, key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	status, detail := AWSErrorDetail(err)
+	if status == 0 {
+		return err
+	}
+	return errors.Errorf(errors.S3RequestFailed, method, key, status, detail)
+}
@@ -0,0 +1,216 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestS3Client(t *testing.T, conf *S3Conf) *S3Client {
+	s, err := NewS3Client(conf)
+	assert.NoError(t, err)
+	return s
+}
+
+func TestS3PutGetObjectPathStyle(t *testing.T) {
+	assert := assert.New(t)
+
+	objects := make(map[string][]byte)
+	svr := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		assert.Regexp("^AWS4-HMAC-SHA256 Credential=ak1/[0-9]{8}/eu-west-1/s3/aws4_request, SignedHeaders=.*;x-amz-security-token, Signature=[0-9a-f]{64}$", req.Header.Get("Authorization"))
+		assert.Equal("token1", req.Header.Get("x-amz-security-token"))
+		switch req.Method {
+		case http.MethodPut:
+			assert.Equal("application/json", req.Header.Get("Content-Type"))
+			objects[req.URL.Path], _ = ioutil.ReadAll(req.Body)
+		case http.MethodGet:
+			if b, ok := objects[req.URL.Path]; ok {
+				res.Write(b)
+			} else {
+				res.WriteHeader(404)
+			}
+		}
+	}))
+	defer svr.Close()
+
+	os.Setenv("AWS_ACCESS_KEY_ID", "ak1")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "sk1")
+	os.Setenv("AWS_SESSION_TOKEN", "token1")
+	os.Setenv("AWS_REGION", "eu-west-1")
+	defer func() {
+		os.Unsetenv("AWS_ACCESS_KEY_ID")
+		os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+		os.Unsetenv("AWS_SESSION_TOKEN")
+		os.Unsetenv("AWS_REGION")
+	}()
+
+	s := newTestS3Client(t, &S3Conf{
+		Endpoint:  svr.URL,
+		Bucket:    "bucket1",
+		PathStyle: true,
+	})
+	err := s.PutObject("a/b.json", "application/json", []byte(`{"a":"b"}`))
+	assert.NoError(err)
+	assert.Equal(`{"a":"b"}`, string(objects["/bucket1/a/b.json"]))
+
+	b, err := s.GetObject("a/b.json")
+	assert.NoError(err)
+	assert.Equal(`{"a":"b"}`, string(b))
+
+	b, err = s.GetObject("a/c.json")
+	assert.NoError(err)
+	assert.Nil(b)
+}
+
+func TestS3RequestErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(403)
+		res.Write([]byte("<Error><Code>AccessDenied</Code></Error>"))
+	}))
+	conf := &S3Conf{
+		Endpoint:        svr.URL,
+		Bucket:          "bucket1",
+		AccessKeyID:     "ak1",
+		SecretAccessKey: "sk1",
+		PathStyle:       true,
+	}
+	s := newTestS3Client(t, conf)
+	err := s.PutObject("key1", "", []byte{})
+	assert.Regexp("FFEC100288.*PUT.*key1.*403.*AccessDenied", err)
+	_, err = s.GetObject("key1")
+	assert.Regexp("FFEC100288.*GET.*403", err)
+
+	svr.Close()
+	_, err = s.GetObject("key1")
+	assert.Error(err)
+}
+
+func TestS3ClientConfigErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewS3Client(&S3Conf{})
+	assert.Regexp("FFEC100286", err)
+	// Credentials are resolved by the SDK when the first request is made
+	_, err = NewS3Client(&S3Conf{Bucket: "bucket1"})
+	assert.NoError(err)
+	_, err = NewS3Client(&S3Conf{Bucket: "bucket1", AccessKeyID: "ak1", SecretAccessKey: "sk1", Endpoint: ":bad"})
+	assert.Error(err)
+	_, err = NewS3Client(&S3Conf{Bucket: "bucket1", AccessKeyID: "ak1", SecretAccessKey: "sk1", TLS: TLSConfig{Enabled: true, CACertsFile: "/does/not/exist"}})
+	assert.Error(err)
+
+	s, err := NewS3Client(&S3Conf{Bucket: "bucket1", AccessKeyID: "ak1", SecretAccessKey: "sk1", Region: "ap-south-1"})
+	assert.NoError(err)
+	assert.Equal("ap-south-1", s.client.Options().Region)
+}
+
+func TestS3ConditionalPutObject(t *testing.T) {
+	assert := assert.New(t)
+
+	var body []byte
+	etag := ""
+	svr := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodPut:
+			if (req.Header.Get("If-None-Match") == "*" && etag != "") ||
+				(req.Header.Get("If-Match") != "" && req.Header.Get("If-Match") != etag) {
+				res.WriteHeader(412)
+				return
+			}
+			body, _ = ioutil.ReadAll(req.Body)
+			etag = fmt.Sprintf(`"etag%d"`, len(body))
+			res.Header().Set("ETag", etag)
+		case http.MethodGet:
+			if etag == "" {
+				res.WriteHeader(404)
+				return
+			}
+			res.Header().Set("ETag", etag)
+			res.Write(body)
+		case http.MethodDelete:
+			body = nil
+			etag = ""
+			res.WriteHeader(204)
+		}
+	}))
+	defer svr.Close()
+
+	s := newTestS3Client(t, &S3Conf{
+		Endpoint:        svr.URL,
+		Bucket:          "bucket1",
+		AccessKeyID:     "ak1",
+		SecretAccessKey: "sk1",
+		PathStyle:       true,
+	})
+
+	b, tag, err := s.GetObjectWithETag("key1")
+	assert.NoError(err)
+	assert.Nil(b)
+	assert.Empty(tag)
+
+	tag, err = s.PutObjectIfMatch("key1", "application/json", []byte(`{}`), "")
+	assert.NoError(err)
+	assert.Equal(`"etag2"`, tag)
+
+	// Creating the object again fails, as it now exists
+	_, err = s.PutObjectIfMatch("key1", "application/json", []byte(`{}`), "")
+	assert.Equal(ErrS3PreconditionFailed, err)
+
+	tag, err = s.PutObjectIfMatch("key1", "application/json", []byte(`{"a":1}`), `"etag2"`)
+	assert.NoError(err)
+	assert.Equal(`"etag7"`, tag)
+	_, err = s.PutObjectIfMatch("key1", "application/json", []byte(`{"a":2}`), `"etag2"`)
+	assert.Regexp("FFEC100343", err)
+
+	b, tag, err = s.GetObjectWithETag("key1")
+	assert.NoError(err)
+	assert.Equal(`{"a":1}`, string(b))
+	assert.Equal(`"etag7"`, tag)
+
+	assert.NoError(s.DeleteObject("key1"))
+	b, _, err = s.GetObjectWithETag("key1")
+	assert.NoError(err)
+	assert.Nil(b)
+}
+
+func TestS3ConditionalRequestErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(500)
+	}))
+	defer svr.Close()
+	s := newTestS3Client(t, &S3Conf{
+		Endpoint:        svr.URL,
+		Bucket:          "bucket1",
+		AccessKeyID:     "ak1",
+		SecretAccessKey: "sk1",
+		PathStyle:       true,
+	})
+	_, err := s.PutObjectIfMatch("key1", "", []byte{}, `"etag1"`)
+	assert.Regexp("FFEC100288.*PUT.*500", err)
+	_, _, err = s.GetObjectWithETag("key1")
+	assert.Regexp("FFEC100288.*GET.*500", err)
+	err = s.DeleteObject("key1")
+	assert.Regexp("FFEC100288.*DELETE.*500", err)
+}
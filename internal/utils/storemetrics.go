@@ -0,0 +1,153 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultSlowStoreOpThreshold is the time after which an operation on a store is logged as slow
+const DefaultSlowStoreOpThreshold = 1 * time.Second
+
+var slowStoreOpThreshold = int64(DefaultSlowStoreOpThreshold)
+
+var storeMetricsRegistry = struct {
+	mux    sync.Mutex
+	stores map[string]*StoreMetrics
+}{stores: make(map[string]*StoreMetrics)}
+
+// StoreOpStats are the timings and outcomes of one type of operation on a store, since it was opened
+type StoreOpStats struct {
+	Count     int64   `json:"count"`
+	Errors    int64   `json:"errors"`
+	Slow      int64   `json:"slow"`
+	ErrorRate float64 `json:"errorRate"`
+	AverageMS float64 `json:"averageMS"`
+	MaxMS     float64 `json:"maxMS"`
+	LastError string  `json:"lastError,omitempty"`
+}
+
+// StoreMetrics times each type of operation on a persistence layer, counting errors and logging
+// operations slower than the threshold, so a store that is degrading shows up before operations
+// start to fail and be retried
+type StoreMetrics struct {
+	name  string
+	mux   sync.Mutex
+	ops   map[string]*StoreOpStats
+	total map[string]time.Duration
+}
+
+// SetSlowStoreOpThreshold sets the threshold for logging slow operations, on all stores
+func SetSlowStoreOpThreshold(threshold time.Duration) {
+	if threshold <= 0 {
+		threshold = DefaultSlowStoreOpThreshold
+	}
+	atomic.StoreInt64(&slowStoreOpThreshold, int64(threshold))
+}
+
+// NewStoreMetrics registers the metrics of a store, which are reported until it is closed.
+// A store opened with the same name replaces the metrics of the previous one.
+func NewStoreMetrics(name string) *StoreMetrics {
+	m := &StoreMetrics{
+		name:  name,
+		ops:   make(map[string]*StoreOpStats),
+		total: make(map[string]time.Duration),
+	}
+	storeMetricsRegistry.mux.Lock()
+	defer storeMetricsRegistry.mux.Unlock()
+	storeMetricsRegistry.stores[name] = m
+	return m
+}
+
+// Start times an operation, which is recorded by calling the returned function with its outcome.
+// Operations on a store without metrics are not recorded.
+func (m *StoreMetrics) Start(op string) func(err error) {
+	if m == nil {
+		return func(error) {}
+	}
+	startTime := time.Now()
+	return func(err error) {
+		m.record(op, time.Since(startTime), err)
+	}
+}
+
+func (m *StoreMetrics) record(op string, elapsed time.Duration, err error) {
+	slow := elapsed >= time.Duration(atomic.LoadInt64(&slowStoreOpThreshold))
+	if slow {
+		log.Warnf("Slow %s operation on %s took %.3fs", op, m.name, elapsed.Seconds())
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	stats := m.ops[op]
+	if stats == nil {
+		stats = &StoreOpStats{}
+		m.ops[op] = stats
+	}
+	stats.Count++
+	m.total[op] += elapsed
+	elapsedMS := float64(elapsed) / float64(time.Millisecond)
+	if elapsedMS > stats.MaxMS {
+		stats.MaxMS = elapsedMS
+	}
+	if slow {
+		stats.Slow++
+	}
+	if err != nil {
+		stats.Errors++
+		stats.LastError = err.Error()
+	}
+}
+
+// Stats returns a copy of the statistics of each type of operation
+func (m *StoreMetrics) Stats() map[string]*StoreOpStats {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	stats := make(map[string]*StoreOpStats, len(m.ops))
+	for op, s := range m.ops {
+		opStats := *s
+		opStats.ErrorRate = float64(s.Errors) / float64(s.Count)
+		opStats.AverageMS = float64(m.total[op]) / float64(time.Millisecond) / float64(s.Count)
+		stats[op] = &opStats
+	}
+	return stats
+}
+
+// Close stops reporting the metrics of the store
+func (m *StoreMetrics) Close() {
+	storeMetricsRegistry.mux.Lock()
+	defer storeMetricsRegistry.mux.Unlock()
+	if storeMetricsRegistry.stores[m.name] == m {
+		delete(storeMetricsRegistry.stores, m.name)
+	}
+}
+
+// AllStoreMetrics returns the statistics of every open store, by the name of the store
+func AllStoreMetrics() map[string]map[string]*StoreOpStats {
+	storeMetricsRegistry.mux.Lock()
+	stores := make([]*StoreMetrics, 0, len(storeMetricsRegistry.stores))
+	for _, m := range storeMetricsRegistry.stores {
+		stores = append(stores, m)
+	}
+	storeMetricsRegistry.mux.Unlock()
+	all := make(map[string]map[string]*StoreOpStats, len(stores))
+	for _, m := range stores {
+		all[m.name] = m.Stats()
+	}
+	return all
+}
@@ -0,0 +1,51 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreMetrics(t *testing.T) {
+	assert := assert.New(t)
+	defer SetSlowStoreOpThreshold(0)
+
+	m := NewStoreMetrics("test-store")
+	m.Start("get")(nil)
+	m.Start("get")(fmt.Errorf("pop"))
+	SetSlowStoreOpThreshold(1 * time.Nanosecond)
+	m.Start("put")(nil)
+
+	stats := AllStoreMetrics()["test-store"]
+	assert.Equal(int64(2), stats["get"].Count)
+	assert.Equal(int64(1), stats["get"].Errors)
+	assert.Equal(0.5, stats["get"].ErrorRate)
+	assert.Equal("pop", stats["get"].LastError)
+	assert.Equal(int64(0), stats["get"].Slow)
+	assert.Equal(int64(1), stats["put"].Slow)
+	assert.True(stats["put"].AverageMS <= stats["put"].MaxMS)
+
+	// Metrics of a store that was replaced under the same name are not removed when it closes
+	replacement := NewStoreMetrics("test-store")
+	m.Close()
+	assert.Empty(AllStoreMetrics()["test-store"])
+	assert.Contains(AllStoreMetrics(), "test-store")
+	replacement.Close()
+	assert.NotContains(AllStoreMetrics(), "test-store")
+}
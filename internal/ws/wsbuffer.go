@@ -0,0 +1,153 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ws
+
+import (
+	"fmt"
+
+	ws "github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+)
+
+// outboundKey identifies the send buffer of a connection that a message is queued on.
+// Replies have their own buffer, separate from any topic.
+type outboundKey struct {
+	replies bool
+	topic   string
+}
+
+var repliesKey = outboundKey{replies: true}
+
+func (k outboundKey) String() string {
+	if k.replies {
+		return "replies"
+	}
+	return fmt.Sprintf("topic '%s'", k.topic)
+}
+
+// sendBuffer is a bounded queue of messages for one topic of a connection
+type sendBuffer struct {
+	key     outboundKey
+	queue   []interface{}
+	dropped int
+}
+
+// gapNotification tells the client how many messages were discarded from the buffer
+func (b *sendBuffer) gapNotification() *webSocketCommandMessage {
+	if b.key.replies {
+		return &webSocketCommandMessage{Type: "replyGap", Dropped: b.dropped}
+	}
+	return &webSocketCommandMessage{Type: "gap", Topic: b.key.topic, Dropped: b.dropped}
+}
+
+// deliver passes a broadcast message or reply to the connection. Without a send buffer size
+// this waits for the sender. Otherwise the message is queued, and the configured policy
+// is applied if the client has fallen behind by the full size of the buffer.
+func (c *webSocketConnection) deliver(key outboundKey, message interface{}) {
+	message = c.frame(key, message)
+	limit := c.server.conf.SendBufferSize
+	if limit <= 0 {
+		select {
+		case c.broadcast <- message:
+		case <-c.closing:
+			log.Warnf("Connection %s closed while attempting to deliver message on %s", c.id, key)
+		}
+		return
+	}
+
+	c.mux.Lock()
+	b, exists := c.outbound[key]
+	if !exists {
+		b = &sendBuffer{key: key}
+		c.outbound[key] = b
+		c.outboundOrder = append(c.outboundOrder, key)
+	}
+	for !c.closed && len(b.queue) >= limit {
+		switch c.server.conf.SendBufferPolicy {
+		case SendBufferPolicyDropOldest:
+			b.queue = b.queue[1:]
+			b.dropped++
+			log.Debugf("WS/%s: Dropped oldest message on %s (%d dropped)", c.id, key, b.dropped)
+		case SendBufferPolicyDisconnect:
+			c.mux.Unlock()
+			err := errors.Errorf(errors.WebSocketSendBufferFull, limit, key)
+			log.Errorf("WS/%s: Closing: %s", c.id, err)
+			closeWithCode(c.conn, ws.ClosePolicyViolation, err.Error())
+			// The listener fails its read, and cleans up the connection
+			c.conn.Close()
+			return
+		default:
+			c.outboundSpace.Wait()
+		}
+	}
+	if c.closed {
+		c.mux.Unlock()
+		log.Warnf("Connection %s closed while attempting to deliver message on %s", c.id, key)
+		return
+	}
+	b.queue = append(b.queue, message)
+	c.mux.Unlock()
+	c.signalOutbound()
+}
+
+func (c *webSocketConnection) signalOutbound() {
+	select {
+	case c.outboundReady <- struct{}{}:
+	default:
+	}
+}
+
+// nextOutbound takes the next message from the send buffers, visiting each topic in turn so
+// one busy topic does not hold up the others. A gap notification is returned ahead of the
+// first message after any were dropped.
+func (c *webSocketConnection) nextOutbound() (message interface{}, more bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for i := 0; i < len(c.outboundOrder) && message == nil; i++ {
+		b := c.outbound[c.outboundOrder[c.outboundNext]]
+		c.outboundNext = (c.outboundNext + 1) % len(c.outboundOrder)
+		switch {
+		case b.dropped > 0:
+			message = c.frame(b.key, b.gapNotification())
+			b.dropped = 0
+		case len(b.queue) > 0:
+			message = b.queue[0]
+			b.queue[0] = nil
+			b.queue = b.queue[1:]
+			c.outboundSpace.Broadcast()
+		}
+	}
+	for _, b := range c.outbound {
+		if len(b.queue) > 0 || b.dropped > 0 {
+			more = true
+			break
+		}
+	}
+	return message, more
+}
+
+// sendOutbound writes one queued message to the client
+func (c *webSocketConnection) sendOutbound() {
+	message, more := c.nextOutbound()
+	if more {
+		c.signalOutbound()
+	}
+	if message != nil {
+		_ = c.conn.WriteJSON(message)
+		c.traceSent()
+	}
+}
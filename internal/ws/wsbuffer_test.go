@@ -0,0 +1,209 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestBufferedConnection returns a server side connection with no sender running,
+// so the test controls when queued messages are written to the client
+func newTestBufferedConnection(t *testing.T, conf *WebSocketServerConf) (*webSocketConnection, *ws.Conn, func()) {
+	s := NewWebSocketServer(conf).(*webSocketServer)
+	serverConns := make(chan *ws.Conn, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := s.upgrader.Upgrade(w, r, nil)
+		assert.NoError(t, err)
+		serverConns <- conn
+	}))
+	u, _ := url.Parse(ts.URL)
+	u.Scheme = "ws"
+	client, _, err := ws.DefaultDialer.Dial(u.String(), nil)
+	assert.NoError(t, err)
+	c := &webSocketConnection{
+		id:            "test",
+		server:        s,
+		conn:          <-serverConns,
+		topics:        make(map[string]*webSocketTopic),
+		closing:       make(chan struct{}),
+		outbound:      make(map[outboundKey]*sendBuffer),
+		outboundReady: make(chan struct{}, 1),
+		trace:         newConnectionTrace(0),
+	}
+	c.outboundSpace = sync.NewCond(&c.mux)
+	return c, client, func() {
+		client.Close()
+		c.conn.Close()
+		ts.Close()
+	}
+}
+
+func TestSendBufferDropOldest(t *testing.T) {
+	assert := assert.New(t)
+
+	c, client, done := newTestBufferedConnection(t, &WebSocketServerConf{
+		SendBufferSize:   2,
+		SendBufferPolicy: SendBufferPolicyDropOldest,
+	})
+	defer done()
+
+	topic := outboundKey{topic: "topic1"}
+	c.deliver(topic, "msg1")
+	c.deliver(topic, "msg2")
+	c.deliver(topic, "msg3")
+	c.deliver(topic, "msg4")
+	c.deliver(outboundKey{replies: true}, "reply1")
+
+	var msgs []interface{}
+	for {
+		msg, more := c.nextOutbound()
+		msgs = append(msgs, msg)
+		if !more {
+			break
+		}
+	}
+	assert.Equal([]interface{}{
+		&webSocketCommandMessage{Type: "gap", Topic: "topic1", Dropped: 2},
+		"reply1",
+		"msg3",
+		"msg4",
+	}, msgs)
+
+	// The gap notification is written to the client like any other message
+	c.deliver(topic, "msg5")
+	c.deliver(topic, "msg6")
+	c.deliver(topic, "msg7")
+	c.sendOutbound()
+	var gap webSocketCommandMessage
+	err := client.ReadJSON(&gap)
+	assert.NoError(err)
+	assert.Equal("gap", gap.Type)
+	assert.Equal(1, gap.Dropped)
+	c.sendOutbound()
+	var val string
+	client.ReadJSON(&val)
+	assert.Equal("msg6", val)
+}
+
+func TestSendBufferReplyGap(t *testing.T) {
+	b := &sendBuffer{key: outboundKey{replies: true}, dropped: 5}
+	assert.Equal(t, &webSocketCommandMessage{Type: "replyGap", Dropped: 5}, b.gapNotification())
+}
+
+func TestSendBufferBlock(t *testing.T) {
+	assert := assert.New(t)
+
+	c, _, done := newTestBufferedConnection(t, &WebSocketServerConf{
+		SendBufferSize: 1,
+	})
+	defer done()
+	assert.Equal(SendBufferPolicyBlock, c.server.conf.SendBufferPolicy)
+
+	topic := outboundKey{topic: "topic1"}
+	c.deliver(topic, "msg1")
+	delivered := make(chan struct{})
+	go func() {
+		c.deliver(topic, "msg2")
+		close(delivered)
+	}()
+	select {
+	case <-delivered:
+		assert.Fail("Producer should have been blocked")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	msg, _ := c.nextOutbound()
+	assert.Equal("msg1", msg)
+	<-delivered
+	msg, more := c.nextOutbound()
+	assert.Equal("msg2", msg)
+	assert.False(more)
+
+	// A blocked producer is released when the connection closes
+	c.deliver(topic, "msg3")
+	go func() {
+		c.deliver(topic, "msg4")
+		close(c.closing)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	c.mux.Lock()
+	c.closed = true
+	c.outboundSpace.Broadcast()
+	c.mux.Unlock()
+	<-c.closing
+}
+
+func TestSendBufferDisconnect(t *testing.T) {
+	assert := assert.New(t)
+
+	c, client, done := newTestBufferedConnection(t, &WebSocketServerConf{
+		SendBufferSize:   1,
+		SendBufferPolicy: SendBufferPolicyDisconnect,
+	})
+	defer done()
+
+	c.deliver(outboundKey{replies: true}, "reply1")
+	c.deliver(outboundKey{replies: true}, "reply2")
+	_, _, err := client.ReadMessage()
+	assert.True(ws.IsCloseError(err, ws.ClosePolicyViolation))
+	assert.Regexp("FFEC100323.*replies", err)
+}
+
+func TestSendBufferUnknownPolicy(t *testing.T) {
+	s := NewWebSocketServer(&WebSocketServerConf{SendBufferPolicy: "wrong"}).(*webSocketServer)
+	assert.Equal(t, SendBufferPolicyBlock, s.conf.SendBufferPolicy)
+}
+
+func TestBroadcastBuffered(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServerConf(&WebSocketServerConf{SendBufferSize: 10})
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	u.Scheme = "ws"
+	u.Path = "/ws"
+	topic := "banana"
+	c, _, err := ws.DefaultDialer.Dial(u.String(), nil)
+	assert.NoError(err)
+
+	c.WriteJSON(&webSocketCommandMessage{
+		Type:  "listen",
+		Topic: topic,
+	})
+	for len(w.topicMap[topic]) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_, b, _ := w.GetChannels(topic)
+	b <- "Hello World"
+	b <- "Hello World Again"
+
+	var val string
+	c.ReadJSON(&val)
+	assert.Equal("Hello World", val)
+	c.ReadJSON(&val)
+	assert.Equal("Hello World Again", val)
+
+	w.Close()
+}
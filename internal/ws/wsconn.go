@@ -15,9 +15,12 @@
 package ws
 
 import (
+	"context"
+	"encoding/json"
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	ws "github.com/gorilla/websocket"
 	log "github.com/sirupsen/logrus"
@@ -28,6 +31,7 @@ import (
 
 type webSocketConnection struct {
 	id        string
+	ctx       context.Context
 	server    *webSocketServer
 	conn      *ws.Conn
 	mux       sync.Mutex
@@ -37,17 +41,72 @@ type webSocketConnection struct {
 	newTopic  chan bool
 	receive   chan error
 	closing   chan struct{}
+	limiter   *rateLimiter
+	// v2 is set when the client negotiated the JSON-RPC sub-protocol
+	v2 bool
+	// reply stream state, protected by mux
+	replyFilter *replyFilter
+	replaying   bool
+	replyBuffer []interface{}
+	// outbound queues, protected by mux, used when a send buffer size is configured
+	outbound      map[outboundKey]*sendBuffer
+	outboundOrder []outboundKey
+	outboundNext  int
+	outboundReady chan struct{}
+	outboundSpace *sync.Cond
+	trace         *connectionTrace
+}
+
+// rateLimiter is a simple token bucket, refilled continuously at the configured rate
+type rateLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func (r *rateLimiter) allow(now time.Time) bool {
+	r.tokens += now.Sub(r.last).Seconds() * r.rate
+	if r.tokens > r.burst {
+		r.tokens = r.burst
+	}
+	r.last = now
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
 }
 
 type webSocketCommandMessage struct {
-	Type    string `json:"type,omitempty"`
-	Topic   string `json:"topic,omitempty"`
-	Message string `json:"message,omitempty"`
+	Type            string      `json:"type,omitempty"`
+	Topic           string      `json:"topic,omitempty"`
+	Message         string      `json:"message,omitempty"`
+	Since           string      `json:"since,omitempty"`
+	RequestIDPrefix string      `json:"requestIdPrefix,omitempty"`
+	From            string      `json:"from,omitempty"`
+	Dropped         int         `json:"dropped,omitempty"`
+	RequestID       string      `json:"requestId,omitempty"`
+	Result          interface{} `json:"result,omitempty"`
+	// raw is the whole command, for commands such as submit that carry a request
+	raw json.RawMessage
 }
 
-func newConnection(server *webSocketServer, conn *ws.Conn) *webSocketConnection {
+// newConnection starts the goroutines of a connection. The context carries the identity of the
+// client from the upgrade request, for the requests it submits.
+func newConnection(ctx context.Context, server *webSocketServer, conn *ws.Conn) *webSocketConnection {
 	wsc := &webSocketConnection{
 		id:        utils.UUIDv4(),
+		ctx:       ctx,
 		server:    server,
 		conn:      conn,
 		newTopic:  make(chan bool),
@@ -55,6 +114,15 @@ func newConnection(server *webSocketServer, conn *ws.Conn) *webSocketConnection
 		broadcast: make(chan interface{}),
 		receive:   make(chan error),
 		closing:   make(chan struct{}),
+		outbound:  make(map[outboundKey]*sendBuffer),
+		// signals the sender there are queued messages, without blocking the producer
+		outboundReady: make(chan struct{}, 1),
+		v2:            conn.Subprotocol() == SubprotocolV2,
+		trace:         newConnectionTrace(server.conf.TraceHistory),
+	}
+	wsc.outboundSpace = sync.NewCond(&wsc.mux)
+	if server.conf.MessageRateLimit > 0 {
+		wsc.limiter = newRateLimiter(server.conf.MessageRateLimit, server.conf.MessageRateBurst)
 	}
 	go wsc.listen()
 	go wsc.sender()
@@ -67,9 +135,12 @@ func (c *webSocketConnection) close() {
 		c.closed = true
 		c.conn.Close()
 		close(c.closing)
+		// wake any producer waiting for space in a send buffer
+		c.outboundSpace.Broadcast()
 	}
 	c.mux.Unlock()
 
+	c.traceClosed()
 	for _, t := range c.topics {
 		c.server.cycleTopic(c.id, t)
 		log.Infof("WS/%s: Websocket closed while active on topic '%s'", c.id, t.topic)
@@ -80,17 +151,22 @@ func (c *webSocketConnection) close() {
 
 func (c *webSocketConnection) sender() {
 	defer c.close()
+	var topics []string
 	buildCases := func() []reflect.SelectCase {
 		c.mux.Lock()
 		defer c.mux.Unlock()
-		cases := make([]reflect.SelectCase, len(c.topics)+3)
+		topics = make([]string, 0, len(c.topics))
+		cases := make([]reflect.SelectCase, len(c.topics)+4)
 		i := 0
 		for _, t := range c.topics {
 			cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(t.senderChannel)}
+			topics = append(topics, t.topic)
 			i++
 		}
 		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.broadcast)}
 		i++
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.outboundReady)}
+		i++
 		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.closing)}
 		i++
 		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.newTopic)}
@@ -107,9 +183,20 @@ func (c *webSocketConnection) sender() {
 		if chosen == len(cases)-1 {
 			// Addition of a new topic
 			cases = buildCases()
-		} else {
+		} else if chosen == len(cases)-3 {
+			// One message from the send buffers, so direct sends are not starved
+			c.sendOutbound()
+		} else if chosen < len(topics) {
 			// Message from one of the existing topics
+			c.traceDispatched(topics[chosen], value.Interface())
+			if err := c.conn.WriteJSON(c.frame(outboundKey{topic: topics[chosen]}, value.Interface())); err != nil {
+				c.traceCompleted(topics[chosen], err)
+			}
+			c.traceSent()
+		} else {
+			// Direct send, already framed for the client
 			_ = c.conn.WriteJSON(value.Interface())
+			c.traceSent()
 		}
 	}
 }
@@ -125,34 +212,50 @@ func (c *webSocketConnection) listenTopic(t *webSocketTopic) {
 	}
 }
 
-func (c *webSocketConnection) listenReplies() {
-	c.server.ListenForReplies(c)
-}
-
 func (c *webSocketConnection) listen() {
 	defer c.close()
 	log.Infof("WS/%s: Connected", c.id)
 	for {
-		var msg webSocketCommandMessage
-		err := c.conn.ReadJSON(&msg)
+		msg, id, err := c.readCommand()
 		if err != nil {
 			log.Errorf("WS/%s: Error: %s", c.id, err)
 			return
 		}
 		log.Debugf("WS/%s: Received: %+v", c.id, msg)
+		c.traceReceived()
+		if c.limiter != nil && !c.limiter.allow(time.Now()) {
+			err := errors.Errorf(errors.WebSocketRateLimitExceeded, c.server.conf.MessageRateLimit)
+			log.Errorf("WS/%s: Closing: %s", c.id, err)
+			closeWithCode(c.conn, ws.ClosePolicyViolation, err.Error())
+			return
+		}
+		if msg == nil {
+			continue
+		}
 
 		t := c.server.getTopic(msg.Topic)
 		switch strings.ToLower(msg.Type) {
 		case "listen":
 			c.listenTopic(t)
+			c.respond(id)
 		case "listenreplies":
-			c.listenReplies()
+			c.listenReplies(msg, id)
+		case "submit":
+			c.submit(msg, id)
 		case "ack":
+			c.traceCompleted(t.topic, nil)
 			c.handleAckOrError(t, nil)
+			c.respond(id)
 		case "error":
-			c.handleAckOrError(t, errors.Errorf(errors.EventStreamsWebSocketErrorFromClient, msg.Message))
+			err := errors.Errorf(errors.EventStreamsWebSocketErrorFromClient, msg.Message)
+			c.traceCompleted(t.topic, err)
+			c.handleAckOrError(t, err)
+			c.respond(id)
 		default:
 			log.Errorf("WS/%s: Unexpected message type: %+v", c.id, msg)
+			if c.v2 {
+				c.respondError(id, jsonRPCMethodNotFound, errors.Errorf(errors.WebSocketUnknownMethod, msg.Type))
+			}
 		}
 	}
 }
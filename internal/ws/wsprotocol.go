@@ -0,0 +1,169 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+)
+
+// SubprotocolV2 is requested by a client in the Sec-WebSocket-Protocol header to use JSON-RPC 2.0
+// framing. Commands are requests (or notifications, without an id) with the command type as the
+// method and the other fields as params, and everything sent by the server is a response to a
+// request or a notification.
+const SubprotocolV2 = "ethconnect.v2"
+
+const jsonRPCVersion = "2.0"
+
+// JSON-RPC 2.0 error codes
+const (
+	jsonRPCParseError     = -32700
+	jsonRPCInvalidRequest = -32600
+	jsonRPCMethodNotFound = -32601
+	jsonRPCInvalidParams  = -32602
+	jsonRPCServerError    = -32000
+)
+
+// Methods of the notifications sent to v2 clients, in addition to the "gap", "replyGap"
+// and "error" command messages
+const (
+	notificationEvents = "events"
+	notificationReply  = "reply"
+)
+
+var jsonRPCNullID = json.RawMessage("null")
+
+type jsonRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type jsonRPCResult struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result"`
+}
+
+type jsonRPCErrorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   *jsonRPCError   `json:"error"`
+}
+
+type jsonRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type jsonRPCNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+// deliveryParams are the params of an events notification, identifying the topic the
+// client needs to ack
+type deliveryParams struct {
+	Topic string      `json:"topic"`
+	Data  interface{} `json:"data"`
+}
+
+// readCommand reads the next command from the client. A nil command with no error means
+// the message was not a valid request, and an error response has been sent.
+func (c *webSocketConnection) readCommand() (*webSocketCommandMessage, json.RawMessage, error) {
+	var msg webSocketCommandMessage
+	_, b, err := c.conn.ReadMessage()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !c.v2 {
+		msg.raw = b
+		return &msg, nil, json.Unmarshal(b, &msg)
+	}
+	var req jsonRPCRequest
+	if err := json.Unmarshal(b, &req); err != nil {
+		c.respondError(jsonRPCNullID, jsonRPCParseError, errors.Errorf(errors.WebSocketInvalidRequest, err))
+		return nil, nil, nil
+	}
+	id := req.ID
+	if id == nil {
+		id = jsonRPCNullID
+	}
+	if req.JSONRPC != jsonRPCVersion || req.Method == "" {
+		c.respondError(id, jsonRPCInvalidRequest, errors.Errorf(errors.WebSocketInvalidRequest, "jsonrpc must be '2.0' and method is required"))
+		return nil, nil, nil
+	}
+	if len(req.Params) > 0 && !bytes.Equal(req.Params, jsonRPCNullID) {
+		if err := json.Unmarshal(req.Params, &msg); err != nil {
+			c.respondError(id, jsonRPCInvalidParams, errors.Errorf(errors.WebSocketInvalidRequest, err))
+			return nil, nil, nil
+		}
+	}
+	msg.Type = req.Method
+	msg.raw = req.Params
+	return &msg, req.ID, nil
+}
+
+// respond confirms a v2 request has been processed. Notifications, and v1 commands, have no id
+// and are not answered.
+func (c *webSocketConnection) respond(id json.RawMessage) {
+	if c.v2 && id != nil {
+		c.sendToClient(&jsonRPCResult{JSONRPC: jsonRPCVersion, ID: id, Result: true})
+	}
+}
+
+// respondError answers a v2 request with an error. v1 clients are sent a message of type "error".
+func (c *webSocketConnection) respondError(id json.RawMessage, code int, err error) {
+	if !c.v2 {
+		c.sendToClient(&webSocketCommandMessage{Type: "error", Message: err.Error()})
+		return
+	}
+	if id == nil {
+		log.Warnf("WS/%s: Error processing notification: %s", c.id, err)
+		return
+	}
+	c.sendToClient(&jsonRPCErrorResponse{
+		JSONRPC: jsonRPCVersion,
+		ID:      id,
+		Error:   &jsonRPCError{Code: code, Message: err.Error()},
+	})
+}
+
+// frame wraps a message for delivery to a v2 client as a notification. Command messages use their
+// type as the method. Messages are returned as-is for v1 clients.
+func (c *webSocketConnection) frame(key outboundKey, message interface{}) interface{} {
+	if !c.v2 {
+		return message
+	}
+	if cmd, ok := message.(*webSocketCommandMessage); ok {
+		params := *cmd
+		params.Type = ""
+		return &jsonRPCNotification{JSONRPC: jsonRPCVersion, Method: cmd.Type, Params: &params}
+	}
+	if key.replies {
+		return &jsonRPCNotification{JSONRPC: jsonRPCVersion, Method: notificationReply, Params: message}
+	}
+	return &jsonRPCNotification{
+		JSONRPC: jsonRPCVersion,
+		Method:  notificationEvents,
+		Params:  &deliveryParams{Topic: key.topic, Data: message},
+	}
+}
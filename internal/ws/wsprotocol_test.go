@@ -0,0 +1,176 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"testing"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func dialTestWebSocketV2(t *testing.T, serverURL string) *ws.Conn {
+	u, _ := url.Parse(serverURL)
+	u.Scheme = "ws"
+	u.Path = "/ws"
+	dialer := &ws.Dialer{Subprotocols: []string{SubprotocolV2}}
+	c, res, err := dialer.Dial(u.String(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, SubprotocolV2, res.Header.Get("Sec-WebSocket-Protocol"))
+	return c
+}
+
+func readTestV2(t *testing.T, c *ws.Conn) map[string]interface{} {
+	var msg map[string]interface{}
+	assert.NoError(t, c.ReadJSON(&msg))
+	assert.Equal(t, "2.0", msg["jsonrpc"])
+	return msg
+}
+
+func TestV2ListenDeliverAck(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+	c := dialTestWebSocketV2(t, ts.URL)
+
+	c.WriteMessage(ws.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"listen","params":{"topic":"topic1"}}`))
+	res := readTestV2(t, c)
+	assert.Equal(float64(1), res["id"])
+	assert.Equal(true, res["result"])
+
+	s, _, r := w.GetChannels("topic1")
+	s <- []string{"event1"}
+	notification := readTestV2(t, c)
+	assert.Equal("events", notification["method"])
+	assert.Nil(notification["id"])
+	assert.Equal(map[string]interface{}{"topic": "topic1", "data": []interface{}{"event1"}}, notification["params"])
+
+	// Acks sent as notifications are not answered
+	c.WriteMessage(ws.TextMessage, []byte(`{"jsonrpc":"2.0","method":"ack","params":{"topic":"topic1"}}`))
+	assert.NoError(<-r)
+
+	s <- []string{"event2"}
+	readTestV2(t, c)
+	c.WriteMessage(ws.TextMessage, []byte(`{"jsonrpc":"2.0","id":"req2","method":"error","params":{"topic":"topic1","message":"Panic!"}}`))
+	assert.Regexp("Panic!", <-r)
+	res = readTestV2(t, c)
+	assert.Equal("req2", res["id"])
+	assert.Equal(true, res["result"])
+
+	w.Close()
+}
+
+func TestV2ProtocolErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+	c := dialTestWebSocketV2(t, ts.URL)
+
+	expectError := func(req string, id interface{}, code float64, errRegexp string) {
+		c.WriteMessage(ws.TextMessage, []byte(req))
+		res := readTestV2(t, c)
+		assert.Equal(id, res["id"], req)
+		rpcErr := res["error"].(map[string]interface{})
+		assert.Equal(code, rpcErr["code"], req)
+		assert.Regexp(errRegexp, rpcErr["message"], req)
+	}
+	expectError(`!json`, nil, jsonRPCParseError, "FFEC100354")
+	expectError(`{"id":1,"method":"listen"}`, float64(1), jsonRPCInvalidRequest, "FFEC100354")
+	expectError(`{"jsonrpc":"2.0","id":2}`, float64(2), jsonRPCInvalidRequest, "FFEC100354")
+	expectError(`{"jsonrpc":"2.0","id":3,"method":"listen","params":["topic1"]}`, float64(3), jsonRPCInvalidParams, "FFEC100354")
+	expectError(`{"jsonrpc":"2.0","id":4,"method":"subscribe"}`, float64(4), jsonRPCMethodNotFound, "FFEC100355.*subscribe")
+
+	// Unknown notifications are not answered, so the next reply is to the following request
+	c.WriteMessage(ws.TextMessage, []byte(`{"jsonrpc":"2.0","method":"subscribe"}`))
+	c.WriteMessage(ws.TextMessage, []byte(`{"jsonrpc":"2.0","id":5,"method":"listen","params":null}`))
+	res := readTestV2(t, c)
+	assert.Equal(float64(5), res["id"])
+
+	w.Close()
+}
+
+func TestV2Replies(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+	c := dialTestWebSocketV2(t, ts.URL)
+
+	c.WriteMessage(ws.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"listenReplies","params":{"since":"yesterday"}}`))
+	res := readTestV2(t, c)
+	assert.Equal(float64(jsonRPCInvalidParams), res["error"].(map[string]interface{})["code"])
+
+	c.WriteMessage(ws.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"listenReplies","params":{"since":"12345"}}`))
+	res = readTestV2(t, c)
+	assert.Equal(float64(jsonRPCServerError), res["error"].(map[string]interface{})["code"])
+	assert.Regexp("FFEC100269", res["error"].(map[string]interface{})["message"])
+
+	w.SetReplyHistory(func(sinceEpochMS int64, from string) ([]map[string]interface{}, error) {
+		return []map[string]interface{}{testReply("req1", "0xaaaa", 12345)}, nil
+	})
+	c.WriteMessage(ws.TextMessage, []byte(`{"jsonrpc":"2.0","id":3,"method":"listenReplies","params":{"since":"12345"}}`))
+	res = readTestV2(t, c)
+	assert.Equal(float64(3), res["id"])
+	notification := readTestV2(t, c)
+	assert.Equal("reply", notification["method"])
+	assert.Equal("req1", notification["params"].(map[string]interface{})["_id"])
+
+	w.SendReply(testReply("req2", "0xaaaa", 23456))
+	notification = readTestV2(t, c)
+	assert.Equal("reply", notification["method"])
+	assert.Equal("req2", notification["params"].(map[string]interface{})["_id"])
+
+	w.Close()
+}
+
+func TestV2ReplayError(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+	w.SetReplyHistory(func(sinceEpochMS int64, from string) ([]map[string]interface{}, error) {
+		return nil, fmt.Errorf("pop")
+	})
+	c := dialTestWebSocketV2(t, ts.URL)
+
+	c.WriteMessage(ws.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"listenReplies","params":{"since":"12345"}}`))
+	readTestV2(t, c)
+	notification := readTestV2(t, c)
+	assert.Equal("error", notification["method"])
+	assert.Regexp("FFEC100270.*pop", notification["params"].(map[string]interface{})["message"])
+
+	w.Close()
+}
+
+func TestV2FrameGap(t *testing.T) {
+	assert := assert.New(t)
+
+	c := &webSocketConnection{v2: true}
+	b := &sendBuffer{key: outboundKey{topic: "topic1"}, dropped: 3}
+	framed, _ := json.Marshal(c.frame(b.key, b.gapNotification()))
+	assert.JSONEq(`{"jsonrpc":"2.0","method":"gap","params":{"topic":"topic1","dropped":3}}`, string(framed))
+
+	b = &sendBuffer{key: repliesKey, dropped: 2}
+	framed, _ = json.Marshal(c.frame(b.key, b.gapNotification()))
+	assert.JSONEq(`{"jsonrpc":"2.0","method":"replyGap","params":{"dropped":2}}`, string(framed))
+
+	c.v2 = false
+	assert.Equal("unframed", c.frame(repliesKey, "unframed"))
+}
@@ -0,0 +1,183 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// ReplyHistory returns persisted replies received at or after the supplied time, oldest first,
+// optionally restricted to a from address. It allows a client to resume a reply stream
+// without polling /replies
+type ReplyHistory func(sinceEpochMS int64, from string) ([]map[string]interface{}, error)
+
+// replyFilter restricts the replies delivered to a connection
+type replyFilter struct {
+	requestIDPrefix string
+	from            string
+}
+
+func (f *replyFilter) matches(message interface{}) bool {
+	if f == nil || (f.requestIDPrefix == "" && f.from == "") {
+		return true
+	}
+	receipt, ok := message.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if f.requestIDPrefix != "" && !strings.HasPrefix(replyRequestID(receipt), f.requestIDPrefix) {
+		return false
+	}
+	if f.from != "" && !strings.EqualFold(utils.GetMapString(receipt, "from"), f.from) {
+		return false
+	}
+	return true
+}
+
+func replyRequestID(receipt map[string]interface{}) string {
+	if id := utils.GetMapString(receipt, "_id"); id != "" {
+		return id
+	}
+	if headers, ok := receipt["headers"].(map[string]interface{}); ok {
+		return utils.GetMapString(headers, "requestId")
+	}
+	return ""
+}
+
+// replyKey identifies a single version of a receipt, as the pending receipt and the
+// final receipt for a request share the same ID
+func replyKey(message interface{}) string {
+	if receipt, ok := message.(map[string]interface{}); ok {
+		return fmt.Sprintf("%s/%v", replyRequestID(receipt), receipt["receivedAt"])
+	}
+	return ""
+}
+
+func parseReplySince(since string) (int64, error) {
+	if isoTime, err := time.Parse(time.RFC3339Nano, since); err == nil {
+		return isoTime.UnixNano() / int64(time.Millisecond), nil
+	}
+	sinceEpochMS, err := strconv.ParseInt(since, 10, 64)
+	if err != nil {
+		return 0, errors.Errorf(errors.WebSocketRepliesBadSince, since)
+	}
+	return sinceEpochMS, nil
+}
+
+// queueReply is called by the server for each live reply. Replies that arrive while
+// the connection is replaying history are held back, so they are delivered in order
+func (c *webSocketConnection) queueReply(message interface{}) bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if !c.replyFilter.matches(message) {
+		return false
+	}
+	if c.replaying {
+		c.replyBuffer = append(c.replyBuffer, message)
+		return false
+	}
+	return true
+}
+
+func (c *webSocketConnection) sendToClient(message interface{}) bool {
+	select {
+	case c.broadcast <- message:
+		return true
+	case <-c.closing:
+		return false
+	}
+}
+
+func (c *webSocketConnection) listenReplies(msg *webSocketCommandMessage, id json.RawMessage) {
+	filter := &replyFilter{
+		requestIDPrefix: msg.RequestIDPrefix,
+		from:            msg.From,
+	}
+	var sinceEpochMS int64
+	var err error
+	if msg.Since != "" {
+		code := jsonRPCInvalidParams
+		sinceEpochMS, err = parseReplySince(msg.Since)
+		if err == nil && c.server.replyHistory == nil {
+			code = jsonRPCServerError
+			err = errors.Errorf(errors.WebSocketRepliesNoHistory)
+		}
+		if err != nil {
+			log.Errorf("WS/%s: Cannot listen for replies: %s", c.id, err)
+			c.respondError(id, code, err)
+			return
+		}
+	}
+
+	c.mux.Lock()
+	c.replyFilter = filter
+	c.replaying = msg.Since != ""
+	c.mux.Unlock()
+	c.server.ListenForReplies(c)
+	c.respond(id)
+	if msg.Since != "" {
+		c.replayReplies(sinceEpochMS, filter)
+	}
+}
+
+// replayReplies sends the history, then any live replies that were held back while
+// the history was being sent, before switching the connection over to live delivery
+func (c *webSocketConnection) replayReplies(sinceEpochMS int64, filter *replyFilter) {
+	sent := make(map[string]bool)
+	history, err := c.server.replyHistory(sinceEpochMS, filter.from)
+	if err != nil {
+		err = errors.Errorf(errors.WebSocketRepliesHistoryFailed, err)
+		log.Errorf("WS/%s: %s", c.id, err)
+		c.sendToClient(c.frame(repliesKey, &webSocketCommandMessage{Type: "error", Message: err.Error()}))
+	}
+	log.Infof("WS/%s: Replaying %d replies since %d", c.id, len(history), sinceEpochMS)
+	for _, receipt := range history {
+		if !filter.matches(receipt) {
+			continue
+		}
+		sent[replyKey(receipt)] = true
+		if !c.sendToClient(c.frame(repliesKey, receipt)) {
+			return
+		}
+	}
+	for {
+		c.mux.Lock()
+		buffered := c.replyBuffer
+		c.replyBuffer = nil
+		if len(buffered) == 0 {
+			c.replaying = false
+		}
+		c.mux.Unlock()
+		if len(buffered) == 0 {
+			return
+		}
+		for _, message := range buffered {
+			if key := replyKey(message); key != "" && sent[key] {
+				continue
+			}
+			if !c.sendToClient(c.frame(repliesKey, message)) {
+				return
+			}
+		}
+	}
+}
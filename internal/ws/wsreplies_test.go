@@ -0,0 +1,206 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ws
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func dialTestWebSocket(t *testing.T, serverURL string) *ws.Conn {
+	u, _ := url.Parse(serverURL)
+	u.Scheme = "ws"
+	u.Path = "/ws"
+	c, _, err := ws.DefaultDialer.Dial(u.String(), nil)
+	assert.NoError(t, err)
+	return c
+}
+
+func waitForReplyListener(w *webSocketServer) {
+	for {
+		w.mux.Lock()
+		n := len(w.replyMap)
+		w.mux.Unlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func testReply(id, from string, receivedAt int64) map[string]interface{} {
+	return map[string]interface{}{
+		"_id":        id,
+		"from":       from,
+		"receivedAt": receivedAt,
+		"headers": map[string]interface{}{
+			"requestId": id,
+		},
+	}
+}
+
+func TestListenRepliesFiltered(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+	c := dialTestWebSocket(t, ts.URL)
+
+	c.WriteJSON(&webSocketCommandMessage{
+		Type:            "listenReplies",
+		RequestIDPrefix: "batch1-",
+		From:            "0xAAAA",
+	})
+	waitForReplyListener(w)
+
+	w.SendReply("not a receipt")
+	w.SendReply(testReply("batch2-1", "0xaaaa", 1))
+	w.SendReply(testReply("batch1-1", "0xbbbb", 2))
+	w.SendReply(testReply("batch1-2", "0xaaaa", 3))
+
+	var val map[string]interface{}
+	c.ReadJSON(&val)
+	assert.Equal("batch1-2", val["_id"])
+
+	w.Close()
+}
+
+func TestListenRepliesSinceReplaysHistory(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+	started := make(chan struct{})
+	proceed := make(chan struct{})
+	w.SetReplyHistory(func(sinceEpochMS int64, from string) ([]map[string]interface{}, error) {
+		assert.Equal(int64(1640995200000), sinceEpochMS)
+		assert.Equal("0xaaaa", from)
+		close(started)
+		<-proceed
+		return []map[string]interface{}{
+			testReply("r1", "0xaaaa", 10),
+			testReply("r2", "0xaaaa", 20),
+		}, nil
+	})
+	c := dialTestWebSocket(t, ts.URL)
+
+	c.WriteJSON(&webSocketCommandMessage{
+		Type:  "listenReplies",
+		Since: "2022-01-01T00:00:00Z",
+		From:  "0xaaaa",
+	})
+	<-started
+	// Replies arriving during the replay are held back, and de-duplicated against the history
+	w.SendReply(testReply("r2", "0xaaaa", 20))
+	w.SendReply(testReply("r3", "0xaaaa", 30))
+	close(proceed)
+
+	for _, expected := range []string{"r1", "r2", "r3"} {
+		var val map[string]interface{}
+		c.ReadJSON(&val)
+		assert.Equal(expected, val["_id"])
+	}
+
+	w.SendReply(testReply("r4", "0xaaaa", 40))
+	var val map[string]interface{}
+	c.ReadJSON(&val)
+	assert.Equal("r4", val["_id"])
+
+	w.Close()
+}
+
+func TestListenRepliesSinceEpochMSHistoryError(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+	w.SetReplyHistory(func(sinceEpochMS int64, from string) ([]map[string]interface{}, error) {
+		assert.Equal(int64(12345), sinceEpochMS)
+		return nil, fmt.Errorf("pop")
+	})
+	c := dialTestWebSocket(t, ts.URL)
+
+	c.WriteJSON(&webSocketCommandMessage{
+		Type:  "listenReplies",
+		Since: "12345",
+	})
+	var errMsg webSocketCommandMessage
+	c.ReadJSON(&errMsg)
+	assert.Equal("error", errMsg.Type)
+	assert.Regexp("FFEC100270.*pop", errMsg.Message)
+
+	// Live delivery continues
+	w.SendReply("live")
+	var val string
+	c.ReadJSON(&val)
+	assert.Equal("live", val)
+
+	w.Close()
+}
+
+func TestListenRepliesSinceErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+	c := dialTestWebSocket(t, ts.URL)
+
+	c.WriteJSON(&webSocketCommandMessage{
+		Type:  "listenReplies",
+		Since: "12345",
+	})
+	var errMsg webSocketCommandMessage
+	c.ReadJSON(&errMsg)
+	assert.Equal("error", errMsg.Type)
+	assert.Regexp("FFEC100269", errMsg.Message)
+
+	w.SetReplyHistory(func(sinceEpochMS int64, from string) ([]map[string]interface{}, error) {
+		return nil, nil
+	})
+	c.WriteJSON(&webSocketCommandMessage{
+		Type:  "listenReplies",
+		Since: "yesterday",
+	})
+	c.ReadJSON(&errMsg)
+	assert.Equal("error", errMsg.Type)
+	assert.Regexp("FFEC100268", errMsg.Message)
+
+	w.mux.Lock()
+	assert.Empty(w.replyMap)
+	w.mux.Unlock()
+
+	w.Close()
+}
+
+func TestReplyFilterMatches(t *testing.T) {
+	assert := assert.New(t)
+
+	var noFilter *replyFilter
+	assert.True(noFilter.matches("anything"))
+	assert.True((&replyFilter{}).matches("anything"))
+
+	f := &replyFilter{requestIDPrefix: "abc"}
+	assert.False(f.matches("anything"))
+	assert.True(f.matches(map[string]interface{}{
+		"headers": map[string]interface{}{"requestId": "abc123"},
+	}))
+	assert.False(f.matches(map[string]interface{}{}))
+	assert.Equal("", replyKey("anything"))
+}
@@ -15,6 +15,8 @@
 package ws
 
 import (
+	"context"
+	"math"
 	"net/http"
 	"reflect"
 	"sync"
@@ -37,10 +39,41 @@ type WebSocketChannels interface {
 type WebSocketServer interface {
 	WebSocketChannels
 	AddRoutes(r *httprouter.Router)
+	SetReplyHistory(history ReplyHistory)
+	SetSubmitter(submitter Submitter)
+	AddDeliveryHook(hook DeliveryHook)
+	ConnectionStats() []*ConnectionStats
 	Close()
 }
 
+// WebSocketServerConf limits the resources a WebSocket client can consume
+type WebSocketServerConf struct {
+	MaxConnections   int     `json:"maxConnections,omitempty"`
+	MaxMessageSize   int64   `json:"maxMessageSize,omitempty"`
+	MessageRateLimit float64 `json:"messageRateLimit,omitempty"`
+	MessageRateBurst int     `json:"messageRateBurst,omitempty"`
+	// SendBufferSize is the number of broadcast messages or replies that can be queued for a
+	// connection on each topic, before SendBufferPolicy is applied. Zero sends each message
+	// directly, blocking until the connection has written it
+	SendBufferSize   int    `json:"sendBufferSize,omitempty"`
+	SendBufferPolicy string `json:"sendBufferPolicy,omitempty"`
+	// TraceHistory is the number of completed batches whose delivery timelines are kept for
+	// each connection, and returned with its statistics
+	TraceHistory int `json:"traceHistory,omitempty"`
+}
+
+const (
+	// SendBufferPolicyBlock holds the producer until the client catches up
+	SendBufferPolicyBlock = "block"
+	// SendBufferPolicyDropOldest discards the oldest queued message, and tells the client
+	// how many were dropped before the next message is sent
+	SendBufferPolicyDropOldest = "dropOldest"
+	// SendBufferPolicyDisconnect closes the connection of the slow client
+	SendBufferPolicyDisconnect = "disconnect"
+)
+
 type webSocketServer struct {
+	conf              *WebSocketServerConf
 	processingTimeout time.Duration
 	mux               sync.Mutex
 	topics            map[string]*webSocketTopic
@@ -50,6 +83,10 @@ type webSocketServer struct {
 	replyChannel      chan interface{}
 	upgrader          *websocket.Upgrader
 	connections       map[string]*webSocketConnection
+	replyHistory      ReplyHistory
+	submitter         Submitter
+	submissions       map[string]*webSocketConnection
+	hooks             []DeliveryHook
 }
 
 type webSocketTopic struct {
@@ -60,18 +97,32 @@ type webSocketTopic struct {
 }
 
 // NewWebSocketServer create a new server with a simplified interface
-func NewWebSocketServer() WebSocketServer {
+func NewWebSocketServer(conf *WebSocketServerConf) WebSocketServer {
+	if conf.MessageRateLimit > 0 && conf.MessageRateBurst <= 0 {
+		conf.MessageRateBurst = int(math.Ceil(conf.MessageRateLimit))
+	}
+	switch conf.SendBufferPolicy {
+	case SendBufferPolicyBlock, SendBufferPolicyDropOldest, SendBufferPolicyDisconnect:
+	default:
+		if conf.SendBufferPolicy != "" {
+			log.Warnf("Unknown WebSocket send buffer policy '%s'. Using '%s'", conf.SendBufferPolicy, SendBufferPolicyBlock)
+		}
+		conf.SendBufferPolicy = SendBufferPolicyBlock
+	}
 	s := &webSocketServer{
+		conf:              conf,
 		connections:       make(map[string]*webSocketConnection),
 		topics:            make(map[string]*webSocketTopic),
 		topicMap:          make(map[string]map[string]*webSocketConnection),
 		replyMap:          make(map[string]*webSocketConnection),
+		submissions:       make(map[string]*webSocketConnection),
 		newTopic:          make(chan bool),
 		replyChannel:      make(chan interface{}),
 		processingTimeout: 30 * time.Second,
 		upgrader: &websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
+			Subprotocols:    []string{SubprotocolV2},
 		},
 	}
 	go s.processBroadcasts()
@@ -87,10 +138,27 @@ func (s *webSocketServer) handler(w http.ResponseWriter, r *http.Request, p http
 	}
 	s.mux.Lock()
 	defer s.mux.Unlock()
-	c := newConnection(s, conn)
+	if s.conf.MaxConnections > 0 && len(s.connections) >= s.conf.MaxConnections {
+		// We complete the upgrade so we can tell the client to back off with a close code,
+		// rather than it seeing a failed handshake it might immediately retry
+		log.Warnf("WebSocket connection from %s rejected: %d/%d connections active", r.RemoteAddr, len(s.connections), s.conf.MaxConnections)
+		closeWithCode(conn, websocket.CloseTryAgainLater, errors.Errorf(errors.WebSocketTooManyConnections, s.conf.MaxConnections).Error())
+		conn.Close()
+		return
+	}
+	if s.conf.MaxMessageSize > 0 {
+		// The library sends a CloseMessageTooBig close code when the limit is exceeded
+		conn.SetReadLimit(s.conf.MaxMessageSize)
+	}
+	// The request context ends when this handler returns, but the connection lives on
+	c := newConnection(context.WithoutCancel(r.Context()), s, conn)
 	s.connections[c.id] = c
 }
 
+func closeWithCode(conn *websocket.Conn, code int, text string) {
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(time.Second))
+}
+
 func (s *webSocketServer) cycleTopic(connInfo string, t *webSocketTopic) {
 	s.mux.Lock()
 	defer s.mux.Unlock()
@@ -108,6 +176,11 @@ func (s *webSocketServer) connectionClosed(c *webSocketConnection) {
 	defer s.mux.Unlock()
 	delete(s.connections, c.id)
 	delete(s.replyMap, c.id)
+	for requestID, submitter := range s.submissions {
+		if submitter == c {
+			delete(s.submissions, requestID)
+		}
+	}
 	for _, topic := range c.topics {
 		delete(s.topicMap[topic.topic], c.id)
 	}
@@ -115,6 +188,13 @@ func (s *webSocketServer) connectionClosed(c *webSocketConnection) {
 
 func (s *webSocketServer) AddRoutes(r *httprouter.Router) {
 	r.GET("/ws", s.handler)
+	r.GET("/ws/connections", s.listConnections)
+	r.GET("/ws/connections/:id", s.getConnection)
+}
+
+// SetReplyHistory enables clients to resume a reply stream with "since"
+func (s *webSocketServer) SetReplyHistory(history ReplyHistory) {
+	s.replyHistory = history
 }
 
 func (s *webSocketServer) Close() {
@@ -155,7 +235,9 @@ func (s *webSocketServer) ListenOnTopic(c *webSocketConnection, topic string) {
 }
 
 func (s *webSocketServer) ListenForReplies(c *webSocketConnection) {
+	s.mux.Lock()
 	s.replyMap[c.id] = c
+	s.mux.Unlock()
 }
 
 func (s *webSocketServer) SendReply(message interface{}) {
@@ -197,7 +279,7 @@ func (s *webSocketServer) processBroadcasts() {
 			topic := topics[chosen]
 			wsconns := getConnListFromMap(s.topicMap[topic])
 			s.mux.Unlock()
-			s.broadcastToConnections(wsconns, value.Interface())
+			s.broadcastToConnections(wsconns, outboundKey{topic: topic}, value.Interface())
 		}
 	}
 }
@@ -217,17 +299,27 @@ func (s *webSocketServer) processReplies() {
 		s.mux.Lock()
 		wsconns := getConnListFromMap(s.replyMap)
 		s.mux.Unlock()
-		log.Debugf("Sending reply to %d WS connections", len(wsconns))
-		s.broadcastToConnections(wsconns, message)
+		submitter := s.takeSubmission(message)
+		matched := make([]*webSocketConnection, 0, len(wsconns)+1)
+		for _, c := range wsconns {
+			if c == submitter {
+				// The receipt of a submission is always delivered to the connection that sent it
+				matched = append(matched, c)
+				submitter = nil
+			} else if c.queueReply(message) {
+				matched = append(matched, c)
+			}
+		}
+		if submitter != nil {
+			matched = append(matched, submitter)
+		}
+		log.Debugf("Sending reply to %d WS connections", len(matched))
+		s.broadcastToConnections(matched, repliesKey, message)
 	}
 }
 
-func (s *webSocketServer) broadcastToConnections(connections []*webSocketConnection, message interface{}) {
+func (s *webSocketServer) broadcastToConnections(connections []*webSocketConnection, key outboundKey, message interface{}) {
 	for _, c := range connections {
-		select {
-		case c.broadcast <- message:
-		case <-c.closing:
-			log.Warnf("Connection %s closed while attempting to deliver reply", c.id)
-		}
+		c.deliver(key, message)
 	}
 }
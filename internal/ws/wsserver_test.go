@@ -29,7 +29,11 @@ import (
 )
 
 func newTestWebSocketServer() (*webSocketServer, *httptest.Server) {
-	s := NewWebSocketServer().(*webSocketServer)
+	return newTestWebSocketServerConf(&WebSocketServerConf{})
+}
+
+func newTestWebSocketServerConf(conf *WebSocketServerConf) (*webSocketServer, *httptest.Server) {
+	s := NewWebSocketServer(conf).(*webSocketServer)
 	r := &httprouter.Router{}
 	s.AddRoutes(r)
 	ts := httptest.NewServer(r)
@@ -366,5 +370,89 @@ func TestBroadcastClosing(t *testing.T) {
 	}
 	close(c.closing)
 	// Check this doesn't block
-	c.server.broadcastToConnections([]*webSocketConnection{c}, "anything")
+	c.server.broadcastToConnections([]*webSocketConnection{c}, outboundKey{topic: "test"}, "anything")
+}
+
+func TestMaxConnections(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServerConf(&WebSocketServerConf{MaxConnections: 1})
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	u.Scheme = "ws"
+	u.Path = "/ws"
+	c1, _, err := ws.DefaultDialer.Dial(u.String(), nil)
+	assert.NoError(err)
+	defer c1.Close()
+
+	c2, _, err := ws.DefaultDialer.Dial(u.String(), nil)
+	assert.NoError(err)
+	_, _, err = c2.ReadMessage()
+	assert.True(ws.IsCloseError(err, ws.CloseTryAgainLater))
+	assert.Regexp("FFEC100232", err)
+	assert.Len(w.connections, 1)
+
+	w.Close()
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServerConf(&WebSocketServerConf{MaxMessageSize: 32})
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	u.Scheme = "ws"
+	u.Path = "/ws"
+	c, _, err := ws.DefaultDialer.Dial(u.String(), nil)
+	assert.NoError(err)
+
+	c.WriteJSON(&webSocketCommandMessage{
+		Type:    "error",
+		Message: "this message is far too long to be accepted",
+	})
+	_, _, err = c.ReadMessage()
+	assert.True(ws.IsCloseError(err, ws.CloseMessageTooBig))
+
+	w.Close()
+}
+
+func TestMessageRateLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServerConf(&WebSocketServerConf{MessageRateLimit: 0.001})
+	defer ts.Close()
+	assert.Equal(1, w.conf.MessageRateBurst)
+
+	u, _ := url.Parse(ts.URL)
+	u.Scheme = "ws"
+	u.Path = "/ws"
+	c, _, err := ws.DefaultDialer.Dial(u.String(), nil)
+	assert.NoError(err)
+
+	c.WriteJSON(&webSocketCommandMessage{Type: "ack", Topic: "t1"})
+	c.WriteJSON(&webSocketCommandMessage{Type: "ack", Topic: "t1"})
+	_, _, err = c.ReadMessage()
+	assert.True(ws.IsCloseError(err, ws.ClosePolicyViolation))
+	assert.Regexp("FFEC100233", err)
+
+	w.Close()
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	assert := assert.New(t)
+
+	r := newRateLimiter(10, 2)
+	now := r.last
+	assert.True(r.allow(now))
+	assert.True(r.allow(now))
+	assert.False(r.allow(now))
+	assert.True(r.allow(now.Add(100 * time.Millisecond)))
+	assert.False(r.allow(now.Add(100 * time.Millisecond)))
+	// Tokens never accumulate beyond the burst size
+	later := now.Add(time.Hour)
+	assert.True(r.allow(later))
+	assert.True(r.allow(later))
+	assert.False(r.allow(later))
 }
@@ -0,0 +1,119 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/hyperledger/firefly-ethconnect/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// Submitter dispatches a request submitted by a WebSocket client, in the same form as the body of
+// a POST to the webhooks API. It returns the reply for the client, and whether a receipt will
+// follow, which is not the case for requests such as queries that are processed synchronously.
+type Submitter func(ctx context.Context, msg map[string]interface{}) (reply interface{}, awaitReceipt bool, err error)
+
+// SetSubmitter enables clients to submit requests with the "submit" command
+func (s *webSocketServer) SetSubmitter(submitter Submitter) {
+	s.submitter = submitter
+}
+
+func (s *webSocketServer) addSubmission(requestID string, c *webSocketConnection) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.submissions[requestID] = c
+}
+
+func (s *webSocketServer) removeSubmission(requestID string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	delete(s.submissions, requestID)
+}
+
+// takeSubmission returns the connection that submitted the request of a reply, if it is still
+// connected. Each request has a single reply, so the request is then forgotten.
+func (s *webSocketServer) takeSubmission(message interface{}) *webSocketConnection {
+	receipt, ok := message.(map[string]interface{})
+	if !ok || receipt["pending"] == true {
+		return nil
+	}
+	requestID := replyRequestID(receipt)
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	c := s.submissions[requestID]
+	delete(s.submissions, requestID)
+	return c
+}
+
+// submit dispatches a request, and registers the connection for its reply before doing so, as the
+// reply can arrive before the submitter returns. Requests are dispatched in the order they are
+// received on a connection, so those from the same address keep their order.
+func (c *webSocketConnection) submit(msg *webSocketCommandMessage, id json.RawMessage) {
+	if c.server.submitter == nil {
+		c.respondSubmitError(id, "", jsonRPCServerError, errors.Errorf(errors.WebSocketSubmitNotEnabled))
+		return
+	}
+	var request map[string]interface{}
+	err := json.Unmarshal(msg.raw, &request)
+	if err == nil && request == nil {
+		err = fmt.Errorf("no request")
+	}
+	if err != nil {
+		c.respondSubmitError(id, "", jsonRPCInvalidParams, errors.Errorf(errors.WebSocketSubmitInvalid, err))
+		return
+	}
+	if !c.v2 {
+		delete(request, "type")
+	}
+
+	// The client can choose the ID to correlate the reply, as on the webhooks API
+	var requestID string
+	if headers, ok := request["headers"].(map[string]interface{}); ok {
+		if requestID, _ = headers["id"].(string); requestID == "" {
+			requestID = utils.UUIDv4()
+			headers["id"] = requestID
+		}
+		c.server.addSubmission(requestID, c)
+	}
+
+	log.Infof("WS/%s: Submitting request %s", c.id, requestID)
+	reply, awaitReceipt, err := c.server.submitter(c.ctx, request)
+	if (err != nil || !awaitReceipt) && requestID != "" {
+		c.server.removeSubmission(requestID)
+	}
+	if err != nil {
+		log.Errorf("WS/%s: Submit failed: %s", c.id, err)
+		c.respondSubmitError(id, requestID, jsonRPCServerError, err)
+		return
+	}
+	if !c.v2 {
+		c.sendToClient(&webSocketCommandMessage{Type: "submitted", RequestID: requestID, Result: reply})
+	} else if id != nil {
+		c.sendToClient(&jsonRPCResult{JSONRPC: jsonRPCVersion, ID: id, Result: reply})
+	}
+}
+
+// respondSubmitError includes the ID of the request, if one was assigned, in the error for v1 clients
+func (c *webSocketConnection) respondSubmitError(id json.RawMessage, requestID string, code int, err error) {
+	if !c.v2 {
+		c.sendToClient(&webSocketCommandMessage{Type: "error", Message: err.Error(), RequestID: requestID})
+		return
+	}
+	c.respondError(id, code, err)
+}
@@ -0,0 +1,159 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ws
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmitV1ReceivesReceipt(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+	var submitted []map[string]interface{}
+	w.SetSubmitter(func(ctx context.Context, msg map[string]interface{}) (interface{}, bool, error) {
+		submitted = append(submitted, msg)
+		return map[string]interface{}{"sent": true}, true, nil
+	})
+	c := dialTestWebSocket(t, ts.URL)
+
+	c.WriteMessage(ws.TextMessage, []byte(`{"type":"submit","headers":{"type":"SendTransaction"},"from":"0xaaaa"}`))
+	var res webSocketCommandMessage
+	assert.NoError(c.ReadJSON(&res))
+	assert.Equal("submitted", res.Type)
+	assert.NotEmpty(res.RequestID)
+	assert.Equal(map[string]interface{}{"sent": true}, res.Result)
+	assert.Len(submitted, 1)
+	assert.Nil(submitted[0]["type"])
+	assert.Equal(res.RequestID, submitted[0]["headers"].(map[string]interface{})["id"])
+
+	// Only the receipt of the submitted request is delivered, without listening for replies
+	w.SendReply(testReply("other", "0xaaaa", 1))
+	w.SendReply(testReply(res.RequestID, "0xaaaa", 2))
+	var receipt map[string]interface{}
+	assert.NoError(c.ReadJSON(&receipt))
+	assert.Equal(res.RequestID, receipt["_id"])
+	w.mux.Lock()
+	assert.Empty(w.submissions)
+	w.mux.Unlock()
+
+	w.Close()
+}
+
+func TestSubmitV1Errors(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+	c := dialTestWebSocket(t, ts.URL)
+
+	c.WriteJSON(&webSocketCommandMessage{Type: "submit"})
+	var res webSocketCommandMessage
+	assert.NoError(c.ReadJSON(&res))
+	assert.Equal("error", res.Type)
+	assert.Regexp("FFEC100445", res.Message)
+
+	w.SetSubmitter(func(ctx context.Context, msg map[string]interface{}) (interface{}, bool, error) {
+		return nil, false, fmt.Errorf("pop")
+	})
+	c.WriteMessage(ws.TextMessage, []byte(`{"type":"submit","headers":{"id":"req1"}}`))
+	assert.NoError(c.ReadJSON(&res))
+	assert.Equal("error", res.Type)
+	assert.Equal("pop", res.Message)
+	assert.Equal("req1", res.RequestID)
+	w.mux.Lock()
+	assert.Empty(w.submissions)
+	w.mux.Unlock()
+
+	w.Close()
+}
+
+func TestSubmitV2ReceivesReceipt(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+	w.SetSubmitter(func(ctx context.Context, msg map[string]interface{}) (interface{}, bool, error) {
+		return map[string]interface{}{"request": msg["headers"].(map[string]interface{})["id"]}, true, nil
+	})
+	c := dialTestWebSocketV2(t, ts.URL)
+
+	c.WriteMessage(ws.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"submit","params":{"headers":{"id":"req1","type":"SendTransaction"}}}`))
+	res := readTestV2(t, c)
+	assert.Equal(float64(1), res["id"])
+	assert.Equal(map[string]interface{}{"request": "req1"}, res["result"])
+
+	// The pending receipt is not delivered, and does not end the submission
+	pending := testReply("req1", "0xaaaa", 1)
+	pending["pending"] = true
+	w.SendReply(pending)
+	w.SendReply(testReply("req1", "0xaaaa", 2))
+	notification := readTestV2(t, c)
+	assert.Equal("reply", notification["method"])
+	assert.Equal(float64(2), notification["params"].(map[string]interface{})["receivedAt"])
+
+	w.Close()
+}
+
+func TestSubmitV2Errors(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+	c := dialTestWebSocketV2(t, ts.URL)
+
+	c.WriteMessage(ws.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"submit","params":{}}`))
+	res := readTestV2(t, c)
+	assert.Equal(float64(jsonRPCServerError), res["error"].(map[string]interface{})["code"])
+	assert.Regexp("FFEC100445", res["error"].(map[string]interface{})["message"])
+
+	calls := 0
+	w.SetSubmitter(func(ctx context.Context, msg map[string]interface{}) (interface{}, bool, error) {
+		calls++
+		return map[string]interface{}{"result": "0x1"}, false, nil
+	})
+	c.WriteMessage(ws.TextMessage, []byte(`{"jsonrpc":"2.0","id":2,"method":"submit","params":null}`))
+	res = readTestV2(t, c)
+	assert.Equal(float64(jsonRPCInvalidParams), res["error"].(map[string]interface{})["code"])
+	assert.Regexp("FFEC100446.*no request", res["error"].(map[string]interface{})["message"])
+
+	// Requests without a receipt to follow are answered, but not tracked
+	c.WriteMessage(ws.TextMessage, []byte(`{"jsonrpc":"2.0","id":3,"method":"submit","params":{"headers":{"type":"Query"}}}`))
+	res = readTestV2(t, c)
+	assert.Equal(map[string]interface{}{"result": "0x1"}, res["result"])
+	assert.Equal(1, calls)
+	w.mux.Lock()
+	assert.Empty(w.submissions)
+	w.mux.Unlock()
+
+	w.Close()
+}
+
+func TestSubmissionsRemovedOnClose(t *testing.T) {
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+	c := &webSocketConnection{id: "conn1"}
+	w.addSubmission("req1", c)
+	w.connectionClosed(c)
+	assert.Empty(t, w.submissions)
+	assert.Nil(t, w.takeSubmission("not a receipt"))
+	w.Close()
+}
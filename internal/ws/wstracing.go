@@ -0,0 +1,340 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/auth"
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+)
+
+// defaultTraceHistory is the number of completed batches kept for each connection
+const defaultTraceHistory = 20
+
+// DeliveryHook is notified as each batch sent on a topic is written to a client, and again
+// when the client acknowledges it, reports an error, or disconnects first.
+// Hooks are called on the goroutines of the connection, so must not block.
+type DeliveryHook interface {
+	BatchDispatched(timeline *BatchTimeline)
+	BatchCompleted(timeline *BatchTimeline)
+}
+
+// BatchTimeline records the delivery of one batch to a client
+type BatchTimeline struct {
+	ConnectionID string     `json:"connectionId"`
+	Topic        string     `json:"topic"`
+	Sequence     uint64     `json:"sequence"`
+	Size         int        `json:"size"`
+	Dispatched   time.Time  `json:"dispatched"`
+	Completed    *time.Time `json:"completed,omitempty"`
+	Acked        bool       `json:"acked"`
+	Error        string     `json:"error,omitempty"`
+	ElapsedMS    int64      `json:"elapsedMS"`
+}
+
+// ConnectionStats are the delivery statistics of one connection. The elapsed times are
+// from writing a batch to the client, until the client acknowledged it or reported an error.
+type ConnectionStats struct {
+	ID                string           `json:"id"`
+	RemoteAddr        string           `json:"remoteAddr"`
+	Subprotocol       string           `json:"subprotocol,omitempty"`
+	Connected         time.Time        `json:"connected"`
+	Topics            []string         `json:"topics"`
+	Replies           bool             `json:"replies"`
+	MessagesSent      uint64           `json:"messagesSent"`
+	BatchesDispatched uint64           `json:"batchesDispatched"`
+	BatchesAcked      uint64           `json:"batchesAcked"`
+	BatchesErrored    uint64           `json:"batchesErrored"`
+	SpuriousAcks      uint64           `json:"spuriousAcks"`
+	AverageElapsedMS  int64            `json:"averageElapsedMS"`
+	MaxElapsedMS      int64            `json:"maxElapsedMS"`
+	LastSent          *time.Time       `json:"lastSent,omitempty"`
+	LastReceived      *time.Time       `json:"lastReceived,omitempty"`
+	InFlight          []*BatchTimeline `json:"inFlight"`
+	Recent            []*BatchTimeline `json:"recent"`
+}
+
+// connectionTrace holds the statistics of a connection, under its own lock so recording them
+// does not contend with the send buffers
+type connectionTrace struct {
+	mux          sync.Mutex
+	connected    time.Time
+	stats        ConnectionStats
+	totalElapsed int64
+	sequence     uint64
+	inFlight     map[string]*BatchTimeline
+	// recent is a ring of the last completed batches, oldest first from recentNext once full
+	recent     []*BatchTimeline
+	recentNext int
+	history    int
+}
+
+func newConnectionTrace(history int) *connectionTrace {
+	if history <= 0 {
+		history = defaultTraceHistory
+	}
+	return &connectionTrace{
+		connected: time.Now().UTC(),
+		inFlight:  make(map[string]*BatchTimeline),
+		history:   history,
+	}
+}
+
+// batchSize is the number of events in a batch, which the event streams send as a slice
+func batchSize(message interface{}) int {
+	v := reflect.ValueOf(message)
+	if v.Kind() == reflect.Slice {
+		return v.Len()
+	}
+	return 1
+}
+
+func (c *webSocketConnection) traceSent() {
+	now := time.Now().UTC()
+	tr := c.trace
+	tr.mux.Lock()
+	tr.stats.MessagesSent++
+	tr.stats.LastSent = &now
+	tr.mux.Unlock()
+}
+
+func (c *webSocketConnection) traceReceived() {
+	now := time.Now().UTC()
+	tr := c.trace
+	tr.mux.Lock()
+	tr.stats.LastReceived = &now
+	tr.mux.Unlock()
+}
+
+// traceDispatched starts the timeline of a batch, before it is written to the client.
+// Any batch still in flight on the topic is completed without an ack, as the sender has
+// given up waiting for it.
+func (c *webSocketConnection) traceDispatched(topic string, message interface{}) {
+	tr := c.trace
+	tr.mux.Lock()
+	superseded := tr.complete(topic, errors.Errorf(errors.EventStreamsWebSocketInterruptedReceive))
+	tr.sequence++
+	timeline := &BatchTimeline{
+		ConnectionID: c.id,
+		Topic:        topic,
+		Sequence:     tr.sequence,
+		Size:         batchSize(message),
+		Dispatched:   time.Now().UTC(),
+	}
+	tr.inFlight[topic] = timeline
+	tr.stats.BatchesDispatched++
+	dispatched := *timeline
+	tr.mux.Unlock()
+
+	hooks := c.server.deliveryHooks()
+	for _, hook := range hooks {
+		if superseded != nil {
+			hook.BatchCompleted(superseded)
+		}
+		hook.BatchDispatched(&dispatched)
+	}
+}
+
+// traceCompleted ends the timeline of the batch in flight on the topic. An ack or error with
+// nothing in flight is counted as spurious.
+func (c *webSocketConnection) traceCompleted(topic string, err error) {
+	tr := c.trace
+	tr.mux.Lock()
+	completed := tr.complete(topic, err)
+	if completed == nil {
+		tr.stats.SpuriousAcks++
+	}
+	tr.mux.Unlock()
+
+	if completed == nil {
+		return
+	}
+	log.Debugf("WS/%s: Batch %d on topic '%s' completed after %dms (acked=%t)", c.id, completed.Sequence, topic, completed.ElapsedMS, completed.Acked)
+	for _, hook := range c.server.deliveryHooks() {
+		hook.BatchCompleted(completed)
+	}
+}
+
+// traceClosed completes every batch in flight when the connection closes
+func (c *webSocketConnection) traceClosed() {
+	tr := c.trace
+	tr.mux.Lock()
+	topics := make([]string, 0, len(tr.inFlight))
+	for topic := range tr.inFlight {
+		topics = append(topics, topic)
+	}
+	tr.mux.Unlock()
+	for _, topic := range topics {
+		c.traceCompleted(topic, errors.Errorf(errors.WebSocketClosed, c.id))
+	}
+}
+
+// complete must be called under the lock. Returns a copy of the completed timeline, for the hooks.
+func (tr *connectionTrace) complete(topic string, err error) *BatchTimeline {
+	timeline, ok := tr.inFlight[topic]
+	if !ok {
+		return nil
+	}
+	delete(tr.inFlight, topic)
+	now := time.Now().UTC()
+	timeline.Completed = &now
+	timeline.ElapsedMS = now.Sub(timeline.Dispatched).Milliseconds()
+	if err != nil {
+		timeline.Error = err.Error()
+		tr.stats.BatchesErrored++
+	} else {
+		timeline.Acked = true
+		tr.stats.BatchesAcked++
+	}
+	tr.totalElapsed += timeline.ElapsedMS
+	if timeline.ElapsedMS > tr.stats.MaxElapsedMS {
+		tr.stats.MaxElapsedMS = timeline.ElapsedMS
+	}
+	if len(tr.recent) < tr.history {
+		tr.recent = append(tr.recent, timeline)
+	} else {
+		tr.recent[tr.recentNext] = timeline
+		tr.recentNext = (tr.recentNext + 1) % tr.history
+	}
+	completed := *timeline
+	return &completed
+}
+
+// snapshot returns a copy of the statistics, safe to serialize without the lock
+func (tr *connectionTrace) snapshot() *ConnectionStats {
+	tr.mux.Lock()
+	defer tr.mux.Unlock()
+	stats := tr.stats
+	stats.Connected = tr.connected
+	if completed := stats.BatchesAcked + stats.BatchesErrored; completed > 0 {
+		stats.AverageElapsedMS = tr.totalElapsed / int64(completed)
+	}
+	stats.InFlight = make([]*BatchTimeline, 0, len(tr.inFlight))
+	for _, timeline := range tr.inFlight {
+		t := *timeline
+		stats.InFlight = append(stats.InFlight, &t)
+	}
+	sort.Slice(stats.InFlight, func(i, j int) bool { return stats.InFlight[i].Sequence < stats.InFlight[j].Sequence })
+	stats.Recent = make([]*BatchTimeline, 0, len(tr.recent))
+	for i := range tr.recent {
+		t := *tr.recent[(tr.recentNext+i)%len(tr.recent)]
+		stats.Recent = append(stats.Recent, &t)
+	}
+	return &stats
+}
+
+// stats combines the delivery statistics with what the connection is listening to
+func (c *webSocketConnection) stats() *ConnectionStats {
+	stats := c.trace.snapshot()
+	stats.ID = c.id
+	stats.RemoteAddr = c.conn.RemoteAddr().String()
+	stats.Subprotocol = c.conn.Subprotocol()
+	c.mux.Lock()
+	stats.Topics = make([]string, 0, len(c.topics))
+	for topic := range c.topics {
+		stats.Topics = append(stats.Topics, topic)
+	}
+	c.mux.Unlock()
+	sort.Strings(stats.Topics)
+	return stats
+}
+
+// AddDeliveryHook registers a hook to be notified of the delivery of each batch
+func (s *webSocketServer) AddDeliveryHook(hook DeliveryHook) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.hooks = append(s.hooks, hook)
+}
+
+func (s *webSocketServer) deliveryHooks() []DeliveryHook {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.hooks
+}
+
+// ConnectionStats returns the statistics of every connection, oldest connection first
+func (s *webSocketServer) ConnectionStats() []*ConnectionStats {
+	s.mux.Lock()
+	wsconns := getConnListFromMap(s.connections)
+	replies := make(map[string]bool, len(s.replyMap))
+	for id := range s.replyMap {
+		replies[id] = true
+	}
+	s.mux.Unlock()
+	stats := make([]*ConnectionStats, 0, len(wsconns))
+	for _, c := range wsconns {
+		cs := c.stats()
+		cs.Replies = replies[c.id]
+		stats = append(stats, cs)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Connected.Before(stats[j].Connected) })
+	return stats
+}
+
+func (s *webSocketServer) listConnections(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+	if err := auth.AuthEventStreams(req.Context()); err != nil {
+		log.Errorf("Unauthorized: %s", err)
+		s.restErrReply(res, req, errors.Errorf(errors.Unauthorized), 401)
+		return
+	}
+	s.restReply(res, req, s.ConnectionStats())
+}
+
+func (s *webSocketServer) getConnection(res http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	log.Infof("--> %s %s", req.Method, req.URL)
+	if err := auth.AuthEventStreams(req.Context()); err != nil {
+		log.Errorf("Unauthorized: %s", err)
+		s.restErrReply(res, req, errors.Errorf(errors.Unauthorized), 401)
+		return
+	}
+	id := params.ByName("id")
+	s.mux.Lock()
+	c, ok := s.connections[id]
+	_, replies := s.replyMap[id]
+	s.mux.Unlock()
+	if !ok {
+		s.restErrReply(res, req, errors.Errorf(errors.WebSocketConnectionNotFound, id), 404)
+		return
+	}
+	stats := c.stats()
+	stats.Replies = replies
+	s.restReply(res, req, stats)
+}
+
+func (s *webSocketServer) restReply(res http.ResponseWriter, req *http.Request, body interface{}) {
+	log.Infof("<-- %s %s [200]", req.Method, req.URL)
+	reply, _ := json.Marshal(body)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(200)
+	_, _ = res.Write(reply)
+}
+
+func (s *webSocketServer) restErrReply(res http.ResponseWriter, req *http.Request, err error, status int) {
+	log.Errorf("<-- %s %s [%d]: %s", req.Method, req.URL, status, err)
+	reply, _ := json.Marshal(errors.ToRESTError(err))
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	_, _ = res.Write(reply)
+}
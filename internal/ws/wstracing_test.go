@@ -0,0 +1,237 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/auth"
+	"github.com/hyperledger/firefly-ethconnect/internal/auth/authtest"
+)
+
+type testDeliveryHook struct {
+	dispatched chan *BatchTimeline
+	completed  chan *BatchTimeline
+}
+
+func newTestDeliveryHook() *testDeliveryHook {
+	return &testDeliveryHook{
+		dispatched: make(chan *BatchTimeline, 10),
+		completed:  make(chan *BatchTimeline, 10),
+	}
+}
+
+func (h *testDeliveryHook) BatchDispatched(timeline *BatchTimeline) {
+	h.dispatched <- timeline
+}
+
+func (h *testDeliveryHook) BatchCompleted(timeline *BatchTimeline) {
+	h.completed <- timeline
+}
+
+func getConnectionStats(t *testing.T, baseURL, path string, expectedStatus int, result interface{}) {
+	res, err := http.Get(baseURL + path)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, expectedStatus, res.StatusCode)
+	err = json.NewDecoder(res.Body).Decode(result)
+	assert.NoError(t, err)
+}
+
+func TestDeliveryTracing(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+	hook := newTestDeliveryHook()
+	w.AddDeliveryHook(hook)
+
+	u, _ := url.Parse(ts.URL)
+	u.Scheme = "ws"
+	u.Path = "/ws"
+	c, _, err := ws.DefaultDialer.Dial(u.String(), nil)
+	assert.NoError(err)
+
+	c.WriteJSON(&webSocketCommandMessage{
+		Type:  "listen",
+		Topic: "topic1",
+	})
+	s, _, r := w.GetChannels("topic1")
+
+	// A batch that is acked
+	s <- []string{"event1", "event2"}
+	var batch []string
+	c.ReadJSON(&batch)
+	assert.Len(batch, 2)
+	dispatched := <-hook.dispatched
+	assert.Equal("topic1", dispatched.Topic)
+	assert.Equal(uint64(1), dispatched.Sequence)
+	assert.Equal(2, dispatched.Size)
+	assert.Nil(dispatched.Completed)
+	time.Sleep(5 * time.Millisecond)
+	c.WriteJSON(&webSocketCommandMessage{
+		Type:  "ack",
+		Topic: "topic1",
+	})
+	assert.NoError(<-r)
+	completed := <-hook.completed
+	assert.True(completed.Acked)
+	assert.NotNil(completed.Completed)
+	assert.GreaterOrEqual(completed.ElapsedMS, int64(5))
+
+	// A batch the client reports an error for
+	s <- []string{"event3"}
+	c.ReadJSON(&batch)
+	<-hook.dispatched
+	c.WriteJSON(&webSocketCommandMessage{
+		Type:    "error",
+		Topic:   "topic1",
+		Message: "Panic!",
+	})
+	assert.Regexp("Panic!", <-r)
+	completed = <-hook.completed
+	assert.False(completed.Acked)
+	assert.Regexp("Panic!", completed.Error)
+
+	// An ack with nothing in flight, and a batch still in flight
+	c.WriteJSON(&webSocketCommandMessage{
+		Type:  "ack",
+		Topic: "topic1",
+	})
+	<-r
+	s <- []string{"event4"}
+	c.ReadJSON(&batch)
+	<-hook.dispatched
+
+	var list []*ConnectionStats
+	getConnectionStats(t, ts.URL, "/ws/connections", 200, &list)
+	assert.Len(list, 1)
+	stats := list[0]
+	assert.Equal([]string{"topic1"}, stats.Topics)
+	assert.False(stats.Replies)
+	assert.NotEmpty(stats.RemoteAddr)
+	assert.Equal(uint64(3), stats.MessagesSent)
+	assert.Equal(uint64(3), stats.BatchesDispatched)
+	assert.Equal(uint64(1), stats.BatchesAcked)
+	assert.Equal(uint64(1), stats.BatchesErrored)
+	assert.Equal(uint64(1), stats.SpuriousAcks)
+	assert.GreaterOrEqual(stats.MaxElapsedMS, int64(5))
+	assert.NotNil(stats.LastSent)
+	assert.NotNil(stats.LastReceived)
+	assert.Len(stats.InFlight, 1)
+	assert.Equal(uint64(3), stats.InFlight[0].Sequence)
+	assert.Len(stats.Recent, 2)
+	assert.True(stats.Recent[0].Acked)
+	assert.False(stats.Recent[1].Acked)
+
+	var single ConnectionStats
+	getConnectionStats(t, ts.URL, "/ws/connections/"+stats.ID, 200, &single)
+	assert.Equal(stats.ID, single.ID)
+	assert.Len(single.InFlight, 1)
+
+	// The batch in flight is completed when the client disconnects
+	c.Close()
+	completed = <-hook.completed
+	assert.Equal(uint64(3), completed.Sequence)
+	assert.Regexp("FFEC100205", completed.Error)
+	<-r
+	w.Close()
+}
+
+func TestDeliveryTracingHistory(t *testing.T) {
+	assert := assert.New(t)
+
+	tr := newConnectionTrace(2)
+	for i := 0; i < 3; i++ {
+		tr.sequence++
+		tr.inFlight["topic1"] = &BatchTimeline{Sequence: tr.sequence, Dispatched: time.Now()}
+		var err error
+		if i == 1 {
+			err = fmt.Errorf("pop")
+		}
+		assert.NotNil(tr.complete("topic1", err))
+	}
+	assert.Nil(tr.complete("topic1", nil))
+
+	stats := tr.snapshot()
+	assert.Equal(uint64(2), stats.BatchesAcked)
+	assert.Equal(uint64(1), stats.BatchesErrored)
+	assert.Len(stats.Recent, 2)
+	assert.Equal(uint64(2), stats.Recent[0].Sequence)
+	assert.Equal("pop", stats.Recent[0].Error)
+	assert.Equal(uint64(3), stats.Recent[1].Sequence)
+	assert.Empty(stats.InFlight)
+
+	assert.Equal(defaultTraceHistory, newConnectionTrace(0).history)
+}
+
+func TestConnectionStatsReplies(t *testing.T) {
+	assert := assert.New(t)
+
+	w, ts := newTestWebSocketServer()
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	u.Scheme = "ws"
+	u.Path = "/ws"
+	c, _, err := ws.DefaultDialer.Dial(u.String(), nil)
+	assert.NoError(err)
+
+	c.WriteJSON(&webSocketCommandMessage{
+		Type: "listenreplies",
+	})
+	for len(w.ConnectionStats()) == 0 || !w.ConnectionStats()[0].Replies {
+		time.Sleep(1 * time.Millisecond)
+	}
+	w.SendReply("Hello World")
+	var val string
+	c.ReadJSON(&val)
+
+	stats := w.ConnectionStats()
+	assert.Equal(uint64(1), stats[0].MessagesSent)
+	assert.Zero(stats[0].BatchesDispatched)
+	w.Close()
+}
+
+func TestConnectionStatsNotFound(t *testing.T) {
+	_, ts := newTestWebSocketServer()
+	defer ts.Close()
+
+	var errBody map[string]string
+	getConnectionStats(t, ts.URL, "/ws/connections/unknown", 404, &errBody)
+	assert.Equal(t, "FFEC100392", errBody["code"])
+}
+
+func TestConnectionStatsUnauthorized(t *testing.T) {
+	auth.RegisterSecurityModule(&authtest.TestSecurityModule{})
+	defer auth.RegisterSecurityModule(nil)
+
+	_, ts := newTestWebSocketServer()
+	defer ts.Close()
+
+	var errBody map[string]string
+	getConnectionStats(t, ts.URL, "/ws/connections", 401, &errBody)
+	assert.Regexp(t, "Unauthorized", errBody["error"])
+	getConnectionStats(t, ts.URL, "/ws/connections/unknown", 401, &errBody)
+	assert.Regexp(t, "Unauthorized", errBody["error"])
+}
@@ -46,9 +46,9 @@ func (_m *ContractStore) AddABI(id string, deployMsg *messages.DeployContract, c
 	return r0, r1
 }
 
-// AddContract provides a mock function with given fields: addrHexNo0x, abiID, pathName, registerAs
-func (_m *ContractStore) AddContract(addrHexNo0x string, abiID string, pathName string, registerAs string) (*contractregistry.ContractInfo, error) {
-	ret := _m.Called(addrHexNo0x, abiID, pathName, registerAs)
+// AddContract provides a mock function with given fields: addrHexNo0x, abiID, pathName, registerAs, labels
+func (_m *ContractStore) AddContract(addrHexNo0x string, abiID string, pathName string, registerAs string, labels map[string]string) (*contractregistry.ContractInfo, error) {
+	ret := _m.Called(addrHexNo0x, abiID, pathName, registerAs, labels)
 
 	if len(ret) == 0 {
 		panic("no return value specified for AddContract")
@@ -56,19 +56,19 @@ func (_m *ContractStore) AddContract(addrHexNo0x string, abiID string, pathName
 
 	var r0 *contractregistry.ContractInfo
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string, string, string, string) (*contractregistry.ContractInfo, error)); ok {
-		return rf(addrHexNo0x, abiID, pathName, registerAs)
+	if rf, ok := ret.Get(0).(func(string, string, string, string, map[string]string) (*contractregistry.ContractInfo, error)); ok {
+		return rf(addrHexNo0x, abiID, pathName, registerAs, labels)
 	}
-	if rf, ok := ret.Get(0).(func(string, string, string, string) *contractregistry.ContractInfo); ok {
-		r0 = rf(addrHexNo0x, abiID, pathName, registerAs)
+	if rf, ok := ret.Get(0).(func(string, string, string, string, map[string]string) *contractregistry.ContractInfo); ok {
+		r0 = rf(addrHexNo0x, abiID, pathName, registerAs, labels)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*contractregistry.ContractInfo)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string, string, string, string) error); ok {
-		r1 = rf(addrHexNo0x, abiID, pathName, registerAs)
+	if rf, ok := ret.Get(1).(func(string, string, string, string, map[string]string) error); ok {
+		r1 = rf(addrHexNo0x, abiID, pathName, registerAs, labels)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -94,17 +94,95 @@ func (_m *ContractStore) AddRemoteInstance(lookupStr string, address string) err
 	return r0
 }
 
-// CheckNameAvailable provides a mock function with given fields: name, isRemote
-func (_m *ContractStore) CheckNameAvailable(name string, isRemote bool) error {
-	ret := _m.Called(name, isRemote)
+// CachedABIListing provides a mock function with given fields:
+func (_m *ContractStore) CachedABIListing() (*contractregistry.CachedListing, error) {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for CachedABIListing")
+	}
+
+	var r0 *contractregistry.CachedListing
+	var r1 error
+	if rf, ok := ret.Get(0).(func() (*contractregistry.CachedListing, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() *contractregistry.CachedListing); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*contractregistry.CachedListing)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CachedContractListing provides a mock function with given fields:
+func (_m *ContractStore) CachedContractListing() (*contractregistry.CachedListing, error) {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for CachedContractListing")
+	}
+
+	var r0 *contractregistry.CachedListing
+	var r1 error
+	if rf, ok := ret.Get(0).(func() (*contractregistry.CachedListing, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() *contractregistry.CachedListing); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*contractregistry.CachedListing)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CheckABIVersionAvailable provides a mock function with given fields: registerAs
+func (_m *ContractStore) CheckABIVersionAvailable(registerAs string) error {
+	ret := _m.Called(registerAs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CheckABIVersionAvailable")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(registerAs)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CheckNameAvailable provides a mock function with given fields: name
+func (_m *ContractStore) CheckNameAvailable(name string) error {
+	ret := _m.Called(name)
 
 	if len(ret) == 0 {
 		panic("no return value specified for CheckNameAvailable")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string, bool) error); ok {
-		r0 = rf(name, isRemote)
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(name)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -117,6 +195,102 @@ func (_m *ContractStore) Close() {
 	_m.Called()
 }
 
+// DeleteABI provides a mock function with given fields: abiID
+func (_m *ContractStore) DeleteABI(abiID string) error {
+	ret := _m.Called(abiID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteABI")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(abiID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteContract provides a mock function with given fields: addrHex
+func (_m *ContractStore) DeleteContract(addrHex string) error {
+	ret := _m.Called(addrHex)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteContract")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(addrHex)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Export provides a mock function with given fields:
+func (_m *ContractStore) Export() (*contractregistry.ContractStoreArchive, error) {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Export")
+	}
+
+	var r0 *contractregistry.ContractStoreArchive
+	var r1 error
+	if rf, ok := ret.Get(0).(func() (*contractregistry.ContractStoreArchive, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() *contractregistry.ContractStoreArchive); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*contractregistry.ContractStoreArchive)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindABIByContent provides a mock function with given fields: deployMsg
+func (_m *ContractStore) FindABIByContent(deployMsg *messages.DeployContract) (*contractregistry.ABIInfo, error) {
+	ret := _m.Called(deployMsg)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindABIByContent")
+	}
+
+	var r0 *contractregistry.ABIInfo
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*messages.DeployContract) (*contractregistry.ABIInfo, error)); ok {
+		return rf(deployMsg)
+	}
+	if rf, ok := ret.Get(0).(func(*messages.DeployContract) *contractregistry.ABIInfo); ok {
+		r0 = rf(deployMsg)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*contractregistry.ABIInfo)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*messages.DeployContract) error); ok {
+		r1 = rf(deployMsg)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetABI provides a mock function with given fields: location, refresh
 func (_m *ContractStore) GetABI(location contractregistry.ABILocation, refresh bool) (*contractregistry.DeployContractWithAddress, error) {
 	ret := _m.Called(location, refresh)
@@ -207,6 +381,36 @@ func (_m *ContractStore) GetLocalABIInfo(abiID string) (*contractregistry.ABIInf
 	return r0, r1
 }
 
+// Import provides a mock function with given fields: archive, overwrite
+func (_m *ContractStore) Import(archive *contractregistry.ContractStoreArchive, overwrite bool) (*contractregistry.ImportResult, error) {
+	ret := _m.Called(archive, overwrite)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Import")
+	}
+
+	var r0 *contractregistry.ImportResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*contractregistry.ContractStoreArchive, bool) (*contractregistry.ImportResult, error)); ok {
+		return rf(archive, overwrite)
+	}
+	if rf, ok := ret.Get(0).(func(*contractregistry.ContractStoreArchive, bool) *contractregistry.ImportResult); ok {
+		r0 = rf(archive, overwrite)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*contractregistry.ImportResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*contractregistry.ContractStoreArchive, bool) error); ok {
+		r1 = rf(archive, overwrite)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Init provides a mock function with given fields:
 func (_m *ContractStore) Init() error {
 	ret := _m.Called()
@@ -225,9 +429,9 @@ func (_m *ContractStore) Init() error {
 	return r0
 }
 
-// ListABIs provides a mock function with given fields:
-func (_m *ContractStore) ListABIs() ([]messages.TimeSortable, error) {
-	ret := _m.Called()
+// ListABIs provides a mock function with given fields: filter
+func (_m *ContractStore) ListABIs(filter *contractregistry.ListingFilter) ([]messages.TimeSortable, error) {
+	ret := _m.Called(filter)
 
 	if len(ret) == 0 {
 		panic("no return value specified for ListABIs")
@@ -235,19 +439,19 @@ func (_m *ContractStore) ListABIs() ([]messages.TimeSortable, error) {
 
 	var r0 []messages.TimeSortable
 	var r1 error
-	if rf, ok := ret.Get(0).(func() ([]messages.TimeSortable, error)); ok {
-		return rf()
+	if rf, ok := ret.Get(0).(func(*contractregistry.ListingFilter) ([]messages.TimeSortable, error)); ok {
+		return rf(filter)
 	}
-	if rf, ok := ret.Get(0).(func() []messages.TimeSortable); ok {
-		r0 = rf()
+	if rf, ok := ret.Get(0).(func(*contractregistry.ListingFilter) []messages.TimeSortable); ok {
+		r0 = rf(filter)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]messages.TimeSortable)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	if rf, ok := ret.Get(1).(func(*contractregistry.ListingFilter) error); ok {
+		r1 = rf(filter)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -255,9 +459,9 @@ func (_m *ContractStore) ListABIs() ([]messages.TimeSortable, error) {
 	return r0, r1
 }
 
-// ListContracts provides a mock function with given fields:
-func (_m *ContractStore) ListContracts() ([]messages.TimeSortable, error) {
-	ret := _m.Called()
+// ListContracts provides a mock function with given fields: filter
+func (_m *ContractStore) ListContracts(filter *contractregistry.ListingFilter) ([]messages.TimeSortable, error) {
+	ret := _m.Called(filter)
 
 	if len(ret) == 0 {
 		panic("no return value specified for ListContracts")
@@ -265,14 +469,122 @@ func (_m *ContractStore) ListContracts() ([]messages.TimeSortable, error) {
 
 	var r0 []messages.TimeSortable
 	var r1 error
-	if rf, ok := ret.Get(0).(func() ([]messages.TimeSortable, error)); ok {
+	if rf, ok := ret.Get(0).(func(*contractregistry.ListingFilter) ([]messages.TimeSortable, error)); ok {
+		return rf(filter)
+	}
+	if rf, ok := ret.Get(0).(func(*contractregistry.ListingFilter) []messages.TimeSortable); ok {
+		r0 = rf(filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]messages.TimeSortable)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*contractregistry.ListingFilter) error); ok {
+		r1 = rf(filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LocalResolver provides a mock function with given fields:
+func (_m *ContractStore) LocalResolver() contractregistry.ContractResolver {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for LocalResolver")
+	}
+
+	var r0 contractregistry.ContractResolver
+	if rf, ok := ret.Get(0).(func() contractregistry.ContractResolver); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(contractregistry.ContractResolver)
+		}
+	}
+
+	return r0
+}
+
+// PublishABI provides a mock function with given fields: abiID, publishAs
+func (_m *ContractStore) PublishABI(abiID string, publishAs string) (string, error) {
+	ret := _m.Called(abiID, publishAs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PublishABI")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string) (string, error)); ok {
+		return rf(abiID, publishAs)
+	}
+	if rf, ok := ret.Get(0).(func(string, string) string); ok {
+		r0 = rf(abiID, publishAs)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(abiID, publishAs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RegisterABIVersion provides a mock function with given fields: abiID, registerAs
+func (_m *ContractStore) RegisterABIVersion(abiID string, registerAs string) (*contractregistry.ABIInfo, error) {
+	ret := _m.Called(abiID, registerAs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RegisterABIVersion")
+	}
+
+	var r0 *contractregistry.ABIInfo
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string) (*contractregistry.ABIInfo, error)); ok {
+		return rf(abiID, registerAs)
+	}
+	if rf, ok := ret.Get(0).(func(string, string) *contractregistry.ABIInfo); ok {
+		r0 = rf(abiID, registerAs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*contractregistry.ABIInfo)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(abiID, registerAs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Reindex provides a mock function with given fields:
+func (_m *ContractStore) Reindex() (*contractregistry.ReindexResult, error) {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Reindex")
+	}
+
+	var r0 *contractregistry.ReindexResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func() (*contractregistry.ReindexResult, error)); ok {
 		return rf()
 	}
-	if rf, ok := ret.Get(0).(func() []messages.TimeSortable); ok {
+	if rf, ok := ret.Get(0).(func() *contractregistry.ReindexResult); ok {
 		r0 = rf()
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]messages.TimeSortable)
+			r0 = ret.Get(0).(*contractregistry.ReindexResult)
 		}
 	}
 
@@ -285,6 +597,36 @@ func (_m *ContractStore) ListContracts() ([]messages.TimeSortable, error) {
 	return r0, r1
 }
 
+// RenameContract provides a mock function with given fields: addrHex, registerAs
+func (_m *ContractStore) RenameContract(addrHex string, registerAs string) (*contractregistry.ContractInfo, error) {
+	ret := _m.Called(addrHex, registerAs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RenameContract")
+	}
+
+	var r0 *contractregistry.ContractInfo
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string) (*contractregistry.ContractInfo, error)); ok {
+		return rf(addrHex, registerAs)
+	}
+	if rf, ok := ret.Get(0).(func(string, string) *contractregistry.ContractInfo); ok {
+		r0 = rf(addrHex, registerAs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*contractregistry.ContractInfo)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(addrHex, registerAs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ResolveContractAddress provides a mock function with given fields: registeredName
 func (_m *ContractStore) ResolveContractAddress(registeredName string) (string, error) {
 	ret := _m.Called(registeredName)
@@ -313,6 +655,24 @@ func (_m *ContractStore) ResolveContractAddress(registeredName string) (string,
 	return r0, r1
 }
 
+// UpdateContract provides a mock function with given fields: info
+func (_m *ContractStore) UpdateContract(info *contractregistry.ContractInfo) error {
+	ret := _m.Called(info)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateContract")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*contractregistry.ContractInfo) error); ok {
+		r0 = rf(info)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewContractStore creates a new instance of ContractStore. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewContractStore(t interface {
@@ -97,6 +97,24 @@ func (_m *RemoteRegistry) LoadFactoryForInstance(lookupStr string, refresh bool)
 	return r0, r1
 }
 
+// PublishGateway provides a mock function with given fields: lookupStr, deployMsg
+func (_m *RemoteRegistry) PublishGateway(lookupStr string, deployMsg *messages.DeployContract) error {
+	ret := _m.Called(lookupStr, deployMsg)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PublishGateway")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, *messages.DeployContract) error); ok {
+		r0 = rf(lookupStr, deployMsg)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // RegisterInstance provides a mock function with given fields: lookupStr, address
 func (_m *RemoteRegistry) RegisterInstance(lookupStr string, address string) error {
 	ret := _m.Called(lookupStr, address)
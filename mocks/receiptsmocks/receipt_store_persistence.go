@@ -27,6 +27,34 @@ func (_m *ReceiptStorePersistence) AddReceipt(requestID string, receipt *map[str
 	return r0
 }
 
+// DeleteReceipts provides a mock function with given fields: requestIDs
+func (_m *ReceiptStorePersistence) DeleteReceipts(requestIDs []string) (int, error) {
+	ret := _m.Called(requestIDs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteReceipts")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func([]string) (int, error)); ok {
+		return rf(requestIDs)
+	}
+	if rf, ok := ret.Get(0).(func([]string) int); ok {
+		r0 = rf(requestIDs)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func([]string) error); ok {
+		r1 = rf(requestIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetReceipt provides a mock function with given fields: requestID
 func (_m *ReceiptStorePersistence) GetReceipt(requestID string) (*map[string]interface{}, error) {
 	ret := _m.Called(requestID)
@@ -87,6 +115,34 @@ func (_m *ReceiptStorePersistence) GetReceipts(skip int, limit int, ids []string
 	return r0, r1
 }
 
+// PruneReceipts provides a mock function with given fields: olderThanEpochMS, maxCount
+func (_m *ReceiptStorePersistence) PruneReceipts(olderThanEpochMS int64, maxCount int) (int, error) {
+	ret := _m.Called(olderThanEpochMS, maxCount)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PruneReceipts")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64, int) (int, error)); ok {
+		return rf(olderThanEpochMS, maxCount)
+	}
+	if rf, ok := ret.Get(0).(func(int64, int) int); ok {
+		r0 = rf(olderThanEpochMS, maxCount)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(int64, int) error); ok {
+		r1 = rf(olderThanEpochMS, maxCount)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewReceiptStorePersistence creates a new instance of ReceiptStorePersistence. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewReceiptStorePersistence(t interface {
@@ -0,0 +1,43 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package errorcodes is the catalogue of the FFEC error codes returned by ethconnect, in the
+// "code" field of REST errors and error replies. It is generated from internal/errors with
+// "go generate ./pkg/errorcodes", and codes are never removed, renamed or reused once released.
+package errorcodes
+
+//go:generate go run ../../internal/errors/gen -src ../../internal/errors/errors.go -out .
+
+// Entry describes one error code. The message is a format string, with the
+// inserts that vary between occurrences of the error as %s or %d verbs.
+type Entry struct {
+	Name        string `json:"name"`
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+	Description string `json:"description,omitempty"`
+}
+
+var byCode map[string]*Entry
+
+func init() {
+	byCode = make(map[string]*Entry, len(Catalogue))
+	for _, e := range Catalogue {
+		byCode[e.Code] = e
+	}
+}
+
+// Lookup returns the catalogue entry for a code such as "FFEC100003", or nil if it is not known
+func Lookup(code string) *Entry {
+	return byCode[code]
+}
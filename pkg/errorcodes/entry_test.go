@@ -0,0 +1,36 @@
+// Copyright 2022 Kaleido
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+//     http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errorcodes
+
+import (
+	"testing"
+
+	"github.com/hyperledger/firefly-ethconnect/internal/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	assert := assert.New(t)
+	e := Lookup(ConfigFileReadFailed)
+	assert.Equal("ConfigFileReadFailed", e.Name)
+	assert.Equal("Failed to read %s: %s", e.Message)
+	assert.Nil(Lookup("FFEC999999"))
+}
+
+func TestCodesMatchErrors(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(errors.ConfigFileReadFailed.Code(), ConfigFileReadFailed)
+	assert.Equal(errors.Errorf(errors.EventStreamsSubscribeBadGetLogsConf).Code(), EventStreamsSubscribeBadGetLogsConf)
+}
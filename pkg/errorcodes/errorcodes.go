@@ -0,0 +1,1465 @@
+// Code generated by internal/errors/gen. DO NOT EDIT.
+
+package errorcodes
+
+const (
+	// AddressBookLookupBadURL we got back a bad URL from the remote address book after our REST call
+	AddressBookLookupBadURL = "FFEC100000"
+	// AddressBookLookupBadHostsFile we have a custom hosts file for DNS resolution, but it cannot be processed
+	AddressBookLookupBadHostsFile = "FFEC100001"
+	// AddressBookLookupNotFound remote addressbook says no
+	AddressBookLookupNotFound = "FFEC100002"
+	// ConfigFileReadFailed failed to read the server config file
+	ConfigFileReadFailed = "FFEC100003"
+	// CompilerVersionNotFound the runtime context of ethconnect has not been configured with a compiler for the requested version
+	CompilerVersionNotFound = "FFEC100004"
+	// CompilerVersionBadRequest the user requested a bad semver
+	CompilerVersionBadRequest = "FFEC100005"
+	// CompilerFailedSolc compilation failure output from solc
+	CompilerFailedSolc = "FFEC100006"
+	// CompilerOutputMissingContract the output from the compiler does not include the requested contract
+	CompilerOutputMissingContract = "FFEC100007"
+	// CompilerOutputMultipleContracts need to select one
+	CompilerOutputMultipleContracts = "FFEC100008"
+	// CompilerBytecodeInvalid hex output from compiler could not be parsed
+	CompilerBytecodeInvalid = "FFEC100009"
+	// CompilerBytecodeEmpty null result from succcessful compile in solc
+	CompilerBytecodeEmpty = "FFEC100010"
+	// CompilerABISerialize could not serialize the ABI output from solc
+	CompilerABISerialize = "FFEC100011"
+	// CompilerABIReRead could not re-read serialized output after writing the ABI
+	CompilerABIReRead = "FFEC100012"
+	// CompilerSerializeDevDocs could not serialize the dev docs output from solc
+	CompilerSerializeDevDocs = "FFEC100013"
+	// ConfigNoRPC missing config for JSON/RPC
+	ConfigNoRPC = "FFEC100014"
+	// ConfigKafkaMissingOutputTopic response topic missing
+	ConfigKafkaMissingOutputTopic = "FFEC100015"
+	// ConfigKafkaMissingInputTopic request topic missing
+	ConfigKafkaMissingInputTopic = "FFEC100016"
+	// ConfigKafkaMissingConsumerGroup consumer group missing
+	ConfigKafkaMissingConsumerGroup = "FFEC100017"
+	// ConfigKafkaMissingBadSASL problem with SASL config
+	ConfigKafkaMissingBadSASL = "FFEC100018"
+	// ConfigKafkaMissingBrokers missing/empty brokers
+	ConfigKafkaMissingBrokers = "FFEC100019"
+	// ConfigRESTGatewayRequiredReceiptStore need to enable params for REST Gatewya
+	ConfigRESTGatewayRequiredReceiptStore = "FFEC100020"
+	// ConfigRESTGatewayRequiredRPC and RPC stuff
+	ConfigRESTGatewayRequiredRPC = "FFEC100021"
+	// ConfigWebhooksDirectRPC for webhooks direct
+	ConfigWebhooksDirectRPC = "FFEC100022"
+	// ConfigTLSCertOrKey incomplete TLS config
+	ConfigTLSCertOrKey = "FFEC100023"
+	// ConfigNoYAML missing configuration file on server start
+	ConfigNoYAML = "FFEC100024"
+	// ConfigYAMLParseFile failed to parse YAML during server startup
+	ConfigYAMLParseFile = "FFEC100025"
+	// ConfigYAMLPostParseFile failed to process YAML as JSON after parsing
+	ConfigYAMLPostParseFile = "FFEC100026"
+	// DeployTransactionMissingCode a DeployTransaction message, without code to deploy
+	DeployTransactionMissingCode = "FFEC100027"
+	// EventStreamsDBLoad failed to init DB
+	EventStreamsDBLoad = "FFEC100028"
+	// EventStreamsNoID attempt to create an event stream/sub without an ID
+	EventStreamsNoID = "FFEC100029"
+	// EventStreamsInvalidActionType unknown action type
+	EventStreamsInvalidActionType = "FFEC100030"
+	// EventStreamsWebhookNoURL attempt to create a Webhook event stream without a URL
+	EventStreamsWebhookNoURL = "FFEC100031"
+	// EventStreamsWebhookInvalidURL attempt to create a Webhook event stream with an invalid URL
+	EventStreamsWebhookInvalidURL = "FFEC100032"
+	// EventStreamsWebhookResumeActive resume when already resumed
+	EventStreamsWebhookResumeActive = "FFEC100033"
+	// EventStreamsWebhookProhibitedAddress some IP ranges can be restricted
+	EventStreamsWebhookProhibitedAddress = "FFEC100034"
+	// EventStreamsWebhookFailedHTTPStatus server at the other end of a webhook returned a non-OK response
+	EventStreamsWebhookFailedHTTPStatus = "FFEC100035"
+	// EventStreamsSubscribeBadBlock the starting block for a subscription request is invalid
+	EventStreamsSubscribeBadBlock = "FFEC100036"
+	// EventStreamsSubscribeStoreFailed problem saving a subscription to our DB
+	EventStreamsSubscribeStoreFailed = "FFEC100037"
+	// EventStreamsSubscribeNoEvent missing event
+	EventStreamsSubscribeNoEvent = "FFEC100038"
+	// EventStreamsSubscriptionNotFound sub not found
+	EventStreamsSubscriptionNotFound = "FFEC100039"
+	// EventStreamsCreateStreamStoreFailed problem saving a subscription to our DB
+	EventStreamsCreateStreamStoreFailed = "FFEC100040"
+	// EventStreamsCreateStreamResourceErr problem creating a resource required by the eventstream
+	EventStreamsCreateStreamResourceErr = "FFEC100041"
+	// EventStreamsStreamNotFound stream not found
+	EventStreamsStreamNotFound = "FFEC100042"
+	// EventStreamsLogDecode problem decoding the logs for an event emitted on the chain
+	EventStreamsLogDecode = "FFEC100043"
+	// EventStreamsLogDecodeInsufficientTopics ran out of topics according to the indexed fields described on the ABI event
+	EventStreamsLogDecodeInsufficientTopics = "FFEC100044"
+	// EventStreamsLogDecodeData RLP decoding of the data section of the logs failed
+	EventStreamsLogDecodeData = "FFEC100045"
+	// EventStreamsWebSocketNotConfigured WebSocket not configured
+	EventStreamsWebSocketNotConfigured = "FFEC100046"
+	// EventStreamsWebSocketInterruptedSend When we are interrupted waiting for a viable connection to send down
+	EventStreamsWebSocketInterruptedSend = "FFEC100047"
+	// EventStreamsWebSocketInterruptedReceive When we are interrupted waiting for a viable connection to send down
+	EventStreamsWebSocketInterruptedReceive = "FFEC100048"
+	// EventStreamsWebSocketErrorFromClient Error message received from client
+	EventStreamsWebSocketErrorFromClient = "FFEC100049"
+	// EventStreamsCannotUpdateType cannot change tyep
+	EventStreamsCannotUpdateType = "FFEC100050"
+	// EventStreamsInvalidDistributionMode unknown distribution mode
+	EventStreamsInvalidDistributionMode = "FFEC100051"
+	// EventStreamsUpdateAlreadyInProgress update already in progress
+	EventStreamsUpdateAlreadyInProgress = "FFEC100052"
+	// KakfaProducerConfirmMsgUnknown we received a confirmation callback, but we aren't expecting it
+	KakfaProducerConfirmMsgUnknown = "FFEC100053"
+	// KVStoreDBLoad failed to init DB
+	KVStoreDBLoad = "FFEC100054"
+	// KVStoreMemFilteringUnsupported memory db is really just for testing. Only ID and since filtering are supported
+	KVStoreMemFilteringUnsupported = "FFEC100055"
+	// HDWalletSigningFailed problem returned from remote HDWallet API
+	HDWalletSigningFailed = "FFEC100056"
+	// HDWalletSigningBadData we got a response, but not with the correct fields
+	HDWalletSigningBadData = "FFEC100057"
+	// HDWalletSigningNoConfig we had a request for HD Wallet signing, but we don't have the required config
+	HDWalletSigningNoConfig = "FFEC100058"
+	// HelperStrToAddressRequiredField re-usable error for missing fields
+	HelperStrToAddressRequiredField = "FFEC100059"
+	// HelperStrToAddressBadAddress re-usable error for bad address
+	HelperStrToAddressBadAddress = "FFEC100060"
+	// HelperYAMLorJSONPayloadTooLarge input message too large
+	HelperYAMLorJSONPayloadTooLarge = "FFEC100061"
+	// HelperYAMLorJSONPayloadReadFailed failed to read input
+	HelperYAMLorJSONPayloadReadFailed = "FFEC100062"
+	// HelperYAMLorJSONPayloadParseFailed input message got error parsing
+	HelperYAMLorJSONPayloadParseFailed = "FFEC100063"
+	// HTTPRequesterSerializeFailed common HTTP request utility for extensions, failed to serialize request
+	HTTPRequesterSerializeFailed = "FFEC100064"
+	// HTTPRequesterNonStatusError common HTTP request utility for extensions, got an error sending a request
+	HTTPRequesterNonStatusError = "FFEC100065"
+	// HTTPRequesterStatusErrorNoData common HTTP request utility for extensions, got a status code, but couldn't deserialize payload
+	HTTPRequesterStatusErrorNoData = "FFEC100066"
+	// HTTPRequesterStatusErrorWithData common HTTP request utility for extensions, got a non-ok status code with JSON errorMessage
+	HTTPRequesterStatusErrorWithData = "FFEC100067"
+	// HTTPRequesterStatusError common HTTP request utility for extensions, got a non-ok status code
+	HTTPRequesterStatusError = "FFEC100068"
+	// HTTPRequesterResponseMissingField common HTTP request utility for extensions, missing expected field in response
+	HTTPRequesterResponseMissingField = "FFEC100069"
+	// HTTPRequesterResponseNonStringField common HTTP request utility for extensions, expected string for field in response
+	HTTPRequesterResponseNonStringField = "FFEC100070"
+	// HTTPRequesterResponseNullField common HTTP request utility for extensions, expected non-empty response field
+	HTTPRequesterResponseNullField = "FFEC100071"
+	// ReceiptStoreDisabled not configured
+	ReceiptStoreDisabled = "FFEC100072"
+	// ReceiptStoreDBLoad failed to init DB
+	ReceiptStoreDBLoad = "FFEC100073"
+	// ReceiptStoreMongoDBConnect couldn't connect to MongoDB
+	ReceiptStoreMongoDBConnect = "FFEC100074"
+	// ReceiptStoreMongoDBIndex couldn't create MongoDB index
+	ReceiptStoreMongoDBIndex = "FFEC100075"
+	// ReceiptStoreLevelDBConnect couldn't open file for the level DB
+	ReceiptStoreLevelDBConnect = "FFEC100076"
+	// ReceiptStoreSerializeResponse problem sending a receipt stored back over the REST API
+	ReceiptStoreSerializeResponse = "FFEC100077"
+	// ReceiptStoreInvalidRequestID bad ID query
+	ReceiptStoreInvalidRequestID = "FFEC100078"
+	// ReceiptStoreInvalidRequestMaxLimit bad limit over max
+	ReceiptStoreInvalidRequestMaxLimit = "FFEC100079"
+	// ReceiptStoreInvalidRequestBadLimit bad limit
+	ReceiptStoreInvalidRequestBadLimit = "FFEC100080"
+	// ReceiptStoreInvalidRequestBadSkip bad skip
+	ReceiptStoreInvalidRequestBadSkip = "FFEC100081"
+	// ReceiptStoreInvalidRequestBadSince bad since
+	ReceiptStoreInvalidRequestBadSince = "FFEC100082"
+	// ReceiptStoreFailedQuery wrapper over detailed error
+	ReceiptStoreFailedQuery = "FFEC100083"
+	// ReceiptStoreFailedQuerySingle wrapper over detailed error
+	ReceiptStoreFailedQuerySingle = "FFEC100084"
+	// ReceiptStoreFailedNotFound receipt isn't in the store
+	ReceiptStoreFailedNotFound = "FFEC100085"
+	// RemoteRegistryCacheInit initialzation issue for remote contract registry
+	RemoteRegistryCacheInit = "FFEC100086"
+	// RemoteRegistryNotConfigured cannot register as a remote registry is not configured
+	RemoteRegistryNotConfigured = "FFEC100087"
+	// RemoteRegistryRegistrationFailed error during registration with remote contract registry
+	RemoteRegistryRegistrationFailed = "FFEC100088"
+	// RemoteRegistryLookupGatewayNotFound did not find the requested ID in the remote registry for a gateway/factory
+	RemoteRegistryLookupGatewayNotFound = "FFEC100089"
+	// RemoteRegistryLookupInstanceNotFound did not find the requested ID in the remote registry for a contract instance
+	RemoteRegistryLookupInstanceNotFound = "FFEC100090"
+	// RemoteRegistryLookupGenericProcessingFailed we don't return the full original error over the REST API after logging
+	RemoteRegistryLookupGenericProcessingFailed = "FFEC100091"
+	// RESTGatewayGatewayNotFound the gateway REST API interface (the 'factory' / ABI generic interface) was not found
+	RESTGatewayGatewayNotFound = "FFEC100092"
+	// RESTGatewayInstanceNotFound the instance REST API interface (an individual registered address) was not found
+	RESTGatewayInstanceNotFound = "FFEC100093"
+	// RESTGatewayEventNotDeclared attempt to subscribe to an event on an instance that does not exist
+	RESTGatewayEventNotDeclared = "FFEC100094"
+	// RESTGatewayMethodNotDeclared attempt to invoke a method name that does not exist in the ABI, or register globally for an event that doesn't exist
+	RESTGatewayMethodNotDeclared = "FFEC100095"
+	// RESTGatewayInvalidToAddress failed to parse a 'to' address supplied on a path
+	RESTGatewayInvalidToAddress = "FFEC100096"
+	// RESTGatewayInvalidFromAddress failed to parse a 'from' address supplied on a path
+	RESTGatewayInvalidFromAddress = "FFEC100097"
+	// RESTGatewayMissingParameter did not supply a parameter required by the method
+	RESTGatewayMissingParameter = "FFEC100098"
+	// RESTGatewayMissingFromAddress did not supply a signing address for the transaction
+	RESTGatewayMissingFromAddress = "FFEC100099"
+	// RESTGatewaySubscribeMissingStreamParameter missed the ID of the stream when registering
+	RESTGatewaySubscribeMissingStreamParameter = "FFEC100100"
+	// RESTGatewayMixedPrivateForAndGroupID confused privacy group info, using simple/Tessera style as well as pre-defined/Orion style
+	RESTGatewayMixedPrivateForAndGroupID = "FFEC100101"
+	// RESTGatewayEventManagerInitFailed constructor failure for event manager
+	RESTGatewayEventManagerInitFailed = "FFEC100102"
+	// RESTGatewayEventStreamInvalid attempt to create an event stream with invalid parameters
+	RESTGatewayEventStreamInvalid = "FFEC100103"
+	// RESTGatewayPostDeployMissingAddress after deployment the receipt did not contain a contract address
+	RESTGatewayPostDeployMissingAddress = "FFEC100104"
+	// RESTGatewayRegistrationSuppliedInvalidAddress invalid address when registering an existing instance of a contract
+	RESTGatewayRegistrationSuppliedInvalidAddress = "FFEC100105"
+	// RESTGatewaySyncMsgTypeMismatch sync-invoke code paths in REST API Gateway should be maintained such that this cannot happen
+	RESTGatewaySyncMsgTypeMismatch = "FFEC100106"
+	// RESTGatewaySyncWrapErrorWithTXDetail wraps a low level error with transaction hash context on sync APIs before returning
+	RESTGatewaySyncWrapErrorWithTXDetail = "FFEC100107"
+	// RESTGatewayMethodTypeInvalid unsupported method type
+	RESTGatewayMethodTypeInvalid = "FFEC100108"
+	// RESTGatewayMethodABIInvalid error processing method from ABI
+	RESTGatewayMethodABIInvalid = "FFEC100109"
+	// RESTGatewayEventABIInvalid error processing method from ABI
+	RESTGatewayEventABIInvalid = "FFEC100110"
+	// RESTGatewayCompileContractInvalidFormData invalid form data when requesting a compilation to generate an ABI/bytecode
+	RESTGatewayCompileContractInvalidFormData = "FFEC100111"
+	// RESTGatewayCompileContractCompileFailed failed to perform compile
+	RESTGatewayCompileContractCompileFailed = "FFEC100112"
+	// RESTGatewayCompileContractPostCompileFailed failed to process output of compilation
+	RESTGatewayCompileContractPostCompileFailed = "FFEC100113"
+	// RESTGatewayCompileContractExtractedReadFailed failed to read extracted contents of uploaded data
+	RESTGatewayCompileContractExtractedReadFailed = "FFEC100114"
+	// RESTGatewayCompileContractNoSOL failed to find any solidity files in uploaded data
+	RESTGatewayCompileContractNoSOL = "FFEC100115"
+	// RESTGatewayCompileContractSolcVerFail failed while checking version of solidity compiler 'solc'
+	RESTGatewayCompileContractSolcVerFail = "FFEC100116"
+	// RESTGatewayCompileContractCompileFailDetails output from compiler failure
+	RESTGatewayCompileContractCompileFailDetails = "FFEC100117"
+	// RESTGatewayCompileContractSolcOutputProcessFail failed to process output of compilation
+	RESTGatewayCompileContractSolcOutputProcessFail = "FFEC100118"
+	// RESTGatewayCompileContractSlashes unsafe slash characters in filenames
+	RESTGatewayCompileContractSlashes = "FFEC100119"
+	// RESTGatewayCompileContractUnzipRead error opening zip/tgz to read (no extra information to remote caller)
+	RESTGatewayCompileContractUnzipRead = "FFEC100120"
+	// RESTGatewayCompileContractUnzipWrite error writing extracted zip (no extra information to remote caller)
+	RESTGatewayCompileContractUnzipWrite = "FFEC100121"
+	// RESTGatewayCompileContractUnzipCopy error writing extracted zip (no extra information to remote caller)
+	RESTGatewayCompileContractUnzipCopy = "FFEC100122"
+	// RESTGatewayCompileContractUnzip failure thrown from decompression library during extract
+	RESTGatewayCompileContractUnzip = "FFEC100123"
+	// RESTGatewayLocalStoreContractSave local filesystem storage failure for contract instance (non-registry code flow)
+	RESTGatewayLocalStoreContractSave = "FFEC100124"
+	// RESTGatewayLocalStoreContractLoad local filesystem load failure for contract instance (non-registry code flow)
+	RESTGatewayLocalStoreContractLoad = "FFEC100125"
+	// RESTGatewayLocalStoreContractNotFound local filesystem not found (non-registry code flow)
+	RESTGatewayLocalStoreContractNotFound = "FFEC100126"
+	// RESTGatewayLocalStoreABINotFound lookup of ABI failed not found (non-registry code flow)
+	RESTGatewayLocalStoreABINotFound = "FFEC100127"
+	// RESTGatewayLocalStoreABILoad local filesystem load failure for ABI details (non-registry code flow)
+	RESTGatewayLocalStoreABILoad = "FFEC100128"
+	// RESTGatewayLocalStoreABIParse local filesystem parse failure for ABI details (non-registry code flow)
+	RESTGatewayLocalStoreABIParse = "FFEC100129"
+	// RESTGatewayLocalStoreMissingABI did not supply ABI JSON when attempting to install ABI (non-registry code flow)
+	RESTGatewayLocalStoreMissingABI = "FFEC100130"
+	// RESTGatewayInvalidABI invalid serialized ABI in msg
+	RESTGatewayInvalidABI = "FFEC100131"
+	// RESTGatewayLocalStoreContractSavePostDeploy local filesystem storage failure for contract instance post deploy (non-registry code flow)
+	RESTGatewayLocalStoreContractSavePostDeploy = "FFEC100132"
+	// RESTGatewayFriendlyNameClash duplicate friendly name when reigstering
+	RESTGatewayFriendlyNameClash = "FFEC100133"
+	// RESTGatewayResourceErr problem creating a resource required by the gateway
+	RESTGatewayResourceErr = "FFEC100134"
+	// RPCCallReturnedError specified RPC call returned error
+	RPCCallReturnedError = "FFEC100135"
+	// RPCConnectFailed error connecting to back-end server over JSON/RPC
+	RPCConnectFailed = "FFEC100136"
+	// SecurityModulePluginLoad failed to load .so
+	SecurityModulePluginLoad = "FFEC100137"
+	// SecurityModulePluginSymbol missing symbol in plugin
+	SecurityModulePluginSymbol = "FFEC100138"
+	// SecurityModuleNoAuthContext missing auth context in context object at point security module is invoked
+	SecurityModuleNoAuthContext = "FFEC100140"
+	// TransactionQueryFailed transaction lookup failed
+	TransactionQueryFailed = "FFEC100141"
+	// TransactionQueryMethodMismatch transaction input did not match the method queried
+	TransactionQueryMethodMismatch = "FFEC100142"
+	// TransactionSendConstructorPackArgs RLP encoding failure for a constructor
+	TransactionSendConstructorPackArgs = "FFEC100143"
+	// TransactionSendMethodPackArgs RLP encoding failure for a method
+	TransactionSendMethodPackArgs = "FFEC100144"
+	// TransactionSendInputTypeUnknown there is a type in the ABI inputs that we don't understand
+	TransactionSendInputTypeUnknown = "FFEC100145"
+	// TransactionSendOutputTypeUnknown there is a type in the ABI outputs that we don't understand
+	TransactionSendOutputTypeUnknown = "FFEC100146"
+	// TransactionSendGasEstimateFailed gas estimation failed prior to sending TX
+	TransactionSendGasEstimateFailed = "FFEC100147"
+	// TransactionSendCallFailedNoRevert failed to perform an eth_call with a JSON/RPC error (not a revert)
+	TransactionSendCallFailedNoRevert = "FFEC100148"
+	// TransactionSendCallFailedRevertMessage directly passes the revert message from the EVM
+	TransactionSendCallFailedRevertMessage = "FFEC100149"
+	// TransactionSendCallFailedRevertNoMessage when we couldn't process the EVM revert message
+	TransactionSendCallFailedRevertNoMessage = "FFEC100150"
+	// TransactionSendMissingPrivateFromOrion there is no default privateFrom in Orion, so the user must always supply it
+	TransactionSendMissingPrivateFromOrion = "FFEC100151"
+	// TransactionSendPrivateTXWithExternalSigner we don't allow private transactions to be combined with a HD Wallet or other external signer currently
+	TransactionSendPrivateTXWithExternalSigner = "FFEC100152"
+	// TransactionSendPrivateForAndPrivacyGroup mixed both params
+	TransactionSendPrivateForAndPrivacyGroup = "FFEC100153"
+	// TransactionSendNonceFailWithPrivacyGroup when we successfully lookup the privacy group, but cannot get the nonce
+	TransactionSendNonceFailWithPrivacyGroup = "FFEC100154"
+	// TransactionSendMissingMethod a request to send a transaction was received (webhook/Kafka) that was missing method details (unexpected when using REST APIs that validate this)
+	TransactionSendMissingMethod = "FFEC100155"
+	// TransactionSendBadNonce a user-supplied nonce string in the JSON input cannot be processed
+	TransactionSendBadNonce = "FFEC100156"
+	// TransactionSendBadValue a user-supplied value (eth amount to transfer) string in the JSON input cannot be processed
+	TransactionSendBadValue = "FFEC100157"
+	// TransactionSendBadGas a user-supplied gas (maximum gas to spend on the TX) string in the JSON input cannot be processed
+	TransactionSendBadGas = "FFEC100158"
+	// TransactionSendBadGasPrice a user-supplied gasPrice (eth to pay for each unit of gas spent) string in the JSON input cannot be processed
+	TransactionSendBadGasPrice = "FFEC100159"
+	// TransactionSendInputTypeBadNumber the input JSON value supplied for a method parameter cannot be converted to a number
+	TransactionSendInputTypeBadNumber = "FFEC100160"
+	// TransactionSendInputTypeBadJSONTypeForNumber the input JSON value supplied for a method parameter was not a number or a string, and needs to be converted to a number
+	TransactionSendInputTypeBadJSONTypeForNumber = "FFEC100161"
+	// TransactionSendInputTypeBadJSONTypeForArray the input JSON value supplied for a method parameter was not compatible with coercion to an array
+	TransactionSendInputTypeBadJSONTypeForArray = "FFEC100162"
+	// TransactionSendInputTypeBadNull the input JSON value supplied was null
+	TransactionSendInputTypeBadNull = "FFEC100163"
+	// TransactionSendInputTypeBadJSONTypeForBoolean the input JSON value supplied for a method parameter was not compatible with coercion to a boolean
+	TransactionSendInputTypeBadJSONTypeForBoolean = "FFEC100164"
+	// TransactionSendInputTypeBadJSONTypeForString the input JSON value supplied for a method parameter was not compatible with coercion to a boolean
+	TransactionSendInputTypeBadJSONTypeForString = "FFEC100165"
+	// TransactionSendInputTypeAddress the input JSON value supplied for a method parameter couldn't be parsed as an eth address
+	TransactionSendInputTypeAddress = "FFEC100166"
+	// TransactionSendInputTypeBadJSONTypeForAddress the input JSON value supplied for a method parameter was not compatible with coercion to an eth address
+	TransactionSendInputTypeBadJSONTypeForAddress = "FFEC100167"
+	// TransactionSendInputTypeBadJSONTypeInNumericArray one of the entries inside of a numeric array, is not valid as a number
+	TransactionSendInputTypeBadJSONTypeInNumericArray = "FFEC100168"
+	// TransactionSendInputTypeBadByteOutsideRange one of the entries inside of a byte array, is a number outside the range for bytes
+	TransactionSendInputTypeBadByteOutsideRange = "FFEC100169"
+	// TransactionSendInputTypeBadJSONTypeForBytes one of the entries inside of a byte array, is a number outside the range for bytes
+	TransactionSendInputTypeBadJSONTypeForBytes = "FFEC100170"
+	// TransactionSendInputTypeBadJSONTypeForTuple if we are provided a non object input on the JSON for a struct (tuple)
+	TransactionSendInputTypeBadJSONTypeForTuple = "FFEC100171"
+	// TransactionSendInputTypeNotSupported did not know how to handle this type - enhancement required
+	TransactionSendInputTypeNotSupported = "FFEC100172"
+	// TransactionSendInputCountMismatch wrong number of args supplied according to the ABI
+	TransactionSendInputCountMismatch = "FFEC100173"
+	// TransactionSendInputStructureWrong the JSON structure supplied to describe the arguments is incorrect according to our schema
+	TransactionSendInputStructureWrong = "FFEC100174"
+	// TransactionSendInputInLineTypeArrayNotString when sending us an ABI definition for the inputs directly
+	TransactionSendInputInLineTypeArrayNotString = "FFEC100175"
+	// TransactionSendInputInLineTypeUnknown when sending us an ABI definition for the inputs directly, the type string isn't known as an ethereum type
+	TransactionSendInputInLineTypeUnknown = "FFEC100176"
+	// TransactionSendMsgTypeUnknown we got a JSON message into the core processor (from Kafka, Webhooks etc.) that we don't understand
+	TransactionSendMsgTypeUnknown = "FFEC100177"
+	// TransactionSendInputTooManyParams more parameters provided than specified on ABI
+	TransactionSendInputTooManyParams = "FFEC100178"
+	// TransactionSendInputNotAssignable if we end up in a situation where the generated type cannot be assigned
+	TransactionSendInputNotAssignable = "FFEC100180"
+	// TransactionSendReceiptCheckError we continually had bad RCs back from the node while trying to check for the receipt up to the timeout
+	TransactionSendReceiptCheckError = "FFEC100181"
+	// TransactionSendReceiptCheckTimeout we didn't have a problem asking the node for a receipt, but the transaction wasn't mined at the end of the timeout
+	TransactionSendReceiptCheckTimeout = "FFEC100182"
+	// TransactionCallInvalidBlockNumber on "eth_call" the optional parameter for the target blocknumber failed to parse to a big integer
+	TransactionCallInvalidBlockNumber = "FFEC100183"
+	// UnpackOutputsFailed RLP decoding of outputs, logs, or events failed
+	UnpackOutputsFailed = "FFEC100184"
+	// UnpackOutputsMismatch RLP decoding of output gave an unexpected type according to the ABI
+	UnpackOutputsMismatch = "FFEC100185"
+	// UnpackOutputsMismatchCount wrong number of arguments
+	UnpackOutputsMismatchCount = "FFEC100186"
+	// UnpackOutputsMismatchNil RLP decoding of output gave a non-nil type, and we expected nil
+	UnpackOutputsMismatchNil = "FFEC100187"
+	// UnpackOutputsMismatchType expected to find a number according to supplied ABI, but got something else
+	UnpackOutputsMismatchType = "FFEC100188"
+	// UnpackOutputsUnknownType did not know how to handle this type - enhancement required
+	UnpackOutputsUnknownType = "FFEC100189"
+	// UnpackOutputsMismatchTupleType we got a type back from the unpacking that doesn't match the ABI
+	UnpackOutputsMismatchTupleType = "FFEC100190"
+	// UnpackOutputsMismatchTupleFieldCount we had a mismatch in the number of fields described on the ABI and the number on the go structure
+	UnpackOutputsMismatchTupleFieldCount = "FFEC100191"
+	// Unauthorized (401 error)
+	Unauthorized = "FFEC100192"
+	// WebhooksInvalidMsgHeaders missing headers section in the JSON/YAML posted
+	WebhooksInvalidMsgHeaders = "FFEC100193"
+	// WebhooksInvalidMsgTypeMissing need to specify a msg type in the header
+	WebhooksInvalidMsgTypeMissing = "FFEC100194"
+	// WebhooksInvalidMsgFromMissing need to specify a msg type in the header
+	WebhooksInvalidMsgFromMissing = "FFEC100195"
+	// WebhooksInvalidMsgType need to specify a valid msg type in the header
+	WebhooksInvalidMsgType = "FFEC100196"
+	// WebhooksKafkaUnexpectedErrFmt problem processing an error that came back from Kafka, so do a deep dump
+	WebhooksKafkaUnexpectedErrFmt = "FFEC100197"
+	// WebhooksKafkaDeliveryReportNoMeta delivery reports should contain the metadata we set when we sent
+	WebhooksKafkaDeliveryReportNoMeta = "FFEC100198"
+	// WebhooksKafkaYAMLtoJSON re-serialization of webhook message into JSON failed
+	WebhooksKafkaYAMLtoJSON = "FFEC100199"
+	// WebhooksKafkaErr wrapper on detailed error from Kafka itself
+	WebhooksKafkaErr = "FFEC100200"
+	// WebhooksDirectTooManyInflight when we're not using a buffered store (Kafka) we have to reject
+	WebhooksDirectTooManyInflight = "FFEC100201"
+	// WebhooksDirectBadHeaders problem processing for in-memory operation
+	WebhooksDirectBadHeaders = "FFEC100202"
+	// LevelDBFailedRetriveOriginalKey problem retrieving entry - original key
+	LevelDBFailedRetriveOriginalKey = "FFEC100203"
+	// LevelDBFailedRetriveGeneratedID problem retrieving entry - generated ID
+	LevelDBFailedRetriveGeneratedID = "FFEC100204"
+	// WebSocketClosed websocket was closed
+	WebSocketClosed = "FFEC100205"
+	// CircuitBreakerTripped is returned when the Kafka circuit breaker has deemed it unsafe to produce more messages
+	CircuitBreakerTripped = "FFEC100206"
+	// EventSupportNotConfigured is returned when event support is not configured
+	EventSupportNotConfigured = "FFEC100207"
+	// FFCBadVersion is returned when an FFCAPI request has a bad version header
+	FFCBadVersion = "FFEC100208"
+	// FFCUnsupportedVersion is returned when there is a bad version supplied on an FFCAPI request
+	FFCUnsupportedVersion = "FFEC100209"
+	// FFCUnsupportedRequestType is returned when the request type is unsupported for an FFCAPI request
+	FFCUnsupportedRequestType = "FFEC100210"
+	// FFCMissingRequestID is returned when the request ID is missing
+	FFCMissingRequestID = "FFEC100211"
+	// FFCUnmarshalABIFail is returned when failing to unmarshal a parameter to a generic go struct
+	FFCUnmarshalABIFail = "FFEC100212"
+	// FFCUnmarshalParamFail is returned when failing to unmarshal a parameter to a generic go struct
+	FFCUnmarshalParamFail = "FFEC100213"
+	// FFCInvalidGasPrice is returned when the gas price cannot be parsed as a number (support for London fork not yet in place)
+	FFCInvalidGasPrice = "FFEC100214"
+	// FFCInvalidTXData is returned when the transaction input data cannot be parsed as hex
+	FFCInvalidTXData = "FFEC100215"
+	// FFCReceiptNotAvailable is returned when a receipt is not found
+	FFCReceiptNotAvailable = "FFEC100216"
+	// FFCRequestTypeNotImplemented is returned when an operation is not supported
+	FFCRequestTypeNotImplemented = "FFEC100217"
+	// FFCBlockNotAvailable is returned when a receipt is not found
+	FFCBlockNotAvailable = "FFEC100218"
+	// ReceiptStoreKeyNotUnique non-unique request ID
+	ReceiptStoreKeyNotUnique = "FFEC100219"
+	// ReceiptErrorIdempotencyCheck failed to query receipt during idempotency check
+	ReceiptErrorIdempotencyCheck = "FFEC100220"
+	// ResubmissionPreventedCheckTransactionHash redelivery was prevented by the processor
+	ResubmissionPreventedCheckTransactionHash = "FFEC100221"
+	// KVStoreDBMarshal failed to unmarshal to object
+	KVStoreDBMarshal = "FFEC100222"
+	// KVStoreDBUnmarshal failed to unmarshal to object
+	KVStoreDBUnmarshal = "FFEC100223"
+	// RESTGatewayMissingStoragePath storage path must be set
+	RESTGatewayMissingStoragePath = "FFEC100224"
+	// CompilerFailedVersion failed to get version
+	CompilerFailedVersion = "FFEC100225"
+	// CompilerFailedVersionRegex failed to extract version from output
+	CompilerFailedVersionRegex = "FFEC100226"
+	// EventStreamsExportBadFormat unknown output format requested for an export
+	EventStreamsExportBadFormat = "FFEC100227"
+	// EventStreamsExportBadBlockRange block range for an export could not be parsed, or is reversed
+	EventStreamsExportBadBlockRange = "FFEC100228"
+	// EventStreamsExportWriteFailed failed writing to the output of an export
+	EventStreamsExportWriteFailed = "FFEC100229"
+	// AccessLogFileOpen failed to open the file configured for the HTTP access log
+	AccessLogFileOpen = "FFEC100230"
+	// AccessLogHijackUnsupported the underlying response writer does not support connection hijacking
+	AccessLogHijackUnsupported = "FFEC100231"
+	// WebSocketTooManyConnections the maximum number of concurrent WebSocket connections has been reached
+	WebSocketTooManyConnections = "FFEC100232"
+	// WebSocketRateLimitExceeded a client sent messages faster than the configured rate limit
+	WebSocketRateLimitExceeded = "FFEC100233"
+	// ConfigUnknownChainProfile the chain profile for receipt extensions is not recognized
+	ConfigUnknownChainProfile = "FFEC100234"
+	// RESTGatewayLegacyRoutesDisabled the unversioned routes have been disabled in config
+	RESTGatewayLegacyRoutesDisabled = "FFEC100235"
+	// GenAPIInvalidABI the input file for OpenAPI generation did not contain a valid ABI
+	GenAPIInvalidABI = "FFEC100236"
+	// GenAPIInvalidBaseURL the base URL for OpenAPI generation could not be parsed
+	GenAPIInvalidBaseURL = "FFEC100237"
+	// GenAPIWriteFailed failed to write the generated OpenAPI definition
+	GenAPIWriteFailed = "FFEC100238"
+	// ReceiptStoreElasticsearchSetup failed to configure the Elasticsearch indices
+	ReceiptStoreElasticsearchSetup = "FFEC100239"
+	// ReceiptStoreElasticsearchRequest a request to Elasticsearch failed to complete
+	ReceiptStoreElasticsearchRequest = "FFEC100240"
+	// ReceiptStoreElasticsearchStatus Elasticsearch returned a failure status
+	ReceiptStoreElasticsearchStatus = "FFEC100241"
+	// ReceiptStoreElasticsearchResponse the response from Elasticsearch could not be parsed
+	ReceiptStoreElasticsearchResponse = "FFEC100242"
+	// ReceiptStoreElasticsearchSerialize the receipt could not be serialized for indexing
+	ReceiptStoreElasticsearchSerialize = "FFEC100243"
+	// ReceiptStoreElasticsearchBulkMismatch the bulk response did not contain a result for every receipt
+	ReceiptStoreElasticsearchBulkMismatch = "FFEC100244"
+	// ReceiptStoreElasticsearchClosed the receipt store has been shut down
+	ReceiptStoreElasticsearchClosed = "FFEC100245"
+	// ReceiptStoreSearchNotSupported full-text/contract search requires a store with rich query support
+	ReceiptStoreSearchNotSupported = "FFEC100246"
+	// EventStreamsSubscribeBadTime the starting time for a subscription request is invalid
+	EventStreamsSubscribeBadTime = "FFEC100247"
+	// EventStreamsSubscribeBlockAndTime both a starting block and time were supplied
+	EventStreamsSubscribeBlockAndTime = "FFEC100248"
+	// ReceiptStoreSQLiteDriverMissing the binary was built without a SQLite driver
+	ReceiptStoreSQLiteDriverMissing = "FFEC100249"
+	// ReceiptStoreSQLiteOpen failed to open or initialize the SQLite database
+	ReceiptStoreSQLiteOpen = "FFEC100250"
+	// ReceiptExporterUnknownType the exporter type is not one we support
+	ReceiptExporterUnknownType = "FFEC100251"
+	// ReceiptExporterMissingConfig a required setting for the exporter is missing
+	ReceiptExporterMissingConfig = "FFEC100252"
+	// ReceiptExporterHTTPStatus the HTTP endpoint rejected the batch
+	ReceiptExporterHTTPStatus = "FFEC100253"
+	// ReceiptExporterWriteFile failed to write a batch file
+	ReceiptExporterWriteFile = "FFEC100254"
+	// SecurityModuleNoApprovalSupport the security module does not implement the approval extension
+	SecurityModuleNoApprovalSupport = "FFEC100255"
+	// ApprovalsConfigPathMissing the approval store path is required when approvals are enabled
+	ApprovalsConfigPathMissing = "FFEC100256"
+	// ApprovalsBadThreshold the value threshold could not be parsed
+	ApprovalsBadThreshold = "FFEC100257"
+	// ApprovalsNotFound no submission is parked with the ID
+	ApprovalsNotFound = "FFEC100258"
+	// ApprovalsNotPending the submission has already been approved or rejected
+	ApprovalsNotPending = "FFEC100259"
+	// ApprovalsSameApprover the submitter attempted to approve their own submission
+	ApprovalsSameApprover = "FFEC100260"
+	// ApprovalsStoreFailed failed to read or write the approval store
+	ApprovalsStoreFailed = "FFEC100261"
+	// ApprovalsRejected reply stored for a submission that was rejected
+	ApprovalsRejected = "FFEC100262"
+	// EventStreamsScheduleInvalid the cron schedule could not be parsed
+	EventStreamsScheduleInvalid = "FFEC100263"
+	// EventStreamsScheduledQueryNotFound the scheduled query does not exist
+	EventStreamsScheduledQueryNotFound = "FFEC100264"
+	// EventStreamsScheduledQueryMissingFields the method or address is missing
+	EventStreamsScheduledQueryMissingFields = "FFEC100265"
+	// EventStreamsScheduledQueryStoreFailed failed to store a scheduled query
+	EventStreamsScheduledQueryStoreFailed = "FFEC100266"
+	// RESTGatewayScheduledQueryInvalid attempt to create a scheduled query with invalid parameters
+	RESTGatewayScheduledQueryInvalid = "FFEC100267"
+	// WebSocketRepliesBadSince the since resume point for a reply stream could not be parsed
+	WebSocketRepliesBadSince = "FFEC100268"
+	// WebSocketRepliesNoHistory there is no receipt store to resume a reply stream from
+	WebSocketRepliesNoHistory = "FFEC100269"
+	// WebSocketRepliesHistoryFailed failed to query the receipt store to resume a reply stream
+	WebSocketRepliesHistoryFailed = "FFEC100270"
+	// RPCCallTimeout an RPC call did not complete within the timeout for its class
+	RPCCallTimeout = "FFEC100271"
+	// RPCTimeoutClassUnknown an RPC method was configured with a timeout class that does not exist
+	RPCTimeoutClassUnknown = "FFEC100272"
+	// RESTGatewayEventSchemaFormat requested a schema for an event in a format we do not generate
+	RESTGatewayEventSchemaFormat = "FFEC100273"
+	// ConfigMTLSCertKey mutual TLS was enabled on the listener without a server certificate and key
+	ConfigMTLSCertKey = "FFEC100274"
+	// ConfigMTLSLoadFailed failed to load the certificates for mutual TLS on the listener
+	ConfigMTLSLoadFailed = "FFEC100275"
+	// ConfigMTLSClientCAs mutual TLS was enabled on the listener without CAs to verify clients against
+	ConfigMTLSClientCAs = "FFEC100276"
+	// RESTGatewayClientCertNotAllowed the client certificate was valid, but the subject is not in the allow-list
+	RESTGatewayClientCertNotAllowed = "FFEC100277"
+	// HTTPRequesterTLSConfig common HTTP request utility for extensions, the TLS configuration could not be loaded
+	HTTPRequesterTLSConfig = "FFEC100278"
+	// EventStreamsPubSubNoTopic attempt to create a Pub/Sub event stream without a topic
+	EventStreamsPubSubNoTopic = "FFEC100279"
+	// EventStreamsPubSubNoProject the project of the Pub/Sub topic could not be determined
+	EventStreamsPubSubNoProject = "FFEC100280"
+	// EventStreamsPubSubInvalidOrderingKey unknown ordering key selection for a Pub/Sub event stream
+	EventStreamsPubSubInvalidOrderingKey = "FFEC100281"
+	// EventStreamsPubSubFailedHTTPStatus Pub/Sub rejected a publish request
+	EventStreamsPubSubFailedHTTPStatus = "FFEC100282"
+	// GCPCredentialsInvalid the Google credentials file could not be loaded
+	GCPCredentialsInvalid = "FFEC100283"
+	// GCPCredentialsNoPrivateKey the service account credentials do not contain an RSA private key
+	GCPCredentialsNoPrivateKey = "FFEC100284"
+	// GCPTokenFailed failed to obtain an access token for GCP
+	GCPTokenFailed = "FFEC100285"
+	// S3MissingBucket no bucket configured for S3
+	S3MissingBucket = "FFEC100286"
+	// S3MissingCredentials no credentials configured or in the environment for S3
+	S3MissingCredentials = "FFEC100287"
+	// S3RequestFailed non-success status from S3
+	S3RequestFailed = "FFEC100288"
+	// ReceiptArchiveNotSupported the receipt store cannot list receipts oldest first
+	ReceiptArchiveNotSupported = "FFEC100289"
+	// ReceiptArchiveNotEnabled request for an archived batch when archiving is disabled
+	ReceiptArchiveNotEnabled = "FFEC100290"
+	// ReceiptArchiveInvalidBatch the batch ID is not in the format we write
+	ReceiptArchiveInvalidBatch = "FFEC100291"
+	// ReceiptArchiveBatchNotFound the batch does not exist in the archive
+	ReceiptArchiveBatchNotFound = "FFEC100292"
+	// ReceiptArchiveReadFailed failed to read a batch from the archive
+	ReceiptArchiveReadFailed = "FFEC100293"
+	// IdempotencyConfigPathMissing the reservation store path is required when idempotency keys are enabled
+	IdempotencyConfigPathMissing = "FFEC100294"
+	// IdempotencyStoreFailed the reservation store could not be read or written
+	IdempotencyStoreFailed = "FFEC100295"
+	// IdempotencyKeyInUse the client supplied ID has already been used, or is reserved
+	IdempotencyKeyInUse = "FFEC100296"
+	// IdempotencyReservationNotFound no unexpired reservation exists for the ID
+	IdempotencyReservationNotFound = "FFEC100297"
+	// IdempotencyInvalidID the client supplied ID contains characters that are not allowed
+	IdempotencyInvalidID = "FFEC100298"
+	// RawTxnInvalid the raw transaction could not be decoded
+	RawTxnInvalid = "FFEC100299"
+	// RawTxnNotReplayProtected the raw transaction was signed without a chain ID, so could be replayed on any chain
+	RawTxnNotReplayProtected = "FFEC100300"
+	// RawTxnChainIDMismatch the raw transaction was signed for a different chain
+	RawTxnChainIDMismatch = "FFEC100301"
+	// RawTxnInvalidSignature the signer could not be recovered from the raw transaction
+	RawTxnInvalidSignature = "FFEC100302"
+	// RawTxnSenderMismatch the raw transaction was signed by a different address to the one supplied
+	RawTxnSenderMismatch = "FFEC100303"
+	// RawTxnNonceUsed the nonce of the raw transaction has already been used, so it is a replay
+	RawTxnNonceUsed = "FFEC100304"
+	// SecurityModuleNoReceiptAdminSupport the security module does not implement the receipt admin extension
+	SecurityModuleNoReceiptAdminSupport = "FFEC100305"
+	// ReceiptStoreFailedDelete the persistence layer failed to delete replies
+	ReceiptStoreFailedDelete = "FFEC100306"
+	// ReceiptStorePurgeInvalidRequest a purge must be restricted by age or by ID
+	ReceiptStorePurgeInvalidRequest = "FFEC100307"
+	// TransactionCancelNonceRequired a cancel transaction must target a specific nonce
+	TransactionCancelNonceRequired = "FFEC100308"
+	// TransactionSendCancelled the request was withdrawn while waiting to be sent
+	TransactionSendCancelled = "FFEC100309"
+	// TransactionCancelNotQueued no queued request with the ID
+	TransactionCancelNotQueued = "FFEC100310"
+	// TransactionCancelAlreadySubmitted the request has already been passed to the node
+	TransactionCancelAlreadySubmitted = "FFEC100311"
+	// WebhooksCancelNotSupported requests dispatched over Kafka are not held in this process
+	WebhooksCancelNotSupported = "FFEC100312"
+	// ConfigUnknownKeys the config file contains keys that do not map to a setting
+	ConfigUnknownKeys = "FFEC100313"
+	// ConfigUnsupportedVersion the config file is for a newer (or invalid) schema version
+	ConfigUnsupportedVersion = "FFEC100314"
+	// ConfigEnvOverlayUnknownField an overlay environment variable does not map to a setting
+	ConfigEnvOverlayUnknownField = "FFEC100315"
+	// ConfigEnvOverlayBadValue an overlay environment variable cannot be converted to the type of the setting
+	ConfigEnvOverlayBadValue = "FFEC100316"
+	// ReceiptStoreSummaryNotSupported the persistence layer cannot count receipts by type
+	ReceiptStoreSummaryNotSupported = "FFEC100317"
+	// ReceiptStoreInvalidSummaryWindow a summary window is not a duration
+	ReceiptStoreInvalidSummaryWindow = "FFEC100318"
+	// ReceiptStoreFailedSummary the persistence layer failed to count receipts
+	ReceiptStoreFailedSummary = "FFEC100319"
+	// RESTGatewaySimulateDeployUnsupported simulation was requested for a contract deployment
+	RESTGatewaySimulateDeployUnsupported = "FFEC100320"
+	// ReceiptStoreNamespaceNotSupported the persistence layer cannot filter receipts by namespace
+	ReceiptStoreNamespaceNotSupported = "FFEC100321"
+	// ReceiptStoreNamespaceRestricted the operation spans all namespaces
+	ReceiptStoreNamespaceRestricted = "FFEC100322"
+	// WebSocketSendBufferFull a slow client did not keep up with the messages queued for it
+	WebSocketSendBufferFull = "FFEC100323"
+	// ReceiptStoreSSEUnsupported the HTTP connection cannot stream Server-Sent Events
+	ReceiptStoreSSEUnsupported = "FFEC100324"
+	// ReceiptStoreEncryptionKey the key encryption key for receipts could not be loaded
+	ReceiptStoreEncryptionKey = "FFEC100325"
+	// ReceiptStoreEncryptFailed a receipt could not be encrypted before it was written
+	ReceiptStoreEncryptFailed = "FFEC100326"
+	// ReceiptStoreDecryptFailed a stored receipt could not be decrypted
+	ReceiptStoreDecryptFailed = "FFEC100327"
+	// ReceiptStoreEncryptionUnsupported the store beneath the encryption layer does not support an optional operation
+	ReceiptStoreEncryptionUnsupported = "FFEC100328"
+	// ReceiptStoreVaultRequest a request to the Vault transit engine failed
+	ReceiptStoreVaultRequest = "FFEC100329"
+	// ReceiptStoreInvalidSummaryGroup the groupBy parameter of a summary query is not a field that can be grouped
+	ReceiptStoreInvalidSummaryGroup = "FFEC100330"
+	// ReceiptStoreGroupSummaryNotSupported the persistence layer cannot group receipts in a summary
+	ReceiptStoreGroupSummaryNotSupported = "FFEC100331"
+	// RESTGatewayUnknownFields the body of a method invocation contained fields that are not inputs of the method
+	RESTGatewayUnknownFields = "FFEC100332"
+	// RESTGatewayLocalStoreABIInUse an ABI cannot be deleted while a contract instance is registered with it
+	RESTGatewayLocalStoreABIInUse = "FFEC100333"
+	// LintUnsupportedType the request type cannot be checked by the lint endpoint
+	LintUnsupportedType = "FFEC100334"
+	// LintMissingField a field required to submit the request is not set
+	LintMissingField = "FFEC100335"
+	// LintMethodNotFound the method of a transaction is not declared in the ABI registered for the contract
+	LintMethodNotFound = "FFEC100336"
+	// LintMethodNotResolved there is no ABI for the method of a transaction, so parameters cannot be checked
+	LintMethodNotResolved = "FFEC100337"
+	// LintGasLow the gas supplied is less than the minimum cost of any transaction
+	LintGasLow = "FFEC100338"
+	// LintGasHigh the gas supplied is more than most networks allow in a block
+	LintGasHigh = "FFEC100339"
+	// LintValueNotPayable value is sent to a method or constructor that is not payable, so the transaction will revert
+	LintValueNotPayable = "FFEC100340"
+	// LintConstantMethod a view or pure method is sent as a transaction
+	LintConstantMethod = "FFEC100341"
+	// LintSolidityNotCompiled a deployment supplies Solidity source, which is not compiled by the lint endpoint
+	LintSolidityNotCompiled = "FFEC100342"
+	// S3PreconditionFailed a conditional write to S3 failed, as another writer changed the object
+	S3PreconditionFailed = "FFEC100343"
+	// RESTGatewayContractUpdateInvalid the body of a request to update a contract instance registration is invalid
+	RESTGatewayContractUpdateInvalid = "FFEC100344"
+	// RESTGatewayInvalidListingParam a paging or filtering parameter of a contract or ABI listing is invalid
+	RESTGatewayInvalidListingParam = "FFEC100345"
+	// EventStreamsSubscribeBadGetLogsConf the eth_getLogs retry configuration of a subscription is invalid
+	EventStreamsSubscribeBadGetLogsConf = "FFEC100346"
+	// RemoteRegistryPublishFailed error publishing a local ABI as a gateway in the remote contract registry
+	RemoteRegistryPublishFailed = "FFEC100347"
+	// RESTGatewayPublishABIInvalid the body of a request to publish an ABI to the remote registry is invalid
+	RESTGatewayPublishABIInvalid = "FFEC100348"
+	// RESTGatewayInvalidSignature a method or event to search for is neither a valid signature nor a selector
+	RESTGatewayInvalidSignature = "FFEC100349"
+	// RESTGatewayImportABIInvalid the body of a request to import a verified ABI is invalid
+	RESTGatewayImportABIInvalid = "FFEC100350"
+	// ABIImportNoSource no service is configured to import verified contracts from, for the chain
+	ABIImportNoSource = "FFEC100351"
+	// ABIImportNotVerified the contract is not verified on any of the configured services
+	ABIImportNotVerified = "FFEC100352"
+	// ABIImportFailed the metadata of a verified contract could not be read
+	ABIImportFailed = "FFEC100353"
+	// WebSocketInvalidRequest a message from a client using the JSON-RPC WebSocket protocol is not a valid request
+	WebSocketInvalidRequest = "FFEC100354"
+	// WebSocketUnknownMethod a client using the JSON-RPC WebSocket protocol called a method that does not exist
+	WebSocketUnknownMethod = "FFEC100355"
+	// RESTGatewayEventQueryInvalid the parameters of an ad hoc event query are invalid
+	RESTGatewayEventQueryInvalid = "FFEC100356"
+	// RESTGatewayEventQueryRangeTooLarge the block range of an ad hoc event query is too large
+	RESTGatewayEventQueryRangeTooLarge = "FFEC100357"
+	// RESTGatewayEventQueryUnknownEvent the event of an ad hoc event query is not in the ABI of the contract
+	RESTGatewayEventQueryUnknownEvent = "FFEC100358"
+	// CompilerSerializeUserDocs could not serialize the user docs output from solc
+	CompilerSerializeUserDocs = "FFEC100359"
+	// TransactionRelayNotConfigured a meta-transaction was submitted without a forwarder configured
+	TransactionRelayNotConfigured = "FFEC100360"
+	// TransactionRelayInvalid the forward request of a meta-transaction is incomplete
+	TransactionRelayInvalid = "FFEC100361"
+	// TransactionRelayVerifyFailed the forwarder rejected the signature or nonce of a meta-transaction
+	TransactionRelayVerifyFailed = "FFEC100362"
+	// RESTGatewayABIVersionInvalid the name and version to register an ABI under could not be parsed
+	RESTGatewayABIVersionInvalid = "FFEC100363"
+	// RESTGatewayABIVersionClash the version of a named ABI is already registered
+	RESTGatewayABIVersionClash = "FFEC100364"
+	// RegistryNotifierUnknownType the registry notifier type is not one we support
+	RegistryNotifierUnknownType = "FFEC100365"
+	// RegistryNotifierMissingConfig a required setting for the registry notifier is missing
+	RegistryNotifierMissingConfig = "FFEC100366"
+	// RegistryNotifierHTTPStatus the webhook rejected a registry notification
+	RegistryNotifierHTTPStatus = "FFEC100367"
+	// RESTGatewayReadStoragePathFailed the storage path could not be scanned for ABI and contract files
+	RESTGatewayReadStoragePathFailed = "FFEC100368"
+	// RegistryReplicationMissingConfig a required setting for registry replication is missing
+	RegistryReplicationMissingConfig = "FFEC100369"
+	// ReceiptStoreRevisionConflict a receipt was changed by another writer since it was read
+	ReceiptStoreRevisionConflict = "FFEC100370"
+	// RESTGatewayBulkABINoArtifacts none of the files uploaded in bulk were contract artifacts
+	RESTGatewayBulkABINoArtifacts = "FFEC100371"
+	// RESTGatewayBulkABIInvalidArtifact a contract artifact uploaded in bulk could not be parsed
+	RESTGatewayBulkABIInvalidArtifact = "FFEC100372"
+	// LoadTestInvalidConfig the options for the load test are incomplete or inconsistent
+	LoadTestInvalidConfig = "FFEC100373"
+	// LoadTestGatewayNotReady the gateway did not respond before the load test started
+	LoadTestGatewayNotReady = "FFEC100374"
+	// LoadTestSetupFailed the event stream or subscription for an events load test could not be created
+	LoadTestSetupFailed = "FFEC100375"
+	// KafkaRetryBufferFull the buffer of messages waiting for Kafka to reconnect is full, and the policy is to park new messages
+	KafkaRetryBufferFull = "FFEC100376"
+	// ConfigKafkaRetryBufferPolicy the policy for a full Kafka retry buffer is not one we support
+	ConfigKafkaRetryBufferPolicy = "FFEC100377"
+	// RESTGatewayArtifactNotSelected more than one contract artifact was uploaded, and none was selected
+	RESTGatewayArtifactNotSelected = "FFEC100378"
+	// RESTGatewayArtifactNotFound the selected contract was not among the uploaded artifacts
+	RESTGatewayArtifactNotFound = "FFEC100379"
+	// ReplyModeInvalid the reply mode on a request is not recognized
+	ReplyModeInvalid = "FFEC100380"
+	// ReplyModeWebhookRequired a reply mode that delivers to a webhook was requested without the URL
+	ReplyModeWebhookRequired = "FFEC100381"
+	// ReplyWebhookUnsafeAddress the reply webhook resolves to a private address, which is not allowed
+	ReplyWebhookUnsafeAddress = "FFEC100382"
+	// ReplyWebhookHTTPStatus the reply webhook returned a non-OK response
+	ReplyWebhookHTTPStatus = "FFEC100383"
+	// RegistryNotifierNoWebSocketServer a WebSocket registry notifier is configured where there is no WebSocket server
+	RegistryNotifierNoWebSocketServer = "FFEC100384"
+	// MigrationStateInvalid the migration state file of a data directory could not be read
+	MigrationStateInvalid = "FFEC100385"
+	// MigrationBackupFailed the data directory could not be backed up before migrating
+	MigrationBackupFailed = "FFEC100386"
+	// MigrationFailed a migration failed, and the data was restored from the backup
+	MigrationFailed = "FFEC100387"
+	// MigrationNoBackup a rollback was requested with no backup to restore
+	MigrationNoBackup = "FFEC100388"
+	// MigrationRestoreFailed a backup could not be restored
+	MigrationRestoreFailed = "FFEC100389"
+	// MigrationVersionAhead the data was migrated by a newer version of ethconnect
+	MigrationVersionAhead = "FFEC100390"
+	// RESTGatewayInvalidLabel a label of a contract or ABI is not a valid key=value pair
+	RESTGatewayInvalidLabel = "FFEC100391"
+	// WebSocketConnectionNotFound the WebSocket connection requested for its statistics is not connected
+	WebSocketConnectionNotFound = "FFEC100392"
+	// TransactionRequestAbandoned the caller went away, or the processor shut down, before the request completed
+	TransactionRequestAbandoned = "FFEC100393"
+	// ContractStoreArchiveInvalid the archive supplied to import into the contract store cannot be used
+	ContractStoreArchiveInvalid = "FFEC100394"
+	// ContractStoreImportConflict entries in the archive clash with those already in the contract store
+	ContractStoreImportConflict = "FFEC100395"
+	// ContractStoreImportMissingABI a contract instance in the archive uses an ABI that is not available
+	ContractStoreImportMissingABI = "FFEC100396"
+	// RESTGatewayBulkSubscribeInvalid the request to subscribe to the events of an ABI could not be parsed
+	RESTGatewayBulkSubscribeInvalid = "FFEC100397"
+	// RESTGatewayBulkSubscribeFailed one of the subscriptions for the events of an ABI failed, and the others were rolled back
+	RESTGatewayBulkSubscribeFailed = "FFEC100398"
+	// DeployTransactionMissingLibrary the bytecode refers to a library, and no address was supplied to link it to
+	DeployTransactionMissingLibrary = "FFEC100399"
+	// DeployTransactionInvalidLibraryAddress the address supplied to link a library to is not an address
+	DeployTransactionInvalidLibraryAddress = "FFEC100400"
+	// DeployTransactionInvalidLinkReference a link reference stored with the bytecode does not fit within it
+	DeployTransactionInvalidLinkReference = "FFEC100401"
+	// RESTGatewayInvalidLibrary a library to link a deployment to is not of the form name=address
+	RESTGatewayInvalidLibrary = "FFEC100402"
+	// ABIValidateInvalidBody the body to validate is not an ABI, build artifact or compiler output
+	ABIValidateInvalidBody = "FFEC100403"
+	// ABIValidateUnknownType an element of the ABI has a type that is not in the ABI specification
+	ABIValidateUnknownType = "FFEC100404"
+	// ABIValidateMissingName a function, event or error in the ABI has no name
+	ABIValidateMissingName = "FFEC100405"
+	// ABIValidateInvalidElement the inputs or outputs of an element of the ABI cannot be parsed
+	ABIValidateInvalidElement = "FFEC100406"
+	// ABIValidateDuplicate an element of the ABI is declared more than once with the same signature
+	ABIValidateDuplicate = "FFEC100407"
+	// ABIValidateSelectorClash two functions of the ABI have different signatures with the same selector
+	ABIValidateSelectorClash = "FFEC100408"
+	// ABIValidateTooManyIndexed an event of the ABI has more indexed inputs than there are topics for
+	ABIValidateTooManyIndexed = "FFEC100409"
+	// ABIValidateTypeAlias an ABI argument has a Solidity type alias, which is not valid in an ABI
+	ABIValidateTypeAlias = "FFEC100410"
+	// ContractStoreUnknownContentHash the configured content hash algorithm is not registered
+	ContractStoreUnknownContentHash = "FFEC100411"
+	// EventStreamsAMQPNoAddress attempt to create an AMQP event stream without a broker URL or target address
+	EventStreamsAMQPNoAddress = "FFEC100412"
+	// AMQPInvalidURL the broker URL is not an amqp or amqps URL
+	AMQPInvalidURL = "FFEC100413"
+	// AMQPConnectFailed failed to establish a connection to the broker
+	AMQPConnectFailed = "FFEC100414"
+	// AMQPProtocolError the broker sent a frame that could not be handled
+	AMQPProtocolError = "FFEC100415"
+	// AMQPAuthFailed the broker did not accept the SASL credentials
+	AMQPAuthFailed = "FFEC100416"
+	// AMQPRemoteClosed the broker closed the connection, session or link
+	AMQPRemoteClosed = "FFEC100417"
+	// AMQPConnectionClosed the connection was closed locally, or lost
+	AMQPConnectionClosed = "FFEC100418"
+	// AMQPDeliveryRejected the broker rejected a message
+	AMQPDeliveryRejected = "FFEC100419"
+	// AMQPDeliveryReleased the broker released or modified a message without accepting it
+	AMQPDeliveryReleased = "FFEC100420"
+	// AMQPTimeout timed out waiting for the broker
+	AMQPTimeout = "FFEC100421"
+	// EventStreamsActionPanic the action of an event stream panicked while delivering a batch
+	EventStreamsActionPanic = "FFEC100422"
+	// EventStreamsNATSNoSubject attempt to create a NATS event stream without a server URL or subject
+	EventStreamsNATSNoSubject = "FFEC100423"
+	// NATSInvalidURL the server URL is not a nats or tls URL
+	NATSInvalidURL = "FFEC100424"
+	// NATSConnectFailed failed to establish a connection to the server
+	NATSConnectFailed = "FFEC100425"
+	// NATSProtocolError the server sent something that could not be handled
+	NATSProtocolError = "FFEC100426"
+	// NATSServerError the server reported an error, such as an authorization failure
+	NATSServerError = "FFEC100427"
+	// NATSConnectionClosed the connection was closed locally, or lost
+	NATSConnectionClosed = "FFEC100428"
+	// NATSPublishFailed JetStream returned an error instead of acknowledging a message
+	NATSPublishFailed = "FFEC100429"
+	// NATSNoResponders no JetStream stream is bound to the subject
+	NATSNoResponders = "FFEC100430"
+	// NATSTimeout timed out waiting for the server
+	NATSTimeout = "FFEC100431"
+	// NATSPayloadTooLarge a message is larger than the server accepts
+	NATSPayloadTooLarge = "FFEC100432"
+	// UnitsInvalidAmount an amount is neither an integer, nor a decimal number with a unit
+	UnitsInvalidAmount = "FFEC100433"
+	// UnitsUnknown an amount or output format uses a unit that is not known
+	UnitsUnknown = "FFEC100434"
+	// UnitsTooPrecise an amount has more decimal places than its unit can represent
+	UnitsTooPrecise = "FFEC100435"
+	// UnitsNoTokenDecimals the token unit is used for a contract that has no token decimals configured
+	UnitsNoTokenDecimals = "FFEC100436"
+	// EventStreamsSQSNoQueue attempt to create an SQS event stream without a queue URL
+	EventStreamsSQSNoQueue = "FFEC100437"
+	// EventStreamsSNSNoTopic attempt to create an SNS event stream without a topic ARN
+	EventStreamsSNSNoTopic = "FFEC100438"
+	// EventStreamsAWSNoRegion the region of the queue or topic could not be determined
+	EventStreamsAWSNoRegion = "FFEC100439"
+	// EventStreamsAWSFailedHTTPStatus SQS or SNS rejected a request
+	EventStreamsAWSFailedHTTPStatus = "FFEC100440"
+	// EventStreamsAWSEntriesFailed some of the messages in a batch were rejected
+	EventStreamsAWSEntriesFailed = "FFEC100441"
+	// EventStreamsAWSMessageTooLarge an event is too large to send as a single message
+	EventStreamsAWSMessageTooLarge = "FFEC100442"
+	// AWSCredentialsNotFound no credentials are available in the environment
+	AWSCredentialsNotFound = "FFEC100443"
+	// AWSAssumeRoleFailed the web identity token could not be exchanged for credentials
+	AWSAssumeRoleFailed = "FFEC100444"
+	// WebSocketSubmitNotEnabled a client submitted a transaction to a WebSocket server that cannot dispatch them
+	WebSocketSubmitNotEnabled = "FFEC100445"
+	// WebSocketSubmitInvalid a transaction submitted over a WebSocket is not a JSON object
+	WebSocketSubmitInvalid = "FFEC100446"
+	// EventStreamsKafkaNoTopic attempt to create a Kafka event stream without brokers or a topic
+	EventStreamsKafkaNoTopic = "FFEC100447"
+	// EventStreamsKafkaSendFailed some of the events in a batch were not accepted by the Kafka brokers
+	EventStreamsKafkaSendFailed = "FFEC100448"
+	// EventStreamsKafkaClosed a batch was sent after the Kafka action of the stream was closed
+	EventStreamsKafkaClosed = "FFEC100449"
+	// EventStreamsMQTTNoTopic attempt to create an MQTT event stream without a broker URL or topic
+	EventStreamsMQTTNoTopic = "FFEC100450"
+	// EventStreamsMQTTInvalidTopic the topic template of an MQTT event stream could not be parsed
+	EventStreamsMQTTInvalidTopic = "FFEC100451"
+	// EventStreamsMQTTInvalidQoS the QoS of an MQTT event stream is not 0, 1 or 2
+	EventStreamsMQTTInvalidQoS = "FFEC100452"
+	// MQTTInvalidURL the broker URL is not an mqtt or mqtts URL
+	MQTTInvalidURL = "FFEC100453"
+	// MQTTInvalidProtocolVersion the protocol version is not one that is supported
+	MQTTInvalidProtocolVersion = "FFEC100454"
+	// MQTTConnectFailed failed to establish a connection to the broker
+	MQTTConnectFailed = "FFEC100455"
+	// MQTTConnectRejected the broker refused the connection, such as for bad credentials
+	MQTTConnectRejected = "FFEC100456"
+	// MQTTProtocolError the broker sent something that could not be handled
+	MQTTProtocolError = "FFEC100457"
+	// MQTTConnectionClosed the connection was closed locally, or lost
+	MQTTConnectionClosed = "FFEC100458"
+	// MQTTDisconnected the broker closed the connection with a reason code
+	MQTTDisconnected = "FFEC100459"
+	// MQTTPublishFailed the broker returned an error reason code instead of accepting a message
+	MQTTPublishFailed = "FFEC100460"
+	// MQTTTimeout timed out waiting for the broker
+	MQTTTimeout = "FFEC100461"
+	// MQTTPacketTooLarge a message is larger than the broker accepts
+	MQTTPacketTooLarge = "FFEC100462"
+	// MQTTQoSNotSupported a message requested a higher QoS than the broker supports
+	MQTTQoSNotSupported = "FFEC100463"
+	// MQTTInvalidTopicName a topic to publish to is empty, or contains a wildcard
+	MQTTInvalidTopicName = "FFEC100464"
+	// ContractRegistryNameClash a name to register is already taken in the local or remote registry
+	ContractRegistryNameClash = "FFEC100465"
+	// ReplyProcessorUnknownType the reply processor type is not one we support
+	ReplyProcessorUnknownType = "FFEC100466"
+	// ReplyProcessorMissingConfig a required setting for the reply processor is missing
+	ReplyProcessorMissingConfig = "FFEC100467"
+	// ReplyProcessorDuplicate a built-in reply processor is configured more than once
+	ReplyProcessorDuplicate = "FFEC100468"
+	// ReplyProcessorHTTPStatus the HTTP endpoint rejected the reply
+	ReplyProcessorHTTPStatus = "FFEC100469"
+	// ReplyProcessorInvalidReceipt the reply could not be parsed as a transaction receipt
+	ReplyProcessorInvalidReceipt = "FFEC100470"
+	// EventStreamsGRPCNoURL attempt to create a gRPC event stream without a service URL
+	EventStreamsGRPCNoURL = "FFEC100471"
+	// EventStreamsGRPCBatchRejected the gRPC service acked a batch with an error
+	EventStreamsGRPCBatchRejected = "FFEC100472"
+	// GRPCInvalidURL the service URL is not a grpc or grpcs URL
+	GRPCInvalidURL = "FFEC100473"
+	// GRPCRequestFailed the Deliver call could not be made, or was refused at the HTTP level
+	GRPCRequestFailed = "FFEC100474"
+	// GRPCStatus the gRPC service ended the Deliver call with an error status
+	GRPCStatus = "FFEC100475"
+	// GRPCInvalidMessage the gRPC service sent a message that could not be read
+	GRPCInvalidMessage = "FFEC100476"
+	// GRPCStreamClosed the stream was closed locally, or ended by the service
+	GRPCStreamClosed = "FFEC100477"
+	// GRPCTimeout timed out waiting for the gRPC service
+	GRPCTimeout = "FFEC100478"
+	// EventStreamsExportJobsNotConfigured no output location is configured for export jobs
+	EventStreamsExportJobsNotConfigured = "FFEC100479"
+	// EventStreamsExportJobNotFound the export job does not exist
+	EventStreamsExportJobNotFound = "FFEC100480"
+	// RESTGatewayExportJobInvalid failed to parse the request to start an export job
+	RESTGatewayExportJobInvalid = "FFEC100481"
+	// EventStreamsPubSubPublishFailed Pub/Sub did not accept every message of a batch
+	EventStreamsPubSubPublishFailed = "FFEC100482"
+	// EventStreamsPubSubClosed the Pub/Sub client was closed, as the stream was stopped
+	EventStreamsPubSubClosed = "FFEC100483"
+	// WebhooksCancelNotSubmitter only the submitter, or an approver, can withdraw a request
+	WebhooksCancelNotSubmitter = "FFEC100484"
+	// ReceiptStoreRevisionsNotSupported the receipt store does not version receipts
+	ReceiptStoreRevisionsNotSupported = "FFEC100485"
+	// SecurityModuleNoAdminSupport the security module does not implement the admin extension
+	SecurityModuleNoAdminSupport = "FFEC100486"
+)
+
+// Catalogue is every error code ethconnect can return, in code order
+var Catalogue = []*Entry{
+	{Name: "AddressBookLookupBadURL", Code: AddressBookLookupBadURL, Message: "Invalid URL obtained for address", Description: "we got back a bad URL from the remote address book after our REST call"},
+	{Name: "AddressBookLookupBadHostsFile", Code: AddressBookLookupBadHostsFile, Message: "Configuration problem (hosts file)", Description: "we have a custom hosts file for DNS resolution, but it cannot be processed"},
+	{Name: "AddressBookLookupNotFound", Code: AddressBookLookupNotFound, Message: "Unknown address", Description: "remote addressbook says no"},
+	{Name: "ConfigFileReadFailed", Code: ConfigFileReadFailed, Message: "Failed to read %s: %s", Description: "failed to read the server config file"},
+	{Name: "CompilerVersionNotFound", Code: CompilerVersionNotFound, Message: "Could not find a configured compiler for requested Solidity major version %s.%s", Description: "the runtime context of ethconnect has not been configured with a compiler for the requested version"},
+	{Name: "CompilerVersionBadRequest", Code: CompilerVersionBadRequest, Message: "Invalid Solidity version requested for compiler. Ensure the string starts with two dot separated numbers, such as 0.5", Description: "the user requested a bad semver"},
+	{Name: "CompilerFailedSolc", Code: CompilerFailedSolc, Message: "Solidity compilation failed: solc: %v\n%s", Description: "compilation failure output from solc"},
+	{Name: "CompilerOutputMissingContract", Code: CompilerOutputMissingContract, Message: "Contract '%s' not found in Solidity source: %s", Description: "the output from the compiler does not include the requested contract"},
+	{Name: "CompilerOutputMultipleContracts", Code: CompilerOutputMultipleContracts, Message: "More than one contract in Solidity file, please set one to call: %s", Description: "need to select one"},
+	{Name: "CompilerBytecodeInvalid", Code: CompilerBytecodeInvalid, Message: "Decoding bytecode: %s", Description: "hex output from compiler could not be parsed"},
+	{Name: "CompilerBytecodeEmpty", Code: CompilerBytecodeEmpty, Message: "Specified contract compiled ok, but did not result in any bytecode: %s", Description: "null result from succcessful compile in solc"},
+	{Name: "CompilerABISerialize", Code: CompilerABISerialize, Message: "Serializing ABI: %s", Description: "could not serialize the ABI output from solc"},
+	{Name: "CompilerABIReRead", Code: CompilerABIReRead, Message: "Parsing ABI: %s", Description: "could not re-read serialized output after writing the ABI"},
+	{Name: "CompilerSerializeDevDocs", Code: CompilerSerializeDevDocs, Message: "Serializing DevDoc: %s", Description: "could not serialize the dev docs output from solc"},
+	{Name: "ConfigNoRPC", Code: ConfigNoRPC, Message: "No JSON/RPC URL set for ethereum node", Description: "missing config for JSON/RPC"},
+	{Name: "ConfigKafkaMissingOutputTopic", Code: ConfigKafkaMissingOutputTopic, Message: "No output topic specified for bridge to send events to", Description: "response topic missing"},
+	{Name: "ConfigKafkaMissingInputTopic", Code: ConfigKafkaMissingInputTopic, Message: "No input topic specified for bridge to listen to", Description: "request topic missing"},
+	{Name: "ConfigKafkaMissingConsumerGroup", Code: ConfigKafkaMissingConsumerGroup, Message: "No consumer group specified", Description: "consumer group missing"},
+	{Name: "ConfigKafkaMissingBadSASL", Code: ConfigKafkaMissingBadSASL, Message: "Username and Password must both be provided for SASL", Description: "problem with SASL config"},
+	{Name: "ConfigKafkaMissingBrokers", Code: ConfigKafkaMissingBrokers, Message: "No Kafka brokers configured", Description: "missing/empty brokers"},
+	{Name: "ConfigRESTGatewayRequiredReceiptStore", Code: ConfigRESTGatewayRequiredReceiptStore, Message: "MongoDB URL, Database and Collection name must be specified to enable the receipt store", Description: "need to enable params for REST Gatewya"},
+	{Name: "ConfigRESTGatewayRequiredRPC", Code: ConfigRESTGatewayRequiredRPC, Message: "RPC URL and Storage Path must be supplied to enable the Open API REST Gateway", Description: "and RPC stuff"},
+	{Name: "ConfigWebhooksDirectRPC", Code: ConfigWebhooksDirectRPC, Message: "No JSON/RPC URL set for ethereum node", Description: "for webhooks direct"},
+	{Name: "ConfigTLSCertOrKey", Code: ConfigTLSCertOrKey, Message: "Client private key and certificate must both be provided for mutual auth", Description: "incomplete TLS config"},
+	{Name: "ConfigNoYAML", Code: ConfigNoYAML, Message: "No YAML configuration filename specified", Description: "missing configuration file on server start"},
+	{Name: "ConfigYAMLParseFile", Code: ConfigYAMLParseFile, Message: "Unable to parse %s as YAML: %s", Description: "failed to parse YAML during server startup"},
+	{Name: "ConfigYAMLPostParseFile", Code: ConfigYAMLPostParseFile, Message: "Failed to process YAML config from %s: %s", Description: "failed to process YAML as JSON after parsing"},
+	{Name: "DeployTransactionMissingCode", Code: DeployTransactionMissingCode, Message: "Missing Compiled Code + ABI, or Solidity", Description: "a DeployTransaction message, without code to deploy"},
+	{Name: "EventStreamsDBLoad", Code: EventStreamsDBLoad, Message: "Failed to open DB at %s: %s", Description: "failed to init DB"},
+	{Name: "EventStreamsNoID", Code: EventStreamsNoID, Message: "No ID", Description: "attempt to create an event stream/sub without an ID"},
+	{Name: "EventStreamsInvalidActionType", Code: EventStreamsInvalidActionType, Message: "Unknown action type '%s'", Description: "unknown action type"},
+	{Name: "EventStreamsWebhookNoURL", Code: EventStreamsWebhookNoURL, Message: "Must specify webhook.url for action type 'webhook'", Description: "attempt to create a Webhook event stream without a URL"},
+	{Name: "EventStreamsWebhookInvalidURL", Code: EventStreamsWebhookInvalidURL, Message: "Invalid URL in webhook action", Description: "attempt to create a Webhook event stream with an invalid URL"},
+	{Name: "EventStreamsWebhookResumeActive", Code: EventStreamsWebhookResumeActive, Message: "Event processor is already active. Suspending:%t", Description: "resume when already resumed"},
+	{Name: "EventStreamsWebhookProhibitedAddress", Code: EventStreamsWebhookProhibitedAddress, Message: "Cannot send Webhook POST to address: %s", Description: "some IP ranges can be restricted"},
+	{Name: "EventStreamsWebhookFailedHTTPStatus", Code: EventStreamsWebhookFailedHTTPStatus, Message: "%s: Failed with status=%d", Description: "server at the other end of a webhook returned a non-OK response"},
+	{Name: "EventStreamsSubscribeBadBlock", Code: EventStreamsSubscribeBadBlock, Message: "FromBlock cannot be parsed as a BigInt", Description: "the starting block for a subscription request is invalid"},
+	{Name: "EventStreamsSubscribeStoreFailed", Code: EventStreamsSubscribeStoreFailed, Message: "Failed to store subscription: %s", Description: "problem saving a subscription to our DB"},
+	{Name: "EventStreamsSubscribeNoEvent", Code: EventStreamsSubscribeNoEvent, Message: "Solidity event name must be specified", Description: "missing event"},
+	{Name: "EventStreamsSubscriptionNotFound", Code: EventStreamsSubscriptionNotFound, Message: "Subscription with ID '%s' not found", Description: "sub not found"},
+	{Name: "EventStreamsCreateStreamStoreFailed", Code: EventStreamsCreateStreamStoreFailed, Message: "Failed to store stream: %s", Description: "problem saving a subscription to our DB"},
+	{Name: "EventStreamsCreateStreamResourceErr", Code: EventStreamsCreateStreamResourceErr, Message: "Failed to create a resource for the stream: %s", Description: "problem creating a resource required by the eventstream"},
+	{Name: "EventStreamsStreamNotFound", Code: EventStreamsStreamNotFound, Message: "Stream with ID '%s' not found", Description: "stream not found"},
+	{Name: "EventStreamsLogDecode", Code: EventStreamsLogDecode, Message: "%s: Failed to decode data: %s", Description: "problem decoding the logs for an event emitted on the chain"},
+	{Name: "EventStreamsLogDecodeInsufficientTopics", Code: EventStreamsLogDecodeInsufficientTopics, Message: "%s: Ran out of topics for indexed fields at field %d of %s", Description: "ran out of topics according to the indexed fields described on the ABI event"},
+	{Name: "EventStreamsLogDecodeData", Code: EventStreamsLogDecodeData, Message: "%s: Failed to parse RLP data from event: %s", Description: "RLP decoding of the data section of the logs failed"},
+	{Name: "EventStreamsWebSocketNotConfigured", Code: EventStreamsWebSocketNotConfigured, Message: "WebSocket listener not configured", Description: "WebSocket not configured"},
+	{Name: "EventStreamsWebSocketInterruptedSend", Code: EventStreamsWebSocketInterruptedSend, Message: "Interrupted waiting for WebSocket connection to send event", Description: "When we are interrupted waiting for a viable connection to send down"},
+	{Name: "EventStreamsWebSocketInterruptedReceive", Code: EventStreamsWebSocketInterruptedReceive, Message: "Interrupted waiting for WebSocket acknowledgment", Description: "When we are interrupted waiting for a viable connection to send down"},
+	{Name: "EventStreamsWebSocketErrorFromClient", Code: EventStreamsWebSocketErrorFromClient, Message: "Error received from WebSocket client: %s", Description: "Error message received from client"},
+	{Name: "EventStreamsCannotUpdateType", Code: EventStreamsCannotUpdateType, Message: "The type of an event stream cannot be changed", Description: "cannot change tyep"},
+	{Name: "EventStreamsInvalidDistributionMode", Code: EventStreamsInvalidDistributionMode, Message: "Invalid distribution mode '%s'. Valid distribution modes are: 'workloadDistribution' and 'broadcast'.", Description: "unknown distribution mode"},
+	{Name: "EventStreamsUpdateAlreadyInProgress", Code: EventStreamsUpdateAlreadyInProgress, Message: "Update to event stream already in progress", Description: "update already in progress"},
+	{Name: "KakfaProducerConfirmMsgUnknown", Code: KakfaProducerConfirmMsgUnknown, Message: "Received confirmation for message not in in-flight map: %s", Description: "we received a confirmation callback, but we aren't expecting it"},
+	{Name: "KVStoreDBLoad", Code: KVStoreDBLoad, Message: "Failed to open DB at %s: %s", Description: "failed to init DB"},
+	{Name: "KVStoreMemFilteringUnsupported", Code: KVStoreMemFilteringUnsupported, Message: "Memory receipts do not support filtering by from/to address", Description: "memory db is really just for testing. Only ID and since filtering are supported"},
+	{Name: "HDWalletSigningFailed", Code: HDWalletSigningFailed, Message: "HDWallet signing failed", Description: "problem returned from remote HDWallet API"},
+	{Name: "HDWalletSigningBadData", Code: HDWalletSigningBadData, Message: "Unexpected response from HDWallet", Description: "we got a response, but not with the correct fields"},
+	{Name: "HDWalletSigningNoConfig", Code: HDWalletSigningNoConfig, Message: "No HD Wallet Configuration", Description: "we had a request for HD Wallet signing, but we don't have the required config"},
+	{Name: "HelperStrToAddressRequiredField", Code: HelperStrToAddressRequiredField, Message: "'%s' must be supplied", Description: "re-usable error for missing fields"},
+	{Name: "HelperStrToAddressBadAddress", Code: HelperStrToAddressBadAddress, Message: "Supplied value for '%s' is not a valid hex address", Description: "re-usable error for bad address"},
+	{Name: "HelperYAMLorJSONPayloadTooLarge", Code: HelperYAMLorJSONPayloadTooLarge, Message: "Message exceeds maximum allowable size", Description: "input message too large"},
+	{Name: "HelperYAMLorJSONPayloadReadFailed", Code: HelperYAMLorJSONPayloadReadFailed, Message: "Unable to read input data: %s", Description: "failed to read input"},
+	{Name: "HelperYAMLorJSONPayloadParseFailed", Code: HelperYAMLorJSONPayloadParseFailed, Message: "Unable to parse as YAML or JSON: %s", Description: "input message got error parsing"},
+	{Name: "HTTPRequesterSerializeFailed", Code: HTTPRequesterSerializeFailed, Message: "Failed to serialize request payload: %s", Description: "common HTTP request utility for extensions, failed to serialize request"},
+	{Name: "HTTPRequesterNonStatusError", Code: HTTPRequesterNonStatusError, Message: "Error querying %s", Description: "common HTTP request utility for extensions, got an error sending a request"},
+	{Name: "HTTPRequesterStatusErrorNoData", Code: HTTPRequesterStatusErrorNoData, Message: "Could not process %s [%d] response", Description: "common HTTP request utility for extensions, got a status code, but couldn't deserialize payload"},
+	{Name: "HTTPRequesterStatusErrorWithData", Code: HTTPRequesterStatusErrorWithData, Message: "%s returned [%d]: %s", Description: "common HTTP request utility for extensions, got a non-ok status code with JSON errorMessage"},
+	{Name: "HTTPRequesterStatusError", Code: HTTPRequesterStatusError, Message: "Error querying %s", Description: "common HTTP request utility for extensions, got a non-ok status code"},
+	{Name: "HTTPRequesterResponseMissingField", Code: HTTPRequesterResponseMissingField, Message: "'%s' missing in %s response", Description: "common HTTP request utility for extensions, missing expected field in response"},
+	{Name: "HTTPRequesterResponseNonStringField", Code: HTTPRequesterResponseNonStringField, Message: "'%s' not a string in %s response", Description: "common HTTP request utility for extensions, expected string for field in response"},
+	{Name: "HTTPRequesterResponseNullField", Code: HTTPRequesterResponseNullField, Message: "'%s' empty (or null) in %s response", Description: "common HTTP request utility for extensions, expected non-empty response field"},
+	{Name: "ReceiptStoreDisabled", Code: ReceiptStoreDisabled, Message: "Receipt store not enabled", Description: "not configured"},
+	{Name: "ReceiptStoreDBLoad", Code: ReceiptStoreDBLoad, Message: "Failed to open DB at %s: %s", Description: "failed to init DB"},
+	{Name: "ReceiptStoreMongoDBConnect", Code: ReceiptStoreMongoDBConnect, Message: "Unable to connect to MongoDB: %s", Description: "couldn't connect to MongoDB"},
+	{Name: "ReceiptStoreMongoDBIndex", Code: ReceiptStoreMongoDBIndex, Message: "Unable to create index: %s", Description: "couldn't create MongoDB index"},
+	{Name: "ReceiptStoreLevelDBConnect", Code: ReceiptStoreLevelDBConnect, Message: "Unable to open LevelDB: %s", Description: "couldn't open file for the level DB"},
+	{Name: "ReceiptStoreSerializeResponse", Code: ReceiptStoreSerializeResponse, Message: "Error serializing response", Description: "problem sending a receipt stored back over the REST API"},
+	{Name: "ReceiptStoreInvalidRequestID", Code: ReceiptStoreInvalidRequestID, Message: "Invalid 'id' query parameter", Description: "bad ID query"},
+	{Name: "ReceiptStoreInvalidRequestMaxLimit", Code: ReceiptStoreInvalidRequestMaxLimit, Message: "Maximum limit is %d", Description: "bad limit over max"},
+	{Name: "ReceiptStoreInvalidRequestBadLimit", Code: ReceiptStoreInvalidRequestBadLimit, Message: "Invalid 'limit' query parameter", Description: "bad limit"},
+	{Name: "ReceiptStoreInvalidRequestBadSkip", Code: ReceiptStoreInvalidRequestBadSkip, Message: "Invalid 'skip' query parameter", Description: "bad skip"},
+	{Name: "ReceiptStoreInvalidRequestBadSince", Code: ReceiptStoreInvalidRequestBadSince, Message: "since cannot be parsed as RFC3339 or millisecond timestamp", Description: "bad since"},
+	{Name: "ReceiptStoreFailedQuery", Code: ReceiptStoreFailedQuery, Message: "Error querying replies: %s", Description: "wrapper over detailed error"},
+	{Name: "ReceiptStoreFailedQuerySingle", Code: ReceiptStoreFailedQuerySingle, Message: "Error querying reply: %s", Description: "wrapper over detailed error"},
+	{Name: "ReceiptStoreFailedNotFound", Code: ReceiptStoreFailedNotFound, Message: "Receipt not available", Description: "receipt isn't in the store"},
+	{Name: "RemoteRegistryCacheInit", Code: RemoteRegistryCacheInit, Message: "Failed to initialize cache for remote registry: %s", Description: "initialzation issue for remote contract registry"},
+	{Name: "RemoteRegistryNotConfigured", Code: RemoteRegistryNotConfigured, Message: "No remote registry is configured", Description: "cannot register as a remote registry is not configured"},
+	{Name: "RemoteRegistryRegistrationFailed", Code: RemoteRegistryRegistrationFailed, Message: "Failed to register instance in remote registry: %s", Description: "error during registration with remote contract registry"},
+	{Name: "RemoteRegistryLookupGatewayNotFound", Code: RemoteRegistryLookupGatewayNotFound, Message: "Gateway not found", Description: "did not find the requested ID in the remote registry for a gateway/factory"},
+	{Name: "RemoteRegistryLookupInstanceNotFound", Code: RemoteRegistryLookupInstanceNotFound, Message: "Instance not found", Description: "did not find the requested ID in the remote registry for a contract instance"},
+	{Name: "RemoteRegistryLookupGenericProcessingFailed", Code: RemoteRegistryLookupGenericProcessingFailed, Message: "Error processing contract registry response", Description: "we don't return the full original error over the REST API after logging"},
+	{Name: "RESTGatewayGatewayNotFound", Code: RESTGatewayGatewayNotFound, Message: "Gateway not found", Description: "the gateway REST API interface (the 'factory' / ABI generic interface) was not found"},
+	{Name: "RESTGatewayInstanceNotFound", Code: RESTGatewayInstanceNotFound, Message: "Instance not found", Description: "the instance REST API interface (an individual registered address) was not found"},
+	{Name: "RESTGatewayEventNotDeclared", Code: RESTGatewayEventNotDeclared, Message: "Event '%s' is not declared in the ABI", Description: "attempt to subscribe to an event on an instance that does not exist"},
+	{Name: "RESTGatewayMethodNotDeclared", Code: RESTGatewayMethodNotDeclared, Message: "Method or Event '%s' is not declared in the ABI of contract '%s'", Description: "attempt to invoke a method name that does not exist in the ABI, or register globally for an event that doesn't exist"},
+	{Name: "RESTGatewayInvalidToAddress", Code: RESTGatewayInvalidToAddress, Message: "To Address must be a 40 character hex string (0x prefix is optional)", Description: "failed to parse a 'to' address supplied on a path"},
+	{Name: "RESTGatewayInvalidFromAddress", Code: RESTGatewayInvalidFromAddress, Message: "From Address must be a 40 character hex string (0x prefix is optional)", Description: "failed to parse a 'from' address supplied on a path"},
+	{Name: "RESTGatewayMissingParameter", Code: RESTGatewayMissingParameter, Message: "Parameter '%s' of method '%s' was not specified in body or query parameters", Description: "did not supply a parameter required by the method"},
+	{Name: "RESTGatewayMissingFromAddress", Code: RESTGatewayMissingFromAddress, Message: "Please specify a valid address in the '%[1]s-from' query string parameter or x-%[2]s-from HTTP header", Description: "did not supply a signing address for the transaction"},
+	{Name: "RESTGatewaySubscribeMissingStreamParameter", Code: RESTGatewaySubscribeMissingStreamParameter, Message: "Must supply a 'stream' parameter in the body or query", Description: "missed the ID of the stream when registering"},
+	{Name: "RESTGatewayMixedPrivateForAndGroupID", Code: RESTGatewayMixedPrivateForAndGroupID, Message: "%[1]s-privatefor and %[1]s-privacygroupid are mutually exclusive", Description: "confused privacy group info, using simple/Tessera style as well as pre-defined/Orion style"},
+	{Name: "RESTGatewayEventManagerInitFailed", Code: RESTGatewayEventManagerInitFailed, Message: "Event-stream subscription manager: %s", Description: "constructor failure for event manager"},
+	{Name: "RESTGatewayEventStreamInvalid", Code: RESTGatewayEventStreamInvalid, Message: "Invalid event stream specification: %s", Description: "attempt to create an event stream with invalid parameters"},
+	{Name: "RESTGatewayPostDeployMissingAddress", Code: RESTGatewayPostDeployMissingAddress, Message: "%s: Missing contract address in receipt", Description: "after deployment the receipt did not contain a contract address"},
+	{Name: "RESTGatewayRegistrationSuppliedInvalidAddress", Code: RESTGatewayRegistrationSuppliedInvalidAddress, Message: "Invalid address in path - must be a 40 character hex string with optional 0x prefix", Description: "invalid address when registering an existing instance of a contract"},
+	{Name: "RESTGatewaySyncMsgTypeMismatch", Code: RESTGatewaySyncMsgTypeMismatch, Message: "Unexpected condition (message types do not match when processing)", Description: "sync-invoke code paths in REST API Gateway should be maintained such that this cannot happen"},
+	{Name: "RESTGatewaySyncWrapErrorWithTXDetail", Code: RESTGatewaySyncWrapErrorWithTXDetail, Message: "TX %s: %s", Description: "wraps a low level error with transaction hash context on sync APIs before returning"},
+	{Name: "RESTGatewayMethodTypeInvalid", Code: RESTGatewayMethodTypeInvalid, Message: "Unsupported method type: %s", Description: "unsupported method type"},
+	{Name: "RESTGatewayMethodABIInvalid", Code: RESTGatewayMethodABIInvalid, Message: "Invalid method '%s' in ABI: %s", Description: "error processing method from ABI"},
+	{Name: "RESTGatewayEventABIInvalid", Code: RESTGatewayEventABIInvalid, Message: "Invalid event '%s' in ABI: %s", Description: "error processing method from ABI"},
+	{Name: "RESTGatewayCompileContractInvalidFormData", Code: RESTGatewayCompileContractInvalidFormData, Message: "Could not parse supplied multi-part form data: %s", Description: "invalid form data when requesting a compilation to generate an ABI/bytecode"},
+	{Name: "RESTGatewayCompileContractCompileFailed", Code: RESTGatewayCompileContractCompileFailed, Message: "Failed to compile solidity: %s", Description: "failed to perform compile"},
+	{Name: "RESTGatewayCompileContractPostCompileFailed", Code: RESTGatewayCompileContractPostCompileFailed, Message: "Failed to process solidity: %s", Description: "failed to process output of compilation"},
+	{Name: "RESTGatewayCompileContractExtractedReadFailed", Code: RESTGatewayCompileContractExtractedReadFailed, Message: "Failed to read extracted multi-part form data", Description: "failed to read extracted contents of uploaded data"},
+	{Name: "RESTGatewayCompileContractNoSOL", Code: RESTGatewayCompileContractNoSOL, Message: "No .sol files found in root. Please set a 'source' query param or form field to the relative path of your solidity", Description: "failed to find any solidity files in uploaded data"},
+	{Name: "RESTGatewayCompileContractSolcVerFail", Code: RESTGatewayCompileContractSolcVerFail, Message: "Failed checking solc version: %s", Description: "failed while checking version of solidity compiler 'solc'"},
+	{Name: "RESTGatewayCompileContractCompileFailDetails", Code: RESTGatewayCompileContractCompileFailDetails, Message: "Failed to compile [%s]: %s", Description: "output from compiler failure"},
+	{Name: "RESTGatewayCompileContractSolcOutputProcessFail", Code: RESTGatewayCompileContractSolcOutputProcessFail, Message: "Failed to parse solc output: %s", Description: "failed to process output of compilation"},
+	{Name: "RESTGatewayCompileContractSlashes", Code: RESTGatewayCompileContractSlashes, Message: "Filenames cannot contain slashes. Use a zip file to upload a directory structure", Description: "unsafe slash characters in filenames"},
+	{Name: "RESTGatewayCompileContractUnzipRead", Code: RESTGatewayCompileContractUnzipRead, Message: "Failed to read archive", Description: "error opening zip/tgz to read (no extra information to remote caller)"},
+	{Name: "RESTGatewayCompileContractUnzipWrite", Code: RESTGatewayCompileContractUnzipWrite, Message: "Failed to process archive", Description: "error writing extracted zip (no extra information to remote caller)"},
+	{Name: "RESTGatewayCompileContractUnzipCopy", Code: RESTGatewayCompileContractUnzipCopy, Message: "Failed to process archive", Description: "error writing extracted zip (no extra information to remote caller)"},
+	{Name: "RESTGatewayCompileContractUnzip", Code: RESTGatewayCompileContractUnzip, Message: "Error unarchiving supplied zip file: %s", Description: "failure thrown from decompression library during extract"},
+	{Name: "RESTGatewayLocalStoreContractSave", Code: RESTGatewayLocalStoreContractSave, Message: "Failed to write ABI JSON: %s", Description: "local filesystem storage failure for contract instance (non-registry code flow)"},
+	{Name: "RESTGatewayLocalStoreContractLoad", Code: RESTGatewayLocalStoreContractLoad, Message: "Failed to find installed contract address for '%s'", Description: "local filesystem load failure for contract instance (non-registry code flow)"},
+	{Name: "RESTGatewayLocalStoreContractNotFound", Code: RESTGatewayLocalStoreContractNotFound, Message: "No contract instance registered with address %s", Description: "local filesystem not found (non-registry code flow)"},
+	{Name: "RESTGatewayLocalStoreABINotFound", Code: RESTGatewayLocalStoreABINotFound, Message: "No ABI found with ID %s", Description: "lookup of ABI failed not found (non-registry code flow)"},
+	{Name: "RESTGatewayLocalStoreABILoad", Code: RESTGatewayLocalStoreABILoad, Message: "Failed to load ABI with ID %s: %s", Description: "local filesystem load failure for ABI details (non-registry code flow)"},
+	{Name: "RESTGatewayLocalStoreABIParse", Code: RESTGatewayLocalStoreABIParse, Message: "Failed to parse ABI with ID %s: %s", Description: "local filesystem parse failure for ABI details (non-registry code flow)"},
+	{Name: "RESTGatewayLocalStoreMissingABI", Code: RESTGatewayLocalStoreMissingABI, Message: "Must supply ABI to install an existing ABI into the REST Gateway", Description: "did not supply ABI JSON when attempting to install ABI (non-registry code flow)"},
+	{Name: "RESTGatewayInvalidABI", Code: RESTGatewayInvalidABI, Message: "Invalid ABI: %s", Description: "invalid serialized ABI in msg"},
+	{Name: "RESTGatewayLocalStoreContractSavePostDeploy", Code: RESTGatewayLocalStoreContractSavePostDeploy, Message: "%s: Failed to write deployment details: %s", Description: "local filesystem storage failure for contract instance post deploy (non-registry code flow)"},
+	{Name: "RESTGatewayFriendlyNameClash", Code: RESTGatewayFriendlyNameClash, Message: "Contract address %s is already registered for name '%s'", Description: "duplicate friendly name when reigstering"},
+	{Name: "RESTGatewayResourceErr", Code: RESTGatewayResourceErr, Message: "Failed to create a resource for the REST Gateway: %s", Description: "problem creating a resource required by the gateway"},
+	{Name: "RPCCallReturnedError", Code: RPCCallReturnedError, Message: "%s returned: %s", Description: "specified RPC call returned error"},
+	{Name: "RPCConnectFailed", Code: RPCConnectFailed, Message: "JSON/RPC connection to %s failed: %s", Description: "error connecting to back-end server over JSON/RPC"},
+	{Name: "SecurityModulePluginLoad", Code: SecurityModulePluginLoad, Message: "Failed to load plugin: %s", Description: "failed to load .so"},
+	{Name: "SecurityModulePluginSymbol", Code: SecurityModulePluginSymbol, Message: "Failed to load 'SecurityModule' symbol from '%s': %s", Description: "missing symbol in plugin"},
+	{Name: "SecurityModuleNoAuthContext", Code: SecurityModuleNoAuthContext, Message: "No auth context", Description: "missing auth context in context object at point security module is invoked"},
+	{Name: "TransactionQueryFailed", Code: TransactionQueryFailed, Message: "Failed to query transaction: %s", Description: "transaction lookup failed"},
+	{Name: "TransactionQueryMethodMismatch", Code: TransactionQueryMethodMismatch, Message: "Method signature did not match: %s != %s", Description: "transaction input did not match the method queried"},
+	{Name: "TransactionSendConstructorPackArgs", Code: TransactionSendConstructorPackArgs, Message: "Packing arguments for constructor: %s", Description: "RLP encoding failure for a constructor"},
+	{Name: "TransactionSendMethodPackArgs", Code: TransactionSendMethodPackArgs, Message: "Packing arguments for method '%s': %s", Description: "RLP encoding failure for a method"},
+	{Name: "TransactionSendInputTypeUnknown", Code: TransactionSendInputTypeUnknown, Message: "ABI input %d: Unable to map %s to etherueum type: %s", Description: "there is a type in the ABI inputs that we don't understand"},
+	{Name: "TransactionSendOutputTypeUnknown", Code: TransactionSendOutputTypeUnknown, Message: "ABI output %d: Unable to map %s to etherueum type: %s", Description: "there is a type in the ABI outputs that we don't understand"},
+	{Name: "TransactionSendGasEstimateFailed", Code: TransactionSendGasEstimateFailed, Message: "Failed to calculate gas for transaction: %s", Description: "gas estimation failed prior to sending TX"},
+	{Name: "TransactionSendCallFailedNoRevert", Code: TransactionSendCallFailedNoRevert, Message: "Call failed: %s", Description: "failed to perform an eth_call with a JSON/RPC error (not a revert)"},
+	{Name: "TransactionSendCallFailedRevertMessage", Code: TransactionSendCallFailedRevertMessage, Message: "%s", Description: "directly passes the revert message from the EVM"},
+	{Name: "TransactionSendCallFailedRevertNoMessage", Code: TransactionSendCallFailedRevertNoMessage, Message: "EVM reverted. Failed to decode error message", Description: "when we couldn't process the EVM revert message"},
+	{Name: "TransactionSendMissingPrivateFromOrion", Code: TransactionSendMissingPrivateFromOrion, Message: "private-from is required when submitting private transactions via Orion", Description: "there is no default privateFrom in Orion, so the user must always supply it"},
+	{Name: "TransactionSendPrivateTXWithExternalSigner", Code: TransactionSendPrivateTXWithExternalSigner, Message: "Signing with %s is not currently supported with private transactions", Description: "we don't allow private transactions to be combined with a HD Wallet or other external signer currently"},
+	{Name: "TransactionSendPrivateForAndPrivacyGroup", Code: TransactionSendPrivateForAndPrivacyGroup, Message: "privacyGroupId and privateFor are mutually exclusive", Description: "mixed both params"},
+	{Name: "TransactionSendNonceFailWithPrivacyGroup", Code: TransactionSendNonceFailWithPrivacyGroup, Message: "priv_getTransactionCount for privacy group '%s' returned: %s", Description: "when we successfully lookup the privacy group, but cannot get the nonce"},
+	{Name: "TransactionSendMissingMethod", Code: TransactionSendMissingMethod, Message: "Method missing - must provide inline 'param' type/value pairs with a 'methodName', or an ABI in 'method'", Description: "a request to send a transaction was received (webhook/Kafka) that was missing method details (unexpected when using REST APIs that validate this)"},
+	{Name: "TransactionSendBadNonce", Code: TransactionSendBadNonce, Message: "Converting supplied 'nonce' to integer: %s", Description: "a user-supplied nonce string in the JSON input cannot be processed"},
+	{Name: "TransactionSendBadValue", Code: TransactionSendBadValue, Message: "Converting supplied 'value' to big integer: %s", Description: "a user-supplied value (eth amount to transfer) string in the JSON input cannot be processed"},
+	{Name: "TransactionSendBadGas", Code: TransactionSendBadGas, Message: "Converting supplied 'gas' to integer: %s", Description: "a user-supplied gas (maximum gas to spend on the TX) string in the JSON input cannot be processed"},
+	{Name: "TransactionSendBadGasPrice", Code: TransactionSendBadGasPrice, Message: "Converting supplied 'gasPrice' to big integer", Description: "a user-supplied gasPrice (eth to pay for each unit of gas spent) string in the JSON input cannot be processed"},
+	{Name: "TransactionSendInputTypeBadNumber", Code: TransactionSendInputTypeBadNumber, Message: "Method '%s' param %s: Could not be converted to a number", Description: "the input JSON value supplied for a method parameter cannot be converted to a number"},
+	{Name: "TransactionSendInputTypeBadJSONTypeForNumber", Code: TransactionSendInputTypeBadJSONTypeForNumber, Message: "Method '%s' param %s is a %s: Must supply a number or a string (supplied=%s)", Description: "the input JSON value supplied for a method parameter was not a number or a string, and needs to be converted to a number"},
+	{Name: "TransactionSendInputTypeBadJSONTypeForArray", Code: TransactionSendInputTypeBadJSONTypeForArray, Message: "Method '%s' param %s is a %s: Must supply an array (supplied=%s)", Description: "the input JSON value supplied for a method parameter was not compatible with coercion to an array"},
+	{Name: "TransactionSendInputTypeBadNull", Code: TransactionSendInputTypeBadNull, Message: "Method '%s' param %s: Cannot supply a null value", Description: "the input JSON value supplied was null"},
+	{Name: "TransactionSendInputTypeBadJSONTypeForBoolean", Code: TransactionSendInputTypeBadJSONTypeForBoolean, Message: "Method '%s' param %s is a %s: Must supply a boolean or a string (supplied=%s)", Description: "the input JSON value supplied for a method parameter was not compatible with coercion to a boolean"},
+	{Name: "TransactionSendInputTypeBadJSONTypeForString", Code: TransactionSendInputTypeBadJSONTypeForString, Message: "Method '%s' param %s: Must supply a string (supplied=%s)", Description: "the input JSON value supplied for a method parameter was not compatible with coercion to a boolean"},
+	{Name: "TransactionSendInputTypeAddress", Code: TransactionSendInputTypeAddress, Message: "Method '%s' param %s: Could not be converted to a hex address (supplied=%s)", Description: "the input JSON value supplied for a method parameter couldn't be parsed as an eth address"},
+	{Name: "TransactionSendInputTypeBadJSONTypeForAddress", Code: TransactionSendInputTypeBadJSONTypeForAddress, Message: "Method '%s' param %s is a %s: Must supply a hex address string (supplied=%s)", Description: "the input JSON value supplied for a method parameter was not compatible with coercion to an eth address"},
+	{Name: "TransactionSendInputTypeBadJSONTypeInNumericArray", Code: TransactionSendInputTypeBadJSONTypeInNumericArray, Message: "Method '%s' param %s is a %s: Invalid entry in number array at index %d (%s)", Description: "one of the entries inside of a numeric array, is not valid as a number"},
+	{Name: "TransactionSendInputTypeBadByteOutsideRange", Code: TransactionSendInputTypeBadByteOutsideRange, Message: "Method '%s' param %s is a %s: Invalid number - outside of range for byte", Description: "one of the entries inside of a byte array, is a number outside the range for bytes"},
+	{Name: "TransactionSendInputTypeBadJSONTypeForBytes", Code: TransactionSendInputTypeBadJSONTypeForBytes, Message: "Method '%s' param %s is a %s: Must supply a hex string, or number array", Description: "one of the entries inside of a byte array, is a number outside the range for bytes"},
+	{Name: "TransactionSendInputTypeBadJSONTypeForTuple", Code: TransactionSendInputTypeBadJSONTypeForTuple, Message: "Method '%s' param %s is a %s: Must supply an object (supplied=%s)", Description: "if we are provided a non object input on the JSON for a struct (tuple)"},
+	{Name: "TransactionSendInputTypeNotSupported", Code: TransactionSendInputTypeNotSupported, Message: "Type '%s' is not yet supported", Description: "did not know how to handle this type - enhancement required"},
+	{Name: "TransactionSendInputCountMismatch", Code: TransactionSendInputCountMismatch, Message: "Method '%s': Requires %d args (supplied=%d)", Description: "wrong number of args supplied according to the ABI"},
+	{Name: "TransactionSendInputStructureWrong", Code: TransactionSendInputStructureWrong, Message: "Param %d: supplied as an object must have 'type' and 'value' fields", Description: "the JSON structure supplied to describe the arguments is incorrect according to our schema"},
+	{Name: "TransactionSendInputInLineTypeArrayNotString", Code: TransactionSendInputInLineTypeArrayNotString, Message: "Param %d: supplied as an object must be string", Description: "when sending us an ABI definition for the inputs directly"},
+	{Name: "TransactionSendInputInLineTypeUnknown", Code: TransactionSendInputInLineTypeUnknown, Message: "Param %d: Unable to map %s to etherueum type: %s", Description: "when sending us an ABI definition for the inputs directly, the type string isn't known as an ethereum type"},
+	{Name: "TransactionSendMsgTypeUnknown", Code: TransactionSendMsgTypeUnknown, Message: "Unknown message type '%s'", Description: "we got a JSON message into the core processor (from Kafka, Webhooks etc.) that we don't understand"},
+	{Name: "TransactionSendInputTooManyParams", Code: TransactionSendInputTooManyParams, Message: "Supplied %d parameters for ABI that supports %d", Description: "more parameters provided than specified on ABI"},
+	{Name: "TransactionSendInputNotAssignable", Code: TransactionSendInputNotAssignable, Message: "Method %s param %s: supplied value '%+v' could not be assigned to '%s' field (%s)", Description: "if we end up in a situation where the generated type cannot be assigned"},
+	{Name: "TransactionSendReceiptCheckError", Code: TransactionSendReceiptCheckError, Message: "Error obtaining transaction receipt (%d retries): %s", Description: "we continually had bad RCs back from the node while trying to check for the receipt up to the timeout"},
+	{Name: "TransactionSendReceiptCheckTimeout", Code: TransactionSendReceiptCheckTimeout, Message: "Timed out waiting for transaction receipt", Description: "we didn't have a problem asking the node for a receipt, but the transaction wasn't mined at the end of the timeout"},
+	{Name: "TransactionCallInvalidBlockNumber", Code: TransactionCallInvalidBlockNumber, Message: "Invalid blocknumber. Failed to parse into big integer", Description: "on \"eth_call\" the optional parameter for the target blocknumber failed to parse to a big integer"},
+	{Name: "UnpackOutputsFailed", Code: UnpackOutputsFailed, Message: "Failed to unpack values: %s", Description: "RLP decoding of outputs, logs, or events failed"},
+	{Name: "UnpackOutputsMismatch", Code: UnpackOutputsMismatch, Message: "Expected %d type in JSON/RPC response. Received %d: %+v", Description: "RLP decoding of output gave an unexpected type according to the ABI"},
+	{Name: "UnpackOutputsMismatchCount", Code: UnpackOutputsMismatchCount, Message: "Expected %d in JSON/RPC response. Received %d: %+v", Description: "wrong number of arguments"},
+	{Name: "UnpackOutputsMismatchNil", Code: UnpackOutputsMismatchNil, Message: "Expected nil in JSON/RPC response. Received: %+v", Description: "RLP decoding of output gave a non-nil type, and we expected nil"},
+	{Name: "UnpackOutputsMismatchType", Code: UnpackOutputsMismatchType, Message: "Expected %s type in JSON/RPC response for %s (%s). Received %s", Description: "expected to find a number according to supplied ABI, but got something else"},
+	{Name: "UnpackOutputsUnknownType", Code: UnpackOutputsUnknownType, Message: "Unable to process type for %s (%s). Received %s", Description: "did not know how to handle this type - enhancement required"},
+	{Name: "UnpackOutputsMismatchTupleType", Code: UnpackOutputsMismatchTupleType, Message: "Unable to process type for %s (%s). Expected %s. Received %+v", Description: "we got a type back from the unpacking that doesn't match the ABI"},
+	{Name: "UnpackOutputsMismatchTupleFieldCount", Code: UnpackOutputsMismatchTupleFieldCount, Message: "Unable to process type for %s (%s). Expected %d fields on the structure. Received %d", Description: "we had a mismatch in the number of fields described on the ABI and the number on the go structure"},
+	{Name: "Unauthorized", Code: Unauthorized, Message: "Unauthorized", Description: "(401 error)"},
+	{Name: "WebhooksInvalidMsgHeaders", Code: WebhooksInvalidMsgHeaders, Message: "Invalid message - missing 'headers' (or not an object)", Description: "missing headers section in the JSON/YAML posted"},
+	{Name: "WebhooksInvalidMsgTypeMissing", Code: WebhooksInvalidMsgTypeMissing, Message: "Invalid message - missing 'headers.type' (or not a string)", Description: "need to specify a msg type in the header"},
+	{Name: "WebhooksInvalidMsgFromMissing", Code: WebhooksInvalidMsgFromMissing, Message: "Invalid message - missing 'from' (or not a string)", Description: "need to specify a msg type in the header"},
+	{Name: "WebhooksInvalidMsgType", Code: WebhooksInvalidMsgType, Message: "Invalid message type: %s", Description: "need to specify a valid msg type in the header"},
+	{Name: "WebhooksKafkaUnexpectedErrFmt", Code: WebhooksKafkaUnexpectedErrFmt, Message: "Error did not contain message and metadata: %+v", Description: "problem processing an error that came back from Kafka, so do a deep dump"},
+	{Name: "WebhooksKafkaDeliveryReportNoMeta", Code: WebhooksKafkaDeliveryReportNoMeta, Message: "Sent message did not contain metadata: %+v", Description: "delivery reports should contain the metadata we set when we sent"},
+	{Name: "WebhooksKafkaYAMLtoJSON", Code: WebhooksKafkaYAMLtoJSON, Message: "Unable to reserialize YAML payload as JSON: %s", Description: "re-serialization of webhook message into JSON failed"},
+	{Name: "WebhooksKafkaErr", Code: WebhooksKafkaErr, Message: "Failed to deliver message to Kafka: %s", Description: "wrapper on detailed error from Kafka itself"},
+	{Name: "WebhooksDirectTooManyInflight", Code: WebhooksDirectTooManyInflight, Message: "Too many in-flight transactions", Description: "when we're not using a buffered store (Kafka) we have to reject"},
+	{Name: "WebhooksDirectBadHeaders", Code: WebhooksDirectBadHeaders, Message: "Failed to process headers in message", Description: "problem processing for in-memory operation"},
+	{Name: "LevelDBFailedRetriveOriginalKey", Code: LevelDBFailedRetriveOriginalKey, Message: "Failed to retrieve the entry for the original key: %s. %s", Description: "problem retrieving entry - original key"},
+	{Name: "LevelDBFailedRetriveGeneratedID", Code: LevelDBFailedRetriveGeneratedID, Message: "Failed to retrieve the entry for the generated ID: %s. %s", Description: "problem retrieving entry - generated ID"},
+	{Name: "WebSocketClosed", Code: WebSocketClosed, Message: "WebSocket '%s' closed", Description: "websocket was closed"},
+	{Name: "CircuitBreakerTripped", Code: CircuitBreakerTripped, Message: "Unable to send Kafka message as the gap of %d messages between consumer and producer is too large. Estimated at %.2fKb", Description: "is returned when the Kafka circuit breaker has deemed it unsafe to produce more messages"},
+	{Name: "EventSupportNotConfigured", Code: EventSupportNotConfigured, Message: "Event support is not configured on this gateway", Description: "is returned when event support is not configured"},
+	{Name: "FFCBadVersion", Code: FFCBadVersion, Message: "Bad FFCAPI Version '%s': %s", Description: "is returned when an FFCAPI request has a bad version header"},
+	{Name: "FFCUnsupportedVersion", Code: FFCUnsupportedVersion, Message: "Unsupported FFCAPI Version '%s'", Description: "is returned when there is a bad version supplied on an FFCAPI request"},
+	{Name: "FFCUnsupportedRequestType", Code: FFCUnsupportedRequestType, Message: "Unsupported FFCAPI request type '%s'", Description: "is returned when the request type is unsupported for an FFCAPI request"},
+	{Name: "FFCMissingRequestID", Code: FFCMissingRequestID, Message: "Missing FFCAPI request id", Description: "is returned when the request ID is missing"},
+	{Name: "FFCUnmarshalABIFail", Code: FFCUnmarshalABIFail, Message: "Failed to parse method ABI: %s", Description: "is returned when failing to unmarshal a parameter to a generic go struct"},
+	{Name: "FFCUnmarshalParamFail", Code: FFCUnmarshalParamFail, Message: "Failed to parse parameter %d: %s", Description: "is returned when failing to unmarshal a parameter to a generic go struct"},
+	{Name: "FFCInvalidGasPrice", Code: FFCInvalidGasPrice, Message: "Failed to parse gasPrice '%s': %s", Description: "is returned when the gas price cannot be parsed as a number (support for London fork not yet in place)"},
+	{Name: "FFCInvalidTXData", Code: FFCInvalidTXData, Message: "Failed to parse transaction data as hex '%s': %s", Description: "is returned when the transaction input data cannot be parsed as hex"},
+	{Name: "FFCReceiptNotAvailable", Code: FFCReceiptNotAvailable, Message: "Receipt not available for transaction '%s'", Description: "is returned when a receipt is not found"},
+	{Name: "FFCRequestTypeNotImplemented", Code: FFCRequestTypeNotImplemented, Message: "FFCAPI request '%s' not currently supported", Description: "is returned when an operation is not supported"},
+	{Name: "FFCBlockNotAvailable", Code: FFCBlockNotAvailable, Message: "Block not available", Description: "is returned when a receipt is not found"},
+	{Name: "ReceiptStoreKeyNotUnique", Code: ReceiptStoreKeyNotUnique, Message: "Request ID is not unique", Description: "non-unique request ID"},
+	{Name: "ReceiptErrorIdempotencyCheck", Code: ReceiptErrorIdempotencyCheck, Message: "Failed querying the receipt store, performing duplicate message check on ackmode=receipt for id %s: %s", Description: "failed to query receipt during idempotency check"},
+	{Name: "ResubmissionPreventedCheckTransactionHash", Code: ResubmissionPreventedCheckTransactionHash, Message: "Resubmission of this transaction was prevented by the REST API Gateway. Check the status of the transaction by the transaction hash", Description: "redelivery was prevented by the processor"},
+	{Name: "KVStoreDBMarshal", Code: KVStoreDBMarshal, Message: "Failed to serialize JSON to %T: %s", Description: "failed to unmarshal to object"},
+	{Name: "KVStoreDBUnmarshal", Code: KVStoreDBUnmarshal, Message: "Failed to parse stored JSON at %T: %s", Description: "failed to unmarshal to object"},
+	{Name: "RESTGatewayMissingStoragePath", Code: RESTGatewayMissingStoragePath, Message: "REST Gateway storagePath must be set", Description: "storage path must be set"},
+	{Name: "CompilerFailedVersion", Code: CompilerFailedVersion, Message: "Failed to invoke solc binary '%s' to check version: %s", Description: "failed to get version"},
+	{Name: "CompilerFailedVersionRegex", Code: CompilerFailedVersionRegex, Message: "Failed to extract version from solc '%s' output: %s", Description: "failed to extract version from output"},
+	{Name: "EventStreamsExportBadFormat", Code: EventStreamsExportBadFormat, Message: "Invalid export format '%s'. Valid formats are: 'csv', 'json' and 'parquet'", Description: "unknown output format requested for an export"},
+	{Name: "EventStreamsExportBadBlockRange", Code: EventStreamsExportBadBlockRange, Message: "Invalid block range for export: fromBlock='%s' toBlock='%s'", Description: "block range for an export could not be parsed, or is reversed"},
+	{Name: "EventStreamsExportWriteFailed", Code: EventStreamsExportWriteFailed, Message: "Failed to write export output: %s", Description: "failed writing to the output of an export"},
+	{Name: "AccessLogFileOpen", Code: AccessLogFileOpen, Message: "Failed to open access log file '%s': %s", Description: "failed to open the file configured for the HTTP access log"},
+	{Name: "AccessLogHijackUnsupported", Code: AccessLogHijackUnsupported, Message: "Response writer does not support hijacking the connection", Description: "the underlying response writer does not support connection hijacking"},
+	{Name: "WebSocketTooManyConnections", Code: WebSocketTooManyConnections, Message: "Maximum of %d WebSocket connections reached", Description: "the maximum number of concurrent WebSocket connections has been reached"},
+	{Name: "WebSocketRateLimitExceeded", Code: WebSocketRateLimitExceeded, Message: "Message rate limit of %.2f/s exceeded", Description: "a client sent messages faster than the configured rate limit"},
+	{Name: "ConfigUnknownChainProfile", Code: ConfigUnknownChainProfile, Message: "Unknown chain profile '%s'. Supported profiles: generic, optimism, arbitrum", Description: "the chain profile for receipt extensions is not recognized"},
+	{Name: "RESTGatewayLegacyRoutesDisabled", Code: RESTGatewayLegacyRoutesDisabled, Message: "Unversioned routes are disabled. Use the %s prefix", Description: "the unversioned routes have been disabled in config"},
+	{Name: "GenAPIInvalidABI", Code: GenAPIInvalidABI, Message: "No valid ABI found in '%s': %v", Description: "the input file for OpenAPI generation did not contain a valid ABI"},
+	{Name: "GenAPIInvalidBaseURL", Code: GenAPIInvalidBaseURL, Message: "Invalid base URL '%s': %s", Description: "the base URL for OpenAPI generation could not be parsed"},
+	{Name: "GenAPIWriteFailed", Code: GenAPIWriteFailed, Message: "Failed to write output '%s': %s", Description: "failed to write the generated OpenAPI definition"},
+	{Name: "ReceiptStoreElasticsearchSetup", Code: ReceiptStoreElasticsearchSetup, Message: "Unable to set up Elasticsearch receipt index: %s", Description: "failed to configure the Elasticsearch indices"},
+	{Name: "ReceiptStoreElasticsearchRequest", Code: ReceiptStoreElasticsearchRequest, Message: "Elasticsearch request %s %s failed: %s", Description: "a request to Elasticsearch failed to complete"},
+	{Name: "ReceiptStoreElasticsearchStatus", Code: ReceiptStoreElasticsearchStatus, Message: "Elasticsearch returned [%d]: %s", Description: "Elasticsearch returned a failure status"},
+	{Name: "ReceiptStoreElasticsearchResponse", Code: ReceiptStoreElasticsearchResponse, Message: "Unable to parse Elasticsearch response: %s", Description: "the response from Elasticsearch could not be parsed"},
+	{Name: "ReceiptStoreElasticsearchSerialize", Code: ReceiptStoreElasticsearchSerialize, Message: "Unable to serialize receipt: %s", Description: "the receipt could not be serialized for indexing"},
+	{Name: "ReceiptStoreElasticsearchBulkMismatch", Code: ReceiptStoreElasticsearchBulkMismatch, Message: "Elasticsearch returned %d bulk results for %d receipts", Description: "the bulk response did not contain a result for every receipt"},
+	{Name: "ReceiptStoreElasticsearchClosed", Code: ReceiptStoreElasticsearchClosed, Message: "Elasticsearch receipt store is closed", Description: "the receipt store has been shut down"},
+	{Name: "ReceiptStoreSearchNotSupported", Code: ReceiptStoreSearchNotSupported, Message: "The configured receipt store does not support the 'q' or 'contractAddress' query parameters", Description: "full-text/contract search requires a store with rich query support"},
+	{Name: "EventStreamsSubscribeBadTime", Code: EventStreamsSubscribeBadTime, Message: "FromTime '%s' cannot be parsed as an RFC3339 timestamp", Description: "the starting time for a subscription request is invalid"},
+	{Name: "EventStreamsSubscribeBlockAndTime", Code: EventStreamsSubscribeBlockAndTime, Message: "Only one of fromBlock and fromTime can be specified", Description: "both a starting block and time were supplied"},
+	{Name: "ReceiptStoreSQLiteDriverMissing", Code: ReceiptStoreSQLiteDriverMissing, Message: "SQLite receipt store is not available in this build (requires cgo)", Description: "the binary was built without a SQLite driver"},
+	{Name: "ReceiptStoreSQLiteOpen", Code: ReceiptStoreSQLiteOpen, Message: "Unable to open SQLite receipt store at %s: %s", Description: "failed to open or initialize the SQLite database"},
+	{Name: "ReceiptExporterUnknownType", Code: ReceiptExporterUnknownType, Message: "Unknown type '%s' for receipt exporter '%s'", Description: "the exporter type is not one we support"},
+	{Name: "ReceiptExporterMissingConfig", Code: ReceiptExporterMissingConfig, Message: "Receipt exporter '%s' requires '%s' to be configured", Description: "a required setting for the exporter is missing"},
+	{Name: "ReceiptExporterHTTPStatus", Code: ReceiptExporterHTTPStatus, Message: "Receipt exporter '%s' received status %d from %s", Description: "the HTTP endpoint rejected the batch"},
+	{Name: "ReceiptExporterWriteFile", Code: ReceiptExporterWriteFile, Message: "Receipt exporter '%s' failed to write batch file: %s", Description: "failed to write a batch file"},
+	{Name: "SecurityModuleNoApprovalSupport", Code: SecurityModuleNoApprovalSupport, Message: "The configured security module does not support the approval workflow", Description: "the security module does not implement the approval extension"},
+	{Name: "ApprovalsConfigPathMissing", Code: ApprovalsConfigPathMissing, Message: "A path for the approval store must be configured when approvals are enabled", Description: "the approval store path is required when approvals are enabled"},
+	{Name: "ApprovalsBadThreshold", Code: ApprovalsBadThreshold, Message: "Invalid approval value threshold '%s'", Description: "the value threshold could not be parsed"},
+	{Name: "ApprovalsNotFound", Code: ApprovalsNotFound, Message: "No submission pending approval with id '%s'", Description: "no submission is parked with the ID"},
+	{Name: "ApprovalsNotPending", Code: ApprovalsNotPending, Message: "Submission '%s' has already been %s", Description: "the submission has already been approved or rejected"},
+	{Name: "ApprovalsSameApprover", Code: ApprovalsSameApprover, Message: "Submission '%s' must be approved by a different principal to the submitter", Description: "the submitter attempted to approve their own submission"},
+	{Name: "ApprovalsStoreFailed", Code: ApprovalsStoreFailed, Message: "Failed to update approval store: %s", Description: "failed to read or write the approval store"},
+	{Name: "ApprovalsRejected", Code: ApprovalsRejected, Message: "Submission rejected by '%s': %s", Description: "reply stored for a submission that was rejected"},
+	{Name: "EventStreamsScheduleInvalid", Code: EventStreamsScheduleInvalid, Message: "Invalid schedule '%s' - must be a five field cron expression, or '@every <duration>' of at least 1s", Description: "the cron schedule could not be parsed"},
+	{Name: "EventStreamsScheduledQueryNotFound", Code: EventStreamsScheduledQueryNotFound, Message: "Scheduled query with ID '%s' not found", Description: "the scheduled query does not exist"},
+	{Name: "EventStreamsScheduledQueryMissingFields", Code: EventStreamsScheduledQueryMissingFields, Message: "A contract address and method must be supplied for a scheduled query", Description: "the method or address is missing"},
+	{Name: "EventStreamsScheduledQueryStoreFailed", Code: EventStreamsScheduledQueryStoreFailed, Message: "Failed to store scheduled query: %s", Description: "failed to store a scheduled query"},
+	{Name: "RESTGatewayScheduledQueryInvalid", Code: RESTGatewayScheduledQueryInvalid, Message: "Invalid scheduled query specification: %s", Description: "attempt to create a scheduled query with invalid parameters"},
+	{Name: "WebSocketRepliesBadSince", Code: WebSocketRepliesBadSince, Message: "since '%s' cannot be parsed as RFC3339 or millisecond timestamp", Description: "the since resume point for a reply stream could not be parsed"},
+	{Name: "WebSocketRepliesNoHistory", Code: WebSocketRepliesNoHistory, Message: "Reply history is not available to resume from 'since'", Description: "there is no receipt store to resume a reply stream from"},
+	{Name: "WebSocketRepliesHistoryFailed", Code: WebSocketRepliesHistoryFailed, Message: "Failed to query reply history: %s", Description: "failed to query the receipt store to resume a reply stream"},
+	{Name: "RPCCallTimeout", Code: RPCCallTimeout, Message: "%s timed out after %s (timeout class '%s')", Description: "an RPC call did not complete within the timeout for its class"},
+	{Name: "RPCTimeoutClassUnknown", Code: RPCTimeoutClassUnknown, Message: "Unknown timeout class '%s' for RPC method '%s' - must be fast, medium or slow", Description: "an RPC method was configured with a timeout class that does not exist"},
+	{Name: "RESTGatewayEventSchemaFormat", Code: RESTGatewayEventSchemaFormat, Message: "Unsupported schema format '%s' - only 'json' is supported", Description: "requested a schema for an event in a format we do not generate"},
+	{Name: "ConfigMTLSCertKey", Code: ConfigMTLSCertKey, Message: "Mutual TLS requires a server certificate file and key file", Description: "mutual TLS was enabled on the listener without a server certificate and key"},
+	{Name: "ConfigMTLSLoadFailed", Code: ConfigMTLSLoadFailed, Message: "Failed to load mutual TLS certificates: %s", Description: "failed to load the certificates for mutual TLS on the listener"},
+	{Name: "ConfigMTLSClientCAs", Code: ConfigMTLSClientCAs, Message: "Mutual TLS requires a file containing the CA certificates trusted to issue client certificates", Description: "mutual TLS was enabled on the listener without CAs to verify clients against"},
+	{Name: "RESTGatewayClientCertNotAllowed", Code: RESTGatewayClientCertNotAllowed, Message: "Client certificate subject '%s' is not permitted", Description: "the client certificate was valid, but the subject is not in the allow-list"},
+	{Name: "HTTPRequesterTLSConfig", Code: HTTPRequesterTLSConfig, Message: "Invalid TLS configuration for %s: %s", Description: "common HTTP request utility for extensions, the TLS configuration could not be loaded"},
+	{Name: "EventStreamsPubSubNoTopic", Code: EventStreamsPubSubNoTopic, Message: "Must specify pubsub.topic for action type 'pubsub'", Description: "attempt to create a Pub/Sub event stream without a topic"},
+	{Name: "EventStreamsPubSubNoProject", Code: EventStreamsPubSubNoProject, Message: "Must specify pubsub.projectId, or a topic of the form 'projects/{project}/topics/{topic}', when the credentials do not include a project", Description: "the project of the Pub/Sub topic could not be determined"},
+	{Name: "EventStreamsPubSubInvalidOrderingKey", Code: EventStreamsPubSubInvalidOrderingKey, Message: "Invalid pubsub.orderingKey '%s' - must be none, stream, subscription, address or transaction", Description: "unknown ordering key selection for a Pub/Sub event stream"},
+	{Name: "EventStreamsPubSubFailedHTTPStatus", Code: EventStreamsPubSubFailedHTTPStatus, Message: "%s: Pub/Sub publish failed with status=%d", Description: "Pub/Sub rejected a publish request"},
+	{Name: "GCPCredentialsInvalid", Code: GCPCredentialsInvalid, Message: "Invalid GCP credentials file '%s': %s", Description: "the Google credentials file could not be loaded"},
+	{Name: "GCPCredentialsNoPrivateKey", Code: GCPCredentialsNoPrivateKey, Message: "GCP service account credentials do not contain a PEM encoded RSA private key", Description: "the service account credentials do not contain an RSA private key"},
+	{Name: "GCPTokenFailed", Code: GCPTokenFailed, Message: "Failed to obtain GCP access token: %s", Description: "failed to obtain an access token for GCP"},
+	{Name: "S3MissingBucket", Code: S3MissingBucket, Message: "S3 bucket must be configured", Description: "no bucket configured for S3"},
+	{Name: "S3MissingCredentials", Code: S3MissingCredentials, Message: "S3 credentials must be configured, or set in AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY", Description: "no credentials configured or in the environment for S3"},
+	{Name: "S3RequestFailed", Code: S3RequestFailed, Message: "S3 %s of '%s' failed with status %d: %s", Description: "non-success status from S3"},
+	{Name: "ReceiptArchiveNotSupported", Code: ReceiptArchiveNotSupported, Message: "The receipt store does not support archiving receipts", Description: "the receipt store cannot list receipts oldest first"},
+	{Name: "ReceiptArchiveNotEnabled", Code: ReceiptArchiveNotEnabled, Message: "Receipt archiving is not enabled", Description: "request for an archived batch when archiving is disabled"},
+	{Name: "ReceiptArchiveInvalidBatch", Code: ReceiptArchiveInvalidBatch, Message: "Invalid receipt archive batch '%s'", Description: "the batch ID is not in the format we write"},
+	{Name: "ReceiptArchiveBatchNotFound", Code: ReceiptArchiveBatchNotFound, Message: "Receipt archive batch '%s' not found", Description: "the batch does not exist in the archive"},
+	{Name: "ReceiptArchiveReadFailed", Code: ReceiptArchiveReadFailed, Message: "Failed to read receipt archive batch '%s': %s", Description: "failed to read a batch from the archive"},
+	{Name: "IdempotencyConfigPathMissing", Code: IdempotencyConfigPathMissing, Message: "A path for the reservation store must be configured when idempotency keys are enabled", Description: "the reservation store path is required when idempotency keys are enabled"},
+	{Name: "IdempotencyStoreFailed", Code: IdempotencyStoreFailed, Message: "Reservation store failed: %s", Description: "the reservation store could not be read or written"},
+	{Name: "IdempotencyKeyInUse", Code: IdempotencyKeyInUse, Message: "The id '%s' has already been used", Description: "the client supplied ID has already been used, or is reserved"},
+	{Name: "IdempotencyReservationNotFound", Code: IdempotencyReservationNotFound, Message: "No reservation found for id '%s'", Description: "no unexpired reservation exists for the ID"},
+	{Name: "IdempotencyInvalidID", Code: IdempotencyInvalidID, Message: "Invalid id '%s' - only alphanumeric characters and '-' are allowed", Description: "the client supplied ID contains characters that are not allowed"},
+	{Name: "RawTxnInvalid", Code: RawTxnInvalid, Message: "Invalid raw transaction: %s", Description: "the raw transaction could not be decoded"},
+	{Name: "RawTxnNotReplayProtected", Code: RawTxnNotReplayProtected, Message: "Raw transaction is not replay protected. It must be signed with a chain ID (EIP-155)", Description: "the raw transaction was signed without a chain ID, so could be replayed on any chain"},
+	{Name: "RawTxnChainIDMismatch", Code: RawTxnChainIDMismatch, Message: "Raw transaction is signed for chain ID %s, but the node is on chain ID %s", Description: "the raw transaction was signed for a different chain"},
+	{Name: "RawTxnInvalidSignature", Code: RawTxnInvalidSignature, Message: "Unable to recover the signer of the raw transaction: %s", Description: "the signer could not be recovered from the raw transaction"},
+	{Name: "RawTxnSenderMismatch", Code: RawTxnSenderMismatch, Message: "Raw transaction is signed by %s, not by '%s'", Description: "the raw transaction was signed by a different address to the one supplied"},
+	{Name: "RawTxnNonceUsed", Code: RawTxnNonceUsed, Message: "Nonce %d has already been used by %s. The next nonce is %d", Description: "the nonce of the raw transaction has already been used, so it is a replay"},
+	{Name: "SecurityModuleNoReceiptAdminSupport", Code: SecurityModuleNoReceiptAdminSupport, Message: "The configured security module does not support deleting replies", Description: "the security module does not implement the receipt admin extension"},
+	{Name: "ReceiptStoreFailedDelete", Code: ReceiptStoreFailedDelete, Message: "Error deleting replies: %s", Description: "the persistence layer failed to delete replies"},
+	{Name: "ReceiptStorePurgeInvalidRequest", Code: ReceiptStorePurgeInvalidRequest, Message: "A purge must specify 'olderThan' or a list of 'ids'", Description: "a purge must be restricted by age or by ID"},
+	{Name: "TransactionCancelNonceRequired", Code: TransactionCancelNonceRequired, Message: "A nonce must be supplied to cancel a transaction", Description: "a cancel transaction must target a specific nonce"},
+	{Name: "TransactionSendCancelled", Code: TransactionSendCancelled, Message: "Request cancelled before it was submitted to the node", Description: "the request was withdrawn while waiting to be sent"},
+	{Name: "TransactionCancelNotQueued", Code: TransactionCancelNotQueued, Message: "Request '%s' is not queued for submission", Description: "no queued request with the ID"},
+	{Name: "TransactionCancelAlreadySubmitted", Code: TransactionCancelAlreadySubmitted, Message: "Request '%s' has already been submitted to the node", Description: "the request has already been passed to the node"},
+	{Name: "WebhooksCancelNotSupported", Code: WebhooksCancelNotSupported, Message: "Requests can only be cancelled when transactions are sent directly to the node", Description: "requests dispatched over Kafka are not held in this process"},
+	{Name: "ConfigUnknownKeys", Code: ConfigUnknownKeys, Message: "Unknown configuration keys in %s: %s", Description: "the config file contains keys that do not map to a setting"},
+	{Name: "ConfigUnsupportedVersion", Code: ConfigUnsupportedVersion, Message: "Configuration version %d is not supported. Supported versions: 1-%d", Description: "the config file is for a newer (or invalid) schema version"},
+	{Name: "ConfigEnvOverlayUnknownField", Code: ConfigEnvOverlayUnknownField, Message: "Environment variable %s does not match a configuration key at '%s'", Description: "an overlay environment variable does not map to a setting"},
+	{Name: "ConfigEnvOverlayBadValue", Code: ConfigEnvOverlayBadValue, Message: "Environment variable %s has an invalid value: %s", Description: "an overlay environment variable cannot be converted to the type of the setting"},
+	{Name: "ReceiptStoreSummaryNotSupported", Code: ReceiptStoreSummaryNotSupported, Message: "The configured receipt store does not support summaries", Description: "the persistence layer cannot count receipts by type"},
+	{Name: "ReceiptStoreInvalidSummaryWindow", Code: ReceiptStoreInvalidSummaryWindow, Message: "Invalid summary window '%s'. Windows must be durations such as '15m' or '24h', or 'all'", Description: "a summary window is not a duration"},
+	{Name: "ReceiptStoreFailedSummary", Code: ReceiptStoreFailedSummary, Message: "Error summarizing receipts: %s", Description: "the persistence layer failed to count receipts"},
+	{Name: "RESTGatewaySimulateDeployUnsupported", Code: RESTGatewaySimulateDeployUnsupported, Message: "Simulation is not supported for contract deployment", Description: "simulation was requested for a contract deployment"},
+	{Name: "ReceiptStoreNamespaceNotSupported", Code: ReceiptStoreNamespaceNotSupported, Message: "The configured receipt store does not support namespaces", Description: "the persistence layer cannot filter receipts by namespace"},
+	{Name: "ReceiptStoreNamespaceRestricted", Code: ReceiptStoreNamespaceRestricted, Message: "This operation spans all namespaces, and is not available to callers restricted to namespace '%s'", Description: "the operation spans all namespaces"},
+	{Name: "WebSocketSendBufferFull", Code: WebSocketSendBufferFull, Message: "Send buffer limit of %d messages reached for %s", Description: "a slow client did not keep up with the messages queued for it"},
+	{Name: "ReceiptStoreSSEUnsupported", Code: ReceiptStoreSSEUnsupported, Message: "Streaming responses are not supported on this connection", Description: "the HTTP connection cannot stream Server-Sent Events"},
+	{Name: "ReceiptStoreEncryptionKey", Code: ReceiptStoreEncryptionKey, Message: "Invalid receipt encryption key: %s", Description: "the key encryption key for receipts could not be loaded"},
+	{Name: "ReceiptStoreEncryptFailed", Code: ReceiptStoreEncryptFailed, Message: "Failed to encrypt receipt '%s': %s", Description: "a receipt could not be encrypted before it was written"},
+	{Name: "ReceiptStoreDecryptFailed", Code: ReceiptStoreDecryptFailed, Message: "Failed to decrypt receipt '%s': %s", Description: "a stored receipt could not be decrypted"},
+	{Name: "ReceiptStoreEncryptionUnsupported", Code: ReceiptStoreEncryptionUnsupported, Message: "The receipt store does not support %s", Description: "the store beneath the encryption layer does not support an optional operation"},
+	{Name: "ReceiptStoreVaultRequest", Code: ReceiptStoreVaultRequest, Message: "Vault transit %s request failed: %s", Description: "a request to the Vault transit engine failed"},
+	{Name: "ReceiptStoreInvalidSummaryGroup", Code: ReceiptStoreInvalidSummaryGroup, Message: "Invalid summary groupBy '%s'. Must be one of: %s", Description: "the groupBy parameter of a summary query is not a field that can be grouped"},
+	{Name: "ReceiptStoreGroupSummaryNotSupported", Code: ReceiptStoreGroupSummaryNotSupported, Message: "The configured receipt store does not support grouped summaries", Description: "the persistence layer cannot group receipts in a summary"},
+	{Name: "RESTGatewayUnknownFields", Code: RESTGatewayUnknownFields, Message: "Unknown field(s) in body for method '%s': %s", Description: "the body of a method invocation contained fields that are not inputs of the method"},
+	{Name: "RESTGatewayLocalStoreABIInUse", Code: RESTGatewayLocalStoreABIInUse, Message: "ABI %s is in use by contract instance %s", Description: "an ABI cannot be deleted while a contract instance is registered with it"},
+	{Name: "LintUnsupportedType", Code: LintUnsupportedType, Message: "Requests of type '%s' cannot be linted. Must be one of: %s", Description: "the request type cannot be checked by the lint endpoint"},
+	{Name: "LintMissingField", Code: LintMissingField, Message: "Missing required field '%s'", Description: "a field required to submit the request is not set"},
+	{Name: "LintMethodNotFound", Code: LintMethodNotFound, Message: "Method '%s' is not declared in the ABI registered for contract %s", Description: "the method of a transaction is not declared in the ABI registered for the contract"},
+	{Name: "LintMethodNotResolved", Code: LintMethodNotResolved, Message: "No ABI is available for method '%s', so parameters will be typed from their JSON values", Description: "there is no ABI for the method of a transaction, so parameters cannot be checked"},
+	{Name: "LintGasLow", Code: LintGasLow, Message: "Gas %d is below the intrinsic cost of a transaction (%d)", Description: "the gas supplied is less than the minimum cost of any transaction"},
+	{Name: "LintGasHigh", Code: LintGasHigh, Message: "Gas %d exceeds %d, which is above the block gas limit of most networks", Description: "the gas supplied is more than most networks allow in a block"},
+	{Name: "LintValueNotPayable", Code: LintValueNotPayable, Message: "Value %s is sent to '%s', which is not payable", Description: "value is sent to a method or constructor that is not payable, so the transaction will revert"},
+	{Name: "LintConstantMethod", Code: LintConstantMethod, Message: "Method '%s' is %s, so sending it as a transaction does not change state - use a query instead", Description: "a view or pure method is sent as a transaction"},
+	{Name: "LintSolidityNotCompiled", Code: LintSolidityNotCompiled, Message: "Constructor parameters are not checked for contracts supplied as Solidity source", Description: "a deployment supplies Solidity source, which is not compiled by the lint endpoint"},
+	{Name: "S3PreconditionFailed", Code: S3PreconditionFailed, Message: "S3 object was modified by another writer", Description: "a conditional write to S3 failed, as another writer changed the object"},
+	{Name: "RESTGatewayContractUpdateInvalid", Code: RESTGatewayContractUpdateInvalid, Message: "Invalid contract instance update: %s", Description: "the body of a request to update a contract instance registration is invalid"},
+	{Name: "RESTGatewayInvalidListingParam", Code: RESTGatewayInvalidListingParam, Message: "Invalid '%s' query parameter: %s", Description: "a paging or filtering parameter of a contract or ABI listing is invalid"},
+	{Name: "EventStreamsSubscribeBadGetLogsConf", Code: EventStreamsSubscribeBadGetLogsConf, Message: "Invalid getLogs configuration. Ranges and backoff times cannot be negative, maxBackoffMS cannot be less than initialBackoffMS, and backoffFactor must be at least 1", Description: "the eth_getLogs retry configuration of a subscription is invalid"},
+	{Name: "RemoteRegistryPublishFailed", Code: RemoteRegistryPublishFailed, Message: "Failed to publish gateway to remote registry: %s", Description: "error publishing a local ABI as a gateway in the remote contract registry"},
+	{Name: "RESTGatewayPublishABIInvalid", Code: RESTGatewayPublishABIInvalid, Message: "Invalid request to publish ABI: %s", Description: "the body of a request to publish an ABI to the remote registry is invalid"},
+	{Name: "RESTGatewayInvalidSignature", Code: RESTGatewayInvalidSignature, Message: "Invalid %s signature '%s'. Supply a signature such as 'transfer(address,uint256)', or a hex selector", Description: "a method or event to search for is neither a valid signature nor a selector"},
+	{Name: "RESTGatewayImportABIInvalid", Code: RESTGatewayImportABIInvalid, Message: "Invalid request to import ABI: %s", Description: "the body of a request to import a verified ABI is invalid"},
+	{Name: "ABIImportNoSource", Code: ABIImportNoSource, Message: "No service is configured to import verified contracts on chain %s", Description: "no service is configured to import verified contracts from, for the chain"},
+	{Name: "ABIImportNotVerified", Code: ABIImportNotVerified, Message: "Contract %s on chain %s is not verified on %s", Description: "the contract is not verified on any of the configured services"},
+	{Name: "ABIImportFailed", Code: ABIImportFailed, Message: "Failed to import ABI from %s: %s", Description: "the metadata of a verified contract could not be read"},
+	{Name: "WebSocketInvalidRequest", Code: WebSocketInvalidRequest, Message: "Invalid JSON-RPC request: %s", Description: "a message from a client using the JSON-RPC WebSocket protocol is not a valid request"},
+	{Name: "WebSocketUnknownMethod", Code: WebSocketUnknownMethod, Message: "Unknown method '%s'", Description: "a client using the JSON-RPC WebSocket protocol called a method that does not exist"},
+	{Name: "RESTGatewayEventQueryInvalid", Code: RESTGatewayEventQueryInvalid, Message: "Invalid event query: %s", Description: "the parameters of an ad hoc event query are invalid"},
+	{Name: "RESTGatewayEventQueryRangeTooLarge", Code: RESTGatewayEventQueryRangeTooLarge, Message: "Block range %d-%d exceeds the maximum of %d blocks for an event query", Description: "the block range of an ad hoc event query is too large"},
+	{Name: "RESTGatewayEventQueryUnknownEvent", Code: RESTGatewayEventQueryUnknownEvent, Message: "Event '%s' not found in ABI '%s'", Description: "the event of an ad hoc event query is not in the ABI of the contract"},
+	{Name: "CompilerSerializeUserDocs", Code: CompilerSerializeUserDocs, Message: "Serializing UserDoc: %s", Description: "could not serialize the user docs output from solc"},
+	{Name: "TransactionRelayNotConfigured", Code: TransactionRelayNotConfigured, Message: "Meta-transaction relay is not configured. Set a forwarder address", Description: "a meta-transaction was submitted without a forwarder configured"},
+	{Name: "TransactionRelayInvalid", Code: TransactionRelayInvalid, Message: "Invalid meta-transaction: %s", Description: "the forward request of a meta-transaction is incomplete"},
+	{Name: "TransactionRelayVerifyFailed", Code: TransactionRelayVerifyFailed, Message: "The forwarder rejected the signature of the meta-transaction from %s with nonce %s", Description: "the forwarder rejected the signature or nonce of a meta-transaction"},
+	{Name: "RESTGatewayABIVersionInvalid", Code: RESTGatewayABIVersionInvalid, Message: "Invalid ABI version '%s'. Register ABIs as name@version, such as erc20token@1.2.0", Description: "the name and version to register an ABI under could not be parsed"},
+	{Name: "RESTGatewayABIVersionClash", Code: RESTGatewayABIVersionClash, Message: "ABI %s is already registered as '%s@%s'", Description: "the version of a named ABI is already registered"},
+	{Name: "RegistryNotifierUnknownType", Code: RegistryNotifierUnknownType, Message: "Unknown type '%s' for registry notifier '%s'", Description: "the registry notifier type is not one we support"},
+	{Name: "RegistryNotifierMissingConfig", Code: RegistryNotifierMissingConfig, Message: "Registry notifier '%s' requires '%s' to be configured", Description: "a required setting for the registry notifier is missing"},
+	{Name: "RegistryNotifierHTTPStatus", Code: RegistryNotifierHTTPStatus, Message: "Registry notifier '%s' received status %d from %s", Description: "the webhook rejected a registry notification"},
+	{Name: "RESTGatewayReadStoragePathFailed", Code: RESTGatewayReadStoragePathFailed, Message: "Failed to read storage path %s: %s", Description: "the storage path could not be scanned for ABI and contract files"},
+	{Name: "RegistryReplicationMissingConfig", Code: RegistryReplicationMissingConfig, Message: "Registry replication requires '%s' to be configured", Description: "a required setting for registry replication is missing"},
+	{Name: "ReceiptStoreRevisionConflict", Code: ReceiptStoreRevisionConflict, Message: "Receipt %s has been updated since revision %d was read", Description: "a receipt was changed by another writer since it was read"},
+	{Name: "RESTGatewayBulkABINoArtifacts", Code: RESTGatewayBulkABINoArtifacts, Message: "No contract artifacts with an ABI were found in the uploaded files", Description: "none of the files uploaded in bulk were contract artifacts"},
+	{Name: "RESTGatewayBulkABIInvalidArtifact", Code: RESTGatewayBulkABIInvalidArtifact, Message: "Invalid contract artifact: %s", Description: "a contract artifact uploaded in bulk could not be parsed"},
+	{Name: "LoadTestInvalidConfig", Code: LoadTestInvalidConfig, Message: "Invalid load test options: %s", Description: "the options for the load test are incomplete or inconsistent"},
+	{Name: "LoadTestGatewayNotReady", Code: LoadTestGatewayNotReady, Message: "Gateway at %s was not ready within %s: %s", Description: "the gateway did not respond before the load test started"},
+	{Name: "LoadTestSetupFailed", Code: LoadTestSetupFailed, Message: "Failed to %s for the load test: %s", Description: "the event stream or subscription for an events load test could not be created"},
+	{Name: "KafkaRetryBufferFull", Code: KafkaRetryBufferFull, Message: "Kafka is unavailable, and %d messages are already buffered to resend when it reconnects", Description: "the buffer of messages waiting for Kafka to reconnect is full, and the policy is to park new messages"},
+	{Name: "ConfigKafkaRetryBufferPolicy", Code: ConfigKafkaRetryBufferPolicy, Message: "Invalid Kafka retry buffer policy '%s'. Must be 'drop' or 'park'", Description: "the policy for a full Kafka retry buffer is not one we support"},
+	{Name: "RESTGatewayArtifactNotSelected", Code: RESTGatewayArtifactNotSelected, Message: "Found %d contract artifacts. Select one with the 'contract' parameter: %s", Description: "more than one contract artifact was uploaded, and none was selected"},
+	{Name: "RESTGatewayArtifactNotFound", Code: RESTGatewayArtifactNotFound, Message: "Contract '%s' not found in the uploaded artifacts", Description: "the selected contract was not among the uploaded artifacts"},
+	{Name: "ReplyModeInvalid", Code: ReplyModeInvalid, Message: "Invalid reply mode '%s'. Must be one of: kafka, webhook, both, none", Description: "the reply mode on a request is not recognized"},
+	{Name: "ReplyModeWebhookRequired", Code: ReplyModeWebhookRequired, Message: "An http or https replyWebhook URL is required with reply mode '%s'", Description: "a reply mode that delivers to a webhook was requested without the URL"},
+	{Name: "ReplyWebhookUnsafeAddress", Code: ReplyWebhookUnsafeAddress, Message: "Reply webhook '%s' resolves to a private address, which is not allowed", Description: "the reply webhook resolves to a private address, which is not allowed"},
+	{Name: "ReplyWebhookHTTPStatus", Code: ReplyWebhookHTTPStatus, Message: "Reply webhook '%s' returned status %d", Description: "the reply webhook returned a non-OK response"},
+	{Name: "RegistryNotifierNoWebSocketServer", Code: RegistryNotifierNoWebSocketServer, Message: "Registry notifier '%s' requires a WebSocket server", Description: "a WebSocket registry notifier is configured where there is no WebSocket server"},
+	{Name: "MigrationStateInvalid", Code: MigrationStateInvalid, Message: "Failed to read migration state '%s': %s", Description: "the migration state file of a data directory could not be read"},
+	{Name: "MigrationBackupFailed", Code: MigrationBackupFailed, Message: "Failed to back up %s to '%s': %s", Description: "the data directory could not be backed up before migrating"},
+	{Name: "MigrationFailed", Code: MigrationFailed, Message: "Migration %d (%s) of %s failed: %s", Description: "a migration failed, and the data was restored from the backup"},
+	{Name: "MigrationNoBackup", Code: MigrationNoBackup, Message: "No backup of %s found in '%s' to roll back to", Description: "a rollback was requested with no backup to restore"},
+	{Name: "MigrationRestoreFailed", Code: MigrationRestoreFailed, Message: "Failed to restore %s from backup '%s': %s", Description: "a backup could not be restored"},
+	{Name: "MigrationVersionAhead", Code: MigrationVersionAhead, Message: "The data of %s is at migration version %d, which is newer than the latest known version %d", Description: "the data was migrated by a newer version of ethconnect"},
+	{Name: "RESTGatewayInvalidLabel", Code: RESTGatewayInvalidLabel, Message: "Invalid label '%s'", Description: "a label of a contract or ABI is not a valid key=value pair"},
+	{Name: "WebSocketConnectionNotFound", Code: WebSocketConnectionNotFound, Message: "WebSocket connection '%s' not found", Description: "the WebSocket connection requested for its statistics is not connected"},
+	{Name: "TransactionRequestAbandoned", Code: TransactionRequestAbandoned, Message: "Processing of the request was abandoned: %s", Description: "the caller went away, or the processor shut down, before the request completed"},
+	{Name: "ContractStoreArchiveInvalid", Code: ContractStoreArchiveInvalid, Message: "Invalid contract store archive: %s", Description: "the archive supplied to import into the contract store cannot be used"},
+	{Name: "ContractStoreImportConflict", Code: ContractStoreImportConflict, Message: "The archive conflicts with %d existing entries in the contract store: %s", Description: "entries in the archive clash with those already in the contract store"},
+	{Name: "ContractStoreImportMissingABI", Code: ContractStoreImportMissingABI, Message: "Contract instance '%s' in the archive uses ABI '%s', which is not in the archive or the contract store", Description: "a contract instance in the archive uses an ABI that is not available"},
+	{Name: "RESTGatewayBulkSubscribeInvalid", Code: RESTGatewayBulkSubscribeInvalid, Message: "Invalid request to subscribe to the events of an ABI: %s", Description: "the request to subscribe to the events of an ABI could not be parsed"},
+	{Name: "RESTGatewayBulkSubscribeFailed", Code: RESTGatewayBulkSubscribeFailed, Message: "Failed to subscribe to event '%s', and the %d subscriptions created before it were removed: %s", Description: "one of the subscriptions for the events of an ABI failed, and the others were rolled back"},
+	{Name: "DeployTransactionMissingLibrary", Code: DeployTransactionMissingLibrary, Message: "The contract must be linked to library '%s' - supply the address it is deployed at in 'libraries'", Description: "the bytecode refers to a library, and no address was supplied to link it to"},
+	{Name: "DeployTransactionInvalidLibraryAddress", Code: DeployTransactionInvalidLibraryAddress, Message: "Invalid address '%s' for library '%s'", Description: "the address supplied to link a library to is not an address"},
+	{Name: "DeployTransactionInvalidLinkReference", Code: DeployTransactionInvalidLinkReference, Message: "The reference to library '%s' at offset %d is outside the bytecode", Description: "a link reference stored with the bytecode does not fit within it"},
+	{Name: "RESTGatewayInvalidLibrary", Code: RESTGatewayInvalidLibrary, Message: "Invalid library '%s' - supply the name and address of the library as name=address, or name=registeredContract", Description: "a library to link a deployment to is not of the form name=address"},
+	{Name: "ABIValidateInvalidBody", Code: ABIValidateInvalidBody, Message: "Unable to read an ABI to validate - supply a JSON ABI, a build artifact or solc output: %s", Description: "the body to validate is not an ABI, build artifact or compiler output"},
+	{Name: "ABIValidateUnknownType", Code: ABIValidateUnknownType, Message: "Unknown element type '%s'", Description: "an element of the ABI has a type that is not in the ABI specification"},
+	{Name: "ABIValidateMissingName", Code: ABIValidateMissingName, Message: "The %s has no name", Description: "a function, event or error in the ABI has no name"},
+	{Name: "ABIValidateInvalidElement", Code: ABIValidateInvalidElement, Message: "Invalid %s '%s': %s", Description: "the inputs or outputs of an element of the ABI cannot be parsed"},
+	{Name: "ABIValidateDuplicate", Code: ABIValidateDuplicate, Message: "Duplicate %s '%s'", Description: "an element of the ABI is declared more than once with the same signature"},
+	{Name: "ABIValidateSelectorClash", Code: ABIValidateSelectorClash, Message: "Function '%s' has the same selector %s as '%s'", Description: "two functions of the ABI have different signatures with the same selector"},
+	{Name: "ABIValidateTooManyIndexed", Code: ABIValidateTooManyIndexed, Message: "Event '%s' has %d indexed inputs, and at most %d are allowed", Description: "an event of the ABI has more indexed inputs than there are topics for"},
+	{Name: "ABIValidateTypeAlias", Code: ABIValidateTypeAlias, Message: "The type '%s' of '%s' is an alias for '%s'", Description: "an ABI argument has a Solidity type alias, which is not valid in an ABI"},
+	{Name: "ContractStoreUnknownContentHash", Code: ContractStoreUnknownContentHash, Message: "Unknown content hash algorithm '%s'", Description: "the configured content hash algorithm is not registered"},
+	{Name: "EventStreamsAMQPNoAddress", Code: EventStreamsAMQPNoAddress, Message: "Must specify amqp.url and amqp.address for action type 'amqp'", Description: "attempt to create an AMQP event stream without a broker URL or target address"},
+	{Name: "AMQPInvalidURL", Code: AMQPInvalidURL, Message: "Invalid AMQP URL '%s' - must be of the form amqp://host:port or amqps://host:port", Description: "the broker URL is not an amqp or amqps URL"},
+	{Name: "AMQPConnectFailed", Code: AMQPConnectFailed, Message: "Failed to connect to AMQP broker %s: %s", Description: "failed to establish a connection to the broker"},
+	{Name: "AMQPProtocolError", Code: AMQPProtocolError, Message: "AMQP protocol error: %s", Description: "the broker sent a frame that could not be handled"},
+	{Name: "AMQPAuthFailed", Code: AMQPAuthFailed, Message: "AMQP broker %s rejected authentication (SASL outcome %d)", Description: "the broker did not accept the SASL credentials"},
+	{Name: "AMQPRemoteClosed", Code: AMQPRemoteClosed, Message: "AMQP broker closed the %s: %s", Description: "the broker closed the connection, session or link"},
+	{Name: "AMQPConnectionClosed", Code: AMQPConnectionClosed, Message: "AMQP connection closed", Description: "the connection was closed locally, or lost"},
+	{Name: "AMQPDeliveryRejected", Code: AMQPDeliveryRejected, Message: "AMQP broker rejected message %s: %s", Description: "the broker rejected a message"},
+	{Name: "AMQPDeliveryReleased", Code: AMQPDeliveryReleased, Message: "AMQP broker released message %s without accepting it", Description: "the broker released or modified a message without accepting it"},
+	{Name: "AMQPTimeout", Code: AMQPTimeout, Message: "Timed out waiting for AMQP broker %s", Description: "timed out waiting for the broker"},
+	{Name: "EventStreamsActionPanic", Code: EventStreamsActionPanic, Message: "Event stream action failed unexpectedly: %v", Description: "the action of an event stream panicked while delivering a batch"},
+	{Name: "EventStreamsNATSNoSubject", Code: EventStreamsNATSNoSubject, Message: "Must specify nats.url and nats.subject for action type 'nats'", Description: "attempt to create a NATS event stream without a server URL or subject"},
+	{Name: "NATSInvalidURL", Code: NATSInvalidURL, Message: "Invalid NATS URL '%s' - must be of the form nats://host:port or tls://host:port", Description: "the server URL is not a nats or tls URL"},
+	{Name: "NATSConnectFailed", Code: NATSConnectFailed, Message: "Failed to connect to NATS server %s: %s", Description: "failed to establish a connection to the server"},
+	{Name: "NATSProtocolError", Code: NATSProtocolError, Message: "NATS protocol error: %s", Description: "the server sent something that could not be handled"},
+	{Name: "NATSServerError", Code: NATSServerError, Message: "NATS server error: %s", Description: "the server reported an error, such as an authorization failure"},
+	{Name: "NATSConnectionClosed", Code: NATSConnectionClosed, Message: "NATS connection closed", Description: "the connection was closed locally, or lost"},
+	{Name: "NATSPublishFailed", Code: NATSPublishFailed, Message: "JetStream did not store message %s: %s", Description: "JetStream returned an error instead of acknowledging a message"},
+	{Name: "NATSNoResponders", Code: NATSNoResponders, Message: "No JetStream stream is listening on subject '%s'", Description: "no JetStream stream is bound to the subject"},
+	{Name: "NATSTimeout", Code: NATSTimeout, Message: "Timed out waiting for NATS server %s", Description: "timed out waiting for the server"},
+	{Name: "NATSPayloadTooLarge", Code: NATSPayloadTooLarge, Message: "Message of %d bytes exceeds the max_payload of %d bytes of the NATS server", Description: "a message is larger than the server accepts"},
+	{Name: "UnitsInvalidAmount", Code: UnitsInvalidAmount, Message: "Invalid amount '%s' - must be an integer, or a decimal number followed by a unit such as ether or gwei", Description: "an amount is neither an integer, nor a decimal number with a unit"},
+	{Name: "UnitsUnknown", Code: UnitsUnknown, Message: "Unknown unit '%s' - must be one of wei, kwei, mwei, gwei, szabo, finney, ether or token", Description: "an amount or output format uses a unit that is not known"},
+	{Name: "UnitsTooPrecise", Code: UnitsTooPrecise, Message: "Amount '%s' has more than the %d decimal places allowed by unit '%s'", Description: "an amount has more decimal places than its unit can represent"},
+	{Name: "UnitsNoTokenDecimals", Code: UnitsNoTokenDecimals, Message: "Cannot convert '%s' as no token decimals are configured for the contract", Description: "the token unit is used for a contract that has no token decimals configured"},
+	{Name: "EventStreamsSQSNoQueue", Code: EventStreamsSQSNoQueue, Message: "Must specify sqs.queueUrl for action type 'sqs'", Description: "attempt to create an SQS event stream without a queue URL"},
+	{Name: "EventStreamsSNSNoTopic", Code: EventStreamsSNSNoTopic, Message: "Must specify sns.topicArn for action type 'sns'", Description: "attempt to create an SNS event stream without a topic ARN"},
+	{Name: "EventStreamsAWSNoRegion", Code: EventStreamsAWSNoRegion, Message: "Could not determine the AWS region of '%s' - set the region of the event stream, or AWS_REGION", Description: "the region of the queue or topic could not be determined"},
+	{Name: "EventStreamsAWSFailedHTTPStatus", Code: EventStreamsAWSFailedHTTPStatus, Message: "%s: %s request failed with status=%d: %s", Description: "SQS or SNS rejected a request"},
+	{Name: "EventStreamsAWSEntriesFailed", Code: EventStreamsAWSEntriesFailed, Message: "%s: %d of %d messages rejected by %s: %s", Description: "some of the messages in a batch were rejected"},
+	{Name: "EventStreamsAWSMessageTooLarge", Code: EventStreamsAWSMessageTooLarge, Message: "%s: Event %s is %d bytes, which exceeds the limit of %d bytes for a message", Description: "an event is too large to send as a single message"},
+	{Name: "AWSCredentialsNotFound", Code: AWSCredentialsNotFound, Message: "No AWS credentials found - set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN", Description: "no credentials are available in the environment"},
+	{Name: "AWSAssumeRoleFailed", Code: AWSAssumeRoleFailed, Message: "Failed to assume AWS role with web identity: %s", Description: "the web identity token could not be exchanged for credentials"},
+	{Name: "WebSocketSubmitNotEnabled", Code: WebSocketSubmitNotEnabled, Message: "Submitting transactions is not enabled on this WebSocket server", Description: "a client submitted a transaction to a WebSocket server that cannot dispatch them"},
+	{Name: "WebSocketSubmitInvalid", Code: WebSocketSubmitInvalid, Message: "Invalid submit request: %s", Description: "a transaction submitted over a WebSocket is not a JSON object"},
+	{Name: "EventStreamsKafkaNoTopic", Code: EventStreamsKafkaNoTopic, Message: "Must specify kafka.brokers and kafka.topic for action type 'kafka'", Description: "attempt to create a Kafka event stream without brokers or a topic"},
+	{Name: "EventStreamsKafkaSendFailed", Code: EventStreamsKafkaSendFailed, Message: "%s: Failed to send %d of %d events to Kafka topic %s: %s", Description: "some of the events in a batch were not accepted by the Kafka brokers"},
+	{Name: "EventStreamsKafkaClosed", Code: EventStreamsKafkaClosed, Message: "Kafka producer for topic %s closed", Description: "a batch was sent after the Kafka action of the stream was closed"},
+	{Name: "EventStreamsMQTTNoTopic", Code: EventStreamsMQTTNoTopic, Message: "Must specify mqtt.url and mqtt.topic for action type 'mqtt'", Description: "attempt to create an MQTT event stream without a broker URL or topic"},
+	{Name: "EventStreamsMQTTInvalidTopic", Code: EventStreamsMQTTInvalidTopic, Message: "Invalid MQTT topic template '%s': %s", Description: "the topic template of an MQTT event stream could not be parsed"},
+	{Name: "EventStreamsMQTTInvalidQoS", Code: EventStreamsMQTTInvalidQoS, Message: "Invalid MQTT QoS %d - must be 0, 1 or 2", Description: "the QoS of an MQTT event stream is not 0, 1 or 2"},
+	{Name: "MQTTInvalidURL", Code: MQTTInvalidURL, Message: "Invalid MQTT URL '%s' - must be of the form mqtt://host:port or mqtts://host:port", Description: "the broker URL is not an mqtt or mqtts URL"},
+	{Name: "MQTTInvalidProtocolVersion", Code: MQTTInvalidProtocolVersion, Message: "Invalid MQTT protocol version '%s' - must be 3.1.1 or 5", Description: "the protocol version is not one that is supported"},
+	{Name: "MQTTConnectFailed", Code: MQTTConnectFailed, Message: "Failed to connect to MQTT broker %s: %s", Description: "failed to establish a connection to the broker"},
+	{Name: "MQTTConnectRejected", Code: MQTTConnectRejected, Message: "MQTT broker %s rejected the connection: %s", Description: "the broker refused the connection, such as for bad credentials"},
+	{Name: "MQTTProtocolError", Code: MQTTProtocolError, Message: "MQTT protocol error: %s", Description: "the broker sent something that could not be handled"},
+	{Name: "MQTTConnectionClosed", Code: MQTTConnectionClosed, Message: "MQTT connection closed", Description: "the connection was closed locally, or lost"},
+	{Name: "MQTTDisconnected", Code: MQTTDisconnected, Message: "MQTT broker %s disconnected: %s", Description: "the broker closed the connection with a reason code"},
+	{Name: "MQTTPublishFailed", Code: MQTTPublishFailed, Message: "MQTT broker did not accept message on topic '%s': %s", Description: "the broker returned an error reason code instead of accepting a message"},
+	{Name: "MQTTTimeout", Code: MQTTTimeout, Message: "Timed out waiting for MQTT broker %s", Description: "timed out waiting for the broker"},
+	{Name: "MQTTPacketTooLarge", Code: MQTTPacketTooLarge, Message: "Message of %d bytes exceeds the maximum packet size of %d bytes of the MQTT broker", Description: "a message is larger than the broker accepts"},
+	{Name: "MQTTQoSNotSupported", Code: MQTTQoSNotSupported, Message: "Message requested QoS %d, but the MQTT broker only supports up to QoS %d", Description: "a message requested a higher QoS than the broker supports"},
+	{Name: "MQTTInvalidTopicName", Code: MQTTInvalidTopicName, Message: "Invalid MQTT topic name '%s' - must not be empty or contain the wildcards '+' or '#'", Description: "a topic to publish to is empty, or contains a wildcard"},
+	{Name: "ContractRegistryNameClash", Code: ContractRegistryNameClash, Message: "Name '%s' is already registered to %s", Description: "a name to register is already taken in the local or remote registry"},
+	{Name: "ReplyProcessorUnknownType", Code: ReplyProcessorUnknownType, Message: "Unknown type '%s' for reply processor '%s'", Description: "the reply processor type is not one we support"},
+	{Name: "ReplyProcessorMissingConfig", Code: ReplyProcessorMissingConfig, Message: "Reply processor '%s' requires '%s' to be configured", Description: "a required setting for the reply processor is missing"},
+	{Name: "ReplyProcessorDuplicate", Code: ReplyProcessorDuplicate, Message: "Reply processor '%s' can only be included once in the chain", Description: "a built-in reply processor is configured more than once"},
+	{Name: "ReplyProcessorHTTPStatus", Code: ReplyProcessorHTTPStatus, Message: "Reply processor '%s' received status %d from %s", Description: "the HTTP endpoint rejected the reply"},
+	{Name: "ReplyProcessorInvalidReceipt", Code: ReplyProcessorInvalidReceipt, Message: "Failed to parse message as transaction receipt: %s", Description: "the reply could not be parsed as a transaction receipt"},
+	{Name: "EventStreamsGRPCNoURL", Code: EventStreamsGRPCNoURL, Message: "Must specify grpc.url for action type 'grpc'", Description: "attempt to create a gRPC event stream without a service URL"},
+	{Name: "EventStreamsGRPCBatchRejected", Code: EventStreamsGRPCBatchRejected, Message: "gRPC service rejected batch %d: %s", Description: "the gRPC service acked a batch with an error"},
+	{Name: "GRPCInvalidURL", Code: GRPCInvalidURL, Message: "Invalid gRPC URL '%s' - must be of the form grpc://host:port or grpcs://host:port", Description: "the service URL is not a grpc or grpcs URL"},
+	{Name: "GRPCRequestFailed", Code: GRPCRequestFailed, Message: "gRPC request to %s failed: %s", Description: "the Deliver call could not be made, or was refused at the HTTP level"},
+	{Name: "GRPCStatus", Code: GRPCStatus, Message: "gRPC service %s returned status %s: %s", Description: "the gRPC service ended the Deliver call with an error status"},
+	{Name: "GRPCInvalidMessage", Code: GRPCInvalidMessage, Message: "Invalid gRPC message: %s", Description: "the gRPC service sent a message that could not be read"},
+	{Name: "GRPCStreamClosed", Code: GRPCStreamClosed, Message: "gRPC stream closed", Description: "the stream was closed locally, or ended by the service"},
+	{Name: "GRPCTimeout", Code: GRPCTimeout, Message: "Timed out waiting for gRPC service %s", Description: "timed out waiting for the gRPC service"},
+	{Name: "EventStreamsExportJobsNotConfigured", Code: EventStreamsExportJobsNotConfigured, Message: "Export jobs require an output path or S3 bucket to be configured", Description: "no output location is configured for export jobs"},
+	{Name: "EventStreamsExportJobNotFound", Code: EventStreamsExportJobNotFound, Message: "Export job with ID '%s' not found", Description: "the export job does not exist"},
+	{Name: "RESTGatewayExportJobInvalid", Code: RESTGatewayExportJobInvalid, Message: "Invalid export job request: %s", Description: "failed to parse the request to start an export job"},
+	{Name: "EventStreamsPubSubPublishFailed", Code: EventStreamsPubSubPublishFailed, Message: "%s: Pub/Sub publish failed: %s", Description: "Pub/Sub did not accept every message of a batch"},
+	{Name: "EventStreamsPubSubClosed", Code: EventStreamsPubSubClosed, Message: "Pub/Sub client closed", Description: "the Pub/Sub client was closed, as the stream was stopped"},
+	{Name: "WebhooksCancelNotSubmitter", Code: WebhooksCancelNotSubmitter, Message: "Request '%s' can only be cancelled by its submitter, or a principal authorized for approvals", Description: "only the submitter, or an approver, can withdraw a request"},
+	{Name: "ReceiptStoreRevisionsNotSupported", Code: ReceiptStoreRevisionsNotSupported, Message: "The configured receipt store does not version receipts", Description: "the receipt store does not version receipts"},
+	{Name: "SecurityModuleNoAdminSupport", Code: SecurityModuleNoAdminSupport, Message: "The configured security module does not support the admin APIs", Description: "the security module does not implement the admin extension"},
+}
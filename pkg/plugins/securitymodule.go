@@ -36,3 +36,34 @@ type SecurityModule interface {
 	// AuthReadAsyncReplyByUUID - Authorization plugpoint for getting an individual reply by UUID (containing an individual receipt/error)
 	AuthReadAsyncReplyByUUID(authCtx interface{}) error
 }
+
+// ApprovalSecurityModule is an optional extension to SecurityModule, required to enforce the
+// four-eyes approval workflow for high-value submissions.
+type ApprovalSecurityModule interface {
+	// Principal - returns a stable identifier for the user or application behind an auth context, so the approver can be checked against the submitter
+	Principal(authCtx interface{}) string
+	// AuthApprovals - Authorization plugpoint for listing, approving and rejecting submissions that are pending approval
+	AuthApprovals(authCtx interface{}) error
+}
+
+// ReceiptAdminSecurityModule is an optional extension to SecurityModule, required to delete
+// replies from the reply store over the REST API when a security module is configured.
+type ReceiptAdminSecurityModule interface {
+	// AuthDeleteAsyncReplies - Authorization plugpoint for deleting individual replies, and purging replies by age or ID
+	AuthDeleteAsyncReplies(authCtx interface{}) error
+}
+
+// AdminSecurityModule is an optional extension to SecurityModule, required to use the admin
+// APIs that reindex, export and import the contract store when a security module is configured.
+type AdminSecurityModule interface {
+	// AuthAdmin - Authorization plugpoint for the admin APIs, which read or replace the whole contract store
+	AuthAdmin(authCtx interface{}) error
+}
+
+// NamespaceSecurityModule is an optional extension to SecurityModule, that partitions the reply
+// store between tenants. Replies to a caller's submissions are stored in their namespace, and
+// listing replies only returns those in the caller's namespace.
+type NamespaceSecurityModule interface {
+	// Namespace - returns the namespace of the caller behind an auth context, or an empty string for callers that can see replies in all namespaces
+	Namespace(authCtx interface{}) string
+}